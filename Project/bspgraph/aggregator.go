@@ -0,0 +1,123 @@
+package bspgraph
+
+import (
+	"math"
+	"sync"
+)
+
+// Aggregator is implemented by types that provide concurrent-safe
+// aggregation primitives (e.g. counters, min/max) that vertices can update
+// while a superstep is executing and that can be inspected by the executor
+// callbacks between supersteps.
+type Aggregator interface {
+	// Type returns the type of this aggregator.
+	Type() string
+
+	// Set the aggregator to the specified value.
+	Set(val interface{})
+
+	// Get the current aggregator value.
+	Get() interface{}
+
+	// Aggregate updates the aggregator's value based on the provided
+	// value.
+	Aggregate(val interface{})
+}
+
+// Float64Accumulator implements a concurrent-safe accumulator for float64
+// values.
+type Float64Accumulator struct {
+	mu  sync.Mutex
+	sum float64
+}
+
+// Type implements Aggregator.
+func (*Float64Accumulator) Type() string { return "Float64Accumulator" }
+
+// Get returns the current value of the accumulator.
+func (a *Float64Accumulator) Get() interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sum
+}
+
+// Set the current value of the accumulator.
+func (a *Float64Accumulator) Set(val interface{}) {
+	a.mu.Lock()
+	a.sum = val.(float64)
+	a.mu.Unlock()
+}
+
+// Aggregate adds a float64 value to the accumulator.
+func (a *Float64Accumulator) Aggregate(val interface{}) {
+	a.mu.Lock()
+	a.sum += val.(float64)
+	a.mu.Unlock()
+}
+
+// IntAccumulator implements a concurrent-safe accumulator for int values.
+type IntAccumulator struct {
+	mu  sync.Mutex
+	sum int
+}
+
+// Type implements Aggregator.
+func (*IntAccumulator) Type() string { return "IntAccumulator" }
+
+// Get returns the current value of the accumulator.
+func (a *IntAccumulator) Get() interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sum
+}
+
+// Set the current value of the accumulator.
+func (a *IntAccumulator) Set(val interface{}) {
+	a.mu.Lock()
+	a.sum = val.(int)
+	a.mu.Unlock()
+}
+
+// Aggregate adds an int value to the accumulator.
+func (a *IntAccumulator) Aggregate(val interface{}) {
+	a.mu.Lock()
+	a.sum += val.(int)
+	a.mu.Unlock()
+}
+
+// Float64Max keeps track of the largest float64 value it has been presented
+// with. Its zero value reports negative infinity.
+type Float64Max struct {
+	mu  sync.Mutex
+	set bool
+	max float64
+}
+
+// Type implements Aggregator.
+func (*Float64Max) Type() string { return "Float64Max" }
+
+// Get returns the current maximum.
+func (a *Float64Max) Get() interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.set {
+		return math.Inf(-1)
+	}
+	return a.max
+}
+
+// Set the current maximum.
+func (a *Float64Max) Set(val interface{}) {
+	a.mu.Lock()
+	a.max, a.set = val.(float64), true
+	a.mu.Unlock()
+}
+
+// Aggregate updates the maximum if val is larger than it.
+func (a *Float64Max) Aggregate(val interface{}) {
+	a.mu.Lock()
+	if v := val.(float64); !a.set || v > a.max {
+		a.max, a.set = v, true
+	}
+	a.mu.Unlock()
+}
@@ -0,0 +1,18 @@
+package bspgraph
+
+import "errors"
+
+var (
+	// ErrUnknownEdgeSource is returned by AddEdge when the source vertex
+	// is not present in the graph.
+	ErrUnknownEdgeSource = errors.New("source vertex is not part of the graph")
+
+	// ErrDestinationIsLocal is returned by Relayer instances to indicate
+	// that a message destination is actually owned by the local graph.
+	ErrDestinationIsLocal = errors.New("message destination is assigned to the local graph")
+
+	// ErrInvalidMessageDestination is returned by calls to SendMessage and
+	// BroadcastToNeighbors when the destination cannot be resolved to any
+	// (local or remote) vertex.
+	ErrInvalidMessageDestination = errors.New("invalid message destination")
+)
@@ -0,0 +1,171 @@
+package bspgraph
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(GraphTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type GraphTestSuite struct{}
+
+type intMsg struct {
+	value int
+}
+
+func (intMsg) Type() string { return "int" }
+
+func (s *GraphTestSuite) TestMessageExchange(c *gc.C) {
+	g, err := NewGraph(GraphConfig{
+		ComputeWorkers: 4,
+		ComputeFn: func(g *Graph, v *Vertex, msgIt MessageIterator) error {
+			if g.Superstep() == 0 {
+				return g.BroadcastToNeighbors(v, intMsg{value: v.Value().(int)})
+			}
+			for msgIt.Next() {
+				v.SetValue(v.Value().(int) + msgIt.Message().(intMsg).value)
+			}
+			v.Freeze()
+			return nil
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(g.Close(), gc.IsNil) }()
+
+	g.AddVertex("a", 1)
+	g.AddVertex("b", 10)
+	g.AddVertex("c", 100)
+	c.Assert(g.AddEdge("a", "c", nil), gc.IsNil)
+	c.Assert(g.AddEdge("b", "c", nil), gc.IsNil)
+	c.Assert(g.AddEdge("c", "a", nil), gc.IsNil)
+
+	c.Assert(NewExecutor(g, ExecutorCallbacks{}).RunSteps(context.TODO(), 2), gc.IsNil)
+	c.Assert(g.Vertices()["a"].Value(), gc.Equals, 101)
+	c.Assert(g.Vertices()["b"].Value(), gc.Equals, 10)
+	c.Assert(g.Vertices()["c"].Value(), gc.Equals, 111)
+}
+
+func (s *GraphTestSuite) TestConnectedComponents(c *gc.C) {
+	// Each vertex keeps track of the smallest vertex ID it has seen so far
+	// and propagates it to its neighbors whenever it changes. Edges are
+	// added in both directions to treat the graph as undirected.
+	g, err := NewGraph(GraphConfig{
+		ComputeWorkers: 4,
+		ComputeFn: func(g *Graph, v *Vertex, msgIt MessageIterator) error {
+			minID, changed := v.Value().(string), g.Superstep() == 0
+			for msgIt.Next() {
+				if id := string(msgIt.Message().(componentMsg)); id < minID {
+					minID, changed = id, true
+				}
+			}
+			v.SetValue(minID)
+			v.Freeze()
+			if !changed {
+				return nil
+			}
+			return g.BroadcastToNeighbors(v, componentMsg(minID))
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(g.Close(), gc.IsNil) }()
+
+	for _, id := range []string{"a", "b", "c", "d", "e", "f"} {
+		g.AddVertex(id, id)
+	}
+	for _, e := range [][2]string{{"a", "c"}, {"c", "b"}, {"d", "e"}, {"f", "e"}} {
+		c.Assert(g.AddEdge(e[0], e[1], nil), gc.IsNil)
+		c.Assert(g.AddEdge(e[1], e[0], nil), gc.IsNil)
+	}
+
+	ex := NewExecutor(g, ExecutorCallbacks{})
+	c.Assert(ex.RunToCompletion(context.TODO()), gc.IsNil)
+
+	exp := map[string]string{"a": "a", "b": "a", "c": "a", "d": "d", "e": "d", "f": "d"}
+	for id, v := range g.Vertices() {
+		c.Assert(v.Value(), gc.Equals, exp[id], gc.Commentf("vertex %s", id))
+	}
+}
+
+type componentMsg string
+
+func (componentMsg) Type() string { return "component" }
+
+func (s *GraphTestSuite) TestAggregatorsAndCallbacks(c *gc.C) {
+	g, err := NewGraph(GraphConfig{
+		ComputeFn: func(g *Graph, v *Vertex, _ MessageIterator) error {
+			g.Aggregator("count").Aggregate(1)
+			g.Aggregator("max").Aggregate(v.Value().(float64))
+			return nil
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(g.Close(), gc.IsNil) }()
+
+	g.AddVertex("a", 1.0)
+	g.AddVertex("b", 4.0)
+	g.RegisterAggregator("count", new(IntAccumulator))
+	g.RegisterAggregator("max", new(Float64Max))
+
+	var steps int
+	ex := NewExecutor(g, ExecutorCallbacks{
+		PreStep: func(_ context.Context, g *Graph) error {
+			g.Aggregator("count").Set(0)
+			return nil
+		},
+		PostStepKeepRunning: func(_ context.Context, g *Graph, activeInStep int) (bool, error) {
+			steps++
+			c.Assert(activeInStep, gc.Equals, 2)
+			c.Assert(g.Aggregator("count").Get(), gc.Equals, 2)
+			return g.Superstep() < 2, nil
+		},
+	})
+	c.Assert(ex.RunToCompletion(context.TODO()), gc.IsNil)
+	c.Assert(steps, gc.Equals, 3)
+	c.Assert(g.Aggregator("max").Get(), gc.Equals, 4.0)
+}
+
+func (s *GraphTestSuite) TestComputeError(c *gc.C) {
+	expErr := errors.New("boom")
+	g, err := NewGraph(GraphConfig{
+		ComputeFn: func(*Graph, *Vertex, MessageIterator) error { return expErr },
+	})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(g.Close(), gc.IsNil) }()
+
+	g.AddVertex("a", nil)
+	err = NewExecutor(g, ExecutorCallbacks{}).RunToCompletion(context.TODO())
+	c.Assert(errors.Is(err, expErr), gc.Equals, true)
+}
+
+func (s *GraphTestSuite) TestSendMessageToUnknownVertex(c *gc.C) {
+	g, err := NewGraph(GraphConfig{
+		ComputeFn: func(g *Graph, v *Vertex, _ MessageIterator) error {
+			return g.SendMessage("remote", intMsg{})
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(g.Close(), gc.IsNil) }()
+	g.AddVertex("a", nil)
+
+	err = NewExecutor(g, ExecutorCallbacks{}).RunSteps(context.TODO(), 1)
+	c.Assert(errors.Is(err, ErrInvalidMessageDestination), gc.Equals, true)
+
+	// Messages to unknown vertices are handed to the relayer.
+	var relayed []string
+	g.RegisterRelayer(RelayerFunc(func(dst string, _ Message) error {
+		relayed = append(relayed, dst)
+		return nil
+	}))
+	c.Assert(NewExecutor(g, ExecutorCallbacks{}).RunSteps(context.TODO(), 1), gc.IsNil)
+	c.Assert(relayed, gc.DeepEquals, []string{"remote"})
+}
+
+func (s *GraphTestSuite) TestMissingComputeFunc(c *gc.C) {
+	_, err := NewGraph(GraphConfig{})
+	c.Assert(err, gc.ErrorMatches, "(?s)graph config validation failed:.*compute function not specified.*")
+}
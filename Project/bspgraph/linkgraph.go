@@ -0,0 +1,97 @@
+package bspgraph
+
+import (
+	"fmt"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+)
+
+// LinkGraphSource defines the set of link graph operations required for
+// loading a snapshot of the link graph into a Graph.
+type LinkGraphSource interface {
+	// LinksAsOf returns an iterator for the set of links whose IDs belong
+	// to the [fromID, toID) range and had been added to the graph by the
+	// end of the specified crawl pass.
+	LinksAsOf(fromID, toID uuid.UUID, passID uint64) (graph.LinkIterator, error)
+
+	// EdgesAsOf returns an iterator for the set of edges whose source
+	// vertex IDs belong to the [fromID, toID) range and were present in
+	// the graph at the end of the specified crawl pass.
+	EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (graph.EdgeIterator, error)
+}
+
+// VertexInitFunc returns the initial value for the vertex that corresponds to
+// a link.
+type VertexInitFunc func(link *graph.Link) interface{}
+
+// EdgeFilterFunc returns true if a link graph edge is to be added to the
+// graph.
+type EdgeFilterFunc func(edge *graph.Edge) bool
+
+// LoadLinkGraph populates g with a vertex for each link in the [fromID, toID)
+// range and an edge for each link graph edge originating from it, as they
+// were at the end of the specified crawl pass. Vertices are keyed by the
+// string representation of the link ID and initialized via initFn; edges are
+// not annotated with a value. If filterFn is specified, only the edges that it
+// accepts are added.
+//
+// Edges whose destination lies outside the loaded range are still added to
+// the graph so that messages sent along them can be routed via a Relayer.
+//
+// Loading a snapshot once allows multiple algorithms to be executed against
+// it (after resetting the vertex values) without re-reading the link graph.
+func LoadLinkGraph(g *Graph, src LinkGraphSource, fromID, toID uuid.UUID, passID uint64, initFn VertexInitFunc, filterFn EdgeFilterFunc) error {
+	linkIt, err := src.LinksAsOf(fromID, toID, passID)
+	if err != nil {
+		return fmt.Errorf("load link graph: %w", err)
+	}
+	for linkIt.Next() {
+		link := linkIt.Link()
+		var initValue interface{}
+		if initFn != nil {
+			initValue = initFn(link)
+		}
+		g.AddVertex(link.ID.String(), initValue)
+	}
+	if err = linkIt.Error(); err != nil {
+		_ = linkIt.Close()
+		return fmt.Errorf("load link graph: %w", err)
+	}
+	if err = linkIt.Close(); err != nil {
+		return fmt.Errorf("load link graph: %w", err)
+	}
+
+	edgeIt, err := src.EdgesAsOf(fromID, toID, passID)
+	if err != nil {
+		return fmt.Errorf("load link graph: %w", err)
+	}
+	var ignoredEdges, filteredEdges int
+	for edgeIt.Next() {
+		edge := edgeIt.Edge()
+		// Edges whose source is not part of the snapshot (e.g. because
+		// the source link was added after the pass) are ignored.
+		if _, known := g.vertices[edge.Src.String()]; !known {
+			ignoredEdges++
+			continue
+		}
+		if filterFn != nil && !filterFn(edge) {
+			filteredEdges++
+			continue
+		}
+		if err = g.AddEdge(edge.Src.String(), edge.Dst.String(), nil); err != nil {
+			_ = edgeIt.Close()
+			return fmt.Errorf("load link graph: %w", err)
+		}
+	}
+	if err = edgeIt.Error(); err != nil {
+		_ = edgeIt.Close()
+		return fmt.Errorf("load link graph: %w", err)
+	}
+	if err = edgeIt.Close(); err != nil {
+		return fmt.Errorf("load link graph: %w", err)
+	}
+
+	g.logger.Debug("loaded link graph", "pass_id", passID, "vertices", len(g.vertices), "ignored_edges", ignoredEdges, "filtered_edges", filteredEdges)
+	return nil
+}
@@ -0,0 +1,100 @@
+package bspgraph
+
+import (
+	"context"
+	"math"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/store/memory"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(LinkGraphLoaderTestSuite))
+
+type LinkGraphLoaderTestSuite struct{}
+
+type distanceMsg int
+
+func (distanceMsg) Type() string { return "distance" }
+
+func (s *LinkGraphLoaderTestSuite) TestShortestPathsOverLinkGraphSnapshot(c *gc.C) {
+	lg := memory.NewInMemoryGraph()
+	links := make(map[string]*graph.Link)
+	for _, spec := range []struct {
+		url  string
+		pass uint64
+	}{{"a", 1}, {"b", 1}, {"c", 1}, {"d", 2}} {
+		link := &graph.Link{URL: spec.url, PassID: spec.pass}
+		c.Assert(lg.UpsertLink(link), gc.IsNil)
+		links[spec.url] = link
+	}
+	for _, spec := range []struct {
+		src, dst string
+		pass     uint64
+	}{{"a", "b", 1}, {"b", "c", 1}, {"a", "d", 2}, {"d", "c", 2}} {
+		c.Assert(lg.UpsertEdge(&graph.Edge{Src: links[spec.src].ID, Dst: links[spec.dst].ID, PassID: spec.pass}), gc.IsNil)
+	}
+
+	g, err := NewGraph(GraphConfig{
+		ComputeWorkers: 2,
+		ComputeFn: func(g *Graph, v *Vertex, msgIt MessageIterator) error {
+			dist := v.Value().(int)
+			for msgIt.Next() {
+				if d := int(msgIt.Message().(distanceMsg)); d < dist {
+					dist = d
+				}
+			}
+			changed := dist < v.Value().(int)
+			v.SetValue(dist)
+			v.Freeze()
+			if changed || (g.Superstep() == 0 && dist == 0) {
+				return g.BroadcastToNeighbors(v, distanceMsg(dist+1))
+			}
+			return nil
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(g.Close(), gc.IsNil) }()
+
+	// Load the snapshot at the end of pass 1; link d and its edges must
+	// not be visible.
+	srcID := links["a"].ID
+	initFn := func(link *graph.Link) interface{} {
+		if link.ID == srcID {
+			return 0
+		}
+		return math.MaxInt32
+	}
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	c.Assert(LoadLinkGraph(g, lg, uuid.Nil, maxUUID, 1, initFn, nil), gc.IsNil)
+	c.Assert(g.Vertices(), gc.HasLen, 3)
+
+	c.Assert(NewExecutor(g, ExecutorCallbacks{}).RunToCompletion(context.TODO()), gc.IsNil)
+	c.Assert(g.Vertices()[links["c"].ID.String()].Value(), gc.Equals, 2)
+}
+
+func (s *LinkGraphLoaderTestSuite) TestEdgeFilter(c *gc.C) {
+	lg := memory.NewInMemoryGraph()
+	links := make(map[string]*graph.Link)
+	for _, url := range []string{"a", "b", "c"} {
+		link := &graph.Link{URL: url}
+		c.Assert(lg.UpsertLink(link), gc.IsNil)
+		links[url] = link
+	}
+	c.Assert(lg.UpsertEdge(&graph.Edge{Src: links["a"].ID, Dst: links["b"].ID}), gc.IsNil)
+	c.Assert(lg.UpsertEdge(&graph.Edge{Src: links["a"].ID, Dst: links["c"].ID, NoFollow: true}), gc.IsNil)
+
+	g, err := NewGraph(GraphConfig{ComputeFn: func(*Graph, *Vertex, MessageIterator) error { return nil }})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(g.Close(), gc.IsNil) }()
+
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	followed := func(edge *graph.Edge) bool { return !edge.NoFollow }
+	c.Assert(LoadLinkGraph(g, lg, uuid.Nil, maxUUID, math.MaxInt64, nil, followed), gc.IsNil)
+	c.Assert(g.Vertices(), gc.HasLen, 3)
+
+	edges := g.Vertices()[links["a"].ID.String()].Edges()
+	c.Assert(edges, gc.HasLen, 1)
+	c.Assert(edges[0].DstID(), gc.Equals, links["b"].ID.String())
+}
@@ -0,0 +1,117 @@
+package bspgraph
+
+import "sync"
+
+// Message is implemented by types that can be exchanged between vertices.
+type Message interface {
+	// Type returns the type of this message.
+	Type() string
+}
+
+// Queue is implemented by types that can serve as message queues.
+type Queue interface {
+	// Enqueue inserts a message to the end of the queue.
+	Enqueue(msg Message) error
+
+	// PendingMessages returns true if the queue contains any messages.
+	PendingMessages() bool
+
+	// DiscardMessages flushes all messages currently in the queue.
+	DiscardMessages() error
+
+	// Messages returns an iterator for accessing the queued messages.
+	Messages() MessageIterator
+
+	// Close releases any resources associated with the queue.
+	Close() error
+}
+
+// MessageIterator provides an API for iterating a list of messages.
+type MessageIterator interface {
+	// Next advances the iterator so that the next message can be retrieved
+	// via a call to Message(). If no more messages are available or an
+	// error occurs, Next() returns false.
+	Next() bool
+
+	// Message returns the message currently pointed to by the iterator.
+	Message() Message
+
+	// Error returns the last error that the iterator encountered.
+	Error() error
+}
+
+// QueueFactory is a function that can create new Queue instances.
+type QueueFactory func() Queue
+
+// inMemoryQueue is a Queue implementation that stores messages in memory.
+// Messages can be enqueued concurrently but the returned iterator must not be
+// used while messages are still being enqueued.
+type inMemoryQueue struct {
+	mu   sync.Mutex
+	msgs []Message
+
+	latchedMsg Message
+}
+
+// NewInMemoryQueue creates a queue that stores messages in memory.
+func NewInMemoryQueue() Queue {
+	return new(inMemoryQueue)
+}
+
+// Enqueue implements Queue.
+func (q *inMemoryQueue) Enqueue(msg Message) error {
+	q.mu.Lock()
+	q.msgs = append(q.msgs, msg)
+	q.mu.Unlock()
+	return nil
+}
+
+// PendingMessages implements Queue.
+func (q *inMemoryQueue) PendingMessages() bool {
+	q.mu.Lock()
+	pending := len(q.msgs) != 0
+	q.mu.Unlock()
+	return pending
+}
+
+// DiscardMessages implements Queue.
+func (q *inMemoryQueue) DiscardMessages() error {
+	q.mu.Lock()
+	q.msgs = q.msgs[:0]
+	q.latchedMsg = nil
+	q.mu.Unlock()
+	return nil
+}
+
+// Close implements Queue.
+func (*inMemoryQueue) Close() error { return nil }
+
+// Messages implements Queue.
+func (q *inMemoryQueue) Messages() MessageIterator { return q }
+
+// Next implements MessageIterator. Messages are consumed in LIFO order.
+func (q *inMemoryQueue) Next() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	qLen := len(q.msgs)
+	if qLen == 0 {
+		q.latchedMsg = nil
+		return false
+	}
+
+	q.latchedMsg = q.msgs[qLen-1]
+	q.msgs = q.msgs[:qLen-1]
+	return true
+}
+
+// Message implements MessageIterator.
+func (q *inMemoryQueue) Message() Message {
+	q.mu.Lock()
+	msg := q.latchedMsg
+	q.mu.Unlock()
+	return msg
+}
+
+// Error implements MessageIterator.
+func (*inMemoryQueue) Error() error { return nil }
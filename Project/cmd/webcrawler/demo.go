@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"webcrawler/config"
+	"webcrawler/crawler"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/privnet"
+	"webcrawler/logging"
+	"webcrawler/service"
+)
+
+//go:embed demosite
+var demoSiteFS embed.FS
+
+// The query that the demo suggests to users and runs in smoke test mode. It
+// matches every page of the sample site.
+const demoQuery = "poet"
+
+// runDemo implements the "demo" command which runs the full stack on top of
+// in-memory stores: it serves an embedded sample site on the loopback
+// interface, crawls it, calculates the PageRank scores of its pages and then
+// serves the search frontend until the process receives SIGINT or SIGTERM. In
+// smoke test mode, the command instead checks that the search API returns
+// the crawled pages and exits. It returns the exit code for the process.
+func runDemo(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("demo", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	listenAddr := flags.String("listen-address", "127.0.0.1:8080", "address to serve the search frontend on")
+	open := flags.Bool("open", true, "open the search frontend in the default browser")
+	smoke := flags.Bool("smoke", false, "check that the crawled pages can be searched and exit")
+	logLevel := flags.String("log-level", "warn", `minimum log level; one of "debug", "info", "warn" or "error"`)
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintln(stderr, fmt.Errorf("unexpected arguments: %v", flags.Args()))
+		return 2
+	}
+
+	// The demo ignores the configuration file and always uses the
+	// in-memory stores so that it does not depend on any external
+	// services.
+	cfg, err := config.Load("", func(cfg *config.Config) {
+		cfg.LinkGraph.Backend = config.LinkGraphMemory
+		cfg.TextIndexer.Backend = config.TextIndexerMemory
+		cfg.TextIndexer.Backup.Enabled = false
+		cfg.PageRank.HistoryStore = config.PageRankHistoryMemory
+		cfg.Crawler.Robots.Store = config.RobotsStoreMemory
+		cfg.Partition.Detector = config.PartitionDetectorStatic
+		cfg.Partition.Members = nil
+		cfg.Frontend.ListenAddress = *listenAddr
+		cfg.Logging.Level = *logLevel
+		cfg.Logging.Format = logging.FormatText
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger, err := logging.New(logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format, Output: stderr})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *smoke {
+		*open = false
+	}
+	if err = runDemoStack(ctx, cfg, logger, stdout, *open, *smoke); err != nil {
+		logger.Error("demo failed", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// runDemoStack crawls the sample site into the stores of a new environment
+// and serves the search frontend.
+func runDemoStack(ctx context.Context, cfg *config.Config, logger *slog.Logger, stdout io.Writer, open, smoke bool) error {
+	site, err := startDemoSite()
+	if err != nil {
+		return fmt.Errorf("sample site: %w", err)
+	}
+	defer func() { _ = site.Close() }()
+	fmt.Fprintf(stdout, "serving the sample site on %s\n", site.url)
+
+	feListener, err := net.Listen("tcp", cfg.Frontend.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("frontend: %w", err)
+	}
+	defer func() { _ = feListener.Close() }()
+
+	env, err := newEnvironment(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = env.Close() }()
+	netDetector, err := privnet.NewDetector()
+	if err != nil {
+		return err
+	}
+	env.netDetector = demoNetworkDetector{host: site.host, PrivateNetworkDetector: netDetector}
+
+	if err = seedDemoSite(env, site); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "crawling the sample site...")
+	crawlerSvc, err := env.crawlerService()
+	if err != nil {
+		return err
+	}
+	if err = crawlerSvc.(*service.Crawler).RunPass(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "calculating PageRank scores...")
+	pageRankSvc, err := env.pageRankService()
+	if err != nil {
+		return err
+	}
+	if err = pageRankSvc.(*service.PageRank).RunPass(ctx); err != nil {
+		return err
+	}
+
+	// The remaining services keep the stack running; the crawler and
+	// PageRank services pick up pages that are submitted via the frontend.
+	group := service.Group{crawlerSvc, pageRankSvc}
+	for _, build := range []func(*environment) (service.Service, error){
+		(*environment).frontendService,
+		(*environment).sloService,
+		(*environment).feedService,
+	} {
+		svc, err := build(env)
+		if err != nil {
+			return err
+		} else if fe, ok := svc.(*service.Frontend); ok {
+			group = append(group, listenerService{Frontend: fe, l: feListener})
+		} else if svc != nil {
+			group = append(group, svc)
+		}
+	}
+
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	groupErrCh := make(chan error, 1)
+	go func() { groupErrCh <- group.Run(groupCtx) }()
+
+	feURL := "http://" + feListener.Addr().String() + "/"
+	if smoke {
+		err = smokeTestDemo(feURL, len(demoPages(site)))
+		cancel()
+		if groupErr := <-groupErrCh; err == nil {
+			err = groupErr
+		}
+		if err == nil {
+			fmt.Fprintln(stdout, "smoke test passed")
+		}
+		return err
+	}
+
+	searchURL := feURL + "search?" + url.Values{"q": {demoQuery}}.Encode()
+	fmt.Fprintf(stdout, "the search frontend is available at %s\ntry searching for %q: %s\npress Ctrl+C to stop the demo\n", feURL, demoQuery, searchURL)
+	if open {
+		if err = openBrowser(searchURL); err != nil {
+			logger.Warn("unable to open the search frontend in a browser", "err", err)
+		}
+	}
+	return <-groupErrCh
+}
+
+// demoSite serves the embedded sample site on the loopback interface.
+type demoSite struct {
+	*http.Server
+	url  string
+	host string
+}
+
+// startDemoSite serves the sample site on a random loopback port.
+func startDemoSite() (*demoSite, error) {
+	content, err := fs.Sub(demoSiteFS, "demosite")
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: http.FileServer(http.FS(content)), ReadHeaderTimeout: fetchTimeout}
+	go func() { _ = srv.Serve(l) }()
+
+	host, _, _ := net.SplitHostPort(l.Addr().String())
+	return &demoSite{Server: srv, url: "http://" + l.Addr().String(), host: host}, nil
+}
+
+// demoPages returns the URLs of the pages of the sample site.
+func demoPages(site *demoSite) []string {
+	pages := []string{site.url + "/"}
+	entries, _ := fs.ReadDir(demoSiteFS, "demosite")
+	for _, entry := range entries {
+		if name := entry.Name(); name != "index.html" && name != "robots.txt" {
+			pages = append(pages, site.url+"/"+name)
+		}
+	}
+	return pages
+}
+
+// seedDemoSite adds the pages of the sample site to the link graph so that
+// they are all fetched by the first crawl pass. The URLs are normalized the
+// same way as the links discovered by the crawler so that the crawled links
+// point to the seeded ones.
+func seedDemoSite(env *environment, site *demoSite) error {
+	urlNormalizer, err := env.urlNormalizer()
+	if err != nil {
+		return err
+	}
+	for _, page := range demoPages(site) {
+		link := &graph.Link{URL: page}
+		if link.URL, err = urlNormalizer.Normalize(page); err != nil {
+			return fmt.Errorf("seed %s: %w", page, err)
+		}
+		if err = env.graph.UpsertLink(link); err != nil {
+			return fmt.Errorf("seed %s: %w", page, err)
+		}
+	}
+	return nil
+}
+
+// smokeTestDemo checks that the search API of the frontend at feURL returns
+// expPages results for the demo query.
+func smokeTestDemo(feURL string, expPages int) error {
+	req, err := http.NewRequest(http.MethodGet, feURL+"api/v1/search?"+url.Values{"q": {demoQuery}}.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{Timeout: fetchTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("smoke test: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("smoke test: unexpected search API status %s", res.Status)
+	}
+
+	var searchRes struct {
+		TotalResults int `json:"totalResults"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&searchRes); err != nil {
+		return fmt.Errorf("smoke test: %w", err)
+	}
+	if searchRes.TotalResults != expPages {
+		return fmt.Errorf("smoke test: expected %d results for %q; got %d", expPages, demoQuery, searchRes.TotalResults)
+	}
+	return nil
+}
+
+// demoNetworkDetector treats the host of the sample site as public and
+// delegates the checks for all other hosts to the wrapped detector.
+type demoNetworkDetector struct {
+	crawler.PrivateNetworkDetector
+	host string
+}
+
+// IsPrivate implements crawler.PrivateNetworkDetector. The host may include a
+// port.
+func (d demoNetworkDetector) IsPrivate(host string) (bool, error) {
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	if hostname == d.host {
+		return false, nil
+	}
+	return d.PrivateNetworkDetector.IsPrivate(host)
+}
+
+// listenerService runs a frontend service on a listener that has already
+// been opened.
+type listenerService struct {
+	*service.Frontend
+	l net.Listener
+}
+
+// Run implements service.Service.
+func (svc listenerService) Run(ctx context.Context) error {
+	return svc.Serve(ctx, svc.l)
+}
+
+// openBrowser opens rawURL in the default browser of the user.
+func openBrowser(rawURL string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", rawURL)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", rawURL)
+	default:
+		cmd = exec.Command("xdg-open", rawURL)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the launcher process once it exits.
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"webcrawler/config"
+	"webcrawler/crawler/textindexer/export"
+	"webcrawler/logging"
+)
+
+// runExportDocs implements the "export-docs" command which writes the
+// documents of the text index as JSONL or Parquet. It returns the exit code
+// for the process.
+func runExportDocs(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export-docs", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "usage: webcrawler export-docs [flags]\n\n"+
+			"Exports the documents of the text index as JSONL or Parquet for processing by\n"+
+			"analytics and machine learning pipelines.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+	formatName := fs.String("format", "", "the export format (jsonl or parquet); detected from the output file extension if not specified")
+	fieldList := fs.String("fields", "", "comma-separated list of the fields to export; one or more of "+strings.Join(export.FieldNames(), ", ")+" (default all)")
+	output := fs.String("o", "", "the file to write the export to; defaults to stdout")
+	var o overrides
+	o.stringFlag(fs, "namespace", "the namespace of the text index to export", func(cfg *config.Config) *string { return &cfg.Namespace })
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	format, err := detectDocumentExportFormat(*formatName, *output)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	fields, err := export.ParseFields(*fieldList)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	cfg, err := config.Load(*path, o...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger, err := logging.New(logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format, Output: stderr})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	env, err := newEnvironment(cfg, logger)
+	if err != nil {
+		logger.Error("unable to initialize stores", "err", err)
+		return 1
+	}
+	defer func() { _ = env.Close() }()
+
+	var file *os.File
+	w := stdout
+	if *output != "" {
+		if file, err = os.Create(*output); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer func() { _ = file.Close() }()
+		w = file
+	}
+	count, err := export.Write(w, env.indexer, export.Options{Format: format, Fields: fields})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if file != nil {
+		if err = file.Close(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(stderr, "exported %d documents\n", count)
+	return 0
+}
+
+// detectDocumentExportFormat returns the format specified by name or, if name
+// is empty, the format implied by the extension of the output file.
+func detectDocumentExportFormat(name, output string) (export.Format, error) {
+	if name != "" {
+		return export.ParseFormat(name)
+	}
+	if strings.EqualFold(filepath.Ext(output), ".parquet") {
+		return export.Parquet, nil
+	}
+	return export.JSONL, nil
+}
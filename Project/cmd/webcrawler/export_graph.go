@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"webcrawler/config"
+	"webcrawler/crawler/linkgraph/export"
+	"webcrawler/logging"
+	"webcrawler/partition"
+)
+
+// runExportGraph implements the "export-graph" command which writes the link
+// graph, or a partition of it, as GraphML, Graphviz DOT or CSV. It returns the
+// exit code for the process.
+func runExportGraph(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export-graph", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "usage: webcrawler export-graph [flags]\n\n"+
+			"Exports the links and edges of the link graph for visualization in tools such\n"+
+			"as Gephi, Graphviz or neo4j. The csv format writes an edge list; use the\n"+
+			"csv-nodes format to export the matching node list.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+	formatName := fs.String("format", string(export.GraphML), "the export format (graphml, dot, csv or csv-nodes)")
+	output := fs.String("o", "", "the file to write the export to; defaults to stdout")
+	part := fs.Int("partition", 0, "the partition of the link ID space to export")
+	numParts := fs.Int("partitions", 1, "the number of partitions the link ID space is split into")
+	var o overrides
+	o.stringFlag(fs, "namespace", "the namespace of the link graph to export", func(cfg *config.Config) *string { return &cfg.Namespace })
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	r, err := partition.NewFullRange(*numParts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	fromID, toID, err := r.PartitionExtents(*part)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	cfg, err := config.Load(*path, o...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger, err := logging.New(logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format, Output: stderr})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	env, err := newEnvironment(cfg, logger)
+	if err != nil {
+		logger.Error("unable to initialize stores", "err", err)
+		return 1
+	}
+	defer func() { _ = env.Close() }()
+
+	var file *os.File
+	w := stdout
+	if *output != "" {
+		if file, err = os.Create(*output); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer func() { _ = file.Close() }()
+		w = file
+	}
+	stats, err := export.Write(w, env.graph, export.Options{Format: format, FromID: fromID, ToID: toID})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if file != nil {
+		if err = file.Close(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(stderr, "exported %d links and %d edges\n", stats.Links, stats.Edges)
+	return 0
+}
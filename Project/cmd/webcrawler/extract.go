@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"webcrawler/config"
+	"webcrawler/crawler"
+	"webcrawler/crawler/textindexer/index"
+)
+
+// runExtract implements the "extract" command which previews the content
+// that the crawler extracts from a sample page using the extraction profiles
+// of the configuration. It returns the exit code for the process.
+func runExtract(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "usage: webcrawler extract [flags] <url>\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+	file := fs.String("file", "", "read the page from a local file instead of fetching the URL")
+	maxContent := fs.Int("max-content", 500, "the maximum number of content characters to print; 0 prints the entire content")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	rawURL := fs.Arg(0)
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	profiles, err := compileExtractionProfiles(cfg.Crawler.ExtractionProfiles)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var content []byte
+	if *file != "" {
+		content, err = os.ReadFile(*file)
+	} else {
+		content, err = fetchPage(rawURL)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	res, err := crawler.ExtractContent(rawURL, content, profiles)
+	if err != nil {
+		fmt.Fprintf(stderr, "warning: %v\n", err)
+	}
+	printExtractedContent(stdout, rawURL, res, *maxContent)
+	return 0
+}
+
+// fetchPage returns the body of the page at rawURL.
+func fetchPage(rawURL string) ([]byte, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	res, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", rawURL, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func printExtractedContent(w io.Writer, rawURL string, res crawler.ExtractedContent, maxContent int) {
+	profile := "none (generic extractor)"
+	if res.Profile != nil {
+		profile = res.Profile.Domain
+	}
+	vertical := res.Vertical
+	if vertical == "" {
+		vertical = index.VerticalWeb
+	}
+	published := "unknown"
+	if !res.PublishedAt.IsZero() {
+		published = res.PublishedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+
+	fmt.Fprintf(w, "url:        %s\n", rawURL)
+	fmt.Fprintf(w, "profile:    %s\n", profile)
+	fmt.Fprintf(w, "title:      %s\n", res.Title)
+	fmt.Fprintf(w, "author:     %s\n", res.Author)
+	fmt.Fprintf(w, "published:  %s\n", published)
+	fmt.Fprintf(w, "language:   %s\n", res.Language)
+	fmt.Fprintf(w, "vertical:   %s\n", vertical)
+
+	content := []rune(res.Content)
+	fmt.Fprintf(w, "content:    (%d characters)\n", len(content))
+	if maxContent > 0 && len(content) > maxContent {
+		content = append(content[:maxContent], '…')
+	}
+	fmt.Fprintln(w, string(content))
+}
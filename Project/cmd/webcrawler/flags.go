@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"webcrawler/config"
+)
+
+// overrides collects the configuration settings that were explicitly set via
+// command-line flags. They are applied on top of the configuration file and
+// the environment.
+type overrides []func(*config.Config)
+
+// registerCommonFlags registers the flags that are shared by all service
+// commands.
+func (o *overrides) registerCommonFlags(fs *flag.FlagSet) {
+	fs.Func("graph-backend", `link graph backend; one of "memory", "postgres", "es", "sqlite", "bolt" or "grpc"`, func(v string) error {
+		backend := v
+		switch v {
+		case "postgres", config.LinkGraphDB:
+			backend = config.LinkGraphDB
+		case config.LinkGraphMemory, config.LinkGraphES, config.LinkGraphSQLite, config.LinkGraphBolt, config.LinkGraphGRPC:
+		default:
+			return fmt.Errorf("expected one of %q, %q, %q, %q, %q or %q", config.LinkGraphMemory, "postgres", config.LinkGraphES, config.LinkGraphSQLite, config.LinkGraphBolt, config.LinkGraphGRPC)
+		}
+		*o = append(*o, func(cfg *config.Config) { cfg.LinkGraph.Backend = backend })
+		return nil
+	})
+	o.stringFlag(fs, "graph-dsn", "data source name for the postgres link graph backend", func(cfg *config.Config) *string { return &cfg.LinkGraph.DSN })
+	o.stringFlag(fs, "graph-sqlite-path", "database file for the sqlite link graph backend", func(cfg *config.Config) *string { return &cfg.LinkGraph.SQLitePath })
+	o.stringFlag(fs, "graph-bolt-path", "database file for the bolt link graph backend", func(cfg *config.Config) *string { return &cfg.LinkGraph.BoltPath })
+	o.stringFlag(fs, "graph-grpc-address", "address of the gRPC server for the grpc link graph backend", func(cfg *config.Config) *string { return &cfg.LinkGraph.GRPCAddress })
+	o.stringFlag(fs, "index-backend", `text indexer backend; one of "memory", "es", "bleve", "meili" or "grpc"`, func(cfg *config.Config) *string { return &cfg.TextIndexer.Backend })
+	o.stringFlag(fs, "index-grpc-address", "address of the gRPC server for the grpc text indexer backend", func(cfg *config.Config) *string { return &cfg.TextIndexer.GRPCAddress })
+	o.stringFlag(fs, "index-bleve-path", "index directory for the bleve text indexer backend", func(cfg *config.Config) *string { return &cfg.TextIndexer.BlevePath })
+	o.stringFlag(fs, "index-meili-url", "URL of the meilisearch instance for the meili text indexer backend", func(cfg *config.Config) *string { return &cfg.TextIndexer.Meili.URL })
+	o.listFlag(fs, "es-nodes", "comma-separated list of elasticsearch node URLs", func(cfg *config.Config) *[]string { return &cfg.TextIndexer.ES.Nodes })
+	o.stringFlag(fs, "partition-self", "the name of this instance in the partition member list", func(cfg *config.Config) *string { return &cfg.Partition.Self })
+	o.listFlag(fs, "partition-members", "comma-separated list of the names of all partitioned instances", func(cfg *config.Config) *[]string { return &cfg.Partition.Members })
+	o.stringFlag(fs, "log-level", `minimum log level; one of "debug", "info", "warn" or "error"`, func(cfg *config.Config) *string { return &cfg.Logging.Level })
+}
+
+// registerCrawlerFlags registers the flags for the crawler service.
+func (o *overrides) registerCrawlerFlags(fs *flag.FlagSet) {
+	o.intFlag(fs, "fetch-workers", "number of concurrent workers used for retrieving links", func(cfg *config.Config) *int { return &cfg.Crawler.FetchWorkers })
+	o.durationFlag(fs, "crawl-interval", "how often a new crawl pass is started", func(cfg *config.Config) *config.Duration { return &cfg.Crawler.UpdateInterval })
+	o.durationFlag(fs, "reindex-threshold", "minimum amount of time before a link is re-crawled", func(cfg *config.Config) *config.Duration { return &cfg.Crawler.ReIndexThreshold })
+	o.stringFlag(fs, "region", "the region of this instance; enables region-aware crawling", func(cfg *config.Config) *string { return &cfg.Crawler.Region.Self })
+	o.stringFlag(fs, "archive-dir", "directory for archiving the fetched responses as WARC files; enables archiving", func(cfg *config.Config) *string { return &cfg.Crawler.Archive.Dir })
+	o.listFlag(fs, "region-active", "comma-separated list of the regions that have crawl capacity", func(cfg *config.Config) *[]string { return &cfg.Crawler.Region.Active })
+}
+
+// registerPageRankFlags registers the flags for the PageRank service.
+func (o *overrides) registerPageRankFlags(fs *flag.FlagSet) {
+	o.intFlag(fs, "pagerank-workers", "number of workers used for calculating PageRank scores", func(cfg *config.Config) *int { return &cfg.PageRank.ComputeWorkers })
+	o.durationFlag(fs, "pagerank-interval", "how often the PageRank scores are recalculated", func(cfg *config.Config) *config.Duration { return &cfg.PageRank.UpdateInterval })
+}
+
+// registerHealthFlags registers the flags for the health and readiness probes
+// of the commands that do not run the frontend.
+func (o *overrides) registerHealthFlags(fs *flag.FlagSet) {
+	o.stringFlag(fs, "health-address", "address to serve the health and readiness probes on; empty to disable", func(cfg *config.Config) *string { return &cfg.Health.ListenAddress })
+}
+
+// registerFrontendFlags registers the flags for the frontend service.
+func (o *overrides) registerFrontendFlags(fs *flag.FlagSet) {
+	o.stringFlag(fs, "listen-address", "address to listen for HTTP requests on", func(cfg *config.Config) *string { return &cfg.Frontend.ListenAddress })
+	o.stringFlag(fs, "grpc-address", "address to serve the gRPC APIs on; empty to disable", func(cfg *config.Config) *string { return &cfg.GRPC.ListenAddress })
+	o.listFlag(fs, "public-fields", "comma-separated document fields included in search results for requests without an API key", func(cfg *config.Config) *[]string { return &cfg.Frontend.PublicFields })
+}
+
+func (o *overrides) stringFlag(fs *flag.FlagSet, name, usage string, field func(*config.Config) *string) {
+	fs.Func(name, usage, func(v string) error {
+		*o = append(*o, func(cfg *config.Config) { *field(cfg) = v })
+		return nil
+	})
+}
+
+func (o *overrides) listFlag(fs *flag.FlagSet, name, usage string, field func(*config.Config) *[]string) {
+	fs.Func(name, usage, func(v string) error {
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*o = append(*o, func(cfg *config.Config) { *field(cfg) = list })
+		return nil
+	})
+}
+
+func (o *overrides) intFlag(fs *flag.FlagSet, name, usage string, field func(*config.Config) *int) {
+	fs.Func(name, usage, func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*o = append(*o, func(cfg *config.Config) { *field(cfg) = n })
+		return nil
+	})
+}
+
+func (o *overrides) durationFlag(fs *flag.FlagSet, name, usage string, field func(*config.Config) *config.Duration) {
+	fs.Func(name, usage, func(v string) error {
+		var d config.Duration
+		if err := d.Set(v); err != nil {
+			return err
+		}
+		*o = append(*o, func(cfg *config.Config) { *field(cfg) = d })
+		return nil
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"webcrawler/config"
+	"webcrawler/crawler/linkgraph/importer"
+	"webcrawler/logging"
+)
+
+// runImportGraph implements the "import-graph" command which loads the links
+// and edges of a CSV or JSONL edge list into the link graph. It returns the
+// exit code for the process.
+func runImportGraph(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import-graph", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "usage: webcrawler import-graph [flags] <file | ->\n\n"+
+			"Imports the links and edges of an edge list into the link graph. Each record\n"+
+			"describes an edge between a source and a target URL. Use - to read the edge\n"+
+			"list from stdin.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+	format := fs.String("format", "", "the format of the edge list (csv or jsonl); detected from the file extension if not specified")
+	batchSize := fs.Int("batch-size", 1000, "the number of edges to upsert into the link graph per batch")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *batchSize <= 0 {
+		fmt.Fprintln(stderr, "batch-size must be positive")
+		return 2
+	}
+	listFormat, err := detectEdgeListFormat(*format, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger, err := logging.New(logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format, Output: stderr})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	env, err := newEnvironment(cfg, logger)
+	if err != nil {
+		logger.Error("unable to initialize stores", "err", err)
+		return 1
+	}
+	defer func() { _ = env.Close() }()
+	if cfg.LinkGraph.Backend == config.LinkGraphMemory {
+		logger.Warn("the in-memory link graph does not persist the imported edges")
+	}
+	norm, err := env.urlNormalizer()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	src := stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer func() { _ = f.Close() }()
+		src = f
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stats, err := importer.Import(ctx, src, env.graph, importer.Options{
+		Format:     listFormat,
+		BatchSize:  *batchSize,
+		Normalizer: norm,
+		Progress: func(st importer.Stats) {
+			fmt.Fprintf(stderr, "read %d records: %d links, %d edges\n", st.Read, st.Links, st.Edges)
+		},
+	})
+	printImportStats(stdout, stats)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// detectEdgeListFormat returns the format specified by name or, if name is
+// empty, the format implied by the extension of location.
+func detectEdgeListFormat(name, location string) (importer.Format, error) {
+	if name != "" {
+		return importer.ParseFormat(name)
+	}
+	switch strings.ToLower(filepath.Ext(location)) {
+	case ".jsonl", ".ndjson":
+		return importer.JSONL, nil
+	default:
+		return importer.CSV, nil
+	}
+}
+
+func printImportStats(w io.Writer, stats importer.Stats) {
+	fmt.Fprintf(w, "links: %d\n", stats.Links)
+	fmt.Fprintf(w, "edges: %d\n", stats.Edges)
+	fmt.Fprintf(w, "duplicates: %d\n", stats.Duplicates)
+	fmt.Fprintf(w, "rejected: %d\n", stats.Rejected)
+	for _, recErr := range stats.Errors {
+		fmt.Fprintf(w, "  line %d: %s\n", recErr.Line, recErr.Message)
+	}
+	if shown := uint64(len(stats.Errors)); shown < stats.Rejected {
+		fmt.Fprintf(w, "  (%d more rejected records not shown)\n", stats.Rejected-shown)
+	}
+}
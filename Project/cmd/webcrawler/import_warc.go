@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"webcrawler/config"
+	"webcrawler/crawler"
+	"webcrawler/crawler/warc"
+	"webcrawler/logging"
+	"webcrawler/service"
+)
+
+// runImportWARC implements the "import-warc" command which populates the link
+// graph and text index from the responses archived in WARC files without
+// fetching them. It returns the exit code for the process.
+func runImportWARC(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import-warc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "usage: webcrawler import-warc [flags] <file>...\n\n"+
+			"Replays the extraction and indexing stages of the crawler over the responses\n"+
+			"archived in plain or gzip-compressed WARC files without issuing any network\n"+
+			"requests.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger, err := logging.New(logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format, Output: stderr})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	env, err := newEnvironment(cfg, logger)
+	if err != nil {
+		logger.Error("unable to initialize stores", "err", err)
+		return 1
+	}
+	defer func() { _ = env.Close() }()
+	if cfg.LinkGraph.Backend == config.LinkGraphMemory || cfg.TextIndexer.Backend == config.TextIndexerMemory {
+		logger.Warn("the in-memory stores do not persist the imported pages")
+	}
+
+	// The pipeline is configured the same way as the one of the crawler
+	// service; Replay disables the settings that require network access.
+	svc, err := env.crawlerService()
+	if err != nil {
+		logger.Error("unable to initialize crawler", "err", err)
+		return 1
+	}
+	pipelineCfg := svc.(*service.Crawler).PipelineConfig()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var total int
+	for _, name := range fs.Args() {
+		count, err := importWARCFile(ctx, pipelineCfg, name)
+		total += count
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s: %d responses imported\n", name, count)
+	}
+	if flusher, ok := env.indexer.(crawler.Flusher); ok {
+		if err = flusher.Flush(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintf(stdout, "total: %d responses imported\n", total)
+	}
+	return 0
+}
+
+// importWARCFile replays the responses archived in the named WARC file.
+func importWARCFile(ctx context.Context, cfg crawler.Config, name string) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	r, err := warc.NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+	return crawler.Replay(ctx, cfg, r)
+}
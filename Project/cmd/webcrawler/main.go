@@ -0,0 +1,250 @@
+// Command webcrawler is the entrypoint for the webcrawler tooling. It runs
+// each service of the search engine on its own (crawl, pagerank, frontend) or
+// all of them in a single process (monolith). The demo command runs the full
+// stack against an embedded sample site without any external dependencies.
+//
+// The services are configured via an optional JSON, YAML or TOML
+// configuration file and WEBCRAWLER_* environment variables (see package
+// config); the settings that are most commonly tuned can also be overridden
+// via command-line flags.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"webcrawler/config"
+	"webcrawler/logging"
+	"webcrawler/service"
+	"webcrawler/supervisor"
+)
+
+// serviceCommand describes a command that runs one or more services.
+type serviceCommand struct {
+	name    string
+	summary string
+	flags   []func(*overrides, *flag.FlagSet)
+	build   []func(*environment) (service.Service, error)
+
+	// Whether the services are run by a supervisor that synchronizes the
+	// crawl and PageRank passes.
+	supervised bool
+}
+
+var serviceCommands = []serviceCommand{
+	{
+		name:    "crawl",
+		summary: "periodically crawl the links in the partition assigned to this instance",
+		flags:   []func(*overrides, *flag.FlagSet){(*overrides).registerCrawlerFlags, (*overrides).registerHealthFlags},
+		build: []func(*environment) (service.Service, error){
+			(*environment).crawlerService,
+			(*environment).jobsService,
+			(*environment).queueWorkerService,
+			(*environment).proxyPoolService,
+			(*environment).healthService,
+			(*environment).mappingMonitorService,
+			(*environment).stateService,
+		},
+	},
+	{
+		name:    "pagerank",
+		summary: "periodically recalculate the PageRank scores of the indexed links",
+		flags:   []func(*overrides, *flag.FlagSet){(*overrides).registerPageRankFlags, (*overrides).registerHealthFlags},
+		build: []func(*environment) (service.Service, error){
+			(*environment).pageRankService,
+			(*environment).healthService,
+			(*environment).mappingMonitorService,
+			(*environment).stateService,
+		},
+	},
+	{
+		name:    "frontend",
+		summary: "serve the search frontend, the GraphQL and gRPC APIs and back up the index",
+		flags:   []func(*overrides, *flag.FlagSet){(*overrides).registerFrontendFlags},
+		build: []func(*environment) (service.Service, error){
+			(*environment).frontendService,
+			(*environment).grpcService,
+			(*environment).sloService,
+			(*environment).feedService,
+			(*environment).backupService,
+			(*environment).mappingMonitorService,
+			(*environment).stateService,
+		},
+	},
+	{
+		name:    "monolith",
+		summary: "run all services in a single process sharing the same stores",
+		flags: []func(*overrides, *flag.FlagSet){
+			(*overrides).registerCrawlerFlags,
+			(*overrides).registerPageRankFlags,
+			(*overrides).registerFrontendFlags,
+		},
+		supervised: true,
+		build: []func(*environment) (service.Service, error){
+			(*environment).crawlerService,
+			(*environment).jobsService,
+			(*environment).queueWorkerService,
+			(*environment).proxyPoolService,
+			(*environment).pageRankService,
+			(*environment).frontendService,
+			(*environment).grpcService,
+			(*environment).sloService,
+			(*environment).feedService,
+			(*environment).backupService,
+			(*environment).mappingMonitorService,
+			(*environment).stateService,
+		},
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+
+	switch args[0] {
+	case "config":
+		return config.RunCommand(args[1:], stdout, stderr)
+	case "extract":
+		return runExtract(args[1:], stdout, stderr)
+	case "seed":
+		return runSeed(args[1:], os.Stdin, stdout, stderr)
+	case "import-warc":
+		return runImportWARC(args[1:], stdout, stderr)
+	case "import-graph":
+		return runImportGraph(args[1:], os.Stdin, stdout, stderr)
+	case "export-graph":
+		return runExportGraph(args[1:], stdout, stderr)
+	case "export-docs":
+		return runExportDocs(args[1:], stdout, stderr)
+	case "demo":
+		return runDemo(args[1:], stdout, stderr)
+	}
+	for _, cmd := range serviceCommands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+	printUsage(stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, "usage: webcrawler <command> [arguments]\n\ncommands:\n")
+	fmt.Fprintf(w, "  %-12s %s\n", "config", "validate or print the service configuration")
+	fmt.Fprintf(w, "  %-12s %s\n", "extract", "preview the content extracted from a sample page")
+	fmt.Fprintf(w, "  %-12s %s\n", "seed", "import a seed list from a file, stdin or an HTTP URL into the link graph")
+	fmt.Fprintf(w, "  %-12s %s\n", "import-warc", "index the responses archived in WARC files without fetching them")
+	fmt.Fprintf(w, "  %-12s %s\n", "import-graph", "load the links and edges of a CSV or JSONL edge list into the link graph")
+	fmt.Fprintf(w, "  %-12s %s\n", "export-graph", "export the link graph as GraphML, Graphviz DOT or CSV")
+	fmt.Fprintf(w, "  %-12s %s\n", "export-docs", "export the documents of the text index as JSONL or Parquet")
+	fmt.Fprintf(w, "  %-12s %s\n", "demo", "crawl an embedded sample site with in-memory stores and serve the search frontend")
+	for _, cmd := range serviceCommands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprint(w, "\nRun 'webcrawler <command> -h' for the flags supported by each command.\n")
+}
+
+// parseFlags parses the command-line flags for cmd and returns the path to the
+// configuration file and the overrides for the configuration settings.
+func (cmd serviceCommand) parseFlags(args []string, stderr io.Writer) (string, overrides, error) {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+
+	var o overrides
+	o.registerCommonFlags(fs)
+	for _, register := range cmd.flags {
+		register(&o, fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	if fs.NArg() != 0 {
+		err := fmt.Errorf("unexpected arguments: %v", fs.Args())
+		fmt.Fprintln(stderr, err)
+		return "", nil, err
+	}
+	return *path, o, nil
+}
+
+// run executes the services of cmd until the process receives SIGINT or
+// SIGTERM and returns the exit code for the process.
+func (cmd serviceCommand) run(args []string, stderr io.Writer) int {
+	path, o, err := cmd.parseFlags(args, stderr)
+	if err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		// The flag set has already reported the error.
+		return 2
+	}
+	cfg, err := config.Load(path, o...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	logger, err := logging.New(logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format, Output: stderr})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	env, err := newEnvironment(cfg, logger)
+	if err != nil {
+		logger.Error("unable to initialize stores", "err", err)
+		return 1
+	}
+	defer func() { _ = env.Close() }()
+	if cmd.name != "monolith" && (cfg.LinkGraph.Backend == config.LinkGraphMemory || cfg.TextIndexer.Backend == config.TextIndexerMemory) {
+		logger.Warn("in-memory stores are not shared with the services running in other processes")
+	}
+	if cmd.name != "monolith" && cfg.LinkGraph.Backend == config.LinkGraphBolt {
+		logger.Warn("the bolt link graph can only be opened by a single process at a time")
+	}
+	if cmd.name != "monolith" && cfg.TextIndexer.Backend == config.TextIndexerBleve {
+		logger.Warn("the bleve text index can only be opened by a single process at a time")
+	}
+
+	if cmd.supervised {
+		// The barrier must exist before the services that enter it
+		// are built.
+		env.barrier = supervisor.NewBarrier()
+	}
+	var group service.Group
+	for _, build := range cmd.build {
+		svc, err := build(env)
+		if err != nil {
+			logger.Error("unable to initialize service", "err", err)
+			return 1
+		} else if svc != nil {
+			group = append(group, svc)
+		}
+	}
+	if cmd.supervised {
+		sup, err := env.supervisor(group)
+		if err != nil {
+			logger.Error("unable to initialize supervisor", "err", err)
+			return 1
+		}
+		group = service.Group{sup}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err = group.Run(ctx); err != nil {
+		logger.Error("service failed", "err", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"webcrawler/config"
+	"webcrawler/crawler/iterutil"
+	"webcrawler/crawler/linkgraph/graph"
+	sqlitegraph "webcrawler/crawler/linkgraph/store/sqlite"
+	"webcrawler/crawler/textindexer/index"
+	memidx "webcrawler/crawler/textindexer/store/memory"
+	"webcrawler/crawler/warc"
+	"webcrawler/logging"
+	"webcrawler/service"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(CommandTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type CommandTestSuite struct{}
+
+func (s *CommandTestSuite) TestUsage(c *gc.C) {
+	var stdout, stderr bytes.Buffer
+	c.Assert(run(nil, &stdout, &stderr), gc.Equals, 2)
+	for _, cmd := range []string{"config", "extract", "seed", "import-warc", "import-graph", "export-graph", "export-docs", "demo", "crawl", "pagerank", "frontend", "monolith"} {
+		c.Assert(stderr.String(), gc.Matches, "(?s).*\n  "+cmd+" .*")
+	}
+
+	stderr.Reset()
+	c.Assert(run([]string{"bogus"}, &stdout, &stderr), gc.Equals, 2)
+	c.Assert(stderr.String(), gc.Matches, `(?s)unknown command "bogus".*`)
+}
+
+func (s *CommandTestSuite) TestDemoSmokeTest(c *gc.C) {
+	var stdout, stderr bytes.Buffer
+	c.Assert(run([]string{"demo", "-smoke", "-listen-address", "127.0.0.1:0"}, &stdout, &stderr), gc.Equals, 0, gc.Commentf("stderr: %s", stderr.String()))
+	c.Assert(stdout.String(), gc.Matches, "(?s).*smoke test passed\n")
+}
+
+func (s *CommandTestSuite) TestFlagOverrides(c *gc.C) {
+	cmd := s.command(c, "monolith")
+	path, o, err := cmd.parseFlags([]string{
+		"-graph-backend", "postgres",
+		"-graph-dsn", "postgresql://root@localhost:26257/linkgraph",
+		"-index-backend", "es",
+		"-es-nodes", "http://a:9200, http://b:9200",
+		"-partition-self", "b",
+		"-partition-members", "a,b,c",
+		"-fetch-workers", "4",
+		"-crawl-interval", "10m",
+		"-pagerank-workers", "8",
+		"-pagerank-interval", "2h",
+		"-listen-address", ":9090",
+	}, new(bytes.Buffer))
+	c.Assert(err, gc.IsNil)
+	c.Assert(path, gc.Equals, "")
+
+	cfg, err := config.Load(path, o...)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.LinkGraph.Backend, gc.Equals, config.LinkGraphDB)
+	c.Assert(cfg.LinkGraph.DSN, gc.Equals, "postgresql://root@localhost:26257/linkgraph")
+	c.Assert(cfg.TextIndexer.Backend, gc.Equals, config.TextIndexerES)
+	c.Assert(cfg.TextIndexer.ES.Nodes, gc.DeepEquals, []string{"http://a:9200", "http://b:9200"})
+	c.Assert(cfg.Partition.Self, gc.Equals, "b")
+	c.Assert(cfg.Partition.Members, gc.DeepEquals, []string{"a", "b", "c"})
+	c.Assert(cfg.Crawler.FetchWorkers, gc.Equals, 4)
+	c.Assert(cfg.Crawler.UpdateInterval, gc.Equals, config.Duration(10*time.Minute))
+	c.Assert(cfg.PageRank.ComputeWorkers, gc.Equals, 8)
+	c.Assert(cfg.PageRank.UpdateInterval, gc.Equals, config.Duration(2*time.Hour))
+	c.Assert(cfg.Frontend.ListenAddress, gc.Equals, ":9090")
+}
+
+func (s *CommandTestSuite) TestFlagsAreScopedToCommands(c *gc.C) {
+	var stderr bytes.Buffer
+	_, _, err := s.command(c, "frontend").parseFlags([]string{"-fetch-workers", "4"}, &stderr)
+	c.Assert(err, gc.NotNil)
+	c.Assert(stderr.String(), gc.Matches, "(?s).*flag provided but not defined: -fetch-workers.*")
+}
+
+func (s *CommandTestSuite) TestInvalidFlags(c *gc.C) {
+	var stdout, stderr bytes.Buffer
+	c.Assert(run([]string{"crawl", "-graph-backend", "mysql"}, &stdout, &stderr), gc.Equals, 2)
+	c.Assert(stderr.String(), gc.Matches, `(?s).*invalid value "mysql" for flag -graph-backend.*`)
+
+	stderr.Reset()
+	c.Assert(run([]string{"crawl", "extra"}, &stdout, &stderr), gc.Equals, 2)
+	c.Assert(stderr.String(), gc.Matches, `(?s).*unexpected arguments: \[extra\].*`)
+
+	// Flags are validated together with the rest of the configuration.
+	stderr.Reset()
+	c.Assert(run([]string{"pagerank", "-pagerank-workers", "0"}, &stdout, &stderr), gc.Equals, 1)
+	c.Assert(stderr.String(), gc.Matches, `(?s).*pageRank.computeWorkers: must be greater than zero.*`)
+}
+
+func (s *CommandTestSuite) TestExtract(c *gc.C) {
+	dir := c.MkDir()
+	cfgPath := filepath.Join(dir, "config.json")
+	c.Assert(os.WriteFile(cfgPath, []byte(`{"crawler": {"extractionProfiles": [
+		{"domain": "example.com", "body": "article p", "author": ".byline", "date": "time"}
+	]}}`), 0o600), gc.IsNil)
+	pagePath := filepath.Join(dir, "page.html")
+	c.Assert(os.WriteFile(pagePath, []byte(`<html lang="en"><head><title>Sample</title></head><body>
+<nav>Menu</nav>
+<article><span class="byline">Jane Doe</span><time datetime="2024-03-05">March 5</time>
+<p>The quick brown fox jumps over the lazy dog.</p></article>
+</body></html>`), 0o600), gc.IsNil)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"extract", "-config", cfgPath, "-file", pagePath, "-max-content", "9", "https://www.example.com/fox"}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Equals, `url:        https://www.example.com/fox
+profile:    example.com
+title:      Sample
+author:     Jane Doe
+published:  2024-03-05T00:00:00Z
+language:   en
+vertical:   news
+content:    (44 characters)
+The quick…
+`)
+
+	// Pages of hosts without a profile are only processed by the generic
+	// extractor.
+	stdout.Reset()
+	code = run([]string{"extract", "-config", cfgPath, "-file", pagePath, "https://other.com/fox"}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(stdout.String(), gc.Matches, `(?s).*profile:    none \(generic extractor\).*author:     \n.*`)
+
+	stderr.Reset()
+	c.Assert(run([]string{"extract", "-config", cfgPath}, &stdout, &stderr), gc.Equals, 2)
+	c.Assert(stderr.String(), gc.Matches, `(?s)usage: webcrawler extract.*`)
+}
+
+func (s *CommandTestSuite) TestSeed(c *gc.C) {
+	dir := c.MkDir()
+	listPath := filepath.Join(dir, "seeds.csv")
+	c.Assert(os.WriteFile(listPath, []byte("url\nhttp://93.184.216.34/a\nhttp://10.0.0.1/\n"), 0o600), gc.IsNil)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"seed", "-dry-run", listPath}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Matches, `(?s)dry run: .*accepted: 1\nrejected: 1\n  line 3: .*private network.*`)
+	c.Assert(stderr.String(), gc.Equals, "read 2 seeds: 1 accepted, 1 rejected\n")
+
+	// Seed lists can also be fetched over HTTP.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("http://93.184.216.34/a\nhttp://93.184.216.34/b\n"))
+	}))
+	defer srv.Close()
+	stdout.Reset()
+	stderr.Reset()
+	graphPath := filepath.Join(dir, "graph.db")
+	cfgPath := filepath.Join(dir, "config.json")
+	c.Assert(os.WriteFile(cfgPath, []byte(`{"linkGraph": {"backend": "sqlite", "sqlitePath": "`+graphPath+`"}}`), 0o600), gc.IsNil)
+	code = run([]string{"seed", "-config", cfgPath, srv.URL}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Equals, "accepted: 2\nrejected: 0\n")
+
+	g, err := sqlitegraph.NewSQLiteGraph(graphPath)
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = g.Close() }()
+	linkIt, err := g.Links(uuid.Nil, uuid.Max, time.Now().Add(time.Minute).Unix())
+	c.Assert(err, gc.IsNil)
+	links, err := iterutil.CollectLinks(linkIt)
+	c.Assert(err, gc.IsNil)
+	c.Assert(links, gc.HasLen, 2)
+
+	stderr.Reset()
+	c.Assert(run([]string{"seed", "-format", "xml", listPath}, &stdout, &stderr), gc.Equals, 2)
+	c.Assert(stderr.String(), gc.Matches, `unsupported seed format "xml"\n`)
+}
+
+func (s *CommandTestSuite) TestImportWARC(c *gc.C) {
+	dir := c.MkDir()
+	archivePath := filepath.Join(dir, "pages.warc.gz")
+	f, err := os.Create(archivePath)
+	c.Assert(err, gc.IsNil)
+	c.Assert(warc.NewWriter(f, true).WriteRecord(&warc.Record{
+		URL:        "http://93.184.216.34/",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       []byte(`<html><head><title>Home</title></head><body><a href="/about">about</a></body></html>`),
+	}), gc.IsNil)
+	c.Assert(f.Close(), gc.IsNil)
+
+	graphPath := filepath.Join(dir, "graph.db")
+	cfgPath := filepath.Join(dir, "config.json")
+	c.Assert(os.WriteFile(cfgPath, []byte(`{"linkGraph": {"backend": "sqlite", "sqlitePath": "`+graphPath+`"}}`), 0o600), gc.IsNil)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"import-warc", "-config", cfgPath, archivePath}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Equals, archivePath+": 1 responses imported\n")
+
+	g, err := sqlitegraph.NewSQLiteGraph(graphPath)
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = g.Close() }()
+	// The imported page is not due for a fetch until the re-index
+	// threshold has elapsed.
+	linkIt, err := g.Links(uuid.Nil, uuid.Max, time.Now().Add(30*24*time.Hour).Unix())
+	c.Assert(err, gc.IsNil)
+	links, err := iterutil.CollectLinks(linkIt)
+	c.Assert(err, gc.IsNil)
+	c.Assert(links, gc.HasLen, 2, gc.Commentf("expected the archived page and the link it contains"))
+
+	c.Assert(run([]string{"import-warc", "-config", cfgPath, filepath.Join(dir, "missing.warc")}, &stdout, &stderr), gc.Equals, 1)
+}
+
+func (s *CommandTestSuite) TestExportGraph(c *gc.C) {
+	dir := c.MkDir()
+	graphPath := filepath.Join(dir, "graph.db")
+	g, err := sqlitegraph.NewSQLiteGraph(graphPath)
+	c.Assert(err, gc.IsNil)
+	src, dst := &graph.Link{URL: "http://example.com/"}, &graph.Link{URL: "http://example.com/about"}
+	c.Assert(g.UpsertLink(src), gc.IsNil)
+	c.Assert(g.UpsertLink(dst), gc.IsNil)
+	c.Assert(g.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID}), gc.IsNil)
+	c.Assert(g.Close(), gc.IsNil)
+	cfgPath := filepath.Join(dir, "config.json")
+	c.Assert(os.WriteFile(cfgPath, []byte(`{"linkGraph": {"backend": "sqlite", "sqlitePath": "`+graphPath+`"}}`), 0o600), gc.IsNil)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"export-graph", "-config", cfgPath, "-format", "dot"}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Matches, `(?s)digraph webcrawler \{\n.*"`+src.ID.String()+`" -> "`+dst.ID.String()+`".*`)
+	c.Assert(stderr.String(), gc.Equals, "exported 2 links and 1 edges\n")
+
+	// Links in other namespaces are not exported.
+	stdout.Reset()
+	stderr.Reset()
+	outPath := filepath.Join(dir, "edges.csv")
+	code = run([]string{"export-graph", "-config", cfgPath, "-format", "csv", "-namespace", "other", "-o", outPath}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Equals, "")
+	out, err := os.ReadFile(outPath)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(out), gc.Equals, "Source,Target,Rel,Anchor,NoFollow,UpdatedAt\n")
+
+	c.Assert(run([]string{"export-graph", "-format", "gexf"}, &stdout, &stderr), gc.Equals, 2)
+	c.Assert(run([]string{"export-graph", "-partition", "2", "-partitions", "2"}, &stdout, &stderr), gc.Equals, 2)
+}
+
+func (s *CommandTestSuite) TestImportGraph(c *gc.C) {
+	dir := c.MkDir()
+	listPath := filepath.Join(dir, "edges.jsonl")
+	c.Assert(os.WriteFile(listPath, []byte(`{"source": "http://example.com/", "target": "http://example.com/a"}`+"\n"+
+		`{"source": "http://example.com/a", "target": "mailto:a@example.com"}`+"\n"), 0o600), gc.IsNil)
+	graphPath := filepath.Join(dir, "graph.db")
+	cfgPath := filepath.Join(dir, "config.json")
+	c.Assert(os.WriteFile(cfgPath, []byte(`{"linkGraph": {"backend": "sqlite", "sqlitePath": "`+graphPath+`"}}`), 0o600), gc.IsNil)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"import-graph", "-config", cfgPath, listPath}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Matches, "links: 2\nedges: 1\nduplicates: 0\nrejected: 1\n  line 2: .*unsupported scheme.*\n")
+	c.Assert(stderr.String(), gc.Equals, "read 2 records: 2 links, 1 edges\n")
+
+	stdout.Reset()
+	code = run([]string{"export-graph", "-config", cfgPath, "-format", "csv-nodes"}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Matches, "(?s)Id,Label,RetrievedAt\n.*http://example.com/a,0\n.*")
+
+	c.Assert(run([]string{"import-graph", "-format", "xml", listPath}, &stdout, &stderr), gc.Equals, 2)
+}
+
+func (s *CommandTestSuite) TestExportDocs(c *gc.C) {
+	dir := c.MkDir()
+	indexPath := filepath.Join(dir, "index")
+	idx, err := memidx.NewDiskBleveIndexer(indexPath)
+	c.Assert(err, gc.IsNil)
+	c.Assert(idx.Index(&index.Document{LinkID: uuid.New(), URL: "http://example.com/", Title: "Home", Content: "Welcome"}), gc.IsNil)
+	c.Assert(idx.Close(), gc.IsNil)
+	cfgPath := filepath.Join(dir, "config.json")
+	c.Assert(os.WriteFile(cfgPath, []byte(`{"textIndexer": {"backend": "bleve", "blevePath": "`+indexPath+`"}}`), 0o600), gc.IsNil)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"export-docs", "-config", cfgPath, "-fields", "url,title"}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	c.Assert(stdout.String(), gc.Equals, `{"url":"http://example.com/","title":"Home"}`+"\n")
+	c.Assert(stderr.String(), gc.Equals, "exported 1 documents\n")
+
+	// The format is detected from the extension of the output file.
+	outPath := filepath.Join(dir, "docs.parquet")
+	stdout.Reset()
+	code = run([]string{"export-docs", "-config", cfgPath, "-o", outPath}, &stdout, &stderr)
+	c.Assert(code, gc.Equals, 0, gc.Commentf(stderr.String()))
+	data, err := os.ReadFile(outPath)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data[:4]), gc.Equals, "PAR1")
+
+	c.Assert(run([]string{"export-docs", "-fields", "body"}, &stdout, &stderr), gc.Equals, 2)
+}
+
+func (s *CommandTestSuite) TestBackupServiceIsSharedWithAdminAPI(c *gc.C) {
+	cfg := config.Default()
+	env, err := newEnvironment(cfg, nil)
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = env.Close() }()
+
+	svc, err := env.backupService()
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc, gc.IsNil, gc.Commentf("backups are disabled by default"))
+
+	cfg.TextIndexer.Backup.Enabled = true
+	cfg.TextIndexer.Backup.Dir = c.MkDir()
+	cfg.Frontend.AdminToken = "s3cr3t"
+	_, err = env.frontendService()
+	c.Assert(err, gc.IsNil)
+	svc, err = env.backupService()
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc, gc.NotNil)
+	c.Assert(svc, gc.Equals, env.backups)
+}
+
+func (s *CommandTestSuite) TestMemoryStoresSurviveRestart(c *gc.C) {
+	dir := c.MkDir()
+	cfg := config.Default()
+	cfg.LinkGraph.StateFile = filepath.Join(dir, "linkgraph.gob")
+	cfg.TextIndexer.StateFile = filepath.Join(dir, "textindexer.gob")
+	cfg.TextIndexer.StateSaveInterval = 0
+
+	env, err := newEnvironment(cfg, logging.Discard())
+	c.Assert(err, gc.IsNil)
+	svc, err := env.stateService()
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc, gc.DeepEquals, stateSaver{env.stateFiles[0]}, gc.Commentf("only the link graph is saved periodically"))
+
+	link := &graph.Link{URL: "https://example.com"}
+	c.Assert(env.graph.UpsertLink(link), gc.IsNil)
+	c.Assert(env.indexer.Index(&index.Document{LinkID: link.ID, URL: link.URL, Title: "Example"}), gc.IsNil)
+	c.Assert(env.Close(), gc.IsNil)
+
+	env, err = newEnvironment(cfg, logging.Discard())
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = env.Close() }()
+	got, err := env.graph.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.URL, gc.Equals, link.URL)
+	doc, err := env.indexer.FindByID(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Title, gc.Equals, "Example")
+}
+
+func (s *CommandTestSuite) TestHealthService(c *gc.C) {
+	path, o, err := s.command(c, "pagerank").parseFlags([]string{"-health-address", "127.0.0.1:9091"}, new(bytes.Buffer))
+	c.Assert(err, gc.IsNil)
+	cfg, err := config.Load(path, o...)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.Health.ListenAddress, gc.Equals, "127.0.0.1:9091")
+
+	env, err := newEnvironment(cfg, logging.Discard())
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = env.Close() }()
+
+	// The in-memory stores are always ready.
+	c.Assert(env.readinessChecks(), gc.HasLen, 0)
+	svc, err := env.healthService()
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc, gc.NotNil)
+
+	cfg.Health.ListenAddress = ""
+	svc, err = env.healthService()
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc, gc.IsNil)
+
+	// The frontend serves the probes on its own listen address.
+	_, _, err = s.command(c, "frontend").parseFlags([]string{"-health-address", ":9091"}, new(bytes.Buffer))
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *CommandTestSuite) TestGRPCStores(c *gc.C) {
+	srvEnv, err := newEnvironment(config.Default(), logging.Discard())
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = srvEnv.Close() }()
+	svc, err := srvEnv.grpcService()
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc, gc.IsNil, gc.Commentf("the gRPC APIs are disabled by default"))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, gc.IsNil)
+	srvEnv.cfg.GRPC.ListenAddress = l.Addr().String()
+	svc, err = srvEnv.grpcService()
+	c.Assert(err, gc.IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- svc.(*service.GRPCServer).Serve(ctx, l) }()
+
+	path, o, err := s.command(c, "crawl").parseFlags([]string{
+		"-graph-backend", "grpc",
+		"-graph-grpc-address", l.Addr().String(),
+		"-index-backend", "grpc",
+		"-index-grpc-address", l.Addr().String(),
+	}, new(bytes.Buffer))
+	c.Assert(err, gc.IsNil)
+	cfg, err := config.Load(path, o...)
+	c.Assert(err, gc.IsNil)
+	env, err := newEnvironment(cfg, logging.Discard())
+	c.Assert(err, gc.IsNil)
+
+	link := &graph.Link{URL: "https://example.com"}
+	c.Assert(env.graph.UpsertLink(link), gc.IsNil)
+	c.Assert(env.graph.SaveCheckpoint(&graph.Checkpoint{Partition: 0, PassID: 1}), gc.IsNil)
+	c.Assert(env.indexer.Index(&index.Document{LinkID: link.ID, URL: link.URL, Title: "Example"}), gc.IsNil)
+	c.Assert(env.Close(), gc.IsNil)
+
+	got, err := srvEnv.graph.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.URL, gc.Equals, link.URL)
+	cp, err := srvEnv.graph.Checkpoint(0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cp.PassID, gc.Equals, uint64(1))
+	doc, err := srvEnv.indexer.FindByID(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Title, gc.Equals, "Example")
+
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+}
+
+func (s *CommandTestSuite) command(c *gc.C, name string) serviceCommand {
+	for _, cmd := range serviceCommands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	c.Fatalf("unknown command %q", name)
+	return serviceCommand{}
+}
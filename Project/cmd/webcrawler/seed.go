@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"webcrawler/config"
+	"webcrawler/crawler/ingest"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/privnet"
+	"webcrawler/logging"
+)
+
+// runSeed implements the "seed" command which imports a seed list from a
+// file, stdin or an HTTP URL into the link graph. It returns the exit code
+// for the process.
+func runSeed(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "usage: webcrawler seed [flags] <file | - | http(s)://url>\n\n"+
+			"Imports a seed list with one URL per line or in CSV format into the link\n"+
+			"graph. Use - to read the seed list from stdin.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+	format := fs.String("format", "", "the format of the seed list (lines or csv); detected from the file extension or content type if not specified")
+	batchSize := fs.Int("batch-size", 500, "the number of seeds to upsert into the link graph per batch")
+	dryRun := fs.Bool("dry-run", false, "validate and normalize the seeds without modifying the link graph")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *batchSize <= 0 {
+		fmt.Fprintln(stderr, "batch-size must be positive")
+		return 2
+	}
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger, err := logging.New(logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format, Output: stderr})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	src, contentType, err := openSeedList(ctx, fs.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer func() { _ = src.Close() }()
+	seedFormat, err := detectSeedFormat(*format, fs.Arg(0), contentType)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	// Dry runs never modify the graph so the stores are not opened.
+	env := &environment{cfg: cfg, logger: logger}
+	var g ingest.GraphAPI = discardGraph{}
+	if !*dryRun {
+		if env, err = newEnvironment(cfg, logger); err != nil {
+			logger.Error("unable to initialize stores", "err", err)
+			return 1
+		}
+		defer func() { _ = env.Close() }()
+		if cfg.LinkGraph.Backend == config.LinkGraphMemory {
+			logger.Warn("the in-memory link graph does not persist the imported seeds")
+		}
+		g = env.graph
+	}
+
+	netDetector := env.netDetector
+	if netDetector == nil {
+		if netDetector, err = privnet.NewDetector(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	norm, err := env.urlNormalizer()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	ing, err := ingest.NewIngester(ingest.Config{Graph: g, PrivateNetworkDetector: netDetector, Normalizer: norm})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	summary, err := ing.ImportSeeds(ctx, src, ingest.SeedOptions{
+		Format:    seedFormat,
+		BatchSize: *batchSize,
+		DryRun:    *dryRun,
+		Progress: func(p ingest.SeedProgress) {
+			fmt.Fprintf(stderr, "read %d seeds: %d accepted, %d rejected\n", p.Read, p.Accepted, p.Rejected)
+		},
+	})
+	printSeedSummary(stdout, summary, *dryRun)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// openSeedList opens the seed list at location and returns it along with its
+// content type, if known.
+func openSeedList(ctx context.Context, location string, stdin io.Reader) (io.ReadCloser, string, error) {
+	switch {
+	case location == "-":
+		return io.NopCloser(stdin), "", nil
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			_ = res.Body.Close()
+			return nil, "", fmt.Errorf("fetching %s: unexpected status %s", location, res.Status)
+		}
+		return res.Body, res.Header.Get("Content-Type"), nil
+	default:
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, "", err
+		}
+		return f, "", nil
+	}
+}
+
+// detectSeedFormat returns the format specified by name or, if name is empty,
+// the format implied by the extension of location or contentType.
+func detectSeedFormat(name, location, contentType string) (ingest.SeedFormat, error) {
+	if name != "" {
+		return ingest.ParseSeedFormat(name)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "text/csv" || strings.EqualFold(filepath.Ext(location), ".csv") {
+		return ingest.SeedFormatCSV, nil
+	}
+	return ingest.SeedFormatLines, nil
+}
+
+func printSeedSummary(w io.Writer, summary ingest.Summary, dryRun bool) {
+	if dryRun {
+		fmt.Fprintln(w, "dry run: the link graph has not been modified")
+	}
+	fmt.Fprintf(w, "accepted: %d\n", summary.Accepted)
+	fmt.Fprintf(w, "rejected: %d\n", summary.Rejected)
+	for _, recErr := range summary.Errors {
+		fmt.Fprintf(w, "  line %d: %s\n", recErr.Record, recErr.Message)
+	}
+	if shown := uint64(len(summary.Errors)); shown < summary.Rejected {
+		fmt.Fprintf(w, "  (%d more rejected seeds not shown)\n", summary.Rejected-shown)
+	}
+}
+
+// discardGraph is used by dry runs in place of the link graph; the ingester
+// never invokes it when importing seeds in dry-run mode.
+type discardGraph struct{}
+
+func (discardGraph) UpsertLink(*graph.Link) error { return nil }
+func (discardGraph) UpsertEdge(*graph.Edge) error { return nil }
@@ -0,0 +1,1199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"webcrawler/config"
+	"webcrawler/crawler"
+	"webcrawler/crawler/blobstore"
+	"webcrawler/crawler/dedup"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/extract"
+	"webcrawler/crawler/fetch"
+	"webcrawler/crawler/ingest"
+	ingestproto "webcrawler/crawler/ingest/proto"
+	"webcrawler/crawler/jobs"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graphapi"
+	graphproto "webcrawler/crawler/linkgraph/graphapi/proto"
+	boltgraph "webcrawler/crawler/linkgraph/store/bolt"
+	dbgraph "webcrawler/crawler/linkgraph/store/db"
+	esgraph "webcrawler/crawler/linkgraph/store/es"
+	memgraph "webcrawler/crawler/linkgraph/store/memory"
+	sqlitegraph "webcrawler/crawler/linkgraph/store/sqlite"
+	"webcrawler/crawler/pacing"
+	"webcrawler/crawler/privnet"
+	"webcrawler/crawler/queue"
+	"webcrawler/crawler/queue/kafka"
+	"webcrawler/crawler/recrawl"
+	"webcrawler/crawler/region"
+	"webcrawler/crawler/retry"
+	"webcrawler/crawler/robots"
+	"webcrawler/crawler/scope"
+	"webcrawler/crawler/textindexer/backup"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/crawler/textindexer/indexapi"
+	indexproto "webcrawler/crawler/textindexer/indexapi/proto"
+	"webcrawler/crawler/textindexer/store/es"
+	"webcrawler/crawler/textindexer/store/meili"
+	memidx "webcrawler/crawler/textindexer/store/memory"
+	"webcrawler/crawler/warc"
+	"webcrawler/frontend/admin"
+	"webcrawler/frontend/feeds"
+	"webcrawler/health"
+	"webcrawler/metrics/slo"
+	"webcrawler/pagerank/history"
+	"webcrawler/partition"
+	"webcrawler/service"
+	"webcrawler/supervisor"
+	"webcrawler/tracing"
+	"webcrawler/urlutil/normalizer"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// The timeout for each request issued by the crawler.
+const fetchTimeout = 30 * time.Second
+
+// linkGraph is implemented by the link graph stores.
+type linkGraph interface {
+	graph.Graph
+	graph.CheckpointStore
+}
+
+// environment holds the stores that are shared by the services running in a
+// process.
+type environment struct {
+	cfg     *config.Config
+	logger  *slog.Logger
+	graph   linkGraph
+	indexer index.Indexer
+	closers []io.Closer
+
+	// The files that the in-memory stores are persisted to; they are
+	// saved periodically by the state saver service.
+	stateFiles []*stateFile
+
+	// The score history is shared by the PageRank and frontend services;
+	// it is created on first use.
+	scoreHistory history.Store
+
+	// The backup scheduler is shared by the backup service and the admin
+	// API; it is created on first use.
+	backups *backup.Scheduler
+
+	// The URL normalizer is shared by the crawler and frontend services so
+	// that crawled and submitted links are normalized the same way; it is
+	// created on first use.
+	normalizer *normalizer.Normalizer
+
+	// The pacing controller and error store of the crawler service; they
+	// are exposed via the admin API if the crawler runs in the same
+	// process as the frontend.
+	pacing      *pacing.Controller
+	crawlErrors *errstore.Store
+
+	// The crawl job manager is created along with the crawler service and
+	// exposed via the admin API if the crawler runs in the same process as
+	// the frontend; it is nil if crawl jobs are not enabled.
+	jobs *jobs.Manager
+
+	// The tiered recrawl scheduler is created along with the crawler
+	// service if recrawl tiers are enabled; it is nil otherwise. Links
+	// that are ingested in the same process are added to it.
+	recrawlScheduler *recrawl.Scheduler
+
+	// The link ingester is shared by the admin API and the gRPC server;
+	// it is created on first use.
+	ingester *ingest.Ingester
+
+	// The queue worker is created along with the crawler service if the
+	// crawled links are distributed via a message queue; it is nil
+	// otherwise.
+	queueWorker *service.QueueWorker
+
+	// The barrier that synchronizes the crawls with the PageRank passes if
+	// the services are run by a supervisor; it is nil otherwise.
+	barrier *supervisor.Barrier
+
+	// The proxy pool is shared by the crawler and the service that health
+	// checks the proxies; it is created on first use.
+	proxies *fetch.ProxyPool
+
+	// The SLO tracker is shared by the SLO service and the frontend; it is
+	// created on first use.
+	slo *slo.Tracker
+
+	// The page feed builder is shared by the feed service and the
+	// frontend; it is created on first use.
+	feeds *feeds.Builder
+
+	// An optional override for the private network detector of the
+	// crawler; the demo command uses it to crawl the sample site that it
+	// serves on the loopback interface.
+	netDetector crawler.PrivateNetworkDetector
+}
+
+// newEnvironment connects to the link graph and text indexer stores
+// configured in cfg. Both stores are scoped to the configured namespace.
+// Callers must invoke Close on the returned environment when they are done
+// using it.
+func newEnvironment(cfg *config.Config, logger *slog.Logger) (*environment, error) {
+	env := &environment{cfg: cfg, logger: logger}
+
+	switch cfg.LinkGraph.Backend {
+	case config.LinkGraphDB:
+		g, err := dbgraph.NewDBGraphWithConfig(cfg.LinkGraph.DSN, dbgraph.Config{Namespace: cfg.Namespace, Logger: logger})
+		if err != nil {
+			return nil, fmt.Errorf("link graph: %w", err)
+		}
+		env.graph = g
+		env.closers = append(env.closers, g)
+	case config.LinkGraphES:
+		opts, err := esOptions(cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("link graph: %w", err)
+		}
+		client, err := es.NewClient(cfg.TextIndexer.ES.Nodes, opts)
+		if err != nil {
+			return nil, fmt.Errorf("link graph: %w", err)
+		}
+		g, err := esgraph.NewElasticSearchGraph(client, esgraph.Options{
+			IndexPrefix: cfg.LinkGraph.ESIndexPrefix,
+			Namespace:   cfg.Namespace,
+			Shards:      opts.Shards,
+			Replicas:    opts.Replicas,
+			Logger:      logger,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("link graph: %w", err)
+		}
+		env.graph = g
+	case config.LinkGraphBolt:
+		g, err := boltgraph.NewBoltGraphWithConfig(cfg.LinkGraph.BoltPath, boltgraph.Config{
+			Namespace: cfg.Namespace,
+			NoSync:    cfg.LinkGraph.BoltNoSync,
+			Logger:    logger,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("link graph: %w", err)
+		}
+		env.graph = g
+		env.closers = append(env.closers, g)
+	case config.LinkGraphGRPC:
+		conn, err := env.dialGRPC(cfg.LinkGraph.GRPCAddress)
+		if err != nil {
+			return nil, fmt.Errorf("link graph: %w", err)
+		}
+		env.graph = graphapi.NewGraphClient(context.Background(), graphproto.NewLinkGraphClient(conn))
+	case config.LinkGraphSQLite:
+		g, err := sqlitegraph.NewSQLiteGraphWithConfig(cfg.LinkGraph.SQLitePath, sqlitegraph.Config{Namespace: cfg.Namespace, Logger: logger})
+		if err != nil {
+			return nil, fmt.Errorf("link graph: %w", err)
+		}
+		env.graph = g
+		env.closers = append(env.closers, g)
+	default:
+		g, err := memgraph.NewInMemoryGraphWithConfig(memgraph.Config{Namespace: cfg.Namespace, Logger: logger})
+		if err != nil {
+			return nil, fmt.Errorf("link graph: %w", err)
+		}
+		env.graph = g
+		if path := cfg.LinkGraph.StateFile; path != "" {
+			sf, err := openStateFile("linkgraph", path, time.Duration(cfg.LinkGraph.StateSaveInterval), g.Load, g.Save, logger)
+			if err != nil {
+				return nil, fmt.Errorf("link graph: %w", err)
+			}
+			env.stateFiles = append(env.stateFiles, sf)
+			env.closers = append(env.closers, sf)
+		}
+	}
+
+	boosts, err := cfg.TextIndexer.Boosts()
+	if err != nil {
+		_ = env.Close()
+		return nil, fmt.Errorf("text indexer: %w", err)
+	}
+	memCfg := memidx.Config{
+		Namespace:   cfg.Namespace,
+		FieldBoosts: boosts,
+		BatchSize:   cfg.TextIndexer.BatchSize,
+		MaxOffset:   uint64(cfg.TextIndexer.MaxOffset),
+	}
+	switch cfg.TextIndexer.Backend {
+	case config.TextIndexerES:
+		opts, err := esOptions(cfg, logger)
+		if err != nil {
+			_ = env.Close()
+			return nil, fmt.Errorf("text indexer: %w", err)
+		}
+		indexer, err := es.NewElasticSearchIndexer(cfg.TextIndexer.ES.Nodes, opts)
+		if err != nil {
+			_ = env.Close()
+			return nil, fmt.Errorf("text indexer: %w", err)
+		}
+		env.indexer = indexer
+	case config.TextIndexerBleve:
+		indexer, err := memidx.NewDiskBleveIndexerWithConfig(cfg.TextIndexer.BlevePath, memCfg)
+		if err != nil {
+			_ = env.Close()
+			return nil, fmt.Errorf("text indexer: %w", err)
+		}
+		env.indexer = indexer
+		env.closers = append(env.closers, indexer)
+	case config.TextIndexerMeili:
+		indexer, err := meili.NewMeilisearchIndexer(cfg.TextIndexer.Meili.URL, meili.Options{
+			IndexName: cfg.TextIndexer.Meili.IndexName,
+			Namespace: cfg.Namespace,
+			APIKey:    cfg.TextIndexer.Meili.APIKey,
+			BatchSize: cfg.TextIndexer.BatchSize,
+			MaxOffset: uint64(cfg.TextIndexer.MaxOffset),
+			Logger:    logger,
+		})
+		if err != nil {
+			_ = env.Close()
+			return nil, fmt.Errorf("text indexer: %w", err)
+		}
+		env.indexer = indexer
+	case config.TextIndexerGRPC:
+		conn, err := env.dialGRPC(cfg.TextIndexer.GRPCAddress)
+		if err != nil {
+			_ = env.Close()
+			return nil, fmt.Errorf("text indexer: %w", err)
+		}
+		env.indexer = indexapi.NewTextIndexerClient(context.Background(), indexproto.NewTextIndexerClient(conn))
+	default:
+		indexer, err := memidx.NewInMemoryBleveIndexerWithConfig(memCfg)
+		if err != nil {
+			_ = env.Close()
+			return nil, fmt.Errorf("text indexer: %w", err)
+		}
+		env.indexer = indexer
+		if path := cfg.TextIndexer.StateFile; path != "" {
+			sf, err := openStateFile("textindexer", path, time.Duration(cfg.TextIndexer.StateSaveInterval), indexer.Restore, indexer.Dump, logger)
+			if err != nil {
+				_ = indexer.Close()
+				_ = env.Close()
+				return nil, fmt.Errorf("text indexer: %w", err)
+			}
+			env.stateFiles = append(env.stateFiles, sf)
+			// The documents must be saved before the indexer is closed.
+			env.closers = append(env.closers, sf)
+		}
+		env.closers = append(env.closers, indexer)
+	}
+
+	return env, nil
+}
+
+// esOptions returns the options for the ES-backed text indexer configured in
+// cfg. The cluster settings in the returned options are also used for
+// connecting the ES-backed link graph.
+func esOptions(cfg *config.Config, logger *slog.Logger) (es.Options, error) {
+	esCfg := cfg.TextIndexer.ES
+	opts := es.Options{
+		IndexName:          esCfg.IndexName,
+		Namespace:          cfg.Namespace,
+		Shards:             esCfg.Shards,
+		Replicas:           esCfg.Replicas,
+		RefreshInterval:    time.Duration(esCfg.RefreshInterval),
+		Username:           esCfg.Username,
+		Password:           esCfg.Password,
+		APIKey:             esCfg.APIKey,
+		InsecureSkipVerify: esCfg.InsecureSkipVerify,
+		Logger:             logger,
+
+		MappingCheckInterval: time.Duration(esCfg.MappingCheckInterval),
+		RejectWritesOnDrift:  esCfg.RejectWritesOnMappingDrift,
+	}
+	boosts, err := cfg.TextIndexer.Boosts()
+	if err != nil {
+		return es.Options{}, err
+	}
+	opts.FieldBoosts = boosts
+	opts.BatchSize = cfg.TextIndexer.BatchSize
+	opts.MaxOffset = uint64(cfg.TextIndexer.MaxOffset)
+	if esCfg.CACertFile != "" {
+		caCert, err := os.ReadFile(esCfg.CACertFile)
+		if err != nil {
+			return es.Options{}, err
+		}
+		opts.CACert = caCert
+	}
+	return opts, nil
+}
+
+// Close releases the resources held by the environment.
+func (env *environment) Close() error {
+	var err error
+	for _, c := range env.closers {
+		if cErr := c.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// crawlerService returns a crawler service for the environment.
+func (env *environment) crawlerService() (service.Service, error) {
+	crawlerCfg := env.cfg.Crawler
+	netDetector, err := env.privateNetworkDetector()
+	if err != nil {
+		return nil, err
+	}
+	urlGetter := &http.Client{Timeout: fetchTimeout}
+	urlNormalizer, err := env.urlNormalizer()
+	if err != nil {
+		return nil, err
+	}
+	fetchCfg := crawlerCfg.Fetch
+	// Zero disables following redirects rather than selecting the
+	// default limit.
+	maxRedirects := fetchCfg.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = -1
+	}
+	proxies, err := env.proxyPool()
+	if err != nil {
+		return nil, err
+	}
+	var render *fetch.RenderConfig
+	if renderCfg := fetchCfg.Render; renderCfg.Enabled() {
+		renderer, err := fetch.NewRemoteRenderer(renderCfg.ServiceURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		render = &fetch.RenderConfig{
+			Renderer: renderer,
+			Domains:  renderCfg.Domains,
+			Timeout:  time.Duration(renderCfg.Timeout),
+			Block: fetch.BlockRules{
+				ResourceTypes: renderCfg.BlockResourceTypes,
+				URLPatterns:   renderCfg.BlockURLPatterns,
+			},
+		}
+	}
+	fetchCache, err := env.fetchCache()
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := fetch.New(fetch.Config{
+		Retry: fetch.RetryPolicy{
+			MaxAttempts: fetchCfg.MaxAttempts,
+			BaseDelay:   time.Duration(fetchCfg.RetryBaseDelay),
+			MaxDelay:    time.Duration(fetchCfg.RetryMaxDelay),
+		},
+		MaxRedirects: maxRedirects,
+		Timeout:      time.Duration(fetchCfg.Timeout),
+		UserAgents:   fetchCfg.UserAgents,
+		Proxies:      proxies,
+		Render:       render,
+		Cache:        fetchCache,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	svcCfg := service.CrawlerConfig{
+		GraphAPI:               env.graph,
+		IndexAPI:               env.indexer,
+		PartitionDetector:      env.partitionDetector(),
+		PrivateNetworkDetector: netDetector,
+		URLGetter:              urlGetter,
+		Fetcher:                fetcher,
+		FetchWorkers:           crawlerCfg.FetchWorkers,
+		UpdateInterval:         time.Duration(crawlerCfg.UpdateInterval),
+		ReIndexThreshold:       time.Duration(crawlerCfg.ReIndexThreshold),
+		Budget: crawler.Budget{
+			MaxDuration: time.Duration(crawlerCfg.MaxPassDuration),
+			MaxBytes:    crawlerCfg.MaxPassBytes,
+		},
+		MaxBodySize:            crawlerCfg.MaxBodySize,
+		ShutdownDrainTimeout:   time.Duration(crawlerCfg.ShutdownDrainTimeout),
+		URLNormalizer:          urlNormalizer,
+		ExtractResourceLinks:   crawlerCfg.ExtractResourceLinks,
+		IgnoreRobotsDirectives: crawlerCfg.Robots.IgnorePageDirectives,
+		Logger:                 env.logger,
+	}
+	for _, name := range crawlerCfg.CaptureHeaders {
+		svcCfg.HeaderRules = append(svcCfg.HeaderRules, crawler.HeaderRule{Header: name})
+	}
+	for _, rule := range crawlerCfg.URLRewrites {
+		canonical, mirror, _ := strings.Cut(rule, "=")
+		svcCfg.URLRewriteRules = append(svcCfg.URLRewriteRules, crawler.URLRewriteRule{Canonical: canonical, Mirror: mirror})
+	}
+	if svcCfg.ExtractionProfiles, err = compileExtractionProfiles(crawlerCfg.ExtractionProfiles); err != nil {
+		return nil, err
+	}
+	if crawlerCfg.Region.Enabled() {
+		routerCfg, err := crawlerCfg.Region.Compile(crawlerCfg.ExtractionProfiles)
+		if err != nil {
+			return nil, err
+		}
+		if svcCfg.Region, err = region.New(routerCfg); err != nil {
+			return nil, err
+		}
+	}
+	if !crawlerCfg.Scope.IsZero() {
+		scopeCfg, err := crawlerCfg.Scope.Compile()
+		if err != nil {
+			return nil, err
+		}
+		if svcCfg.Scope, err = scope.New(scopeCfg); err != nil {
+			return nil, err
+		}
+	}
+	if crawlerCfg.Dedup.Enabled {
+		if svcCfg.Deduplicator, err = dedup.NewIndex(crawlerCfg.Dedup.MaxDistance); err != nil {
+			return nil, err
+		}
+	}
+	if pacingCfg := crawlerCfg.Pacing; pacingCfg.Enabled {
+		if env.pacing, err = pacing.NewController(pacing.Config{
+			DefaultDelay:   time.Duration(pacingCfg.DefaultDelay),
+			MaxDelay:       time.Duration(pacingCfg.MaxDelay),
+			InitialBackoff: time.Duration(pacingCfg.InitialBackoff),
+			MaxWait:        time.Duration(pacingCfg.MaxWait),
+		}); err != nil {
+			return nil, err
+		}
+		svcCfg.Pacer = env.pacing
+	}
+	// Links are re-crawled on every pass if the re-index threshold is zero
+	// so there is nothing to adapt.
+	if recrawlCfg := crawlerCfg.Recrawl; recrawlCfg.Adaptive && crawlerCfg.ReIndexThreshold > 0 {
+		policyCfg := recrawl.PolicyConfig{
+			BaseInterval:  time.Duration(crawlerCfg.ReIndexThreshold),
+			MinInterval:   time.Duration(recrawlCfg.MinInterval),
+			MaxInterval:   time.Duration(recrawlCfg.MaxInterval),
+			DepthPenalty:  recrawlCfg.DepthPenalty,
+			Scores:        recrawl.IndexScores{Finder: env.indexer},
+			PageRankBoost: recrawlCfg.PageRankBoost,
+		}
+		// Zero values disable the depth penalty and the PageRank boost
+		// rather than selecting the policy defaults.
+		if policyCfg.DepthPenalty == 0 {
+			policyCfg.DepthPenalty = -1
+		}
+		if policyCfg.PageRankBoost == 0 {
+			policyCfg.PageRankBoost = -1
+		}
+		if svcCfg.RecrawlPolicy, err = recrawl.NewPolicy(policyCfg); err != nil {
+			return nil, err
+		}
+	} else if tiersCfg := crawlerCfg.Recrawl.Tiers; tiersCfg.Enabled {
+		if env.recrawlScheduler, err = newRecrawlScheduler(tiersCfg); err != nil {
+			return nil, err
+		}
+		svcCfg.RecrawlPolicy = env.recrawlScheduler
+	}
+	if retryCfg := crawlerCfg.Retry; retryCfg.Enabled {
+		if svcCfg.Failures, err = retry.NewTracker(retry.Config{
+			Graph:       env.graph,
+			BaseDelay:   time.Duration(retryCfg.BaseDelay),
+			MaxDelay:    time.Duration(retryCfg.MaxDelay),
+			MaxAttempts: retryCfg.MaxAttempts,
+			Logger:      env.logger,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if archiveCfg := crawlerCfg.Archive; archiveCfg.Enabled() {
+		archiver, err := warc.NewFileWriter(warc.FileConfig{Dir: archiveCfg.Dir, MaxFileSize: archiveCfg.MaxFileSize})
+		if err != nil {
+			return nil, err
+		}
+		env.closers = append(env.closers, archiver)
+		svcCfg.Archiver = archiver
+	}
+	if crawlerCfg.ErrorStoreSize > 0 {
+		if env.crawlErrors, err = errstore.NewStore(errstore.Config{MaxRecords: crawlerCfg.ErrorStoreSize}); err != nil {
+			return nil, err
+		}
+		svcCfg.Errors = env.crawlErrors
+	}
+	if flusher, ok := env.indexer.(crawler.Flusher); ok {
+		svcCfg.Flushers = append(svcCfg.Flushers, flusher)
+	}
+
+	if robotsCfg := crawlerCfg.Robots; robotsCfg.Enabled {
+		var store robots.Store = robots.NewMemoryStore()
+		if robotsCfg.Store == config.RobotsStoreDB {
+			pgStore, err := robots.NewPostgresStore(env.cfg.LinkGraph.DSN)
+			if err != nil {
+				return nil, fmt.Errorf("robots store: %w", err)
+			}
+			env.closers = append(env.closers, pgStore)
+			store = pgStore
+		}
+		cache, err := robots.NewCache(robots.Config{
+			URLGetter:   urlGetter,
+			Store:       store,
+			UserAgent:   robotsCfg.UserAgent,
+			TTL:         time.Duration(robotsCfg.TTL),
+			NegativeTTL: time.Duration(robotsCfg.NegativeTTL),
+			Logger:      env.logger,
+		})
+		if err != nil {
+			return nil, err
+		}
+		svcCfg.Robots = cache
+	}
+
+	var linkQueue *queue.LinkQueue
+	if queueCfg := crawlerCfg.Queue; queueCfg.Enabled() {
+		if linkQueue, err = env.linkQueue(); err != nil {
+			return nil, err
+		}
+		svcCfg.Queue = linkQueue
+		svcCfg.QueueLease = time.Duration(queueCfg.Lease)
+	}
+
+	svc, err := service.NewCrawler(svcCfg)
+	if err != nil {
+		return nil, err
+	}
+	if linkQueue != nil {
+		if env.queueWorker, err = service.NewQueueWorker(service.QueueWorkerConfig{
+			Queue:    linkQueue,
+			Pipeline: svc.PipelineConfig(),
+			Gate:     env.crawlGate(),
+			Logger:   env.logger,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if jobsCfg := crawlerCfg.Jobs; jobsCfg.Enabled() {
+		store, err := jobs.NewFileStore(jobsCfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		if env.jobs, err = jobs.NewManager(jobs.Config{
+			Store:     store,
+			Crawler:   svc.PipelineConfig(),
+			BatchSize: jobsCfg.BatchSize,
+			Gate:      env.crawlGate(),
+			Logger:    env.logger,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return svc, nil
+}
+
+// crawlGate returns the gate that the crawls which run outside of the crawl
+// passes (i.e. crawl jobs and queue workers) must enter or nil if the services
+// are not run by a supervisor.
+func (env *environment) crawlGate() crawler.Gate {
+	if env.barrier == nil {
+		return nil
+	}
+	return env.barrier
+}
+
+// jobsService returns the service that runs the crawl jobs or nil if crawl
+// jobs are not enabled. It must be built after the crawler service.
+func (env *environment) jobsService() (service.Service, error) {
+	if env.jobs == nil {
+		return nil, nil
+	}
+	return env.jobs, nil
+}
+
+// queueWorkerService returns the service that crawls the links received from
+// the link queue or nil if no queue is configured. It must be built after the
+// crawler service.
+func (env *environment) queueWorkerService() (service.Service, error) {
+	if env.queueWorker == nil {
+		return nil, nil
+	}
+	return env.queueWorker, nil
+}
+
+// linkQueue returns the queue that distributes the links of each crawl pass
+// among the crawler instances.
+func (env *environment) linkQueue() (*queue.LinkQueue, error) {
+	queueCfg := env.cfg.Crawler.Queue
+	var broker queue.Broker
+	switch queueCfg.Backend {
+	case config.QueueBackendKafka:
+		kafkaBroker, err := kafka.NewBroker(kafka.Config{Brokers: queueCfg.Brokers})
+		if err != nil {
+			return nil, err
+		}
+		env.closers = append(env.closers, kafkaBroker)
+		broker = kafkaBroker
+	default:
+		memBroker := queue.NewMemoryBroker()
+		env.closers = append(env.closers, memBroker)
+		broker = memBroker
+	}
+	topic := queueCfg.Topic
+	if topic == "" {
+		topic = fmt.Sprintf("webcrawler.%s.links", env.cfg.Namespace)
+	}
+	return queue.NewLinkQueue(queue.LinkQueueConfig{
+		Broker:          broker,
+		Topic:           topic,
+		DeadLetterTopic: queueCfg.DeadLetterTopic,
+		Group:           queueCfg.Group,
+		MaxAttempts:     queueCfg.MaxAttempts,
+		BatchSize:       queueCfg.BatchSize,
+		Logger:          env.logger,
+	})
+}
+
+// proxyPoolService returns the service that health checks the outbound
+// proxies of the crawler or nil if fetches are not proxied.
+func (env *environment) proxyPoolService() (service.Service, error) {
+	pool, err := env.proxyPool()
+	if err != nil || pool == nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// proxyPool returns the pool of outbound proxies for the crawler or nil if
+// fetches are not proxied.
+func (env *environment) proxyPool() (*fetch.ProxyPool, error) {
+	proxyCfg := env.cfg.Crawler.Fetch.Proxy
+	if !proxyCfg.Enabled() || env.proxies != nil {
+		return env.proxies, nil
+	}
+	proxies, err := fetch.ParseProxies(proxyCfg.URLs)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := fetch.NewProxyPool(fetch.ProxyPoolConfig{
+		Provider:       proxies,
+		MaxFailures:    proxyCfg.MaxFailures,
+		Cooldown:       time.Duration(proxyCfg.Cooldown),
+		HealthCheckURL: proxyCfg.HealthCheckURL,
+		CheckInterval:  time.Duration(proxyCfg.CheckInterval),
+		Logger:         env.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env.proxies = pool
+	return pool, nil
+}
+
+// fetchCache returns the settings of the HTTP cache in front of the fetcher or
+// nil if responses are not cached.
+func (env *environment) fetchCache() (*fetch.CacheConfig, error) {
+	cacheCfg := env.cfg.Crawler.Fetch.Cache
+	if !cacheCfg.Enabled() {
+		return nil, nil
+	}
+
+	var store fetch.CacheStore
+	switch cacheCfg.Backend {
+	case config.FetchCacheRedis:
+		opts, err := redis.ParseURL(cacheCfg.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("fetch cache: %w", err)
+		}
+		client := redis.NewClient(opts)
+		env.closers = append(env.closers, client)
+		store = fetch.NewRedisCache(client, cacheCfg.KeyPrefix)
+	default:
+		diskCache, err := fetch.NewDiskCache(cacheCfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		store = diskCache
+	}
+	return &fetch.CacheConfig{
+		Store:        store,
+		MinTTL:       time.Duration(cacheCfg.MinTTL),
+		Retention:    time.Duration(cacheCfg.Retention),
+		MaxEntrySize: cacheCfg.MaxEntrySize,
+		Logger:       env.logger,
+	}, nil
+}
+
+// compileExtractionProfiles compiles the configured extraction profiles.
+// Profiles that only tag a domain with a region are skipped.
+func compileExtractionProfiles(cfgs []config.ExtractionProfileConfig) ([]extract.Profile, error) {
+	profiles := make([]extract.Profile, 0, len(cfgs))
+	for _, profileCfg := range cfgs {
+		if !profileCfg.HasSelectors() {
+			continue
+		}
+		profile, err := profileCfg.Compile()
+		if err != nil {
+			return nil, fmt.Errorf("extraction profile for %q: %w", profileCfg.Domain, err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// dialGRPC returns a connection to the gRPC server at addr through which the
+// stores of another process are accessed. The connection is closed along with
+// the environment.
+func (env *environment) dialGRPC(addr string) (*grpc.ClientConn, error) {
+	opts := append(tracing.DialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	env.closers = append(env.closers, conn)
+	return conn, nil
+}
+
+// privateNetworkDetector returns the detector for links to private networks
+// used by the crawler and the link ingester.
+func (env *environment) privateNetworkDetector() (crawler.PrivateNetworkDetector, error) {
+	if env.netDetector != nil {
+		return env.netDetector, nil
+	}
+	return privnet.NewDetector()
+}
+
+// urlNormalizer returns the URL normalizer configured for the environment.
+func (env *environment) urlNormalizer() (*normalizer.Normalizer, error) {
+	if env.normalizer != nil {
+		return env.normalizer, nil
+	}
+	normCfg := env.cfg.Crawler.URLNormalization
+	n, err := normalizer.New(normalizer.Config{
+		StripParams:       normCfg.StripQueryParams,
+		SortQuery:         normCfg.SortQueryParams,
+		TrimTrailingSlash: normCfg.TrimTrailingSlash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env.normalizer = n
+	return n, nil
+}
+
+// pageRankService returns a PageRank service for the environment.
+func (env *environment) pageRankService() (service.Service, error) {
+	hist, err := env.pageRankHistory()
+	if err != nil {
+		return nil, err
+	}
+	return service.NewPageRank(service.PageRankConfig{
+		GraphAPI:          env.graph,
+		IndexAPI:          env.indexer,
+		ComputeWorkers:    env.cfg.PageRank.ComputeWorkers,
+		UpdateInterval:    time.Duration(env.cfg.PageRank.UpdateInterval),
+		History:           hist,
+		HistoryPasses:     env.cfg.PageRank.HistoryPasses,
+		SkipNoFollowEdges: env.cfg.PageRank.SkipNoFollowEdges,
+		Logger:            env.logger,
+	})
+}
+
+// pageRankHistory returns the PageRank score history store configured for the
+// environment.
+func (env *environment) pageRankHistory() (history.Store, error) {
+	if env.scoreHistory != nil {
+		return env.scoreHistory, nil
+	}
+	if env.cfg.PageRank.HistoryStore == config.PageRankHistoryDB {
+		pgStore, err := history.NewPostgresStore(env.cfg.LinkGraph.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("score history store: %w", err)
+		}
+		env.closers = append(env.closers, pgStore)
+		env.scoreHistory = pgStore
+	} else {
+		env.scoreHistory = history.NewMemoryStore()
+	}
+	return env.scoreHistory, nil
+}
+
+// frontendService returns a frontend service for the environment.
+func (env *environment) frontendService() (service.Service, error) {
+	feCfg := env.cfg.Frontend
+	svcCfg := service.FrontendConfig{
+		GraphAPI:             env.graph,
+		IndexAPI:             env.indexer,
+		ListenAddress:        feCfg.ListenAddress,
+		ResultsPerPage:       feCfg.ResultsPerPage,
+		GraphQLMaxDepth:      feCfg.GraphQLMaxDepth,
+		GraphQLMaxComplexity: feCfg.GraphQLMaxComplexity,
+		Logger:               env.logger,
+	}
+	hist, err := env.pageRankHistory()
+	if err != nil {
+		return nil, err
+	}
+	svcCfg.ScoreHistory = hist
+	if svcCfg.Access, err = feCfg.AccessPolicy(); err != nil {
+		return nil, fmt.Errorf("access policy: %w", err)
+	}
+	if svcCfg.URLNormalizer, err = env.urlNormalizer(); err != nil {
+		return nil, err
+	}
+	if svcCfg.SLO, err = env.sloTracker(); err != nil {
+		return nil, err
+	}
+	builder, err := env.feedBuilder()
+	if err != nil {
+		return nil, err
+	} else if builder != nil {
+		svcCfg.Feeds = builder
+	}
+	svcCfg.HealthChecks = env.healthChecks()
+	svcCfg.ReadinessChecks = env.readinessChecks()
+	if feCfg.AdminToken != "" {
+		adminHandler, err := env.adminHandler()
+		if err != nil {
+			return nil, err
+		}
+		svcCfg.Admin = adminHandler
+	}
+	return service.NewFrontend(svcCfg)
+}
+
+// supervisor returns a supervisor for running services in a single process.
+// The crawler and PageRank services are run as passes so that PageRank scores
+// are never calculated while a crawl pass is still updating the link graph;
+// the remaining services run alongside them. Crawl jobs and queue workers
+// enter the barrier of the supervisor before crawling each batch of links (see
+// crawlGate) so that PageRank passes do not overlap with them either.
+func (env *environment) supervisor(services []service.Service) (*supervisor.Supervisor, error) {
+	cfg := supervisor.Config{Barrier: env.barrier, Logger: env.logger}
+	for _, svc := range services {
+		switch svc := svc.(type) {
+		case *service.Crawler:
+			cfg.Passes = append(cfg.Passes, supervisor.Pass{
+				Name:     svc.Name(),
+				Runner:   svc,
+				Interval: time.Duration(env.cfg.Crawler.UpdateInterval),
+			})
+		case *service.PageRank:
+			cfg.Passes = append(cfg.Passes, supervisor.Pass{
+				Name:       svc.Name(),
+				Runner:     svc,
+				Interval:   time.Duration(env.cfg.PageRank.UpdateInterval),
+				Exclusive:  true,
+				LeaderOnly: true,
+			})
+		default:
+			cfg.Services = append(cfg.Services, svc)
+		}
+	}
+	return supervisor.New(cfg)
+}
+
+// healthService returns the service that serves the health and readiness
+// probes of the crawl and pagerank commands or nil if the probes are
+// disabled.
+func (env *environment) healthService() (service.Service, error) {
+	if env.cfg.Health.ListenAddress == "" {
+		return nil, nil
+	}
+	return service.NewHealthServer(service.HealthServerConfig{
+		ListenAddress:   env.cfg.Health.ListenAddress,
+		HealthChecks:    env.healthChecks(),
+		ReadinessChecks: env.readinessChecks(),
+		Logger:          env.logger,
+	})
+}
+
+// healthChecks returns the checks for the health of the stores that are
+// reported at /healthz.
+func (env *environment) healthChecks() map[string]health.Checker {
+	checks := make(map[string]health.Checker)
+	if checker, ok := env.indexer.(*es.ElasticSearchIndexer); ok {
+		checks["textindexer.mapping"] = checker
+	}
+	return checks
+}
+
+// readinessChecks returns the checks for whether the stores that are backed
+// by a remote server can be reached; they are reported at /readyz. Stores
+// that run in the same process are always ready.
+func (env *environment) readinessChecks() map[string]health.Checker {
+	timeout := time.Duration(env.cfg.Health.PingTimeout)
+	checks := make(map[string]health.Checker)
+	if p, ok := env.graph.(graph.Pinger); ok {
+		checks["linkgraph"] = health.PingChecker(p, timeout)
+	}
+	if p, ok := env.indexer.(index.Pinger); ok {
+		checks["textindexer"] = health.PingChecker(p, timeout)
+	}
+	return checks
+}
+
+// mappingMonitorService returns the service that periodically checks the
+// mapping of the ES-backed text index for drift or nil if the text indexer
+// is not backed by ES or periodic checks are disabled.
+func (env *environment) mappingMonitorService() (service.Service, error) {
+	indexer, ok := env.indexer.(*es.ElasticSearchIndexer)
+	if !ok {
+		return nil, nil
+	}
+	if monitor := indexer.MappingMonitor(); monitor != nil {
+		return monitor, nil
+	}
+	return nil, nil
+}
+
+// stateService returns the service that periodically saves the state files
+// of the in-memory stores. It returns nil if no state file has a save
+// interval.
+func (env *environment) stateService() (service.Service, error) {
+	var saver stateSaver
+	for _, sf := range env.stateFiles {
+		if sf.interval > 0 {
+			saver = append(saver, sf)
+		}
+	}
+	if len(saver) == 0 {
+		return nil, nil
+	}
+	return saver, nil
+}
+
+// sloService returns the service that samples the metrics for the SLO
+// indicators served by the frontend.
+func (env *environment) sloService() (service.Service, error) {
+	return env.sloTracker()
+}
+
+// sloTracker returns the SLO tracker for the environment.
+func (env *environment) sloTracker() (*slo.Tracker, error) {
+	if env.slo != nil {
+		return env.slo, nil
+	}
+	tracker, err := slo.NewTracker(slo.Config{
+		FetchSuccessTarget: env.cfg.Frontend.SLOFetchSuccessTarget,
+		Logger:             env.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env.slo = tracker
+	return tracker, nil
+}
+
+// feedService returns the service that regenerates the page feeds served by
+// the frontend or nil if the feeds are disabled.
+func (env *environment) feedService() (service.Service, error) {
+	builder, err := env.feedBuilder()
+	if err != nil || builder == nil {
+		return nil, err
+	}
+	return builder, nil
+}
+
+// feedBuilder returns the page feed builder for the environment or nil if
+// the feeds are disabled.
+func (env *environment) feedBuilder() (*feeds.Builder, error) {
+	feCfg := env.cfg.Frontend
+	if feCfg.FeedRefreshInterval == 0 || env.feeds != nil {
+		return env.feeds, nil
+	}
+	builder, err := feeds.NewBuilder(feeds.Config{
+		IndexAPI:        env.indexer,
+		RefreshInterval: time.Duration(feCfg.FeedRefreshInterval),
+		Size:            feCfg.FeedSize,
+		Logger:          env.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env.feeds = builder
+	return builder, nil
+}
+
+// adminHandler returns the admin API handler for the environment.
+func (env *environment) adminHandler() (*admin.Handler, error) {
+	feCfg := env.cfg.Frontend
+	var auditOut io.Writer
+	if feCfg.AdminAuditLog != "" {
+		f, err := os.OpenFile(feCfg.AdminAuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("admin audit log: %w", err)
+		}
+		env.closers = append(env.closers, f)
+		auditOut = f
+	}
+
+	adminCfg := admin.Config{
+		IndexAPI: env.indexer,
+		AuditLog: admin.NewInMemoryAuditLog(auditOut),
+		Token:    feCfg.AdminToken,
+		Graph:    env.graph,
+	}
+	sched, err := env.backupScheduler()
+	if err != nil {
+		return nil, err
+	} else if sched != nil {
+		adminCfg.Backups = sched
+	}
+	if env.pacing != nil {
+		adminCfg.Pacing = env.pacing
+	}
+	if env.crawlErrors != nil {
+		adminCfg.Errors = env.crawlErrors
+	}
+	if env.jobs != nil {
+		adminCfg.Jobs = env.jobs
+	}
+	ingester, err := env.linkIngester()
+	if err != nil {
+		return nil, err
+	}
+	adminCfg.Ingest = ingest.NewHTTPHandler(ingester)
+	return admin.NewHandler(adminCfg)
+}
+
+// linkIngester returns the ingester for externally discovered links. The
+// links are added to the recrawl scheduler if the crawler runs in the same
+// process; otherwise they are picked up by the next crawl pass.
+func (env *environment) linkIngester() (*ingest.Ingester, error) {
+	if env.ingester != nil {
+		return env.ingester, nil
+	}
+	netDetector, err := env.privateNetworkDetector()
+	if err != nil {
+		return nil, err
+	}
+	urlNormalizer, err := env.urlNormalizer()
+	if err != nil {
+		return nil, err
+	}
+	ingestCfg := ingest.Config{
+		Graph:                  env.graph,
+		PrivateNetworkDetector: netDetector,
+		Normalizer:             urlNormalizer,
+	}
+	if env.recrawlScheduler != nil {
+		ingestCfg.Frontier = env.recrawlScheduler
+	}
+	if env.ingester, err = ingest.NewIngester(ingestCfg); err != nil {
+		return nil, err
+	}
+	return env.ingester, nil
+}
+
+// grpcService returns the service that serves the gRPC APIs or nil if they
+// are disabled.
+func (env *environment) grpcService() (service.Service, error) {
+	if env.cfg.GRPC.ListenAddress == "" {
+		return nil, nil
+	}
+	ingester, err := env.linkIngester()
+	if err != nil {
+		return nil, err
+	}
+	return service.NewGRPCServer(service.GRPCServerConfig{
+		ListenAddress: env.cfg.GRPC.ListenAddress,
+		Services: []func(*grpc.Server){
+			func(srv *grpc.Server) { ingestproto.RegisterLinkIngestionServer(srv, ingest.NewGRPCServer(ingester)) },
+			func(srv *grpc.Server) { graphproto.RegisterLinkGraphServer(srv, graphapi.NewGraphServer(env.graph)) },
+			func(srv *grpc.Server) {
+				indexproto.RegisterTextIndexerServer(srv, indexapi.NewTextIndexerServer(env.indexer))
+			},
+		},
+		Logger: env.logger,
+	})
+}
+
+// backupService returns the index backup service for the environment or nil
+// if backups are not enabled.
+func (env *environment) backupService() (service.Service, error) {
+	sched, err := env.backupScheduler()
+	if err != nil || sched == nil {
+		return nil, err
+	}
+	return sched, nil
+}
+
+// backupScheduler returns the index backup scheduler for the environment or
+// nil if backups are not enabled.
+func (env *environment) backupScheduler() (*backup.Scheduler, error) {
+	backupCfg := env.cfg.TextIndexer.Backup
+	if !backupCfg.Enabled || env.backups != nil {
+		return env.backups, nil
+	}
+
+	var backend backup.Backend
+	if esIndexer, ok := env.indexer.(*es.ElasticSearchIndexer); ok {
+		backend = esIndexer.SnapshotBackend(backupCfg.ESRepository)
+	} else {
+		store, err := blobstore.NewFileStore(backupCfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("backup store: %w", err)
+		}
+		if backend, err = backup.NewBlobBackend(backup.BlobConfig{Index: env.indexer, Store: store}); err != nil {
+			return nil, err
+		}
+	}
+
+	sched, err := backup.NewScheduler(backup.Config{
+		Backend:  backend,
+		Interval: time.Duration(backupCfg.Interval),
+		Retention: backup.Retention{
+			KeepLast: backupCfg.KeepLast,
+			MaxAge:   time.Duration(backupCfg.MaxAge),
+		},
+		SmokeQueries: backupCfg.SmokeQueries,
+		Logger:       env.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env.backups = sched
+	return sched, nil
+}
+
+// partitionDetector returns the partition detector configured for the
+// environment.
+func (env *environment) partitionDetector() partition.Detector {
+	partCfg := env.cfg.Partition
+	self := partCfg.Self
+	if self == "" {
+		self, _ = os.Hostname()
+	}
+	if partCfg.Detector == config.PartitionDetectorDNS {
+		return partition.NewDNSDetector(partCfg.SRVName, self)
+	}
+	return partition.NewStaticDetector(self, partCfg.Members)
+}
+
+// newRecrawlScheduler returns a tiered recrawl scheduler that assigns links
+// matching the configured news and archive patterns to the respective tiers.
+func newRecrawlScheduler(cfg config.RecrawlTiersConfig) (*recrawl.Scheduler, error) {
+	var rules []recrawl.Rule
+	for _, tierPatterns := range []struct {
+		tier     recrawl.Tier
+		patterns []string
+	}{
+		{recrawl.TierNews, cfg.NewsPatterns},
+		{recrawl.TierArchive, cfg.ArchivePatterns},
+	} {
+		for _, pattern := range tierPatterns.patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("recrawl tier pattern %q: %w", pattern, err)
+			}
+			rules = append(rules, recrawl.Rule{Pattern: re, Tier: tierPatterns.tier})
+		}
+	}
+	return recrawl.NewScheduler(recrawl.Config{
+		Rules: rules,
+		Intervals: map[recrawl.Tier]time.Duration{
+			recrawl.TierNews:     time.Duration(cfg.NewsInterval),
+			recrawl.TierStandard: time.Duration(cfg.StandardInterval),
+			recrawl.TierArchive:  time.Duration(cfg.ArchiveInterval),
+		},
+	})
+}
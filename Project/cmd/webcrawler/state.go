@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+	"webcrawler/service"
+)
+
+// stateFile persists the contents of an in-memory store to a file so that
+// they survive a restart. The file is replaced atomically on every save so
+// that a crash while saving never leaves a partially written state behind.
+type stateFile struct {
+	name     string
+	path     string
+	interval time.Duration
+	save     func(io.Writer) error
+	logger   *slog.Logger
+
+	// Serializes the periodic saves with the final save on Close.
+	mu sync.Mutex
+}
+
+// openStateFile restores the store called name from the file at path using
+// load if the file exists. The returned state file saves the store using
+// save every interval while it runs as a service and once more when it is
+// closed.
+func openStateFile(name, path string, interval time.Duration, load func(io.Reader) error, save func(io.Writer) error, logger *slog.Logger) (*stateFile, error) {
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		err = load(bufio.NewReader(f))
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("restore %s: %s: %w", name, path, err)
+		}
+		logger.Info("restored state", "store", name, "path", path)
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("restore %s: %w", name, err)
+	}
+
+	return &stateFile{
+		name:     name,
+		path:     path,
+		interval: interval,
+		save:     save,
+		logger:   logger,
+	}, nil
+}
+
+// Save writes the contents of the store to the state file.
+func (sf *stateFile) Save() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if err := sf.writeFile(); err != nil {
+		return fmt.Errorf("save %s: %w", sf.name, err)
+	}
+	return nil
+}
+
+func (sf *stateFile) writeFile() error {
+	tmp := sf.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err = sf.save(w); err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, sf.path)
+}
+
+// Close saves the contents of the store one last time.
+func (sf *stateFile) Close() error {
+	return sf.Save()
+}
+
+// Name implements service.Service.
+func (sf *stateFile) Name() string { return sf.name + "-state" }
+
+// Run implements service.Service. It saves the contents of the store every
+// save interval until ctx is cancelled. Failed saves are logged and retried
+// at the next interval.
+func (sf *stateFile) Run(ctx context.Context) error {
+	ticker := time.NewTicker(sf.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		if err := sf.Save(); err != nil {
+			sf.logger.Error("unable to save state", "store", sf.name, "err", err)
+			continue
+		}
+		sf.logger.Debug("saved state", "store", sf.name, "path", sf.path, "duration", time.Since(start))
+	}
+}
+
+// stateSaver periodically saves the state files of the in-memory stores of
+// an environment.
+type stateSaver []*stateFile
+
+// Name implements service.Service.
+func (stateSaver) Name() string { return "state-saver" }
+
+// Run implements service.Service.
+func (s stateSaver) Run(ctx context.Context) error {
+	group := make(service.Group, 0, len(s))
+	for _, sf := range s {
+		group = append(group, sf)
+	}
+	return group.Run(ctx)
+}
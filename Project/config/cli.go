@@ -0,0 +1,64 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// RunCommand implements the "config" CLI command. The supported subcommands
+// are:
+//
+//   - validate: load and validate the configuration and report any errors.
+//   - print-effective: print the effective configuration after applying the
+//     defaults, the configuration file and the environment.
+//
+// RunCommand returns the exit code for the process.
+func RunCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch args[0] {
+	case "validate":
+		if _, err := Load(*path); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "configuration is valid")
+		return 0
+	case "print-effective":
+		cfg, err := Load(*path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if err = cfg.PrintEffective(stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown config subcommand %q\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `usage: config <subcommand> [-config file.{json,yaml,toml}]
+
+subcommands:
+  validate          validate the configuration and report any errors
+  print-effective   print the configuration after applying defaults,
+                    the configuration file and environment overrides
+`)
+}
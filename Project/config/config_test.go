@@ -0,0 +1,769 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"webcrawler/crawler/region"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/namespace"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ConfigTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type ConfigTestSuite struct{}
+
+func (s *ConfigTestSuite) TestDefaultsAreValid(c *gc.C) {
+	c.Assert(Default().Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestDecode(c *gc.C) {
+	cfg := Default()
+	err := cfg.Decode(strings.NewReader(`{
+		"crawler": {"fetchWorkers": 4, "updateInterval": "1m30s"},
+		"textIndexer": {"backend": "es", "es": {"nodes": ["http://es:9200"]}}
+	}`))
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.Crawler.FetchWorkers, gc.Equals, 4)
+	c.Assert(cfg.Crawler.UpdateInterval, gc.Equals, Duration(90*time.Second))
+	c.Assert(cfg.TextIndexer.ES.Nodes, gc.DeepEquals, []string{"http://es:9200"})
+
+	// Settings not present in the file retain their defaults.
+	c.Assert(cfg.TextIndexer.ES.IndexName, gc.Equals, "textindexer")
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestDecodeRejectsUnknownSettings(c *gc.C) {
+	err := Default().Decode(strings.NewReader(`{"crawler": {"fetchWorkerz": 4}}`))
+	c.Assert(err, gc.ErrorMatches, `.*unknown field "fetchWorkerz".*`)
+}
+
+func (s *ConfigTestSuite) TestDecodeFormats(c *gc.C) {
+	docs := map[string]string{
+		"config.yaml": `
+crawler:
+  fetchWorkers: 4
+  updateInterval: 1m30s
+textIndexer:
+  backend: es
+  es:
+    nodes: ["http://es:9200"]
+`,
+		"config.toml": `
+[crawler]
+fetchWorkers = 4
+updateInterval = "1m30s"
+
+[textIndexer]
+backend = "es"
+es = { nodes = ["http://es:9200"] }
+`,
+	}
+	for path, doc := range docs {
+		cfg := Default()
+		err := cfg.DecodeFormat(strings.NewReader(doc), FormatFor(path))
+		c.Assert(err, gc.IsNil, gc.Commentf(path))
+		c.Assert(cfg.Crawler.FetchWorkers, gc.Equals, 4, gc.Commentf(path))
+		c.Assert(cfg.Crawler.UpdateInterval, gc.Equals, Duration(90*time.Second), gc.Commentf(path))
+		c.Assert(cfg.TextIndexer.ES.Nodes, gc.DeepEquals, []string{"http://es:9200"}, gc.Commentf(path))
+		c.Assert(cfg.TextIndexer.ES.IndexName, gc.Equals, "textindexer", gc.Commentf(path))
+	}
+
+	c.Assert(Default().DecodeFormat(strings.NewReader(""), FormatYAML), gc.IsNil)
+	err := Default().DecodeFormat(strings.NewReader("crawler:\n  fetchWorkerz: 4\n"), FormatYAML)
+	c.Assert(err, gc.ErrorMatches, `.*unknown field "fetchWorkerz".*`)
+	err = Default().DecodeFormat(strings.NewReader("[crawler]\nfetchWorkerz = 4\n"), FormatTOML)
+	c.Assert(err, gc.ErrorMatches, `.*unknown field "fetchWorkerz".*`)
+	c.Assert(FormatFor("webcrawler.YML"), gc.Equals, FormatYAML)
+	c.Assert(FormatFor("webcrawler.conf"), gc.Equals, FormatJSON)
+}
+
+func (s *ConfigTestSuite) TestApplyEnv(c *gc.C) {
+	env := map[string]string{
+		"WEBCRAWLER_CRAWLER_FETCH_WORKERS":   "32",
+		"WEBCRAWLER_CRAWLER_UPDATE_INTERVAL": "10m",
+		"WEBCRAWLER_ES_NODES":                "http://a:9200, http://b:9200",
+		"WEBCRAWLER_ES_INSECURE_SKIP_VERIFY": "true",
+
+		"WEBCRAWLER_ES_REJECT_WRITES_ON_MAPPING_DRIFT": "true",
+	}
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(env)), gc.IsNil)
+	c.Assert(cfg.Crawler.FetchWorkers, gc.Equals, 32)
+	c.Assert(cfg.Crawler.UpdateInterval, gc.Equals, Duration(10*time.Minute))
+	c.Assert(cfg.TextIndexer.ES.Nodes, gc.DeepEquals, []string{"http://a:9200", "http://b:9200"})
+	c.Assert(cfg.TextIndexer.ES.InsecureSkipVerify, gc.Equals, true)
+	c.Assert(cfg.TextIndexer.ES.RejectWritesOnMappingDrift, gc.Equals, true)
+	c.Assert(cfg.TextIndexer.ES.MappingCheckInterval, gc.Equals, Duration(5*time.Minute))
+}
+
+func (s *ConfigTestSuite) TestApplyEnvInvalidValue(c *gc.C) {
+	env := map[string]string{"WEBCRAWLER_CRAWLER_FETCH_WORKERS": "many"}
+	err := Default().ApplyEnv(lookupFrom(env))
+	c.Assert(err, gc.ErrorMatches, `invalid value "many" for WEBCRAWLER_CRAWLER_FETCH_WORKERS.*`)
+}
+
+func (s *ConfigTestSuite) TestValidateReportsAllErrors(c *gc.C) {
+	cfg := Default()
+	cfg.Crawler.FetchWorkers = 0
+	cfg.LinkGraph.Backend = LinkGraphDB
+	cfg.TextIndexer.Backend = TextIndexerES
+	cfg.Crawler.CaptureHeaders = []string{"X-Generator", "X Powered By"}
+	cfg.Frontend.SLOFetchSuccessTarget = 1
+	cfg.Crawler.MaxBodySize = 0
+	cfg.Crawler.ErrorStoreSize = -1
+	cfg.Frontend.FeedSize = 0
+
+	err := cfg.Validate()
+	c.Assert(err, gc.NotNil)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.fetchWorkers: must be greater than zero.*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*linkGraph\.dsn: must be set when the "db" backend is selected.*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*textIndexer\.es\.nodes: at least one node must be specified.*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.captureHeaders\[1\]: invalid header name "X Powered By".*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*frontend\.sloFetchSuccessTarget: must be between 0 and 1 exclusive.*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.maxBodySize: must be greater than zero.*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.errorStoreSize: must not be negative.*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*frontend\.feedSize: must be greater than zero.*`)
+}
+
+func (s *ConfigTestSuite) TestValidateUnknownBackend(c *gc.C) {
+	cfg := Default()
+	cfg.LinkGraph.Backend = "redis"
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*linkGraph\.backend: unknown backend "redis".*`)
+}
+
+func (s *ConfigTestSuite) TestValidateESLinkGraph(c *gc.C) {
+	cfg := Default()
+	cfg.LinkGraph.Backend = LinkGraphES
+	cfg.LinkGraph.ESIndexPrefix = ""
+	err := cfg.Validate()
+	c.Assert(err, gc.NotNil)
+	c.Assert(err.Error(), gc.Matches, `(?s).*linkGraph\.esIndexPrefix: must not be empty.*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*textIndexer\.es\.nodes: at least one node must be specified.*`)
+
+	// The link graph shares the cluster settings of the text indexer.
+	cfg.LinkGraph.ESIndexPrefix = "linkgraph"
+	cfg.TextIndexer.ES.Nodes = []string{"http://localhost:9200"}
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestValidateSQLiteLinkGraph(c *gc.C) {
+	cfg := Default()
+	cfg.LinkGraph.Backend = LinkGraphSQLite
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*linkGraph\.sqlitePath: must be set when the "sqlite" backend is selected.*`)
+
+	cfg.LinkGraph.SQLitePath = "/var/lib/webcrawler/linkgraph.db"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestValidateBleveTextIndexer(c *gc.C) {
+	cfg := Default()
+	cfg.TextIndexer.Backend = TextIndexerBleve
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*textIndexer\.blevePath: must be set when the "bleve" backend is selected.*`)
+
+	cfg.TextIndexer.BlevePath = "/var/lib/webcrawler/index.bleve"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestValidateMeiliTextIndexer(c *gc.C) {
+	cfg := Default()
+	cfg.TextIndexer.Backend = TextIndexerMeili
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*textIndexer\.meili\.url: "" is not a valid URL.*`)
+
+	cfg.TextIndexer.Meili.URL = "http://localhost:7700"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestTextIndexerFieldBoosts(c *gc.C) {
+	cfg := Default()
+	boosts, err := cfg.TextIndexer.Boosts()
+	c.Assert(err, gc.IsNil)
+	c.Assert(boosts, gc.IsNil)
+
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "TEXTINDEXER_FIELD_BOOSTS": "title^4,content",
+	})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+	boosts, err = cfg.TextIndexer.Boosts()
+	c.Assert(err, gc.IsNil)
+	c.Assert(boosts, gc.DeepEquals, index.FieldBoosts{index.FieldTitle: 4, index.FieldContent: 1})
+
+	cfg.TextIndexer.FieldBoosts = []string{"Author^2"}
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*textIndexer\.fieldBoosts: field boost "Author\^2": unknown field.*`)
+	cfg.TextIndexer.FieldBoosts = []string{"Title^0"}
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*textIndexer\.fieldBoosts: field boosts: boost of field "Title" must be positive.*`)
+}
+
+func (s *ConfigTestSuite) TestTextIndexerPaging(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "TEXTINDEXER_BATCH_SIZE": "50",
+		EnvPrefix + "TEXTINDEXER_MAX_OFFSET": "500",
+	})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+	c.Assert(cfg.TextIndexer.BatchSize, gc.Equals, 50)
+	c.Assert(cfg.TextIndexer.MaxOffset, gc.Equals, 500)
+
+	cfg.TextIndexer.BatchSize = 0
+	cfg.TextIndexer.MaxOffset = -1
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*textIndexer\.batchSize: batch size must be between 1 and 1000 \(got 0\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*textIndexer\.maxOffset: must be greater than zero \(got -1\).*`)
+}
+
+func (s *ConfigTestSuite) TestMemoryStateFiles(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "LINKGRAPH_STATE_FILE":            "/var/lib/webcrawler/linkgraph.gob",
+		EnvPrefix + "LINKGRAPH_STATE_SAVE_INTERVAL":   "1m",
+		EnvPrefix + "TEXTINDEXER_STATE_FILE":          "/var/lib/webcrawler/textindexer.gob",
+		EnvPrefix + "TEXTINDEXER_STATE_SAVE_INTERVAL": "0s",
+	})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+	c.Assert(cfg.LinkGraph.StateFile, gc.Equals, "/var/lib/webcrawler/linkgraph.gob")
+	c.Assert(cfg.LinkGraph.StateSaveInterval, gc.Equals, Duration(time.Minute))
+	c.Assert(cfg.TextIndexer.StateFile, gc.Equals, "/var/lib/webcrawler/textindexer.gob")
+	c.Assert(cfg.TextIndexer.StateSaveInterval, gc.Equals, Duration(0))
+
+	cfg.LinkGraph.Backend = LinkGraphBolt
+	cfg.LinkGraph.BoltPath = "/var/lib/webcrawler/linkgraph.bolt"
+	cfg.TextIndexer.StateSaveInterval = -1
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*linkGraph\.stateFile: is only supported by the "memory" backend.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*textIndexer\.stateSaveInterval: must not be negative.*`)
+}
+
+func (s *ConfigTestSuite) TestValidateHealth(c *gc.C) {
+	cfg := Default()
+	cfg.Health.ListenAddress = ""
+	c.Assert(cfg.Validate(), gc.IsNil, gc.Commentf("an empty address disables the probes"))
+
+	cfg.Health.ListenAddress = "8081"
+	cfg.Health.PingTimeout = 0
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*health\.listenAddress: "8081" is not a valid host:port address.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*health\.pingTimeout: must be positive.*`)
+}
+
+func (s *ConfigTestSuite) TestValidateGRPC(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.GRPC.ListenAddress, gc.Equals, "", gc.Commentf("the gRPC APIs should be disabled by default"))
+
+	cfg.GRPC.ListenAddress = "9090"
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*grpc\.listenAddress: "9090" is not a valid host:port address.*`)
+	cfg.GRPC.ListenAddress = ":9090"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestValidateBoltLinkGraph(c *gc.C) {
+	cfg := Default()
+	cfg.LinkGraph.Backend = LinkGraphBolt
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*linkGraph\.boltPath: must be set when the "bolt" backend is selected.*`)
+
+	cfg.LinkGraph.BoltPath = "/var/lib/webcrawler/linkgraph.bolt"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestValidateGRPCLinkGraph(c *gc.C) {
+	cfg := Default()
+	cfg.LinkGraph.Backend = LinkGraphGRPC
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*linkGraph\.grpcAddress: must be set when the "grpc" backend is selected.*`)
+
+	cfg.LinkGraph.GRPCAddress = "graph.internal"
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*linkGraph\.grpcAddress: "graph.internal" is not a valid host:port address.*`)
+
+	cfg.LinkGraph.GRPCAddress = "graph.internal:9090"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestValidateGRPCTextIndexer(c *gc.C) {
+	cfg := Default()
+	cfg.TextIndexer.Backend = TextIndexerGRPC
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*textIndexer\.grpcAddress: must be set when the "grpc" backend is selected.*`)
+
+	cfg.TextIndexer.GRPCAddress = "index.internal"
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*textIndexer\.grpcAddress: "index.internal" is not a valid host:port address.*`)
+
+	cfg.TextIndexer.GRPCAddress = "index.internal:9090"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestPrintEffectiveMasksSecrets(c *gc.C) {
+	cfg := Default()
+	cfg.TextIndexer.ES.Username = "elastic"
+	cfg.TextIndexer.ES.Password = "hunter2"
+
+	var buf bytes.Buffer
+	c.Assert(cfg.PrintEffective(&buf), gc.IsNil)
+	c.Assert(buf.String(), gc.Not(gc.Matches), `(?s).*hunter2.*`)
+	c.Assert(buf.String(), gc.Matches, `(?s).*"password": "\*\*\*\*\*\*\*\*".*`)
+	c.Assert(buf.String(), gc.Matches, `(?s).*"updateInterval": "5m0s".*`)
+
+	// The original configuration must not be modified.
+	c.Assert(cfg.TextIndexer.ES.Password, gc.Equals, "hunter2")
+}
+
+func (s *ConfigTestSuite) TestRunCommand(c *gc.C) {
+	var stdout, stderr bytes.Buffer
+	c.Assert(RunCommand([]string{"validate"}, &stdout, &stderr), gc.Equals, 0)
+	c.Assert(stdout.String(), gc.Equals, "configuration is valid\n")
+
+	stdout.Reset()
+	c.Assert(RunCommand([]string{"print-effective"}, &stdout, &stderr), gc.Equals, 0)
+	c.Assert(stdout.String(), gc.Matches, `(?s).*"fetchWorkers": 16.*`)
+
+	c.Assert(RunCommand([]string{"bogus"}, &stdout, &stderr), gc.Equals, 2)
+}
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func (s *ConfigTestSuite) TestPartitionValidation(c *gc.C) {
+	cfg := Default()
+	cfg.Partition.Detector = PartitionDetectorDNS
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*partition\.srvName: must be set when the "dns" detector is selected.*`)
+
+	cfg.Partition.SRVName = "crawler.default.svc.cluster.local"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestNamespaceValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.Namespace, gc.Equals, namespace.Default)
+
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "NAMESPACE": "staging-crawl",
+	})), gc.IsNil)
+	c.Assert(cfg.Namespace, gc.Equals, "staging-crawl")
+	c.Assert(cfg.Validate(), gc.IsNil)
+
+	cfg.Namespace = "Staging Crawl"
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*namespace: invalid namespace: name "Staging Crawl" contains unsupported character.*`)
+}
+
+func (s *ConfigTestSuite) TestLoggingValidation(c *gc.C) {
+	cfg := Default()
+	cfg.Logging.Level = "verbose"
+	cfg.Logging.Format = "xml"
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*logging\.level: unknown level "verbose".*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*logging\.format: unknown format "xml".*`)
+
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "LOG_LEVEL":  "debug",
+		EnvPrefix + "LOG_FORMAT": "text",
+	})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestRobotsValidation(c *gc.C) {
+	cfg := Default()
+	cfg.Crawler.Robots.Store = RobotsStoreDB
+	cfg.Crawler.Robots.UserAgent = ""
+	cfg.Crawler.Robots.NegativeTTL = Duration(-time.Minute)
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.robots\.store: the "db" store requires the "db" link graph backend.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.robots\.userAgent: must not be empty.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.robots\.negativeTTL: must not be negative.*`)
+
+	cfg.Crawler.Robots.UserAgent = "webcrawler"
+	cfg.Crawler.Robots.NegativeTTL = Duration(time.Minute)
+	cfg.LinkGraph.Backend = LinkGraphDB
+	cfg.LinkGraph.DSN = "postgresql://user@localhost:26257/linkgraph"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestURLRewriteValidation(c *gc.C) {
+	cfg := Default()
+	cfg.Crawler.URLRewrites = []string{"https://example.com/", "https://docs.example.com/=mirror/docs"}
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.urlRewrites\[0\]: "https://example.com/" must have the form canonicalPrefix=mirrorPrefix.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.urlRewrites\[1\]: "mirror/docs" is not a valid http\(s\) URL prefix.*`)
+
+	cfg.Crawler.URLRewrites = []string{"https://docs.example.com/=http://mirror.internal/docs/"}
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestURLNormalizationSettings(c *gc.C) {
+	cfg := Default()
+	env := map[string]string{
+		"WEBCRAWLER_CRAWLER_STRIP_QUERY_PARAMS":  "utm_*,sessionid",
+		"WEBCRAWLER_CRAWLER_TRIM_TRAILING_SLASH": "false",
+	}
+	c.Assert(cfg.ApplyEnv(lookupFrom(env)), gc.IsNil)
+	c.Assert(cfg.Crawler.URLNormalization, gc.DeepEquals, URLNormalizationConfig{
+		StripQueryParams: []string{"utm_*", "sessionid"},
+		SortQueryParams:  true,
+	})
+	c.Assert(cfg.Validate(), gc.IsNil)
+
+	cfg.Crawler.URLNormalization.StripQueryParams = []string{"utm_*", "*"}
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*crawler\.urlNormalization\.stripQueryParams\[1\]: must specify a parameter name or prefix.*`)
+}
+
+func (s *ConfigTestSuite) TestExtractionProfiles(c *gc.C) {
+	cfg := Default()
+	err := cfg.Decode(strings.NewReader(`{
+		"crawler": {"extractionProfiles": [
+			{"domain": "example.com", "title": "h1.headline", "body": "article .content", "date": "time[datetime]"},
+			{"domain": "", "body": "div["},
+			{"domain": "example.org"}
+		]}
+	}`))
+	c.Assert(err, gc.IsNil)
+
+	profile, err := cfg.Crawler.ExtractionProfiles[0].Compile()
+	c.Assert(err, gc.IsNil)
+	c.Assert(profile.Domain, gc.Equals, "example.com")
+	c.Assert(profile.Title.String(), gc.Equals, "h1.headline")
+	c.Assert(profile.Body.String(), gc.Equals, "article .content")
+	c.Assert(profile.Author, gc.IsNil)
+	c.Assert(profile.Date.String(), gc.Equals, "time[datetime]")
+
+	err = cfg.Validate()
+	c.Assert(err, gc.NotNil)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.extractionProfiles\[1\]\.domain: invalid domain "".*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.extractionProfiles\[1\]: invalid CSS selector "div\[".*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.extractionProfiles\[2\]: must specify at least one selector or a region.*`)
+	c.Assert(err.Error(), gc.Not(gc.Matches), `(?s).*extractionProfiles\[0\].*`)
+}
+
+func (s *ConfigTestSuite) TestRegion(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.Crawler.Region.Enabled(), gc.Equals, false)
+	err := cfg.Decode(strings.NewReader(`{
+		"crawler": {
+			"extractionProfiles": [
+				{"domain": "example.de", "region": "eu"},
+				{"domain": "example.com", "body": "article"}
+			],
+			"region": {"self": "eu", "default": "us", "spillover": ["eu=uk", "eu=us"]}
+		}
+	}`))
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_REGION_ACTIVE": "us,uk",
+	})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+
+	routerCfg, err := cfg.Crawler.Region.Compile(cfg.Crawler.ExtractionProfiles)
+	c.Assert(err, gc.IsNil)
+	c.Assert(routerCfg, gc.DeepEquals, region.Config{
+		Self:      "eu",
+		Default:   "us",
+		Domains:   []region.DomainTag{{Domain: "example.de", Region: "eu"}},
+		Active:    []string{"us", "uk"},
+		Spillover: map[string][]string{"eu": {"uk", "us"}},
+	})
+
+	cfg.Crawler.Region.Default = ""
+	cfg.Crawler.Region.Spillover = []string{"eu=eu"}
+	err = cfg.Validate()
+	c.Assert(err, gc.NotNil)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.region: invalid default region "".*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*crawler\.region: invalid spillover target "eu" for region "eu".*`)
+
+	cfg.Crawler.Region.Spillover = []string{"eu"}
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*crawler\.region: invalid spillover rule "eu"; expected region=target.*`)
+}
+
+func (s *ConfigTestSuite) TestDedupValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.Crawler.Dedup, gc.DeepEquals, DedupConfig{Enabled: true, MaxDistance: 3})
+
+	cfg.Crawler.Dedup.MaxDistance = 4
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*crawler\.dedup\.maxDistance: must be between 0 and 3 \(got 4\).*`)
+}
+
+func (s *ConfigTestSuite) TestJobsValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.Crawler.Jobs.Enabled(), gc.Equals, false)
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_JOBS_DIR":        "/var/lib/webcrawler/jobs",
+		EnvPrefix + "CRAWLER_JOBS_BATCH_SIZE": "0",
+	})), gc.IsNil)
+	c.Assert(cfg.Crawler.Jobs.Enabled(), gc.Equals, true)
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*crawler\.jobs\.batchSize: must be greater than zero \(got 0\).*`)
+}
+
+func (s *ConfigTestSuite) TestQueueValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.Crawler.Queue.Enabled(), gc.Equals, false)
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_QUEUE_BACKEND":      "kafka",
+		EnvPrefix + "CRAWLER_QUEUE_MAX_ATTEMPTS": "0",
+	})), gc.IsNil)
+	c.Assert(cfg.Crawler.Queue.Enabled(), gc.Equals, true)
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.queue\.brokers: must not be empty for the "kafka" backend.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.queue\.maxAttempts: must be greater than zero \(got 0\).*`)
+
+	cfg.Crawler.Queue.Backend = "nats"
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*crawler\.queue\.backend: unknown backend "nats"; expected one of "memory" or "kafka".*`)
+
+	cfg = Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_QUEUE_BACKEND": "kafka",
+		EnvPrefix + "CRAWLER_QUEUE_BROKERS": "kafka-1:9092,kafka-2:9092",
+	})), gc.IsNil)
+	c.Assert(cfg.Crawler.Queue.Brokers, gc.DeepEquals, []string{"kafka-1:9092", "kafka-2:9092"})
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestRetryValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_RETRY_BASE_DELAY":   "1h",
+		EnvPrefix + "CRAWLER_RETRY_MAX_DELAY":    "30m",
+		EnvPrefix + "CRAWLER_RETRY_MAX_ATTEMPTS": "0",
+	})), gc.IsNil)
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.retry\.maxDelay: must not be lower than the base delay \(got 30m0s\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.retry\.maxAttempts: must be greater than zero \(got 0\).*`)
+
+	// Disabled retries are not validated.
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{EnvPrefix + "CRAWLER_RETRY_ENABLED": "false"})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestArchiveValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.Crawler.Archive.Enabled(), gc.Equals, false)
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_ARCHIVE_DIR":           "/var/lib/webcrawler/warc",
+		EnvPrefix + "CRAWLER_ARCHIVE_MAX_FILE_SIZE": "-1",
+	})), gc.IsNil)
+	c.Assert(cfg.Crawler.Archive.Enabled(), gc.Equals, true)
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*crawler\.archive\.maxFileSize: must be greater than zero \(got -1\).*`)
+}
+
+func (s *ConfigTestSuite) TestFetchValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_FETCH_MAX_ATTEMPTS":     "0",
+		EnvPrefix + "CRAWLER_FETCH_RETRY_BASE_DELAY": "1m",
+		EnvPrefix + "CRAWLER_FETCH_USER_AGENTS":      "webcrawler/1.0,Mozilla/5.0 (compatible; webcrawler)",
+	})), gc.IsNil)
+	c.Assert(cfg.Crawler.Fetch.UserAgents, gc.DeepEquals, []string{"webcrawler/1.0", "Mozilla/5.0 (compatible; webcrawler)"})
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.maxAttempts: must be greater than zero \(got 0\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.retryMaxDelay: must not be lower than the retry base delay \(got 30s\).*`)
+}
+
+func (s *ConfigTestSuite) TestProxyValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_FETCH_PROXY_URLS":             "http://proxy-1:3128,ftp://proxy-2",
+		EnvPrefix + "CRAWLER_FETCH_PROXY_HEALTH_CHECK_URL": "/healthz",
+		EnvPrefix + "CRAWLER_FETCH_PROXY_COOLDOWN":         "0s",
+	})), gc.IsNil)
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.proxy\.urls: invalid proxy URL "ftp://proxy-2": unsupported scheme "ftp".*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.proxy\.cooldown: must be a positive duration \(got 0s\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.proxy\.healthCheckURL: must be an absolute URL \(got "/healthz"\).*`)
+
+	// The settings are ignored if no proxies are configured.
+	cfg.Crawler.Fetch.Proxy.URLs = nil
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestRenderValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_FETCH_RENDER_SERVICE_URL":          "browserless:3000",
+		EnvPrefix + "CRAWLER_FETCH_RENDER_BLOCK_RESOURCE_TYPES": "image,videos",
+		EnvPrefix + "CRAWLER_FETCH_RENDER_BLOCK_URL_PATTERNS":   "(ads",
+	})), gc.IsNil)
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.render\.serviceURL: must be an absolute http\(s\) URL \(got "browserless:3000"\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.render\.domains: must not be empty if a service URL is specified.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.render\.blockResourceTypes\[1\]: must be one of .* \(got "videos"\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.render\.blockURLPatterns\[0\]: error parsing regexp.*`)
+
+	// The settings are ignored if pages are not rendered.
+	cfg.Crawler.Fetch.Render.ServiceURL = ""
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestFetchCacheValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_FETCH_CACHE_BACKEND":        "redis",
+		EnvPrefix + "CRAWLER_FETCH_CACHE_REDIS_URL":      "localhost:6379",
+		EnvPrefix + "CRAWLER_FETCH_CACHE_MIN_TTL":        "-1h",
+		EnvPrefix + "CRAWLER_FETCH_CACHE_MAX_ENTRY_SIZE": "0",
+	})), gc.IsNil)
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.cache\.redisURL: .*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.cache\.minTTL: must not be negative \(got -1h0m0s\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.fetch\.cache\.maxEntrySize: must be greater than zero \(got 0\).*`)
+
+	cfg.Crawler.Fetch.Cache.Backend = "memcached"
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*crawler\.fetch\.cache\.backend: unknown backend "memcached".*`)
+
+	cfg.Crawler.Fetch.Cache = Default().Crawler.Fetch.Cache
+	cfg.Crawler.Fetch.Cache.Backend = FetchCacheRedis
+	cfg.Crawler.Fetch.Cache.RedisURL = "redis://localhost:6379/1"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestPacingValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_PACING_DEFAULT_DELAY": "2m",
+		EnvPrefix + "CRAWLER_PACING_MAX_WAIT":      "-1s",
+	})), gc.IsNil)
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.pacing\.defaultDelay: must be between zero and the max delay \(got 2m0s\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.pacing\.maxWait: must not be negative \(got -1s\).*`)
+
+	// The settings are ignored if pacing is disabled.
+	cfg.Crawler.Pacing.Enabled = false
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestRecrawlValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_RECRAWL_MIN_INTERVAL":   "2h",
+		EnvPrefix + "CRAWLER_RECRAWL_MAX_INTERVAL":   "1h",
+		EnvPrefix + "CRAWLER_RECRAWL_DEPTH_PENALTY":  "-0.5",
+		EnvPrefix + "CRAWLER_RECRAWL_PAGERANK_BOOST": "2",
+	})), gc.IsNil)
+	c.Assert(cfg.Crawler.Recrawl.PageRankBoost, gc.Equals, 2.0)
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.recrawl\.maxInterval: must not be lower than the min interval \(got 1h0m0s\).*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.recrawl\.depthPenalty: must not be negative \(got -0.5\).*`)
+
+	// The settings are ignored if adaptive recrawling is disabled.
+	cfg.Crawler.Recrawl.Adaptive = false
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestRecrawlTiersValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_RECRAWL_TIERS_ENABLED":          "true",
+		EnvPrefix + "CRAWLER_RECRAWL_TIERS_NEWS_PATTERNS":    `^https://news\.,(`,
+		EnvPrefix + "CRAWLER_RECRAWL_TIERS_ARCHIVE_INTERVAL": "0s",
+	})), gc.IsNil)
+	c.Assert(cfg.Crawler.Recrawl.Tiers.NewsPatterns, gc.DeepEquals, []string{`^https://news\.`, "("})
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.recrawl\.tiers\.enabled: cannot be combined with adaptive recrawling.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.recrawl\.tiers\.newsPatterns\[1\]: error parsing regexp.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.recrawl\.tiers\.archiveInterval: must be greater than zero \(got 0s\).*`)
+
+	cfg.Crawler.Recrawl.Adaptive = false
+	cfg.Crawler.Recrawl.Tiers.NewsPatterns = []string{`^https://news\.`}
+	cfg.Crawler.Recrawl.Tiers.ArchiveInterval = Duration(24 * time.Hour)
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestScopeValidation(c *gc.C) {
+	cfg := Default()
+	c.Assert(cfg.Crawler.Scope.IsZero(), gc.Equals, true)
+
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "CRAWLER_SCOPE_INCLUDE_HOSTS":        "example.com,*.example.org",
+		EnvPrefix + "CRAWLER_SCOPE_EXCLUDE_URL_PATTERNS": `/login,\.pdf$`,
+		EnvPrefix + "CRAWLER_SCOPE_MAX_PATH_DEPTH":       "4",
+		EnvPrefix + "CRAWLER_SCOPE_SAME_DOMAIN_ONLY":     "true",
+	})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+	c.Assert(cfg.Crawler.Scope.IsZero(), gc.Equals, false)
+
+	scopeCfg, err := cfg.Crawler.Scope.Compile()
+	c.Assert(err, gc.IsNil)
+	c.Assert(scopeCfg.IncludeHosts, gc.DeepEquals, []string{"example.com", "*.example.org"})
+	c.Assert(scopeCfg.ExcludeURLs, gc.HasLen, 2)
+	c.Assert(scopeCfg.ExcludeURLs[1].String(), gc.Equals, `\.pdf$`)
+	c.Assert(scopeCfg.MaxPathDepth, gc.Equals, 4)
+	c.Assert(scopeCfg.SameDomainOnly, gc.Equals, true)
+
+	cfg.Crawler.Scope.ExcludeHosts = []string{"example.com/admin"}
+	cfg.Crawler.Scope.IncludeURLPatterns = []string{"(unclosed"}
+	cfg.Crawler.Scope.MaxPagesPerDomain = -1
+	err = cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.scope\.excludeHosts\[0\]: invalid host pattern "example.com/admin".*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.scope\.includeURLPatterns\[0\]: error parsing regexp.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*crawler\.scope\.maxPagesPerDomain: must not be negative \(got -1\).*`)
+}
+
+func (s *ConfigTestSuite) TestBackupValidation(c *gc.C) {
+	cfg := Default()
+	cfg.TextIndexer.Backup.Enabled = true
+	cfg.TextIndexer.Backup.KeepLast = 0
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*textIndexer\.backup\.keepLast: must be greater than zero.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*textIndexer\.backup\.dir: must be set when backing up the "memory" backend.*`)
+
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "TEXTINDEXER_BACKUP_KEEP_LAST": "3",
+		EnvPrefix + "TEXTINDEXER_BACKUP_DIR":       "/var/lib/webcrawler/backups",
+	})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+
+	cfg.TextIndexer.Backend = TextIndexerES
+	cfg.TextIndexer.ES.Nodes = []string{"http://localhost:9200"}
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*textIndexer\.backup\.esRepository: must be set when backing up the "es" backend.*`)
+}
+
+func (s *ConfigTestSuite) TestPageRankHistoryValidation(c *gc.C) {
+	cfg := Default()
+	cfg.PageRank.HistoryStore = PageRankHistoryDB
+	cfg.PageRank.HistoryPasses = -1
+	err := cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*pageRank\.historyStore: the "db" store requires the "db" link graph backend.*`)
+	c.Assert(err, gc.ErrorMatches, `(?s).*pageRank\.historyPasses: must not be negative.*`)
+
+	cfg.PageRank.HistoryPasses = 0
+	cfg.LinkGraph.Backend = LinkGraphDB
+	cfg.LinkGraph.DSN = "postgresql://user@localhost:26257/linkgraph"
+	c.Assert(cfg.Validate(), gc.IsNil)
+}
+
+func (s *ConfigTestSuite) TestFrontendAccessPolicy(c *gc.C) {
+	cfg := Default()
+	policy, err := cfg.Frontend.AccessPolicy()
+	c.Assert(err, gc.IsNil)
+	c.Assert(policy, gc.IsNil)
+
+	c.Assert(cfg.ApplyEnv(lookupFrom(map[string]string{
+		EnvPrefix + "FRONTEND_PUBLIC_FIELDS": "url,title",
+		EnvPrefix + "FRONTEND_API_KEYS":      "k3y=content|pageRank,basic=",
+	})), gc.IsNil)
+	c.Assert(cfg.Validate(), gc.IsNil)
+	policy, err = cfg.Frontend.AccessPolicy()
+	c.Assert(err, gc.IsNil)
+	fields, err := policy.FieldsFor("k3y")
+	c.Assert(err, gc.IsNil)
+	c.Assert(fields.List(), gc.DeepEquals, []string{"content", "pageRank", "title", "url"})
+	fields, err = policy.FieldsFor("basic")
+	c.Assert(err, gc.IsNil)
+	c.Assert(fields.List(), gc.DeepEquals, []string{"title", "url"})
+
+	// API keys are masked when printing the configuration.
+	var buf bytes.Buffer
+	c.Assert(cfg.PrintEffective(&buf), gc.IsNil)
+	c.Assert(buf.String(), gc.Not(gc.Matches), `(?s).*k3y.*`)
+	c.Assert(cfg.Frontend.APIKeys[0], gc.Equals, "k3y=content|pageRank")
+
+	cfg.Frontend.APIKeys = []string{"k3y", "s3cr3t=bogus"}
+	err = cfg.Validate()
+	c.Assert(err, gc.ErrorMatches, `(?s).*frontend\.apiKeys: invalid API key entry #1; expected key=field1\|field2.*`)
+
+	cfg.Frontend.APIKeys = []string{"s3cr3t=bogus", "k3y=content", "k3y=pageRank"}
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*frontend\.apiKeys: duplicate API key entry #3.*`)
+
+	cfg.Frontend.APIKeys = []string{"s3cr3t=bogus"}
+	c.Assert(cfg.Validate(), gc.ErrorMatches, `(?s).*frontend\.apiKeys: unknown field "bogus" for API key s3cr\*\*\*\*.*`)
+}
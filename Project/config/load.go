@@ -0,0 +1,257 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported configuration file formats.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FormatFor returns the format of the configuration file at path based on
+// its extension. Files with the ".yaml" or ".yml" extension are YAML files,
+// files with the ".toml" extension are TOML files and all other files are
+// JSON files.
+func FormatFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// Load returns the effective configuration obtained by overlaying the
+// configuration file at path (if path is not empty) and the environment
+// variables on top of the defaults. The format of the file is determined by
+// FormatFor. Any overrides (e.g. from command-line flags) are applied last,
+// in order. The returned configuration is validated before it is returned.
+func Load(path string, overrides ...func(*Config)) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("load config: %w", err)
+		}
+		err = cfg.DecodeFormat(f, FormatFor(path))
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("load config %q: %w", path, err)
+		}
+	}
+
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	for _, override := range overrides {
+		override(cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Decode overlays the JSON configuration read from r on top of cfg. Unknown
+// settings are rejected so that typos do not go unnoticed.
+func (cfg *Config) Decode(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeFormat overlays the configuration read from r in the specified format
+// on top of cfg. The settings have the same names in all formats and unknown
+// settings are rejected regardless of the format.
+func (cfg *Config) DecodeFormat(r io.Reader, format string) error {
+	var (
+		tree interface{}
+		err  error
+	)
+	switch format {
+	case FormatJSON:
+		return cfg.Decode(r)
+	case FormatYAML:
+		err = yaml.NewDecoder(r).Decode(&tree)
+		if err == io.EOF {
+			// An empty document leaves the configuration unchanged.
+			return nil
+		}
+	case FormatTOML:
+		err = toml.NewDecoder(r).Decode(&tree)
+	default:
+		return fmt.Errorf("unsupported configuration format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Re-encode the document as JSON so that the settings are named,
+	// converted and checked the same way as those of JSON files.
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return cfg.Decode(bytes.NewReader(data))
+}
+
+// ApplyEnv overlays the values of any environment variables returned by
+// lookup on top of cfg. The variable name for each setting is EnvPrefix
+// followed by the value of the `env` tag of the corresponding field.
+func (cfg *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	return walkFields(reflect.ValueOf(cfg).Elem(), "", func(f field) error {
+		if f.env == "" {
+			return nil
+		}
+
+		envVar := EnvPrefix + f.env
+		raw, set := lookup(envVar)
+		if !set {
+			return nil
+		}
+		if err := setFromString(f.value, raw); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", raw, envVar, err)
+		}
+		return nil
+	})
+}
+
+// PrintEffective writes the JSON representation of cfg to w. The values of
+// secret settings are masked.
+func (cfg *Config) PrintEffective(w io.Writer) error {
+	redacted := *cfg
+	_ = walkFields(reflect.ValueOf(&redacted).Elem(), "", func(f field) error {
+		if !f.secret {
+			return nil
+		}
+		switch {
+		case f.value.Kind() == reflect.String && f.value.String() != "":
+			f.value.SetString("********")
+		case f.value.Kind() == reflect.Slice && f.value.Len() != 0:
+			// Replace the slice instead of masking its elements in
+			// place as it is shared with cfg.
+			masked := make([]string, f.value.Len())
+			for i := range masked {
+				masked[i] = "********"
+			}
+			f.value.Set(reflect.ValueOf(masked))
+		}
+		return nil
+	})
+
+	data, err := json.MarshalIndent(&redacted, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data)
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// field describes a leaf setting in the configuration tree.
+type field struct {
+	// The dotted JSON path to the field (e.g. "textIndexer.es.nodes").
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// walkFields invokes fn for each leaf field of the struct v.
+func walkFields(v reflect.Value, prefix string, fn func(field) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		path := jsonName(sf)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := walkFields(fv, path, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fn(field{path: path, env: sf.Tag.Get("env"), secret: sf.Tag.Get("secret") == "true", value: fv}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonName(sf reflect.StructField) string {
+	if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return sf.Name
+}
+
+// setFromString parses raw according to the type of v and assigns it to v.
+func setFromString(v reflect.Value, raw string) error {
+	if d, ok := v.Addr().Interface().(*Duration); ok {
+		return d.Set(raw)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", v.Type())
+		}
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,583 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"webcrawler/crawler/dedup"
+	"webcrawler/crawler/fetch"
+	"webcrawler/crawler/region"
+	"webcrawler/crawler/scope"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+	"webcrawler/namespace"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/redis/go-redis/v9"
+)
+
+// Validate checks the configuration for errors. The returned error lists all
+// detected problems, each one prefixed by the path to the offending setting.
+func (cfg *Config) Validate() error {
+	var err error
+	addErr := func(path, format string, args ...interface{}) {
+		err = multierror.Append(err, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if nsErr := namespace.Validate(cfg.Namespace); nsErr != nil {
+		addErr("namespace", "%v", nsErr)
+	}
+
+	// Crawler
+	if cfg.Crawler.FetchWorkers <= 0 {
+		addErr("crawler.fetchWorkers", "must be greater than zero (got %d)", cfg.Crawler.FetchWorkers)
+	}
+	if cfg.Crawler.UpdateInterval <= 0 {
+		addErr("crawler.updateInterval", "must be a positive duration (got %s)", cfg.Crawler.UpdateInterval)
+	}
+	if cfg.Crawler.ReIndexThreshold < 0 {
+		addErr("crawler.reIndexThreshold", "must not be negative (got %s)", cfg.Crawler.ReIndexThreshold)
+	}
+	if cfg.Crawler.MaxPassDuration < 0 {
+		addErr("crawler.maxPassDuration", "must not be negative (got %s)", cfg.Crawler.MaxPassDuration)
+	}
+	if cfg.Crawler.MaxPassBytes < 0 {
+		addErr("crawler.maxPassBytes", "must not be negative (got %d)", cfg.Crawler.MaxPassBytes)
+	}
+	if cfg.Crawler.MaxBodySize <= 0 {
+		addErr("crawler.maxBodySize", "must be greater than zero (got %d)", cfg.Crawler.MaxBodySize)
+	}
+	if cfg.Crawler.ErrorStoreSize < 0 {
+		addErr("crawler.errorStoreSize", "must not be negative (got %d)", cfg.Crawler.ErrorStoreSize)
+	}
+	if cfg.Crawler.ShutdownDrainTimeout < 0 {
+		addErr("crawler.shutdownDrainTimeout", "must not be negative (got %s)", cfg.Crawler.ShutdownDrainTimeout)
+	}
+	for i, name := range cfg.Crawler.CaptureHeaders {
+		if name == "" || strings.ContainsAny(name, " \t:") {
+			addErr(fmt.Sprintf("crawler.captureHeaders[%d]", i), "invalid header name %q", name)
+		}
+	}
+
+	for i, rule := range cfg.Crawler.URLRewrites {
+		path := fmt.Sprintf("crawler.urlRewrites[%d]", i)
+		canonical, mirror, found := strings.Cut(rule, "=")
+		if !found {
+			addErr(path, "%q must have the form canonicalPrefix=mirrorPrefix", rule)
+			continue
+		}
+		for _, prefix := range []string{canonical, mirror} {
+			if u, pErr := url.Parse(prefix); pErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				addErr(path, "%q is not a valid http(s) URL prefix", prefix)
+			}
+		}
+	}
+
+	for i, param := range cfg.Crawler.URLNormalization.StripQueryParams {
+		if strings.TrimSuffix(param, "*") == "" {
+			addErr(fmt.Sprintf("crawler.urlNormalization.stripQueryParams[%d]", i), "must specify a parameter name or prefix")
+		}
+	}
+
+	for i, profile := range cfg.Crawler.ExtractionProfiles {
+		path := fmt.Sprintf("crawler.extractionProfiles[%d]", i)
+		if strings.TrimSpace(profile.Domain) == "" || strings.ContainsAny(profile.Domain, "/: ") {
+			addErr(path+".domain", "invalid domain %q", profile.Domain)
+		}
+		if !profile.HasSelectors() && profile.Region == "" {
+			addErr(path, "must specify at least one selector or a region")
+		} else if _, cErr := profile.Compile(); cErr != nil {
+			addErr(path, "%v", cErr)
+		}
+	}
+
+	if regionCfg := cfg.Crawler.Region; regionCfg.Enabled() {
+		routerCfg, rErr := regionCfg.Compile(cfg.Crawler.ExtractionProfiles)
+		if rErr == nil {
+			_, rErr = region.New(routerCfg)
+		}
+		// Report each problem detected by the router separately.
+		var mErr *multierror.Error
+		if errors.As(rErr, &mErr) {
+			for _, e := range mErr.Errors {
+				addErr("crawler.region", "%v", e)
+			}
+		} else if rErr != nil {
+			addErr("crawler.region", "%v", rErr)
+		}
+	}
+
+	if d := cfg.Crawler.Dedup.MaxDistance; d < 0 || d > dedup.MaxDistance {
+		addErr("crawler.dedup.maxDistance", "must be between 0 and %d (got %d)", dedup.MaxDistance, d)
+	}
+
+	if cfg.Crawler.Jobs.BatchSize <= 0 {
+		addErr("crawler.jobs.batchSize", "must be greater than zero (got %d)", cfg.Crawler.Jobs.BatchSize)
+	}
+	if cfg.Crawler.Archive.MaxFileSize <= 0 {
+		addErr("crawler.archive.maxFileSize", "must be greater than zero (got %d)", cfg.Crawler.Archive.MaxFileSize)
+	}
+
+	if queueCfg := cfg.Crawler.Queue; queueCfg.Enabled() {
+		switch queueCfg.Backend {
+		case QueueBackendMemory:
+		case QueueBackendKafka:
+			if len(queueCfg.Brokers) == 0 {
+				addErr("crawler.queue.brokers", "must not be empty for the %q backend", QueueBackendKafka)
+			}
+		default:
+			addErr("crawler.queue.backend", "unknown backend %q; expected one of %q or %q", queueCfg.Backend, QueueBackendMemory, QueueBackendKafka)
+		}
+		if queueCfg.Group == "" {
+			addErr("crawler.queue.group", "must not be empty")
+		}
+		if queueCfg.MaxAttempts <= 0 {
+			addErr("crawler.queue.maxAttempts", "must be greater than zero (got %d)", queueCfg.MaxAttempts)
+		}
+		if queueCfg.BatchSize <= 0 {
+			addErr("crawler.queue.batchSize", "must be greater than zero (got %d)", queueCfg.BatchSize)
+		}
+		if queueCfg.Lease < 0 {
+			addErr("crawler.queue.lease", "must not be negative (got %s)", queueCfg.Lease)
+		}
+	}
+
+	scopeCfg := cfg.Crawler.Scope
+	for _, field := range []struct {
+		name     string
+		patterns []string
+		check    func(string) error
+	}{
+		{"includeHosts", scopeCfg.IncludeHosts, scope.ValidateHostPattern},
+		{"excludeHosts", scopeCfg.ExcludeHosts, scope.ValidateHostPattern},
+		{"includeURLPatterns", scopeCfg.IncludeURLPatterns, compileRegexp},
+		{"excludeURLPatterns", scopeCfg.ExcludeURLPatterns, compileRegexp},
+	} {
+		for i, pattern := range field.patterns {
+			if pErr := field.check(pattern); pErr != nil {
+				addErr(fmt.Sprintf("crawler.scope.%s[%d]", field.name, i), "%v", pErr)
+			}
+		}
+	}
+	if scopeCfg.MaxPathDepth < 0 {
+		addErr("crawler.scope.maxPathDepth", "must not be negative (got %d)", scopeCfg.MaxPathDepth)
+	}
+	if scopeCfg.MaxPagesPerDomain < 0 {
+		addErr("crawler.scope.maxPagesPerDomain", "must not be negative (got %d)", scopeCfg.MaxPagesPerDomain)
+	}
+
+	robotsCfg := cfg.Crawler.Robots
+	switch robotsCfg.Store {
+	case RobotsStoreMemory:
+	case RobotsStoreDB:
+		if cfg.LinkGraph.Backend != LinkGraphDB {
+			addErr("crawler.robots.store", "the %q store requires the %q link graph backend", RobotsStoreDB, LinkGraphDB)
+		}
+	default:
+		addErr("crawler.robots.store", "unknown store %q; expected one of %q or %q", robotsCfg.Store, RobotsStoreMemory, RobotsStoreDB)
+	}
+	if robotsCfg.Enabled && robotsCfg.UserAgent == "" {
+		addErr("crawler.robots.userAgent", "must not be empty")
+	}
+	if robotsCfg.TTL < 0 {
+		addErr("crawler.robots.ttl", "must not be negative (got %s)", robotsCfg.TTL)
+	}
+	if robotsCfg.NegativeTTL < 0 {
+		addErr("crawler.robots.negativeTTL", "must not be negative (got %s)", robotsCfg.NegativeTTL)
+	}
+
+	fetchCfg := cfg.Crawler.Fetch
+	if fetchCfg.Timeout <= 0 {
+		addErr("crawler.fetch.timeout", "must be a positive duration (got %s)", fetchCfg.Timeout)
+	}
+	if fetchCfg.MaxAttempts <= 0 {
+		addErr("crawler.fetch.maxAttempts", "must be greater than zero (got %d)", fetchCfg.MaxAttempts)
+	}
+	if fetchCfg.RetryBaseDelay < 0 {
+		addErr("crawler.fetch.retryBaseDelay", "must not be negative (got %s)", fetchCfg.RetryBaseDelay)
+	}
+	if fetchCfg.RetryMaxDelay < fetchCfg.RetryBaseDelay {
+		addErr("crawler.fetch.retryMaxDelay", "must not be lower than the retry base delay (got %s)", fetchCfg.RetryMaxDelay)
+	}
+	if fetchCfg.MaxRedirects < 0 {
+		addErr("crawler.fetch.maxRedirects", "must not be negative (got %d)", fetchCfg.MaxRedirects)
+	}
+	for i, ua := range fetchCfg.UserAgents {
+		if strings.TrimSpace(ua) == "" {
+			addErr(fmt.Sprintf("crawler.fetch.userAgents[%d]", i), "must not be empty")
+		}
+	}
+
+	if proxyCfg := fetchCfg.Proxy; proxyCfg.Enabled() {
+		if _, pErr := fetch.ParseProxies(proxyCfg.URLs); pErr != nil {
+			addErr("crawler.fetch.proxy.urls", "%v", pErr)
+		}
+		if proxyCfg.MaxFailures <= 0 {
+			addErr("crawler.fetch.proxy.maxFailures", "must be greater than zero (got %d)", proxyCfg.MaxFailures)
+		}
+		if proxyCfg.Cooldown <= 0 {
+			addErr("crawler.fetch.proxy.cooldown", "must be a positive duration (got %s)", proxyCfg.Cooldown)
+		}
+		if proxyCfg.CheckInterval <= 0 {
+			addErr("crawler.fetch.proxy.checkInterval", "must be a positive duration (got %s)", proxyCfg.CheckInterval)
+		}
+		if proxyCfg.HealthCheckURL != "" {
+			if u, uErr := url.Parse(proxyCfg.HealthCheckURL); uErr != nil || !u.IsAbs() {
+				addErr("crawler.fetch.proxy.healthCheckURL", "must be an absolute URL (got %q)", proxyCfg.HealthCheckURL)
+			}
+		}
+	}
+	if renderCfg := fetchCfg.Render; renderCfg.Enabled() {
+		if _, rErr := fetch.NewRemoteRenderer(renderCfg.ServiceURL, nil); rErr != nil {
+			addErr("crawler.fetch.render.serviceURL", "must be an absolute http(s) URL (got %q)", renderCfg.ServiceURL)
+		}
+		if len(renderCfg.Domains) == 0 {
+			addErr("crawler.fetch.render.domains", "must not be empty if a service URL is specified")
+		}
+		for i, domain := range renderCfg.Domains {
+			if strings.TrimSpace(domain) == "" {
+				addErr(fmt.Sprintf("crawler.fetch.render.domains[%d]", i), "must not be empty")
+			}
+		}
+		if renderCfg.Timeout <= 0 {
+			addErr("crawler.fetch.render.timeout", "must be a positive duration (got %s)", renderCfg.Timeout)
+		}
+		for i, typ := range renderCfg.BlockResourceTypes {
+			if !fetch.IsResourceType(typ) {
+				addErr(fmt.Sprintf("crawler.fetch.render.blockResourceTypes[%d]", i), "must be one of %s (got %q)", strings.Join(fetch.ResourceTypes, ", "), typ)
+			}
+		}
+		for i, pattern := range renderCfg.BlockURLPatterns {
+			if reErr := compileRegexp(pattern); reErr != nil {
+				addErr(fmt.Sprintf("crawler.fetch.render.blockURLPatterns[%d]", i), "%v", reErr)
+			}
+		}
+	}
+	if cacheCfg := fetchCfg.Cache; cacheCfg.Enabled() {
+		switch cacheCfg.Backend {
+		case FetchCacheDisk:
+			if strings.TrimSpace(cacheCfg.Dir) == "" {
+				addErr("crawler.fetch.cache.dir", "must not be empty for the %q backend", FetchCacheDisk)
+			}
+		case FetchCacheRedis:
+			if _, rErr := redis.ParseURL(cacheCfg.RedisURL); rErr != nil {
+				addErr("crawler.fetch.cache.redisURL", "%v", rErr)
+			}
+		default:
+			addErr("crawler.fetch.cache.backend", "unknown backend %q; expected one of %q or %q", cacheCfg.Backend, FetchCacheDisk, FetchCacheRedis)
+		}
+		if cacheCfg.MinTTL < 0 {
+			addErr("crawler.fetch.cache.minTTL", "must not be negative (got %s)", cacheCfg.MinTTL)
+		}
+		if cacheCfg.Retention <= 0 {
+			addErr("crawler.fetch.cache.retention", "must be a positive duration (got %s)", cacheCfg.Retention)
+		}
+		if cacheCfg.MaxEntrySize <= 0 {
+			addErr("crawler.fetch.cache.maxEntrySize", "must be greater than zero (got %d)", cacheCfg.MaxEntrySize)
+		}
+	}
+
+	if pacingCfg := cfg.Crawler.Pacing; pacingCfg.Enabled {
+		if pacingCfg.MaxDelay <= 0 {
+			addErr("crawler.pacing.maxDelay", "must be greater than zero (got %s)", pacingCfg.MaxDelay)
+		}
+		if pacingCfg.DefaultDelay < 0 || pacingCfg.DefaultDelay > pacingCfg.MaxDelay {
+			addErr("crawler.pacing.defaultDelay", "must be between zero and the max delay (got %s)", pacingCfg.DefaultDelay)
+		}
+		if pacingCfg.InitialBackoff < 0 {
+			addErr("crawler.pacing.initialBackoff", "must not be negative (got %s)", pacingCfg.InitialBackoff)
+		}
+		if pacingCfg.MaxWait < 0 {
+			addErr("crawler.pacing.maxWait", "must not be negative (got %s)", pacingCfg.MaxWait)
+		}
+	}
+
+	if recrawlCfg := cfg.Crawler.Recrawl; recrawlCfg.Adaptive {
+		if recrawlCfg.MinInterval <= 0 {
+			addErr("crawler.recrawl.minInterval", "must be greater than zero (got %s)", recrawlCfg.MinInterval)
+		}
+		if recrawlCfg.MaxInterval < recrawlCfg.MinInterval {
+			addErr("crawler.recrawl.maxInterval", "must not be lower than the min interval (got %s)", recrawlCfg.MaxInterval)
+		}
+		if recrawlCfg.DepthPenalty < 0 {
+			addErr("crawler.recrawl.depthPenalty", "must not be negative (got %g)", recrawlCfg.DepthPenalty)
+		}
+		if recrawlCfg.PageRankBoost < 0 {
+			addErr("crawler.recrawl.pageRankBoost", "must not be negative (got %g)", recrawlCfg.PageRankBoost)
+		}
+	}
+	if tiersCfg := cfg.Crawler.Recrawl.Tiers; tiersCfg.Enabled {
+		if cfg.Crawler.Recrawl.Adaptive {
+			addErr("crawler.recrawl.tiers.enabled", "cannot be combined with adaptive recrawling")
+		}
+		for _, field := range []struct {
+			name     string
+			patterns []string
+		}{
+			{"newsPatterns", tiersCfg.NewsPatterns},
+			{"archivePatterns", tiersCfg.ArchivePatterns},
+		} {
+			for i, pattern := range field.patterns {
+				if pErr := compileRegexp(pattern); pErr != nil {
+					addErr(fmt.Sprintf("crawler.recrawl.tiers.%s[%d]", field.name, i), "%v", pErr)
+				}
+			}
+		}
+		for _, field := range []struct {
+			name     string
+			interval Duration
+		}{
+			{"newsInterval", tiersCfg.NewsInterval},
+			{"standardInterval", tiersCfg.StandardInterval},
+			{"archiveInterval", tiersCfg.ArchiveInterval},
+		} {
+			if field.interval <= 0 {
+				addErr("crawler.recrawl.tiers."+field.name, "must be greater than zero (got %s)", field.interval)
+			}
+		}
+	}
+
+	if retryCfg := cfg.Crawler.Retry; retryCfg.Enabled {
+		if retryCfg.BaseDelay <= 0 {
+			addErr("crawler.retry.baseDelay", "must be greater than zero (got %s)", retryCfg.BaseDelay)
+		}
+		if retryCfg.MaxDelay < retryCfg.BaseDelay {
+			addErr("crawler.retry.maxDelay", "must not be lower than the base delay (got %s)", retryCfg.MaxDelay)
+		}
+		if retryCfg.MaxAttempts <= 0 {
+			addErr("crawler.retry.maxAttempts", "must be greater than zero (got %d)", retryCfg.MaxAttempts)
+		}
+	}
+
+	// Link graph
+	switch cfg.LinkGraph.Backend {
+	case LinkGraphMemory:
+	case LinkGraphDB:
+		if cfg.LinkGraph.DSN == "" {
+			addErr("linkGraph.dsn", "must be set when the %q backend is selected (e.g. postgresql://user@host:26257/linkgraph)", LinkGraphDB)
+		} else if _, pErr := url.Parse(cfg.LinkGraph.DSN); pErr != nil {
+			addErr("linkGraph.dsn", "is not a valid URL: %v", pErr)
+		}
+	case LinkGraphES:
+		if cfg.LinkGraph.ESIndexPrefix == "" {
+			addErr("linkGraph.esIndexPrefix", "must not be empty")
+		}
+	case LinkGraphSQLite:
+		if cfg.LinkGraph.SQLitePath == "" {
+			addErr("linkGraph.sqlitePath", "must be set when the %q backend is selected", LinkGraphSQLite)
+		}
+	case LinkGraphBolt:
+		if cfg.LinkGraph.BoltPath == "" {
+			addErr("linkGraph.boltPath", "must be set when the %q backend is selected", LinkGraphBolt)
+		}
+	case LinkGraphGRPC:
+		if addr := cfg.LinkGraph.GRPCAddress; addr == "" {
+			addErr("linkGraph.grpcAddress", "must be set when the %q backend is selected", LinkGraphGRPC)
+		} else if _, _, aErr := net.SplitHostPort(addr); aErr != nil {
+			addErr("linkGraph.grpcAddress", "%q is not a valid host:port address", addr)
+		}
+	default:
+		addErr("linkGraph.backend", "unknown backend %q; expected one of %q, %q, %q, %q, %q or %q", cfg.LinkGraph.Backend, LinkGraphMemory, LinkGraphDB, LinkGraphES, LinkGraphSQLite, LinkGraphBolt, LinkGraphGRPC)
+	}
+	if cfg.LinkGraph.StateFile != "" && cfg.LinkGraph.Backend != LinkGraphMemory {
+		addErr("linkGraph.stateFile", "is only supported by the %q backend", LinkGraphMemory)
+	}
+	if cfg.LinkGraph.StateSaveInterval < 0 {
+		addErr("linkGraph.stateSaveInterval", "must not be negative (got %s)", cfg.LinkGraph.StateSaveInterval)
+	}
+
+	// Text indexer
+	switch cfg.TextIndexer.Backend {
+	case TextIndexerMemory:
+	case TextIndexerES:
+		if cfg.TextIndexer.ES.IndexName == "" {
+			addErr("textIndexer.es.indexName", "must not be empty")
+		}
+	case TextIndexerBleve:
+		if cfg.TextIndexer.BlevePath == "" {
+			addErr("textIndexer.blevePath", "must be set when the %q backend is selected", TextIndexerBleve)
+		}
+	case TextIndexerMeili:
+		if u, pErr := url.Parse(cfg.TextIndexer.Meili.URL); pErr != nil || u.Scheme == "" || u.Host == "" {
+			addErr("textIndexer.meili.url", "%q is not a valid URL (e.g. http://localhost:7700)", cfg.TextIndexer.Meili.URL)
+		}
+		if cfg.TextIndexer.Meili.IndexName == "" {
+			addErr("textIndexer.meili.indexName", "must not be empty")
+		}
+	case TextIndexerGRPC:
+		if addr := cfg.TextIndexer.GRPCAddress; addr == "" {
+			addErr("textIndexer.grpcAddress", "must be set when the %q backend is selected", TextIndexerGRPC)
+		} else if _, _, aErr := net.SplitHostPort(addr); aErr != nil {
+			addErr("textIndexer.grpcAddress", "%q is not a valid host:port address", addr)
+		}
+	default:
+		addErr("textIndexer.backend", "unknown backend %q; expected one of %q, %q, %q, %q or %q", cfg.TextIndexer.Backend, TextIndexerMemory, TextIndexerES, TextIndexerBleve, TextIndexerMeili, TextIndexerGRPC)
+	}
+	if cfg.TextIndexer.StateFile != "" && cfg.TextIndexer.Backend != TextIndexerMemory {
+		addErr("textIndexer.stateFile", "is only supported by the %q backend", TextIndexerMemory)
+	}
+	if cfg.TextIndexer.StateSaveInterval < 0 {
+		addErr("textIndexer.stateSaveInterval", "must not be negative (got %s)", cfg.TextIndexer.StateSaveInterval)
+	}
+
+	if _, bErr := cfg.TextIndexer.Boosts(); bErr != nil {
+		addErr("textIndexer.fieldBoosts", "%v", bErr)
+	}
+	if bErr := index.CheckBatchSize(cfg.TextIndexer.BatchSize); bErr != nil {
+		addErr("textIndexer.batchSize", "%v", bErr)
+	}
+	if cfg.TextIndexer.MaxOffset <= 0 {
+		addErr("textIndexer.maxOffset", "must be greater than zero (got %d)", cfg.TextIndexer.MaxOffset)
+	}
+
+	// The ES cluster settings are shared by the ES-backed text indexer and
+	// link graph stores.
+	if cfg.TextIndexer.Backend == TextIndexerES || cfg.LinkGraph.Backend == LinkGraphES {
+		esCfg := cfg.TextIndexer.ES
+		if len(esCfg.Nodes) == 0 {
+			addErr("textIndexer.es.nodes", "at least one node must be specified when the %q text indexer or link graph backend is selected", TextIndexerES)
+		}
+		for _, node := range esCfg.Nodes {
+			if u, pErr := url.Parse(node); pErr != nil || u.Scheme == "" || u.Host == "" {
+				addErr("textIndexer.es.nodes", "%q is not a valid node URL (e.g. http://localhost:9200)", node)
+			}
+		}
+		if esCfg.Shards < 0 {
+			addErr("textIndexer.es.shards", "must not be negative (got %d)", esCfg.Shards)
+		}
+		if esCfg.Replicas < -1 {
+			addErr("textIndexer.es.replicas", "must be -1 (no replicas), 0 (cluster default) or a positive number (got %d)", esCfg.Replicas)
+		}
+		if esCfg.APIKey != "" && esCfg.Username != "" {
+			addErr("textIndexer.es.apiKey", "cannot be combined with username/password authentication")
+		}
+		if esCfg.Password != "" && esCfg.Username == "" {
+			addErr("textIndexer.es.username", "must be set when a password is specified")
+		}
+	}
+	if backupCfg := cfg.TextIndexer.Backup; backupCfg.Enabled {
+		if backupCfg.Interval <= 0 {
+			addErr("textIndexer.backup.interval", "must be a positive duration (got %s)", backupCfg.Interval)
+		}
+		if backupCfg.KeepLast <= 0 {
+			addErr("textIndexer.backup.keepLast", "must be greater than zero (got %d)", backupCfg.KeepLast)
+		}
+		if backupCfg.MaxAge < 0 {
+			addErr("textIndexer.backup.maxAge", "must not be negative (got %s)", backupCfg.MaxAge)
+		}
+		switch {
+		case cfg.TextIndexer.Backend != TextIndexerES && backupCfg.Dir == "":
+			addErr("textIndexer.backup.dir", "must be set when backing up the %q backend", cfg.TextIndexer.Backend)
+		case cfg.TextIndexer.Backend == TextIndexerES && backupCfg.ESRepository == "":
+			addErr("textIndexer.backup.esRepository", "must be set when backing up the %q backend", TextIndexerES)
+		}
+	}
+
+	// PageRank
+	if cfg.PageRank.ComputeWorkers <= 0 {
+		addErr("pageRank.computeWorkers", "must be greater than zero (got %d)", cfg.PageRank.ComputeWorkers)
+	}
+	if cfg.PageRank.UpdateInterval <= 0 {
+		addErr("pageRank.updateInterval", "must be a positive duration (got %s)", cfg.PageRank.UpdateInterval)
+	}
+	switch cfg.PageRank.HistoryStore {
+	case PageRankHistoryMemory:
+	case PageRankHistoryDB:
+		if cfg.LinkGraph.Backend != LinkGraphDB {
+			addErr("pageRank.historyStore", "the %q store requires the %q link graph backend", PageRankHistoryDB, LinkGraphDB)
+		}
+	default:
+		addErr("pageRank.historyStore", "unknown store %q; expected one of %q or %q", cfg.PageRank.HistoryStore, PageRankHistoryMemory, PageRankHistoryDB)
+	}
+	if cfg.PageRank.HistoryPasses < 0 {
+		addErr("pageRank.historyPasses", "must not be negative (got %d)", cfg.PageRank.HistoryPasses)
+	}
+
+	// Frontend
+	if _, _, aErr := net.SplitHostPort(cfg.Frontend.ListenAddress); aErr != nil {
+		addErr("frontend.listenAddress", "%q is not a valid host:port address", cfg.Frontend.ListenAddress)
+	}
+	if cfg.Frontend.ResultsPerPage <= 0 {
+		addErr("frontend.resultsPerPage", "must be greater than zero (got %d)", cfg.Frontend.ResultsPerPage)
+	}
+	if cfg.Frontend.GraphQLMaxDepth <= 0 {
+		addErr("frontend.graphQLMaxDepth", "must be greater than zero (got %d)", cfg.Frontend.GraphQLMaxDepth)
+	}
+	if cfg.Frontend.GraphQLMaxComplexity <= 0 {
+		addErr("frontend.graphQLMaxComplexity", "must be greater than zero (got %d)", cfg.Frontend.GraphQLMaxComplexity)
+	}
+	if t := cfg.Frontend.SLOFetchSuccessTarget; t <= 0 || t >= 1 {
+		addErr("frontend.sloFetchSuccessTarget", "must be between 0 and 1 exclusive (got %g)", t)
+	}
+	if cfg.Frontend.FeedRefreshInterval < 0 {
+		addErr("frontend.feedRefreshInterval", "must not be negative (got %s)", cfg.Frontend.FeedRefreshInterval)
+	}
+	if cfg.Frontend.FeedSize <= 0 {
+		addErr("frontend.feedSize", "must be greater than zero (got %d)", cfg.Frontend.FeedSize)
+	}
+	if _, pErr := cfg.Frontend.AccessPolicy(); pErr != nil {
+		// Report each problem detected by the policy separately.
+		var mErr *multierror.Error
+		if errors.As(pErr, &mErr) {
+			for _, e := range mErr.Errors {
+				addErr("frontend.apiKeys", "%v", e)
+			}
+		} else {
+			addErr("frontend.apiKeys", "%v", pErr)
+		}
+	}
+
+	// Partitioning
+	switch cfg.Partition.Detector {
+	case PartitionDetectorStatic:
+		if len(cfg.Partition.Members) != 0 && cfg.Partition.Self == "" {
+			addErr("partition.self", "must be set when a member list is specified")
+		}
+	case PartitionDetectorDNS:
+		if cfg.Partition.SRVName == "" {
+			addErr("partition.srvName", "must be set when the %q detector is selected", PartitionDetectorDNS)
+		}
+	default:
+		addErr("partition.detector", "unknown detector %q; expected one of %q or %q", cfg.Partition.Detector, PartitionDetectorStatic, PartitionDetectorDNS)
+	}
+
+	// Health
+	if addr := cfg.Health.ListenAddress; addr != "" {
+		if _, _, aErr := net.SplitHostPort(addr); aErr != nil {
+			addErr("health.listenAddress", "%q is not a valid host:port address", addr)
+		}
+	}
+	if cfg.Health.PingTimeout <= 0 {
+		addErr("health.pingTimeout", "must be positive (got %s)", cfg.Health.PingTimeout)
+	}
+
+	// gRPC
+	if addr := cfg.GRPC.ListenAddress; addr != "" {
+		if _, _, aErr := net.SplitHostPort(addr); aErr != nil {
+			addErr("grpc.listenAddress", "%q is not a valid host:port address", addr)
+		}
+	}
+
+	// Logging
+	if _, lErr := logging.ParseLevel(cfg.Logging.Level); lErr != nil || cfg.Logging.Level == "" {
+		addErr("logging.level", "unknown level %q; expected one of \"debug\", \"info\", \"warn\" or \"error\"", cfg.Logging.Level)
+	}
+	switch cfg.Logging.Format {
+	case logging.FormatJSON, logging.FormatText:
+	default:
+		addErr("logging.format", "unknown format %q; expected one of %q or %q", cfg.Logging.Format, logging.FormatJSON, logging.FormatText)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+func compileRegexp(expr string) error {
+	_, err := regexp.Compile(expr)
+	return err
+}
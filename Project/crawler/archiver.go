@@ -0,0 +1,44 @@
+package crawler
+
+import (
+	"context"
+	"log/slog"
+	"webcrawler/crawler/warc"
+	"webcrawler/logging"
+	"webcrawler/pipeline"
+)
+
+var _ pipeline.Processor = (*responseArchiver)(nil)
+
+// responseArchiver writes the response of each fetched page to an Archiver.
+// Archiving is best-effort: failures are logged but never cause a payload to
+// be dropped.
+type responseArchiver struct {
+	archiver Archiver
+	logger   *slog.Logger
+}
+
+func newResponseArchiver(archiver Archiver, logger *slog.Logger) *responseArchiver {
+	return &responseArchiver{archiver: archiver, logger: logging.Component(logger, "crawler.archiver")}
+}
+
+func (ra *responseArchiver) Process(_ context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	fetchedURL := payload.URL
+	if payload.BaseURL != "" {
+		fetchedURL = payload.BaseURL
+	}
+	err := ra.archiver.WriteRecord(&warc.Record{
+		LinkID:     payload.LinkID,
+		URL:        fetchedURL,
+		FetchedAt:  payload.FetchedAt,
+		StatusCode: payload.StatusCode,
+		Proto:      payload.Proto,
+		Header:     payload.ResponseHeader,
+		Body:       payload.RawContent.Bytes(),
+	})
+	if err != nil {
+		ra.logger.Error("unable to archive response", logging.Link(payload.LinkID, payload.URL), "err", err)
+	}
+	return payload, nil
+}
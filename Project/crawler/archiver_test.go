@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"webcrawler/crawler/warc"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ResponseArchiverTestSuite))
+
+type ResponseArchiverTestSuite struct{}
+
+func (s *ResponseArchiverTestSuite) TestArchiveResponse(c *gc.C) {
+	archiver := new(recordingArchiver)
+	ra := newResponseArchiver(archiver, nil)
+
+	p := &crawlerPayload{
+		LinkID:         uuid.New(),
+		URL:            "http://example.com/old",
+		BaseURL:        "http://example.com/new",
+		FetchedAt:      time.Now(),
+		StatusCode:     http.StatusOK,
+		Proto:          "HTTP/2.0",
+		ResponseHeader: http.Header{"Content-Type": {"text/html"}},
+	}
+	p.RawContent.WriteString("<html></html>")
+	out, err := ra.Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.Equals, p)
+	c.Assert(archiver.records, gc.HasLen, 1)
+	rec := archiver.records[0]
+	c.Assert(rec.LinkID, gc.Equals, p.LinkID)
+	c.Assert(rec.URL, gc.Equals, "http://example.com/new", gc.Commentf("redirected fetches are archived under the final URL"))
+	c.Assert(rec.Proto, gc.Equals, "HTTP/2.0")
+	c.Assert(string(rec.Body), gc.Equals, "<html></html>")
+
+	// Failures do not drop the payload.
+	archiver.err = errors.New("disk full")
+	out, err = ra.Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.Equals, p)
+}
+
+type recordingArchiver struct {
+	records []*warc.Record
+	err     error
+}
+
+func (a *recordingArchiver) WriteRecord(rec *warc.Record) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.records = append(a.records, rec)
+	return nil
+}
@@ -0,0 +1,118 @@
+// Package blobstore provides storage for binary assets (e.g. favicons and page
+// thumbnails) that are captured by the crawler and served by the frontend.
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when attempting to look up a blob that does not
+// exist.
+var ErrNotFound = errors.New("blob not found")
+
+// Blob is a binary asset together with its media type.
+type Blob struct {
+	ContentType string
+	Data        []byte
+}
+
+// Store is implemented by objects that can store and retrieve blobs.
+type Store interface {
+	// Put stores a blob under key, replacing any existing blob.
+	Put(key string, blob *Blob) error
+
+	// Get returns the blob stored under key.
+	Get(key string) (*Blob, error)
+
+	// Delete removes the blob stored under key. Deleting a missing blob
+	// is not an error.
+	Delete(key string) error
+}
+
+// Compile-time check for ensuring InMemoryStore implements Store.
+var _ Store = (*InMemoryStore)(nil)
+
+// InMemoryStore is a Store implementation that keeps blobs in memory.
+type InMemoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string]*Blob
+}
+
+// NewInMemoryStore creates a new in-memory blob store.
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{blobs: make(map[string]*Blob)}
+}
+
+// Put stores a blob under key, replacing any existing blob.
+func (s *InMemoryStore) Put(key string, blob *Blob) error {
+	bCopy := &Blob{
+		ContentType: blob.ContentType,
+		Data:        append([]byte(nil), blob.Data...),
+	}
+
+	s.mu.Lock()
+	s.blobs[key] = bCopy
+	s.mu.Unlock()
+	return nil
+}
+
+// Get returns the blob stored under key.
+func (s *InMemoryStore) Get(key string) (*Blob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blob, found := s.blobs[key]
+	if !found {
+		return nil, fmt.Errorf("get blob %q: %w", key, ErrNotFound)
+	}
+	return &Blob{ContentType: blob.ContentType, Data: append([]byte(nil), blob.Data...)}, nil
+}
+
+// Delete removes the blob stored under key.
+func (s *InMemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.blobs, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// HTTPHandler serves the blobs of a Store over HTTP. The blob key is the
+// request path with any prefix stripped by the caller (e.g. via
+// http.StripPrefix).
+type HTTPHandler struct {
+	store Store
+}
+
+// NewHTTPHandler returns an HTTP handler that serves blobs from store.
+func NewHTTPHandler(store Store) *HTTPHandler {
+	return &HTTPHandler{store: store}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blob, err := h.store.Get(strings.TrimPrefix(r.URL.Path, "/"))
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", blob.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(blob.Data)
+	}
+}
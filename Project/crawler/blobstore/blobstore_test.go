@@ -0,0 +1,75 @@
+package blobstore
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(BlobStoreTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type BlobStoreTestSuite struct{}
+
+func (s *BlobStoreTestSuite) TestPutGet(c *gc.C) {
+	store := NewInMemoryStore()
+	data := []byte{1, 2, 3}
+	c.Assert(store.Put("favicons/example.com", &Blob{ContentType: "image/png", Data: data}), gc.IsNil)
+
+	// Mutating the original slice must not affect the stored blob.
+	data[0] = 42
+
+	blob, err := store.Get("favicons/example.com")
+	c.Assert(err, gc.IsNil)
+	c.Assert(blob, gc.DeepEquals, &Blob{ContentType: "image/png", Data: []byte{1, 2, 3}})
+
+	_, err = store.Get("missing")
+	c.Assert(errors.Is(err, ErrNotFound), gc.Equals, true)
+}
+
+func (s *BlobStoreTestSuite) TestDelete(c *gc.C) {
+	store := NewInMemoryStore()
+	c.Assert(store.Put("a", &Blob{Data: []byte("a")}), gc.IsNil)
+	c.Assert(store.Delete("a"), gc.IsNil)
+	c.Assert(store.Delete("a"), gc.IsNil)
+
+	_, err := store.Get("a")
+	c.Assert(errors.Is(err, ErrNotFound), gc.Equals, true)
+}
+
+func (s *BlobStoreTestSuite) TestFileStore(c *gc.C) {
+	store, err := NewFileStore(c.MkDir())
+	c.Assert(err, gc.IsNil)
+	c.Assert(store.Put("backups/2024/a.gz", &Blob{ContentType: "application/gzip", Data: []byte{1, 2}}), gc.IsNil)
+
+	blob, err := store.Get("backups/2024/a.gz")
+	c.Assert(err, gc.IsNil)
+	c.Assert(blob, gc.DeepEquals, &Blob{ContentType: "application/gzip", Data: []byte{1, 2}})
+
+	c.Assert(store.Delete("backups/2024/a.gz"), gc.IsNil)
+	_, err = store.Get("backups/2024/a.gz")
+	c.Assert(errors.Is(err, ErrNotFound), gc.Equals, true)
+
+	err = store.Put("../escape", &Blob{})
+	c.Assert(err, gc.ErrorMatches, `put blob "../escape": invalid key`)
+}
+
+func (s *BlobStoreTestSuite) TestHTTPHandler(c *gc.C) {
+	store := NewInMemoryStore()
+	c.Assert(store.Put("thumbnails/abc", &Blob{ContentType: "image/jpeg", Data: []byte("jpeg")}), gc.IsNil)
+	h := http.StripPrefix("/blobs", NewHTTPHandler(store))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blobs/thumbnails/abc", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "image/jpeg")
+	c.Assert(rec.Body.String(), gc.Equals, "jpeg")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blobs/thumbnails/missing", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound)
+}
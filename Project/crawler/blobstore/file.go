@@ -0,0 +1,115 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The suffix of the files that hold the media type of each blob.
+const contentTypeSuffix = ".content-type"
+
+// Compile-time check for ensuring FileStore implements Store.
+var _ Store = (*FileStore)(nil)
+
+// FileStore is a Store implementation that keeps each blob in a file below a
+// base directory. Keys are slash-separated paths relative to the base
+// directory; the media type of each blob is stored in a sibling file.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a blob store that keeps blobs below dir. The directory
+// is created if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("file store: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put stores a blob under key, replacing any existing blob.
+func (s *FileStore) Put(key string, blob *Blob) error {
+	path, err := s.path(key)
+	if err != nil {
+		return fmt.Errorf("put blob %q: %w", key, err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("put blob %q: %w", key, err)
+	}
+	if err = writeFileAtomic(path+contentTypeSuffix, []byte(blob.ContentType)); err != nil {
+		return fmt.Errorf("put blob %q: %w", key, err)
+	}
+	if err = writeFileAtomic(path, blob.Data); err != nil {
+		return fmt.Errorf("put blob %q: %w", key, err)
+	}
+	return nil
+}
+
+// Get returns the blob stored under key.
+func (s *FileStore) Get(key string) (*Blob, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, fmt.Errorf("get blob %q: %w", key, err)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("get blob %q: %w", key, ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("get blob %q: %w", key, err)
+	}
+	contentType, err := os.ReadFile(path + contentTypeSuffix)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("get blob %q: %w", key, err)
+	}
+	return &Blob{ContentType: string(contentType), Data: data}, nil
+}
+
+// Delete removes the blob stored under key.
+func (s *FileStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return fmt.Errorf("delete blob %q: %w", key, err)
+	}
+	for _, p := range []string{path, path + contentTypeSuffix} {
+		if err = os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("delete blob %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// path maps key to a path below the base directory. Keys that would escape
+// the base directory are rejected.
+func (s *FileStore) path(key string) (string, error) {
+	rel := filepath.FromSlash(key)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid key")
+	}
+	return filepath.Join(s.dir, rel), nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to path so
+// that readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
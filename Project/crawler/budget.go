@@ -0,0 +1,137 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Budget defines the resource limits for a single crawl job. A zero value for
+// any of the fields disables the corresponding limit.
+type Budget struct {
+	// The maximum wall-clock time that a crawl job may run for.
+	MaxDuration time.Duration
+
+	// The maximum number of response body bytes that a crawl job may fetch.
+	MaxBytes int64
+}
+
+// BudgetStatus describes whether a crawl job ran to completion or was cut
+// short by one of its budget limits or a shutdown request.
+type BudgetStatus uint8
+
+const (
+	// BudgetNotExceeded indicates that the job processed every link.
+	BudgetNotExceeded BudgetStatus = iota
+
+	// BudgetDurationExceeded indicates that the job ran out of time.
+	BudgetDurationExceeded
+
+	// BudgetBytesExceeded indicates that the job fetched too many bytes.
+	BudgetBytesExceeded
+
+	// CrawlInterrupted indicates that the job was stopped by a shutdown
+	// request (see ShutdownCoordinator).
+	CrawlInterrupted
+)
+
+// String implements fmt.Stringer.
+func (s BudgetStatus) String() string {
+	switch s {
+	case BudgetDurationExceeded:
+		return "duration budget exceeded"
+	case BudgetBytesExceeded:
+		return "byte budget exceeded"
+	case CrawlInterrupted:
+		return "interrupted"
+	default:
+		return "completed"
+	}
+}
+
+// Report summarizes the outcome of a crawl job.
+type Report struct {
+	// The number of links that went through the crawler pipeline.
+	Processed int
+
+	// The total number of response body bytes fetched by the job.
+	BytesFetched int64
+
+	// The wall-clock time that the job took to drain.
+	Elapsed time.Duration
+
+	// Indicates whether the job was cut short by its budget or a shutdown
+	// request.
+	Status BudgetStatus
+
+	// The IDs of the links that were not crawled because the budget was
+	// exceeded or a shutdown was requested. These links keep their
+	// previous retrieval timestamp and will therefore be picked up by the
+	// next crawl pass (or the resumed pass).
+	Deferred []uuid.UUID
+
+	// The hosts that were quarantined during the job because of their
+	// high fetch latency.
+	QuarantinedHosts []string
+}
+
+// String implements fmt.Stringer.
+func (r *Report) String() string {
+	return fmt.Sprintf(
+		"crawl %s: processed=%d bytes=%d elapsed=%s deferred=%d quarantined=%d",
+		r.Status, r.Processed, r.BytesFetched, r.Elapsed, len(r.Deferred), len(r.QuarantinedHosts),
+	)
+}
+
+// budgetTracker keeps track of the resources consumed by a crawl job.
+type budgetTracker struct {
+	budget   Budget
+	deadline time.Time
+	bytes    int64
+}
+
+func newBudgetTracker(budget Budget) *budgetTracker {
+	t := &budgetTracker{budget: budget}
+	if budget.MaxDuration > 0 {
+		t.deadline = time.Now().Add(budget.MaxDuration)
+	}
+	return t
+}
+
+// addBytes records n additional fetched bytes.
+func (t *budgetTracker) addBytes(n int64) {
+	atomic.AddInt64(&t.bytes, n)
+}
+
+// bytesFetched returns the number of bytes fetched so far.
+func (t *budgetTracker) bytesFetched() int64 {
+	return atomic.LoadInt64(&t.bytes)
+}
+
+// status reports whether any of the budget limits has been exceeded.
+func (t *budgetTracker) status() BudgetStatus {
+	if !t.deadline.IsZero() && !time.Now().Before(t.deadline) {
+		return BudgetDurationExceeded
+	}
+	if t.budget.MaxBytes > 0 && t.bytesFetched() >= t.budget.MaxBytes {
+		return BudgetBytesExceeded
+	}
+	return BudgetNotExceeded
+}
+
+type budgetTrackerKey struct{}
+
+// withBudgetTracker returns a context that carries t so pipeline stages can
+// report the resources they consume.
+func withBudgetTracker(ctx context.Context, t *budgetTracker) context.Context {
+	return context.WithValue(ctx, budgetTrackerKey{}, t)
+}
+
+// budgetTrackerFromContext returns the tracker associated with ctx or nil.
+func budgetTrackerFromContext(ctx context.Context) *budgetTracker {
+	t, _ := ctx.Value(budgetTrackerKey{}).(*budgetTracker)
+	return t
+}
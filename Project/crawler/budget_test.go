@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"context"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/pipeline"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(BudgetTestSuite))
+
+type BudgetTestSuite struct{}
+
+func (s *BudgetTestSuite) TestCrawlWithoutBudget(c *gc.C) {
+	links := makeLinks(5)
+	report, err := s.crawler(0).CrawlWithBudget(context.TODO(), &sliceLinkIterator{links: links}, Budget{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Status, gc.Equals, BudgetNotExceeded)
+	c.Assert(report.Processed, gc.Equals, len(links))
+	c.Assert(report.Deferred, gc.HasLen, 0)
+}
+
+func (s *BudgetTestSuite) TestCrawlWithDurationBudget(c *gc.C) {
+	links := makeLinks(5)
+	budget := Budget{MaxDuration: time.Nanosecond}
+	report, err := s.crawler(0).CrawlWithBudget(context.TODO(), &sliceLinkIterator{links: links}, budget)
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Status, gc.Equals, BudgetDurationExceeded)
+	c.Assert(report.Processed, gc.Equals, 0)
+	c.Assert(report.Deferred, gc.HasLen, len(links))
+	for i, id := range report.Deferred {
+		c.Assert(id, gc.Equals, links[i].ID)
+	}
+}
+
+func (s *BudgetTestSuite) TestCrawlWithByteBudget(c *gc.C) {
+	links := makeLinks(10)
+	budget := Budget{MaxBytes: 1}
+	report, err := s.crawler(100).CrawlWithBudget(context.TODO(), &sliceLinkIterator{links: links}, budget)
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Status, gc.Equals, BudgetBytesExceeded)
+	c.Assert(report.BytesFetched >= 100, gc.Equals, true)
+
+	// The source may have already fetched the next link by the time the
+	// first payload reports its byte count.
+	c.Assert(report.Processed >= 1 && report.Processed <= 2, gc.Equals, true, gc.Commentf("processed %d links", report.Processed))
+	c.Assert(report.Processed+len(report.Deferred), gc.Equals, len(links))
+}
+
+// crawler returns a Crawler whose pipeline mimics the broadcast layout of the
+// real crawler and records bytesPerLink fetched bytes for each payload.
+func (s *BudgetTestSuite) crawler(bytesPerLink int64) *Crawler {
+	fetch := pipeline.ProcessorFunc(func(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+		budgetTrackerFromContext(ctx).addBytes(bytesPerLink)
+		return p, nil
+	})
+	passthrough := pipeline.ProcessorFunc(func(_ context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+		return p, nil
+	})
+
+	return &Crawler{
+		p: pipeline.New(
+			pipeline.FIFO(fetch),
+			pipeline.Broadcast(passthrough, passthrough),
+		),
+	}
+}
+
+func makeLinks(n int) []*graph.Link {
+	links := make([]*graph.Link, n)
+	for i := range links {
+		links[i] = &graph.Link{ID: uuid.New(), URL: "http://example.com"}
+	}
+	return links
+}
+
+type sliceLinkIterator struct {
+	links    []*graph.Link
+	curIndex int
+}
+
+func (i *sliceLinkIterator) Next() bool {
+	if i.curIndex >= len(i.links) {
+		return false
+	}
+	i.curIndex++
+	return true
+}
+
+func (i *sliceLinkIterator) Error() error      { return nil }
+func (i *sliceLinkIterator) Close() error      { return nil }
+func (i *sliceLinkIterator) Link() *graph.Link { return i.links[i.curIndex-1] }
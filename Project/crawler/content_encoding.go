@@ -0,0 +1,25 @@
+package crawler
+
+import "io"
+
+// DefaultMaxBodySize is the maximum size of a decoded response body if the
+// crawler config does not specify one.
+const DefaultMaxBodySize = 10 << 20
+
+// countingReader counts the bytes read from the wrapped reader and records
+// the last read error other than io.EOF. It allows the fetcher to tell errors
+// while receiving a response body apart from errors while decoding it.
+type countingReader struct {
+	r       io.Reader
+	n       int64
+	lastErr error
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	if err != nil && err != io.EOF {
+		cr.lastErr = err
+	}
+	return n, err
+}
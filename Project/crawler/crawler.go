@@ -2,13 +2,26 @@ package crawler
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"regexp"
 	"time"
+	"webcrawler/crawler/blobstore"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/extract"
+	"webcrawler/crawler/jsonpath"
 	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/scope"
 	"webcrawler/crawler/textindexer/index"
+	"webcrawler/crawler/warc"
+	"webcrawler/metrics"
 	"webcrawler/pipeline"
+	"webcrawler/tracing"
+	"webcrawler/urlutil/normalizer"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 //go:generate mockgen -package mocks -destination mocks/mocks.g webcrawler/crawler URLGetter,PrivateNetworkDetector,Graph,Indexer
@@ -18,12 +31,100 @@ type URLGetter interface {
 	Get(url string) (*http.Response, error)
 }
 
+// Fetcher is implemented by objects that can execute the HTTP requests for
+// fetching links (e.g. the fetchers created by fetch.New).
+type Fetcher interface {
+	// Fetch executes req and returns its response. Requests are cancelled
+	// when their context is done.
+	Fetch(req *http.Request) (*http.Response, error)
+}
+
 // PrivateNetworkDetector is implemented by objects that can detect whether a
 // host resolves to a private network address.
 type PrivateNetworkDetector interface {
 	IsPrivate(host string) (bool, error)
 }
 
+// RobotsPolicy is implemented by objects that can evaluate the robots.txt
+// policy of the host of a URL (e.g. robots.Cache).
+type RobotsPolicy interface {
+	// Allowed returns true if the crawler may fetch rawURL.
+	Allowed(rawURL string) (bool, error)
+}
+
+// Pacer is implemented by objects that space out the requests to each host
+// according to the pacing signals it provides (e.g. pacing.Controller).
+type Pacer interface {
+	// Wait blocks until the next request to host may be issued. It
+	// returns an error if the request cannot be issued in a timely
+	// manner or ctx is cancelled.
+	Wait(ctx context.Context, host string) error
+
+	// SetCrawlDelay sets the Crawl-delay specified by the robots.txt file
+	// of host.
+	SetCrawlDelay(host string, delay time.Duration)
+
+	// Observe updates the pacing state of host using the status code and
+	// headers of a response received from it.
+	Observe(host string, statusCode int, header http.Header)
+}
+
+// Deduplicator is implemented by objects that can detect pages whose content
+// is a near-duplicate of a previously crawled page (e.g. dedup.Index).
+type Deduplicator interface {
+	// Canonical returns the ID of the link whose content fingerprint is
+	// closest to fingerprint or linkID if no near-duplicate exists.
+	Canonical(linkID uuid.UUID, fingerprint uint64) (uuid.UUID, error)
+}
+
+// RecrawlPolicy is implemented by objects that schedule the next fetch of each
+// crawled link (e.g. recrawl.Policy).
+type RecrawlPolicy interface {
+	// NextFetch returns the time at which link, which was fetched at
+	// fetchedAt and whose extracted content hashes to contentHash, is due
+	// to be fetched again.
+	NextFetch(link *graph.Link, contentHash uint64, fetchedAt time.Time) time.Time
+}
+
+// ErrorRecorder is implemented by objects that keep track of the errors
+// encountered while crawling links such as errstore.Store.
+type ErrorRecorder interface {
+	// Record records an error event.
+	Record(e errstore.Event)
+}
+
+// FailureTracker is implemented by objects that keep track of the links that
+// could not be fetched, e.g. for scheduling retries (such as retry.Tracker).
+type FailureTracker interface {
+	// LinkFailed is invoked when fetching the link with the specified ID
+	// fails with an error of the specified class.
+	LinkFailed(linkID uuid.UUID, url string, class errstore.Class, err error)
+}
+
+// ProvenanceRecorder is implemented by objects that keep track of the stages
+// that each crawled link went through, e.g. for attributing slow crawls to
+// particular stages.
+type ProvenanceRecorder interface {
+	// LinkProcessed is invoked once a link that did not encounter any
+	// errors has left the pipeline.
+	LinkProcessed(p LinkProvenance)
+}
+
+// LinkProvenance describes the journey of a link through the crawler
+// pipeline. The provenance of links that encounter an error is attached to
+// the events that are passed to the ErrorRecorder instead.
+type LinkProvenance struct {
+	PassID uint64
+	LinkID uuid.UUID
+	URL    string
+
+	// The stages that processed the link in the order in which they
+	// finished. A link that was dropped by a stage (e.g. because its
+	// content is not supported) ends with an errstore.StageDropped
+	// outcome.
+	Stages []errstore.StageRecord
+}
+
 // Graph is implemented by objects that can upsert links and edges into a link
 // graph instance.
 type Graph interface {
@@ -46,6 +147,61 @@ type Indexer interface {
 	Index(doc *index.Document) error
 }
 
+// BlobStore is implemented by objects that can store binary assets such as
+// favicons and page thumbnails.
+type BlobStore interface {
+	// Put stores a blob under key, replacing any existing blob.
+	Put(key string, blob *blobstore.Blob) error
+}
+
+// Screenshotter is implemented by rendering fetchers that can capture an
+// image of a rendered web-page.
+type Screenshotter interface {
+	// Screenshot renders the page at url and returns an image of it.
+	Screenshot(ctx context.Context, url string) (*blobstore.Blob, error)
+}
+
+// Archiver is implemented by objects that archive the responses of fetched
+// pages, such as warc.FileWriter.
+type Archiver interface {
+	// WriteRecord archives the response described by rec.
+	WriteRecord(rec *warc.Record) error
+}
+
+// HeaderRule describes a response header that the crawler captures for each
+// fetched page. Captured headers are stored with the indexed document and can
+// be used to filter search results.
+type HeaderRule struct {
+	// The name of the header to capture (case-insensitive).
+	Header string
+
+	// An optional pattern for extracting part of the header value. If the
+	// pattern contains a capturing group, the first group is captured;
+	// otherwise, the entire match is captured. Headers whose value does
+	// not match the pattern are not captured.
+	Pattern *regexp.Regexp
+}
+
+// StructuredSource describes a JSON API whose responses are crawled and
+// indexed alongside HTML pages. The document fields are populated by
+// evaluating JSONPath expressions against the decoded response body.
+type StructuredSource struct {
+	// The URLs that are served by this source.
+	URLPattern *regexp.Regexp
+
+	// An optional expression that selects the document title. If it
+	// matches multiple values, the first one is used.
+	Title *jsonpath.Path
+
+	// The expressions that select the document content. The matched
+	// values are joined with spaces.
+	Content []*jsonpath.Path
+
+	// Optional expressions that select links to other pages or API
+	// endpoints. Relative links are resolved against the fetched URL.
+	Links []*jsonpath.Path
+}
+
 // Config encapsulates the configuration options for creating a new Crawler.
 type Config struct {
 	// A PrivateNetworkDetector instance
@@ -54,6 +210,24 @@ type Config struct {
 	// A URLGetter instance for fetching links.
 	URLGetter URLGetter
 
+	// An optional Fetcher for fetching links. If specified, it is used
+	// instead of the URLGetter, which is still used for capturing
+	// favicons. Fetch requests advertise the content encodings that the
+	// crawler decodes itself so decoding middlewares such as fetch.Decode
+	// leave their responses untouched.
+	Fetcher Fetcher
+
+	// An optional RobotsPolicy for skipping links that are disallowed by
+	// the robots.txt file of their host. If not specified, robots.txt
+	// files are ignored.
+	Robots RobotsPolicy
+
+	// An optional Pacer for spacing out the requests to each host. If a
+	// RobotsPolicy is also specified and it can report the Crawl-delay of
+	// a host (such as robots.Cache), the delay is passed to the Pacer.
+	// If not specified, links are fetched as fast as the workers allow.
+	Pacer Pacer
+
 	// A GraphUpdater instance for addding new links to the link graph.
 	Graph Graph
 
@@ -62,43 +236,240 @@ type Config struct {
 
 	// The number of concurrent workers used for retrieving links.
 	FetchWorkers int
+
+	// The ID of the crawl pass that this crawler belongs to. Links and
+	// edges that are written to the link graph are stamped with this ID
+	// so that graph consumers can read a consistent snapshot of the graph
+	// as of the end of a particular pass.
+	PassID uint64
+
+	// An optional BlobStore for storing captured favicons and thumbnails.
+	// Capturing is disabled if no BlobStore is specified.
+	BlobStore BlobStore
+
+	// If true, the favicon of each crawled host is fetched (once per host)
+	// and stored in the BlobStore.
+	CaptureFavicons bool
+
+	// An optional Screenshotter for capturing page thumbnails. It is only
+	// used if a BlobStore is also specified.
+	Screenshotter Screenshotter
+
+	// An optional list of response headers to capture for each fetched
+	// page.
+	HeaderRules []HeaderRule
+
+	// An optional Archiver for the responses of the fetched pages. Bodies
+	// are archived after their content encoding has been decoded.
+	Archiver Archiver
+
+	// An optional list of JSON APIs to crawl. URLs that match one of the
+	// sources are accepted if they return JSON content.
+	StructuredSources []StructuredSource
+
+	// Optional settings for adapting the fetch timeout for each host to
+	// its observed latency. If not specified, fetches are only subject to
+	// the timeouts of the URLGetter. Timeouts are only enforced if a
+	// Fetcher is specified or the URLGetter can also execute requests via
+	// a Do method (such as http.Client).
+	AdaptiveTimeouts *AdaptiveTimeouts
+
+	// The maximum size of a response body after decoding its content
+	// encoding. Responses with larger bodies are skipped. If not
+	// specified, DefaultMaxBodySize is used.
+	MaxBodySize int64
+
+	// An optional list of per-domain extraction profiles. The selectors
+	// of the first profile that matches the host of a page take
+	// precedence over the generic text extractor.
+	ExtractionProfiles []extract.Profile
+
+	// The number of sentences to include in the extractive summary that
+	// is generated for each page and stored with the indexed document. A
+	// zero value disables summarization.
+	SummarySentences int
+
+	// An optional list of rules for fetching pages from a mirror while
+	// storing and indexing them under their canonical URLs.
+	URLRewriteRules []URLRewriteRule
+
+	// An optional normalizer for the discovered links. If not specified,
+	// only the normalizations that are not configurable are applied.
+	URLNormalizer *normalizer.Normalizer
+
+	// An optional Scope for dropping discovered links that fall outside
+	// the crawl scope before they are added to the link graph. If not
+	// specified, all links are added.
+	Scope *scope.Scope
+
+	// If true, the noindex and nofollow directives that pages declare
+	// via the X-Robots-Tag header or a <meta name="robots"> tag are
+	// ignored, e.g. for archival crawls. Otherwise, noindex pages are
+	// added to the link graph but not indexed and no links are
+	// extracted from nofollow pages.
+	IgnoreRobotsDirectives bool
+
+	// If true, the images (including srcset candidates), scripts and
+	// frames referenced by each page are added to the link graph along
+	// with its links. Their edges are tagged with the rel type of the
+	// reference so that graph consumers can tell them apart.
+	ExtractResourceLinks bool
+
+	// An optional Deduplicator for detecting pages whose content is a
+	// near-duplicate of a previously crawled page. Only a reference to
+	// the canonical page is indexed for such pages. If not specified,
+	// all pages are indexed.
+	Deduplicator Deduplicator
+
+	// An optional RecrawlPolicy for scheduling the next fetch of each
+	// crawled link. If not specified, crawled links are due again
+	// immediately so that every crawl pass fetches them.
+	RecrawlPolicy RecrawlPolicy
+
+	// An optional ShutdownCoordinator for stopping the crawl gracefully.
+	// Once a shutdown is requested, no new links are fed into the
+	// pipeline while the links that are already in flight are allowed to
+	// drain.
+	Shutdown *ShutdownCoordinator
+
+	// An optional ErrorRecorder for keeping track of fetch failures,
+	// non-2xx responses and link graph and text index write failures by
+	// error class.
+	Errors ErrorRecorder
+
+	// An optional FailureTracker that is notified about the links that
+	// could not be fetched (e.g. because of DNS, connection or robots.txt
+	// failures or non-2xx responses). Link graph and text index write
+	// failures are not reported.
+	Failures FailureTracker
+
+	// An optional ProvenanceRecorder for receiving the duration and
+	// outcome of each stage for the links that are crawled without
+	// errors.
+	Provenance ProvenanceRecorder
+
+	// An optional logger for reporting skipped links and failures. Each
+	// pipeline stage tags its records with its component name and the ID,
+	// URL and host of the link being processed. If not specified, nothing
+	// is logged.
+	Logger *slog.Logger
 }
 
 // Crawler implements a web-page crawling pipeline consisting of the following
 // stages:
 //
-//   - Given a URL, retrieve the web-page contents from the remote server.
-//   - Extract and resolve absolute and relative links from the retrieved page.
-//   - Extract page title and text content from the retrieved page.
+//   - Given a URL, retrieve the web-page contents from the remote server (or
+//     the mirror specified by a URL rewrite rule) if its robots.txt policy
+//     allows it, decode its gzip, deflate, brotli or zstd content encoding
+//     and capture any configured response headers. Requests to
+//     each host are paced according to the Crawl-delay, Retry-After and
+//     overload signals of the host if a Pacer is configured.
+//   - Optionally, archive the response (status line, headers and body) of
+//     each fetched page, e.g. as WARC records.
+//   - Unless configured otherwise, parse the noindex and nofollow robots
+//     directives declared by the X-Robots-Tag header or the
+//     <meta name="robots"> tags of the page.
+//   - For JSON API responses, populate the title, content and links using
+//     the configured structured sources.
+//   - For PDF documents, extract the document title and text. For RSS and
+//     Atom feeds, extract the feed title and item summaries and add the
+//     item links. PDF documents are indexed under the documents search
+//     vertical and feeds under the news vertical.
+//   - Extract, resolve and normalize absolute and relative links from the
+//     retrieved page (unless it is a nofollow page), drop the links that
+//     fall outside the crawl scope and detect the canonical URL declared by
+//     the page.
+//   - Extract page title and text content from the retrieved page using the
+//     extraction profile for the page host (if any) and falling back to the
+//     generic extractor for the fields that the profile does not select.
+//     Pages with news article markup are indexed under the news vertical.
+//   - Optionally, generate an extractive summary of the text content.
+//   - Optionally, detect pages whose content is a near-duplicate of a
+//     previously crawled page.
+//   - Optionally, capture the favicon for the page host and a thumbnail of
+//     the page.
 //   - Update the link graph: add new links and create edges between the crawled
-//     page and the links within it.
-//   - Index crawled page title and text content.
+//     page and the links within it. The crawled page is scheduled for its
+//     next fetch using the RecrawlPolicy, if one is configured.
+//   - Index crawled page title and text content unless the page declares a
+//     different canonical URL or is a noindex page. For near-duplicate pages only a reference to
+//     the canonical page is indexed.
+//
+// Each crawl pass is traced by a "crawler.pass" span. Links are traced
+// separately by a "crawler.link" span which is linked to the pass span and
+// contains a child span for each stage that the link went through. The
+// duration and outcome of each stage are attached to the errors reported to
+// the ErrorRecorder or, if the link is crawled without errors, passed to the
+// ProvenanceRecorder.
 type Crawler struct {
-	p *pipeline.Pipeline
+	p                *pipeline.Pipeline
+	passID           uint64
+	adaptiveTimeouts *AdaptiveTimeouts
+	shutdown         *ShutdownCoordinator
+	provenance       ProvenanceRecorder
 }
 
 // NewCrawler returns a new crawler instance.
 func NewCrawler(cfg Config) *Crawler {
 	return &Crawler{
-		p: assembleCrawlerPipeline(cfg),
+		p:                assembleCrawlerPipeline(cfg),
+		passID:           cfg.PassID,
+		adaptiveTimeouts: cfg.AdaptiveTimeouts,
+		shutdown:         cfg.Shutdown,
+		provenance:       cfg.Provenance,
 	}
 }
 
 // assembleCrawlerPipeline creates the various stages of a crawler pipeline
 // using the options in cfg and assembles them into a pipeline instance.
 func assembleCrawlerPipeline(cfg Config) *pipeline.Pipeline {
-	return pipeline.New(
+	rewriter := newURLRewriter(cfg.URLRewriteRules)
+	errs := &errorReporter{recorder: cfg.Errors, failures: cfg.Failures, passID: cfg.PassID}
+	fetcher := cfg.Fetcher
+	if fetcher == nil {
+		fetcher = getterFetcher{getter: cfg.URLGetter}
+	}
+	stages := []pipeline.StageRunner{
 		pipeline.FixedWorkerPool(
-			newLinkFetcher(cfg.URLGetter, cfg.PrivateNetworkDetector),
+			traced("link_fetcher", newLinkFetcher(fetcher, cfg.PrivateNetworkDetector, cfg.Robots, cfg.Pacer, cfg.HeaderRules, cfg.StructuredSources, rewriter, cfg.MaxBodySize, errs, cfg.Logger)),
 			cfg.FetchWorkers,
 		),
-		pipeline.FIFO(newLinkExtractor(cfg.PrivateNetworkDetector)),
-		pipeline.FIFO(newTextExtractor()),
-		pipeline.Broadcast(
-			newGraphUpdater(cfg.Graph),
-			newTextIndexer(cfg.Indexer),
-		),
+	}
+	if cfg.Archiver != nil {
+		stages = append(stages, pipeline.FIFO(traced("archiver", newResponseArchiver(cfg.Archiver, cfg.Logger))))
+	}
+	if !cfg.IgnoreRobotsDirectives {
+		stages = append(stages, pipeline.FIFO(traced("robots_directives", newRobotsDirectiveParser(cfg.Logger))))
+	}
+	if len(cfg.StructuredSources) != 0 {
+		stages = append(stages, pipeline.FIFO(traced("structured_adapter", newStructuredAdapter(cfg.PrivateNetworkDetector, cfg.StructuredSources, rewriter, cfg.URLNormalizer, cfg.Scope, cfg.Logger))))
+	}
+	stages = append(stages,
+		pipeline.FIFO(traced("document_adapter", newDocumentAdapter(cfg.PrivateNetworkDetector, rewriter, cfg.URLNormalizer, cfg.Scope, cfg.Logger))),
+		pipeline.FIFO(traced("link_extractor", newLinkExtractor(cfg.PrivateNetworkDetector, rewriter, cfg.URLNormalizer, cfg.Scope, cfg.ExtractResourceLinks))),
+		pipeline.FIFO(traced("text_extractor", newTextExtractor(cfg.ExtractionProfiles, cfg.Logger))),
 	)
+	if cfg.SummarySentences > 0 {
+		stages = append(stages, pipeline.FIFO(traced("summarizer", newSummarizer(cfg.SummarySentences))))
+	}
+	if cfg.Deduplicator != nil {
+		stages = append(stages, pipeline.FIFO(traced("dedup_checker", newDedupChecker(cfg.Deduplicator, cfg.Logger))))
+	}
+
+	if cfg.BlobStore != nil && (cfg.CaptureFavicons || cfg.Screenshotter != nil) {
+		// Capturing requires additional network requests so it is
+		// performed by the same number of workers as fetching.
+		stages = append(stages, pipeline.FixedWorkerPool(
+			traced("media_capturer", newMediaCapturer(cfg)),
+			cfg.FetchWorkers,
+		))
+	}
+
+	stages = append(stages, pipeline.Broadcast(
+		traced("graph_updater", newGraphUpdater(cfg.Graph, cfg.RecrawlPolicy, cfg.PassID, errs, cfg.Logger)),
+		traced("text_indexer", newTextIndexer(cfg.Indexer, errs, cfg.Logger)),
+	))
+	return pipeline.New(stages...)
 }
 
 // Crawl iterates linkIt and sends each link through the crawler pipeline
@@ -106,17 +477,98 @@ func assembleCrawlerPipeline(cfg Config) *pipeline.Pipeline {
 // Crawl block until the link iterator is exhausted, an error occurs or the
 // context is cancelled.
 func (c *Crawler) Crawl(ctx context.Context, linkIt graph.LinkIterator) (int, error) {
-	sink := new(countingSink)
-	err := c.p.Process(ctx, &linkSource{linkIt: linkIt}, sink)
-	return sink.getCount(), err
+	report, err := c.CrawlWithBudget(ctx, linkIt, Budget{})
+	return report.Processed, err
+}
+
+// CrawlWithBudget behaves like Crawl but stops feeding new links into the
+// pipeline once any of the limits in budget is exceeded or a shutdown is
+// requested via the crawler's ShutdownCoordinator. Links that are
+// already in flight are allowed to drain and the remaining links from linkIt
+// are recorded as deferred in the returned report.
+func (c *Crawler) CrawlWithBudget(ctx context.Context, linkIt graph.LinkIterator, budget Budget) (report *Report, err error) {
+	ctx, span := tracer.Start(ctx, "crawler.pass")
+	span.SetAttributes(attribute.Int64("crawler.pass_id", int64(c.passID)))
+	defer func() {
+		if report != nil {
+			span.SetAttributes(
+				attribute.Int("crawler.processed", report.Processed),
+				attribute.Int64("crawler.bytes_fetched", report.BytesFetched),
+			)
+		}
+		tracing.End(span, err)
+	}()
+
+	var (
+		startedAt = time.Now()
+		tracker   = newBudgetTracker(budget)
+		source    = &linkSource{linkIt: linkIt, tracker: tracker, shutdown: c.shutdown, provenance: c.provenance, passID: c.passID}
+		sink      = new(countingSink)
+		latencies *hostLatencies
+	)
+
+	ctx = withBudgetTracker(ctx, tracker)
+	if c.adaptiveTimeouts != nil {
+		// Host latencies are tracked separately for each pass.
+		timeoutCfg := *c.adaptiveTimeouts
+		if err := timeoutCfg.validate(); err != nil {
+			return nil, fmt.Errorf("crawl: invalid adaptive timeout settings: %w", err)
+		}
+		latencies = newHostLatencies(timeoutCfg)
+		ctx = withHostLatencies(ctx, latencies)
+	}
+
+	err = c.p.Process(ctx, source, sink)
+	report = &Report{
+		Processed:    sink.getCount(),
+		BytesFetched: tracker.bytesFetched(),
+		Status:       source.status,
+	}
+	if latencies != nil {
+		report.QuarantinedHosts = latencies.quarantinedHosts()
+	}
+
+	// Mark any links that were not sent through the pipeline as deferred.
+	if err == nil && report.Status != BudgetNotExceeded {
+		for linkIt.Next() {
+			report.Deferred = append(report.Deferred, linkIt.Link().ID)
+		}
+		err = linkIt.Error()
+	}
+
+	report.Elapsed = time.Since(startedAt)
+	return report, err
 }
 
 type linkSource struct {
-	linkIt graph.LinkIterator
+	linkIt   graph.LinkIterator
+	tracker  *budgetTracker
+	shutdown *ShutdownCoordinator
+	status   BudgetStatus
+
+	// The recorder for the provenance of each link and the ID of the
+	// crawl pass.
+	provenance ProvenanceRecorder
+	passID     uint64
+
+	// The context of the crawl pass; used as the parent of link traces.
+	ctx context.Context
 }
 
-func (ls *linkSource) Error() error              { return ls.linkIt.Error() }
-func (ls *linkSource) Next(context.Context) bool { return ls.linkIt.Next() }
+func (ls *linkSource) Error() error { return ls.linkIt.Error() }
+func (ls *linkSource) Next(ctx context.Context) bool {
+	ls.ctx = ctx
+	if ls.shutdown.draining() {
+		ls.status = CrawlInterrupted
+		return false
+	}
+	if ls.tracker != nil {
+		if ls.status = ls.tracker.status(); ls.status != BudgetNotExceeded {
+			return false
+		}
+	}
+	return ls.linkIt.Next()
+}
 func (ls *linkSource) Payload() pipeline.Payload {
 	link := ls.linkIt.Link()
 	p := payloadPool.Get().(*crawlerPayload)
@@ -124,6 +576,12 @@ func (ls *linkSource) Payload() pipeline.Payload {
 	p.LinkID = link.ID
 	p.URL = link.URL
 	p.RetrievedAt = link.RetrievedAt
+	p.PrevContentHash = link.ContentHash
+	p.trace = startLinkTrace(ls.ctx, link.ID, link.URL)
+	p.trace.recorder, p.trace.passID = ls.provenance, ls.passID
+	if link.RetrievedAt != 0 {
+		metrics.FrontierAge.Observe(time.Since(time.Unix(link.RetrievedAt, 0)).Seconds())
+	}
 	return p
 }
 
@@ -36,7 +36,7 @@ var (
 	  <a href="/absolute/path">I am an absolute link</a>
 	  <a href="//images/cart.png">I am using the same URL scheme as this page</a>
 	  
-	  <!-- Link should be added to the index but without creating an edge to it -->
+	  <!-- Link should be added to the index with an edge that is flagged as nofollow -->
 	  <a href="ignore-me" rel="nofollow"/>
 
 	  <!-- The following links should be ignored -->
@@ -0,0 +1,96 @@
+package dedup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(DedupTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+const article = `Publius Ovidius Naso was a Roman poet who lived during the reign
+of Augustus. He was a contemporary of the older Virgil and Horace, with whom
+he is often ranked as one of the three canonical poets of Latin literature.
+The Imperial scholar Quintilian considered him the last of the Latin love
+elegists. Although Ovid enjoyed enormous popularity during his lifetime, the
+emperor Augustus banished him to Tomis, a remote province on the Black Sea,
+where he remained until his death. Ovid himself attributed his exile to a
+poem and a mistake, but his reticence has led to much speculation among
+scholars.`
+
+type DedupTestSuite struct{}
+
+func (s *DedupTestSuite) TestFingerprint(c *gc.C) {
+	// Fingerprints are only stable for pages with a reasonable amount of
+	// text.
+	page := strings.Repeat(article+"\n", 8)
+	fp := Fingerprint(page)
+	c.Assert(fp, gc.Not(gc.Equals), uint64(0))
+
+	// Case, punctuation and whitespace do not affect the fingerprint.
+	c.Assert(Fingerprint("  "+strings.ToUpper(page)+"!!"), gc.Equals, fp)
+
+	// Small edits yield nearby fingerprints.
+	edited := strings.Replace(page, "enormous", "great", 1) + " Home | About | Contact"
+	c.Assert(Distance(fp, Fingerprint(edited)) <= DefaultDistance, gc.Equals, true, gc.Commentf("distance %d", Distance(fp, Fingerprint(edited))))
+
+	// Unrelated texts yield distant fingerprints.
+	other := `The quick brown fox jumps over the lazy dog while the farmer
+watches from the porch of the old barn and wonders whether it will rain
+before the harvest is brought in from the fields next to the river.`
+	c.Assert(Distance(fp, Fingerprint(other)) > DefaultDistance, gc.Equals, true)
+
+	// Texts that are too short are not fingerprinted.
+	c.Assert(Fingerprint("404 page not found"), gc.Equals, uint64(0))
+}
+
+func (s *DedupTestSuite) TestIndex(c *gc.C) {
+	idx, err := NewIndex(2)
+	c.Assert(err, gc.IsNil)
+
+	orig, dup, other := uuid.New(), uuid.New(), uuid.New()
+	fp := uint64(0xdeadbeefcafef00d)
+
+	canonical, err := idx.Canonical(orig, fp)
+	c.Assert(err, gc.IsNil)
+	c.Assert(canonical, gc.Equals, orig)
+
+	// Fingerprints within the distance threshold map to the original.
+	canonical, err = idx.Canonical(dup, fp^0x8001)
+	c.Assert(err, gc.IsNil)
+	c.Assert(canonical, gc.Equals, orig)
+
+	// Fingerprints beyond the threshold are registered as canonical.
+	canonical, err = idx.Canonical(other, fp^0x8000800080000000)
+	c.Assert(err, gc.IsNil)
+	c.Assert(canonical, gc.Equals, other)
+	c.Assert(idx.Len(), gc.Equals, 2)
+
+	// Re-submitting a canonical document leaves it canonical.
+	canonical, err = idx.Canonical(orig, fp)
+	c.Assert(err, gc.IsNil)
+	c.Assert(canonical, gc.Equals, orig)
+
+	// Once the original content changes, the duplicate becomes canonical.
+	canonical, err = idx.Canonical(orig, ^fp)
+	c.Assert(err, gc.IsNil)
+	c.Assert(canonical, gc.Equals, orig)
+	canonical, err = idx.Canonical(dup, fp^0x8001)
+	c.Assert(err, gc.IsNil)
+	c.Assert(canonical, gc.Equals, dup)
+
+	idx.Remove(dup)
+	idx.Remove(uuid.New())
+	c.Assert(idx.Len(), gc.Equals, 2)
+}
+
+func (s *DedupTestSuite) TestNewIndexValidation(c *gc.C) {
+	_, err := NewIndex(MaxDistance + 1)
+	c.Assert(err, gc.ErrorMatches, "dedup index: distance must be between 0 and 3 .*")
+	_, err = NewIndex(-1)
+	c.Assert(err, gc.NotNil)
+}
@@ -0,0 +1,132 @@
+package dedup
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// The number of 16-bit bands that fingerprints are split into for looking up
+// candidate duplicates. Two fingerprints that differ in at most numBands-1
+// bits share at least one band.
+const numBands = 4
+
+// MaxDistance is the largest Hamming distance that an Index can detect.
+const MaxDistance = numBands - 1
+
+// DefaultDistance is the default Hamming distance threshold for reporting
+// two documents as near-duplicates.
+const DefaultDistance = 3
+
+// Index keeps the fingerprints of the canonical documents that have been
+// seen so far and looks up the near-duplicates of new documents. It is safe
+// for concurrent use.
+//
+// The index is kept in memory. After a restart it is repopulated as pages
+// are crawled again.
+type Index struct {
+	maxDistance int
+
+	mu           sync.Mutex
+	fingerprints map[uuid.UUID]uint64
+	bands        [numBands]map[uint16][]uuid.UUID
+}
+
+// NewIndex returns a new, empty Index that reports documents whose
+// fingerprints differ in at most maxDistance bits as duplicates.
+func NewIndex(maxDistance int) (*Index, error) {
+	if maxDistance < 0 || maxDistance > MaxDistance {
+		return nil, fmt.Errorf("dedup index: distance must be between 0 and %d (got %d)", MaxDistance, maxDistance)
+	}
+
+	idx := &Index{
+		maxDistance:  maxDistance,
+		fingerprints: make(map[uuid.UUID]uint64),
+	}
+	for i := range idx.bands {
+		idx.bands[i] = make(map[uint16][]uuid.UUID)
+	}
+	return idx, nil
+}
+
+// Canonical returns the ID of the canonical document whose fingerprint is
+// closest to the fingerprint of the document for linkID. If there is no
+// such document within the distance threshold, the document is registered
+// as canonical and linkID is returned.
+//
+// The previously registered fingerprint for linkID (if any) is replaced so
+// that documents whose content changed are re-evaluated.
+func (idx *Index) Canonical(linkID uuid.UUID, fingerprint uint64) (uuid.UUID, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if old, exists := idx.fingerprints[linkID]; exists {
+		if old == fingerprint {
+			return linkID, nil
+		}
+		idx.remove(linkID, old)
+	}
+
+	var (
+		bestID   uuid.UUID
+		bestDist = idx.maxDistance + 1
+	)
+	for band := 0; band < numBands; band++ {
+		for _, candidateID := range idx.bands[band][bandOf(fingerprint, band)] {
+			if dist := Distance(fingerprint, idx.fingerprints[candidateID]); dist < bestDist {
+				bestID, bestDist = candidateID, dist
+			}
+		}
+	}
+	if bestID != uuid.Nil {
+		return bestID, nil
+	}
+
+	idx.fingerprints[linkID] = fingerprint
+	for band := 0; band < numBands; band++ {
+		key := bandOf(fingerprint, band)
+		idx.bands[band][key] = append(idx.bands[band][key], linkID)
+	}
+	return linkID, nil
+}
+
+// Remove drops the fingerprint registered for linkID.
+func (idx *Index) Remove(linkID uuid.UUID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if fingerprint, exists := idx.fingerprints[linkID]; exists {
+		idx.remove(linkID, fingerprint)
+	}
+}
+
+// Len returns the number of canonical documents in the index.
+func (idx *Index) Len() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return len(idx.fingerprints)
+}
+
+func (idx *Index) remove(linkID uuid.UUID, fingerprint uint64) {
+	delete(idx.fingerprints, linkID)
+	for band := 0; band < numBands; band++ {
+		key := bandOf(fingerprint, band)
+		ids := idx.bands[band][key]
+		for i, id := range ids {
+			if id == linkID {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(idx.bands[band], key)
+		} else {
+			idx.bands[band][key] = ids
+		}
+	}
+}
+
+// bandOf returns the 16-bit band with the specified index of fingerprint.
+func bandOf(fingerprint uint64, band int) uint16 {
+	return uint16(fingerprint >> (16 * band))
+}
@@ -0,0 +1,73 @@
+// Package dedup detects pages whose content is a near-duplicate of a
+// previously crawled page (e.g. mirrors or URL aliases that were not mapped
+// to a single URL).
+//
+// The content of each page is reduced to a 64-bit SimHash fingerprint. The
+// fingerprints of near-duplicate documents differ in only a few bits so two
+// documents are considered duplicates if the Hamming distance between their
+// fingerprints does not exceed a configurable threshold.
+package dedup
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// MinWords is the minimum number of words that a text must contain for a
+// fingerprint to be computed. Shorter texts (e.g. error or placeholder
+// pages) would be reported as duplicates of each other.
+const MinWords = 10
+
+// The number of consecutive words that make up each feature of a text.
+const shingleSize = 3
+
+// Fingerprint returns the SimHash fingerprint of text. Each feature of the
+// text is a sequence of shingleSize consecutive lower-cased words. A zero
+// fingerprint is returned if text contains less than MinWords words.
+func Fingerprint(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) < MinWords {
+		return 0
+	}
+
+	var (
+		weights [64]int
+		h       = fnv.New64a()
+	)
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h.Reset()
+		for j, word := range words[i : i+shingleSize] {
+			if j != 0 {
+				_, _ = h.Write([]byte{' '})
+			}
+			_, _ = h.Write([]byte(word))
+		}
+
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fp uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fp |= 1 << bit
+		}
+	}
+	return fp
+}
+
+// Distance returns the number of bits that differ between fingerprints a and
+// b.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
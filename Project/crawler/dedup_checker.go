@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"context"
+	"log/slog"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+	"webcrawler/pipeline"
+
+	"github.com/google/uuid"
+)
+
+var _ pipeline.Processor = (*dedupChecker)(nil)
+
+// dedupChecker flags payloads whose content is a near-duplicate of a
+// previously crawled page so that only a reference to that page is indexed.
+type dedupChecker struct {
+	dedup  Deduplicator
+	logger *slog.Logger
+}
+
+func newDedupChecker(dedup Deduplicator, logger *slog.Logger) *dedupChecker {
+	return &dedupChecker{
+		dedup:  dedup,
+		logger: logging.Component(logger, "crawler.dedup_checker"),
+	}
+}
+
+func (dc *dedupChecker) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+
+	// Pages that are too short to be fingerprinted are always indexed.
+	if payload.Fingerprint == 0 {
+		return payload, nil
+	}
+
+	canonicalID, err := dc.dedup.Canonical(payload.LinkID, payload.Fingerprint)
+	if err != nil {
+		// Duplicate detection is best-effort; index the page as usual.
+		dc.logger.Warn("unable to check for duplicate content", logging.Link(payload.LinkID, payload.URL), "err", err)
+		return payload, nil
+	}
+	if canonicalID != payload.LinkID && canonicalID != uuid.Nil {
+		payload.DuplicateOf = canonicalID
+		metrics.DuplicatePages.Inc()
+		dc.logger.Debug("detected near-duplicate content", logging.Link(payload.LinkID, payload.URL), "duplicate_of", canonicalID.String())
+	}
+	return payload, nil
+}
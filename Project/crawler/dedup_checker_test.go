@@ -0,0 +1,34 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+	"webcrawler/crawler/dedup"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(DedupCheckerTestSuite))
+
+type DedupCheckerTestSuite struct{}
+
+func (s *DedupCheckerTestSuite) TestDedupChecker(c *gc.C) {
+	idx, err := dedup.NewIndex(dedup.DefaultDistance)
+	c.Assert(err, gc.IsNil)
+	checker := newDedupChecker(idx, nil)
+
+	content := strings.Repeat("Ovidius poeta in terra pontica exsul vixit et carmina tristia scripsit. ", 20)
+	orig := &crawlerPayload{LinkID: uuid.New(), URL: "http://example.com/ovid", Fingerprint: dedup.Fingerprint(content)}
+	mirror := &crawlerPayload{LinkID: uuid.New(), URL: "http://mirror.com/ovid", Fingerprint: dedup.Fingerprint(content + " Mirror")}
+	short := &crawlerPayload{LinkID: uuid.New(), URL: "http://example.com/404"}
+
+	for _, p := range []*crawlerPayload{orig, mirror, short} {
+		out, err := checker.Process(context.TODO(), p)
+		c.Assert(err, gc.IsNil)
+		c.Assert(out, gc.Equals, p)
+	}
+	c.Assert(orig.DuplicateOf, gc.Equals, uuid.Nil)
+	c.Assert(mirror.DuplicateOf, gc.Equals, orig.LinkID)
+	c.Assert(short.DuplicateOf, gc.Equals, uuid.Nil)
+}
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/url"
+	"webcrawler/crawler/dedup"
+	"webcrawler/crawler/feed"
+	"webcrawler/crawler/pdftext"
+	"webcrawler/crawler/scope"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+	"webcrawler/pipeline"
+	"webcrawler/urlutil/normalizer"
+)
+
+var _ pipeline.Processor = (*documentAdapter)(nil)
+
+// documentAdapter populates the title, content and links of PDF documents and
+// news feeds and routes them to the documents and news search verticals
+// respectively. HTML and structured payloads are passed through unchanged.
+type documentAdapter struct {
+	// Used for filtering the links of feed items using the same rules as
+	// the links extracted from HTML pages.
+	linkFilter *linkExtractor
+
+	logger *slog.Logger
+}
+
+func newDocumentAdapter(netDetector PrivateNetworkDetector, rewriter *urlRewriter, urlNormalizer *normalizer.Normalizer, crawlScope *scope.Scope, logger *slog.Logger) *documentAdapter {
+	return &documentAdapter{
+		linkFilter: newLinkExtractor(netDetector, rewriter, urlNormalizer, crawlScope, false),
+		logger:     logging.Component(logger, "crawler.document_adapter"),
+	}
+}
+
+func (da *documentAdapter) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	var ok bool
+	switch payload.Format {
+	case formatPDF:
+		ok = da.adaptPDF(payload)
+	case formatFeed:
+		ok = da.adaptFeed(payload)
+	default:
+		return payload, nil
+	}
+	if !ok {
+		return nil, nil
+	}
+	return payload, nil
+}
+
+// adaptPDF extracts the title and text of a PDF document. It returns false if
+// the text of the document cannot be extracted.
+func (da *documentAdapter) adaptPDF(payload *crawlerPayload) bool {
+	res, err := pdftext.Extract(payload.RawContent.Bytes())
+	if err != nil {
+		da.logger.Warn("skipping PDF document with unsupported content", logging.Link(payload.LinkID, payload.URL), "err", err)
+		return false
+	}
+
+	payload.Title = res.Title
+	payload.TextContent = res.Text
+	payload.Fingerprint = dedup.Fingerprint(payload.TextContent)
+	payload.Vertical = index.VerticalDocuments
+	return true
+}
+
+// adaptFeed indexes the title and item summaries of an RSS or Atom feed and
+// adds the item links to the payload so that the full articles get crawled.
+// It returns false if the response is not a feed.
+func (da *documentAdapter) adaptFeed(payload *crawlerPayload) bool {
+	base := payload.URL
+	if payload.BaseURL != "" {
+		base = payload.BaseURL
+	}
+	relTo, err := url.Parse(base)
+	if err != nil {
+		return false
+	}
+
+	f, err := feed.Parse(bytes.NewReader(payload.RawContent.Bytes()))
+	if err != nil {
+		da.logger.Debug("skipping XML response that is not a news feed", logging.Link(payload.LinkID, payload.URL), "err", err)
+		return false
+	}
+
+	payload.Title = f.Title
+	payload.TextContent = f.Text()
+	payload.Fingerprint = dedup.Fingerprint(payload.TextContent)
+	payload.Vertical = index.VerticalNews
+
+	if payload.NoFollow {
+		return true
+	}
+	seenMap := make(map[string]struct{})
+	for _, item := range f.Items {
+		if link, ok := da.linkFilter.filterLink(relTo, item.Link, seenMap); ok {
+			payload.Links = append(payload.Links, link)
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package crawler
+
+import (
+	"context"
+	"webcrawler/crawler/mocks"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/golang/mock/gomock"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(DocumentAdapterTestSuite))
+
+type DocumentAdapterTestSuite struct {
+	privNetDetector *mocks.MockPrivateNetworkDetector
+}
+
+const testPDF = `%PDF-1.4
+1 0 obj << /Title (Annual Report 2023) >> endobj
+2 0 obj << /Length 58 >>
+stream
+BT /F1 12 Tf 72 712 Td (Revenue grew) Tj T* (by 12%.) Tj ET
+endstream
+endobj
+trailer << /Info 1 0 R >>
+%%EOF`
+
+func (s *DocumentAdapterTestSuite) TestPDF(c *gc.C) {
+	p := s.adapt(c, "http://example.com/report.pdf", formatPDF, testPDF)
+	c.Assert(p, gc.NotNil)
+	c.Assert(p.Title, gc.Equals, "Annual Report 2023")
+	c.Assert(p.TextContent, gc.Equals, "Revenue grew by 12%.")
+	c.Assert(p.Vertical, gc.Equals, index.VerticalDocuments)
+
+	// Documents whose text cannot be extracted are skipped.
+	p = s.adapt(c, "http://example.com/report.pdf", formatPDF, "<html></html>")
+	c.Assert(p, gc.IsNil)
+}
+
+func (s *DocumentAdapterTestSuite) TestFeed(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.privNetDetector.EXPECT().IsPrivate("other.com").Return(false, nil)
+
+	p := s.adapt(c, "http://example.com/feed.xml", formatFeed, `<rss version="2.0"><channel>
+  <title>Example News</title>
+  <item><title>Rain expected</title><link>/news/rain</link><description>Bring an umbrella</description></item>
+  <item><title>Rain expected (update)</title><link>/news/rain#update</link></item>
+  <item><title>Elsewhere</title><link>http://other.com/story</link></item>
+  <item><title>Photo</title><link>/photo.jpg</link></item>
+</channel></rss>`)
+	c.Assert(p, gc.NotNil)
+	c.Assert(p.Title, gc.Equals, "Example News")
+	c.Assert(p.TextContent, gc.Equals, "Example News Rain expected Bring an umbrella Rain expected (update) Elsewhere Photo")
+	c.Assert(p.Vertical, gc.Equals, index.VerticalNews)
+	c.Assert(p.Links, gc.DeepEquals, []string{
+		"http://example.com/news/rain",
+		"http://other.com/story",
+	})
+
+	// XML responses that are not feeds are skipped.
+	p = s.adapt(c, "http://example.com/sitemap.xml", formatFeed, `<urlset><url><loc>http://example.com/</loc></url></urlset>`)
+	c.Assert(p, gc.IsNil)
+}
+
+func (s *DocumentAdapterTestSuite) TestSkipOtherFormats(c *gc.C) {
+	p := s.adapt(c, "http://example.com/", formatHTML, `<html><title>foo</title></html>`)
+	c.Assert(p, gc.NotNil)
+	c.Assert(p.Title, gc.Equals, "")
+	c.Assert(p.Vertical, gc.Equals, "")
+}
+
+func (s *DocumentAdapterTestSuite) adapt(c *gc.C, url string, format contentFormat, body string) *crawlerPayload {
+	payload := &crawlerPayload{URL: url, Format: format}
+	payload.RawContent.WriteString(body)
+
+	out, err := newDocumentAdapter(s.privNetDetector, nil, nil, nil, nil).Process(context.TODO(), payload)
+	c.Assert(err, gc.IsNil)
+	if out != nil {
+		c.Assert(out, gc.Equals, payload)
+		return out.(*crawlerPayload)
+	}
+
+	return nil
+}
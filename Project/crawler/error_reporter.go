@@ -0,0 +1,33 @@
+package crawler
+
+import "webcrawler/crawler/errstore"
+
+// errorReporter reports the errors encountered by the pipeline stages of a
+// crawl pass to an optional ErrorRecorder and the fetch failures to an
+// optional FailureTracker. A nil errorReporter discards all errors.
+type errorReporter struct {
+	recorder ErrorRecorder
+	failures FailureTracker
+	passID   uint64
+}
+
+// report records an error of the specified class encountered by stage while
+// processing p. The event carries the provenance of the link up to the
+// failing stage and no success provenance is recorded for the link.
+func (r *errorReporter) report(stage string, p *crawlerPayload, class errstore.Class, err error) {
+	stages := p.trace.fail(stage, p.stageStartedAt)
+	if r == nil {
+		return
+	}
+	if r.failures != nil && stage == fetcherStage {
+		r.failures.LinkFailed(p.LinkID, p.URL, class, err)
+	}
+	if r.recorder == nil {
+		return
+	}
+	e := errstore.Event{PassID: r.passID, URL: p.URL, Stage: stage, Class: class, Stages: stages}
+	if err != nil {
+		e.Message = err.Error()
+	}
+	r.recorder.Record(e)
+}
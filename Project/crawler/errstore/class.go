@@ -0,0 +1,110 @@
+package errstore
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Class describes the type of an error encountered while crawling a link.
+type Class string
+
+// The supported error classes.
+const (
+	// ClassDNS indicates that the host of the link could not be resolved.
+	ClassDNS Class = "dns"
+
+	// ClassTimeout indicates that the request or the response body did not
+	// complete in time.
+	ClassTimeout Class = "timeout"
+
+	// ClassConnection indicates that the connection to the host was
+	// refused or reset.
+	ClassConnection Class = "connection"
+
+	// ClassTLS indicates a TLS handshake or certificate verification
+	// failure.
+	ClassTLS Class = "tls"
+
+	// ClassHTTPRedirect, ClassHTTPClient and ClassHTTPServer indicate that
+	// the host responded with a 3xx, 4xx or 5xx status code respectively.
+	ClassHTTPRedirect Class = "http_3xx"
+	ClassHTTPClient   Class = "http_4xx"
+	ClassHTTPServer   Class = "http_5xx"
+
+	// ClassRobots indicates that the robots.txt policy of the host could
+	// not be retrieved.
+	ClassRobots Class = "robots"
+
+	// ClassEncoding indicates that the response body uses an unsupported
+	// or malformed content encoding.
+	ClassEncoding Class = "encoding"
+
+	// ClassTooLarge indicates that the response body exceeded the size
+	// limit.
+	ClassTooLarge Class = "too_large"
+
+	// ClassGraph and ClassIndex indicate that the crawled link could not
+	// be written to the link graph or the text index respectively.
+	ClassGraph Class = "graph"
+	ClassIndex Class = "index"
+
+	// ClassOther is used for errors that do not fit any other class.
+	ClassOther Class = "other"
+)
+
+// Dead returns true if errors of class c indicate a dead link, i.e. one whose
+// host name does not resolve or whose server rejects it with a 4xx status
+// code, rather than a transient failure.
+func (c Class) Dead() bool {
+	return c == ClassDNS || c == ClassHTTPClient
+}
+
+// Classify returns the class of a network error returned while fetching a
+// link.
+func Classify(err error) Class {
+	var (
+		dnsErr     *net.DNSError
+		netErr     net.Error
+		certErr    *tls.CertificateVerificationError
+		recordErr  tls.RecordHeaderError
+		alertErr   tls.AlertError
+		unknownCA  x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		invalidErr x509.CertificateInvalidError
+	)
+	switch {
+	case err == nil:
+		return ClassOther
+	case errors.As(err, &dnsErr):
+		return ClassDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ClassTimeout
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &unknownCA), errors.As(err, &hostErr), errors.As(err, &invalidErr):
+		return ClassTLS
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH):
+		return ClassConnection
+	default:
+		return ClassOther
+	}
+}
+
+// ClassifyStatus returns the class of a response with a non-2xx status code.
+func ClassifyStatus(statusCode int) Class {
+	switch {
+	case statusCode >= 300 && statusCode < 400:
+		return ClassHTTPRedirect
+	case statusCode >= 400 && statusCode < 500:
+		return ClassHTTPClient
+	case statusCode >= 500 && statusCode < 600:
+		return ClassHTTPServer
+	default:
+		return ClassOther
+	}
+}
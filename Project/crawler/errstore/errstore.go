@@ -0,0 +1,294 @@
+// Package errstore keeps track of the errors encountered by the crawler so
+// that operators can find out why links are failing without trawling through
+// the logs. Errors are aggregated by crawl pass, URL, pipeline stage and
+// error class and can be queried by host and pass.
+package errstore
+
+import (
+	"container/list"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// The maximum length of a recorded error message. Longer messages are
+// truncated.
+const maxMessageLen = 512
+
+// Event describes an error encountered while crawling a link.
+type Event struct {
+	// The ID of the crawl pass that encountered the error.
+	PassID uint64
+
+	// The URL of the link.
+	URL string
+
+	// The name of the pipeline stage that encountered the error (e.g.
+	// "link_fetcher").
+	Stage string
+
+	// The error class.
+	Class Class
+
+	// The error message.
+	Message string
+
+	// The stages that processed the link before the error was
+	// encountered, followed by the failing stage.
+	Stages []StageRecord
+}
+
+// StageOutcome describes how a pipeline stage finished processing a link.
+type StageOutcome string
+
+// The supported stage outcomes.
+const (
+	// StageOK indicates that the stage passed the link to the next
+	// stage.
+	StageOK StageOutcome = "ok"
+
+	// StageDropped indicates that the stage stopped processing the link
+	// (e.g. because its content is not supported).
+	StageDropped StageOutcome = "dropped"
+
+	// StageFailed indicates that the stage encountered an error.
+	StageFailed StageOutcome = "failed"
+)
+
+// StageRecord describes the processing of a link by a pipeline stage.
+type StageRecord struct {
+	Stage   string       `json:"stage"`
+	Outcome StageOutcome `json:"outcome"`
+
+	// The time spent in the stage in nanoseconds.
+	Duration time.Duration `json:"duration"`
+}
+
+// Record aggregates the events for a URL that share the same pass, stage
+// and error class.
+type Record struct {
+	PassID    uint64    `json:"passID"`
+	URL       string    `json:"url"`
+	Host      string    `json:"host"`
+	Stage     string    `json:"stage"`
+	Class     Class     `json:"class"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+
+	// The message of the most recent event.
+	LastMessage string `json:"lastMessage"`
+
+	// The stages that processed the link in the most recent event.
+	Stages []StageRecord `json:"stages,omitempty"`
+}
+
+// ClassSummary summarizes the records of an error class that match a query.
+type ClassSummary struct {
+	Class Class `json:"class"`
+
+	// The number of events.
+	Count int `json:"count"`
+
+	// The number of distinct URLs and hosts that the events refer to.
+	URLs  int `json:"urls"`
+	Hosts int `json:"hosts"`
+
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Filter selects the records that are considered by a query. Zero-valued
+// fields match all records.
+type Filter struct {
+	Host   string
+	PassID uint64
+	Stage  string
+	Class  Class
+}
+
+func (f Filter) matches(r *Record) bool {
+	return (f.Host == "" || strings.EqualFold(f.Host, r.Host)) &&
+		(f.PassID == 0 || f.PassID == r.PassID) &&
+		(f.Stage == "" || f.Stage == r.Stage) &&
+		(f.Class == "" || f.Class == r.Class)
+}
+
+// Config encapsulates the settings for a Store.
+type Config struct {
+	// The maximum number of records to keep. Once the limit is reached,
+	// the least recently seen records are evicted. Defaults to 10000.
+	MaxRecords int
+
+	// A clock for timestamping events. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.MaxRecords < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid max records value"))
+	} else if cfg.MaxRecords == 0 {
+		cfg.MaxRecords = 10000
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+type recordKey struct {
+	passID uint64
+	url    string
+	stage  string
+	class  Class
+}
+
+// Store is an in-memory store for crawl errors. It is safe for concurrent
+// use.
+type Store struct {
+	cfg Config
+
+	mu      sync.Mutex
+	records map[recordKey]*list.Element
+
+	// The records ordered from the most to the least recently seen.
+	lru *list.List
+}
+
+// NewStore returns a new error store using the provided config.
+func NewStore(cfg Config) (*Store, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("error store: config validation failed: %w", err)
+	}
+	return &Store{
+		cfg:     cfg,
+		records: make(map[recordKey]*list.Element),
+		lru:     list.New(),
+	}, nil
+}
+
+// Record adds an event to the store.
+func (s *Store) Record(e Event) {
+	now := s.cfg.Clock()
+	if len(e.Message) > maxMessageLen {
+		e.Message = e.Message[:maxMessageLen]
+	}
+	if e.Class == "" {
+		e.Class = ClassOther
+	}
+	key := recordKey{passID: e.PassID, url: e.URL, stage: e.Stage, class: e.Class}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, found := s.records[key]; found {
+		r := el.Value.(*Record)
+		r.Count++
+		r.LastSeen = now
+		r.LastMessage = e.Message
+		r.Stages = e.Stages
+		s.lru.MoveToFront(el)
+		return
+	}
+
+	if s.lru.Len() >= s.cfg.MaxRecords {
+		oldest := s.lru.Back()
+		r := s.lru.Remove(oldest).(*Record)
+		delete(s.records, recordKey{passID: r.PassID, url: r.URL, stage: r.Stage, class: r.Class})
+	}
+	s.records[key] = s.lru.PushFront(&Record{
+		PassID:      e.PassID,
+		URL:         e.URL,
+		Host:        hostOf(e.URL),
+		Stage:       e.Stage,
+		Class:       e.Class,
+		Count:       1,
+		FirstSeen:   now,
+		LastSeen:    now,
+		LastMessage: e.Message,
+		Stages:      e.Stages,
+	})
+}
+
+// Records returns up to limit records that match f ordered from the most to
+// the least recently seen. A non-positive limit returns all matching
+// records.
+func (s *Store) Records(f Filter, limit int) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []Record
+	for el := s.lru.Front(); el != nil && (limit <= 0 || len(records) < limit); el = el.Next() {
+		if r := el.Value.(*Record); f.matches(r) {
+			records = append(records, *r)
+		}
+	}
+	return records
+}
+
+// TopClasses returns up to limit error classes of the records that match f
+// ordered by descending event count. A non-positive limit returns all
+// classes.
+func (s *Store) TopClasses(f Filter, limit int) []ClassSummary {
+	type classStats struct {
+		summary ClassSummary
+		urls    map[string]struct{}
+		hosts   map[string]struct{}
+	}
+
+	s.mu.Lock()
+	stats := make(map[Class]*classStats)
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		r := el.Value.(*Record)
+		if !f.matches(r) {
+			continue
+		}
+		cs := stats[r.Class]
+		if cs == nil {
+			cs = &classStats{
+				summary: ClassSummary{Class: r.Class},
+				urls:    make(map[string]struct{}),
+				hosts:   make(map[string]struct{}),
+			}
+			stats[r.Class] = cs
+		}
+		cs.summary.Count += r.Count
+		cs.urls[r.URL] = struct{}{}
+		cs.hosts[r.Host] = struct{}{}
+		if r.LastSeen.After(cs.summary.LastSeen) {
+			cs.summary.LastSeen = r.LastSeen
+		}
+	}
+	s.mu.Unlock()
+
+	summaries := make([]ClassSummary, 0, len(stats))
+	for _, cs := range stats {
+		cs.summary.URLs, cs.summary.Hosts = len(cs.urls), len(cs.hosts)
+		summaries = append(summaries, cs.summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Class < summaries[j].Class
+	})
+	if limit > 0 && len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+	return summaries
+}
+
+// hostOf returns the lower-case host name of rawURL or an empty string if
+// rawURL cannot be parsed.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
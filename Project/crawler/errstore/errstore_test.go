@@ -0,0 +1,126 @@
+package errstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(StoreTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type StoreTestSuite struct {
+	now   time.Time
+	store *Store
+}
+
+func (s *StoreTestSuite) SetUpTest(c *gc.C) {
+	s.now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store, err := NewStore(Config{MaxRecords: 4, Clock: func() time.Time { return s.now }})
+	c.Assert(err, gc.IsNil)
+	s.store = store
+}
+
+func (s *StoreTestSuite) TestAggregation(c *gc.C) {
+	s.store.Record(Event{PassID: 1, URL: "http://Example.com/a", Stage: "link_fetcher", Class: ClassTimeout, Message: "first"})
+	s.now = s.now.Add(time.Minute)
+	s.store.Record(Event{PassID: 1, URL: "http://Example.com/a", Stage: "link_fetcher", Class: ClassTimeout, Message: "second", Stages: []StageRecord{
+		{Stage: "link_fetcher", Outcome: StageFailed, Duration: 3 * time.Second},
+	}})
+
+	records := s.store.Records(Filter{}, 0)
+	c.Assert(records, gc.HasLen, 1)
+	c.Assert(records[0], gc.DeepEquals, Record{
+		PassID:      1,
+		URL:         "http://Example.com/a",
+		Host:        "example.com",
+		Stage:       "link_fetcher",
+		Class:       ClassTimeout,
+		Count:       2,
+		FirstSeen:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		LastSeen:    time.Date(2024, 5, 1, 12, 1, 0, 0, time.UTC),
+		LastMessage: "second",
+		Stages:      []StageRecord{{Stage: "link_fetcher", Outcome: StageFailed, Duration: 3 * time.Second}},
+	})
+}
+
+func (s *StoreTestSuite) TestTopClasses(c *gc.C) {
+	for i := 0; i < 3; i++ {
+		s.store.Record(Event{PassID: 1, URL: fmt.Sprintf("http://a.com/%d", i), Stage: "link_fetcher", Class: ClassHTTPClient})
+	}
+	s.store.Record(Event{PassID: 1, URL: "http://a.com/0", Stage: "link_fetcher", Class: ClassHTTPClient})
+	s.store.Record(Event{PassID: 2, URL: "http://b.com/", Stage: "link_fetcher", Class: ClassDNS})
+
+	top := s.store.TopClasses(Filter{}, 0)
+	c.Assert(top, gc.HasLen, 2)
+	c.Assert(top[0].Class, gc.Equals, ClassHTTPClient)
+	c.Assert(top[0].Count, gc.Equals, 4)
+	c.Assert(top[0].URLs, gc.Equals, 3)
+	c.Assert(top[0].Hosts, gc.Equals, 1)
+	c.Assert(top[1].Class, gc.Equals, ClassDNS)
+
+	// Per-host and per-pass queries.
+	top = s.store.TopClasses(Filter{Host: "B.com"}, 0)
+	c.Assert(top, gc.HasLen, 1)
+	c.Assert(top[0].Class, gc.Equals, ClassDNS)
+	top = s.store.TopClasses(Filter{PassID: 1}, 0)
+	c.Assert(top, gc.HasLen, 1)
+	c.Assert(top[0].Class, gc.Equals, ClassHTTPClient)
+
+	c.Assert(s.store.TopClasses(Filter{}, 1), gc.HasLen, 1)
+	c.Assert(s.store.TopClasses(Filter{Host: "c.com"}, 0), gc.HasLen, 0)
+}
+
+func (s *StoreTestSuite) TestEviction(c *gc.C) {
+	for i := 0; i < 4; i++ {
+		s.store.Record(Event{PassID: 1, URL: fmt.Sprintf("http://a.com/%d", i), Class: ClassOther})
+	}
+	// Touch the oldest record so that the next record evicts /1.
+	s.store.Record(Event{PassID: 1, URL: "http://a.com/0", Class: ClassOther})
+	s.store.Record(Event{PassID: 1, URL: "http://a.com/4", Class: ClassOther})
+
+	var urls []string
+	for _, r := range s.store.Records(Filter{}, 0) {
+		urls = append(urls, r.URL)
+	}
+	c.Assert(urls, gc.DeepEquals, []string{"http://a.com/4", "http://a.com/0", "http://a.com/3", "http://a.com/2"})
+	c.Assert(s.store.Records(Filter{}, 2), gc.HasLen, 2)
+}
+
+func (s *StoreTestSuite) TestInvalidConfig(c *gc.C) {
+	_, err := NewStore(Config{MaxRecords: -1})
+	c.Assert(err, gc.ErrorMatches, "(?s)error store: config validation failed:.*invalid max records value.*")
+}
+
+func (s *StoreTestSuite) TestClassify(c *gc.C) {
+	specs := []struct {
+		err error
+		exp Class
+	}{
+		{err: &net.DNSError{Err: "no such host", Name: "example.invalid"}, exp: ClassDNS},
+		{err: fmt.Errorf("get: %w", context.DeadlineExceeded), exp: ClassTimeout},
+		{err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, exp: ClassConnection},
+		{err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, exp: ClassConnection},
+		{err: errors.New("stopped after 10 redirects"), exp: ClassOther},
+	}
+	for specIndex, spec := range specs {
+		c.Assert(Classify(spec.err), gc.Equals, spec.exp, gc.Commentf("spec %d", specIndex))
+	}
+
+	c.Assert(ClassifyStatus(301), gc.Equals, ClassHTTPRedirect)
+	c.Assert(ClassifyStatus(404), gc.Equals, ClassHTTPClient)
+	c.Assert(ClassifyStatus(503), gc.Equals, ClassHTTPServer)
+	c.Assert(ClassifyStatus(101), gc.Equals, ClassOther)
+
+	c.Assert(ClassDNS.Dead(), gc.Equals, true)
+	c.Assert(ClassHTTPClient.Dead(), gc.Equals, true)
+	c.Assert(ClassHTTPServer.Dead(), gc.Equals, false)
+}
@@ -0,0 +1,141 @@
+package extract
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ExtractTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type ExtractTestSuite struct{}
+
+const page = `<html>
+<head>
+<title>Generic title | Example News</title>
+<meta property="og:title" content="Open Graph title">
+<meta name="author" content="Meta Author">
+</head>
+<body>
+<nav class="menu"><a href="/">Home</a></nav>
+<article id="story" class="post featured">
+  <h1 class="headline">Rain expected <em>all</em> week</h1>
+  <p class="byline">By <a rel="author" href="/jane">Jane Doe</a></p>
+  <time datetime="2024-03-05T08:30:00Z">March 5</time>
+  <div class="body">
+    <p>First paragraph.</p>
+    <script>trackView();</script>
+    <p>Second <b>para</b>graph.</p>
+  </div>
+  <div class="body related"><p>Related stories</p></div>
+</article>
+<footer><p>Copyright</p></footer>
+</body>
+</html>`
+
+func (s *ExtractTestSuite) TestSelectors(c *gc.C) {
+	doc, err := html.Parse(strings.NewReader(page))
+	c.Assert(err, gc.IsNil)
+
+	specs := []struct {
+		expr string
+		exp  []string
+	}{
+		{expr: "h1", exp: []string{"Rain expected all week"}},
+		{expr: "#story .headline", exp: []string{"Rain expected all week"}},
+		{expr: "article.post.featured > h1", exp: []string{"Rain expected all week"}},
+		{expr: "body > h1", exp: nil},
+		{expr: "div.body p", exp: []string{"First paragraph.", "Second paragraph.", "Related stories"}},
+		{expr: "a[rel=author]", exp: []string{"Jane Doe"}},
+		{expr: `[class~="related"] p`, exp: []string{"Related stories"}},
+		{expr: "a[href^='/j']", exp: []string{"Jane Doe"}},
+		{expr: "nav a, footer *", exp: []string{"Home", "Copyright"}},
+		{expr: "ARTICLE > * > P[class$=line]", exp: nil},
+		{expr: "article > p[class*=line]", exp: []string{"By Jane Doe"}},
+		{expr: "meta[content]", exp: []string{"", ""}},
+	}
+	for specIndex, spec := range specs {
+		sel, err := Compile(spec.expr)
+		c.Assert(err, gc.IsNil, gc.Commentf("spec %d", specIndex))
+
+		var got []string
+		for _, n := range sel.MatchAll(doc) {
+			got = append(got, textOf(n))
+		}
+		c.Assert(got, gc.DeepEquals, spec.exp, gc.Commentf("spec %d: %s", specIndex, spec.expr))
+	}
+}
+
+func (s *ExtractTestSuite) TestInvalidSelectors(c *gc.C) {
+	for _, expr := range []string{"", "  ", "div,", ">p", "div >", "#", "p.", "a[", "a[href", "a[=x]", "a[href|=x]", "a[href='x]", "a!b", ".body:not"} {
+		_, err := Compile(expr)
+		c.Assert(errors.Is(err, ErrInvalidSelector), gc.Equals, true, gc.Commentf("expr %q: %v", expr, err))
+	}
+}
+
+func (s *ExtractTestSuite) TestExtract(c *gc.C) {
+	p := Profile{
+		Domain: "example.com",
+		Title:  MustCompile(`meta[property="og:title"], h1.headline`),
+		Body:   MustCompile("article .body, article .body p"),
+		Author: MustCompile("a[rel=author]"),
+		Date:   MustCompile("article time"),
+	}
+
+	res, err := p.Extract(strings.NewReader(page))
+	c.Assert(err, gc.IsNil)
+	c.Assert(res, gc.DeepEquals, Result{
+		// The first match in document order is used.
+		Title:    "Open Graph title",
+		Body:     "First paragraph. Second paragraph. Related stories",
+		Author:   "Jane Doe",
+		DateText: "2024-03-05T08:30:00Z",
+		Date:     time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC),
+	})
+
+	// Fields without a selector or a match are left empty.
+	p = Profile{Domain: "example.com", Author: MustCompile(".missing")}
+	res, err = p.Extract(strings.NewReader(page))
+	c.Assert(err, gc.IsNil)
+	c.Assert(res, gc.DeepEquals, Result{})
+}
+
+func (s *ExtractTestSuite) TestMatch(c *gc.C) {
+	profiles := []Profile{
+		{Domain: "news.example.com"},
+		{Domain: "Example.com."},
+	}
+
+	c.Assert(Match(profiles, "https://news.example.com/a"), gc.Equals, &profiles[0])
+	c.Assert(Match(profiles, "https://www.news.example.com/a"), gc.Equals, &profiles[0])
+	c.Assert(Match(profiles, "https://EXAMPLE.com:8080/a"), gc.Equals, &profiles[1])
+	c.Assert(Match(profiles, "https://blog.example.com/a"), gc.Equals, &profiles[1])
+	c.Assert(Match(profiles, "https://badexample.com/a"), gc.IsNil)
+	c.Assert(Match(nil, "https://example.com/a"), gc.IsNil)
+}
+
+func (s *ExtractTestSuite) TestParseDate(c *gc.C) {
+	specs := []struct {
+		in  string
+		exp time.Time
+	}{
+		{in: "2024-03-05", exp: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{in: " March 5, 2024 ", exp: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{in: "5 Mar 2024", exp: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{in: "2024-03-05T10:00:00+02:00", exp: time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC)},
+	}
+	for specIndex, spec := range specs {
+		got, ok := ParseDate(spec.in)
+		c.Assert(ok, gc.Equals, true, gc.Commentf("spec %d", specIndex))
+		c.Assert(got.Equal(spec.exp), gc.Equals, true, gc.Commentf("spec %d: got %s", specIndex, got))
+	}
+
+	_, ok := ParseDate("last Tuesday")
+	c.Assert(ok, gc.Equals, false)
+}
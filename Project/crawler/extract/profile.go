@@ -0,0 +1,214 @@
+// Package extract implements per-domain extraction profiles that select the
+// title, body, author and publication date of the pages of a site via CSS
+// selectors. Profiles are used for sites whose content is not captured well
+// by the generic text extractor of the crawler.
+package extract
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Profile describes how the content of the pages of a particular domain is
+// extracted. All selectors are optional; fields whose selector is not
+// specified (or does not match) are populated by the generic extractor.
+type Profile struct {
+	// The domain whose pages are extracted using this profile. The profile
+	// also applies to the subdomains of the domain.
+	Domain string
+
+	// Selects the page title. If it matches multiple elements, the first
+	// one is used.
+	Title *Selector
+
+	// Selects the elements that make up the page body. The text of the
+	// matched elements is joined with spaces.
+	Body *Selector
+
+	// Selects the page author. If it matches multiple elements, the first
+	// one is used.
+	Author *Selector
+
+	// Selects the publication date of the page. If it matches multiple
+	// elements, the first one is used.
+	Date *Selector
+}
+
+// Result holds the fields extracted from a page using a Profile.
+type Result struct {
+	Title  string
+	Body   string
+	Author string
+
+	// The text selected by the Date selector and the date parsed from it.
+	// Date is zero if the text is not in one of the supported formats.
+	DateText string
+	Date     time.Time
+}
+
+// Matches returns true if the profile applies to pages served by host.
+func (p *Profile) Matches(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	domain := strings.TrimSuffix(strings.ToLower(p.Domain), ".")
+	return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+// Match returns the first profile that applies to rawURL or nil if no
+// profile applies.
+func Match(profiles []Profile, rawURL string) *Profile {
+	if len(profiles) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	for i := range profiles {
+		if profiles[i].Matches(u.Hostname()) {
+			return &profiles[i]
+		}
+	}
+	return nil
+}
+
+// Extract parses the HTML document read from r and evaluates the selectors
+// of the profile against it.
+func (p *Profile) Extract(r io.Reader) (Result, error) {
+	var res Result
+	doc, err := html.Parse(r)
+	if err != nil {
+		return res, fmt.Errorf("extract: unable to parse HTML: %w", err)
+	}
+
+	if p.Title != nil {
+		res.Title = valueOf(p.Title.MatchFirst(doc))
+	}
+	if p.Body != nil {
+		res.Body = bodyOf(p.Body.MatchAll(doc))
+	}
+	if p.Author != nil {
+		res.Author = valueOf(p.Author.MatchFirst(doc))
+	}
+	if p.Date != nil {
+		res.DateText = valueOf(p.Date.MatchFirst(doc))
+		res.Date, _ = ParseDate(res.DateText)
+	}
+	return res, nil
+}
+
+// valueOf returns the value of a title, author or date element. Values are
+// read from the content attribute of <meta> elements, the datetime attribute
+// of <time> elements and the text of all other elements.
+func valueOf(n *html.Node) string {
+	switch {
+	case n == nil:
+		return ""
+	case n.DataAtom == atom.Meta:
+		return strings.TrimSpace(attrValue(n, "content"))
+	case n.DataAtom == atom.Time && attrValue(n, "datetime") != "":
+		return strings.TrimSpace(attrValue(n, "datetime"))
+	default:
+		return textOf(n)
+	}
+}
+
+// bodyOf returns the text of the matched body elements. Elements that are
+// nested within another matched element are skipped so that their text is
+// only included once.
+func bodyOf(matches []*html.Node) string {
+	matched := make(map[*html.Node]struct{}, len(matches))
+	var parts []string
+	for _, n := range matches {
+		matched[n] = struct{}{}
+		if hasMatchedAncestor(n, matched) {
+			continue
+		}
+		if text := textOf(n); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func hasMatchedAncestor(n *html.Node, matched map[*html.Node]struct{}) bool {
+	for anc := n.Parent; anc != nil; anc = anc.Parent {
+		if _, found := matched[anc]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineElements lists the elements that do not introduce a word boundary.
+var inlineElements = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Cite: true, atom.Code: true,
+	atom.Em: true, atom.I: true, atom.Mark: true, atom.Q: true, atom.S: true,
+	atom.Small: true, atom.Span: true, atom.Strong: true, atom.Sub: true,
+	atom.Sup: true, atom.Time: true, atom.U: true,
+}
+
+// textOf returns the text below n with runs of whitespace collapsed into a
+// single space. The content of scripts, styles and templates is ignored.
+func textOf(n *html.Node) string {
+	var sb strings.Builder
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template:
+				return
+			}
+		}
+
+		boundary := n.Type == html.ElementNode && !inlineElements[n.DataAtom]
+		if boundary {
+			sb.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+		if boundary {
+			sb.WriteByte(' ')
+		}
+	}
+	visit(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// dateLayouts lists the formats that ParseDate accepts.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// ParseDate parses a publication date in one of the commonly used formats
+// (e.g. "2006-01-02", "2006-01-02T15:04:05Z07:00" or "January 2, 2006").
+// Dates without a time zone are interpreted as UTC.
+func ParseDate(text string) (time.Time, bool) {
+	text = strings.TrimSpace(text)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,388 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// ErrInvalidSelector is returned when a CSS selector cannot be parsed.
+var ErrInvalidSelector = errors.New("invalid CSS selector")
+
+// attrSelector matches elements by the value of one of their attributes.
+type attrSelector struct {
+	name string
+
+	// One of 0 (attribute is present), '=' (exact match), '~' (one of the
+	// whitespace-separated words matches), '^' (prefix), '$' (suffix) or
+	// '*' (substring).
+	op    byte
+	value string
+}
+
+// compoundSelector matches a single element by its tag name, ID, classes
+// and attributes. Empty fields match any element.
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSelector
+}
+
+// complexSelector is a chain of compound selectors. combinators[i] is the
+// relationship between parts[i] and parts[i+1]: ' ' for a descendant and
+// '>' for a child.
+type complexSelector struct {
+	parts       []compoundSelector
+	combinators []byte
+}
+
+// Selector is a compiled CSS selector.
+//
+// The supported syntax consists of:
+//   - type (div) and universal (*) selectors.
+//   - ID (#main) and class (.post) selectors.
+//   - attribute selectors ([name], [name=value], [name~=value],
+//     [name^=value], [name$=value] and [name*=value]); values may be
+//     quoted.
+//   - descendant (article p) and child (article > p) combinators.
+//   - selector lists (h1, .headline) that match any of the selectors.
+type Selector struct {
+	expr   string
+	groups []complexSelector
+}
+
+// Compile parses a CSS selector.
+func Compile(expr string) (*Selector, error) {
+	p := &selectorParser{in: expr}
+	sel := &Selector{expr: expr}
+	for {
+		group, err := p.parseComplex()
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidSelector, expr, err)
+		}
+		sel.groups = append(sel.groups, group)
+		if p.eof() {
+			return sel, nil
+		}
+		// parseComplex only stops at the end of the input or a comma.
+		p.pos++
+	}
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(expr string) *Selector {
+	sel, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return sel
+}
+
+// String returns the source text of the selector.
+func (sel *Selector) String() string {
+	return sel.expr
+}
+
+// MatchAll returns the elements below root that match the selector in
+// document order.
+func (sel *Selector) MatchAll(root *html.Node) []*html.Node {
+	var matches []*html.Node
+	walkElements(root, func(n *html.Node) bool {
+		if sel.matches(n) {
+			matches = append(matches, n)
+		}
+		return true
+	})
+	return matches
+}
+
+// MatchFirst returns the first element below root that matches the selector
+// or nil if no element matches.
+func (sel *Selector) MatchFirst(root *html.Node) *html.Node {
+	var match *html.Node
+	walkElements(root, func(n *html.Node) bool {
+		if sel.matches(n) {
+			match = n
+		}
+		return match == nil
+	})
+	return match
+}
+
+func (sel *Selector) matches(n *html.Node) bool {
+	for _, group := range sel.groups {
+		if group.matches(n, len(group.parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkElements invokes fn for each element below root in document order
+// until fn returns false.
+func walkElements(root *html.Node, fn func(*html.Node) bool) bool {
+	for n := root.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode && !fn(n) {
+			return false
+		}
+		if !walkElements(n, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches returns true if n matches parts[index] and its ancestors match the
+// preceding parts of the selector.
+func (cs complexSelector) matches(n *html.Node, index int) bool {
+	if !cs.parts[index].matches(n) {
+		return false
+	} else if index == 0 {
+		return true
+	}
+
+	if cs.combinators[index-1] == '>' {
+		parent := n.Parent
+		return parent != nil && parent.Type == html.ElementNode && cs.matches(parent, index-1)
+	}
+	for anc := n.Parent; anc != nil && anc.Type == html.ElementNode; anc = anc.Parent {
+		if cs.matches(anc, index-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compoundSelector) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode || (c.tag != "" && c.tag != n.Data) {
+		return false
+	}
+	if c.id != "" && attrValue(n, "id") != c.id {
+		return false
+	}
+	if len(c.classes) != 0 {
+		classes := strings.Fields(attrValue(n, "class"))
+		for _, want := range c.classes {
+			if !containsString(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, attr := range c.attrs {
+		if !attr.matches(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a attrSelector) matches(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || attr.Key != a.name {
+			continue
+		}
+		switch a.op {
+		case 0:
+			return true
+		case '=':
+			return attr.Val == a.value
+		case '~':
+			return containsString(strings.Fields(attr.Val), a.value)
+		case '^':
+			return a.value != "" && strings.HasPrefix(attr.Val, a.value)
+		case '$':
+			return a.value != "" && strings.HasSuffix(attr.Val, a.value)
+		case '*':
+			return a.value != "" && strings.Contains(attr.Val, a.value)
+		}
+	}
+	return false
+}
+
+// attrValue returns the value of the attribute called name or an empty
+// string if n does not have such an attribute.
+func attrValue(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorParser implements a recursive-descent parser for CSS selectors.
+type selectorParser struct {
+	in  string
+	pos int
+}
+
+func (p *selectorParser) eof() bool  { return p.pos >= len(p.in) }
+func (p *selectorParser) peek() byte { return p.in[p.pos] }
+
+// skipSpace advances past any whitespace and returns true if any was found.
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\n\r\f", p.peek()) != -1 {
+		p.pos++
+	}
+	return p.pos != start
+}
+
+// parseComplex parses a selector up to the next comma or the end of input.
+func (p *selectorParser) parseComplex() (complexSelector, error) {
+	var cs complexSelector
+	p.skipSpace()
+	for {
+		part, err := p.parseCompound()
+		if err != nil {
+			return cs, err
+		}
+		cs.parts = append(cs.parts, part)
+
+		hadSpace := p.skipSpace()
+		if p.eof() || p.peek() == ',' {
+			return cs, nil
+		}
+
+		switch {
+		case p.peek() == '>':
+			p.pos++
+			p.skipSpace()
+			cs.combinators = append(cs.combinators, '>')
+		case hadSpace:
+			cs.combinators = append(cs.combinators, ' ')
+		default:
+			return cs, fmt.Errorf("unexpected character %q at offset %d", p.peek(), p.pos)
+		}
+	}
+}
+
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	var c compoundSelector
+	start := p.pos
+	if !p.eof() && p.peek() == '*' {
+		p.pos++
+	} else {
+		c.tag = strings.ToLower(p.parseIdent())
+	}
+
+	for !p.eof() {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			if c.id = p.parseIdent(); c.id == "" {
+				return c, fmt.Errorf("missing ID after '#' at offset %d", p.pos)
+			}
+		case '.':
+			p.pos++
+			class := p.parseIdent()
+			if class == "" {
+				return c, fmt.Errorf("missing class name after '.' at offset %d", p.pos)
+			}
+			c.classes = append(c.classes, class)
+		case '[':
+			attr, err := p.parseAttr()
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, attr)
+		default:
+			if p.pos == start {
+				return c, fmt.Errorf("unexpected character %q at offset %d", p.peek(), p.pos)
+			}
+			return c, nil
+		}
+	}
+	if p.pos == start {
+		return c, fmt.Errorf("missing selector at offset %d", p.pos)
+	}
+	return c, nil
+}
+
+func (p *selectorParser) parseAttr() (attrSelector, error) {
+	var a attrSelector
+	p.pos++ // skip '['
+	p.skipSpace()
+	if a.name = strings.ToLower(p.parseIdent()); a.name == "" {
+		return a, fmt.Errorf("missing attribute name at offset %d", p.pos)
+	}
+	p.skipSpace()
+	if p.eof() {
+		return a, errors.New("unterminated attribute selector")
+	}
+
+	if p.peek() != ']' {
+		switch {
+		case p.peek() == '=':
+			a.op = '='
+			p.pos++
+		case strings.HasPrefix(p.in[p.pos:], "~=") || strings.HasPrefix(p.in[p.pos:], "^=") ||
+			strings.HasPrefix(p.in[p.pos:], "$=") || strings.HasPrefix(p.in[p.pos:], "*="):
+			a.op = p.peek()
+			p.pos += 2
+		default:
+			return a, fmt.Errorf("unsupported attribute operator at offset %d", p.pos)
+		}
+
+		p.skipSpace()
+		value, err := p.parseValue()
+		if err != nil {
+			return a, err
+		}
+		a.value = value
+		p.skipSpace()
+	}
+
+	if p.eof() || p.peek() != ']' {
+		return a, errors.New("unterminated attribute selector")
+	}
+	p.pos++
+	return a, nil
+}
+
+// parseValue parses a quoted string or an identifier.
+func (p *selectorParser) parseValue() (string, error) {
+	if p.eof() {
+		return "", errors.New("missing attribute value")
+	}
+	if quote := p.peek(); quote == '"' || quote == '\'' {
+		end := strings.IndexByte(p.in[p.pos+1:], quote)
+		if end == -1 {
+			return "", fmt.Errorf("unterminated string at offset %d", p.pos)
+		}
+		value := p.in[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	}
+	value := p.parseIdent()
+	if value == "" {
+		return "", fmt.Errorf("missing attribute value at offset %d", p.pos)
+	}
+	return value, nil
+}
+
+func (p *selectorParser) parseIdent() string {
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.in[p.pos:])
+		if r != '-' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.in[start:p.pos]
+}
@@ -0,0 +1,148 @@
+// Package feed parses RSS 2.0 and Atom news feeds.
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ErrUnsupportedFormat is returned when a document is neither an RSS nor an
+// Atom feed.
+var ErrUnsupportedFormat = errors.New("unsupported feed format")
+
+var tagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// Feed describes a parsed news feed.
+type Feed struct {
+	Title       string
+	Description string
+	Items       []Item
+}
+
+// Item describes an entry of a news feed.
+type Item struct {
+	Title string
+
+	// The link to the full article. It may be relative to the feed URL.
+	Link string
+
+	// The summary of the item with any markup removed.
+	Summary string
+}
+
+// Text returns the title and summary of the feed and its items joined by
+// spaces.
+func (f *Feed) Text() string {
+	parts := []string{f.Title, f.Description}
+	for _, item := range f.Items {
+		parts = append(parts, item.Title, item.Summary)
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+type rssDoc struct {
+	Channel struct {
+		Title       string `xml:"title"`
+		Description string `xml:"description"`
+		Items       []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			GUID        string `xml:"guid"`
+			Description string `xml:"description"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomDoc struct {
+	Title    string `xml:"title"`
+	Subtitle string `xml:"subtitle"`
+	Entries  []struct {
+		Title   string     `xml:"title"`
+		Links   []atomLink `xml:"link"`
+		Summary string     `xml:"summary"`
+		Content string     `xml:"content"`
+	} `xml:"entry"`
+}
+
+// Parse reads an RSS 2.0 or Atom feed from r.
+func Parse(r io.Reader) (*Feed, error) {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	// Locate the root element to detect the feed format.
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("feed: %w", ErrUnsupportedFormat)
+		}
+		root, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(root.Name.Local) {
+		case "rss":
+			var doc rssDoc
+			if err = dec.DecodeElement(&doc, &root); err != nil {
+				return nil, fmt.Errorf("feed: %w", err)
+			}
+			return mapRSS(&doc), nil
+		case "feed":
+			var doc atomDoc
+			if err = dec.DecodeElement(&doc, &root); err != nil {
+				return nil, fmt.Errorf("feed: %w", err)
+			}
+			return mapAtom(&doc), nil
+		default:
+			return nil, fmt.Errorf("feed: %w: unexpected root element %q", ErrUnsupportedFormat, root.Name.Local)
+		}
+	}
+}
+
+func mapRSS(doc *rssDoc) *Feed {
+	f := &Feed{Title: plainText(doc.Channel.Title), Description: plainText(doc.Channel.Description)}
+	for _, item := range doc.Channel.Items {
+		link := strings.TrimSpace(item.Link)
+		if link == "" && strings.HasPrefix(strings.TrimSpace(item.GUID), "http") {
+			link = strings.TrimSpace(item.GUID)
+		}
+		f.Items = append(f.Items, Item{Title: plainText(item.Title), Link: link, Summary: plainText(item.Description)})
+	}
+	return f
+}
+
+func mapAtom(doc *atomDoc) *Feed {
+	f := &Feed{Title: plainText(doc.Title), Description: plainText(doc.Subtitle)}
+	for _, entry := range doc.Entries {
+		item := Item{Title: plainText(entry.Title), Summary: plainText(entry.Summary)}
+		if item.Summary == "" {
+			item.Summary = plainText(entry.Content)
+		}
+		for _, link := range entry.Links {
+			if link.Rel == "" || link.Rel == "alternate" {
+				item.Link = strings.TrimSpace(link.Href)
+				break
+			}
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f
+}
+
+// plainText strips any markup from s (feeds often embed escaped HTML) and
+// collapses runs of whitespace.
+func plainText(s string) string {
+	s = html.UnescapeString(tagRegex.ReplaceAllString(s, " "))
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,68 @@
+package feed
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(FeedTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type FeedTestSuite struct{}
+
+func (s *FeedTestSuite) TestRSS(c *gc.C) {
+	f, err := Parse(strings.NewReader(`<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0">
+<channel>
+  <title>Example News</title>
+  <description>Latest &amp; greatest</description>
+  <item>
+    <title>Rain expected</title>
+    <link>https://example.com/rain</link>
+    <description>&lt;p&gt;Bring an &lt;b&gt;umbrella&lt;/b&gt;.&lt;/p&gt;</description>
+  </item>
+  <item>
+    <title>Sunny weekend</title>
+    <guid>https://example.com/sun</guid>
+  </item>
+</channel>
+</rss>`))
+	c.Assert(err, gc.IsNil)
+	c.Assert(f, gc.DeepEquals, &Feed{
+		Title:       "Example News",
+		Description: "Latest & greatest",
+		Items: []Item{
+			{Title: "Rain expected", Link: "https://example.com/rain", Summary: "Bring an umbrella ."},
+			{Title: "Sunny weekend", Link: "https://example.com/sun"},
+		},
+	})
+	c.Assert(f.Text(), gc.Equals, "Example News Latest & greatest Rain expected Bring an umbrella . Sunny weekend")
+}
+
+func (s *FeedTestSuite) TestAtom(c *gc.C) {
+	f, err := Parse(strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Blog</title>
+  <entry>
+    <title>Release notes</title>
+    <link rel="edit" href="/edit/1"/>
+    <link href="/posts/release-notes"/>
+    <content type="html">Version 2 is out</content>
+  </entry>
+</feed>`))
+	c.Assert(err, gc.IsNil)
+	c.Assert(f, gc.DeepEquals, &Feed{
+		Title: "Example Blog",
+		Items: []Item{{Title: "Release notes", Link: "/posts/release-notes", Summary: "Version 2 is out"}},
+	})
+}
+
+func (s *FeedTestSuite) TestUnsupportedFormat(c *gc.C) {
+	for _, doc := range []string{"<html><body></body></html>", "not xml", ""} {
+		_, err := Parse(strings.NewReader(doc))
+		c.Assert(errors.Is(err, ErrUnsupportedFormat), gc.Equals, true, gc.Commentf("doc %q: %v", doc, err))
+	}
+}
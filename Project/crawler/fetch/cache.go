@@ -0,0 +1,427 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"webcrawler/logging"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// CacheStatusHeader is the header that the Cache middleware adds to its
+// responses to report whether they were served from the cache.
+const CacheStatusHeader = "X-Cache"
+
+// The values of the CacheStatusHeader.
+const (
+	// The response was served from a fresh cache entry without contacting
+	// the host.
+	CacheHit = "hit"
+
+	// The response was served from a stale cache entry after the host
+	// confirmed that it is still valid.
+	CacheRevalidated = "revalidated"
+
+	// The response was fetched from the host.
+	CacheMiss = "miss"
+)
+
+// The upper bound for the freshness lifetime that is derived from the
+// Last-Modified header of responses without explicit expiration times.
+const maxHeuristicFreshness = 24 * time.Hour
+
+// ErrCacheMiss is returned by CacheStore implementations for keys without an
+// entry.
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheStore is implemented by objects that persist the entries of the Cache
+// middleware.
+type CacheStore interface {
+	// Get returns the entry stored under key or ErrCacheMiss.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set stores entry under key. The store may evict the entry once ttl
+	// has elapsed.
+	Set(ctx context.Context, key string, entry []byte, ttl time.Duration) error
+
+	// Delete removes the entry stored under key, if any.
+	Delete(ctx context.Context, key string) error
+}
+
+// CacheConfig configures the Cache middleware.
+type CacheConfig struct {
+	// The store for the cache entries.
+	Store CacheStore
+
+	// The minimum time for which entries are served without revalidation,
+	// regardless of the caching headers of their responses (apart from
+	// no-store). Useful for development runs that fetch the same pages
+	// over and over again. Zero honors the caching headers.
+	MinTTL time.Duration
+
+	// The time for which entries are kept after they were stored or last
+	// revalidated so that stale entries can be revalidated with a
+	// conditional request. Defaults to 7 days.
+	Retention time.Duration
+
+	// The maximum size of a cached body. Larger responses are passed
+	// through without being stored. Defaults to 10 MiB.
+	MaxEntrySize int64
+
+	// A function that returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *CacheConfig) validate() error {
+	var err error
+	if cfg.Store == nil {
+		err = multierror.Append(err, fmt.Errorf("cache store has not been provided"))
+	}
+	if cfg.MinTTL < 0 {
+		err = multierror.Append(err, fmt.Errorf("min TTL must not be negative"))
+	}
+	if cfg.Retention < 0 {
+		err = multierror.Append(err, fmt.Errorf("retention must not be negative"))
+	} else if cfg.Retention == 0 {
+		cfg.Retention = 7 * 24 * time.Hour
+	}
+	if cfg.MaxEntrySize < 0 {
+		err = multierror.Append(err, fmt.Errorf("max entry size must not be negative"))
+	} else if cfg.MaxEntrySize == 0 {
+		cfg.MaxEntrySize = 10 << 20
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+// Cache returns a Middleware that serves GET requests from the cache store
+// specified by cfg, loosely following the caching rules of RFC 7234 for a
+// private cache:
+//
+//   - Responses with a cacheable status code are stored under their
+//     request URL unless they carry a no-store directive or a "Vary: *"
+//     header. Other Vary headers are ignored.
+//   - Entries are fresh for the lifetime given by the max-age directive or
+//     the Expires header, or for a tenth of the time since the page was
+//     last modified. Fresh entries are served without contacting the host.
+//   - Stale entries with an ETag or Last-Modified validator are revalidated
+//     with a conditional request; a 304 response refreshes the entry.
+//
+// Requests with a Range, Authorization or conditional header or a no-store
+// directive bypass the cache; a no-cache directive forces revalidation. The
+// responses carry a CacheStatusHeader.
+func Cache(cfg CacheConfig) (Middleware, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("cache: config validation failed: %w", err)
+	}
+	return cache(cfg), nil
+}
+
+// cache returns the Cache middleware for a validated config.
+func cache(cfg CacheConfig) Middleware {
+	logger := logging.Component(cfg.Logger, "fetch.cache")
+	return func(next Fetcher) Fetcher {
+		c := &httpCache{cfg: cfg, next: next, logger: logger}
+		return Func(c.fetch)
+	}
+}
+
+// httpCache is the Fetcher returned by the Cache middleware.
+type httpCache struct {
+	cfg    CacheConfig
+	next   Fetcher
+	logger *slog.Logger
+}
+
+func (c *httpCache) fetch(req *http.Request) (*http.Response, error) {
+	if !cacheableRequest(req) {
+		return c.next.Fetch(req)
+	}
+
+	ctx, key := req.Context(), req.URL.String()
+	entry := c.load(ctx, key)
+	now := c.cfg.Clock()
+	condReq := req
+	if entry != nil {
+		_, noCache := parseCacheControl(req.Header)["no-cache"]
+		if !noCache && entry.fresh(now, c.cfg.MinTTL) {
+			return entry.response(req, now, CacheHit), nil
+		}
+		condReq = entry.conditionalRequest(req)
+	}
+
+	res, err := c.next.Fetch(condReq)
+	if err != nil {
+		return nil, err
+	}
+	if condReq != req && res.StatusCode == http.StatusNotModified {
+		discard(res)
+		entry.revalidate(res.Header, now)
+		c.save(ctx, key, entry)
+		return entry.response(req, now, CacheRevalidated), nil
+	}
+	return c.store(ctx, key, res, now)
+}
+
+// store saves res in the cache if it is cacheable and returns a response with
+// the same contents.
+func (c *httpCache) store(ctx context.Context, key string, res *http.Response, now time.Time) (*http.Response, error) {
+	if !c.cacheableResponse(res, now) {
+		res.Header.Set(CacheStatusHeader, CacheMiss)
+		return res, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, c.cfg.MaxEntrySize+1))
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("cache: read response body: %w", err)
+	}
+	if int64(len(body)) > c.cfg.MaxEntrySize {
+		res.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), res.Body), Closer: res.Body}
+		res.Header.Set(CacheStatusHeader, CacheMiss)
+		return res, nil
+	}
+	_ = res.Body.Close()
+
+	entry := &cacheEntry{
+		StatusCode: res.StatusCode,
+		Header:     res.Header.Clone(),
+		Body:       body,
+		StoredAt:   now,
+	}
+	if res.Request != nil && res.Request.URL != nil {
+		entry.URL = res.Request.URL.String()
+	}
+	c.save(ctx, key, entry)
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.Header.Set(CacheStatusHeader, CacheMiss)
+	return res, nil
+}
+
+// load returns the entry stored under key or nil if there is none. Errors are
+// logged and treated as cache misses.
+func (c *httpCache) load(ctx context.Context, key string) *cacheEntry {
+	data, err := c.cfg.Store.Get(ctx, key)
+	if errors.Is(err, ErrCacheMiss) {
+		return nil
+	} else if err != nil {
+		c.logger.Warn("unable to read cache entry", "url", key, "err", err)
+		return nil
+	}
+	entry := new(cacheEntry)
+	if err = json.Unmarshal(data, entry); err != nil {
+		c.logger.Warn("discarding malformed cache entry", "url", key, "err", err)
+		_ = c.cfg.Store.Delete(ctx, key)
+		return nil
+	}
+	return entry
+}
+
+// save stores entry under key. Errors are logged as they do not affect the
+// response.
+func (c *httpCache) save(ctx context.Context, key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		ttl := max(entry.lifetime(), c.cfg.MinTTL, c.cfg.Retention)
+		err = c.cfg.Store.Set(ctx, key, data, ttl)
+	}
+	if err != nil {
+		c.logger.Warn("unable to write cache entry", "url", key, "err", err)
+	}
+}
+
+// cacheableResponse returns true if res can be stored and served from the
+// cache later on.
+func (c *httpCache) cacheableResponse(res *http.Response, now time.Time) bool {
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+		http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+	default:
+		return false
+	}
+	if _, noStore := parseCacheControl(res.Header)["no-store"]; noStore {
+		return false
+	}
+	if strings.TrimSpace(res.Header.Get("Vary")) == "*" {
+		return false
+	}
+
+	// Entries that are neither fresh nor revalidatable would never be
+	// served.
+	entry := &cacheEntry{Header: res.Header, StoredAt: now}
+	return c.cfg.MinTTL > 0 || entry.lifetime() > 0 || entry.hasValidators()
+}
+
+// cacheableRequest returns true if the response to req may be served from
+// the cache.
+func cacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	for _, h := range []string{"Range", "Authorization", "If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since"} {
+		if req.Header.Get(h) != "" {
+			return false
+		}
+	}
+	_, noStore := parseCacheControl(req.Header)["no-store"]
+	return !noStore
+}
+
+// cacheEntry is a response stored by the Cache middleware.
+type cacheEntry struct {
+	// The URL of the response after following redirects.
+	URL        string      `json:"url,omitempty"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+
+	// The time when the response was received or last revalidated.
+	StoredAt time.Time `json:"storedAt"`
+}
+
+// lifetime returns the freshness lifetime of the entry.
+func (e *cacheEntry) lifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if _, noCache := cc["no-cache"]; noCache {
+		return 0
+	}
+	if maxAge, ok := cc["max-age"]; ok {
+		secs, err := strconv.ParseInt(maxAge, 10, 64)
+		if err != nil || secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	date := e.StoredAt
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		date = t
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		// Invalid dates (e.g. "0") represent a time in the past.
+		t, err := http.ParseTime(expires)
+		if err != nil || !t.After(date) {
+			return 0
+		}
+		return t.Sub(date)
+	}
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && lm.Before(date) {
+		return min(date.Sub(lm)/10, maxHeuristicFreshness)
+	}
+	return 0
+}
+
+// age returns the current age of the entry.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	age := max(now.Sub(e.StoredAt), 0)
+	if secs, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && secs > 0 {
+		age += time.Duration(secs) * time.Second
+	}
+	return age
+}
+
+// fresh returns true if the entry can be served without revalidation.
+func (e *cacheEntry) fresh(now time.Time, minTTL time.Duration) bool {
+	return e.age(now) < max(e.lifetime(), minTTL)
+}
+
+func (e *cacheEntry) hasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// conditionalRequest returns a copy of req that asks the host to confirm that
+// the entry is still valid or req itself if the entry has no validators.
+func (e *cacheEntry) conditionalRequest(req *http.Request) *http.Request {
+	if !e.hasValidators() {
+		return req
+	}
+	req = req.Clone(req.Context())
+	if etag := e.Header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		req.Header.Set("If-Modified-Since", lm)
+	}
+	return req
+}
+
+// revalidate updates the entry with the headers of a 304 response.
+func (e *cacheEntry) revalidate(header http.Header, now time.Time) {
+	for name, values := range header {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Content-Range", "Transfer-Encoding", CacheStatusHeader:
+			continue
+		}
+		e.Header[name] = append([]string(nil), values...)
+	}
+	if header.Get("Age") == "" {
+		e.Header.Del("Age")
+	}
+	e.StoredAt = now
+}
+
+// response returns a response for req with the contents of the entry.
+func (e *cacheEntry) response(req *http.Request, now time.Time, status string) *http.Response {
+	finalReq := req
+	if e.URL != "" && e.URL != req.URL.String() {
+		if u, err := req.URL.Parse(e.URL); err == nil {
+			finalReq = req.Clone(req.Context())
+			finalReq.URL = u
+			finalReq.Host = ""
+		}
+	}
+
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+	header.Set(CacheStatusHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       finalReq,
+	}
+}
+
+// parseCacheControl returns the directives of the Cache-Control header in h
+// keyed by their lowercase names.
+func parseCacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				directives[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// prefixedBody is a response body whose first bytes were already read into
+// memory.
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,145 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The length of the expiration time that prefixes the files of a DiskCache.
+const diskCacheHeaderLen = 8
+
+// DiskCache is a CacheStore that keeps each entry in a file of a directory.
+// The files are named after the SHA-256 hash of their key and are spread
+// across 256 subdirectories.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a DiskCache that stores its entries in dir, creating
+// the directory if it does not exist.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("disk cache: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// Get implements CacheStore. Expired entries are removed.
+func (d *DiskCache) Get(_ context.Context, key string) ([]byte, error) {
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrCacheMiss
+	} else if err != nil {
+		return nil, fmt.Errorf("disk cache: %w", err)
+	} else if len(data) < diskCacheHeaderLen {
+		_ = os.Remove(path)
+		return nil, ErrCacheMiss
+	}
+
+	expiresAt := int64(binary.BigEndian.Uint64(data))
+	if expiresAt != 0 && time.Now().UnixNano() >= expiresAt {
+		_ = os.Remove(path)
+		return nil, ErrCacheMiss
+	}
+	return data[diskCacheHeaderLen:], nil
+}
+
+// Set implements CacheStore. The entry is written to a temporary file that
+// replaces the previous entry once it is complete. A non-positive ttl keeps
+// the entry until it is replaced or deleted.
+func (d *DiskCache) Set(_ context.Context, key string, entry []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixNano()
+	}
+	data := binary.BigEndian.AppendUint64(make([]byte, 0, diskCacheHeaderLen+len(entry)), uint64(expiresAt))
+	data = append(data, entry...)
+
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("disk cache: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("disk cache: %w", err)
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Close()
+	} else {
+		_ = f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("disk cache: %w", err)
+	}
+	return nil
+}
+
+// Delete implements CacheStore.
+func (d *DiskCache) Delete(_ context.Context, key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("disk cache: %w", err)
+	}
+	return nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(d.dir, name[:2], name)
+}
+
+// RedisCache is a CacheStore that keeps its entries in Redis. Expired entries
+// are evicted by Redis.
+type RedisCache struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisCache returns a RedisCache that stores its entries via client under
+// keys that start with prefix.
+func NewRedisCache(client redis.UniversalClient, prefix string) *RedisCache {
+	return &RedisCache{client: client, prefix: prefix}
+}
+
+// Get implements CacheStore.
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	} else if err != nil {
+		return nil, fmt.Errorf("redis cache: %w", err)
+	}
+	return data, nil
+}
+
+// Set implements CacheStore. A non-positive ttl keeps the entry until it is
+// replaced or deleted.
+func (r *RedisCache) Set(ctx context.Context, key string, entry []byte, ttl time.Duration) error {
+	if err := r.client.Set(ctx, r.prefix+key, entry, max(ttl, 0)).Err(); err != nil {
+		return fmt.Errorf("redis cache: %w", err)
+	}
+	return nil
+}
+
+// Delete implements CacheStore.
+func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, r.prefix+key).Err(); err != nil {
+		return fmt.Errorf("redis cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(CacheTestSuite))
+
+type CacheTestSuite struct {
+	now  time.Time
+	reqs []*http.Request
+
+	// The responses returned by the fake host in order.
+	responses []func(req *http.Request) *http.Response
+}
+
+func (s *CacheTestSuite) SetUpTest(c *gc.C) {
+	s.now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.reqs, s.responses = nil, nil
+}
+
+func (s *CacheTestSuite) TestFreshEntriesAndRevalidation(c *gc.C) {
+	f := s.cachingFetcher(c, CacheConfig{})
+	s.respond(func(req *http.Request) *http.Response {
+		res := okResponse(req, "v1")
+		res.Header.Set("Cache-Control", "max-age=60")
+		res.Header.Set("ETag", `"v1"`)
+		return res
+	}, func(req *http.Request) *http.Response {
+		res := statusResponse(req, http.StatusNotModified, "")
+		res.Header.Set("Cache-Control", "max-age=120")
+		return res
+	})
+
+	s.assertFetch(c, f, "v1", CacheMiss)
+	s.now = s.now.Add(30 * time.Second)
+	res := s.assertFetch(c, f, "v1", CacheHit)
+	c.Assert(res.Header.Get("Age"), gc.Equals, "30")
+	c.Assert(s.reqs, gc.HasLen, 1)
+
+	// Stale entries are revalidated and the headers of the 304 response
+	// extend their lifetime.
+	s.now = s.now.Add(time.Minute)
+	s.assertFetch(c, f, "v1", CacheRevalidated)
+	c.Assert(s.reqs, gc.HasLen, 2)
+	c.Assert(s.reqs[1].Header.Get("If-None-Match"), gc.Equals, `"v1"`)
+	s.now = s.now.Add(90 * time.Second)
+	s.assertFetch(c, f, "v1", CacheHit)
+	c.Assert(s.reqs, gc.HasLen, 2)
+}
+
+func (s *CacheTestSuite) TestChangedResponsesReplaceEntries(c *gc.C) {
+	f := s.cachingFetcher(c, CacheConfig{})
+	lastModified := s.now.Add(-time.Hour).Format(http.TimeFormat)
+	s.respond(func(req *http.Request) *http.Response {
+		res := okResponse(req, "v1")
+		res.Header.Set("Date", s.now.Format(http.TimeFormat))
+		res.Header.Set("Last-Modified", lastModified)
+		return res
+	}, func(req *http.Request) *http.Response {
+		return okResponse(req, "v2")
+	})
+
+	// The lifetime of the entry is a tenth of the time since the page was
+	// last modified.
+	s.assertFetch(c, f, "v1", CacheMiss)
+	s.now = s.now.Add(5 * time.Minute)
+	s.assertFetch(c, f, "v1", CacheHit)
+	s.now = s.now.Add(2 * time.Minute)
+	s.assertFetch(c, f, "v2", CacheMiss)
+	c.Assert(s.reqs, gc.HasLen, 2)
+	c.Assert(s.reqs[1].Header.Get("If-Modified-Since"), gc.Equals, lastModified)
+}
+
+func (s *CacheTestSuite) TestUncacheableResponses(c *gc.C) {
+	f := s.cachingFetcher(c, CacheConfig{})
+	for _, header := range []string{"Cache-Control: no-store, max-age=60", "Vary: *", "ETag: ignored"} {
+		name, value, _ := strings.Cut(header, ": ")
+		s.reqs = nil
+		s.respond(func(req *http.Request) *http.Response {
+			res := okResponse(req, "body")
+			res.Header.Set("Cache-Control", "max-age=60")
+			res.Header.Set(name, value)
+			if name == "ETag" {
+				res.StatusCode = http.StatusInternalServerError
+			}
+			return res
+		}, func(req *http.Request) *http.Response {
+			return okResponse(req, "body")
+		})
+
+		url := "http://example.com/" + name
+		s.assertFetchURL(c, f, url, "body", CacheMiss)
+		s.assertFetchURL(c, f, url, "body", CacheMiss)
+		c.Assert(s.reqs, gc.HasLen, 2, gc.Commentf("response with header %q", header))
+	}
+}
+
+func (s *CacheTestSuite) TestRequestsBypassingTheCache(c *gc.C) {
+	f := s.cachingFetcher(c, CacheConfig{})
+	for i := 0; i < 3; i++ {
+		s.respond(func(req *http.Request) *http.Response {
+			res := okResponse(req, "body")
+			res.Header.Set("Cache-Control", "max-age=60")
+			return res
+		})
+	}
+	s.assertFetch(c, f, "body", CacheMiss)
+
+	// Requests with conditional headers are passed through untouched.
+	req := newRequest(c, "http://example.com/")
+	req.Header.Set("If-None-Match", `"v0"`)
+	res, err := f.Fetch(req)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Header.Get(CacheStatusHeader), gc.Equals, "")
+
+	// A no-cache directive forces a refetch.
+	req = newRequest(c, "http://example.com/")
+	req.Header.Set("Cache-Control", "no-cache")
+	res, err = f.Fetch(req)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Header.Get(CacheStatusHeader), gc.Equals, CacheMiss)
+	c.Assert(s.reqs, gc.HasLen, 3)
+}
+
+func (s *CacheTestSuite) TestMinTTL(c *gc.C) {
+	f := s.cachingFetcher(c, CacheConfig{MinTTL: time.Hour})
+	s.respond(func(req *http.Request) *http.Response {
+		res := okResponse(req, "moved")
+		res.Request = newRequest(c, "http://example.com/final")
+		res.Header.Set("Cache-Control", "no-cache")
+		return res
+	})
+
+	s.assertFetch(c, f, "moved", CacheMiss)
+	s.now = s.now.Add(59 * time.Minute)
+	res := s.assertFetch(c, f, "moved", CacheHit)
+	c.Assert(res.Request.URL.String(), gc.Equals, "http://example.com/final")
+	c.Assert(s.reqs, gc.HasLen, 1)
+}
+
+func (s *CacheTestSuite) TestLargeBodiesAreNotStored(c *gc.C) {
+	f := s.cachingFetcher(c, CacheConfig{MinTTL: time.Hour, MaxEntrySize: 4})
+	for i := 0; i < 2; i++ {
+		s.respond(func(req *http.Request) *http.Response {
+			return okResponse(req, "too large")
+		})
+	}
+	s.assertFetch(c, f, "too large", CacheMiss)
+	s.assertFetch(c, f, "too large", CacheMiss)
+	c.Assert(s.reqs, gc.HasLen, 2)
+}
+
+func (s *CacheTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := Cache(CacheConfig{MinTTL: -1, Retention: -1, MaxEntrySize: -1})
+	c.Assert(err, gc.ErrorMatches, `(?s)cache: config validation failed: .*cache store has not been provided.*min TTL must not be negative.*retention must not be negative.*max entry size must not be negative.*`)
+}
+
+func (s *CacheTestSuite) TestDiskCache(c *gc.C) {
+	store, err := NewDiskCache(c.MkDir())
+	c.Assert(err, gc.IsNil)
+	s.testStore(c, store)
+}
+
+func (s *CacheTestSuite) TestRedisCache(c *gc.C) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		c.Skip("Missing REDIS_ADDR envvar; skipping redis cache test")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer func() { _ = client.Close() }()
+	s.testStore(c, NewRedisCache(client, "fetch-cache-test:"))
+}
+
+func (s *CacheTestSuite) testStore(c *gc.C, store CacheStore) {
+	ctx := context.Background()
+	_, err := store.Get(ctx, "http://example.com/")
+	c.Assert(err, gc.Equals, ErrCacheMiss)
+
+	c.Assert(store.Set(ctx, "http://example.com/", []byte("entry"), time.Hour), gc.IsNil)
+	entry, err := store.Get(ctx, "http://example.com/")
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(entry), gc.Equals, "entry")
+
+	c.Assert(store.Delete(ctx, "http://example.com/"), gc.IsNil)
+	c.Assert(store.Delete(ctx, "http://example.com/"), gc.IsNil)
+	_, err = store.Get(ctx, "http://example.com/")
+	c.Assert(err, gc.Equals, ErrCacheMiss)
+
+	c.Assert(store.Set(ctx, "http://example.com/expiring", []byte("entry"), time.Millisecond), gc.IsNil)
+	time.Sleep(10 * time.Millisecond)
+	_, err = store.Get(ctx, "http://example.com/expiring")
+	c.Assert(err, gc.Equals, ErrCacheMiss)
+}
+
+// cachingFetcher returns a Fetcher that caches the responses of the fake host
+// in a DiskCache.
+func (s *CacheTestSuite) cachingFetcher(c *gc.C, cfg CacheConfig) Fetcher {
+	store, err := NewDiskCache(c.MkDir())
+	c.Assert(err, gc.IsNil)
+	cfg.Store = store
+	cfg.Clock = func() time.Time { return s.now }
+	mw, err := Cache(cfg)
+	c.Assert(err, gc.IsNil)
+	return mw(Func(func(req *http.Request) (*http.Response, error) {
+		c.Assert(s.responses, gc.Not(gc.HasLen), 0, gc.Commentf("unexpected request for %s", req.URL))
+		s.reqs = append(s.reqs, req)
+		res := s.responses[0](req)
+		s.responses = s.responses[1:]
+		return res, nil
+	}))
+}
+
+func (s *CacheTestSuite) respond(responses ...func(req *http.Request) *http.Response) {
+	s.responses = append(s.responses, responses...)
+}
+
+func (s *CacheTestSuite) assertFetch(c *gc.C, f Fetcher, expBody, expStatus string) *http.Response {
+	return s.assertFetchURL(c, f, "http://example.com/", expBody, expStatus)
+}
+
+func (s *CacheTestSuite) assertFetchURL(c *gc.C, f Fetcher, url, expBody, expStatus string) *http.Response {
+	res, err := f.Fetch(newRequest(c, url))
+	c.Assert(err, gc.IsNil)
+	body, err := io.ReadAll(res.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Body.Close(), gc.IsNil)
+	c.Assert(string(body), gc.Equals, expBody)
+	c.Assert(res.Header.Get(CacheStatusHeader), gc.Equals, expStatus)
+	return res
+}
@@ -0,0 +1,181 @@
+package fetch
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding lists the content encodings that NewBodyDecoder can decode
+// in the format of an Accept-Encoding request header. Setting the header
+// explicitly disables the transparent gzip decoding of http.Transport.
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+// The maximum window size of zstd-encoded responses. RFC 9659 requires HTTP
+// senders to limit the window to 8 MiB.
+const maxZstdWindow = 8 << 20
+
+// UnsupportedEncodingError is returned by NewBodyDecoder for content
+// encodings that cannot be decoded.
+type UnsupportedEncodingError struct {
+	Encoding string
+}
+
+func (e UnsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported content encoding %q", e.Encoding)
+}
+
+// DecodedBody is the decoded response body returned by NewBodyDecoder.
+type DecodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close releases the resources held by the decoders. It does not close the
+// underlying response body.
+func (b *DecodedBody) Close() error {
+	var err error
+	for _, c := range b.closers {
+		if cErr := c.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// NewBodyDecoder returns a reader for the decoded contents of r according to
+// the value of its Content-Encoding header. Encodings are listed in the order
+// in which they were applied so they are decoded in reverse. It also returns
+// the name of the outermost encoding for reporting purposes ("identity" if
+// the body is not encoded).
+func NewBodyDecoder(r io.Reader, contentEncoding string) (*DecodedBody, string, error) {
+	var encodings []string
+	for _, enc := range strings.Split(contentEncoding, ",") {
+		if enc = strings.ToLower(strings.TrimSpace(enc)); enc != "" && enc != "identity" {
+			encodings = append(encodings, enc)
+		}
+	}
+	if len(encodings) == 0 {
+		return &DecodedBody{Reader: r}, "identity", nil
+	}
+
+	body := &DecodedBody{Reader: r}
+	for i := len(encodings) - 1; i >= 0; i-- {
+		dec, closer, err := newDecoder(body.Reader, encodings[i])
+		if err != nil {
+			_ = body.Close()
+			return nil, encodings[len(encodings)-1], err
+		}
+		body.Reader = dec
+		if closer != nil {
+			body.closers = append(body.closers, closer)
+		}
+	}
+	return body, encodings[len(encodings)-1], nil
+}
+
+// newDecoder returns a reader for the contents of r decoded according to
+// encoding and an optional closer for releasing the resources of the decoder.
+func newDecoder(r io.Reader, encoding string) (io.Reader, io.Closer, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err == io.EOF {
+			// Some hosts send empty bodies with a gzip encoding
+			// (e.g. for error responses).
+			return strings.NewReader(""), nil, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr, nil
+	case "deflate":
+		// The deflate encoding is defined as a zlib stream but some
+		// hosts send raw deflate data instead.
+		br := bufio.NewReader(r)
+		header, err := br.Peek(2)
+		if err == io.EOF && len(header) == 0 {
+			return strings.NewReader(""), nil, nil
+		}
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, nil, err
+			}
+			return zr, zr, nil
+		}
+		fr := flate.NewReader(br)
+		return fr, fr, nil
+	case "br":
+		return brotli.NewReader(r), nil, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindow))
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.IOReadCloser(), nil
+	default:
+		return nil, nil, UnsupportedEncodingError{Encoding: encoding}
+	}
+}
+
+// Decode returns a middleware that negotiates the content encodings listed in
+// AcceptEncoding and decodes the bodies of the responses. Like
+// http.Transport, it leaves requests that already specify an Accept-Encoding
+// header and their responses untouched; the caller is then responsible for
+// decoding the body. Decoded responses have their Content-Encoding and
+// Content-Length headers removed and their Uncompressed field set.
+func Decode() Middleware {
+	return func(next Fetcher) Fetcher {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Accept-Encoding") != "" {
+				return next.Fetch(req)
+			}
+
+			req = req.Clone(req.Context())
+			req.Header.Set("Accept-Encoding", AcceptEncoding)
+			res, err := next.Fetch(req)
+			if err != nil {
+				return nil, err
+			}
+			contentEncoding := res.Header.Get("Content-Encoding")
+			if contentEncoding == "" {
+				return res, nil
+			}
+
+			body, _, err := NewBodyDecoder(res.Body, contentEncoding)
+			if err != nil {
+				_ = res.Body.Close()
+				return nil, fmt.Errorf("decode response body: %w", err)
+			}
+			res.Body = &decodedReadCloser{DecodedBody: body, raw: res.Body}
+			res.Header.Del("Content-Encoding")
+			res.Header.Del("Content-Length")
+			res.ContentLength = -1
+			res.Uncompressed = true
+			return res, nil
+		})
+	}
+}
+
+// decodedReadCloser is the body of a response decoded by the Decode
+// middleware. Closing it also closes the original body.
+type decodedReadCloser struct {
+	*DecodedBody
+	raw io.ReadCloser
+}
+
+func (b *decodedReadCloser) Close() error {
+	err := b.DecodedBody.Close()
+	if rawErr := b.raw.Close(); err == nil {
+		err = rawErr
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ContentEncodingTestSuite))
+
+type ContentEncodingTestSuite struct{}
+
+func (s *ContentEncodingTestSuite) TestDecodeEncodings(c *gc.C) {
+	content := strings.Repeat("<p>Lorem ipsum dolor sit amet</p>", 100)
+	specs := []struct {
+		contentEncoding string
+		expEncoding     string
+	}{
+		{contentEncoding: "", expEncoding: "identity"},
+		{contentEncoding: "identity", expEncoding: "identity"},
+		{contentEncoding: "gzip", expEncoding: "gzip"},
+		{contentEncoding: "x-gzip", expEncoding: "x-gzip"},
+		{contentEncoding: "deflate", expEncoding: "deflate"},
+		{contentEncoding: "raw-deflate", expEncoding: "deflate"},
+		{contentEncoding: "br", expEncoding: "br"},
+		{contentEncoding: "zstd", expEncoding: "zstd"},
+		{contentEncoding: "gzip, br", expEncoding: "br"},
+		{contentEncoding: " ZSTD ", expEncoding: "zstd"},
+	}
+
+	for specIndex, spec := range specs {
+		c.Logf("[spec %d] content encoding: %q", specIndex, spec.contentEncoding)
+		header := spec.contentEncoding
+		if header == "raw-deflate" {
+			header = "deflate"
+		}
+
+		body, encoding, err := NewBodyDecoder(bytes.NewReader(encodeBody(c, spec.contentEncoding, content)), header)
+		c.Assert(err, gc.IsNil)
+		c.Assert(encoding, gc.Equals, spec.expEncoding)
+		got, err := io.ReadAll(body)
+		c.Assert(err, gc.IsNil)
+		c.Assert(body.Close(), gc.IsNil)
+		c.Assert(string(got), gc.Equals, content)
+	}
+}
+
+func (s *ContentEncodingTestSuite) TestDecodeEmptyBody(c *gc.C) {
+	for _, enc := range []string{"gzip", "deflate"} {
+		body, _, err := NewBodyDecoder(strings.NewReader(""), enc)
+		c.Assert(err, gc.IsNil)
+		got, err := io.ReadAll(body)
+		c.Assert(err, gc.IsNil)
+		c.Assert(got, gc.HasLen, 0)
+	}
+}
+
+func (s *ContentEncodingTestSuite) TestUnsupportedEncoding(c *gc.C) {
+	_, _, err := NewBodyDecoder(strings.NewReader("data"), "gzip, compress")
+	c.Assert(err, gc.FitsTypeOf, UnsupportedEncodingError{})
+	c.Assert(err, gc.ErrorMatches, `unsupported content encoding "compress"`)
+}
+
+func (s *ContentEncodingTestSuite) TestDecodeMiddleware(c *gc.C) {
+	content := "<p>Lorem ipsum dolor sit amet</p>"
+	f := Decode()(Func(func(req *http.Request) (*http.Response, error) {
+		res := okResponse(req, string(encodeBody(c, "br", content)))
+		res.Header.Set("Content-Encoding", "br")
+		res.Header.Set("Content-Length", "42")
+		res.Header.Set("X-Accept-Encoding", req.Header.Get("Accept-Encoding"))
+		return res, nil
+	}))
+
+	res, err := f.Fetch(newRequest(c, "http://example.com/"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Header.Get("X-Accept-Encoding"), gc.Equals, AcceptEncoding)
+	c.Assert(res.Header.Get("Content-Encoding"), gc.Equals, "")
+	c.Assert(res.Header.Get("Content-Length"), gc.Equals, "")
+	c.Assert(res.Uncompressed, gc.Equals, true)
+	got, err := io.ReadAll(res.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Body.Close(), gc.IsNil)
+	c.Assert(string(got), gc.Equals, content)
+
+	// Responses to requests that negotiate their own encodings are not
+	// decoded.
+	req := newRequest(c, "http://example.com/")
+	req.Header.Set("Accept-Encoding", "br")
+	res, err = f.Fetch(req)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Header.Get("Content-Encoding"), gc.Equals, "br")
+	c.Assert(res.Uncompressed, gc.Equals, false)
+}
+
+// encodeBody encodes content using the comma-separated list of encodings in
+// the order in which they are listed. The "raw-deflate" encoding produces a
+// deflate stream without the zlib wrapper.
+func encodeBody(c *gc.C, encodings, content string) []byte {
+	data := []byte(content)
+	for _, enc := range strings.Split(encodings, ",") {
+		var (
+			buf bytes.Buffer
+			w   io.WriteCloser
+			err error
+		)
+		switch strings.ToLower(strings.TrimSpace(enc)) {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "raw-deflate":
+			w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		case "zstd":
+			w, err = zstd.NewWriter(&buf)
+		default:
+			c.Fatalf("unknown encoding %q", enc)
+		}
+		c.Assert(err, gc.IsNil)
+		_, err = w.Write(data)
+		c.Assert(err, gc.IsNil)
+		c.Assert(w.Close(), gc.IsNil)
+		data = buf.Bytes()
+	}
+	return data
+}
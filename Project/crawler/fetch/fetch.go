@@ -0,0 +1,193 @@
+// Package fetch provides the Fetcher abstraction that the crawler uses for
+// retrieving links and a set of middlewares that add behavior to a Fetcher:
+// retries with backoff, redirect following, per-request timeouts, body size
+// limits, user-agent rotation, content decoding, response caching and routing
+// through a pool of proxies. Pages that are rendered client-side can be loaded
+// through a headless browser via the Render middleware.
+//
+// New assembles a Fetcher from a Config with all middlewares applied in the
+// recommended order. Custom chains can be assembled with Chain and tests can
+// inject a fake Fetcher via Func.
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Fetcher is implemented by objects that can execute HTTP requests.
+type Fetcher interface {
+	// Fetch executes req and returns its response. Requests are cancelled
+	// when their context is done.
+	Fetch(req *http.Request) (*http.Response, error)
+}
+
+// Func is an adapter that allows ordinary functions to be used as Fetchers.
+type Func func(req *http.Request) (*http.Response, error)
+
+// Fetch implements Fetcher by calling f(req).
+func (f Func) Fetch(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Fetcher with additional behavior.
+type Middleware func(next Fetcher) Fetcher
+
+// Chain wraps f with the specified middlewares. The first middleware is the
+// outermost one, i.e. it is the first to see each request and the last to see
+// each response.
+func Chain(f Fetcher, mws ...Middleware) Fetcher {
+	for i := len(mws) - 1; i >= 0; i-- {
+		f = mws[i](f)
+	}
+	return f
+}
+
+// Transport returns a Fetcher that executes requests via a single round trip
+// of rt. Unlike http.Client, it neither follows redirects nor imposes a
+// timeout; see the FollowRedirects and Timeout middlewares. If rt is nil,
+// http.DefaultTransport is used.
+func Transport(rt http.RoundTripper) Fetcher {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return Func(rt.RoundTrip)
+}
+
+// Config encapsulates the settings for a Fetcher created by New.
+type Config struct {
+	// The transport for executing requests. Defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// Settings for retrying failed requests. Defaults to a single
+	// attempt.
+	Retry RetryPolicy
+
+	// The maximum number of redirects to follow for each request.
+	// Defaults to 10. A negative value disables following redirects so
+	// that redirect responses are returned to the caller.
+	MaxRedirects int
+
+	// The maximum time for each attempt, including following redirects
+	// and reading the response body. Zero disables the timeout.
+	Timeout time.Duration
+
+	// The maximum size of a response body after decoding. Zero disables
+	// the limit.
+	MaxBodySize int64
+
+	// An optional list of User-Agent header values that are used in
+	// rotation.
+	UserAgents []string
+
+	// An optional pool of proxies through which requests are routed. If
+	// specified, the Transport must be an *http.Transport.
+	Proxies *ProxyPool
+
+	// Optional settings for rendering the pages of selected domains in a
+	// headless browser. Rendered pages are not subject to the Timeout
+	// (they are limited by the render timeout instead) nor to the
+	// middlewares that follow Render.
+	Render *RenderConfig
+
+	// Optional settings for caching responses. Cached responses are
+	// subject to the MaxBodySize but not to the middlewares that follow
+	// Cache.
+	Cache *CacheConfig
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.Timeout < 0 {
+		err = multierror.Append(err, fmt.Errorf("timeout must not be negative"))
+	}
+	if cfg.MaxBodySize < 0 {
+		err = multierror.Append(err, fmt.Errorf("max body size must not be negative"))
+	}
+	for _, ua := range cfg.UserAgents {
+		if ua == "" {
+			err = multierror.Append(err, fmt.Errorf("user agents must not be empty"))
+			break
+		}
+	}
+	if cfg.Proxies != nil && cfg.Transport != nil {
+		if _, ok := cfg.Transport.(*http.Transport); !ok {
+			err = multierror.Append(err, fmt.Errorf("proxies require an *http.Transport"))
+		}
+	}
+	if retryErr := cfg.Retry.validate(); retryErr != nil {
+		err = multierror.Append(err, retryErr)
+	}
+	if cfg.Render != nil {
+		if renderErr := cfg.Render.validate(); renderErr != nil {
+			err = multierror.Append(err, renderErr)
+		}
+	}
+	if cfg.Cache != nil {
+		if cacheErr := cfg.Cache.validate(); cacheErr != nil {
+			err = multierror.Append(err, cacheErr)
+		}
+	}
+	if cfg.MaxRedirects == 0 {
+		cfg.MaxRedirects = 10
+	}
+	return err
+}
+
+// New returns a Fetcher that applies the middlewares enabled by cfg in the
+// following order:
+//
+//   - Retry retries failed attempts.
+//   - RotateUserAgents selects the User-Agent of each attempt.
+//   - MaxBodySize limits the size of the decoded response body.
+//   - Cache serves responses from the cache.
+//   - Render loads the pages of the opted-in domains in a headless
+//     browser.
+//   - Timeout limits the duration of each attempt.
+//   - Decode decodes the response body.
+//   - FollowRedirects follows redirects to the final response.
+//   - The middleware of the proxy pool routes each request, including
+//     each redirect, through the proxy of its host.
+func New(cfg Config) (Fetcher, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("fetch: config validation failed: %w", err)
+	}
+
+	mws := []Middleware{Retry(cfg.Retry)}
+	if len(cfg.UserAgents) != 0 {
+		mws = append(mws, RotateUserAgents(cfg.UserAgents))
+	}
+	if cfg.MaxBodySize > 0 {
+		mws = append(mws, MaxBodySize(cfg.MaxBodySize))
+	}
+	if cfg.Cache != nil {
+		mws = append(mws, cache(*cfg.Cache))
+	}
+	if cfg.Render != nil {
+		mws = append(mws, render(*cfg.Render))
+	}
+	if cfg.Timeout > 0 {
+		mws = append(mws, Timeout(cfg.Timeout))
+	}
+	mws = append(mws, Decode())
+	if cfg.MaxRedirects > 0 {
+		mws = append(mws, FollowRedirects(cfg.MaxRedirects))
+	}
+
+	transport := cfg.Transport
+	if cfg.Proxies != nil {
+		base, ok := transport.(*http.Transport)
+		if !ok {
+			base = http.DefaultTransport.(*http.Transport)
+		}
+		proxied := base.Clone()
+		proxied.Proxy = cfg.Proxies.Proxy
+		transport = proxied
+		mws = append(mws, cfg.Proxies.Middleware())
+	}
+	return Chain(Transport(transport), mws...), nil
+}
@@ -0,0 +1,140 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(FetchTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type FetchTestSuite struct{}
+
+func (s *FetchTestSuite) TestChainOrder(c *gc.C) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Fetcher) Fetcher {
+			return Func(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return next.Fetch(req)
+			})
+		}
+	}
+	f := Chain(Func(func(req *http.Request) (*http.Response, error) {
+		calls = append(calls, "base")
+		return okResponse(req, ""), nil
+	}), mw("outer"), mw("inner"))
+
+	_, err := f.Fetch(newRequest(c, "http://example.com/"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(calls, gc.DeepEquals, []string{"outer", "inner", "base"})
+}
+
+func (s *FetchTestSuite) TestNew(c *gc.C) {
+	content := strings.Repeat("<p>Lorem ipsum</p>", 10)
+	var (
+		mu         sync.Mutex
+		userAgents []string
+		attempts   int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+		case "/flaky":
+			if attempts++; attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(encodeBody(c, "gzip", content))
+		case "/large":
+			_, _ = io.WriteString(w, strings.Repeat("a", 1024))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	f, err := New(Config{
+		Retry:        RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+		MaxRedirects: 2,
+		Timeout:      50 * time.Millisecond,
+		MaxBodySize:  512,
+		UserAgents:   []string{"ua-1", "ua-2"},
+	})
+	c.Assert(err, gc.IsNil)
+
+	for _, path := range []string{"/old", "/flaky"} {
+		res, err := f.Fetch(newRequest(c, srv.URL+path))
+		c.Assert(err, gc.IsNil)
+		body, err := io.ReadAll(res.Body)
+		c.Assert(err, gc.IsNil)
+		c.Assert(res.Body.Close(), gc.IsNil)
+		c.Assert(string(body), gc.Equals, content)
+		c.Assert(res.Request.URL.Path, gc.Equals, "/page")
+	}
+	// Redirects are followed with the user agent of their attempt.
+	c.Assert(userAgents, gc.DeepEquals, []string{"ua-1", "ua-1", "ua-2", "ua-1", "ua-1"})
+
+	res, err := f.Fetch(newRequest(c, srv.URL+"/large"))
+	c.Assert(err, gc.IsNil)
+	_, err = io.ReadAll(res.Body)
+	c.Assert(err, gc.Equals, ErrBodyTooLarge)
+	c.Assert(res.Body.Close(), gc.IsNil)
+
+	_, err = f.Fetch(newRequest(c, srv.URL+"/slow"))
+	c.Assert(err, gc.ErrorMatches, ".*context deadline exceeded")
+
+	_, err = f.Fetch(newRequest(c, srv.URL+"/loop"))
+	c.Assert(err, gc.ErrorMatches, ".*stopped after 2 redirects: too many redirects")
+}
+
+func (s *FetchTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := New(Config{
+		Retry:       RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Second},
+		Timeout:     -1,
+		MaxBodySize: -1,
+		UserAgents:  []string{""},
+		Cache:       &CacheConfig{},
+	})
+	c.Assert(err, gc.ErrorMatches, `(?s)fetch: config validation failed: .*timeout must not be negative.*max body size must not be negative.*user agents must not be empty.*max retry delay must not be lower than the base delay.*cache store has not been provided.*`)
+}
+
+func newRequest(c *gc.C, rawURL string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	c.Assert(err, gc.IsNil)
+	return req
+}
+
+func okResponse(req *http.Request, body string) *http.Response {
+	return statusResponse(req, http.StatusOK, body)
+}
+
+func statusResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
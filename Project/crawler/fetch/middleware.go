@@ -0,0 +1,343 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrTooManyRedirects is returned by fetchers with the FollowRedirects
+	// middleware if a redirect chain exceeds the configured limit.
+	ErrTooManyRedirects = errors.New("too many redirects")
+
+	// ErrBodyTooLarge is returned when reading a response body that
+	// exceeds the limit configured via the MaxBodySize middleware.
+	ErrBodyTooLarge = errors.New("response body too large")
+)
+
+// The maximum number of bytes read from the body of a discarded response so
+// that the connection can be reused.
+const maxDrainBytes = 4 << 10
+
+// RetryPolicy describes how failed requests are retried.
+type RetryPolicy struct {
+	// The maximum number of attempts for each request, including the
+	// first one. Values below 2 disable retries.
+	MaxAttempts int
+
+	// The delay before the first retry. It doubles with each further
+	// retry. Defaults to 1s.
+	BaseDelay time.Duration
+
+	// The upper bound for the delay between attempts, including the
+	// delays requested via Retry-After headers. Responses that request a
+	// longer delay are returned to the caller. Defaults to 30s.
+	MaxDelay time.Duration
+}
+
+func (p *RetryPolicy) validate() error {
+	if p.MaxAttempts < 0 || p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("retry attempts and delays must not be negative")
+	}
+	if p.MaxDelay != 0 && p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("max retry delay must not be lower than the base delay")
+	}
+	return nil
+}
+
+// Retry returns a middleware that retries requests that fail with a transport
+// error or a 429, 502, 503 or 504 response according to policy. Retries
+// honor Retry-After response headers. Requests whose context is done and
+// requests with a body that cannot be replayed (see http.Request.GetBody)
+// are not retried.
+func Retry(policy RetryPolicy) Middleware {
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = time.Second
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 30 * time.Second
+	}
+	return func(next Fetcher) Fetcher {
+		if policy.MaxAttempts < 2 {
+			return next
+		}
+		return Func(func(req *http.Request) (*http.Response, error) {
+			attemptReq := req
+			for attempt := 1; ; attempt++ {
+				res, err := next.Fetch(attemptReq)
+				if attempt == policy.MaxAttempts || !replayable(req) {
+					return res, err
+				}
+
+				delay := policy.backoff(attempt)
+				if err != nil {
+					if req.Context().Err() != nil || errors.Is(err, ErrTooManyRedirects) {
+						return nil, err
+					}
+				} else {
+					if !retryableStatus(res.StatusCode) {
+						return res, nil
+					}
+					if pause, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+						if pause > policy.MaxDelay {
+							return res, nil
+						}
+						delay = max(delay, pause)
+					}
+					discard(res)
+				}
+
+				if err = sleep(req.Context(), delay); err != nil {
+					return nil, err
+				}
+				if attemptReq, err = replay(req); err != nil {
+					return nil, err
+				}
+			}
+		})
+	}
+}
+
+// backoff returns the delay before the retry that follows the specified
+// attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// FollowRedirects returns a middleware that follows up to maxRedirects
+// redirects for each request and returns the final response. Requests that
+// exceed the limit fail with ErrTooManyRedirects. The wrapped Fetcher must
+// not follow redirects itself (see Transport).
+//
+// As with http.Client, 301, 302 and 303 redirects of requests other than GET
+// and HEAD are followed with a GET request, and sensitive headers such as
+// Authorization and Cookie are not forwarded to other hosts.
+func FollowRedirects(maxRedirects int) Middleware {
+	return func(next Fetcher) Fetcher {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			for redirects := 0; ; redirects++ {
+				res, err := next.Fetch(req)
+				if err != nil {
+					return nil, err
+				}
+				nextReq, err := redirectRequest(req, res)
+				if err != nil || nextReq == nil {
+					return res, err
+				}
+
+				discard(res)
+				if redirects == maxRedirects {
+					return nil, fmt.Errorf("%s: stopped after %d redirects: %w", req.URL, maxRedirects, ErrTooManyRedirects)
+				}
+				req = nextReq
+			}
+		})
+	}
+}
+
+// redirectRequest returns the request for following the redirect response res
+// to req or nil if res is not a redirect that can be followed.
+func redirectRequest(req *http.Request, res *http.Response) (*http.Request, error) {
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		return nil, nil
+	}
+
+	keepMethod := false
+	switch res.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		keepMethod = req.Method == http.MethodGet || req.Method == http.MethodHead
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if !replayable(req) {
+			return nil, nil
+		}
+		keepMethod = true
+	default:
+		return nil, nil
+	}
+
+	u, err := req.URL.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid redirect location %q: %w", req.URL, loc, err)
+	}
+
+	var nextReq *http.Request
+	if keepMethod {
+		if nextReq, err = replay(req); err != nil {
+			return nil, err
+		}
+	} else {
+		nextReq = req.Clone(req.Context())
+		nextReq.Method, nextReq.Body, nextReq.GetBody, nextReq.ContentLength = http.MethodGet, nil, nil, 0
+		nextReq.Header.Del("Content-Type")
+		nextReq.Header.Del("Content-Length")
+	}
+	nextReq.URL, nextReq.Host = u, ""
+	if !strings.EqualFold(u.Hostname(), req.URL.Hostname()) {
+		for _, h := range []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"} {
+			nextReq.Header.Del(h)
+		}
+	}
+	return nextReq, nil
+}
+
+// Timeout returns a middleware that cancels each request if it does not
+// complete within d. The deadline also applies to reading the response
+// body.
+func Timeout(d time.Duration) Middleware {
+	return func(next Fetcher) Fetcher {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			res, err := next.Fetch(req.WithContext(ctx))
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+			return res, nil
+		})
+	}
+}
+
+// cancelOnClose is a response body that cancels the context of its request
+// when it is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// MaxBodySize returns a middleware that fails reads from response bodies that
+// exceed n bytes with ErrBodyTooLarge. The limit applies to the body as
+// returned by the wrapped Fetcher, i.e. to the decoded body if it is wrapped
+// by the Decode middleware.
+func MaxBodySize(n int64) Middleware {
+	return func(next Fetcher) Fetcher {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			res, err := next.Fetch(req)
+			if err != nil {
+				return nil, err
+			}
+			res.Body = &limitedBody{ReadCloser: res.Body, remaining: n}
+			return res, nil
+		})
+	}
+}
+
+// limitedBody is a response body that fails once more than a given number of
+// bytes is read from it.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// Read one byte past the limit to tell bodies that end exactly at the
+	// limit apart from the ones that exceed it.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n, b.remaining = int(b.remaining), 0
+		return n, ErrBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// RotateUserAgents returns a middleware that sets the User-Agent header of
+// each request to the next entry of userAgents in round-robin order. If
+// userAgents is empty, requests are passed through unchanged.
+func RotateUserAgents(userAgents []string) Middleware {
+	userAgents = append([]string(nil), userAgents...)
+	var counter atomic.Uint64
+	return func(next Fetcher) Fetcher {
+		if len(userAgents) == 0 {
+			return next
+		}
+		return Func(func(req *http.Request) (*http.Response, error) {
+			ua := userAgents[(counter.Add(1)-1)%uint64(len(userAgents))]
+			req = req.Clone(req.Context())
+			req.Header.Set("User-Agent", ua)
+			return next.Fetch(req)
+		})
+	}
+}
+
+// replayable returns true if req can be sent again.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// replay returns a copy of req that can be sent again.
+func replay(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// discard drains and closes the body of a response that is not returned to
+// the caller so that its connection can be reused.
+func discard(res *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxDrainBytes))
+	_ = res.Body.Close()
+}
+
+// parseRetryAfter parses the value of a Retry-After header which is either a
+// number of seconds or an HTTP date and returns the requested pause.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second, secs > 0
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
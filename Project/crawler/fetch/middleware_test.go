@@ -0,0 +1,181 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(MiddlewareTestSuite))
+
+type MiddlewareTestSuite struct{}
+
+func (s *MiddlewareTestSuite) TestRetry(c *gc.C) {
+	var attempts int
+	f := Retry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})(Func(func(req *http.Request) (*http.Response, error) {
+		switch attempts++; attempts {
+		case 1:
+			return nil, errors.New("connection reset")
+		case 2:
+			return statusResponse(req, http.StatusBadGateway, ""), nil
+		default:
+			return okResponse(req, "ok"), nil
+		}
+	}))
+
+	res, err := f.Fetch(newRequest(c, "http://example.com/"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.StatusCode, gc.Equals, http.StatusOK)
+	c.Assert(attempts, gc.Equals, 3)
+}
+
+func (s *MiddlewareTestSuite) TestRetryGivesUp(c *gc.C) {
+	var attempts int
+	f := Retry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second})(Func(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		res := statusResponse(req, http.StatusServiceUnavailable, "")
+		if strings.HasSuffix(req.URL.Path, "/later") {
+			res.Header.Set("Retry-After", "60")
+		}
+		return res, nil
+	}))
+
+	// The last response is returned once all attempts have failed.
+	res, err := f.Fetch(newRequest(c, "http://example.com/"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.StatusCode, gc.Equals, http.StatusServiceUnavailable)
+	c.Assert(attempts, gc.Equals, 3)
+
+	// Responses that request a pause beyond the max delay are not
+	// retried.
+	attempts = 0
+	res, err = f.Fetch(newRequest(c, "http://example.com/later"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.StatusCode, gc.Equals, http.StatusServiceUnavailable)
+	c.Assert(attempts, gc.Equals, 1)
+}
+
+func (s *MiddlewareTestSuite) TestRetryStopsWhenContextIsDone(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts int
+	f := Retry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour})(Func(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		cancel()
+		return nil, errors.New("connection reset")
+	}))
+
+	_, err := f.Fetch(newRequest(c, "http://example.com/").WithContext(ctx))
+	c.Assert(err, gc.ErrorMatches, "connection reset")
+	c.Assert(attempts, gc.Equals, 1)
+}
+
+func (s *MiddlewareTestSuite) TestRetryBackoff(c *gc.C) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	var delays []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		delays = append(delays, policy.backoff(attempt))
+	}
+	c.Assert(delays, gc.DeepEquals, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second})
+}
+
+func (s *MiddlewareTestSuite) TestFollowRedirects(c *gc.C) {
+	var reqs []*http.Request
+	f := FollowRedirects(5)(Func(func(req *http.Request) (*http.Response, error) {
+		reqs = append(reqs, req)
+		switch req.URL.String() {
+		case "http://example.com/form":
+			res := statusResponse(req, http.StatusSeeOther, "")
+			res.Header.Set("Location", "done")
+			return res, nil
+		case "http://example.com/done":
+			res := statusResponse(req, http.StatusFound, "")
+			res.Header.Set("Location", "https://other.example/final")
+			return res, nil
+		default:
+			return okResponse(req, "final"), nil
+		}
+	}))
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/form", strings.NewReader("q=1"))
+	c.Assert(err, gc.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Cookie", "session=1")
+	res, err := f.Fetch(req)
+	c.Assert(err, gc.IsNil)
+	body, err := io.ReadAll(res.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(body), gc.Equals, "final")
+
+	c.Assert(reqs, gc.HasLen, 3)
+	c.Assert(reqs[1].Method, gc.Equals, http.MethodGet)
+	c.Assert(reqs[1].Body, gc.IsNil)
+	c.Assert(reqs[1].Header.Get("Content-Type"), gc.Equals, "")
+	c.Assert(reqs[1].Header.Get("Cookie"), gc.Equals, "session=1")
+	// Cookies are not forwarded to other hosts.
+	c.Assert(reqs[2].URL.String(), gc.Equals, "https://other.example/final")
+	c.Assert(reqs[2].Header.Get("Cookie"), gc.Equals, "")
+}
+
+func (s *MiddlewareTestSuite) TestMaxBodySize(c *gc.C) {
+	f := MaxBodySize(4)(Func(func(req *http.Request) (*http.Response, error) {
+		return okResponse(req, strings.TrimPrefix(req.URL.Path, "/")), nil
+	}))
+
+	res, err := f.Fetch(newRequest(c, "http://example.com/abcd"))
+	c.Assert(err, gc.IsNil)
+	body, err := io.ReadAll(res.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(body), gc.Equals, "abcd")
+
+	res, err = f.Fetch(newRequest(c, "http://example.com/abcde"))
+	c.Assert(err, gc.IsNil)
+	body, err = io.ReadAll(res.Body)
+	c.Assert(err, gc.Equals, ErrBodyTooLarge)
+	c.Assert(string(body), gc.Equals, "abcd")
+}
+
+func (s *MiddlewareTestSuite) TestTimeout(c *gc.C) {
+	var reqCtx context.Context
+	f := Timeout(time.Minute)(Func(func(req *http.Request) (*http.Response, error) {
+		reqCtx = req.Context()
+		return okResponse(req, "ok"), nil
+	}))
+
+	res, err := f.Fetch(newRequest(c, "http://example.com/"))
+	c.Assert(err, gc.IsNil)
+	_, hasDeadline := reqCtx.Deadline()
+	c.Assert(hasDeadline, gc.Equals, true)
+
+	// The deadline applies until the body is closed.
+	c.Assert(reqCtx.Err(), gc.IsNil)
+	c.Assert(res.Body.Close(), gc.IsNil)
+	c.Assert(reqCtx.Err(), gc.Equals, context.Canceled)
+}
+
+func (s *MiddlewareTestSuite) TestRotateUserAgents(c *gc.C) {
+	var userAgents []string
+	base := Func(func(req *http.Request) (*http.Response, error) {
+		userAgents = append(userAgents, req.Header.Get("User-Agent"))
+		return okResponse(req, ""), nil
+	})
+
+	f := RotateUserAgents([]string{"a", "b"})(base)
+	req := newRequest(c, "http://example.com/")
+	for i := 0; i < 3; i++ {
+		_, err := f.Fetch(req)
+		c.Assert(err, gc.IsNil)
+	}
+	c.Assert(userAgents, gc.DeepEquals, []string{"a", "b", "a"})
+	c.Assert(req.Header.Get("User-Agent"), gc.Equals, "", gc.Commentf("expected the original request to be left untouched"))
+
+	// Requests are passed through if no user agents are specified.
+	userAgents = nil
+	_, err := RotateUserAgents(nil)(base).Fetch(req)
+	c.Assert(err, gc.IsNil)
+	c.Assert(userAgents, gc.DeepEquals, []string{""})
+}
@@ -0,0 +1,306 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ResourceTypes lists the types of the sub-resources that a page loads in a
+// browser (as reported by the Chrome DevTools protocol) which can be blocked
+// while rendering it.
+var ResourceTypes = []string{
+	"document", "stylesheet", "image", "media", "font", "script", "texttrack",
+	"xhr", "fetch", "eventsource", "websocket", "manifest", "other",
+}
+
+// BlockRules describes the sub-resources that are not loaded while rendering a
+// page. Blocking images, media and fonts speeds up rendering without affecting
+// the DOM of most pages.
+type BlockRules struct {
+	// The types of the blocked resources; see ResourceTypes.
+	ResourceTypes []string
+
+	// Regular expressions that match the URLs of blocked resources (e.g.
+	// those of ad or analytics scripts).
+	URLPatterns []string
+}
+
+func (r BlockRules) validate() error {
+	var err error
+	for _, typ := range r.ResourceTypes {
+		if !IsResourceType(typ) {
+			err = multierror.Append(err, fmt.Errorf("unknown resource type %q", typ))
+		}
+	}
+	for _, pattern := range r.URLPatterns {
+		if _, reErr := regexp.Compile(pattern); reErr != nil {
+			err = multierror.Append(err, fmt.Errorf("invalid URL pattern %q: %w", pattern, reErr))
+		}
+	}
+	return err
+}
+
+// IsResourceType returns true if typ is one of the ResourceTypes.
+func IsResourceType(typ string) bool {
+	for _, known := range ResourceTypes {
+		if typ == known {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderRequest describes a page to be rendered by a Renderer.
+type RenderRequest struct {
+	// The URL of the page.
+	URL string
+
+	// Additional headers (e.g. User-Agent) for the request that loads the
+	// page.
+	Header http.Header
+
+	// The maximum time for loading and rendering the page.
+	Timeout time.Duration
+
+	// The sub-resources that are not loaded.
+	Block BlockRules
+}
+
+// Rendered is the result of rendering a page.
+type Rendered struct {
+	// The URL of the page after following any redirects. If empty, the
+	// requested URL is assumed.
+	URL string
+
+	// The status code of the response that the page was loaded from. If
+	// zero, http.StatusOK is assumed.
+	StatusCode int
+
+	// The serialized DOM of the page once it has been rendered.
+	HTML string
+}
+
+// Renderer is implemented by objects that can load a page in a (headless)
+// browser and return its DOM once client-side scripts have rendered it.
+type Renderer interface {
+	Render(ctx context.Context, req *RenderRequest) (*Rendered, error)
+}
+
+// RenderConfig configures the Render middleware.
+type RenderConfig struct {
+	// The Renderer for the opted-in pages.
+	Renderer Renderer
+
+	// The domains whose pages (including those of their subdomains) are
+	// rendered. Pages of other domains are fetched as usual.
+	Domains []string
+
+	// The maximum time for rendering each page. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// The sub-resources that are not loaded while rendering pages.
+	Block BlockRules
+}
+
+func (cfg *RenderConfig) validate() error {
+	// Normalize a copy of the domains provided by the caller.
+	cfg.Domains = append([]string(nil), cfg.Domains...)
+
+	var err error
+	if cfg.Renderer == nil {
+		err = multierror.Append(err, fmt.Errorf("renderer has not been provided"))
+	}
+	if len(cfg.Domains) == 0 {
+		err = multierror.Append(err, fmt.Errorf("no domains have been specified"))
+	}
+	for i, domain := range cfg.Domains {
+		if cfg.Domains[i] = normalizeDomain(domain); cfg.Domains[i] == "" {
+			err = multierror.Append(err, fmt.Errorf("domain %d has not been specified", i))
+		}
+	}
+	if cfg.Timeout < 0 {
+		err = multierror.Append(err, fmt.Errorf("render timeout must not be negative"))
+	} else if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if blockErr := cfg.Block.validate(); blockErr != nil {
+		err = multierror.Append(err, blockErr)
+	}
+	return err
+}
+
+func (cfg *RenderConfig) matches(host string) bool {
+	host = normalizeDomain(host)
+	for _, domain := range cfg.Domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// Render returns a Middleware that loads the pages of the domains specified
+// by cfg via its Renderer instead of passing their requests to the next
+// Fetcher. The responses of rendered pages have an HTML body that contains
+// the DOM after rendering. Only GET requests are rendered.
+func Render(cfg RenderConfig) (Middleware, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("render: config validation failed: %w", err)
+	}
+	return render(cfg), nil
+}
+
+// render returns the Render middleware for a validated config.
+func render(cfg RenderConfig) Middleware {
+	return func(next Fetcher) Fetcher {
+		return Func(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet || !cfg.matches(req.URL.Hostname()) {
+				return next.Fetch(req)
+			}
+
+			ctx, cancel := context.WithTimeout(req.Context(), cfg.Timeout)
+			defer cancel()
+			rendered, err := cfg.Renderer.Render(ctx, &RenderRequest{
+				URL:     req.URL.String(),
+				Header:  req.Header,
+				Timeout: cfg.Timeout,
+				Block:   cfg.Block,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("render %s: %w", req.URL, err)
+			}
+			return renderedResponse(req, rendered), nil
+		})
+	}
+}
+
+// renderedResponse returns a response for req with the rendered page as its
+// body. If the page was redirected, the response's request refers to the
+// final URL of the page.
+func renderedResponse(req *http.Request, rendered *Rendered) *http.Response {
+	finalReq := req
+	if rendered.URL != "" && rendered.URL != req.URL.String() {
+		if u, err := req.URL.Parse(rendered.URL); err == nil {
+			finalReq = req.Clone(req.Context())
+			finalReq.URL = u
+			finalReq.Host = ""
+		}
+	}
+
+	status := rendered.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(rendered.HTML)),
+		ContentLength: int64(len(rendered.HTML)),
+		Request:       finalReq,
+	}
+}
+
+// RemoteRenderer is a Renderer that delegates rendering to a remote headless
+// Chrome service which implements the "/content" API of browserless: pages
+// are requested via a JSON POST request and the service responds with the
+// rendered HTML and reports the status code and final URL of the page via
+// the X-Response-Code and X-Response-URL headers.
+type RemoteRenderer struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewRemoteRenderer returns a RemoteRenderer that posts render requests to
+// the specified endpoint (e.g. "http://browserless:3000/content?token=..."),
+// using rt as the transport. If rt is nil, http.DefaultTransport is used.
+func NewRemoteRenderer(endpoint string, rt http.RoundTripper) (*RemoteRenderer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("remote renderer: invalid endpoint %q", endpoint)
+	}
+	return &RemoteRenderer{endpoint: endpoint, client: &http.Client{Transport: rt}}, nil
+}
+
+type remoteRenderReq struct {
+	URL                  string            `json:"url"`
+	GotoOptions          remoteGotoOptions `json:"gotoOptions"`
+	RejectResourceTypes  []string          `json:"rejectResourceTypes,omitempty"`
+	RejectRequestPattern []string          `json:"rejectRequestPattern,omitempty"`
+	SetExtraHTTPHeaders  map[string]string `json:"setExtraHTTPHeaders,omitempty"`
+}
+
+type remoteGotoOptions struct {
+	Timeout   int64  `json:"timeout"`
+	WaitUntil string `json:"waitUntil"`
+}
+
+// Render implements Renderer.
+func (r *RemoteRenderer) Render(ctx context.Context, req *RenderRequest) (*Rendered, error) {
+	body := remoteRenderReq{
+		URL: req.URL,
+		GotoOptions: remoteGotoOptions{
+			Timeout:   req.Timeout.Milliseconds(),
+			WaitUntil: "networkidle2",
+		},
+		RejectResourceTypes:  req.Block.ResourceTypes,
+		RejectRequestPattern: req.Block.URLPatterns,
+	}
+	for name := range req.Header {
+		// Encodings are negotiated by the browser.
+		if name == "Accept-Encoding" {
+			continue
+		}
+		if body.SetExtraHTTPHeaders == nil {
+			body.SetExtraHTTPHeaders = make(map[string]string)
+		}
+		body.SetExtraHTTPHeaders[name] = req.Header.Get(name)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("remote renderer: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("remote renderer: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	res, err := r.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("remote renderer: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote renderer: service responded with %s", res.Status)
+	}
+	html, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("remote renderer: %w", err)
+	}
+
+	rendered := &Rendered{URL: res.Header.Get("X-Response-URL"), HTML: string(html)}
+	if code, err := strconv.Atoi(res.Header.Get("X-Response-Code")); err == nil {
+		rendered.StatusCode = code
+	}
+	return rendered, nil
+}
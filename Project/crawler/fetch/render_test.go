@@ -0,0 +1,125 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(RenderTestSuite))
+
+type RenderTestSuite struct{}
+
+func (s *RenderTestSuite) TestRenderOptedInDomains(c *gc.C) {
+	renderer := &fakeRenderer{rendered: &Rendered{URL: "https://app.example.com/home", HTML: "<p>rendered</p>"}}
+	mw, err := Render(RenderConfig{
+		Renderer: renderer,
+		Domains:  []string{"Example.com."},
+		Block:    BlockRules{ResourceTypes: []string{"image", "font"}},
+	})
+	c.Assert(err, gc.IsNil)
+	f := mw(Func(func(req *http.Request) (*http.Response, error) {
+		return okResponse(req, "fetched"), nil
+	}))
+
+	req := newRequest(c, "https://app.example.com/")
+	req.Header.Set("User-Agent", "webcrawler")
+	res, err := f.Fetch(req)
+	c.Assert(err, gc.IsNil)
+	body, err := io.ReadAll(res.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(body), gc.Equals, "<p>rendered</p>")
+	c.Assert(res.StatusCode, gc.Equals, http.StatusOK)
+	c.Assert(res.Header.Get("Content-Type"), gc.Equals, "text/html; charset=utf-8")
+	c.Assert(res.Request.URL.String(), gc.Equals, "https://app.example.com/home")
+
+	c.Assert(renderer.reqs, gc.HasLen, 1)
+	c.Assert(renderer.reqs[0].URL, gc.Equals, "https://app.example.com/")
+	c.Assert(renderer.reqs[0].Header.Get("User-Agent"), gc.Equals, "webcrawler")
+	c.Assert(renderer.reqs[0].Timeout, gc.Equals, 30*time.Second)
+	c.Assert(renderer.reqs[0].Block.ResourceTypes, gc.DeepEquals, []string{"image", "font"})
+
+	// Pages of other domains are fetched as usual.
+	for _, rawURL := range []string{"https://other.com/", "https://notexample.com/"} {
+		res, err = f.Fetch(newRequest(c, rawURL))
+		c.Assert(err, gc.IsNil)
+		body, err = io.ReadAll(res.Body)
+		c.Assert(err, gc.IsNil)
+		c.Assert(string(body), gc.Equals, "fetched", gc.Commentf("fetching %s", rawURL))
+	}
+	c.Assert(renderer.reqs, gc.HasLen, 1)
+
+	renderer.err = errors.New("browser crashed")
+	_, err = f.Fetch(newRequest(c, "https://example.com/"))
+	c.Assert(err, gc.ErrorMatches, "render https://example.com/: browser crashed")
+}
+
+func (s *RenderTestSuite) TestRenderConfigValidation(c *gc.C) {
+	_, err := Render(RenderConfig{
+		Domains: []string{" "},
+		Timeout: -1,
+		Block:   BlockRules{ResourceTypes: []string{"images"}, URLPatterns: []string{"("}},
+	})
+	c.Assert(err, gc.ErrorMatches, `(?s)render: config validation failed: .*renderer has not been provided.*domain 0 has not been specified.*render timeout must not be negative.*unknown resource type "images".*invalid URL pattern "\(".*`)
+}
+
+func (s *RenderTestSuite) TestRemoteRenderer(c *gc.C) {
+	var got remoteRenderReq
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, gc.Equals, http.MethodPost)
+		c.Check(r.URL.Query().Get("token"), gc.Equals, "secret")
+		c.Check(json.NewDecoder(r.Body).Decode(&got), gc.IsNil)
+		if got.URL == "https://down.example/" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Response-Code", "404")
+		w.Header().Set("X-Response-URL", "https://example.com/missing")
+		_, _ = io.WriteString(w, "<p>not found</p>")
+	}))
+	defer srv.Close()
+
+	renderer, err := NewRemoteRenderer(srv.URL+"/content?token=secret", nil)
+	c.Assert(err, gc.IsNil)
+	rendered, err := renderer.Render(context.Background(), &RenderRequest{
+		URL:     "https://example.com/",
+		Header:  http.Header{"User-Agent": {"webcrawler"}, "Accept-Encoding": {"gzip"}},
+		Timeout: 10 * time.Second,
+		Block:   BlockRules{ResourceTypes: []string{"image"}, URLPatterns: []string{`\.ads\.`}},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(rendered, gc.DeepEquals, &Rendered{URL: "https://example.com/missing", StatusCode: 404, HTML: "<p>not found</p>"})
+	c.Assert(got, gc.DeepEquals, remoteRenderReq{
+		URL:                  "https://example.com/",
+		GotoOptions:          remoteGotoOptions{Timeout: 10000, WaitUntil: "networkidle2"},
+		RejectResourceTypes:  []string{"image"},
+		RejectRequestPattern: []string{`\.ads\.`},
+		SetExtraHTTPHeaders:  map[string]string{"User-Agent": "webcrawler"},
+	})
+
+	_, err = renderer.Render(context.Background(), &RenderRequest{URL: "https://down.example/"})
+	c.Assert(err, gc.ErrorMatches, "remote renderer: service responded with 503 Service Unavailable")
+
+	_, err = NewRemoteRenderer("browserless:3000", nil)
+	c.Assert(err, gc.ErrorMatches, `remote renderer: invalid endpoint "browserless:3000"`)
+}
+
+type fakeRenderer struct {
+	reqs     []*RenderRequest
+	rendered *Rendered
+	err      error
+}
+
+func (r *fakeRenderer) Render(_ context.Context, req *RenderRequest) (*Rendered, error) {
+	r.reqs = append(r.reqs, req)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.rendered, nil
+}
@@ -0,0 +1,12 @@
+package crawler
+
+import "context"
+
+// Gate is implemented by objects that synchronize the crawls which update the
+// link graph with other work that must not overlap with them, such as the
+// PageRank passes scheduled by a supervisor.Supervisor.
+type Gate interface {
+	// Enter blocks until a crawl may start or ctx is cancelled. The
+	// returned function must be invoked once the crawl has returned.
+	Enter(ctx context.Context) (leave func(), err error)
+}
@@ -2,62 +2,115 @@ package crawler
 
 import (
 	"context"
+	"log/slog"
 	"time"
+	"webcrawler/crawler/errstore"
 	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
 	"webcrawler/pipeline"
+	"webcrawler/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type graphUpdater struct {
 	updater Graph
+	policy  RecrawlPolicy
+	passID  uint64
+	errs    *errorReporter
+	logger  *slog.Logger
 }
 
-func newGraphUpdater(updater Graph) *graphUpdater {
+func newGraphUpdater(updater Graph, policy RecrawlPolicy, passID uint64, errs *errorReporter, logger *slog.Logger) *graphUpdater {
 	return &graphUpdater{
 		updater: updater,
+		policy:  policy,
+		passID:  passID,
+		errs:    errs,
+		logger:  logging.Component(logger, "crawler.graph_updater"),
 	}
 }
 
 func (u *graphUpdater) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
 	payload := p.(*crawlerPayload)
+	if err := u.update(ctx, payload); err != nil {
+		u.logger.Error("unable to update link graph", logging.Link(payload.LinkID, payload.URL), "err", err)
+		u.errs.report(graphUpdaterStage, payload, errstore.ClassGraph, err)
+		return nil, err
+	}
 
+	return p, nil
+}
+
+// update upserts the crawled link along with its content hash and the time of
+// its next fetch, the links discovered in it and the edges to them and removes
+// any stale edges.
+func (u *graphUpdater) update(ctx context.Context, payload *crawlerPayload) error {
+	now := time.Now()
+	contentHash := index.ContentHash(indexDocument(payload, now))
 	src := &graph.Link{
 		ID:          payload.LinkID,
 		URL:         payload.URL,
-		RetrievedAt: time.Now().Unix(),
-	}
-	if err := u.updater.UpsertLink(src); err != nil {
-		return nil, err
+		RetrievedAt: now.Unix(),
+		PassID:      u.passID,
 	}
 
-	// Upsert discovered no-follow links without creating an edge
-	for _, dstLink := range payload.NoFollowLinks {
-		dst := &graph.Link{URL: dstLink}
-		if err := u.updater.UpsertLink(dst); err != nil {
-			return nil, err
-		}
+	// Only the hashes of indexed pages are stored so that pages which
+	// become indexable are indexed even if their content is unchanged.
+	if indexable(payload) {
+		src.ContentHash = contentHash
+	}
+	if u.policy != nil {
+		src.NextFetchAt = u.policy.NextFetch(src, contentHash, now).Unix()
+	}
+	if err := tracing.Do(ctx, tracer, "linkgraph.UpsertLink", func() error { return u.updater.UpsertLink(src) }); err != nil {
+		return err
 	}
 
-	// Upsert discovered links and create edges for them. Keep track of
-	// the current time so we can drop stale edges that have not been
-	// updated after this loop.
+	// Upsert discovered links and create edges for them; the edges to
+	// no-follow links are flagged as such. Keep track of the current time
+	// so we can drop stale edges that have not been updated after these
+	// loops.
 	removeEdgesOlderThan := time.Now()
-	for _, dstLink := range payload.Links {
-		dst := &graph.Link{URL: dstLink}
+	upsertLinksAndEdges := func(dstLinks []string, noFollow bool) error {
+		for _, dstLink := range dstLinks {
+			dst := &graph.Link{URL: dstLink, PassID: u.passID}
 
-		if err := u.updater.UpsertLink(dst); err != nil {
-			return nil, err
-		}
+			if err := u.updater.UpsertLink(dst); err != nil {
+				return err
+			}
 
-		if err := u.updater.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID}); err != nil {
-			return nil, err
+			edge := &graph.Edge{
+				Src:        src.ID,
+				Dst:        dst.ID,
+				PassID:     u.passID,
+				RelType:    payload.LinkRels[dstLink],
+				AnchorText: payload.LinkAnchors[dstLink],
+				NoFollow:   noFollow,
+			}
+			if err := u.updater.UpsertEdge(edge); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
+	err := tracing.Do(ctx, tracer, "linkgraph.UpsertNoFollowLinks", func() error {
+		return upsertLinksAndEdges(payload.NoFollowLinks, true)
+	}, attribute.Int("crawler.link_count", len(payload.NoFollowLinks)))
+	if err != nil {
+		return err
+	}
+	err = tracing.Do(ctx, tracer, "linkgraph.UpsertLinksAndEdges", func() error {
+		return upsertLinksAndEdges(payload.Links, false)
+	}, attribute.Int("crawler.link_count", len(payload.Links)))
+	if err != nil {
+		return err
 	}
 
 	// Drop stale edges that were not touched while upserting the outgoing
 	// edges.
-	if err := u.updater.RemoveStaleEdges(src.ID, removeEdgesOlderThan); err != nil {
-		return nil, err
-	}
-
-	return p, nil
+	return tracing.Do(ctx, tracer, "linkgraph.RemoveStaleEdges", func() error {
+		return u.updater.RemoveStaleEdges(src.ID, removeEdgesOlderThan)
+	})
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
 
 	"webcrawler/crawler/mocks"
 
@@ -16,7 +17,12 @@ import (
 var _ = gc.Suite(new(GraphUpdaterTestSuite))
 
 type GraphUpdaterTestSuite struct {
-	graph *mocks.MockGraph
+	graph  *mocks.MockGraph
+	policy RecrawlPolicy
+}
+
+func (s *GraphUpdaterTestSuite) SetUpTest(c *gc.C) {
+	s.policy = nil
 }
 
 func (s *GraphUpdaterTestSuite) TestGraphUpdater(c *gc.C) {
@@ -34,12 +40,19 @@ func (s *GraphUpdaterTestSuite) TestGraphUpdater(c *gc.C) {
 			"http://example.com/foo",
 			"http://example.com/bar",
 		},
+		LinkRels: map[string]graph.RelType{
+			"http://example.com/bar": graph.RelCanonical,
+		},
+		LinkAnchors: map[string]string{
+			"http://forum.com":       "Forum",
+			"http://example.com/foo": "Foo",
+		},
 	}
 
 	exp := s.graph.EXPECT()
 
 	// We expect the original link to be upserted with a new timestamp and
-	// two additional insert calls for the discovered links.
+	// three additional insert calls for the discovered links.
 	exp.UpsertLink(linkMatcher{id: payload.LinkID, url: payload.URL, notBefore: time.Now().Unix()}).Return(nil)
 
 	id0, id1, id2 := uuid.New(), uuid.New(), uuid.New()
@@ -47,10 +60,12 @@ func (s *GraphUpdaterTestSuite) TestGraphUpdater(c *gc.C) {
 	exp.UpsertLink(linkMatcher{url: "http://example.com/foo", notBefore: 0}).DoAndReturn(setLinkID(id1))
 	exp.UpsertLink(linkMatcher{url: "http://example.com/bar", notBefore: 0}).DoAndReturn(setLinkID(id2))
 
-	// We then expect two edges to be created from the origin link to the
-	// two links we just created.
-	exp.UpsertEdge(edgeMatcher{src: payload.LinkID, dst: id1}).Return(nil)
-	exp.UpsertEdge(edgeMatcher{src: payload.LinkID, dst: id2}).Return(nil)
+	// We then expect edges to be created from the origin link to the
+	// links we just created, tagged with their rel types and anchor
+	// attributes.
+	exp.UpsertEdge(edgeMatcher{src: payload.LinkID, dst: id0, relType: graph.RelHyperlink, anchorText: "Forum", noFollow: true}).Return(nil)
+	exp.UpsertEdge(edgeMatcher{src: payload.LinkID, dst: id1, relType: graph.RelHyperlink, anchorText: "Foo"}).Return(nil)
+	exp.UpsertEdge(edgeMatcher{src: payload.LinkID, dst: id2, relType: graph.RelCanonical}).Return(nil)
 
 	// Finally we expect a call to drop stale edges whose source is the origin link.
 	exp.RemoveStaleEdges(payload.LinkID, gomock.Any()).Return(nil)
@@ -59,8 +74,52 @@ func (s *GraphUpdaterTestSuite) TestGraphUpdater(c *gc.C) {
 	c.Assert(p, gc.Not(gc.IsNil))
 }
 
+func (s *GraphUpdaterTestSuite) TestRecrawlPolicy(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.graph = mocks.NewMockGraph(ctrl)
+	policy := &policyStub{interval: time.Hour}
+	s.policy = policy
+
+	payload := &crawlerPayload{LinkID: uuid.New(), URL: "http://example.com", Title: "Title", TextContent: "Content"}
+
+	var upserted *graph.Link
+	s.graph.EXPECT().UpsertLink(gomock.Any()).DoAndReturn(func(link *graph.Link) error {
+		upserted = link
+		return nil
+	})
+	s.graph.EXPECT().RemoveStaleEdges(payload.LinkID, gomock.Any()).Return(nil)
+
+	s.updateGraph(c, payload)
+	c.Assert(upserted.NextFetchAt, gc.Equals, upserted.RetrievedAt+3600)
+	c.Assert(policy.contentHash, gc.Equals, index.ContentHash(&index.Document{LinkID: payload.LinkID, URL: payload.URL, Title: "Title", Content: "Content"}))
+}
+
+func (s *GraphUpdaterTestSuite) TestContentHash(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.graph = mocks.NewMockGraph(ctrl)
+
+	payload := &crawlerPayload{LinkID: uuid.New(), URL: "http://example.com", Title: "Title", TextContent: "Content"}
+
+	var upserted *graph.Link
+	s.graph.EXPECT().UpsertLink(gomock.Any()).DoAndReturn(func(link *graph.Link) error {
+		upserted = link
+		return nil
+	}).Times(2)
+	s.graph.EXPECT().RemoveStaleEdges(payload.LinkID, gomock.Any()).Return(nil).Times(2)
+
+	s.updateGraph(c, payload)
+	c.Assert(upserted.ContentHash, gc.Equals, index.ContentHash(&index.Document{LinkID: payload.LinkID, URL: payload.URL, Title: "Title", Content: "Content"}))
+
+	// The hashes of pages that are not indexed are not stored.
+	payload.NoIndex = true
+	s.updateGraph(c, payload)
+	c.Assert(upserted.ContentHash, gc.Equals, uint64(0))
+}
+
 func (s *GraphUpdaterTestSuite) updateGraph(c *gc.C, p *crawlerPayload) *crawlerPayload {
-	out, err := newGraphUpdater(s.graph).Process(context.TODO(), p)
+	out, err := newGraphUpdater(s.graph, s.policy, 0, nil, nil).Process(context.TODO(), p)
 	c.Assert(err, gc.IsNil)
 	if out != nil {
 		c.Assert(out, gc.FitsTypeOf, p)
@@ -87,7 +146,7 @@ func (lm linkMatcher) Matches(x interface{}) bool {
 	link := x.(*graph.Link)
 	return lm.id == link.ID &&
 		lm.url == link.URL &&
-		link.RetrievedAt > lm.notBefore
+		link.RetrievedAt >= lm.notBefore
 }
 
 func (lm linkMatcher) String() string {
@@ -95,15 +154,29 @@ func (lm linkMatcher) String() string {
 }
 
 type edgeMatcher struct {
-	src uuid.UUID
-	dst uuid.UUID
+	src        uuid.UUID
+	dst        uuid.UUID
+	relType    graph.RelType
+	anchorText string
+	noFollow   bool
 }
 
 func (em edgeMatcher) Matches(x interface{}) bool {
 	edge := x.(*graph.Edge)
-	return em.src == edge.Src && em.dst == edge.Dst
+	return em.src == edge.Src && em.dst == edge.Dst && em.relType == edge.RelType &&
+		em.anchorText == edge.AnchorText && em.noFollow == edge.NoFollow
 }
 
 func (em edgeMatcher) String() string {
-	return fmt.Sprintf("has Src=%q and Dst=%q", em.src, em.dst)
+	return fmt.Sprintf("has Src=%q, Dst=%q, RelType=%s, AnchorText=%q and NoFollow=%t", em.src, em.dst, em.relType, em.anchorText, em.noFollow)
+}
+
+type policyStub struct {
+	interval    time.Duration
+	contentHash uint64
+}
+
+func (p *policyStub) NextFetch(_ *graph.Link, contentHash uint64, fetchedAt time.Time) time.Time {
+	p.contentHash = contentHash
+	return fetchedAt.Add(p.interval)
 }
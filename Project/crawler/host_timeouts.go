@@ -0,0 +1,179 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// AdaptiveTimeouts configures per-host fetch timeouts that adapt to the
+// latency observed for each host while crawling a pass.
+type AdaptiveTimeouts struct {
+	// The lower and upper bounds for the per-host timeouts. Hosts with
+	// fewer than MinSamples recorded fetches use MaxTimeout.
+	MinTimeout time.Duration
+	MaxTimeout time.Duration
+
+	// The timeout for a host is calculated by multiplying its p95 fetch
+	// latency with this value. Defaults to 2.
+	Multiplier float64
+
+	// Hosts whose p95 fetch latency exceeds this threshold are quarantined
+	// for the rest of the pass; links to quarantined hosts are skipped. A
+	// zero value disables quarantining.
+	QuarantineThreshold time.Duration
+
+	// The number of fetches that must be recorded for a host before its
+	// timeout is adapted or it can be quarantined. Defaults to 5.
+	MinSamples int
+
+	// The number of most recent fetch latencies that are kept for each
+	// host. Defaults to 50.
+	WindowSize int
+}
+
+func (at *AdaptiveTimeouts) validate() error {
+	var err error
+	if at.MinTimeout <= 0 {
+		err = multierror.Append(err, fmt.Errorf("min timeout must be positive"))
+	}
+	if at.MaxTimeout < at.MinTimeout {
+		err = multierror.Append(err, fmt.Errorf("max timeout must not be less than min timeout"))
+	}
+	if at.QuarantineThreshold < 0 {
+		err = multierror.Append(err, fmt.Errorf("quarantine threshold must not be negative"))
+	} else if at.QuarantineThreshold > at.MaxTimeout {
+		// Fetches are cut off at MaxTimeout so the latency of a host can
+		// never exceed a larger threshold.
+		err = multierror.Append(err, fmt.Errorf("quarantine threshold must not exceed max timeout"))
+	}
+	if at.Multiplier < 0 || at.MinSamples < 0 || at.WindowSize < 0 {
+		err = multierror.Append(err, fmt.Errorf("multiplier, min samples and window size must not be negative"))
+	}
+	if at.Multiplier == 0 {
+		at.Multiplier = 2
+	}
+	if at.MinSamples == 0 {
+		at.MinSamples = 5
+	}
+	if at.WindowSize == 0 {
+		at.WindowSize = 50
+	}
+	if at.WindowSize < at.MinSamples {
+		err = multierror.Append(err, fmt.Errorf("window size must not be less than min samples"))
+	}
+	return err
+}
+
+// hostLatencies keeps track of the fetch latency for each host crawled by a
+// single pass and derives the per-host timeouts from it.
+type hostLatencies struct {
+	cfg AdaptiveTimeouts
+
+	mu    sync.Mutex
+	hosts map[string]*hostStats
+}
+
+// hostStats holds the most recent fetch latencies for a host in a ring
+// buffer.
+type hostStats struct {
+	samples     []time.Duration
+	next        int
+	quarantined bool
+}
+
+func newHostLatencies(cfg AdaptiveTimeouts) *hostLatencies {
+	return &hostLatencies{cfg: cfg, hosts: make(map[string]*hostStats)}
+}
+
+// timeoutFor returns the fetch timeout for host. The second return value is
+// false if the host has been quarantined.
+func (hl *hostLatencies) timeoutFor(host string) (time.Duration, bool) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	stats := hl.hosts[host]
+	if stats == nil || len(stats.samples) < hl.cfg.MinSamples {
+		return hl.cfg.MaxTimeout, true
+	}
+	if stats.quarantined {
+		return 0, false
+	}
+
+	timeout := time.Duration(float64(stats.p95()) * hl.cfg.Multiplier)
+	switch {
+	case timeout < hl.cfg.MinTimeout:
+		timeout = hl.cfg.MinTimeout
+	case timeout > hl.cfg.MaxTimeout:
+		timeout = hl.cfg.MaxTimeout
+	}
+	return timeout, true
+}
+
+// record adds the latency of a fetch from host and quarantines the host if
+// its p95 latency exceeds the configured threshold. It returns true if the
+// host became quarantined by this call.
+func (hl *hostLatencies) record(host string, latency time.Duration) bool {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	stats := hl.hosts[host]
+	if stats == nil {
+		stats = new(hostStats)
+		hl.hosts[host] = stats
+	}
+
+	if len(stats.samples) < hl.cfg.WindowSize {
+		stats.samples = append(stats.samples, latency)
+	} else {
+		stats.samples[stats.next] = latency
+		stats.next = (stats.next + 1) % hl.cfg.WindowSize
+	}
+
+	if !stats.quarantined && hl.cfg.QuarantineThreshold > 0 && len(stats.samples) >= hl.cfg.MinSamples && stats.p95() > hl.cfg.QuarantineThreshold {
+		stats.quarantined = true
+		return true
+	}
+	return false
+}
+
+// quarantinedHosts returns the sorted list of quarantined hosts.
+func (hl *hostLatencies) quarantinedHosts() []string {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	var hosts []string
+	for host, stats := range hl.hosts {
+		if stats.quarantined {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// p95 returns the 95th percentile of the recorded latencies.
+func (s *hostStats) p95() time.Duration {
+	sorted := append([]time.Duration(nil), s.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*95+99)/100-1]
+}
+
+type hostLatenciesKey struct{}
+
+// withHostLatencies returns a context that carries hl so the link fetcher
+// can look up per-host timeouts.
+func withHostLatencies(ctx context.Context, hl *hostLatencies) context.Context {
+	return context.WithValue(ctx, hostLatenciesKey{}, hl)
+}
+
+// hostLatenciesFromContext returns the latency tracker associated with ctx
+// or nil.
+func hostLatenciesFromContext(ctx context.Context) *hostLatencies {
+	hl, _ := ctx.Value(hostLatenciesKey{}).(*hostLatencies)
+	return hl
+}
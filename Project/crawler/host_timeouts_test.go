@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"time"
+	"webcrawler/crawler/mocks"
+
+	"github.com/golang/mock/gomock"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(HostTimeoutsTestSuite))
+
+type HostTimeoutsTestSuite struct{}
+
+func (s *HostTimeoutsTestSuite) TestConfigValidation(c *gc.C) {
+	cfg := AdaptiveTimeouts{MinTimeout: time.Second, MaxTimeout: 10 * time.Second}
+	c.Assert(cfg.validate(), gc.IsNil)
+	c.Assert(cfg.Multiplier, gc.Equals, 2.0)
+	c.Assert(cfg.MinSamples, gc.Equals, 5)
+	c.Assert(cfg.WindowSize, gc.Equals, 50)
+
+	cfg = AdaptiveTimeouts{MaxTimeout: -time.Second, QuarantineThreshold: time.Second, WindowSize: 2}
+	c.Assert(cfg.validate(), gc.ErrorMatches, "(?s).*min timeout must be positive.*quarantine threshold must not exceed max timeout.*window size must not be less than min samples.*")
+}
+
+func (s *HostTimeoutsTestSuite) TestTimeoutAdaptsToLatency(c *gc.C) {
+	hl := newHostLatencies(s.config(0))
+
+	// Hosts without enough samples use the max timeout.
+	for i := 0; i < 4; i++ {
+		hl.record("fast.example.com", 10*time.Millisecond)
+		hl.record("slow.example.com", 800*time.Millisecond)
+	}
+	timeout, ok := hl.timeoutFor("fast.example.com")
+	c.Assert(ok, gc.Equals, true)
+	c.Assert(timeout, gc.Equals, 2*time.Second)
+
+	hl.record("fast.example.com", 20*time.Millisecond)
+	hl.record("slow.example.com", 900*time.Millisecond)
+
+	// The timeout is clamped to the min timeout for fast hosts.
+	timeout, _ = hl.timeoutFor("fast.example.com")
+	c.Assert(timeout, gc.Equals, 100*time.Millisecond)
+
+	// p95 = 900ms; timeout = 2 * p95, clamped to the max timeout.
+	timeout, _ = hl.timeoutFor("slow.example.com")
+	c.Assert(timeout, gc.Equals, 1800*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		hl.record("slow.example.com", 1500*time.Millisecond)
+	}
+	timeout, _ = hl.timeoutFor("slow.example.com")
+	c.Assert(timeout, gc.Equals, 2*time.Second)
+	c.Assert(hl.quarantinedHosts(), gc.HasLen, 0)
+}
+
+func (s *HostTimeoutsTestSuite) TestWindowKeepsRecentSamples(c *gc.C) {
+	cfg := s.config(0)
+	cfg.WindowSize = 5
+	hl := newHostLatencies(cfg)
+
+	for i := 0; i < 5; i++ {
+		hl.record("example.com", time.Second)
+	}
+	for i := 0; i < 5; i++ {
+		hl.record("example.com", 200*time.Millisecond)
+	}
+	timeout, _ := hl.timeoutFor("example.com")
+	c.Assert(timeout, gc.Equals, 400*time.Millisecond)
+}
+
+func (s *HostTimeoutsTestSuite) TestQuarantine(c *gc.C) {
+	hl := newHostLatencies(s.config(time.Second))
+
+	for i := 0; i < 5; i++ {
+		hl.record("slow.example.com", 1500*time.Millisecond)
+		hl.record("fast.example.com", 10*time.Millisecond)
+	}
+
+	_, ok := hl.timeoutFor("slow.example.com")
+	c.Assert(ok, gc.Equals, false)
+	_, ok = hl.timeoutFor("fast.example.com")
+	c.Assert(ok, gc.Equals, true)
+	c.Assert(hl.quarantinedHosts(), gc.DeepEquals, []string{"slow.example.com"})
+
+	// Quarantined hosts remain quarantined for the rest of the pass.
+	for i := 0; i < 50; i++ {
+		hl.record("slow.example.com", time.Millisecond)
+	}
+	_, ok = hl.timeoutFor("slow.example.com")
+	c.Assert(ok, gc.Equals, false)
+}
+
+func (s *HostTimeoutsTestSuite) TestLinkFetcherEnforcesHostTimeouts(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	privNetDetector := mocks.NewMockPrivateNetworkDetector(ctrl)
+	privNetDetector.EXPECT().IsPrivate(gomock.Any()).Return(false, nil).AnyTimes()
+
+	cfg := AdaptiveTimeouts{MinTimeout: time.Millisecond, MaxTimeout: 20 * time.Millisecond, QuarantineThreshold: 10 * time.Millisecond, MinSamples: 2}
+	c.Assert(cfg.validate(), gc.IsNil)
+	hl := newHostLatencies(cfg)
+	ctx := withHostLatencies(context.TODO(), hl)
+
+	getter := &blockingGetter{slowHost: "slow.example.com"}
+	lf := newLinkFetcher(getterFetcher{getter: getter}, privNetDetector, nil, nil, nil, nil, nil, 0, nil, nil)
+
+	out, err := lf.Process(ctx, &crawlerPayload{URL: "http://fast.example.com/"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.NotNil)
+
+	// Requests to the slow host time out and are skipped until the host
+	// gets quarantined.
+	for i := 0; i < 2; i++ {
+		out, err = lf.Process(ctx, &crawlerPayload{URL: "http://slow.example.com/"})
+		c.Assert(err, gc.IsNil)
+		c.Assert(out, gc.IsNil)
+	}
+	c.Assert(getter.slowRequests, gc.Equals, 2)
+	c.Assert(hl.quarantinedHosts(), gc.DeepEquals, []string{"slow.example.com"})
+
+	out, err = lf.Process(ctx, &crawlerPayload{URL: "http://slow.example.com/other"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.IsNil)
+	c.Assert(getter.slowRequests, gc.Equals, 2)
+}
+
+func (s *HostTimeoutsTestSuite) config(quarantineThreshold time.Duration) AdaptiveTimeouts {
+	return AdaptiveTimeouts{
+		MinTimeout:          100 * time.Millisecond,
+		MaxTimeout:          2 * time.Second,
+		QuarantineThreshold: quarantineThreshold,
+		Multiplier:          2,
+		MinSamples:          5,
+		WindowSize:          50,
+	}
+}
+
+// blockingGetter responds immediately to all requests except the ones for
+// slowHost which block until the request context is cancelled.
+type blockingGetter struct {
+	slowHost     string
+	slowRequests int
+}
+
+func (g *blockingGetter) Get(string) (*http.Response, error) {
+	panic("Get should not be called when a timeout applies")
+}
+
+func (g *blockingGetter) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Hostname() == g.slowHost {
+		g.slowRequests++
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return makeResponse(200, "hello", "text/html"), nil
+}
@@ -0,0 +1,54 @@
+package ingest
+
+import (
+	"io"
+	"webcrawler/crawler/ingest/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ proto.LinkIngestionServer = (*GRPCServer)(nil)
+
+// GRPCServer exposes the Ingester over gRPC.
+type GRPCServer struct {
+	proto.UnimplementedLinkIngestionServer
+	ingester *Ingester
+}
+
+// NewGRPCServer returns a gRPC server that feeds records to ingester.
+func NewGRPCServer(ingester *Ingester) *GRPCServer {
+	return &GRPCServer{ingester: ingester}
+}
+
+// IngestLinks receives a stream of link records and replies with a summary
+// once the client closes the stream.
+func (s *GRPCServer) IngestLinks(stream proto.LinkIngestion_IngestLinksServer) error {
+	var (
+		b      summaryBuilder
+		record uint64
+	)
+	for {
+		rec, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		record++
+		if err = s.ingester.ingestRecord(&b, record, Record{URL: rec.Url, SrcURL: rec.SrcUrl}); err != nil {
+			return status.Errorf(codes.Internal, "record %d: %v", record, err)
+		}
+	}
+
+	return stream.SendAndClose(encodeSummary(b.summary))
+}
+
+func encodeSummary(s Summary) *proto.IngestSummary {
+	res := &proto.IngestSummary{Accepted: s.Accepted, Rejected: s.Rejected}
+	for _, recErr := range s.Errors {
+		res.Errors = append(res.Errors, &proto.RecordError{Record: recErr.Record, Message: recErr.Message})
+	}
+	return res
+}
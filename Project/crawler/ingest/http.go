@@ -0,0 +1,105 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// The maximum size of a single NDJSON line.
+const maxLineSize = 64 * 1024
+
+// HTTPHandler is an http.Handler that accepts a stream of link records
+// encoded as newline-delimited JSON documents in the body of a POST request.
+// Each line must contain a single JSON-encoded Record. The handler responds
+// with a JSON-encoded Summary once the entire body has been processed.
+//
+// Seed lists can be imported by posting them with a text/plain (one URL per
+// line) or text/csv content type; see SeedFormatLines and SeedFormatCSV.
+// Setting the dryRun query parameter to true validates the seeds without
+// modifying the graph.
+type HTTPHandler struct {
+	ingester *Ingester
+}
+
+// NewHTTPHandler returns an HTTP handler that feeds records to ingester.
+func NewHTTPHandler(ingester *Ingester) *HTTPHandler {
+	return &HTTPHandler{ingester: ingester}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/plain":
+		h.importSeeds(w, r, SeedFormatLines)
+		return
+	case "text/csv":
+		h.importSeeds(w, r, SeedFormatCSV)
+		return
+	}
+
+	var (
+		b       summaryBuilder
+		record  uint64
+		scanner = bufio.NewScanner(r.Body)
+	)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		record++
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			b.reject(record, fmt.Errorf("%w: malformed JSON: %v", ErrInvalidRecord, err))
+			continue
+		}
+		if err := h.ingester.ingestRecord(&b, record, rec); err != nil {
+			http.Error(w, fmt.Sprintf("record %d: %v", record, err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("reading request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writeSummary(w, b.summary)
+}
+
+// importSeeds imports the seed list in the body of r.
+func (h *HTTPHandler) importSeeds(w http.ResponseWriter, r *http.Request, format SeedFormat) {
+	opts := SeedOptions{Format: format}
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid dryRun value %q", v), http.StatusBadRequest)
+			return
+		}
+		opts.DryRun = dryRun
+	}
+
+	summary, err := h.ingester.ImportSeeds(r.Context(), r.Body, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeSummary(w, summary)
+}
+
+func writeSummary(w http.ResponseWriter, summary Summary) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,236 @@
+// Package ingest accepts links that were discovered by external systems (e.g.
+// log analysis or referrer data), validates and normalizes them and feeds
+// them into the link graph and, optionally, the recrawl frontier.
+//
+// Records can be submitted either as a client-streaming gRPC call (see
+// GRPCServer) or as a stream of newline-delimited JSON documents over HTTP
+// (see HTTPHandler). Seed lists in plain text or CSV format can be imported
+// in bulk via ImportSeeds.
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/recrawl"
+	"webcrawler/urlutil/normalizer"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/ingest.proto
+
+// ErrInvalidRecord is returned when a record does not describe a valid link.
+var ErrInvalidRecord = errors.New("invalid record")
+
+// The maximum number of per-record errors reported back to clients.
+const maxReportedErrors = 20
+
+// GraphAPI defines the set of link graph operations required by the Ingester.
+type GraphAPI interface {
+	UpsertLink(link *graph.Link) error
+	UpsertEdge(edge *graph.Edge) error
+}
+
+// Frontier is implemented by objects that schedule links for crawling, such
+// as recrawl.Scheduler.
+type Frontier interface {
+	// Add inserts link into the frontier.
+	Add(link *graph.Link) recrawl.Tier
+}
+
+// PrivateNetworkDetector is implemented by objects that can detect whether a
+// host resolves to a private network address.
+type PrivateNetworkDetector interface {
+	IsPrivate(host string) (bool, error)
+}
+
+// Config encapsulates the settings for the Ingester.
+type Config struct {
+	// The link graph to insert links and edges into.
+	Graph GraphAPI
+
+	// An optional frontier to schedule ingested links with.
+	Frontier Frontier
+
+	// A detector for rejecting links to private networks.
+	PrivateNetworkDetector PrivateNetworkDetector
+
+	// An optional normalizer for the ingested URLs. If not specified,
+	// only the normalizations that are not configurable are applied.
+	Normalizer *normalizer.Normalizer
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.Graph == nil {
+		err = multierror.Append(err, fmt.Errorf("graph API has not been provided"))
+	}
+	if cfg.PrivateNetworkDetector == nil {
+		err = multierror.Append(err, fmt.Errorf("private network detector has not been provided"))
+	}
+	return err
+}
+
+// Record describes a link that was discovered by an external system.
+type Record struct {
+	// The URL of the discovered link.
+	URL string `json:"url"`
+
+	// An optional URL of a page that links to URL. If specified, an edge
+	// from SrcURL to URL is added to the link graph.
+	SrcURL string `json:"srcURL,omitempty"`
+}
+
+// Ingester validates, normalizes and stores externally discovered links. It
+// is safe for concurrent use.
+type Ingester struct {
+	cfg Config
+}
+
+// NewIngester returns a new Ingester using the provided config.
+func NewIngester(cfg Config) (*Ingester, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("ingester: config validation failed: %w", err)
+	}
+	return &Ingester{cfg: cfg}, nil
+}
+
+// Ingest validates and normalizes rec and upserts the link (and the edge from
+// its source, if specified) into the graph. Invalid records are rejected with
+// an error that wraps ErrInvalidRecord.
+func (i *Ingester) Ingest(rec Record) error {
+	rec, err := i.prepare(rec)
+	if err != nil {
+		return err
+	}
+	return i.store(rec)
+}
+
+// prepare validates rec and returns a copy with normalized URLs. A source URL
+// that normalizes to the link URL itself is dropped.
+func (i *Ingester) prepare(rec Record) (Record, error) {
+	dstURL, err := i.normalize(rec.URL)
+	if err != nil {
+		return Record{}, fmt.Errorf("url: %w", err)
+	}
+
+	var srcURL string
+	if rec.SrcURL != "" {
+		if srcURL, err = i.normalize(rec.SrcURL); err != nil {
+			return Record{}, fmt.Errorf("srcURL: %w", err)
+		}
+	}
+	if srcURL == dstURL {
+		srcURL = ""
+	}
+	return Record{URL: dstURL, SrcURL: srcURL}, nil
+}
+
+// store upserts the link (and the edge from its source, if specified) of a
+// record that has been returned by prepare into the graph.
+func (i *Ingester) store(rec Record) error {
+	dst := &graph.Link{URL: rec.URL}
+	if err := i.cfg.Graph.UpsertLink(dst); err != nil {
+		return fmt.Errorf("ingest: %w", err)
+	}
+	if i.cfg.Frontier != nil {
+		i.cfg.Frontier.Add(dst)
+	}
+
+	if rec.SrcURL == "" {
+		return nil
+	}
+
+	src := &graph.Link{URL: rec.SrcURL}
+	if err := i.cfg.Graph.UpsertLink(src); err != nil {
+		return fmt.Errorf("ingest: %w", err)
+	}
+	if i.cfg.Frontier != nil {
+		i.cfg.Frontier.Add(src)
+	}
+	if err := i.cfg.Graph.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID}); err != nil {
+		return fmt.Errorf("ingest: %w", err)
+	}
+
+	return nil
+}
+
+// normalize validates rawURL and returns its canonical representation as
+// produced by the configured normalizer.
+func (i *Ingester) normalize(rawURL string) (string, error) {
+	normalized, err := i.cfg.Normalizer.Normalize(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("%w: URLs with credentials are not accepted", ErrInvalidRecord)
+	}
+
+	host := u.Hostname()
+	if isPrivate, err := i.cfg.PrivateNetworkDetector.IsPrivate(host); err != nil {
+		return "", fmt.Errorf("%w: cannot resolve host %q: %v", ErrInvalidRecord, host, err)
+	} else if isPrivate {
+		return "", fmt.Errorf("%w: host %q resolves to a private network address", ErrInvalidRecord, host)
+	}
+
+	return normalized, nil
+}
+
+// Summary describes the outcome of ingesting a stream of records.
+type Summary struct {
+	Accepted uint64        `json:"accepted"`
+	Rejected uint64        `json:"rejected"`
+	Errors   []RecordError `json:"errors,omitempty"`
+}
+
+// RecordError describes why a record was rejected.
+type RecordError struct {
+	// The position of the record in the stream (starting at 1). For seed
+	// lists, this is the line that contains the record.
+	Record  uint64 `json:"record"`
+	Message string `json:"message"`
+}
+
+// summaryBuilder accumulates a Summary while a stream is being processed.
+type summaryBuilder struct {
+	mu      sync.Mutex
+	summary Summary
+}
+
+func (b *summaryBuilder) accept() {
+	b.mu.Lock()
+	b.summary.Accepted++
+	b.mu.Unlock()
+}
+
+func (b *summaryBuilder) reject(record uint64, err error) {
+	b.mu.Lock()
+	b.summary.Rejected++
+	if len(b.summary.Errors) < maxReportedErrors {
+		b.summary.Errors = append(b.summary.Errors, RecordError{Record: record, Message: err.Error()})
+	}
+	b.mu.Unlock()
+}
+
+// ingestRecord ingests rec and updates the summary. Errors caused by invalid
+// records are recorded in the summary; any other error is returned.
+func (i *Ingester) ingestRecord(b *summaryBuilder, record uint64, rec Record) error {
+	err := i.Ingest(rec)
+	switch {
+	case err == nil:
+		b.accept()
+	case errors.Is(err, ErrInvalidRecord):
+		b.reject(record, err)
+	default:
+		return err
+	}
+	return nil
+}
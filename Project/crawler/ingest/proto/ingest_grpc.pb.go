@@ -0,0 +1,150 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: ingest.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	LinkIngestion_IngestLinks_FullMethodName = "/proto.LinkIngestion/IngestLinks"
+)
+
+// LinkIngestionClient is the client API for LinkIngestion service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// LinkIngestion accepts links discovered by external systems (e.g. log
+// analysis or referrer data) so they can be crawled.
+type LinkIngestionClient interface {
+	IngestLinks(ctx context.Context, opts ...grpc.CallOption) (LinkIngestion_IngestLinksClient, error)
+}
+
+type linkIngestionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLinkIngestionClient(cc grpc.ClientConnInterface) LinkIngestionClient {
+	return &linkIngestionClient{cc}
+}
+
+func (c *linkIngestionClient) IngestLinks(ctx context.Context, opts ...grpc.CallOption) (LinkIngestion_IngestLinksClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LinkIngestion_ServiceDesc.Streams[0], LinkIngestion_IngestLinks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &linkIngestionIngestLinksClient{ClientStream: stream}
+	return x, nil
+}
+
+type LinkIngestion_IngestLinksClient interface {
+	Send(*LinkRecord) error
+	CloseAndRecv() (*IngestSummary, error)
+	grpc.ClientStream
+}
+
+type linkIngestionIngestLinksClient struct {
+	grpc.ClientStream
+}
+
+func (x *linkIngestionIngestLinksClient) Send(m *LinkRecord) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *linkIngestionIngestLinksClient) CloseAndRecv() (*IngestSummary, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(IngestSummary)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LinkIngestionServer is the server API for LinkIngestion service.
+// All implementations must embed UnimplementedLinkIngestionServer
+// for forward compatibility
+//
+// LinkIngestion accepts links discovered by external systems (e.g. log
+// analysis or referrer data) so they can be crawled.
+type LinkIngestionServer interface {
+	IngestLinks(LinkIngestion_IngestLinksServer) error
+	mustEmbedUnimplementedLinkIngestionServer()
+}
+
+// UnimplementedLinkIngestionServer must be embedded to have forward compatible implementations.
+type UnimplementedLinkIngestionServer struct {
+}
+
+func (UnimplementedLinkIngestionServer) IngestLinks(LinkIngestion_IngestLinksServer) error {
+	return status.Errorf(codes.Unimplemented, "method IngestLinks not implemented")
+}
+func (UnimplementedLinkIngestionServer) mustEmbedUnimplementedLinkIngestionServer() {}
+
+// UnsafeLinkIngestionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LinkIngestionServer will
+// result in compilation errors.
+type UnsafeLinkIngestionServer interface {
+	mustEmbedUnimplementedLinkIngestionServer()
+}
+
+func RegisterLinkIngestionServer(s grpc.ServiceRegistrar, srv LinkIngestionServer) {
+	s.RegisterService(&LinkIngestion_ServiceDesc, srv)
+}
+
+func _LinkIngestion_IngestLinks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LinkIngestionServer).IngestLinks(&linkIngestionIngestLinksServer{ServerStream: stream})
+}
+
+type LinkIngestion_IngestLinksServer interface {
+	SendAndClose(*IngestSummary) error
+	Recv() (*LinkRecord, error)
+	grpc.ServerStream
+}
+
+type linkIngestionIngestLinksServer struct {
+	grpc.ServerStream
+}
+
+func (x *linkIngestionIngestLinksServer) SendAndClose(m *IngestSummary) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *linkIngestionIngestLinksServer) Recv() (*LinkRecord, error) {
+	m := new(LinkRecord)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LinkIngestion_ServiceDesc is the grpc.ServiceDesc for LinkIngestion service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LinkIngestion_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.LinkIngestion",
+	HandlerType: (*LinkIngestionServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "IngestLinks",
+			Handler:       _LinkIngestion_IngestLinks_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "ingest.proto",
+}
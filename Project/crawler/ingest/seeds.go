@@ -0,0 +1,230 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SeedFormat describes the encoding of a seed list.
+type SeedFormat string
+
+const (
+	// SeedFormatLines describes seed lists with one URL per line. Blank
+	// lines and lines starting with '#' are ignored.
+	SeedFormatLines SeedFormat = "lines"
+
+	// SeedFormatCSV describes seed lists in CSV format. If the first row is
+	// a header with a "url" column, the URLs are read from that column and
+	// the source URLs from the optional "srcURL" column. Otherwise, the URLs
+	// are read from the first column and the source URLs from the second
+	// one, if present.
+	SeedFormatCSV SeedFormat = "csv"
+)
+
+// ParseSeedFormat returns the SeedFormat with the specified name.
+func ParseSeedFormat(name string) (SeedFormat, error) {
+	switch f := SeedFormat(strings.ToLower(name)); f {
+	case SeedFormatLines, SeedFormatCSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported seed format %q", name)
+	}
+}
+
+// The default number of seeds that are upserted into the graph per batch.
+const defaultSeedBatchSize = 500
+
+// SeedOptions configures the import of a seed list.
+type SeedOptions struct {
+	// The encoding of the seed list. Defaults to SeedFormatLines.
+	Format SeedFormat
+
+	// The number of valid seeds to upsert into the graph before reporting
+	// progress. Defaults to 500.
+	BatchSize int
+
+	// If set, the seeds are validated and normalized but the graph is not
+	// modified.
+	DryRun bool
+
+	// An optional callback that is invoked after each batch and once the
+	// entire seed list has been read.
+	Progress func(SeedProgress)
+}
+
+// SeedProgress describes the progress of a seed list import.
+type SeedProgress struct {
+	// The number of seeds read from the list so far.
+	Read uint64
+
+	// The number of seeds that were accepted and rejected so far.
+	Accepted uint64
+	Rejected uint64
+}
+
+// seed is a record read from a seed list along with its line number.
+type seed struct {
+	line uint64
+	rec  Record
+}
+
+// ImportSeeds reads the seed list from r, validates and normalizes each seed
+// and upserts the valid ones into the graph in batches. Invalid seeds are
+// reported in the returned summary where each RecordError refers to the line
+// of the seed list that contains the rejected seed.
+//
+// The import stops if ctx is cancelled, r cannot be read or the graph
+// returns an error; the returned summary then describes the batches that were
+// processed until that point.
+func (i *Ingester) ImportSeeds(ctx context.Context, r io.Reader, opts SeedOptions) (Summary, error) {
+	var next func() (seed, error)
+	switch opts.Format {
+	case SeedFormatLines, "":
+		next = newLineSeedReader(r)
+	case SeedFormatCSV:
+		next = newCSVSeedReader(r)
+	default:
+		return Summary{}, fmt.Errorf("seeds: unsupported format %q", opts.Format)
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSeedBatchSize
+	}
+
+	var (
+		b        summaryBuilder
+		read     uint64
+		reported uint64
+		batch    = make([]seed, 0, batchSize)
+	)
+	flush := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, s := range batch {
+			if !opts.DryRun {
+				if err := i.store(s.rec); err != nil {
+					return fmt.Errorf("seeds: line %d: %w", s.line, err)
+				}
+			}
+			b.accept()
+		}
+		batch = batch[:0]
+		if opts.Progress != nil && read != reported {
+			reported = read
+			opts.Progress(SeedProgress{Read: read, Accepted: b.summary.Accepted, Rejected: b.summary.Rejected})
+		}
+		return nil
+	}
+
+	for {
+		s, err := next()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, ErrInvalidRecord) {
+			read++
+			b.reject(s.line, err)
+			continue
+		} else if err != nil {
+			return b.summary, fmt.Errorf("seeds: %w", err)
+		}
+
+		read++
+		if s.rec, err = i.prepare(s.rec); err != nil {
+			b.reject(s.line, err)
+			continue
+		}
+		if batch = append(batch, s); len(batch) == batchSize {
+			if err = flush(); err != nil {
+				return b.summary, err
+			}
+		}
+	}
+	return b.summary, flush()
+}
+
+// newLineSeedReader returns a function that yields the seeds of a seed list
+// with one URL per line.
+func newLineSeedReader(r io.Reader) func() (seed, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	var line uint64
+	return func() (seed, error) {
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			return seed{line: line, rec: Record{URL: text}}, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return seed{}, err
+		}
+		return seed{}, io.EOF
+	}
+}
+
+// newCSVSeedReader returns a function that yields the seeds of a seed list in
+// CSV format.
+func newCSVSeedReader(r io.Reader) func() (seed, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	var (
+		urlCol, srcCol = 0, 1
+		first          = true
+	)
+	return func() (seed, error) {
+		for {
+			row, err := cr.Read()
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return seed{line: uint64(parseErr.StartLine)}, fmt.Errorf("%w: %v", ErrInvalidRecord, parseErr.Err)
+			} else if err != nil {
+				return seed{}, err
+			}
+			line, _ := cr.FieldPos(0)
+
+			if first {
+				first = false
+				if col, src := csvHeader(row); col >= 0 {
+					urlCol, srcCol = col, src
+					continue
+				}
+			}
+
+			var rec Record
+			if urlCol < len(row) {
+				rec.URL = strings.TrimSpace(row[urlCol])
+			}
+			if srcCol >= 0 && srcCol < len(row) {
+				rec.SrcURL = strings.TrimSpace(row[srcCol])
+			}
+			return seed{line: uint64(line), rec: rec}, nil
+		}
+	}
+}
+
+// csvHeader returns the indices of the URL and source URL columns if row is a
+// CSV header, or -1 for the URL column otherwise. The source URL column is
+// -1 if the header does not contain one.
+func csvHeader(row []string) (urlCol, srcCol int) {
+	urlCol, srcCol = -1, -1
+	for i, name := range row {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "url":
+			urlCol = i
+		case "srcurl", "src_url":
+			srcCol = i
+		}
+	}
+	return urlCol, srcCol
+}
@@ -0,0 +1,134 @@
+package iterutil
+
+import (
+	"webcrawler/crawler/textindexer/index"
+)
+
+// The Filter, Map and Limit adapters for index iterators report the total
+// count, maximum score, facets and suggestions of the iterator they wrap,
+// which describe the full result set of the search rather than the adapted
+// one.
+
+// FilterDocuments returns an iterator that only yields the documents of it
+// for which keep returns true.
+func FilterDocuments(it index.Iterator, keep func(*index.Document) bool) index.Iterator {
+	return &docFilter{Iterator: it, keep: keep}
+}
+
+type docFilter struct {
+	index.Iterator
+	keep func(*index.Document) bool
+}
+
+// Next implements index.Iterator.
+func (f *docFilter) Next() bool {
+	for f.Iterator.Next() {
+		if f.keep(f.Iterator.Document()) {
+			return true
+		}
+	}
+	return false
+}
+
+// MapDocuments returns an iterator that yields the result of fn for each
+// document of it. If fn returns an error, the iteration stops and the error
+// is reported by the Error method of the returned iterator.
+func MapDocuments(it index.Iterator, fn func(*index.Document) (*index.Document, error)) index.Iterator {
+	return &docMapper{Iterator: it, fn: fn}
+}
+
+type docMapper struct {
+	index.Iterator
+	fn  func(*index.Document) (*index.Document, error)
+	doc *index.Document
+	err error
+}
+
+// Next implements index.Iterator.
+func (m *docMapper) Next() bool {
+	if m.err != nil || !m.Iterator.Next() {
+		return false
+	}
+	m.doc, m.err = m.fn(m.Iterator.Document())
+	return m.err == nil
+}
+
+// Document implements index.Iterator.
+func (m *docMapper) Document() *index.Document { return m.doc }
+
+// Error implements index.Iterator.
+func (m *docMapper) Error() error {
+	if m.err != nil {
+		return m.err
+	}
+	return m.Iterator.Error()
+}
+
+// LimitDocuments returns an iterator that yields at most n documents of it.
+func LimitDocuments(it index.Iterator, n int) index.Iterator {
+	return &docLimiter{Iterator: it, limiter: limiter{remaining: n}}
+}
+
+type docLimiter struct {
+	index.Iterator
+	limiter
+}
+
+// Next implements index.Iterator.
+func (l *docLimiter) Next() bool { return l.next(l.Iterator) }
+
+// MergeDocuments returns an iterator that yields the documents of each of
+// its in turn. The total count of the returned iterator is the sum of the
+// total counts of its and its maximum score is the highest maximum score
+// among them. As facets and suggestions cannot be combined, the returned
+// iterator reports neither.
+func MergeDocuments(its ...index.Iterator) index.Iterator {
+	m := &docMerger{docs: its}
+	for _, it := range its {
+		m.its = append(m.its, it)
+	}
+	return m
+}
+
+type docMerger struct {
+	merger
+	docs []index.Iterator
+}
+
+// Document implements index.Iterator.
+func (m *docMerger) Document() *index.Document { return m.docs[m.cur].Document() }
+
+// TotalCount implements index.Iterator.
+func (m *docMerger) TotalCount() uint64 {
+	var total uint64
+	for _, it := range m.docs {
+		total += it.TotalCount()
+	}
+	return total
+}
+
+// MaxScore implements index.Iterator.
+func (m *docMerger) MaxScore() float64 {
+	var max float64
+	for _, it := range m.docs {
+		if score := it.MaxScore(); score > max {
+			max = score
+		}
+	}
+	return max
+}
+
+// Facets implements index.Iterator.
+func (m *docMerger) Facets() []index.Facet { return nil }
+
+// Suggestions implements index.Iterator.
+func (m *docMerger) Suggestions() []index.Suggestion { return nil }
+
+// CollectDocuments returns the remaining documents of it and closes it.
+func CollectDocuments(it index.Iterator) ([]*index.Document, error) {
+	var docs []*index.Document
+	if err := drain(it, func() { docs = append(docs, it.Document()) }); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
@@ -0,0 +1,98 @@
+package iterutil
+
+import "webcrawler/crawler/linkgraph/graph"
+
+// FilterEdges returns an iterator that only yields the edges of it for which
+// keep returns true.
+func FilterEdges(it graph.EdgeIterator, keep func(*graph.Edge) bool) graph.EdgeIterator {
+	return &edgeFilter{EdgeIterator: it, keep: keep}
+}
+
+type edgeFilter struct {
+	graph.EdgeIterator
+	keep func(*graph.Edge) bool
+}
+
+// Next implements graph.EdgeIterator.
+func (f *edgeFilter) Next() bool {
+	for f.EdgeIterator.Next() {
+		if f.keep(f.EdgeIterator.Edge()) {
+			return true
+		}
+	}
+	return false
+}
+
+// MapEdges returns an iterator that yields the result of fn for each edge of
+// it. If fn returns an error, the iteration stops and the error is reported
+// by the Error method of the returned iterator.
+func MapEdges(it graph.EdgeIterator, fn func(*graph.Edge) (*graph.Edge, error)) graph.EdgeIterator {
+	return &edgeMapper{EdgeIterator: it, fn: fn}
+}
+
+type edgeMapper struct {
+	graph.EdgeIterator
+	fn   func(*graph.Edge) (*graph.Edge, error)
+	edge *graph.Edge
+	err  error
+}
+
+// Next implements graph.EdgeIterator.
+func (m *edgeMapper) Next() bool {
+	if m.err != nil || !m.EdgeIterator.Next() {
+		return false
+	}
+	m.edge, m.err = m.fn(m.EdgeIterator.Edge())
+	return m.err == nil
+}
+
+// Edge implements graph.EdgeIterator.
+func (m *edgeMapper) Edge() *graph.Edge { return m.edge }
+
+// Error implements graph.EdgeIterator.
+func (m *edgeMapper) Error() error {
+	if m.err != nil {
+		return m.err
+	}
+	return m.EdgeIterator.Error()
+}
+
+// LimitEdges returns an iterator that yields at most n edges of it.
+func LimitEdges(it graph.EdgeIterator, n int) graph.EdgeIterator {
+	return &edgeLimiter{EdgeIterator: it, limiter: limiter{remaining: n}}
+}
+
+type edgeLimiter struct {
+	graph.EdgeIterator
+	limiter
+}
+
+// Next implements graph.EdgeIterator.
+func (l *edgeLimiter) Next() bool { return l.next(l.EdgeIterator) }
+
+// MergeEdges returns an iterator that yields the edges of each of its in
+// turn.
+func MergeEdges(its ...graph.EdgeIterator) graph.EdgeIterator {
+	m := &edgeMerger{edges: its}
+	for _, it := range its {
+		m.its = append(m.its, it)
+	}
+	return m
+}
+
+type edgeMerger struct {
+	merger
+	edges []graph.EdgeIterator
+}
+
+// Edge implements graph.EdgeIterator.
+func (m *edgeMerger) Edge() *graph.Edge { return m.edges[m.cur].Edge() }
+
+// CollectEdges returns the remaining edges of it and closes it.
+func CollectEdges(it graph.EdgeIterator) ([]*graph.Edge, error) {
+	var edges []*graph.Edge
+	if err := drain(it, func() { edges = append(edges, it.Edge()) }); err != nil {
+		return nil, err
+	}
+	return edges, nil
+}
@@ -0,0 +1,86 @@
+// Package iterutil provides adapters for the link graph and text index
+// iterators so that callers can filter, transform, truncate and concatenate
+// them without writing their own traversal loops:
+//
+//   - Filter adapters skip the items that a predicate rejects.
+//   - Map adapters replace each item with the result of a function; an error
+//     returned by the function stops the iteration and is reported by Error.
+//   - Limit adapters stop after a maximum number of items without advancing
+//     the wrapped iterator any further.
+//   - Merge adapters yield the items of several iterators one iterator after
+//     the other. An error reported by any of them stops the iteration.
+//   - Collect functions drain an iterator into a slice and close it.
+//
+// The adapters report the errors of the iterators they wrap and closing an
+// adapter closes the wrapped iterators.
+package iterutil
+
+import (
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// limiter tracks the number of items that a Limit adapter may still yield.
+type limiter struct {
+	remaining int
+}
+
+func (l *limiter) next(it graph.Iterator) bool {
+	if l.remaining <= 0 || !it.Next() {
+		return false
+	}
+	l.remaining--
+	return true
+}
+
+// merger yields the items of a list of iterators one iterator after the
+// other.
+type merger struct {
+	its []graph.Iterator
+	cur int
+	err error
+}
+
+func (m *merger) Next() bool {
+	for m.cur < len(m.its) {
+		it := m.its[m.cur]
+		if it.Next() {
+			return true
+		}
+		if err := it.Error(); err != nil {
+			m.err = err
+			m.cur = len(m.its)
+			return false
+		}
+		m.cur++
+	}
+	return false
+}
+
+func (m *merger) Error() error { return m.err }
+
+// Close closes all iterators, including the ones that have not been
+// visited.
+func (m *merger) Close() error {
+	var err error
+	for _, it := range m.its {
+		if cErr := it.Close(); cErr != nil {
+			err = multierror.Append(err, cErr)
+		}
+	}
+	return err
+}
+
+// drain advances it until it is exhausted invoking fn for each item and
+// closes it. If it reports an error, the error is returned.
+func drain(it graph.Iterator, fn func()) error {
+	for it.Next() {
+		fn()
+	}
+	if err := it.Error(); err != nil {
+		_ = it.Close()
+		return err
+	}
+	return it.Close()
+}
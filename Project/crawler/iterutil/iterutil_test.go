@@ -0,0 +1,207 @@
+package iterutil
+
+import (
+	"errors"
+	"testing"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(IterUtilTestSuite))
+
+func Test(t *testing.T) {
+	// Run all gocheck test-suites
+	gc.TestingT(t)
+}
+
+type IterUtilTestSuite struct{}
+
+func (s *IterUtilTestSuite) TestLinkAdapters(c *gc.C) {
+	src := newLinks("a", "bb", "c", "dd", "e")
+	it := LimitLinks(MapLinks(
+		FilterLinks(src, func(l *graph.Link) bool { return len(l.URL) == 1 }),
+		func(l *graph.Link) (*graph.Link, error) { return &graph.Link{URL: "http://" + l.URL}, nil },
+	), 2)
+	links, err := CollectLinks(it)
+	c.Assert(err, gc.IsNil)
+	c.Assert(linkURLs(links), gc.DeepEquals, []string{"http://a", "http://c"})
+	c.Assert(src.closed, gc.Equals, true)
+
+	// The limit stops the iteration without advancing the wrapped
+	// iterator any further.
+	c.Assert(src.pos, gc.Equals, 3)
+
+	// Zero limits yield nothing.
+	links, err = CollectLinks(LimitLinks(newLinks("a"), 0))
+	c.Assert(err, gc.IsNil)
+	c.Assert(links, gc.HasLen, 0)
+}
+
+func (s *IterUtilTestSuite) TestMapErrorStopsIteration(c *gc.C) {
+	src := newLinks("a", "b", "c")
+	it := MapLinks(src, func(l *graph.Link) (*graph.Link, error) {
+		if l.URL == "b" {
+			return nil, errors.New("boom")
+		}
+		return l, nil
+	})
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Error(), gc.ErrorMatches, "boom")
+	c.Assert(src.pos, gc.Equals, 2)
+
+	_, err := CollectLinks(MapLinks(newLinks("b"), func(*graph.Link) (*graph.Link, error) { return nil, errors.New("boom") }))
+	c.Assert(err, gc.ErrorMatches, "boom")
+}
+
+func (s *IterUtilTestSuite) TestMergeLinks(c *gc.C) {
+	first, empty, last := newLinks("a", "b"), newLinks(), newLinks("c")
+	links, err := CollectLinks(MergeLinks(first, empty, last))
+	c.Assert(err, gc.IsNil)
+	c.Assert(linkURLs(links), gc.DeepEquals, []string{"a", "b", "c"})
+	for _, it := range []*sliceIterator{first, empty, last} {
+		c.Assert(it.closed, gc.Equals, true)
+	}
+
+	// Errors stop the iteration but all iterators are closed.
+	failing, unvisited := newLinks("a"), newLinks("b")
+	failing.err = errors.New("read failed")
+	unvisited.closeErr = errors.New("close failed")
+	it := MergeLinks(failing, unvisited)
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Error(), gc.ErrorMatches, "read failed")
+	c.Assert(unvisited.pos, gc.Equals, 0)
+	c.Assert(it.Close(), gc.ErrorMatches, "(?s).*close failed.*")
+	c.Assert(failing.closed, gc.Equals, true)
+}
+
+func (s *IterUtilTestSuite) TestEdgeAdapters(c *gc.C) {
+	first, second := newEdges(1, 2, 3), newEdges(4, 5)
+	it := LimitEdges(FilterEdges(MergeEdges(first, second), func(e *graph.Edge) bool { return e.PassID%2 == 1 }), 2)
+	it = MapEdges(it, func(e *graph.Edge) (*graph.Edge, error) { return &graph.Edge{PassID: e.PassID * 10}, nil })
+	edges, err := CollectEdges(it)
+	c.Assert(err, gc.IsNil)
+	c.Assert(edges, gc.HasLen, 2)
+	c.Assert(edges[0].PassID, gc.Equals, uint64(10))
+	c.Assert(edges[1].PassID, gc.Equals, uint64(30))
+	c.Assert(first.closed && second.closed, gc.Equals, true)
+
+	failing := newEdges(1)
+	failing.err = errors.New("read failed")
+	_, err = CollectEdges(FilterEdges(failing, func(*graph.Edge) bool { return true }))
+	c.Assert(err, gc.ErrorMatches, "read failed")
+	c.Assert(failing.closed, gc.Equals, true)
+}
+
+func (s *IterUtilTestSuite) TestDocumentAdapters(c *gc.C) {
+	it := FilterDocuments(newDocs(5, 2.5, "a", "b", "c"), func(d *index.Document) bool { return d.Title != "b" })
+	it = MapDocuments(LimitDocuments(it, 5), func(d *index.Document) (*index.Document, error) {
+		return &index.Document{Title: d.Title + "!"}, nil
+	})
+
+	// The search metadata of the wrapped iterator is preserved.
+	c.Assert(it.TotalCount(), gc.Equals, uint64(5))
+	c.Assert(it.MaxScore(), gc.Equals, 2.5)
+	docs, err := CollectDocuments(it)
+	c.Assert(err, gc.IsNil)
+	c.Assert(docTitles(docs), gc.DeepEquals, []string{"a!", "c!"})
+
+	merged := MergeDocuments(newDocs(2, 1.5, "a", "b"), newDocs(1, 3, "c"))
+	c.Assert(merged.TotalCount(), gc.Equals, uint64(3))
+	c.Assert(merged.MaxScore(), gc.Equals, 3.0)
+	c.Assert(merged.Facets(), gc.IsNil)
+	docs, err = CollectDocuments(merged)
+	c.Assert(err, gc.IsNil)
+	c.Assert(docTitles(docs), gc.DeepEquals, []string{"a", "b", "c"})
+}
+
+// sliceIterator iterates a slice of items and implements the iterator
+// interfaces of the link graph and the text index.
+type sliceIterator struct {
+	links []*graph.Link
+	edges []*graph.Edge
+	docs  []*index.Document
+	n     int
+
+	totalCount uint64
+	maxScore   float64
+
+	pos      int
+	err      error
+	closeErr error
+	closed   bool
+}
+
+func newLinks(urls ...string) *sliceIterator {
+	it := &sliceIterator{n: len(urls)}
+	for _, u := range urls {
+		it.links = append(it.links, &graph.Link{URL: u})
+	}
+	return it
+}
+
+func newEdges(passIDs ...uint64) *sliceIterator {
+	it := &sliceIterator{n: len(passIDs)}
+	for _, id := range passIDs {
+		it.edges = append(it.edges, &graph.Edge{PassID: id})
+	}
+	return it
+}
+
+func newDocs(totalCount uint64, maxScore float64, titles ...string) *sliceIterator {
+	it := &sliceIterator{n: len(titles), totalCount: totalCount, maxScore: maxScore}
+	for _, title := range titles {
+		it.docs = append(it.docs, &index.Document{Title: title})
+	}
+	return it
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.pos >= it.n {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+// Error reports the error of a failing iterator once it has yielded all of
+// its items.
+func (it *sliceIterator) Error() error {
+	if it.pos < it.n {
+		return nil
+	}
+	return it.err
+}
+
+func (it *sliceIterator) Close() error {
+	it.closed = true
+	return it.closeErr
+}
+
+func (it *sliceIterator) Link() *graph.Link               { return it.links[it.pos-1] }
+func (it *sliceIterator) Edge() *graph.Edge               { return it.edges[it.pos-1] }
+func (it *sliceIterator) Document() *index.Document       { return it.docs[it.pos-1] }
+func (it *sliceIterator) TotalCount() uint64              { return it.totalCount }
+func (it *sliceIterator) MaxScore() float64               { return it.maxScore }
+func (it *sliceIterator) Facets() []index.Facet           { return []index.Facet{} }
+func (it *sliceIterator) Suggestions() []index.Suggestion { return nil }
+
+func linkURLs(links []*graph.Link) []string {
+	var urls []string
+	for _, l := range links {
+		urls = append(urls, l.URL)
+	}
+	return urls
+}
+
+func docTitles(docs []*index.Document) []string {
+	titles := make([]string, 0, len(docs))
+	for _, d := range docs {
+		titles = append(titles, d.Title)
+	}
+	return titles
+}
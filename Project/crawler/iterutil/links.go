@@ -0,0 +1,98 @@
+package iterutil
+
+import "webcrawler/crawler/linkgraph/graph"
+
+// FilterLinks returns an iterator that only yields the links of it for which
+// keep returns true.
+func FilterLinks(it graph.LinkIterator, keep func(*graph.Link) bool) graph.LinkIterator {
+	return &linkFilter{LinkIterator: it, keep: keep}
+}
+
+type linkFilter struct {
+	graph.LinkIterator
+	keep func(*graph.Link) bool
+}
+
+// Next implements graph.LinkIterator.
+func (f *linkFilter) Next() bool {
+	for f.LinkIterator.Next() {
+		if f.keep(f.LinkIterator.Link()) {
+			return true
+		}
+	}
+	return false
+}
+
+// MapLinks returns an iterator that yields the result of fn for each link of
+// it. If fn returns an error, the iteration stops and the error is reported
+// by the Error method of the returned iterator.
+func MapLinks(it graph.LinkIterator, fn func(*graph.Link) (*graph.Link, error)) graph.LinkIterator {
+	return &linkMapper{LinkIterator: it, fn: fn}
+}
+
+type linkMapper struct {
+	graph.LinkIterator
+	fn   func(*graph.Link) (*graph.Link, error)
+	link *graph.Link
+	err  error
+}
+
+// Next implements graph.LinkIterator.
+func (m *linkMapper) Next() bool {
+	if m.err != nil || !m.LinkIterator.Next() {
+		return false
+	}
+	m.link, m.err = m.fn(m.LinkIterator.Link())
+	return m.err == nil
+}
+
+// Link implements graph.LinkIterator.
+func (m *linkMapper) Link() *graph.Link { return m.link }
+
+// Error implements graph.LinkIterator.
+func (m *linkMapper) Error() error {
+	if m.err != nil {
+		return m.err
+	}
+	return m.LinkIterator.Error()
+}
+
+// LimitLinks returns an iterator that yields at most n links of it.
+func LimitLinks(it graph.LinkIterator, n int) graph.LinkIterator {
+	return &linkLimiter{LinkIterator: it, limiter: limiter{remaining: n}}
+}
+
+type linkLimiter struct {
+	graph.LinkIterator
+	limiter
+}
+
+// Next implements graph.LinkIterator.
+func (l *linkLimiter) Next() bool { return l.next(l.LinkIterator) }
+
+// MergeLinks returns an iterator that yields the links of each of its in
+// turn.
+func MergeLinks(its ...graph.LinkIterator) graph.LinkIterator {
+	m := &linkMerger{links: its}
+	for _, it := range its {
+		m.its = append(m.its, it)
+	}
+	return m
+}
+
+type linkMerger struct {
+	merger
+	links []graph.LinkIterator
+}
+
+// Link implements graph.LinkIterator.
+func (m *linkMerger) Link() *graph.Link { return m.links[m.cur].Link() }
+
+// CollectLinks returns the remaining links of it and closes it.
+func CollectLinks(it graph.LinkIterator) ([]*graph.Link, error) {
+	var links []*graph.Link
+	if err := drain(it, func() { links = append(links, it.Link()) }); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
@@ -0,0 +1,744 @@
+// Package jobs runs named crawl jobs alongside the periodic crawl passes. A
+// job starts from a list of seed URLs and crawls the links discovered from
+// them that fall within its scope until its frontier is exhausted or it has
+// fetched its maximum number of pages. Jobs can be paused, resumed and
+// cancelled while they run and their state is persisted after every batch of
+// links so that running jobs pick up where they left off after a restart.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"webcrawler/crawler"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/scope"
+	"webcrawler/logging"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+var (
+	// ErrNotFound is returned when a job does not exist.
+	ErrNotFound = errors.New("job not found")
+
+	// ErrExists is returned when a job with the same name already exists.
+	ErrExists = errors.New("job already exists")
+
+	// ErrInvalidSpec is returned when the description of a new job is
+	// invalid.
+	ErrInvalidSpec = errors.New("invalid job spec")
+
+	// ErrInvalidState is returned when an operation is not permitted in
+	// the current state of a job (e.g. resuming a cancelled job).
+	ErrInvalidState = errors.New("operation not permitted in the current job state")
+)
+
+// State describes the lifecycle state of a job.
+type State string
+
+// The supported job states.
+const (
+	// Running indicates that the job is crawling its frontier.
+	Running State = "running"
+
+	// Paused indicates that the job has been paused and can be resumed.
+	Paused State = "paused"
+
+	// Cancelled indicates that the job has been cancelled.
+	Cancelled State = "cancelled"
+
+	// Completed indicates that the job has exhausted its frontier or
+	// fetched its maximum number of pages.
+	Completed State = "completed"
+
+	// Failed indicates that the job stopped because of an error. Failed
+	// jobs can be resumed.
+	Failed State = "failed"
+)
+
+// nameRegex matches the valid job names; names are used as file names by
+// the FileStore.
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// Scope restricts the links that a job crawls; see scope.Config. If no
+// include host patterns are specified, the job is restricted to the hosts
+// of its seeds.
+type Scope struct {
+	IncludeHosts       []string `json:"includeHosts,omitempty"`
+	ExcludeHosts       []string `json:"excludeHosts,omitempty"`
+	IncludeURLPatterns []string `json:"includeURLPatterns,omitempty"`
+	ExcludeURLPatterns []string `json:"excludeURLPatterns,omitempty"`
+	MaxPathDepth       int      `json:"maxPathDepth,omitempty"`
+	MaxPagesPerDomain  int      `json:"maxPagesPerDomain,omitempty"`
+	SameDomainOnly     bool     `json:"sameDomainOnly,omitempty"`
+}
+
+// compile returns the scope settings for a job with the specified seeds.
+func (s Scope) compile(seeds []string) (scope.Config, error) {
+	cfg := scope.Config{
+		IncludeHosts:      s.IncludeHosts,
+		ExcludeHosts:      s.ExcludeHosts,
+		MaxPathDepth:      s.MaxPathDepth,
+		MaxPagesPerDomain: s.MaxPagesPerDomain,
+		SameDomainOnly:    s.SameDomainOnly,
+	}
+	if len(cfg.IncludeHosts) == 0 {
+		for _, seed := range seeds {
+			if u, err := url.Parse(seed); err == nil {
+				cfg.IncludeHosts = append(cfg.IncludeHosts, u.Hostname())
+			}
+		}
+	}
+
+	var err error
+	for _, patterns := range []struct {
+		exprs []string
+		dst   *[]*regexp.Regexp
+	}{
+		{s.IncludeURLPatterns, &cfg.IncludeURLs},
+		{s.ExcludeURLPatterns, &cfg.ExcludeURLs},
+	} {
+		for _, expr := range patterns.exprs {
+			re, reErr := regexp.Compile(expr)
+			if reErr != nil {
+				err = multierror.Append(err, fmt.Errorf("invalid URL pattern %q: %w", expr, reErr))
+				continue
+			}
+			*patterns.dst = append(*patterns.dst, re)
+		}
+	}
+	return cfg, err
+}
+
+// Spec describes a new crawl job.
+type Spec struct {
+	// The unique name of the job. Names consist of up to 64 letters,
+	// digits, dots, dashes and underscores.
+	Name string `json:"name"`
+
+	// The URLs that the job starts crawling from.
+	Seeds []string `json:"seeds"`
+
+	// The scope of the links that the job crawls.
+	Scope Scope `json:"scope"`
+
+	// The maximum number of pages that the job fetches. Zero disables the
+	// limit.
+	MaxPages int `json:"maxPages,omitempty"`
+}
+
+func (spec *Spec) validate() error {
+	var err error
+	if !nameRegex.MatchString(spec.Name) {
+		err = multierror.Append(err, fmt.Errorf("invalid job name %q", spec.Name))
+	}
+	if len(spec.Seeds) == 0 {
+		err = multierror.Append(err, fmt.Errorf("no seeds have been specified"))
+	}
+	for i, seed := range spec.Seeds {
+		spec.Seeds[i] = strings.TrimSpace(seed)
+		u, uErr := url.Parse(spec.Seeds[i])
+		if uErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			err = multierror.Append(err, fmt.Errorf("seed %d: %q is not an absolute http(s) URL", i, seed))
+		}
+	}
+	if spec.MaxPages < 0 {
+		err = multierror.Append(err, fmt.Errorf("max pages must not be negative"))
+	}
+	if _, scopeErr := spec.Scope.compile(spec.Seeds); scopeErr != nil {
+		err = multierror.Append(err, scopeErr)
+	} else if scopeErr = validateScope(spec.Scope); scopeErr != nil {
+		err = multierror.Append(err, scopeErr)
+	}
+	return err
+}
+
+// validateScope checks the scope settings that are validated by scope.New.
+func validateScope(s Scope) error {
+	cfg, err := s.compile(nil)
+	if err != nil {
+		return err
+	}
+	_, err = scope.New(cfg)
+	return err
+}
+
+// Progress describes the progress of a job.
+type Progress struct {
+	// The number of pages that went through the crawler pipeline.
+	PagesFetched int `json:"pagesFetched"`
+
+	// The number of response body bytes fetched.
+	BytesFetched int64 `json:"bytesFetched"`
+
+	// The number of links that are still to be crawled.
+	FrontierSize int `json:"frontierSize"`
+
+	// The number of errors encountered while crawling.
+	Errors int `json:"errors"`
+}
+
+// Job describes a crawl job and its progress.
+type Job struct {
+	Spec
+
+	State    State    `json:"state"`
+	Progress Progress `json:"progress"`
+
+	// The error that caused a failed job to stop.
+	Error string `json:"error,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Config encapsulates the settings for a Manager.
+type Config struct {
+	// The store for persisting the state of the jobs.
+	Store Store
+
+	// The settings for the crawler that runs each job. Its Graph is used
+	// for adding the seeds of each job to the link graph. The Scope is
+	// replaced by the scope of each job and errors are counted towards
+	// the job that encountered them before they are passed to the
+	// configured ErrorRecorder (if any).
+	Crawler crawler.Config
+
+	// The number of links that each job crawls between persisting its
+	// state. Defaults to 100.
+	BatchSize int
+
+	// An optional gate that each job enters before it crawls a batch of
+	// links and leaves once the batch has been crawled.
+	Gate crawler.Gate
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.Store == nil {
+		err = multierror.Append(err, fmt.Errorf("job store has not been provided"))
+	}
+	if cfg.Crawler.Graph == nil {
+		err = multierror.Append(err, fmt.Errorf("graph has not been provided"))
+	}
+	if cfg.Crawler.Indexer == nil {
+		err = multierror.Append(err, fmt.Errorf("indexer has not been provided"))
+	}
+	if cfg.Crawler.PrivateNetworkDetector == nil {
+		err = multierror.Append(err, fmt.Errorf("private network detector has not been provided"))
+	}
+	if cfg.Crawler.URLGetter == nil && cfg.Crawler.Fetcher == nil {
+		err = multierror.Append(err, fmt.Errorf("URL getter or fetcher has not been provided"))
+	}
+	if cfg.Crawler.FetchWorkers <= 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid number of fetch workers"))
+	}
+	if cfg.BatchSize < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid batch size"))
+	} else if cfg.BatchSize == 0 {
+		cfg.BatchSize = 100
+	}
+	return err
+}
+
+// job holds the state of a job while it is managed by a Manager. All fields
+// are guarded by the mutex of the Manager.
+type job struct {
+	Job
+	frontier []string
+	seen     map[string]struct{}
+	seenList []string
+
+	// stopCh is closed to stop the go-routine that runs the job; it is
+	// nil if the job is not running. done is closed once the most
+	// recently started go-routine has returned.
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+func newJob(rec *Record) *job {
+	j := &job{
+		Job:      rec.Job,
+		frontier: rec.Frontier,
+		seen:     make(map[string]struct{}, len(rec.Seen)),
+	}
+	for _, u := range rec.Seen {
+		j.markSeen(u)
+	}
+	return j
+}
+
+// markSeen returns false if u has already been seen by the job.
+func (j *job) markSeen(u string) bool {
+	if _, found := j.seen[u]; found {
+		return false
+	}
+	j.seen[u] = struct{}{}
+	j.seenList = append(j.seenList, u)
+	return true
+}
+
+func (j *job) snapshot() *Job {
+	cp := j.Job
+	cp.Seeds = append([]string(nil), j.Seeds...)
+	cp.Progress.FrontierSize = len(j.frontier)
+	return &cp
+}
+
+func (j *job) record() *Record {
+	return &Record{
+		Job:      *j.snapshot(),
+		Frontier: append([]string(nil), j.frontier...),
+		Seen:     append([]string(nil), j.seenList...),
+	}
+}
+
+// Manager creates, runs and controls crawl jobs. It implements the
+// service.Service interface: running jobs are only crawled while Run is
+// executing and resume when it is invoked again (e.g. after a restart). It
+// is safe for concurrent use.
+type Manager struct {
+	cfg    Config
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	jobs map[string]*job
+
+	// The context passed to Run or nil if the manager is not running.
+	runCtx context.Context
+	wg     sync.WaitGroup
+}
+
+// NewManager returns a new Manager instance using the provided config. The
+// jobs persisted in the configured store are loaded.
+func NewManager(cfg Config) (*Manager, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("job manager: config validation failed: %w", err)
+	}
+
+	recs, err := cfg.Store.LoadJobs()
+	if err != nil {
+		return nil, fmt.Errorf("job manager: %w", err)
+	}
+	m := &Manager{
+		cfg:    cfg,
+		logger: logging.Component(cfg.Logger, "crawler.jobs"),
+		jobs:   make(map[string]*job, len(recs)),
+	}
+	for _, rec := range recs {
+		m.jobs[rec.Name] = newJob(rec)
+	}
+	return m, nil
+}
+
+// Name implements service.Service.
+func (m *Manager) Name() string { return "jobs" }
+
+// Run implements service.Service. It starts the running jobs and blocks
+// until ctx is cancelled. Once ctx is cancelled, the jobs stop feeding new
+// links into their crawler pipelines and Run returns after the links that
+// are in flight have drained and the state of each job has been persisted.
+// Jobs that were running remain in the running state so that they resume
+// when Run is invoked again.
+func (m *Manager) Run(ctx context.Context) error {
+	m.mu.Lock()
+	m.runCtx = ctx
+	for _, j := range m.jobs {
+		if j.State == Running {
+			m.start(j)
+		}
+	}
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	m.runCtx = nil
+	for _, j := range m.jobs {
+		m.stop(j)
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+	return nil
+}
+
+// Create creates a new job described by spec and starts running it.
+func (m *Manager) Create(spec Spec) (*Job, error) {
+	spec.Seeds = append([]string(nil), spec.Seeds...)
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.jobs[spec.Name]; exists {
+		return nil, ErrExists
+	}
+
+	now := time.Now()
+	j := newJob(&Record{Job: Job{Spec: spec, State: Running, CreatedAt: now, UpdatedAt: now}})
+	for _, seed := range spec.Seeds {
+		if j.markSeen(seed) {
+			j.frontier = append(j.frontier, seed)
+		}
+	}
+	if err := m.cfg.Store.SaveJob(j.record()); err != nil {
+		return nil, err
+	}
+	m.jobs[spec.Name] = j
+	m.logger.Info("created crawl job", "job", spec.Name, "seeds", len(j.frontier))
+
+	if m.runCtx != nil {
+		m.start(j)
+	}
+	return j.snapshot(), nil
+}
+
+// Jobs returns all jobs ordered by name.
+func (m *Manager) Jobs() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		list = append(list, j.snapshot())
+	}
+	sort.Slice(list, func(i, k int) bool { return list[i].Name < list[k].Name })
+	return list
+}
+
+// Job returns the job with the specified name.
+func (m *Manager) Job(name string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, found := m.jobs[name]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// Pause pauses a running job. The links that are in flight are crawled
+// before the job stops.
+func (m *Manager) Pause(name string) (*Job, error) {
+	return m.transition(name, Paused, Running)
+}
+
+// Resume resumes a paused or failed job.
+func (m *Manager) Resume(name string) (*Job, error) {
+	return m.transition(name, Running, Paused, Failed)
+}
+
+// Cancel cancels a job that has not completed yet. Cancelled jobs cannot be
+// resumed.
+func (m *Manager) Cancel(name string) (*Job, error) {
+	return m.transition(name, Cancelled, Running, Paused, Failed)
+}
+
+// transition moves the job with the specified name to the target state if
+// it is in one of the from states.
+func (m *Manager) transition(name string, target State, from ...State) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, found := m.jobs[name]
+	if !found {
+		return nil, ErrNotFound
+	}
+	permitted := false
+	for _, state := range from {
+		permitted = permitted || j.State == state
+	}
+	if !permitted {
+		return nil, fmt.Errorf("%w: job %q is %s", ErrInvalidState, name, j.State)
+	}
+
+	j.State, j.Error, j.UpdatedAt = target, "", time.Now()
+	if err := m.cfg.Store.SaveJob(j.record()); err != nil {
+		return nil, err
+	}
+	m.logger.Info("crawl job state changed", "job", name, "state", target)
+
+	if target == Running {
+		if m.runCtx != nil {
+			m.start(j)
+		}
+	} else {
+		m.stop(j)
+	}
+	return j.snapshot(), nil
+}
+
+// start starts a go-routine that runs j. It must be called while holding
+// the manager's mutex.
+func (m *Manager) start(j *job) {
+	if j.stopCh != nil {
+		return
+	}
+	stopCh, prevDone, done := make(chan struct{}), j.done, make(chan struct{})
+	j.stopCh, j.done = stopCh, done
+
+	// The crawl context is detached from the context of Run so that the
+	// links that are in flight can drain once the job is stopped.
+	ctx := context.WithoutCancel(m.runCtx)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(done)
+		// Wait for the previous run of the job (e.g. before it was
+		// paused) to drain.
+		if prevDone != nil {
+			<-prevDone
+		}
+		m.run(ctx, j, stopCh)
+	}()
+}
+
+// stop signals the go-routine that runs j (if any) to stop. It must be
+// called while holding the manager's mutex.
+func (m *Manager) stop(j *job) {
+	if j.stopCh != nil {
+		close(j.stopCh)
+		j.stopCh = nil
+	}
+}
+
+// run crawls the frontier of j in batches until the job is stopped, its
+// frontier is exhausted or it has fetched its maximum number of pages.
+func (m *Manager) run(ctx context.Context, j *job, stopCh <-chan struct{}) {
+	m.mu.Lock()
+	name, seeds, jobScope := j.Name, j.Seeds, j.Scope
+	m.mu.Unlock()
+
+	scopeCfg, err := jobScope.compile(seeds)
+	var sc *scope.Scope
+	if err == nil {
+		sc, err = scope.New(scopeCfg)
+	}
+	if err != nil {
+		m.fail(j, stopCh, err)
+		return
+	}
+
+	m.logger.Info("running crawl job", "job", name)
+	for {
+		batch, ok := m.nextBatch(j, stopCh)
+		if !ok {
+			return
+		}
+		leave, ok := m.enter(ctx, stopCh)
+		if !ok {
+			return
+		}
+
+		it := &batchIterator{stopCh: stopCh, links: make([]*graph.Link, len(batch))}
+		for i, u := range batch {
+			link := &graph.Link{URL: u}
+			if err := m.cfg.Crawler.Graph.UpsertLink(link); err != nil {
+				m.logger.Error("unable to add link to the link graph", "job", name, "url", u, "err", err)
+				m.mu.Lock()
+				j.Progress.Errors++
+				m.mu.Unlock()
+				continue
+			}
+			it.links[i] = link
+		}
+
+		cfg := m.cfg.Crawler
+		cfg.Graph = &jobGraph{Graph: m.cfg.Crawler.Graph, m: m, j: j}
+		cfg.Errors = &jobErrors{recorder: m.cfg.Crawler.Errors, m: m, j: j}
+		cfg.Scope = sc
+		cfg.Shutdown = nil
+		report, err := crawler.NewCrawler(cfg).CrawlWithBudget(ctx, it, crawler.Budget{})
+		leave()
+
+		m.mu.Lock()
+		// Only the links that were fed into the pipeline are removed
+		// from the frontier; the others are crawled once the job is
+		// resumed.
+		j.frontier = j.frontier[it.pos:]
+		if report != nil {
+			j.Progress.PagesFetched += report.Processed
+			j.Progress.BytesFetched += report.BytesFetched
+		}
+		j.UpdatedAt = time.Now()
+		m.save(j)
+		m.mu.Unlock()
+
+		if err != nil {
+			m.fail(j, stopCh, err)
+			return
+		}
+	}
+}
+
+// enter blocks until the configured gate (if any) allows a batch of links to
+// be crawled. It returns false if the job is stopped while waiting.
+func (m *Manager) enter(ctx context.Context, stopCh <-chan struct{}) (func(), bool) {
+	if m.cfg.Gate == nil {
+		return func() {}, true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	leave, err := m.cfg.Gate.Enter(ctx)
+	if err != nil {
+		return nil, false
+	}
+	return leave, true
+}
+
+// nextBatch returns the next batch of links to crawl for j or false if the
+// job has been stopped or has completed.
+func (m *Manager) nextBatch(j *job, stopCh <-chan struct{}) ([]string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-stopCh:
+		return nil, false
+	default:
+	}
+
+	n := len(j.frontier)
+	if n > m.cfg.BatchSize {
+		n = m.cfg.BatchSize
+	}
+	if j.MaxPages > 0 && j.MaxPages-j.Progress.PagesFetched < n {
+		n = j.MaxPages - j.Progress.PagesFetched
+	}
+	if n <= 0 {
+		j.State, j.UpdatedAt = Completed, time.Now()
+		m.stop(j)
+		m.save(j)
+		m.logger.Info("crawl job completed", "job", j.Name, "pages_fetched", j.Progress.PagesFetched)
+		return nil, false
+	}
+	return append([]string(nil), j.frontier[:n]...), true
+}
+
+// fail marks j as failed unless it has been stopped in the meantime.
+func (m *Manager) fail(j *job, stopCh <-chan struct{}, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-stopCh:
+		return
+	default:
+	}
+	m.logger.Error("crawl job failed", "job", j.Name, "err", err)
+	j.State, j.Error, j.UpdatedAt = Failed, err.Error(), time.Now()
+	m.stop(j)
+	m.save(j)
+}
+
+// save persists the state of j. It must be called while holding the
+// manager's mutex. Failures are logged and the state is persisted again
+// after the next batch.
+func (m *Manager) save(j *job) {
+	if err := m.cfg.Store.SaveJob(j.record()); err != nil {
+		m.logger.Error("unable to persist crawl job", "job", j.Name, "err", err)
+	}
+}
+
+// discovered adds a link that was discovered by j to its frontier.
+func (m *Manager) discovered(j *job, u string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j.markSeen(u) {
+		j.frontier = append(j.frontier, u)
+	}
+}
+
+// jobGraph wraps the link graph of a job's crawler and adds the discovered
+// links to the frontier of the job. The crawled links themselves have
+// already been seen by the job.
+type jobGraph struct {
+	crawler.Graph
+	m *Manager
+	j *job
+}
+
+// UpsertLink implements crawler.Graph.
+func (g *jobGraph) UpsertLink(link *graph.Link) error {
+	if err := g.Graph.UpsertLink(link); err != nil {
+		return err
+	}
+	g.m.discovered(g.j, link.URL)
+	return nil
+}
+
+// jobErrors counts the errors encountered by a job and passes them on to the
+// configured ErrorRecorder.
+type jobErrors struct {
+	recorder crawler.ErrorRecorder
+	m        *Manager
+	j        *job
+}
+
+// Record implements crawler.ErrorRecorder.
+func (r *jobErrors) Record(e errstore.Event) {
+	r.m.mu.Lock()
+	r.j.Progress.Errors++
+	r.m.mu.Unlock()
+	if r.recorder != nil {
+		r.recorder.Record(e)
+	}
+}
+
+// batchIterator is a graph.LinkIterator for a batch of links that stops once
+// the job is stopped. Links that could not be added to the link graph are
+// nil and skipped.
+type batchIterator struct {
+	stopCh <-chan struct{}
+	links  []*graph.Link
+
+	// The number of links consumed from the batch.
+	pos int
+}
+
+// Next implements graph.LinkIterator.
+func (it *batchIterator) Next() bool {
+	for it.pos < len(it.links) {
+		select {
+		case <-it.stopCh:
+			return false
+		default:
+		}
+		it.pos++
+		if it.links[it.pos-1] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Link implements graph.LinkIterator.
+func (it *batchIterator) Link() *graph.Link { return it.links[it.pos-1] }
+
+// Error implements graph.LinkIterator.
+func (it *batchIterator) Error() error { return nil }
+
+// Close implements graph.LinkIterator.
+func (it *batchIterator) Close() error { return nil }
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+	"webcrawler/crawler"
+	"webcrawler/crawler/linkgraph/graph"
+	memgraph "webcrawler/crawler/linkgraph/store/memory"
+	memidx "webcrawler/crawler/textindexer/store/memory"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(JobsTestSuite))
+
+func Test(t *testing.T) {
+	// Run all gocheck test-suites
+	gc.TestingT(t)
+}
+
+type JobsTestSuite struct {
+	srv   *httptest.Server
+	graph *memgraph.InMemoryGraph
+}
+
+func (s *JobsTestSuite) SetUpTest(c *gc.C) {
+	// Each page links to the next page, the index page and an external
+	// site that is outside the scope of the jobs.
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var page int
+		_, _ = fmt.Sscanf(r.URL.Path, "/page/%d", &page)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, `<html><head><title>Page %d</title></head><body>
+			<a href="/page/%d">next</a> <a href="/page/0">index</a> <a href="https://external.example/">external</a>
+			</body></html>`, page, page+1)
+	}))
+	s.graph = memgraph.NewInMemoryGraph()
+}
+
+func (s *JobsTestSuite) TearDownTest(c *gc.C) {
+	s.srv.Close()
+}
+
+func (s *JobsTestSuite) TestRunJobToCompletion(c *gc.C) {
+	store := NewInMemoryStore()
+	m := s.newManager(c, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	runDone := s.run(m, ctx)
+	defer func() { cancel(); <-runDone }()
+
+	job, err := m.Create(Spec{Name: "site", Seeds: []string{s.srv.URL + "/page/0"}, MaxPages: 5})
+	c.Assert(err, gc.IsNil)
+	c.Assert(job.State, gc.Equals, Running)
+	c.Assert(job.Progress.FrontierSize, gc.Equals, 1)
+
+	job = s.awaitState(c, m, "site", Completed)
+	c.Assert(job.Progress.PagesFetched, gc.Equals, 5)
+	c.Assert(job.Progress.BytesFetched > 0, gc.Equals, true)
+	c.Assert(job.Progress.Errors, gc.Equals, 0)
+	c.Assert(job.Progress.FrontierSize, gc.Equals, 1)
+
+	// The crawled pages and the in-scope links discovered on them have
+	// been added to the link graph but the external link has not.
+	var urls []string
+	it, err := s.graph.Links(minUUID, maxUUID, time.Now().Add(time.Hour).Unix())
+	c.Assert(err, gc.IsNil)
+	for it.Next() {
+		urls = append(urls, it.Link().URL)
+	}
+	c.Assert(it.Close(), gc.IsNil)
+	sort.Strings(urls)
+	c.Assert(urls, gc.HasLen, 6)
+	c.Assert(urls[5], gc.Equals, s.srv.URL+"/page/5")
+
+	// The final state has been persisted.
+	recs, err := store.LoadJobs()
+	c.Assert(err, gc.IsNil)
+	c.Assert(recs, gc.HasLen, 1)
+	c.Assert(recs[0].State, gc.Equals, Completed)
+	c.Assert(recs[0].Frontier, gc.DeepEquals, []string{s.srv.URL + "/page/5"})
+	c.Assert(recs[0].Seen, gc.HasLen, 6)
+
+	_, err = m.Resume("site")
+	c.Assert(err, gc.ErrorMatches, `operation not permitted in the current job state: job "site" is completed`)
+}
+
+func (s *JobsTestSuite) TestJobStateTransitions(c *gc.C) {
+	m := s.newManager(c, NewInMemoryStore())
+
+	// Jobs are not crawled until the manager runs.
+	_, err := m.Create(Spec{Name: "site", Seeds: []string{s.srv.URL}})
+	c.Assert(err, gc.IsNil)
+	_, err = m.Create(Spec{Name: "site", Seeds: []string{s.srv.URL}})
+	c.Assert(err, gc.Equals, ErrExists)
+
+	job, err := m.Pause("site")
+	c.Assert(err, gc.IsNil)
+	c.Assert(job.State, gc.Equals, Paused)
+	_, err = m.Pause("site")
+	c.Assert(err, gc.ErrorMatches, `.*job "site" is paused`)
+
+	job, err = m.Resume("site")
+	c.Assert(err, gc.IsNil)
+	c.Assert(job.State, gc.Equals, Running)
+
+	job, err = m.Cancel("site")
+	c.Assert(err, gc.IsNil)
+	c.Assert(job.State, gc.Equals, Cancelled)
+	_, err = m.Resume("site")
+	c.Assert(err, gc.ErrorMatches, `.*job "site" is cancelled`)
+
+	_, err = m.Cancel("missing")
+	c.Assert(err, gc.Equals, ErrNotFound)
+	_, err = m.Job("missing")
+	c.Assert(err, gc.Equals, ErrNotFound)
+
+	jobs := m.Jobs()
+	c.Assert(jobs, gc.HasLen, 1)
+	c.Assert(jobs[0].Name, gc.Equals, "site")
+}
+
+func (s *JobsTestSuite) TestJobsSurviveRestarts(c *gc.C) {
+	store, err := NewFileStore(c.MkDir())
+	c.Assert(err, gc.IsNil)
+	m := s.newManager(c, store)
+	_, err = m.Create(Spec{Name: "running", Seeds: []string{s.srv.URL + "/page/0"}, MaxPages: 2})
+	c.Assert(err, gc.IsNil)
+	_, err = m.Create(Spec{Name: "paused", Seeds: []string{s.srv.URL + "/page/0"}})
+	c.Assert(err, gc.IsNil)
+	_, err = m.Pause("paused")
+	c.Assert(err, gc.IsNil)
+
+	// A new manager loads the persisted jobs and resumes the running
+	// ones.
+	m = s.newManager(c, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	runDone := s.run(m, ctx)
+	defer func() { cancel(); <-runDone }()
+
+	job := s.awaitState(c, m, "running", Completed)
+	c.Assert(job.Progress.PagesFetched, gc.Equals, 2)
+	job, err = m.Job("paused")
+	c.Assert(err, gc.IsNil)
+	c.Assert(job.State, gc.Equals, Paused)
+	c.Assert(job.Progress.PagesFetched, gc.Equals, 0)
+	c.Assert(job.Progress.FrontierSize, gc.Equals, 1)
+}
+
+func (s *JobsTestSuite) TestInvalidSpec(c *gc.C) {
+	m := s.newManager(c, NewInMemoryStore())
+	_, err := m.Create(Spec{
+		Name:     "../etc",
+		Seeds:    []string{"example.com"},
+		Scope:    Scope{IncludeURLPatterns: []string{"("}, MaxPathDepth: -1},
+		MaxPages: -1,
+	})
+	c.Assert(err, gc.ErrorMatches, `(?s)invalid job spec: .*invalid job name "../etc".*seed 0: "example.com" is not an absolute http\(s\) URL.*max pages must not be negative.*invalid URL pattern "\(".*`)
+	c.Assert(m.Jobs(), gc.HasLen, 0)
+
+	_, err = m.Create(Spec{Name: "site"})
+	c.Assert(err, gc.ErrorMatches, `(?s)invalid job spec: .*no seeds have been specified.*`)
+}
+
+func (s *JobsTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewManager(Config{BatchSize: -1})
+	c.Assert(err, gc.ErrorMatches, `(?s)job manager: config validation failed: .*job store has not been provided.*graph has not been provided.*indexer has not been provided.*private network detector has not been provided.*URL getter or fetcher has not been provided.*invalid number of fetch workers.*invalid batch size.*`)
+}
+
+func (s *JobsTestSuite) newManager(c *gc.C, store Store) *Manager {
+	indexer, err := memidx.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	m, err := NewManager(Config{
+		Store: store,
+		Crawler: crawler.Config{
+			PrivateNetworkDetector: publicNetwork{},
+			URLGetter:              http.DefaultClient,
+			Graph:                  crawlerGraph{s.graph},
+			Indexer:                indexer,
+			FetchWorkers:           2,
+		},
+		BatchSize: 2,
+	})
+	c.Assert(err, gc.IsNil)
+	return m
+}
+
+func (s *JobsTestSuite) run(m *Manager, ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = m.Run(ctx)
+	}()
+	return done
+}
+
+func (s *JobsTestSuite) awaitState(c *gc.C, m *Manager, name string, state State) *Job {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, err := m.Job(name)
+		c.Assert(err, gc.IsNil)
+		if job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			c.Fatalf("job %q did not reach state %q; got %q", name, state, job.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// publicNetwork is a crawler.PrivateNetworkDetector that allows the crawler
+// to fetch pages from the loopback test server.
+type publicNetwork struct{}
+
+func (publicNetwork) IsPrivate(string) (bool, error) { return false, nil }
+
+// crawlerGraph adapts the in-memory link graph to the crawler.Graph
+// interface.
+type crawlerGraph struct {
+	*memgraph.InMemoryGraph
+}
+
+func (g crawlerGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore time.Time) error {
+	return g.InMemoryGraph.RemoveStaleEdges(fromID, updatedBefore.Unix())
+}
+
+var (
+	minUUID = uuid.Nil
+	maxUUID = uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+)
+
+var _ graph.LinkIterator = (*batchIterator)(nil)
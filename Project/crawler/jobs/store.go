@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Record captures the persisted state of a job: its description and progress
+// along with the links that are still to be crawled and the links that have
+// already been queued so that a job picks up where it left off after a
+// restart.
+type Record struct {
+	Job
+
+	// The URLs that are still to be crawled in the order in which they
+	// were discovered.
+	Frontier []string `json:"frontier"`
+
+	// The URLs that have been added to the frontier so far, including
+	// the ones that have already been crawled.
+	Seen []string `json:"seen"`
+}
+
+// Store is implemented by objects that can persist the state of crawl jobs.
+type Store interface {
+	// LoadJobs returns the records of all persisted jobs.
+	LoadJobs() ([]*Record, error)
+
+	// SaveJob creates or replaces the record of the job with the same
+	// name as rec.
+	SaveJob(rec *Record) error
+}
+
+// InMemoryStore is a Store that keeps job records in memory. It is safe for
+// concurrent use.
+type InMemoryStore struct {
+	mu      sync.Mutex
+	records map[string][]byte
+}
+
+// NewInMemoryStore returns a new InMemoryStore instance.
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{records: make(map[string][]byte)}
+}
+
+// LoadJobs implements Store.
+func (s *InMemoryStore) LoadJobs() ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.records))
+	for name := range s.records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	recs := make([]*Record, 0, len(names))
+	for _, name := range names {
+		rec := new(Record)
+		if err := json.Unmarshal(s.records[name], rec); err != nil {
+			return nil, fmt.Errorf("load jobs: %w", err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// SaveJob implements Store. Records are stored in their serialized form so
+// that callers cannot modify them afterwards.
+func (s *InMemoryStore) SaveJob(rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.Name] = data
+	return nil
+}
+
+// The suffix of the files that hold the job records of a FileStore.
+const jobFileSuffix = ".job.json"
+
+// FileStore is a Store that keeps the record of each job in a separate JSON
+// file inside a directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore that stores job records in dir. The
+// directory is created if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create jobs dir: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// LoadJobs implements Store.
+func (s *FileStore) LoadJobs() ([]*Record, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("load jobs: %w", err)
+	}
+
+	var recs []*Record
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), jobFileSuffix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("load jobs: %w", err)
+		}
+		rec := new(Record)
+		if err = json.Unmarshal(data, rec); err != nil {
+			return nil, fmt.Errorf("load jobs: %s: %w", entry.Name(), err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// SaveJob implements Store. The job file is replaced atomically so that a
+// crash while saving never leaves a partially written record behind.
+func (s *FileStore) SaveJob(rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+
+	path := filepath.Join(s.dir, filepath.Base(rec.Name)+jobFileSuffix)
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+	return nil
+}
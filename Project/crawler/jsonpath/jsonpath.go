@@ -0,0 +1,218 @@
+// Package jsonpath implements a subset of the JSONPath query language for
+// selecting values from decoded JSON documents.
+//
+// The supported syntax consists of:
+//   - the root selector ($), which must start every expression.
+//   - child selectors using dot (.name) or bracket (['name']) notation.
+//   - array index selectors ([0]); negative indices count from the end of
+//     the array.
+//   - wildcard selectors (.* and [*]) that select all object members or
+//     array elements.
+//   - recursive descent (..name and ..*) that selects matching values at
+//     any depth.
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPath is returned when a JSONPath expression cannot be parsed.
+var ErrInvalidPath = errors.New("invalid JSONPath expression")
+
+type selectorKind uint8
+
+const (
+	selectChild selectorKind = iota
+	selectIndex
+	selectWildcard
+)
+
+type selector struct {
+	kind      selectorKind
+	name      string
+	index     int
+	recursive bool
+}
+
+// Path is a compiled JSONPath expression.
+type Path struct {
+	expr      string
+	selectors []selector
+}
+
+// Compile parses a JSONPath expression.
+func Compile(expr string) (*Path, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("%w %q: expressions must start with '$'", ErrInvalidPath, expr)
+	}
+
+	p := &Path{expr: expr}
+	for rest := expr[1:]; rest != ""; {
+		var (
+			sel selector
+			err error
+		)
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			sel.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				sel, rest, err = parseBracket(rest)
+				sel.recursive = true
+			} else {
+				sel, rest, err = parseDotName(rest)
+				sel.recursive = true
+			}
+		case rest[0] == '.':
+			sel, rest, err = parseDotName(rest[1:])
+		case rest[0] == '[':
+			sel, rest, err = parseBracket(rest)
+		default:
+			err = fmt.Errorf("unexpected character %q", rest[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidPath, expr, err)
+		}
+		p.selectors = append(p.selectors, sel)
+	}
+
+	return p, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be
+// parsed.
+func MustCompile(expr string) *Path {
+	p, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source expression of the path.
+func (p *Path) String() string { return p.expr }
+
+// Find returns the values in doc that are selected by the path. The doc
+// argument is expected to be the result of decoding a JSON document into an
+// interface{} value. Object members are visited in sorted key order so that
+// the order of the results is deterministic.
+func (p *Path) Find(doc interface{}) []interface{} {
+	nodes := []interface{}{doc}
+	for _, sel := range p.selectors {
+		var next []interface{}
+		for _, node := range nodes {
+			if sel.recursive {
+				next = appendRecursive(next, sel, node)
+			} else {
+				next = appendMatches(next, sel, node)
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func parseDotName(rest string) (selector, string, error) {
+	end := strings.IndexAny(rest, ".[")
+	if end == -1 {
+		end = len(rest)
+	}
+	name := rest[:end]
+	switch name {
+	case "":
+		return selector{}, "", fmt.Errorf("missing member name")
+	case "*":
+		return selector{kind: selectWildcard}, rest[end:], nil
+	default:
+		return selector{kind: selectChild, name: name}, rest[end:], nil
+	}
+}
+
+func parseBracket(rest string) (selector, string, error) {
+	if len(rest) > 1 && (rest[1] == '\'' || rest[1] == '"') {
+		quote := rest[1]
+		end := strings.IndexByte(rest[2:], quote)
+		if end == -1 || len(rest) < end+4 || rest[end+3] != ']' {
+			return selector{}, "", fmt.Errorf("unterminated quoted member name")
+		}
+		return selector{kind: selectChild, name: rest[2 : end+2]}, rest[end+4:], nil
+	}
+
+	end := strings.IndexByte(rest, ']')
+	if end == -1 {
+		return selector{}, "", fmt.Errorf("missing ']'")
+	}
+	inner := rest[1:end]
+	if inner == "*" {
+		return selector{kind: selectWildcard}, rest[end+1:], nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return selector{}, "", fmt.Errorf("invalid array index %q", inner)
+	}
+	return selector{kind: selectIndex, index: index}, rest[end+1:], nil
+}
+
+// appendMatches appends the children of node that match sel to out.
+func appendMatches(out []interface{}, sel selector, node interface{}) []interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		switch sel.kind {
+		case selectChild:
+			if child, found := v[sel.name]; found {
+				out = append(out, child)
+			}
+		case selectWildcard:
+			for _, key := range sortedKeys(v) {
+				out = append(out, v[key])
+			}
+		}
+	case []interface{}:
+		switch sel.kind {
+		case selectIndex:
+			index := sel.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				out = append(out, v[index])
+			}
+		case selectWildcard:
+			out = append(out, v...)
+		}
+	}
+	return out
+}
+
+// appendRecursive appends the values that match sel at any depth below (and
+// including) node to out.
+func appendRecursive(out []interface{}, sel selector, node interface{}) []interface{} {
+	out = appendMatches(out, sel, node)
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			out = appendRecursive(out, sel, v[key])
+		}
+	case []interface{}:
+		for _, child := range v {
+			out = appendRecursive(out, sel, child)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,59 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(JSONPathTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type JSONPathTestSuite struct{}
+
+const testDoc = `{
+  "title": "Catalogue",
+  "meta": {"author": "Ovidius", "tags": ["poetry", "latin"]},
+  "items": [
+    {"name": "Metamorphoses", "url": "/books/1", "price": 10},
+    {"name": "Tristia", "url": "/books/2", "related": {"url": "/books/3"}}
+  ],
+  "odd key": "quoted"
+}`
+
+func (s *JSONPathTestSuite) TestFind(c *gc.C) {
+	var doc interface{}
+	c.Assert(json.Unmarshal([]byte(testDoc), &doc), gc.IsNil)
+
+	specs := []struct {
+		expr string
+		exp  []interface{}
+	}{
+		{expr: "$.title", exp: []interface{}{"Catalogue"}},
+		{expr: "$.meta.author", exp: []interface{}{"Ovidius"}},
+		{expr: "$['odd key']", exp: []interface{}{"quoted"}},
+		{expr: `$.meta["tags"][1]`, exp: []interface{}{"latin"}},
+		{expr: "$.meta.tags[-1]", exp: []interface{}{"latin"}},
+		{expr: "$.meta.tags[*]", exp: []interface{}{"poetry", "latin"}},
+		{expr: "$.items[*].name", exp: []interface{}{"Metamorphoses", "Tristia"}},
+		{expr: "$.items.*.price", exp: []interface{}{float64(10)}},
+		{expr: "$..url", exp: []interface{}{"/books/1", "/books/2", "/books/3"}},
+		{expr: "$.items[5].name", exp: nil},
+		{expr: "$.missing", exp: nil},
+	}
+
+	for _, spec := range specs {
+		got := MustCompile(spec.expr).Find(doc)
+		c.Assert(got, gc.DeepEquals, spec.exp, gc.Commentf("expr %q", spec.expr))
+	}
+}
+
+func (s *JSONPathTestSuite) TestInvalidExpressions(c *gc.C) {
+	for _, expr := range []string{"", "title", "$.", "$[", "$[abc]", "$['unterminated", "$.a..", "$a"} {
+		_, err := Compile(expr)
+		c.Assert(errors.Is(err, ErrInvalidPath), gc.Equals, true, gc.Commentf("expr %q", expr))
+	}
+}
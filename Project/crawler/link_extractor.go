@@ -2,9 +2,16 @@ package crawler
 
 import (
 	"context"
+	"html"
 	"net/url"
 	"regexp"
+	"strings"
+	"unicode/utf8"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/scope"
+	"webcrawler/metrics"
 	"webcrawler/pipeline"
+	"webcrawler/urlutil/normalizer"
 )
 
 var (
@@ -12,44 +19,154 @@ var (
 	baseHrefRegex  = regexp.MustCompile(`(?i)<base.*?href\s*?=\s*?"(.*?)\s*?"`)
 	findLinkRegex  = regexp.MustCompile(`(?i)<a.*?href\s*?=\s*?"\s*?(.*?)\s*?".*?>`)
 	nofollowRegex  = regexp.MustCompile(`(?i)rel\s*?=\s*?"?nofollow"?`)
+	canonicalRegex = regexp.MustCompile(`(?i)<link[^>]*?\srel\s*=\s*["']?canonical["'\s>][^>]*>`)
+	alternateRegex = regexp.MustCompile(`(?i)<link[^>]*?\srel\s*=\s*["']?alternate["'\s>][^>]*>`)
+	hreflangRegex  = regexp.MustCompile(`(?i)\shreflang\s*=`)
+	refreshRegex   = regexp.MustCompile(`(?i)<meta[^>]*?\shttp-equiv\s*=\s*["']?refresh["'\s>][^>]*>`)
+	refreshURL     = regexp.MustCompile(`(?i)\scontent\s*=\s*["']?\s*[\d.]*\s*[;,]\s*url\s*=\s*['"]?([^"'\s>]+)`)
+
+	// Tags whose src attribute references a resource of the page.
+	resourceTagRegex = regexp.MustCompile(`(?i)<(img|script|iframe)\s[^>]*>`)
+	srcRegex         = regexp.MustCompile(`(?i)\ssrc\s*=\s*["']?([^"'\s>]+)`)
+	srcsetRegex      = regexp.MustCompile(`(?i)\ssrcset\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+	// Used for extracting the text of <a> tags.
+	anchorEndRegex = regexp.MustCompile(`(?i)</a\s*>`)
+	tagRegex       = regexp.MustCompile(`<[^>]*>`)
 )
 
+// The maximum number of bytes after an <a> tag that are searched for its
+// closing tag and the maximum length of the extracted anchor text.
+const (
+	maxAnchorScanLen = 4096
+	maxAnchorTextLen = 256
+)
+
+// The rel types of the resources referenced by each tag.
+var resourceRelTypes = map[string]graph.RelType{
+	"img":    graph.RelImage,
+	"script": graph.RelScript,
+	"iframe": graph.RelFrame,
+}
+
 type linkExtractor struct {
 	netDetector PrivateNetworkDetector
+
+	// Used for mapping links to a mirror back to their canonical form.
+	rewriter *urlRewriter
+
+	// Used for mapping the different spellings of a link to a single
+	// URL.
+	normalizer *normalizer.Normalizer
+
+	// Used for dropping links that are outside the crawl scope.
+	scope *scope.Scope
+
+	// If set, the images, scripts and frames referenced by each page are
+	// also extracted.
+	resourceLinks bool
 }
 
-func newLinkExtractor(netDetector PrivateNetworkDetector) *linkExtractor {
+func newLinkExtractor(netDetector PrivateNetworkDetector, rewriter *urlRewriter, urlNormalizer *normalizer.Normalizer, crawlScope *scope.Scope, resourceLinks bool) *linkExtractor {
 	return &linkExtractor{
-		netDetector: netDetector,
+		netDetector:   netDetector,
+		rewriter:      rewriter,
+		normalizer:    urlNormalizer,
+		scope:         crawlScope,
+		resourceLinks: resourceLinks,
 	}
 }
 
 func (le *linkExtractor) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
 	payload := p.(*crawlerPayload)
+	if payload.Format != formatHTML {
+		return payload, nil
+	}
+
 	relTo, err := url.Parse(payload.URL)
 	if err != nil {
 		return nil, err
 	}
+	if payload.BaseURL != "" {
+		if relTo, err = url.Parse(payload.BaseURL); err != nil {
+			return nil, err
+		}
+	}
 	content := payload.RawContent.String()
 
 	// Search page content for a <base> tag and resolve it to an abs URL.
 	if baseMatch := baseHrefRegex.FindStringSubmatch(content); len(baseMatch) == 2 {
-		if base := resolveURL(relTo, ensureHasTrailingSlash(baseMatch[1])); base != nil {
+		if base := le.resolveLink(relTo, ensureHasTrailingSlash(baseMatch[1])); base != nil {
 			relTo = base
 		}
 	}
 
+	seenMap := make(map[string]struct{})
+
+	// addTypedLink adds a link other than a hyperlink to the payload and
+	// records its rel type. Links to the page itself are skipped. It
+	// returns the normalized link and true if the link was added.
+	selfURL := le.selfURL(payload.URL)
+	addTypedLink := func(link *url.URL, relType graph.RelType) (string, bool) {
+		if !le.retainLink(relTo.Hostname(), link) {
+			return "", false
+		}
+		link = le.normalizer.NormalizeURL(link)
+		linkStr := link.String()
+		if _, seen := seenMap[linkStr]; seen || linkStr == selfURL || !le.inScope(relTo, link) {
+			return "", false
+		}
+		seenMap[linkStr] = struct{}{}
+		payload.Links = append(payload.Links, linkStr)
+		if payload.LinkRels == nil {
+			payload.LinkRels = make(map[string]graph.RelType)
+		}
+		payload.LinkRels[linkStr] = relType
+		return linkStr, true
+	}
+
+	// If the page declares a different canonical URL, link to it so that
+	// it gets crawled and indexed in place of this page.
+	if link := le.canonicalLink(relTo, content); link != nil {
+		if linkStr, added := addTypedLink(link, graph.RelCanonical); added {
+			payload.CanonicalURL = linkStr
+		}
+	}
+
+	// Pages with a nofollow robots directive only declare their
+	// canonical URL.
+	if payload.NoFollow {
+		return payload, nil
+	}
+
+	// Link to the alternate language versions of the page and to the
+	// target of a meta refresh redirect.
+	for _, tag := range alternateRegex.FindAllString(content, -1) {
+		if !hreflangRegex.MatchString(tag) {
+			continue
+		}
+		if hrefMatch := hrefRegex.FindStringSubmatch(tag); len(hrefMatch) == 2 {
+			addTypedLink(le.resolveLink(relTo, hrefMatch[1]), graph.RelAlternate)
+		}
+	}
+	if tag := refreshRegex.FindString(content); tag != "" {
+		if urlMatch := refreshURL.FindStringSubmatch(tag); len(urlMatch) == 2 {
+			addTypedLink(le.resolveLink(relTo, urlMatch[1]), graph.RelRefresh)
+		}
+	}
+
 	// Find the unique set of links from the document, resolve them and
 	// add them to the payload.
-	seenMap := make(map[string]struct{})
-	for _, match := range findLinkRegex.FindAllStringSubmatch(content, -1) {
-		link := resolveURL(relTo, match[1])
+	for _, loc := range findLinkRegex.FindAllStringSubmatchIndex(content, -1) {
+		tag := content[loc[0]:loc[1]]
+		link := le.resolveLink(relTo, content[loc[2]:loc[3]])
 		if !le.retainLink(relTo.Hostname(), link) {
 			continue
 		}
 
-		// Truncate anchors and drop duplicates
-		link.Fragment = ""
+		// Normalize links (which also truncates anchors) and drop
+		// duplicates.
+		link = le.normalizer.NormalizeURL(link)
 		linkStr := link.String()
 		if _, seen := seenMap[linkStr]; seen {
 			continue
@@ -61,16 +178,101 @@ func (le *linkExtractor) Process(ctx context.Context, p pipeline.Payload) (pipel
 		}
 
 		seenMap[linkStr] = struct{}{}
-		if nofollowRegex.MatchString(match[0]) {
+		if !le.inScope(relTo, link) {
+			continue
+		}
+		if nofollowRegex.MatchString(tag) {
 			payload.NoFollowLinks = append(payload.NoFollowLinks, linkStr)
 		} else {
 			payload.Links = append(payload.Links, linkStr)
 		}
+		if anchor := anchorText(tag, content[loc[1]:]); anchor != "" {
+			if payload.LinkAnchors == nil {
+				payload.LinkAnchors = make(map[string]string)
+			}
+			payload.LinkAnchors[linkStr] = anchor
+		}
+	}
+
+	if le.resourceLinks {
+		for _, match := range resourceTagRegex.FindAllStringSubmatch(content, -1) {
+			relType := resourceRelTypes[strings.ToLower(match[1])]
+			for _, target := range resourceTargets(match[0]) {
+				addTypedLink(le.resolveLink(relTo, target), relType)
+			}
+		}
 	}
 
 	return payload, nil
 }
 
+// anchorText returns the text between the <a> tag and its closing tag, given
+// the content that follows the tag. Nested tags are dropped, entities are
+// unescaped and whitespace is collapsed. The text is truncated to
+// maxAnchorTextLen bytes.
+func anchorText(tag, rest string) string {
+	if strings.HasSuffix(tag, "/>") {
+		return ""
+	}
+	if len(rest) > maxAnchorScanLen {
+		rest = rest[:maxAnchorScanLen]
+	}
+	end := anchorEndRegex.FindStringIndex(rest)
+	if end == nil {
+		return ""
+	}
+	text := tagRegex.ReplaceAllString(rest[:end[0]], " ")
+	text = strings.TrimSpace(repeatedSpaceRegex.ReplaceAllString(html.UnescapeString(text), " "))
+	if len(text) > maxAnchorTextLen {
+		text = text[:maxAnchorTextLen]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	return text
+}
+
+// resourceTargets returns the URLs referenced by the src and srcset
+// attributes of tag.
+func resourceTargets(tag string) []string {
+	var targets []string
+	if srcMatch := srcRegex.FindStringSubmatch(tag); len(srcMatch) == 2 {
+		targets = append(targets, srcMatch[1])
+	}
+	if srcsetMatch := srcsetRegex.FindStringSubmatch(tag); srcsetMatch != nil {
+		// Each image candidate is a URL that is optionally followed by
+		// a width or pixel density descriptor.
+		for _, candidate := range strings.Split(srcsetMatch[1]+srcsetMatch[2], ",") {
+			if fields := strings.Fields(candidate); len(fields) != 0 {
+				targets = append(targets, fields[0])
+			}
+		}
+	}
+	return targets
+}
+
+// filterLink applies the rules of Process to a link target that was found in
+// the body of a non-HTML payload served from relTo. It returns the normalized
+// link and true if the link should be added to the payload. Returned links
+// are recorded in seen so that duplicates are dropped.
+func (le *linkExtractor) filterLink(relTo *url.URL, target string, seen map[string]struct{}) (string, bool) {
+	link := le.resolveLink(relTo, strings.TrimSpace(target))
+	if !le.retainLink(relTo.Hostname(), link) {
+		return "", false
+	}
+
+	link = le.normalizer.NormalizeURL(link)
+	linkStr := link.String()
+	if _, dup := seen[linkStr]; dup || exclusionRegex.MatchString(linkStr) {
+		return "", false
+	}
+	seen[linkStr] = struct{}{}
+	if !le.inScope(relTo, link) {
+		return "", false
+	}
+	return linkStr, true
+}
+
 func (le *linkExtractor) retainLink(srcHost string, link *url.URL) bool {
 	// Skip links that could not be resolved
 	if link == nil {
@@ -95,6 +297,40 @@ func (le *linkExtractor) retainLink(srcHost string, link *url.URL) bool {
 	return true
 }
 
+// inScope returns true if link, which was found on the page at src, is within
+// the crawl scope. The caller must normalize link beforehand.
+func (le *linkExtractor) inScope(src, link *url.URL) bool {
+	if reason := le.scope.Check(src, link); reason != scope.InScope {
+		metrics.OutOfScopeLinks.WithLabelValues(string(reason)).Inc()
+		return false
+	}
+	return true
+}
+
+// canonicalLink returns the resolved URL of the first <link rel="canonical">
+// tag in content or nil if content does not contain such a tag.
+func (le *linkExtractor) canonicalLink(relTo *url.URL, content string) *url.URL {
+	tag := canonicalRegex.FindString(content)
+	if hrefMatch := hrefRegex.FindStringSubmatch(tag); len(hrefMatch) == 2 {
+		return le.resolveLink(relTo, hrefMatch[1])
+	}
+	return nil
+}
+
+// selfURL returns the normalized form of the URL of the processed page.
+func (le *linkExtractor) selfURL(rawURL string) string {
+	if normalized, err := le.normalizer.Normalize(rawURL); err == nil {
+		return normalized
+	}
+	return rawURL
+}
+
+// resolveLink resolves target against relTo and maps the resulting URL to
+// its canonical form if it points to a mirror.
+func (le *linkExtractor) resolveLink(relTo *url.URL, target string) *url.URL {
+	return le.rewriter.canonicalURL(resolveURL(relTo, target))
+}
+
 func ensureHasTrailingSlash(s string) string {
 	if s[len(s)-1] != '/' {
 		return s + "/"
@@ -3,9 +3,14 @@ package crawler
 import (
 	"context"
 	"net/url"
+	"regexp"
 	"sort"
+	"strings"
 
+	"webcrawler/crawler/linkgraph/graph"
 	"webcrawler/crawler/mocks"
+	"webcrawler/crawler/scope"
+	"webcrawler/urlutil/normalizer"
 
 	"github.com/golang/mock/gomock"
 	gc "gopkg.in/check.v1"
@@ -81,6 +86,15 @@ func assertResolvedURL(c *gc.C, target, base, exp string) {
 
 type LinkExtractorTestSuite struct {
 	privNetDetector *mocks.MockPrivateNetworkDetector
+	normalizer      *normalizer.Normalizer
+	scope           *scope.Scope
+	resourceLinks   bool
+}
+
+func (s *LinkExtractorTestSuite) SetUpTest(c *gc.C) {
+	s.normalizer = nil
+	s.scope = nil
+	s.resourceLinks = false
 }
 
 func (s *LinkExtractorTestSuite) TestLinkExtractor(c *gc.C) {
@@ -111,8 +125,8 @@ func (s *LinkExtractorTestSuite) TestLinkExtractor(c *gc.C) {
 </html>
 `
 	s.assertExtractedLinks(c, "http://test.com", content, []string{
-		"https://example.com",
-		"http://foo.com",
+		"https://example.com/",
+		"http://foo.com/",
 		"http://test.com/absolute/link",
 	}, []string{
 		"http://test.com/local",
@@ -209,16 +223,229 @@ func (s *LinkExtractorTestSuite) TestLinkExtractorWithPrivateNetworkLinks(c *gc.
 </html>
 `
 	s.assertExtractedLinks(c, "https://test.com/content/", content, []string{
-		"https://example.com",
+		"https://example.com/",
+	}, nil)
+}
+
+func (s *LinkExtractorTestSuite) TestLinkExtractorWithNormalizer(c *gc.C) {
+	var err error
+	s.normalizer, err = normalizer.New(normalizer.Config{
+		StripParams:       normalizer.DefaultStripParams,
+		SortQuery:         true,
+		TrimTrailingSlash: true,
+	})
+	c.Assert(err, gc.IsNil)
+
+	content := `
+<html>
+<body>
+<!-- all of the following links are spellings of the same URL -->
+<a href="/docs/?b=2&a=1"></a>
+<a href="/docs?a=1&b=2&utm_source=newsletter"></a>
+<a href="HTTP://TEST.com:80/docs/?a=1&b=2#install"></a>
+<a href="./docs/./?a=1&b=2"></a>
+</body>
+</html>
+`
+	s.assertExtractedLinks(c, "http://test.com/", content, []string{
+		"http://test.com/docs?a=1&b=2",
+	}, nil)
+}
+
+func (s *LinkExtractorTestSuite) TestLinkExtractorWithCanonicalLink(c *gc.C) {
+	content := `
+<html>
+<head>
+<link rel="canonical" href="/article#comments"/>
+</head>
+<body>
+<a href="/article"></a>
+<a href="/other"></a>
+</body>
+</html>
+`
+	p := s.assertExtractedLinks(c, "https://test.com/article?page=1", content, []string{
+		"https://test.com/article",
+		"https://test.com/other",
 	}, nil)
+	c.Assert(p.CanonicalURL, gc.Equals, "https://test.com/article")
+
+	// Pages that declare themselves as canonical have no canonical URL.
+	p = s.assertExtractedLinks(c, "https://TEST.com/article", content, []string{
+		"https://test.com/article",
+		"https://test.com/other",
+	}, nil)
+	c.Assert(p.CanonicalURL, gc.Equals, "")
+}
+
+func (s *LinkExtractorTestSuite) TestLinkExtractorWithTypedLinks(c *gc.C) {
+	content := `
+<html>
+<head>
+<link rel="alternate" hreflang="en" href="https://test.com/en/"/>
+<link rel="alternate" hreflang="de" href="/de/"/>
+<link rel="alternate" type="application/rss+xml" href="/feed.xml"/>
+<meta http-equiv="refresh" content="5; url='/moved'">
+<script src="/app.js"></script>
+</head>
+<body>
+<a href="/de/">Deutsch</a>
+<a href="/about">about</a>
+<img src="/logo.png" srcset="/logo-2x.png 2x, /logo-3x.png 3x" alt="logo">
+<iframe src="/embed"></iframe>
+</body>
+</html>
+`
+	// Resources are only extracted if enabled.
+	p := s.assertExtractedLinks(c, "https://test.com/en/", content, []string{
+		"https://test.com/about",
+		"https://test.com/de/",
+		"https://test.com/moved",
+	}, nil)
+	c.Assert(p.LinkRels, gc.DeepEquals, map[string]graph.RelType{
+		"https://test.com/de/":   graph.RelAlternate,
+		"https://test.com/moved": graph.RelRefresh,
+	})
+
+	s.resourceLinks = true
+	p = s.assertExtractedLinks(c, "https://test.com/en/", content, []string{
+		"https://test.com/about",
+		"https://test.com/app.js",
+		"https://test.com/de/",
+		"https://test.com/embed",
+		"https://test.com/logo-2x.png",
+		"https://test.com/logo-3x.png",
+		"https://test.com/logo.png",
+		"https://test.com/moved",
+	}, nil)
+	c.Assert(p.LinkRels, gc.DeepEquals, map[string]graph.RelType{
+		"https://test.com/app.js":      graph.RelScript,
+		"https://test.com/de/":         graph.RelAlternate,
+		"https://test.com/embed":       graph.RelFrame,
+		"https://test.com/logo-2x.png": graph.RelImage,
+		"https://test.com/logo-3x.png": graph.RelImage,
+		"https://test.com/logo.png":    graph.RelImage,
+		"https://test.com/moved":       graph.RelRefresh,
+	})
+}
+
+func (s *LinkExtractorTestSuite) TestLinkExtractorWithScope(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.privNetDetector.EXPECT().IsPrivate("other.com").Return(false, nil)
+
+	var err error
+	s.scope, err = scope.New(scope.Config{
+		ExcludeURLs:       []*regexp.Regexp{regexp.MustCompile(`/login`)},
+		MaxPathDepth:      2,
+		MaxPagesPerDomain: 3,
+		SameDomainOnly:    true,
+	})
+	c.Assert(err, gc.IsNil)
+
+	content := `
+<html>
+<head>
+<link rel="canonical" href="https://test.com/a/b/c"/>
+</head>
+<body>
+<a href="/docs">docs</a>
+<a href="/docs/intro" rel="nofollow">intro</a>
+<a href="/docs/guides/setup">too deep</a>
+<a href="/login?next=/docs">login</a>
+<a href="https://other.com/">off-site</a>
+<a href="/blog">blog</a>
+<a href="/about">over quota</a>
+</body>
+</html>
+`
+	s.assertExtractedLinks(c, "https://test.com/", content, []string{
+		"https://test.com/blog",
+		"https://test.com/docs",
+	}, []string{
+		"https://test.com/docs/intro",
+	})
+}
+
+func (s *LinkExtractorTestSuite) TestLinkExtractorAnchorTexts(c *gc.C) {
+	content := `
+<html>
+<body>
+<a href="/about" title="About">
+	About <b>us</b> &amp; our
+	team
+</a>
+<a href="/about">duplicate</a>
+<a href="/forum" rel="nofollow">Forum</a>
+<a href="/empty"></a>
+<a href="/self-closing"/>
+<a href="/long">` + strings.Repeat("é", 200) + `</a>
+</body>
+</html>
+`
+	p := s.assertExtractedLinks(c, "https://test.com/", content, []string{
+		"https://test.com/about",
+		"https://test.com/empty",
+		"https://test.com/long",
+		"https://test.com/self-closing",
+	}, []string{
+		"https://test.com/forum",
+	})
+	c.Assert(p.LinkAnchors, gc.DeepEquals, map[string]string{
+		"https://test.com/about": "About us & our team",
+		"https://test.com/forum": "Forum",
+		"https://test.com/long":  strings.Repeat("é", maxAnchorTextLen/2),
+	})
+}
+
+func (s *LinkExtractorTestSuite) TestLinkExtractorWithRedirectedFetch(c *gc.C) {
+	content := `
+<html>
+<body>
+<a href="./foo.html">link to foo</a>
+</body>
+</html>
+`
+	p := &crawlerPayload{URL: "https://test.com/content", BaseURL: "https://test.com/content/"}
+	_, err := p.RawContent.WriteString(content)
+	c.Assert(err, gc.IsNil)
+
+	_, err = newLinkExtractor(s.privNetDetector, nil, nil, nil, false).Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	c.Assert(p.Links, gc.DeepEquals, []string{"https://test.com/content/foo.html"})
+}
+
+func (s *LinkExtractorTestSuite) TestLinkExtractorWithNoFollowPage(c *gc.C) {
+	content := `
+<html>
+<head>
+<link rel="canonical" href="/article"/>
+<link rel="alternate" hreflang="de" href="/de/article"/>
+</head>
+<body>
+<a href="/other">other</a>
+</body>
+</html>
+`
+	p := &crawlerPayload{URL: "https://test.com/article?page=1", NoFollow: true}
+	_, err := p.RawContent.WriteString(content)
+	c.Assert(err, gc.IsNil)
+
+	// Only the canonical URL is extracted from nofollow pages.
+	_, err = newLinkExtractor(s.privNetDetector, nil, nil, nil, false).Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	c.Assert(p.Links, gc.DeepEquals, []string{"https://test.com/article"})
+	c.Assert(p.NoFollowLinks, gc.HasLen, 0)
+	c.Assert(p.CanonicalURL, gc.Equals, "https://test.com/article")
 }
 
-func (s *LinkExtractorTestSuite) assertExtractedLinks(c *gc.C, url, content string, expLinks []string, expNoFollowLinks []string) {
+func (s *LinkExtractorTestSuite) assertExtractedLinks(c *gc.C, url, content string, expLinks []string, expNoFollowLinks []string) *crawlerPayload {
 	p := &crawlerPayload{URL: url}
 	_, err := p.RawContent.WriteString(content)
 	c.Assert(err, gc.IsNil)
 
-	le := newLinkExtractor(s.privNetDetector)
+	le := newLinkExtractor(s.privNetDetector, nil, s.normalizer, s.scope, s.resourceLinks)
 	ret, err := le.Process(context.TODO(), p)
 	c.Assert(err, gc.IsNil)
 	c.Assert(ret, gc.DeepEquals, p)
@@ -227,4 +454,5 @@ func (s *LinkExtractorTestSuite) assertExtractedLinks(c *gc.C, url, content stri
 	sort.Strings(p.Links)
 	c.Assert(p.Links, gc.DeepEquals, expLinks)
 	c.Assert(p.NoFollowLinks, gc.DeepEquals, expNoFollowLinks)
+	return p
 }
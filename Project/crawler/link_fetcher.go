@@ -2,23 +2,63 @@ package crawler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/fetch"
+	"webcrawler/logging"
+	"webcrawler/metrics"
 	"webcrawler/pipeline"
 )
 
+// The maximum length of a captured header value. Longer values are
+// truncated.
+const maxCapturedHeaderLen = 1024
+
+// The names of the pipeline stages that report errors.
+const (
+	fetcherStage      = "link_fetcher"
+	graphUpdaterStage = "graph_updater"
+	textIndexerStage  = "text_indexer"
+)
+
 var _ pipeline.Processor = (*linkFetcher)(nil)
 
 type linkFetcher struct {
-	urlGetter   URLGetter
+	fetcher     Fetcher
 	netDetector PrivateNetworkDetector
+	robots      RobotsPolicy
+	pacer       Pacer
+	headerRules []HeaderRule
+	sources     []StructuredSource
+	rewriter    *urlRewriter
+	maxBodySize int64
+	errs        *errorReporter
+	logger      *slog.Logger
 }
 
-func newLinkFetcher(urlGetter URLGetter, netDetector PrivateNetworkDetector) *linkFetcher {
+func newLinkFetcher(fetcher Fetcher, netDetector PrivateNetworkDetector, robots RobotsPolicy, pacer Pacer, headerRules []HeaderRule, sources []StructuredSource, rewriter *urlRewriter, maxBodySize int64, errs *errorReporter, logger *slog.Logger) *linkFetcher {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
 	return &linkFetcher{
-		urlGetter:   urlGetter,
+		fetcher:     fetcher,
 		netDetector: netDetector,
+		robots:      robots,
+		pacer:       pacer,
+		headerRules: headerRules,
+		sources:     sources,
+		rewriter:    rewriter,
+		maxBodySize: maxBodySize,
+		errs:        errs,
+		logger:      logging.Component(logger, "crawler.link_fetcher"),
 	}
 }
 
@@ -27,42 +67,273 @@ func (lf *linkFetcher) Process(ctx context.Context, p pipeline.Payload) (pipelin
 
 	// Skip URLs that point to files that cannot contain html content.
 	if exclusionRegex.MatchString(payload.URL) {
+		lf.logger.Debug("skipping link to non-html file", logging.Link(payload.LinkID, payload.URL))
 		return nil, nil
 	}
 
-	// Never crawl links in private networks (e.g. link-local addresses).
-	// This is a security risk!
-	if isPrivate, err := lf.isPrivate(payload.URL); err != nil || isPrivate {
+	// Links that match a rewrite rule are fetched from their mirror.
+	fetchURL, mirrored := lf.rewriter.toMirror(payload.URL)
+	u, err := url.Parse(fetchURL)
+	if err != nil {
+		lf.logger.Warn("skipping link with invalid URL", logging.Link(payload.LinkID, payload.URL), "fetch_url", fetchURL, "err", err)
 		return nil, nil
 	}
 
-	res, err := lf.urlGetter.Get(payload.URL)
+	// Never crawl links in private networks (e.g. link-local addresses).
+	// This is a security risk! Mirrors are exempt as they are explicitly
+	// configured by the operator and typically live in internal networks.
+	if !mirrored {
+		if isPrivate, err := lf.netDetector.IsPrivate(u.Hostname()); err != nil {
+			lf.logger.Warn("skipping link with unresolvable host", logging.Link(payload.LinkID, payload.URL), "err", err)
+			lf.errs.report(fetcherStage, payload, errstore.ClassDNS, err)
+			return nil, nil
+		} else if isPrivate {
+			lf.logger.Debug("skipping link to private network", logging.Link(payload.LinkID, payload.URL))
+			return nil, nil
+		}
+	}
+
+	// Respect the robots.txt policy of the host that the link is fetched
+	// from.
+	if lf.robots != nil {
+		if allowed, err := lf.robots.Allowed(fetchURL); err != nil {
+			lf.logger.Warn("skipping link with unknown robots.txt policy", logging.Link(payload.LinkID, payload.URL), "fetch_url", fetchURL, "err", err)
+			lf.errs.report(fetcherStage, payload, errstore.ClassRobots, err)
+			return nil, nil
+		} else if !allowed {
+			lf.logger.Debug("skipping link disallowed by robots.txt", logging.Link(payload.LinkID, payload.URL), "fetch_url", fetchURL)
+			return nil, nil
+		}
+	}
+
+	// Wait for the next request slot of the host; links to hosts that are
+	// throttled for longer than the pacer is willing to wait are skipped.
+	if lf.pacer != nil {
+		if delayer, ok := lf.robots.(crawlDelayer); ok {
+			if delay, err := delayer.CrawlDelay(fetchURL); err == nil {
+				lf.pacer.SetCrawlDelay(u.Hostname(), delay)
+			}
+		}
+
+		waitStart := time.Now()
+		err := lf.pacer.Wait(ctx, u.Hostname())
+		metrics.ObserveSince(metrics.PacingWait, waitStart)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil
+			}
+			metrics.ThrottledLinks.Inc()
+			lf.logger.Debug("skipping link to throttled host", logging.Link(payload.LinkID, payload.URL), "err", err)
+			return nil, nil
+		}
+	}
+
+	// Skip links to quarantined hosts and apply the per-host timeout if
+	// adaptive timeouts are enabled.
+	fetchCtx, latencies := ctx, hostLatenciesFromContext(ctx)
+	if latencies != nil {
+		timeout, ok := latencies.timeoutFor(u.Hostname())
+		if !ok {
+			lf.logger.Debug("skipping link to quarantined host", logging.Link(payload.LinkID, payload.URL))
+			return nil, nil
+		}
+
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	startedAt := time.Now()
+	res, err := lf.get(fetchCtx, fetchURL)
 	if err != nil {
+		metrics.FetchResponses.WithLabelValues("error").Inc()
+		lf.recordLatency(ctx, latencies, u.Hostname(), startedAt)
+		lf.logger.Warn("fetch failed", logging.Link(payload.LinkID, payload.URL), "fetch_url", fetchURL, "err", err)
+		if ctx.Err() == nil {
+			lf.errs.report(fetcherStage, payload, errstore.Classify(err), err)
+		}
 		return nil, nil
 	}
-	_, err = io.Copy(&payload.RawContent, res.Body)
+	if lf.pacer != nil {
+		lf.pacer.Observe(u.Hostname(), res.StatusCode, res.Header)
+	}
+	// The body is decoded while it is received and the size limit applies
+	// to the decoded content. Byte budgets apply to the received bytes.
+	received := &countingReader{r: res.Body}
+	body, encoding, err := fetch.NewBodyDecoder(received, res.Header.Get("Content-Encoding"))
+	var n int64
+	if err == nil {
+		n, err = io.Copy(&payload.RawContent, io.LimitReader(body, lf.maxBodySize+1))
+		_ = body.Close()
+	}
 	_ = res.Body.Close()
-	if err != nil {
+	metrics.ObserveSince(metrics.FetchDuration, startedAt)
+	metrics.FetchResponses.WithLabelValues(strconv.Itoa(res.StatusCode)).Inc()
+	lf.recordLatency(ctx, latencies, u.Hostname(), startedAt)
+	if tracker := budgetTrackerFromContext(ctx); tracker != nil {
+		tracker.addBytes(received.n)
+	}
+	// Bodies may also be truncated by a size limit of the fetcher.
+	tooLarge := n > lf.maxBodySize
+	if errors.Is(err, fetch.ErrBodyTooLarge) {
+		err, tooLarge = nil, true
+	}
+	var unsupported fetch.UnsupportedEncodingError
+	switch {
+	case errors.As(err, &unsupported):
+		metrics.FetchContentEncodings.WithLabelValues("unsupported").Inc()
+		metrics.SkippedBodies.WithLabelValues("unsupported_encoding").Inc()
+		lf.logger.Debug("skipping link with unsupported content encoding", logging.Link(payload.LinkID, payload.URL), "content_encoding", unsupported.Encoding)
+		lf.errs.report(fetcherStage, payload, errstore.ClassEncoding, err)
+		return nil, nil
+	case err != nil && received.lastErr == nil:
+		// Errors that did not originate from reading the response body
+		// were raised by the decoders.
+		metrics.FetchContentEncodings.WithLabelValues(encoding).Inc()
+		metrics.SkippedBodies.WithLabelValues("decode_error").Inc()
+		lf.logger.Warn("skipping link with malformed response body", logging.Link(payload.LinkID, payload.URL), "content_encoding", encoding, "err", err)
+		lf.errs.report(fetcherStage, payload, errstore.ClassEncoding, err)
+		return nil, nil
+	case err != nil:
+		// Skip payloads whose body could not be read before the host
+		// timeout expired.
+		if fetchCtx.Err() != nil && ctx.Err() == nil {
+			lf.logger.Warn("host timeout expired while reading response body", logging.Link(payload.LinkID, payload.URL), "err", err)
+			lf.errs.report(fetcherStage, payload, errstore.ClassTimeout, err)
+			return nil, nil
+		}
+		lf.logger.Error("unable to read response body", logging.Link(payload.LinkID, payload.URL), "err", err)
+		if ctx.Err() == nil {
+			lf.errs.report(fetcherStage, payload, errstore.Classify(err), err)
+		}
 		return nil, err
 	}
+	metrics.FetchContentEncodings.WithLabelValues(encoding).Inc()
+	if tooLarge {
+		metrics.SkippedBodies.WithLabelValues("too_large").Inc()
+		lf.logger.Warn("skipping link with oversized response body", logging.Link(payload.LinkID, payload.URL), "content_encoding", encoding, "max_bytes", lf.maxBodySize)
+		lf.errs.report(fetcherStage, payload, errstore.ClassTooLarge, fmt.Errorf("response body exceeds %d bytes", lf.maxBodySize))
+		return nil, nil
+	}
 
 	// Skip payloads for invalid http status codes.
 	if res.StatusCode < 200 || res.StatusCode > 299 {
+		lf.logger.Debug("skipping link with non-2xx status code", logging.Link(payload.LinkID, payload.URL), "status", res.StatusCode)
+		lf.errs.report(fetcherStage, payload, errstore.ClassifyStatus(res.StatusCode), fmt.Errorf("unexpected status code %d", res.StatusCode))
 		return nil, nil
 	}
 
-	// Skip payloads for non-html payloads
-	if contentType := res.Header.Get("Content-Type"); !strings.Contains(contentType, "html") {
+	// Skip payloads with unsupported content types. Besides HTML pages,
+	// PDF documents, news feeds and JSON responses from structured
+	// sources are supported.
+	contentType := strings.ToLower(res.Header.Get("Content-Type"))
+	switch {
+	case strings.Contains(contentType, "html"):
+	case strings.Contains(contentType, "json") && matchStructuredSource(lf.sources, payload.URL) != nil:
+		payload.Format = formatStructured
+	case strings.Contains(contentType, "application/pdf"):
+		payload.Format = formatPDF
+	case strings.Contains(contentType, "rss"), strings.Contains(contentType, "atom"), strings.Contains(contentType, "xml"):
+		payload.Format = formatFeed
+	default:
+		lf.logger.Debug("skipping link with unsupported content type", logging.Link(payload.LinkID, payload.URL), "content_type", contentType)
 		return nil, nil
 	}
 
+	// Relative links in the content of redirected fetches are relative
+	// to the URL that the redirect chain ended at.
+	if res.Request != nil && res.Request.URL != nil && res.Request.URL.String() != fetchURL {
+		payload.BaseURL = res.Request.URL.String()
+	}
+
+	payload.Headers = lf.captureHeaders(res.Header)
+	payload.StatusCode, payload.Proto, payload.ResponseHeader = res.StatusCode, res.Proto, res.Header
+	payload.RobotsTags = append(payload.RobotsTags, res.Header.Values("X-Robots-Tag")...)
+	payload.FetchedAt = time.Now()
+	metrics.PagesFetched.Inc()
+	lf.logger.Debug("fetched link", logging.Link(payload.LinkID, payload.URL), "status", res.StatusCode, "bytes", n, "duration", time.Since(startedAt))
 	return payload, nil
 }
 
-func (lf *linkFetcher) isPrivate(URL string) (bool, error) {
-	u, err := url.Parse(URL)
+// captureHeaders applies the configured header rules to h and returns the
+// captured values keyed by lower-case header name or nil if no header was
+// captured. Multiple values for the same header are joined with a comma.
+func (lf *linkFetcher) captureHeaders(h http.Header) map[string]string {
+	var captured map[string]string
+	for _, rule := range lf.headerRules {
+		values := h.Values(rule.Header)
+		if len(values) == 0 {
+			continue
+		}
+
+		value := strings.Join(values, ", ")
+		if rule.Pattern != nil {
+			match := rule.Pattern.FindStringSubmatch(value)
+			switch {
+			case match == nil:
+				continue
+			case len(match) > 1:
+				value = match[1]
+			default:
+				value = match[0]
+			}
+		}
+		if len(value) > maxCapturedHeaderLen {
+			value = value[:maxCapturedHeaderLen]
+		}
+
+		if captured == nil {
+			captured = make(map[string]string)
+		}
+		captured[strings.ToLower(rule.Header)] = value
+	}
+	return captured
+}
+
+// crawlDelayer is implemented by robots policies that can report the
+// Crawl-delay of the host of a URL such as robots.Cache.
+type crawlDelayer interface {
+	CrawlDelay(rawURL string) (time.Duration, error)
+}
+
+// requestDoer is implemented by URL getters that can execute arbitrary
+// requests such as http.Client.
+type requestDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// getterFetcher adapts a URLGetter to the Fetcher interface. If the URL
+// getter supports it, requests are executed via its Do method so that they
+// negotiate the content encodings and are cancelled when their context is
+// done; otherwise, only the URL of each request is used.
+type getterFetcher struct {
+	getter URLGetter
+}
+
+func (f getterFetcher) Fetch(req *http.Request) (*http.Response, error) {
+	if doer, ok := f.getter.(requestDoer); ok {
+		return doer.Do(req)
+	}
+	return f.getter.Get(req.URL.String())
+}
+
+// get fetches rawURL. The request negotiates the content encodings that are
+// supported by fetch.NewBodyDecoder and is cancelled when the deadline of
+// ctx (if any) expires.
+func (lf *linkFetcher) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", fetch.AcceptEncoding)
+	return lf.fetcher.Fetch(req)
+}
+
+// recordLatency records the time elapsed since startedAt as the latency of a
+// fetch from host. Fetches that were interrupted because ctx was cancelled
+// are not recorded.
+func (lf *linkFetcher) recordLatency(ctx context.Context, latencies *hostLatencies, host string, startedAt time.Time) {
+	if latencies != nil && ctx.Err() == nil && latencies.record(host, time.Since(startedAt)) {
+		lf.logger.Warn("quarantining slow host for the rest of the pass", "host", host)
 	}
-	return lf.netDetector.IsPrivate(u.Hostname())
 }
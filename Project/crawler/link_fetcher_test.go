@@ -1,14 +1,34 @@
 package crawler
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/fetch"
 	"webcrawler/crawler/mocks"
+	"webcrawler/crawler/pacing"
+	"webcrawler/crawler/robots"
+	"webcrawler/logging"
 
+	"github.com/andybalholm/brotli"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
 	gc "gopkg.in/check.v1"
 )
 
@@ -17,9 +37,25 @@ var _ = gc.Suite(new(LinkFetcherTestSuite))
 type LinkFetcherTestSuite struct {
 	urlGetter       *mocks.MockURLGetter
 	privNetDetector *mocks.MockPrivateNetworkDetector
+	robots          RobotsPolicy
+	pacer           Pacer
+	headerRules     []HeaderRule
+	sources         []StructuredSource
+	rewriter        *urlRewriter
+	maxBodySize     int64
+	errs            *errorReporter
+	logs            bytes.Buffer
 }
 
 func (s *LinkFetcherTestSuite) SetUpTest(c *gc.C) {
+	s.robots = nil
+	s.pacer = nil
+	s.headerRules = nil
+	s.sources = nil
+	s.rewriter = nil
+	s.maxBodySize = 0
+	s.errs = nil
+	s.logs.Reset()
 }
 
 func (s *LinkFetcherTestSuite) TestLinkFetcherWithExcludedExtension(c *gc.C) {
@@ -48,6 +84,81 @@ func (s *LinkFetcherTestSuite) TestLinkFetcher(c *gc.C) {
 	c.Assert(p.RawContent.String(), gc.Equals, "hello")
 }
 
+func (s *LinkFetcherTestSuite) TestLinkFetcherWithRedirect(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+
+	res := makeResponse(200, "hello", "text/html")
+	res.Request = httptest.NewRequest(http.MethodGet, "http://example.com/docs/", nil)
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil)
+	s.urlGetter.EXPECT().Get("http://example.com/docs").Return(res, nil)
+
+	p := s.fetchLink(c, "http://example.com/docs")
+	c.Assert(p.URL, gc.Equals, "http://example.com/docs")
+	c.Assert(p.BaseURL, gc.Equals, "http://example.com/docs/")
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherCapturesHeaders(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.headerRules = []HeaderRule{
+		{Header: "X-Generator"},
+		{Header: "Link", Pattern: regexp.MustCompile(`<([^>]+)>;\s*rel="canonical"`)},
+		{Header: "Server", Pattern: regexp.MustCompile(`^nginx`)},
+		{Header: "X-Missing"},
+	}
+
+	res := makeResponse(200, "hello", "text/html")
+	res.Header.Set("X-Generator", "WordPress 6.4")
+	res.Header.Add("Link", `<http://example.com/style.css>; rel="preload"`)
+	res.Header.Add("Link", `<http://example.com/>; rel="canonical"`)
+	res.Header.Set("Server", "Apache")
+	res.Header.Add("X-Robots-Tag", "noindex")
+	res.Header.Add("X-Robots-Tag", "otherbot: nofollow")
+
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil)
+	s.urlGetter.EXPECT().Get("http://example.com/index.html").Return(res, nil)
+
+	p := s.fetchLink(c, "http://example.com/index.html")
+	c.Assert(p.Headers, gc.DeepEquals, map[string]string{
+		"x-generator": "WordPress 6.4",
+		"link":        "http://example.com/",
+	})
+
+	// Robots directives are always kept for the robots directive parser.
+	c.Assert(p.RobotsTags, gc.DeepEquals, []string{"noindex", "otherbot: nofollow"})
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherWithStructuredSource(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.sources = testStructuredSources
+
+	s.privNetDetector.EXPECT().IsPrivate("api.example.com").Return(false, nil).Times(2)
+	s.urlGetter.EXPECT().Get("http://api.example.com/books/1").Return(
+		makeResponse(200, `{"book": {}}`, "application/json; charset=utf-8"),
+		nil,
+	)
+	s.urlGetter.EXPECT().Get("http://api.example.com/authors/1").Return(
+		makeResponse(200, `{"author": {}}`, "application/json"),
+		nil,
+	)
+
+	p := s.fetchLink(c, "http://api.example.com/books/1")
+	c.Assert(p, gc.NotNil)
+	c.Assert(p.Format, gc.Equals, formatStructured)
+
+	// JSON responses for URLs that do not match a source are skipped.
+	p = s.fetchLink(c, "http://api.example.com/authors/1")
+	c.Assert(p, gc.IsNil)
+}
+
 func (s *LinkFetcherTestSuite) TestLinkFetcherForLinkWithPortNumber(c *gc.C) {
 	ctrl := gomock.NewController(c)
 	defer ctrl.Finish()
@@ -96,6 +207,33 @@ func (s *LinkFetcherTestSuite) TestLinkFetcherWithNonHTMLContentType(c *gc.C) {
 	c.Assert(p, gc.IsNil)
 }
 
+func (s *LinkFetcherTestSuite) TestLinkFetcherWithDocumentContentTypes(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+
+	specs := []struct {
+		contentType string
+		expFormat   contentFormat
+	}{
+		{contentType: "text/html; charset=utf-8", expFormat: formatHTML},
+		{contentType: "application/xhtml+xml", expFormat: formatHTML},
+		{contentType: "application/pdf", expFormat: formatPDF},
+		{contentType: "application/rss+xml", expFormat: formatFeed},
+		{contentType: "application/atom+xml", expFormat: formatFeed},
+		{contentType: "Text/XML", expFormat: formatFeed},
+	}
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil).Times(len(specs))
+	for _, spec := range specs {
+		s.urlGetter.EXPECT().Get("http://example.com/doc").Return(makeResponse(200, "body", spec.contentType), nil)
+
+		p := s.fetchLink(c, "http://example.com/doc")
+		c.Assert(p, gc.NotNil, gc.Commentf("content type %q", spec.contentType))
+		c.Assert(p.Format, gc.Equals, spec.expFormat, gc.Commentf("content type %q", spec.contentType))
+	}
+}
+
 func (s *LinkFetcherTestSuite) TestLinkFetcherWithLinkThatResolvesToPrivateNetwork(c *gc.C) {
 	ctrl := gomock.NewController(c)
 	defer ctrl.Finish()
@@ -108,9 +246,126 @@ func (s *LinkFetcherTestSuite) TestLinkFetcherWithLinkThatResolvesToPrivateNetwo
 	c.Assert(p, gc.IsNil)
 }
 
+func (s *LinkFetcherTestSuite) TestLinkFetcherWithMirror(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.rewriter = newURLRewriter([]URLRewriteRule{
+		{Canonical: "https://example.com/", Mirror: "http://10.0.0.1/example/"},
+	})
+
+	// Mirrors are not subject to the private network check.
+	s.urlGetter.EXPECT().Get("http://10.0.0.1/example/index.html").Return(
+		makeResponse(200, "hello", "text/html"),
+		nil,
+	)
+
+	p := s.fetchLink(c, "https://example.com/index.html")
+	c.Assert(p, gc.NotNil)
+	c.Assert(p.URL, gc.Equals, "https://example.com/index.html")
+	c.Assert(p.RawContent.String(), gc.Equals, "hello")
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherRespectsRobots(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+
+	var err error
+	s.robots, err = robots.NewCache(robots.Config{URLGetter: s.urlGetter})
+	c.Assert(err, gc.IsNil)
+
+	// The robots.txt file is only fetched once for both links.
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil).Times(2)
+	s.urlGetter.EXPECT().Get("http://example.com/robots.txt").Return(
+		makeResponse(200, "User-agent: *\nDisallow: /private/\n", "text/plain"),
+		nil,
+	)
+	s.urlGetter.EXPECT().Get("http://example.com/public/index.html").Return(
+		makeResponse(200, "hello", "text/html"),
+		nil,
+	)
+
+	c.Assert(s.fetchLink(c, "http://example.com/public/index.html"), gc.NotNil)
+	c.Assert(s.fetchLink(c, "http://example.com/private/index.html"), gc.IsNil)
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherPacesHosts(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+
+	var err error
+	s.robots, err = robots.NewCache(robots.Config{URLGetter: s.urlGetter})
+	c.Assert(err, gc.IsNil)
+	pacer, err := pacing.NewController(pacing.Config{MaxDelay: time.Minute, MaxWait: time.Second})
+	c.Assert(err, gc.IsNil)
+	s.pacer = pacer
+
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil).Times(2)
+	s.urlGetter.EXPECT().Get("http://example.com/robots.txt").Return(
+		makeResponse(200, "User-agent: *\nCrawl-delay: 5\n", "text/plain"),
+		nil,
+	)
+	res := makeResponse(503, "", "text/html")
+	res.Header.Set("Retry-After", "30")
+	s.urlGetter.EXPECT().Get("http://example.com/index.html").Return(res, nil)
+
+	// The second link is skipped without being fetched as the host asked
+	// the crawler to back off for longer than the pacer is willing to wait.
+	c.Assert(s.fetchLink(c, "http://example.com/index.html"), gc.IsNil)
+	c.Assert(s.fetchLink(c, "http://example.com/about.html"), gc.IsNil)
+
+	status := pacer.Status()
+	c.Assert(status, gc.HasLen, 1)
+	c.Assert(status[0].Host, gc.Equals, "example.com")
+	c.Assert(status[0].CrawlDelay, gc.Equals, 5*time.Second)
+	c.Assert(status[0].Backoff, gc.Equals, time.Second)
+	c.Assert(status[0].LastStatus, gc.Equals, 503)
+	c.Assert(status[0].RetryAfter, gc.NotNil)
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherLogsSkippedLinks(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil).Times(2)
+	s.urlGetter.EXPECT().Get("http://example.com/missing").Return(makeResponse(404, "", "text/html"), nil)
+	s.urlGetter.EXPECT().Get("http://example.com/down").Return(nil, errors.New("connection refused"))
+
+	c.Assert(s.fetchLink(c, "http://example.com/missing"), gc.IsNil)
+	c.Assert(s.fetchLink(c, "http://example.com/down"), gc.IsNil)
+
+	var records []map[string]interface{}
+	for dec := json.NewDecoder(&s.logs); dec.More(); {
+		var rec map[string]interface{}
+		c.Assert(dec.Decode(&rec), gc.IsNil)
+		records = append(records, rec)
+	}
+	c.Assert(records, gc.HasLen, 2)
+
+	c.Assert(records[0]["level"], gc.Equals, "DEBUG")
+	c.Assert(records[0]["component"], gc.Equals, "crawler.link_fetcher")
+	c.Assert(records[0]["status"], gc.Equals, float64(404))
+	c.Assert(records[0]["link"].(map[string]interface{})["host"], gc.Equals, "example.com")
+
+	c.Assert(records[1]["level"], gc.Equals, "WARN")
+	c.Assert(records[1]["msg"], gc.Equals, "fetch failed")
+	c.Assert(records[1]["err"], gc.Equals, "connection refused")
+	c.Assert(records[1]["link"].(map[string]interface{})["url"], gc.Equals, "http://example.com/down")
+}
+
 func (s *LinkFetcherTestSuite) fetchLink(c *gc.C, url string) *crawlerPayload {
+	logger, err := logging.New(logging.Config{Level: "debug", Output: &s.logs})
+	c.Assert(err, gc.IsNil)
+
 	p := &crawlerPayload{URL: url}
-	out, err := newLinkFetcher(s.urlGetter, s.privNetDetector).Process(context.TODO(), p)
+	out, err := newLinkFetcher(getterFetcher{getter: s.urlGetter}, s.privNetDetector, s.robots, s.pacer, s.headerRules, s.sources, s.rewriter, s.maxBodySize, s.errs, logger).Process(context.TODO(), p)
 	c.Assert(err, gc.IsNil)
 	if out != nil {
 		c.Assert(out, gc.FitsTypeOf, p)
@@ -130,3 +385,190 @@ func makeResponse(status int, body, contentType string) *http.Response {
 	}
 	return res
 }
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherNegotiatesContentEncoding(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.privNetDetector.EXPECT().IsPrivate("127.0.0.1").Return(false, nil)
+
+	content := "<html><body>compressed</body></html>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Accept-Encoding"), gc.Equals, fetch.AcceptEncoding)
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Encoding", "br")
+		_, _ = w.Write(encodeBody(c, "br", content))
+	}))
+	defer srv.Close()
+
+	lf := newLinkFetcher(getterFetcher{getter: srv.Client()}, s.privNetDetector, nil, nil, nil, nil, nil, 0, nil, nil)
+	out, err := lf.Process(context.TODO(), &crawlerPayload{URL: srv.URL + "/index.html"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.NotNil)
+	c.Assert(out.(*crawlerPayload).RawContent.String(), gc.Equals, content)
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherDecodesContentEncoding(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil)
+
+	content := "<html><body>zstd</body></html>"
+	res := makeResponse(200, string(encodeBody(c, "zstd", content)), "text/html")
+	res.Header.Set("Content-Encoding", "zstd")
+	s.urlGetter.EXPECT().Get("http://example.com/index.html").Return(res, nil)
+
+	p := s.fetchLink(c, "http://example.com/index.html")
+	c.Assert(p, gc.NotNil)
+	c.Assert(p.RawContent.String(), gc.Equals, content)
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherSkipsUndecodableBodies(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil).Times(4)
+	s.maxBodySize = 1024
+
+	specs := []struct {
+		path            string
+		contentEncoding string
+		body            string
+	}{
+		{path: "/unsupported", contentEncoding: "compress", body: "data"},
+		{path: "/corrupt", contentEncoding: "gzip", body: "not gzip data"},
+		// The limit applies to the decoded body.
+		{path: "/bomb", contentEncoding: "gzip", body: string(encodeBody(c, "gzip", strings.Repeat("a", 4096)))},
+		{path: "/large", body: strings.Repeat("a", 1025)},
+	}
+	for _, spec := range specs {
+		res := makeResponse(200, spec.body, "text/html")
+		if spec.contentEncoding != "" {
+			res.Header.Set("Content-Encoding", spec.contentEncoding)
+		}
+		s.urlGetter.EXPECT().Get("http://example.com"+spec.path).Return(res, nil)
+	}
+
+	for _, spec := range specs {
+		c.Logf("path: %s", spec.path)
+		c.Assert(s.fetchLink(c, "http://example.com"+spec.path), gc.IsNil)
+	}
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherWithFetcher(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil).Times(2)
+	store, err := errstore.NewStore(errstore.Config{})
+	c.Assert(err, gc.IsNil)
+	errs := &errorReporter{recorder: store}
+
+	var fetched []string
+	fetcher := fetch.Chain(fetch.Func(func(req *http.Request) (*http.Response, error) {
+		fetched = append(fetched, req.URL.Path)
+		c.Check(req.Header.Get("Accept-Encoding"), gc.Equals, fetch.AcceptEncoding)
+		if req.URL.Path == "/large" {
+			return makeResponse(200, strings.Repeat("a", 64), "text/html"), nil
+		}
+		return makeResponse(200, "<html>ok</html>", "text/html"), nil
+	}), fetch.MaxBodySize(32))
+	lf := newLinkFetcher(fetcher, s.privNetDetector, nil, nil, nil, nil, nil, 0, errs, nil)
+
+	out, err := lf.Process(context.TODO(), &crawlerPayload{URL: "http://example.com/index.html"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.NotNil)
+	c.Assert(out.(*crawlerPayload).RawContent.String(), gc.Equals, "<html>ok</html>")
+
+	// Bodies that exceed the size limit of the fetcher are skipped.
+	out, err = lf.Process(context.TODO(), &crawlerPayload{URL: "http://example.com/large"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.IsNil)
+	c.Assert(store.Records(errstore.Filter{Class: errstore.ClassTooLarge}, 0), gc.HasLen, 1)
+	c.Assert(fetched, gc.DeepEquals, []string{"/index.html", "/large"})
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherRecordsErrors(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	store, err := errstore.NewStore(errstore.Config{})
+	c.Assert(err, gc.IsNil)
+	s.errs = &errorReporter{recorder: store, passID: 7}
+
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil).Times(3)
+	s.privNetDetector.EXPECT().IsPrivate("unknown.example").Return(false, &net.DNSError{Err: "no such host", Name: "unknown.example"})
+	s.urlGetter.EXPECT().Get("http://example.com/missing").Return(makeResponse(404, "", "text/html"), nil).Times(2)
+	s.urlGetter.EXPECT().Get("http://example.com/down").Return(nil, &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)})
+
+	c.Assert(s.fetchLink(c, "http://example.com/missing"), gc.IsNil)
+	c.Assert(s.fetchLink(c, "http://example.com/missing"), gc.IsNil)
+	c.Assert(s.fetchLink(c, "http://example.com/down"), gc.IsNil)
+	c.Assert(s.fetchLink(c, "http://unknown.example/"), gc.IsNil)
+
+	top := store.TopClasses(errstore.Filter{PassID: 7}, 0)
+	c.Assert(top, gc.HasLen, 3)
+	c.Assert(top[0].Class, gc.Equals, errstore.ClassHTTPClient)
+	c.Assert(top[0].Count, gc.Equals, 2)
+
+	records := store.Records(errstore.Filter{Host: "example.com", Class: errstore.ClassConnection}, 0)
+	c.Assert(records, gc.HasLen, 1)
+	c.Assert(records[0].Stage, gc.Equals, "link_fetcher")
+	c.Assert(records[0].URL, gc.Equals, "http://example.com/down")
+
+	records = store.Records(errstore.Filter{Class: errstore.ClassDNS}, 0)
+	c.Assert(records, gc.HasLen, 1)
+	c.Assert(records[0].Host, gc.Equals, "unknown.example")
+}
+
+func (s *LinkFetcherTestSuite) TestLinkFetcherReportsFailedLinks(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	tracker := new(failureTrackerStub)
+	s.errs = &errorReporter{failures: tracker}
+
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil)
+	s.urlGetter.EXPECT().Get("http://example.com/down").Return(makeResponse(503, "", "text/html"), nil)
+
+	c.Assert(s.fetchLink(c, "http://example.com/down"), gc.IsNil)
+	c.Assert(tracker.failures, gc.DeepEquals, []string{"http://example.com/down: http_5xx: unexpected status code 503"})
+}
+
+// failureTrackerStub is a FailureTracker that records the reported failures.
+type failureTrackerStub struct {
+	failures []string
+}
+
+func (t *failureTrackerStub) LinkFailed(_ uuid.UUID, url string, class errstore.Class, err error) {
+	t.failures = append(t.failures, fmt.Sprintf("%s: %s: %v", url, class, err))
+}
+
+// encodeBody encodes content using the specified content encoding.
+func encodeBody(c *gc.C, encoding, content string) []byte {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	default:
+		c.Fatalf("unknown encoding %q", encoding)
+	}
+	c.Assert(err, gc.IsNil)
+	_, err = w.Write([]byte(content))
+	c.Assert(err, gc.IsNil)
+	c.Assert(w.Close(), gc.IsNil)
+	return buf.Bytes()
+}
@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"webcrawler/crawler/linkgraph/graph"
+)
+
+// graphMLEncoder writes a GraphML document. Links are described by their URL
+// and retrieval time and edges by their rel type, anchor text and nofollow
+// flag.
+type graphMLEncoder struct{}
+
+func (graphMLEncoder) begin(w *bufio.Writer) {
+	w.WriteString(xml.Header)
+	w.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	w.WriteString(`  <key id="url" for="node" attr.name="url" attr.type="string"/>` + "\n")
+	w.WriteString(`  <key id="retrievedAt" for="node" attr.name="retrievedAt" attr.type="long"/>` + "\n")
+	w.WriteString(`  <key id="rel" for="edge" attr.name="rel" attr.type="string"/>` + "\n")
+	w.WriteString(`  <key id="anchor" for="edge" attr.name="anchor" attr.type="string"/>` + "\n")
+	w.WriteString(`  <key id="nofollow" for="edge" attr.name="nofollow" attr.type="boolean"/>` + "\n")
+	w.WriteString(`  <graph id="webcrawler" edgedefault="directed">` + "\n")
+}
+
+func (graphMLEncoder) link(w *bufio.Writer, link *graph.Link) {
+	w.WriteString(`    <node id="` + link.ID.String() + `">`)
+	writeGraphMLData(w, "url", link.URL)
+	writeGraphMLData(w, "retrievedAt", strconv.FormatInt(link.RetrievedAt, 10))
+	w.WriteString("</node>\n")
+}
+
+func (graphMLEncoder) edge(w *bufio.Writer, edge *graph.Edge) {
+	w.WriteString(`    <edge id="` + edge.ID.String() + `" source="` + edge.Src.String() + `" target="` + edge.Dst.String() + `">`)
+	writeGraphMLData(w, "rel", edge.RelType.String())
+	if edge.AnchorText != "" {
+		writeGraphMLData(w, "anchor", edge.AnchorText)
+	}
+	writeGraphMLData(w, "nofollow", strconv.FormatBool(edge.NoFollow))
+	w.WriteString("</edge>\n")
+}
+
+func (graphMLEncoder) end(w *bufio.Writer) {
+	w.WriteString("  </graph>\n</graphml>\n")
+}
+
+func writeGraphMLData(w *bufio.Writer, key, value string) {
+	w.WriteString(`<data key="` + key + `">`)
+	_ = xml.EscapeText(w, []byte(value))
+	w.WriteString("</data>")
+}
+
+// dotEncoder writes a Graphviz directed graph whose nodes are labelled with
+// the link URLs.
+type dotEncoder struct{}
+
+func (dotEncoder) begin(w *bufio.Writer) { w.WriteString("digraph webcrawler {\n") }
+
+func (dotEncoder) link(w *bufio.Writer, link *graph.Link) {
+	w.WriteString("  " + dotQuote(link.ID.String()) + " [label=" + dotQuote(link.URL) + "];\n")
+}
+
+func (dotEncoder) edge(w *bufio.Writer, edge *graph.Edge) {
+	w.WriteString("  " + dotQuote(edge.Src.String()) + " -> " + dotQuote(edge.Dst.String()) + " [rel=" + dotQuote(edge.RelType.String()))
+	if edge.NoFollow {
+		w.WriteString(", style=dashed")
+	}
+	w.WriteString("];\n")
+}
+
+func (dotEncoder) end(w *bufio.Writer) { w.WriteString("}\n") }
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+
+// dotQuote returns s as a quoted DOT ID.
+func dotQuote(s string) string { return `"` + dotEscaper.Replace(s) + `"` }
+
+// csvEdgeEncoder writes an edge list whose Source and Target columns hold
+// link IDs. The column names are recognized by Gephi's spreadsheet importer.
+type csvEdgeEncoder struct{}
+
+func (csvEdgeEncoder) begin(w *bufio.Writer) {
+	writeCSV(w, "Source", "Target", "Rel", "Anchor", "NoFollow", "UpdatedAt")
+}
+
+func (csvEdgeEncoder) link(*bufio.Writer, *graph.Link) {}
+
+func (csvEdgeEncoder) edge(w *bufio.Writer, edge *graph.Edge) {
+	writeCSV(w, edge.Src.String(), edge.Dst.String(), edge.RelType.String(), edge.AnchorText,
+		strconv.FormatBool(edge.NoFollow), strconv.FormatInt(edge.UpdatedAt, 10))
+}
+
+func (csvEdgeEncoder) end(*bufio.Writer) {}
+
+// csvNodeEncoder writes a node list whose Id and Label columns hold the link
+// IDs and URLs.
+type csvNodeEncoder struct{}
+
+func (csvNodeEncoder) begin(w *bufio.Writer) { writeCSV(w, "Id", "Label", "RetrievedAt") }
+
+func (csvNodeEncoder) link(w *bufio.Writer, link *graph.Link) {
+	writeCSV(w, link.ID.String(), link.URL, strconv.FormatInt(link.RetrievedAt, 10))
+}
+
+func (csvNodeEncoder) edge(*bufio.Writer, *graph.Edge) {}
+
+func (csvNodeEncoder) end(*bufio.Writer) {}
+
+// writeCSV writes a single CSV record. Write errors are reported when w is
+// flushed.
+func writeCSV(w *bufio.Writer, fields ...string) {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(fields)
+	cw.Flush()
+}
@@ -0,0 +1,154 @@
+// Package export streams the contents of a link graph in formats understood by
+// graph visualization and analysis tools such as Gephi, Graphviz and neo4j.
+//
+// Links and edges are read via the LinkIterator and EdgeIterator APIs of the
+// graph and written as they are read, so exports of large graphs only hold a
+// single link or edge in memory at any time. Exports can be restricted to a
+// range of link IDs (e.g. a partition); edges are included if their source
+// link belongs to the range even if their destination does not.
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/partition"
+
+	"github.com/google/uuid"
+)
+
+// Format describes the encoding of an export.
+type Format string
+
+const (
+	// GraphML exports the links and edges as a GraphML document.
+	GraphML Format = "graphml"
+
+	// DOT exports the links and edges as a Graphviz directed graph.
+	DOT Format = "dot"
+
+	// CSV exports the edges as an edge list with Source and Target
+	// columns holding link IDs.
+	CSV Format = "csv"
+
+	// CSVNodes exports the links as a node list with Id and Label
+	// columns; it complements the CSV edge list.
+	CSVNodes Format = "csv-nodes"
+)
+
+// ParseFormat returns the Format with the specified name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case GraphML, DOT, CSV, CSVNodes:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q", name)
+	}
+}
+
+// GraphAPI defines the set of link graph operations required for exporting a
+// graph.
+type GraphAPI interface {
+	Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error)
+	Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error)
+}
+
+// Options configures an export.
+type Options struct {
+	// The encoding of the export. Defaults to GraphML.
+	Format Format
+
+	// The [FromID, ToID) range of link IDs to export. A zero ToID selects
+	// all links from FromID onwards.
+	FromID, ToID uuid.UUID
+}
+
+// Stats describes the outcome of an export.
+type Stats struct {
+	Links int
+	Edges int
+}
+
+// encoder writes the links and edges of an export in a particular format.
+// Links are always written before edges.
+type encoder interface {
+	begin(w *bufio.Writer)
+	link(w *bufio.Writer, link *graph.Link)
+	edge(w *bufio.Writer, edge *graph.Edge)
+	end(w *bufio.Writer)
+}
+
+// Write streams the links and edges of g that are selected by opts to w.
+func Write(w io.Writer, g GraphAPI, opts Options) (Stats, error) {
+	var (
+		enc        encoder
+		withLinks  = true
+		withEdges  = true
+		fromID, to = opts.FromID, opts.ToID
+	)
+	switch opts.Format {
+	case GraphML, "":
+		enc = graphMLEncoder{}
+	case DOT:
+		enc = dotEncoder{}
+	case CSV:
+		enc, withLinks = csvEdgeEncoder{}, false
+	case CSVNodes:
+		enc, withEdges = csvNodeEncoder{}, false
+	default:
+		return Stats{}, fmt.Errorf("export: unsupported format %q", opts.Format)
+	}
+	if to == uuid.Nil {
+		to = partition.MaxUUID
+	}
+
+	var stats Stats
+	bw := bufio.NewWriter(w)
+	enc.begin(bw)
+	if withLinks {
+		it, err := g.Links(fromID, to, math.MaxInt64)
+		if err != nil {
+			return stats, fmt.Errorf("export: %w", err)
+		}
+		for it.Next() {
+			enc.link(bw, it.Link())
+			stats.Links++
+		}
+		if err = closeIterator(it); err != nil {
+			return stats, fmt.Errorf("export: %w", err)
+		}
+	}
+	if withEdges {
+		// Edges that are upserted while the export is running are
+		// skipped.
+		it, err := g.Edges(fromID, to, time.Now().Add(time.Minute).Unix())
+		if err != nil {
+			return stats, fmt.Errorf("export: %w", err)
+		}
+		for it.Next() {
+			enc.edge(bw, it.Edge())
+			stats.Edges++
+		}
+		if err = closeIterator(it); err != nil {
+			return stats, fmt.Errorf("export: %w", err)
+		}
+	}
+	enc.end(bw)
+	if err := bw.Flush(); err != nil {
+		return stats, fmt.Errorf("export: %w", err)
+	}
+	return stats, nil
+}
+
+// closeIterator closes it and returns the error reported by it, if any.
+func closeIterator(it graph.Iterator) error {
+	if err := it.Error(); err != nil {
+		_ = it.Close()
+		return err
+	}
+	return it.Close()
+}
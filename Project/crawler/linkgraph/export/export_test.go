@@ -0,0 +1,135 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"testing"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/store/memory"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ExportTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type ExportTestSuite struct {
+	g          *memory.InMemoryGraph
+	home, page *graph.Link
+}
+
+func (s *ExportTestSuite) SetUpTest(c *gc.C) {
+	s.g = memory.NewInMemoryGraph()
+	s.home = &graph.Link{URL: "http://example.com/", RetrievedAt: 1700000000}
+	s.page = &graph.Link{URL: `http://example.com/?q="a"&b`}
+	c.Assert(s.g.UpsertLink(s.home), gc.IsNil)
+	c.Assert(s.g.UpsertLink(s.page), gc.IsNil)
+	c.Assert(s.g.UpsertEdge(&graph.Edge{Src: s.home.ID, Dst: s.page.ID, AnchorText: `<b>"page"</b>`, NoFollow: true}), gc.IsNil)
+}
+
+func (s *ExportTestSuite) TestGraphML(c *gc.C) {
+	var buf bytes.Buffer
+	stats, err := Write(&buf, s.g, Options{Format: GraphML})
+	c.Assert(err, gc.IsNil)
+	c.Assert(stats, gc.Equals, Stats{Links: 2, Edges: 1})
+
+	var doc struct {
+		Nodes []struct {
+			ID   string `xml:"id,attr"`
+			Data []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"data"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+			Target string `xml:"target,attr"`
+			Data   []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"data"`
+		} `xml:"graph>edge"`
+	}
+	c.Assert(xml.Unmarshal(buf.Bytes(), &doc), gc.IsNil)
+	c.Assert(doc.Nodes, gc.HasLen, 2)
+	urls := make(map[string]string)
+	for _, node := range doc.Nodes {
+		urls[node.ID] = node.Data[0].Value
+	}
+	c.Assert(urls, gc.DeepEquals, map[string]string{
+		s.home.ID.String(): s.home.URL,
+		s.page.ID.String(): s.page.URL,
+	})
+	c.Assert(doc.Edges, gc.HasLen, 1)
+	edge := doc.Edges[0]
+	c.Assert(edge.Source, gc.Equals, s.home.ID.String())
+	c.Assert(edge.Target, gc.Equals, s.page.ID.String())
+	c.Assert(edge.Data[1].Key, gc.Equals, "anchor")
+	c.Assert(edge.Data[1].Value, gc.Equals, `<b>"page"</b>`)
+	c.Assert(edge.Data[2].Value, gc.Equals, "true")
+}
+
+func (s *ExportTestSuite) TestDOT(c *gc.C) {
+	var buf bytes.Buffer
+	_, err := Write(&buf, s.g, Options{Format: DOT})
+	c.Assert(err, gc.IsNil)
+	out := buf.String()
+	c.Assert(out, gc.Matches, `(?s)digraph webcrawler \{\n.*\}\n`)
+	c.Assert(out, gc.Matches, `(?s).*"`+s.page.ID.String()+`" \[label="http://example.com/\?q=\\"a\\"&b"\];.*`)
+	c.Assert(out, gc.Matches, `(?s).*"`+s.home.ID.String()+`" -> "`+s.page.ID.String()+`" \[rel="hyperlink", style=dashed\];.*`)
+}
+
+func (s *ExportTestSuite) TestCSV(c *gc.C) {
+	var buf bytes.Buffer
+	stats, err := Write(&buf, s.g, Options{Format: CSV})
+	c.Assert(err, gc.IsNil)
+	c.Assert(stats, gc.Equals, Stats{Edges: 1})
+	records, err := csv.NewReader(&buf).ReadAll()
+	c.Assert(err, gc.IsNil)
+	c.Assert(records, gc.HasLen, 2)
+	c.Assert(records[0], gc.DeepEquals, []string{"Source", "Target", "Rel", "Anchor", "NoFollow", "UpdatedAt"})
+	c.Assert(records[1][:5], gc.DeepEquals, []string{s.home.ID.String(), s.page.ID.String(), "hyperlink", `<b>"page"</b>`, "true"})
+
+	buf.Reset()
+	stats, err = Write(&buf, s.g, Options{Format: CSVNodes})
+	c.Assert(err, gc.IsNil)
+	c.Assert(stats, gc.Equals, Stats{Links: 2})
+	records, err = csv.NewReader(&buf).ReadAll()
+	c.Assert(err, gc.IsNil)
+	c.Assert(records, gc.HasLen, 3)
+	c.Assert(records[0], gc.DeepEquals, []string{"Id", "Label", "RetrievedAt"})
+}
+
+func (s *ExportTestSuite) TestRange(c *gc.C) {
+	// Only the links in the range and the edges originating from them are
+	// exported.
+	var buf bytes.Buffer
+	stats, err := Write(&buf, s.g, Options{Format: CSVNodes, FromID: s.page.ID, ToID: nextID(s.page.ID)})
+	c.Assert(err, gc.IsNil)
+	c.Assert(stats, gc.Equals, Stats{Links: 1})
+
+	stats, err = Write(&buf, s.g, Options{Format: CSV, FromID: s.home.ID, ToID: nextID(s.home.ID)})
+	c.Assert(err, gc.IsNil)
+	c.Assert(stats, gc.Equals, Stats{Edges: 1})
+}
+
+func (s *ExportTestSuite) TestParseFormat(c *gc.C) {
+	format, err := ParseFormat("GraphML")
+	c.Assert(err, gc.IsNil)
+	c.Assert(format, gc.Equals, GraphML)
+	_, err = ParseFormat("gexf")
+	c.Assert(err, gc.ErrorMatches, `unsupported export format "gexf"`)
+}
+
+// nextID returns the ID that follows id.
+func nextID(id uuid.UUID) uuid.UUID {
+	for i := len(id) - 1; i >= 0; i-- {
+		if id[i]++; id[i] != 0 {
+			break
+		}
+	}
+	return id
+}
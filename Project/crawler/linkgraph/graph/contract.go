@@ -1,16 +1,134 @@
 package graph
 
 import (
+	"context"
+
 	"github.com/google/uuid"
 )
 
 type Graph interface {
 	UpsertLink(link *Link) error
 	FindLink(id uuid.UUID) (*Link, error)
+
+	// FindLinks looks up the links with the specified IDs using a single
+	// round-trip to the store. The returned slice is aligned with ids;
+	// entries for IDs that do not exist are nil. If any of the links is
+	// missing, the partial results are returned together with a
+	// *MissingLinksError that lists the missing IDs.
+	FindLinks(ids []uuid.UUID) ([]*Link, error)
+
 	UpsertEdge(edge *Edge) error
 	RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error
-	Links(fromID, toID uuid.UUID, retrievedBefore int64) (LinkIterator, error)
+
+	// Links returns an iterator for the set of links whose IDs belong to
+	// the [fromID, toID) range and which are due to be fetched before the
+	// provided unix timestamp, i.e. their NextFetchAt value is lower than
+	// dueBefore.
+	Links(fromID, toID uuid.UUID, dueBefore int64) (LinkIterator, error)
+
 	Edges(fromId, toID uuid.UUID, updatedBefore int64) (EdgeIterator, error)
+
+	// RecordLinkFailure creates or replaces the failure record of the link
+	// with ID f.LinkID and reschedules the link so that it is not due to
+	// be fetched before f.NextRetryAt or, if f.Quarantined is set, at
+	// all. ErrNotFound is returned if the link does not exist.
+	RecordLinkFailure(f *LinkFailure) error
+
+	// LinkFailure returns the failure record of the link with the
+	// specified ID or ErrNotFound if the link has no failure record.
+	LinkFailure(id uuid.UUID) (*LinkFailure, error)
+
+	// FailedLinks returns an iterator for the failure records of the
+	// links whose IDs belong to the [fromID, toID) range.
+	FailedLinks(fromID, toID uuid.UUID) (LinkFailureIterator, error)
+
+	// Diff returns an iterator for the set of changes that were applied to
+	// the graph by the crawl passes in the (passA, passB] range. Links are
+	// never removed from the graph so a diff only reports link additions
+	// and updates. As links only keep track of the last pass that updated
+	// them, link updates are reported accurately only when passB is the
+	// most recent pass.
+	Diff(passA, passB uint64) (ChangeIterator, error)
+
+	// LinksAsOf returns an iterator for the set of links whose IDs belong
+	// to the [fromID, toID) range and had been added to the graph by the
+	// end of the specified crawl pass.
+	LinksAsOf(fromID, toID uuid.UUID, passID uint64) (LinkIterator, error)
+
+	// EdgesAsOf returns an iterator for the set of edges whose source
+	// vertex IDs belong to the [fromID, toID) range and were present in the
+	// graph at the end of the specified crawl pass. Edges that were added
+	// by a later pass are skipped while edges that were removed by a later
+	// pass are still included.
+	//
+	// Together with LinksAsOf, this allows consumers such as PageRank to
+	// process a consistent snapshot of the graph while the crawler keeps
+	// updating it.
+	EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (EdgeIterator, error)
+}
+
+// CheckpointStore is implemented by graphs that can persist crawl
+// checkpoints alongside the links and edges.
+type CheckpointStore interface {
+	// SaveCheckpoint creates or replaces the checkpoint for the partition
+	// specified by cp.
+	SaveCheckpoint(cp *Checkpoint) error
+
+	// Checkpoint returns the checkpoint for the specified partition or
+	// ErrNotFound if no checkpoint has been recorded for it yet.
+	Checkpoint(partition int) (*Checkpoint, error)
+
+	// Checkpoints returns the checkpoints of all partitions ordered by
+	// partition.
+	Checkpoints() ([]*Checkpoint, error)
+}
+
+// RemovalPruner is implemented by graphs that keep records of the edges
+// removed by each crawl pass so that they can be reported by Diff and
+// EdgesAsOf.
+type RemovalPruner interface {
+	// PruneRemovals discards the records of the edges that were removed
+	// by the specified or an earlier crawl pass. Afterwards, Diff and
+	// EdgesAsOf only report accurate results for passes from passID on.
+	PruneRemovals(passID uint64) error
+}
+
+// EdgeGrouper is implemented by graphs that can iterate their edges grouped
+// by destination link, e.g. for aggregating the anchor text of the links
+// pointing to each page.
+type EdgeGrouper interface {
+	// EdgesGroupedByDst returns an iterator for the set of edges whose
+	// destination vertex IDs belong to the [fromID, toID) range. Groups are
+	// returned in destination ID order and the edges of each group are
+	// ordered by source ID. Edges are streamed from the graph, so only a
+	// single group is held in memory at any time.
+	EdgesGroupedByDst(fromID, toID uuid.UUID) (EdgeGroupIterator, error)
+}
+
+// Pinger is implemented by graphs that are backed by a remote server.
+type Pinger interface {
+	// Ping returns an error if the server cannot be reached or is unable
+	// to serve requests.
+	Ping(ctx context.Context) error
+}
+
+// Snapshotter is implemented by graphs that can provide a consistent view of
+// their links and edges while they are being updated.
+type Snapshotter interface {
+	// Snapshot returns a read-only view of the graph at the time of the
+	// call. The view is not affected by subsequent updates to the graph
+	// and iterating it does not block writers.
+	Snapshot() Snapshot
+}
+
+// Snapshot is an immutable, read-only view of a link graph. It supports the
+// read operations of Graph with the same semantics.
+type Snapshot interface {
+	FindLink(id uuid.UUID) (*Link, error)
+	Links(fromID, toID uuid.UUID, dueBefore int64) (LinkIterator, error)
+	Edges(fromID, toID uuid.UUID, updatedBefore int64) (EdgeIterator, error)
+	LinksAsOf(fromID, toID uuid.UUID, passID uint64) (LinkIterator, error)
+	EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (EdgeIterator, error)
 }
 
 // LinkIterator is implemented by objects that can iterate the graph links.
@@ -21,6 +139,15 @@ type LinkIterator interface {
 	Link() *Link
 }
 
+// LinkFailureIterator is implemented by objects that can iterate the failure
+// records of the graph links.
+type LinkFailureIterator interface {
+	Iterator
+
+	// Failure returns the currently fetched failure record.
+	Failure() *LinkFailure
+}
+
 // EdgeIterator is implemented by objects that can iterate the graph edges.
 type EdgeIterator interface {
 	Iterator
@@ -29,6 +156,26 @@ type EdgeIterator interface {
 	Edge() *Edge
 }
 
+// EdgeGroupIterator is implemented by objects that can iterate the graph
+// edges grouped by destination link.
+type EdgeGroupIterator interface {
+	Iterator
+
+	// Dst returns the destination link ID of the currently fetched group.
+	Dst() uuid.UUID
+
+	// Edges returns the edges of the currently fetched group.
+	Edges() []*Edge
+}
+
+// ChangeIterator is implemented by objects that can iterate graph changes.
+type ChangeIterator interface {
+	Iterator
+
+	// Change returns the currently fetched change.
+	Change() *Change
+}
+
 type Iterator interface {
 	// Next advances the iterator. If no more items are available or an
 	// error occurs, calls to Next() return false.
@@ -1,6 +1,10 @@
 package graph
 
 import (
+	"fmt"
+	"math"
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -8,6 +12,66 @@ type Link struct {
 	ID          uuid.UUID
 	URL         string
 	RetrievedAt int64
+
+	// The unix timestamp at which the link is due to be fetched again. A
+	// zero value indicates that the link is due immediately. When a link
+	// is upserted, the value of the upsert with the most recent
+	// RetrievedAt timestamp wins.
+	NextFetchAt int64
+
+	// A hash of the indexable content of the link as of its most recent
+	// retrieval (see index.ContentHash) or zero if unknown. When a link is
+	// upserted, the value of the upsert with the most recent RetrievedAt
+	// timestamp wins; upserts with the same timestamp only set the hash if
+	// none is stored.
+	ContentHash uint64
+
+	// The namespace of the crawl that the link belongs to. This field is
+	// populated by the graph store.
+	Namespace string
+
+	// The ID of the crawl pass that first inserted the link into the graph.
+	// This field is populated by the graph store.
+	FirstPassID uint64
+
+	// The ID of the crawl pass that last upserted the link. A zero value
+	// indicates that the link was not upserted as part of a crawl pass.
+	PassID uint64
+}
+
+// LinkFailure records the failed attempts to crawl a link since it was last
+// retrieved. A failure record is discarded once its link is upserted with a
+// RetrievedAt timestamp that is more recent than FailedAt.
+type LinkFailure struct {
+	LinkID uuid.UUID
+
+	// The URL of the link. This field is populated by the graph store.
+	URL string
+
+	// The reason for the most recent failure.
+	Reason string
+
+	// The number of consecutive failed attempts.
+	Attempts int
+
+	// The unix timestamp of the most recent failure.
+	FailedAt int64
+
+	// The unix timestamp at which the link is due to be retried.
+	NextRetryAt int64
+
+	// Quarantined is set once the link has exhausted its attempts.
+	// Quarantined links are never due to be fetched.
+	Quarantined bool
+}
+
+// DueAt returns the unix timestamp at which the link of f is due to be
+// fetched again.
+func (f *LinkFailure) DueAt() int64 {
+	if f.Quarantined {
+		return math.MaxInt64
+	}
+	return f.NextRetryAt
 }
 
 type Edge struct {
@@ -15,4 +79,173 @@ type Edge struct {
 	Src       uuid.UUID
 	Dst       uuid.UUID
 	UpdatedAt int64
+
+	// The namespace of the crawl that the edge belongs to. This field is
+	// populated by the graph store.
+	Namespace string
+
+	// The ID of the crawl pass that first inserted the edge into the graph.
+	// This field is populated by the graph store.
+	FirstPassID uint64
+
+	// The ID of the crawl pass that last upserted the edge. A zero value
+	// indicates that the edge was not upserted as part of a crawl pass.
+	PassID uint64
+
+	// The kind of reference from the source page to the destination.
+	// When an edge is upserted, the value of the most recent upsert wins.
+	RelType RelType
+
+	// The text of the hyperlink that created the edge. When an edge is
+	// upserted, the value of the most recent upsert wins.
+	AnchorText string
+
+	// NoFollow is set if the hyperlink that created the edge carries a
+	// rel="nofollow" attribute. When an edge is upserted, the value of the
+	// most recent upsert wins.
+	NoFollow bool
+}
+
+// RelType describes how the source page of an edge refers to its
+// destination.
+type RelType uint8
+
+const (
+	// RelHyperlink is an <a href> link.
+	RelHyperlink RelType = iota
+
+	// RelCanonical is the canonical URL declared via <link rel="canonical">.
+	RelCanonical
+
+	// RelAlternate is an alternate language version of the page declared
+	// via <link rel="alternate" hreflang="...">.
+	RelAlternate
+
+	// RelRefresh is the target of a <meta http-equiv="refresh"> redirect.
+	RelRefresh
+
+	// RelImage is an image referenced via <img src> or <img srcset>.
+	RelImage
+
+	// RelScript is a script referenced via <script src>.
+	RelScript
+
+	// RelFrame is a page embedded via <iframe src>.
+	RelFrame
+)
+
+var relTypeNames = []string{"hyperlink", "canonical", "alternate", "refresh", "image", "script", "frame"}
+
+// String returns the name of the rel type.
+func (t RelType) String() string {
+	if int(t) < len(relTypeNames) {
+		return relTypeNames[t]
+	}
+	return fmt.Sprintf("RelType(%d)", t)
+}
+
+// ParseRelType returns the RelType with the specified name.
+func ParseRelType(name string) (RelType, error) {
+	for i, relName := range relTypeNames {
+		if relName == name {
+			return RelType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rel type %q", name)
+}
+
+// ChangeType describes the kind of change reported by a graph diff.
+type ChangeType uint8
+
+const (
+	// ChangeLinkAdded indicates that a link was first discovered.
+	ChangeLinkAdded ChangeType = iota
+
+	// ChangeLinkUpdated indicates that an existing link was upserted.
+	ChangeLinkUpdated
+
+	// ChangeEdgeAdded indicates that a new edge was created.
+	ChangeEdgeAdded
+
+	// ChangeEdgeRemoved indicates that a stale edge was removed.
+	ChangeEdgeRemoved
+)
+
+// Change describes a single difference between two crawl passes. Depending
+// on the change type, either Link or Edge is populated.
+type Change struct {
+	Type ChangeType
+	Link *Link
+	Edge *Edge
+}
+
+// Checkpoint records the progress of the crawl passes over a partition of the
+// link graph so that an interrupted pass can be resumed after a restart.
+type Checkpoint struct {
+	// The partition of the link graph that the checkpoint refers to.
+	Partition int
+
+	// The ID of the most recent crawl pass over the partition.
+	PassID uint64
+
+	// The time when the pass started. The pass crawls the links that were
+	// retrieved before this time; links that have already been crawled by
+	// the pass are retrieved after it and are therefore skipped when the
+	// pass is resumed.
+	PassStartedAt time.Time
+
+	// The time when the pass completed or the zero time if the pass was
+	// interrupted before crawling all links.
+	CompletedAt time.Time
+
+	// The time when the checkpoint was recorded. This field is populated
+	// by the graph store.
+	UpdatedAt time.Time
+}
+
+// Completed returns true if the checkpointed pass crawled all links.
+func (cp *Checkpoint) Completed() bool {
+	return !cp.CompletedAt.IsZero()
+}
+
+// CompletedPass returns the most recent crawl pass that every partition in
+// cps has completed, or zero if cps is empty. The pass of an interrupted
+// checkpoint is still in progress, so only the preceding pass counts as
+// completed for its partition.
+func CompletedPass(cps []*Checkpoint) uint64 {
+	var passID uint64
+	for i, cp := range cps {
+		cpPassID := cp.PassID
+		if !cp.Completed() && cpPassID > 0 {
+			cpPassID--
+		}
+		if i == 0 || cpPassID < passID {
+			passID = cpPassID
+		}
+	}
+	return passID
+}
+
+// ArrangeLinks returns the entries of found in the order of ids, which is the
+// result format expected from FindLinks. Entries for IDs that are not present
+// in found are nil and reported via a *MissingLinksError.
+func ArrangeLinks(ids []uuid.UUID, found map[uuid.UUID]*Link) ([]*Link, error) {
+	var (
+		links   = make([]*Link, len(ids))
+		missing []uuid.UUID
+		seen    = make(map[uuid.UUID]struct{})
+	)
+	for i, id := range ids {
+		if links[i] = found[id]; links[i] != nil {
+			continue
+		}
+		if _, dup := seen[id]; !dup {
+			seen[id] = struct{}{}
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) != 0 {
+		return links, &MissingLinksError{IDs: missing}
+	}
+	return links, nil
 }
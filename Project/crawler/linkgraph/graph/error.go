@@ -1,6 +1,11 @@
 package graph
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
 
 var (
 	ErrNotFound = errors.New("not found")
@@ -8,4 +13,30 @@ var (
 	// ErrUnknownEdgeLinks is returned when attempting to create an edge
 	// with an invalid source and/or destination ID
 	ErrUnknownEdgeLinks = errors.New("unknown source and/or destination for edge")
+
+	// ErrInvalidURL is returned when attempting to upsert a link whose URL
+	// is rejected by the store's integrity constraints.
+	ErrInvalidURL = errors.New("invalid link URL")
+
+	// ErrLinkHasEdges is returned when attempting to remove a link that is
+	// still referenced by one or more edges.
+	ErrLinkHasEdges = errors.New("link is referenced by edges")
 )
+
+// MissingLinksError is returned by FindLinks when some of the requested links
+// do not exist. It wraps ErrNotFound.
+type MissingLinksError struct {
+	// The IDs of the missing links in the order they were requested.
+	IDs []uuid.UUID
+}
+
+// Error implements error.
+func (e *MissingLinksError) Error() string {
+	return fmt.Sprintf("%d link(s) not found", len(e.IDs))
+}
+
+// Unwrap returns ErrNotFound so that callers can check for missing links with
+// errors.Is.
+func (e *MissingLinksError) Unwrap() error {
+	return ErrNotFound
+}
@@ -3,6 +3,7 @@ package graphtest
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sort"
 	"sync"
@@ -97,6 +98,41 @@ func (s *SuiteBase) TestFindLink(c *gc.C) {
 	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
 }
 
+// TestFindLinks verifies the batched link lookup logic.
+func (s *SuiteBase) TestFindLinks(c *gc.C) {
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		link := &graph.Link{URL: fmt.Sprintf("https://example.com/%d", i)}
+		c.Assert(s.g.UpsertLink(link), gc.IsNil)
+		ids = append(ids, link.ID)
+	}
+
+	// Results must follow the order of the requested IDs.
+	links, err := s.g.FindLinks([]uuid.UUID{ids[2], ids[0], ids[1]})
+	c.Assert(err, gc.IsNil)
+	c.Assert(links, gc.HasLen, 3)
+	for i, expIdx := range []int{2, 0, 1} {
+		c.Assert(links[i].ID, gc.Equals, ids[expIdx])
+		c.Assert(links[i].URL, gc.Equals, fmt.Sprintf("https://example.com/%d", expIdx))
+	}
+
+	// Missing IDs yield nil entries and are reported once each.
+	unknown := uuid.New()
+	links, err = s.g.FindLinks([]uuid.UUID{unknown, ids[1], unknown})
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+	var missingErr *graph.MissingLinksError
+	c.Assert(errors.As(err, &missingErr), gc.Equals, true)
+	c.Assert(missingErr.IDs, gc.DeepEquals, []uuid.UUID{unknown})
+	c.Assert(links, gc.HasLen, 3)
+	c.Assert(links[0], gc.IsNil)
+	c.Assert(links[1].ID, gc.Equals, ids[1])
+	c.Assert(links[2], gc.IsNil)
+
+	links, err = s.g.FindLinks(nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(links, gc.HasLen, 0)
+}
+
 // TestConcurrentLinkIterators verifies that multiple clients can concurrently
 // access the store.
 func (s *SuiteBase) TestConcurrentLinkIterators(c *gc.C) {
@@ -151,22 +187,143 @@ func (s *SuiteBase) TestConcurrentLinkIterators(c *gc.C) {
 	}
 }
 
-// TestLinkIteratorTimeFilter verifies that the time-based filtering of the
-// link iterator works as expected.
+// TestLinkIteratorTimeFilter verifies that the link iterator only returns
+// the links that are due to be fetched before the provided timestamp.
 func (s *SuiteBase) TestLinkIteratorTimeFilter(c *gc.C) {
+	now := time.Now().Unix()
 	linkUUIDs := make([]uuid.UUID, 3)
-	linkInsertTimes := make([]int64, len(linkUUIDs))
+	linkDueTimes := make([]int64, len(linkUUIDs))
 	for i := 0; i < len(linkUUIDs); i++ {
-		link := &graph.Link{URL: fmt.Sprint(i), RetrievedAt: time.Now().Unix()}
+		linkDueTimes[i] = now + int64(i)*3600
+		link := &graph.Link{URL: fmt.Sprint(i), RetrievedAt: now, NextFetchAt: linkDueTimes[i]}
 		c.Assert(s.g.UpsertLink(link), gc.IsNil)
 		linkUUIDs[i] = link.ID
-		linkInsertTimes[i] = time.Now().Unix()
 	}
 
-	for i, t := range linkInsertTimes {
-		c.Logf("fetching links created before edge %d", i)
-		s.assertIteratedLinkIDsMatch(c, t, linkUUIDs[:i+1])
+	for i, t := range linkDueTimes {
+		c.Logf("fetching links due before link %d", i)
+		s.assertIteratedLinkIDsMatch(c, t, linkUUIDs[:i])
+		s.assertIteratedLinkIDsMatch(c, t+1, linkUUIDs[:i+1])
+	}
+}
+
+// TestUpsertLinkNextFetchAt verifies that upserts keep the next fetch time
+// of the most recent retrieval.
+func (s *SuiteBase) TestUpsertLinkNextFetchAt(c *gc.C) {
+	now := time.Now().Unix()
+	link := &graph.Link{URL: "https://example.com", RetrievedAt: now, NextFetchAt: now + 3600}
+	c.Assert(s.g.UpsertLink(link), gc.IsNil)
+
+	// Discovering the link again, e.g. as the destination of an edge,
+	// does not reschedule it.
+	c.Assert(s.g.UpsertLink(&graph.Link{URL: link.URL}), gc.IsNil)
+	stored, err := s.g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(stored.NextFetchAt, gc.Equals, now+3600)
+
+	// A more recent retrieval overrides the next fetch time even if it
+	// is earlier than the stored one.
+	c.Assert(s.g.UpsertLink(&graph.Link{URL: link.URL, RetrievedAt: now + 60, NextFetchAt: now + 1800}), gc.IsNil)
+	stored, err = s.g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(stored.RetrievedAt, gc.Equals, now+60)
+	c.Assert(stored.NextFetchAt, gc.Equals, now+1800)
+
+	// Links that have never been retrieved are due immediately.
+	fresh := &graph.Link{URL: "https://example.com/fresh"}
+	c.Assert(s.g.UpsertLink(fresh), gc.IsNil)
+	s.assertIteratedLinkIDsMatch(c, now, []uuid.UUID{fresh.ID})
+}
+
+// TestUpsertLinkContentHash verifies that upserts keep the content hash of
+// the most recent retrieval.
+func (s *SuiteBase) TestUpsertLinkContentHash(c *gc.C) {
+	now := time.Now().Unix()
+	link := &graph.Link{URL: "https://example.com", RetrievedAt: now, ContentHash: 1<<63 | 42}
+	c.Assert(s.g.UpsertLink(link), gc.IsNil)
+
+	// Discovering the link again does not clear its content hash.
+	c.Assert(s.g.UpsertLink(&graph.Link{URL: link.URL}), gc.IsNil)
+	stored, err := s.g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(stored.ContentHash, gc.Equals, link.ContentHash)
+
+	// A more recent retrieval overrides the content hash.
+	c.Assert(s.g.UpsertLink(&graph.Link{URL: link.URL, RetrievedAt: now + 60, ContentHash: 7}), gc.IsNil)
+	stored, err = s.g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(stored.ContentHash, gc.Equals, uint64(7))
+
+	// The content hash is returned by the link iterators.
+	it, err := s.partitionedLinkIterator(c, 0, 1, math.MaxInt64)
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Link().ContentHash, gc.Equals, uint64(7))
+	c.Assert(it.Close(), gc.IsNil)
+}
+
+// TestLinkFailures verifies that failure records reschedule their links and
+// are discarded once the link is retrieved again.
+func (s *SuiteBase) TestLinkFailures(c *gc.C) {
+	now := time.Now().Unix()
+	failing := &graph.Link{URL: "https://example.com/failing", RetrievedAt: now - 7200}
+	quarantined := &graph.Link{URL: "https://example.com/quarantined"}
+	healthy := &graph.Link{URL: "https://example.com/healthy"}
+	for _, link := range []*graph.Link{failing, quarantined, healthy} {
+		c.Assert(s.g.UpsertLink(link), gc.IsNil)
+	}
+
+	_, err := s.g.LinkFailure(failing.ID)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+	err = s.g.RecordLinkFailure(&graph.LinkFailure{LinkID: uuid.New(), Reason: "timeout", Attempts: 1, FailedAt: now})
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	c.Assert(s.g.RecordLinkFailure(&graph.LinkFailure{LinkID: failing.ID, Reason: "timeout", Attempts: 1, FailedAt: now - 60, NextRetryAt: now + 60}), gc.IsNil)
+	f := &graph.LinkFailure{LinkID: failing.ID, Reason: "http_5xx: unexpected status code 503", Attempts: 2, FailedAt: now, NextRetryAt: now + 600}
+	c.Assert(s.g.RecordLinkFailure(f), gc.IsNil)
+	c.Assert(f.URL, gc.Equals, failing.URL)
+	c.Assert(s.g.RecordLinkFailure(&graph.LinkFailure{LinkID: quarantined.ID, Reason: "dns", Attempts: 5, FailedAt: now, NextRetryAt: now + 600, Quarantined: true}), gc.IsNil)
+
+	got, err := s.g.LinkFailure(failing.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, f)
+
+	// Failed links are due once their retry time has been reached while
+	// quarantined links are never due.
+	s.assertIteratedLinkIDsMatch(c, now+1, []uuid.UUID{healthy.ID})
+	s.assertIteratedLinkIDsMatch(c, now+601, []uuid.UUID{failing.ID, healthy.ID})
+	s.assertIteratedLinkIDsMatch(c, math.MaxInt64, []uuid.UUID{failing.ID, healthy.ID})
+
+	// Rediscovering a link keeps its failure record.
+	c.Assert(s.g.UpsertLink(&graph.Link{URL: failing.URL}), gc.IsNil)
+	c.Assert(s.failedLinks(c), gc.DeepEquals, map[uuid.UUID]int{failing.ID: 2, quarantined.ID: 5})
+
+	// A successful retrieval discards the failure record.
+	c.Assert(s.g.UpsertLink(&graph.Link{URL: failing.URL, RetrievedAt: now + 1, NextFetchAt: now + 3600}), gc.IsNil)
+	_, err = s.g.LinkFailure(failing.ID)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+	c.Assert(s.failedLinks(c), gc.DeepEquals, map[uuid.UUID]int{quarantined.ID: 5})
+	stored, err := s.g.FindLink(failing.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(stored.NextFetchAt, gc.Equals, now+3600)
+}
+
+// failedLinks returns the attempt counts of the failure records of the graph
+// keyed by link ID.
+func (s *SuiteBase) failedLinks(c *gc.C) map[uuid.UUID]int {
+	from, to := s.partitionRange(c, 0, 1)
+	it, err := s.g.FailedLinks(from, to)
+	c.Assert(err, gc.IsNil)
+
+	got := make(map[uuid.UUID]int)
+	for it.Next() {
+		f := it.Failure()
+		c.Assert(f.URL, gc.Not(gc.Equals), "")
+		got[f.LinkID] = f.Attempts
 	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	return got
 }
 
 func (s *SuiteBase) assertIteratedLinkIDsMatch(c *gc.C, updatedBefore int64, exp []uuid.UUID) {
@@ -180,6 +337,10 @@ func (s *SuiteBase) assertIteratedLinkIDsMatch(c *gc.C, updatedBefore int64, exp
 	c.Assert(it.Error(), gc.IsNil)
 	c.Assert(it.Close(), gc.IsNil)
 
+	if len(exp) == 0 {
+		c.Assert(got, gc.HasLen, 0)
+		return
+	}
 	sort.Slice(got, func(l, r int) bool { return got[l].String() < got[r].String() })
 	sort.Slice(exp, func(l, r int) bool { return exp[l].String() < exp[r].String() })
 	c.Assert(got, gc.DeepEquals, exp)
@@ -244,6 +405,7 @@ func (s *SuiteBase) TestUpsertEdge(c *gc.C) {
 	c.Assert(edge.UpdatedAt == 0, gc.Equals, false, gc.Commentf("UpdatedAt field not set"))
 
 	// Update existing edge
+	waitForNextSecond()
 	other := &graph.Edge{
 		ID:  edge.ID,
 		Src: linkUUIDs[0],
@@ -263,6 +425,91 @@ func (s *SuiteBase) TestUpsertEdge(c *gc.C) {
 	c.Assert(errors.Is(err, graph.ErrUnknownEdgeLinks), gc.Equals, true)
 }
 
+// TestUpsertEdgeRelType verifies that the rel type of an edge is stored and
+// updated by subsequent upserts.
+func (s *SuiteBase) TestUpsertEdgeRelType(c *gc.C) {
+	src := &graph.Link{URL: "https://example.com"}
+	dst := &graph.Link{URL: "https://example.com/de"}
+	c.Assert(s.g.UpsertLink(src), gc.IsNil)
+	c.Assert(s.g.UpsertLink(dst), gc.IsNil)
+
+	edge := &graph.Edge{Src: src.ID, Dst: dst.ID, RelType: graph.RelAlternate}
+	c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+	c.Assert(edge.RelType, gc.Equals, graph.RelAlternate)
+	edges := s.outEdges(c, src.ID)
+	c.Assert(edges, gc.HasLen, 1)
+	c.Assert(edges[0].RelType, gc.Equals, graph.RelAlternate)
+
+	other := &graph.Edge{Src: src.ID, Dst: dst.ID, RelType: graph.RelHyperlink}
+	c.Assert(s.g.UpsertEdge(other), gc.IsNil)
+	c.Assert(other.ID, gc.Equals, edge.ID)
+	c.Assert(other.RelType, gc.Equals, graph.RelHyperlink)
+	edges = s.outEdges(c, src.ID)
+	c.Assert(edges, gc.HasLen, 1)
+	c.Assert(edges[0].RelType, gc.Equals, graph.RelHyperlink)
+}
+
+// TestUpsertEdgeAnchorAttributes verifies that the anchor text and nofollow
+// flag of an edge are stored, updated by subsequent upserts and preserved
+// when the edge is removed.
+func (s *SuiteBase) TestUpsertEdgeAnchorAttributes(c *gc.C) {
+	src := &graph.Link{URL: "https://example.com", PassID: 1}
+	dst := &graph.Link{URL: "https://example.com/about", PassID: 1}
+	c.Assert(s.g.UpsertLink(src), gc.IsNil)
+	c.Assert(s.g.UpsertLink(dst), gc.IsNil)
+
+	edge := &graph.Edge{Src: src.ID, Dst: dst.ID, PassID: 1, AnchorText: "About us"}
+	c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+	c.Assert(edge.AnchorText, gc.Equals, "About us")
+	c.Assert(edge.NoFollow, gc.Equals, false)
+	edges := s.outEdges(c, src.ID)
+	c.Assert(edges, gc.HasLen, 1)
+	c.Assert(edges[0].AnchorText, gc.Equals, "About us")
+	c.Assert(edges[0].NoFollow, gc.Equals, false)
+
+	other := &graph.Edge{Src: src.ID, Dst: dst.ID, PassID: 1, AnchorText: "Über uns", NoFollow: true}
+	c.Assert(s.g.UpsertEdge(other), gc.IsNil)
+	c.Assert(other.ID, gc.Equals, edge.ID)
+	edges = s.outEdges(c, src.ID)
+	c.Assert(edges, gc.HasLen, 1)
+	c.Assert(edges[0].AnchorText, gc.Equals, "Über uns")
+	c.Assert(edges[0].NoFollow, gc.Equals, true)
+
+	// Snapshots of earlier passes retain the attributes of removed edges.
+	c.Assert(s.g.UpsertLink(&graph.Link{URL: src.URL, PassID: 2}), gc.IsNil)
+	c.Assert(s.g.RemoveStaleEdges(src.ID, time.Now().Add(time.Hour).Unix()), gc.IsNil)
+	c.Assert(s.outEdges(c, src.ID), gc.HasLen, 0)
+
+	it, err := s.g.EdgesAsOf(uuid.Nil, uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"), 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Edge().AnchorText, gc.Equals, "Über uns")
+	c.Assert(it.Edge().NoFollow, gc.Equals, true)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+}
+
+// outEdges returns the edges that originate from src.
+func (s *SuiteBase) outEdges(c *gc.C, src uuid.UUID) []*graph.Edge {
+	it, err := s.g.Edges(
+		uuid.Nil,
+		uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"),
+		time.Now().Add(time.Hour).Unix(),
+	)
+	c.Assert(err, gc.IsNil)
+
+	var edges []*graph.Edge
+	for it.Next() {
+		if edge := it.Edge(); edge.Src == src {
+			edges = append(edges, edge)
+		}
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	return edges
+}
+
 // TestConcurrentEdgeIterators verifies that multiple clients can concurrently
 // access the store.
 func (s *SuiteBase) TestConcurrentEdgeIterators(c *gc.C) {
@@ -292,7 +539,7 @@ func (s *SuiteBase) TestConcurrentEdgeIterators(c *gc.C) {
 
 			itTagComment := gc.Commentf("iterator %d", id)
 			seen := make(map[string]bool)
-			it, err := s.partitionedEdgeIterator(c, 0, 1, time.Now().Unix())
+			it, err := s.partitionedEdgeIterator(c, 0, 1, updatedSoFar())
 			c.Assert(err, gc.IsNil, itTagComment)
 			defer func() {
 				c.Assert(it.Close(), gc.IsNil, itTagComment)
@@ -329,26 +576,30 @@ func (s *SuiteBase) TestConcurrentEdgeIterators(c *gc.C) {
 // edge iterator works as expected.
 func (s *SuiteBase) TestEdgeIteratorTimeFilter(c *gc.C) {
 	linkUUIDs := make([]uuid.UUID, 3)
-	linkInsertTimes := make([]time.Time, len(linkUUIDs))
 	for i := 0; i < len(linkUUIDs); i++ {
 		link := &graph.Link{URL: fmt.Sprint(i)}
 		c.Assert(s.g.UpsertLink(link), gc.IsNil)
 		linkUUIDs[i] = link.ID
-		linkInsertTimes[i] = time.Now()
 	}
 
+	// Each edge is upserted in a different second so that it can be told
+	// apart by its update time.
 	edgeUUIDs := make([]uuid.UUID, len(linkUUIDs))
-	edgeInsertTimes := make([]int64, len(linkUUIDs))
+	edgeUpdateTimes := make([]int64, len(linkUUIDs))
 	for i := 0; i < len(linkUUIDs); i++ {
+		if i != 0 {
+			waitForNextSecond()
+		}
 		edge := &graph.Edge{Src: linkUUIDs[0], Dst: linkUUIDs[i]}
 		c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
 		edgeUUIDs[i] = edge.ID
-		edgeInsertTimes[i] = time.Now().Unix()
+		edgeUpdateTimes[i] = edge.UpdatedAt
 	}
 
-	for i, t := range edgeInsertTimes {
-		c.Logf("fetching edges created before edge %d", i)
-		s.assertIteratedEdgeIDsMatch(c, t, edgeUUIDs[:i+1])
+	for i, t := range edgeUpdateTimes {
+		c.Logf("fetching edges updated before edge %d", i)
+		s.assertIteratedEdgeIDsMatch(c, t, edgeUUIDs[:i])
+		s.assertIteratedEdgeIDsMatch(c, t+1, edgeUUIDs[:i+1])
 	}
 }
 
@@ -363,6 +614,10 @@ func (s *SuiteBase) assertIteratedEdgeIDsMatch(c *gc.C, updatedBefore int64, exp
 	c.Assert(it.Error(), gc.IsNil)
 	c.Assert(it.Close(), gc.IsNil)
 
+	if len(exp) == 0 {
+		c.Assert(got, gc.HasLen, 0)
+		return
+	}
 	sort.Slice(got, func(l, r int) bool { return got[l].String() < got[r].String() })
 	sort.Slice(exp, func(l, r int) bool { return exp[l].String() < exp[r].String() })
 	c.Assert(got, gc.DeepEquals, exp)
@@ -404,7 +659,7 @@ func (s *SuiteBase) iteratePartitionedEdges(c *gc.C, numPartitions int) int {
 			linksInPartition[linkID] = struct{}{}
 		}
 
-		it, err := s.partitionedEdgeIterator(c, partition, numPartitions, time.Now().Unix())
+		it, err := s.partitionedEdgeIterator(c, partition, numPartitions, updatedSoFar())
 		c.Assert(err, gc.IsNil)
 		defer func() {
 			c.Assert(it.Close(), gc.IsNil)
@@ -449,8 +704,8 @@ func (s *SuiteBase) TestRemoveStaleEdges(c *gc.C) {
 		lastTs = e1.UpdatedAt
 	}
 
-	deleteBefore := lastTs
-	time.Sleep(250 * time.Millisecond)
+	deleteBefore := lastTs + 1
+	waitForNextSecond()
 
 	// The following edges will have an updated at value > lastTs
 	for i := 0; i < numEdges; i++ {
@@ -462,7 +717,7 @@ func (s *SuiteBase) TestRemoveStaleEdges(c *gc.C) {
 	}
 	c.Assert(s.g.RemoveStaleEdges(linkUUIDs[0], deleteBefore), gc.IsNil)
 
-	it, err := s.partitionedEdgeIterator(c, 0, 1, time.Now().Unix())
+	it, err := s.partitionedEdgeIterator(c, 0, 1, updatedSoFar())
 	c.Assert(err, gc.IsNil)
 	defer func() { c.Assert(it.Close(), gc.IsNil) }()
 
@@ -477,6 +732,475 @@ func (s *SuiteBase) TestRemoveStaleEdges(c *gc.C) {
 	c.Assert(seen, gc.Equals, numEdges)
 }
 
+// TestRemoveLink verifies that removing a link also removes the edges that
+// reference it. The test is skipped for graphs that do not support link
+// removal.
+func (s *SuiteBase) TestRemoveLink(c *gc.C) {
+	remover, ok := s.g.(interface{ RemoveLink(uuid.UUID) error })
+	if !ok {
+		c.Skip("graph does not support link removal")
+	}
+
+	linkIDs := make([]uuid.UUID, 3)
+	for i := range linkIDs {
+		link := &graph.Link{URL: fmt.Sprint(i)}
+		c.Assert(s.g.UpsertLink(link), gc.IsNil)
+		linkIDs[i] = link.ID
+	}
+
+	kept := &graph.Edge{Src: linkIDs[0], Dst: linkIDs[2]}
+	for _, edge := range []*graph.Edge{
+		{Src: linkIDs[0], Dst: linkIDs[1]},
+		{Src: linkIDs[1], Dst: linkIDs[2]},
+		kept,
+	} {
+		c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+	}
+
+	c.Assert(remover.RemoveLink(linkIDs[1]), gc.IsNil)
+	_, err := s.g.FindLink(linkIDs[1])
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	err = remover.RemoveLink(linkIDs[1])
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	// Edges to or from the removed link must be gone.
+	it, err := s.partitionedEdgeIterator(c, 0, 1, time.Now().Add(time.Minute).Unix())
+	c.Assert(err, gc.IsNil)
+	var edgeIDs []uuid.UUID
+	for it.Next() {
+		edgeIDs = append(edgeIDs, it.Edge().ID)
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(edgeIDs, gc.DeepEquals, []uuid.UUID{kept.ID})
+
+	// Creating an edge to the removed link must fail.
+	err = s.g.UpsertEdge(&graph.Edge{Src: linkIDs[0], Dst: linkIDs[1]})
+	c.Assert(errors.Is(err, graph.ErrUnknownEdgeLinks), gc.Equals, true)
+
+	// The URL of the removed link can be reused.
+	link := &graph.Link{URL: "1"}
+	c.Assert(s.g.UpsertLink(link), gc.IsNil)
+	c.Assert(link.ID, gc.Not(gc.Equals), linkIDs[1])
+}
+
+// TestDiff verifies that the changes applied by each crawl pass are
+// correctly reported when diffing two passes.
+func (s *SuiteBase) TestDiff(c *gc.C) {
+	var (
+		links      = make(map[string]*graph.Link)
+		removeAll  = time.Now().Add(time.Hour).Unix()
+		upsertLink = func(url string, pass uint64) {
+			link := &graph.Link{URL: url, PassID: pass}
+			c.Assert(s.g.UpsertLink(link), gc.IsNil)
+			links[url] = link
+		}
+		upsertEdge = func(src, dst string, pass uint64) {
+			edge := &graph.Edge{Src: links[src].ID, Dst: links[dst].ID, PassID: pass}
+			c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+		}
+	)
+
+	// Pass 1: discover A and B and link them together.
+	upsertLink("A", 1)
+	upsertLink("B", 1)
+	upsertEdge("A", "B", 1)
+
+	// Pass 2: recrawl A which now only links to a newly discovered link C
+	// which in turn links to B.
+	upsertLink("A", 2)
+	c.Assert(s.g.RemoveStaleEdges(links["A"].ID, removeAll), gc.IsNil)
+	upsertLink("C", 2)
+	upsertEdge("A", "C", 2)
+	upsertEdge("C", "B", 2)
+
+	// Pass 3: recrawl C which no longer links anywhere and discover D.
+	upsertLink("C", 3)
+	c.Assert(s.g.RemoveStaleEdges(links["C"].ID, removeAll), gc.IsNil)
+	upsertLink("D", 3)
+
+	specs := []struct {
+		passA, passB uint64
+		exp          []string
+	}{
+		{0, 1, []string{"link added A", "link added B", "edge added A->B"}},
+		{1, 2, []string{"link updated A", "link added C", "edge removed A->B", "edge added A->C", "edge added C->B"}},
+		{2, 3, []string{"link updated C", "link added D", "edge removed C->B"}},
+		{0, 3, []string{"link added A", "link added B", "link added C", "link added D", "edge added A->C"}},
+		{3, 3, nil},
+	}
+
+	for _, spec := range specs {
+		it, err := s.g.Diff(spec.passA, spec.passB)
+		c.Assert(err, gc.IsNil)
+		got := s.describeChanges(c, it)
+
+		sort.Strings(spec.exp)
+		c.Assert(got, gc.DeepEquals, spec.exp, gc.Commentf("diff(%d, %d)", spec.passA, spec.passB))
+	}
+}
+
+// TestAsOfIterators verifies that LinksAsOf and EdgesAsOf return a snapshot
+// of the graph as it was at the end of a particular crawl pass.
+func (s *SuiteBase) TestAsOfIterators(c *gc.C) {
+	var (
+		links      = make(map[string]*graph.Link)
+		removeAll  = time.Now().Add(time.Hour).Unix()
+		upsertLink = func(url string, pass uint64) {
+			link := &graph.Link{URL: url, PassID: pass}
+			c.Assert(s.g.UpsertLink(link), gc.IsNil)
+			links[url] = link
+		}
+		upsertEdge = func(src, dst string, pass uint64) {
+			edge := &graph.Edge{Src: links[src].ID, Dst: links[dst].ID, PassID: pass}
+			c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+		}
+	)
+
+	// Pass 1: discover A and B and link them together.
+	upsertLink("A", 1)
+	upsertLink("B", 1)
+	upsertEdge("A", "B", 1)
+
+	// Pass 2: recrawl A which now only links to a newly discovered link C.
+	upsertLink("A", 2)
+	c.Assert(s.g.RemoveStaleEdges(links["A"].ID, removeAll), gc.IsNil)
+	upsertLink("C", 2)
+	upsertEdge("A", "C", 2)
+
+	specs := []struct {
+		passID   uint64
+		expLinks []string
+		expEdges []string
+	}{
+		{0, nil, nil},
+		{1, []string{"A", "B"}, []string{"A->B"}},
+		{2, []string{"A", "B", "C"}, []string{"A->C"}},
+	}
+
+	minUUID := uuid.Nil
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	for _, spec := range specs {
+		linkIt, err := s.g.LinksAsOf(minUUID, maxUUID, spec.passID)
+		c.Assert(err, gc.IsNil)
+		var gotLinks []string
+		for linkIt.Next() {
+			gotLinks = append(gotLinks, linkIt.Link().URL)
+		}
+		c.Assert(linkIt.Error(), gc.IsNil)
+		c.Assert(linkIt.Close(), gc.IsNil)
+		sort.Strings(gotLinks)
+		c.Assert(gotLinks, gc.DeepEquals, spec.expLinks, gc.Commentf("links as of pass %d", spec.passID))
+
+		edgeIt, err := s.g.EdgesAsOf(minUUID, maxUUID, spec.passID)
+		c.Assert(err, gc.IsNil)
+		var gotEdges []string
+		for edgeIt.Next() {
+			edge := edgeIt.Edge()
+			src, err := s.g.FindLink(edge.Src)
+			c.Assert(err, gc.IsNil)
+			dst, err := s.g.FindLink(edge.Dst)
+			c.Assert(err, gc.IsNil)
+			gotEdges = append(gotEdges, src.URL+"->"+dst.URL)
+		}
+		c.Assert(edgeIt.Error(), gc.IsNil)
+		c.Assert(edgeIt.Close(), gc.IsNil)
+		sort.Strings(gotEdges)
+		c.Assert(gotEdges, gc.DeepEquals, spec.expEdges, gc.Commentf("edges as of pass %d", spec.passID))
+	}
+}
+
+// TestPruneRemovals verifies that pruning discards the edge removals of the
+// specified and earlier passes while later removals are still reported. It is
+// skipped for graphs that do not implement graph.RemovalPruner.
+func (s *SuiteBase) TestPruneRemovals(c *gc.C) {
+	pruner, ok := s.g.(graph.RemovalPruner)
+	if !ok {
+		c.Skip("graph does not implement graph.RemovalPruner")
+	}
+
+	var (
+		links      = make(map[string]*graph.Link)
+		removeAll  = time.Now().Add(time.Hour).Unix()
+		upsertLink = func(url string, pass uint64) {
+			link := &graph.Link{URL: url, PassID: pass}
+			c.Assert(s.g.UpsertLink(link), gc.IsNil)
+			links[url] = link
+		}
+		upsertEdge = func(src, dst string, pass uint64) {
+			edge := &graph.Edge{Src: links[src].ID, Dst: links[dst].ID, PassID: pass}
+			c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+		}
+	)
+
+	// Pass 1 links A to B, pass 2 replaces the edge with A->C and C->B and
+	// pass 3 removes C->B.
+	upsertLink("A", 1)
+	upsertLink("B", 1)
+	upsertEdge("A", "B", 1)
+	upsertLink("A", 2)
+	c.Assert(s.g.RemoveStaleEdges(links["A"].ID, removeAll), gc.IsNil)
+	upsertLink("C", 2)
+	upsertEdge("A", "C", 2)
+	upsertEdge("C", "B", 2)
+	upsertLink("C", 3)
+	c.Assert(s.g.RemoveStaleEdges(links["C"].ID, removeAll), gc.IsNil)
+
+	c.Assert(pruner.PruneRemovals(2), gc.IsNil)
+
+	specs := []struct {
+		passA, passB uint64
+		exp          []string
+	}{
+		// The removal of A->B by pass 2 has been pruned.
+		{1, 2, []string{"link updated A", "link added C", "edge added A->C", "edge added C->B"}},
+		{2, 3, []string{"link updated C", "edge removed C->B"}},
+	}
+	for _, spec := range specs {
+		it, err := s.g.Diff(spec.passA, spec.passB)
+		c.Assert(err, gc.IsNil)
+		got := s.describeChanges(c, it)
+
+		sort.Strings(spec.exp)
+		c.Assert(got, gc.DeepEquals, spec.exp, gc.Commentf("diff(%d, %d)", spec.passA, spec.passB))
+	}
+
+	// Edges removed after the pruned passes are still part of the graph
+	// as of pass 2.
+	it, err := s.g.EdgesAsOf(uuid.Nil, uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"), 2)
+	c.Assert(err, gc.IsNil)
+	var dsts []uuid.UUID
+	for it.Next() {
+		dsts = append(dsts, it.Edge().Dst)
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(dsts, gc.HasLen, 2)
+}
+
+// TestCheckpoints verifies that checkpoints are persisted per partition. It
+// is skipped for graphs that do not implement graph.CheckpointStore.
+func (s *SuiteBase) TestCheckpoints(c *gc.C) {
+	store, ok := s.g.(graph.CheckpointStore)
+	if !ok {
+		c.Skip("graph does not implement graph.CheckpointStore")
+	}
+
+	_, err := store.Checkpoint(0)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	startedAt := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	interrupted := &graph.Checkpoint{Partition: 0, PassID: 3, PassStartedAt: startedAt}
+	c.Assert(store.SaveCheckpoint(interrupted), gc.IsNil)
+	c.Assert(interrupted.UpdatedAt.IsZero(), gc.Equals, false, gc.Commentf("expected the update time to be populated"))
+	c.Assert(store.SaveCheckpoint(&graph.Checkpoint{Partition: 1, PassID: 7, PassStartedAt: startedAt}), gc.IsNil)
+
+	got, err := store.Checkpoint(0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.PassID, gc.Equals, uint64(3))
+	c.Assert(got.PassStartedAt.Equal(startedAt), gc.Equals, true)
+	c.Assert(got.Completed(), gc.Equals, false)
+
+	// Saving a checkpoint for the same partition replaces the old one.
+	completedAt := startedAt.Add(30 * time.Minute)
+	c.Assert(store.SaveCheckpoint(&graph.Checkpoint{Partition: 0, PassID: 3, PassStartedAt: startedAt, CompletedAt: completedAt}), gc.IsNil)
+	got, err = store.Checkpoint(0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Completed(), gc.Equals, true)
+	c.Assert(got.CompletedAt.Equal(completedAt), gc.Equals, true)
+
+	got, err = store.Checkpoint(1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.PassID, gc.Equals, uint64(7))
+
+	list, err := store.Checkpoints()
+	c.Assert(err, gc.IsNil)
+	c.Assert(list, gc.HasLen, 2)
+	for i, cp := range list {
+		c.Assert(cp.Partition, gc.Equals, i)
+	}
+	c.Assert(list[1].PassID, gc.Equals, uint64(7))
+
+	// Partition 0 completed pass 3 while partition 1 is still crawling
+	// pass 7.
+	c.Assert(graph.CompletedPass(list), gc.Equals, uint64(3))
+	c.Assert(graph.CompletedPass(list[1:]), gc.Equals, uint64(6))
+	c.Assert(graph.CompletedPass(nil), gc.Equals, uint64(0))
+}
+
+// TestEdgesGroupedByDst verifies that edges are grouped by destination and
+// that the groups and their edges are returned in ID order. It is skipped for
+// graphs that do not implement graph.EdgeGrouper.
+func (s *SuiteBase) TestEdgesGroupedByDst(c *gc.C) {
+	grouper, ok := s.g.(graph.EdgeGrouper)
+	if !ok {
+		c.Skip("graph does not implement graph.EdgeGrouper")
+	}
+
+	// Create enough edges for stores that fetch edges in batches to
+	// resume iteration in the middle of a group.
+	numDsts, numSrcs := 10, 30
+	linkUUIDs := make([]uuid.UUID, numDsts+numSrcs)
+	for i := range linkUUIDs {
+		link := &graph.Link{URL: fmt.Sprint(i)}
+		c.Assert(s.g.UpsertLink(link), gc.IsNil)
+		linkUUIDs[i] = link.ID
+	}
+	expSrcs := make(map[uuid.UUID][]string)
+	for _, dst := range linkUUIDs[:numDsts] {
+		for _, src := range linkUUIDs[numDsts:] {
+			c.Assert(s.g.UpsertEdge(&graph.Edge{Src: src, Dst: dst}), gc.IsNil)
+			expSrcs[dst] = append(expSrcs[dst], src.String())
+		}
+		sort.Strings(expSrcs[dst])
+	}
+
+	// Check with multiple partitions to verify that groups are selected by
+	// their destination ID.
+	numPartitions := 3
+	gotSrcs := make(map[uuid.UUID][]string)
+	for partition := 0; partition < numPartitions; partition++ {
+		from, to := s.partitionRange(c, partition, numPartitions)
+		it, err := grouper.EdgesGroupedByDst(from, to)
+		c.Assert(err, gc.IsNil)
+
+		var lastDst string
+		for it.Next() {
+			dst := it.Dst()
+			c.Assert(dst.String() >= from.String() && dst.String() < to.String(), gc.Equals, true, gc.Commentf("group destination outside of partition range"))
+			c.Assert(dst.String() > lastDst, gc.Equals, true, gc.Commentf("groups not ordered by destination"))
+			_, seen := gotSrcs[dst]
+			c.Assert(seen, gc.Equals, false, gc.Commentf("iterator returned the same group twice"))
+			lastDst = dst.String()
+
+			srcs := []string{}
+			for _, edge := range it.Edges() {
+				c.Assert(edge.Dst, gc.Equals, dst)
+				srcs = append(srcs, edge.Src.String())
+			}
+			c.Assert(sort.StringsAreSorted(srcs), gc.Equals, true, gc.Commentf("group edges not ordered by source"))
+			gotSrcs[dst] = srcs
+		}
+		c.Assert(it.Error(), gc.IsNil)
+		c.Assert(it.Close(), gc.IsNil)
+	}
+	c.Assert(gotSrcs, gc.DeepEquals, expSrcs)
+}
+
+// TestSnapshot verifies that snapshots are not affected by updates applied to
+// the graph after they were taken. It is skipped for graphs that do not
+// implement graph.Snapshotter.
+func (s *SuiteBase) TestSnapshot(c *gc.C) {
+	snapshotter, ok := s.g.(graph.Snapshotter)
+	if !ok {
+		c.Skip("graph does not implement graph.Snapshotter")
+	}
+
+	var (
+		links      = make(map[string]*graph.Link)
+		minUUID    = uuid.Nil
+		maxUUID    = uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+		future     = time.Now().Add(time.Hour).Unix()
+		retrieved  = time.Now().Add(-time.Hour).Truncate(time.Second).Unix()
+		upsertLink = func(url string, retrievedAt int64) {
+			link := &graph.Link{URL: url, RetrievedAt: retrievedAt}
+			c.Assert(s.g.UpsertLink(link), gc.IsNil)
+			links[url] = link
+		}
+		upsertEdge = func(src, dst, anchor string) {
+			edge := &graph.Edge{Src: links[src].ID, Dst: links[dst].ID, AnchorText: anchor}
+			c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+		}
+		describe = func(src interface {
+			Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error)
+			Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error)
+		}) ([]string, []string) {
+			var gotLinks, gotEdges []string
+			urls := make(map[uuid.UUID]string)
+			linkIt, err := src.Links(minUUID, maxUUID, future)
+			c.Assert(err, gc.IsNil)
+			for linkIt.Next() {
+				link := linkIt.Link()
+				urls[link.ID] = link.URL
+				gotLinks = append(gotLinks, link.URL)
+			}
+			c.Assert(linkIt.Error(), gc.IsNil)
+			c.Assert(linkIt.Close(), gc.IsNil)
+
+			edgeIt, err := src.Edges(minUUID, maxUUID, future)
+			c.Assert(err, gc.IsNil)
+			for edgeIt.Next() {
+				edge := edgeIt.Edge()
+				gotEdges = append(gotEdges, urls[edge.Src]+"->"+urls[edge.Dst]+" "+edge.AnchorText)
+			}
+			c.Assert(edgeIt.Error(), gc.IsNil)
+			c.Assert(edgeIt.Close(), gc.IsNil)
+			sort.Strings(gotLinks)
+			sort.Strings(gotEdges)
+			return gotLinks, gotEdges
+		}
+	)
+
+	upsertLink("A", retrieved)
+	upsertLink("B", retrieved)
+	upsertEdge("A", "B", "old")
+	snap := snapshotter.Snapshot()
+
+	// Update existing links and edges and add new ones.
+	upsertLink("A", retrieved+60)
+	upsertLink("C", retrieved)
+	upsertEdge("A", "B", "new")
+	upsertEdge("A", "C", "new")
+
+	gotLinks, gotEdges := describe(snap)
+	c.Assert(gotLinks, gc.DeepEquals, []string{"A", "B"})
+	c.Assert(gotEdges, gc.DeepEquals, []string{"A->B old"})
+
+	link, err := snap.FindLink(links["A"].ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(link.RetrievedAt, gc.Equals, retrieved)
+	_, err = snap.FindLink(links["C"].ID)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	// The graph and new snapshots reflect the updates.
+	expLinks, expEdges := []string{"A", "B", "C"}, []string{"A->B new", "A->C new"}
+	gotLinks, gotEdges = describe(s.g)
+	c.Assert(gotLinks, gc.DeepEquals, expLinks)
+	c.Assert(gotEdges, gc.DeepEquals, expEdges)
+	gotLinks, gotEdges = describe(snapshotter.Snapshot())
+	c.Assert(gotLinks, gc.DeepEquals, expLinks)
+	c.Assert(gotEdges, gc.DeepEquals, expEdges)
+}
+
+func (s *SuiteBase) describeChanges(c *gc.C, it graph.ChangeIterator) []string {
+	urlFor := func(id uuid.UUID) string {
+		link, err := s.g.FindLink(id)
+		c.Assert(err, gc.IsNil)
+		return link.URL
+	}
+
+	var got []string
+	for it.Next() {
+		change := it.Change()
+		switch change.Type {
+		case graph.ChangeLinkAdded:
+			got = append(got, "link added "+change.Link.URL)
+		case graph.ChangeLinkUpdated:
+			got = append(got, "link updated "+change.Link.URL)
+		case graph.ChangeEdgeAdded:
+			got = append(got, "edge added "+urlFor(change.Edge.Src)+"->"+urlFor(change.Edge.Dst))
+		case graph.ChangeEdgeRemoved:
+			got = append(got, "edge removed "+urlFor(change.Edge.Src)+"->"+urlFor(change.Edge.Dst))
+		}
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+
+	sort.Strings(got)
+	return got
+}
+
 func (s *SuiteBase) partitionedLinkIterator(c *gc.C, partition, numPartitions int, accessedBefore int64) (graph.LinkIterator, error) {
 	from, to := s.partitionRange(c, partition, numPartitions)
 	return s.g.Links(from, to, accessedBefore)
@@ -525,3 +1249,17 @@ func (s *SuiteBase) partitionRange(c *gc.C, partition, numPartitions int) (from,
 
 	return from, to
 }
+
+// updatedSoFar returns a cutoff for the edge iterators that includes every
+// edge updated so far. Edge update times have a resolution of one second and
+// the cutoff is exclusive, so the current time would exclude the edges that
+// were updated during the current second.
+func updatedSoFar() int64 {
+	return time.Now().Unix() + 1
+}
+
+// waitForNextSecond blocks until the current second has elapsed so that
+// subsequent updates are timestamped later than the preceding ones.
+func waitForNextSecond() {
+	time.Sleep(time.Until(time.Unix(time.Now().Unix()+1, 0)))
+}
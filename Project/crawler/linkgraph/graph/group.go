@@ -0,0 +1,71 @@
+package graph
+
+import "github.com/google/uuid"
+
+// GroupEdgesByDst returns an EdgeGroupIterator that groups consecutive edges
+// of it that share the same destination link. The edges returned by it must
+// be ordered by destination ID. Closing the returned iterator also closes it.
+func GroupEdgesByDst(it EdgeIterator) EdgeGroupIterator {
+	return &edgeGroupIterator{it: it}
+}
+
+// edgeGroupIterator groups the edges of an EdgeIterator by destination.
+type edgeGroupIterator struct {
+	it EdgeIterator
+
+	// The first edge of the next group, if it has already been fetched.
+	pending   *Edge
+	exhausted bool
+
+	dst   uuid.UUID
+	edges []*Edge
+}
+
+// Next implements EdgeGroupIterator.
+func (i *edgeGroupIterator) Next() bool {
+	if i.pending == nil && !i.exhausted {
+		if i.it.Next() {
+			i.pending = i.it.Edge()
+		} else {
+			i.exhausted = true
+		}
+	}
+	if i.pending == nil {
+		return false
+	}
+
+	i.dst, i.edges = i.pending.Dst, []*Edge{i.pending}
+	i.pending = nil
+	for i.it.Next() {
+		edge := i.it.Edge()
+		if edge.Dst != i.dst {
+			i.pending = edge
+			return true
+		}
+		i.edges = append(i.edges, edge)
+	}
+
+	// Avoid returning a partial group if the underlying iterator failed.
+	i.exhausted = true
+	return i.it.Error() == nil
+}
+
+// Error implements EdgeGroupIterator.
+func (i *edgeGroupIterator) Error() error {
+	return i.it.Error()
+}
+
+// Close implements EdgeGroupIterator.
+func (i *edgeGroupIterator) Close() error {
+	return i.it.Close()
+}
+
+// Dst implements EdgeGroupIterator.
+func (i *edgeGroupIterator) Dst() uuid.UUID {
+	return i.dst
+}
+
+// Edges implements EdgeGroupIterator.
+func (i *edgeGroupIterator) Edges() []*Edge {
+	return i.edges
+}
@@ -0,0 +1,293 @@
+package graphapi
+
+import (
+	"context"
+	"fmt"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graphapi/proto"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Compile-time checks for ensuring GraphClient implements the required
+// interfaces.
+var (
+	_ graph.Graph           = (*GraphClient)(nil)
+	_ graph.CheckpointStore = (*GraphClient)(nil)
+	_ graph.RemovalPruner   = (*GraphClient)(nil)
+)
+
+// GraphClient provides an API compatible with the graph.Graph interface for
+// accessing a link graph instance exposed by a remote gRPC server.
+type GraphClient struct {
+	ctx context.Context
+	cli proto.LinkGraphClient
+}
+
+// NewGraphClient returns a new client instance that implements the
+// graph.Graph interface by delegating methods to a graph instance exposed by
+// a remote gRPC server. All requests are bound to ctx;
+// cancelling it aborts any in-flight requests and open iterators.
+func NewGraphClient(ctx context.Context, rpcClient proto.LinkGraphClient) *GraphClient {
+	return &GraphClient{ctx: ctx, cli: rpcClient}
+}
+
+// WithContext returns a copy of the client whose requests are bound to ctx.
+// It allows callers to propagate the trace that is associated with ctx to the
+// remote server.
+func (c *GraphClient) WithContext(ctx context.Context) *GraphClient {
+	return &GraphClient{ctx: ctx, cli: c.cli}
+}
+
+// UpsertLink creates a new link or updates an existing link. The fields
+// populated by the remote graph (e.g. the link ID) are copied back to link.
+func (c *GraphClient) UpsertLink(link *graph.Link) error {
+	res, err := c.cli.UpsertLink(c.ctx, encodeLink(link))
+	if err != nil {
+		return fmt.Errorf("upsert link: %w", decodeError(err))
+	}
+
+	upserted, err := decodeLink(res)
+	if err != nil {
+		return fmt.Errorf("upsert link: %w", err)
+	}
+	*link = *upserted
+	return nil
+}
+
+// FindLink looks up a link by its ID.
+func (c *GraphClient) FindLink(id uuid.UUID) (*graph.Link, error) {
+	res, err := c.cli.FindLink(c.ctx, &proto.FindLinkRequest{Uuid: id[:]})
+	if err != nil {
+		return nil, fmt.Errorf("find link: %w", decodeError(err))
+	}
+
+	link, err := decodeLink(res)
+	if err != nil {
+		return nil, fmt.Errorf("find link: %w", err)
+	}
+	return link, nil
+}
+
+// FindLinks looks up the links with the specified IDs using a single RPC. The
+// returned slice is aligned with ids; missing links are reported via a
+// *graph.MissingLinksError.
+func (c *GraphClient) FindLinks(ids []uuid.UUID) ([]*graph.Link, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	req := &proto.FindLinksRequest{Uuids: make([][]byte, len(ids))}
+	for i, id := range ids {
+		req.Uuids[i] = id[:]
+	}
+	res, err := c.cli.FindLinks(c.ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("find links: %w", decodeError(err))
+	}
+
+	found := make(map[uuid.UUID]*graph.Link, len(res.Links))
+	for _, l := range res.Links {
+		link, err := decodeLink(l)
+		if err != nil {
+			return nil, fmt.Errorf("find links: %w", err)
+		}
+		found[link.ID] = link
+	}
+
+	links, err := graph.ArrangeLinks(ids, found)
+	if err != nil {
+		return links, fmt.Errorf("find links: %w", err)
+	}
+	return links, nil
+}
+
+// UpsertEdge creates a new edge or updates an existing edge. The fields
+// populated by the remote graph (e.g. the edge ID) are copied back to edge.
+func (c *GraphClient) UpsertEdge(edge *graph.Edge) error {
+	res, err := c.cli.UpsertEdge(c.ctx, encodeEdge(edge))
+	if err != nil {
+		return fmt.Errorf("upsert edge: %w", decodeError(err))
+	}
+
+	upserted, err := decodeEdge(res)
+	if err != nil {
+		return fmt.Errorf("upsert edge: %w", err)
+	}
+	*edge = *upserted
+	return nil
+}
+
+// RemoveStaleEdges removes any edge that originates from the specified link
+// ID and was updated before the specified timestamp.
+func (c *GraphClient) RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error {
+	_, err := c.cli.RemoveStaleEdges(c.ctx, &proto.RemoveStaleEdgesQuery{
+		FromUuid:      fromID[:],
+		UpdatedBefore: updatedBefore,
+	})
+	if err != nil {
+		return fmt.Errorf("remove stale edges: %w", decodeError(err))
+	}
+	return nil
+}
+
+// Links returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and are due to be fetched before the provided
+// timestamp.
+func (c *GraphClient) Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error) {
+	ctx, cancelFn := context.WithCancel(c.ctx)
+	stream, err := c.cli.Links(ctx, &proto.Range{FromUuid: fromID[:], ToUuid: toID[:], Filter: dueBefore})
+	if err != nil {
+		cancelFn()
+		return nil, fmt.Errorf("links: %w", decodeError(err))
+	}
+	return &linkIterator{stream: stream, cancelFn: cancelFn}, nil
+}
+
+// LinksAsOf returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and had been added to the graph by the end of the
+// specified crawl pass.
+func (c *GraphClient) LinksAsOf(fromID, toID uuid.UUID, passID uint64) (graph.LinkIterator, error) {
+	ctx, cancelFn := context.WithCancel(c.ctx)
+	stream, err := c.cli.LinksAsOf(ctx, &proto.AsOfRange{FromUuid: fromID[:], ToUuid: toID[:], PassId: passID})
+	if err != nil {
+		cancelFn()
+		return nil, fmt.Errorf("links as of: %w", decodeError(err))
+	}
+	return &linkIterator{stream: stream, cancelFn: cancelFn}, nil
+}
+
+// Edges returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were updated before the provided
+// timestamp.
+func (c *GraphClient) Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error) {
+	ctx, cancelFn := context.WithCancel(c.ctx)
+	stream, err := c.cli.Edges(ctx, &proto.Range{FromUuid: fromID[:], ToUuid: toID[:], Filter: updatedBefore})
+	if err != nil {
+		cancelFn()
+		return nil, fmt.Errorf("edges: %w", decodeError(err))
+	}
+	return &edgeIterator{stream: stream, cancelFn: cancelFn}, nil
+}
+
+// EdgesAsOf returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were present in the graph at the
+// end of the specified crawl pass.
+func (c *GraphClient) EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (graph.EdgeIterator, error) {
+	ctx, cancelFn := context.WithCancel(c.ctx)
+	stream, err := c.cli.EdgesAsOf(ctx, &proto.AsOfRange{FromUuid: fromID[:], ToUuid: toID[:], PassId: passID})
+	if err != nil {
+		cancelFn()
+		return nil, fmt.Errorf("edges as of: %w", decodeError(err))
+	}
+	return &edgeIterator{stream: stream, cancelFn: cancelFn}, nil
+}
+
+// Diff returns an iterator for the set of changes that were applied to the
+// graph by the crawl passes in the (passA, passB] range.
+func (c *GraphClient) Diff(passA, passB uint64) (graph.ChangeIterator, error) {
+	ctx, cancelFn := context.WithCancel(c.ctx)
+	stream, err := c.cli.Diff(ctx, &proto.DiffRequest{PassA: passA, PassB: passB})
+	if err != nil {
+		cancelFn()
+		return nil, fmt.Errorf("diff: %w", decodeError(err))
+	}
+	return &changeIterator{stream: stream, cancelFn: cancelFn}, nil
+}
+
+// RecordLinkFailure creates or replaces the failure record of a link and
+// reschedules the link accordingly. The URL populated by the remote graph is
+// copied back to f.
+func (c *GraphClient) RecordLinkFailure(f *graph.LinkFailure) error {
+	res, err := c.cli.RecordLinkFailure(c.ctx, encodeLinkFailure(f))
+	if err != nil {
+		return fmt.Errorf("record link failure: %w", decodeError(err))
+	}
+	f.URL = res.Url
+	return nil
+}
+
+// LinkFailure returns the failure record of the link with the specified ID.
+func (c *GraphClient) LinkFailure(id uuid.UUID) (*graph.LinkFailure, error) {
+	res, err := c.cli.FindLinkFailure(c.ctx, &proto.FindLinkFailureRequest{LinkUuid: id[:]})
+	if err != nil {
+		return nil, fmt.Errorf("find link failure: %w", decodeError(err))
+	}
+
+	f, err := decodeLinkFailure(res)
+	if err != nil {
+		return nil, fmt.Errorf("find link failure: %w", err)
+	}
+	return f, nil
+}
+
+// FailedLinks returns an iterator for the failure records of the links whose
+// IDs belong to the [fromID, toID) range.
+func (c *GraphClient) FailedLinks(fromID, toID uuid.UUID) (graph.LinkFailureIterator, error) {
+	ctx, cancelFn := context.WithCancel(c.ctx)
+	stream, err := c.cli.FailedLinks(ctx, &proto.FailedLinksRequest{FromUuid: fromID[:], ToUuid: toID[:]})
+	if err != nil {
+		cancelFn()
+		return nil, fmt.Errorf("failed links: %w", decodeError(err))
+	}
+	return &failureIterator{stream: stream, cancelFn: cancelFn}, nil
+}
+
+// SaveCheckpoint creates or replaces the checkpoint for the partition
+// specified by cp. The update time populated by the remote graph is copied
+// back to cp.
+func (c *GraphClient) SaveCheckpoint(cp *graph.Checkpoint) error {
+	res, err := c.cli.SaveCheckpoint(c.ctx, encodeCheckpoint(cp))
+	if err != nil {
+		return fmt.Errorf("save checkpoint: %w", decodeError(err))
+	}
+	cp.UpdatedAt = decodeTime(res.UpdatedAt)
+	return nil
+}
+
+// Checkpoint returns the checkpoint for the specified partition.
+func (c *GraphClient) Checkpoint(partition int) (*graph.Checkpoint, error) {
+	res, err := c.cli.FindCheckpoint(c.ctx, &proto.FindCheckpointRequest{Partition: int64(partition)})
+	if err != nil {
+		return nil, fmt.Errorf("find checkpoint: %w", decodeError(err))
+	}
+	return decodeCheckpoint(res), nil
+}
+
+// Checkpoints returns the checkpoints of all partitions ordered by partition.
+func (c *GraphClient) Checkpoints() ([]*graph.Checkpoint, error) {
+	res, err := c.cli.Checkpoints(c.ctx, new(emptypb.Empty))
+	if err != nil {
+		return nil, fmt.Errorf("checkpoints: %w", decodeError(err))
+	}
+	cps := make([]*graph.Checkpoint, len(res.Checkpoints))
+	for i, cp := range res.Checkpoints {
+		cps[i] = decodeCheckpoint(cp)
+	}
+	return cps, nil
+}
+
+// PruneRemovals discards the records of the edges that were removed by the
+// specified or an earlier crawl pass.
+func (c *GraphClient) PruneRemovals(passID uint64) error {
+	if _, err := c.cli.PruneRemovals(c.ctx, &proto.PruneRemovalsRequest{PassId: passID}); err != nil {
+		return fmt.Errorf("prune removals: %w", decodeError(err))
+	}
+	return nil
+}
+
+// decodeError maps the gRPC status errors produced by encodeError back to
+// the graph package errors.
+func decodeError(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return graph.ErrNotFound
+	case codes.FailedPrecondition:
+		return graph.ErrUnknownEdgeLinks
+	default:
+		return err
+	}
+}
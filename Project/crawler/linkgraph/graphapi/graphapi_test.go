@@ -0,0 +1,227 @@
+package graphapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graphapi/proto"
+	"webcrawler/crawler/linkgraph/store/memory"
+	"webcrawler/namespace"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(GraphAPITestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+var maxUUID = uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+
+type GraphAPITestSuite struct {
+	g    *memory.InMemoryGraph
+	srv  *grpc.Server
+	conn *grpc.ClientConn
+	cli  *GraphClient
+}
+
+func (s *GraphAPITestSuite) SetUpTest(c *gc.C) {
+	s.g = memory.NewInMemoryGraph()
+
+	lis := bufconn.Listen(1024 * 1024)
+	s.srv = grpc.NewServer()
+	proto.RegisterLinkGraphServer(s.srv, NewGraphServer(s.g))
+	go func() { _ = s.srv.Serve(lis) }()
+
+	var err error
+	s.conn, err = grpc.DialContext(context.TODO(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	c.Assert(err, gc.IsNil)
+	s.cli = NewGraphClient(context.TODO(), proto.NewLinkGraphClient(s.conn))
+}
+
+func (s *GraphAPITestSuite) TearDownTest(c *gc.C) {
+	_ = s.conn.Close()
+	s.srv.Stop()
+}
+
+func (s *GraphAPITestSuite) TestUpsertAndFindLink(c *gc.C) {
+	link := &graph.Link{URL: "http://example.com", RetrievedAt: time.Now().Unix(), ContentHash: 1<<63 | 42, PassID: 3}
+	c.Assert(s.cli.UpsertLink(link), gc.IsNil)
+	c.Assert(link.ID, gc.Not(gc.Equals), uuid.Nil, gc.Commentf("expected the assigned link ID to be copied back"))
+	c.Assert(link.FirstPassID, gc.Equals, uint64(3))
+	c.Assert(link.Namespace, gc.Equals, namespace.Default)
+
+	got, err := s.cli.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, link)
+
+	stored, err := s.g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(stored, gc.DeepEquals, link)
+
+	_, err = s.cli.FindLink(uuid.New())
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+}
+
+func (s *GraphAPITestSuite) TestFindLinks(c *gc.C) {
+	a := &graph.Link{URL: "http://a.com"}
+	b := &graph.Link{URL: "http://b.com"}
+	c.Assert(s.g.UpsertLink(a), gc.IsNil)
+	c.Assert(s.g.UpsertLink(b), gc.IsNil)
+
+	links, err := s.cli.FindLinks([]uuid.UUID{b.ID, a.ID})
+	c.Assert(err, gc.IsNil)
+	c.Assert(links, gc.DeepEquals, []*graph.Link{b, a})
+
+	unknown := uuid.New()
+	links, err = s.cli.FindLinks([]uuid.UUID{unknown, a.ID})
+	var missingErr *graph.MissingLinksError
+	c.Assert(errors.As(err, &missingErr), gc.Equals, true)
+	c.Assert(missingErr.IDs, gc.DeepEquals, []uuid.UUID{unknown})
+	c.Assert(links, gc.DeepEquals, []*graph.Link{nil, a})
+}
+
+func (s *GraphAPITestSuite) TestUpsertEdgeWithUnknownLinks(c *gc.C) {
+	err := s.cli.UpsertEdge(&graph.Edge{Src: uuid.New(), Dst: uuid.New()})
+	c.Assert(errors.Is(err, graph.ErrUnknownEdgeLinks), gc.Equals, true)
+}
+
+func (s *GraphAPITestSuite) TestLinkFailures(c *gc.C) {
+	link := &graph.Link{URL: "http://example.com"}
+	c.Assert(s.g.UpsertLink(link), gc.IsNil)
+
+	f := &graph.LinkFailure{LinkID: link.ID, Reason: "fetch: timeout", Attempts: 2, FailedAt: 10, NextRetryAt: 70}
+	c.Assert(s.cli.RecordLinkFailure(f), gc.IsNil)
+	c.Assert(f.URL, gc.Equals, link.URL)
+
+	got, err := s.cli.LinkFailure(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, f)
+
+	it, err := s.cli.FailedLinks(uuid.Nil, maxUUID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Failure(), gc.DeepEquals, f)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+
+	_, err = s.cli.LinkFailure(uuid.New())
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+	err = s.cli.RecordLinkFailure(&graph.LinkFailure{LinkID: uuid.New()})
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+}
+
+func (s *GraphAPITestSuite) TestCheckpoints(c *gc.C) {
+	_, err := s.cli.Checkpoint(1)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	startedAt := time.Now().Add(-time.Minute).Round(0)
+	cps := []*graph.Checkpoint{
+		{Partition: 1, PassID: 3, PassStartedAt: startedAt},
+		{Partition: 0, PassID: 2, PassStartedAt: startedAt, CompletedAt: startedAt.Add(time.Second)},
+	}
+	for _, cp := range cps {
+		c.Assert(s.cli.SaveCheckpoint(cp), gc.IsNil)
+		c.Assert(cp.UpdatedAt.IsZero(), gc.Equals, false, gc.Commentf("expected the update time to be copied back"))
+	}
+
+	got, err := s.cli.Checkpoint(1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.PassID, gc.Equals, uint64(3))
+	c.Assert(got.PassStartedAt.Equal(startedAt), gc.Equals, true)
+	c.Assert(got.Completed(), gc.Equals, false)
+
+	all, err := s.cli.Checkpoints()
+	c.Assert(err, gc.IsNil)
+	c.Assert(all, gc.HasLen, 2)
+	c.Assert(all[0].Partition, gc.Equals, 0)
+	c.Assert(all[0].Completed(), gc.Equals, true)
+	c.Assert(graph.CompletedPass(all), gc.Equals, uint64(2))
+
+	c.Assert(s.cli.PruneRemovals(2), gc.IsNil)
+}
+
+func (s *GraphAPITestSuite) TestCheckpointsNotSupported(c *gc.C) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	proto.RegisterLinkGraphServer(srv, NewGraphServer(struct{ graph.Graph }{s.g}))
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.DialContext(context.TODO(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = conn.Close() }()
+	cli := NewGraphClient(context.TODO(), proto.NewLinkGraphClient(conn))
+
+	_, err = cli.Checkpoints()
+	c.Assert(status.Code(errors.Unwrap(err)), gc.Equals, codes.Unimplemented)
+	err = cli.PruneRemovals(1)
+	c.Assert(status.Code(errors.Unwrap(err)), gc.Equals, codes.Unimplemented)
+}
+
+func (s *GraphAPITestSuite) TestStreamLinksAndEdges(c *gc.C) {
+	var linkIDs []uuid.UUID
+	for _, u := range []string{"http://a.com", "http://b.com", "http://c.com"} {
+		link := &graph.Link{URL: u, PassID: 1}
+		c.Assert(s.g.UpsertLink(link), gc.IsNil)
+		linkIDs = append(linkIDs, link.ID)
+	}
+	edge := &graph.Edge{Src: linkIDs[0], Dst: linkIDs[1], PassID: 1}
+	c.Assert(s.cli.UpsertEdge(edge), gc.IsNil)
+	c.Assert(edge.ID, gc.Not(gc.Equals), uuid.Nil)
+
+	linkIt, err := s.cli.Links(uuid.Nil, maxUUID, time.Now().Add(time.Hour).Unix())
+	c.Assert(err, gc.IsNil)
+	seen := make(map[uuid.UUID]bool)
+	for linkIt.Next() {
+		seen[linkIt.Link().ID] = true
+	}
+	c.Assert(linkIt.Error(), gc.IsNil)
+	c.Assert(linkIt.Close(), gc.IsNil)
+	c.Assert(seen, gc.HasLen, len(linkIDs))
+
+	edgeIt, err := s.cli.EdgesAsOf(uuid.Nil, maxUUID, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(edgeIt.Next(), gc.Equals, true)
+	c.Assert(edgeIt.Edge(), gc.DeepEquals, edge)
+	c.Assert(edgeIt.Next(), gc.Equals, false)
+	c.Assert(edgeIt.Error(), gc.IsNil)
+	c.Assert(edgeIt.Close(), gc.IsNil)
+
+	changeIt, err := s.cli.Diff(0, 1)
+	c.Assert(err, gc.IsNil)
+	var changes int
+	for changeIt.Next() {
+		changes++
+	}
+	c.Assert(changeIt.Error(), gc.IsNil)
+	c.Assert(changeIt.Close(), gc.IsNil)
+	c.Assert(changes, gc.Equals, len(linkIDs)+1)
+}
+
+func (s *GraphAPITestSuite) TestCloseIteratorEarly(c *gc.C) {
+	for i := 0; i < 10; i++ {
+		c.Assert(s.g.UpsertLink(&graph.Link{URL: "http://example.com/" + uuid.NewString()}), gc.IsNil)
+	}
+
+	it, err := s.cli.LinksAsOf(uuid.Nil, maxUUID, 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(it.Error(), gc.IsNil)
+}
@@ -0,0 +1,195 @@
+package graphapi
+
+import (
+	"context"
+	"io"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graphapi/proto"
+)
+
+// linkIterator implements graph.LinkIterator on top of a link stream.
+type linkIterator struct {
+	stream interface {
+		Recv() (*proto.Link, error)
+	}
+	cancelFn context.CancelFunc
+
+	latched *graph.Link
+	lastErr error
+}
+
+// Next advances the iterator.
+func (it *linkIterator) Next() bool {
+	if it.lastErr != nil {
+		return false
+	}
+	res, err := it.stream.Recv()
+	if err != nil {
+		if err != io.EOF {
+			it.lastErr = decodeError(err)
+		}
+		it.cancelFn()
+		return false
+	}
+
+	if it.latched, it.lastErr = decodeLink(res); it.lastErr != nil {
+		it.cancelFn()
+		return false
+	}
+	return true
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *linkIterator) Error() error { return it.lastErr }
+
+// Link returns the currently fetched link.
+func (it *linkIterator) Link() *graph.Link { return it.latched }
+
+// Close releases any resources associated with the iterator.
+func (it *linkIterator) Close() error {
+	it.cancelFn()
+	return nil
+}
+
+// edgeIterator implements graph.EdgeIterator on top of an edge stream.
+type edgeIterator struct {
+	stream interface {
+		Recv() (*proto.Edge, error)
+	}
+	cancelFn context.CancelFunc
+
+	latched *graph.Edge
+	lastErr error
+}
+
+// Next advances the iterator.
+func (it *edgeIterator) Next() bool {
+	if it.lastErr != nil {
+		return false
+	}
+	res, err := it.stream.Recv()
+	if err != nil {
+		if err != io.EOF {
+			it.lastErr = decodeError(err)
+		}
+		it.cancelFn()
+		return false
+	}
+
+	if it.latched, it.lastErr = decodeEdge(res); it.lastErr != nil {
+		it.cancelFn()
+		return false
+	}
+	return true
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *edgeIterator) Error() error { return it.lastErr }
+
+// Edge returns the currently fetched edge.
+func (it *edgeIterator) Edge() *graph.Edge { return it.latched }
+
+// Close releases any resources associated with the iterator.
+func (it *edgeIterator) Close() error {
+	it.cancelFn()
+	return nil
+}
+
+// changeIterator implements graph.ChangeIterator on top of a change stream.
+type changeIterator struct {
+	stream interface {
+		Recv() (*proto.Change, error)
+	}
+	cancelFn context.CancelFunc
+
+	latched *graph.Change
+	lastErr error
+}
+
+// Next advances the iterator.
+func (it *changeIterator) Next() bool {
+	if it.lastErr != nil {
+		return false
+	}
+	res, err := it.stream.Recv()
+	if err != nil {
+		if err != io.EOF {
+			it.lastErr = decodeError(err)
+		}
+		it.cancelFn()
+		return false
+	}
+
+	change := &graph.Change{Type: graph.ChangeType(res.Type)}
+	if res.Link != nil {
+		if change.Link, it.lastErr = decodeLink(res.Link); it.lastErr != nil {
+			it.cancelFn()
+			return false
+		}
+	}
+	if res.Edge != nil {
+		if change.Edge, it.lastErr = decodeEdge(res.Edge); it.lastErr != nil {
+			it.cancelFn()
+			return false
+		}
+	}
+	it.latched = change
+	return true
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *changeIterator) Error() error { return it.lastErr }
+
+// Change returns the currently fetched change.
+func (it *changeIterator) Change() *graph.Change { return it.latched }
+
+// Close releases any resources associated with the iterator.
+func (it *changeIterator) Close() error {
+	it.cancelFn()
+	return nil
+}
+
+// failureIterator implements graph.LinkFailureIterator on top of a link
+// failure stream.
+type failureIterator struct {
+	stream interface {
+		Recv() (*proto.LinkFailure, error)
+	}
+	cancelFn context.CancelFunc
+
+	latched *graph.LinkFailure
+	lastErr error
+}
+
+// Next advances the iterator.
+func (it *failureIterator) Next() bool {
+	if it.lastErr != nil {
+		return false
+	}
+	res, err := it.stream.Recv()
+	if err != nil {
+		if err != io.EOF {
+			it.lastErr = decodeError(err)
+		}
+		it.cancelFn()
+		return false
+	}
+
+	if it.latched, it.lastErr = decodeLinkFailure(res); it.lastErr != nil {
+		it.cancelFn()
+		return false
+	}
+	return true
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *failureIterator) Error() error { return it.lastErr }
+
+// Failure returns the currently fetched failure record.
+func (it *failureIterator) Failure() *graph.LinkFailure { return it.latched }
+
+// Close releases any resources associated with the iterator.
+func (it *failureIterator) Close() error {
+	it.cancelFn()
+	return nil
+}
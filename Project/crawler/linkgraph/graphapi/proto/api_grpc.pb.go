@@ -0,0 +1,886 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: api.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	LinkGraph_UpsertLink_FullMethodName        = "/proto.LinkGraph/UpsertLink"
+	LinkGraph_FindLink_FullMethodName          = "/proto.LinkGraph/FindLink"
+	LinkGraph_FindLinks_FullMethodName         = "/proto.LinkGraph/FindLinks"
+	LinkGraph_UpsertEdge_FullMethodName        = "/proto.LinkGraph/UpsertEdge"
+	LinkGraph_RemoveStaleEdges_FullMethodName  = "/proto.LinkGraph/RemoveStaleEdges"
+	LinkGraph_Links_FullMethodName             = "/proto.LinkGraph/Links"
+	LinkGraph_Edges_FullMethodName             = "/proto.LinkGraph/Edges"
+	LinkGraph_LinksAsOf_FullMethodName         = "/proto.LinkGraph/LinksAsOf"
+	LinkGraph_EdgesAsOf_FullMethodName         = "/proto.LinkGraph/EdgesAsOf"
+	LinkGraph_Diff_FullMethodName              = "/proto.LinkGraph/Diff"
+	LinkGraph_RecordLinkFailure_FullMethodName = "/proto.LinkGraph/RecordLinkFailure"
+	LinkGraph_FindLinkFailure_FullMethodName   = "/proto.LinkGraph/FindLinkFailure"
+	LinkGraph_FailedLinks_FullMethodName       = "/proto.LinkGraph/FailedLinks"
+	LinkGraph_SaveCheckpoint_FullMethodName    = "/proto.LinkGraph/SaveCheckpoint"
+	LinkGraph_FindCheckpoint_FullMethodName    = "/proto.LinkGraph/FindCheckpoint"
+	LinkGraph_Checkpoints_FullMethodName       = "/proto.LinkGraph/Checkpoints"
+	LinkGraph_PruneRemovals_FullMethodName     = "/proto.LinkGraph/PruneRemovals"
+)
+
+// LinkGraphClient is the client API for LinkGraph service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// LinkGraph provides remote access to a link graph instance.
+type LinkGraphClient interface {
+	UpsertLink(ctx context.Context, in *Link, opts ...grpc.CallOption) (*Link, error)
+	FindLink(ctx context.Context, in *FindLinkRequest, opts ...grpc.CallOption) (*Link, error)
+	FindLinks(ctx context.Context, in *FindLinksRequest, opts ...grpc.CallOption) (*FindLinksResponse, error)
+	UpsertEdge(ctx context.Context, in *Edge, opts ...grpc.CallOption) (*Edge, error)
+	RemoveStaleEdges(ctx context.Context, in *RemoveStaleEdgesQuery, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Links(ctx context.Context, in *Range, opts ...grpc.CallOption) (LinkGraph_LinksClient, error)
+	Edges(ctx context.Context, in *Range, opts ...grpc.CallOption) (LinkGraph_EdgesClient, error)
+	LinksAsOf(ctx context.Context, in *AsOfRange, opts ...grpc.CallOption) (LinkGraph_LinksAsOfClient, error)
+	EdgesAsOf(ctx context.Context, in *AsOfRange, opts ...grpc.CallOption) (LinkGraph_EdgesAsOfClient, error)
+	Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (LinkGraph_DiffClient, error)
+	RecordLinkFailure(ctx context.Context, in *LinkFailure, opts ...grpc.CallOption) (*LinkFailure, error)
+	FindLinkFailure(ctx context.Context, in *FindLinkFailureRequest, opts ...grpc.CallOption) (*LinkFailure, error)
+	FailedLinks(ctx context.Context, in *FailedLinksRequest, opts ...grpc.CallOption) (LinkGraph_FailedLinksClient, error)
+	SaveCheckpoint(ctx context.Context, in *Checkpoint, opts ...grpc.CallOption) (*Checkpoint, error)
+	FindCheckpoint(ctx context.Context, in *FindCheckpointRequest, opts ...grpc.CallOption) (*Checkpoint, error)
+	Checkpoints(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*CheckpointList, error)
+	PruneRemovals(ctx context.Context, in *PruneRemovalsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type linkGraphClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLinkGraphClient(cc grpc.ClientConnInterface) LinkGraphClient {
+	return &linkGraphClient{cc}
+}
+
+func (c *linkGraphClient) UpsertLink(ctx context.Context, in *Link, opts ...grpc.CallOption) (*Link, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Link)
+	err := c.cc.Invoke(ctx, LinkGraph_UpsertLink_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) FindLink(ctx context.Context, in *FindLinkRequest, opts ...grpc.CallOption) (*Link, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Link)
+	err := c.cc.Invoke(ctx, LinkGraph_FindLink_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) FindLinks(ctx context.Context, in *FindLinksRequest, opts ...grpc.CallOption) (*FindLinksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FindLinksResponse)
+	err := c.cc.Invoke(ctx, LinkGraph_FindLinks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) UpsertEdge(ctx context.Context, in *Edge, opts ...grpc.CallOption) (*Edge, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Edge)
+	err := c.cc.Invoke(ctx, LinkGraph_UpsertEdge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) RemoveStaleEdges(ctx context.Context, in *RemoveStaleEdgesQuery, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, LinkGraph_RemoveStaleEdges_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) Links(ctx context.Context, in *Range, opts ...grpc.CallOption) (LinkGraph_LinksClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LinkGraph_ServiceDesc.Streams[0], LinkGraph_Links_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &linkGraphLinksClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LinkGraph_LinksClient interface {
+	Recv() (*Link, error)
+	grpc.ClientStream
+}
+
+type linkGraphLinksClient struct {
+	grpc.ClientStream
+}
+
+func (x *linkGraphLinksClient) Recv() (*Link, error) {
+	m := new(Link)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *linkGraphClient) Edges(ctx context.Context, in *Range, opts ...grpc.CallOption) (LinkGraph_EdgesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LinkGraph_ServiceDesc.Streams[1], LinkGraph_Edges_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &linkGraphEdgesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LinkGraph_EdgesClient interface {
+	Recv() (*Edge, error)
+	grpc.ClientStream
+}
+
+type linkGraphEdgesClient struct {
+	grpc.ClientStream
+}
+
+func (x *linkGraphEdgesClient) Recv() (*Edge, error) {
+	m := new(Edge)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *linkGraphClient) LinksAsOf(ctx context.Context, in *AsOfRange, opts ...grpc.CallOption) (LinkGraph_LinksAsOfClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LinkGraph_ServiceDesc.Streams[2], LinkGraph_LinksAsOf_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &linkGraphLinksAsOfClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LinkGraph_LinksAsOfClient interface {
+	Recv() (*Link, error)
+	grpc.ClientStream
+}
+
+type linkGraphLinksAsOfClient struct {
+	grpc.ClientStream
+}
+
+func (x *linkGraphLinksAsOfClient) Recv() (*Link, error) {
+	m := new(Link)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *linkGraphClient) EdgesAsOf(ctx context.Context, in *AsOfRange, opts ...grpc.CallOption) (LinkGraph_EdgesAsOfClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LinkGraph_ServiceDesc.Streams[3], LinkGraph_EdgesAsOf_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &linkGraphEdgesAsOfClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LinkGraph_EdgesAsOfClient interface {
+	Recv() (*Edge, error)
+	grpc.ClientStream
+}
+
+type linkGraphEdgesAsOfClient struct {
+	grpc.ClientStream
+}
+
+func (x *linkGraphEdgesAsOfClient) Recv() (*Edge, error) {
+	m := new(Edge)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *linkGraphClient) Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (LinkGraph_DiffClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LinkGraph_ServiceDesc.Streams[4], LinkGraph_Diff_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &linkGraphDiffClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LinkGraph_DiffClient interface {
+	Recv() (*Change, error)
+	grpc.ClientStream
+}
+
+type linkGraphDiffClient struct {
+	grpc.ClientStream
+}
+
+func (x *linkGraphDiffClient) Recv() (*Change, error) {
+	m := new(Change)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *linkGraphClient) RecordLinkFailure(ctx context.Context, in *LinkFailure, opts ...grpc.CallOption) (*LinkFailure, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LinkFailure)
+	err := c.cc.Invoke(ctx, LinkGraph_RecordLinkFailure_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) FindLinkFailure(ctx context.Context, in *FindLinkFailureRequest, opts ...grpc.CallOption) (*LinkFailure, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LinkFailure)
+	err := c.cc.Invoke(ctx, LinkGraph_FindLinkFailure_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) FailedLinks(ctx context.Context, in *FailedLinksRequest, opts ...grpc.CallOption) (LinkGraph_FailedLinksClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LinkGraph_ServiceDesc.Streams[5], LinkGraph_FailedLinks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &linkGraphFailedLinksClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LinkGraph_FailedLinksClient interface {
+	Recv() (*LinkFailure, error)
+	grpc.ClientStream
+}
+
+type linkGraphFailedLinksClient struct {
+	grpc.ClientStream
+}
+
+func (x *linkGraphFailedLinksClient) Recv() (*LinkFailure, error) {
+	m := new(LinkFailure)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *linkGraphClient) SaveCheckpoint(ctx context.Context, in *Checkpoint, opts ...grpc.CallOption) (*Checkpoint, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Checkpoint)
+	err := c.cc.Invoke(ctx, LinkGraph_SaveCheckpoint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) FindCheckpoint(ctx context.Context, in *FindCheckpointRequest, opts ...grpc.CallOption) (*Checkpoint, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Checkpoint)
+	err := c.cc.Invoke(ctx, LinkGraph_FindCheckpoint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) Checkpoints(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*CheckpointList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckpointList)
+	err := c.cc.Invoke(ctx, LinkGraph_Checkpoints_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkGraphClient) PruneRemovals(ctx context.Context, in *PruneRemovalsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, LinkGraph_PruneRemovals_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LinkGraphServer is the server API for LinkGraph service.
+// All implementations must embed UnimplementedLinkGraphServer
+// for forward compatibility
+//
+// LinkGraph provides remote access to a link graph instance.
+type LinkGraphServer interface {
+	UpsertLink(context.Context, *Link) (*Link, error)
+	FindLink(context.Context, *FindLinkRequest) (*Link, error)
+	FindLinks(context.Context, *FindLinksRequest) (*FindLinksResponse, error)
+	UpsertEdge(context.Context, *Edge) (*Edge, error)
+	RemoveStaleEdges(context.Context, *RemoveStaleEdgesQuery) (*emptypb.Empty, error)
+	Links(*Range, LinkGraph_LinksServer) error
+	Edges(*Range, LinkGraph_EdgesServer) error
+	LinksAsOf(*AsOfRange, LinkGraph_LinksAsOfServer) error
+	EdgesAsOf(*AsOfRange, LinkGraph_EdgesAsOfServer) error
+	Diff(*DiffRequest, LinkGraph_DiffServer) error
+	RecordLinkFailure(context.Context, *LinkFailure) (*LinkFailure, error)
+	FindLinkFailure(context.Context, *FindLinkFailureRequest) (*LinkFailure, error)
+	FailedLinks(*FailedLinksRequest, LinkGraph_FailedLinksServer) error
+	SaveCheckpoint(context.Context, *Checkpoint) (*Checkpoint, error)
+	FindCheckpoint(context.Context, *FindCheckpointRequest) (*Checkpoint, error)
+	Checkpoints(context.Context, *emptypb.Empty) (*CheckpointList, error)
+	PruneRemovals(context.Context, *PruneRemovalsRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedLinkGraphServer()
+}
+
+// UnimplementedLinkGraphServer must be embedded to have forward compatible implementations.
+type UnimplementedLinkGraphServer struct {
+}
+
+func (UnimplementedLinkGraphServer) UpsertLink(context.Context, *Link) (*Link, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertLink not implemented")
+}
+func (UnimplementedLinkGraphServer) FindLink(context.Context, *FindLinkRequest) (*Link, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindLink not implemented")
+}
+func (UnimplementedLinkGraphServer) FindLinks(context.Context, *FindLinksRequest) (*FindLinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindLinks not implemented")
+}
+func (UnimplementedLinkGraphServer) UpsertEdge(context.Context, *Edge) (*Edge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertEdge not implemented")
+}
+func (UnimplementedLinkGraphServer) RemoveStaleEdges(context.Context, *RemoveStaleEdgesQuery) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveStaleEdges not implemented")
+}
+func (UnimplementedLinkGraphServer) Links(*Range, LinkGraph_LinksServer) error {
+	return status.Errorf(codes.Unimplemented, "method Links not implemented")
+}
+func (UnimplementedLinkGraphServer) Edges(*Range, LinkGraph_EdgesServer) error {
+	return status.Errorf(codes.Unimplemented, "method Edges not implemented")
+}
+func (UnimplementedLinkGraphServer) LinksAsOf(*AsOfRange, LinkGraph_LinksAsOfServer) error {
+	return status.Errorf(codes.Unimplemented, "method LinksAsOf not implemented")
+}
+func (UnimplementedLinkGraphServer) EdgesAsOf(*AsOfRange, LinkGraph_EdgesAsOfServer) error {
+	return status.Errorf(codes.Unimplemented, "method EdgesAsOf not implemented")
+}
+func (UnimplementedLinkGraphServer) Diff(*DiffRequest, LinkGraph_DiffServer) error {
+	return status.Errorf(codes.Unimplemented, "method Diff not implemented")
+}
+func (UnimplementedLinkGraphServer) RecordLinkFailure(context.Context, *LinkFailure) (*LinkFailure, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordLinkFailure not implemented")
+}
+func (UnimplementedLinkGraphServer) FindLinkFailure(context.Context, *FindLinkFailureRequest) (*LinkFailure, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindLinkFailure not implemented")
+}
+func (UnimplementedLinkGraphServer) FailedLinks(*FailedLinksRequest, LinkGraph_FailedLinksServer) error {
+	return status.Errorf(codes.Unimplemented, "method FailedLinks not implemented")
+}
+func (UnimplementedLinkGraphServer) SaveCheckpoint(context.Context, *Checkpoint) (*Checkpoint, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveCheckpoint not implemented")
+}
+func (UnimplementedLinkGraphServer) FindCheckpoint(context.Context, *FindCheckpointRequest) (*Checkpoint, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindCheckpoint not implemented")
+}
+func (UnimplementedLinkGraphServer) Checkpoints(context.Context, *emptypb.Empty) (*CheckpointList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Checkpoints not implemented")
+}
+func (UnimplementedLinkGraphServer) PruneRemovals(context.Context, *PruneRemovalsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PruneRemovals not implemented")
+}
+func (UnimplementedLinkGraphServer) mustEmbedUnimplementedLinkGraphServer() {}
+
+// UnsafeLinkGraphServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LinkGraphServer will
+// result in compilation errors.
+type UnsafeLinkGraphServer interface {
+	mustEmbedUnimplementedLinkGraphServer()
+}
+
+func RegisterLinkGraphServer(s grpc.ServiceRegistrar, srv LinkGraphServer) {
+	s.RegisterService(&LinkGraph_ServiceDesc, srv)
+}
+
+func _LinkGraph_UpsertLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Link)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).UpsertLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_UpsertLink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).UpsertLink(ctx, req.(*Link))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_FindLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).FindLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_FindLink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).FindLink(ctx, req.(*FindLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_FindLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindLinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).FindLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_FindLinks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).FindLinks(ctx, req.(*FindLinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_UpsertEdge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Edge)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).UpsertEdge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_UpsertEdge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).UpsertEdge(ctx, req.(*Edge))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_RemoveStaleEdges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveStaleEdgesQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).RemoveStaleEdges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_RemoveStaleEdges_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).RemoveStaleEdges(ctx, req.(*RemoveStaleEdgesQuery))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_Links_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Range)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LinkGraphServer).Links(m, &linkGraphLinksServer{ServerStream: stream})
+}
+
+type LinkGraph_LinksServer interface {
+	Send(*Link) error
+	grpc.ServerStream
+}
+
+type linkGraphLinksServer struct {
+	grpc.ServerStream
+}
+
+func (x *linkGraphLinksServer) Send(m *Link) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LinkGraph_Edges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Range)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LinkGraphServer).Edges(m, &linkGraphEdgesServer{ServerStream: stream})
+}
+
+type LinkGraph_EdgesServer interface {
+	Send(*Edge) error
+	grpc.ServerStream
+}
+
+type linkGraphEdgesServer struct {
+	grpc.ServerStream
+}
+
+func (x *linkGraphEdgesServer) Send(m *Edge) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LinkGraph_LinksAsOf_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AsOfRange)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LinkGraphServer).LinksAsOf(m, &linkGraphLinksAsOfServer{ServerStream: stream})
+}
+
+type LinkGraph_LinksAsOfServer interface {
+	Send(*Link) error
+	grpc.ServerStream
+}
+
+type linkGraphLinksAsOfServer struct {
+	grpc.ServerStream
+}
+
+func (x *linkGraphLinksAsOfServer) Send(m *Link) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LinkGraph_EdgesAsOf_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AsOfRange)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LinkGraphServer).EdgesAsOf(m, &linkGraphEdgesAsOfServer{ServerStream: stream})
+}
+
+type LinkGraph_EdgesAsOfServer interface {
+	Send(*Edge) error
+	grpc.ServerStream
+}
+
+type linkGraphEdgesAsOfServer struct {
+	grpc.ServerStream
+}
+
+func (x *linkGraphEdgesAsOfServer) Send(m *Edge) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LinkGraph_Diff_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DiffRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LinkGraphServer).Diff(m, &linkGraphDiffServer{ServerStream: stream})
+}
+
+type LinkGraph_DiffServer interface {
+	Send(*Change) error
+	grpc.ServerStream
+}
+
+type linkGraphDiffServer struct {
+	grpc.ServerStream
+}
+
+func (x *linkGraphDiffServer) Send(m *Change) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LinkGraph_RecordLinkFailure_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LinkFailure)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).RecordLinkFailure(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_RecordLinkFailure_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).RecordLinkFailure(ctx, req.(*LinkFailure))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_FindLinkFailure_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindLinkFailureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).FindLinkFailure(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_FindLinkFailure_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).FindLinkFailure(ctx, req.(*FindLinkFailureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_FailedLinks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FailedLinksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LinkGraphServer).FailedLinks(m, &linkGraphFailedLinksServer{ServerStream: stream})
+}
+
+type LinkGraph_FailedLinksServer interface {
+	Send(*LinkFailure) error
+	grpc.ServerStream
+}
+
+type linkGraphFailedLinksServer struct {
+	grpc.ServerStream
+}
+
+func (x *linkGraphFailedLinksServer) Send(m *LinkFailure) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LinkGraph_SaveCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Checkpoint)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).SaveCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_SaveCheckpoint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).SaveCheckpoint(ctx, req.(*Checkpoint))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_FindCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).FindCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_FindCheckpoint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).FindCheckpoint(ctx, req.(*FindCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_Checkpoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).Checkpoints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_Checkpoints_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).Checkpoints(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkGraph_PruneRemovals_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PruneRemovalsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkGraphServer).PruneRemovals(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkGraph_PruneRemovals_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkGraphServer).PruneRemovals(ctx, req.(*PruneRemovalsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// LinkGraph_ServiceDesc is the grpc.ServiceDesc for LinkGraph service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LinkGraph_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.LinkGraph",
+	HandlerType: (*LinkGraphServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "UpsertLink",
+			Handler:    _LinkGraph_UpsertLink_Handler,
+		},
+		{
+			MethodName: "FindLink",
+			Handler:    _LinkGraph_FindLink_Handler,
+		},
+		{
+			MethodName: "FindLinks",
+			Handler:    _LinkGraph_FindLinks_Handler,
+		},
+		{
+			MethodName: "UpsertEdge",
+			Handler:    _LinkGraph_UpsertEdge_Handler,
+		},
+		{
+			MethodName: "RemoveStaleEdges",
+			Handler:    _LinkGraph_RemoveStaleEdges_Handler,
+		},
+		{
+			MethodName: "RecordLinkFailure",
+			Handler:    _LinkGraph_RecordLinkFailure_Handler,
+		},
+		{
+			MethodName: "FindLinkFailure",
+			Handler:    _LinkGraph_FindLinkFailure_Handler,
+		},
+		{
+			MethodName: "SaveCheckpoint",
+			Handler:    _LinkGraph_SaveCheckpoint_Handler,
+		},
+		{
+			MethodName: "FindCheckpoint",
+			Handler:    _LinkGraph_FindCheckpoint_Handler,
+		},
+		{
+			MethodName: "Checkpoints",
+			Handler:    _LinkGraph_Checkpoints_Handler,
+		},
+		{
+			MethodName: "PruneRemovals",
+			Handler:    _LinkGraph_PruneRemovals_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Links",
+			Handler:       _LinkGraph_Links_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Edges",
+			Handler:       _LinkGraph_Edges_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "LinksAsOf",
+			Handler:       _LinkGraph_LinksAsOf_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "EdgesAsOf",
+			Handler:       _LinkGraph_EdgesAsOf_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Diff",
+			Handler:       _LinkGraph_Diff_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "FailedLinks",
+			Handler:       _LinkGraph_FailedLinks_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api.proto",
+}
@@ -0,0 +1,553 @@
+// Package graphapi exposes a link graph over gRPC. The server wraps any
+// graph.Graph implementation while the client implements graph.Graph (as
+// well as graph.CheckpointStore and graph.RemovalPruner) on top of a remote
+// server so that crawler workers can access a link graph without direct
+// access to its backing store.
+package graphapi
+
+import (
+	"context"
+	"errors"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graphapi/proto"
+	"webcrawler/tracing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/api.proto
+
+var _ proto.LinkGraphServer = (*GraphServer)(nil)
+
+var tracer = tracing.Tracer("linkgraph")
+
+// GraphServer provides a gRPC layer for accessing a link graph.
+type GraphServer struct {
+	proto.UnimplementedLinkGraphServer
+	g graph.Graph
+}
+
+// NewGraphServer returns a new server instance that uses the provided graph
+// as its backing store.
+func NewGraphServer(g graph.Graph) *GraphServer {
+	return &GraphServer{g: g}
+}
+
+// UpsertLink inserts or updates a link.
+func (s *GraphServer) UpsertLink(ctx context.Context, req *proto.Link) (*proto.Link, error) {
+	link, err := decodeLink(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = tracing.Do(ctx, tracer, "linkgraph.UpsertLink", func() error { return s.g.UpsertLink(link) }); err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeLink(link), nil
+}
+
+// FindLink looks up a link by its ID.
+func (s *GraphServer) FindLink(ctx context.Context, req *proto.FindLinkRequest) (*proto.Link, error) {
+	id, err := decodeID(req.Uuid, "uuid")
+	if err != nil {
+		return nil, err
+	}
+	var link *graph.Link
+	err = tracing.Do(ctx, tracer, "linkgraph.FindLink", func() (err error) {
+		link, err = s.g.FindLink(id)
+		return err
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeLink(link), nil
+}
+
+// FindLinks looks up a batch of links by their IDs. Links that do not exist
+// are omitted from the response.
+func (s *GraphServer) FindLinks(ctx context.Context, req *proto.FindLinksRequest) (*proto.FindLinksResponse, error) {
+	ids := make([]uuid.UUID, len(req.Uuids))
+	for i, raw := range req.Uuids {
+		id, err := decodeID(raw, "uuids")
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+
+	var links []*graph.Link
+	err := tracing.Do(ctx, tracer, "linkgraph.FindLinks", func() (err error) {
+		links, err = s.g.FindLinks(ids)
+		return err
+	})
+	var missingErr *graph.MissingLinksError
+	if err != nil && !errors.As(err, &missingErr) {
+		return nil, encodeError(err)
+	}
+
+	res := &proto.FindLinksResponse{Links: make([]*proto.Link, 0, len(links))}
+	for _, link := range links {
+		if link != nil {
+			res.Links = append(res.Links, encodeLink(link))
+		}
+	}
+	return res, nil
+}
+
+// UpsertEdge inserts or updates an edge.
+func (s *GraphServer) UpsertEdge(ctx context.Context, req *proto.Edge) (*proto.Edge, error) {
+	edge, err := decodeEdge(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = tracing.Do(ctx, tracer, "linkgraph.UpsertEdge", func() error { return s.g.UpsertEdge(edge) }); err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeEdge(edge), nil
+}
+
+// RemoveStaleEdges removes any edge that originates from the specified link
+// ID and was updated before the specified timestamp.
+func (s *GraphServer) RemoveStaleEdges(ctx context.Context, req *proto.RemoveStaleEdgesQuery) (*emptypb.Empty, error) {
+	fromID, err := decodeID(req.FromUuid, "from_uuid")
+	if err != nil {
+		return nil, err
+	}
+	err = tracing.Do(ctx, tracer, "linkgraph.RemoveStaleEdges", func() error {
+		return s.g.RemoveStaleEdges(fromID, req.UpdatedBefore)
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// Links streams the set of links in the specified ID range.
+func (s *GraphServer) Links(req *proto.Range, stream proto.LinkGraph_LinksServer) error {
+	fromID, toID, err := decodeRange(req.FromUuid, req.ToUuid)
+	if err != nil {
+		return err
+	}
+	it, err := s.g.Links(fromID, toID, req.Filter)
+	if err != nil {
+		return encodeError(err)
+	}
+	return streamLinks(stream.Context(), it, stream.Send)
+}
+
+// LinksAsOf streams the set of links in the specified ID range that were
+// present in the graph at the end of the specified crawl pass.
+func (s *GraphServer) LinksAsOf(req *proto.AsOfRange, stream proto.LinkGraph_LinksAsOfServer) error {
+	fromID, toID, err := decodeRange(req.FromUuid, req.ToUuid)
+	if err != nil {
+		return err
+	}
+	it, err := s.g.LinksAsOf(fromID, toID, req.PassId)
+	if err != nil {
+		return encodeError(err)
+	}
+	return streamLinks(stream.Context(), it, stream.Send)
+}
+
+// Edges streams the set of edges whose source link IDs belong to the
+// specified range.
+func (s *GraphServer) Edges(req *proto.Range, stream proto.LinkGraph_EdgesServer) error {
+	fromID, toID, err := decodeRange(req.FromUuid, req.ToUuid)
+	if err != nil {
+		return err
+	}
+	it, err := s.g.Edges(fromID, toID, req.Filter)
+	if err != nil {
+		return encodeError(err)
+	}
+	return streamEdges(stream.Context(), it, stream.Send)
+}
+
+// EdgesAsOf streams the set of edges whose source link IDs belong to the
+// specified range and were present in the graph at the end of the specified
+// crawl pass.
+func (s *GraphServer) EdgesAsOf(req *proto.AsOfRange, stream proto.LinkGraph_EdgesAsOfServer) error {
+	fromID, toID, err := decodeRange(req.FromUuid, req.ToUuid)
+	if err != nil {
+		return err
+	}
+	it, err := s.g.EdgesAsOf(fromID, toID, req.PassId)
+	if err != nil {
+		return encodeError(err)
+	}
+	return streamEdges(stream.Context(), it, stream.Send)
+}
+
+// Diff streams the set of changes applied by the crawl passes in the
+// specified range.
+func (s *GraphServer) Diff(req *proto.DiffRequest, stream proto.LinkGraph_DiffServer) error {
+	it, err := s.g.Diff(req.PassA, req.PassB)
+	if err != nil {
+		return encodeError(err)
+	}
+	defer func() { _ = it.Close() }()
+
+	for it.Next() {
+		if err = stream.Context().Err(); err != nil {
+			return err
+		}
+		if err = stream.Send(encodeChange(it.Change())); err != nil {
+			return err
+		}
+	}
+	if err = it.Error(); err != nil {
+		return encodeError(err)
+	}
+	return nil
+}
+
+// RecordLinkFailure creates or replaces the failure record of a link.
+func (s *GraphServer) RecordLinkFailure(ctx context.Context, req *proto.LinkFailure) (*proto.LinkFailure, error) {
+	f, err := decodeLinkFailure(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = tracing.Do(ctx, tracer, "linkgraph.RecordLinkFailure", func() error { return s.g.RecordLinkFailure(f) }); err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeLinkFailure(f), nil
+}
+
+// FindLinkFailure looks up the failure record of a link by its ID.
+func (s *GraphServer) FindLinkFailure(ctx context.Context, req *proto.FindLinkFailureRequest) (*proto.LinkFailure, error) {
+	id, err := decodeID(req.LinkUuid, "link_uuid")
+	if err != nil {
+		return nil, err
+	}
+	var f *graph.LinkFailure
+	err = tracing.Do(ctx, tracer, "linkgraph.FindLinkFailure", func() (err error) {
+		f, err = s.g.LinkFailure(id)
+		return err
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeLinkFailure(f), nil
+}
+
+// FailedLinks streams the failure records of the links in the specified ID
+// range.
+func (s *GraphServer) FailedLinks(req *proto.FailedLinksRequest, stream proto.LinkGraph_FailedLinksServer) error {
+	fromID, toID, err := decodeRange(req.FromUuid, req.ToUuid)
+	if err != nil {
+		return err
+	}
+	it, err := s.g.FailedLinks(fromID, toID)
+	if err != nil {
+		return encodeError(err)
+	}
+	defer func() { _ = it.Close() }()
+
+	for it.Next() {
+		if err = stream.Context().Err(); err != nil {
+			return err
+		}
+		if err = stream.Send(encodeLinkFailure(it.Failure())); err != nil {
+			return err
+		}
+	}
+	if err = it.Error(); err != nil {
+		return encodeError(err)
+	}
+	return nil
+}
+
+// SaveCheckpoint creates or replaces the checkpoint of a partition.
+func (s *GraphServer) SaveCheckpoint(ctx context.Context, req *proto.Checkpoint) (*proto.Checkpoint, error) {
+	store, err := s.checkpointStore()
+	if err != nil {
+		return nil, err
+	}
+	cp := decodeCheckpoint(req)
+	if err = tracing.Do(ctx, tracer, "linkgraph.SaveCheckpoint", func() error { return store.SaveCheckpoint(cp) }); err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeCheckpoint(cp), nil
+}
+
+// FindCheckpoint looks up the checkpoint of a partition.
+func (s *GraphServer) FindCheckpoint(ctx context.Context, req *proto.FindCheckpointRequest) (*proto.Checkpoint, error) {
+	store, err := s.checkpointStore()
+	if err != nil {
+		return nil, err
+	}
+	var cp *graph.Checkpoint
+	err = tracing.Do(ctx, tracer, "linkgraph.FindCheckpoint", func() (err error) {
+		cp, err = store.Checkpoint(int(req.Partition))
+		return err
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeCheckpoint(cp), nil
+}
+
+// Checkpoints returns the checkpoints of all partitions.
+func (s *GraphServer) Checkpoints(ctx context.Context, _ *emptypb.Empty) (*proto.CheckpointList, error) {
+	store, err := s.checkpointStore()
+	if err != nil {
+		return nil, err
+	}
+	var cps []*graph.Checkpoint
+	err = tracing.Do(ctx, tracer, "linkgraph.Checkpoints", func() (err error) {
+		cps, err = store.Checkpoints()
+		return err
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	res := &proto.CheckpointList{Checkpoints: make([]*proto.Checkpoint, len(cps))}
+	for i, cp := range cps {
+		res.Checkpoints[i] = encodeCheckpoint(cp)
+	}
+	return res, nil
+}
+
+// PruneRemovals discards the records of the edges removed by the specified
+// or an earlier crawl pass.
+func (s *GraphServer) PruneRemovals(ctx context.Context, req *proto.PruneRemovalsRequest) (*emptypb.Empty, error) {
+	pruner, ok := s.g.(graph.RemovalPruner)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the link graph does not record edge removals")
+	}
+	if err := tracing.Do(ctx, tracer, "linkgraph.PruneRemovals", func() error { return pruner.PruneRemovals(req.PassId) }); err != nil {
+		return nil, encodeError(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// checkpointStore returns the checkpoint store of the served graph or an
+// Unimplemented error if the graph does not store checkpoints.
+func (s *GraphServer) checkpointStore() (graph.CheckpointStore, error) {
+	store, ok := s.g.(graph.CheckpointStore)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the link graph does not store checkpoints")
+	}
+	return store, nil
+}
+
+func streamLinks(ctx context.Context, it graph.LinkIterator, send func(*proto.Link) error) error {
+	defer func() { _ = it.Close() }()
+
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := send(encodeLink(it.Link())); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return encodeError(err)
+	}
+	return nil
+}
+
+func streamEdges(ctx context.Context, it graph.EdgeIterator, send func(*proto.Edge) error) error {
+	defer func() { _ = it.Close() }()
+
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := send(encodeEdge(it.Edge())); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return encodeError(err)
+	}
+	return nil
+}
+
+// encodeError maps the errors returned by the graph to gRPC status errors so
+// that the client can map them back to the graph package errors.
+func encodeError(err error) error {
+	switch {
+	case errors.Is(err, graph.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, graph.ErrUnknownEdgeLinks):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func decodeID(raw []byte, field string) (uuid.UUID, error) {
+	if len(raw) == 0 {
+		return uuid.Nil, nil
+	}
+	id, err := uuid.FromBytes(raw)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", field, err)
+	}
+	return id, nil
+}
+
+func decodeRange(from, to []byte) (uuid.UUID, uuid.UUID, error) {
+	fromID, err := decodeID(from, "from_uuid")
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	toID, err := decodeID(to, "to_uuid")
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return fromID, toID, nil
+}
+
+func decodeLink(l *proto.Link) (*graph.Link, error) {
+	id, err := decodeID(l.Uuid, "uuid")
+	if err != nil {
+		return nil, err
+	}
+	return &graph.Link{
+		ID:          id,
+		URL:         l.Url,
+		RetrievedAt: l.RetrievedAt,
+		NextFetchAt: l.NextFetchAt,
+		ContentHash: l.ContentHash,
+		FirstPassID: l.FirstPassId,
+		PassID:      l.PassId,
+		Namespace:   l.Namespace,
+	}, nil
+}
+
+func decodeEdge(e *proto.Edge) (*graph.Edge, error) {
+	id, err := decodeID(e.Uuid, "uuid")
+	if err != nil {
+		return nil, err
+	}
+	src, err := decodeID(e.SrcUuid, "src_uuid")
+	if err != nil {
+		return nil, err
+	}
+	dst, err := decodeID(e.DstUuid, "dst_uuid")
+	if err != nil {
+		return nil, err
+	}
+	return &graph.Edge{
+		ID:          id,
+		Src:         src,
+		Dst:         dst,
+		UpdatedAt:   e.UpdatedAt,
+		FirstPassID: e.FirstPassId,
+		PassID:      e.PassId,
+		Namespace:   e.Namespace,
+		RelType:     graph.RelType(e.RelType),
+		AnchorText:  e.AnchorText,
+		NoFollow:    e.NoFollow,
+	}, nil
+}
+
+func encodeLink(l *graph.Link) *proto.Link {
+	return &proto.Link{
+		Uuid:        l.ID[:],
+		Url:         l.URL,
+		RetrievedAt: l.RetrievedAt,
+		NextFetchAt: l.NextFetchAt,
+		ContentHash: l.ContentHash,
+		FirstPassId: l.FirstPassID,
+		PassId:      l.PassID,
+		Namespace:   l.Namespace,
+	}
+}
+
+func encodeEdge(e *graph.Edge) *proto.Edge {
+	return &proto.Edge{
+		Uuid:        e.ID[:],
+		SrcUuid:     e.Src[:],
+		DstUuid:     e.Dst[:],
+		UpdatedAt:   e.UpdatedAt,
+		FirstPassId: e.FirstPassID,
+		PassId:      e.PassID,
+		Namespace:   e.Namespace,
+		RelType:     proto.Edge_RelType(e.RelType),
+		AnchorText:  e.AnchorText,
+		NoFollow:    e.NoFollow,
+	}
+}
+
+func decodeLinkFailure(f *proto.LinkFailure) (*graph.LinkFailure, error) {
+	id, err := decodeID(f.LinkUuid, "link_uuid")
+	if err != nil {
+		return nil, err
+	}
+	return &graph.LinkFailure{
+		LinkID:      id,
+		URL:         f.Url,
+		Reason:      f.Reason,
+		Attempts:    int(f.Attempts),
+		FailedAt:    f.FailedAt,
+		NextRetryAt: f.NextRetryAt,
+		Quarantined: f.Quarantined,
+	}, nil
+}
+
+func encodeLinkFailure(f *graph.LinkFailure) *proto.LinkFailure {
+	return &proto.LinkFailure{
+		LinkUuid:    f.LinkID[:],
+		Url:         f.URL,
+		Reason:      f.Reason,
+		Attempts:    int64(f.Attempts),
+		FailedAt:    f.FailedAt,
+		NextRetryAt: f.NextRetryAt,
+		Quarantined: f.Quarantined,
+	}
+}
+
+func encodeChange(c *graph.Change) *proto.Change {
+	res := &proto.Change{Type: proto.Change_Type(c.Type)}
+	if c.Link != nil {
+		res.Link = encodeLink(c.Link)
+	}
+	if c.Edge != nil {
+		res.Edge = encodeEdge(c.Edge)
+	}
+	return res
+}
+
+func decodeCheckpoint(cp *proto.Checkpoint) *graph.Checkpoint {
+	return &graph.Checkpoint{
+		Partition:     int(cp.Partition),
+		PassID:        cp.PassId,
+		PassStartedAt: decodeTime(cp.PassStartedAt),
+		CompletedAt:   decodeTime(cp.CompletedAt),
+		UpdatedAt:     decodeTime(cp.UpdatedAt),
+	}
+}
+
+func encodeCheckpoint(cp *graph.Checkpoint) *proto.Checkpoint {
+	return &proto.Checkpoint{
+		Partition:     int64(cp.Partition),
+		PassId:        cp.PassID,
+		PassStartedAt: encodeTime(cp.PassStartedAt),
+		CompletedAt:   encodeTime(cp.CompletedAt),
+		UpdatedAt:     encodeTime(cp.UpdatedAt),
+	}
+}
+
+// encodeTime leaves the timestamp unset for the zero time so that it can be
+// told apart from the Unix epoch.
+func encodeTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func decodeTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
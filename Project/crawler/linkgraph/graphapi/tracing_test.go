@@ -0,0 +1,74 @@
+package graphapi
+
+import (
+	"context"
+	"net"
+	"sync"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graphapi/proto"
+	"webcrawler/crawler/linkgraph/store/memory"
+	"webcrawler/tracing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(TracingTestSuite))
+
+type TracingTestSuite struct {
+	rec *tracetest.SpanRecorder
+	tp  *sdktrace.TracerProvider
+}
+
+// The global tracer provider can only be replaced once as the package-level
+// tracers keep delegating to the first registered provider. The recorder is
+// therefore shared by all runs of the suite.
+var (
+	spanRecorder    = tracetest.NewSpanRecorder()
+	tracerProvider  = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	setProviderOnce sync.Once
+)
+
+func (s *TracingTestSuite) SetUpSuite(c *gc.C) {
+	setProviderOnce.Do(func() { otel.SetTracerProvider(tracerProvider) })
+	s.rec, s.tp = spanRecorder, tracerProvider
+}
+
+func (s *TracingTestSuite) TestTracePropagation(c *gc.C) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(tracing.ServerOptions()...)
+	proto.RegisterLinkGraphServer(srv, NewGraphServer(memory.NewInMemoryGraph()))
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, tracing.DialOptions()...)
+	conn, err := grpc.DialContext(context.TODO(), "bufnet", dialOpts...)
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = conn.Close() }()
+	cli := NewGraphClient(context.TODO(), proto.NewLinkGraphClient(conn))
+
+	ctx, root := s.tp.Tracer("test").Start(context.TODO(), "root")
+	err = cli.WithContext(ctx).UpsertLink(&graph.Link{URL: "http://example.com"})
+	root.End()
+	c.Assert(err, gc.IsNil)
+
+	// The store span must belong to the client trace and be a child of
+	// the span created by the server for the RPC.
+	var found bool
+	for _, span := range s.rec.Ended() {
+		if span.Name() != "linkgraph.UpsertLink" || span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			continue
+		}
+		found = true
+		c.Assert(span.Parent().IsRemote(), gc.Equals, false)
+	}
+	c.Assert(found, gc.Equals, true, gc.Commentf("expected a span for the graph store call in the client trace"))
+}
@@ -0,0 +1,249 @@
+// Package importer loads links and edges from edge lists into a link graph,
+// e.g. for evaluating PageRank against public web graph datasets.
+//
+// Each record of an edge list describes an edge between a source and a target
+// URL. The URLs are validated and normalized and each distinct URL is upserted
+// into the graph once; the IDs assigned to the links are cached for the
+// duration of the import so that the edges can refer to them.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/urlutil/normalizer"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidRecord is returned when an edge list record cannot be imported.
+var ErrInvalidRecord = errors.New("invalid record")
+
+// Format describes the encoding of an edge list.
+type Format string
+
+const (
+	// CSV describes edge lists in CSV format. If the first row is a header
+	// with "source" and "target" columns, the URLs are read from those
+	// columns and the optional "rel", "anchor" and "nofollow" columns
+	// describe the edge. Otherwise, the source and target URLs are read
+	// from the first two columns. Lines starting with '#' are ignored.
+	CSV Format = "csv"
+
+	// JSONL describes edge lists with one JSON object per line; see
+	// Record for the supported fields. Blank lines are ignored.
+	JSONL Format = "jsonl"
+)
+
+// ParseFormat returns the Format with the specified name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case CSV, JSONL:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported edge list format %q", name)
+	}
+}
+
+// Record describes an edge list entry.
+type Record struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Rel      string `json:"rel,omitempty"`
+	Anchor   string `json:"anchor,omitempty"`
+	NoFollow bool   `json:"nofollow,omitempty"`
+}
+
+// GraphAPI defines the set of link graph operations required for importing
+// an edge list.
+type GraphAPI interface {
+	UpsertLink(link *graph.Link) error
+	UpsertEdge(edge *graph.Edge) error
+}
+
+// The default number of records that are upserted into the graph per batch.
+const defaultBatchSize = 1000
+
+// The maximum number of rejected records that are described in Stats.
+const maxReportedErrors = 100
+
+// Options configures an import.
+type Options struct {
+	// The encoding of the edge list. Defaults to CSV.
+	Format Format
+
+	// The number of valid records to upsert into the graph before
+	// reporting progress. Defaults to 1000.
+	BatchSize int
+
+	// The normalizer for the source and target URLs. If not specified,
+	// the URLs are normalized with the default settings.
+	Normalizer *normalizer.Normalizer
+
+	// An optional callback that is invoked after each batch and once the
+	// entire edge list has been read.
+	Progress func(Stats)
+}
+
+// Stats describes the outcome of an import.
+type Stats struct {
+	// The number of records read from the edge list.
+	Read uint64 `json:"read"`
+
+	// The number of distinct links upserted into the graph and the
+	// number of edge upserts.
+	Links uint64 `json:"links"`
+	Edges uint64 `json:"edges"`
+
+	// The number of records that repeat an edge of the same batch and
+	// the number of records that were rejected.
+	Duplicates uint64        `json:"duplicates"`
+	Rejected   uint64        `json:"rejected"`
+	Errors     []RecordError `json:"errors,omitempty"`
+}
+
+// RecordError describes why a record was rejected.
+type RecordError struct {
+	// The line of the edge list that contains the record.
+	Line    uint64 `json:"line"`
+	Message string `json:"message"`
+}
+
+// entry is a record read from an edge list along with its line number.
+type entry struct {
+	line uint64
+	rec  Record
+}
+
+// edgeKey identifies an edge within a batch.
+type edgeKey struct {
+	src, dst uuid.UUID
+}
+
+// Import reads the edge list from r and upserts its links and edges into g in
+// batches. Invalid records are reported in the returned stats. Edges that
+// appear more than once in a batch are upserted once; as the graph resolves
+// edges by their endpoints, duplicates across batches update the same edge.
+//
+// The import stops if ctx is cancelled, r cannot be read or the graph
+// returns an error; the returned stats then describe the batches that were
+// processed until that point.
+func Import(ctx context.Context, r io.Reader, g GraphAPI, opts Options) (Stats, error) {
+	var next func() (entry, error)
+	switch opts.Format {
+	case CSV, "":
+		next = newCSVReader(r)
+	case JSONL:
+		next = newJSONLReader(r)
+	default:
+		return Stats{}, fmt.Errorf("import: unsupported format %q", opts.Format)
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	var (
+		stats    Stats
+		reported uint64
+		linkIDs  = make(map[string]uuid.UUID)
+		batch    = make([]*graph.Edge, 0, batchSize)
+		inBatch  = make(map[edgeKey]struct{}, batchSize)
+	)
+	reject := func(line uint64, err error) {
+		stats.Rejected++
+		if len(stats.Errors) < maxReportedErrors {
+			stats.Errors = append(stats.Errors, RecordError{Line: line, Message: err.Error()})
+		}
+	}
+	linkID := func(linkURL string) (uuid.UUID, error) {
+		if id, ok := linkIDs[linkURL]; ok {
+			return id, nil
+		}
+		link := &graph.Link{URL: linkURL}
+		if err := g.UpsertLink(link); err != nil {
+			return uuid.Nil, err
+		}
+		linkIDs[linkURL] = link.ID
+		stats.Links++
+		return link.ID, nil
+	}
+	flush := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, edge := range batch {
+			if err := g.UpsertEdge(edge); err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+			stats.Edges++
+		}
+		batch = batch[:0]
+		clear(inBatch)
+		if opts.Progress != nil && stats.Read != reported {
+			reported = stats.Read
+			opts.Progress(stats)
+		}
+		return nil
+	}
+
+	for {
+		e, err := next()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, ErrInvalidRecord) {
+			stats.Read++
+			reject(e.line, err)
+			continue
+		} else if err != nil {
+			return stats, fmt.Errorf("import: %w", err)
+		}
+
+		stats.Read++
+		edge, err := prepare(&e.rec, opts.Normalizer)
+		if err != nil {
+			reject(e.line, err)
+			continue
+		}
+		if edge.Src, err = linkID(e.rec.Source); err != nil {
+			return stats, fmt.Errorf("import: line %d: %w", e.line, err)
+		}
+		if edge.Dst, err = linkID(e.rec.Target); err != nil {
+			return stats, fmt.Errorf("import: line %d: %w", e.line, err)
+		}
+		key := edgeKey{src: edge.Src, dst: edge.Dst}
+		if _, dup := inBatch[key]; dup {
+			stats.Duplicates++
+			continue
+		}
+		inBatch[key] = struct{}{}
+		if batch = append(batch, edge); len(batch) == batchSize {
+			if err = flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, flush()
+}
+
+// prepare validates rec, normalizes its URLs in place and returns the edge it
+// describes with unset endpoints.
+func prepare(rec *Record, n *normalizer.Normalizer) (*graph.Edge, error) {
+	var err error
+	if rec.Source, err = n.Normalize(rec.Source); err != nil {
+		return nil, fmt.Errorf("%w: source: %w", ErrInvalidRecord, err)
+	}
+	if rec.Target, err = n.Normalize(rec.Target); err != nil {
+		return nil, fmt.Errorf("%w: target: %w", ErrInvalidRecord, err)
+	}
+	edge := &graph.Edge{AnchorText: rec.Anchor, NoFollow: rec.NoFollow}
+	if rec.Rel != "" {
+		if edge.RelType, err = graph.ParseRelType(strings.ToLower(rec.Rel)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+		}
+	}
+	return edge, nil
+}
@@ -0,0 +1,122 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"webcrawler/crawler/iterutil"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/store/memory"
+	"webcrawler/partition"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ImporterTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type ImporterTestSuite struct{}
+
+func (s *ImporterTestSuite) TestImportCSV(c *gc.C) {
+	g := memory.NewInMemoryGraph()
+	list := "# crawl sample\n" +
+		"Source,Target,Rel,Anchor,NoFollow\n" +
+		"http://example.com/,http://example.com/a,,About us,false\n" +
+		"http://example.com/,http://example.com/a,,About us,\n" +
+		"http://EXAMPLE.com:80/,http://example.com/b#top,canonical,,\n" +
+		"ftp://example.com/,http://example.com/a,,,\n" +
+		"http://example.com/a,http://example.com/b,bogus,,\n" +
+		"http://example.com/a,http://example.com/b,,,maybe\n"
+
+	var progress []Stats
+	stats, err := Import(context.TODO(), strings.NewReader(list), g, Options{
+		BatchSize: 2,
+		Progress:  func(st Stats) { progress = append(progress, st) },
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(stats.Read, gc.Equals, uint64(6))
+	c.Assert(stats.Links, gc.Equals, uint64(3))
+	c.Assert(stats.Edges, gc.Equals, uint64(2))
+	c.Assert(stats.Duplicates, gc.Equals, uint64(1))
+	c.Assert(stats.Rejected, gc.Equals, uint64(3))
+	c.Assert(stats.Errors, gc.HasLen, 3)
+	c.Assert(stats.Errors[0].Line, gc.Equals, uint64(6))
+	c.Assert(stats.Errors[0].Message, gc.Matches, `invalid record: source: .*unsupported scheme "ftp"`)
+	c.Assert(stats.Errors[1].Message, gc.Matches, `invalid record: unknown rel type "bogus"`)
+	c.Assert(stats.Errors[2].Message, gc.Matches, `invalid record: invalid nofollow value "maybe"`)
+	c.Assert(progress, gc.HasLen, 2)
+	c.Assert(progress[0].Edges, gc.Equals, uint64(2))
+
+	edges := s.edges(c, g)
+	c.Assert(edges, gc.HasLen, 2)
+	c.Assert(edges["http://example.com/ -> http://example.com/a"].AnchorText, gc.Equals, "About us")
+	c.Assert(edges["http://example.com/ -> http://example.com/b"].RelType, gc.Equals, graph.RelCanonical)
+}
+
+func (s *ImporterTestSuite) TestImportJSONL(c *gc.C) {
+	g := memory.NewInMemoryGraph()
+	list := `{"source": "http://example.com/", "target": "http://example.com/a", "nofollow": true}` + "\n\n" +
+		`{"source": "http://example.com/a", "target": "http://example.com/"}` + "\n" +
+		`{"source": "http://example.com/a", "target": "http://example.com/"}` + "\n" +
+		`{"source": "http://example.com/a"` + "\n"
+
+	stats, err := Import(context.TODO(), strings.NewReader(list), g, Options{Format: JSONL})
+	c.Assert(err, gc.IsNil)
+	c.Assert(stats, gc.DeepEquals, Stats{
+		Read: 4, Links: 2, Edges: 2, Duplicates: 1, Rejected: 1,
+		Errors: []RecordError{{Line: 5, Message: "invalid record: unexpected end of JSON input"}},
+	})
+
+	edges := s.edges(c, g)
+	c.Assert(edges["http://example.com/ -> http://example.com/a"].NoFollow, gc.Equals, true)
+	c.Assert(edges["http://example.com/a -> http://example.com/"], gc.NotNil)
+}
+
+func (s *ImporterTestSuite) TestImportWithoutHeader(c *gc.C) {
+	g := memory.NewInMemoryGraph()
+	stats, err := Import(context.TODO(), strings.NewReader("http://example.com/,http://example.com/a\n"), g, Options{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(stats.Edges, gc.Equals, uint64(1))
+}
+
+func (s *ImporterTestSuite) TestGraphErrorsAbortTheImport(c *gc.C) {
+	list := "http://example.com/,http://example.com/a\n"
+	_, err := Import(context.TODO(), strings.NewReader(list), failingGraph{}, Options{})
+	c.Assert(err, gc.ErrorMatches, "import: line 1: graph unavailable")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err = Import(ctx, strings.NewReader(list), memory.NewInMemoryGraph(), Options{})
+	c.Assert(err, gc.Equals, context.Canceled)
+}
+
+// edges returns the edges of g keyed by their source and destination URLs.
+func (s *ImporterTestSuite) edges(c *gc.C, g *memory.InMemoryGraph) map[string]*graph.Edge {
+	linkIt, err := g.Links(uuid.Nil, partition.MaxUUID, 1<<62)
+	c.Assert(err, gc.IsNil)
+	links, err := iterutil.CollectLinks(linkIt)
+	c.Assert(err, gc.IsNil)
+	urls := make(map[uuid.UUID]string)
+	for _, link := range links {
+		urls[link.ID] = link.URL
+	}
+
+	edgeIt, err := g.Edges(uuid.Nil, partition.MaxUUID, 1<<62)
+	c.Assert(err, gc.IsNil)
+	edges := make(map[string]*graph.Edge)
+	for edgeIt.Next() {
+		edge := edgeIt.Edge()
+		edges[urls[edge.Src]+" -> "+urls[edge.Dst]] = edge
+	}
+	c.Assert(edgeIt.Error(), gc.IsNil)
+	c.Assert(edgeIt.Close(), gc.IsNil)
+	return edges
+}
+
+type failingGraph struct{}
+
+func (failingGraph) UpsertLink(*graph.Link) error { return errors.New("graph unavailable") }
+func (failingGraph) UpsertEdge(*graph.Edge) error { return errors.New("graph unavailable") }
@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The maximum length of a line in a JSONL edge list.
+const maxLineSize = 1 << 20
+
+// newCSVReader returns a function that yields the records of an edge list in
+// CSV format.
+func newCSVReader(r io.Reader) func() (entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	var (
+		cols  = csvColumns{source: 0, target: 1, rel: -1, anchor: -1, noFollow: -1}
+		first = true
+	)
+	return func() (entry, error) {
+		for {
+			row, err := cr.Read()
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return entry{line: uint64(parseErr.StartLine)}, fmt.Errorf("%w: %v", ErrInvalidRecord, parseErr.Err)
+			} else if err != nil {
+				return entry{}, err
+			}
+			line, _ := cr.FieldPos(0)
+
+			if first {
+				first = false
+				if header, ok := csvHeader(row); ok {
+					cols = header
+					continue
+				}
+			}
+
+			e := entry{line: uint64(line)}
+			e.rec.Source = csvField(row, cols.source)
+			e.rec.Target = csvField(row, cols.target)
+			e.rec.Rel = csvField(row, cols.rel)
+			e.rec.Anchor = csvField(row, cols.anchor)
+			if noFollow := csvField(row, cols.noFollow); noFollow != "" {
+				if e.rec.NoFollow, err = strconv.ParseBool(noFollow); err != nil {
+					return e, fmt.Errorf("%w: invalid nofollow value %q", ErrInvalidRecord, noFollow)
+				}
+			}
+			return e, nil
+		}
+	}
+}
+
+// csvColumns holds the indices of the columns of a CSV edge list. Optional
+// columns that are not present are set to -1.
+type csvColumns struct {
+	source, target, rel, anchor, noFollow int
+}
+
+// csvHeader returns the columns described by row if it is a CSV header with
+// source and target columns.
+func csvHeader(row []string) (csvColumns, bool) {
+	cols := csvColumns{source: -1, target: -1, rel: -1, anchor: -1, noFollow: -1}
+	for i, name := range row {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "source", "src":
+			cols.source = i
+		case "target", "dst":
+			cols.target = i
+		case "rel":
+			cols.rel = i
+		case "anchor":
+			cols.anchor = i
+		case "nofollow":
+			cols.noFollow = i
+		}
+	}
+	return cols, cols.source >= 0 && cols.target >= 0
+}
+
+// csvField returns the trimmed value of the specified column of row or an
+// empty string if row does not have such a column.
+func csvField(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[col])
+}
+
+// newJSONLReader returns a function that yields the records of an edge list
+// with one JSON object per line.
+func newJSONLReader(r io.Reader) func() (entry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	var line uint64
+	return func() (entry, error) {
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			e := entry{line: line}
+			if err := json.Unmarshal([]byte(text), &e.rec); err != nil {
+				return e, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+			}
+			return e, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return entry{}, err
+		}
+		return entry{}, io.EOF
+	}
+}
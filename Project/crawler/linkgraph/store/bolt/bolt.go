@@ -0,0 +1,732 @@
+// Package bolt implements a link graph that is persisted to an embedded bbolt
+// key-value store. It targets single-machine crawls with high write
+// throughput: concurrent upserts are coalesced into shared transactions and
+// links and edges are stored in key order so that the links and edges of a
+// partition are retrieved via range scans.
+package bolt
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+	"webcrawler/namespace"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// The maximum time to wait for the file lock of the database; bbolt files can
+// only be opened by a single process at a time.
+const openTimeout = 5 * time.Second
+
+var (
+	// Compile-time checks for ensuring BoltGraph implements Graph,
+	// CheckpointStore, RemovalPruner and EdgeGrouper.
+	_ graph.Graph           = (*BoltGraph)(nil)
+	_ graph.CheckpointStore = (*BoltGraph)(nil)
+	_ graph.RemovalPruner   = (*BoltGraph)(nil)
+	_ graph.EdgeGrouper     = (*BoltGraph)(nil)
+
+	upsertLinkDuration = metrics.GraphUpsertDuration.WithLabelValues("bolt", "link")
+	upsertEdgeDuration = metrics.GraphUpsertDuration.WithLabelValues("bolt", "edge")
+)
+
+// BoltGraph implements a graph that persists its links and edges to a bbolt
+// database file. Each BoltGraph is bound to a single namespace whose data is
+// stored in a separate top-level bucket, so the data of multiple crawls can
+// be kept in the same database file. Note that a database file can only be
+// opened by a single BoltGraph at a time.
+type BoltGraph struct {
+	db     *bbolt.DB
+	ns     string
+	logger *slog.Logger
+}
+
+// Config encapsulates the optional settings for a BoltGraph.
+type Config struct {
+	// The namespace of the crawl whose data is accessed through the
+	// graph. Defaults to namespace.Default.
+	Namespace string
+
+	// If set, committed transactions are not synced to disk. This speeds
+	// up writes considerably at the cost of losing the most recent
+	// changes (or corrupting the database) if the machine crashes.
+	NoSync bool
+
+	// The maximum time that an upsert waits for concurrent upserts to
+	// share its write transaction. Longer delays increase the throughput
+	// of concurrent writers at the cost of latency for sequential ones.
+	// Defaults to bbolt.DefaultMaxBatchDelay.
+	MaxBatchDelay time.Duration
+
+	// An optional logger for reporting removals. If not specified,
+	// nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if nsErr := namespace.Validate(namespace.OrDefault(cfg.Namespace)); nsErr != nil {
+		err = multierror.Append(err, nsErr)
+	}
+	if cfg.MaxBatchDelay < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for max batch delay"))
+	} else if cfg.MaxBatchDelay == 0 {
+		cfg.MaxBatchDelay = bbolt.DefaultMaxBatchDelay
+	}
+	return err
+}
+
+// NewBoltGraph returns a BoltGraph instance that stores its data in the
+// database file at path. The file is created if it does not exist.
+func NewBoltGraph(path string) (*BoltGraph, error) {
+	return NewBoltGraphWithConfig(path, Config{})
+}
+
+// NewBoltGraphWithConfig returns a BoltGraph instance that stores its data in
+// the database file at path and uses the settings in cfg. The file is created
+// if it does not exist.
+func NewBoltGraphWithConfig(path string, cfg Config) (*BoltGraph, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("bolt graph: config validation failed: %w", err)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("bolt graph: database path has not been specified")
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: openTimeout, NoSync: cfg.NoSync})
+	if err != nil {
+		return nil, fmt.Errorf("bolt graph: %w", err)
+	}
+	db.MaxBatchDelay = cfg.MaxBatchDelay
+
+	g := &BoltGraph{
+		db:     db,
+		ns:     namespace.OrDefault(cfg.Namespace),
+		logger: logging.Component(cfg.Logger, "linkgraph.bolt"),
+	}
+	if err = db.Update(g.createBuckets); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("bolt graph: creating buckets: %w", err)
+	}
+	return g, nil
+}
+
+func (g *BoltGraph) createBuckets(tx *bbolt.Tx) error {
+	nsBucket, err := tx.CreateBucketIfNotExists([]byte(g.ns))
+	if err != nil {
+		return err
+	}
+	for _, name := range nsBuckets {
+		if _, err = nsBucket.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	return g.convertLegacyLinks(nsBucket)
+}
+
+// convertLegacyLinks moves the links of the legacy links buckets, if present,
+// to the links bucket.
+func (g *BoltGraph) convertLegacyLinks(nsBucket *bbolt.Bucket) error {
+	links := nsBucket.Bucket(linksBucket)
+	for _, format := range legacyLinkFormats {
+		legacy := nsBucket.Bucket(format.bucket)
+		if legacy == nil {
+			continue
+		}
+		err := legacy.ForEach(func(key, val []byte) error {
+			link, err := format.decode(key, val, g.ns)
+			if err != nil {
+				return err
+			}
+			return links.Put(key, encodeLink(link))
+		})
+		if err != nil {
+			return fmt.Errorf("converting legacy links: %w", err)
+		}
+		if err = nsBucket.DeleteBucket(format.bucket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucket returns the named bucket of the graph namespace.
+func (g *BoltGraph) bucket(tx *bbolt.Tx, name []byte) *bbolt.Bucket {
+	return tx.Bucket([]byte(g.ns)).Bucket(name)
+}
+
+// Namespace returns the namespace that the graph is bound to.
+func (g *BoltGraph) Namespace() string {
+	return g.ns
+}
+
+// Close closes the backing database.
+func (g *BoltGraph) Close() error {
+	return g.db.Close()
+}
+
+// UpsertLink creates a new link or updates an existing link.
+func (g *BoltGraph) UpsertLink(link *graph.Link) error {
+	defer metrics.ObserveSince(upsertLinkDuration, time.Now())
+
+	var stored *graph.Link
+	err := g.db.Batch(func(tx *bbolt.Tx) error {
+		links, urls := g.bucket(tx, linksBucket), g.bucket(tx, urlsBucket)
+
+		// Links are identified by their URL; the ID of the provided
+		// link is ignored.
+		if id := urls.Get([]byte(link.URL)); id != nil {
+			existing, err := decodeLink(id, links.Get(id), g.ns)
+			if err != nil {
+				return err
+			}
+			if link.RetrievedAt > existing.RetrievedAt || (link.RetrievedAt == existing.RetrievedAt && link.NextFetchAt > existing.NextFetchAt) {
+				existing.NextFetchAt = link.NextFetchAt
+			}
+			if link.RetrievedAt > existing.RetrievedAt || (link.RetrievedAt == existing.RetrievedAt && existing.ContentHash == 0) {
+				existing.ContentHash = link.ContentHash
+			}
+			if link.RetrievedAt > existing.RetrievedAt {
+				existing.RetrievedAt = link.RetrievedAt
+			}
+			if link.PassID > existing.PassID {
+				existing.PassID = link.PassID
+			}
+			if err = g.clearFailure(tx, existing); err != nil {
+				return err
+			}
+			stored = existing
+			return links.Put(existing.ID[:], encodeLink(existing))
+		}
+
+		stored = &graph.Link{
+			ID:          uuid.New(),
+			URL:         link.URL,
+			RetrievedAt: link.RetrievedAt,
+			NextFetchAt: link.NextFetchAt,
+			ContentHash: link.ContentHash,
+			Namespace:   g.ns,
+			FirstPassID: link.PassID,
+			PassID:      link.PassID,
+		}
+		if err := urls.Put([]byte(link.URL), stored.ID[:]); err != nil {
+			return err
+		}
+		return links.Put(stored.ID[:], encodeLink(stored))
+	})
+	if err != nil {
+		return fmt.Errorf("upsert link: %w", err)
+	}
+
+	link.ID, link.RetrievedAt, link.NextFetchAt, link.ContentHash, link.FirstPassID, link.Namespace = stored.ID, stored.RetrievedAt, stored.NextFetchAt, stored.ContentHash, stored.FirstPassID, g.ns
+	return nil
+}
+
+// FindLink looks up a link by its ID.
+func (g *BoltGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
+	var link *graph.Link
+	err := g.db.View(func(tx *bbolt.Tx) error {
+		val := g.bucket(tx, linksBucket).Get(id[:])
+		if val == nil {
+			return graph.ErrNotFound
+		}
+		var err error
+		link, err = decodeLink(id[:], val, g.ns)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find link: %w", err)
+	}
+
+	return link, nil
+}
+
+// FindLinks looks up the links with the specified IDs within a single read
+// transaction. The returned slice is aligned with ids; missing links are
+// reported via a *graph.MissingLinksError.
+func (g *BoltGraph) FindLinks(ids []uuid.UUID) ([]*graph.Link, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	found := make(map[uuid.UUID]*graph.Link, len(ids))
+	err := g.db.View(func(tx *bbolt.Tx) error {
+		links := g.bucket(tx, linksBucket)
+		for _, id := range ids {
+			val := links.Get(id[:])
+			if val == nil {
+				continue
+			}
+			link, err := decodeLink(id[:], val, g.ns)
+			if err != nil {
+				return err
+			}
+			found[id] = link
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+
+	links, err := graph.ArrangeLinks(ids, found)
+	if err != nil {
+		return links, fmt.Errorf("find links: %w", err)
+	}
+	return links, nil
+}
+
+// RemoveLink removes the link with the specified ID from the graph together
+// with all edges that originate from or point to it.
+func (g *BoltGraph) RemoveLink(id uuid.UUID) error {
+	err := g.db.Update(func(tx *bbolt.Tx) error {
+		links := g.bucket(tx, linksBucket)
+		val := links.Get(id[:])
+		if val == nil {
+			return graph.ErrNotFound
+		}
+		link, err := decodeLink(id[:], val, g.ns)
+		if err != nil {
+			return err
+		}
+		if err = g.bucket(tx, urlsBucket).Delete([]byte(link.URL)); err != nil {
+			return err
+		}
+		if err = links.Delete(id[:]); err != nil {
+			return err
+		}
+		if err = g.bucket(tx, failuresBucket).Delete(id[:]); err != nil {
+			return err
+		}
+
+		edges, inEdges := g.bucket(tx, edgesBucket), g.bucket(tx, inEdgesBucket)
+		// Outgoing edges.
+		if err = deletePrefix(edges, id[:], func(key []byte) error {
+			return inEdges.Delete(edgeKey(uuidFrom(key[idLen:]), id))
+		}); err != nil {
+			return err
+		}
+		// Incoming edges.
+		return deletePrefix(inEdges, id[:], func(key []byte) error {
+			return edges.Delete(edgeKey(uuidFrom(key[idLen:]), id))
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	}
+
+	g.logger.Debug("removed link", logging.Link(id, ""))
+	return nil
+}
+
+// Links returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and are due to be fetched before the provided value.
+func (g *BoltGraph) Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error) {
+	return &linkIterator{
+		kvIterator: g.newRangeIterator(linksBucket, fromID, toID),
+		accept:     func(link *graph.Link) bool { return link.NextFetchAt < dueBefore },
+	}, nil
+}
+
+// UpsertEdge creates a new edge or updates an existing edge.
+func (g *BoltGraph) UpsertEdge(edge *graph.Edge) error {
+	defer metrics.ObserveSince(upsertEdgeDuration, time.Now())
+
+	var stored *graph.Edge
+	err := g.db.Batch(func(tx *bbolt.Tx) error {
+		links := g.bucket(tx, linksBucket)
+		if links.Get(edge.Src[:]) == nil || links.Get(edge.Dst[:]) == nil {
+			return graph.ErrUnknownEdgeLinks
+		}
+
+		edges := g.bucket(tx, edgesBucket)
+		key := edgeKey(edge.Src, edge.Dst)
+		if val := edges.Get(key); val != nil {
+			existing, err := decodeEdge(key, val, g.ns)
+			if err != nil {
+				return err
+			}
+			existing.UpdatedAt = time.Now().Unix()
+			existing.RelType = edge.RelType
+			existing.AnchorText = edge.AnchorText
+			existing.NoFollow = edge.NoFollow
+			if edge.PassID > existing.PassID {
+				existing.PassID = edge.PassID
+			}
+			stored = existing
+			return edges.Put(key, encodeEdge(existing))
+		}
+
+		stored = &graph.Edge{
+			ID:          uuid.New(),
+			Src:         edge.Src,
+			Dst:         edge.Dst,
+			UpdatedAt:   time.Now().Unix(),
+			Namespace:   g.ns,
+			FirstPassID: edge.PassID,
+			PassID:      edge.PassID,
+			RelType:     edge.RelType,
+			AnchorText:  edge.AnchorText,
+			NoFollow:    edge.NoFollow,
+		}
+		if err := g.bucket(tx, inEdgesBucket).Put(edgeKey(edge.Dst, edge.Src), nil); err != nil {
+			return err
+		}
+		return edges.Put(key, encodeEdge(stored))
+	})
+	if err != nil {
+		return fmt.Errorf("upsert edge: %w", err)
+	}
+
+	edge.ID, edge.UpdatedAt, edge.FirstPassID, edge.PassID, edge.Namespace = stored.ID, stored.UpdatedAt, stored.FirstPassID, stored.PassID, g.ns
+	return nil
+}
+
+// Edges returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were last updated before the provided
+// value.
+func (g *BoltGraph) Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error) {
+	return &edgeIterator{
+		sources: []edgeSource{{
+			kvIterator: g.newRangeIterator(edgesBucket, fromID, toID),
+			accept:     func(edge *graph.Edge, _ uint64) bool { return edge.UpdatedAt < updatedBefore },
+		}},
+	}, nil
+}
+
+// EdgesGroupedByDst returns an iterator for the set of edges whose destination
+// vertex IDs belong to the [fromID, toID) range grouped by destination. The
+// in-edges bucket is scanned in key order and each entry is resolved to the
+// edge it refers to within the same read transaction.
+func (g *BoltGraph) EdgesGroupedByDst(fromID, toID uuid.UUID) (graph.EdgeGroupIterator, error) {
+	inEdges := g.newRangeIterator(inEdgesBucket, fromID, toID)
+	inEdges.resolve = func(tx *bbolt.Tx, rec kv) (kv, bool) {
+		key := edgeKey(uuidFrom(rec.key[idLen:]), uuidFrom(rec.key[:idLen]))
+		val := g.bucket(tx, edgesBucket).Get(key)
+		if val == nil {
+			return kv{}, false
+		}
+		return kv{key: key, val: append([]byte(nil), val...)}, true
+	}
+
+	return graph.GroupEdgesByDst(&edgeIterator{
+		sources: []edgeSource{{
+			kvIterator: inEdges,
+			accept:     func(*graph.Edge, uint64) bool { return true },
+		}},
+	}), nil
+}
+
+// RemoveStaleEdges removes any edge that originates from the specified link ID
+// and was updated before the specified timestamp. Edge removals are
+// attributed to the pass that last crawled the source link and recorded so
+// they can be reported by Diff.
+func (g *BoltGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error {
+	err := g.db.Update(func(tx *bbolt.Tx) error {
+		var removedPassID uint64
+		if val := g.bucket(tx, linksBucket).Get(fromID[:]); val != nil {
+			src, err := decodeLink(fromID[:], val, g.ns)
+			if err != nil {
+				return err
+			}
+			removedPassID = src.PassID
+		}
+
+		var (
+			edges    = g.bucket(tx, edgesBucket)
+			inEdges  = g.bucket(tx, inEdgesBucket)
+			removals = g.bucket(tx, edgeRemovalsBucket)
+			stale    []*graph.Edge
+		)
+		c := edges.Cursor()
+		for k, v := c.Seek(fromID[:]); k != nil && bytes.HasPrefix(k, fromID[:]); k, v = c.Next() {
+			edge, err := decodeEdge(k, v, g.ns)
+			if err != nil {
+				return err
+			}
+			if edge.UpdatedAt < updatedBefore {
+				stale = append(stale, edge)
+			}
+		}
+
+		for _, edge := range stale {
+			if err := edges.Delete(edgeKey(edge.Src, edge.Dst)); err != nil {
+				return err
+			}
+			if err := inEdges.Delete(edgeKey(edge.Dst, edge.Src)); err != nil {
+				return err
+			}
+			if removedPassID == 0 {
+				continue
+			}
+			if err := removals.Put(encodeEdgeRemoval(edge, removedPassID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("remove stale edges: %w", err)
+	}
+
+	return nil
+}
+
+// PruneRemovals discards the records of the edges that were removed by the
+// specified or an earlier crawl pass.
+func (g *BoltGraph) PruneRemovals(passID uint64) error {
+	err := g.db.Update(func(tx *bbolt.Tx) error {
+		removals := g.bucket(tx, edgeRemovalsBucket)
+		var pruned [][]byte
+		err := removals.ForEach(func(key, val []byte) error {
+			_, removedPassID, err := decodeEdgeRemoval(key, val, g.ns)
+			if err != nil {
+				return err
+			}
+			if removedPassID <= passID {
+				pruned = append(pruned, append([]byte(nil), key...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range pruned {
+			if err = removals.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("prune removals: %w", err)
+	}
+	return nil
+}
+
+// Diff returns an iterator for the set of changes that were applied to the
+// graph by the crawl passes in the (passA, passB] range. As the store does
+// not index links and edges by pass, Diff scans all links and edges of the
+// namespace.
+func (g *BoltGraph) Diff(passA, passB uint64) (graph.ChangeIterator, error) {
+	inRange := func(passID uint64) bool { return passID > passA && passID <= passB }
+	return &changeIterator{
+		links: &linkIterator{
+			kvIterator: g.newKVIterator(linksBucket, nil, nil),
+			accept: func(link *graph.Link) bool {
+				return inRange(link.FirstPassID) || inRange(link.PassID)
+			},
+		},
+		edges: &edgeIterator{
+			sources: []edgeSource{
+				{
+					kvIterator: g.newKVIterator(edgesBucket, nil, nil),
+					accept:     func(edge *graph.Edge, _ uint64) bool { return inRange(edge.FirstPassID) },
+				},
+				{
+					kvIterator: g.newKVIterator(edgeRemovalsBucket, nil, nil),
+					accept: func(edge *graph.Edge, removedPassID uint64) bool {
+						// Edges that were both added and removed within
+						// the range are reported as additions as they
+						// existed at some point of the range.
+						return (inRange(removedPassID) && edge.FirstPassID <= passA) ||
+							(inRange(edge.FirstPassID) && removedPassID > passB)
+					},
+				},
+			},
+		},
+		inRange: inRange,
+	}, nil
+}
+
+// LinksAsOf returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and had been added to the graph by the end of the
+// specified crawl pass.
+func (g *BoltGraph) LinksAsOf(fromID, toID uuid.UUID, passID uint64) (graph.LinkIterator, error) {
+	return &linkIterator{
+		kvIterator: g.newRangeIterator(linksBucket, fromID, toID),
+		accept:     func(link *graph.Link) bool { return link.FirstPassID <= passID },
+	}, nil
+}
+
+// EdgesAsOf returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were present in the graph at the end
+// of the specified crawl pass.
+func (g *BoltGraph) EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (graph.EdgeIterator, error) {
+	return &edgeIterator{
+		sources: []edgeSource{
+			{
+				kvIterator: g.newRangeIterator(edgesBucket, fromID, toID),
+				accept:     func(edge *graph.Edge, _ uint64) bool { return edge.FirstPassID <= passID },
+			},
+			{
+				// Edges that were removed after the requested pass.
+				kvIterator: g.newRangeIterator(edgeRemovalsBucket, fromID, toID),
+				accept: func(edge *graph.Edge, removedPassID uint64) bool {
+					return edge.FirstPassID <= passID && removedPassID > passID
+				},
+			},
+		},
+	}, nil
+}
+
+// clearFailure discards the failure record of link if the link has been
+// retrieved since the failure was recorded.
+func (g *BoltGraph) clearFailure(tx *bbolt.Tx, link *graph.Link) error {
+	failures := g.bucket(tx, failuresBucket)
+	val := failures.Get(link.ID[:])
+	if val == nil {
+		return nil
+	}
+	f, err := decodeFailure(link.ID[:], val)
+	if err != nil {
+		return err
+	}
+	if link.RetrievedAt > f.FailedAt {
+		return failures.Delete(link.ID[:])
+	}
+	return nil
+}
+
+// RecordLinkFailure creates or replaces the failure record of a link and
+// reschedules the link accordingly.
+func (g *BoltGraph) RecordLinkFailure(f *graph.LinkFailure) error {
+	err := g.db.Update(func(tx *bbolt.Tx) error {
+		links := g.bucket(tx, linksBucket)
+		val := links.Get(f.LinkID[:])
+		if val == nil {
+			return graph.ErrNotFound
+		}
+		link, err := decodeLink(f.LinkID[:], val, g.ns)
+		if err != nil {
+			return err
+		}
+		link.NextFetchAt = f.DueAt()
+		if err = links.Put(link.ID[:], encodeLink(link)); err != nil {
+			return err
+		}
+
+		f.URL = link.URL
+		if val, err = encodeFailure(f); err != nil {
+			return err
+		}
+		return g.bucket(tx, failuresBucket).Put(link.ID[:], val)
+	})
+	if err != nil {
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	return nil
+}
+
+// LinkFailure returns the failure record of the link with the specified ID.
+func (g *BoltGraph) LinkFailure(id uuid.UUID) (*graph.LinkFailure, error) {
+	var f *graph.LinkFailure
+	err := g.db.View(func(tx *bbolt.Tx) error {
+		val := g.bucket(tx, failuresBucket).Get(id[:])
+		if val == nil {
+			return graph.ErrNotFound
+		}
+		var err error
+		f, err = decodeFailure(id[:], val)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find link failure: %w", err)
+	}
+	return f, nil
+}
+
+// FailedLinks returns an iterator for the failure records of the links whose
+// IDs belong to the [fromID, toID) range.
+func (g *BoltGraph) FailedLinks(fromID, toID uuid.UUID) (graph.LinkFailureIterator, error) {
+	return &failureIterator{kvIterator: g.newRangeIterator(failuresBucket, fromID, toID)}, nil
+}
+
+// SaveCheckpoint creates or replaces the checkpoint for the partition
+// specified by cp.
+func (g *BoltGraph) SaveCheckpoint(cp *graph.Checkpoint) error {
+	saved := *cp
+	saved.UpdatedAt = time.Now().UTC()
+	val, err := encodeCheckpoint(&saved)
+	if err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	err = g.db.Update(func(tx *bbolt.Tx) error {
+		return g.bucket(tx, checkpointsBucket).Put(checkpointKey(cp.Partition), val)
+	})
+	if err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	cp.UpdatedAt = saved.UpdatedAt
+	return nil
+}
+
+// Checkpoint returns the checkpoint for the specified partition.
+func (g *BoltGraph) Checkpoint(partition int) (*graph.Checkpoint, error) {
+	var cp *graph.Checkpoint
+	err := g.db.View(func(tx *bbolt.Tx) error {
+		val := g.bucket(tx, checkpointsBucket).Get(checkpointKey(partition))
+		if val == nil {
+			return graph.ErrNotFound
+		}
+		var err error
+		cp, err = decodeCheckpoint(partition, val)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// Checkpoints returns the checkpoints of all partitions ordered by partition.
+func (g *BoltGraph) Checkpoints() ([]*graph.Checkpoint, error) {
+	var list []*graph.Checkpoint
+	err := g.db.View(func(tx *bbolt.Tx) error {
+		// Checkpoint keys are big-endian partition numbers, so the
+		// bucket is already ordered by partition.
+		return g.bucket(tx, checkpointsBucket).ForEach(func(key, val []byte) error {
+			cp, err := decodeCheckpoint(checkpointPartition(key), val)
+			if err != nil {
+				return err
+			}
+			list = append(list, cp)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checkpoints: %w", err)
+	}
+	return list, nil
+}
+
+// deletePrefix deletes the keys of b that start with prefix after invoking
+// onDelete for each of them.
+func deletePrefix(b *bbolt.Bucket, prefix []byte, onDelete func(key []byte) error) error {
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := onDelete(k); err != nil {
+			return err
+		}
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uuidFrom(b []byte) uuid.UUID {
+	var id uuid.UUID
+	copy(id[:], b)
+	return id
+}
@@ -0,0 +1,253 @@
+package bolt
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graph/graphtest"
+	"webcrawler/namespace"
+
+	"github.com/google/uuid"
+	bbolt "go.etcd.io/bbolt"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(BoltGraphTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type BoltGraphTestSuite struct {
+	graphtest.SuiteBase
+	path string
+	g    *BoltGraph
+}
+
+func (s *BoltGraphTestSuite) SetUpTest(c *gc.C) {
+	s.path = filepath.Join(c.MkDir(), "linkgraph.bolt")
+	g, err := NewBoltGraphWithConfig(s.path, Config{MaxBatchDelay: time.Millisecond})
+	c.Assert(err, gc.IsNil)
+	s.SetGraph(g)
+	s.g = g
+}
+
+func (s *BoltGraphTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.g.Close(), gc.IsNil)
+}
+
+func (s *BoltGraphTestSuite) TestIterationAcrossBatches(c *gc.C) {
+	numLinks := 2*batchSize + 1
+	dst := &graph.Link{URL: "https://example.com"}
+	c.Assert(s.g.UpsertLink(dst), gc.IsNil)
+	for i := 0; i < numLinks; i++ {
+		src := &graph.Link{URL: fmt.Sprintf("https://example.com/%d", i)}
+		c.Assert(s.g.UpsertLink(src), gc.IsNil)
+		c.Assert(s.g.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID}), gc.IsNil)
+	}
+
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	linkIt, err := s.g.Links(uuid.Nil, maxUUID, time.Now().Add(time.Hour).Unix())
+	c.Assert(err, gc.IsNil)
+	seen := make(map[uuid.UUID]bool)
+	for linkIt.Next() {
+		seen[linkIt.Link().ID] = true
+	}
+	c.Assert(linkIt.Error(), gc.IsNil)
+	c.Assert(linkIt.Close(), gc.IsNil)
+	c.Assert(seen, gc.HasLen, numLinks+1)
+
+	// Removing the destination link removes all edges pointing to it.
+	c.Assert(s.g.RemoveLink(dst.ID), gc.IsNil)
+	edgeIt, err := s.g.Edges(uuid.Nil, maxUUID, time.Now().Add(time.Hour).Unix())
+	c.Assert(err, gc.IsNil)
+	c.Assert(edgeIt.Next(), gc.Equals, false)
+	c.Assert(edgeIt.Error(), gc.IsNil)
+	c.Assert(edgeIt.Close(), gc.IsNil)
+}
+
+func (s *BoltGraphTestSuite) TestPersistence(c *gc.C) {
+	link := &graph.Link{URL: "https://example.com", RetrievedAt: time.Now().Unix()}
+	c.Assert(s.g.UpsertLink(link), gc.IsNil)
+	c.Assert(s.g.Close(), gc.IsNil)
+
+	// Reopening the database applies the schema again.
+	g, err := NewBoltGraphWithConfig(s.path, Config{MaxBatchDelay: time.Millisecond})
+	c.Assert(err, gc.IsNil)
+	s.g = g
+
+	found, err := g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(found, gc.DeepEquals, link)
+}
+
+// TestPersistedEdgeUpdateTime verifies that edge update times survive a
+// reopen with their second resolution intact so that the exclusive cutoff
+// of the edge iterators keeps applying to them.
+func (s *BoltGraphTestSuite) TestPersistedEdgeUpdateTime(c *gc.C) {
+	src := &graph.Link{URL: "https://example.com"}
+	dst := &graph.Link{URL: "https://example.com/about"}
+	c.Assert(s.g.UpsertLink(src), gc.IsNil)
+	c.Assert(s.g.UpsertLink(dst), gc.IsNil)
+	edge := &graph.Edge{Src: src.ID, Dst: dst.ID}
+	c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+	c.Assert(s.g.Close(), gc.IsNil)
+
+	g, err := NewBoltGraphWithConfig(s.path, Config{MaxBatchDelay: time.Millisecond})
+	c.Assert(err, gc.IsNil)
+	s.g = g
+
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	it, err := g.Edges(uuid.Nil, maxUUID, edge.UpdatedAt)
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Close(), gc.IsNil)
+
+	it, err = g.Edges(uuid.Nil, maxUUID, edge.UpdatedAt+1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Edge(), gc.DeepEquals, edge)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+}
+
+func (s *BoltGraphTestSuite) TestLegacyEdgeRecords(c *gc.C) {
+	src := &graph.Link{URL: "https://example.com"}
+	dst := &graph.Link{URL: "https://example.com/about"}
+	c.Assert(s.g.UpsertLink(src), gc.IsNil)
+	c.Assert(s.g.UpsertLink(dst), gc.IsNil)
+	edge := &graph.Edge{Src: src.ID, Dst: dst.ID, RelType: graph.RelFrame, AnchorText: "About", NoFollow: true}
+	c.Assert(s.g.UpsertEdge(edge), gc.IsNil)
+
+	specs := []struct {
+		descr      string
+		valLen     int
+		expRelType graph.RelType
+	}{
+		// Edges stored before anchor attributes were tracked lack
+		// them but retain their rel type.
+		{descr: "rel type format", valLen: legacyEdgeValueLen + 1, expRelType: graph.RelFrame},
+		// Edges stored before rel types were tracked are decoded as
+		// hyperlinks.
+		{descr: "legacy format", valLen: legacyEdgeValueLen, expRelType: graph.RelHyperlink},
+	}
+	for _, spec := range specs {
+		err := s.g.db.Update(func(tx *bbolt.Tx) error {
+			edges := tx.Bucket([]byte(s.g.ns)).Bucket(edgesBucket)
+			return edges.Put(edgeKey(src.ID, dst.ID), encodeEdge(edge)[:spec.valLen])
+		})
+		c.Assert(err, gc.IsNil)
+
+		it, err := s.g.Edges(uuid.Nil, uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"), time.Now().Add(time.Hour).Unix())
+		c.Assert(err, gc.IsNil)
+		c.Assert(it.Next(), gc.Equals, true)
+		c.Assert(it.Edge().RelType, gc.Equals, spec.expRelType, gc.Commentf(spec.descr))
+		c.Assert(it.Edge().AnchorText, gc.Equals, "", gc.Commentf(spec.descr))
+		c.Assert(it.Edge().NoFollow, gc.Equals, false, gc.Commentf(spec.descr))
+		c.Assert(it.Close(), gc.IsNil)
+	}
+}
+
+func (s *BoltGraphTestSuite) TestLegacyLinkConversion(c *gc.C) {
+	v1 := &graph.Link{URL: "https://example.com", RetrievedAt: time.Now().Unix(), NextFetchAt: time.Now().Add(time.Hour).Unix(), ContentHash: 42, PassID: 3}
+	v2 := &graph.Link{URL: "https://example.com/about", RetrievedAt: time.Now().Unix(), NextFetchAt: time.Now().Add(time.Hour).Unix(), ContentHash: 42, PassID: 3}
+	c.Assert(s.g.UpsertLink(v1), gc.IsNil)
+	c.Assert(s.g.UpsertLink(v2), gc.IsNil)
+
+	// Rewrite the links in the legacy formats.
+	err := s.g.db.Update(func(tx *bbolt.Tx) error {
+		nsBucket := tx.Bucket([]byte(s.g.ns))
+		for _, spec := range []struct {
+			bucket []byte
+			link   *graph.Link
+			strip  func(val []byte) []byte
+		}{
+			{bucket: legacyLinksBucket, link: v1, strip: func(val []byte) []byte { return append(val[:8], val[24:]...) }},
+			{bucket: v2LinksBucket, link: v2, strip: func(val []byte) []byte { return append(val[:16], val[24:]...) }},
+		} {
+			legacy, err := nsBucket.CreateBucket(spec.bucket)
+			if err != nil {
+				return err
+			}
+			if err = legacy.Put(spec.link.ID[:], spec.strip(encodeLink(spec.link))); err != nil {
+				return err
+			}
+			if err = nsBucket.Bucket(linksBucket).Delete(spec.link.ID[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(s.g.Close(), gc.IsNil)
+
+	g, err := NewBoltGraphWithConfig(s.path, Config{MaxBatchDelay: time.Millisecond})
+	c.Assert(err, gc.IsNil)
+	s.g = g
+
+	// Converted links have no content hash and links converted from the
+	// first format are due immediately.
+	found, err := g.FindLink(v1.ID)
+	c.Assert(err, gc.IsNil)
+	v1.NextFetchAt, v1.ContentHash = 0, 0
+	c.Assert(found, gc.DeepEquals, v1)
+	found, err = g.FindLink(v2.ID)
+	c.Assert(err, gc.IsNil)
+	v2.ContentHash = 0
+	c.Assert(found, gc.DeepEquals, v2)
+	err = g.db.View(func(tx *bbolt.Tx) error {
+		c.Assert(tx.Bucket([]byte(g.ns)).Bucket(legacyLinksBucket), gc.IsNil)
+		c.Assert(tx.Bucket([]byte(g.ns)).Bucket(v2LinksBucket), gc.IsNil)
+		return nil
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *BoltGraphTestSuite) TestNamespaceIsolation(c *gc.C) {
+	l1 := &graph.Link{URL: "https://example.com"}
+	c.Assert(s.g.UpsertLink(l1), gc.IsNil)
+	c.Assert(l1.Namespace, gc.Equals, namespace.Default)
+
+	// The database file can only be opened by one graph at a time.
+	c.Assert(s.g.Close(), gc.IsNil)
+	other, err := NewBoltGraphWithConfig(s.path, Config{Namespace: "other-crawl"})
+	c.Assert(err, gc.IsNil)
+	s.g = other
+
+	// Upserting the same URL in another namespace creates a separate link.
+	l2 := &graph.Link{URL: "https://example.com"}
+	c.Assert(other.UpsertLink(l2), gc.IsNil)
+	c.Assert(l2.Namespace, gc.Equals, "other-crawl")
+	c.Assert(l2.ID, gc.Not(gc.Equals), l1.ID)
+
+	_, err = other.FindLink(l1.ID)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	// Edges cannot connect links across namespaces.
+	err = other.UpsertEdge(&graph.Edge{Src: l2.ID, Dst: l1.ID})
+	c.Assert(errors.Is(err, graph.ErrUnknownEdgeLinks), gc.Equals, true)
+
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	it, err := other.Links(uuid.Nil, maxUUID, time.Now().Add(time.Hour).Unix())
+	c.Assert(err, gc.IsNil)
+	var ids []uuid.UUID
+	for it.Next() {
+		ids = append(ids, it.Link().ID)
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(ids, gc.DeepEquals, []uuid.UUID{l2.ID})
+}
+
+func (s *BoltGraphTestSuite) TestInvalidConfig(c *gc.C) {
+	_, err := NewBoltGraphWithConfig(s.path, Config{Namespace: "Not Valid"})
+	c.Assert(err, gc.ErrorMatches, "(?s)bolt graph: config validation failed:.*invalid namespace.*")
+
+	_, err = NewBoltGraphWithConfig(s.path, Config{MaxBatchDelay: -time.Second})
+	c.Assert(err, gc.ErrorMatches, "(?s)bolt graph: config validation failed:.*max batch delay.*")
+
+	_, err = NewBoltGraph("")
+	c.Assert(err, gc.ErrorMatches, "bolt graph: database path has not been specified")
+}
@@ -0,0 +1,315 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+)
+
+// The names of the buckets that are nested in the bucket of each namespace.
+var (
+	// Links keyed by ID. The values hold the retrieval time, the next
+	// fetch time, the content hash, the first and last pass IDs followed
+	// by the URL.
+	linksBucket = []byte("links-v3")
+
+	// Links keyed by ID in the format used before links kept track of
+	// their content hash. The values hold the retrieval time, the next
+	// fetch time, the first and last pass IDs followed by the URL.
+	v2LinksBucket = []byte("links-v2")
+
+	// Links keyed by ID in the format used before links kept track of
+	// their next fetch time. The values hold the retrieval time, the first
+	// and last pass IDs followed by the URL.
+	legacyLinksBucket = []byte("links")
+
+	// Link IDs keyed by URL.
+	urlsBucket = []byte("urls")
+
+	// Edges keyed by the source and destination link IDs so that the
+	// edges of a partition can be scanned in order of their source IDs.
+	// The values hold the edge ID, the update time, the first and last
+	// pass IDs, the rel type and the edge flags followed by the anchor
+	// text. Edges that were stored before rel types were tracked lack the
+	// trailing fields and are decoded as hyperlinks; edges that were stored
+	// before anchor attributes were tracked lack the flags and the anchor
+	// text.
+	edgesBucket = []byte("edges")
+
+	// Empty values keyed by the destination and source link IDs of each
+	// edge; used for removing the edges that point to a removed link.
+	inEdgesBucket = []byte("in-edges")
+
+	// Copies of the edges that were removed by a crawl pass keyed by the
+	// source link and edge IDs. The values hold the destination link ID,
+	// the update time, the first and last pass IDs, the ID of the pass
+	// that removed the edge and, in the format of the edges bucket, the
+	// (optional) rel type, flags and anchor text.
+	edgeRemovalsBucket = []byte("edge-removals")
+
+	// JSON-encoded checkpoints keyed by partition number.
+	checkpointsBucket = []byte("checkpoints")
+
+	// JSON-encoded link failure records keyed by link ID.
+	failuresBucket = []byte("failures")
+
+	nsBuckets = [][]byte{linksBucket, urlsBucket, edgesBucket, inEdgesBucket, edgeRemovalsBucket, checkpointsBucket, failuresBucket}
+
+	// The buckets holding links in a superseded format along with their
+	// decoders. Their links are converted when a namespace is opened.
+	legacyLinkFormats = []struct {
+		bucket []byte
+		decode func(key, val []byte, ns string) (*graph.Link, error)
+	}{
+		{bucket: v2LinksBucket, decode: decodeV2Link},
+		{bucket: legacyLinksBucket, decode: decodeLegacyLink},
+	}
+)
+
+const (
+	idLen                 = len(uuid.UUID{})
+	linkHeaderLen         = 5 * 8
+	v2LinkHeaderLen       = 4 * 8
+	legacyLinkHeaderLen   = 3 * 8
+	legacyEdgeValueLen    = idLen + 3*8
+	edgeHeaderLen         = legacyEdgeValueLen + 2
+	legacyRemovalValueLen = idLen + 4*8
+	removalHeaderLen      = legacyRemovalValueLen + 2
+)
+
+// The bits of the edge flags.
+const edgeFlagNoFollow = 1 << 0
+
+// encodeEdgeAttrs writes the rel type, the flags and the anchor text of edge
+// to buf which must have room for them.
+func encodeEdgeAttrs(buf []byte, edge *graph.Edge) {
+	buf[0] = byte(edge.RelType)
+	if edge.NoFollow {
+		buf[1] |= edgeFlagNoFollow
+	}
+	copy(buf[2:], edge.AnchorText)
+}
+
+// decodeEdgeAttrs populates the rel type, the nofollow flag and the anchor
+// text of edge from buf. Depending on the format of the record, buf is either
+// empty, holds the rel type or holds all attributes.
+func decodeEdgeAttrs(buf []byte, edge *graph.Edge) {
+	if len(buf) >= 1 {
+		edge.RelType = graph.RelType(buf[0])
+	}
+	if len(buf) >= 2 {
+		edge.NoFollow = buf[1]&edgeFlagNoFollow != 0
+		edge.AnchorText = string(buf[2:])
+	}
+}
+
+func encodeLink(link *graph.Link) []byte {
+	buf := make([]byte, linkHeaderLen+len(link.URL))
+	binary.BigEndian.PutUint64(buf[0:], uint64(link.RetrievedAt))
+	binary.BigEndian.PutUint64(buf[8:], uint64(link.NextFetchAt))
+	binary.BigEndian.PutUint64(buf[16:], link.ContentHash)
+	binary.BigEndian.PutUint64(buf[24:], link.FirstPassID)
+	binary.BigEndian.PutUint64(buf[32:], link.PassID)
+	copy(buf[linkHeaderLen:], link.URL)
+	return buf
+}
+
+func decodeLink(key, val []byte, ns string) (*graph.Link, error) {
+	if len(key) != idLen || len(val) < linkHeaderLen {
+		return nil, fmt.Errorf("malformed link record")
+	}
+	link := &graph.Link{
+		RetrievedAt: int64(binary.BigEndian.Uint64(val[0:])),
+		NextFetchAt: int64(binary.BigEndian.Uint64(val[8:])),
+		ContentHash: binary.BigEndian.Uint64(val[16:]),
+		FirstPassID: binary.BigEndian.Uint64(val[24:]),
+		PassID:      binary.BigEndian.Uint64(val[32:]),
+		URL:         string(val[linkHeaderLen:]),
+		Namespace:   ns,
+	}
+	copy(link.ID[:], key)
+	return link, nil
+}
+
+// decodeV2Link decodes a link record from the v2 links bucket. The content
+// hash of v2 links is unknown.
+func decodeV2Link(key, val []byte, ns string) (*graph.Link, error) {
+	if len(key) != idLen || len(val) < v2LinkHeaderLen {
+		return nil, fmt.Errorf("malformed v2 link record")
+	}
+	link := &graph.Link{
+		RetrievedAt: int64(binary.BigEndian.Uint64(val[0:])),
+		NextFetchAt: int64(binary.BigEndian.Uint64(val[8:])),
+		FirstPassID: binary.BigEndian.Uint64(val[16:]),
+		PassID:      binary.BigEndian.Uint64(val[24:]),
+		URL:         string(val[v2LinkHeaderLen:]),
+		Namespace:   ns,
+	}
+	copy(link.ID[:], key)
+	return link, nil
+}
+
+// decodeLegacyLink decodes a link record from the legacy links bucket. The
+// next fetch time of legacy links is zero so they are due immediately.
+func decodeLegacyLink(key, val []byte, ns string) (*graph.Link, error) {
+	if len(key) != idLen || len(val) < legacyLinkHeaderLen {
+		return nil, fmt.Errorf("malformed legacy link record")
+	}
+	link := &graph.Link{
+		RetrievedAt: int64(binary.BigEndian.Uint64(val[0:])),
+		FirstPassID: binary.BigEndian.Uint64(val[8:]),
+		PassID:      binary.BigEndian.Uint64(val[16:]),
+		URL:         string(val[legacyLinkHeaderLen:]),
+		Namespace:   ns,
+	}
+	copy(link.ID[:], key)
+	return link, nil
+}
+
+func edgeKey(src, dst uuid.UUID) []byte {
+	return append(append(make([]byte, 0, 2*idLen), src[:]...), dst[:]...)
+}
+
+func encodeEdge(edge *graph.Edge) []byte {
+	buf := make([]byte, edgeHeaderLen+len(edge.AnchorText))
+	copy(buf, edge.ID[:])
+	binary.BigEndian.PutUint64(buf[idLen:], uint64(edge.UpdatedAt))
+	binary.BigEndian.PutUint64(buf[idLen+8:], edge.FirstPassID)
+	binary.BigEndian.PutUint64(buf[idLen+16:], edge.PassID)
+	encodeEdgeAttrs(buf[legacyEdgeValueLen:], edge)
+	return buf
+}
+
+func decodeEdge(key, val []byte, ns string) (*graph.Edge, error) {
+	if len(key) != 2*idLen || len(val) < legacyEdgeValueLen {
+		return nil, fmt.Errorf("malformed edge record")
+	}
+	edge := &graph.Edge{
+		UpdatedAt:   int64(binary.BigEndian.Uint64(val[idLen:])),
+		FirstPassID: binary.BigEndian.Uint64(val[idLen+8:]),
+		PassID:      binary.BigEndian.Uint64(val[idLen+16:]),
+		Namespace:   ns,
+	}
+	copy(edge.Src[:], key)
+	copy(edge.Dst[:], key[idLen:])
+	copy(edge.ID[:], val)
+	decodeEdgeAttrs(val[legacyEdgeValueLen:], edge)
+	return edge, nil
+}
+
+func encodeEdgeRemoval(edge *graph.Edge, removedPassID uint64) (key, val []byte) {
+	key = append(append(make([]byte, 0, 2*idLen), edge.Src[:]...), edge.ID[:]...)
+	val = make([]byte, removalHeaderLen+len(edge.AnchorText))
+	copy(val, edge.Dst[:])
+	binary.BigEndian.PutUint64(val[idLen:], uint64(edge.UpdatedAt))
+	binary.BigEndian.PutUint64(val[idLen+8:], edge.FirstPassID)
+	binary.BigEndian.PutUint64(val[idLen+16:], edge.PassID)
+	binary.BigEndian.PutUint64(val[idLen+24:], removedPassID)
+	encodeEdgeAttrs(val[legacyRemovalValueLen:], edge)
+	return key, val
+}
+
+func decodeEdgeRemoval(key, val []byte, ns string) (*graph.Edge, uint64, error) {
+	if len(key) != 2*idLen || len(val) < legacyRemovalValueLen {
+		return nil, 0, fmt.Errorf("malformed edge removal record")
+	}
+	edge := &graph.Edge{
+		UpdatedAt:   int64(binary.BigEndian.Uint64(val[idLen:])),
+		FirstPassID: binary.BigEndian.Uint64(val[idLen+8:]),
+		PassID:      binary.BigEndian.Uint64(val[idLen+16:]),
+		Namespace:   ns,
+	}
+	copy(edge.Src[:], key)
+	copy(edge.ID[:], key[idLen:])
+	copy(edge.Dst[:], val)
+	decodeEdgeAttrs(val[legacyRemovalValueLen:], edge)
+	return edge, binary.BigEndian.Uint64(val[idLen+24:]), nil
+}
+
+func checkpointKey(partition int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(partition))
+}
+
+func checkpointPartition(key []byte) int {
+	return int(binary.BigEndian.Uint64(key))
+}
+
+type checkpointRecord struct {
+	PassID        uint64     `json:"passID"`
+	PassStartedAt time.Time  `json:"passStartedAt"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+func encodeCheckpoint(cp *graph.Checkpoint) ([]byte, error) {
+	rec := checkpointRecord{
+		PassID:        cp.PassID,
+		PassStartedAt: cp.PassStartedAt.UTC(),
+		UpdatedAt:     cp.UpdatedAt.UTC(),
+	}
+	if !cp.CompletedAt.IsZero() {
+		completedAt := cp.CompletedAt.UTC()
+		rec.CompletedAt = &completedAt
+	}
+	return json.Marshal(rec)
+}
+
+func decodeCheckpoint(partition int, val []byte) (*graph.Checkpoint, error) {
+	var rec checkpointRecord
+	if err := json.Unmarshal(val, &rec); err != nil {
+		return nil, fmt.Errorf("malformed checkpoint record: %w", err)
+	}
+	cp := &graph.Checkpoint{
+		Partition:     partition,
+		PassID:        rec.PassID,
+		PassStartedAt: rec.PassStartedAt,
+		UpdatedAt:     rec.UpdatedAt,
+	}
+	if rec.CompletedAt != nil {
+		cp.CompletedAt = *rec.CompletedAt
+	}
+	return cp, nil
+}
+
+type failureRecord struct {
+	URL         string `json:"url"`
+	Reason      string `json:"reason"`
+	Attempts    int    `json:"attempts"`
+	FailedAt    int64  `json:"failedAt"`
+	NextRetryAt int64  `json:"nextRetryAt"`
+	Quarantined bool   `json:"quarantined,omitempty"`
+}
+
+func encodeFailure(f *graph.LinkFailure) ([]byte, error) {
+	return json.Marshal(failureRecord{
+		URL:         f.URL,
+		Reason:      f.Reason,
+		Attempts:    f.Attempts,
+		FailedAt:    f.FailedAt,
+		NextRetryAt: f.NextRetryAt,
+		Quarantined: f.Quarantined,
+	})
+}
+
+func decodeFailure(key, val []byte) (*graph.LinkFailure, error) {
+	var rec failureRecord
+	if len(key) != idLen {
+		return nil, fmt.Errorf("malformed link failure record")
+	} else if err := json.Unmarshal(val, &rec); err != nil {
+		return nil, fmt.Errorf("malformed link failure record: %w", err)
+	}
+	f := &graph.LinkFailure{
+		URL:         rec.URL,
+		Reason:      rec.Reason,
+		Attempts:    rec.Attempts,
+		FailedAt:    rec.FailedAt,
+		NextRetryAt: rec.NextRetryAt,
+		Quarantined: rec.Quarantined,
+	}
+	copy(f.LinkID[:], key)
+	return f, nil
+}
@@ -0,0 +1,305 @@
+package bolt
+
+import (
+	"bytes"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// The number of records that are read by each read transaction of an
+// iterator.
+const batchSize = 256
+
+// kv is a key-value pair that was copied out of a read transaction.
+type kv struct {
+	key, val []byte
+}
+
+// kvIterator scans the keys of a namespace bucket that belong to a range. The
+// records are read in batches, each in its own read transaction, so that
+// slow consumers do not keep a transaction open for the duration of the
+// iteration; long-running read transactions prevent bbolt from reusing the
+// pages freed by writers. As a result, the iterator observes the changes that
+// are committed while it is in progress.
+type kvIterator struct {
+	g      *BoltGraph
+	bucket []byte
+
+	// The scan resumes at seek; the key equal to seek is skipped if
+	// skipSeek is set. The scan stops at the first key whose prefix is
+	// greater than or equal to end; a nil end scans until the end of the
+	// bucket.
+	seek     []byte
+	skipSeek bool
+	end      []byte
+
+	// An optional function that replaces each scanned record with a
+	// record read by the same transaction, e.g. to look up the records
+	// referenced by an index bucket. Records for which it returns false
+	// are skipped. The returned record must not reference memory owned by
+	// the transaction.
+	resolve func(tx *bbolt.Tx, rec kv) (kv, bool)
+
+	batch   []kv
+	idx     int
+	done    bool
+	lastErr error
+}
+
+func (g *BoltGraph) newKVIterator(bucket []byte, from, to []byte) *kvIterator {
+	return &kvIterator{g: g, bucket: bucket, seek: from, end: to}
+}
+
+// newRangeIterator returns an iterator for the records of bucket whose keys
+// start with an ID in the [fromID, toID) range.
+func (g *BoltGraph) newRangeIterator(bucket []byte, fromID, toID uuid.UUID) *kvIterator {
+	return g.newKVIterator(bucket, append([]byte(nil), fromID[:]...), append([]byte(nil), toID[:]...))
+}
+
+// next returns the next record or false if the scan is complete or failed.
+func (it *kvIterator) next() (kv, bool) {
+	for it.idx >= len(it.batch) {
+		if it.done || it.lastErr != nil {
+			return kv{}, false
+		}
+		it.lastErr = it.g.db.View(it.fetchBatch)
+	}
+	rec := it.batch[it.idx]
+	it.idx++
+	return rec, true
+}
+
+func (it *kvIterator) fetchBatch(tx *bbolt.Tx) error {
+	it.batch, it.idx = it.batch[:0], 0
+
+	c := it.g.bucket(tx, it.bucket).Cursor()
+	k, v := c.First()
+	if it.seek != nil {
+		k, v = c.Seek(it.seek)
+	}
+	if it.skipSeek && k != nil && bytes.Equal(k, it.seek) {
+		k, v = c.Next()
+	}
+	var (
+		scanned int
+		lastKey []byte
+	)
+	for ; k != nil && scanned < batchSize; k, v = c.Next() {
+		if it.end != nil && bytes.Compare(k[:min(len(k), len(it.end))], it.end) >= 0 {
+			break
+		}
+		// Keys and values are only valid for the lifetime of the
+		// transaction.
+		rec := kv{key: append([]byte(nil), k...), val: append([]byte(nil), v...)}
+		scanned, lastKey = scanned+1, rec.key
+		if it.resolve != nil {
+			var found bool
+			if rec, found = it.resolve(tx, rec); !found {
+				continue
+			}
+		}
+		it.batch = append(it.batch, rec)
+	}
+
+	if scanned < batchSize {
+		it.done = true
+	} else {
+		it.seek, it.skipSeek = lastKey, true
+	}
+	return nil
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *kvIterator) Error() error {
+	return it.lastErr
+}
+
+// Close releases any resources associated with the iterator.
+func (it *kvIterator) Close() error {
+	it.batch, it.idx, it.done = nil, 0, true
+	return nil
+}
+
+// linkIterator is a graph.LinkIterator implementation for the bolt graph.
+type linkIterator struct {
+	*kvIterator
+	accept      func(*graph.Link) bool
+	latchedLink *graph.Link
+}
+
+// Next implements graph.LinkIterator.
+func (i *linkIterator) Next() bool {
+	for {
+		rec, ok := i.next()
+		if !ok {
+			return false
+		}
+		link, err := decodeLink(rec.key, rec.val, i.g.ns)
+		if err != nil {
+			i.lastErr = err
+			return false
+		}
+		if i.accept(link) {
+			i.latchedLink = link
+			return true
+		}
+	}
+}
+
+// Link implements graph.LinkIterator.
+func (i *linkIterator) Link() *graph.Link {
+	return i.latchedLink
+}
+
+// failureIterator is a graph.LinkFailureIterator implementation for the bolt
+// graph.
+type failureIterator struct {
+	*kvIterator
+	latchedFailure *graph.LinkFailure
+}
+
+// Next implements graph.LinkFailureIterator.
+func (i *failureIterator) Next() bool {
+	rec, ok := i.next()
+	if !ok {
+		return false
+	}
+	if i.latchedFailure, i.lastErr = decodeFailure(rec.key, rec.val); i.lastErr != nil {
+		return false
+	}
+	return true
+}
+
+// Failure implements graph.LinkFailureIterator.
+func (i *failureIterator) Failure() *graph.LinkFailure {
+	return i.latchedFailure
+}
+
+// edgeSource describes one of the buckets that an edgeIterator reads edges
+// from: either the edges or the edge removals bucket.
+type edgeSource struct {
+	*kvIterator
+
+	// accept is invoked with each edge and, for edge removals, the ID of
+	// the pass that removed it.
+	accept func(edge *graph.Edge, removedPassID uint64) bool
+}
+
+// edgeIterator is a graph.EdgeIterator implementation for the bolt graph. It
+// returns the accepted edges of each of its sources in turn.
+type edgeIterator struct {
+	sources   []edgeSource
+	sourceIdx int
+	lastErr   error
+
+	latchedEdge          *graph.Edge
+	latchedRemovedPassID uint64
+}
+
+// Next implements graph.EdgeIterator.
+func (i *edgeIterator) Next() bool {
+	for i.lastErr == nil && i.sourceIdx < len(i.sources) {
+		src := i.sources[i.sourceIdx]
+		rec, ok := src.next()
+		if !ok {
+			if i.lastErr = src.Error(); i.lastErr != nil {
+				return false
+			}
+			i.sourceIdx++
+			continue
+		}
+
+		var (
+			edge          *graph.Edge
+			removedPassID uint64
+		)
+		if bytes.Equal(src.bucket, edgeRemovalsBucket) {
+			edge, removedPassID, i.lastErr = decodeEdgeRemoval(rec.key, rec.val, src.g.ns)
+		} else {
+			edge, i.lastErr = decodeEdge(rec.key, rec.val, src.g.ns)
+		}
+		if i.lastErr != nil {
+			return false
+		}
+		if src.accept(edge, removedPassID) {
+			i.latchedEdge, i.latchedRemovedPassID = edge, removedPassID
+			return true
+		}
+	}
+	return false
+}
+
+// Edge implements graph.EdgeIterator.
+func (i *edgeIterator) Edge() *graph.Edge {
+	return i.latchedEdge
+}
+
+// Error implements graph.EdgeIterator.
+func (i *edgeIterator) Error() error {
+	return i.lastErr
+}
+
+// Close implements graph.EdgeIterator.
+func (i *edgeIterator) Close() error {
+	for _, src := range i.sources {
+		_ = src.Close()
+	}
+	i.sourceIdx = len(i.sources)
+	return nil
+}
+
+// changeIterator is a graph.ChangeIterator implementation for the bolt graph.
+// It first returns the link changes and then the edge changes.
+type changeIterator struct {
+	links   *linkIterator
+	edges   *edgeIterator
+	inRange func(passID uint64) bool
+
+	latchedChange *graph.Change
+}
+
+// Next implements graph.ChangeIterator.
+func (i *changeIterator) Next() bool {
+	if i.links.Error() == nil && i.links.Next() {
+		link := i.links.Link()
+		changeType := graph.ChangeLinkUpdated
+		if i.inRange(link.FirstPassID) {
+			changeType = graph.ChangeLinkAdded
+		}
+		i.latchedChange = &graph.Change{Type: changeType, Link: link}
+		return true
+	} else if i.links.Error() != nil || !i.edges.Next() {
+		return false
+	}
+
+	// Removals of edges that were added within the range are reported as
+	// additions.
+	edge := i.edges.Edge()
+	changeType := graph.ChangeEdgeAdded
+	if i.edges.latchedRemovedPassID != 0 && !i.inRange(edge.FirstPassID) {
+		changeType = graph.ChangeEdgeRemoved
+	}
+	i.latchedChange = &graph.Change{Type: changeType, Edge: edge}
+	return true
+}
+
+// Change implements graph.ChangeIterator.
+func (i *changeIterator) Change() *graph.Change {
+	return i.latchedChange
+}
+
+// Error implements graph.ChangeIterator.
+func (i *changeIterator) Error() error {
+	if err := i.links.Error(); err != nil {
+		return err
+	}
+	return i.edges.Error()
+}
+
+// Close implements graph.ChangeIterator.
+func (i *changeIterator) Close() error {
+	_ = i.links.Close()
+	return i.edges.Close()
+}
@@ -1,51 +1,199 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"webcrawler/crawler/linkgraph/graph"
-
+	"database/sql/driver"
 	"fmt"
+	"log/slog"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+	"webcrawler/namespace"
 
 	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
 	"github.com/lib/pq"
 )
 
 var (
+	// All queries are scoped to the namespace of the DBGraph instance
+	// which is passed as the last argument.
 	upsertLinkQuery = `
-INSERT INTO links (url, retrieved_at) VALUES ($1, $2) 
-ON CONFLICT (url) DO UPDATE SET retrieved_at=GREATEST(links.retrieved_at, $2)
-RETURNING id, retrieved_at
+INSERT INTO links (url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id, namespace) VALUES ($1, $2, $3, $6, $4, $4, $5) 
+ON CONFLICT (namespace, url) DO UPDATE SET
+	next_fetch_at=CASE
+		WHEN $2 > links.retrieved_at THEN $3
+		WHEN $2 < links.retrieved_at THEN links.next_fetch_at
+		ELSE GREATEST(links.next_fetch_at, $3)
+	END,
+	content_hash=CASE
+		WHEN $2 > links.retrieved_at THEN $6
+		WHEN $2 = links.retrieved_at AND links.content_hash = 0 THEN $6
+		ELSE links.content_hash
+	END,
+	retrieved_at=GREATEST(links.retrieved_at, $2), pass_id=GREATEST(links.pass_id, $4)
+RETURNING id, retrieved_at, next_fetch_at, content_hash, first_pass_id
 `
-	findLinkQuery         = "SELECT url, retrieved_at FROM links WHERE id=$1"
-	linksInPartitionQuery = "SELECT id, url, retrieved_at FROM links WHERE id >= $1 AND id < $2 AND retrieved_at < $3"
+	removeLinkQuery       = "DELETE FROM links WHERE id=$1 AND namespace=$2"
+	findLinkQuery         = "SELECT url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id FROM links WHERE id=$1 AND namespace=$2"
+	findLinksQuery        = "SELECT id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id FROM links WHERE id = ANY($1::UUID[]) AND namespace=$2"
+	linksInPartitionQuery = "SELECT id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id FROM links WHERE id >= $1 AND id < $2 AND next_fetch_at < $3 AND namespace=$4"
 
+	// Edges may only connect links that belong to the namespace of the
+	// edge; no row is returned if either link is not part of it.
 	upsertEdgeQuery = `
-INSERT INTO edges (src, dst, updated_at) VALUES ($1, $2, NOW())
-ON CONFLICT (src,dst) DO UPDATE SET updated_at=NOW()
-RETURNING id, updated_at
+INSERT INTO edges (src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow, namespace)
+SELECT $1, $2, NOW(), $3, $3, $4, $5, $6, $7
+WHERE EXISTS (SELECT 1 FROM links WHERE id=$1 AND namespace=$7) AND EXISTS (SELECT 1 FROM links WHERE id=$2 AND namespace=$7)
+ON CONFLICT (src,dst) DO UPDATE SET updated_at=NOW(), pass_id=GREATEST(edges.pass_id, $3), rel_type=$4, anchor_text=$5, no_follow=$6
+RETURNING id, updated_at, first_pass_id, pass_id
+`
+	edgesInPartitionQuery = "SELECT id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow FROM edges WHERE src >= $1 AND src < $2 AND updated_at < $3 AND namespace=$4"
+	edgesByDstQuery       = "SELECT id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow FROM edges WHERE dst >= $1 AND dst < $2 AND namespace=$3 ORDER BY dst, src"
+
+	// Edge removals are attributed to the pass that last crawled the source
+	// link and recorded so they can be reported by Diff.
+	removeStaleEdgesQuery = `
+WITH removed AS (
+	DELETE FROM edges WHERE src=$1 AND updated_at < $2 AND namespace=$3
+	RETURNING id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+)
+INSERT INTO edge_removals (id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow, removed_pass_id, namespace)
+SELECT removed.id, removed.src, removed.dst, removed.updated_at, removed.first_pass_id, removed.pass_id, removed.rel_type, removed.anchor_text, removed.no_follow, links.pass_id, $3
+FROM removed JOIN links ON links.id = removed.src
+WHERE links.pass_id > 0
+`
+
+	linkChangesQuery = `
+SELECT CASE WHEN first_pass_id > $1 THEN 0 ELSE 1 END, id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id
+FROM links
+WHERE ((first_pass_id > $1 AND first_pass_id <= $2) OR (pass_id > $1 AND pass_id <= $2)) AND namespace=$3
+`
+	edgeChangesQuery = `
+SELECT 2, id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+FROM edges
+WHERE first_pass_id > $1 AND first_pass_id <= $2 AND namespace=$3
+UNION ALL
+SELECT CASE WHEN first_pass_id <= $1 THEN 3 ELSE 2 END, id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+FROM edge_removals
+WHERE ((removed_pass_id > $1 AND removed_pass_id <= $2 AND first_pass_id <= $1)
+   OR (first_pass_id > $1 AND first_pass_id <= $2 AND removed_pass_id > $2)) AND namespace=$3
+`
+
+	linksAsOfQuery = "SELECT id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id FROM links WHERE id >= $1 AND id < $2 AND first_pass_id <= $3 AND namespace=$4"
+
+	// Edges that were removed after the requested pass are read back from
+	// the edge_removals table. Both sets are fetched by the same statement
+	// so they are read from the same snapshot.
+	edgesAsOfQuery = `
+SELECT id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+FROM edges
+WHERE src >= $1 AND src < $2 AND first_pass_id <= $3 AND namespace=$4
+UNION ALL
+SELECT id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+FROM edge_removals
+WHERE src >= $1 AND src < $2 AND first_pass_id <= $3 AND removed_pass_id > $3 AND namespace=$4
+`
+	pruneRemovalsQuery = "DELETE FROM edge_removals WHERE removed_pass_id <= $1 AND namespace=$2"
+
+	// Failure records are kept until they are replaced or their link is
+	// removed; records whose link has been retrieved after the failure are
+	// filtered out by the queries.
+	rescheduleFailedLinkQuery = "UPDATE links SET next_fetch_at=$2 WHERE id=$1 AND namespace=$3 RETURNING url"
+	upsertLinkFailureQuery    = `
+INSERT INTO link_failures (link_id, reason, attempts, failed_at, next_retry_at, quarantined, namespace) VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (link_id) DO UPDATE SET reason=$2, attempts=$3, failed_at=$4, next_retry_at=$5, quarantined=$6
+`
+	findLinkFailureQuery = `
+SELECT f.link_id, l.url, f.reason, f.attempts, f.failed_at, f.next_retry_at, f.quarantined
+FROM link_failures f JOIN links l ON l.id = f.link_id
+WHERE f.link_id=$1 AND f.namespace=$2 AND f.failed_at >= l.retrieved_at
+`
+	failedLinksQuery = `
+SELECT f.link_id, l.url, f.reason, f.attempts, f.failed_at, f.next_retry_at, f.quarantined
+FROM link_failures f JOIN links l ON l.id = f.link_id
+WHERE f.link_id >= $1 AND f.link_id < $2 AND f.namespace=$3 AND f.failed_at >= l.retrieved_at
 `
-	edgesInPartitionQuery = "SELECT id, src, dst, updated_at FROM edges WHERE src >= $1 AND src < $2 AND updated_at < $3"
-	removeStaleEdgesQuery = "DELETE FROM edges WHERE src=$1 AND updated_at < $2"
 
-	// Compile-time check for ensuring DBGraph implements Graph.
-	_ graph.Graph = (*DBGraph)(nil)
+	saveCheckpointQuery = `
+INSERT INTO checkpoints (partition, pass_id, pass_started_at, completed_at, updated_at, namespace) VALUES ($1, $2, $3, $4, NOW(), $5)
+ON CONFLICT (namespace, partition) DO UPDATE SET pass_id=$2, pass_started_at=$3, completed_at=$4, updated_at=NOW()
+RETURNING updated_at
+`
+	findCheckpointQuery = "SELECT pass_id, pass_started_at, completed_at, updated_at FROM checkpoints WHERE partition=$1 AND namespace=$2"
+	checkpointsQuery    = "SELECT partition, pass_id, pass_started_at, completed_at, updated_at FROM checkpoints WHERE namespace=$1 ORDER BY partition"
+
+	// Compile-time checks for ensuring DBGraph implements Graph,
+	// CheckpointStore, RemovalPruner, EdgeGrouper and Pinger.
+	_ graph.Graph           = (*DBGraph)(nil)
+	_ graph.CheckpointStore = (*DBGraph)(nil)
+	_ graph.RemovalPruner   = (*DBGraph)(nil)
+	_ graph.EdgeGrouper     = (*DBGraph)(nil)
+	_ graph.Pinger          = (*DBGraph)(nil)
+
+	upsertLinkDuration = metrics.GraphUpsertDuration.WithLabelValues("db", "link")
+	upsertEdgeDuration = metrics.GraphUpsertDuration.WithLabelValues("db", "edge")
 )
 
 // DBGraph implements a graph that persists its links and edges to a
-// db instance.
+// db instance. Each DBGraph is bound to a single namespace; instances bound to
+// different namespaces can share the same db without observing each other's
+// links, edges and checkpoints.
 type DBGraph struct {
-	db *sql.DB
+	db     *sql.DB
+	ns     string
+	logger *slog.Logger
+}
+
+// Config encapsulates the optional settings for a DBGraph.
+type Config struct {
+	// The namespace of the crawl whose data is accessed through the
+	// graph. Defaults to namespace.Default.
+	Namespace string
+
+	// An optional logger for reporting removals. If not specified,
+	// nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if nsErr := namespace.Validate(namespace.OrDefault(cfg.Namespace)); nsErr != nil {
+		err = multierror.Append(err, nsErr)
+	}
+	return err
 }
 
 // NewDBGraph returns a DBGraph instance that connects to the db
 // instance specified by dsn.
 func NewDBGraph(dsn string) (*DBGraph, error) {
+	return NewDBGraphWithConfig(dsn, Config{})
+}
+
+// NewDBGraphWithConfig returns a DBGraph instance that connects to the db
+// instance specified by dsn and uses the settings in cfg.
+func NewDBGraphWithConfig(dsn string, cfg Config) (*DBGraph, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("db graph: config validation failed: %w", err)
+	}
+
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	return &DBGraph{db: db}, nil
+	return &DBGraph{
+		db:     db,
+		ns:     namespace.OrDefault(cfg.Namespace),
+		logger: logging.Component(cfg.Logger, "linkgraph.db"),
+	}, nil
+}
+
+// Namespace returns the namespace that the graph is bound to.
+func (c *DBGraph) Namespace() string {
+	return c.ns
 }
 
 // Close terminates the connection to the backing db instance.
@@ -53,22 +201,31 @@ func (c *DBGraph) Close() error {
 	return c.db.Close()
 }
 
+// Ping verifies that the db instance can be reached.
+func (c *DBGraph) Ping(ctx context.Context) error {
+	if err := c.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	return nil
+}
+
 // UpsertLink creates a new link or updates an existing link.
 func (c *DBGraph) UpsertLink(link *graph.Link) error {
-	row := c.db.QueryRow(upsertLinkQuery, link.URL, link.RetrievedAt)
-	if err := row.Scan(&link.ID, &link.RetrievedAt); err != nil {
+	defer metrics.ObserveSince(upsertLinkDuration, time.Now())
+	row := c.db.QueryRow(upsertLinkQuery, link.URL, link.RetrievedAt, link.NextFetchAt, link.PassID, c.ns, contentHash(link.ContentHash))
+	if err := row.Scan(&link.ID, &link.RetrievedAt, &link.NextFetchAt, (*contentHash)(&link.ContentHash), &link.FirstPassID); err != nil {
 		return fmt.Errorf("upsert link: %w", err)
 	}
+	link.Namespace = c.ns
 
-	link.RetrievedAt = link.RetrievedAt
 	return nil
 }
 
 // FindLink looks up a link by its ID.
 func (c *DBGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
-	row := c.db.QueryRow(findLinkQuery, id)
-	link := &graph.Link{ID: id}
-	if err := row.Scan(&link.URL, &link.RetrievedAt); err != nil {
+	row := c.db.QueryRow(findLinkQuery, id, c.ns)
+	link := &graph.Link{ID: id, Namespace: c.ns}
+	if err := row.Scan(&link.URL, &link.RetrievedAt, &link.NextFetchAt, (*contentHash)(&link.ContentHash), &link.FirstPassID, &link.PassID); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("find link: %w", graph.ErrNotFound)
 		}
@@ -76,32 +233,88 @@ func (c *DBGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
 		return nil, fmt.Errorf("find link: %w", err)
 	}
 
-	link.RetrievedAt = link.RetrievedAt
 	return link, nil
 }
 
+// FindLinks looks up the links with the specified IDs using a single query.
+// The returned slice is aligned with ids; missing links are reported via a
+// *graph.MissingLinksError.
+func (c *DBGraph) FindLinks(ids []uuid.UUID) ([]*graph.Link, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	idList := make([]string, len(ids))
+	for i, id := range ids {
+		idList[i] = id.String()
+	}
+	rows, err := c.db.Query(findLinksQuery, pq.Array(idList), c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+
+	it := &linkIterator{rows: rows, ns: c.ns}
+	found := make(map[uuid.UUID]*graph.Link, len(ids))
+	for it.Next() {
+		found[it.Link().ID] = it.Link()
+	}
+	if err = it.Error(); err != nil {
+		_ = it.Close()
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+	if err = it.Close(); err != nil {
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+
+	links, err := graph.ArrangeLinks(ids, found)
+	if err != nil {
+		return links, fmt.Errorf("find links: %w", err)
+	}
+	return links, nil
+}
+
+// RemoveLink removes the link with the specified ID from the graph. Edges
+// that reference the link are removed by the cascading foreign key
+// constraints of the edges table.
+func (c *DBGraph) RemoveLink(id uuid.UUID) error {
+	res, err := c.db.Exec(removeLinkQuery, id, c.ns)
+	if err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	}
+
+	if affected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	} else if affected == 0 {
+		return fmt.Errorf("remove link: %w", graph.ErrNotFound)
+	}
+
+	c.logger.Debug("removed link", logging.Link(id, ""))
+	return nil
+}
+
 // Links returns an iterator for the set of links whose IDs belong to the
-// [fromID, toID) range and were last accessed before the provided value.
-func (c *DBGraph) Links(fromID, toID uuid.UUID, accessedBefore int64) (graph.LinkIterator, error) {
-	rows, err := c.db.Query(linksInPartitionQuery, fromID, toID, accessedBefore)
+// [fromID, toID) range and are due to be fetched before the provided value.
+func (c *DBGraph) Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error) {
+	rows, err := c.db.Query(linksInPartitionQuery, fromID, toID, dueBefore, c.ns)
 	if err != nil {
 		return nil, fmt.Errorf("links: %w", err)
 	}
 
-	return &linkIterator{rows: rows}, nil
+	return &linkIterator{rows: rows, ns: c.ns}, nil
 }
 
 // UpsertEdge creates a new edge or updates an existing edge.
 func (c *DBGraph) UpsertEdge(edge *graph.Edge) error {
-	row := c.db.QueryRow(upsertEdgeQuery, edge.Src, edge.Dst)
-	if err := row.Scan(&edge.ID, &edge.UpdatedAt); err != nil {
-		if isForeignKeyViolationError(err) {
+	defer metrics.ObserveSince(upsertEdgeDuration, time.Now())
+	row := c.db.QueryRow(upsertEdgeQuery, edge.Src, edge.Dst, edge.PassID, edge.RelType, edge.AnchorText, edge.NoFollow, c.ns)
+	if err := row.Scan(&edge.ID, &edge.UpdatedAt, &edge.FirstPassID, &edge.PassID); err != nil {
+		if err == sql.ErrNoRows || isForeignKeyViolationError(err) {
 			err = graph.ErrUnknownEdgeLinks
 		}
 		return fmt.Errorf("upsert edge: %w", err)
 	}
+	edge.Namespace = c.ns
 
-	edge.UpdatedAt = edge.UpdatedAt
 	return nil
 }
 
@@ -109,18 +322,30 @@ func (c *DBGraph) UpsertEdge(edge *graph.Edge) error {
 // belong to the [fromID, toID) range and were last updated before the provided
 // value.
 func (c *DBGraph) Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error) {
-	rows, err := c.db.Query(edgesInPartitionQuery, fromID, toID, updatedBefore)
+	rows, err := c.db.Query(edgesInPartitionQuery, fromID, toID, updatedBefore, c.ns)
 	if err != nil {
 		return nil, fmt.Errorf("edges: %w", err)
 	}
 
-	return &edgeIterator{rows: rows}, nil
+	return &edgeIterator{rows: rows, ns: c.ns}, nil
+}
+
+// EdgesGroupedByDst returns an iterator for the set of edges whose destination
+// vertex IDs belong to the [fromID, toID) range grouped by destination. The
+// rows are streamed from the db in destination order.
+func (c *DBGraph) EdgesGroupedByDst(fromID, toID uuid.UUID) (graph.EdgeGroupIterator, error) {
+	rows, err := c.db.Query(edgesByDstQuery, fromID, toID, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("edges grouped by dst: %w", err)
+	}
+
+	return graph.GroupEdgesByDst(&edgeIterator{rows: rows, ns: c.ns}), nil
 }
 
 // RemoveStaleEdges removes any edge that originates from the specified link ID
 // and was updated before the specified timestamp.
 func (c *DBGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error {
-	_, err := c.db.Exec(removeStaleEdgesQuery, fromID, updatedBefore)
+	_, err := c.db.Exec(removeStaleEdgesQuery, fromID, updatedBefore, c.ns)
 	if err != nil {
 		return fmt.Errorf("remove stale edges: %w", err)
 	}
@@ -128,6 +353,50 @@ func (c *DBGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error
 	return nil
 }
 
+// PruneRemovals discards the records of the edges that were removed by the
+// specified or an earlier crawl pass.
+func (c *DBGraph) PruneRemovals(passID uint64) error {
+	if _, err := c.db.Exec(pruneRemovalsQuery, passID, c.ns); err != nil {
+		return fmt.Errorf("prune removals: %w", err)
+	}
+	return nil
+}
+
+// Diff returns an iterator for the set of changes that were applied to the
+// graph by the crawl passes in the (passA, passB] range.
+func (c *DBGraph) Diff(passA, passB uint64) (graph.ChangeIterator, error) {
+	rows, err := c.db.Query(linkChangesQuery, passA, passB, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+
+	return &changeIterator{db: c.db, ns: c.ns, passA: passA, passB: passB, rows: rows}, nil
+}
+
+// LinksAsOf returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and had been added to the graph by the end of the
+// specified crawl pass.
+func (c *DBGraph) LinksAsOf(fromID, toID uuid.UUID, passID uint64) (graph.LinkIterator, error) {
+	rows, err := c.db.Query(linksAsOfQuery, fromID, toID, passID, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("links as of: %w", err)
+	}
+
+	return &linkIterator{rows: rows, ns: c.ns}, nil
+}
+
+// EdgesAsOf returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were present in the graph at the end
+// of the specified crawl pass.
+func (c *DBGraph) EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (graph.EdgeIterator, error) {
+	rows, err := c.db.Query(edgesAsOfQuery, fromID, toID, passID, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("edges as of: %w", err)
+	}
+
+	return &edgeIterator{rows: rows, ns: c.ns}, nil
+}
+
 // isForeignKeyViolationError returns true if err indicates a foreign key
 // constraint violation.
 func isForeignKeyViolationError(err error) bool {
@@ -138,3 +407,126 @@ func isForeignKeyViolationError(err error) bool {
 
 	return pqErr.Code.Name() == "foreign_key_violation"
 }
+
+// RecordLinkFailure creates or replaces the failure record of a link and
+// reschedules the link accordingly.
+func (c *DBGraph) RecordLinkFailure(f *graph.LinkFailure) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	if err = tx.QueryRow(rescheduleFailedLinkQuery, f.LinkID, f.DueAt(), c.ns).Scan(&f.URL); err != nil {
+		_ = tx.Rollback()
+		if err == sql.ErrNoRows {
+			err = graph.ErrNotFound
+		}
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	if _, err = tx.Exec(upsertLinkFailureQuery, f.LinkID, f.Reason, f.Attempts, f.FailedAt, f.NextRetryAt, f.Quarantined, c.ns); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	return nil
+}
+
+// LinkFailure returns the failure record of the link with the specified ID.
+func (c *DBGraph) LinkFailure(id uuid.UUID) (*graph.LinkFailure, error) {
+	row := c.db.QueryRow(findLinkFailureQuery, id, c.ns)
+	f := new(graph.LinkFailure)
+	if err := row.Scan(&f.LinkID, &f.URL, &f.Reason, &f.Attempts, &f.FailedAt, &f.NextRetryAt, &f.Quarantined); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("find link failure: %w", graph.ErrNotFound)
+		}
+		return nil, fmt.Errorf("find link failure: %w", err)
+	}
+	return f, nil
+}
+
+// FailedLinks returns an iterator for the failure records of the links whose
+// IDs belong to the [fromID, toID) range.
+func (c *DBGraph) FailedLinks(fromID, toID uuid.UUID) (graph.LinkFailureIterator, error) {
+	rows, err := c.db.Query(failedLinksQuery, fromID, toID, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed links: %w", err)
+	}
+
+	return &failureIterator{rows: rows}, nil
+}
+
+// SaveCheckpoint creates or replaces the checkpoint for the partition
+// specified by cp.
+func (c *DBGraph) SaveCheckpoint(cp *graph.Checkpoint) error {
+	completedAt := sql.NullTime{Time: cp.CompletedAt.UTC(), Valid: !cp.CompletedAt.IsZero()}
+	row := c.db.QueryRow(saveCheckpointQuery, cp.Partition, cp.PassID, cp.PassStartedAt.UTC(), completedAt, c.ns)
+	if err := row.Scan(&cp.UpdatedAt); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Checkpoint returns the checkpoint for the specified partition.
+func (c *DBGraph) Checkpoint(partition int) (*graph.Checkpoint, error) {
+	var (
+		cp          = &graph.Checkpoint{Partition: partition}
+		completedAt sql.NullTime
+	)
+	row := c.db.QueryRow(findCheckpointQuery, partition, c.ns)
+	if err := row.Scan(&cp.PassID, &cp.PassStartedAt, &completedAt, &cp.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("find checkpoint: %w", graph.ErrNotFound)
+		}
+		return nil, fmt.Errorf("find checkpoint: %w", err)
+	}
+	if completedAt.Valid {
+		cp.CompletedAt = completedAt.Time
+	}
+	return cp, nil
+}
+
+// Checkpoints returns the checkpoints of all partitions ordered by partition.
+func (c *DBGraph) Checkpoints() ([]*graph.Checkpoint, error) {
+	rows, err := c.db.Query(checkpointsQuery, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoints: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []*graph.Checkpoint
+	for rows.Next() {
+		var (
+			cp          = new(graph.Checkpoint)
+			completedAt sql.NullTime
+		)
+		if err = rows.Scan(&cp.Partition, &cp.PassID, &cp.PassStartedAt, &completedAt, &cp.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("checkpoints: %w", err)
+		}
+		if completedAt.Valid {
+			cp.CompletedAt = completedAt.Time
+		}
+		list = append(list, cp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("checkpoints: %w", err)
+	}
+	return list, nil
+}
+
+// contentHash stores the content hashes of links, which use the full uint64
+// range, in signed integer columns.
+type contentHash uint64
+
+// Value implements driver.Valuer.
+func (h contentHash) Value() (driver.Value, error) { return int64(h), nil }
+
+// Scan implements sql.Scanner.
+func (h *contentHash) Scan(src interface{}) error {
+	v, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("unsupported content hash type %T", src)
+	}
+	*h = contentHash(v)
+	return nil
+}
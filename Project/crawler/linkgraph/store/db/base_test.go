@@ -1,11 +1,18 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"math"
 	"os"
 	"testing"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
 	"webcrawler/crawler/linkgraph/graph/graphtest"
+	"webcrawler/namespace"
 
+	"github.com/google/uuid"
 	gc "gopkg.in/check.v1"
 )
 
@@ -15,6 +22,7 @@ func Test(t *testing.T) { gc.TestingT(t) }
 
 type DbGraphTestSuite struct {
 	graphtest.SuiteBase
+	g  *DBGraph
 	db *sql.DB
 }
 
@@ -27,6 +35,7 @@ func (s *DbGraphTestSuite) SetUpSuite(c *gc.C) {
 	g, err := NewDBGraph(dsn)
 	c.Assert(err, gc.IsNil)
 	s.SetGraph(g)
+	s.g = g
 	s.db = g.db
 }
 
@@ -46,4 +55,104 @@ func (s *DbGraphTestSuite) flushDB(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	_, err = s.db.Exec("DELETE FROM edges")
 	c.Assert(err, gc.IsNil)
+	_, err = s.db.Exec("DELETE FROM checkpoints")
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *DbGraphTestSuite) TestPing(c *gc.C) {
+	c.Assert(s.g.Ping(context.Background()), gc.IsNil)
+}
+
+func (s *DbGraphTestSuite) TestNamespaceIsolation(c *gc.C) {
+	dsn := os.Getenv("CDB_DSN")
+	other, err := NewDBGraphWithConfig(dsn, Config{Namespace: "other-crawl"})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(other.Close(), gc.IsNil) }()
+
+	g := s.g
+	l1 := &graph.Link{URL: "https://example.com"}
+	c.Assert(g.UpsertLink(l1), gc.IsNil)
+	c.Assert(l1.Namespace, gc.Equals, namespace.Default)
+
+	// Upserting the same URL in another namespace creates a separate link.
+	l2 := &graph.Link{URL: "https://example.com"}
+	c.Assert(other.UpsertLink(l2), gc.IsNil)
+	c.Assert(l2.Namespace, gc.Equals, "other-crawl")
+	c.Assert(l2.ID, gc.Not(gc.Equals), l1.ID)
+
+	_, err = other.FindLink(l1.ID)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	// Edges cannot connect links across namespaces.
+	err = other.UpsertEdge(&graph.Edge{Src: l2.ID, Dst: l1.ID})
+	c.Assert(errors.Is(err, graph.ErrUnknownEdgeLinks), gc.Equals, true)
+
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	it, err := other.Links(uuid.Nil, maxUUID, time.Now().Add(time.Hour).Unix())
+	c.Assert(err, gc.IsNil)
+	var ids []uuid.UUID
+	for it.Next() {
+		ids = append(ids, it.Link().ID)
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(ids, gc.DeepEquals, []uuid.UUID{l2.ID})
+}
+
+func (s *DbGraphTestSuite) TestInvalidNamespace(c *gc.C) {
+	_, err := NewDBGraphWithConfig(os.Getenv("CDB_DSN"), Config{Namespace: "Not Valid"})
+	c.Assert(err, gc.ErrorMatches, "(?s)db graph: config validation failed:.*invalid namespace.*")
+}
+
+// TestAsOfPassIDs verifies that the graph can be loaded as of the pass IDs
+// used by the PageRank service: the most recent pass completed by all
+// partitions or, for graphs without checkpoints, the largest pass ID.
+func (s *DbGraphTestSuite) TestAsOfPassIDs(c *gc.C) {
+	g := s.g
+	pass := uint64(math.MaxInt32) + 1
+	src := &graph.Link{URL: "https://example.com/src", PassID: pass}
+	dst := &graph.Link{URL: "https://example.com/dst", PassID: pass}
+	discovered := &graph.Link{URL: "https://example.com/discovered", PassID: pass + 1}
+	for _, link := range []*graph.Link{src, dst, discovered} {
+		c.Assert(g.UpsertLink(link), gc.IsNil)
+	}
+	c.Assert(g.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID, PassID: pass}), gc.IsNil)
+	c.Assert(g.UpsertEdge(&graph.Edge{Src: src.ID, Dst: discovered.ID, PassID: pass + 1}), gc.IsNil)
+
+	now := time.Now()
+	c.Assert(g.SaveCheckpoint(&graph.Checkpoint{Partition: 0, PassID: pass, PassStartedAt: now, CompletedAt: now}), gc.IsNil)
+	c.Assert(g.SaveCheckpoint(&graph.Checkpoint{Partition: 1, PassID: pass + 1, PassStartedAt: now}), gc.IsNil)
+	cps, err := g.Checkpoints()
+	c.Assert(err, gc.IsNil)
+	completed := graph.CompletedPass(cps)
+	c.Assert(completed, gc.Equals, pass)
+
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	for _, spec := range []struct {
+		passID             uint64
+		expLinks, expEdges int
+	}{
+		{passID: completed, expLinks: 2, expEdges: 1},
+		{passID: math.MaxInt64, expLinks: 3, expEdges: 2},
+	} {
+		linkIt, err := g.LinksAsOf(uuid.Nil, maxUUID, spec.passID)
+		c.Assert(err, gc.IsNil)
+		var links int
+		for linkIt.Next() {
+			links++
+		}
+		c.Assert(linkIt.Error(), gc.IsNil)
+		c.Assert(linkIt.Close(), gc.IsNil)
+		c.Assert(links, gc.Equals, spec.expLinks, gc.Commentf("pass %d", spec.passID))
+
+		edgeIt, err := g.EdgesAsOf(uuid.Nil, maxUUID, spec.passID)
+		c.Assert(err, gc.IsNil)
+		var edges int
+		for edgeIt.Next() {
+			edges++
+		}
+		c.Assert(edgeIt.Error(), gc.IsNil)
+		c.Assert(edgeIt.Close(), gc.IsNil)
+		c.Assert(edges, gc.Equals, spec.expEdges, gc.Commentf("pass %d", spec.passID))
+	}
 }
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS edge_removals;
+ALTER TABLE edges DROP COLUMN IF EXISTS pass_id;
+ALTER TABLE edges DROP COLUMN IF EXISTS first_pass_id;
+ALTER TABLE links DROP COLUMN IF EXISTS pass_id;
+ALTER TABLE links DROP COLUMN IF EXISTS first_pass_id;
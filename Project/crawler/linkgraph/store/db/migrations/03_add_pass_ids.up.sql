@@ -0,0 +1,15 @@
+ALTER TABLE links ADD COLUMN IF NOT EXISTS first_pass_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS pass_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS first_pass_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS pass_id BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS edge_removals (
+	id UUID NOT NULL,
+	src UUID NOT NULL,
+	dst UUID NOT NULL,
+	updated_at TIMESTAMP,
+	first_pass_id BIGINT NOT NULL,
+	pass_id BIGINT NOT NULL,
+	removed_pass_id BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS edge_removals_by_pass ON edge_removals (removed_pass_id);
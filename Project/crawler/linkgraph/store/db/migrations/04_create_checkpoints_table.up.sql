@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS checkpoints (
+	partition INT PRIMARY KEY,
+	pass_id BIGINT NOT NULL,
+	pass_started_at TIMESTAMP NOT NULL,
+	completed_at TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS robots_cache;
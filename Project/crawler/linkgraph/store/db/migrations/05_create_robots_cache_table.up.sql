@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS robots_cache (
+	host TEXT PRIMARY KEY,
+	status_code INT NOT NULL,
+	body BYTEA,
+	fetched_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS pagerank_domain_history;
+DROP TABLE IF EXISTS pagerank_history;
+DROP TABLE IF EXISTS pagerank_passes;
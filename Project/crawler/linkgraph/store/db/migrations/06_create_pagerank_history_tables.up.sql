@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS pagerank_passes (
+	pass_id INT PRIMARY KEY,
+	computed_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pagerank_history (
+	link_id UUID NOT NULL,
+	pass_id INT NOT NULL,
+	score REAL NOT NULL,
+	PRIMARY KEY (link_id, pass_id)
+);
+
+CREATE TABLE IF NOT EXISTS pagerank_domain_history (
+	host TEXT NOT NULL,
+	pass_id INT NOT NULL,
+	score REAL NOT NULL,
+	links INT NOT NULL,
+	PRIMARY KEY (host, pass_id)
+);
@@ -0,0 +1,14 @@
+DELETE FROM checkpoints WHERE namespace != 'default';
+ALTER TABLE checkpoints ALTER PRIMARY KEY USING COLUMNS (partition);
+ALTER TABLE checkpoints DROP COLUMN IF EXISTS namespace;
+
+DELETE FROM edge_removals WHERE namespace != 'default';
+ALTER TABLE edge_removals DROP COLUMN IF EXISTS namespace;
+
+DROP INDEX IF EXISTS edges@edges_by_namespace_src;
+ALTER TABLE edges DROP COLUMN IF EXISTS namespace;
+
+DELETE FROM links WHERE namespace != 'default';
+CREATE UNIQUE INDEX IF NOT EXISTS links_url_key ON links (url);
+DROP INDEX IF EXISTS links@links_namespace_url_key CASCADE;
+ALTER TABLE links DROP COLUMN IF EXISTS namespace;
@@ -0,0 +1,11 @@
+ALTER TABLE links ADD COLUMN IF NOT EXISTS namespace STRING NOT NULL DEFAULT 'default';
+CREATE UNIQUE INDEX IF NOT EXISTS links_namespace_url_key ON links (namespace, url);
+DROP INDEX IF EXISTS links@links_url_key CASCADE;
+
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS namespace STRING NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS edges_by_namespace_src ON edges (namespace, src);
+
+ALTER TABLE edge_removals ADD COLUMN IF NOT EXISTS namespace STRING NOT NULL DEFAULT 'default';
+
+ALTER TABLE checkpoints ADD COLUMN IF NOT EXISTS namespace STRING NOT NULL DEFAULT 'default';
+ALTER TABLE checkpoints ALTER PRIMARY KEY USING COLUMNS (namespace, partition);
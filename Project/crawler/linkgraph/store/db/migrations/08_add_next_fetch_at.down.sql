@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS links@links_by_namespace_next_fetch_at;
+ALTER TABLE links DROP COLUMN IF EXISTS next_fetch_at;
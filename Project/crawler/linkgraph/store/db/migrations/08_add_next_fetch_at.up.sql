@@ -0,0 +1,2 @@
+ALTER TABLE links ADD COLUMN IF NOT EXISTS next_fetch_at INT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS links_by_namespace_next_fetch_at ON links (namespace, next_fetch_at);
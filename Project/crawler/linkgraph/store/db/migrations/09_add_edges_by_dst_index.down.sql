@@ -0,0 +1 @@
+DROP INDEX IF EXISTS edges@edges_by_namespace_dst;
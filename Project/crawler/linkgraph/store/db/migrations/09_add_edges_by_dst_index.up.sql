@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS edges_by_namespace_dst ON edges (namespace, dst, src);
@@ -0,0 +1,2 @@
+ALTER TABLE edge_removals DROP COLUMN IF EXISTS rel_type;
+ALTER TABLE edges DROP COLUMN IF EXISTS rel_type;
@@ -0,0 +1,2 @@
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS rel_type INT NOT NULL DEFAULT 0;
+ALTER TABLE edge_removals ADD COLUMN IF NOT EXISTS rel_type INT NOT NULL DEFAULT 0;
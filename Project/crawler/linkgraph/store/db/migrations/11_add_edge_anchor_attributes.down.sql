@@ -0,0 +1,4 @@
+ALTER TABLE edge_removals DROP COLUMN IF EXISTS no_follow;
+ALTER TABLE edge_removals DROP COLUMN IF EXISTS anchor_text;
+ALTER TABLE edges DROP COLUMN IF EXISTS no_follow;
+ALTER TABLE edges DROP COLUMN IF EXISTS anchor_text;
@@ -0,0 +1,4 @@
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS anchor_text STRING NOT NULL DEFAULT '';
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS no_follow BOOL NOT NULL DEFAULT false;
+ALTER TABLE edge_removals ADD COLUMN IF NOT EXISTS anchor_text STRING NOT NULL DEFAULT '';
+ALTER TABLE edge_removals ADD COLUMN IF NOT EXISTS no_follow BOOL NOT NULL DEFAULT false;
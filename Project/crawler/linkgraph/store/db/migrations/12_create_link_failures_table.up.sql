@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS link_failures (
+	link_id UUID PRIMARY KEY REFERENCES links(id) ON DELETE CASCADE,
+	namespace STRING NOT NULL DEFAULT 'default',
+	reason STRING NOT NULL,
+	attempts INT NOT NULL,
+	failed_at INT NOT NULL,
+	next_retry_at INT NOT NULL,
+	quarantined BOOL NOT NULL DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS link_failures_by_namespace_link_id ON link_failures (namespace, link_id);
@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN IF NOT EXISTS content_hash INT NOT NULL DEFAULT 0;
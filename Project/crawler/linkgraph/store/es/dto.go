@@ -0,0 +1,344 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+)
+
+// The size of each page of results that is cached locally by the iterators.
+const batchSize = 100
+
+// The number of attempts for resolving the ID of a link URL when the URL is
+// concurrently removed from the graph.
+const maxURLLookupAttempts = 3
+
+// The mappings for the documents in each of the graph indices. Link and edge
+// IDs are stored as keywords so that partitions can be scanned via range
+// queries; keyword ranges use the same lexicographic order as the string
+// representation of UUIDs.
+var (
+	linkMappings = `
+{
+  "dynamic": "strict",
+  "properties": {
+    "ID": {"type": "keyword"},
+    "URL": {"type": "keyword", "index": false},
+    "RetrievedAt": {"type": "long"},
+    "NextFetchAt": {"type": "long"},
+    "ContentHash": {"type": "keyword", "index": false},
+    "FirstPassID": {"type": "long"},
+    "PassID": {"type": "long"},
+    "Failure": {
+      "properties": {
+        "Reason": {"type": "keyword", "index": false},
+        "Attempts": {"type": "integer"},
+        "FailedAt": {"type": "long"},
+        "NextRetryAt": {"type": "long"},
+        "Quarantined": {"type": "boolean"}
+      }
+    }
+  }
+}`
+
+	urlMappings = `
+{
+  "dynamic": "strict",
+  "properties": {
+    "LinkID": {"type": "keyword", "index": false}
+  }
+}`
+
+	edgeMappings = `
+{
+  "dynamic": "strict",
+  "properties": {
+    "ID": {"type": "keyword"},
+    "Src": {"type": "keyword"},
+    "Dst": {"type": "keyword"},
+    "UpdatedAt": {"type": "long"},
+    "FirstPassID": {"type": "long"},
+    "PassID": {"type": "long"},
+    "RelType": {"type": "byte"},
+    "AnchorText": {"type": "text"},
+    "NoFollow": {"type": "boolean"},
+    "RemovedPassID": {"type": "long"}
+  }
+}`
+
+	checkpointMappings = `
+{
+  "dynamic": "strict",
+  "properties": {
+    "Partition": {"type": "integer"},
+    "PassID": {"type": "long"},
+    "PassStartedAt": {"type": "date"},
+    "CompletedAt": {"type": "date"},
+    "UpdatedAt": {"type": "date"}
+  }
+}`
+)
+
+// The scripts for updating existing links and edges. They keep the most
+// recent retrieval time and pass ID as well as the next fetch time and the
+// content hash of the most recent retrieval, mirroring the upsert queries of
+// the db store. Content hashes are stored as hex strings since long fields
+// cannot hold the full uint64 range. The
+// failure record of a link is discarded once the link is retrieved after the
+// failure.
+const (
+	updateLinkScript = `
+long nextFetchAt = ctx._source.containsKey('NextFetchAt') ? ctx._source.NextFetchAt : 0L;
+if (params.retrievedAt > ctx._source.RetrievedAt || (params.retrievedAt == ctx._source.RetrievedAt && params.nextFetchAt > nextFetchAt)) { nextFetchAt = params.nextFetchAt }
+ctx._source.NextFetchAt = nextFetchAt;
+if (params.retrievedAt > ctx._source.RetrievedAt || (params.retrievedAt == ctx._source.RetrievedAt && ctx._source.ContentHash == null)) {
+  if (params.contentHash == '') { ctx._source.remove('ContentHash') } else { ctx._source.ContentHash = params.contentHash }
+}
+if (params.retrievedAt > ctx._source.RetrievedAt) { ctx._source.RetrievedAt = params.retrievedAt }
+if (params.passID > ctx._source.PassID) { ctx._source.PassID = params.passID }
+if (ctx._source.Failure != null && ctx._source.RetrievedAt > ctx._source.Failure.FailedAt) { ctx._source.remove('Failure') }`
+
+	recordFailureScript = `
+ctx._source.NextFetchAt = params.nextFetchAt;
+ctx._source.Failure = params.failure;`
+
+	updateEdgeScript = `
+ctx._source.UpdatedAt = params.updatedAt;
+ctx._source.RelType = params.relType;
+ctx._source.AnchorText = params.anchorText;
+ctx._source.NoFollow = params.noFollow;
+if (params.passID > ctx._source.PassID) { ctx._source.PassID = params.passID }`
+)
+
+type esLink struct {
+	ID          string `json:"ID"`
+	URL         string `json:"URL"`
+	RetrievedAt int64  `json:"RetrievedAt"`
+	NextFetchAt int64  `json:"NextFetchAt"`
+	ContentHash string `json:"ContentHash,omitempty"`
+	FirstPassID uint64 `json:"FirstPassID"`
+	PassID      uint64 `json:"PassID"`
+
+	// The failure record of the link, if any.
+	Failure *esLinkFailure `json:"Failure,omitempty"`
+}
+
+type esLinkFailure struct {
+	Reason      string `json:"Reason"`
+	Attempts    int    `json:"Attempts"`
+	FailedAt    int64  `json:"FailedAt"`
+	NextRetryAt int64  `json:"NextRetryAt"`
+	Quarantined bool   `json:"Quarantined"`
+}
+
+func makeEsLink(link *graph.Link, id uuid.UUID) esLink {
+	return esLink{
+		ID:          id.String(),
+		URL:         link.URL,
+		RetrievedAt: link.RetrievedAt,
+		NextFetchAt: link.NextFetchAt,
+		ContentHash: makeEsHash(link.ContentHash),
+		FirstPassID: link.PassID,
+		PassID:      link.PassID,
+	}
+}
+
+func mapEsLink(doc *esLink, ns string) (*graph.Link, error) {
+	id, err := uuid.Parse(doc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("malformed link ID: %w", err)
+	}
+	return &graph.Link{
+		ID:          id,
+		URL:         doc.URL,
+		RetrievedAt: doc.RetrievedAt,
+		NextFetchAt: doc.NextFetchAt,
+		ContentHash: mapEsHash(doc.ContentHash),
+		Namespace:   ns,
+		FirstPassID: doc.FirstPassID,
+		PassID:      doc.PassID,
+	}, nil
+}
+
+func makeEsHash(h uint64) string {
+	if h == 0 {
+		return ""
+	}
+	return strconv.FormatUint(h, 16)
+}
+
+func mapEsHash(v string) uint64 {
+	h, _ := strconv.ParseUint(v, 16, 64)
+	return h
+}
+
+func makeEsLinkFailure(f *graph.LinkFailure) esLinkFailure {
+	return esLinkFailure{
+		Reason:      f.Reason,
+		Attempts:    f.Attempts,
+		FailedAt:    f.FailedAt,
+		NextRetryAt: f.NextRetryAt,
+		Quarantined: f.Quarantined,
+	}
+}
+
+// mapEsLinkFailure returns the failure record of the link in doc or nil if the
+// link has no failure record.
+func mapEsLinkFailure(doc *esLink) (*graph.LinkFailure, error) {
+	if doc.Failure == nil {
+		return nil, nil
+	}
+	id, err := uuid.Parse(doc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("malformed link ID: %w", err)
+	}
+	return &graph.LinkFailure{
+		LinkID:      id,
+		URL:         doc.URL,
+		Reason:      doc.Failure.Reason,
+		Attempts:    doc.Failure.Attempts,
+		FailedAt:    doc.Failure.FailedAt,
+		NextRetryAt: doc.Failure.NextRetryAt,
+		Quarantined: doc.Failure.Quarantined,
+	}, nil
+}
+
+type esURL struct {
+	LinkID string `json:"LinkID"`
+}
+
+// esEdge describes the documents in the edges and the edge removals indices.
+// RemovedPassID is only set for edge removals. Documents that were stored
+// before rel types and anchor attributes were tracked lack the corresponding
+// fields and are mapped to hyperlinks without anchor attributes.
+type esEdge struct {
+	ID            string        `json:"ID"`
+	Src           string        `json:"Src"`
+	Dst           string        `json:"Dst"`
+	UpdatedAt     int64         `json:"UpdatedAt"`
+	FirstPassID   uint64        `json:"FirstPassID"`
+	PassID        uint64        `json:"PassID"`
+	RelType       graph.RelType `json:"RelType"`
+	AnchorText    string        `json:"AnchorText"`
+	NoFollow      bool          `json:"NoFollow"`
+	RemovedPassID uint64        `json:"RemovedPassID,omitempty"`
+}
+
+func mapEsEdge(doc *esEdge, ns string) (*graph.Edge, error) {
+	var ids [3]uuid.UUID
+	for i, field := range []string{doc.ID, doc.Src, doc.Dst} {
+		id, err := uuid.Parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("malformed edge ID: %w", err)
+		}
+		ids[i] = id
+	}
+	return &graph.Edge{
+		ID:          ids[0],
+		Src:         ids[1],
+		Dst:         ids[2],
+		UpdatedAt:   doc.UpdatedAt,
+		Namespace:   ns,
+		FirstPassID: doc.FirstPassID,
+		PassID:      doc.PassID,
+		RelType:     doc.RelType,
+		AnchorText:  doc.AnchorText,
+		NoFollow:    doc.NoFollow,
+	}, nil
+}
+
+type esCheckpoint struct {
+	Partition     int        `json:"Partition"`
+	PassID        uint64     `json:"PassID"`
+	PassStartedAt time.Time  `json:"PassStartedAt"`
+	CompletedAt   *time.Time `json:"CompletedAt"`
+	UpdatedAt     time.Time  `json:"UpdatedAt"`
+}
+
+func makeEsCheckpoint(cp *graph.Checkpoint) esCheckpoint {
+	doc := esCheckpoint{
+		Partition:     cp.Partition,
+		PassID:        cp.PassID,
+		PassStartedAt: cp.PassStartedAt.UTC(),
+		UpdatedAt:     cp.UpdatedAt.UTC(),
+	}
+	if !cp.CompletedAt.IsZero() {
+		completedAt := cp.CompletedAt.UTC()
+		doc.CompletedAt = &completedAt
+	}
+	return doc
+}
+
+func mapEsCheckpoint(doc *esCheckpoint) *graph.Checkpoint {
+	cp := &graph.Checkpoint{
+		Partition:     doc.Partition,
+		PassID:        doc.PassID,
+		PassStartedAt: doc.PassStartedAt,
+		UpdatedAt:     doc.UpdatedAt,
+	}
+	if doc.CompletedAt != nil {
+		cp.CompletedAt = *doc.CompletedAt
+	}
+	return cp
+}
+
+type esGetRes struct {
+	Found  bool            `json:"found"`
+	Source json.RawMessage `json:"_source"`
+}
+
+type esMgetRes struct {
+	Docs []esGetRes `json:"docs"`
+}
+
+type esUpdateRes struct {
+	Result string   `json:"result"`
+	Get    esGetRes `json:"get"`
+}
+
+type esSearchRes struct {
+	Hits esSearchResHits `json:"hits"`
+}
+
+type esSearchResHits struct {
+	HitList []esHit `json:"hits"`
+}
+
+type esHit struct {
+	ID          string          `json:"_id"`
+	SeqNo       int             `json:"_seq_no"`
+	PrimaryTerm int             `json:"_primary_term"`
+	Source      json.RawMessage `json:"_source"`
+}
+
+type esBulkRes struct {
+	Errors bool                    `json:"errors"`
+	Items  []map[string]esBulkItem `json:"items"`
+}
+
+type esBulkItem struct {
+	ID     string   `json:"_id"`
+	Status int      `json:"status"`
+	Error  *esError `json:"error"`
+}
+
+type esClusterHealthRes struct {
+	Status string `json:"status"`
+}
+
+type esErrorRes struct {
+	Error esError `json:"error"`
+}
+
+type esError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (e esError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Reason)
+}
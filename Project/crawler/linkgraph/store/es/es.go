@@ -0,0 +1,947 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+
+	"github.com/elastic/go-elasticsearch"
+	"github.com/elastic/go-elasticsearch/esapi"
+	"github.com/google/uuid"
+)
+
+// Compile-time checks for ensuring ElasticSearchGraph implements Graph,
+// CheckpointStore, RemovalPruner, EdgeGrouper and Pinger.
+var (
+	_ graph.Graph           = (*ElasticSearchGraph)(nil)
+	_ graph.CheckpointStore = (*ElasticSearchGraph)(nil)
+	_ graph.RemovalPruner   = (*ElasticSearchGraph)(nil)
+	_ graph.EdgeGrouper     = (*ElasticSearchGraph)(nil)
+	_ graph.Pinger          = (*ElasticSearchGraph)(nil)
+)
+
+var (
+	upsertLinkDuration = metrics.GraphUpsertDuration.WithLabelValues("es", "link")
+	upsertEdgeDuration = metrics.GraphUpsertDuration.WithLabelValues("es", "edge")
+)
+
+// ElasticSearchGraph implements a graph that persists its links and edges as
+// documents in a set of elasticsearch indices. Each ElasticSearchGraph is
+// bound to a single namespace whose documents are stored in a dedicated set
+// of indices.
+//
+// As elasticsearch does not support transactions, RemoveLink must not be
+// invoked concurrently with upserts that reference the removed link.
+type ElasticSearchGraph struct {
+	es      *elasticsearch.Client
+	ns      string
+	idx     indexNames
+	refresh string
+	logger  *slog.Logger
+}
+
+// NewElasticSearchGraph returns an ElasticSearchGraph that stores its
+// documents in the cluster that client is connected to. The indices are
+// configured via opts and are created if they do not already exist.
+func NewElasticSearchGraph(client *elasticsearch.Client, opts Options) (*ElasticSearchGraph, error) {
+	if err := opts.validate(); err != nil {
+		return nil, fmt.Errorf("es graph: config validation failed: %w", err)
+	}
+	opts.applyDefaults()
+
+	g := &ElasticSearchGraph{
+		es:      client,
+		ns:      opts.Namespace,
+		idx:     opts.indexNames(),
+		refresh: "false",
+		logger:  logging.Component(opts.Logger, "linkgraph.es"),
+	}
+	if opts.SyncUpdates {
+		g.refresh = "true"
+	}
+	if err := g.ensureIndices(opts); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Namespace returns the namespace that the graph is bound to.
+func (g *ElasticSearchGraph) Namespace() string {
+	return g.ns
+}
+
+// Ping verifies that the cluster can be reached and that the graph indices
+// are available. Indices whose cluster health is red fail the check.
+func (g *ElasticSearchGraph) Ping(ctx context.Context) error {
+	res, err := g.es.Cluster.Health(
+		g.es.Cluster.Health.WithContext(ctx),
+		g.es.Cluster.Health.WithIndex(g.idx.all()...),
+	)
+	if err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+
+	var health esClusterHealthRes
+	if err = unmarshalResponse(res, &health); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	if health.Status == "red" {
+		return fmt.Errorf("ping: cluster health is %s", health.Status)
+	}
+	return nil
+}
+
+// UpsertLink creates a new link or updates an existing link.
+func (g *ElasticSearchGraph) UpsertLink(link *graph.Link) error {
+	defer metrics.ObserveSince(upsertLinkDuration, time.Now())
+	id, err := g.linkIDForURL(link.URL)
+	if err != nil {
+		return fmt.Errorf("upsert link: %w", err)
+	}
+
+	update := map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": updateLinkScript,
+			"params": map[string]interface{}{
+				"retrievedAt": link.RetrievedAt,
+				"nextFetchAt": link.NextFetchAt,
+				"contentHash": makeEsHash(link.ContentHash),
+				"passID":      link.PassID,
+			},
+		},
+		"upsert": makeEsLink(link, id),
+	}
+	var stored esLink
+	if err = g.updateDoc(g.idx.links, id.String(), update, &stored); err != nil {
+		return fmt.Errorf("upsert link: %w", err)
+	}
+
+	link.ID = id
+	link.RetrievedAt = stored.RetrievedAt
+	link.NextFetchAt = stored.NextFetchAt
+	link.ContentHash = mapEsHash(stored.ContentHash)
+	link.FirstPassID = stored.FirstPassID
+	link.Namespace = g.ns
+	return nil
+}
+
+// linkIDForURL returns the ID of the link with the specified URL. If no link
+// with that URL exists, a new ID is reserved for it. Reservations are created
+// with the "create" op type so that concurrent upserts of the same URL
+// resolve to the same ID.
+func (g *ElasticSearchGraph) linkIDForURL(linkURL string) (uuid.UUID, error) {
+	key := urlDocID(linkURL)
+
+	for attempt := 0; attempt < maxURLLookupAttempts; attempt++ {
+		id := uuid.New()
+		body, err := encodeBody(esURL{LinkID: id.String()})
+		if err != nil {
+			return uuid.Nil, err
+		}
+		res, err := g.es.Index(g.idx.urls, body,
+			g.es.Index.WithDocumentID(key),
+			g.es.Index.WithOpType("create"),
+		)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if err = unmarshalResponse(res, nil); err == nil {
+			return id, nil
+		} else if esErr, valid := err.(esError); !valid || esErr.Type != "version_conflict_engine_exception" {
+			return uuid.Nil, err
+		}
+
+		// The URL is already known; look up its ID. If the link was
+		// removed in the meantime, try to reserve a new ID.
+		var existing esURL
+		found, err := g.getDoc(g.idx.urls, key, &existing)
+		if err != nil {
+			return uuid.Nil, err
+		} else if found {
+			return uuid.Parse(existing.LinkID)
+		}
+	}
+
+	return uuid.Nil, fmt.Errorf("unable to resolve the link ID for %q", linkURL)
+}
+
+// FindLink looks up a link by its ID.
+func (g *ElasticSearchGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
+	var doc esLink
+	found, err := g.getDoc(g.idx.links, id.String(), &doc)
+	if err != nil {
+		return nil, fmt.Errorf("find link: %w", err)
+	} else if !found {
+		return nil, fmt.Errorf("find link: %w", graph.ErrNotFound)
+	}
+
+	link, err := mapEsLink(&doc, g.ns)
+	if err != nil {
+		return nil, fmt.Errorf("find link: %w", err)
+	}
+	return link, nil
+}
+
+// FindLinks looks up the links with the specified IDs using a single multi-get
+// request. The returned slice is aligned with ids; missing links are reported
+// via a *graph.MissingLinksError.
+func (g *ElasticSearchGraph) FindLinks(ids []uuid.UUID) ([]*graph.Link, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	docs, err := g.multiGet(g.idx.links, ids)
+	if err != nil {
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+
+	found := make(map[uuid.UUID]*graph.Link, len(ids))
+	for _, doc := range docs {
+		if !doc.Found {
+			continue
+		}
+		var esDoc esLink
+		if err = json.Unmarshal(doc.Source, &esDoc); err != nil {
+			return nil, fmt.Errorf("find links: %w", err)
+		}
+		link, err := mapEsLink(&esDoc, g.ns)
+		if err != nil {
+			return nil, fmt.Errorf("find links: %w", err)
+		}
+		found[link.ID] = link
+	}
+
+	links, err := graph.ArrangeLinks(ids, found)
+	if err != nil {
+		return links, fmt.Errorf("find links: %w", err)
+	}
+	return links, nil
+}
+
+// RemoveLink removes the link with the specified ID from the graph together
+// with the edges that reference it, mirroring the cascading foreign key
+// constraints of the db store.
+func (g *ElasticSearchGraph) RemoveLink(id uuid.UUID) error {
+	link, err := g.FindLink(id)
+	if err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	}
+
+	// Release the URL first so that a failure while removing the link
+	// document does not leave a URL that resolves to a missing link.
+	if err = g.deleteDoc(g.idx.urls, urlDocID(link.URL)); err != nil && !errors.Is(err, graph.ErrNotFound) {
+		return fmt.Errorf("remove link: %w", err)
+	}
+	if err = g.deleteDoc(g.idx.links, id.String()); err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	}
+
+	removedEdges, err := g.deleteByQuery(g.idx.edges, map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []interface{}{
+				termQuery("Src", id.String()),
+				termQuery("Dst", id.String()),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	}
+
+	g.logger.Debug("removed link", logging.Link(id, link.URL), "removed_edges", removedEdges)
+	return nil
+}
+
+// Links returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and are due to be fetched before the provided unix
+// timestamp. Links that were indexed before the next fetch time was tracked
+// lack the NextFetchAt field and are always due.
+func (g *ElasticSearchGraph) Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error) {
+	query := filterQuery(
+		idRangeQuery("ID", fromID, toID),
+		map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					rangeQuery("NextFetchAt", "lt", dueBefore),
+					map[string]interface{}{
+						"bool": map[string]interface{}{
+							"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "NextFetchAt"}},
+						},
+					},
+				},
+			},
+		},
+	)
+	return &linkIterator{hitIterator: g.newHitIterator(indexQuery{g.idx.links, query})}, nil
+}
+
+// LinksAsOf returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and had been added to the graph by the end of the
+// specified crawl pass.
+func (g *ElasticSearchGraph) LinksAsOf(fromID, toID uuid.UUID, passID uint64) (graph.LinkIterator, error) {
+	query := filterQuery(
+		idRangeQuery("ID", fromID, toID),
+		rangeQuery("FirstPassID", "lte", passID),
+	)
+	return &linkIterator{hitIterator: g.newHitIterator(indexQuery{g.idx.links, query})}, nil
+}
+
+// UpsertEdge creates a new edge or updates an existing edge.
+func (g *ElasticSearchGraph) UpsertEdge(edge *graph.Edge) error {
+	defer metrics.ObserveSince(upsertEdgeDuration, time.Now())
+	docs, err := g.multiGet(g.idx.links, []uuid.UUID{edge.Src, edge.Dst})
+	if err != nil {
+		return fmt.Errorf("upsert edge: %w", err)
+	}
+	for _, doc := range docs {
+		if !doc.Found {
+			return fmt.Errorf("upsert edge: %w", graph.ErrUnknownEdgeLinks)
+		}
+	}
+
+	// Edge documents are keyed by their endpoints so that each
+	// (src, dst) pair maps to a single edge.
+	updatedAt := time.Now().Unix()
+	update := map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": updateEdgeScript,
+			"params": map[string]interface{}{
+				"updatedAt":  updatedAt,
+				"passID":     edge.PassID,
+				"relType":    edge.RelType,
+				"anchorText": edge.AnchorText,
+				"noFollow":   edge.NoFollow,
+			},
+		},
+		"upsert": esEdge{
+			ID:          uuid.New().String(),
+			Src:         edge.Src.String(),
+			Dst:         edge.Dst.String(),
+			UpdatedAt:   updatedAt,
+			FirstPassID: edge.PassID,
+			PassID:      edge.PassID,
+			RelType:     edge.RelType,
+			AnchorText:  edge.AnchorText,
+			NoFollow:    edge.NoFollow,
+		},
+	}
+	var stored esEdge
+	if err = g.updateDoc(g.idx.edges, edgeDocID(edge.Src, edge.Dst), update, &stored); err != nil {
+		return fmt.Errorf("upsert edge: %w", err)
+	}
+
+	storedEdge, err := mapEsEdge(&stored, g.ns)
+	if err != nil {
+		return fmt.Errorf("upsert edge: %w", err)
+	}
+	*edge = *storedEdge
+	return nil
+}
+
+// Edges returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were updated before the provided
+// unix timestamp.
+func (g *ElasticSearchGraph) Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error) {
+	query := filterQuery(
+		idRangeQuery("Src", fromID, toID),
+		rangeQuery("UpdatedAt", "lt", updatedBefore),
+	)
+	return &edgeIterator{hitIterator: g.newHitIterator(indexQuery{g.idx.edges, query})}, nil
+}
+
+// EdgesGroupedByDst returns an iterator for the set of edges whose destination
+// vertex IDs belong to the [fromID, toID) range grouped by destination. Edges
+// are fetched in batches sorted by their destination and source IDs.
+func (g *ElasticSearchGraph) EdgesGroupedByDst(fromID, toID uuid.UUID) (graph.EdgeGroupIterator, error) {
+	query := filterQuery(idRangeQuery("Dst", fromID, toID))
+	it := g.newHitIterator(indexQuery{g.idx.edges, query})
+	it.sortBy = []string{"Dst", "Src"}
+	return graph.GroupEdgesByDst(&edgeIterator{hitIterator: it}), nil
+}
+
+// EdgesAsOf returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were present in the graph at the end
+// of the specified crawl pass.
+func (g *ElasticSearchGraph) EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (graph.EdgeIterator, error) {
+	current := filterQuery(
+		idRangeQuery("Src", fromID, toID),
+		rangeQuery("FirstPassID", "lte", passID),
+	)
+
+	// Include edges that existed at the end of the pass but have been
+	// removed by a subsequent pass.
+	removed := filterQuery(
+		idRangeQuery("Src", fromID, toID),
+		rangeQuery("FirstPassID", "lte", passID),
+		rangeQuery("RemovedPassID", "gt", passID),
+	)
+
+	it := g.newHitIterator(
+		indexQuery{g.idx.edges, current},
+		indexQuery{g.idx.edgeRemovals, removed},
+	)
+	return &edgeIterator{hitIterator: it}, nil
+}
+
+// RemoveStaleEdges removes any edge that originates from the specified link ID
+// and was updated before the specified timestamp.
+//
+// Each edge is deleted only if it has not been modified since it was matched
+// so that edges that are concurrently refreshed by the crawler are retained.
+func (g *ElasticSearchGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error {
+	// Edge removals are attributed to the pass that last crawled the
+	// source link so they can be reported by Diff.
+	var removedInPass uint64
+	if src, err := g.FindLink(fromID); err == nil {
+		removedInPass = src.PassID
+	}
+
+	query := filterQuery(
+		termQuery("Src", fromID.String()),
+		rangeQuery("UpdatedAt", "lt", updatedBefore),
+	)
+	it := g.newHitIterator(indexQuery{g.idx.edges, query})
+	var stale []esHit
+	for it.Next() {
+		stale = append(stale, it.hit())
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("remove stale edges: %w", err)
+	}
+
+	var removed int
+	for start := 0; start < len(stale); start += batchSize {
+		end := start + batchSize
+		if end > len(stale) {
+			end = len(stale)
+		}
+		count, err := g.removeEdges(stale[start:end], removedInPass)
+		if err != nil {
+			return fmt.Errorf("remove stale edges: %w", err)
+		}
+		removed += count
+	}
+
+	if removed != 0 {
+		g.logger.Debug("removed stale edges", "src", fromID.String(), "count", removed)
+	}
+	return nil
+}
+
+// removeEdges deletes the edge documents in hits using a single bulk request
+// and, if removedInPass is non-zero, records a removal for each deleted edge.
+// It returns the number of deleted edges.
+func (g *ElasticSearchGraph) removeEdges(hits []esHit, removedInPass uint64) (int, error) {
+	var buf bytes.Buffer
+	for _, hit := range hits {
+		if err := encodeBulkAction(&buf, "delete", g.idx.edges, hit.ID, map[string]interface{}{
+			"if_seq_no":       hit.SeqNo,
+			"if_primary_term": hit.PrimaryTerm,
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	items, err := g.bulk(&buf)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		removed  int
+		removals []esEdge
+	)
+	for i, item := range items {
+		switch {
+		case item.Status == http.StatusConflict || item.Status == http.StatusNotFound:
+			// The edge was concurrently updated or removed.
+			continue
+		case item.Error != nil:
+			return 0, *item.Error
+		}
+
+		removed++
+		if removedInPass != 0 {
+			var removal esEdge
+			if err = json.Unmarshal(hits[i].Source, &removal); err != nil {
+				return 0, err
+			}
+			removal.RemovedPassID = removedInPass
+			removals = append(removals, removal)
+		}
+	}
+	if len(removals) == 0 {
+		return removed, nil
+	}
+
+	buf.Reset()
+	for _, removal := range removals {
+		if err = encodeBulkAction(&buf, "index", g.idx.edgeRemovals, removal.ID, nil); err != nil {
+			return 0, err
+		}
+		if err = json.NewEncoder(&buf).Encode(removal); err != nil {
+			return 0, err
+		}
+	}
+	if items, err = g.bulk(&buf); err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		if item.Error != nil {
+			return 0, *item.Error
+		}
+	}
+	return removed, nil
+}
+
+// PruneRemovals discards the records of the edges that were removed by the
+// specified or an earlier crawl pass.
+func (g *ElasticSearchGraph) PruneRemovals(passID uint64) error {
+	pruned, err := g.deleteByQuery(g.idx.edgeRemovals, rangeQuery("RemovedPassID", "lte", passID))
+	if err != nil {
+		return fmt.Errorf("prune removals: %w", err)
+	}
+	if pruned != 0 {
+		g.logger.Debug("pruned edge removals", "pass_id", passID, "count", pruned)
+	}
+	return nil
+}
+
+// Diff returns an iterator for the set of changes that were applied to the
+// graph by the crawl passes in the (passA, passB] range.
+func (g *ElasticSearchGraph) Diff(passA, passB uint64) (graph.ChangeIterator, error) {
+	inRange := func(field string) map[string]interface{} {
+		return map[string]interface{}{
+			"range": map[string]interface{}{
+				field: map[string]interface{}{"gt": passA, "lte": passB},
+			},
+		}
+	}
+
+	links := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []interface{}{inRange("FirstPassID"), inRange("PassID")},
+		},
+	}
+	edges := inRange("FirstPassID")
+	removals := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []interface{}{
+				filterQuery(inRange("RemovedPassID"), rangeQuery("FirstPassID", "lte", passA)),
+				// The edge was added within the range and removed
+				// afterwards.
+				filterQuery(inRange("FirstPassID"), rangeQuery("RemovedPassID", "gt", passB)),
+			},
+		},
+	}
+
+	it := g.newHitIterator(
+		indexQuery{g.idx.links, links},
+		indexQuery{g.idx.edges, edges},
+		indexQuery{g.idx.edgeRemovals, removals},
+	)
+	return &changeIterator{hitIterator: it, passA: passA, passB: passB}, nil
+}
+
+// RecordLinkFailure creates or replaces the failure record of a link and
+// reschedules the link accordingly.
+func (g *ElasticSearchGraph) RecordLinkFailure(f *graph.LinkFailure) error {
+	update := map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": recordFailureScript,
+			"params": map[string]interface{}{
+				"nextFetchAt": f.DueAt(),
+				"failure":     makeEsLinkFailure(f),
+			},
+		},
+	}
+	var stored esLink
+	if err := g.updateDoc(g.idx.links, f.LinkID.String(), update, &stored); err != nil {
+		if esErr, valid := err.(esError); valid && esErr.Type == "document_missing_exception" {
+			err = graph.ErrNotFound
+		}
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	f.URL = stored.URL
+	return nil
+}
+
+// LinkFailure returns the failure record of the link with the specified ID.
+func (g *ElasticSearchGraph) LinkFailure(id uuid.UUID) (*graph.LinkFailure, error) {
+	var doc esLink
+	found, err := g.getDoc(g.idx.links, id.String(), &doc)
+	if err != nil {
+		return nil, fmt.Errorf("find link failure: %w", err)
+	} else if !found || doc.Failure == nil {
+		return nil, fmt.Errorf("find link failure: %w", graph.ErrNotFound)
+	}
+
+	f, err := mapEsLinkFailure(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("find link failure: %w", err)
+	}
+	return f, nil
+}
+
+// FailedLinks returns an iterator for the failure records of the links whose
+// IDs belong to the [fromID, toID) range.
+func (g *ElasticSearchGraph) FailedLinks(fromID, toID uuid.UUID) (graph.LinkFailureIterator, error) {
+	query := filterQuery(
+		idRangeQuery("ID", fromID, toID),
+		map[string]interface{}{"exists": map[string]interface{}{"field": "Failure.FailedAt"}},
+	)
+	return &failureIterator{hitIterator: g.newHitIterator(indexQuery{g.idx.links, query})}, nil
+}
+
+// SaveCheckpoint creates or replaces the checkpoint for the partition
+// specified by cp.
+func (g *ElasticSearchGraph) SaveCheckpoint(cp *graph.Checkpoint) error {
+	cp.UpdatedAt = time.Now()
+	body, err := encodeBody(makeEsCheckpoint(cp))
+	if err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+
+	res, err := g.es.Index(g.idx.checkpoints, body,
+		g.es.Index.WithDocumentID(strconv.Itoa(cp.Partition)),
+		g.es.Index.WithRefresh(g.refresh),
+	)
+	if err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	if err = unmarshalResponse(res, nil); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Checkpoint returns the checkpoint for the specified partition.
+func (g *ElasticSearchGraph) Checkpoint(partition int) (*graph.Checkpoint, error) {
+	var doc esCheckpoint
+	found, err := g.getDoc(g.idx.checkpoints, strconv.Itoa(partition), &doc)
+	if err != nil {
+		return nil, fmt.Errorf("find checkpoint: %w", err)
+	} else if !found {
+		return nil, fmt.Errorf("find checkpoint: %w", graph.ErrNotFound)
+	}
+	return mapEsCheckpoint(&doc), nil
+}
+
+// Checkpoints returns the checkpoints of all partitions ordered by partition.
+func (g *ElasticSearchGraph) Checkpoints() ([]*graph.Checkpoint, error) {
+	it := g.newHitIterator(indexQuery{
+		index: g.idx.checkpoints,
+		query: map[string]interface{}{"match_all": map[string]interface{}{}},
+	})
+	it.sortBy = []string{"Partition"}
+
+	var list []*graph.Checkpoint
+	for it.Next() {
+		var doc esCheckpoint
+		if err := json.Unmarshal(it.hit().Source, &doc); err != nil {
+			return nil, fmt.Errorf("checkpoints: %w", err)
+		}
+		list = append(list, mapEsCheckpoint(&doc))
+	}
+	if err := it.Error(); err != nil {
+		return nil, fmt.Errorf("checkpoints: %w", err)
+	}
+	return list, nil
+}
+
+// ensureIndices creates any graph index that does not already exist.
+func (g *ElasticSearchGraph) ensureIndices(opts Options) error {
+	mappings := map[string]string{
+		g.idx.links:        linkMappings,
+		g.idx.urls:         urlMappings,
+		g.idx.edges:        edgeMappings,
+		g.idx.edgeRemovals: edgeMappings,
+		g.idx.checkpoints:  checkpointMappings,
+	}
+	for _, name := range g.idx.all() {
+		body, err := encodeBody(map[string]interface{}{
+			"settings": opts.indexSettings(),
+			"mappings": json.RawMessage(mappings[name]),
+		})
+		if err != nil {
+			return fmt.Errorf("cannot create ES index %q: %w", name, err)
+		}
+
+		res, err := g.es.Indices.Create(name, g.es.Indices.Create.WithBody(body))
+		if err != nil {
+			return fmt.Errorf("cannot create ES index %q: %w", name, err)
+		}
+		if err = unmarshalResponse(res, nil); err != nil {
+			if esErr, valid := err.(esError); valid && esErr.Type == "resource_already_exists_exception" {
+				if err = g.updateMappings(name, mappings[name]); err != nil {
+					return fmt.Errorf("cannot update mappings of ES index %q: %w", name, err)
+				}
+				continue
+			}
+			return fmt.Errorf("cannot create ES index %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// updateMappings applies mappings to an existing index so that the fields
+// which were introduced after the index was created can be stored.
+func (g *ElasticSearchGraph) updateMappings(indexName, mappings string) error {
+	res, err := g.es.Indices.PutMapping(strings.NewReader(mappings), g.es.Indices.PutMapping.WithIndex(indexName))
+	if err != nil {
+		return err
+	}
+	return unmarshalResponse(res, nil)
+}
+
+// getDoc fetches the document with the specified ID and decodes its source
+// into to. It returns false if the document does not exist.
+func (g *ElasticSearchGraph) getDoc(indexName, id string, to interface{}) (bool, error) {
+	res, err := g.es.Get(indexName, id)
+	if err != nil {
+		return false, err
+	}
+
+	var getRes esGetRes
+	if err = unmarshalResponse(res, &getRes); err != nil {
+		// Responses for missing documents use the 404 status code but
+		// do not carry an error object.
+		if esErr, valid := err.(esError); valid && esErr.Type == "" && res.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if !getRes.Found {
+		return false, nil
+	}
+	return true, json.Unmarshal(getRes.Source, to)
+}
+
+// multiGet fetches the documents with the specified IDs using a single
+// request. The returned documents are aligned with ids.
+func (g *ElasticSearchGraph) multiGet(indexName string, ids []uuid.UUID) ([]esGetRes, error) {
+	idList := make([]string, len(ids))
+	for i, id := range ids {
+		idList[i] = id.String()
+	}
+	body, err := encodeBody(map[string]interface{}{"ids": idList})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := g.es.Mget(body, g.es.Mget.WithIndex(indexName))
+	if err != nil {
+		return nil, err
+	}
+	var mgetRes esMgetRes
+	if err = unmarshalResponse(res, &mgetRes); err != nil {
+		return nil, err
+	}
+	return mgetRes.Docs, nil
+}
+
+// updateDoc applies the scripted upsert in update to the document with the
+// specified ID and decodes the resulting document source into to.
+func (g *ElasticSearchGraph) updateDoc(indexName, id string, update map[string]interface{}, to interface{}) error {
+	body, err := encodeBody(update)
+	if err != nil {
+		return err
+	}
+
+	res, err := g.es.Update(indexName, id, body,
+		g.es.Update.WithRefresh(g.refresh),
+		g.es.Update.WithRetryOnConflict(3),
+		g.es.Update.WithSource("true"),
+	)
+	if err != nil {
+		return err
+	}
+	var updateRes esUpdateRes
+	if err = unmarshalResponse(res, &updateRes); err != nil {
+		return err
+	}
+	return json.Unmarshal(updateRes.Get.Source, to)
+}
+
+// deleteDoc deletes the document with the specified ID. It returns
+// graph.ErrNotFound if the document does not exist.
+func (g *ElasticSearchGraph) deleteDoc(indexName, id string) error {
+	res, err := g.es.Delete(indexName, id, g.es.Delete.WithRefresh(g.refresh))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		_ = res.Body.Close()
+		return graph.ErrNotFound
+	}
+	return unmarshalResponse(res, nil)
+}
+
+// deleteByQuery deletes the documents that match query and returns the number
+// of deleted documents. The index is refreshed beforehand so that the query
+// also matches recently written documents.
+func (g *ElasticSearchGraph) deleteByQuery(indexName string, query map[string]interface{}) (int, error) {
+	res, err := g.es.Indices.Refresh(g.es.Indices.Refresh.WithIndex(indexName))
+	if err != nil {
+		return 0, err
+	}
+	if err = unmarshalResponse(res, nil); err != nil {
+		return 0, err
+	}
+
+	body, err := encodeBody(map[string]interface{}{"query": query})
+	if err != nil {
+		return 0, err
+	}
+	res, err = g.es.DeleteByQuery([]string{indexName}, body,
+		g.es.DeleteByQuery.WithConflicts("proceed"),
+		g.es.DeleteByQuery.WithRefresh(g.refresh == "true"),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var deleteRes struct {
+		Deleted int `json:"deleted"`
+	}
+	if err = unmarshalResponse(res, &deleteRes); err != nil {
+		return 0, err
+	}
+	return deleteRes.Deleted, nil
+}
+
+// bulk executes the bulk request in body and returns the result of each
+// action.
+func (g *ElasticSearchGraph) bulk(body *bytes.Buffer) ([]esBulkItem, error) {
+	res, err := g.es.Bulk(body, g.es.Bulk.WithRefresh(g.refresh))
+	if err != nil {
+		return nil, err
+	}
+	var bulkRes esBulkRes
+	if err = unmarshalResponse(res, &bulkRes); err != nil {
+		return nil, err
+	}
+
+	items := make([]esBulkItem, len(bulkRes.Items))
+	for i, item := range bulkRes.Items {
+		for _, result := range item {
+			items[i] = result
+		}
+	}
+	return items, nil
+}
+
+// search runs searchQuery against indexName.
+func (g *ElasticSearchGraph) search(indexName string, searchQuery map[string]interface{}) (*esSearchRes, error) {
+	body, err := encodeBody(searchQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := g.es.Search(
+		g.es.Search.WithIndex(indexName),
+		g.es.Search.WithBody(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var searchRes esSearchRes
+	if err = unmarshalResponse(res, &searchRes); err != nil {
+		return nil, err
+	}
+	return &searchRes, nil
+}
+
+// urlDocID returns the ID of the document that maps linkURL to a link ID. URLs
+// are hashed as they may exceed the maximum length of document IDs.
+func urlDocID(linkURL string) string {
+	sum := sha256.Sum256([]byte(linkURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// edgeDocID returns the ID of the document for the edge between src and dst.
+func edgeDocID(src, dst uuid.UUID) string {
+	return src.String() + "_" + dst.String()
+}
+
+// idRangeQuery matches the documents whose field belongs to the [from, to)
+// range.
+func idRangeQuery(field string, from, to uuid.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			field: map[string]interface{}{"gte": from.String(), "lt": to.String()},
+		},
+	}
+}
+
+func rangeQuery(field, op string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			field: map[string]interface{}{op: value},
+		},
+	}
+}
+
+func termQuery(field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"term": map[string]interface{}{field: value},
+	}
+}
+
+// filterQuery matches the documents that satisfy all clauses.
+func filterQuery(clauses ...map[string]interface{}) map[string]interface{} {
+	filter := make([]interface{}, len(clauses))
+	for i, clause := range clauses {
+		filter[i] = clause
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{"filter": filter},
+	}
+}
+
+// encodeBulkAction appends the metadata line of a bulk action to buf.
+func encodeBulkAction(buf *bytes.Buffer, action, indexName, id string, params map[string]interface{}) error {
+	meta := map[string]interface{}{"_index": indexName, "_id": id}
+	for k, v := range params {
+		meta[k] = v
+	}
+	return json.NewEncoder(buf).Encode(map[string]interface{}{action: meta})
+}
+
+func encodeBody(v interface{}) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// unmarshalResponse decodes the body of res into to or, if res indicates a
+// failure, returns the reported esError. The body is discarded if to is nil.
+func unmarshalResponse(res *esapi.Response, to interface{}) error {
+	defer func() { _ = res.Body.Close() }()
+
+	if res.IsError() {
+		var errRes esErrorRes
+		if err := json.NewDecoder(res.Body).Decode(&errRes); err != nil {
+			return err
+		}
+		return errRes.Error
+	}
+
+	if to == nil {
+		to = new(map[string]interface{})
+	}
+	return json.NewDecoder(res.Body).Decode(to)
+}
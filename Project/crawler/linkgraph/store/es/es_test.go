@@ -0,0 +1,92 @@
+package es
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graph/graphtest"
+
+	"github.com/elastic/go-elasticsearch"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ElasticSearchGraphTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type ElasticSearchGraphTestSuite struct {
+	graphtest.SuiteBase
+	client *elasticsearch.Client
+	g      *ElasticSearchGraph
+	opts   Options
+}
+
+func (s *ElasticSearchGraphTestSuite) SetUpSuite(c *gc.C) {
+	nodeList := os.Getenv("ES_NODES")
+	if nodeList == "" {
+		c.Skip("Missing ES_NODES envvar; skipping elasticsearch-backed graph test suite")
+	}
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: strings.Split(nodeList, ",")})
+	c.Assert(err, gc.IsNil)
+	s.client = client
+	s.opts = Options{IndexPrefix: "linkgraph-test", Replicas: NoReplicas, SyncUpdates: true}
+}
+
+func (s *ElasticSearchGraphTestSuite) SetUpTest(c *gc.C) {
+	s.deleteIndices(c, s.opts)
+	g, err := NewElasticSearchGraph(s.client, s.opts)
+	c.Assert(err, gc.IsNil)
+	s.SetGraph(g)
+	s.g = g
+}
+
+func (s *ElasticSearchGraphTestSuite) TearDownSuite(c *gc.C) {
+	if s.client != nil {
+		s.deleteIndices(c, s.opts)
+	}
+}
+
+func (s *ElasticSearchGraphTestSuite) deleteIndices(c *gc.C, opts Options) {
+	opts.applyDefaults()
+	res, err := s.client.Indices.Delete(opts.indexNames().all(), s.client.Indices.Delete.WithIgnoreUnavailable(true))
+	c.Assert(err, gc.IsNil)
+	_ = res.Body.Close()
+}
+
+func (s *ElasticSearchGraphTestSuite) TestPing(c *gc.C) {
+	c.Assert(s.g.Ping(context.Background()), gc.IsNil)
+}
+
+func (s *ElasticSearchGraphTestSuite) TestNamespaceIsolation(c *gc.C) {
+	otherOpts := s.opts
+	otherOpts.Namespace = "other-crawl"
+	s.deleteIndices(c, otherOpts)
+	defer s.deleteIndices(c, otherOpts)
+	other, err := NewElasticSearchGraph(s.client, otherOpts)
+	c.Assert(err, gc.IsNil)
+
+	l1 := &graph.Link{URL: "https://example.com"}
+	c.Assert(s.g.UpsertLink(l1), gc.IsNil)
+
+	// Upserting the same URL in another namespace creates a separate link.
+	l2 := &graph.Link{URL: "https://example.com"}
+	c.Assert(other.UpsertLink(l2), gc.IsNil)
+	c.Assert(l2.Namespace, gc.Equals, "other-crawl")
+	c.Assert(l2.ID, gc.Not(gc.Equals), l1.ID)
+
+	_, err = other.FindLink(l1.ID)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	// Edges cannot connect links across namespaces.
+	err = other.UpsertEdge(&graph.Edge{Src: l2.ID, Dst: l1.ID})
+	c.Assert(errors.Is(err, graph.ErrUnknownEdgeLinks), gc.Equals, true)
+}
+
+func (s *ElasticSearchGraphTestSuite) TestInvalidOptions(c *gc.C) {
+	_, err := NewElasticSearchGraph(s.client, Options{Namespace: "Not Valid"})
+	c.Assert(err, gc.ErrorMatches, "es graph: config validation failed: .*")
+}
@@ -0,0 +1,264 @@
+package es
+
+import (
+	"encoding/json"
+	"webcrawler/crawler/linkgraph/graph"
+)
+
+// indexQuery describes a query against a single graph index.
+type indexQuery struct {
+	index string
+	query map[string]interface{}
+}
+
+// hitIterator iterates the documents that match a list of queries. The
+// queries are run one after the other; the results of each query are fetched
+// in batches sorted by the ID field (or the sortBy fields), with each batch
+// resuming after the sort values of the last document in the previous one. As
+// these values never change, the iterator does not skip or repeat documents
+// that are concurrently updated.
+type hitIterator struct {
+	g       *ElasticSearchGraph
+	queries []indexQuery
+
+	// The fields that the results are sorted by, which default to the ID
+	// field. The values of the fields must never change and must uniquely
+	// identify each document.
+	sortBy []string
+
+	// The index of the query whose results are being iterated.
+	queryIdx    int
+	searchAfter []interface{}
+
+	rsIdx int
+	rs    *esSearchRes
+
+	latchedHit esHit
+	lastErr    error
+}
+
+func (g *ElasticSearchGraph) newHitIterator(queries ...indexQuery) *hitIterator {
+	return &hitIterator{g: g, queries: queries}
+}
+
+// Next loads the next document. It returns false if no more documents are
+// available.
+func (it *hitIterator) Next() bool {
+	for it.lastErr == nil && it.queryIdx < len(it.queries) {
+		if it.rs != nil && it.rsIdx < len(it.rs.Hits.HitList) {
+			it.latchedHit = it.rs.Hits.HitList[it.rsIdx]
+			it.rsIdx++
+
+			var doc map[string]interface{}
+			if it.lastErr = json.Unmarshal(it.latchedHit.Source, &doc); it.lastErr != nil {
+				return false
+			}
+			it.searchAfter = it.searchAfter[:0]
+			for _, field := range it.sortFields() {
+				it.searchAfter = append(it.searchAfter, doc[field])
+			}
+			return true
+		}
+
+		// A short batch means that there are no more results for the
+		// current query.
+		if it.rs != nil && len(it.rs.Hits.HitList) < batchSize {
+			it.queryIdx, it.searchAfter, it.rs = it.queryIdx+1, nil, nil
+			continue
+		}
+		it.fetchNextBatch()
+	}
+	return false
+}
+
+// fetchNextBatch retrieves the batch of documents for the current query that
+// follows searchAfter.
+func (it *hitIterator) fetchNextBatch() {
+	var sort []interface{}
+	for _, field := range it.sortFields() {
+		sort = append(sort, map[string]interface{}{field: "asc"})
+	}
+
+	q := it.queries[it.queryIdx]
+	query := map[string]interface{}{
+		"query":               q.query,
+		"size":                batchSize,
+		"sort":                sort,
+		"seq_no_primary_term": true,
+		"track_total_hits":    false,
+	}
+	if len(it.searchAfter) != 0 {
+		query["search_after"] = it.searchAfter
+	}
+
+	rs, err := it.g.search(q.index, query)
+	if err != nil {
+		it.lastErr = err
+		return
+	}
+	it.rs, it.rsIdx = rs, 0
+}
+
+// sortFields returns the fields that the results are sorted by.
+func (it *hitIterator) sortFields() []string {
+	if len(it.sortBy) == 0 {
+		return []string{"ID"}
+	}
+	return it.sortBy
+}
+
+// hit returns the current document.
+func (it *hitIterator) hit() esHit {
+	return it.latchedHit
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *hitIterator) Error() error {
+	return it.lastErr
+}
+
+// Close releases any resources associated with the iterator.
+func (it *hitIterator) Close() error {
+	it.rs = nil
+	it.queryIdx = len(it.queries)
+	return nil
+}
+
+// linkIterator is a graph.LinkIterator implementation for the es graph.
+type linkIterator struct {
+	*hitIterator
+	latchedLink *graph.Link
+}
+
+// Next implements graph.LinkIterator.
+func (i *linkIterator) Next() bool {
+	if !i.hitIterator.Next() {
+		return false
+	}
+
+	var doc esLink
+	if i.lastErr = json.Unmarshal(i.hit().Source, &doc); i.lastErr != nil {
+		return false
+	}
+	i.latchedLink, i.lastErr = mapEsLink(&doc, i.g.ns)
+	return i.lastErr == nil
+}
+
+// Link implements graph.LinkIterator.
+func (i *linkIterator) Link() *graph.Link {
+	return i.latchedLink
+}
+
+// failureIterator is a graph.LinkFailureIterator implementation for the es
+// graph.
+type failureIterator struct {
+	*hitIterator
+	latchedFailure *graph.LinkFailure
+}
+
+// Next implements graph.LinkFailureIterator.
+func (i *failureIterator) Next() bool {
+	if !i.hitIterator.Next() {
+		return false
+	}
+
+	var doc esLink
+	if i.lastErr = json.Unmarshal(i.hit().Source, &doc); i.lastErr != nil {
+		return false
+	}
+	i.latchedFailure, i.lastErr = mapEsLinkFailure(&doc)
+	return i.lastErr == nil
+}
+
+// Failure implements graph.LinkFailureIterator.
+func (i *failureIterator) Failure() *graph.LinkFailure {
+	return i.latchedFailure
+}
+
+// edgeIterator is a graph.EdgeIterator implementation for the es graph.
+type edgeIterator struct {
+	*hitIterator
+	latchedEdge *graph.Edge
+}
+
+// Next implements graph.EdgeIterator.
+func (i *edgeIterator) Next() bool {
+	if !i.hitIterator.Next() {
+		return false
+	}
+
+	var doc esEdge
+	if i.lastErr = json.Unmarshal(i.hit().Source, &doc); i.lastErr != nil {
+		return false
+	}
+	i.latchedEdge, i.lastErr = mapEsEdge(&doc, i.g.ns)
+	return i.lastErr == nil
+}
+
+// Edge implements graph.EdgeIterator.
+func (i *edgeIterator) Edge() *graph.Edge {
+	return i.latchedEdge
+}
+
+// changeIterator is a graph.ChangeIterator implementation for the es graph.
+// It expects the queries of the underlying iterator to match the changed
+// links, the added edges and the edge removals in that order.
+type changeIterator struct {
+	*hitIterator
+	passA, passB uint64
+
+	latchedChange *graph.Change
+}
+
+// Next implements graph.ChangeIterator.
+func (i *changeIterator) Next() bool {
+	if !i.hitIterator.Next() {
+		return false
+	}
+
+	src := i.hit().Source
+	if i.queryIdx == 0 {
+		var doc esLink
+		if i.lastErr = json.Unmarshal(src, &doc); i.lastErr != nil {
+			return false
+		}
+		link, err := mapEsLink(&doc, i.g.ns)
+		if i.lastErr = err; err != nil {
+			return false
+		}
+
+		changeType := graph.ChangeLinkUpdated
+		if i.inRange(link.FirstPassID) {
+			changeType = graph.ChangeLinkAdded
+		}
+		i.latchedChange = &graph.Change{Type: changeType, Link: link}
+		return true
+	}
+
+	var doc esEdge
+	if i.lastErr = json.Unmarshal(src, &doc); i.lastErr != nil {
+		return false
+	}
+	edge, err := mapEsEdge(&doc, i.g.ns)
+	if i.lastErr = err; err != nil {
+		return false
+	}
+
+	// Removals of edges that were added within the range are reported
+	// as additions.
+	changeType := graph.ChangeEdgeAdded
+	if doc.RemovedPassID != 0 && !i.inRange(doc.FirstPassID) {
+		changeType = graph.ChangeEdgeRemoved
+	}
+	i.latchedChange = &graph.Change{Type: changeType, Edge: edge}
+	return true
+}
+
+func (i *changeIterator) inRange(passID uint64) bool {
+	return passID > i.passA && passID <= i.passB
+}
+
+// Change implements graph.ChangeIterator.
+func (i *changeIterator) Change() *graph.Change {
+	return i.latchedChange
+}
@@ -0,0 +1,124 @@
+package es
+
+import (
+	"fmt"
+	"log/slog"
+	"webcrawler/namespace"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// NoReplicas can be assigned to Options.Replicas to create the indices
+// without any replica shards.
+const NoReplicas = -1
+
+// The default prefix for the names of the indices used by the graph.
+const defaultIndexPrefix = "linkgraph"
+
+// Options configures the elasticsearch indices used by an ElasticSearchGraph.
+type Options struct {
+	// The prefix for the names of the indices that store the links,
+	// edges, edge removals and checkpoints of the graph. Defaults to
+	// "linkgraph".
+	IndexPrefix string
+
+	// The namespace of the crawl whose links and edges are accessed
+	// through the graph. Each namespace other than namespace.Default is
+	// stored in its own set of indices whose prefix is IndexPrefix
+	// suffixed with "-" and the namespace. Defaults to namespace.Default.
+	Namespace string
+
+	// The number of primary shards for each index. If zero, the cluster
+	// default is used.
+	Shards int
+
+	// The number of replicas for each primary shard. If zero, the cluster
+	// default is used. Set to NoReplicas to disable replication.
+	Replicas int
+
+	// If set, write operations block until the affected index has been
+	// refreshed so that changes are immediately visible to the iterators.
+	// Lookups by ID always observe the latest changes.
+	SyncUpdates bool
+
+	// An optional logger for reporting removals. If not specified,
+	// nothing is logged.
+	Logger *slog.Logger
+}
+
+func (opts *Options) validate() error {
+	var err error
+	if opts.Shards < 0 {
+		err = multierror.Append(err, fmt.Errorf("number of shards must not be negative"))
+	}
+	if opts.Replicas < NoReplicas {
+		err = multierror.Append(err, fmt.Errorf("number of replicas must be NoReplicas, zero or positive"))
+	}
+	if nsErr := namespace.Validate(namespace.OrDefault(opts.Namespace)); nsErr != nil {
+		err = multierror.Append(err, nsErr)
+	}
+	return err
+}
+
+func (opts *Options) applyDefaults() {
+	if opts.IndexPrefix == "" {
+		opts.IndexPrefix = defaultIndexPrefix
+	}
+	opts.Namespace = namespace.OrDefault(opts.Namespace)
+	if opts.Namespace != namespace.Default {
+		opts.IndexPrefix += "-" + opts.Namespace
+	}
+}
+
+// indexNames returns the names of the indices that store the documents of
+// the graph.
+func (opts *Options) indexNames() indexNames {
+	return indexNames{
+		links:        opts.IndexPrefix + "-links",
+		urls:         opts.IndexPrefix + "-urls",
+		edges:        opts.IndexPrefix + "-edges",
+		edgeRemovals: opts.IndexPrefix + "-edge-removals",
+		checkpoints:  opts.IndexPrefix + "-checkpoints",
+	}
+}
+
+// indexSettings returns the settings section for the creation requests of
+// the graph indices.
+func (opts *Options) indexSettings() map[string]interface{} {
+	settings := make(map[string]interface{})
+	if opts.Shards > 0 {
+		settings["number_of_shards"] = opts.Shards
+	}
+	switch {
+	case opts.Replicas == NoReplicas:
+		settings["number_of_replicas"] = 0
+	case opts.Replicas > 0:
+		settings["number_of_replicas"] = opts.Replicas
+	}
+	return map[string]interface{}{"index": settings}
+}
+
+// indexNames holds the names of the indices used by the graph.
+type indexNames struct {
+	// Link documents keyed by link ID.
+	links string
+
+	// Documents that map the hash of each link URL to the link ID. They
+	// enforce the uniqueness of link URLs as ES cannot enforce unique
+	// constraints on document fields.
+	urls string
+
+	// Edge documents keyed by the source and destination link IDs.
+	edges string
+
+	// Copies of the edges that were removed by a crawl pass.
+	edgeRemovals string
+
+	// Checkpoint documents keyed by partition number.
+	checkpoints string
+}
+
+// all returns the names of all graph indices.
+func (n indexNames) all() []string {
+	return []string{n.links, n.urls, n.edges, n.edgeRemovals, n.checkpoints}
+}
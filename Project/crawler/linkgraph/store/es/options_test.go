@@ -0,0 +1,49 @@
+package es
+
+import (
+	"errors"
+	"webcrawler/namespace"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(OptionsTestSuite))
+
+type OptionsTestSuite struct{}
+
+func (s *OptionsTestSuite) TestDefaultIndexNames(c *gc.C) {
+	var opts Options
+	c.Assert(opts.validate(), gc.IsNil)
+	opts.applyDefaults()
+	c.Assert(opts.Namespace, gc.Equals, namespace.Default)
+	c.Assert(opts.indexNames().all(), gc.DeepEquals, []string{
+		"linkgraph-links",
+		"linkgraph-urls",
+		"linkgraph-edges",
+		"linkgraph-edge-removals",
+		"linkgraph-checkpoints",
+	})
+	c.Assert(opts.indexSettings(), gc.DeepEquals, map[string]interface{}{"index": map[string]interface{}{}})
+}
+
+func (s *OptionsTestSuite) TestNamespacedIndexNames(c *gc.C) {
+	opts := Options{IndexPrefix: "graph", Namespace: "staging", Shards: 3, Replicas: NoReplicas}
+	c.Assert(opts.validate(), gc.IsNil)
+	opts.applyDefaults()
+	c.Assert(opts.indexNames().links, gc.Equals, "graph-staging-links")
+	c.Assert(opts.indexNames().checkpoints, gc.Equals, "graph-staging-checkpoints")
+	c.Assert(opts.indexSettings(), gc.DeepEquals, map[string]interface{}{
+		"index": map[string]interface{}{
+			"number_of_shards":   3,
+			"number_of_replicas": 0,
+		},
+	})
+}
+
+func (s *OptionsTestSuite) TestValidation(c *gc.C) {
+	opts := Options{Namespace: "Not Valid", Shards: -1, Replicas: -2}
+	err := opts.validate()
+	c.Assert(err, gc.NotNil)
+	c.Assert(errors.Is(err, namespace.ErrInvalid), gc.Equals, true)
+	c.Assert(err, gc.ErrorMatches, "(?s).*shards must not be negative.*replicas must be.*")
+}
@@ -2,63 +2,136 @@ package memory
 
 import (
 	"fmt"
+	"sort"
 	"time"
 	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+	"webcrawler/namespace"
 
 	"github.com/google/uuid"
 )
 
-// Compile-time check for ensuring InMemoryGraph implements Graph.
-var _ graph.Graph = (*InMemoryGraph)(nil)
+// Compile-time checks for ensuring InMemoryGraph implements Graph,
+// CheckpointStore, RemovalPruner, EdgeGrouper and Snapshotter.
+var (
+	_ graph.Graph           = (*InMemoryGraph)(nil)
+	_ graph.CheckpointStore = (*InMemoryGraph)(nil)
+	_ graph.RemovalPruner   = (*InMemoryGraph)(nil)
+	_ graph.EdgeGrouper     = (*InMemoryGraph)(nil)
+	_ graph.Snapshotter     = (*InMemoryGraph)(nil)
+)
+
+var (
+	upsertLinkDuration = metrics.GraphUpsertDuration.WithLabelValues("memory", "link")
+	upsertEdgeDuration = metrics.GraphUpsertDuration.WithLabelValues("memory", "edge")
+)
 
-// NewInMemoryGraph creates a new in-memory link graph.
+// NewInMemoryGraph creates a new in-memory link graph that enforces the
+// same integrity constraints as the db store.
 func NewInMemoryGraph() *InMemoryGraph {
-	return &InMemoryGraph{
-		links:        make(map[uuid.UUID]*graph.Link),
-		edges:        make(map[uuid.UUID]*graph.Edge),
-		linkURLIndex: make(map[string]*graph.Link),
-		linkEdgeMap:  make(map[uuid.UUID]edgeList),
+	return newInMemoryGraph(Config{}, numShards)
+}
+
+// NewInMemoryGraphWithConfig creates a new in-memory link graph that
+// enforces the integrity constraints specified by cfg.
+func NewInMemoryGraphWithConfig(cfg Config) (*InMemoryGraph, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("in-memory graph: config validation failed: %w", err)
+	}
+	return newInMemoryGraph(cfg, numShards), nil
+}
+
+func newInMemoryGraph(cfg Config, shards int) *InMemoryGraph {
+	s := &InMemoryGraph{
+		cfg:         cfg,
+		ns:          namespace.OrDefault(cfg.Namespace),
+		logger:      logging.Component(cfg.Logger, "linkgraph.memory"),
+		linkShards:  make([]*linkShard, shards),
+		urlShards:   make([]*urlShard, shards),
+		checkpoints: make(map[int]*graph.Checkpoint),
+	}
+	for i := 0; i < shards; i++ {
+		s.linkShards[i] = newLinkShard()
+		s.urlShards[i] = &urlShard{links: make(map[string]uuid.UUID)}
 	}
+	return s
 }
 
 // UpsertLink creates a new link or updates an existing link.
 func (s *InMemoryGraph) UpsertLink(link *graph.Link) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer metrics.ObserveSince(upsertLinkDuration, time.Now())
+	if err := s.cfg.checkURL(link.URL); err != nil {
+		s.logger.Debug("rejected link", "url", link.URL, "err", err)
+		return fmt.Errorf("upsert link: %w", err)
+	}
+
+	us := s.urlShardFor(link.URL)
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	link.Namespace = s.ns
 
 	// Check if a link with the same URL already exists. If so, convert
 	// this into an update and point the link ID to the existing link.
-	if existing := s.linkURLIndex[link.URL]; existing != nil {
+	if id, exists := us.links[link.URL]; exists {
+		ls := s.linkShardFor(id)
+		ls.mu.Lock()
+		defer ls.mu.Unlock()
+		ls.unshare()
+
+		existing := ls.links[id]
 		link.ID = existing.ID
-		origTs := existing.RetrievedAt
+		link.FirstPassID = existing.FirstPassID
+		origTs, origNextFetch, origHash, origPass := existing.RetrievedAt, existing.NextFetchAt, existing.ContentHash, existing.PassID
 		*existing = *link
+		if origTs > existing.RetrievedAt || (origTs == existing.RetrievedAt && origNextFetch > existing.NextFetchAt) {
+			existing.NextFetchAt = origNextFetch
+		}
+		if origTs > existing.RetrievedAt || (origTs == existing.RetrievedAt && origHash != 0) {
+			existing.ContentHash = origHash
+		}
 		if origTs > existing.RetrievedAt {
 			existing.RetrievedAt = origTs
 		}
+		if origPass > existing.PassID {
+			existing.PassID = origPass
+		}
+		if f := ls.failures[existing.ID]; f != nil && existing.RetrievedAt > f.FailedAt {
+			delete(ls.failures, existing.ID)
+		}
 		return nil
 	}
 
 	// Assign new ID and insert link
+	var ls *linkShard
 	for {
 		link.ID = uuid.New()
-		if s.links[link.ID] == nil {
+		ls = s.linkShardFor(link.ID)
+		ls.mu.Lock()
+		if ls.links[link.ID] == nil {
 			break
 		}
+		ls.mu.Unlock()
 	}
+	defer ls.mu.Unlock()
+	ls.unshare()
 
+	link.FirstPassID = link.PassID
 	lCopy := new(graph.Link)
 	*lCopy = *link
-	s.linkURLIndex[lCopy.URL] = lCopy
-	s.links[lCopy.ID] = lCopy
+	us.links[lCopy.URL] = lCopy.ID
+	ls.links[lCopy.ID] = lCopy
 	return nil
 }
 
 // FindLink looks up a link by its ID.
 func (s *InMemoryGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	ls := s.linkShardFor(id)
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
 
-	link := s.links[id]
+	link := ls.links[id]
 	if link == nil {
 		return nil, fmt.Errorf("find link: %w", graph.ErrNotFound)
 	}
@@ -68,39 +141,177 @@ func (s *InMemoryGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
 	return lCopy, nil
 }
 
+// FindLinks looks up the links with the specified IDs. The returned slice is
+// aligned with ids; missing links are reported via a
+// *graph.MissingLinksError.
+func (s *InMemoryGraph) FindLinks(ids []uuid.UUID) ([]*graph.Link, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	found := make(map[uuid.UUID]*graph.Link, len(ids))
+	for _, id := range ids {
+		ls := s.linkShardFor(id)
+		ls.mu.RLock()
+		if link := ls.links[id]; link != nil {
+			found[id] = copyLink(link)
+		}
+		ls.mu.RUnlock()
+	}
+
+	links, err := graph.ArrangeLinks(ids, found)
+	if err != nil {
+		return links, fmt.Errorf("find links: %w", err)
+	}
+	return links, nil
+}
+
+// RemoveLink removes the link with the specified ID from the graph. Edges
+// that reference the link are handled according to the configured link
+// removal policy.
+func (s *InMemoryGraph) RemoveLink(id uuid.UUID) error {
+	ls := s.linkShardFor(id)
+	ls.mu.RLock()
+	link := ls.links[id]
+	var linkURL string
+	if link != nil {
+		linkURL = link.URL
+	}
+	ls.mu.RUnlock()
+	if link == nil {
+		return fmt.Errorf("remove link: %w", graph.ErrNotFound)
+	}
+
+	// The URL of a link never changes so we can release the shard lock
+	// and reacquire it after locking the URL shard.
+	us := s.urlShardFor(linkURL)
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	// Edges that point to the link may originate from any shard.
+	shards := []*linkShard{ls}
+	if s.cfg.OnLinkRemoval != KeepEdges {
+		shards = s.linkShards
+	}
+	for _, shard := range shards {
+		shard.mu.Lock()
+	}
+	defer func() {
+		for _, shard := range shards {
+			shard.mu.Unlock()
+		}
+	}()
+
+	// The link may have been removed while no lock was held.
+	if ls.links[id] == nil {
+		return fmt.Errorf("remove link: %w", graph.ErrNotFound)
+	}
+
+	var removedEdges int
+	switch s.cfg.OnLinkRemoval {
+	case RestrictIfEdges:
+		for _, shard := range shards {
+			for _, edge := range shard.edges {
+				if edge.Src == id || edge.Dst == id {
+					return fmt.Errorf("remove link: %w", graph.ErrLinkHasEdges)
+				}
+			}
+		}
+	case CascadeEdges:
+		for _, shard := range shards {
+			// Only clone the data of shards that are actually modified.
+			var referencing []*graph.Edge
+			for _, edge := range shard.edges {
+				if edge.Src == id || edge.Dst == id {
+					referencing = append(referencing, edge)
+				}
+			}
+			if len(referencing) == 0 {
+				continue
+			}
+
+			shard.unshare()
+			for _, edge := range referencing {
+				delete(shard.edges, edge.ID)
+				if edge.Src != id {
+					shard.linkEdgeMap[edge.Src] = shard.linkEdgeMap[edge.Src].without(edge.ID)
+				}
+			}
+			removedEdges += len(referencing)
+		}
+	}
+
+	ls.unshare()
+	if s.cfg.OnLinkRemoval == CascadeEdges {
+		delete(ls.linkEdgeMap, id)
+	}
+	delete(ls.links, id)
+	delete(us.links, linkURL)
+	delete(ls.failures, id)
+	s.logger.Debug("removed link", logging.Link(id, linkURL), "removed_edges", removedEdges)
+	return nil
+}
+
 // Links returns an iterator for the set of links whose IDs belong to the
-// [fromID, toID) range and were retrieved before the provided unix timestamp.
-func (s *InMemoryGraph) Links(fromID, toID uuid.UUID, retrievedBefore int64) (graph.LinkIterator, error) {
+// [fromID, toID) range and are due to be fetched before the provided unix
+// timestamp.
+func (s *InMemoryGraph) Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error) {
 	from, to := fromID.String(), toID.String()
+	match := func(link *graph.Link) bool { return link.NextFetchAt < dueBefore }
 
-	s.mu.RLock()
-	var list []*graph.Link
-	for linkID, link := range s.links {
-		if id := linkID.String(); id >= from && id < to && link.RetrievedAt < retrievedBefore {
-			list = append(list, link)
-		}
+	var list []shardLink
+	for _, shard := range s.shardsInRange(fromID, toID) {
+		shard.mu.RLock()
+		list = shard.appendLinks(list, shard, from, to, match)
+		shard.mu.RUnlock()
 	}
-	s.mu.RUnlock()
 
-	return &linkIterator{s: s, links: list}, nil
+	return &linkIterator{links: list}, nil
+}
+
+// LinksAsOf returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and had been added to the graph by the end of the
+// specified crawl pass.
+func (s *InMemoryGraph) LinksAsOf(fromID, toID uuid.UUID, passID uint64) (graph.LinkIterator, error) {
+	from, to := fromID.String(), toID.String()
+	match := func(link *graph.Link) bool { return link.FirstPassID <= passID }
+
+	var list []shardLink
+	for _, shard := range s.shardsInRange(fromID, toID) {
+		shard.mu.RLock()
+		list = shard.appendLinks(list, shard, from, to, match)
+		shard.mu.RUnlock()
+	}
+
+	return &linkIterator{links: list}, nil
 }
 
 // UpsertEdge creates a new edge or updates an existing edge.
 func (s *InMemoryGraph) UpsertEdge(edge *graph.Edge) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer metrics.ObserveSince(upsertEdgeDuration, time.Now())
 
-	_, srcExists := s.links[edge.Src]
-	_, dstExists := s.links[edge.Dst]
+	// Edges are stored in the shard of their source link.
+	ls, dstShard, unlock := s.lockEdgeShards(edge.Src, edge.Dst)
+	defer unlock()
+
+	_, srcExists := ls.links[edge.Src]
+	_, dstExists := dstShard.links[edge.Dst]
 	if !srcExists || !dstExists {
 		return fmt.Errorf("upsert edge: %w", graph.ErrUnknownEdgeLinks)
 	}
+	ls.unshare()
 
 	// Scan edge list from source
-	for _, edgeID := range s.linkEdgeMap[edge.Src] {
-		existingEdge := s.edges[edgeID]
+	for _, edgeID := range ls.linkEdgeMap[edge.Src] {
+		existingEdge := ls.edges[edgeID]
 		if existingEdge.Src == edge.Src && existingEdge.Dst == edge.Dst {
 			existingEdge.UpdatedAt = time.Now().Unix()
+			existingEdge.RelType = edge.RelType
+			existingEdge.AnchorText = edge.AnchorText
+			existingEdge.NoFollow = edge.NoFollow
+			if edge.PassID > existingEdge.PassID {
+				existingEdge.PassID = edge.PassID
+			}
 			*edge = *existingEdge
 			return nil
 		}
@@ -109,19 +320,21 @@ func (s *InMemoryGraph) UpsertEdge(edge *graph.Edge) error {
 	// Insert new edge
 	for {
 		edge.ID = uuid.New()
-		if s.edges[edge.ID] == nil {
+		if ls.edges[edge.ID] == nil {
 			break
 		}
 	}
 
 	edge.UpdatedAt = time.Now().Unix()
+	edge.FirstPassID = edge.PassID
+	edge.Namespace = s.ns
 	eCopy := new(graph.Edge)
 	*eCopy = *edge
-	s.edges[eCopy.ID] = eCopy
+	ls.edges[eCopy.ID] = eCopy
 
 	// Append the edge ID to the list of edges originating from the
 	// edge's source link.
-	s.linkEdgeMap[edge.Src] = append(s.linkEdgeMap[edge.Src], eCopy.ID)
+	ls.linkEdgeMap[edge.Src] = append(ls.linkEdgeMap[edge.Src], eCopy.ID)
 	return nil
 }
 
@@ -131,42 +344,260 @@ func (s *InMemoryGraph) UpsertEdge(edge *graph.Edge) error {
 func (s *InMemoryGraph) Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error) {
 	from, to := fromID.String(), toID.String()
 
-	s.mu.RLock()
-	var list []*graph.Edge
-	for linkID := range s.links {
-		if id := linkID.String(); id < from || id >= to {
-			continue
-		}
+	var list []shardEdge
+	for _, shard := range s.shardsInRange(fromID, toID) {
+		shard.mu.RLock()
+		list = shard.appendEdges(list, shard, from, to, updatedBefore)
+		shard.mu.RUnlock()
+	}
 
-		for _, edgeID := range s.linkEdgeMap[linkID] {
-			if edge := s.edges[edgeID]; edge.UpdatedAt < updatedBefore {
-				list = append(list, edge)
-			}
-		}
+	return &edgeIterator{edges: list}, nil
+}
+
+// EdgesAsOf returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were present in the graph at the end
+// of the specified crawl pass.
+func (s *InMemoryGraph) EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (graph.EdgeIterator, error) {
+	from, to := fromID.String(), toID.String()
+
+	var list []shardEdge
+	for _, shard := range s.shardsInRange(fromID, toID) {
+		shard.mu.RLock()
+		list = shard.appendEdgesAsOf(list, shard, from, to, passID)
+		shard.mu.RUnlock()
 	}
-	s.mu.RUnlock()
 
-	return &edgeIterator{s: s, edges: list}, nil
+	return &edgeIterator{edges: list}, nil
+}
+
+// EdgesGroupedByDst returns an iterator for the set of edges whose destination
+// vertex IDs belong to the [fromID, toID) range grouped by destination.
+func (s *InMemoryGraph) EdgesGroupedByDst(fromID, toID uuid.UUID) (graph.EdgeGroupIterator, error) {
+	from, to := fromID.String(), toID.String()
+
+	// Edges are sharded by their source link so every shard needs to be
+	// scanned.
+	var list []shardEdge
+	for _, shard := range s.linkShards {
+		shard.mu.RLock()
+		list = shard.appendEdgesToDsts(list, shard, from, to)
+		shard.mu.RUnlock()
+	}
+
+	sortEdgesByDst(list)
+	return graph.GroupEdgesByDst(&edgeIterator{edges: list}), nil
 }
 
 // RemoveStaleEdges removes any edge that originates from the specified link ID
 // and was updated before the specified timestamp.
 func (s *InMemoryGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	var newEdgeList edgeList
-	for _, edgeID := range s.linkEdgeMap[fromID] {
-		edge := s.edges[edgeID]
-		if edge.UpdatedAt < updatedBefore {
-			delete(s.edges, edgeID)
+	ls := s.linkShardFor(fromID)
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	// Edge removals are attributed to the pass that last crawled the
+	// source link so they can be reported by Diff.
+	var removedInPass uint64
+	if src := ls.links[fromID]; src != nil {
+		removedInPass = src.PassID
+	}
+
+	var (
+		newEdgeList edgeList
+		stale       []*graph.Edge
+	)
+	for _, edgeID := range ls.linkEdgeMap[fromID] {
+		if edge := ls.edges[edgeID]; edge.UpdatedAt < updatedBefore {
+			stale = append(stale, edge)
 			continue
 		}
 
 		newEdgeList = append(newEdgeList, edgeID)
 	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	ls.unshare()
+	for _, edge := range stale {
+		delete(ls.edges, edge.ID)
+		if removedInPass != 0 {
+			ls.edgeRemovals = append(ls.edgeRemovals, edgeRemoval{edge: edge, passID: removedInPass})
+		}
+	}
 
 	// Replace edge list or origin link with the filtered edge list
-	s.linkEdgeMap[fromID] = newEdgeList
+	s.logger.Debug("removed stale edges", "src", fromID.String(), "count", len(stale))
+	ls.linkEdgeMap[fromID] = newEdgeList
 	return nil
 }
+
+// Diff returns an iterator for the set of changes that were applied to the
+// graph by the crawl passes in the (passA, passB] range.
+func (s *InMemoryGraph) Diff(passA, passB uint64) (graph.ChangeIterator, error) {
+	inRange := func(passID uint64) bool { return passID > passA && passID <= passB }
+
+	var list []*graph.Change
+	for _, shard := range s.linkShards {
+		shard.mu.RLock()
+		for _, link := range shard.links {
+			switch {
+			case inRange(link.FirstPassID):
+				list = append(list, &graph.Change{Type: graph.ChangeLinkAdded, Link: copyLink(link)})
+			case inRange(link.PassID):
+				list = append(list, &graph.Change{Type: graph.ChangeLinkUpdated, Link: copyLink(link)})
+			}
+		}
+
+		for _, edge := range shard.edges {
+			if inRange(edge.FirstPassID) {
+				list = append(list, &graph.Change{Type: graph.ChangeEdgeAdded, Edge: copyEdge(edge)})
+			}
+		}
+
+		for _, removal := range shard.edgeRemovals {
+			switch {
+			case inRange(removal.passID) && removal.edge.FirstPassID <= passA:
+				list = append(list, &graph.Change{Type: graph.ChangeEdgeRemoved, Edge: copyEdge(removal.edge)})
+			case inRange(removal.edge.FirstPassID) && removal.passID > passB:
+				// The edge was added within the range and removed afterwards.
+				list = append(list, &graph.Change{Type: graph.ChangeEdgeAdded, Edge: copyEdge(removal.edge)})
+			}
+		}
+		shard.mu.RUnlock()
+	}
+
+	return &changeIterator{changes: list}, nil
+}
+
+// PruneRemovals discards the records of the edges that were removed by the
+// specified or an earlier crawl pass.
+func (s *InMemoryGraph) PruneRemovals(passID uint64) error {
+	for _, ls := range s.linkShards {
+		ls.mu.Lock()
+		// The remaining records are copied to a new slice, so the
+		// shard data does not need to be unshared from any snapshot.
+		var kept []edgeRemoval
+		for _, removal := range ls.edgeRemovals {
+			if removal.passID > passID {
+				kept = append(kept, removal)
+			}
+		}
+		if len(kept) != len(ls.edgeRemovals) {
+			ls.edgeRemovals = kept
+		}
+		ls.mu.Unlock()
+	}
+	return nil
+}
+
+// RecordLinkFailure creates or replaces the failure record of a link and
+// reschedules the link accordingly.
+func (s *InMemoryGraph) RecordLinkFailure(f *graph.LinkFailure) error {
+	ls := s.linkShardFor(f.LinkID)
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.links[f.LinkID] == nil {
+		return fmt.Errorf("record link failure: %w", graph.ErrNotFound)
+	}
+	ls.unshare()
+
+	link := ls.links[f.LinkID]
+	f.URL = link.URL
+	fCopy := new(graph.LinkFailure)
+	*fCopy = *f
+	ls.failures[f.LinkID] = fCopy
+	link.NextFetchAt = f.DueAt()
+	return nil
+}
+
+// LinkFailure returns the failure record of the link with the specified ID.
+func (s *InMemoryGraph) LinkFailure(id uuid.UUID) (*graph.LinkFailure, error) {
+	ls := s.linkShardFor(id)
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	f := ls.failures[id]
+	if f == nil {
+		return nil, fmt.Errorf("find link failure: %w", graph.ErrNotFound)
+	}
+	fCopy := new(graph.LinkFailure)
+	*fCopy = *f
+	return fCopy, nil
+}
+
+// FailedLinks returns an iterator for the failure records of the links whose
+// IDs belong to the [fromID, toID) range.
+func (s *InMemoryGraph) FailedLinks(fromID, toID uuid.UUID) (graph.LinkFailureIterator, error) {
+	from, to := fromID.String(), toID.String()
+
+	var list []*graph.LinkFailure
+	for _, shard := range s.shardsInRange(fromID, toID) {
+		shard.mu.RLock()
+		for linkID, f := range shard.failures {
+			if id := linkID.String(); id >= from && id < to {
+				fCopy := new(graph.LinkFailure)
+				*fCopy = *f
+				list = append(list, fCopy)
+			}
+		}
+		shard.mu.RUnlock()
+	}
+
+	return &failureIterator{failures: list}, nil
+}
+
+// SaveCheckpoint creates or replaces the checkpoint for the partition
+// specified by cp.
+func (s *InMemoryGraph) SaveCheckpoint(cp *graph.Checkpoint) error {
+	s.cpMu.Lock()
+	defer s.cpMu.Unlock()
+
+	cp.UpdatedAt = time.Now()
+	cpCopy := new(graph.Checkpoint)
+	*cpCopy = *cp
+	s.checkpoints[cp.Partition] = cpCopy
+	return nil
+}
+
+// Checkpoint returns the checkpoint for the specified partition.
+func (s *InMemoryGraph) Checkpoint(partition int) (*graph.Checkpoint, error) {
+	s.cpMu.RLock()
+	defer s.cpMu.RUnlock()
+
+	cp := s.checkpoints[partition]
+	if cp == nil {
+		return nil, fmt.Errorf("find checkpoint: %w", graph.ErrNotFound)
+	}
+	cpCopy := new(graph.Checkpoint)
+	*cpCopy = *cp
+	return cpCopy, nil
+}
+
+// Checkpoints returns the checkpoints of all partitions ordered by partition.
+func (s *InMemoryGraph) Checkpoints() ([]*graph.Checkpoint, error) {
+	s.cpMu.RLock()
+	defer s.cpMu.RUnlock()
+
+	list := make([]*graph.Checkpoint, 0, len(s.checkpoints))
+	for _, cp := range s.checkpoints {
+		cpCopy := new(graph.Checkpoint)
+		*cpCopy = *cp
+		list = append(list, cpCopy)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Partition < list[j].Partition })
+	return list, nil
+}
+
+func copyLink(link *graph.Link) *graph.Link {
+	lCopy := new(graph.Link)
+	*lCopy = *link
+	return lCopy
+}
+
+func copyEdge(edge *graph.Edge) *graph.Edge {
+	eCopy := new(graph.Edge)
+	*eCopy = *edge
+	return eCopy
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/namespace"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// LinkRemovalPolicy specifies how edges are handled when the link they
+// reference is removed from the graph.
+type LinkRemovalPolicy uint8
+
+const (
+	// CascadeEdges removes every edge that originates from or points to a
+	// removed link. This mirrors the ON DELETE CASCADE constraint of the
+	// edges table in the db store.
+	CascadeEdges LinkRemovalPolicy = iota
+
+	// RestrictIfEdges refuses to remove links that are still referenced
+	// by edges.
+	RestrictIfEdges
+
+	// KeepEdges removes the link but leaves any edges that reference it
+	// untouched.
+	KeepEdges
+)
+
+// Config encapsulates the integrity constraints enforced by the in-memory
+// graph. The zero value matches the constraints of the db store.
+type Config struct {
+	// ValidateURLs enables the validation of link URLs on upsert. When
+	// enabled, only absolute URLs with a scheme and a host are accepted.
+	ValidateURLs bool
+
+	// MaxURLLength specifies the maximum length (in bytes) of link URLs.
+	// A zero value disables the check.
+	MaxURLLength int
+
+	// OnLinkRemoval specifies how edges that reference a removed link are
+	// handled. Defaults to CascadeEdges.
+	OnLinkRemoval LinkRemovalPolicy
+
+	// The namespace of the crawl that the links and edges of the graph
+	// belong to. Defaults to namespace.Default.
+	Namespace string
+
+	// An optional logger for reporting rejected links and removals. If
+	// not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.MaxURLLength < 0 {
+		err = multierror.Append(err, fmt.Errorf("max URL length must not be negative"))
+	}
+	if cfg.OnLinkRemoval > KeepEdges {
+		err = multierror.Append(err, fmt.Errorf("unknown link removal policy %d", cfg.OnLinkRemoval))
+	}
+	if nsErr := namespace.Validate(namespace.OrDefault(cfg.Namespace)); nsErr != nil {
+		err = multierror.Append(err, nsErr)
+	}
+	return err
+}
+
+// checkURL returns an error if rawURL violates the configured integrity
+// constraints.
+func (cfg *Config) checkURL(rawURL string) error {
+	if cfg.MaxURLLength > 0 && len(rawURL) > cfg.MaxURLLength {
+		return fmt.Errorf("%w: URL length exceeds %d bytes", graph.ErrInvalidURL, cfg.MaxURLLength)
+	}
+	if !cfg.ValidateURLs {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", graph.ErrInvalidURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%w: URL must be absolute", graph.ErrInvalidURL)
+	}
+	return nil
+}
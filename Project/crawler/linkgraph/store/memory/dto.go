@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"log/slog"
 	"sync"
 	"webcrawler/crawler/linkgraph/graph"
 
@@ -10,14 +11,82 @@ import (
 // edgeList contains the slice of edge UUIDs that originate from a link in the graph.
 type edgeList []uuid.UUID
 
+// without returns a copy of the list that excludes the specified edge ID.
+func (l edgeList) without(edgeID uuid.UUID) edgeList {
+	var out edgeList
+	for _, id := range l {
+		if id != edgeID {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// edgeRemoval records an edge that was removed while crawling a pass.
+type edgeRemoval struct {
+	edge   *graph.Edge
+	passID uint64
+}
+
+// numShards is the number of shards that the links of the graph are split
+// into. Links are assigned to shards by the first byte of their ID.
+const numShards = 256
+
+// shardData holds the links whose IDs map to the same shard, the edges that
+// originate from them and their failure records.
+type shardData struct {
+	links        map[uuid.UUID]*graph.Link
+	edges        map[uuid.UUID]*graph.Edge
+	linkEdgeMap  map[uuid.UUID]edgeList
+	edgeRemovals []edgeRemoval
+	failures     map[uuid.UUID]*graph.LinkFailure
+}
+
+// linkShard guards the data of a shard with its own lock. Once a snapshot of
+// the graph has been taken, the data is shared with the snapshot and it is
+// cloned before it is modified again.
+type linkShard struct {
+	mu sync.RWMutex
+	shardData
+
+	shared bool
+}
+
+// urlShard maps the link URLs that hash to the same shard to their link IDs.
+type urlShard struct {
+	mu    sync.Mutex
+	links map[string]uuid.UUID
+}
+
+// shardLink pairs a link with the shard whose lock guards it. The shard is nil
+// for links that belong to a snapshot and therefore never change.
+type shardLink struct {
+	shard *linkShard
+	link  *graph.Link
+}
+
+// shardEdge pairs an edge with the shard whose lock guards it. The shard is nil
+// for edges that belong to a snapshot and therefore never change.
+type shardEdge struct {
+	shard *linkShard
+	edge  *graph.Edge
+}
+
 // InMemoryGraph implements an in-memory link graph that can be concurrently
 // accessed by multiple clients.
+//
+// To reduce lock contention, the graph is split into shards that are guarded
+// by their own locks. Operations that need more than one lock acquire URL
+// shard locks before link shard locks and link shard locks in ascending
+// shard order.
 type InMemoryGraph struct {
-	mu sync.RWMutex
+	cfg    Config
+	ns     string
+	logger *slog.Logger
 
-	links map[uuid.UUID]*graph.Link
-	edges map[uuid.UUID]*graph.Edge
+	linkShards []*linkShard
+	urlShards  []*urlShard
 
-	linkURLIndex map[string]*graph.Link
-	linkEdgeMap  map[uuid.UUID]edgeList
+	cpMu        sync.RWMutex
+	checkpoints map[int]*graph.Checkpoint
 }
@@ -4,9 +4,7 @@ import "webcrawler/crawler/linkgraph/graph"
 
 // linkIterator is a graph.LinkIterator implementation for the in-memory graph.
 type linkIterator struct {
-	s *InMemoryGraph
-
-	links    []*graph.Link
+	links    []shardLink
 	curIndex int
 }
 
@@ -32,19 +30,23 @@ func (i *linkIterator) Close() error {
 // Link implements graph.LinkIterator.
 func (i *linkIterator) Link() *graph.Link {
 	// The link pointer contents may be overwritten by a graph update; to
-	// avoid data-races we acquire the read lock first and clone the link
-	i.s.mu.RLock()
+	// avoid data-races we acquire the shard read lock first and clone the
+	// link. Snapshot links never change so they can be cloned without
+	// locking.
+	cur := i.links[i.curIndex-1]
+	if cur.shard == nil {
+		return copyLink(cur.link)
+	}
+	cur.shard.mu.RLock()
 	link := new(graph.Link)
-	*link = *i.links[i.curIndex-1]
-	i.s.mu.RUnlock()
+	*link = *cur.link
+	cur.shard.mu.RUnlock()
 	return link
 }
 
 // edgeIterator is a graph.EdgeIterator implementation for the in-memory graph.
 type edgeIterator struct {
-	s *InMemoryGraph
-
-	edges    []*graph.Edge
+	edges    []shardEdge
 	curIndex int
 }
 
@@ -70,10 +72,80 @@ func (i *edgeIterator) Close() error {
 // Link implements graph.LinkIterator.
 func (i *edgeIterator) Edge() *graph.Edge {
 	// The edge pointer contents may be overwritten by a graph update; to
-	// avoid data-races we acquire the read lock first and clone the edge
-	i.s.mu.RLock()
+	// avoid data-races we acquire the shard read lock first and clone the
+	// edge. Snapshot edges never change so they can be cloned without
+	// locking.
+	cur := i.edges[i.curIndex-1]
+	if cur.shard == nil {
+		return copyEdge(cur.edge)
+	}
+	cur.shard.mu.RLock()
 	edge := new(graph.Edge)
-	*edge = *i.edges[i.curIndex-1]
-	i.s.mu.RUnlock()
+	*edge = *cur.edge
+	cur.shard.mu.RUnlock()
 	return edge
 }
+
+// changeIterator is a graph.ChangeIterator implementation for the in-memory graph.
+type changeIterator struct {
+	changes  []*graph.Change
+	curIndex int
+}
+
+// Next implements graph.ChangeIterator.
+func (i *changeIterator) Next() bool {
+	if i.curIndex >= len(i.changes) {
+		return false
+	}
+	i.curIndex++
+	return true
+}
+
+// Error implements graph.ChangeIterator.
+func (i *changeIterator) Error() error {
+	return nil
+}
+
+// Close implements graph.ChangeIterator.
+func (i *changeIterator) Close() error {
+	return nil
+}
+
+// Change implements graph.ChangeIterator.
+func (i *changeIterator) Change() *graph.Change {
+	// Changes hold copies of the graph entries so no locking is required.
+	return i.changes[i.curIndex-1]
+}
+
+// failureIterator is a graph.LinkFailureIterator implementation for the
+// in-memory graph.
+type failureIterator struct {
+	failures []*graph.LinkFailure
+	curIndex int
+}
+
+// Next implements graph.LinkFailureIterator.
+func (i *failureIterator) Next() bool {
+	if i.curIndex >= len(i.failures) {
+		return false
+	}
+	i.curIndex++
+	return true
+}
+
+// Error implements graph.LinkFailureIterator.
+func (i *failureIterator) Error() error {
+	return nil
+}
+
+// Close implements graph.LinkFailureIterator.
+func (i *failureIterator) Close() error {
+	return nil
+}
+
+// Failure implements graph.LinkFailureIterator.
+func (i *failureIterator) Failure() *graph.LinkFailure {
+	// Failure records are copied when the iterator is created so no
+	// locking is required.
+	return i.failures[i.curIndex-1]
+}
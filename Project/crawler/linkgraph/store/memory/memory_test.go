@@ -1,9 +1,20 @@
 package memory
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
 	"webcrawler/crawler/linkgraph/graph/graphtest"
+	"webcrawler/namespace"
+	"webcrawler/partition"
 
+	"github.com/google/uuid"
 	gc "gopkg.in/check.v1"
 )
 
@@ -18,3 +29,171 @@ type InMemoryGraphTestSuite struct {
 func (s *InMemoryGraphTestSuite) SetUpTest(c *gc.C) {
 	s.SetGraph(NewInMemoryGraph())
 }
+
+var _ = gc.Suite(new(IntegrityTestSuite))
+
+type IntegrityTestSuite struct{}
+
+func (s *IntegrityTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewInMemoryGraphWithConfig(Config{MaxURLLength: -1, OnLinkRemoval: KeepEdges + 1, Namespace: "Bad Name"})
+	c.Assert(err, gc.ErrorMatches, "(?s).*max URL length must not be negative.*unknown link removal policy.*invalid namespace.*")
+}
+
+func (s *IntegrityTestSuite) TestNamespace(c *gc.C) {
+	g := NewInMemoryGraph()
+	src, dst := s.linkPair(c, g)
+	c.Assert(src.Namespace, gc.Equals, namespace.Default)
+
+	g, err := NewInMemoryGraphWithConfig(Config{Namespace: "crawl-1"})
+	c.Assert(err, gc.IsNil)
+	src, dst = s.linkPair(c, g)
+	c.Assert(src.Namespace, gc.Equals, "crawl-1")
+
+	edge := &graph.Edge{Src: src.ID, Dst: dst.ID}
+	c.Assert(g.UpsertEdge(edge), gc.IsNil)
+	c.Assert(edge.Namespace, gc.Equals, "crawl-1")
+
+	found, err := g.FindLink(dst.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(found.Namespace, gc.Equals, "crawl-1")
+}
+
+func (s *IntegrityTestSuite) TestURLConstraints(c *gc.C) {
+	g, err := NewInMemoryGraphWithConfig(Config{ValidateURLs: true, MaxURLLength: 32})
+	c.Assert(err, gc.IsNil)
+
+	for _, rawURL := range []string{
+		"",
+		"foo",
+		"/relative/path",
+		"http://",
+		"http://example.com/%zz",
+		"https://example.com/" + strings.Repeat("a", 32),
+	} {
+		err = g.UpsertLink(&graph.Link{URL: rawURL})
+		c.Assert(errors.Is(err, graph.ErrInvalidURL), gc.Equals, true, gc.Commentf("url %q", rawURL))
+	}
+
+	c.Assert(g.UpsertLink(&graph.Link{URL: "https://example.com"}), gc.IsNil)
+}
+
+func (s *IntegrityTestSuite) TestRestrictLinkRemoval(c *gc.C) {
+	g, err := NewInMemoryGraphWithConfig(Config{OnLinkRemoval: RestrictIfEdges})
+	c.Assert(err, gc.IsNil)
+	src, dst := s.linkPair(c, g)
+
+	err = g.RemoveLink(dst.ID)
+	c.Assert(errors.Is(err, graph.ErrLinkHasEdges), gc.Equals, true)
+	_, err = g.FindLink(dst.ID)
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(g.RemoveStaleEdges(src.ID, time.Now().Add(time.Minute).Unix()), gc.IsNil)
+	c.Assert(g.RemoveLink(dst.ID), gc.IsNil)
+}
+
+func (s *IntegrityTestSuite) TestKeepEdgesOnLinkRemoval(c *gc.C) {
+	g, err := NewInMemoryGraphWithConfig(Config{OnLinkRemoval: KeepEdges})
+	c.Assert(err, gc.IsNil)
+	src, dst := s.linkPair(c, g)
+
+	c.Assert(g.RemoveLink(dst.ID), gc.IsNil)
+	c.Assert(g.linkShardFor(src.ID).linkEdgeMap[src.ID], gc.HasLen, 1)
+}
+
+func (s *IntegrityTestSuite) linkPair(c *gc.C, g *InMemoryGraph) (*graph.Link, *graph.Link) {
+	src := &graph.Link{URL: "https://example.com"}
+	dst := &graph.Link{URL: "https://example.com/about"}
+	c.Assert(g.UpsertLink(src), gc.IsNil)
+	c.Assert(g.UpsertLink(dst), gc.IsNil)
+	c.Assert(g.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID}), gc.IsNil)
+	return src, dst
+}
+
+var _ = gc.Suite(new(SnapshotTestSuite))
+
+type SnapshotTestSuite struct{}
+
+func (s *SnapshotTestSuite) TestIterateWhileUpdating(c *gc.C) {
+	g := NewInMemoryGraph()
+	src := &graph.Link{URL: "https://example.com"}
+	c.Assert(g.UpsertLink(src), gc.IsNil)
+	for i := 0; i < 100; i++ {
+		dst := &graph.Link{URL: fmt.Sprintf("https://example.com/%d", i)}
+		c.Assert(g.UpsertLink(dst), gc.IsNil)
+		c.Assert(g.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID}), gc.IsNil)
+	}
+	snap := g.Snapshot()
+
+	// Keep updating the graph while the snapshot is iterated.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			dst := &graph.Link{URL: fmt.Sprintf("https://example.org/%d", i)}
+			c.Check(g.UpsertLink(dst), gc.IsNil)
+			c.Check(g.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID}), gc.IsNil)
+			c.Check(g.UpsertLink(&graph.Link{URL: src.URL, RetrievedAt: time.Now().Unix()}), gc.IsNil)
+		}
+	}()
+
+	for round := 0; round < 10; round++ {
+		linkIt, err := snap.Links(uuid.Nil, partition.MaxUUID, math.MaxInt64)
+		c.Assert(err, gc.IsNil)
+		var links int
+		for linkIt.Next() {
+			c.Assert(linkIt.Link().RetrievedAt, gc.Equals, int64(0))
+			links++
+		}
+		c.Assert(links, gc.Equals, 101)
+
+		edgeIt, err := snap.Edges(uuid.Nil, partition.MaxUUID, math.MaxInt64)
+		c.Assert(err, gc.IsNil)
+		var edges int
+		for edgeIt.Next() {
+			edgeIt.Edge()
+			edges++
+		}
+		c.Assert(edges, gc.Equals, 100)
+	}
+	close(done)
+	wg.Wait()
+}
+
+// BenchmarkConcurrentUpserts measures the throughput of concurrent link and
+// edge upserts when the graph consists of a single shard (equivalent to
+// guarding the whole graph with one lock) and when it is fully sharded.
+func BenchmarkConcurrentUpserts(b *testing.B) {
+	for _, shards := range []int{1, numShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			g := newInMemoryGraph(Config{}, shards)
+			var seq int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var prev *graph.Link
+				for pb.Next() {
+					link := &graph.Link{
+						URL:         fmt.Sprintf("https://example.com/%d", atomic.AddInt64(&seq, 1)),
+						RetrievedAt: time.Now().Unix(),
+					}
+					if err := g.UpsertLink(link); err != nil {
+						b.Fatal(err)
+					}
+					if prev != nil {
+						if err := g.UpsertEdge(&graph.Edge{Src: prev.ID, Dst: link.ID}); err != nil {
+							b.Fatal(err)
+						}
+					}
+					prev = link
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+)
+
+// saveFormatVersion is the version of the format written by Save.
+const saveFormatVersion = 1
+
+// saveHeader is the first value of a saved graph.
+type saveHeader struct {
+	Version   int
+	Namespace string
+}
+
+// saveRecord is an entry of a saved graph. Exactly one of its fields is set.
+type saveRecord struct {
+	Link       *graph.Link
+	Edge       *graph.Edge
+	Removal    *savedRemoval
+	Failure    *graph.LinkFailure
+	Checkpoint *graph.Checkpoint
+}
+
+// savedRemoval is the saved form of an edgeRemoval.
+type savedRemoval struct {
+	Edge   *graph.Edge
+	PassID uint64
+}
+
+// Save writes the links, edges, failure records and checkpoints of the graph
+// to w as a gob stream that can be restored with Load. The graph is saved
+// from a snapshot so it can be updated while it is being saved.
+func (s *InMemoryGraph) Save(w io.Writer) error {
+	snap := s.snapshot()
+
+	// Checkpoints are replaced rather than modified so they can be
+	// encoded after releasing the lock.
+	s.cpMu.RLock()
+	checkpoints := make([]*graph.Checkpoint, 0, len(s.checkpoints))
+	for _, cp := range s.checkpoints {
+		checkpoints = append(checkpoints, cp)
+	}
+	s.cpMu.RUnlock()
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(saveHeader{Version: saveFormatVersion, Namespace: s.ns}); err != nil {
+		return fmt.Errorf("save graph: %w", err)
+	}
+	encode := func(rec saveRecord) error {
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("save graph: %w", err)
+		}
+		return nil
+	}
+
+	for _, data := range snap.shards {
+		for _, link := range data.links {
+			if err := encode(saveRecord{Link: link}); err != nil {
+				return err
+			}
+		}
+		// Edges are saved in the order they were added to their source
+		// link.
+		for _, list := range data.linkEdgeMap {
+			for _, edgeID := range list {
+				if err := encode(saveRecord{Edge: data.edges[edgeID]}); err != nil {
+					return err
+				}
+			}
+		}
+		for _, removal := range data.edgeRemovals {
+			if err := encode(saveRecord{Removal: &savedRemoval{Edge: removal.edge, PassID: removal.passID}}); err != nil {
+				return err
+			}
+		}
+		for _, f := range data.failures {
+			if err := encode(saveRecord{Failure: f}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, cp := range checkpoints {
+		if err := encode(saveRecord{Checkpoint: cp}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load replaces the contents of the graph with the contents read from r,
+// which must have been written by Save. The graph is left unchanged if the
+// contents cannot be read.
+func (s *InMemoryGraph) Load(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	var hdr saveHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return fmt.Errorf("load graph: %w", err)
+	}
+	if hdr.Version != saveFormatVersion {
+		return fmt.Errorf("load graph: unsupported format version %d", hdr.Version)
+	}
+	if hdr.Namespace != s.ns {
+		return fmt.Errorf("load graph: saved graph belongs to namespace %q", hdr.Namespace)
+	}
+
+	var (
+		numShards   = len(s.linkShards)
+		shards      = make([]shardData, numShards)
+		urls        = make([]map[string]uuid.UUID, numShards)
+		checkpoints = make(map[int]*graph.Checkpoint)
+	)
+	for i := range shards {
+		shards[i] = newLinkShard().shardData
+		urls[i] = make(map[string]uuid.UUID)
+	}
+	for {
+		var rec saveRecord
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("load graph: %w", err)
+		}
+
+		switch {
+		case rec.Link != nil:
+			data := &shards[shardIndex(rec.Link.ID, numShards)]
+			data.links[rec.Link.ID] = rec.Link
+			urls[urlShardIndex(rec.Link.URL, numShards)][rec.Link.URL] = rec.Link.ID
+		case rec.Edge != nil:
+			data := &shards[shardIndex(rec.Edge.Src, numShards)]
+			data.edges[rec.Edge.ID] = rec.Edge
+			data.linkEdgeMap[rec.Edge.Src] = append(data.linkEdgeMap[rec.Edge.Src], rec.Edge.ID)
+		case rec.Removal != nil && rec.Removal.Edge != nil:
+			data := &shards[shardIndex(rec.Removal.Edge.Src, numShards)]
+			data.edgeRemovals = append(data.edgeRemovals, edgeRemoval{edge: rec.Removal.Edge, passID: rec.Removal.PassID})
+		case rec.Failure != nil:
+			data := &shards[shardIndex(rec.Failure.LinkID, numShards)]
+			data.failures[rec.Failure.LinkID] = rec.Failure
+		case rec.Checkpoint != nil:
+			checkpoints[rec.Checkpoint.Partition] = rec.Checkpoint
+		default:
+			return fmt.Errorf("load graph: malformed record")
+		}
+	}
+
+	// Swap the contents of the graph while holding all locks in the
+	// documented order.
+	for _, us := range s.urlShards {
+		us.mu.Lock()
+	}
+	for _, ls := range s.linkShards {
+		ls.mu.Lock()
+	}
+	s.cpMu.Lock()
+	for i := range s.linkShards {
+		s.linkShards[i].shardData = shards[i]
+		s.linkShards[i].shared = false
+		s.urlShards[i].links = urls[i]
+	}
+	s.checkpoints = checkpoints
+	s.cpMu.Unlock()
+	for _, ls := range s.linkShards {
+		ls.mu.Unlock()
+	}
+	for _, us := range s.urlShards {
+		us.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/partition"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(PersistenceTestSuite))
+
+type PersistenceTestSuite struct{}
+
+func (s *PersistenceTestSuite) TestSaveAndLoad(c *gc.C) {
+	g := NewInMemoryGraph()
+	var (
+		a = &graph.Link{URL: "https://example.com/a", PassID: 1, RetrievedAt: 100}
+		b = &graph.Link{URL: "https://example.com/b", PassID: 1}
+		d = &graph.Link{URL: "https://example.com/d", PassID: 2}
+	)
+	c.Assert(g.UpsertLink(a), gc.IsNil)
+	c.Assert(g.UpsertLink(b), gc.IsNil)
+	c.Assert(g.UpsertEdge(&graph.Edge{Src: a.ID, Dst: b.ID, PassID: 1, AnchorText: "b"}), gc.IsNil)
+
+	// Recrawl a in pass 2 so that its edge to b is recorded as removed.
+	a.PassID = 2
+	c.Assert(g.UpsertLink(a), gc.IsNil)
+	c.Assert(g.RemoveStaleEdges(a.ID, time.Now().Add(time.Hour).Unix()), gc.IsNil)
+	c.Assert(g.UpsertLink(d), gc.IsNil)
+	c.Assert(g.UpsertEdge(&graph.Edge{Src: a.ID, Dst: d.ID, PassID: 2, AnchorText: "d"}), gc.IsNil)
+	c.Assert(g.RecordLinkFailure(&graph.LinkFailure{LinkID: b.ID, Reason: "timeout", Attempts: 2, FailedAt: 200, NextRetryAt: 300}), gc.IsNil)
+	c.Assert(g.SaveCheckpoint(&graph.Checkpoint{Partition: 3, PassID: 2, PassStartedAt: time.Unix(1000, 0).UTC()}), gc.IsNil)
+
+	var buf bytes.Buffer
+	c.Assert(g.Save(&buf), gc.IsNil)
+	restored := NewInMemoryGraph()
+	c.Assert(restored.Load(&buf), gc.IsNil)
+
+	for _, link := range []*graph.Link{a, b, d} {
+		exp, err := g.FindLink(link.ID)
+		c.Assert(err, gc.IsNil)
+		got, err := restored.FindLink(link.ID)
+		c.Assert(err, gc.IsNil)
+		c.Assert(got, gc.DeepEquals, exp)
+	}
+	c.Assert(s.edges(c, restored), gc.DeepEquals, s.edges(c, g))
+	// Edges removed by later passes are restored as well.
+	asOfPass1 := s.edgesAsOf(c, restored, 1)
+	c.Assert(asOfPass1, gc.HasLen, 1)
+	c.Assert(asOfPass1, gc.DeepEquals, s.edgesAsOf(c, g, 1))
+
+	f, err := restored.LinkFailure(b.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(f.Reason, gc.Equals, "timeout")
+	cp, err := restored.Checkpoint(3)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cp.PassID, gc.Equals, uint64(2))
+
+	// The URL index is rebuilt so upserts of known URLs update the
+	// existing links.
+	dup := &graph.Link{URL: b.URL}
+	c.Assert(restored.UpsertLink(dup), gc.IsNil)
+	c.Assert(dup.ID, gc.Equals, b.ID)
+}
+
+func (s *PersistenceTestSuite) TestLoadRejectsOtherNamespaces(c *gc.C) {
+	g, err := NewInMemoryGraphWithConfig(Config{Namespace: "other"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(g.UpsertLink(&graph.Link{URL: "https://example.com"}), gc.IsNil)
+
+	var buf bytes.Buffer
+	c.Assert(g.Save(&buf), gc.IsNil)
+	restored := NewInMemoryGraph()
+	err = restored.Load(&buf)
+	c.Assert(err, gc.ErrorMatches, `load graph: saved graph belongs to namespace "other"`)
+}
+
+func (s *PersistenceTestSuite) TestFailedLoadKeepsContents(c *gc.C) {
+	g := NewInMemoryGraph()
+	link := &graph.Link{URL: "https://example.com"}
+	c.Assert(g.UpsertLink(link), gc.IsNil)
+
+	// A truncated stream must not replace the existing contents.
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	c.Assert(enc.Encode(saveHeader{Version: saveFormatVersion, Namespace: g.ns}), gc.IsNil)
+	c.Assert(enc.Encode(saveRecord{Link: &graph.Link{ID: uuid.New(), URL: "https://example.org"}}), gc.IsNil)
+	data := buf.Bytes()
+	c.Assert(g.Load(bytes.NewReader(data[:len(data)-3])), gc.NotNil)
+
+	_, err := g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(g.Load(bytes.NewReader(nil)), gc.ErrorMatches, "load graph: EOF")
+}
+
+func (s *PersistenceTestSuite) edges(c *gc.C, g *InMemoryGraph) map[uuid.UUID]graph.Edge {
+	it, err := g.Edges(uuid.Nil, partition.MaxUUID, math.MaxInt64)
+	c.Assert(err, gc.IsNil)
+	return s.collectEdges(c, it)
+}
+
+func (s *PersistenceTestSuite) edgesAsOf(c *gc.C, g *InMemoryGraph, passID uint64) map[uuid.UUID]graph.Edge {
+	it, err := g.EdgesAsOf(uuid.Nil, partition.MaxUUID, passID)
+	c.Assert(err, gc.IsNil)
+	return s.collectEdges(c, it)
+}
+
+func (s *PersistenceTestSuite) collectEdges(c *gc.C, it graph.EdgeIterator) map[uuid.UUID]graph.Edge {
+	edges := make(map[uuid.UUID]graph.Edge)
+	for it.Next() {
+		edge := it.Edge()
+		edges[edge.ID] = *edge
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	return edges
+}
@@ -0,0 +1,195 @@
+package memory
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+)
+
+func newLinkShard() *linkShard {
+	return &linkShard{
+		shardData: shardData{
+			links:       make(map[uuid.UUID]*graph.Link),
+			edges:       make(map[uuid.UUID]*graph.Edge),
+			linkEdgeMap: make(map[uuid.UUID]edgeList),
+			failures:    make(map[uuid.UUID]*graph.LinkFailure),
+		},
+	}
+}
+
+// unshare clones the shard data if it is shared with a snapshot so that it
+// can be modified. It must be called while holding the shard write lock.
+func (ls *linkShard) unshare() {
+	if !ls.shared {
+		return
+	}
+
+	d := shardData{
+		links:        make(map[uuid.UUID]*graph.Link, len(ls.links)),
+		edges:        make(map[uuid.UUID]*graph.Edge, len(ls.edges)),
+		linkEdgeMap:  make(map[uuid.UUID]edgeList, len(ls.linkEdgeMap)),
+		edgeRemovals: append([]edgeRemoval(nil), ls.edgeRemovals...),
+		failures:     make(map[uuid.UUID]*graph.LinkFailure, len(ls.failures)),
+	}
+	for id, link := range ls.links {
+		d.links[id] = copyLink(link)
+	}
+	for id, edge := range ls.edges {
+		d.edges[id] = copyEdge(edge)
+	}
+	for id, list := range ls.linkEdgeMap {
+		d.linkEdgeMap[id] = append(edgeList(nil), list...)
+	}
+	// Failure records are replaced rather than modified so they can
+	// be shared.
+	for id, f := range ls.failures {
+		d.failures[id] = f
+	}
+	ls.shardData = d
+	ls.shared = false
+}
+
+// appendLinks appends the links whose IDs belong to the [from, to) range and
+// that satisfy match to list. The entries are associated with owner.
+func (d *shardData) appendLinks(list []shardLink, owner *linkShard, from, to string, match func(*graph.Link) bool) []shardLink {
+	for linkID, link := range d.links {
+		if id := linkID.String(); id >= from && id < to && match(link) {
+			list = append(list, shardLink{shard: owner, link: link})
+		}
+	}
+	return list
+}
+
+// appendEdges appends the edges whose source IDs belong to the [from, to)
+// range and that were updated before updatedBefore to list. The entries are
+// associated with owner.
+func (d *shardData) appendEdges(list []shardEdge, owner *linkShard, from, to string, updatedBefore int64) []shardEdge {
+	for linkID := range d.links {
+		if id := linkID.String(); id < from || id >= to {
+			continue
+		}
+
+		for _, edgeID := range d.linkEdgeMap[linkID] {
+			if edge := d.edges[edgeID]; edge.UpdatedAt < updatedBefore {
+				list = append(list, shardEdge{shard: owner, edge: edge})
+			}
+		}
+	}
+	return list
+}
+
+// appendEdgesAsOf appends the edges whose source IDs belong to the [from, to)
+// range and that were present at the end of the specified pass to list. The
+// entries are associated with owner.
+func (d *shardData) appendEdgesAsOf(list []shardEdge, owner *linkShard, from, to string, passID uint64) []shardEdge {
+	inRange := func(src uuid.UUID) bool {
+		id := src.String()
+		return id >= from && id < to
+	}
+
+	for _, edge := range d.edges {
+		if inRange(edge.Src) && edge.FirstPassID <= passID {
+			list = append(list, shardEdge{shard: owner, edge: edge})
+		}
+	}
+
+	// Include edges that existed at the end of the pass but have been
+	// removed by a subsequent pass.
+	for _, removal := range d.edgeRemovals {
+		if inRange(removal.edge.Src) && removal.edge.FirstPassID <= passID && removal.passID > passID {
+			list = append(list, shardEdge{shard: owner, edge: removal.edge})
+		}
+	}
+	return list
+}
+
+// appendEdgesToDsts appends the edges whose destination IDs belong to the
+// [from, to) range to list. The entries are associated with owner.
+func (d *shardData) appendEdgesToDsts(list []shardEdge, owner *linkShard, from, to string) []shardEdge {
+	for _, edge := range d.edges {
+		if id := edge.Dst.String(); id >= from && id < to {
+			list = append(list, shardEdge{shard: owner, edge: edge})
+		}
+	}
+	return list
+}
+
+// sortEdgesByDst sorts edges by destination and source ID. The source and
+// destination of an edge never change so they can be compared without holding
+// any locks.
+func sortEdgesByDst(list []shardEdge) {
+	sort.Slice(list, func(i, j int) bool {
+		edgeI, edgeJ := list[i].edge, list[j].edge
+		if dstI, dstJ := edgeI.Dst.String(), edgeJ.Dst.String(); dstI != dstJ {
+			return dstI < dstJ
+		}
+		return edgeI.Src.String() < edgeJ.Src.String()
+	})
+}
+
+// shardIndex returns the index of the link shard that holds the link with
+// the specified ID. Shards cover consecutive ID ranges so that range queries
+// only need to visit the shards that overlap the queried range.
+func shardIndex(id uuid.UUID, shards int) int {
+	return int(id[0]) * shards / 256
+}
+
+// urlShardIndex returns the index of the URL shard that indexes the
+// specified link URL.
+func urlShardIndex(linkURL string, shards int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(linkURL))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// shardRange returns the [lo, hi) range of the indices of the shards that may
+// hold links whose IDs belong to the [fromID, toID) range.
+func shardRange(fromID, toID uuid.UUID, shards int) (int, int) {
+	if bytes.Compare(fromID[:], toID[:]) >= 0 {
+		return 0, 0
+	}
+	return shardIndex(fromID, shards), shardIndex(toID, shards) + 1
+}
+
+// linkShardFor returns the shard that holds the link with the specified ID.
+func (s *InMemoryGraph) linkShardFor(id uuid.UUID) *linkShard {
+	return s.linkShards[shardIndex(id, len(s.linkShards))]
+}
+
+// urlShardFor returns the shard that indexes the specified link URL.
+func (s *InMemoryGraph) urlShardFor(linkURL string) *urlShard {
+	return s.urlShards[urlShardIndex(linkURL, len(s.urlShards))]
+}
+
+// shardsInRange returns the link shards that may hold links whose IDs belong
+// to the [fromID, toID) range.
+func (s *InMemoryGraph) shardsInRange(fromID, toID uuid.UUID) []*linkShard {
+	lo, hi := shardRange(fromID, toID, len(s.linkShards))
+	return s.linkShards[lo:hi]
+}
+
+// lockEdgeShards write-locks the shard of the source link of an edge and
+// read-locks the shard of its destination link. It returns both shards and a
+// function that releases the acquired locks.
+func (s *InMemoryGraph) lockEdgeShards(srcID, dstID uuid.UUID) (*linkShard, *linkShard, func()) {
+	srcIdx, dstIdx := shardIndex(srcID, len(s.linkShards)), shardIndex(dstID, len(s.linkShards))
+	src, dst := s.linkShards[srcIdx], s.linkShards[dstIdx]
+	switch {
+	case srcIdx == dstIdx:
+		src.mu.Lock()
+		return src, dst, src.mu.Unlock
+	case srcIdx < dstIdx:
+		src.mu.Lock()
+		dst.mu.RLock()
+	default:
+		dst.mu.RLock()
+		src.mu.Lock()
+	}
+	return src, dst, func() {
+		dst.mu.RUnlock()
+		src.mu.Unlock()
+	}
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"fmt"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+)
+
+// Compile-time checks for ensuring snapshot implements Snapshot and
+// EdgeGrouper.
+var (
+	_ graph.Snapshot    = (*snapshot)(nil)
+	_ graph.EdgeGrouper = (*snapshot)(nil)
+)
+
+// snapshot is a read-only view of an in-memory graph. It shares the data of
+// the graph shards until they are modified; writers clone the data of a
+// shard before their first update after the snapshot was taken, so the data
+// referenced by the snapshot never changes and can be read without locking.
+type snapshot struct {
+	shards []*shardData
+}
+
+// Snapshot returns a read-only view of the graph at the time of the call.
+// Taking a snapshot does not copy any data; instead, the data of each shard
+// is copied the next time that the shard is modified.
+func (s *InMemoryGraph) Snapshot() graph.Snapshot {
+	return s.snapshot()
+}
+
+func (s *InMemoryGraph) snapshot() *snapshot {
+	// Acquire all shard locks so that the snapshot reflects the graph at
+	// a single point in time.
+	for _, shard := range s.linkShards {
+		shard.mu.Lock()
+	}
+
+	snap := &snapshot{shards: make([]*shardData, len(s.linkShards))}
+	for i, shard := range s.linkShards {
+		data := shard.shardData
+		snap.shards[i] = &data
+		shard.shared = true
+		shard.mu.Unlock()
+	}
+	return snap
+}
+
+// FindLink looks up a link by its ID.
+func (s *snapshot) FindLink(id uuid.UUID) (*graph.Link, error) {
+	link := s.shards[shardIndex(id, len(s.shards))].links[id]
+	if link == nil {
+		return nil, fmt.Errorf("find link: %w", graph.ErrNotFound)
+	}
+	return copyLink(link), nil
+}
+
+// Links returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and were due to be fetched before the provided unix
+// timestamp.
+func (s *snapshot) Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error) {
+	from, to := fromID.String(), toID.String()
+	match := func(link *graph.Link) bool { return link.NextFetchAt < dueBefore }
+
+	var list []shardLink
+	for _, data := range s.shardsInRange(fromID, toID) {
+		list = data.appendLinks(list, nil, from, to, match)
+	}
+	return &linkIterator{links: list}, nil
+}
+
+// LinksAsOf returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and had been added to the graph by the end of the
+// specified crawl pass.
+func (s *snapshot) LinksAsOf(fromID, toID uuid.UUID, passID uint64) (graph.LinkIterator, error) {
+	from, to := fromID.String(), toID.String()
+	match := func(link *graph.Link) bool { return link.FirstPassID <= passID }
+
+	var list []shardLink
+	for _, data := range s.shardsInRange(fromID, toID) {
+		list = data.appendLinks(list, nil, from, to, match)
+	}
+	return &linkIterator{links: list}, nil
+}
+
+// Edges returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were updated before the provided
+// unix timestamp.
+func (s *snapshot) Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error) {
+	from, to := fromID.String(), toID.String()
+
+	var list []shardEdge
+	for _, data := range s.shardsInRange(fromID, toID) {
+		list = data.appendEdges(list, nil, from, to, updatedBefore)
+	}
+	return &edgeIterator{edges: list}, nil
+}
+
+// EdgesAsOf returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were present in the graph at the end
+// of the specified crawl pass.
+func (s *snapshot) EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (graph.EdgeIterator, error) {
+	from, to := fromID.String(), toID.String()
+
+	var list []shardEdge
+	for _, data := range s.shardsInRange(fromID, toID) {
+		list = data.appendEdgesAsOf(list, nil, from, to, passID)
+	}
+	return &edgeIterator{edges: list}, nil
+}
+
+// EdgesGroupedByDst returns an iterator for the set of edges whose destination
+// vertex IDs belong to the [fromID, toID) range grouped by destination.
+func (s *snapshot) EdgesGroupedByDst(fromID, toID uuid.UUID) (graph.EdgeGroupIterator, error) {
+	from, to := fromID.String(), toID.String()
+
+	var list []shardEdge
+	for _, data := range s.shards {
+		list = data.appendEdgesToDsts(list, nil, from, to)
+	}
+
+	sortEdgesByDst(list)
+	return graph.GroupEdgesByDst(&edgeIterator{edges: list}), nil
+}
+
+// shardsInRange returns the data of the shards that may hold links whose IDs
+// belong to the [fromID, toID) range.
+func (s *snapshot) shardsInRange(fromID, toID uuid.UUID) []*shardData {
+	lo, hi := shardRange(fromID, toID, len(s.shards))
+	return s.shards[lo:hi]
+}
@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"webcrawler/crawler/linkgraph/graph"
+)
+
+// linkIterator is a graph.LinkIterator implementation for the sqlite graph.
+type linkIterator struct {
+	rows        *sql.Rows
+	ns          string
+	lastErr     error
+	latchedLink *graph.Link
+}
+
+// Next implements graph.LinkIterator.
+func (i *linkIterator) Next() bool {
+	if i.lastErr != nil || !i.rows.Next() {
+		return false
+	}
+
+	l := &graph.Link{Namespace: i.ns}
+	i.lastErr = i.rows.Scan(&l.ID, &l.URL, &l.RetrievedAt, &l.NextFetchAt, (*contentHash)(&l.ContentHash), &l.FirstPassID, &l.PassID)
+	if i.lastErr != nil {
+		return false
+	}
+
+	i.latchedLink = l
+	return true
+}
+
+// Error implements graph.LinkIterator.
+func (i *linkIterator) Error() error {
+	return i.lastErr
+}
+
+// Close implements graph.LinkIterator.
+func (i *linkIterator) Close() error {
+	err := i.rows.Close()
+	if err != nil {
+		return fmt.Errorf("link iterator: %w", err)
+	}
+	return nil
+}
+
+// Link implements graph.LinkIterator.
+func (i *linkIterator) Link() *graph.Link {
+	return i.latchedLink
+}
+
+// edgeIterator is a graph.EdgeIterator implementation for the sqlite graph.
+type edgeIterator struct {
+	rows        *sql.Rows
+	ns          string
+	lastErr     error
+	latchedEdge *graph.Edge
+}
+
+// Next implements graph.EdgeIterator.
+func (i *edgeIterator) Next() bool {
+	if i.lastErr != nil || !i.rows.Next() {
+		return false
+	}
+
+	e := &graph.Edge{Namespace: i.ns}
+	i.lastErr = i.rows.Scan(&e.ID, &e.Src, &e.Dst, &e.UpdatedAt, &e.FirstPassID, &e.PassID, &e.RelType, &e.AnchorText, &e.NoFollow)
+	if i.lastErr != nil {
+		return false
+	}
+
+	i.latchedEdge = e
+	return true
+}
+
+// Error implements graph.EdgeIterator.
+func (i *edgeIterator) Error() error {
+	return i.lastErr
+}
+
+// Close implements graph.EdgeIterator.
+func (i *edgeIterator) Close() error {
+	err := i.rows.Close()
+	if err != nil {
+		return fmt.Errorf("edge iterator: %w", err)
+	}
+	return nil
+}
+
+// Edge implements graph.EdgeIterator.
+func (i *edgeIterator) Edge() *graph.Edge {
+	return i.latchedEdge
+}
+
+// changeIterator is a graph.ChangeIterator implementation for the sqlite graph.
+// It first streams the link changes and then lazily queries for the edge
+// changes once the link changes have been exhausted.
+type changeIterator struct {
+	db           *sql.DB
+	ns           string
+	passA, passB uint64
+
+	rows          *sql.Rows
+	scanningEdges bool
+	lastErr       error
+	latchedChange *graph.Change
+}
+
+// Next implements graph.ChangeIterator.
+func (i *changeIterator) Next() bool {
+	for i.lastErr == nil {
+		if i.rows.Next() {
+			i.latchedChange, i.lastErr = i.scanChange()
+			return i.lastErr == nil
+		}
+
+		if i.lastErr = i.rows.Err(); i.lastErr != nil || i.scanningEdges {
+			return false
+		}
+
+		// Switch over to the edge changes.
+		if i.lastErr = i.rows.Close(); i.lastErr != nil {
+			return false
+		}
+		if i.rows, i.lastErr = i.db.Query(edgeChangesQuery, i.passA, i.passB, i.ns); i.lastErr != nil {
+			return false
+		}
+		i.scanningEdges = true
+	}
+
+	return false
+}
+
+func (i *changeIterator) scanChange() (*graph.Change, error) {
+	change := new(graph.Change)
+	if !i.scanningEdges {
+		l := &graph.Link{Namespace: i.ns}
+		err := i.rows.Scan(&change.Type, &l.ID, &l.URL, &l.RetrievedAt, &l.NextFetchAt, (*contentHash)(&l.ContentHash), &l.FirstPassID, &l.PassID)
+		change.Link = l
+		return change, err
+	}
+
+	e := &graph.Edge{Namespace: i.ns}
+	err := i.rows.Scan(&change.Type, &e.ID, &e.Src, &e.Dst, &e.UpdatedAt, &e.FirstPassID, &e.PassID, &e.RelType, &e.AnchorText, &e.NoFollow)
+	change.Edge = e
+	return change, err
+}
+
+// Error implements graph.ChangeIterator.
+func (i *changeIterator) Error() error {
+	return i.lastErr
+}
+
+// Close implements graph.ChangeIterator.
+func (i *changeIterator) Close() error {
+	if i.rows == nil {
+		return nil
+	}
+
+	err := i.rows.Close()
+	if err != nil {
+		return fmt.Errorf("change iterator: %w", err)
+	}
+	return nil
+}
+
+// Change implements graph.ChangeIterator.
+func (i *changeIterator) Change() *graph.Change {
+	return i.latchedChange
+}
+
+// failureIterator is a graph.LinkFailureIterator implementation for the
+// sqlite graph.
+type failureIterator struct {
+	rows           *sql.Rows
+	lastErr        error
+	latchedFailure *graph.LinkFailure
+}
+
+// Next implements graph.LinkFailureIterator.
+func (i *failureIterator) Next() bool {
+	if i.lastErr != nil || !i.rows.Next() {
+		return false
+	}
+
+	f := new(graph.LinkFailure)
+	i.lastErr = i.rows.Scan(&f.LinkID, &f.URL, &f.Reason, &f.Attempts, &f.FailedAt, &f.NextRetryAt, &f.Quarantined)
+	if i.lastErr != nil {
+		return false
+	}
+
+	i.latchedFailure = f
+	return true
+}
+
+// Error implements graph.LinkFailureIterator.
+func (i *failureIterator) Error() error {
+	return i.lastErr
+}
+
+// Close implements graph.LinkFailureIterator.
+func (i *failureIterator) Close() error {
+	err := i.rows.Close()
+	if err != nil {
+		return fmt.Errorf("link failure iterator: %w", err)
+	}
+	return nil
+}
+
+// Failure implements graph.LinkFailureIterator.
+func (i *failureIterator) Failure() *graph.LinkFailure {
+	return i.latchedFailure
+}
@@ -0,0 +1,678 @@
+// Package sqlite implements a link graph that is persisted to a local SQLite
+// database file. It targets single-node deployments that need to persist
+// their crawls without running a database server. The database is opened in
+// WAL mode so that iterators can read from it while links and edges are
+// being upserted.
+package sqlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+	"webcrawler/namespace"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+	_ "modernc.org/sqlite" // registers the pure-Go sqlite driver
+)
+
+// The time (in milliseconds) that a connection waits for a lock held by
+// another connection before failing with SQLITE_BUSY.
+const busyTimeoutMillis = 5000
+
+var (
+	// The schema is applied each time the database is opened. Link and
+	// edge IDs are stored as text so that partitions can be selected via
+	// range queries; text comparisons use the same ordering as the byte
+	// representation of UUIDs. Timestamps are stored as unix seconds,
+	// except for the checkpoint timestamps which use unix nanoseconds.
+	schema = `
+CREATE TABLE IF NOT EXISTS links (
+	id TEXT PRIMARY KEY,
+	namespace TEXT NOT NULL,
+	url TEXT NOT NULL,
+	retrieved_at INTEGER NOT NULL DEFAULT 0,
+	next_fetch_at INTEGER NOT NULL DEFAULT 0,
+	content_hash INTEGER NOT NULL DEFAULT 0,
+	first_pass_id INTEGER NOT NULL DEFAULT 0,
+	pass_id INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (namespace, url)
+);
+CREATE TABLE IF NOT EXISTS edges (
+	id TEXT PRIMARY KEY,
+	namespace TEXT NOT NULL,
+	src TEXT NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+	dst TEXT NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+	updated_at INTEGER NOT NULL,
+	first_pass_id INTEGER NOT NULL DEFAULT 0,
+	pass_id INTEGER NOT NULL DEFAULT 0,
+	rel_type INTEGER NOT NULL DEFAULT 0,
+	anchor_text TEXT NOT NULL DEFAULT '',
+	no_follow INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (src, dst)
+);
+CREATE INDEX IF NOT EXISTS edges_by_dst ON edges (dst);
+CREATE TABLE IF NOT EXISTS edge_removals (
+	id TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	src TEXT NOT NULL,
+	dst TEXT NOT NULL,
+	updated_at INTEGER NOT NULL,
+	first_pass_id INTEGER NOT NULL,
+	pass_id INTEGER NOT NULL,
+	removed_pass_id INTEGER NOT NULL,
+	rel_type INTEGER NOT NULL DEFAULT 0,
+	anchor_text TEXT NOT NULL DEFAULT '',
+	no_follow INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS edge_removals_by_src ON edge_removals (namespace, src);
+CREATE INDEX IF NOT EXISTS edge_removals_by_pass ON edge_removals (namespace, removed_pass_id);
+CREATE TABLE IF NOT EXISTS link_failures (
+	link_id TEXT PRIMARY KEY REFERENCES links(id) ON DELETE CASCADE,
+	namespace TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	failed_at INTEGER NOT NULL,
+	next_retry_at INTEGER NOT NULL,
+	quarantined INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS checkpoints (
+	namespace TEXT NOT NULL,
+	partition INTEGER NOT NULL,
+	pass_id INTEGER NOT NULL,
+	pass_started_at INTEGER NOT NULL,
+	completed_at INTEGER,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (namespace, partition)
+);
+`
+
+	// The columns that were added to the schema after its tables were
+	// first created. They are added to the tables of existing databases
+	// when they are opened.
+	addedColumns = []struct{ table, column, definition string }{
+		{"links", "next_fetch_at", "INTEGER NOT NULL DEFAULT 0"},
+		{"edges", "rel_type", "INTEGER NOT NULL DEFAULT 0"},
+		{"edge_removals", "rel_type", "INTEGER NOT NULL DEFAULT 0"},
+		{"edges", "anchor_text", "TEXT NOT NULL DEFAULT ''"},
+		{"edges", "no_follow", "INTEGER NOT NULL DEFAULT 0"},
+		{"edge_removals", "anchor_text", "TEXT NOT NULL DEFAULT ''"},
+		{"edge_removals", "no_follow", "INTEGER NOT NULL DEFAULT 0"},
+		{"links", "content_hash", "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	// The indices on added columns; they are created once the columns are
+	// present.
+	addedColumnIndices = `
+CREATE INDEX IF NOT EXISTS links_by_next_fetch_at ON links (namespace, next_fetch_at);
+`
+
+	// All queries are scoped to the namespace of the SQLiteGraph instance
+	// which is passed as the last argument.
+	upsertLinkQuery = `
+INSERT INTO links (id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id, namespace) VALUES (?1, ?2, ?3, ?4, ?7, ?5, ?5, ?6)
+ON CONFLICT (namespace, url) DO UPDATE SET
+	next_fetch_at=CASE
+		WHEN ?3 > links.retrieved_at THEN ?4
+		WHEN ?3 < links.retrieved_at THEN links.next_fetch_at
+		ELSE MAX(links.next_fetch_at, ?4)
+	END,
+	content_hash=CASE
+		WHEN ?3 > links.retrieved_at THEN ?7
+		WHEN ?3 = links.retrieved_at AND links.content_hash = 0 THEN ?7
+		ELSE links.content_hash
+	END,
+	retrieved_at=MAX(links.retrieved_at, ?3), pass_id=MAX(links.pass_id, ?5)
+RETURNING id, retrieved_at, next_fetch_at, content_hash, first_pass_id
+`
+	removeLinkQuery       = "DELETE FROM links WHERE id=?1 AND namespace=?2"
+	findLinkQuery         = "SELECT url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id FROM links WHERE id=?1 AND namespace=?2"
+	findLinksQuery        = "SELECT id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id FROM links WHERE id IN (SELECT value FROM json_each(?1)) AND namespace=?2"
+	linksInPartitionQuery = "SELECT id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id FROM links WHERE id >= ?1 AND id < ?2 AND next_fetch_at < ?3 AND namespace=?4"
+
+	// Edges may only connect links that belong to the namespace of the
+	// edge; no row is returned if either link is not part of it.
+	upsertEdgeQuery = `
+INSERT INTO edges (id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow, namespace)
+SELECT ?1, ?2, ?3, ?4, ?5, ?5, ?6, ?7, ?8, ?9
+WHERE EXISTS (SELECT 1 FROM links WHERE id=?2 AND namespace=?9) AND EXISTS (SELECT 1 FROM links WHERE id=?3 AND namespace=?9)
+ON CONFLICT (src, dst) DO UPDATE SET updated_at=?4, pass_id=MAX(edges.pass_id, ?5), rel_type=?6, anchor_text=?7, no_follow=?8
+RETURNING id, updated_at, first_pass_id, pass_id
+`
+	edgesInPartitionQuery = "SELECT id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow FROM edges WHERE src >= ?1 AND src < ?2 AND updated_at < ?3 AND namespace=?4"
+	edgesByDstQuery       = "SELECT id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow FROM edges WHERE dst >= ?1 AND dst < ?2 AND namespace=?3 ORDER BY dst, src"
+
+	// SQLite does not support data-modifying statements in CTEs so stale
+	// edges are copied to the edge_removals table before being deleted.
+	// Edge removals are attributed to the pass that last crawled the source
+	// link and recorded so they can be reported by Diff.
+	recordStaleEdgesQuery = `
+INSERT INTO edge_removals (id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow, removed_pass_id, namespace)
+SELECT edges.id, edges.src, edges.dst, edges.updated_at, edges.first_pass_id, edges.pass_id, edges.rel_type, edges.anchor_text, edges.no_follow, links.pass_id, ?3
+FROM edges JOIN links ON links.id = edges.src
+WHERE edges.src=?1 AND edges.updated_at < ?2 AND edges.namespace=?3 AND links.pass_id > 0
+`
+	removeStaleEdgesQuery = "DELETE FROM edges WHERE src=?1 AND updated_at < ?2 AND namespace=?3"
+
+	linkChangesQuery = `
+SELECT CASE WHEN first_pass_id > ?1 THEN 0 ELSE 1 END, id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id
+FROM links
+WHERE ((first_pass_id > ?1 AND first_pass_id <= ?2) OR (pass_id > ?1 AND pass_id <= ?2)) AND namespace=?3
+`
+	edgeChangesQuery = `
+SELECT 2, id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+FROM edges
+WHERE first_pass_id > ?1 AND first_pass_id <= ?2 AND namespace=?3
+UNION ALL
+SELECT CASE WHEN first_pass_id <= ?1 THEN 3 ELSE 2 END, id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+FROM edge_removals
+WHERE ((removed_pass_id > ?1 AND removed_pass_id <= ?2 AND first_pass_id <= ?1)
+   OR (first_pass_id > ?1 AND first_pass_id <= ?2 AND removed_pass_id > ?2)) AND namespace=?3
+`
+
+	linksAsOfQuery = "SELECT id, url, retrieved_at, next_fetch_at, content_hash, first_pass_id, pass_id FROM links WHERE id >= ?1 AND id < ?2 AND first_pass_id <= ?3 AND namespace=?4"
+
+	// Edges that were removed after the requested pass are read back from
+	// the edge_removals table. Both sets are fetched by the same statement
+	// so they are read from the same snapshot.
+	edgesAsOfQuery = `
+SELECT id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+FROM edges
+WHERE src >= ?1 AND src < ?2 AND first_pass_id <= ?3 AND namespace=?4
+UNION ALL
+SELECT id, src, dst, updated_at, first_pass_id, pass_id, rel_type, anchor_text, no_follow
+FROM edge_removals
+WHERE src >= ?1 AND src < ?2 AND first_pass_id <= ?3 AND removed_pass_id > ?3 AND namespace=?4
+`
+	pruneRemovalsQuery = "DELETE FROM edge_removals WHERE removed_pass_id <= ?1 AND namespace=?2"
+
+	// Failure records are kept until they are replaced or their link is
+	// removed; records whose link has been retrieved after the failure are
+	// filtered out by the queries.
+	rescheduleFailedLinkQuery = "UPDATE links SET next_fetch_at=?2 WHERE id=?1 AND namespace=?3 RETURNING url"
+	upsertLinkFailureQuery    = `
+INSERT INTO link_failures (link_id, reason, attempts, failed_at, next_retry_at, quarantined, namespace) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+ON CONFLICT (link_id) DO UPDATE SET reason=?2, attempts=?3, failed_at=?4, next_retry_at=?5, quarantined=?6
+`
+	findLinkFailureQuery = `
+SELECT f.link_id, l.url, f.reason, f.attempts, f.failed_at, f.next_retry_at, f.quarantined
+FROM link_failures f JOIN links l ON l.id = f.link_id
+WHERE f.link_id=?1 AND f.namespace=?2 AND f.failed_at >= l.retrieved_at
+`
+	failedLinksQuery = `
+SELECT f.link_id, l.url, f.reason, f.attempts, f.failed_at, f.next_retry_at, f.quarantined
+FROM link_failures f JOIN links l ON l.id = f.link_id
+WHERE f.link_id >= ?1 AND f.link_id < ?2 AND f.namespace=?3 AND f.failed_at >= l.retrieved_at
+`
+
+	saveCheckpointQuery = `
+INSERT INTO checkpoints (partition, pass_id, pass_started_at, completed_at, updated_at, namespace) VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+ON CONFLICT (namespace, partition) DO UPDATE SET pass_id=?2, pass_started_at=?3, completed_at=?4, updated_at=?5
+`
+	findCheckpointQuery = "SELECT pass_id, pass_started_at, completed_at, updated_at FROM checkpoints WHERE partition=?1 AND namespace=?2"
+	checkpointsQuery    = "SELECT partition, pass_id, pass_started_at, completed_at, updated_at FROM checkpoints WHERE namespace=?1 ORDER BY partition"
+
+	// Compile-time checks for ensuring SQLiteGraph implements Graph,
+	// CheckpointStore, RemovalPruner and EdgeGrouper.
+	_ graph.Graph           = (*SQLiteGraph)(nil)
+	_ graph.CheckpointStore = (*SQLiteGraph)(nil)
+	_ graph.RemovalPruner   = (*SQLiteGraph)(nil)
+	_ graph.EdgeGrouper     = (*SQLiteGraph)(nil)
+
+	upsertLinkDuration = metrics.GraphUpsertDuration.WithLabelValues("sqlite", "link")
+	upsertEdgeDuration = metrics.GraphUpsertDuration.WithLabelValues("sqlite", "edge")
+)
+
+// SQLiteGraph implements a graph that persists its links and edges to a
+// SQLite database file. Each SQLiteGraph is bound to a single namespace;
+// instances bound to different namespaces can share the same database file
+// without observing each other's links, edges and checkpoints.
+type SQLiteGraph struct {
+	db     *sql.DB
+	ns     string
+	logger *slog.Logger
+}
+
+// Config encapsulates the optional settings for a SQLiteGraph.
+type Config struct {
+	// The namespace of the crawl whose data is accessed through the
+	// graph. Defaults to namespace.Default.
+	Namespace string
+
+	// An optional logger for reporting removals. If not specified,
+	// nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if nsErr := namespace.Validate(namespace.OrDefault(cfg.Namespace)); nsErr != nil {
+		err = multierror.Append(err, nsErr)
+	}
+	return err
+}
+
+// NewSQLiteGraph returns a SQLiteGraph instance that stores its data in the
+// database file at path. The file is created if it does not exist.
+func NewSQLiteGraph(path string) (*SQLiteGraph, error) {
+	return NewSQLiteGraphWithConfig(path, Config{})
+}
+
+// NewSQLiteGraphWithConfig returns a SQLiteGraph instance that stores its
+// data in the database file at path and uses the settings in cfg. The file is
+// created if it does not exist.
+func NewSQLiteGraphWithConfig(path string, cfg Config) (*SQLiteGraph, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("sqlite graph: config validation failed: %w", err)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("sqlite graph: database path has not been specified")
+	}
+
+	db, err := sql.Open("sqlite", dataSourceName(path))
+	if err != nil {
+		return nil, fmt.Errorf("sqlite graph: %w", err)
+	}
+	if _, err = db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("sqlite graph: creating schema: %w", err)
+	}
+	if err = addMissingColumns(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("sqlite graph: updating schema: %w", err)
+	}
+
+	return &SQLiteGraph{
+		db:     db,
+		ns:     namespace.OrDefault(cfg.Namespace),
+		logger: logging.Component(cfg.Logger, "linkgraph.sqlite"),
+	}, nil
+}
+
+// addMissingColumns adds the columns listed in addedColumns to the tables of
+// databases that were created by older versions of the schema and indexes
+// them.
+func addMissingColumns(db *sql.DB) error {
+	for _, col := range addedColumns {
+		var present bool
+		row := db.QueryRow("SELECT COUNT(*) > 0 FROM pragma_table_info(?1) WHERE name=?2", col.table, col.column)
+		if err := row.Scan(&present); err != nil {
+			return err
+		}
+		if present {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.column, col.definition)); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec(addedColumnIndices)
+	return err
+}
+
+// dataSourceName returns the DSN for opening the database file at path. The
+// pragmas are applied to each connection of the pool: WAL mode allows readers
+// to proceed while a write is in progress, the busy timeout makes concurrent
+// writers wait for each other and foreign keys are required for removing the
+// edges of removed links. Transactions acquire the write lock upfront so that
+// they never fail when upgrading a read lock.
+func dataSourceName(path string) string {
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeoutMillis))
+	params.Add("_pragma", "foreign_keys(ON)")
+	params.Set("_txlock", "immediate")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// Namespace returns the namespace that the graph is bound to.
+func (c *SQLiteGraph) Namespace() string {
+	return c.ns
+}
+
+// Close closes the backing database.
+func (c *SQLiteGraph) Close() error {
+	return c.db.Close()
+}
+
+// UpsertLink creates a new link or updates an existing link.
+func (c *SQLiteGraph) UpsertLink(link *graph.Link) error {
+	defer metrics.ObserveSince(upsertLinkDuration, time.Now())
+	row := c.db.QueryRow(upsertLinkQuery, uuid.New(), link.URL, link.RetrievedAt, link.NextFetchAt, link.PassID, c.ns, contentHash(link.ContentHash))
+	if err := row.Scan(&link.ID, &link.RetrievedAt, &link.NextFetchAt, (*contentHash)(&link.ContentHash), &link.FirstPassID); err != nil {
+		return fmt.Errorf("upsert link: %w", err)
+	}
+	link.Namespace = c.ns
+
+	return nil
+}
+
+// FindLink looks up a link by its ID.
+func (c *SQLiteGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
+	row := c.db.QueryRow(findLinkQuery, id, c.ns)
+	link := &graph.Link{ID: id, Namespace: c.ns}
+	if err := row.Scan(&link.URL, &link.RetrievedAt, &link.NextFetchAt, (*contentHash)(&link.ContentHash), &link.FirstPassID, &link.PassID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("find link: %w", graph.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("find link: %w", err)
+	}
+
+	return link, nil
+}
+
+// FindLinks looks up the links with the specified IDs using a single query.
+// The returned slice is aligned with ids; missing links are reported via a
+// *graph.MissingLinksError.
+func (c *SQLiteGraph) FindLinks(ids []uuid.UUID) ([]*graph.Link, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// The IDs are passed as a JSON array to avoid running into the limit
+	// on the number of query parameters.
+	idList, err := json.Marshal(ids)
+	if err != nil {
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+	rows, err := c.db.Query(findLinksQuery, string(idList), c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+
+	it := &linkIterator{rows: rows, ns: c.ns}
+	found := make(map[uuid.UUID]*graph.Link, len(ids))
+	for it.Next() {
+		found[it.Link().ID] = it.Link()
+	}
+	if err = it.Error(); err != nil {
+		_ = it.Close()
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+	if err = it.Close(); err != nil {
+		return nil, fmt.Errorf("find links: %w", err)
+	}
+
+	links, err := graph.ArrangeLinks(ids, found)
+	if err != nil {
+		return links, fmt.Errorf("find links: %w", err)
+	}
+	return links, nil
+}
+
+// RemoveLink removes the link with the specified ID from the graph. Edges
+// that reference the link are removed by the cascading foreign key
+// constraints of the edges table.
+func (c *SQLiteGraph) RemoveLink(id uuid.UUID) error {
+	res, err := c.db.Exec(removeLinkQuery, id, c.ns)
+	if err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	}
+
+	if affected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	} else if affected == 0 {
+		return fmt.Errorf("remove link: %w", graph.ErrNotFound)
+	}
+
+	c.logger.Debug("removed link", logging.Link(id, ""))
+	return nil
+}
+
+// Links returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and are due to be fetched before the provided value.
+func (c *SQLiteGraph) Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error) {
+	rows, err := c.db.Query(linksInPartitionQuery, fromID, toID, dueBefore, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("links: %w", err)
+	}
+
+	return &linkIterator{rows: rows, ns: c.ns}, nil
+}
+
+// UpsertEdge creates a new edge or updates an existing edge.
+func (c *SQLiteGraph) UpsertEdge(edge *graph.Edge) error {
+	defer metrics.ObserveSince(upsertEdgeDuration, time.Now())
+	row := c.db.QueryRow(upsertEdgeQuery, uuid.New(), edge.Src, edge.Dst, time.Now().Unix(), edge.PassID, edge.RelType, edge.AnchorText, edge.NoFollow, c.ns)
+	if err := row.Scan(&edge.ID, &edge.UpdatedAt, &edge.FirstPassID, &edge.PassID); err != nil {
+		if err == sql.ErrNoRows {
+			err = graph.ErrUnknownEdgeLinks
+		}
+		return fmt.Errorf("upsert edge: %w", err)
+	}
+	edge.Namespace = c.ns
+
+	return nil
+}
+
+// Edges returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were last updated before the provided
+// value.
+func (c *SQLiteGraph) Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error) {
+	rows, err := c.db.Query(edgesInPartitionQuery, fromID, toID, updatedBefore, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("edges: %w", err)
+	}
+
+	return &edgeIterator{rows: rows, ns: c.ns}, nil
+}
+
+// EdgesGroupedByDst returns an iterator for the set of edges whose destination
+// vertex IDs belong to the [fromID, toID) range grouped by destination.
+func (c *SQLiteGraph) EdgesGroupedByDst(fromID, toID uuid.UUID) (graph.EdgeGroupIterator, error) {
+	rows, err := c.db.Query(edgesByDstQuery, fromID, toID, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("edges grouped by dst: %w", err)
+	}
+
+	return graph.GroupEdgesByDst(&edgeIterator{rows: rows, ns: c.ns}), nil
+}
+
+// RemoveStaleEdges removes any edge that originates from the specified link ID
+// and was updated before the specified timestamp.
+func (c *SQLiteGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("remove stale edges: %w", err)
+	}
+	for _, query := range []string{recordStaleEdgesQuery, removeStaleEdgesQuery} {
+		if _, err = tx.Exec(query, fromID, updatedBefore, c.ns); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("remove stale edges: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("remove stale edges: %w", err)
+	}
+
+	return nil
+}
+
+// PruneRemovals discards the records of the edges that were removed by the
+// specified or an earlier crawl pass.
+func (c *SQLiteGraph) PruneRemovals(passID uint64) error {
+	if _, err := c.db.Exec(pruneRemovalsQuery, passID, c.ns); err != nil {
+		return fmt.Errorf("prune removals: %w", err)
+	}
+	return nil
+}
+
+// Diff returns an iterator for the set of changes that were applied to the
+// graph by the crawl passes in the (passA, passB] range.
+func (c *SQLiteGraph) Diff(passA, passB uint64) (graph.ChangeIterator, error) {
+	rows, err := c.db.Query(linkChangesQuery, passA, passB, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+
+	return &changeIterator{db: c.db, ns: c.ns, passA: passA, passB: passB, rows: rows}, nil
+}
+
+// LinksAsOf returns an iterator for the set of links whose IDs belong to the
+// [fromID, toID) range and had been added to the graph by the end of the
+// specified crawl pass.
+func (c *SQLiteGraph) LinksAsOf(fromID, toID uuid.UUID, passID uint64) (graph.LinkIterator, error) {
+	rows, err := c.db.Query(linksAsOfQuery, fromID, toID, passID, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("links as of: %w", err)
+	}
+
+	return &linkIterator{rows: rows, ns: c.ns}, nil
+}
+
+// EdgesAsOf returns an iterator for the set of edges whose source vertex IDs
+// belong to the [fromID, toID) range and were present in the graph at the end
+// of the specified crawl pass.
+func (c *SQLiteGraph) EdgesAsOf(fromID, toID uuid.UUID, passID uint64) (graph.EdgeIterator, error) {
+	rows, err := c.db.Query(edgesAsOfQuery, fromID, toID, passID, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("edges as of: %w", err)
+	}
+
+	return &edgeIterator{rows: rows, ns: c.ns}, nil
+}
+
+// RecordLinkFailure creates or replaces the failure record of a link and
+// reschedules the link accordingly.
+func (c *SQLiteGraph) RecordLinkFailure(f *graph.LinkFailure) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	if err = tx.QueryRow(rescheduleFailedLinkQuery, f.LinkID, f.DueAt(), c.ns).Scan(&f.URL); err != nil {
+		_ = tx.Rollback()
+		if err == sql.ErrNoRows {
+			err = graph.ErrNotFound
+		}
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	if _, err = tx.Exec(upsertLinkFailureQuery, f.LinkID, f.Reason, f.Attempts, f.FailedAt, f.NextRetryAt, f.Quarantined, c.ns); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("record link failure: %w", err)
+	}
+	return nil
+}
+
+// LinkFailure returns the failure record of the link with the specified ID.
+func (c *SQLiteGraph) LinkFailure(id uuid.UUID) (*graph.LinkFailure, error) {
+	rows, err := c.db.Query(findLinkFailureQuery, id, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("find link failure: %w", err)
+	}
+	it := &failureIterator{rows: rows}
+	defer func() { _ = it.Close() }()
+	if !it.Next() {
+		if err = it.Error(); err == nil {
+			err = graph.ErrNotFound
+		}
+		return nil, fmt.Errorf("find link failure: %w", err)
+	}
+	return it.Failure(), nil
+}
+
+// FailedLinks returns an iterator for the failure records of the links whose
+// IDs belong to the [fromID, toID) range.
+func (c *SQLiteGraph) FailedLinks(fromID, toID uuid.UUID) (graph.LinkFailureIterator, error) {
+	rows, err := c.db.Query(failedLinksQuery, fromID, toID, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed links: %w", err)
+	}
+
+	return &failureIterator{rows: rows}, nil
+}
+
+// SaveCheckpoint creates or replaces the checkpoint for the partition
+// specified by cp.
+func (c *SQLiteGraph) SaveCheckpoint(cp *graph.Checkpoint) error {
+	var completedAt sql.NullInt64
+	if !cp.CompletedAt.IsZero() {
+		completedAt = sql.NullInt64{Int64: cp.CompletedAt.UnixNano(), Valid: true}
+	}
+	updatedAt := time.Now().UTC()
+	if _, err := c.db.Exec(saveCheckpointQuery, cp.Partition, cp.PassID, cp.PassStartedAt.UnixNano(), completedAt, updatedAt.UnixNano(), c.ns); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	cp.UpdatedAt = updatedAt
+	return nil
+}
+
+// Checkpoint returns the checkpoint for the specified partition.
+func (c *SQLiteGraph) Checkpoint(partition int) (*graph.Checkpoint, error) {
+	var (
+		cp                       = &graph.Checkpoint{Partition: partition}
+		passStartedAt, updatedAt int64
+		completedAt              sql.NullInt64
+	)
+	row := c.db.QueryRow(findCheckpointQuery, partition, c.ns)
+	if err := row.Scan(&cp.PassID, &passStartedAt, &completedAt, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("find checkpoint: %w", graph.ErrNotFound)
+		}
+		return nil, fmt.Errorf("find checkpoint: %w", err)
+	}
+	cp.PassStartedAt = time.Unix(0, passStartedAt).UTC()
+	cp.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	if completedAt.Valid {
+		cp.CompletedAt = time.Unix(0, completedAt.Int64).UTC()
+	}
+	return cp, nil
+}
+
+// Checkpoints returns the checkpoints of all partitions ordered by partition.
+func (c *SQLiteGraph) Checkpoints() ([]*graph.Checkpoint, error) {
+	rows, err := c.db.Query(checkpointsQuery, c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoints: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []*graph.Checkpoint
+	for rows.Next() {
+		var (
+			cp                       = new(graph.Checkpoint)
+			passStartedAt, updatedAt int64
+			completedAt              sql.NullInt64
+		)
+		if err = rows.Scan(&cp.Partition, &cp.PassID, &passStartedAt, &completedAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("checkpoints: %w", err)
+		}
+		cp.PassStartedAt = time.Unix(0, passStartedAt).UTC()
+		cp.UpdatedAt = time.Unix(0, updatedAt).UTC()
+		if completedAt.Valid {
+			cp.CompletedAt = time.Unix(0, completedAt.Int64).UTC()
+		}
+		list = append(list, cp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("checkpoints: %w", err)
+	}
+	return list, nil
+}
+
+// contentHash stores the content hashes of links, which use the full uint64
+// range, in signed integer columns.
+type contentHash uint64
+
+// Value implements driver.Valuer.
+func (h contentHash) Value() (driver.Value, error) { return int64(h), nil }
+
+// Scan implements sql.Scanner.
+func (h *contentHash) Scan(src interface{}) error {
+	v, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("unsupported content hash type %T", src)
+	}
+	*h = contentHash(v)
+	return nil
+}
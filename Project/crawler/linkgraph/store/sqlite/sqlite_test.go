@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/graph/graphtest"
+	"webcrawler/namespace"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(SQLiteGraphTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type SQLiteGraphTestSuite struct {
+	graphtest.SuiteBase
+	path string
+	g    *SQLiteGraph
+}
+
+func (s *SQLiteGraphTestSuite) SetUpTest(c *gc.C) {
+	s.path = filepath.Join(c.MkDir(), "linkgraph.db")
+	g, err := NewSQLiteGraph(s.path)
+	c.Assert(err, gc.IsNil)
+	s.SetGraph(g)
+	s.g = g
+}
+
+func (s *SQLiteGraphTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.g.Close(), gc.IsNil)
+}
+
+func (s *SQLiteGraphTestSuite) TestJournalMode(c *gc.C) {
+	var mode string
+	c.Assert(s.g.db.QueryRow("PRAGMA journal_mode").Scan(&mode), gc.IsNil)
+	c.Assert(mode, gc.Equals, "wal")
+}
+
+func (s *SQLiteGraphTestSuite) TestPersistence(c *gc.C) {
+	link := &graph.Link{URL: "https://example.com", RetrievedAt: time.Now().Unix()}
+	c.Assert(s.g.UpsertLink(link), gc.IsNil)
+	c.Assert(s.g.Close(), gc.IsNil)
+
+	// Reopening the database applies the schema again.
+	g, err := NewSQLiteGraph(s.path)
+	c.Assert(err, gc.IsNil)
+	s.g = g
+
+	found, err := g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(found, gc.DeepEquals, link)
+}
+
+func (s *SQLiteGraphTestSuite) TestAddMissingColumns(c *gc.C) {
+	link := &graph.Link{URL: "https://example.com", RetrievedAt: time.Now().Unix(), NextFetchAt: time.Now().Add(time.Hour).Unix()}
+	c.Assert(s.g.UpsertLink(link), gc.IsNil)
+
+	// Emulate a database that was created before the next fetch time,
+	// the content hashes of links and the rel types and anchor attributes
+	// of edges were tracked.
+	_, err := s.g.db.Exec(`
+DROP INDEX links_by_next_fetch_at;
+ALTER TABLE links DROP COLUMN next_fetch_at;
+ALTER TABLE links DROP COLUMN content_hash;
+ALTER TABLE edges DROP COLUMN rel_type;
+ALTER TABLE edge_removals DROP COLUMN rel_type;
+ALTER TABLE edges DROP COLUMN anchor_text;
+ALTER TABLE edges DROP COLUMN no_follow;
+ALTER TABLE edge_removals DROP COLUMN anchor_text;
+ALTER TABLE edge_removals DROP COLUMN no_follow;
+`)
+	c.Assert(err, gc.IsNil)
+	c.Assert(s.g.Close(), gc.IsNil)
+
+	g, err := NewSQLiteGraph(s.path)
+	c.Assert(err, gc.IsNil)
+	s.g = g
+
+	// The links of existing databases are due immediately.
+	found, err := g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	link.NextFetchAt = 0
+	c.Assert(found, gc.DeepEquals, link)
+
+	edge := &graph.Edge{Src: link.ID, Dst: link.ID, RelType: graph.RelCanonical, AnchorText: "self", NoFollow: true}
+	c.Assert(g.UpsertEdge(edge), gc.IsNil)
+	c.Assert(edge.RelType, gc.Equals, graph.RelCanonical)
+	c.Assert(edge.AnchorText, gc.Equals, "self")
+}
+
+func (s *SQLiteGraphTestSuite) TestNamespaceIsolation(c *gc.C) {
+	other, err := NewSQLiteGraphWithConfig(s.path, Config{Namespace: "other-crawl"})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(other.Close(), gc.IsNil) }()
+
+	g := s.g
+	l1 := &graph.Link{URL: "https://example.com"}
+	c.Assert(g.UpsertLink(l1), gc.IsNil)
+	c.Assert(l1.Namespace, gc.Equals, namespace.Default)
+
+	// Upserting the same URL in another namespace creates a separate link.
+	l2 := &graph.Link{URL: "https://example.com"}
+	c.Assert(other.UpsertLink(l2), gc.IsNil)
+	c.Assert(l2.Namespace, gc.Equals, "other-crawl")
+	c.Assert(l2.ID, gc.Not(gc.Equals), l1.ID)
+
+	_, err = other.FindLink(l1.ID)
+	c.Assert(errors.Is(err, graph.ErrNotFound), gc.Equals, true)
+
+	// Edges cannot connect links across namespaces.
+	err = other.UpsertEdge(&graph.Edge{Src: l2.ID, Dst: l1.ID})
+	c.Assert(errors.Is(err, graph.ErrUnknownEdgeLinks), gc.Equals, true)
+
+	maxUUID := uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+	it, err := other.Links(uuid.Nil, maxUUID, time.Now().Add(time.Hour).Unix())
+	c.Assert(err, gc.IsNil)
+	var ids []uuid.UUID
+	for it.Next() {
+		ids = append(ids, it.Link().ID)
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(ids, gc.DeepEquals, []uuid.UUID{l2.ID})
+}
+
+func (s *SQLiteGraphTestSuite) TestInvalidConfig(c *gc.C) {
+	_, err := NewSQLiteGraphWithConfig(s.path, Config{Namespace: "Not Valid"})
+	c.Assert(err, gc.ErrorMatches, "(?s)sqlite graph: config validation failed:.*invalid namespace.*")
+
+	_, err = NewSQLiteGraph("")
+	c.Assert(err, gc.ErrorMatches, "sqlite graph: database path has not been specified")
+}
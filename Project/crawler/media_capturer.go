@@ -0,0 +1,161 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"webcrawler/crawler/blobstore"
+	"webcrawler/logging"
+	"webcrawler/pipeline"
+)
+
+var (
+	iconLinkRegex = regexp.MustCompile(`(?i)<link[^>]*?\srel\s*=\s*["']?(?:shortcut\s+)?icon["'\s>][^>]*>`)
+	hrefRegex     = regexp.MustCompile(`(?i)\shref\s*=\s*["']?([^"'\s>]+)`)
+)
+
+// The maximum size of a favicon that will be stored.
+const maxFaviconSize = 256 * 1024
+
+var _ pipeline.Processor = (*mediaCapturer)(nil)
+
+// mediaCapturer captures the favicon of each crawled host and, if a
+// Screenshotter is available, a thumbnail of each crawled page and stores
+// them in a blob store. Capturing is best-effort: failures never cause a
+// payload to be dropped.
+type mediaCapturer struct {
+	urlGetter     URLGetter
+	netDetector   PrivateNetworkDetector
+	blobStore     BlobStore
+	screenshotter Screenshotter
+
+	captureFavicons bool
+	logger          *slog.Logger
+
+	// A per-host cache of favicon references. Each host is only looked
+	// up once; hosts without a usable favicon are cached as well.
+	favicons sync.Map
+}
+
+type hostFavicon struct {
+	once sync.Once
+	ref  string
+}
+
+func newMediaCapturer(cfg Config) *mediaCapturer {
+	return &mediaCapturer{
+		urlGetter:       cfg.URLGetter,
+		netDetector:     cfg.PrivateNetworkDetector,
+		blobStore:       cfg.BlobStore,
+		screenshotter:   cfg.Screenshotter,
+		captureFavicons: cfg.CaptureFavicons,
+		logger:          logging.Component(cfg.Logger, "crawler.media_capturer"),
+	}
+}
+
+func (mc *mediaCapturer) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+
+	if mc.captureFavicons {
+		payload.FaviconRef = mc.favicon(payload)
+	}
+
+	if mc.screenshotter != nil {
+		mc.thumbnail(ctx, payload)
+	}
+
+	return payload, nil
+}
+
+// thumbnail captures a thumbnail of the payload's page and stores its blob
+// store key in the payload.
+func (mc *mediaCapturer) thumbnail(ctx context.Context, payload *crawlerPayload) {
+	blob, err := mc.screenshotter.Screenshot(ctx, payload.URL)
+	if err != nil {
+		mc.logger.Debug("unable to capture thumbnail", logging.Link(payload.LinkID, payload.URL), "err", err)
+		return
+	} else if blob == nil {
+		return
+	}
+
+	key := "thumbnails/" + payload.LinkID.String()
+	if err = mc.blobStore.Put(key, blob); err != nil {
+		mc.logger.Warn("unable to store thumbnail", logging.Link(payload.LinkID, payload.URL), "key", key, "err", err)
+		return
+	}
+	payload.ThumbnailRef = key
+}
+
+// favicon returns the blob store key for the favicon of the payload's host,
+// fetching and storing it if the host has not been seen before.
+func (mc *mediaCapturer) favicon(payload *crawlerPayload) string {
+	pageURL, err := url.Parse(payload.URL)
+	if err != nil || pageURL.Host == "" {
+		return ""
+	}
+
+	host := strings.ToLower(pageURL.Host)
+	entry, _ := mc.favicons.LoadOrStore(host, new(hostFavicon))
+	hf := entry.(*hostFavicon)
+	hf.once.Do(func() {
+		iconURL := findFaviconURL(pageURL, payload.RawContent.String())
+		if blob := mc.fetchFavicon(iconURL); blob != nil {
+			key := "favicons/" + host
+			if err := mc.blobStore.Put(key, blob); err != nil {
+				mc.logger.Warn("unable to store favicon", "host", host, "key", key, "err", err)
+				return
+			}
+			hf.ref = key
+		}
+	})
+	return hf.ref
+}
+
+// findFaviconURL returns the URL of the icon declared by the page content or
+// the conventional /favicon.ico location if no icon is declared.
+func findFaviconURL(pageURL *url.URL, content string) *url.URL {
+	if tag := iconLinkRegex.FindString(content); tag != "" {
+		if hrefMatch := hrefRegex.FindStringSubmatch(tag); len(hrefMatch) == 2 {
+			if iconURL := resolveURL(pageURL, hrefMatch[1]); iconURL != nil {
+				return iconURL
+			}
+		}
+	}
+
+	return &url.URL{Scheme: pageURL.Scheme, Host: pageURL.Host, Path: "/favicon.ico"}
+}
+
+// fetchFavicon retrieves the icon at iconURL returning nil if it cannot be
+// retrieved or is not a valid image.
+func (mc *mediaCapturer) fetchFavicon(iconURL *url.URL) *blobstore.Blob {
+	if iconURL.Scheme != "http" && iconURL.Scheme != "https" {
+		return nil
+	}
+	if isPrivate, err := mc.netDetector.IsPrivate(iconURL.Hostname()); err != nil || isPrivate {
+		return nil
+	}
+
+	res, err := mc.urlGetter.Get(iconURL.String())
+	if err != nil {
+		mc.logger.Debug("unable to fetch favicon", "url", iconURL.String(), "err", err)
+		return nil
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	contentType := res.Header.Get("Content-Type")
+	if res.StatusCode < 200 || res.StatusCode > 299 || !strings.HasPrefix(contentType, "image/") {
+		return nil
+	}
+
+	// Read one extra byte to detect icons that exceed the size limit.
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxFaviconSize+1))
+	if err != nil || len(data) == 0 || len(data) > maxFaviconSize {
+		return nil
+	}
+
+	return &blobstore.Blob{ContentType: contentType, Data: data}
+}
@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"webcrawler/crawler/blobstore"
+	"webcrawler/crawler/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(MediaCapturerTestSuite))
+
+type MediaCapturerTestSuite struct {
+	urlGetter       *mocks.MockURLGetter
+	privNetDetector *mocks.MockPrivateNetworkDetector
+	blobs           *blobstore.InMemoryStore
+}
+
+func (s *MediaCapturerTestSuite) SetUpTest(c *gc.C) {
+	s.blobs = blobstore.NewInMemoryStore()
+}
+
+func (s *MediaCapturerTestSuite) TestDeclaredFaviconIsCachedPerHost(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+
+	s.privNetDetector.EXPECT().IsPrivate("cdn.example.com").Return(false, nil)
+	s.urlGetter.EXPECT().Get("http://cdn.example.com/icons/fav.png").Return(
+		makeResponse(200, "png-data", "image/png"),
+		nil,
+	).Times(1)
+
+	mc := newMediaCapturer(Config{
+		URLGetter:              s.urlGetter,
+		PrivateNetworkDetector: s.privNetDetector,
+		BlobStore:              s.blobs,
+		CaptureFavicons:        true,
+	})
+
+	content := `<html><head><link href="//cdn.example.com/icons/fav.png" rel="shortcut icon"></head></html>`
+	for _, pageURL := range []string{"http://example.com/a", "http://example.com/b"} {
+		p := s.capture(c, mc, pageURL, content)
+		c.Assert(p.FaviconRef, gc.Equals, "favicons/example.com")
+	}
+
+	blob, err := s.blobs.Get("favicons/example.com")
+	c.Assert(err, gc.IsNil)
+	c.Assert(blob, gc.DeepEquals, &blobstore.Blob{ContentType: "image/png", Data: []byte("png-data")})
+}
+
+func (s *MediaCapturerTestSuite) TestFallbackFaviconAndMissingIcon(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.urlGetter = mocks.NewMockURLGetter(ctrl)
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+
+	s.privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil)
+	s.urlGetter.EXPECT().Get("https://example.com/favicon.ico").Return(
+		makeResponse(404, "not found", "text/html"),
+		nil,
+	).Times(1)
+
+	mc := newMediaCapturer(Config{
+		URLGetter:              s.urlGetter,
+		PrivateNetworkDetector: s.privNetDetector,
+		BlobStore:              s.blobs,
+		CaptureFavicons:        true,
+	})
+
+	// Hosts without a usable favicon are not looked up again.
+	for i := 0; i < 2; i++ {
+		p := s.capture(c, mc, "https://example.com/page", "<html></html>")
+		c.Assert(p.FaviconRef, gc.Equals, "")
+	}
+}
+
+func (s *MediaCapturerTestSuite) TestThumbnailCapture(c *gc.C) {
+	mc := newMediaCapturer(Config{
+		BlobStore: s.blobs,
+		Screenshotter: screenshotterFunc(func(_ context.Context, url string) (*blobstore.Blob, error) {
+			if url == "http://example.com/broken" {
+				return nil, errors.New("render failed")
+			}
+			return &blobstore.Blob{ContentType: "image/jpeg", Data: []byte(url)}, nil
+		}),
+	})
+
+	p := s.capture(c, mc, "http://example.com/page", "<html></html>")
+	c.Assert(p.FaviconRef, gc.Equals, "")
+	c.Assert(p.ThumbnailRef, gc.Equals, "thumbnails/"+p.LinkID.String())
+
+	blob, err := s.blobs.Get(p.ThumbnailRef)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(blob.Data), gc.Equals, "http://example.com/page")
+
+	// Rendering failures must not drop the payload.
+	p = s.capture(c, mc, "http://example.com/broken", "<html></html>")
+	c.Assert(p.ThumbnailRef, gc.Equals, "")
+}
+
+func (s *MediaCapturerTestSuite) capture(c *gc.C, mc *mediaCapturer, url, content string) *crawlerPayload {
+	p := &crawlerPayload{LinkID: uuid.New(), URL: url}
+	_, err := p.RawContent.WriteString(content)
+	c.Assert(err, gc.IsNil)
+
+	out, err := mc.Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.FitsTypeOf, p)
+	return out.(*crawlerPayload)
+}
+
+type screenshotterFunc func(context.Context, string) (*blobstore.Blob, error)
+
+func (f screenshotterFunc) Screenshot(ctx context.Context, url string) (*blobstore.Blob, error) {
+	return f(ctx, url)
+}
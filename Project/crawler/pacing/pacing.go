@@ -0,0 +1,346 @@
+// Package pacing spaces out the requests that the crawler sends to each host
+// according to the pacing signals provided by the host. All signals feed a
+// single per-host delay model:
+//
+//   - The base delay of a host is the larger of the configured default delay
+//     and the Crawl-delay of its robots.txt file.
+//   - Each 429 (Too Many Requests) or 503 (Service Unavailable) response
+//     multiplies the backoff delay of the host by the backoff factor,
+//     starting at the initial backoff. Each successful response divides it
+//     by the same factor until it drops below the initial backoff and is
+//     cleared, so sustained overload responses slow the crawler down while
+//     isolated ones are quickly forgotten.
+//   - A Retry-After response header pauses all requests to the host until
+//     the specified time.
+//
+// The delay between the start of two consecutive requests to a host is the
+// larger of its base and backoff delays, capped at the configured maximum.
+package pacing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrThrottled is returned by Wait if the next request to a host cannot be
+// issued within the configured maximum wait time.
+var ErrThrottled = errors.New("host is throttled")
+
+// Config encapsulates the settings for a Controller.
+type Config struct {
+	// The delay between consecutive requests to hosts that do not
+	// provide any pacing signals. Defaults to no delay.
+	DefaultDelay time.Duration
+
+	// The upper bound for the delay between consecutive requests to a host
+	// and for the pauses requested via Retry-After headers.
+	MaxDelay time.Duration
+
+	// The backoff delay applied after the first overload response from a
+	// host. Defaults to 1s.
+	InitialBackoff time.Duration
+
+	// The factor by which the backoff delay grows with each overload
+	// response and shrinks with each successful response. Defaults to 2.
+	BackoffFactor float64
+
+	// The maximum time that Wait blocks. Requests that would have to wait
+	// longer fail with ErrThrottled. Defaults to MaxDelay.
+	MaxWait time.Duration
+
+	// The state of hosts that have not been requested for this long is
+	// discarded. Defaults to 1h.
+	IdleTTL time.Duration
+
+	// A clock for testing. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.MaxDelay <= 0 {
+		err = multierror.Append(err, fmt.Errorf("max delay must be positive"))
+	}
+	if cfg.DefaultDelay < 0 || cfg.DefaultDelay > cfg.MaxDelay {
+		err = multierror.Append(err, fmt.Errorf("default delay must be between zero and the max delay"))
+	}
+	if cfg.InitialBackoff < 0 || cfg.MaxWait < 0 || cfg.IdleTTL < 0 {
+		err = multierror.Append(err, fmt.Errorf("initial backoff, max wait and idle TTL must not be negative"))
+	}
+	if cfg.BackoffFactor != 0 && cfg.BackoffFactor <= 1 {
+		err = multierror.Append(err, fmt.Errorf("backoff factor must be greater than 1"))
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.BackoffFactor == 0 {
+		cfg.BackoffFactor = 2
+	}
+	if cfg.MaxWait == 0 {
+		cfg.MaxWait = cfg.MaxDelay
+	}
+	if cfg.IdleTTL == 0 {
+		cfg.IdleTTL = time.Hour
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+// HostState describes the pacing state of a host.
+type HostState struct {
+	Host string `json:"host"`
+
+	// The current delay between consecutive requests to the host and the
+	// signals it is derived from.
+	Delay      time.Duration `json:"delay"`
+	CrawlDelay time.Duration `json:"crawlDelay,omitempty"`
+	Backoff    time.Duration `json:"backoff,omitempty"`
+
+	// The time until which requests are paused due to a Retry-After
+	// header (if it lies in the future).
+	RetryAfter *time.Time `json:"retryAfter,omitempty"`
+
+	// The number of consecutive overload responses and the status code of
+	// the most recent response.
+	Overloads  int `json:"overloads,omitempty"`
+	LastStatus int `json:"lastStatus,omitempty"`
+
+	// The earliest time at which the next request to the host may start.
+	NextRequestAt time.Time `json:"nextRequestAt"`
+}
+
+type hostState struct {
+	crawlDelay time.Duration
+	backoff    time.Duration
+	retryUntil time.Time
+	nextSlot   time.Time
+	overloads  int
+	lastStatus int
+	lastSeen   time.Time
+}
+
+// Controller paces the requests to each host. It is safe for concurrent use.
+type Controller struct {
+	cfg   Config
+	sleep func(context.Context, time.Duration) error
+
+	mu        sync.Mutex
+	hosts     map[string]*hostState
+	lastPrune time.Time
+}
+
+// NewController returns a new Controller using the provided config.
+func NewController(cfg Config) (*Controller, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("pacing controller: config validation failed: %w", err)
+	}
+	return &Controller{
+		cfg:       cfg,
+		sleep:     sleep,
+		hosts:     make(map[string]*hostState),
+		lastPrune: cfg.Clock(),
+	}, nil
+}
+
+// Wait reserves the next request slot for host and blocks until it starts or
+// ctx is cancelled. It returns an error wrapping ErrThrottled without
+// reserving a slot if the wait would exceed the configured maximum.
+func (c *Controller) Wait(ctx context.Context, host string) error {
+	now := c.cfg.Clock()
+
+	c.mu.Lock()
+	c.pruneLocked(now)
+	hs := c.hostLocked(host, now)
+	start := now
+	if hs.nextSlot.After(start) {
+		start = hs.nextSlot
+	}
+	if hs.retryUntil.After(start) {
+		start = hs.retryUntil
+	}
+	wait := start.Sub(now)
+	if wait > c.cfg.MaxWait {
+		c.mu.Unlock()
+		return fmt.Errorf("%w: next request to %s allowed in %s", ErrThrottled, host, wait.Round(time.Millisecond))
+	}
+	hs.nextSlot = start.Add(c.delayLocked(hs))
+	c.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	return c.sleep(ctx, wait)
+}
+
+// SetCrawlDelay sets the Crawl-delay that the robots.txt file of host
+// specifies.
+func (c *Controller) SetCrawlDelay(host string, delay time.Duration) {
+	if delay > c.cfg.MaxDelay {
+		delay = c.cfg.MaxDelay
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hostLocked(host, c.cfg.Clock()).crawlDelay = delay
+}
+
+// Observe updates the pacing state of host using the status code and headers
+// of a response received from it.
+func (c *Controller) Observe(host string, statusCode int, header http.Header) {
+	now := c.cfg.Clock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hs := c.hostLocked(host, now)
+	hs.lastStatus = statusCode
+
+	switch {
+	case statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable:
+		hs.overloads++
+		if hs.backoff == 0 {
+			hs.backoff = c.cfg.InitialBackoff
+		} else {
+			hs.backoff = time.Duration(float64(hs.backoff) * c.cfg.BackoffFactor)
+		}
+		if hs.backoff > c.cfg.MaxDelay {
+			hs.backoff = c.cfg.MaxDelay
+		}
+
+		// The slot of the next request was reserved using the previous
+		// delay; push it back so that the backoff applies right away.
+		if next := now.Add(c.delayLocked(hs)); next.After(hs.nextSlot) {
+			hs.nextSlot = next
+		}
+	case statusCode >= 200 && statusCode < 500:
+		hs.overloads = 0
+		if hs.backoff = time.Duration(float64(hs.backoff) / c.cfg.BackoffFactor); hs.backoff < c.cfg.InitialBackoff {
+			hs.backoff = 0
+		}
+	}
+
+	if pause, ok := parseRetryAfter(header.Get("Retry-After"), now); ok {
+		if pause > c.cfg.MaxDelay {
+			pause = c.cfg.MaxDelay
+		}
+		if until := now.Add(pause); until.After(hs.retryUntil) {
+			hs.retryUntil = until
+		}
+	}
+}
+
+// Status returns the pacing state of the hosts that were recently requested
+// sorted by host name.
+func (c *Controller) Status() []HostState {
+	now := c.cfg.Clock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneLocked(now)
+
+	list := make([]HostState, 0, len(c.hosts))
+	for host, hs := range c.hosts {
+		state := HostState{
+			Host:          host,
+			Delay:         c.delayLocked(hs),
+			CrawlDelay:    hs.crawlDelay,
+			Backoff:       hs.backoff,
+			Overloads:     hs.overloads,
+			LastStatus:    hs.lastStatus,
+			NextRequestAt: hs.nextSlot,
+		}
+		if hs.retryUntil.After(now) {
+			retryUntil := hs.retryUntil
+			state.RetryAfter = &retryUntil
+			if retryUntil.After(state.NextRequestAt) {
+				state.NextRequestAt = retryUntil
+			}
+		}
+		if state.NextRequestAt.Before(now) {
+			state.NextRequestAt = now
+		}
+		list = append(list, state)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Host < list[j].Host })
+	return list
+}
+
+// hostLocked returns the state for host, creating it if required. The caller
+// must hold c.mu.
+func (c *Controller) hostLocked(host string, now time.Time) *hostState {
+	host = strings.ToLower(host)
+	hs := c.hosts[host]
+	if hs == nil {
+		hs = new(hostState)
+		c.hosts[host] = hs
+	}
+	hs.lastSeen = now
+	return hs
+}
+
+// delayLocked returns the delay between consecutive requests to the host
+// with state hs. The caller must hold c.mu.
+func (c *Controller) delayLocked(hs *hostState) time.Duration {
+	delay := c.cfg.DefaultDelay
+	if hs.crawlDelay > delay {
+		delay = hs.crawlDelay
+	}
+	if hs.backoff > delay {
+		delay = hs.backoff
+	}
+	if delay > c.cfg.MaxDelay {
+		delay = c.cfg.MaxDelay
+	}
+	return delay
+}
+
+// pruneLocked discards the state of idle hosts. To amortize its cost, it only
+// runs once per minute. The caller must hold c.mu.
+func (c *Controller) pruneLocked(now time.Time) {
+	if now.Sub(c.lastPrune) < time.Minute {
+		return
+	}
+	c.lastPrune = now
+	for host, hs := range c.hosts {
+		if now.Sub(hs.lastSeen) >= c.cfg.IdleTTL && !hs.retryUntil.After(now) && !hs.nextSlot.After(now) {
+			delete(c.hosts, host)
+		}
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header which is either a
+// number of seconds or an HTTP date and returns the requested pause.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second, secs > 0
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
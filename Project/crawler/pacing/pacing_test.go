@@ -0,0 +1,148 @@
+package pacing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(PacingTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type PacingTestSuite struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (s *PacingTestSuite) SetUpTest(c *gc.C) {
+	s.now = time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	s.sleeps = nil
+}
+
+func (s *PacingTestSuite) TestBaseDelay(c *gc.C) {
+	ctrl := s.controller(c, Config{DefaultDelay: time.Second, MaxDelay: 10 * time.Second})
+
+	// Requests to the same host are spaced out by the default delay
+	// while other hosts are not affected.
+	s.wait(c, ctrl, "example.com")
+	s.wait(c, ctrl, "example.com")
+	s.wait(c, ctrl, "other.com")
+	c.Assert(s.sleeps, gc.DeepEquals, []time.Duration{time.Second})
+
+	// The Crawl-delay takes precedence if it is longer and is capped at
+	// the max delay.
+	ctrl.SetCrawlDelay("Example.com", 5*time.Second)
+	s.sleeps = nil
+	s.wait(c, ctrl, "example.com")
+	s.wait(c, ctrl, "example.com")
+	c.Assert(s.sleeps, gc.DeepEquals, []time.Duration{time.Second, 5 * time.Second})
+
+	ctrl.SetCrawlDelay("example.com", time.Hour)
+	c.Assert(s.state(c, ctrl, "example.com").Delay, gc.Equals, 10*time.Second)
+}
+
+func (s *PacingTestSuite) TestOverloadBackoff(c *gc.C) {
+	ctrl := s.controller(c, Config{MaxDelay: 10 * time.Second})
+
+	for _, exp := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		ctrl.Observe("example.com", http.StatusServiceUnavailable, nil)
+		c.Assert(s.state(c, ctrl, "example.com").Backoff, gc.Equals, exp)
+	}
+	state := s.state(c, ctrl, "example.com")
+	c.Assert(state.Overloads, gc.Equals, 5)
+	c.Assert(state.Delay, gc.Equals, 10*time.Second)
+	c.Assert(state.NextRequestAt, gc.Equals, s.now.Add(10*time.Second))
+
+	// Successful responses gradually shrink the backoff.
+	for _, exp := range []time.Duration{5 * time.Second, 2500 * time.Millisecond, 1250 * time.Millisecond, 0} {
+		ctrl.Observe("example.com", http.StatusOK, nil)
+		c.Assert(s.state(c, ctrl, "example.com").Backoff, gc.Equals, exp)
+	}
+	state = s.state(c, ctrl, "example.com")
+	c.Assert(state.Overloads, gc.Equals, 0)
+	c.Assert(state.LastStatus, gc.Equals, http.StatusOK)
+}
+
+func (s *PacingTestSuite) TestRetryAfter(c *gc.C) {
+	ctrl := s.controller(c, Config{MaxDelay: time.Minute, MaxWait: 30 * time.Second})
+
+	ctrl.Observe("example.com", http.StatusTooManyRequests, http.Header{"Retry-After": {"20"}})
+	state := s.state(c, ctrl, "example.com")
+	c.Assert(*state.RetryAfter, gc.Equals, s.now.Add(20*time.Second))
+	c.Assert(state.NextRequestAt, gc.Equals, s.now.Add(20*time.Second))
+	s.wait(c, ctrl, "example.com")
+	c.Assert(s.sleeps, gc.DeepEquals, []time.Duration{20 * time.Second})
+
+	// Pauses beyond the max wait are reported as throttled; HTTP dates
+	// are supported and pauses are capped at the max delay.
+	date := s.now.Add(2 * time.Hour).Format(http.TimeFormat)
+	ctrl.Observe("other.com", http.StatusServiceUnavailable, http.Header{"Retry-After": {date}})
+	err := ctrl.Wait(context.TODO(), "other.com")
+	c.Assert(errors.Is(err, ErrThrottled), gc.Equals, true)
+	c.Assert(*s.state(c, ctrl, "other.com").RetryAfter, gc.Equals, s.now.Add(time.Minute))
+
+	// Once the pause is over, requests resume.
+	s.now = s.now.Add(time.Minute)
+	c.Assert(s.state(c, ctrl, "other.com").RetryAfter, gc.IsNil)
+
+	// Invalid values are ignored.
+	ctrl.Observe("third.com", http.StatusOK, http.Header{"Retry-After": {"soon"}})
+	c.Assert(s.state(c, ctrl, "third.com").RetryAfter, gc.IsNil)
+}
+
+func (s *PacingTestSuite) TestIdleHostsArePruned(c *gc.C) {
+	ctrl := s.controller(c, Config{MaxDelay: time.Second, IdleTTL: 10 * time.Minute})
+	s.wait(c, ctrl, "example.com")
+	c.Assert(ctrl.Status(), gc.HasLen, 1)
+
+	s.now = s.now.Add(10 * time.Minute)
+	c.Assert(ctrl.Status(), gc.HasLen, 0)
+}
+
+func (s *PacingTestSuite) TestWaitIsCancellable(c *gc.C) {
+	ctrl, err := NewController(Config{DefaultDelay: time.Hour, MaxDelay: time.Hour})
+	c.Assert(err, gc.IsNil)
+	c.Assert(ctrl.Wait(context.TODO(), "example.com"), gc.IsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c.Assert(ctrl.Wait(ctx, "example.com"), gc.Equals, context.DeadlineExceeded)
+}
+
+func (s *PacingTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewController(Config{DefaultDelay: 2 * time.Second, MaxDelay: time.Second, BackoffFactor: 0.5})
+	c.Assert(err, gc.ErrorMatches, "(?s)pacing controller: config validation failed:.*default delay.*backoff factor.*")
+	_, err = NewController(Config{})
+	c.Assert(err, gc.ErrorMatches, "(?s).*max delay must be positive.*")
+}
+
+func (s *PacingTestSuite) controller(c *gc.C, cfg Config) *Controller {
+	cfg.Clock = func() time.Time { return s.now }
+	ctrl, err := NewController(cfg)
+	c.Assert(err, gc.IsNil)
+	ctrl.sleep = func(_ context.Context, d time.Duration) error {
+		s.sleeps = append(s.sleeps, d)
+		s.now = s.now.Add(d)
+		return nil
+	}
+	return ctrl
+}
+
+func (s *PacingTestSuite) wait(c *gc.C, ctrl *Controller, host string) {
+	c.Assert(ctrl.Wait(context.TODO(), host), gc.IsNil)
+}
+
+func (s *PacingTestSuite) state(c *gc.C, ctrl *Controller, host string) HostState {
+	for _, state := range ctrl.Status() {
+		if state.Host == host {
+			return state
+		}
+	}
+	c.Fatalf("no state for host %q", host)
+	return HostState{}
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/http"
 	"sync"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
 	"webcrawler/pipeline"
 
 	"github.com/google/uuid"
@@ -18,20 +21,114 @@ var (
 	}
 )
 
+// contentFormat describes the format of a fetched response body. It selects
+// the pipeline stages that extract the title, content and links of a payload.
+type contentFormat uint8
+
+const (
+	// formatHTML payloads are processed by the HTML link and text
+	// extractors.
+	formatHTML contentFormat = iota
+
+	// formatStructured payloads are JSON responses from a structured
+	// source that are processed by the structured adapter.
+	formatStructured
+
+	// formatPDF and formatFeed payloads are PDF documents and RSS/Atom
+	// feeds that are processed by the document adapter.
+	formatPDF
+	formatFeed
+)
+
 type crawlerPayload struct {
 	LinkID      uuid.UUID
 	URL         string
 	RetrievedAt int64
 
+	// The content hash that the graph stores for the link as of its last
+	// crawl (see graph.Link) or zero if unknown. The text indexer skips
+	// pages whose content still hashes to it.
+	PrevContentHash uint64
+
+	// The time when the content was fetched; used for tracking the index
+	// lag.
+	FetchedAt time.Time
+
+	// The URL that the content was served from if the fetch was
+	// redirected. Relative links are resolved against it.
+	BaseURL string
+
+	// The canonical URL declared by the page via a
+	// <link rel="canonical"> tag if it differs from URL.
+	CanonicalURL string
+
 	RawContent bytes.Buffer
 
-	// NoFollowLinks are still added to the graph but no outgoing edges
-	// will be created from this link to them.
+	// NoFollowLinks are added to the graph with edges that are flagged as
+	// nofollow.
 	NoFollowLinks []string
 
-	Links       []string
+	Links []string
+
+	// The rel types of the Links that are not hyperlinks (e.g. canonical
+	// URLs, alternates and referenced resources) keyed by link.
+	LinkRels map[string]graph.RelType
+
+	// The anchor texts of the hyperlinks in Links and NoFollowLinks keyed
+	// by link.
+	LinkAnchors map[string]string
+
 	Title       string
+	Language    string
 	TextContent string
+	Summary     string
+
+	// The author and publication date selected by the extraction profile
+	// for the page host (if any).
+	Author      string
+	PublishedAt time.Time
+
+	// The SimHash fingerprint of TextContent and, if the content is a
+	// near-duplicate of a previously crawled page, the ID of that page.
+	Fingerprint uint64
+	DuplicateOf uuid.UUID
+
+	// Blob store references for captured media.
+	FaviconRef   string
+	ThumbnailRef string
+
+	// Captured response headers keyed by lower-case header name.
+	Headers map[string]string
+
+	// The status code, HTTP version and headers of the fetched response;
+	// used for archiving it.
+	StatusCode     int
+	Proto          string
+	ResponseHeader http.Header
+
+	// The values of the X-Robots-Tag response headers.
+	RobotsTags []string
+
+	// The page-level robots directives declared by the page. NoIndex
+	// pages are not indexed and no links are extracted from NoFollow
+	// pages.
+	NoIndex  bool
+	NoFollow bool
+
+	// The format of RawContent. The title, content and links of non-HTML
+	// payloads are populated by the stage responsible for their format
+	// instead of the HTML extractors.
+	Format contentFormat
+
+	// The search vertical that the payload is indexed under. If empty,
+	// the indexer assigns the web vertical.
+	Vertical string
+
+	// The trace that covers the journey of the link through the pipeline.
+	trace *linkTrace
+
+	// The time when the current stage started processing the payload.
+	stageStartedAt time.Time
 }
 
 // Clone implements pipeline.Payload.
@@ -40,10 +137,50 @@ func (p *crawlerPayload) Clone() pipeline.Payload {
 	newP.LinkID = p.LinkID
 	newP.URL = p.URL
 	newP.RetrievedAt = p.RetrievedAt
+	newP.PrevContentHash = p.PrevContentHash
+	newP.FetchedAt = p.FetchedAt
+	newP.BaseURL = p.BaseURL
+	newP.CanonicalURL = p.CanonicalURL
 	newP.NoFollowLinks = append([]string(nil), p.NoFollowLinks...)
 	newP.Links = append([]string(nil), p.Links...)
 	newP.Title = p.Title
+	newP.Language = p.Language
 	newP.TextContent = p.TextContent
+	newP.Summary = p.Summary
+	newP.Author = p.Author
+	newP.PublishedAt = p.PublishedAt
+	newP.Fingerprint = p.Fingerprint
+	newP.DuplicateOf = p.DuplicateOf
+	newP.FaviconRef = p.FaviconRef
+	newP.ThumbnailRef = p.ThumbnailRef
+	newP.StatusCode = p.StatusCode
+	newP.Proto = p.Proto
+	newP.ResponseHeader = p.ResponseHeader.Clone()
+	newP.RobotsTags = append([]string(nil), p.RobotsTags...)
+	newP.NoIndex = p.NoIndex
+	newP.NoFollow = p.NoFollow
+	newP.Format = p.Format
+	newP.Vertical = p.Vertical
+	newP.trace = p.trace
+	p.trace.retain()
+	if p.LinkRels != nil {
+		newP.LinkRels = make(map[string]graph.RelType, len(p.LinkRels))
+		for link, relType := range p.LinkRels {
+			newP.LinkRels[link] = relType
+		}
+	}
+	if p.LinkAnchors != nil {
+		newP.LinkAnchors = make(map[string]string, len(p.LinkAnchors))
+		for link, anchor := range p.LinkAnchors {
+			newP.LinkAnchors[link] = anchor
+		}
+	}
+	if p.Headers != nil {
+		newP.Headers = make(map[string]string, len(p.Headers))
+		for name, value := range p.Headers {
+			newP.Headers[name] = value
+		}
+	}
 
 	_, err := io.Copy(&newP.RawContent, &p.RawContent)
 	if err != nil {
@@ -55,10 +192,35 @@ func (p *crawlerPayload) Clone() pipeline.Payload {
 // MarkAsProcessed implements pipeline.Payload
 func (p *crawlerPayload) MarkAsProcessed() {
 	p.URL = p.URL[:0]
+	p.FetchedAt = time.Time{}
+	p.BaseURL = p.BaseURL[:0]
+	p.CanonicalURL = p.CanonicalURL[:0]
 	p.RawContent.Reset()
 	p.NoFollowLinks = p.NoFollowLinks[:0]
 	p.Links = p.Links[:0]
+	p.LinkRels = nil
+	p.LinkAnchors = nil
 	p.Title = p.Title[:0]
+	p.Language = p.Language[:0]
 	p.TextContent = p.TextContent[:0]
+	p.Summary = p.Summary[:0]
+	p.Author = p.Author[:0]
+	p.PublishedAt = time.Time{}
+	p.Fingerprint = 0
+	p.DuplicateOf = uuid.Nil
+	p.FaviconRef = p.FaviconRef[:0]
+	p.ThumbnailRef = p.ThumbnailRef[:0]
+	p.Headers = nil
+	p.StatusCode = 0
+	p.Proto = p.Proto[:0]
+	p.ResponseHeader = nil
+	p.RobotsTags = p.RobotsTags[:0]
+	p.NoIndex = false
+	p.NoFollow = false
+	p.Format = formatHTML
+	p.Vertical = p.Vertical[:0]
+	p.trace.release()
+	p.trace = nil
+	p.stageStartedAt = time.Time{}
 	payloadPool.Put(p)
 }
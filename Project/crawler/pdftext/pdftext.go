@@ -0,0 +1,379 @@
+// Package pdftext extracts the title and plain text of PDF documents so that
+// they can be indexed alongside web-pages.
+//
+// The extractor does not implement a full PDF parser. It scans the document
+// for content streams that are either uncompressed or compressed with the
+// FlateDecode filter and collects the strings that are shown by the text
+// operators (Tj, TJ, ' and ") of each stream. Strings are decoded as Latin-1,
+// which covers documents that use the standard single-byte font encodings;
+// text shown with composite (CID) fonts is skipped. Encrypted documents are
+// not supported.
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// The maximum number of bytes that a single stream may inflate to. Larger
+// streams are truncated.
+const maxStreamSize = 16 << 20
+
+var (
+	// ErrNotPDF is returned when the input does not start with a PDF
+	// header.
+	ErrNotPDF = errors.New("not a PDF document")
+
+	// ErrEncrypted is returned for encrypted documents.
+	ErrEncrypted = errors.New("encrypted PDF documents are not supported")
+
+	streamRegex = regexp.MustCompile(`stream\r?\n`)
+	titleRegex  = regexp.MustCompile(`/Title\s*([(<])`)
+)
+
+// Result holds the fields extracted from a PDF document.
+type Result struct {
+	// The title from the document information dictionary (if any).
+	Title string
+
+	// The text shown on the pages of the document with runs of whitespace
+	// collapsed into a single space.
+	Text string
+}
+
+// Extract returns the title and text of the PDF document in data.
+func Extract(data []byte) (Result, error) {
+	var res Result
+	start := bytes.Index(data, []byte("%PDF-"))
+	if start == -1 || start > 1024 {
+		return res, ErrNotPDF
+	}
+	data = data[start:]
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return res, ErrEncrypted
+	}
+
+	if loc := titleRegex.FindSubmatchIndex(data); loc != nil {
+		if raw, ok := readString(data[loc[2]:]); ok {
+			res.Title = collapseSpace(decodeText(raw))
+		}
+	}
+
+	var sb strings.Builder
+	for _, content := range contentStreams(data) {
+		extractText(content, &sb)
+		sb.WriteByte(' ')
+	}
+	res.Text = collapseSpace(sb.String())
+	return res, nil
+}
+
+// contentStreams returns the decoded contents of the streams in data that
+// contain text objects. Streams using unsupported filters are skipped.
+func contentStreams(data []byte) [][]byte {
+	var streams [][]byte
+	for _, loc := range streamRegex.FindAllIndex(data, -1) {
+		// Skip matches of the "endstream" keyword.
+		if loc[0] >= 3 && string(data[loc[0]-3:loc[0]]) == "end" {
+			continue
+		}
+
+		dict := streamDict(data[:loc[0]])
+		if dict == nil || bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("/Length1")) {
+			continue
+		}
+
+		body := data[loc[1]:]
+		end := bytes.Index(body, []byte("endstream"))
+		if end == -1 {
+			continue
+		}
+		body = bytes.TrimRight(body[:end], "\r\n")
+
+		switch {
+		case !bytes.Contains(dict, []byte("/Filter")):
+		case isFlateOnly(dict):
+			inflated, err := inflate(body)
+			if err != nil {
+				continue
+			}
+			body = inflated
+		default:
+			continue
+		}
+
+		if bytes.Contains(body, []byte("BT")) {
+			streams = append(streams, body)
+		}
+	}
+	return streams
+}
+
+// streamDict returns the dictionary of the stream whose "stream" keyword is
+// located at the end of prefix or nil if it cannot be located.
+func streamDict(prefix []byte) []byte {
+	objStart := bytes.LastIndex(prefix, []byte(" obj"))
+	if objStart == -1 {
+		return nil
+	}
+	dict := prefix[objStart:]
+	if !bytes.Contains(dict, []byte("<<")) {
+		return nil
+	}
+	return dict
+}
+
+// isFlateOnly returns true if the FlateDecode filter is the only filter
+// applied to the stream with the specified dictionary.
+func isFlateOnly(dict []byte) bool {
+	filters := dict[bytes.Index(dict, []byte("/Filter"))+len("/Filter"):]
+	filters = bytes.TrimLeft(filters, " \t\r\n")
+	if bytes.HasPrefix(filters, []byte("[")) {
+		if end := bytes.IndexByte(filters, ']'); end != -1 {
+			return strings.Join(strings.Fields(string(filters[1:end])), " ") == "/FlateDecode"
+		}
+		return false
+	}
+	return bytes.HasPrefix(filters, []byte("/FlateDecode")) &&
+		!bytes.HasPrefix(filters, []byte("/FlateDecode/")) &&
+		!bytes.HasPrefix(filters, []byte("/FlateDecode /DecodeParms"))
+}
+
+func inflate(body []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = zr.Close() }()
+
+	// Streams are often truncated or padded; keep whatever could be
+	// inflated before the error.
+	out, err := io.ReadAll(io.LimitReader(zr, maxStreamSize))
+	if len(out) == 0 && err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// extractText appends the strings shown by the text operators of content to
+// sb. Operators that move to a new position are mapped to spaces.
+func extractText(content []byte, sb *strings.Builder) {
+	var (
+		operands []string
+		inArray  bool
+		array    []string
+	)
+	for pos := 0; pos < len(content); {
+		c := content[pos]
+		switch {
+		case c == '(' || (c == '<' && (pos+1 >= len(content) || content[pos+1] != '<')):
+			raw, n := readStringAt(content[pos:])
+			pos += n
+			if raw == nil {
+				continue
+			}
+			if inArray {
+				array = append(array, decodeText(raw))
+			} else {
+				operands = append(operands, decodeText(raw))
+			}
+		case c == '<' || c == '>':
+			// Dictionary delimiters.
+			if pos++; pos < len(content) && content[pos] == c {
+				pos++
+			}
+		case c == '/':
+			// Skip names such as font resources.
+			for pos++; pos < len(content) && !isDelimiter(content[pos]) && !isSpace(content[pos]); pos++ {
+			}
+		case c == '[':
+			inArray, array = true, array[:0]
+			pos++
+		case c == ']':
+			inArray = false
+			pos++
+		case c == '%':
+			for pos < len(content) && content[pos] != '\n' && content[pos] != '\r' {
+				pos++
+			}
+		case isDelimiter(c) || isSpace(c):
+			pos++
+		default:
+			end := pos
+			for end < len(content) && !isDelimiter(content[end]) && !isSpace(content[end]) {
+				end++
+			}
+			token := string(content[pos:end])
+			pos = end
+
+			if inArray {
+				// Large negative kerning adjustments within TJ
+				// arrays separate words.
+				if kern, err := strconv.ParseFloat(token, 64); err == nil && kern < -200 {
+					array = append(array, " ")
+				}
+				continue
+			}
+			if _, err := strconv.ParseFloat(token, 64); err == nil {
+				continue
+			}
+
+			switch token {
+			case "Tj":
+				if len(operands) != 0 {
+					sb.WriteString(operands[len(operands)-1])
+				}
+			case "'", `"`:
+				sb.WriteByte(' ')
+				if len(operands) != 0 {
+					sb.WriteString(operands[len(operands)-1])
+				}
+			case "TJ":
+				for _, s := range array {
+					sb.WriteString(s)
+				}
+				array = array[:0]
+			case "Td", "TD", "T*", "Tm", "ET":
+				sb.WriteByte(' ')
+			}
+			operands = operands[:0]
+		}
+	}
+}
+
+// readString reads the literal or hex string at the start of data.
+func readString(data []byte) ([]byte, bool) {
+	raw, _ := readStringAt(data)
+	return raw, raw != nil
+}
+
+// readStringAt reads the literal or hex string at the start of data and
+// returns its raw bytes and the number of bytes consumed. The returned slice
+// is nil if the string is malformed.
+func readStringAt(data []byte) ([]byte, int) {
+	if data[0] == '<' {
+		end := bytes.IndexByte(data, '>')
+		if end == -1 {
+			return nil, len(data)
+		}
+		hex := strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, string(data[1:end]))
+		if len(hex)%2 == 1 {
+			hex += "0"
+		}
+		out := make([]byte, 0, len(hex)/2)
+		for i := 0; i < len(hex); i += 2 {
+			b, err := strconv.ParseUint(hex[i:i+2], 16, 8)
+			if err != nil {
+				return nil, end + 1
+			}
+			out = append(out, byte(b))
+		}
+		return out, end + 1
+	}
+
+	var (
+		out   []byte
+		depth int
+	)
+	for pos := 1; pos < len(data); pos++ {
+		c := data[pos]
+		switch c {
+		case '\\':
+			pos++
+			if pos >= len(data) {
+				return nil, pos
+			}
+			switch esc := data[pos]; esc {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+				out = append(out, ' ')
+			case '\r', '\n':
+				// Line continuation.
+			default:
+				if esc >= '0' && esc <= '7' {
+					end := pos
+					for end < len(data) && end < pos+3 && data[end] >= '0' && data[end] <= '7' {
+						end++
+					}
+					v, _ := strconv.ParseUint(string(data[pos:end]), 8, 16)
+					out = append(out, byte(v))
+					pos = end - 1
+					continue
+				}
+				out = append(out, esc)
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			if depth == 0 {
+				if out == nil {
+					out = []byte{}
+				}
+				return out, pos + 1
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return nil, len(data)
+}
+
+// decodeText converts a PDF string to UTF-8. Strings that start with a UTF-16
+// byte order mark are decoded as UTF-16BE; all other strings are decoded as
+// Latin-1 with control characters removed. Strings containing NUL bytes are
+// most likely glyph IDs of composite fonts and are dropped.
+func decodeText(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	if bytes.IndexByte(raw, 0) != -1 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, b := range raw {
+		switch r := rune(b); {
+		case r == '\t' || r == '\n' || r == '\r':
+			sb.WriteByte(' ')
+		case unicode.IsPrint(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) != -1
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,58 @@
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(PDFTextTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type PDFTextTestSuite struct{}
+
+func (s *PDFTextTestSuite) TestExtract(c *gc.C) {
+	page1 := "BT /F1 24 Tf 72 720 Td (Quarterly \\(Q3\\) report) Tj ET\n" +
+		"BT /F1 12 Tf 72 690 Td [(Rev)20(enue gr)-10(ew)-300(by 12%)] TJ 0 -14 Td (Caf\\351 sales) Tj T* <4F7574> Tj ET"
+	page2 := "BT /F1 12 Tf 72 720 Td (Second page) Tj (with a line) ' <0001002A> Tj ET"
+
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	_, err := zw.Write([]byte(page2))
+	c.Assert(err, gc.IsNil)
+	c.Assert(zw.Close(), gc.IsNil)
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n")
+	doc.WriteString("1 0 obj\n<< /Title (Annual \\(draft\\)) /Author (Jane) >>\nendobj\n")
+	fmt.Fprintf(&doc, "4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(page1), page1)
+	fmt.Fprintf(&doc, "5 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", deflated.Len())
+	doc.Write(deflated.Bytes())
+	doc.WriteString("\nendstream\nendobj\n")
+	doc.WriteString("6 0 obj\n<< /Length 10 /Filter /DCTDecode /Subtype /Image >>\nstream\nBT (x) Tj \nendstream\nendobj\n")
+	doc.WriteString("trailer\n<< /Info 1 0 R >>\n%%EOF\n")
+
+	res, err := Extract(doc.Bytes())
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Title, gc.Equals, "Annual (draft)")
+	c.Assert(res.Text, gc.Equals, "Quarterly (Q3) report Revenue grew by 12% Café sales Out Second page with a line")
+}
+
+func (s *PDFTextTestSuite) TestUTF16Title(c *gc.C) {
+	res, err := Extract([]byte("%PDF-1.7\n1 0 obj << /Title <FEFF00C40062> >> endobj"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Title, gc.Equals, "Äb")
+	c.Assert(res.Text, gc.Equals, "")
+}
+
+func (s *PDFTextTestSuite) TestUnsupportedDocuments(c *gc.C) {
+	_, err := Extract([]byte("<html><body>not a pdf</body></html>"))
+	c.Assert(err, gc.Equals, ErrNotPDF)
+
+	_, err = Extract([]byte("%PDF-1.4\ntrailer << /Encrypt 5 0 R >>"))
+	c.Assert(err, gc.Equals, ErrEncrypted)
+}
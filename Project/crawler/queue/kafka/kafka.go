@@ -0,0 +1,160 @@
+// Package kafka implements a queue.Broker on top of Kafka.
+//
+// Messages are distributed among the partitions of a topic by hashing their
+// keys, and the members of a consumer group split the partitions of the
+// topic between them. The offset of a message is committed when it is
+// acknowledged; messages that were received but not acknowledged when a
+// member leaves the group are redelivered to the member that takes over its
+// partitions.
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"webcrawler/crawler/queue"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/segmentio/kafka-go"
+)
+
+// The maximum time to wait for the offset of an acknowledged message to be
+// committed.
+const commitTimeout = 10 * time.Second
+
+// Config encapsulates the settings for a Broker.
+type Config struct {
+	// The addresses (host:port) of the Kafka brokers to bootstrap from.
+	Brokers []string
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if len(cfg.Brokers) == 0 {
+		err = multierror.Append(err, fmt.Errorf("no Kafka brokers have been specified"))
+	}
+	return err
+}
+
+// Broker is a queue.Broker that publishes messages to and consumes messages
+// from Kafka topics.
+type Broker struct {
+	cfg    Config
+	writer *kafka.Writer
+
+	mu      sync.Mutex
+	readers []*kafka.Reader
+}
+
+// NewBroker returns a new Broker using the provided config. Topics that do
+// not exist are created when messages are first published to them.
+func NewBroker(cfg Config) (*Broker, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("kafka broker: config validation failed: %w", err)
+	}
+	return &Broker{
+		cfg: cfg,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.Brokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+	}, nil
+}
+
+// Publish implements queue.Broker. It returns once all brokers that hold a
+// replica of the target partitions have acknowledged the messages.
+func (b *Broker) Publish(ctx context.Context, topic string, msgs ...queue.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	kmsgs := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		kmsgs[i] = kafka.Message{Topic: topic, Key: []byte(msg.Key), Value: msg.Value}
+		for k, v := range msg.Headers {
+			kmsgs[i].Headers = append(kmsgs[i].Headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+	}
+	if err := b.writer.WriteMessages(ctx, kmsgs...); err != nil {
+		return fmt.Errorf("kafka broker: %w", err)
+	}
+	return nil
+}
+
+// Subscribe implements queue.Broker. New consumer groups start consuming from
+// the oldest message that is retained by the topic.
+func (b *Broker) Subscribe(topic, group string) (queue.Subscription, error) {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     b.cfg.Brokers,
+		Topic:       topic,
+		GroupID:     group,
+		StartOffset: kafka.FirstOffset,
+	})
+	b.mu.Lock()
+	b.readers = append(b.readers, r)
+	b.mu.Unlock()
+	return &subscription{broker: b, reader: r}, nil
+}
+
+// Close flushes the pending messages and closes all subscriptions.
+func (b *Broker) Close() error {
+	var err error
+	if wErr := b.writer.Close(); wErr != nil {
+		err = multierror.Append(err, wErr)
+	}
+	b.mu.Lock()
+	readers := b.readers
+	b.readers = nil
+	b.mu.Unlock()
+	for _, r := range readers {
+		if rErr := r.Close(); rErr != nil {
+			err = multierror.Append(err, rErr)
+		}
+	}
+	return err
+}
+
+func (b *Broker) removeReader(r *kafka.Reader) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, other := range b.readers {
+		if other == r {
+			b.readers = append(b.readers[:i], b.readers[i+1:]...)
+			return
+		}
+	}
+}
+
+// subscription is a member of a Kafka consumer group.
+type subscription struct {
+	broker *Broker
+	reader *kafka.Reader
+}
+
+// Next implements queue.Subscription.
+func (s *subscription) Next(ctx context.Context) (*queue.Delivery, error) {
+	kmsg, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := queue.Message{Key: string(kmsg.Key), Value: kmsg.Value}
+	if len(kmsg.Headers) != 0 {
+		msg.Headers = make(map[string]string, len(kmsg.Headers))
+		for _, h := range kmsg.Headers {
+			msg.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return queue.NewDelivery(msg, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+		defer cancel()
+		return s.reader.CommitMessages(ctx, kmsg)
+	}), nil
+}
+
+// Close implements queue.Subscription.
+func (s *subscription) Close() error {
+	s.broker.removeReader(s.reader)
+	return s.reader.Close()
+}
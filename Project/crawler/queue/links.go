@@ -0,0 +1,312 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+// The headers that are set on link messages.
+const (
+	// AttemptHeader holds the number of times that crawling the link has
+	// failed. It is absent for new messages.
+	AttemptHeader = "webcrawler-attempt"
+
+	// ErrorHeader holds the error that caused a link to be moved to the
+	// dead-letter topic.
+	ErrorHeader = "webcrawler-error"
+)
+
+// linkPayload is the JSON encoding of a link message.
+type linkPayload struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	RetrievedAt int64     `json:"retrievedAt,omitempty"`
+	ContentHash uint64    `json:"contentHash,omitempty"`
+}
+
+// encodeLink returns the message for link. Messages are keyed by the host of
+// the link so that all links of a host are crawled by the same member of a
+// consumer group, which keeps per-host pacing and robots.txt caching local to
+// that member.
+func encodeLink(link *graph.Link) (Message, error) {
+	value, err := json.Marshal(linkPayload{ID: link.ID, URL: link.URL, RetrievedAt: link.RetrievedAt, ContentHash: link.ContentHash})
+	if err != nil {
+		return Message{}, err
+	}
+	var key string
+	if u, err := url.Parse(link.URL); err == nil {
+		key = u.Hostname()
+	}
+	return Message{Key: key, Value: value}, nil
+}
+
+// decodeLink returns the link encoded by msg.
+func decodeLink(msg Message) (*graph.Link, error) {
+	var p linkPayload
+	if err := json.Unmarshal(msg.Value, &p); err != nil {
+		return nil, fmt.Errorf("malformed link message: %w", err)
+	}
+	if p.ID == uuid.Nil || p.URL == "" {
+		return nil, errors.New("malformed link message: missing link ID or URL")
+	}
+	return &graph.Link{ID: p.ID, URL: p.URL, RetrievedAt: p.RetrievedAt, ContentHash: p.ContentHash}, nil
+}
+
+// attempts returns the number of failed attempts recorded in msg.
+func attempts(msg Message) int {
+	n, _ := strconv.Atoi(msg.Headers[AttemptHeader])
+	return n
+}
+
+// CrawlFunc crawls the links of it.
+type CrawlFunc func(ctx context.Context, it graph.LinkIterator) error
+
+// LinkQueueConfig encapsulates the settings for a LinkQueue.
+type LinkQueueConfig struct {
+	// The broker that carries the link messages.
+	Broker Broker
+
+	// The topic to which the links are published.
+	Topic string
+
+	// The topic to which links that could not be crawled are moved.
+	// Defaults to Topic with a ".dlq" suffix.
+	DeadLetterTopic string
+
+	// The consumer group of the workers that crawl the links.
+	Group string
+
+	// The number of times that crawling a link is attempted before it is
+	// moved to the dead-letter topic.
+	MaxAttempts int
+
+	// The maximum number of links that are crawled together.
+	BatchSize int
+
+	// The maximum time to wait for a batch to fill up once its first
+	// link has been received.
+	BatchWait time.Duration
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *LinkQueueConfig) validate() error {
+	var err error
+	if cfg.Broker == nil {
+		err = multierror.Append(err, fmt.Errorf("broker has not been provided"))
+	}
+	if cfg.Topic == "" {
+		err = multierror.Append(err, fmt.Errorf("topic has not been specified"))
+	}
+	if cfg.DeadLetterTopic == "" {
+		cfg.DeadLetterTopic = cfg.Topic + ".dlq"
+	}
+	if cfg.Group == "" {
+		err = multierror.Append(err, fmt.Errorf("consumer group has not been specified"))
+	}
+	if cfg.MaxAttempts <= 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid max attempts"))
+	}
+	if cfg.BatchSize <= 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid batch size"))
+	}
+	if cfg.BatchWait <= 0 {
+		cfg.BatchWait = time.Second
+	}
+	return err
+}
+
+// LinkQueue publishes links to a topic and crawls the links that are
+// consumed from it.
+type LinkQueue struct {
+	cfg    LinkQueueConfig
+	logger *slog.Logger
+}
+
+// NewLinkQueue returns a new LinkQueue using the provided config.
+func NewLinkQueue(cfg LinkQueueConfig) (*LinkQueue, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("link queue: config validation failed: %w", err)
+	}
+	return &LinkQueue{cfg: cfg, logger: logging.Component(cfg.Logger, "queue")}, nil
+}
+
+// Publish publishes links to the topic of the queue.
+func (q *LinkQueue) Publish(ctx context.Context, links ...*graph.Link) error {
+	msgs := make([]Message, 0, len(links))
+	for _, link := range links {
+		msg, err := encodeLink(link)
+		if err != nil {
+			return fmt.Errorf("link queue: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := q.cfg.Broker.Publish(ctx, q.cfg.Topic, msgs...); err != nil {
+		return fmt.Errorf("link queue: %w", err)
+	}
+	metrics.QueueMessages.WithLabelValues("published").Add(float64(len(msgs)))
+	return nil
+}
+
+// Consume joins the consumer group of the queue and crawls the links that
+// are delivered to it in batches until ctx is cancelled.
+//
+// The messages of a batch are acknowledged once crawl returns. If crawl
+// fails, each link of the batch is published again with an incremented
+// attempt count or moved to the dead-letter topic once it has exhausted its
+// attempts. If ctx is cancelled while a batch is crawled, the batch is not
+// acknowledged and its links are redelivered to another consumer; links can
+// therefore be crawled more than once.
+func (q *LinkQueue) Consume(ctx context.Context, crawl CrawlFunc) error {
+	sub, err := q.cfg.Broker.Subscribe(q.cfg.Topic, q.cfg.Group)
+	if err != nil {
+		return fmt.Errorf("link queue: %w", err)
+	}
+	defer func() { _ = sub.Close() }()
+
+	for {
+		batch, err := q.nextBatch(ctx, sub)
+		if len(batch) != 0 {
+			if batchErr := q.process(ctx, batch, crawl); batchErr != nil {
+				return fmt.Errorf("link queue: %w", batchErr)
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("link queue: %w", err)
+		}
+	}
+}
+
+// nextBatch blocks until a message is delivered and then collects further
+// messages until the batch is full or BatchWait elapses.
+func (q *LinkQueue) nextBatch(ctx context.Context, sub Subscription) ([]*Delivery, error) {
+	first, err := sub.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch := []*Delivery{first}
+
+	waitCtx, cancel := context.WithTimeout(ctx, q.cfg.BatchWait)
+	defer cancel()
+	for len(batch) < q.cfg.BatchSize {
+		d, err := sub.Next(waitCtx)
+		if err != nil {
+			if waitCtx.Err() != nil && ctx.Err() == nil {
+				err = nil
+			}
+			return batch, err
+		}
+		batch = append(batch, d)
+	}
+	return batch, nil
+}
+
+// process crawls the links of batch and acknowledges their messages.
+// Messages that do not encode a link are moved to the dead-letter topic.
+func (q *LinkQueue) process(ctx context.Context, batch []*Delivery, crawl CrawlFunc) error {
+	links := make([]*graph.Link, 0, len(batch))
+	valid := make([]*Delivery, 0, len(batch))
+	for _, d := range batch {
+		link, err := decodeLink(d.Message)
+		if err != nil {
+			if err = q.deadLetter(ctx, d, err); err != nil {
+				return err
+			}
+			continue
+		}
+		links = append(links, link)
+		valid = append(valid, d)
+	}
+	if len(links) == 0 {
+		return nil
+	}
+
+	crawlErr := crawl(ctx, &sliceLinkIterator{links: links})
+	if ctx.Err() != nil {
+		// The links are redelivered once the subscription is closed.
+		return nil
+	}
+	for _, d := range valid {
+		if crawlErr != nil {
+			if err := q.retry(ctx, d, crawlErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.Ack(); err != nil {
+			return err
+		}
+		metrics.QueueMessages.WithLabelValues("acked").Inc()
+	}
+	return nil
+}
+
+// retry publishes the message of d again with an incremented attempt count
+// or moves it to the dead-letter topic if it has exhausted its attempts.
+func (q *LinkQueue) retry(ctx context.Context, d *Delivery, cause error) error {
+	attempt := attempts(d.Message) + 1
+	if attempt >= q.cfg.MaxAttempts {
+		return q.deadLetter(ctx, d, cause)
+	}
+	msg := withHeader(d.Message, AttemptHeader, strconv.Itoa(attempt))
+	if err := q.cfg.Broker.Publish(ctx, q.cfg.Topic, msg); err != nil {
+		return err
+	}
+	metrics.QueueMessages.WithLabelValues("retried").Inc()
+	return d.Ack()
+}
+
+// deadLetter moves the message of d to the dead-letter topic.
+func (q *LinkQueue) deadLetter(ctx context.Context, d *Delivery, cause error) error {
+	q.logger.Warn("moving message to dead-letter topic", "topic", q.cfg.DeadLetterTopic, "key", d.Key, "err", cause)
+	msg := withHeader(d.Message, ErrorHeader, cause.Error())
+	if err := q.cfg.Broker.Publish(ctx, q.cfg.DeadLetterTopic, msg); err != nil {
+		return err
+	}
+	metrics.QueueMessages.WithLabelValues("dead_lettered").Inc()
+	return d.Ack()
+}
+
+// withHeader returns a copy of msg with the specified header set.
+func withHeader(msg Message, key, value string) Message {
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[key] = value
+	msg.Headers = headers
+	return msg
+}
+
+// sliceLinkIterator is a graph.LinkIterator over a slice of links.
+type sliceLinkIterator struct {
+	links []*graph.Link
+	cur   *graph.Link
+}
+
+func (it *sliceLinkIterator) Next() bool {
+	if len(it.links) == 0 {
+		return false
+	}
+	it.cur, it.links = it.links[0], it.links[1:]
+	return true
+}
+
+func (it *sliceLinkIterator) Link() *graph.Link { return it.cur }
+func (it *sliceLinkIterator) Error() error      { return nil }
+func (it *sliceLinkIterator) Close() error      { return nil }
@@ -0,0 +1,194 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryBroker is a Broker that keeps the messages of each topic in memory.
+// It allows the queue-based pipeline to run in a single process and is used
+// by tests.
+//
+// Each consumer group receives all messages of a topic, including those that
+// were published before the group was created. Within a group, messages are
+// handed out to the member that asks first rather than partitioned by key.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]*memTopic
+	closed bool
+}
+
+type memTopic struct {
+	log    []Message
+	groups map[string]*memGroup
+}
+
+type memGroup struct {
+	pending []Message
+
+	// signal is closed and replaced whenever messages are added to
+	// pending.
+	signal chan struct{}
+}
+
+// NewMemoryBroker returns a new MemoryBroker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: make(map[string]*memTopic)}
+}
+
+// Publish implements Broker.
+func (b *MemoryBroker) Publish(_ context.Context, topic string, msgs ...Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	t := b.topic(topic)
+	t.log = append(t.log, msgs...)
+	for _, g := range t.groups {
+		g.push(msgs...)
+	}
+	return nil
+}
+
+// Subscribe implements Broker.
+func (b *MemoryBroker) Subscribe(topic, group string) (Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	t := b.topic(topic)
+	g := t.groups[group]
+	if g == nil {
+		g = &memGroup{signal: make(chan struct{})}
+		g.push(t.log...)
+		t.groups[group] = g
+	}
+	return &memSubscription{broker: b, group: g, inflight: make(map[uint64]Message)}, nil
+}
+
+// Messages returns a copy of the messages that have been published to topic.
+func (b *MemoryBroker) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t := b.topics[topic]; t != nil {
+		return append([]Message(nil), t.log...)
+	}
+	return nil
+}
+
+// Close closes the broker. Pending calls to Next return ErrClosed.
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		for _, t := range b.topics {
+			for _, g := range t.groups {
+				close(g.signal)
+			}
+		}
+	}
+	return nil
+}
+
+// topic returns the topic with the specified name, creating it if needed.
+// The caller must hold b.mu.
+func (b *MemoryBroker) topic(name string) *memTopic {
+	t := b.topics[name]
+	if t == nil {
+		t = &memTopic{groups: make(map[string]*memGroup)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// push appends msgs to the pending messages of g. The caller must hold the
+// broker lock.
+func (g *memGroup) push(msgs ...Message) {
+	if len(msgs) == 0 {
+		return
+	}
+	g.pending = append(g.pending, msgs...)
+	close(g.signal)
+	g.signal = make(chan struct{})
+}
+
+// memSubscription is a member of a MemoryBroker consumer group.
+type memSubscription struct {
+	broker *MemoryBroker
+	group  *memGroup
+
+	// The messages that were received but not acknowledged; protected by
+	// the broker lock.
+	inflight map[uint64]Message
+	nextID   uint64
+	closed   bool
+}
+
+// Next implements Subscription.
+func (s *memSubscription) Next(ctx context.Context) (*Delivery, error) {
+	for {
+		s.broker.mu.Lock()
+		if s.closed || s.broker.closed {
+			s.broker.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if len(s.group.pending) > 0 {
+			msg := s.group.pending[0]
+			s.group.pending = s.group.pending[1:]
+			id := s.nextID
+			s.nextID++
+			s.inflight[id] = msg
+			s.broker.mu.Unlock()
+			return NewDelivery(msg, func() error { return s.ack(id) }), nil
+		}
+		signal := s.group.signal
+		s.broker.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-signal:
+		}
+	}
+}
+
+func (s *memSubscription) ack(id uint64) error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	delete(s.inflight, id)
+	return nil
+}
+
+// Close implements Subscription. Unacknowledged messages are returned to the
+// front of the group's pending messages.
+func (s *memSubscription) Close() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if len(s.inflight) == 0 || s.broker.closed {
+		return nil
+	}
+	ids := make([]uint64, 0, len(s.inflight))
+	for id := range s.inflight {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	requeued := make([]Message, 0, len(ids)+len(s.group.pending))
+	for _, id := range ids {
+		requeued = append(requeued, s.inflight[id])
+	}
+	requeued = append(requeued, s.group.pending...)
+	s.group.pending = nil
+	s.group.push(requeued...)
+	return nil
+}
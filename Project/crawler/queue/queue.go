@@ -0,0 +1,81 @@
+// Package queue provides a message-queue transport for the crawler pipeline
+// so that crawling can be scaled horizontally. Instead of crawling the due
+// links of its partition itself, the crawler service publishes them to a
+// topic and a pool of workers consumes them and sends them through the
+// fetch, extract and index stages of the pipeline.
+//
+// Messages are processed at least once: a worker only acknowledges a batch of
+// links once it has been crawled and messages that are not acknowledged are
+// redelivered to another member of the consumer group. Links that repeatedly
+// fail to be crawled are moved to a dead-letter topic.
+//
+// The Broker interface abstracts the message queue; this package provides an
+// in-memory implementation for tests and single-process deployments and the
+// kafka subpackage an implementation backed by Kafka.
+package queue
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned when a broker or subscription has been closed.
+var ErrClosed = errors.New("queue: closed")
+
+// Message is a message that is published to a topic.
+type Message struct {
+	// The key of the message. Messages with the same key are delivered to
+	// the same member of a consumer group in the order in which they were
+	// published.
+	Key string
+
+	// The message payload.
+	Value []byte
+
+	// Optional metadata for the message.
+	Headers map[string]string
+}
+
+// Delivery is a message that was received by a subscription.
+type Delivery struct {
+	Message
+
+	ack func() error
+}
+
+// NewDelivery returns a Delivery for msg. Broker implementations invoke ack
+// when the message is acknowledged.
+func NewDelivery(msg Message, ack func() error) *Delivery {
+	return &Delivery{Message: msg, ack: ack}
+}
+
+// Ack acknowledges that the message has been processed so that it is not
+// redelivered.
+func (d *Delivery) Ack() error {
+	if d.ack == nil {
+		return nil
+	}
+	return d.ack()
+}
+
+// Subscription receives the messages of a topic on behalf of a member of a
+// consumer group.
+type Subscription interface {
+	// Next blocks until the next message is available or ctx expires.
+	Next(ctx context.Context) (*Delivery, error)
+
+	// Close leaves the consumer group. Messages that were received but
+	// not acknowledged are redelivered to the remaining members.
+	Close() error
+}
+
+// Broker is implemented by message queues.
+type Broker interface {
+	// Publish appends msgs to topic.
+	Publish(ctx context.Context, topic string, msgs ...Message) error
+
+	// Subscribe joins the consumer group with the specified name for
+	// topic. Each message of the topic is delivered to one member of each
+	// consumer group.
+	Subscribe(topic, group string) (Subscription, error)
+}
@@ -0,0 +1,225 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"webcrawler/crawler/iterutil"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(QueueTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type QueueTestSuite struct{}
+
+func (s *QueueTestSuite) TestMemoryBrokerConsumerGroups(c *gc.C) {
+	b := NewMemoryBroker()
+	defer func() { _ = b.Close() }()
+	ctx := context.Background()
+	c.Assert(b.Publish(ctx, "topic", Message{Key: "a"}, Message{Key: "b"}), gc.IsNil)
+
+	// Each group receives all messages, including those published before
+	// it was created.
+	for _, group := range []string{"g1", "g2"} {
+		sub, err := b.Subscribe("topic", group)
+		c.Assert(err, gc.IsNil)
+		for _, key := range []string{"a", "b"} {
+			d, err := sub.Next(ctx)
+			c.Assert(err, gc.IsNil)
+			c.Assert(d.Key, gc.Equals, key)
+			c.Assert(d.Ack(), gc.IsNil)
+		}
+		c.Assert(sub.Close(), gc.IsNil)
+	}
+
+	// Members of the same group share the messages.
+	sub1, err := b.Subscribe("topic", "g1")
+	c.Assert(err, gc.IsNil)
+	sub2, err := b.Subscribe("topic", "g1")
+	c.Assert(err, gc.IsNil)
+	c.Assert(b.Publish(ctx, "topic", Message{Key: "c"}, Message{Key: "d"}), gc.IsNil)
+	d1, err := sub1.Next(ctx)
+	c.Assert(err, gc.IsNil)
+	d2, err := sub2.Next(ctx)
+	c.Assert(err, gc.IsNil)
+	c.Assert([]string{d1.Key, d2.Key}, gc.DeepEquals, []string{"c", "d"})
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = sub2.Next(timeoutCtx)
+	c.Assert(err, gc.Equals, context.DeadlineExceeded)
+}
+
+func (s *QueueTestSuite) TestMemoryBrokerRedeliversUnackedMessages(c *gc.C) {
+	b := NewMemoryBroker()
+	defer func() { _ = b.Close() }()
+	ctx := context.Background()
+	c.Assert(b.Publish(ctx, "topic", Message{Key: "a"}, Message{Key: "b"}, Message{Key: "c"}), gc.IsNil)
+
+	sub, err := b.Subscribe("topic", "g")
+	c.Assert(err, gc.IsNil)
+	a, err := sub.Next(ctx)
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Ack(), gc.IsNil)
+	_, err = sub.Next(ctx)
+	c.Assert(err, gc.IsNil)
+	c.Assert(sub.Close(), gc.IsNil)
+
+	sub, err = b.Subscribe("topic", "g")
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = sub.Close() }()
+	for _, key := range []string{"b", "c"} {
+		d, err := sub.Next(ctx)
+		c.Assert(err, gc.IsNil)
+		c.Assert(d.Key, gc.Equals, key)
+	}
+
+	c.Assert(b.Close(), gc.IsNil)
+	_, err = sub.Next(ctx)
+	c.Assert(err, gc.Equals, ErrClosed)
+}
+
+func (s *QueueTestSuite) TestConsume(c *gc.C) {
+	b := NewMemoryBroker()
+	defer func() { _ = b.Close() }()
+	q := newTestQueue(c, b)
+
+	links := []*graph.Link{
+		{ID: uuid.New(), URL: "http://example.com/a", RetrievedAt: 42, ContentHash: 7},
+		{ID: uuid.New(), URL: "http://example.com/b"},
+		{ID: uuid.New(), URL: "http://example.org/"},
+	}
+	c.Assert(q.Publish(context.Background(), links...), gc.IsNil)
+	msgs := b.Messages("links")
+	c.Assert(msgs, gc.HasLen, 3)
+	c.Assert(msgs[0].Key, gc.Equals, "example.com")
+	c.Assert(msgs[2].Key, gc.Equals, "example.org")
+
+	var (
+		mu      sync.Mutex
+		crawled []*graph.Link
+	)
+	consumeUntil(c, q, func(_ context.Context, it graph.LinkIterator) error {
+		batch, err := iterutil.CollectLinks(it)
+		c.Assert(err, gc.IsNil)
+		mu.Lock()
+		crawled = append(crawled, batch...)
+		mu.Unlock()
+		return nil
+	}, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(crawled) == len(links)
+	})
+	c.Assert(crawled, gc.DeepEquals, links)
+	c.Assert(b.Messages("links.dlq"), gc.HasLen, 0)
+}
+
+func (s *QueueTestSuite) TestConsumeRetriesAndDeadLettersFailedLinks(c *gc.C) {
+	b := NewMemoryBroker()
+	defer func() { _ = b.Close() }()
+	q := newTestQueue(c, b)
+
+	link := &graph.Link{ID: uuid.New(), URL: "http://example.com/"}
+	c.Assert(q.Publish(context.Background(), link), gc.IsNil)
+	c.Assert(b.Publish(context.Background(), "links", Message{Value: []byte("not a link")}), gc.IsNil)
+
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	consumeUntil(c, q, func(_ context.Context, it graph.LinkIterator) error {
+		_, _ = iterutil.CollectLinks(it)
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		return errors.New("index unavailable")
+	}, func() bool { return len(b.Messages("links.dlq")) == 2 })
+
+	c.Assert(attempts, gc.Equals, 3)
+	dlq := b.Messages("links.dlq")
+	c.Assert(dlq[0].Headers[ErrorHeader], gc.Matches, "malformed link message: .*")
+	c.Assert(dlq[1].Key, gc.Equals, "example.com")
+	c.Assert(dlq[1].Headers[ErrorHeader], gc.Equals, "index unavailable")
+	c.Assert(dlq[1].Headers[AttemptHeader], gc.Equals, "2")
+	dead, err := decodeLink(dlq[1])
+	c.Assert(err, gc.IsNil)
+	c.Assert(dead, gc.DeepEquals, link)
+}
+
+func (s *QueueTestSuite) TestConsumeDoesNotAckInterruptedBatches(c *gc.C) {
+	b := NewMemoryBroker()
+	defer func() { _ = b.Close() }()
+	q := newTestQueue(c, b)
+	link := &graph.Link{ID: uuid.New(), URL: "http://example.com/"}
+	c.Assert(q.Publish(context.Background(), link), gc.IsNil)
+
+	// The consumer is stopped while the batch is crawled.
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Assert(q.Consume(ctx, func(ctx context.Context, _ graph.LinkIterator) error {
+		cancel()
+		return ctx.Err()
+	}), gc.IsNil)
+
+	// The link is redelivered to the next member of the group.
+	var (
+		mu      sync.Mutex
+		crawled []*graph.Link
+	)
+	consumeUntil(c, q, func(_ context.Context, it graph.LinkIterator) error {
+		batch, err := iterutil.CollectLinks(it)
+		mu.Lock()
+		crawled = append(crawled, batch...)
+		mu.Unlock()
+		return err
+	}, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(crawled) != 0
+	})
+	c.Assert(crawled, gc.DeepEquals, []*graph.Link{link})
+	c.Assert(b.Messages("links"), gc.HasLen, 1)
+}
+
+func (s *QueueTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewLinkQueue(LinkQueueConfig{})
+	c.Assert(err, gc.ErrorMatches, `(?s)link queue: config validation failed: .*broker has not been provided.*topic has not been specified.*consumer group has not been specified.*invalid max attempts.*invalid batch size.*`)
+}
+
+func newTestQueue(c *gc.C, b Broker) *LinkQueue {
+	q, err := NewLinkQueue(LinkQueueConfig{
+		Broker:      b,
+		Topic:       "links",
+		Group:       "crawlers",
+		MaxAttempts: 3,
+		BatchSize:   2,
+		BatchWait:   10 * time.Millisecond,
+	})
+	c.Assert(err, gc.IsNil)
+	return q
+}
+
+// consumeUntil consumes the links of q until cond is satisfied.
+func consumeUntil(c *gc.C, q *LinkQueue, crawl CrawlFunc, cond func() bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- q.Consume(ctx, crawl) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			c.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+}
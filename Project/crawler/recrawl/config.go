@@ -0,0 +1,98 @@
+package recrawl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Config encapsulates the settings for a Scheduler.
+type Config struct {
+	// An ordered list of rules for assigning links to tiers. The first
+	// matching rule wins. Links that do not match any rule are assigned
+	// to TierStandard.
+	Rules []Rule
+
+	// The recrawl interval for each tier. Missing entries are populated
+	// from DefaultIntervals.
+	Intervals map[Tier]time.Duration
+
+	// Links whose observed change rate rises above this threshold are
+	// promoted to a more frequently crawled tier. Defaults to 0.75.
+	PromoteAbove float64
+
+	// Links whose observed change rate drops below this threshold are
+	// demoted to a less frequently crawled tier. Defaults to 0.1.
+	DemoteBelow float64
+
+	// The number of fetches that must be observed for a link before it
+	// can be promoted or demoted. Defaults to 3.
+	MinObservations int
+
+	// The weight (0, 1] given to the latest observation when updating the
+	// exponentially weighted change rate of a link. Defaults to 0.3.
+	Smoothing float64
+
+	// The maximum number of links that are tracked. Once the limit is
+	// reached, an arbitrary link is evicted for each new link. Defaults
+	// to 1000000.
+	MaxTrackedLinks int
+
+	// A function that returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *Config) validate() error {
+	var err error
+
+	if cfg.Intervals == nil {
+		cfg.Intervals = make(map[Tier]time.Duration, len(DefaultIntervals))
+	}
+	for _, tier := range Tiers {
+		if cfg.Intervals[tier] == 0 {
+			cfg.Intervals[tier] = DefaultIntervals[tier]
+		} else if cfg.Intervals[tier] < 0 {
+			err = multierror.Append(err, fmt.Errorf("invalid recrawl interval for tier %q", tier))
+		}
+	}
+
+	for i, rule := range cfg.Rules {
+		if rule.Pattern == nil {
+			err = multierror.Append(err, fmt.Errorf("rule %d: missing URL pattern", i))
+		}
+		if rule.Tier > TierArchive {
+			err = multierror.Append(err, fmt.Errorf("rule %d: unknown tier %d", i, rule.Tier))
+		}
+	}
+
+	if cfg.PromoteAbove == 0 {
+		cfg.PromoteAbove = 0.75
+	}
+	if cfg.DemoteBelow == 0 {
+		cfg.DemoteBelow = 0.1
+	}
+	if cfg.DemoteBelow >= cfg.PromoteAbove {
+		err = multierror.Append(err, fmt.Errorf("demotion threshold must be lower than the promotion threshold"))
+	}
+
+	if cfg.MinObservations <= 0 {
+		cfg.MinObservations = 3
+	}
+
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = 0.3
+	} else if cfg.Smoothing < 0 || cfg.Smoothing > 1 {
+		err = multierror.Append(err, fmt.Errorf("smoothing factor must be in the (0, 1] range"))
+	}
+
+	if cfg.MaxTrackedLinks <= 0 {
+		cfg.MaxTrackedLinks = 1000000
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+
+	return err
+}
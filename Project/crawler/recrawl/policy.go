@@ -0,0 +1,279 @@
+package recrawl
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Scores looks up the PageRank scores of links.
+type Scores interface {
+	// PageRank returns the PageRank score of the link with the specified
+	// ID.
+	PageRank(linkID uuid.UUID) (float64, error)
+}
+
+// PolicyConfig encapsulates the settings for a Policy.
+type PolicyConfig struct {
+	// The recrawl interval for links at the root of their host whose
+	// change rate matches TargetChangeRate and that have no PageRank
+	// score. Defaults to 7 days.
+	BaseInterval time.Duration
+
+	// The bounds for the computed recrawl intervals. They default to 1
+	// hour and 90 days respectively.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// The change rate at which links are recrawled every BaseInterval.
+	// Links whose content changes more (or less) often are recrawled
+	// proportionally more (or less) often. Defaults to 0.5.
+	TargetChangeRate float64
+
+	// The number of refetches of a link that must be observed before its
+	// change rate is taken into account. Defaults to 2.
+	MinObservations int
+
+	// The weight (0, 1] given to the latest observation when updating the
+	// exponentially weighted change rate of a link. Defaults to 0.3.
+	Smoothing float64
+
+	// The relative increase of the recrawl interval for each segment of
+	// the URL path of a link. Pages deep within a site tend to change
+	// less often than its landing pages. Defaults to 0.25; a negative
+	// value disables the depth penalty.
+	DepthPenalty float64
+
+	// An optional source of PageRank scores. Links whose score reaches
+	// PageRankReference have their recrawl interval divided by
+	// 1+PageRankBoost; links with lower scores receive a proportionally
+	// smaller boost.
+	Scores Scores
+
+	// The PageRank score that receives the full boost. As scores sum up
+	// to 1 across the link graph, suitable values depend on the size of
+	// the graph. Defaults to 0.001.
+	PageRankReference float64
+
+	// The maximum factor by which a high PageRank score shortens the
+	// recrawl interval of a link. Defaults to 3; a negative value
+	// disables the boost.
+	PageRankBoost float64
+
+	// The maximum number of links whose change history is tracked. Once
+	// the limit is reached, the history of an arbitrary link is evicted
+	// for each new link. Defaults to 1000000.
+	MaxTrackedLinks int
+}
+
+func (cfg *PolicyConfig) validate() error {
+	var err error
+	if cfg.BaseInterval == 0 {
+		cfg.BaseInterval = 7 * 24 * time.Hour
+	}
+	if cfg.MinInterval == 0 {
+		cfg.MinInterval = time.Hour
+	}
+	if cfg.MaxInterval == 0 {
+		cfg.MaxInterval = 90 * 24 * time.Hour
+	}
+	if cfg.BaseInterval < 0 || cfg.MinInterval < 0 || cfg.MaxInterval < 0 {
+		err = multierror.Append(err, fmt.Errorf("recrawl intervals must not be negative"))
+	} else if cfg.MinInterval > cfg.MaxInterval {
+		err = multierror.Append(err, fmt.Errorf("min recrawl interval must not exceed the max recrawl interval"))
+	}
+
+	if cfg.TargetChangeRate == 0 {
+		cfg.TargetChangeRate = 0.5
+	} else if cfg.TargetChangeRate < 0 || cfg.TargetChangeRate > 1 {
+		err = multierror.Append(err, fmt.Errorf("target change rate must be in the (0, 1] range"))
+	}
+	if cfg.MinObservations <= 0 {
+		cfg.MinObservations = 2
+	}
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = 0.3
+	} else if cfg.Smoothing < 0 || cfg.Smoothing > 1 {
+		err = multierror.Append(err, fmt.Errorf("smoothing factor must be in the (0, 1] range"))
+	}
+
+	if cfg.DepthPenalty == 0 {
+		cfg.DepthPenalty = 0.25
+	} else if cfg.DepthPenalty < 0 {
+		cfg.DepthPenalty = 0
+	}
+	if cfg.PageRankReference == 0 {
+		cfg.PageRankReference = 0.001
+	} else if cfg.PageRankReference < 0 {
+		err = multierror.Append(err, fmt.Errorf("PageRank reference score must be positive"))
+	}
+	if cfg.PageRankBoost == 0 {
+		cfg.PageRankBoost = 3
+	} else if cfg.PageRankBoost < 0 {
+		cfg.PageRankBoost = 0
+	}
+
+	if cfg.MaxTrackedLinks <= 0 {
+		cfg.MaxTrackedLinks = 1000000
+	}
+	return err
+}
+
+// changeHistory tracks how often the content of a link changes.
+type changeHistory struct {
+	contentHash  uint64
+	observations int
+	changeRate   float64
+}
+
+// Policy computes per-link recrawl intervals based on how often the content
+// of each link has changed between fetches, the depth of the link within its
+// site and its PageRank score. The change history of each link is kept in
+// memory, so links are scheduled using their depth and score alone until
+// enough fetches have been observed by the process. It is safe for concurrent
+// use.
+type Policy struct {
+	cfg PolicyConfig
+
+	mu      sync.Mutex
+	history map[uuid.UUID]*changeHistory
+}
+
+// NewPolicy returns a new Policy instance using the provided config.
+func NewPolicy(cfg PolicyConfig) (*Policy, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("recrawl policy: config validation failed: %w", err)
+	}
+	return &Policy{cfg: cfg, history: make(map[uuid.UUID]*changeHistory)}, nil
+}
+
+// NextFetch records that link was fetched at fetchedAt and its content hashed
+// to contentHash (see index.ContentHash) and returns the time at which the
+// link is due to be fetched again.
+func (p *Policy) NextFetch(link *graph.Link, contentHash uint64, fetchedAt time.Time) time.Time {
+	changeRate, observed := p.recordFetch(link.ID, contentHash)
+
+	var pageRank float64
+	if p.cfg.Scores != nil && p.cfg.PageRankBoost > 0 {
+		// Links without a score (e.g. links that have not been indexed
+		// yet) are not boosted.
+		if score, err := p.cfg.Scores.PageRank(link.ID); err == nil {
+			pageRank = score
+		}
+	}
+	return fetchedAt.Add(p.interval(changeRate, observed, URLDepth(link.URL), pageRank))
+}
+
+// recordFetch updates the change history of a link and returns its change
+// rate. The second return value is false if too few fetches have been
+// observed for the change rate to be meaningful.
+func (p *Policy) recordFetch(linkID uuid.UUID, contentHash uint64) (float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h, exists := p.history[linkID]
+	if !exists {
+		if len(p.history) >= p.cfg.MaxTrackedLinks {
+			for id := range p.history {
+				delete(p.history, id)
+				break
+			}
+		}
+		p.history[linkID] = &changeHistory{contentHash: contentHash}
+		return 0, false
+	}
+
+	var changed float64
+	if contentHash != h.contentHash {
+		changed = 1
+	}
+	if h.observations == 0 {
+		h.changeRate = changed
+	} else {
+		h.changeRate = p.cfg.Smoothing*changed + (1-p.cfg.Smoothing)*h.changeRate
+	}
+	h.observations++
+	h.contentHash = contentHash
+	return h.changeRate, h.observations >= p.cfg.MinObservations
+}
+
+// interval returns the recrawl interval for a link with the provided
+// properties.
+func (p *Policy) interval(changeRate float64, observed bool, depth int, pageRank float64) time.Duration {
+	interval := float64(p.cfg.BaseInterval)
+	if observed && changeRate > 0 {
+		interval *= p.cfg.TargetChangeRate / changeRate
+	} else if observed {
+		// Links that have not changed for a while are recrawled as
+		// rarely as possible unless they are boosted by their score.
+		interval = float64(p.cfg.MaxInterval)
+	}
+	interval *= 1 + p.cfg.DepthPenalty*float64(depth)
+	if pageRank > 0 {
+		interval /= 1 + p.cfg.PageRankBoost*math.Min(pageRank/p.cfg.PageRankReference, 1)
+	}
+
+	switch {
+	case interval < float64(p.cfg.MinInterval):
+		return p.cfg.MinInterval
+	case interval > float64(p.cfg.MaxInterval):
+		return p.cfg.MaxInterval
+	default:
+		return time.Duration(interval)
+	}
+}
+
+// URLDepth returns the number of non-empty segments in the path of rawURL,
+// which approximates the depth of a link within its site. Unparsable URLs
+// have a depth of zero.
+func URLDepth(rawURL string) int {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0
+	}
+	var depth int
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment != "" {
+			depth++
+		}
+	}
+	return depth
+}
+
+// FixedInterval is a recrawl policy that schedules each link to be fetched
+// again once the interval has elapsed.
+type FixedInterval time.Duration
+
+// NextFetch returns fetchedAt plus the fixed interval.
+func (i FixedInterval) NextFetch(_ *graph.Link, _ uint64, fetchedAt time.Time) time.Time {
+	return fetchedAt.Add(time.Duration(i))
+}
+
+// DocumentFinder is implemented by text indexers that can look up the
+// document of a link.
+type DocumentFinder interface {
+	FindByID(linkID uuid.UUID) (*index.Document, error)
+}
+
+// IndexScores implements Scores by looking up the PageRank scores that are
+// stored with the indexed documents.
+type IndexScores struct {
+	Finder DocumentFinder
+}
+
+// PageRank implements Scores.
+func (s IndexScores) PageRank(linkID uuid.UUID) (float64, error) {
+	doc, err := s.Finder.FindByID(linkID)
+	if err != nil {
+		return 0, err
+	}
+	return doc.PageRank, nil
+}
@@ -0,0 +1,117 @@
+package recrawl
+
+import (
+	"fmt"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(PolicyTestSuite))
+
+type PolicyTestSuite struct {
+	now time.Time
+}
+
+func (s *PolicyTestSuite) SetUpTest(c *gc.C) {
+	s.now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *PolicyTestSuite) TestChangeRate(c *gc.C) {
+	p := s.policy(c, PolicyConfig{BaseInterval: 24 * time.Hour, Smoothing: 1, DepthPenalty: -1})
+	link := &graph.Link{ID: uuid.New(), URL: "https://example.com/"}
+
+	// Links are scheduled using the base interval until enough
+	// refetches have been observed.
+	c.Assert(p.NextFetch(link, 1, s.now), gc.Equals, s.now.Add(24*time.Hour))
+	c.Assert(p.NextFetch(link, 2, s.now), gc.Equals, s.now.Add(24*time.Hour))
+
+	// Links that change on every fetch are recrawled twice as often as
+	// links that change at the target rate.
+	c.Assert(p.NextFetch(link, 3, s.now), gc.Equals, s.now.Add(12*time.Hour))
+
+	// Links that stop changing are recrawled as rarely as possible.
+	c.Assert(p.NextFetch(link, 3, s.now), gc.Equals, s.now.Add(90*24*time.Hour))
+}
+
+func (s *PolicyTestSuite) TestDepthPenalty(c *gc.C) {
+	p := s.policy(c, PolicyConfig{BaseInterval: 24 * time.Hour, DepthPenalty: 0.5})
+
+	for depth, url := range []string{"https://example.com", "https://example.com/a/", "https://example.com/a/b?c=d"} {
+		link := &graph.Link{ID: uuid.New(), URL: url}
+		exp := s.now.Add(time.Duration(float64(24*time.Hour) * (1 + 0.5*float64(depth))))
+		c.Assert(p.NextFetch(link, 1, s.now), gc.Equals, exp, gc.Commentf("url %q", url))
+	}
+}
+
+func (s *PolicyTestSuite) TestPageRankBoost(c *gc.C) {
+	scores := scoresMap{}
+	p := s.policy(c, PolicyConfig{
+		BaseInterval:      24 * time.Hour,
+		MinInterval:       4 * time.Hour,
+		DepthPenalty:      -1,
+		Scores:            scores,
+		PageRankReference: 0.1,
+		PageRankBoost:     3,
+	})
+
+	specs := []struct {
+		score float64
+		exp   time.Duration
+	}{
+		{score: 0, exp: 24 * time.Hour},
+		{score: 0.1 / 3, exp: 12 * time.Hour},
+		{score: 0.1, exp: 6 * time.Hour},
+		{score: 0.5, exp: 6 * time.Hour},
+	}
+	for _, spec := range specs {
+		link := &graph.Link{ID: uuid.New(), URL: "https://example.com"}
+		scores[link.ID] = spec.score
+		c.Assert(p.NextFetch(link, 1, s.now), gc.Equals, s.now.Add(spec.exp), gc.Commentf("score %f", spec.score))
+	}
+
+	// Links with unknown scores are not boosted.
+	c.Assert(p.NextFetch(&graph.Link{ID: uuid.New()}, 1, s.now), gc.Equals, s.now.Add(24*time.Hour))
+}
+
+func (s *PolicyTestSuite) TestIntervalBounds(c *gc.C) {
+	p := s.policy(c, PolicyConfig{BaseInterval: 24 * time.Hour, MinInterval: 20 * time.Hour, MaxInterval: 30 * time.Hour, DepthPenalty: 1})
+
+	link := &graph.Link{ID: uuid.New(), URL: "https://example.com/a/b/c"}
+	c.Assert(p.NextFetch(link, 1, s.now), gc.Equals, s.now.Add(30*time.Hour))
+}
+
+func (s *PolicyTestSuite) TestHistoryEviction(c *gc.C) {
+	p := s.policy(c, PolicyConfig{MaxTrackedLinks: 2})
+	for i := 0; i < 5; i++ {
+		p.NextFetch(&graph.Link{ID: uuid.New(), URL: fmt.Sprintf("https://example.com/%d", i)}, 1, s.now)
+	}
+	c.Assert(p.history, gc.HasLen, 2)
+}
+
+func (s *PolicyTestSuite) TestFixedInterval(c *gc.C) {
+	c.Assert(FixedInterval(time.Hour).NextFetch(&graph.Link{}, 1, s.now), gc.Equals, s.now.Add(time.Hour))
+}
+
+func (s *PolicyTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewPolicy(PolicyConfig{MinInterval: 2 * time.Hour, MaxInterval: time.Hour, TargetChangeRate: 2})
+	c.Assert(err, gc.ErrorMatches, "(?s)recrawl policy: config validation failed:.*min recrawl interval.*target change rate.*")
+}
+
+func (s *PolicyTestSuite) policy(c *gc.C, cfg PolicyConfig) *Policy {
+	p, err := NewPolicy(cfg)
+	c.Assert(err, gc.IsNil)
+	return p
+}
+
+type scoresMap map[uuid.UUID]float64
+
+func (m scoresMap) PageRank(linkID uuid.UUID) (float64, error) {
+	score, found := m[linkID]
+	if !found {
+		return 0, fmt.Errorf("unknown link")
+	}
+	return score, nil
+}
@@ -0,0 +1,46 @@
+package recrawl
+
+import "container/heap"
+
+// queue is a priority queue of links ordered by the time they are due to be
+// recrawled. It implements heap.Interface.
+type queue []*linkState
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return q[i].dueAt.Before(q[j].dueAt) }
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].queueIndex = i
+	q[j].queueIndex = j
+}
+
+func (q *queue) Push(x interface{}) {
+	state := x.(*linkState)
+	state.queueIndex = len(*q)
+	*q = append(*q, state)
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	state := old[n-1]
+	old[n-1] = nil
+	state.queueIndex = -1
+	*q = old[:n-1]
+	return state
+}
+
+// peek returns the link with the earliest due time or nil if the queue is
+// empty.
+func (q queue) peek() *linkState {
+	if len(q) == 0 {
+		return nil
+	}
+	return q[0]
+}
+
+// update restores the heap ordering after the due time of state changes.
+func (q *queue) update(state *linkState) {
+	heap.Fix(q, state.queueIndex)
+}
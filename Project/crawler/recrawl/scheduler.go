@@ -0,0 +1,303 @@
+// Package recrawl assigns links to recrawl tiers (news, standard and archive)
+// and maintains a separate frontier queue for each tier so that links are
+// recrawled according to the schedule of their tier.
+//
+// Links are initially assigned to a tier using a configurable set of URL
+// rules. After each fetch, the scheduler updates the observed change rate of
+// the link and promotes or demotes it to a different tier if its content
+// changes more or less frequently than expected. The scheduler also serves as
+// the recrawl policy of the crawler, in which case the due time of each link
+// is stored with the link in the link graph and picked up by the crawl
+// passes.
+//
+// Policy offers an alternative that does not require a frontier queue: it
+// computes a recrawl interval for each link from its observed change rate,
+// its depth and its PageRank score, which is stored with the link in the link
+// graph so that each crawl pass only fetches the links that are due.
+package recrawl
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnknownLink is returned when reporting a fetch for a link that has not
+// been added to the scheduler.
+var ErrUnknownLink = errors.New("unknown link")
+
+// linkState tracks the scheduling information for a single link.
+type linkState struct {
+	link   *graph.Link
+	tier   Tier
+	pinned bool
+
+	fetched      bool
+	observations int
+	changeRate   float64
+	contentHash  uint64
+
+	dueAt      time.Time
+	queueIndex int
+}
+
+// Scheduler maintains a frontier queue per recrawl tier. It is safe for
+// concurrent use.
+type Scheduler struct {
+	cfg Config
+
+	mu     sync.Mutex
+	links  map[uuid.UUID]*linkState
+	queues map[Tier]*queue
+}
+
+// NewScheduler returns a new Scheduler instance using the provided config.
+func NewScheduler(cfg Config) (*Scheduler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("recrawl scheduler: config validation failed: %w", err)
+	}
+
+	s := &Scheduler{
+		cfg:    cfg,
+		links:  make(map[uuid.UUID]*linkState),
+		queues: make(map[Tier]*queue, len(Tiers)),
+	}
+	for _, tier := range Tiers {
+		s.queues[tier] = new(queue)
+	}
+	return s, nil
+}
+
+// Add inserts link into the frontier queue of its tier or refreshes the
+// information for a link that has already been added. New links are due for
+// recrawling one tier interval after their last retrieval time; links that
+// have never been retrieved are due immediately. Add returns the tier that
+// the link has been assigned to.
+func (s *Scheduler) Add(link *graph.Link) Tier {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(link).tier
+}
+
+// add implements Add and returns the state of the link. It must be called
+// while holding the lock.
+func (s *Scheduler) add(link *graph.Link) *linkState {
+	lCopy := new(graph.Link)
+	*lCopy = *link
+
+	if state, exists := s.links[link.ID]; exists {
+		state.link = lCopy
+		return state
+	}
+	if len(s.links) >= s.cfg.MaxTrackedLinks {
+		s.evictOne()
+	}
+
+	state := &linkState{link: lCopy, tier: TierStandard}
+	if rule := s.matchRule(link.URL); rule != nil {
+		state.tier, state.pinned = rule.Tier, rule.Pinned
+	}
+	if link.RetrievedAt != 0 {
+		state.dueAt = time.Unix(link.RetrievedAt, 0).Add(s.cfg.Intervals[state.tier])
+	}
+
+	s.links[link.ID] = state
+	heap.Push(s.queues[state.tier], state)
+	return state
+}
+
+// evictOne stops tracking an arbitrary link. It must be called while holding
+// the lock.
+func (s *Scheduler) evictOne() {
+	for id, state := range s.links {
+		heap.Remove(s.queues[state.tier], state.queueIndex)
+		delete(s.links, id)
+		return
+	}
+}
+
+// RecordFetch updates the change rate of the link with the specified ID by
+// comparing contentHash to the hash of the previously fetched content. If
+// the change rate crosses one of the configured thresholds, the link is moved
+// to a different tier. The link is then rescheduled one (new) tier interval
+// after fetchedAt. RecordFetch returns the tier that the link belongs to
+// after the update.
+func (s *Scheduler) RecordFetch(linkID uuid.UUID, contentHash uint64, fetchedAt time.Time) (Tier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.links[linkID]
+	if !exists {
+		return 0, fmt.Errorf("record fetch: %w", ErrUnknownLink)
+	}
+	s.recordFetch(state, contentHash, fetchedAt)
+	return state.tier, nil
+}
+
+// NextFetch adds link to the scheduler unless it is already tracked, records
+// that it was fetched at fetchedAt and returns the time at which it is due to
+// be fetched again according to the interval of its tier. It allows the
+// scheduler to be used as the recrawl policy of the crawler.
+func (s *Scheduler) NextFetch(link *graph.Link, contentHash uint64, fetchedAt time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.add(link)
+	s.recordFetch(state, contentHash, fetchedAt)
+	return state.dueAt
+}
+
+// recordFetch implements RecordFetch. It must be called while holding the
+// lock.
+func (s *Scheduler) recordFetch(state *linkState, contentHash uint64, fetchedAt time.Time) {
+	// The first fetch only establishes the baseline content hash.
+	if state.fetched {
+		var changed float64
+		if contentHash != state.contentHash {
+			changed = 1
+		}
+		if state.observations == 0 {
+			state.changeRate = changed
+		} else {
+			state.changeRate = s.cfg.Smoothing*changed + (1-s.cfg.Smoothing)*state.changeRate
+		}
+		state.observations++
+	}
+	state.fetched, state.contentHash = true, contentHash
+	state.link.RetrievedAt = fetchedAt.Unix()
+
+	if newTier := s.adaptTier(state); newTier != state.tier {
+		heap.Remove(s.queues[state.tier], state.queueIndex)
+		state.tier = newTier
+		state.dueAt = fetchedAt.Add(s.cfg.Intervals[newTier])
+		heap.Push(s.queues[newTier], state)
+
+		// Start tracking the change rate for the new tier afresh.
+		state.observations = 0
+		return
+	}
+
+	state.dueAt = fetchedAt.Add(s.cfg.Intervals[state.tier])
+	s.queues[state.tier].update(state)
+}
+
+// Due removes up to max links (or all links if max is zero) from the frontier
+// queue of tier whose recrawl time has elapsed and returns an iterator over
+// them. Returned links are provisionally rescheduled one tier interval into
+// the future so that links whose fetch fails are eventually retried.
+func (s *Scheduler) Due(tier Tier, max int) graph.LinkIterator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		now   = s.cfg.Clock()
+		q     = s.queues[tier]
+		due   []*linkState
+		links []*graph.Link
+	)
+	if q == nil {
+		return &linkIterator{}
+	}
+
+	for (max == 0 || len(due) < max) && q.Len() != 0 && !q.peek().dueAt.After(now) {
+		state := heap.Pop(q).(*linkState)
+		due = append(due, state)
+
+		lCopy := new(graph.Link)
+		*lCopy = *state.link
+		links = append(links, lCopy)
+	}
+
+	for _, state := range due {
+		state.dueAt = now.Add(s.cfg.Intervals[tier])
+		heap.Push(q, state)
+	}
+
+	return &linkIterator{links: links}
+}
+
+// TierOf returns the tier that the link with the specified ID is assigned to.
+func (s *Scheduler) TierOf(linkID uuid.UUID) (Tier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.links[linkID]
+	if !exists {
+		return 0, fmt.Errorf("tier of: %w", ErrUnknownLink)
+	}
+	return state.tier, nil
+}
+
+// Len returns the number of links in the frontier queue of tier.
+func (s *Scheduler) Len(tier Tier) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q := s.queues[tier]; q != nil {
+		return q.Len()
+	}
+	return 0
+}
+
+// Interval returns the recrawl interval for tier.
+func (s *Scheduler) Interval(tier Tier) time.Duration {
+	return s.cfg.Intervals[tier]
+}
+
+// matchRule returns the first rule whose pattern matches url or nil if no
+// rule matches.
+func (s *Scheduler) matchRule(url string) *Rule {
+	for i := range s.cfg.Rules {
+		if s.cfg.Rules[i].Pattern.MatchString(url) {
+			return &s.cfg.Rules[i]
+		}
+	}
+	return nil
+}
+
+// adaptTier returns the tier that a link should belong to given its observed
+// change rate.
+func (s *Scheduler) adaptTier(state *linkState) Tier {
+	if state.pinned || state.observations < s.cfg.MinObservations {
+		return state.tier
+	}
+
+	switch {
+	case state.changeRate > s.cfg.PromoteAbove:
+		return state.tier.promote()
+	case state.changeRate < s.cfg.DemoteBelow:
+		return state.tier.demote()
+	default:
+		return state.tier
+	}
+}
+
+// linkIterator is a graph.LinkIterator over a slice of links that are due for
+// recrawling.
+type linkIterator struct {
+	links    []*graph.Link
+	curIndex int
+}
+
+// Next implements graph.LinkIterator.
+func (i *linkIterator) Next() bool {
+	if i.curIndex >= len(i.links) {
+		return false
+	}
+	i.curIndex++
+	return true
+}
+
+// Error implements graph.LinkIterator.
+func (i *linkIterator) Error() error { return nil }
+
+// Close implements graph.LinkIterator.
+func (i *linkIterator) Close() error { return nil }
+
+// Link implements graph.LinkIterator.
+func (i *linkIterator) Link() *graph.Link { return i.links[i.curIndex-1] }
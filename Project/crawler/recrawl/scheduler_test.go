@@ -0,0 +1,180 @@
+package recrawl
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(SchedulerTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type SchedulerTestSuite struct {
+	now time.Time
+}
+
+func (s *SchedulerTestSuite) SetUpTest(c *gc.C) {
+	s.now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *SchedulerTestSuite) TestRuleClassification(c *gc.C) {
+	sched := s.scheduler(c, Config{
+		Rules: []Rule{
+			{Pattern: regexp.MustCompile(`^https?://news\.`), Tier: TierNews},
+			{Pattern: regexp.MustCompile(`/archive/`), Tier: TierArchive},
+		},
+	})
+
+	c.Assert(sched.Add(s.link("http://news.example.com/today", 0)), gc.Equals, TierNews)
+	c.Assert(sched.Add(s.link("http://example.com/archive/2001", 0)), gc.Equals, TierArchive)
+	c.Assert(sched.Add(s.link("http://example.com/about", 0)), gc.Equals, TierStandard)
+
+	for _, tier := range Tiers {
+		c.Assert(sched.Len(tier), gc.Equals, 1, gc.Commentf("tier %s", tier))
+	}
+}
+
+func (s *SchedulerTestSuite) TestDueLinksPerTier(c *gc.C) {
+	sched := s.scheduler(c, Config{
+		Rules: []Rule{{Pattern: regexp.MustCompile(`news`), Tier: TierNews}},
+	})
+
+	// Retrieved 2h ago: due for the news tier but not the standard tier.
+	retrievedAt := s.now.Add(-2 * time.Hour).Unix()
+	news := s.link("http://news.example.com", retrievedAt)
+	standard := s.link("http://example.com", retrievedAt)
+	sched.Add(news)
+	sched.Add(standard)
+
+	c.Assert(collectIDs(c, sched.Due(TierStandard, 0)), gc.HasLen, 0)
+	c.Assert(collectIDs(c, sched.Due(TierNews, 0)), gc.DeepEquals, []uuid.UUID{news.ID})
+
+	// Links returned by Due are rescheduled one interval later.
+	c.Assert(collectIDs(c, sched.Due(TierNews, 0)), gc.HasLen, 0)
+	s.now = s.now.Add(time.Hour)
+	c.Assert(collectIDs(c, sched.Due(TierNews, 0)), gc.DeepEquals, []uuid.UUID{news.ID})
+
+	// Standard links become due after a week.
+	s.now = s.now.Add(7 * 24 * time.Hour)
+	c.Assert(collectIDs(c, sched.Due(TierStandard, 0)), gc.DeepEquals, []uuid.UUID{standard.ID})
+}
+
+func (s *SchedulerTestSuite) TestDueLimit(c *gc.C) {
+	sched := s.scheduler(c, Config{})
+	for i := 0; i < 5; i++ {
+		sched.Add(s.link("http://example.com", 0))
+	}
+
+	c.Assert(collectIDs(c, sched.Due(TierStandard, 2)), gc.HasLen, 2)
+	c.Assert(collectIDs(c, sched.Due(TierStandard, 0)), gc.HasLen, 3)
+	c.Assert(sched.Len(TierStandard), gc.Equals, 5)
+}
+
+func (s *SchedulerTestSuite) TestAdaptivePromotionAndDemotion(c *gc.C) {
+	sched := s.scheduler(c, Config{MinObservations: 2, Smoothing: 1})
+	link := s.link("http://example.com", 0)
+	sched.Add(link)
+
+	// Content that changes on every fetch gets promoted.
+	for i, expTier := range []Tier{TierStandard, TierStandard, TierNews} {
+		tier, err := sched.RecordFetch(link.ID, uint64(i+1), s.now)
+		c.Assert(err, gc.IsNil)
+		c.Assert(tier, gc.Equals, expTier, gc.Commentf("fetch %d", i))
+	}
+
+	// Content that never changes gets demoted one tier at a time.
+	for i, expTier := range []Tier{TierNews, TierStandard, TierStandard, TierArchive} {
+		tier, err := sched.RecordFetch(link.ID, 42, s.now)
+		c.Assert(err, gc.IsNil)
+		c.Assert(tier, gc.Equals, expTier, gc.Commentf("fetch %d", i))
+	}
+	c.Assert(sched.Len(TierArchive), gc.Equals, 1)
+	c.Assert(sched.Len(TierNews)+sched.Len(TierStandard), gc.Equals, 0)
+}
+
+func (s *SchedulerTestSuite) TestPinnedRule(c *gc.C) {
+	sched := s.scheduler(c, Config{
+		Rules:           []Rule{{Pattern: regexp.MustCompile(`.*`), Tier: TierNews, Pinned: true}},
+		MinObservations: 1,
+	})
+	link := s.link("http://example.com", 0)
+	sched.Add(link)
+
+	for i := 0; i < 5; i++ {
+		tier, err := sched.RecordFetch(link.ID, 42, s.now)
+		c.Assert(err, gc.IsNil)
+		c.Assert(tier, gc.Equals, TierNews)
+	}
+}
+
+func (s *SchedulerTestSuite) TestNextFetch(c *gc.C) {
+	sched := s.scheduler(c, Config{
+		Rules:           []Rule{{Pattern: regexp.MustCompile(`news`), Tier: TierNews}},
+		MinObservations: 2,
+		Smoothing:       1,
+	})
+	news := s.link("http://news.example.com", s.now.Unix())
+	c.Assert(sched.NextFetch(news, 1, s.now), gc.Equals, s.now.Add(time.Hour))
+	c.Assert(sched.Len(TierNews), gc.Equals, 1)
+
+	// Standard links are promoted once their content keeps changing.
+	standard := s.link("http://example.com", s.now.Unix())
+	for i, expDue := range []time.Duration{7 * 24 * time.Hour, 7 * 24 * time.Hour, time.Hour} {
+		c.Assert(sched.NextFetch(standard, uint64(i), s.now), gc.Equals, s.now.Add(expDue), gc.Commentf("fetch %d", i))
+	}
+	tier, err := sched.TierOf(standard.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(tier, gc.Equals, TierNews)
+}
+
+func (s *SchedulerTestSuite) TestMaxTrackedLinks(c *gc.C) {
+	sched := s.scheduler(c, Config{MaxTrackedLinks: 2})
+	for i := 0; i < 5; i++ {
+		sched.NextFetch(s.link("http://example.com", 0), 1, s.now)
+	}
+	c.Assert(sched.Len(TierStandard), gc.Equals, 2)
+}
+
+func (s *SchedulerTestSuite) TestUnknownLink(c *gc.C) {
+	sched := s.scheduler(c, Config{})
+	_, err := sched.RecordFetch(uuid.New(), 1, s.now)
+	c.Assert(errors.Is(err, ErrUnknownLink), gc.Equals, true)
+	_, err = sched.TierOf(uuid.New())
+	c.Assert(errors.Is(err, ErrUnknownLink), gc.Equals, true)
+}
+
+func (s *SchedulerTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewScheduler(Config{
+		Rules:        []Rule{{Tier: TierNews}},
+		PromoteAbove: 0.1,
+		DemoteBelow:  0.5,
+	})
+	c.Assert(err, gc.ErrorMatches, "(?s).*missing URL pattern.*demotion threshold.*")
+}
+
+func (s *SchedulerTestSuite) scheduler(c *gc.C, cfg Config) *Scheduler {
+	cfg.Clock = func() time.Time { return s.now }
+	sched, err := NewScheduler(cfg)
+	c.Assert(err, gc.IsNil)
+	return sched
+}
+
+func (s *SchedulerTestSuite) link(url string, retrievedAt int64) *graph.Link {
+	return &graph.Link{ID: uuid.New(), URL: url, RetrievedAt: retrievedAt}
+}
+
+func collectIDs(c *gc.C, it graph.LinkIterator) []uuid.UUID {
+	var ids []uuid.UUID
+	for it.Next() {
+		ids = append(ids, it.Link().ID)
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	return ids
+}
@@ -0,0 +1,80 @@
+package recrawl
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Tier describes how often the links assigned to it should be recrawled.
+type Tier uint8
+
+const (
+	// TierStandard is the default tier for links that change occasionally.
+	TierStandard Tier = iota
+
+	// TierNews is used for frequently changing links such as news sites.
+	TierNews
+
+	// TierArchive is used for links whose content rarely changes.
+	TierArchive
+)
+
+// Tiers lists all supported tiers from the most to the least frequently
+// crawled one.
+var Tiers = []Tier{TierNews, TierStandard, TierArchive}
+
+// String implements fmt.Stringer.
+func (t Tier) String() string {
+	switch t {
+	case TierNews:
+		return "news"
+	case TierStandard:
+		return "standard"
+	case TierArchive:
+		return "archive"
+	default:
+		return fmt.Sprintf("tier(%d)", uint8(t))
+	}
+}
+
+// promote returns the next more frequently crawled tier.
+func (t Tier) promote() Tier {
+	switch t {
+	case TierArchive:
+		return TierStandard
+	default:
+		return TierNews
+	}
+}
+
+// demote returns the next less frequently crawled tier.
+func (t Tier) demote() Tier {
+	switch t {
+	case TierNews:
+		return TierStandard
+	default:
+		return TierArchive
+	}
+}
+
+// DefaultIntervals contains the recrawl interval for each tier that is used
+// when Config.Intervals does not specify one.
+var DefaultIntervals = map[Tier]time.Duration{
+	TierNews:     time.Hour,
+	TierStandard: 7 * 24 * time.Hour,
+	TierArchive:  30 * 24 * time.Hour,
+}
+
+// Rule assigns links whose URL matches Pattern to a tier.
+type Rule struct {
+	// The pattern to match link URLs against.
+	Pattern *regexp.Regexp
+
+	// The tier to assign matching links to.
+	Tier Tier
+
+	// If set, matching links always stay in Tier regardless of their
+	// observed change rate.
+	Pinned bool
+}
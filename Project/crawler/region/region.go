@@ -0,0 +1,192 @@
+// Package region assigns the hosts of crawled links to the region whose
+// crawler instances fetch them. This allows, for example, EU sites to be
+// crawled from an EU egress while US sites are crawled by US instances.
+//
+// Each host belongs to a home region: the region of the first domain tag
+// that matches the host or the default region if no tag matches. A host is
+// crawled by the instances of its home region as long as that region has
+// crawl capacity. Otherwise, it spills over to:
+//
+//   - the first region with capacity in the spillover list of its home
+//     region.
+//   - the default region if it has capacity.
+//   - the first region with capacity in lexicographic order.
+//
+// Every instance evaluates the same rules against the same settings, so all
+// instances agree on the assignment of each host without coordinating.
+package region
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// DomainTag assigns the pages of a domain and its subdomains to a region.
+type DomainTag struct {
+	Domain string
+	Region string
+}
+
+func (t DomainTag) matches(host string) bool {
+	return host == t.Domain || strings.HasSuffix(host, "."+t.Domain)
+}
+
+// Config encapsulates the settings for a Router.
+type Config struct {
+	// The region of this crawler instance.
+	Self string
+
+	// The home region of hosts that do not match any domain tag.
+	Default string
+
+	// The domain tags. The first tag whose domain matches a host
+	// determines its home region.
+	Domains []DomainTag
+
+	// The regions that have crawl capacity. If empty, all regions that
+	// are referenced by the other settings are considered to have
+	// capacity.
+	Active []string
+
+	// The regions that the hosts of a region spill over to, in order of
+	// preference, when the region has no crawl capacity.
+	Spillover map[string][]string
+}
+
+func (cfg *Config) validate() error {
+	// Normalize copies of the slices provided by the caller.
+	cfg.Domains = append([]DomainTag(nil), cfg.Domains...)
+	cfg.Active = append([]string(nil), cfg.Active...)
+
+	var err error
+	if cfg.Self = normalize(cfg.Self); !validName(cfg.Self) {
+		err = multierror.Append(err, fmt.Errorf("invalid region %q for this instance", cfg.Self))
+	}
+	if cfg.Default = normalize(cfg.Default); !validName(cfg.Default) {
+		err = multierror.Append(err, fmt.Errorf("invalid default region %q", cfg.Default))
+	}
+	for i, tag := range cfg.Domains {
+		tag.Domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(tag.Domain)), ".")
+		if tag.Domain == "" {
+			err = multierror.Append(err, fmt.Errorf("domain tag %d: domain has not been specified", i))
+		}
+		if tag.Region = normalize(tag.Region); !validName(tag.Region) {
+			err = multierror.Append(err, fmt.Errorf("domain tag %d: invalid region %q", i, tag.Region))
+		}
+		cfg.Domains[i] = tag
+	}
+	for i, name := range cfg.Active {
+		if cfg.Active[i] = normalize(name); !validName(cfg.Active[i]) {
+			err = multierror.Append(err, fmt.Errorf("invalid active region %q", name))
+		}
+	}
+	spillover := make(map[string][]string, len(cfg.Spillover))
+	for from, targets := range cfg.Spillover {
+		from = normalize(from)
+		if !validName(from) {
+			err = multierror.Append(err, fmt.Errorf("invalid spillover region %q", from))
+		}
+		for _, to := range targets {
+			if to = normalize(to); !validName(to) || to == from {
+				err = multierror.Append(err, fmt.Errorf("invalid spillover target %q for region %q", to, from))
+				continue
+			}
+			spillover[from] = append(spillover[from], to)
+		}
+	}
+	cfg.Spillover = spillover
+	return err
+}
+
+// Router decides which of the crawled links are fetched by this instance
+// based on the region of their host. It is safe for concurrent use.
+type Router struct {
+	cfg Config
+
+	// The regions with crawl capacity in lexicographic order.
+	active    []string
+	activeSet map[string]bool
+}
+
+// New returns a new Router using the provided config.
+func New(cfg Config) (*Router, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("region router: config validation failed: %w", err)
+	}
+
+	active := cfg.Active
+	if len(active) == 0 {
+		active = []string{cfg.Self, cfg.Default}
+		for _, tag := range cfg.Domains {
+			active = append(active, tag.Region)
+		}
+		for from, targets := range cfg.Spillover {
+			active = append(append(active, from), targets...)
+		}
+	}
+
+	r := &Router{cfg: cfg, activeSet: make(map[string]bool)}
+	for _, name := range active {
+		if !r.activeSet[name] {
+			r.activeSet[name] = true
+			r.active = append(r.active, name)
+		}
+	}
+	sort.Strings(r.active)
+	return r, nil
+}
+
+// Self returns the region of this instance.
+func (r *Router) Self() string { return r.cfg.Self }
+
+// HomeRegion returns the region that host is tagged with.
+func (r *Router) HomeRegion(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, tag := range r.cfg.Domains {
+		if tag.matches(host) {
+			return tag.Region
+		}
+	}
+	return r.cfg.Default
+}
+
+// Assign returns the region whose instances crawl host.
+func (r *Router) Assign(host string) string {
+	home := r.HomeRegion(host)
+	if r.activeSet[home] {
+		return home
+	}
+	for _, target := range r.cfg.Spillover[home] {
+		if r.activeSet[target] {
+			return target
+		}
+	}
+	if r.activeSet[r.cfg.Default] {
+		return r.cfg.Default
+	}
+	return r.active[0]
+}
+
+// Accepts returns true if the link at rawURL is crawled by this instance.
+// Links whose URL cannot be parsed are treated like links to untagged hosts.
+func (r *Router) Accepts(rawURL string) bool {
+	var host string
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Hostname()
+	}
+	return r.Assign(host) == r.cfg.Self
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// validName returns true if name can be used as a region name in the
+// configuration file and in comma-separated lists.
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " ,=|")
+}
@@ -0,0 +1,96 @@
+package region
+
+import (
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(RouterTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type RouterTestSuite struct{}
+
+func (s *RouterTestSuite) config(self string, active ...string) Config {
+	return Config{
+		Self:    self,
+		Default: "us",
+		Domains: []DomainTag{
+			{Domain: "example.de", Region: "EU"},
+			{Domain: "example.fr.", Region: "eu"},
+			{Domain: "example.jp", Region: "ap"},
+		},
+		Active: active,
+		Spillover: map[string][]string{
+			"eu": {"uk", "us"},
+		},
+	}
+}
+
+func (s *RouterTestSuite) TestHomeRegion(c *gc.C) {
+	r, err := New(s.config("eu"))
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(r.Self(), gc.Equals, "eu")
+	c.Assert(r.HomeRegion("example.de"), gc.Equals, "eu")
+	c.Assert(r.HomeRegion("WWW.Example.FR"), gc.Equals, "eu")
+	c.Assert(r.HomeRegion("example.jp"), gc.Equals, "ap")
+	c.Assert(r.HomeRegion("notexample.de"), gc.Equals, "us")
+	c.Assert(r.HomeRegion("example.com"), gc.Equals, "us")
+}
+
+func (s *RouterTestSuite) TestAssignWithCapacity(c *gc.C) {
+	// Without an explicit list of active regions all regions have capacity.
+	eu, err := New(s.config("eu"))
+	c.Assert(err, gc.IsNil)
+	us, err := New(s.config("us"))
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(eu.Accepts("https://blog.example.de/post"), gc.Equals, true)
+	c.Assert(us.Accepts("https://blog.example.de/post"), gc.Equals, false)
+	c.Assert(eu.Accepts("https://example.com/"), gc.Equals, false)
+	c.Assert(us.Accepts("https://example.com/"), gc.Equals, true)
+	c.Assert(us.Accepts("https://example.jp/"), gc.Equals, false)
+
+	// Unparseable links are crawled by the default region.
+	c.Assert(eu.Accepts("://"), gc.Equals, false)
+	c.Assert(us.Accepts("://"), gc.Equals, true)
+}
+
+func (s *RouterTestSuite) TestSpillover(c *gc.C) {
+	specs := []struct {
+		active []string
+		host   string
+		exp    string
+	}{
+		// The first active region in the spillover list is used.
+		{active: []string{"uk", "us", "ap"}, host: "example.de", exp: "uk"},
+		{active: []string{"us", "ap"}, host: "example.de", exp: "us"},
+		// Regions without spillover rules fall back to the default region.
+		{active: []string{"us", "eu"}, host: "example.jp", exp: "us"},
+		// ... or the first active region if the default has no capacity.
+		{active: []string{"eu", "ap"}, host: "example.com", exp: "ap"},
+		{active: []string{"sa"}, host: "example.de", exp: "sa"},
+	}
+	for specIndex, spec := range specs {
+		r, err := New(s.config("us", spec.active...))
+		c.Assert(err, gc.IsNil)
+		c.Assert(r.Assign(spec.host), gc.Equals, spec.exp, gc.Commentf("spec %d", specIndex))
+	}
+}
+
+func (s *RouterTestSuite) TestInvalidConfig(c *gc.C) {
+	_, err := New(Config{
+		Domains:   []DomainTag{{Region: "eu"}, {Domain: "example.com"}},
+		Active:    []string{"eu,us"},
+		Spillover: map[string][]string{"eu": {"eu"}},
+	})
+	c.Assert(err, gc.ErrorMatches, `(?s)region router: config validation failed:.*`+
+		`invalid region "" for this instance.*`+
+		`invalid default region "".*`+
+		`domain tag 0: domain has not been specified.*`+
+		`domain tag 1: invalid region "".*`+
+		`invalid active region "eu,us".*`+
+		`invalid spillover target "eu" for region "eu".*`)
+}
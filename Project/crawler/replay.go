@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/warc"
+	"webcrawler/urlutil/normalizer"
+)
+
+// ArchiveReader is implemented by objects that read archived responses, such
+// as warc.Reader. Next returns io.EOF once all responses have been read.
+type ArchiveReader interface {
+	Next() (*warc.Record, error)
+}
+
+// Replay sends the responses read from archive through a crawler pipeline
+// assembled from cfg without issuing any network requests: the fetcher
+// serves the archived responses and robots.txt policies, pacing, adaptive
+// timeouts, media capturing, URL rewriting and archiving are disabled. As
+// hosts are not resolved, links to private networks are not detected.
+//
+// The URL of each response is normalized and upserted into the link graph
+// before it is replayed so that the link graph and text index are populated
+// as if the links had been crawled. Replay returns the number of responses
+// that went through the pipeline.
+func Replay(ctx context.Context, cfg Config, archive ArchiveReader) (int, error) {
+	responses := &archivedResponses{byURL: make(map[string]*warc.Record)}
+	cfg.Fetcher = responses
+	cfg.URLGetter = nil
+	cfg.PrivateNetworkDetector = offlineNetworkDetector{}
+	cfg.Robots = nil
+	cfg.Pacer = nil
+	cfg.AdaptiveTimeouts = nil
+	cfg.BlobStore = nil
+	cfg.URLRewriteRules = nil
+	cfg.Archiver = nil
+
+	linkIt := &archiveLinkIterator{archive: archive, graph: cfg.Graph, normalizer: cfg.URLNormalizer, responses: responses}
+	return NewCrawler(cfg).Crawl(ctx, linkIt)
+}
+
+// archivedResponses is a Fetcher that serves archived responses. Each response
+// is served once.
+type archivedResponses struct {
+	mu    sync.Mutex
+	byURL map[string]*warc.Record
+}
+
+func (ar *archivedResponses) add(rawURL string, rec *warc.Record) {
+	ar.mu.Lock()
+	ar.byURL[rawURL] = rec
+	ar.mu.Unlock()
+}
+
+// Fetch implements Fetcher.
+func (ar *archivedResponses) Fetch(req *http.Request) (*http.Response, error) {
+	rawURL := req.URL.String()
+	ar.mu.Lock()
+	rec, ok := ar.byURL[rawURL]
+	delete(ar.byURL, rawURL)
+	ar.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no archived response for %s", rawURL)
+	}
+
+	proto := rec.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+		StatusCode:    rec.StatusCode,
+		Proto:         proto,
+		Header:        rec.Header,
+		Body:          io.NopCloser(bytes.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}
+	if res.Header == nil {
+		res.Header = make(http.Header)
+	}
+	res.ProtoMajor, res.ProtoMinor, _ = http.ParseHTTPVersion(proto)
+	return res, nil
+}
+
+// archiveLinkIterator is a graph.LinkIterator that upserts the link for each
+// archived response into the link graph and yields it.
+type archiveLinkIterator struct {
+	archive    ArchiveReader
+	graph      Graph
+	normalizer *normalizer.Normalizer
+	responses  *archivedResponses
+
+	link *graph.Link
+	err  error
+}
+
+func (it *archiveLinkIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for {
+		rec, err := it.archive.Next()
+		if err == io.EOF {
+			return false
+		} else if err != nil {
+			it.err = err
+			return false
+		}
+
+		linkURL, err := it.normalizer.Normalize(rec.URL)
+		if err != nil {
+			// Responses for URLs that cannot be crawled are skipped.
+			continue
+		}
+		link := &graph.Link{URL: linkURL}
+		if err = it.graph.UpsertLink(link); err != nil {
+			it.err = fmt.Errorf("replay: %w", err)
+			return false
+		}
+		it.responses.add(linkURL, rec)
+		it.link = link
+		return true
+	}
+}
+
+func (it *archiveLinkIterator) Link() *graph.Link { return it.link }
+func (it *archiveLinkIterator) Error() error      { return it.err }
+func (it *archiveLinkIterator) Close() error      { return nil }
+
+// offlineNetworkDetector is a PrivateNetworkDetector that never resolves
+// hosts and reports all of them as public.
+type offlineNetworkDetector struct{}
+
+func (offlineNetworkDetector) IsPrivate(string) (bool, error) { return false, nil }
@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/crawler/warc"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ReplayTestSuite))
+
+type ReplayTestSuite struct{}
+
+func (s *ReplayTestSuite) TestReplayWARC(c *gc.C) {
+	var archive bytes.Buffer
+	w := warc.NewWriter(&archive, false)
+	c.Assert(w.WriteInfo(""), gc.IsNil)
+	for _, rec := range []*warc.Record{
+		{
+			URL:        "http://example.com/",
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       []byte(`<html><head><title>Home</title></head><body>Welcome <a href="/about">about</a></body></html>`),
+		},
+		{
+			URL:        "http://example.com/missing",
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       []byte(`<html><body>Not found</body></html>`),
+		},
+	} {
+		c.Assert(w.WriteRecord(rec), gc.IsNil)
+	}
+
+	// Archives created by other tools keep the transfer and content
+	// encodings of the responses.
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	_, _ = zw.Write([]byte(`<html><head><title>About</title></head><body>About us</body></html>`))
+	c.Assert(zw.Close(), gc.IsNil)
+	block := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Encoding: gzip\r\nTransfer-Encoding: chunked\r\n\r\n%x\r\n%s\r\n0\r\n\r\n", body.Len(), body.String())
+	fmt.Fprintf(&archive, "WARC/1.0\r\nWARC-Type: response\r\nWARC-Target-URI: <http://EXAMPLE.com/about>\r\nWARC-Date: 2024-03-05T10:00:00Z\r\nContent-Type: application/http;msgtype=response\r\nContent-Length: %d\r\n\r\n%s\r\n\r\n", len(block), block)
+
+	reader, err := warc.NewReader(&archive)
+	c.Assert(err, gc.IsNil)
+	g, idx := newReplayGraph(), new(replayIndex)
+	count, err := Replay(context.TODO(), Config{Graph: g, Indexer: idx, FetchWorkers: 2}, reader)
+	c.Assert(err, gc.IsNil)
+	c.Assert(count, gc.Equals, 2)
+
+	c.Assert(g.links, gc.HasLen, 3)
+	home, about := g.links["http://example.com/"], g.links["http://example.com/about"]
+	c.Assert(home.RetrievedAt, gc.Not(gc.Equals), int64(0))
+	c.Assert(about.RetrievedAt, gc.Not(gc.Equals), int64(0))
+	c.Assert(g.edges, gc.DeepEquals, map[uuid.UUID][]uuid.UUID{home.ID: {about.ID}})
+
+	titles := make(map[string]string)
+	for _, doc := range idx.docs {
+		titles[doc.URL] = doc.Title
+	}
+	c.Assert(titles, gc.DeepEquals, map[string]string{
+		"http://example.com/":      "Home",
+		"http://example.com/about": "About",
+	})
+}
+
+// replayGraph is an in-memory Graph that assigns link IDs by URL.
+type replayGraph struct {
+	mu    sync.Mutex
+	links map[string]*graph.Link
+	edges map[uuid.UUID][]uuid.UUID
+}
+
+func newReplayGraph() *replayGraph {
+	return &replayGraph{links: make(map[string]*graph.Link), edges: make(map[uuid.UUID][]uuid.UUID)}
+}
+
+func (g *replayGraph) UpsertLink(link *graph.Link) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if existing := g.links[link.URL]; existing != nil {
+		link.ID = existing.ID
+		if link.RetrievedAt > existing.RetrievedAt {
+			existing.RetrievedAt = link.RetrievedAt
+		}
+		return nil
+	}
+	link.ID = uuid.New()
+	g.links[link.URL] = &graph.Link{ID: link.ID, URL: link.URL, RetrievedAt: link.RetrievedAt}
+	return nil
+}
+
+func (g *replayGraph) UpsertEdge(edge *graph.Edge) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.edges[edge.Src] = append(g.edges[edge.Src], edge.Dst)
+	return nil
+}
+
+func (g *replayGraph) RemoveStaleEdges(uuid.UUID, time.Time) error { return nil }
+
+type replayIndex struct {
+	mu   sync.Mutex
+	docs []*index.Document
+}
+
+func (idx *replayIndex) Index(doc *index.Document) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.docs = append(idx.docs, doc)
+	return nil
+}
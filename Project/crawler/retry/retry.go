@@ -0,0 +1,166 @@
+// Package retry schedules the links that could not be fetched for another
+// attempt.
+//
+// Each failed fetch is recorded in the link graph together with its reason
+// and the number of consecutive failures. The link is retried after an
+// exponentially growing delay; once it has exhausted its attempts it is
+// quarantined and no longer crawled until it is rediscovered with a more
+// recent retrieval time or its failure record is replaced.
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Graph is implemented by link graphs that keep track of failed links.
+type Graph interface {
+	// LinkFailure returns the failure record of the link with the
+	// specified ID or graph.ErrNotFound if the link has no failure
+	// record.
+	LinkFailure(id uuid.UUID) (*graph.LinkFailure, error)
+
+	// RecordLinkFailure creates or replaces the failure record of a link
+	// and reschedules the link accordingly.
+	RecordLinkFailure(f *graph.LinkFailure) error
+}
+
+// Config encapsulates the settings for a Tracker.
+type Config struct {
+	// The link graph that stores the failure records.
+	Graph Graph
+
+	// The delay before the first retry of a link. Each subsequent failure
+	// doubles the delay. Defaults to 1 minute.
+	BaseDelay time.Duration
+
+	// The upper bound for the retry delay. Defaults to 24 hours.
+	MaxDelay time.Duration
+
+	// The number of consecutive failed attempts after which a link is
+	// quarantined. Defaults to 5.
+	MaxAttempts int
+
+	// A function that returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.Graph == nil {
+		err = multierror.Append(err, fmt.Errorf("link graph has not been provided"))
+	}
+	if cfg.BaseDelay == 0 {
+		cfg.BaseDelay = time.Minute
+	} else if cfg.BaseDelay < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid base delay"))
+	}
+	if cfg.MaxDelay == 0 {
+		cfg.MaxDelay = 24 * time.Hour
+	}
+	if cfg.MaxDelay < cfg.BaseDelay {
+		err = multierror.Append(err, fmt.Errorf("max delay must not be lower than the base delay"))
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 5
+	} else if cfg.MaxAttempts < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid max attempts"))
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+// Tracker implements crawler.FailureTracker by recording the failed fetches
+// of each link in the link graph and scheduling the link for a retry with
+// exponential backoff.
+type Tracker struct {
+	cfg    Config
+	logger *slog.Logger
+}
+
+// NewTracker returns a new Tracker using the provided config.
+func NewTracker(cfg Config) (*Tracker, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("retry tracker: config validation failed: %w", err)
+	}
+	return &Tracker{cfg: cfg, logger: logging.Component(cfg.Logger, "retry")}, nil
+}
+
+// LinkFailed implements crawler.FailureTracker. Errors are logged as the
+// failure of a single link must not stop the crawl.
+func (t *Tracker) LinkFailed(linkID uuid.UUID, url string, class errstore.Class, err error) {
+	f, recErr := t.record(linkID, failureReason(class, err))
+	if recErr != nil {
+		t.logger.Error("unable to record link failure", logging.Link(linkID, url), "err", recErr)
+		return
+	}
+
+	if f.Quarantined {
+		metrics.LinkFailures.WithLabelValues("quarantined").Inc()
+		t.logger.Warn("quarantined link", logging.Link(linkID, url), "attempts", f.Attempts, "reason", f.Reason)
+		return
+	}
+	metrics.LinkFailures.WithLabelValues("retry_scheduled").Inc()
+	t.logger.Debug("scheduled link retry", logging.Link(linkID, url), "attempts", f.Attempts, "next_retry_at", time.Unix(f.NextRetryAt, 0))
+}
+
+// record increments the attempt count of the failure record of the link and
+// reschedules it.
+func (t *Tracker) record(linkID uuid.UUID, reason string) (*graph.LinkFailure, error) {
+	attempts := 1
+	prev, err := t.cfg.Graph.LinkFailure(linkID)
+	if err == nil {
+		attempts = prev.Attempts + 1
+	} else if !errors.Is(err, graph.ErrNotFound) {
+		return nil, err
+	}
+
+	now := t.cfg.Clock()
+	f := &graph.LinkFailure{
+		LinkID:      linkID,
+		Reason:      reason,
+		Attempts:    attempts,
+		FailedAt:    now.Unix(),
+		NextRetryAt: now.Add(t.Delay(attempts)).Unix(),
+		Quarantined: attempts >= t.cfg.MaxAttempts,
+	}
+	if err = t.cfg.Graph.RecordLinkFailure(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Delay returns the delay before a link that has failed the specified number
+// of consecutive attempts is retried.
+func (t *Tracker) Delay(attempts int) time.Duration {
+	delay := t.cfg.BaseDelay
+	for i := 1; i < attempts && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+	return delay
+}
+
+// failureReason describes a failure of the specified class.
+func failureReason(class errstore.Class, err error) string {
+	if err == nil {
+		return string(class)
+	}
+	return fmt.Sprintf("%s: %v", class, err)
+}
@@ -0,0 +1,78 @@
+package retry
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/linkgraph/store/memory"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(RetryTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type RetryTestSuite struct{}
+
+func (s *RetryTestSuite) TestBackoffAndQuarantine(c *gc.C) {
+	g := memory.NewInMemoryGraph()
+	link := &graph.Link{URL: "http://example.com/"}
+	c.Assert(g.UpsertLink(link), gc.IsNil)
+
+	now := time.Unix(1000, 0)
+	tracker, err := NewTracker(Config{
+		Graph:       g,
+		BaseDelay:   time.Minute,
+		MaxDelay:    3 * time.Minute,
+		MaxAttempts: 4,
+		Clock:       func() time.Time { return now },
+	})
+	c.Assert(err, gc.IsNil)
+
+	for attempt, expDelay := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		tracker.LinkFailed(link.ID, link.URL, errstore.ClassHTTPServer, errors.New("unexpected status code 503"))
+
+		f, err := g.LinkFailure(link.ID)
+		c.Assert(err, gc.IsNil)
+		c.Assert(f.Attempts, gc.Equals, attempt+1)
+		c.Assert(f.Reason, gc.Equals, "http_5xx: unexpected status code 503")
+		c.Assert(f.NextRetryAt, gc.Equals, now.Add(expDelay).Unix())
+		c.Assert(f.Quarantined, gc.Equals, false)
+
+		stored, err := g.FindLink(link.ID)
+		c.Assert(err, gc.IsNil)
+		c.Assert(stored.NextFetchAt, gc.Equals, f.NextRetryAt)
+	}
+
+	tracker.LinkFailed(link.ID, link.URL, errstore.ClassTimeout, nil)
+	f, err := g.LinkFailure(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(f.Attempts, gc.Equals, 4)
+	c.Assert(f.Reason, gc.Equals, "timeout")
+	c.Assert(f.Quarantined, gc.Equals, true)
+	stored, err := g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(stored.NextFetchAt, gc.Equals, int64(math.MaxInt64))
+}
+
+func (s *RetryTestSuite) TestUnknownLink(c *gc.C) {
+	g := memory.NewInMemoryGraph()
+	tracker, err := NewTracker(Config{Graph: g})
+	c.Assert(err, gc.IsNil)
+
+	// Failures of links that are not part of the graph are only logged.
+	tracker.LinkFailed(uuid.New(), "http://example.com/", errstore.ClassDNS, errors.New("no such host"))
+	it, err := g.FailedLinks(uuid.Nil, uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, false)
+}
+
+func (s *RetryTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewTracker(Config{BaseDelay: time.Hour, MaxDelay: time.Minute, MaxAttempts: -1})
+	c.Assert(err, gc.ErrorMatches, `(?s)retry tracker: config validation failed: .*link graph has not been provided.*max delay must not be lower than the base delay.*invalid max attempts.*`)
+}
@@ -0,0 +1,235 @@
+package robots
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// URLGetter is implemented by objects that can perform HTTP GET requests.
+type URLGetter interface {
+	Get(url string) (*http.Response, error)
+}
+
+// Config encapsulates the settings for a Cache.
+type Config struct {
+	// The getter for fetching robots.txt files.
+	URLGetter URLGetter
+
+	// The store that is shared by all workers. Defaults to a new
+	// MemoryStore which only shares entries within the process.
+	Store Store
+
+	// The user agent whose rules are applied. Defaults to "webcrawler".
+	UserAgent string
+
+	// How long to cache robots.txt files that were fetched successfully.
+	// Defaults to 24h.
+	TTL time.Duration
+
+	// How long to cache the outcome for hosts without a robots.txt file
+	// or whose robots.txt file could not be fetched. Defaults to 1h.
+	NegativeTTL time.Duration
+
+	// The maximum number of robots.txt bytes to parse. Any content past
+	// this limit is ignored. Defaults to 500KiB.
+	MaxBodySize int64
+
+	// An optional logger for reporting fetch and store failures. If not
+	// specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.URLGetter == nil {
+		err = multierror.Append(err, fmt.Errorf("URL getter has not been provided"))
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "webcrawler"
+	}
+	if cfg.TTL < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid TTL"))
+	} else if cfg.TTL == 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.NegativeTTL < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid negative TTL"))
+	} else if cfg.NegativeTTL == 0 {
+		cfg.NegativeTTL = time.Hour
+	}
+	if cfg.MaxBodySize < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid max body size"))
+	} else if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = 500 << 10
+	}
+	return err
+}
+
+// Cache evaluates the robots.txt policies of the hosts visited by the crawler.
+// Policies are looked up in a local cache first and then in the shared store;
+// robots.txt files are only fetched when neither holds an unexpired entry, so
+// a fleet of workers that share a store fetches each file once per TTL.
+// Concurrent lookups for the same host share a single fetch. It is safe for
+// concurrent use.
+type Cache struct {
+	cfg    Config
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	local    map[string]*cachedPolicy
+	inflight map[string]*lookup
+}
+
+type cachedPolicy struct {
+	policy    *Policy
+	expiresAt time.Time
+}
+
+type lookup struct {
+	done   chan struct{}
+	result *cachedPolicy
+}
+
+// NewCache returns a new Cache instance using the provided config.
+func NewCache(cfg Config) (*Cache, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("robots cache: config validation failed: %w", err)
+	}
+
+	return &Cache{
+		cfg:      cfg,
+		logger:   logging.Component(cfg.Logger, "crawler.robots"),
+		local:    make(map[string]*cachedPolicy),
+		inflight: make(map[string]*lookup),
+	}, nil
+}
+
+// Allowed returns true if the robots.txt policy of the host of rawURL allows
+// the crawler to fetch it.
+func (c *Cache) Allowed(rawURL string) (bool, error) {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return false, err
+	}
+
+	policy := c.policyFor(u.Scheme + "://" + u.Host)
+	return policy.Allowed(c.cfg.UserAgent, u.RequestURI()), nil
+}
+
+// CrawlDelay returns the Crawl-delay that the robots.txt policy of the host
+// of rawURL specifies for the crawler or zero if it does not specify one.
+func (c *Cache) CrawlDelay(rawURL string) (time.Duration, error) {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return 0, err
+	}
+
+	policy := c.policyFor(u.Scheme + "://" + u.Host)
+	return policy.CrawlDelay(c.cfg.UserAgent), nil
+}
+
+// parseURL parses rawURL and checks that it is an absolute http(s) URL.
+func parseURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("robots: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("robots: unsupported URL %q", rawURL)
+	}
+	return u, nil
+}
+
+// policyFor returns the policy for host, which is specified as a scheme and
+// authority.
+func (c *Cache) policyFor(host string) *Policy {
+	now := time.Now()
+
+	c.mu.Lock()
+	if cached := c.local[host]; cached != nil && now.Before(cached.expiresAt) {
+		c.mu.Unlock()
+		metrics.RobotsLookups.WithLabelValues("local").Inc()
+		return cached.policy
+	}
+	if l := c.inflight[host]; l != nil {
+		c.mu.Unlock()
+		<-l.done
+		metrics.RobotsLookups.WithLabelValues("local").Inc()
+		return l.result.policy
+	}
+	l := &lookup{done: make(chan struct{})}
+	c.inflight[host] = l
+	c.mu.Unlock()
+
+	l.result = c.load(host, now)
+
+	c.mu.Lock()
+	c.local[host] = l.result
+	delete(c.inflight, host)
+	c.mu.Unlock()
+	close(l.done)
+	return l.result.policy
+}
+
+// load returns the policy for host from the shared store or fetches its
+// robots.txt file if the store does not contain an unexpired entry.
+func (c *Cache) load(host string, now time.Time) *cachedPolicy {
+	entry, err := c.cfg.Store.Get(host)
+	switch {
+	case err == nil && now.Before(entry.ExpiresAt):
+		metrics.RobotsLookups.WithLabelValues("shared").Inc()
+		return &cachedPolicy{policy: entry.Policy(), expiresAt: entry.ExpiresAt}
+	case err != nil && !errors.Is(err, ErrNotFound):
+		// Fall back to fetching the file; the policy is still cached
+		// locally.
+		c.logger.Warn("unable to read robots.txt entry from store", "host", host, "err", err)
+	}
+
+	metrics.RobotsLookups.WithLabelValues("fetched").Inc()
+	entry = c.fetch(host, now)
+	if err = c.cfg.Store.Put(entry); err != nil {
+		c.logger.Warn("unable to write robots.txt entry to store", "host", host, "err", err)
+	}
+	return &cachedPolicy{policy: entry.Policy(), expiresAt: entry.ExpiresAt}
+}
+
+// fetch retrieves the robots.txt file for host and returns a new entry for it.
+func (c *Cache) fetch(host string, now time.Time) *Entry {
+	entry := &Entry{Host: host, FetchedAt: now, ExpiresAt: now.Add(c.cfg.NegativeTTL)}
+
+	res, err := c.cfg.URLGetter.Get(host + "/robots.txt")
+	if err != nil {
+		c.logger.Warn("unable to fetch robots.txt; disallowing host until the entry expires", "host", host, "err", err)
+		return entry
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	entry.StatusCode = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		if res.StatusCode >= 500 {
+			c.logger.Warn("robots.txt is unavailable; disallowing host until the entry expires", "host", host, "status", res.StatusCode)
+		}
+		return entry
+	}
+
+	if entry.Body, err = io.ReadAll(io.LimitReader(res.Body, c.cfg.MaxBodySize)); err != nil {
+		c.logger.Warn("unable to read robots.txt; disallowing host until the entry expires", "host", host, "err", err)
+		entry.StatusCode, entry.Body = 0, nil
+		return entry
+	}
+	entry.ExpiresAt = now.Add(c.cfg.TTL)
+	return entry
+}
@@ -0,0 +1,200 @@
+// Package robots implements a robots.txt parser and a cache of per-host
+// crawl policies that can be shared by a fleet of crawler workers.
+//
+// Policies are evaluated following RFC 9309: the rules of the most specific
+// group that matches the crawler's user agent apply, the longest matching
+// rule wins and "*" and "$" wildcards are supported in rule paths.
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes the paths that a crawler is allowed to fetch from a host.
+// The zero value allows all paths.
+type Policy struct {
+	groups      []*group
+	disallowAll bool
+}
+
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll returns a policy that allows all paths.
+func AllowAll() *Policy {
+	return &Policy{}
+}
+
+// DisallowAll returns a policy that disallows all paths.
+func DisallowAll() *Policy {
+	return &Policy{disallowAll: true}
+}
+
+// Parse parses the contents of a robots.txt file. Lines that cannot be
+// parsed are ignored.
+func Parse(body []byte) *Policy {
+	var (
+		p   = new(Policy)
+		cur *group
+
+		// Consecutive user-agent lines belong to the same group.
+		lastWasAgent bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx != -1 {
+			line = line[:idx]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !lastWasAgent {
+				cur = new(group)
+				p.groups = append(p.groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			// Rules outside a group and empty disallow rules
+			// (which allow everything) are ignored.
+			if cur == nil || value == "" {
+				continue
+			}
+			cur.rules = append(cur.rules, rule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			lastWasAgent = false
+			// Crawl-delay is a non-standard extension whose value is
+			// the number of seconds to wait between requests.
+			if secs, err := strconv.ParseFloat(value, 64); cur != nil && err == nil && secs > 0 {
+				cur.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		default:
+			lastWasAgent = false
+		}
+	}
+	return p
+}
+
+// Allowed returns true if the crawler identified by userAgent may fetch
+// path. The path should include the query string of the URL, if any.
+func (p *Policy) Allowed(userAgent, path string) bool {
+	if p.disallowAll {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	g := p.groupFor(userAgent)
+	if g == nil {
+		return true
+	}
+
+	// The longest matching rule wins; if an allow and a disallow rule
+	// are equally long, the allow rule wins.
+	var (
+		matchLen = -1
+		allowed  = true
+	)
+	for _, r := range g.rules {
+		if !matchPattern(r.pattern, path) {
+			continue
+		}
+		if n := len(r.pattern); n > matchLen || (n == matchLen && r.allow) {
+			matchLen, allowed = n, r.allow
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the delay between consecutive requests that the group
+// matching userAgent asks for or zero if it does not specify one.
+func (p *Policy) CrawlDelay(userAgent string) time.Duration {
+	if g := p.groupFor(userAgent); g != nil {
+		return g.crawlDelay
+	}
+	return 0
+}
+
+// groupFor returns the group with the longest user-agent token that is a
+// prefix of the product token of userAgent, falling back to the "*" group.
+func (p *Policy) groupFor(userAgent string) *group {
+	product := strings.ToLower(userAgent)
+	if idx := strings.IndexAny(product, "/ "); idx != -1 {
+		product = product[:idx]
+	}
+
+	var (
+		best     *group
+		bestLen  int
+		wildcard *group
+	)
+	for _, g := range p.groups {
+		for _, agent := range g.agents {
+			switch {
+			case agent == "*":
+				if wildcard == nil {
+					wildcard = g
+				}
+			case strings.HasPrefix(product, agent) && len(agent) > bestLen:
+				best, bestLen = g, len(agent)
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return wildcard
+}
+
+// matchPattern returns true if path matches the rule pattern. A "*" in the
+// pattern matches any sequence of characters and a trailing "$" anchors the
+// pattern to the end of path; otherwise patterns match path prefixes.
+func matchPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		// The last part must match the end of the path when the pattern
+		// is anchored.
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx == -1 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
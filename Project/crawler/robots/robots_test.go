@@ -0,0 +1,272 @@
+package robots
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var (
+	_ = gc.Suite(new(PolicyTestSuite))
+	_ = gc.Suite(new(CacheTestSuite))
+)
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type PolicyTestSuite struct{}
+
+func (s *PolicyTestSuite) TestGroupSelection(c *gc.C) {
+	p := Parse([]byte(`
+# Comments and unknown directives are ignored.
+Sitemap: http://example.com/sitemap.xml
+
+User-agent: *
+Disallow: /
+
+User-agent: webcrawler
+User-agent: other-bot
+Disallow: /private/ # trailing comment
+
+User-agent: webcrawler-images
+Allow: /
+`))
+
+	c.Assert(p.Allowed("webcrawler/1.0", "/index.html"), gc.Equals, true)
+	c.Assert(p.Allowed("WebCrawler", "/private/data"), gc.Equals, false)
+	c.Assert(p.Allowed("other-bot", "/private/data"), gc.Equals, false)
+	c.Assert(p.Allowed("webcrawler-images", "/private/data"), gc.Equals, true, gc.Commentf("expected the most specific group to apply"))
+	c.Assert(p.Allowed("unknown-bot", "/index.html"), gc.Equals, false, gc.Commentf("expected the wildcard group to apply"))
+	c.Assert(p.Allowed("unknown-bot", "/robots.txt"), gc.Equals, true)
+}
+
+func (s *PolicyTestSuite) TestRulePrecedence(c *gc.C) {
+	p := Parse([]byte(`
+User-agent: *
+Disallow: /docs/
+Allow: /docs/public/
+Disallow: /*.php$
+Allow: /page
+Disallow: /page
+Disallow:
+`))
+
+	specs := []struct {
+		path string
+		exp  bool
+	}{
+		{"/", true},
+		{"/docs/", false},
+		{"/docs/internal/a.html", false},
+		{"/docs/public/a.html", true},
+		{"/index.php", false},
+		{"/index.php?q=1", true},
+		{"/dir/index.php", false},
+		{"/page", true},
+	}
+	for _, spec := range specs {
+		c.Assert(p.Allowed("webcrawler", spec.path), gc.Equals, spec.exp, gc.Commentf("path %q", spec.path))
+	}
+}
+
+func (s *PolicyTestSuite) TestCrawlDelay(c *gc.C) {
+	p := Parse([]byte(`
+User-agent: *
+Crawl-delay: 2.5
+
+User-agent: webcrawler
+Crawl-delay: soon
+Disallow: /private/
+
+User-agent: fast-bot
+Crawl-delay: 1
+`))
+
+	c.Assert(p.CrawlDelay("unknown-bot"), gc.Equals, 2500*time.Millisecond)
+	c.Assert(p.CrawlDelay("fast-bot/2.0"), gc.Equals, time.Second)
+	c.Assert(p.CrawlDelay("webcrawler"), gc.Equals, time.Duration(0), gc.Commentf("expected invalid values to be ignored"))
+	c.Assert(AllowAll().CrawlDelay("webcrawler"), gc.Equals, time.Duration(0))
+}
+
+func (s *PolicyTestSuite) TestEntryPolicy(c *gc.C) {
+	c.Assert((&Entry{StatusCode: 404}).Policy().Allowed("webcrawler", "/"), gc.Equals, true)
+	c.Assert((&Entry{StatusCode: 503}).Policy().Allowed("webcrawler", "/"), gc.Equals, false)
+	c.Assert((&Entry{StatusCode: 0}).Policy().Allowed("webcrawler", "/"), gc.Equals, false)
+	c.Assert((&Entry{StatusCode: 200, Body: []byte("User-agent: *\nDisallow: /a")}).Policy().Allowed("webcrawler", "/a"), gc.Equals, false)
+}
+
+type CacheTestSuite struct{}
+
+func (s *CacheTestSuite) TestSharedStore(c *gc.C) {
+	var (
+		store  = NewMemoryStore()
+		getter = newFakeGetter(map[string]fakeResponse{
+			"http://example.com/robots.txt": {status: 200, body: "User-agent: *\nCrawl-delay: 3\nDisallow: /private"},
+		})
+	)
+
+	// Two workers that share a store fetch the robots.txt file once.
+	for i := 0; i < 2; i++ {
+		cache := s.cache(c, Config{URLGetter: getter, Store: store})
+		for j := 0; j < 3; j++ {
+			allowed, err := cache.Allowed("http://example.com/index.html")
+			c.Assert(err, gc.IsNil)
+			c.Assert(allowed, gc.Equals, true)
+
+			allowed, err = cache.Allowed("http://example.com/private/data?x=1")
+			c.Assert(err, gc.IsNil)
+			c.Assert(allowed, gc.Equals, false)
+		}
+	}
+	c.Assert(getter.count("http://example.com/robots.txt"), gc.Equals, 1)
+
+	delay, err := s.cache(c, Config{URLGetter: getter, Store: store}).CrawlDelay("http://example.com/index.html")
+	c.Assert(err, gc.IsNil)
+	c.Assert(delay, gc.Equals, 3*time.Second)
+
+	entry, err := store.Get("http://example.com")
+	c.Assert(err, gc.IsNil)
+	c.Assert(entry.StatusCode, gc.Equals, 200)
+	c.Assert(entry.ExpiresAt.Sub(entry.FetchedAt), gc.Equals, 24*time.Hour)
+}
+
+func (s *CacheTestSuite) TestNegativeCaching(c *gc.C) {
+	var (
+		store  = NewMemoryStore()
+		getter = newFakeGetter(map[string]fakeResponse{
+			"http://missing.com/robots.txt": {status: 404},
+			"http://broken.com/robots.txt":  {status: 500},
+			"http://down.com/robots.txt":    {err: errors.New("connection refused")},
+		})
+		cache = s.cache(c, Config{URLGetter: getter, Store: store, NegativeTTL: 10 * time.Minute})
+	)
+
+	specs := []struct {
+		url string
+		exp bool
+	}{
+		{"http://missing.com/index.html", true},
+		{"http://broken.com/index.html", false},
+		{"http://down.com/index.html", false},
+	}
+	for i := 0; i < 2; i++ {
+		for _, spec := range specs {
+			allowed, err := cache.Allowed(spec.url)
+			c.Assert(err, gc.IsNil)
+			c.Assert(allowed, gc.Equals, spec.exp, gc.Commentf("url %q", spec.url))
+		}
+	}
+
+	for _, host := range []string{"http://missing.com", "http://broken.com", "http://down.com"} {
+		c.Assert(getter.count(host+"/robots.txt"), gc.Equals, 1)
+		entry, err := store.Get(host)
+		c.Assert(err, gc.IsNil)
+		c.Assert(entry.ExpiresAt.Sub(entry.FetchedAt), gc.Equals, 10*time.Minute)
+	}
+}
+
+func (s *CacheTestSuite) TestExpiredEntriesAreRefreshed(c *gc.C) {
+	var (
+		store  = NewMemoryStore()
+		getter = newFakeGetter(map[string]fakeResponse{
+			"https://example.com/robots.txt": {status: 200, body: "User-agent: *\nAllow: /"},
+		})
+	)
+	c.Assert(store.Put(&Entry{
+		Host:       "https://example.com",
+		StatusCode: 200,
+		Body:       []byte("User-agent: *\nDisallow: /"),
+		FetchedAt:  time.Now().Add(-48 * time.Hour),
+		ExpiresAt:  time.Now().Add(-24 * time.Hour),
+	}), gc.IsNil)
+
+	allowed, err := s.cache(c, Config{URLGetter: getter, Store: store}).Allowed("https://example.com/")
+	c.Assert(err, gc.IsNil)
+	c.Assert(allowed, gc.Equals, true)
+	c.Assert(getter.count("https://example.com/robots.txt"), gc.Equals, 1)
+}
+
+func (s *CacheTestSuite) TestConcurrentLookupsShareFetch(c *gc.C) {
+	getter := newFakeGetter(map[string]fakeResponse{
+		"http://example.com/robots.txt": {status: 200, body: "User-agent: *\nDisallow: /private", delay: 50 * time.Millisecond},
+	})
+	cache := s.cache(c, Config{URLGetter: getter})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allowed, err := cache.Allowed("http://example.com/private")
+			c.Check(err, gc.IsNil)
+			c.Check(allowed, gc.Equals, false)
+		}()
+	}
+	wg.Wait()
+	c.Assert(getter.count("http://example.com/robots.txt"), gc.Equals, 1)
+}
+
+func (s *CacheTestSuite) TestInvalidURLs(c *gc.C) {
+	cache := s.cache(c, Config{URLGetter: newFakeGetter(nil)})
+	_, err := cache.Allowed("ftp://example.com/file")
+	c.Assert(err, gc.ErrorMatches, "robots: unsupported URL.*")
+	_, err = cache.Allowed("http://[::1")
+	c.Assert(err, gc.NotNil)
+	_, err = cache.CrawlDelay("ftp://example.com/file")
+	c.Assert(err, gc.ErrorMatches, "robots: unsupported URL.*")
+}
+
+func (s *CacheTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewCache(Config{TTL: -1, NegativeTTL: -1, MaxBodySize: -1})
+	c.Assert(err, gc.ErrorMatches, "(?s).*URL getter has not been provided.*invalid TTL.*invalid negative TTL.*invalid max body size.*")
+}
+
+func (s *CacheTestSuite) cache(c *gc.C, cfg Config) *Cache {
+	cache, err := NewCache(cfg)
+	c.Assert(err, gc.IsNil)
+	return cache
+}
+
+type fakeResponse struct {
+	status int
+	body   string
+	err    error
+	delay  time.Duration
+}
+
+type fakeGetter struct {
+	mu        sync.Mutex
+	responses map[string]fakeResponse
+	calls     map[string]int
+}
+
+func newFakeGetter(responses map[string]fakeResponse) *fakeGetter {
+	return &fakeGetter{responses: responses, calls: make(map[string]int)}
+}
+
+func (g *fakeGetter) Get(url string) (*http.Response, error) {
+	g.mu.Lock()
+	g.calls[url]++
+	res, ok := g.responses[url]
+	g.mu.Unlock()
+
+	time.Sleep(res.delay)
+	if !ok {
+		res.status = http.StatusNotFound
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &http.Response{StatusCode: res.status, Body: io.NopCloser(strings.NewReader(res.body))}, nil
+}
+
+func (g *fakeGetter) count(url string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.calls[url]
+}
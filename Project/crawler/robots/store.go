@@ -0,0 +1,156 @@
+package robots
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	// Register the postgres driver.
+	_ "github.com/lib/pq"
+)
+
+// ErrNotFound is returned by stores when no entry exists for a host.
+var ErrNotFound = errors.New("not found")
+
+// Entry holds the outcome of fetching the robots.txt file of a host.
+type Entry struct {
+	// The scheme and authority of the host (e.g. "https://example.com").
+	Host string
+
+	// The HTTP status code of the robots.txt response or zero if the
+	// file could not be fetched (e.g. because of a network error).
+	StatusCode int
+
+	// The body of the robots.txt response. Only populated for successful
+	// responses.
+	Body []byte
+
+	// The time when the robots.txt file was fetched.
+	FetchedAt time.Time
+
+	// The time after which the entry must be refreshed.
+	ExpiresAt time.Time
+}
+
+// Policy returns the crawl policy described by the entry. Following RFC 9309,
+// hosts without a robots.txt file (4xx responses) allow all paths while hosts
+// whose robots.txt file is unreachable (5xx responses or network errors)
+// disallow all paths.
+func (e *Entry) Policy() *Policy {
+	switch {
+	case e.StatusCode >= 200 && e.StatusCode <= 299:
+		return Parse(e.Body)
+	case e.StatusCode >= 400 && e.StatusCode <= 499:
+		return AllowAll()
+	default:
+		return DisallowAll()
+	}
+}
+
+// Store is implemented by objects that can persist robots.txt entries so
+// that they can be shared between crawler workers.
+type Store interface {
+	// Get returns the entry for host or ErrNotFound. Expired entries may
+	// be returned.
+	Get(host string) (*Entry, error)
+
+	// Put creates or replaces the entry for entry.Host.
+	Put(entry *Entry) error
+}
+
+// Compile-time checks for ensuring the stores implement Store.
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*PostgresStore)(nil)
+)
+
+// MemoryStore is a Store that keeps entries in memory. It can be shared by
+// the workers of a single process.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+// NewMemoryStore returns a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(host string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry := s.entries[host]
+	if entry == nil {
+		return nil, fmt.Errorf("get robots entry: %w", ErrNotFound)
+	}
+	eCopy := new(Entry)
+	*eCopy = *entry
+	return eCopy, nil
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(entry *Entry) error {
+	eCopy := new(Entry)
+	*eCopy = *entry
+	eCopy.Body = append([]byte(nil), entry.Body...)
+
+	s.mu.Lock()
+	s.entries[entry.Host] = eCopy
+	s.mu.Unlock()
+	return nil
+}
+
+var (
+	upsertEntryQuery = `
+INSERT INTO robots_cache (host, status_code, body, fetched_at, expires_at) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (host) DO UPDATE SET status_code=$2, body=$3, fetched_at=$4, expires_at=$5
+`
+	findEntryQuery = "SELECT status_code, body, fetched_at, expires_at FROM robots_cache WHERE host=$1"
+)
+
+// PostgresStore is a Store that persists entries to the robots_cache table
+// of a postgres database so that they can be shared by a fleet of workers.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore returns a PostgresStore that connects to the database
+// specified by dsn.
+func NewPostgresStore(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Close terminates the connection to the database.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// Get implements Store.
+func (s *PostgresStore) Get(host string) (*Entry, error) {
+	entry := &Entry{Host: host}
+	row := s.db.QueryRow(findEntryQuery, host)
+	if err := row.Scan(&entry.StatusCode, &entry.Body, &entry.FetchedAt, &entry.ExpiresAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("get robots entry: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("get robots entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Put implements Store.
+func (s *PostgresStore) Put(entry *Entry) error {
+	_, err := s.db.Exec(upsertEntryQuery, entry.Host, entry.StatusCode, entry.Body, entry.FetchedAt.UTC(), entry.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("put robots entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"webcrawler/logging"
+	"webcrawler/pipeline"
+)
+
+var (
+	metaRobotsRegex   = regexp.MustCompile(`(?i)<meta[^>]*?\sname\s*=\s*["']?robots["'\s>][^>]*>`)
+	metaContentRegex  = regexp.MustCompile(`(?i)\scontent\s*=\s*(?:"([^"]*)"|'([^']*)'|([^"'\s>]+))`)
+	robotsTagUARegex  = regexp.MustCompile(`^\s*([A-Za-z][\w-]*)\s*:`)
+	robotsTagRuleArgs = map[string]bool{
+		"unavailable_after": true,
+		"max-snippet":       true,
+		"max-image-preview": true,
+		"max-video-preview": true,
+	}
+)
+
+var _ pipeline.Processor = (*robotsDirectiveParser)(nil)
+
+// robotsDirectiveParser applies the page-level robots directives declared by
+// the X-Robots-Tag response headers and, for HTML pages, the
+// <meta name="robots"> tags of each payload. Pages with a noindex directive
+// are not indexed and no links are extracted from pages with a nofollow
+// directive.
+type robotsDirectiveParser struct {
+	logger *slog.Logger
+}
+
+func newRobotsDirectiveParser(logger *slog.Logger) *robotsDirectiveParser {
+	return &robotsDirectiveParser{logger: logging.Component(logger, "crawler.robots_directives")}
+}
+
+func (rp *robotsDirectiveParser) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	for _, value := range payload.RobotsTags {
+		if !appliesToAllAgents(value) {
+			continue
+		}
+		rp.apply(payload, value)
+	}
+	if payload.Format == formatHTML {
+		for _, tag := range metaRobotsRegex.FindAllString(payload.RawContent.String(), -1) {
+			if match := metaContentRegex.FindStringSubmatch(tag); match != nil {
+				rp.apply(payload, match[1]+match[2]+match[3])
+			}
+		}
+	}
+
+	if payload.NoIndex || payload.NoFollow {
+		rp.logger.Debug("page declares robots directives", logging.Link(payload.LinkID, payload.URL), "noindex", payload.NoIndex, "nofollow", payload.NoFollow)
+	}
+	return payload, nil
+}
+
+// apply sets the noindex and nofollow flags of payload according to the
+// comma-separated list of directives.
+func (rp *robotsDirectiveParser) apply(payload *crawlerPayload, directives string) {
+	for _, directive := range strings.Split(directives, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			payload.NoIndex = true
+		case "nofollow":
+			payload.NoFollow = true
+		case "none":
+			payload.NoIndex, payload.NoFollow = true, true
+		}
+	}
+}
+
+// appliesToAllAgents returns false if the X-Robots-Tag value is scoped to a
+// particular user agent (e.g. "googlebot: noindex").
+func appliesToAllAgents(value string) bool {
+	match := robotsTagUARegex.FindStringSubmatch(value)
+	return match == nil || robotsTagRuleArgs[strings.ToLower(match[1])]
+}
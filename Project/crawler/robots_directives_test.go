@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"context"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(RobotsDirectivesTestSuite))
+
+type RobotsDirectivesTestSuite struct{}
+
+func (s *RobotsDirectivesTestSuite) TestRobotsDirectives(c *gc.C) {
+	specs := []struct {
+		descr       string
+		robotsTags  []string
+		format      contentFormat
+		content     string
+		expNoIndex  bool
+		expNoFollow bool
+	}{
+		{
+			descr:   "no directives",
+			content: `<html><head><meta name="description" content="noindex"></head></html>`,
+		},
+		{
+			descr:      "header directives",
+			robotsTags: []string{"NoIndex"},
+			expNoIndex: true,
+		},
+		{
+			descr:       "header directives with arguments",
+			robotsTags:  []string{"unavailable_after: 25 Jun 2010 15:00:00 PST, nofollow"},
+			expNoFollow: true,
+		},
+		{
+			descr:      "header directives for other user agents",
+			robotsTags: []string{"googlebot: noindex, nofollow", "otherbot: none"},
+		},
+		{
+			descr:       "none directive",
+			robotsTags:  []string{"none"},
+			format:      formatPDF,
+			expNoIndex:  true,
+			expNoFollow: true,
+		},
+		{
+			descr:       "meta tags",
+			content:     `<head><META NAME="robots" CONTENT="noindex"><meta content='nofollow' name='robots'></head>`,
+			expNoIndex:  true,
+			expNoFollow: true,
+		},
+		{
+			descr:       "unquoted meta tag",
+			content:     `<head><meta name=robots content=nofollow></head>`,
+			expNoFollow: true,
+		},
+		{
+			descr:   "meta tags of non-HTML content",
+			format:  formatFeed,
+			content: `<meta name="robots" content="noindex">`,
+		},
+	}
+
+	for _, spec := range specs {
+		p := &crawlerPayload{URL: "http://example.com/", RobotsTags: spec.robotsTags, Format: spec.format}
+		_, _ = p.RawContent.WriteString(spec.content)
+
+		out, err := newRobotsDirectiveParser(nil).Process(context.TODO(), p)
+		c.Assert(err, gc.IsNil)
+		c.Assert(out.(*crawlerPayload).NoIndex, gc.Equals, spec.expNoIndex, gc.Commentf(spec.descr))
+		c.Assert(out.(*crawlerPayload).NoFollow, gc.Equals, spec.expNoFollow, gc.Commentf(spec.descr))
+	}
+}
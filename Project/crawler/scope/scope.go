@@ -0,0 +1,229 @@
+// Package scope decides which of the links discovered by the crawler fall
+// within the configured crawl scope. Links outside the scope are dropped
+// before they are added to the link graph and are therefore never crawled.
+//
+// A link is in scope if all of the following hold:
+//
+//   - its host matches one of the include host patterns (if any) and none of
+//     the exclude host patterns.
+//   - it points to the same domain as the page it was found on if the scope
+//     is restricted to same-domain links.
+//   - its URL matches one of the include URL patterns (if any) and none of
+//     the exclude URL patterns.
+//   - its path does not have more segments than the maximum path depth.
+//   - the maximum number of pages for its domain has not been reached.
+package scope
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Reason describes why a link is outside the crawl scope.
+type Reason string
+
+// The reasons reported by Check.
+const (
+	// InScope indicates that the link is within the crawl scope.
+	InScope Reason = ""
+
+	// ExcludedHost indicates that the link host does not match the
+	// include host patterns or matches an exclude host pattern.
+	ExcludedHost Reason = "host"
+
+	// OffSite indicates that the link points to a different domain than
+	// the page it was found on.
+	OffSite Reason = "off_site"
+
+	// ExcludedURL indicates that the link URL does not match the include
+	// URL patterns or matches an exclude URL pattern.
+	ExcludedURL Reason = "url"
+
+	// TooDeep indicates that the link path exceeds the maximum depth.
+	TooDeep Reason = "depth"
+
+	// QuotaExceeded indicates that the maximum number of pages for the
+	// link domain has been reached.
+	QuotaExceeded Reason = "domain_quota"
+)
+
+// Config encapsulates the settings for a Scope. The zero value places all
+// links in scope.
+type Config struct {
+	// Host patterns that links must (include) or must not (exclude)
+	// match. Patterns without wildcards match the host and all of its
+	// subdomains (e.g. "example.com" matches "docs.example.com"); other
+	// patterns are matched against the entire host using path.Match
+	// syntax (e.g. "*.example.com" or "cdn?.example.com"). If no include
+	// patterns are specified, all hosts are included.
+	IncludeHosts []string
+	ExcludeHosts []string
+
+	// URL patterns that links must (include) or must not (exclude) match.
+	// If no include patterns are specified, all URLs are included.
+	IncludeURLs []*regexp.Regexp
+	ExcludeURLs []*regexp.Regexp
+
+	// The maximum number of path segments of a link (e.g. 2 for
+	// "/docs/intro"). Zero disables the limit.
+	MaxPathDepth int
+
+	// The maximum number of distinct links that are accepted for each
+	// domain. Zero disables the limit.
+	MaxPagesPerDomain int
+
+	// If set, only links that point to the same domain as the page they
+	// were found on are accepted.
+	SameDomainOnly bool
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	for _, patterns := range [][]string{cfg.IncludeHosts, cfg.ExcludeHosts} {
+		for _, pattern := range patterns {
+			if pErr := ValidateHostPattern(pattern); pErr != nil {
+				err = multierror.Append(err, pErr)
+			}
+		}
+	}
+	if cfg.MaxPathDepth < 0 {
+		err = multierror.Append(err, fmt.Errorf("max path depth must not be negative"))
+	}
+	if cfg.MaxPagesPerDomain < 0 {
+		err = multierror.Append(err, fmt.Errorf("max pages per domain must not be negative"))
+	}
+	return err
+}
+
+// Scope decides whether links are within the crawl scope. It is safe for
+// concurrent use. A nil Scope places all links in scope.
+type Scope struct {
+	cfg Config
+
+	// The links accepted for each domain; only tracked if the number of
+	// pages per domain is limited.
+	mu    sync.Mutex
+	pages map[string]map[string]struct{}
+}
+
+// New returns a new Scope using the provided config.
+func New(cfg Config) (*Scope, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("crawl scope: config validation failed: %w", err)
+	}
+	return &Scope{cfg: cfg, pages: make(map[string]map[string]struct{})}, nil
+}
+
+// Check returns InScope if link, which was found on the page at src, is
+// within the crawl scope or the reason why it is not. Links that are in
+// scope count towards the page quota of their domain.
+func (s *Scope) Check(src, link *url.URL) Reason {
+	if s == nil {
+		return InScope
+	}
+
+	host := strings.ToLower(link.Hostname())
+	if len(s.cfg.IncludeHosts) != 0 && !matchAnyHost(s.cfg.IncludeHosts, host) {
+		return ExcludedHost
+	}
+	if matchAnyHost(s.cfg.ExcludeHosts, host) {
+		return ExcludedHost
+	}
+	if s.cfg.SameDomainOnly && src != nil && domainOf(src.Hostname()) != domainOf(host) {
+		return OffSite
+	}
+
+	linkStr := link.String()
+	if len(s.cfg.IncludeURLs) != 0 && !matchAnyURL(s.cfg.IncludeURLs, linkStr) {
+		return ExcludedURL
+	}
+	if matchAnyURL(s.cfg.ExcludeURLs, linkStr) {
+		return ExcludedURL
+	}
+	if s.cfg.MaxPathDepth > 0 && PathDepth(link) > s.cfg.MaxPathDepth {
+		return TooDeep
+	}
+	if s.cfg.MaxPagesPerDomain > 0 && !s.claimPage(domainOf(host), linkStr) {
+		return QuotaExceeded
+	}
+	return InScope
+}
+
+// claimPage records linkStr as a page of domain and returns true unless the
+// page quota of the domain has already been reached by other links.
+func (s *Scope) claimPage(domain, linkStr string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pages := s.pages[domain]
+	if _, found := pages[linkStr]; found {
+		return true
+	}
+	if len(pages) >= s.cfg.MaxPagesPerDomain {
+		return false
+	}
+	if pages == nil {
+		pages = make(map[string]struct{})
+		s.pages[domain] = pages
+	}
+	pages[linkStr] = struct{}{}
+	return true
+}
+
+// PathDepth returns the number of non-empty segments in the path of u.
+func PathDepth(u *url.URL) int {
+	return len(strings.FieldsFunc(u.EscapedPath(), func(r rune) bool { return r == '/' }))
+}
+
+// MatchHost returns true if host matches pattern. See Config for the
+// supported pattern syntax.
+func MatchHost(pattern, host string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, _ := path.Match(pattern, host)
+		return matched
+	}
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+func matchAnyHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if MatchHost(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyURL(patterns []*regexp.Regexp, linkStr string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(linkStr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateHostPattern returns an error if pattern is not a valid host pattern.
+func ValidateHostPattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" || strings.ContainsAny(pattern, "/: ") {
+		return fmt.Errorf("invalid host pattern %q", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid host pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// domainOf returns the domain that host belongs to for the purpose of the
+// same-domain check and the page quotas; a leading "www." label is ignored.
+func domainOf(host string) string {
+	return strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(host), "."), "www.")
+}
@@ -0,0 +1,110 @@
+package scope
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ScopeTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type ScopeTestSuite struct{}
+
+func (s *ScopeTestSuite) TestHostPatterns(c *gc.C) {
+	sc, err := New(Config{
+		IncludeHosts: []string{"example.com", "*.example.org"},
+		ExcludeHosts: []string{"private.example.com", "cdn?.example.org"},
+	})
+	c.Assert(err, gc.IsNil)
+
+	specs := []struct {
+		link string
+		exp  Reason
+	}{
+		{link: "http://example.com/", exp: InScope},
+		{link: "http://DOCS.example.com/a", exp: InScope},
+		{link: "http://badexample.com/", exp: ExcludedHost},
+		{link: "http://example.org/", exp: ExcludedHost},
+		{link: "http://www.example.org/", exp: InScope},
+		{link: "http://cdn1.example.org/", exp: ExcludedHost},
+		{link: "http://a.private.example.com/", exp: ExcludedHost},
+	}
+	for specIndex, spec := range specs {
+		c.Assert(sc.Check(nil, mustParse(c, spec.link)), gc.Equals, spec.exp, gc.Commentf("spec %d: %s", specIndex, spec.link))
+	}
+}
+
+func (s *ScopeTestSuite) TestURLPatternsAndPathDepth(c *gc.C) {
+	sc, err := New(Config{
+		IncludeURLs:  []*regexp.Regexp{regexp.MustCompile(`^https://example\.com/(docs|blog)/`)},
+		ExcludeURLs:  []*regexp.Regexp{regexp.MustCompile(`/drafts/`)},
+		MaxPathDepth: 3,
+	})
+	c.Assert(err, gc.IsNil)
+
+	specs := []struct {
+		link string
+		exp  Reason
+	}{
+		{link: "https://example.com/docs/intro", exp: InScope},
+		{link: "https://example.com/about", exp: ExcludedURL},
+		{link: "https://example.com/blog/drafts/post", exp: ExcludedURL},
+		{link: "https://example.com/docs/a/b/", exp: InScope},
+		{link: "https://example.com/docs/a/b/c", exp: TooDeep},
+	}
+	for specIndex, spec := range specs {
+		c.Assert(sc.Check(nil, mustParse(c, spec.link)), gc.Equals, spec.exp, gc.Commentf("spec %d: %s", specIndex, spec.link))
+	}
+}
+
+func (s *ScopeTestSuite) TestSameDomainOnly(c *gc.C) {
+	sc, err := New(Config{SameDomainOnly: true})
+	c.Assert(err, gc.IsNil)
+
+	src := mustParse(c, "https://www.example.com/index.html")
+	c.Assert(sc.Check(src, mustParse(c, "https://example.com/about")), gc.Equals, InScope)
+	c.Assert(sc.Check(src, mustParse(c, "https://www.example.com/about")), gc.Equals, InScope)
+	c.Assert(sc.Check(src, mustParse(c, "https://docs.example.com/")), gc.Equals, OffSite)
+	c.Assert(sc.Check(src, mustParse(c, "https://other.com/")), gc.Equals, OffSite)
+}
+
+func (s *ScopeTestSuite) TestMaxPagesPerDomain(c *gc.C) {
+	sc, err := New(Config{MaxPagesPerDomain: 2})
+	c.Assert(err, gc.IsNil)
+
+	for i := 0; i < 2; i++ {
+		c.Assert(sc.Check(nil, mustParse(c, fmt.Sprintf("https://example.com/%d", i))), gc.Equals, InScope)
+	}
+	c.Assert(sc.Check(nil, mustParse(c, "https://www.example.com/2")), gc.Equals, QuotaExceeded)
+
+	// Links that were already accepted remain in scope and other domains
+	// have their own quota.
+	c.Assert(sc.Check(nil, mustParse(c, "https://example.com/1")), gc.Equals, InScope)
+	c.Assert(sc.Check(nil, mustParse(c, "https://other.com/2")), gc.Equals, InScope)
+}
+
+func (s *ScopeTestSuite) TestNilScope(c *gc.C) {
+	var sc *Scope
+	c.Assert(sc.Check(nil, mustParse(c, "https://example.com/")), gc.Equals, InScope)
+}
+
+func (s *ScopeTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := New(Config{
+		IncludeHosts:      []string{"", "example.com/docs"},
+		ExcludeHosts:      []string{"[a-"},
+		MaxPathDepth:      -1,
+		MaxPagesPerDomain: -1,
+	})
+	c.Assert(err, gc.ErrorMatches, `(?s)crawl scope: config validation failed:.*invalid host pattern "".*invalid host pattern "example.com/docs".*invalid host pattern "\[a-".*max path depth.*max pages per domain.*`)
+}
+
+func mustParse(c *gc.C, rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	c.Assert(err, gc.IsNil)
+	return u
+}
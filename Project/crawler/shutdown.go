@@ -0,0 +1,242 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/logging"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Flusher is implemented by indexers (or other sinks) that buffer writes and
+// need to flush them before the process exits.
+type Flusher interface {
+	// Flush blocks until all pending writes have been applied.
+	Flush() error
+}
+
+// ShutdownConfig encapsulates the settings for a ShutdownCoordinator.
+type ShutdownConfig struct {
+	// The store for persisting the crawl checkpoints; typically the link
+	// graph.
+	Checkpoints graph.CheckpointStore
+
+	// The partition of the link graph that is crawled by this instance.
+	Partition int
+
+	// An optional list of components whose pending writes are flushed
+	// once the crawler pipeline has drained.
+	Flushers []Flusher
+
+	// The maximum time to wait for in-flight links to drain after a
+	// shutdown has been requested. Once it elapses, the context returned
+	// by Context is cancelled and the remaining in-flight links are
+	// abandoned; they will be crawled again when the pass is resumed. A
+	// zero value waits indefinitely.
+	DrainTimeout time.Duration
+
+	// An optional logger for reporting shutdown progress. If not
+	// specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *ShutdownConfig) validate() error {
+	var err error
+	if cfg.Checkpoints == nil {
+		err = multierror.Append(err, fmt.Errorf("checkpoint store has not been provided"))
+	}
+	if cfg.Partition < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid partition"))
+	}
+	if cfg.DrainTimeout < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid drain timeout"))
+	}
+	return err
+}
+
+// Pass describes the crawl pass that should be executed for a partition.
+type Pass struct {
+	// The ID of the pass; used as the crawler's Config.PassID.
+	ID uint64
+
+	// The time when the pass started. Only the links that were retrieved
+	// before this time should be crawled by the pass.
+	StartedAt time.Time
+
+	// Indicates whether the pass resumes an interrupted pass.
+	Resumed bool
+}
+
+// ShutdownCoordinator allows a crawl pass to be stopped gracefully (e.g. when
+// the process receives SIGTERM) and resumed after a restart.
+//
+// Once a shutdown has been requested, crawlers configured with the
+// coordinator stop feeding new links into their pipeline while the links that
+// are already in flight are allowed to drain. The caller then invokes Complete
+// to flush any pending index writes and record a checkpoint for the pass. If
+// the pass was interrupted, NextPass returns the same pass after a restart so
+// that it resumes with the links that were not crawled.
+type ShutdownCoordinator struct {
+	cfg    ShutdownConfig
+	logger *slog.Logger
+
+	drainCh   chan struct{}
+	drainOnce sync.Once
+}
+
+// NewShutdownCoordinator returns a new ShutdownCoordinator instance using the
+// provided config.
+func NewShutdownCoordinator(cfg ShutdownConfig) (*ShutdownCoordinator, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("shutdown coordinator: config validation failed: %w", err)
+	}
+
+	return &ShutdownCoordinator{
+		cfg:     cfg,
+		logger:  logging.Component(cfg.Logger, "crawler.shutdown"),
+		drainCh: make(chan struct{}),
+	}, nil
+}
+
+// NextPass returns the pass to execute for the partition. If the checkpoint
+// for the partition refers to an interrupted pass, that pass is resumed;
+// otherwise, a new pass that follows the last checkpointed pass is started.
+func (sc *ShutdownCoordinator) NextPass() (Pass, error) {
+	cp, err := sc.cfg.Checkpoints.Checkpoint(sc.cfg.Partition)
+	switch {
+	case errors.Is(err, graph.ErrNotFound):
+		return Pass{ID: 1, StartedAt: time.Now()}, nil
+	case err != nil:
+		return Pass{}, fmt.Errorf("next pass: %w", err)
+	case !cp.Completed():
+		sc.logger.Info("resuming interrupted crawl pass", "partition", sc.cfg.Partition, "pass_id", cp.PassID, "pass_started_at", cp.PassStartedAt)
+		return Pass{ID: cp.PassID, StartedAt: cp.PassStartedAt, Resumed: true}, nil
+	default:
+		return Pass{ID: cp.PassID + 1, StartedAt: time.Now()}, nil
+	}
+}
+
+// Shutdown requests the crawlers that use the coordinator to stop feeding
+// new links into their pipelines. It is safe to call Shutdown multiple times
+// and from multiple go-routines.
+func (sc *ShutdownCoordinator) Shutdown() {
+	sc.drainOnce.Do(func() {
+		sc.logger.Info("shutdown requested; draining crawler pipeline", "partition", sc.cfg.Partition)
+		close(sc.drainCh)
+	})
+}
+
+// Draining returns a channel that is closed once a shutdown is requested.
+func (sc *ShutdownCoordinator) Draining() <-chan struct{} {
+	return sc.drainCh
+}
+
+// NotifyOnSignal requests a shutdown when the process receives any of sigs
+// (e.g. syscall.SIGTERM). The returned function stops listening for signals.
+func (sc *ShutdownCoordinator) NotifyOnSignal(sigs ...os.Signal) (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	doneCh := make(chan struct{})
+	signal.Notify(sigCh, sigs...)
+
+	go func() {
+		select {
+		case sig := <-sigCh:
+			sc.logger.Info("received signal", "signal", sig.String())
+			sc.Shutdown()
+		case <-doneCh:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigCh)
+			close(doneCh)
+		})
+	}
+}
+
+// Context returns a context derived from parent that should be used for
+// running the crawl pass. If a drain timeout is configured, the context is
+// cancelled once the timeout elapses after a shutdown has been requested.
+func (sc *ShutdownCoordinator) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancelFn := context.WithCancel(parent)
+	if sc.cfg.DrainTimeout == 0 {
+		return ctx, cancelFn
+	}
+
+	go func() {
+		select {
+		case <-sc.drainCh:
+		case <-ctx.Done():
+			return
+		}
+
+		timer := time.NewTimer(sc.cfg.DrainTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			sc.logger.Warn("drain timeout exceeded; abandoning in-flight links", "partition", sc.cfg.Partition, "timeout", sc.cfg.DrainTimeout)
+			cancelFn()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancelFn
+}
+
+// Complete flushes the pending writes of the configured flushers and records
+// a checkpoint for pass. The pass is checkpointed as completed only if report
+// indicates that all of its links were crawled; a nil report (e.g. because
+// the pass failed) is treated as an interrupted pass.
+func (sc *ShutdownCoordinator) Complete(pass Pass, report *Report) error {
+	var err error
+	for _, f := range sc.cfg.Flushers {
+		if flushErr := f.Flush(); flushErr != nil {
+			err = multierror.Append(err, flushErr)
+		}
+	}
+	if err != nil {
+		// Do not checkpoint the pass as completed if some of its
+		// writes may have been lost.
+		report = nil
+	}
+
+	cp := &graph.Checkpoint{
+		Partition:     sc.cfg.Partition,
+		PassID:        pass.ID,
+		PassStartedAt: pass.StartedAt,
+	}
+	if report != nil && report.Status == BudgetNotExceeded {
+		cp.CompletedAt = time.Now()
+	}
+	if cpErr := sc.cfg.Checkpoints.SaveCheckpoint(cp); cpErr != nil {
+		err = multierror.Append(err, cpErr)
+	}
+	if err != nil {
+		return fmt.Errorf("complete pass: %w", err)
+	}
+
+	sc.logger.Info("recorded crawl checkpoint", "partition", cp.Partition, "pass_id", cp.PassID, "completed", cp.Completed())
+	return nil
+}
+
+// draining reports whether a shutdown has been requested. It is safe to call
+// on a nil coordinator.
+func (sc *ShutdownCoordinator) draining() bool {
+	if sc == nil {
+		return false
+	}
+	select {
+	case <-sc.drainCh:
+		return true
+	default:
+		return false
+	}
+}
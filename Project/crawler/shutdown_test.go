@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"time"
+	memgraph "webcrawler/crawler/linkgraph/store/memory"
+	"webcrawler/pipeline"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ShutdownTestSuite))
+
+type ShutdownTestSuite struct {
+	g *memgraph.InMemoryGraph
+}
+
+func (s *ShutdownTestSuite) SetUpTest(c *gc.C) {
+	s.g = memgraph.NewInMemoryGraph()
+}
+
+func (s *ShutdownTestSuite) TestShutdownDrainsInFlightLinks(c *gc.C) {
+	sc := s.coordinator(c, ShutdownConfig{})
+
+	// Request a shutdown while the first link is being fetched.
+	fetch := pipeline.ProcessorFunc(func(_ context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+		sc.Shutdown()
+		return p, nil
+	})
+	passthrough := pipeline.ProcessorFunc(func(_ context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+		return p, nil
+	})
+	crawler := &Crawler{
+		p: pipeline.New(
+			pipeline.FIFO(fetch),
+			pipeline.Broadcast(passthrough, passthrough),
+		),
+		shutdown: sc,
+	}
+
+	links := makeLinks(10)
+	report, err := crawler.CrawlWithBudget(context.TODO(), &sliceLinkIterator{links: links}, Budget{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Status, gc.Equals, CrawlInterrupted)
+
+	// Every link that entered the pipeline must have been drained.
+	c.Assert(report.Processed >= 1 && report.Processed <= 2, gc.Equals, true, gc.Commentf("processed %d links", report.Processed))
+	c.Assert(report.Processed+len(report.Deferred), gc.Equals, len(links))
+}
+
+func (s *ShutdownTestSuite) TestResumeInterruptedPass(c *gc.C) {
+	flusher := new(countingFlusher)
+	sc := s.coordinator(c, ShutdownConfig{Partition: 2, Flushers: []Flusher{flusher}})
+
+	pass, err := sc.NextPass()
+	c.Assert(err, gc.IsNil)
+	c.Assert(pass.ID, gc.Equals, uint64(1))
+	c.Assert(pass.Resumed, gc.Equals, false)
+
+	c.Assert(sc.Complete(pass, &Report{Status: CrawlInterrupted}), gc.IsNil)
+	c.Assert(flusher.flushes, gc.Equals, 1)
+
+	// After a restart, the interrupted pass is resumed with its original
+	// start time so that already crawled links are skipped.
+	sc = s.coordinator(c, ShutdownConfig{Partition: 2})
+	resumed, err := sc.NextPass()
+	c.Assert(err, gc.IsNil)
+	c.Assert(resumed.ID, gc.Equals, pass.ID)
+	c.Assert(resumed.StartedAt.Equal(pass.StartedAt), gc.Equals, true)
+	c.Assert(resumed.Resumed, gc.Equals, true)
+
+	c.Assert(sc.Complete(resumed, &Report{Status: BudgetNotExceeded}), gc.IsNil)
+	next, err := sc.NextPass()
+	c.Assert(err, gc.IsNil)
+	c.Assert(next.ID, gc.Equals, uint64(2))
+	c.Assert(next.Resumed, gc.Equals, false)
+
+	// Checkpoints are kept per partition.
+	other := s.coordinator(c, ShutdownConfig{Partition: 0})
+	pass, err = other.NextPass()
+	c.Assert(err, gc.IsNil)
+	c.Assert(pass.ID, gc.Equals, uint64(1))
+}
+
+func (s *ShutdownTestSuite) TestFlushFailureKeepsPassResumable(c *gc.C) {
+	flusher := &countingFlusher{err: errors.New("bulk request failed")}
+	sc := s.coordinator(c, ShutdownConfig{Flushers: []Flusher{flusher}})
+
+	pass, err := sc.NextPass()
+	c.Assert(err, gc.IsNil)
+	err = sc.Complete(pass, &Report{Status: BudgetNotExceeded})
+	c.Assert(err, gc.ErrorMatches, "(?s)complete pass:.*bulk request failed.*")
+
+	cp, err := s.g.Checkpoint(0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cp.Completed(), gc.Equals, false)
+}
+
+func (s *ShutdownTestSuite) TestBudgetExceededPassIsResumable(c *gc.C) {
+	flusher := new(countingFlusher)
+	sc := s.coordinator(c, ShutdownConfig{Flushers: []Flusher{flusher}})
+
+	pass, err := sc.NextPass()
+	c.Assert(err, gc.IsNil)
+	c.Assert(sc.Complete(pass, &Report{Status: BudgetBytesExceeded}), gc.IsNil)
+	c.Assert(flusher.flushes, gc.Equals, 1)
+
+	// The checkpoint of a pass that ran out of budget is finalized as
+	// interrupted so that the deferred links are crawled when the pass
+	// is resumed.
+	cp, err := s.g.Checkpoint(0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cp.Completed(), gc.Equals, false)
+
+	resumed, err := sc.NextPass()
+	c.Assert(err, gc.IsNil)
+	c.Assert(resumed.ID, gc.Equals, pass.ID)
+	c.Assert(resumed.Resumed, gc.Equals, true)
+}
+
+func (s *ShutdownTestSuite) TestDrainTimeout(c *gc.C) {
+	sc := s.coordinator(c, ShutdownConfig{DrainTimeout: 10 * time.Millisecond})
+	ctx, cancelFn := sc.Context(context.TODO())
+	defer cancelFn()
+
+	select {
+	case <-ctx.Done():
+		c.Fatal("context cancelled before a shutdown was requested")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sc.Shutdown()
+	sc.Shutdown() // Shutdown is idempotent
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		c.Fatal("timed out waiting for the drain timeout to cancel the context")
+	}
+}
+
+func (s *ShutdownTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewShutdownCoordinator(ShutdownConfig{Partition: -1, DrainTimeout: -time.Second})
+	c.Assert(err, gc.ErrorMatches, "(?s).*checkpoint store has not been provided.*invalid partition.*invalid drain timeout.*")
+}
+
+func (s *ShutdownTestSuite) coordinator(c *gc.C, cfg ShutdownConfig) *ShutdownCoordinator {
+	cfg.Checkpoints = s.g
+	sc, err := NewShutdownCoordinator(cfg)
+	c.Assert(err, gc.IsNil)
+	return sc
+}
+
+type countingFlusher struct {
+	flushes int
+	err     error
+}
+
+func (f *countingFlusher) Flush() error {
+	f.flushes++
+	return f.err
+}
@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"webcrawler/crawler/dedup"
+	"webcrawler/crawler/scope"
+	"webcrawler/logging"
+	"webcrawler/pipeline"
+	"webcrawler/urlutil/normalizer"
+)
+
+var _ pipeline.Processor = (*structuredAdapter)(nil)
+
+// structuredAdapter populates the title, content and links of payloads that
+// were fetched from a structured source by evaluating the source's JSONPath
+// expressions against the JSON response body.
+type structuredAdapter struct {
+	sources []StructuredSource
+
+	// Used for filtering the extracted links using the same rules as the
+	// links extracted from HTML pages.
+	linkFilter *linkExtractor
+
+	logger *slog.Logger
+}
+
+func newStructuredAdapter(netDetector PrivateNetworkDetector, sources []StructuredSource, rewriter *urlRewriter, urlNormalizer *normalizer.Normalizer, crawlScope *scope.Scope, logger *slog.Logger) *structuredAdapter {
+	return &structuredAdapter{
+		sources:    sources,
+		linkFilter: newLinkExtractor(netDetector, rewriter, urlNormalizer, crawlScope, false),
+		logger:     logging.Component(logger, "crawler.structured_adapter"),
+	}
+}
+
+func (sa *structuredAdapter) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	if payload.Format != formatStructured {
+		return payload, nil
+	}
+
+	src := matchStructuredSource(sa.sources, payload.URL)
+	base := payload.URL
+	if payload.BaseURL != "" {
+		base = payload.BaseURL
+	}
+	relTo, err := url.Parse(base)
+	if src == nil || err != nil {
+		sa.logger.Debug("skipping link without a matching structured source", logging.Link(payload.LinkID, payload.URL))
+		return nil, nil
+	}
+
+	// Skip payloads whose body is not valid JSON.
+	var doc interface{}
+	if err = json.Unmarshal(payload.RawContent.Bytes(), &doc); err != nil {
+		sa.logger.Warn("skipping structured response with invalid JSON body", logging.Link(payload.LinkID, payload.URL), "err", err)
+		return nil, nil
+	}
+
+	if src.Title != nil {
+		for _, val := range src.Title.Find(doc) {
+			if title, ok := scalarString(val); ok && title != "" {
+				payload.Title = title
+				break
+			}
+		}
+	}
+
+	var content []string
+	for _, path := range src.Content {
+		for _, val := range path.Find(doc) {
+			content = appendText(content, val)
+		}
+	}
+	payload.TextContent = strings.Join(content, " ")
+	payload.Fingerprint = dedup.Fingerprint(payload.TextContent)
+
+	if payload.NoFollow {
+		return payload, nil
+	}
+	seenMap := make(map[string]struct{})
+	for _, path := range src.Links {
+		for _, val := range path.Find(doc) {
+			target, ok := val.(string)
+			if !ok {
+				continue
+			}
+
+			if link, ok := sa.linkFilter.filterLink(relTo, target, seenMap); ok {
+				payload.Links = append(payload.Links, link)
+			}
+		}
+	}
+
+	return payload, nil
+}
+
+// matchStructuredSource returns the first source whose URL pattern matches
+// rawURL or nil if no source matches.
+func matchStructuredSource(sources []StructuredSource, rawURL string) *StructuredSource {
+	for i := range sources {
+		if sources[i].URLPattern != nil && sources[i].URLPattern.MatchString(rawURL) {
+			return &sources[i]
+		}
+	}
+	return nil
+}
+
+// scalarString returns the text representation of a scalar JSON value.
+func scalarString(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return strings.TrimSpace(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// appendText appends the text of val to out. Arrays and objects are
+// flattened with object members visited in sorted key order.
+func appendText(out []string, val interface{}) []string {
+	switch v := val.(type) {
+	case []interface{}:
+		for _, item := range v {
+			out = appendText(out, item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			out = appendText(out, v[key])
+		}
+	default:
+		if text, ok := scalarString(v); ok && text != "" {
+			out = append(out, text)
+		}
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package crawler
+
+import (
+	"context"
+	"regexp"
+
+	"webcrawler/crawler/jsonpath"
+	"webcrawler/crawler/mocks"
+
+	"github.com/golang/mock/gomock"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(StructuredAdapterTestSuite))
+
+type StructuredAdapterTestSuite struct {
+	privNetDetector *mocks.MockPrivateNetworkDetector
+}
+
+var testStructuredSources = []StructuredSource{
+	{
+		URLPattern: regexp.MustCompile(`^https?://api\.example\.com/books/`),
+		Title:      jsonpath.MustCompile("$.book.title"),
+		Content: []*jsonpath.Path{
+			jsonpath.MustCompile("$.book.summary"),
+			jsonpath.MustCompile("$.book.tags[*]"),
+		},
+		Links: []*jsonpath.Path{
+			jsonpath.MustCompile("$.related[*].href"),
+		},
+	},
+}
+
+func (s *StructuredAdapterTestSuite) TestExtractFields(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.privNetDetector = mocks.NewMockPrivateNetworkDetector(ctrl)
+	s.privNetDetector.EXPECT().IsPrivate("other.com").Return(false, nil)
+	s.privNetDetector.EXPECT().IsPrivate("169.254.169.254").Return(true, nil)
+
+	p := s.adapt(c, "http://api.example.com/books/1", `{
+	  "book": {"title": " Metamorphoses ", "summary": "An epic poem", "tags": ["latin", "poetry", 8]},
+	  "related": [
+	    {"href": "/books/2"},
+	    {"href": "/books/2#reviews"},
+	    {"href": "http://other.com/books/3"},
+	    {"href": "http://169.254.169.254/latest"},
+	    {"href": "/cover.png"},
+	    {"href": 42}
+	  ]
+	}`)
+	c.Assert(p, gc.NotNil)
+	c.Assert(p.Title, gc.Equals, "Metamorphoses")
+	c.Assert(p.TextContent, gc.Equals, "An epic poem latin poetry 8")
+	c.Assert(p.Links, gc.DeepEquals, []string{
+		"http://api.example.com/books/2",
+		"http://other.com/books/3",
+	})
+}
+
+func (s *StructuredAdapterTestSuite) TestInvalidJSON(c *gc.C) {
+	p := s.adapt(c, "http://api.example.com/books/1", `{"book": `)
+	c.Assert(p, gc.IsNil)
+}
+
+func (s *StructuredAdapterTestSuite) TestSkipNonStructuredPayloads(c *gc.C) {
+	payload := &crawlerPayload{URL: "http://api.example.com/books/1"}
+	payload.RawContent.WriteString(`<html><title>foo</title></html>`)
+
+	out, err := newStructuredAdapter(nil, testStructuredSources, nil, nil, nil, nil).Process(context.TODO(), payload)
+	c.Assert(err, gc.IsNil)
+	c.Assert(out, gc.Equals, payload)
+	c.Assert(payload.Title, gc.Equals, "")
+}
+
+func (s *StructuredAdapterTestSuite) adapt(c *gc.C, url, body string) *crawlerPayload {
+	payload := &crawlerPayload{URL: url, Format: formatStructured}
+	payload.RawContent.WriteString(body)
+
+	out, err := newStructuredAdapter(s.privNetDetector, testStructuredSources, nil, nil, nil, nil).Process(context.TODO(), payload)
+	c.Assert(err, gc.IsNil)
+	if out != nil {
+		c.Assert(out, gc.FitsTypeOf, payload)
+		return out.(*crawlerPayload)
+	}
+
+	return nil
+}
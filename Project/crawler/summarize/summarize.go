@@ -0,0 +1,185 @@
+// Package summarize implements extractive text summarization. Summaries are
+// built by ranking the sentences of a text with the TextRank algorithm and
+// selecting the highest ranked sentences in their original order.
+package summarize
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// The damping factor and the convergence threshold for the sentence
+	// ranking iterations.
+	dampingFactor = 0.85
+	epsilon       = 1e-6
+	maxIterations = 100
+
+	// Only the first maxSentences sentences of a text are considered as
+	// the cost of ranking grows quadratically with the sentence count.
+	maxSentences = 200
+
+	// Sentences with fewer words are usually navigation or boilerplate
+	// fragments and are never included in a summary.
+	minSentenceWords = 4
+)
+
+// sentence describes a candidate sentence for inclusion in a summary.
+type sentence struct {
+	text  string
+	words map[string]struct{}
+	pos   int
+	score float64
+}
+
+// Summarize returns a summary of text consisting of at most numSentences of
+// its sentences. The selected sentences are returned in the order they
+// appear in text, separated by a single space. An empty string is returned
+// if text does not contain any sentences that are long enough.
+func Summarize(text string, numSentences int) string {
+	if numSentences <= 0 {
+		return ""
+	}
+
+	sentences := splitSentences(text)
+	if len(sentences) > numSentences {
+		rank(sentences)
+		sort.SliceStable(sentences, func(i, j int) bool { return sentences[i].score > sentences[j].score })
+		sentences = sentences[:numSentences]
+		sort.Slice(sentences, func(i, j int) bool { return sentences[i].pos < sentences[j].pos })
+	}
+
+	parts := make([]string, len(sentences))
+	for i, s := range sentences {
+		parts[i] = s.text
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitSentences splits text into sentences. A sentence ends with one or
+// more terminal punctuation marks that are followed by whitespace or the end
+// of the text.
+func splitSentences(text string) []*sentence {
+	var (
+		sentences []*sentence
+		runes     = []rune(strings.Join(strings.Fields(text), " "))
+		start     int
+	)
+	for i := 0; i < len(runes) && len(sentences) < maxSentences; i++ {
+		if i+1 < len(runes) && (!isTerminal(runes[i]) || !unicode.IsSpace(runes[i+1])) {
+			continue
+		}
+
+		if s := newSentence(string(runes[start:i+1]), len(sentences)); s != nil {
+			sentences = append(sentences, s)
+		}
+		start = i + 1
+	}
+	return sentences
+}
+
+func isTerminal(r rune) bool {
+	return r == '.' || r == '!' || r == '?'
+}
+
+// newSentence returns a sentence for text or nil if text has too few words.
+func newSentence(text string, pos int) *sentence {
+	text = strings.TrimSpace(text)
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(fields) < minSentenceWords {
+		return nil
+	}
+
+	words := make(map[string]struct{}, len(fields))
+	for _, w := range fields {
+		if _, isStopWord := stopWords[w]; !isStopWord {
+			words[w] = struct{}{}
+		}
+	}
+	return &sentence{text: text, words: words, pos: pos}
+}
+
+// rank assigns a TextRank score to each sentence. Sentences are vertices of
+// a graph whose edges are weighted by the similarity of the sentences they
+// connect; the score of each sentence is its weighted PageRank.
+func rank(sentences []*sentence) {
+	n := len(sentences)
+	weights := make([][]float64, n)
+	outWeights := make([]float64, n)
+	for i := range sentences {
+		weights[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			w := similarity(sentences[i], sentences[j])
+			weights[i][j], weights[j][i] = w, w
+			outWeights[i] += w
+			outWeights[j] += w
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for iter := 0; iter < maxIterations; iter++ {
+		var delta float64
+		for i := 0; i < n; i++ {
+			var sum float64
+			for j := 0; j < n; j++ {
+				if weights[j][i] != 0 {
+					sum += weights[j][i] / outWeights[j] * scores[j]
+				}
+			}
+			next[i] = (1-dampingFactor)/float64(n) + dampingFactor*sum
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores, next = next, scores
+		if delta < epsilon {
+			break
+		}
+	}
+
+	for i, s := range sentences {
+		s.score = scores[i]
+	}
+}
+
+// similarity returns the number of words shared by a and b normalized by the
+// logarithm of the sentence lengths.
+func similarity(a, b *sentence) float64 {
+	if len(a.words) > len(b.words) {
+		a, b = b, a
+	}
+
+	var overlap int
+	for w := range a.words {
+		if _, found := b.words[w]; found {
+			overlap++
+		}
+	}
+	if overlap == 0 {
+		return 0
+	}
+	return float64(overlap) / (math.Log(float64(len(a.words)+1)) + math.Log(float64(len(b.words)+1)))
+}
+
+// stopWords lists common English words that are ignored when comparing
+// sentences.
+var stopWords = func() map[string]struct{} {
+	list := strings.Fields(`
+		a an and are as at be been but by for from has have he her his i if in
+		into is it its of on or our she so than that the their them then there
+		these they this to was we were what when which who will with you your
+	`)
+	words := make(map[string]struct{}, len(list))
+	for _, w := range list {
+		words[w] = struct{}{}
+	}
+	return words
+}()
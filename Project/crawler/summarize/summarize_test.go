@@ -0,0 +1,52 @@
+package summarize
+
+import (
+	"strings"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(SummarizeTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type SummarizeTestSuite struct{}
+
+func (s *SummarizeTestSuite) TestSplitSentences(c *gc.C) {
+	sentences := splitSentences("Home. The price rose by 3.5 percent today! Did the\n market   react to the news? Yes it did react strongly")
+
+	var got []string
+	for _, s := range sentences {
+		got = append(got, s.text)
+	}
+	c.Assert(got, gc.DeepEquals, []string{
+		"The price rose by 3.5 percent today!",
+		"Did the market react to the news?",
+		"Yes it did react strongly",
+	})
+}
+
+func (s *SummarizeTestSuite) TestSummarize(c *gc.C) {
+	text := strings.Join([]string{
+		"Subscribe to our newsletter for weekly updates.",
+		"The crawler fetches web pages and extracts their links.",
+		"Extracted links are added to the link graph for future crawling.",
+		"The link graph is used to compute the PageRank of each web page.",
+		"Our office cat enjoys sleeping on keyboards.",
+		"Pages with a higher PageRank are ranked higher in search results.",
+	}, " ")
+
+	// The selected sentences must be on-topic and appear in their
+	// original order.
+	got := Summarize(text, 2)
+	c.Assert(got, gc.Equals, "The crawler fetches web pages and extracts their links. The link graph is used to compute the PageRank of each web page.")
+	for _, offTopic := range []string{"newsletter", "cat"} {
+		c.Assert(strings.Contains(got, offTopic), gc.Equals, false)
+	}
+
+	// Texts with fewer sentences than requested are returned as is.
+	c.Assert(Summarize("Only one sentence is here.", 3), gc.Equals, "Only one sentence is here.")
+	c.Assert(Summarize("Too short.", 3), gc.Equals, "")
+	c.Assert(Summarize(text, 0), gc.Equals, "")
+}
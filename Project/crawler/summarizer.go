@@ -0,0 +1,25 @@
+package crawler
+
+import (
+	"context"
+	"webcrawler/crawler/summarize"
+	"webcrawler/pipeline"
+)
+
+var _ pipeline.Processor = (*summarizer)(nil)
+
+// summarizer generates an extractive summary of the text content of each
+// payload.
+type summarizer struct {
+	numSentences int
+}
+
+func newSummarizer(numSentences int) *summarizer {
+	return &summarizer{numSentences: numSentences}
+}
+
+func (s *summarizer) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	payload.Summary = summarize.Summarize(payload.TextContent, s.numSentences)
+	return payload, nil
+}
@@ -0,0 +1,20 @@
+package crawler
+
+import (
+	"context"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(SummarizerTestSuite))
+
+type SummarizerTestSuite struct{}
+
+func (s *SummarizerTestSuite) TestSummarizer(c *gc.C) {
+	p := &crawlerPayload{
+		TextContent: "Menu. The crawler fetches web pages. The crawler indexes web pages. Cats sleep all day long.",
+	}
+	out, err := newSummarizer(1).Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	c.Assert(out.(*crawlerPayload).Summary, gc.Equals, "The crawler fetches web pages.")
+}
@@ -1,11 +1,18 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
 	"html"
+	"log/slog"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
+	"webcrawler/crawler/dedup"
+	"webcrawler/crawler/extract"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
 	"webcrawler/pipeline"
 
 	"github.com/microcosm-cc/bluemonday"
@@ -13,37 +20,128 @@ import (
 
 var (
 	titleRegex         = regexp.MustCompile(`(?i)<title.*?>(.*?)</title>`)
+	htmlLangRegex      = regexp.MustCompile(`(?i)<html[^>]*?\slang\s*=\s*["']?([a-zA-Z]{2,3})`)
 	repeatedSpaceRegex = regexp.MustCompile(`\s+`)
+
+	// Markup that identifies news articles: the Open Graph publication
+	// time and schema.org NewsArticle types (including subtypes such as
+	// ReportageNewsArticle) declared via JSON-LD.
+	articleTimeRegex = regexp.MustCompile(`(?i)<meta[^>]+["']article:published_time["']`)
+	newsSchemaRegex  = regexp.MustCompile(`"@type"\s*:\s*(?:\[[^\]]*)?"[A-Za-z]*NewsArticle"`)
 )
 
+// ExtractedContent holds the fields that the crawler extracts from an HTML
+// page.
+type ExtractedContent struct {
+	Title       string
+	Language    string
+	Content     string
+	Author      string
+	PublishedAt time.Time
+
+	// The search vertical of the page: news for news articles and empty
+	// for all other pages.
+	Vertical string
+
+	// The extraction profile that was applied to the page or nil if the
+	// page was only processed by the generic extractor.
+	Profile *extract.Profile
+}
+
+// ExtractContent extracts the content of the HTML page served from rawURL in
+// the same way as the crawler pipeline: the selectors of the first matching
+// profile take precedence and the generic extractor populates the title and
+// content if the profile does not select them. It is intended for previewing
+// the effect of extraction profiles on a sample page.
+//
+// If the matching profile cannot be applied, the result of the generic
+// extractor is returned along with the error.
+func ExtractContent(rawURL string, content []byte, profiles []extract.Profile) (ExtractedContent, error) {
+	return newTextExtractor(profiles, nil).extract(rawURL, content)
+}
+
 type textExtractor struct {
 	policyPool sync.Pool
+	profiles   []extract.Profile
+	logger     *slog.Logger
 }
 
-func newTextExtractor() *textExtractor {
+func newTextExtractor(profiles []extract.Profile, logger *slog.Logger) *textExtractor {
 	return &textExtractor{
 		policyPool: sync.Pool{
 			New: func() interface{} {
 				return bluemonday.StrictPolicy()
 			},
 		},
+		profiles: profiles,
+		logger:   logging.Component(logger, "crawler.text_extractor"),
 	}
 }
 
 func (te *textExtractor) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
 	payload := p.(*crawlerPayload)
+	if payload.Format != formatHTML {
+		return payload, nil
+	}
+
+	res, err := te.extract(payload.URL, payload.RawContent.Bytes())
+	if err != nil {
+		te.logger.Warn("unable to apply extraction profile; using the generic extractor", logging.Link(payload.LinkID, payload.URL), "err", err)
+	}
+	payload.Title = res.Title
+	payload.Language = res.Language
+	payload.TextContent = res.Content
+	payload.Author = res.Author
+	payload.PublishedAt = res.PublishedAt
+	payload.Vertical = res.Vertical
+	payload.Fingerprint = dedup.Fingerprint(payload.TextContent)
+
+	return payload, nil
+}
+
+func (te *textExtractor) extract(rawURL string, content []byte) (ExtractedContent, error) {
+	var (
+		res ExtractedContent
+		err error
+	)
+	if profile := extract.Match(te.profiles, rawURL); profile != nil {
+		var profileRes extract.Result
+		if profileRes, err = profile.Extract(bytes.NewReader(content)); err == nil {
+			res = ExtractedContent{
+				Title:       profileRes.Title,
+				Content:     profileRes.Body,
+				Author:      profileRes.Author,
+				PublishedAt: profileRes.Date,
+				Profile:     profile,
+			}
+		}
+	}
+
 	policy := te.policyPool.Get().(*bluemonday.Policy)
+	defer te.policyPool.Put(policy)
 
-	if titleMatch := titleRegex.FindStringSubmatch(payload.RawContent.String()); len(titleMatch) == 2 {
-		payload.Title = strings.TrimSpace(html.UnescapeString(repeatedSpaceRegex.ReplaceAllString(
-			policy.Sanitize(titleMatch[1]), " ",
+	if titleMatch := titleRegex.FindSubmatch(content); res.Title == "" && len(titleMatch) == 2 {
+		res.Title = strings.TrimSpace(html.UnescapeString(repeatedSpaceRegex.ReplaceAllString(
+			policy.Sanitize(string(titleMatch[1])), " ",
 		)))
 	}
 
-	payload.TextContent = strings.TrimSpace(html.UnescapeString(repeatedSpaceRegex.ReplaceAllString(
-		policy.SanitizeReader(&payload.RawContent).String(), " ",
-	)))
-	te.policyPool.Put(policy)
+	// Use the primary subtag of the document's lang attribute (e.g. "en"
+	// for "en-US") as its language.
+	if langMatch := htmlLangRegex.FindSubmatch(content); len(langMatch) == 2 {
+		res.Language = strings.ToLower(string(langMatch[1]))
+	}
 
-	return payload, nil
+	if res.Content == "" {
+		res.Content = strings.TrimSpace(html.UnescapeString(repeatedSpaceRegex.ReplaceAllString(
+			string(policy.SanitizeBytes(content)), " ",
+		)))
+	}
+
+	// Pages are treated as news articles if they carry article markup or
+	// their extraction profile selects a publication date.
+	if !res.PublishedAt.IsZero() || articleTimeRegex.Match(content) || newsSchemaRegex.Match(content) {
+		res.Vertical = index.VerticalNews
+	}
+	return res, err
 }
@@ -2,6 +2,9 @@ package crawler
 
 import (
 	"context"
+	"time"
+	"webcrawler/crawler/extract"
+	"webcrawler/crawler/textindexer/index"
 
 	gc "gopkg.in/check.v1"
 )
@@ -30,15 +33,99 @@ func (s *ContentExtractorTestSuite) TestContentExtractorWithTitle(c *gc.C) {
 	assertExtractedContent(c, content, "Test title", `Some content`)
 }
 
-func assertExtractedContent(c *gc.C, content, expTitle, expText string) {
+func (s *ContentExtractorTestSuite) TestContentExtractorWithLanguage(c *gc.C) {
+	content := `<html class="no-js" lang="en-US">
+<body>
+<div>Some<span> content</span></div>
+</body>
+</html>
+`
+	p := assertExtractedContent(c, content, "", `Some content`)
+	c.Assert(p.Language, gc.Equals, "en")
+}
+
+func (s *ContentExtractorTestSuite) TestContentExtractorWithProfile(c *gc.C) {
+	content := `<html lang="en">
+<head><title>Generic title</title></head>
+<body>
+<nav>Home | About</nav>
+<article>
+<h1>Headline</h1>
+<span class="author">Jane Doe</span>
+<time datetime="2024-03-05">March 5</time>
+<div class="story"><p>Story text.</p></div>
+</article>
+</body>
+</html>
+`
+	profiles := []extract.Profile{
+		{Domain: "news.example.com", Title: extract.MustCompile("h1")},
+		{
+			Domain: "example.com",
+			Title:  extract.MustCompile("h1.missing"),
+			Body:   extract.MustCompile(".story"),
+			Author: extract.MustCompile(".author"),
+			Date:   extract.MustCompile("article time"),
+		},
+	}
+
+	p := new(crawlerPayload)
+	p.URL = "https://www.example.com/story"
+	_, err := p.RawContent.WriteString(content)
+	c.Assert(err, gc.IsNil)
+
+	_, err = newTextExtractor(profiles, nil).Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+
+	// The generic extractor populates the fields that the profile does
+	// not select.
+	c.Assert(p.Title, gc.Equals, "Generic title")
+	c.Assert(p.Language, gc.Equals, "en")
+	c.Assert(p.TextContent, gc.Equals, "Story text.")
+	c.Assert(p.Author, gc.Equals, "Jane Doe")
+	c.Assert(p.PublishedAt, gc.Equals, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC))
+	c.Assert(p.Vertical, gc.Equals, index.VerticalNews)
+
+	// Pages of other hosts are only processed by the generic extractor.
+	res, err := ExtractContent("https://other.com/story", []byte(content), profiles)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Profile, gc.IsNil)
+	c.Assert(res.Author, gc.Equals, "")
+	c.Assert(res.Content, gc.Equals, "Home | About Headline Jane Doe March 5 Story text.")
+
+	res, err = ExtractContent("https://news.example.com/story", []byte(content), profiles)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Profile, gc.Equals, &profiles[0])
+	c.Assert(res.Title, gc.Equals, "Headline")
+}
+
+func (s *ContentExtractorTestSuite) TestContentExtractorDetectsNewsArticles(c *gc.C) {
+	specs := []struct {
+		head        string
+		expVertical string
+	}{
+		{head: `<meta property="og:type" content="website">`, expVertical: ""},
+		{head: `<meta property="article:published_time" content="2024-03-05T08:00:00Z">`, expVertical: index.VerticalNews},
+		{head: `<script type="application/ld+json">{"@context": "https://schema.org", "@type": "NewsArticle"}</script>`, expVertical: index.VerticalNews},
+		{head: `<script type="application/ld+json">{"@type": ["Thing", "ReportageNewsArticle"]}</script>`, expVertical: index.VerticalNews},
+		{head: `<script type="application/ld+json">{"@type": "Article"}</script>`, expVertical: ""},
+	}
+	for specIndex, spec := range specs {
+		p := assertExtractedContent(c, "<html><head>"+spec.head+"</head><body><p>Text</p></body></html>", "", "Text")
+		c.Assert(p.Vertical, gc.Equals, spec.expVertical, gc.Commentf("spec %d", specIndex))
+	}
+}
+
+func assertExtractedContent(c *gc.C, content, expTitle, expText string) *crawlerPayload {
 	p := new(crawlerPayload)
 	_, err := p.RawContent.WriteString(content)
 	c.Assert(err, gc.IsNil)
 
-	ret, err := newTextExtractor().Process(context.TODO(), p)
+	ret, err := newTextExtractor(nil, nil).Process(context.TODO(), p)
 	c.Assert(err, gc.IsNil)
 	c.Assert(ret, gc.DeepEquals, p)
 
 	c.Assert(p.Title, gc.Equals, expTitle)
 	c.Assert(p.TextContent, gc.Equals, expText)
+	return p
 }
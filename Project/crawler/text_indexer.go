@@ -2,35 +2,115 @@ package crawler
 
 import (
 	"context"
+	"log/slog"
 	"time"
+	"webcrawler/crawler/errstore"
+	"webcrawler/logging"
+	"webcrawler/metrics"
 	"webcrawler/pipeline"
+	"webcrawler/tracing"
 
 	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
 )
 
 type textIndexer struct {
 	indexer Indexer
+	errs    *errorReporter
+	logger  *slog.Logger
 }
 
-func newTextIndexer(indexer Indexer) *textIndexer {
+func newTextIndexer(indexer Indexer, errs *errorReporter, logger *slog.Logger) *textIndexer {
 	return &textIndexer{
 		indexer: indexer,
+		errs:    errs,
+		logger:  logging.Component(logger, "crawler.text_indexer"),
 	}
 }
 
 func (i *textIndexer) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
 	payload := p.(*crawlerPayload)
 
-	doc := &index.Document{
-		LinkID:    payload.LinkID,
-		URL:       payload.URL,
-		Title:     payload.Title,
-		Content:   payload.TextContent,
-		IndexedAt: time.Now(),
+	// Pages that declare a different canonical URL duplicate the content
+	// of the canonical page which is indexed when it gets crawled.
+	if payload.CanonicalURL != "" {
+		i.logger.Debug("skipping indexing of non-canonical page", logging.Link(payload.LinkID, payload.URL), "canonical_url", payload.CanonicalURL)
+		return p, nil
+	}
+	if payload.NoIndex {
+		i.logger.Debug("skipping indexing of noindex page", logging.Link(payload.LinkID, payload.URL))
+		return p, nil
 	}
-	if err := i.indexer.Index(doc); err != nil {
+
+	// Both the indexing time and the change time of the document are set
+	// from the same timestamp which indexers store as is.
+	now := time.Now().UTC().Round(0)
+	doc := indexDocument(payload, now)
+
+	// Pages whose content did not change since they were last crawled are
+	// not re-indexed; the graph updater still records their retrieval
+	// along with the hash of their content.
+	doc.ContentHash = index.ContentHash(doc)
+	if payload.PrevContentHash != 0 && payload.PrevContentHash == doc.ContentHash {
+		metrics.UnchangedDocuments.Inc()
+		i.logger.Debug("skipping indexing of unchanged page", logging.Link(payload.LinkID, payload.URL))
+		return p, nil
+	}
+	doc.ChangedAt = now
+
+	if err := tracing.Do(ctx, tracer, "textindexer.Index", func() error { return i.indexer.Index(doc) }); err != nil {
+		i.logger.Error("unable to index document", logging.Link(payload.LinkID, payload.URL), "err", err)
+		i.errs.report(textIndexerStage, payload, errstore.ClassIndex, err)
 		return nil, err
 	}
+	if !payload.FetchedAt.IsZero() {
+		metrics.ObserveSince(metrics.IndexLag, payload.FetchedAt)
+	}
 
 	return p, nil
 }
+
+// indexable returns true if the text indexer indexes payload; pages that
+// declare a different canonical URL or opt out of indexing are skipped.
+func indexable(payload *crawlerPayload) bool {
+	return payload.CanonicalURL == "" && !payload.NoIndex
+}
+
+// indexDocument returns the document that is indexed for payload. The graph
+// updater hashes the same document so that both stages agree on whether the
+// content of a page changed.
+func indexDocument(payload *crawlerPayload, indexedAt time.Time) *index.Document {
+	// Near-duplicates are indexed as a reference to the canonical page
+	// so that they do not show up in search results.
+	if payload.DuplicateOf != uuid.Nil {
+		return &index.Document{
+			LinkID:      payload.LinkID,
+			URL:         payload.URL,
+			IndexedAt:   indexedAt,
+			Fingerprint: payload.Fingerprint,
+			DuplicateOf: payload.DuplicateOf,
+		}
+	}
+
+	return &index.Document{
+		LinkID:    payload.LinkID,
+		URL:       payload.URL,
+		Title:     payload.Title,
+		Content:   payload.TextContent,
+		Summary:   payload.Summary,
+		Language:  payload.Language,
+		Vertical:  payload.Vertical,
+		IndexedAt: indexedAt,
+
+		Author:      payload.Author,
+		PublishedAt: payload.PublishedAt,
+
+		FaviconRef:   payload.FaviconRef,
+		ThumbnailRef: payload.ThumbnailRef,
+
+		Headers: payload.Headers,
+
+		Fingerprint: payload.Fingerprint,
+	}
+}
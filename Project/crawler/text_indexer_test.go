@@ -44,8 +44,122 @@ func (s *TextIndexerTestSuite) TestTextIndexer(c *gc.C) {
 	c.Assert(p, gc.Not(gc.IsNil))
 }
 
+func (s *TextIndexerTestSuite) TestTextIndexerSetsVertical(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.indexer = mocks.NewMockIndexer(ctrl)
+
+	payload := &crawlerPayload{
+		LinkID:      uuid.New(),
+		URL:         "http://example.com/report.pdf",
+		TextContent: "Lorem ipsum dolor",
+		Vertical:    index.VerticalDocuments,
+	}
+
+	s.indexer.EXPECT().Index(gomock.Any()).DoAndReturn(func(doc *index.Document) error {
+		c.Assert(doc.Vertical, gc.Equals, index.VerticalDocuments)
+		return nil
+	})
+
+	p := s.updateIndex(c, payload)
+	c.Assert(p, gc.Not(gc.IsNil))
+}
+
+func (s *TextIndexerTestSuite) TestTextIndexerSkipsNoIndexPages(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.indexer = mocks.NewMockIndexer(ctrl)
+
+	// No calls to Index are expected.
+	p := s.updateIndex(c, &crawlerPayload{
+		LinkID:  uuid.New(),
+		URL:     "http://example.com/private",
+		Title:   "some title",
+		NoIndex: true,
+	})
+	c.Assert(p, gc.Not(gc.IsNil))
+}
+
+func (s *TextIndexerTestSuite) TestTextIndexerSkipsNonCanonicalPages(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.indexer = mocks.NewMockIndexer(ctrl)
+
+	// No calls to Index are expected.
+	p := s.updateIndex(c, &crawlerPayload{
+		LinkID:       uuid.New(),
+		URL:          "http://example.com/?page=1",
+		CanonicalURL: "http://example.com/",
+		Title:        "some title",
+	})
+	c.Assert(p, gc.Not(gc.IsNil))
+}
+
+func (s *TextIndexerTestSuite) TestTextIndexerIndexesReferenceForDuplicates(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.indexer = mocks.NewMockIndexer(ctrl)
+
+	payload := &crawlerPayload{
+		LinkID:      uuid.New(),
+		URL:         "http://mirror.com/",
+		Title:       "some title",
+		TextContent: "Lorem ipsum dolor",
+		Fingerprint: 42,
+		DuplicateOf: uuid.New(),
+	}
+
+	s.indexer.EXPECT().Index(gomock.Any()).DoAndReturn(func(doc *index.Document) error {
+		c.Assert(doc.LinkID, gc.Equals, payload.LinkID)
+		c.Assert(doc.URL, gc.Equals, payload.URL)
+		c.Assert(doc.Title, gc.Equals, "")
+		c.Assert(doc.Content, gc.Equals, "")
+		c.Assert(doc.Fingerprint, gc.Equals, uint64(42))
+		c.Assert(doc.DuplicateOf, gc.Equals, payload.DuplicateOf)
+		return nil
+	})
+
+	p := s.updateIndex(c, payload)
+	c.Assert(p, gc.Not(gc.IsNil))
+}
+
+func (s *TextIndexerTestSuite) TestTextIndexerSkipsUnchangedDocuments(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	s.indexer = mocks.NewMockIndexer(ctrl)
+
+	payload := &crawlerPayload{
+		LinkID:      uuid.New(),
+		URL:         "http://example.com",
+		Title:       "some title",
+		TextContent: "Lorem ipsum dolor",
+	}
+
+	var indexed *index.Document
+	s.indexer.EXPECT().Index(gomock.Any()).DoAndReturn(func(doc *index.Document) error {
+		c.Assert(doc.ContentHash, gc.Not(gc.Equals), uint64(0))
+		c.Assert(doc.ChangedAt, gc.Equals, doc.IndexedAt)
+		indexed = doc
+		return nil
+	})
+	c.Assert(s.updateIndex(c, payload), gc.Not(gc.IsNil))
+
+	// Re-crawling the unchanged page does not re-index it.
+	payload.PrevContentHash = indexed.ContentHash
+	c.Assert(s.updateIndex(c, payload), gc.Not(gc.IsNil))
+
+	// Changed pages are re-indexed.
+	payload.TextContent = "Lorem ipsum dolor sit amet"
+	s.indexer.EXPECT().Index(gomock.Any()).DoAndReturn(func(doc *index.Document) error {
+		c.Assert(doc.ContentHash, gc.Not(gc.Equals), indexed.ContentHash)
+		c.Assert(doc.ChangedAt, gc.Equals, doc.IndexedAt)
+		return nil
+	})
+	c.Assert(s.updateIndex(c, payload), gc.Not(gc.IsNil))
+}
+
 func (s *TextIndexerTestSuite) updateIndex(c *gc.C, p *crawlerPayload) *crawlerPayload {
-	out, err := newTextIndexer(s.indexer).Process(context.TODO(), p)
+	out, err := newTextIndexer(s.indexer, nil, nil).Process(context.TODO(), p)
 	c.Assert(err, gc.IsNil)
 	if out != nil {
 		c.Assert(out, gc.FitsTypeOf, p)
@@ -0,0 +1,65 @@
+// Package backup implements scheduled backups of the text indexer contents.
+//
+// A Backend creates, lists, deletes and restores backups of a single index.
+// Two implementations are available: BlobBackend, which dumps the documents
+// of any indexer to a blob store, and the snapshot backend provided by the
+// elasticsearch store which relies on an ES snapshot repository.
+//
+// The Scheduler periodically creates a backup, applies a retention policy to
+// the existing backups and verifies the newest backup by restoring it to a
+// scratch index and running a set of smoke queries against it.
+package backup
+
+import (
+	"errors"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+)
+
+// ErrNotFound is returned when attempting to access a backup that does not
+// exist.
+var ErrNotFound = errors.New("backup not found")
+
+// Info describes a backup.
+type Info struct {
+	// The unique name of the backup.
+	Name string `json:"name"`
+
+	// The time when the backup was created.
+	CreatedAt time.Time `json:"createdAt"`
+
+	// The number of documents in the index when the backup was created.
+	Documents int64 `json:"documents"`
+}
+
+// RestoredIndex is a scratch index that holds the contents of a restored
+// backup.
+type RestoredIndex interface {
+	// Search the index for a particular query and return back a result
+	// iterator.
+	Search(query index.Query) (index.Iterator, error)
+
+	// All returns an iterator over every document in the index.
+	All(cursor index.Cursor) (index.DocumentIterator, error)
+
+	// Close drops the scratch index and releases any allocated resources.
+	Close() error
+}
+
+// Backend is implemented by objects that can manage the backups of an index.
+type Backend interface {
+	// Create backs up the current contents of the index under name.
+	Create(name string) (*Info, error)
+
+	// List returns the available backups ordered by creation time, oldest
+	// first.
+	List() ([]*Info, error)
+
+	// Delete removes the backup with the specified name.
+	Delete(name string) error
+
+	// Restore restores the backup with the specified name to a scratch
+	// index. Callers must close the returned index when they are done
+	// with it.
+	Restore(name string) (RestoredIndex, error)
+}
@@ -0,0 +1,177 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"webcrawler/crawler/blobstore"
+	"webcrawler/crawler/textindexer/index"
+	memidx "webcrawler/crawler/textindexer/store/memory"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(BackupTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type BackupTestSuite struct {
+	idx     *memidx.InMemoryBleveIndexer
+	store   *blobstore.InMemoryStore
+	backend *BlobBackend
+	now     time.Time
+}
+
+func (s *BackupTestSuite) SetUpTest(c *gc.C) {
+	var err error
+	s.idx, err = memidx.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	for i := 0; i < 3; i++ {
+		c.Assert(s.idx.Index(&index.Document{
+			LinkID:  uuid.New(),
+			URL:     fmt.Sprintf("http://example.com/%d", i),
+			Title:   fmt.Sprintf("Ovidius poeta %d", i),
+			Content: "Ovidius poeta in terra pontica",
+		}), gc.IsNil)
+	}
+
+	s.now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.store = blobstore.NewInMemoryStore()
+	s.backend, err = NewBlobBackend(BlobConfig{
+		Index: s.idx,
+		Store: s.store,
+		Clock: s.clock,
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *BackupTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+}
+
+func (s *BackupTestSuite) clock() time.Time { return s.now }
+
+func (s *BackupTestSuite) TestBlobBackendRoundTrip(c *gc.C) {
+	info, err := s.backend.Create("first")
+	c.Assert(err, gc.IsNil)
+	c.Assert(info, gc.DeepEquals, &Info{Name: "first", CreatedAt: s.now, Documents: 3})
+
+	_, err = s.backend.Create("first")
+	c.Assert(err, gc.ErrorMatches, `create backup: backup "first" already exists`)
+
+	infos, err := s.backend.List()
+	c.Assert(err, gc.IsNil)
+	c.Assert(infos, gc.DeepEquals, []*Info{info})
+
+	restored, err := s.backend.Restore("first")
+	c.Assert(err, gc.IsNil)
+	it, err := restored.Search(index.Query{Type: index.QueryTypeMatch, Expression: "pontica"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.TotalCount(), gc.Equals, uint64(3))
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(restored.Close(), gc.IsNil)
+
+	c.Assert(s.backend.Delete("first"), gc.IsNil)
+	_, err = s.store.Get("backups/first.jsonl.gz")
+	c.Assert(errors.Is(err, blobstore.ErrNotFound), gc.Equals, true)
+	c.Assert(errors.Is(s.backend.Delete("first"), ErrNotFound), gc.Equals, true)
+	_, err = s.backend.Restore("first")
+	c.Assert(errors.Is(err, ErrNotFound), gc.Equals, true)
+}
+
+func (s *BackupTestSuite) TestRetention(c *gc.C) {
+	var infos []*Info
+	for i := 0; i < 5; i++ {
+		infos = append(infos, &Info{Name: fmt.Sprint(i), CreatedAt: s.now.Add(time.Duration(i-4) * 24 * time.Hour)})
+	}
+
+	specs := []struct {
+		policy Retention
+		exp    []string
+	}{
+		{policy: Retention{KeepLast: 3}, exp: []string{"0", "1"}},
+		{policy: Retention{MaxAge: 36 * time.Hour}, exp: []string{"0", "1", "2"}},
+		{policy: Retention{KeepLast: 4, MaxAge: 60 * time.Hour}, exp: []string{"0", "1"}},
+		// The newest backup is always kept.
+		{policy: Retention{MaxAge: time.Minute}, exp: []string{"0", "1", "2", "3"}},
+		{policy: Retention{}, exp: nil},
+	}
+	for specIndex, spec := range specs {
+		var got []string
+		for _, info := range expired(infos, spec.policy, s.now) {
+			got = append(got, info.Name)
+		}
+		c.Assert(got, gc.DeepEquals, spec.exp, gc.Commentf("spec %d", specIndex))
+	}
+}
+
+func (s *BackupTestSuite) TestScheduledJobs(c *gc.C) {
+	sched, err := NewScheduler(Config{
+		Backend:      s.backend,
+		Retention:    Retention{KeepLast: 2},
+		SmokeQueries: []string{"terra pontica"},
+		Clock:        s.clock,
+	})
+	c.Assert(err, gc.IsNil)
+
+	for i := 0; i < 3; i++ {
+		sched.runJobs()
+		s.now = s.now.Add(24 * time.Hour)
+	}
+
+	status, err := sched.Status()
+	c.Assert(err, gc.IsNil)
+	c.Assert(status.Backups, gc.HasLen, 2)
+	c.Assert(status.Backups[0].Name, gc.Equals, "backup-20240302-120000")
+	c.Assert(status.Backups[1].Name, gc.Equals, "backup-20240303-120000")
+	c.Assert(status.LastBackup.Backup, gc.Equals, "backup-20240303-120000")
+	c.Assert(status.LastBackup.Error, gc.Equals, "")
+
+	v := status.LastVerification
+	c.Assert(v.Backup, gc.Equals, "backup-20240303-120000")
+	c.Assert(v.Passed, gc.Equals, true, gc.Commentf("error: %s", v.Error))
+	c.Assert(v.Documents, gc.Equals, int64(3))
+	c.Assert(v.Queries, gc.HasLen, 4)
+	c.Assert(v.Queries[0], gc.DeepEquals, QueryResult{Query: "terra pontica", Results: 3})
+
+	// The next backup is due one interval after the newest backup.
+	s.now = time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	c.Assert(sched.untilNextBackup(), gc.Equals, 12*time.Hour)
+}
+
+func (s *BackupTestSuite) TestVerificationFailures(c *gc.C) {
+	sched, err := NewScheduler(Config{
+		Backend:      s.backend,
+		SmokeQueries: []string{"nonexistent"},
+		Clock:        s.clock,
+	})
+	c.Assert(err, gc.IsNil)
+
+	_, err = sched.Verify("")
+	c.Assert(errors.Is(err, ErrNotFound), gc.Equals, true)
+
+	info, err := sched.Backup()
+	c.Assert(err, gc.IsNil)
+	v, err := sched.Verify(info.Name)
+	c.Assert(err, gc.IsNil)
+	c.Assert(v.Passed, gc.Equals, false)
+	c.Assert(v.Error, gc.Equals, "1 of 4 smoke queries failed")
+	c.Assert(v.Queries[0], gc.DeepEquals, QueryResult{Query: "nonexistent", Error: "no results"})
+
+	// Corrupt the backup data.
+	c.Assert(s.store.Put("backups/"+info.Name+".jsonl.gz", &blobstore.Blob{Data: []byte("garbage")}), gc.IsNil)
+	v, err = sched.Verify("")
+	c.Assert(err, gc.IsNil)
+	c.Assert(v.Passed, gc.Equals, false)
+	c.Assert(v.Error, gc.Matches, `restore backup ".*": unexpected EOF`)
+}
+
+func (s *BackupTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewBlobBackend(BlobConfig{})
+	c.Assert(err, gc.ErrorMatches, "(?s)blob backup backend: config validation failed:.*index.*blob store.*")
+
+	_, err = NewScheduler(Config{Interval: -1, Retention: Retention{KeepLast: -1, MaxAge: -1}})
+	c.Assert(err, gc.ErrorMatches, "(?s)backup scheduler: config validation failed:.*backend.*interval.*retained backup count.*maximum backup age.*")
+}
@@ -0,0 +1,265 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+	"webcrawler/crawler/blobstore"
+	"webcrawler/crawler/textindexer/index"
+	memidx "webcrawler/crawler/textindexer/store/memory"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// The key (relative to the configured prefix) of the blob that lists the
+// available backups.
+const manifestKey = "manifest.json"
+
+// ScratchIndex is implemented by indices that backups can be restored to.
+type ScratchIndex interface {
+	RestoredIndex
+
+	// Index inserts a restored document to the index.
+	Index(doc *index.Document) error
+}
+
+// BlobConfig encapsulates the settings for a BlobBackend.
+type BlobConfig struct {
+	// The index to back up.
+	Index interface {
+		All(cursor index.Cursor) (index.DocumentIterator, error)
+	}
+
+	// The blob store where backups are kept.
+	Store blobstore.Store
+
+	// A prefix for the keys of the blobs created by the backend. Defaults
+	// to "backups/".
+	Prefix string
+
+	// A function that creates an empty index for restoring backups to.
+	// Defaults to creating an in-memory bleve index.
+	NewScratchIndex func() (ScratchIndex, error)
+
+	// A clock for timestamping backups. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *BlobConfig) validate() error {
+	var err error
+	if cfg.Index == nil {
+		err = multierror.Append(err, fmt.Errorf("index has not been provided"))
+	}
+	if cfg.Store == nil {
+		err = multierror.Append(err, fmt.Errorf("blob store has not been provided"))
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "backups/"
+	}
+	if cfg.NewScratchIndex == nil {
+		cfg.NewScratchIndex = func() (ScratchIndex, error) { return memidx.NewInMemoryBleveIndexer() }
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+// Compile-time check for ensuring BlobBackend implements Backend.
+var _ Backend = (*BlobBackend)(nil)
+
+// BlobBackend is a Backend that dumps the documents of an index as gzipped
+// JSON lines to a blob store. Backups are restored by re-indexing the dumped
+// documents into a scratch index.
+type BlobBackend struct {
+	cfg BlobConfig
+
+	// mu serializes updates to the manifest.
+	mu sync.Mutex
+}
+
+// NewBlobBackend returns a new blob store backend using the provided config.
+func NewBlobBackend(cfg BlobConfig) (*BlobBackend, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("blob backup backend: config validation failed: %w", err)
+	}
+	return &BlobBackend{cfg: cfg}, nil
+}
+
+// Create backs up the current contents of the index under name.
+func (b *BlobBackend) Create(name string) (*Info, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	infos, err := b.manifest()
+	if err != nil {
+		return nil, fmt.Errorf("create backup: %w", err)
+	}
+	for _, info := range infos {
+		if info.Name == name {
+			return nil, fmt.Errorf("create backup: backup %q already exists", name)
+		}
+	}
+
+	info := &Info{Name: name, CreatedAt: b.cfg.Clock().UTC()}
+	data, err := b.dump(info)
+	if err != nil {
+		return nil, fmt.Errorf("create backup: %w", err)
+	}
+	if err = b.cfg.Store.Put(b.dataKey(name), &blobstore.Blob{ContentType: "application/gzip", Data: data}); err != nil {
+		return nil, fmt.Errorf("create backup: %w", err)
+	}
+	if err = b.saveManifest(append(infos, info)); err != nil {
+		return nil, fmt.Errorf("create backup: %w", err)
+	}
+	return info, nil
+}
+
+// dump encodes every document of the index and updates the document count
+// of info.
+func (b *BlobBackend) dump(info *Info) ([]byte, error) {
+	it, err := b.cfg.Index.All("")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = it.Close() }()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for it.Next() {
+		if err = enc.Encode(it.Document()); err != nil {
+			return nil, err
+		}
+		info.Documents++
+	}
+	if err = it.Error(); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// List returns the available backups ordered by creation time, oldest first.
+func (b *BlobBackend) List() ([]*Info, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	infos, err := b.manifest()
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	return infos, nil
+}
+
+// Delete removes the backup with the specified name.
+func (b *BlobBackend) Delete(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	infos, err := b.manifest()
+	if err != nil {
+		return fmt.Errorf("delete backup: %w", err)
+	}
+	remaining := infos[:0]
+	for _, info := range infos {
+		if info.Name != name {
+			remaining = append(remaining, info)
+		}
+	}
+	if len(remaining) == len(infos) {
+		return fmt.Errorf("delete backup %q: %w", name, ErrNotFound)
+	}
+
+	// Update the manifest first so that a failure to delete the data
+	// leaves an orphaned blob rather than a dangling manifest entry.
+	if err = b.saveManifest(remaining); err != nil {
+		return fmt.Errorf("delete backup: %w", err)
+	}
+	if err = b.cfg.Store.Delete(b.dataKey(name)); err != nil {
+		return fmt.Errorf("delete backup: %w", err)
+	}
+	return nil
+}
+
+// Restore restores the backup with the specified name to a scratch index.
+func (b *BlobBackend) Restore(name string) (RestoredIndex, error) {
+	blob, err := b.cfg.Store.Get(b.dataKey(name))
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, fmt.Errorf("restore backup %q: %w", name, ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("restore backup: %w", err)
+	}
+
+	scratch, err := b.cfg.NewScratchIndex()
+	if err != nil {
+		return nil, fmt.Errorf("restore backup: %w", err)
+	}
+	if err = load(scratch, blob.Data); err != nil {
+		_ = scratch.Close()
+		return nil, fmt.Errorf("restore backup %q: %w", name, err)
+	}
+	return scratch, nil
+}
+
+// load indexes the documents encoded in data into idx.
+func load(idx ScratchIndex, data []byte) error {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = zr.Close() }()
+
+	dec := json.NewDecoder(zr)
+	for {
+		var doc index.Document
+		if err = dec.Decode(&doc); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = idx.Index(&doc); err != nil {
+			return err
+		}
+	}
+}
+
+// manifest returns the list of backups recorded in the manifest blob.
+func (b *BlobBackend) manifest() ([]*Info, error) {
+	blob, err := b.cfg.Store.Get(b.cfg.Prefix + manifestKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var infos []*Info
+	if err = json.Unmarshal(blob.Data, &infos); err != nil {
+		return nil, fmt.Errorf("malformed manifest: %w", err)
+	}
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].CreatedAt.Before(infos[j].CreatedAt) })
+	return infos, nil
+}
+
+func (b *BlobBackend) saveManifest(infos []*Info) error {
+	if infos == nil {
+		infos = []*Info{}
+	}
+	data, err := json.Marshal(infos)
+	if err != nil {
+		return err
+	}
+	return b.cfg.Store.Put(b.cfg.Prefix+manifestKey, &blobstore.Blob{ContentType: "application/json", Data: data})
+}
+
+func (b *BlobBackend) dataKey(name string) string {
+	return b.cfg.Prefix + name + ".jsonl.gz"
+}
@@ -0,0 +1,421 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Names of the jobs executed by the scheduler; used as metric labels.
+const (
+	jobBackup    = "backup"
+	jobRetention = "retention"
+	jobVerify    = "verify"
+)
+
+// The layout of the timestamp embedded in the names of scheduled backups.
+// It only uses characters that are valid in ES snapshot names.
+const nameLayout = "20060102-150405"
+
+// Retention controls which backups are kept. The newest backup is never
+// deleted.
+type Retention struct {
+	// The number of most recent backups to keep. Zero keeps all backups.
+	KeepLast int
+
+	// Backups older than MaxAge are deleted. Zero disables age-based
+	// expiry.
+	MaxAge time.Duration
+}
+
+// Config encapsulates the settings for the backup scheduler.
+type Config struct {
+	// The backend for managing backups.
+	Backend Backend
+
+	// How often a new backup is created. Defaults to 24 hours.
+	Interval time.Duration
+
+	// The retention policy for existing backups. If KeepLast is zero, it
+	// defaults to 7.
+	Retention Retention
+
+	// Queries that are executed against each restored backup. Each query
+	// must return at least one result for the verification to pass.
+	SmokeQueries []string
+
+	// The number of restored documents whose titles are looked up via a
+	// phrase query. Defaults to 5.
+	SampleSize int
+
+	// A clock for naming backups and applying the retention policy.
+	// Defaults to time.Now.
+	Clock func() time.Time
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.Backend == nil {
+		err = multierror.Append(err, fmt.Errorf("backend has not been provided"))
+	}
+	if cfg.Interval < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for backup interval"))
+	} else if cfg.Interval == 0 {
+		cfg.Interval = 24 * time.Hour
+	}
+	if cfg.Retention.KeepLast < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for retained backup count"))
+	} else if cfg.Retention.KeepLast == 0 {
+		cfg.Retention.KeepLast = 7
+	}
+	if cfg.Retention.MaxAge < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for maximum backup age"))
+	}
+	if cfg.SampleSize < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for verification sample size"))
+	} else if cfg.SampleSize == 0 {
+		cfg.SampleSize = 5
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+// Run describes the outcome of a backup job.
+type Run struct {
+	Backup    string        `json:"backup,omitempty"`
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// QueryResult describes the outcome of a smoke query executed against a
+// restored backup.
+type QueryResult struct {
+	Query   string `json:"query"`
+	Results uint64 `json:"results"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Verification describes the outcome of restoring a backup to a scratch
+// index and querying it.
+type Verification struct {
+	Backup    string        `json:"backup"`
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+
+	// The number of documents recorded when the backup was created and
+	// the number of documents in the restored index.
+	ExpectedDocuments int64 `json:"expectedDocuments"`
+	Documents         int64 `json:"documents"`
+
+	Queries []QueryResult `json:"queries"`
+	Passed  bool          `json:"passed"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// Status summarizes the available backups and the outcome of the most recent
+// jobs.
+type Status struct {
+	Backups          []*Info       `json:"backups"`
+	LastBackup       *Run          `json:"lastBackup,omitempty"`
+	LastVerification *Verification `json:"lastVerification,omitempty"`
+}
+
+// Scheduler periodically backs up an index, prunes old backups and verifies
+// that the newest backup can be restored.
+type Scheduler struct {
+	cfg    Config
+	logger *slog.Logger
+
+	// jobMu serializes the execution of backup and verification jobs.
+	jobMu sync.Mutex
+
+	mu               sync.Mutex
+	lastBackup       *Run
+	lastVerification *Verification
+}
+
+// NewScheduler returns a new backup scheduler using the provided config.
+func NewScheduler(cfg Config) (*Scheduler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("backup scheduler: config validation failed: %w", err)
+	}
+	return &Scheduler{cfg: cfg, logger: logging.Component(cfg.Logger, "textindexer.backup")}, nil
+}
+
+// Name returns the name of the scheduler when running as a service.
+func (s *Scheduler) Name() string { return "backup" }
+
+// Run executes the scheduled backup jobs until ctx is cancelled. The first
+// backup is created once Interval has elapsed since the newest existing
+// backup (or immediately if there are no backups).
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.logger.Info("starting service", "interval", s.cfg.Interval)
+	defer s.logger.Info("stopped service")
+
+	timer := time.NewTimer(s.untilNextBackup())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		s.runJobs()
+		timer.Reset(s.cfg.Interval)
+	}
+}
+
+// untilNextBackup returns the time until the next scheduled backup based on
+// the creation time of the newest backup.
+func (s *Scheduler) untilNextBackup() time.Duration {
+	infos, err := s.cfg.Backend.List()
+	if err != nil {
+		s.logger.Warn("unable to list existing backups", "err", err)
+		return 0
+	} else if len(infos) == 0 {
+		return 0
+	}
+	if wait := infos[len(infos)-1].CreatedAt.Add(s.cfg.Interval).Sub(s.cfg.Clock()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// runJobs creates a new backup, applies the retention policy and verifies
+// the new backup. Failures are logged.
+func (s *Scheduler) runJobs() {
+	info, err := s.Backup()
+	if err != nil {
+		s.logger.Error("backup failed", "err", err)
+		return
+	}
+	s.logger.Info("created backup", "backup", info.Name, "documents", info.Documents)
+
+	if err = s.Prune(); err != nil {
+		s.logger.Error("unable to apply backup retention policy", "err", err)
+	}
+
+	v, err := s.Verify(info.Name)
+	if err != nil {
+		s.logger.Error("backup verification failed", "backup", info.Name, "err", err)
+	} else if !v.Passed {
+		s.logger.Error("backup verification failed", "backup", info.Name, "err", v.Error)
+	} else {
+		s.logger.Info("verified backup", "backup", info.Name, "documents", v.Documents)
+	}
+}
+
+// Backup creates a new backup.
+func (s *Scheduler) Backup() (*Info, error) {
+	s.jobMu.Lock()
+	defer s.jobMu.Unlock()
+
+	startedAt := s.cfg.Clock()
+	run := &Run{Backup: "backup-" + startedAt.UTC().Format(nameLayout), StartedAt: startedAt}
+	info, err := s.cfg.Backend.Create(run.Backup)
+	run.Duration = s.cfg.Clock().Sub(startedAt)
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.lastBackup = run
+	s.mu.Unlock()
+	recordJob(jobBackup, err == nil)
+	return info, err
+}
+
+// Prune deletes the backups that are not covered by the retention policy.
+func (s *Scheduler) Prune() error {
+	s.jobMu.Lock()
+	defer s.jobMu.Unlock()
+
+	infos, err := s.cfg.Backend.List()
+	if err == nil {
+		for _, info := range expired(infos, s.cfg.Retention, s.cfg.Clock()) {
+			if dErr := s.cfg.Backend.Delete(info.Name); dErr != nil {
+				err = multierror.Append(err, dErr)
+				continue
+			}
+			s.logger.Info("deleted expired backup", "backup", info.Name)
+		}
+	}
+	recordJob(jobRetention, err == nil)
+	return err
+}
+
+// expired returns the backups in infos (ordered oldest first) that should be
+// deleted according to policy.
+func expired(infos []*Info, policy Retention, now time.Time) []*Info {
+	var out []*Info
+	for i, info := range infos {
+		newer := len(infos) - 1 - i
+		if newer == 0 {
+			break
+		}
+		if (policy.KeepLast > 0 && newer >= policy.KeepLast) ||
+			(policy.MaxAge > 0 && now.Sub(info.CreatedAt) > policy.MaxAge) {
+			out = append(out, info)
+		}
+	}
+	return out
+}
+
+// Verify restores the backup with the specified name (or the newest backup
+// if name is empty) to a scratch index and checks that it contains the
+// expected number of documents and that the smoke queries return results.
+// Restore and query failures are reported via the returned Verification; an
+// error is only returned if the backup does not exist or the backups cannot
+// be listed.
+func (s *Scheduler) Verify(name string) (*Verification, error) {
+	s.jobMu.Lock()
+	defer s.jobMu.Unlock()
+
+	info, err := s.lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("verify backup: %w", err)
+	}
+
+	v := &Verification{Backup: info.Name, StartedAt: s.cfg.Clock(), ExpectedDocuments: info.Documents}
+	if err = s.verify(v); err != nil {
+		v.Error = err.Error()
+	}
+	v.Passed = v.Error == ""
+	v.Duration = s.cfg.Clock().Sub(v.StartedAt)
+
+	s.mu.Lock()
+	s.lastVerification = v
+	s.mu.Unlock()
+	recordJob(jobVerify, v.Passed)
+	return v, nil
+}
+
+func (s *Scheduler) verify(v *Verification) error {
+	restored, err := s.cfg.Backend.Restore(v.Backup)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = restored.Close() }()
+
+	it, err := restored.All("")
+	if err != nil {
+		return err
+	}
+	var sampleTitles []string
+	for it.Next() {
+		v.Documents++
+		if title := it.Document().Title; title != "" && len(sampleTitles) < s.cfg.SampleSize {
+			sampleTitles = append(sampleTitles, title)
+		}
+	}
+	err = it.Error()
+	_ = it.Close()
+	if err != nil {
+		return err
+	}
+	if v.Documents < v.ExpectedDocuments {
+		return fmt.Errorf("restored %d documents; expected at least %d", v.Documents, v.ExpectedDocuments)
+	}
+
+	queries := make([]index.Query, 0, len(s.cfg.SmokeQueries)+len(sampleTitles))
+	for _, expr := range s.cfg.SmokeQueries {
+		queries = append(queries, index.Query{Type: index.QueryTypeMatch, Expression: expr})
+	}
+	for _, title := range sampleTitles {
+		queries = append(queries, index.Query{Type: index.QueryTypePhrase, Expression: title})
+	}
+
+	var failed int
+	for _, q := range queries {
+		res := runQuery(restored, q)
+		if res.Error != "" {
+			failed++
+		}
+		v.Queries = append(v.Queries, res)
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d smoke queries failed", failed, len(queries))
+	}
+	return nil
+}
+
+func runQuery(idx RestoredIndex, q index.Query) QueryResult {
+	res := QueryResult{Query: q.Expression}
+	it, err := idx.Search(q)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Results = it.TotalCount()
+	_ = it.Close()
+	if res.Results == 0 {
+		res.Error = "no results"
+	}
+	return res
+}
+
+// lookup returns the backup with the specified name or the newest backup if
+// name is empty.
+func (s *Scheduler) lookup(name string) (*Info, error) {
+	infos, err := s.cfg.Backend.List()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		if len(infos) == 0 {
+			return nil, ErrNotFound
+		}
+		return infos[len(infos)-1], nil
+	}
+	for _, info := range infos {
+		if info.Name == name {
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("%q: %w", name, ErrNotFound)
+}
+
+// Status returns the available backups and the outcome of the most recent
+// backup and verification jobs.
+func (s *Scheduler) Status() (*Status, error) {
+	infos, err := s.cfg.Backend.List()
+	if err != nil {
+		return nil, fmt.Errorf("backup status: %w", err)
+	}
+	if infos == nil {
+		infos = []*Info{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &Status{
+		Backups:          infos,
+		LastBackup:       s.lastBackup,
+		LastVerification: s.lastVerification,
+	}, nil
+}
+
+func recordJob(job string, success bool) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	metrics.BackupJobs.WithLabelValues(job, result).Inc()
+	if success {
+		metrics.BackupLastSuccess.WithLabelValues(job).SetToCurrentTime()
+	}
+}
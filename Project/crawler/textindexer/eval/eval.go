@@ -0,0 +1,128 @@
+// Package eval measures the ranking quality of an index.Indexer by running a
+// set of judged queries against it and computing standard information
+// retrieval metrics (NDCG, MRR and precision@k) over the results.
+package eval
+
+import (
+	"fmt"
+	"webcrawler/crawler/iterutil"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+)
+
+// DefaultK is the result cut-off used when Evaluator.K is not set.
+const DefaultK = 10
+
+// JudgedQuery pairs a search query with the IDs of the documents that a
+// human judge considered relevant for it.
+type JudgedQuery struct {
+	// A short, human-readable name for the query.
+	Name string
+
+	// The query to run against the indexer.
+	Query index.Query
+
+	// The link IDs of the documents that are relevant for the query.
+	Relevant []uuid.UUID
+}
+
+// QueryResult holds the metrics computed for a single judged query.
+type QueryResult struct {
+	Name string
+
+	// The link IDs of the top K documents returned by the indexer.
+	Retrieved []uuid.UUID
+
+	NDCG           float64
+	ReciprocalRank float64
+	Precision      float64
+}
+
+// Report summarizes the metrics for a set of judged queries.
+type Report struct {
+	// The result cut-off used for computing the metrics.
+	K int
+
+	// Per-query metrics in the order the queries were evaluated.
+	Queries []QueryResult
+
+	// Metrics averaged over all queries.
+	MeanNDCG      float64
+	MRR           float64
+	MeanPrecision float64
+}
+
+// String implements fmt.Stringer.
+func (r *Report) String() string {
+	return fmt.Sprintf(
+		"queries=%d NDCG@%d=%.4f MRR@%d=%.4f P@%d=%.4f",
+		len(r.Queries), r.K, r.MeanNDCG, r.K, r.MRR, r.K, r.MeanPrecision,
+	)
+}
+
+// Evaluator runs judged queries against an index.Indexer.
+type Evaluator struct {
+	// The number of top results to consider for each query. If zero,
+	// DefaultK will be used instead.
+	K int
+}
+
+// Evaluate runs each query against idx and returns a report with the
+// computed metrics.
+func (e Evaluator) Evaluate(idx index.Indexer, queries []JudgedQuery) (*Report, error) {
+	k := e.K
+	if k <= 0 {
+		k = DefaultK
+	}
+
+	report := &Report{K: k, Queries: make([]QueryResult, 0, len(queries))}
+	for _, q := range queries {
+		retrieved, err := search(idx, q.Query, k)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate query %q: %w", q.Name, err)
+		}
+
+		relevant := make(map[uuid.UUID]bool, len(q.Relevant))
+		for _, id := range q.Relevant {
+			relevant[id] = true
+		}
+
+		res := QueryResult{
+			Name:           q.Name,
+			Retrieved:      retrieved,
+			NDCG:           NDCGAtK(retrieved, relevant, k),
+			ReciprocalRank: ReciprocalRank(retrieved, relevant, k),
+			Precision:      PrecisionAtK(retrieved, relevant, k),
+		}
+		report.MeanNDCG += res.NDCG
+		report.MRR += res.ReciprocalRank
+		report.MeanPrecision += res.Precision
+		report.Queries = append(report.Queries, res)
+	}
+
+	if n := float64(len(report.Queries)); n != 0 {
+		report.MeanNDCG /= n
+		report.MRR /= n
+		report.MeanPrecision /= n
+	}
+	return report, nil
+}
+
+// search returns the link IDs of the top k documents that match q.
+func search(idx index.Indexer, q index.Query, k int) ([]uuid.UUID, error) {
+	it, err := idx.Search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := iterutil.CollectDocuments(iterutil.LimitDocuments(it, k))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.LinkID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,67 @@
+package eval
+
+import (
+	"math"
+	"testing"
+	"webcrawler/crawler/textindexer/store/memory"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(EvalTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type EvalTestSuite struct{}
+
+func (s *EvalTestSuite) TestMetrics(c *gc.C) {
+	ids := make([]uuid.UUID, 5)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+	relevant := map[uuid.UUID]bool{ids[1]: true, ids[3]: true}
+
+	c.Assert(PrecisionAtK(ids, relevant, 4), gc.Equals, 0.5)
+	c.Assert(PrecisionAtK(ids, relevant, 1), gc.Equals, 0.0)
+	c.Assert(ReciprocalRank(ids, relevant, 5), gc.Equals, 0.5)
+	c.Assert(ReciprocalRank(ids, relevant, 1), gc.Equals, 0.0)
+
+	expNDCG := (1/math.Log2(3) + 1/math.Log2(5)) / (1 + 1/math.Log2(3))
+	c.Assert(math.Abs(NDCGAtK(ids, relevant, 5)-expNDCG) < 1e-9, gc.Equals, true)
+
+	// A perfect ranking yields an NDCG of 1.
+	c.Assert(NDCGAtK([]uuid.UUID{ids[3], ids[1]}, relevant, 5), gc.Equals, 1.0)
+	c.Assert(NDCGAtK(nil, relevant, 5), gc.Equals, 0.0)
+}
+
+// TestFixtureBaseline evaluates the judged queries in the fixture against the
+// in-memory indexer. Changes to the ranking formula that lower any of the
+// metrics below the recorded baseline will cause this test to fail; if the
+// change is intentional, the baseline should be updated accordingly.
+func (s *EvalTestSuite) TestFixtureBaseline(c *gc.C) {
+	fixture, err := LoadFixtureFile("testdata/fixture.json")
+	c.Assert(err, gc.IsNil)
+
+	idx, err := memory.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(idx.Close(), gc.IsNil) }()
+	c.Assert(fixture.Populate(idx), gc.IsNil)
+
+	report, err := Evaluator{K: 5}.Evaluate(idx, fixture.Queries)
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Queries, gc.HasLen, len(fixture.Queries))
+	c.Logf("%s", report)
+	for _, q := range report.Queries {
+		c.Logf("  %-25s NDCG=%.4f RR=%.4f P=%.4f", q.Name, q.NDCG, q.ReciprocalRank, q.Precision)
+	}
+
+	const (
+		baselineNDCG      = 0.92
+		baselineMRR       = 1.0
+		baselinePrecision = 0.4
+	)
+	c.Assert(report.MeanNDCG >= baselineNDCG, gc.Equals, true, gc.Commentf("NDCG regressed: %s", report))
+	c.Assert(report.MRR >= baselineMRR, gc.Equals, true, gc.Commentf("MRR regressed: %s", report))
+	c.Assert(report.MeanPrecision >= baselinePrecision, gc.Equals, true, gc.Commentf("precision regressed: %s", report))
+}
@@ -0,0 +1,106 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+)
+
+// Fixture describes a set of documents together with the judged queries that
+// should be evaluated against them.
+type Fixture struct {
+	Documents []*index.Document
+	Queries   []JudgedQuery
+}
+
+// fixtureFile is the on-disk JSON representation of a Fixture.
+type fixtureFile struct {
+	Documents []struct {
+		LinkID   uuid.UUID `json:"link_id"`
+		URL      string    `json:"url"`
+		Title    string    `json:"title"`
+		Content  string    `json:"content"`
+		Language string    `json:"language"`
+		PageRank float64   `json:"page_rank"`
+	} `json:"documents"`
+	Queries []struct {
+		Name       string      `json:"name"`
+		Type       string      `json:"type"`
+		Expression string      `json:"expression"`
+		Relevant   []uuid.UUID `json:"relevant"`
+	} `json:"queries"`
+}
+
+var fixtureQueryTypes = map[string]index.QueryType{
+	"":        index.QueryTypeMatch,
+	"match":   index.QueryTypeMatch,
+	"phrase":  index.QueryTypePhrase,
+	"boolean": index.QueryTypeBoolean,
+}
+
+// LoadFixtureFile reads a JSON fixture from the specified path.
+func LoadFixtureFile(path string) (*Fixture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("load fixture: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return LoadFixture(f)
+}
+
+// LoadFixture reads a JSON fixture from r.
+func LoadFixture(r io.Reader) (*Fixture, error) {
+	var in fixtureFile
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("load fixture: %w", err)
+	}
+
+	fixture := new(Fixture)
+	for _, d := range in.Documents {
+		fixture.Documents = append(fixture.Documents, &index.Document{
+			LinkID:   d.LinkID,
+			URL:      d.URL,
+			Title:    d.Title,
+			Content:  d.Content,
+			Language: d.Language,
+			PageRank: d.PageRank,
+		})
+	}
+
+	for _, q := range in.Queries {
+		qType, known := fixtureQueryTypes[q.Type]
+		if !known {
+			return nil, fmt.Errorf("load fixture: query %q: unknown query type %q", q.Name, q.Type)
+		}
+		fixture.Queries = append(fixture.Queries, JudgedQuery{
+			Name:     q.Name,
+			Query:    index.Query{Type: qType, Expression: q.Expression},
+			Relevant: q.Relevant,
+		})
+	}
+
+	return fixture, nil
+}
+
+// Populate indexes the fixture documents into idx and assigns their PageRank
+// scores.
+func (f *Fixture) Populate(idx index.Indexer) error {
+	for _, doc := range f.Documents {
+		score := doc.PageRank
+		if err := idx.Index(doc); err != nil {
+			return fmt.Errorf("populate fixture: %w", err)
+		}
+		if score == 0 {
+			continue
+		}
+		if err := idx.UpdateScore(doc.LinkID, score); err != nil {
+			return fmt.Errorf("populate fixture: %w", err)
+		}
+	}
+	return nil
+}
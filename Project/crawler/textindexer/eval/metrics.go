@@ -0,0 +1,70 @@
+package eval
+
+import (
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// PrecisionAtK returns the fraction of the top k results that are relevant.
+func PrecisionAtK(results []uuid.UUID, relevant map[uuid.UUID]bool, k int) float64 {
+	if k <= 0 {
+		return 0
+	}
+
+	var hits int
+	for _, id := range topK(results, k) {
+		if relevant[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(k)
+}
+
+// ReciprocalRank returns 1/rank for the first relevant result within the top
+// k results or zero if none of them is relevant.
+func ReciprocalRank(results []uuid.UUID, relevant map[uuid.UUID]bool, k int) float64 {
+	for i, id := range topK(results, k) {
+		if relevant[id] {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// NDCGAtK returns the normalized discounted cumulative gain for the top k
+// results using binary relevance grades.
+func NDCGAtK(results []uuid.UUID, relevant map[uuid.UUID]bool, k int) float64 {
+	var dcg float64
+	for i, id := range topK(results, k) {
+		if relevant[id] {
+			dcg += discount(i)
+		}
+	}
+
+	// The ideal ranking places all relevant documents at the top.
+	var idcg float64
+	for i := 0; i < len(relevant) && i < k; i++ {
+		idcg += discount(i)
+	}
+
+	if idcg == 0 {
+		return 0
+	}
+	return dcg / idcg
+}
+
+// discount returns the DCG discount factor for the result at the specified
+// (zero-based) position.
+func discount(pos int) float64 {
+	return 1 / math.Log2(float64(pos+2))
+}
+
+func topK(results []uuid.UUID, k int) []uuid.UUID {
+	if k < 0 {
+		return nil
+	} else if len(results) > k {
+		return results[:k]
+	}
+	return results
+}
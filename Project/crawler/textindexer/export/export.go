@@ -0,0 +1,98 @@
+// Package export writes the documents of a text index as JSONL or Parquet so
+// that the crawled content can be processed by analytics and machine learning
+// pipelines.
+//
+// Documents are read via the All iterator of the indexer (a scroll over
+// elasticsearch, an iteration of the bleve index etc.) and written as they are
+// read. Exports can be restricted to a subset of the document fields.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"webcrawler/crawler/textindexer/index"
+)
+
+// Format describes the encoding of an export.
+type Format string
+
+const (
+	// JSONL exports each document as a JSON object on its own line.
+	JSONL Format = "jsonl"
+
+	// Parquet exports the documents as a snappy-compressed Parquet file
+	// with one column per field.
+	Parquet Format = "parquet"
+)
+
+// ParseFormat returns the Format with the specified name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case JSONL, Parquet:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q", name)
+	}
+}
+
+// DocumentSource is implemented by objects that can iterate the indexed
+// documents, such as index.Indexer.
+type DocumentSource interface {
+	All(cursor index.Cursor) (index.DocumentIterator, error)
+}
+
+// Options configures an export.
+type Options struct {
+	// The encoding of the export. Defaults to JSONL.
+	Format Format
+
+	// The names of the fields to export in the order in which they are
+	// written (see FieldNames). Defaults to all fields.
+	Fields []string
+}
+
+// documentWriter writes documents in a particular format.
+type documentWriter interface {
+	write(doc *index.Document) error
+	close() error
+}
+
+// Write streams the documents of src to w and returns the number of exported
+// documents.
+func Write(w io.Writer, src DocumentSource, opts Options) (int, error) {
+	selected, err := selectFields(opts.Fields)
+	if err != nil {
+		return 0, fmt.Errorf("export: %w", err)
+	}
+	var dw documentWriter
+	switch opts.Format {
+	case JSONL, "":
+		dw = newJSONLWriter(w, selected)
+	case Parquet:
+		dw = newParquetWriter(w, selected)
+	default:
+		return 0, fmt.Errorf("export: unsupported format %q", opts.Format)
+	}
+
+	it, err := src.All("")
+	if err != nil {
+		return 0, fmt.Errorf("export: %w", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	var count int
+	for it.Next() {
+		if err = dw.write(it.Document()); err != nil {
+			return count, fmt.Errorf("export: %w", err)
+		}
+		count++
+	}
+	if err = it.Error(); err != nil {
+		return count, fmt.Errorf("export: %w", err)
+	}
+	if err = dw.close(); err != nil {
+		return count, fmt.Errorf("export: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+	memidx "webcrawler/crawler/textindexer/store/memory"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ExportTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type ExportTestSuite struct {
+	idx  *memidx.InMemoryBleveIndexer
+	docs []*index.Document
+}
+
+func (s *ExportTestSuite) SetUpTest(c *gc.C) {
+	var err error
+	s.idx, err = memidx.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	s.docs = []*index.Document{
+		{
+			LinkID:      uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+			URL:         "http://example.com/",
+			Title:       "Home",
+			Content:     "Welcome home",
+			PublishedAt: time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC),
+			Headers:     map[string]string{"content-type": "text/html"},
+		},
+		{
+			LinkID:  uuid.MustParse("22222222-2222-2222-2222-222222222222"),
+			URL:     "http://example.com/about",
+			Title:   "About",
+			Content: "About us",
+		},
+	}
+	for _, doc := range s.docs {
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+	}
+	c.Assert(s.idx.UpdateScore(s.docs[0].LinkID, 0.75), gc.IsNil)
+}
+
+func (s *ExportTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+}
+
+func (s *ExportTestSuite) TestJSONL(c *gc.C) {
+	var buf bytes.Buffer
+	count, err := Write(&buf, s.idx, Options{Fields: []string{"url", "title", "publishedAt", "pageRank"}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(count, gc.Equals, 2)
+	c.Assert(buf.String(), gc.Equals,
+		`{"url":"http://example.com/","title":"Home","publishedAt":"2024-03-05T10:00:00Z","pageRank":0.75}`+"\n"+
+			`{"url":"http://example.com/about","title":"About","publishedAt":null,"pageRank":0}`+"\n")
+
+	// All fields are exported by default.
+	buf.Reset()
+	_, err = Write(&buf, s.idx, Options{Format: JSONL})
+	c.Assert(err, gc.IsNil)
+	var doc map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(strings.SplitN(buf.String(), "\n", 2)[0]), &doc), gc.IsNil)
+	c.Assert(doc, gc.HasLen, len(FieldNames()))
+	c.Assert(doc["headers"], gc.DeepEquals, map[string]interface{}{"content-type": "text/html"})
+	c.Assert(doc["duplicateOf"], gc.IsNil)
+}
+
+func (s *ExportTestSuite) TestParquet(c *gc.C) {
+	var buf bytes.Buffer
+	count, err := Write(&buf, s.idx, Options{Format: Parquet, Fields: []string{"url", "linkID", "publishedAt", "pageRank", "headers"}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(count, gc.Equals, 2)
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, gc.IsNil)
+	c.Assert(f.NumRows(), gc.Equals, int64(2))
+	c.Assert(f.Schema().Columns(), gc.DeepEquals, [][]string{{"headers"}, {"linkID"}, {"pageRank"}, {"publishedAt"}, {"url"}})
+
+	r := parquet.NewReader(f)
+	defer func() { _ = r.Close() }()
+	rows := make([]parquet.Row, 2)
+	n, err := r.ReadRows(rows)
+	c.Assert(n, gc.Equals, 2)
+	c.Assert(err, gc.Equals, io.EOF)
+
+	c.Assert(rows[0][0].String(), gc.Equals, `{"content-type":"text/html"}`)
+	c.Assert(rows[0][1].String(), gc.Equals, s.docs[0].LinkID.String())
+	c.Assert(rows[0][2].Double(), gc.Equals, 0.75)
+	c.Assert(rows[0][3].Int64(), gc.Equals, s.docs[0].PublishedAt.UnixMilli())
+	c.Assert(rows[0][4].String(), gc.Equals, "http://example.com/")
+	c.Assert(rows[1][0].IsNull(), gc.Equals, true)
+	c.Assert(rows[1][3].IsNull(), gc.Equals, true)
+	c.Assert(rows[1][4].String(), gc.Equals, "http://example.com/about")
+}
+
+func (s *ExportTestSuite) TestParseFields(c *gc.C) {
+	names, err := ParseFields(" url, Title ,")
+	c.Assert(err, gc.IsNil)
+	c.Assert(names, gc.DeepEquals, []string{"url", "Title"})
+
+	_, err = ParseFields("url,body")
+	c.Assert(err, gc.ErrorMatches, `unknown field "body"; expected one of linkID, url, .*`)
+	_, err = ParseFields("url,URL")
+	c.Assert(err, gc.ErrorMatches, `field "url" is selected more than once`)
+
+	_, err = Write(new(bytes.Buffer), s.idx, Options{Format: "xml"})
+	c.Assert(err, gc.ErrorMatches, `export: unsupported format "xml"`)
+}
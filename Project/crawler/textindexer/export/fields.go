@@ -0,0 +1,116 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+)
+
+// field describes an exported document field.
+type field struct {
+	name string
+
+	// The Parquet column type of the field.
+	node parquet.Node
+
+	// value returns the value of the field for doc. Optional fields
+	// return nil if they are not set.
+	value func(doc *index.Document) interface{}
+}
+
+// fields lists the exportable fields in their default order.
+var fields = []field{
+	{name: "linkID", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.LinkID.String() }},
+	{name: "url", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.URL }},
+	{name: "title", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.Title }},
+	{name: "content", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.Content }},
+	{name: "summary", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.Summary }},
+	{name: "language", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.Language }},
+	{name: "author", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.Author }},
+	{name: "publishedAt", node: parquet.Optional(parquet.Timestamp(parquet.Millisecond)), value: func(doc *index.Document) interface{} { return optionalTime(doc.PublishedAt) }},
+	{name: "vertical", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.Vertical }},
+	{name: "namespace", node: parquet.String(), value: func(doc *index.Document) interface{} { return doc.Namespace }},
+	{name: "indexedAt", node: parquet.Optional(parquet.Timestamp(parquet.Millisecond)), value: func(doc *index.Document) interface{} { return optionalTime(doc.IndexedAt) }},
+	{name: "changedAt", node: parquet.Optional(parquet.Timestamp(parquet.Millisecond)), value: func(doc *index.Document) interface{} { return optionalTime(doc.ChangedAt) }},
+	{name: "pageRank", node: parquet.Leaf(parquet.DoubleType), value: func(doc *index.Document) interface{} { return doc.PageRank }},
+	{name: "headers", node: parquet.Optional(parquet.JSON()), value: func(doc *index.Document) interface{} {
+		if len(doc.Headers) == 0 {
+			return nil
+		}
+		return doc.Headers
+	}},
+	{name: "fingerprint", node: parquet.Uint(64), value: func(doc *index.Document) interface{} { return doc.Fingerprint }},
+	{name: "contentHash", node: parquet.Uint(64), value: func(doc *index.Document) interface{} { return doc.ContentHash }},
+	{name: "duplicateOf", node: parquet.Optional(parquet.String()), value: func(doc *index.Document) interface{} {
+		if doc.DuplicateOf == uuid.Nil {
+			return nil
+		}
+		return doc.DuplicateOf.String()
+	}},
+}
+
+// FieldNames returns the names of the exportable document fields.
+func FieldNames() []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// ParseFields splits a comma-separated list of field names and verifies that
+// each of them refers to an exportable field.
+func ParseFields(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if _, err := selectFields(names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// selectFields returns the fields with the specified names or all fields if
+// names is empty.
+func selectFields(names []string) ([]field, error) {
+	if len(names) == 0 {
+		return fields, nil
+	}
+	selected := make([]field, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		f, ok := fieldByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q; expected one of %s", name, strings.Join(FieldNames(), ", "))
+		} else if seen[f.name] {
+			return nil, fmt.Errorf("field %q is selected more than once", f.name)
+		}
+		seen[f.name] = true
+		selected = append(selected, f)
+	}
+	return selected, nil
+}
+
+func fieldByName(name string) (field, bool) {
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// optionalTime returns t in UTC or nil if t is the zero time.
+func optionalTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
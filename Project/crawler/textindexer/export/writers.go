@@ -0,0 +1,147 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// jsonlWriter writes each document as a JSON object whose keys follow the
+// order of the selected fields. Unset optional fields are encoded as null.
+type jsonlWriter struct {
+	w      *bufio.Writer
+	fields []field
+}
+
+func newJSONLWriter(w io.Writer, fields []field) *jsonlWriter {
+	return &jsonlWriter{w: bufio.NewWriter(w), fields: fields}
+}
+
+func (jw *jsonlWriter) write(doc *index.Document) error {
+	_ = jw.w.WriteByte('{')
+	for i, f := range jw.fields {
+		if i > 0 {
+			_ = jw.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.name)
+		val, err := json.Marshal(f.value(doc))
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		_, _ = jw.w.Write(key)
+		_ = jw.w.WriteByte(':')
+		_, _ = jw.w.Write(val)
+	}
+	_, err := jw.w.WriteString("}\n")
+	return err
+}
+
+func (jw *jsonlWriter) close() error { return jw.w.Flush() }
+
+// The number of documents that are buffered before being handed over to the
+// Parquet writer.
+const parquetBatchSize = 256
+
+// parquetWriter writes the documents as a Parquet file with a flat schema
+// holding a column for each selected field.
+type parquetWriter struct {
+	w       *parquet.Writer
+	fields  []field
+	columns []int
+	rows    []parquet.Row
+}
+
+func newParquetWriter(w io.Writer, fields []field) *parquetWriter {
+	group := make(parquet.Group, len(fields))
+	for _, f := range fields {
+		group[f.name] = f.node
+	}
+	schema := parquet.NewSchema("document", group)
+
+	// The columns of a group are ordered by name; columns[i] holds the
+	// index of the column for fields[i].
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	sort.Strings(names)
+	columns := make([]int, len(fields))
+	for i, f := range fields {
+		columns[i] = sort.SearchStrings(names, f.name)
+	}
+
+	return &parquetWriter{
+		w:       parquet.NewWriter(w, schema, parquet.Compression(&parquet.Snappy)),
+		fields:  fields,
+		columns: columns,
+		rows:    make([]parquet.Row, 0, parquetBatchSize),
+	}
+}
+
+func (pw *parquetWriter) write(doc *index.Document) error {
+	row := make(parquet.Row, len(pw.fields))
+	for i, f := range pw.fields {
+		val, err := parquetValue(f, f.value(doc), pw.columns[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		row[pw.columns[i]] = val
+	}
+	if pw.rows = append(pw.rows, row); len(pw.rows) == parquetBatchSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flush() error {
+	_, err := pw.w.WriteRows(pw.rows)
+	pw.rows = pw.rows[:0]
+	return err
+}
+
+func (pw *parquetWriter) close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	return pw.w.Close()
+}
+
+// parquetValue converts the value v of f to a Parquet value for the specified
+// column. As the schema is flat, the definition level of a value is 1 for
+// optional fields that are set and 0 otherwise.
+func parquetValue(f field, v interface{}, column int) (parquet.Value, error) {
+	var defLevel int
+	if f.node.Optional() {
+		if v == nil {
+			return parquet.NullValue().Level(0, 0, column), nil
+		}
+		defLevel = 1
+	}
+
+	var val parquet.Value
+	switch v := v.(type) {
+	case string:
+		val = parquet.ByteArrayValue([]byte(v))
+	case float64:
+		val = parquet.DoubleValue(v)
+	case uint64:
+		val = parquet.Int64Value(int64(v))
+	case time.Time:
+		val = parquet.Int64Value(v.UnixMilli())
+	case map[string]string:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		val = parquet.ByteArrayValue(data)
+	default:
+		return parquet.Value{}, fmt.Errorf("unsupported value type %T", v)
+	}
+	return val.Level(0, defLevel, column), nil
+}
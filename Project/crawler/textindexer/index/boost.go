@@ -0,0 +1,96 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BoostableFields lists the document fields that are searched by queries
+// which do not target a specific field and whose matches can be boosted.
+var BoostableFields = []string{FieldTitle, FieldURL, FieldContent}
+
+// FieldBoosts maps the names of the fields that are searched by queries which
+// do not target a specific field to the factor that the relevance score of
+// their matches is multiplied by. Fields that are not included in the map are
+// not searched.
+type FieldBoosts map[string]float64
+
+// DefaultFieldBoosts ranks title matches above URL matches and URL matches
+// above content matches.
+var DefaultFieldBoosts = FieldBoosts{FieldTitle: 3, FieldURL: 2, FieldContent: 1}
+
+// ParseFieldBoosts parses a list of field boosts formatted as "Field^boost"
+// (e.g. "Title^3"). Field names are case-insensitive and fields without a
+// boost suffix get a boost of 1.
+func ParseFieldBoosts(specs []string) (FieldBoosts, error) {
+	boosts := make(FieldBoosts, len(specs))
+	for _, spec := range specs {
+		name, value, hasBoost := strings.Cut(strings.TrimSpace(spec), "^")
+		field, ok := boostableField(name)
+		if !ok {
+			return nil, fmt.Errorf("field boost %q: unknown field; expected one of: %s", spec, strings.Join(BoostableFields, ", "))
+		}
+		boost := 1.0
+		if hasBoost {
+			var err error
+			if boost, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("field boost %q: invalid boost", spec)
+			}
+		}
+		boosts[field] = boost
+	}
+	if err := boosts.Validate(); err != nil {
+		return nil, err
+	}
+	return boosts, nil
+}
+
+// Validate checks that all boosted fields are included in BoostableFields,
+// that their boosts are positive and that at least one field is boosted.
+func (b FieldBoosts) Validate() error {
+	if len(b) == 0 {
+		return errors.New("field boosts: at least one field must be searched")
+	}
+	for field, boost := range b {
+		if canonical, ok := boostableField(field); !ok || canonical != field {
+			return fmt.Errorf("field boosts: unknown field %q", field)
+		} else if !(boost > 0) {
+			return fmt.Errorf("field boosts: boost of field %q must be positive", field)
+		}
+	}
+	return nil
+}
+
+// Fields returns the names of the boosted fields in the order in which they
+// appear in BoostableFields.
+func (b FieldBoosts) Fields() []string {
+	var fields []string
+	for _, field := range BoostableFields {
+		if _, ok := b[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// String formats the boosts as a comma-separated list of "Field^boost" specs.
+func (b FieldBoosts) String() string {
+	specs := make([]string, 0, len(b))
+	for _, field := range b.Fields() {
+		specs = append(specs, field+"^"+strconv.FormatFloat(b[field], 'g', -1, 64))
+	}
+	return strings.Join(specs, ",")
+}
+
+// boostableField returns the canonical name of the boostable field that
+// matches name case-insensitively.
+func boostableField(name string) (string, bool) {
+	for _, field := range BoostableFields {
+		if strings.EqualFold(field, name) {
+			return field, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,36 @@
+package index
+
+import (
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(BoostTestSuite))
+
+type BoostTestSuite struct{}
+
+func (s *BoostTestSuite) TestParseFieldBoosts(c *gc.C) {
+	boosts, err := ParseFieldBoosts([]string{"content", " TITLE^2.5", "Url^0.5"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(boosts, gc.DeepEquals, FieldBoosts{FieldTitle: 2.5, FieldURL: 0.5, FieldContent: 1})
+	c.Assert(boosts.Fields(), gc.DeepEquals, []string{FieldTitle, FieldURL, FieldContent})
+	c.Assert(boosts.String(), gc.Equals, "Title^2.5,URL^0.5,Content^1")
+
+	specs := []struct {
+		specs  []string
+		expErr string
+	}{
+		{specs: nil, expErr: "field boosts: at least one field must be searched"},
+		{specs: []string{"Author^2"}, expErr: `field boost "Author\^2": unknown field; expected one of: Title, URL, Content`},
+		{specs: []string{"Title^high"}, expErr: `field boost "Title\^high": invalid boost`},
+		{specs: []string{"Title^-1"}, expErr: `field boosts: boost of field "Title" must be positive`},
+	}
+	for _, spec := range specs {
+		_, err := ParseFieldBoosts(spec.specs)
+		c.Assert(err, gc.ErrorMatches, spec.expErr, gc.Commentf("specs %q", spec.specs))
+	}
+}
+
+func (s *BoostTestSuite) TestValidate(c *gc.C) {
+	c.Assert(DefaultFieldBoosts.Validate(), gc.IsNil)
+	c.Assert(FieldBoosts{"title": 1}.Validate(), gc.ErrorMatches, `field boosts: unknown field "title"`)
+}
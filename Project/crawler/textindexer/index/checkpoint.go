@@ -0,0 +1,154 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CheckpointStore is implemented by objects that can persist the position
+// of long-running jobs that iterate over all indexed documents so that they
+// can resume after a restart.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the cursor that was last saved for the job
+	// with the specified name or an empty cursor if no checkpoint exists.
+	LoadCheckpoint(job string) (Cursor, error)
+
+	// SaveCheckpoint persists the cursor for the job with the specified
+	// name. Saving an empty cursor clears the checkpoint.
+	SaveCheckpoint(job string, cursor Cursor) error
+}
+
+// InMemoryCheckpointStore is a CheckpointStore that keeps checkpoints in
+// memory. It is safe for concurrent use.
+type InMemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]Cursor
+}
+
+// NewInMemoryCheckpointStore returns a new InMemoryCheckpointStore instance.
+func NewInMemoryCheckpointStore() *InMemoryCheckpointStore {
+	return &InMemoryCheckpointStore{checkpoints: make(map[string]Cursor)}
+}
+
+// LoadCheckpoint implements CheckpointStore.
+func (s *InMemoryCheckpointStore) LoadCheckpoint(job string) (Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoints[job], nil
+}
+
+// SaveCheckpoint implements CheckpointStore.
+func (s *InMemoryCheckpointStore) SaveCheckpoint(job string, cursor Cursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cursor == "" {
+		delete(s.checkpoints, job)
+		return nil
+	}
+	s.checkpoints[job] = cursor
+	return nil
+}
+
+// FileCheckpointStore is a CheckpointStore that keeps the checkpoint of each
+// job in a separate file inside a directory.
+type FileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore returns a FileCheckpointStore that stores
+// checkpoints in dir. The directory is created if it does not exist.
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create checkpoint dir: %w", err)
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// LoadCheckpoint implements CheckpointStore.
+func (s *FileCheckpointStore) LoadCheckpoint(job string) (Cursor, error) {
+	data, err := os.ReadFile(s.pathFor(job))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("load checkpoint: %w", err)
+	}
+	return Cursor(data), nil
+}
+
+// SaveCheckpoint implements CheckpointStore. The checkpoint file is replaced
+// atomically so that a crash while saving never leaves a partially written
+// checkpoint behind.
+func (s *FileCheckpointStore) SaveCheckpoint(job string, cursor Cursor) error {
+	path := s.pathFor(job)
+	if cursor == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("save checkpoint: %w", err)
+		}
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(cursor), 0o644); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *FileCheckpointStore) pathFor(job string) string {
+	return filepath.Join(s.dir, filepath.Base(job)+".checkpoint")
+}
+
+// WalkAll invokes fn for each document returned by idx.All, resuming from the
+// checkpoint that store holds for job. The checkpoint is updated after every
+// checkpointEvery documents and whenever fn returns an error, in which case
+// the failed document will be retried by the next walk. Once all documents
+// have been visited the checkpoint is cleared so that the next walk starts
+// over from the beginning of the index.
+func WalkAll(idx Indexer, store CheckpointStore, job string, checkpointEvery int, fn func(*Document) error) error {
+	cursor, err := store.LoadCheckpoint(job)
+	if err != nil {
+		return fmt.Errorf("walk %q: %w", job, err)
+	}
+
+	it, err := idx.All(cursor)
+	if err != nil {
+		return fmt.Errorf("walk %q: %w", job, err)
+	}
+
+	var visited int
+	for it.Next() {
+		if err = fn(it.Document()); err != nil {
+			_ = it.Close()
+			if saveErr := store.SaveCheckpoint(job, cursor); saveErr != nil {
+				return fmt.Errorf("walk %q: %w", job, saveErr)
+			}
+			return fmt.Errorf("walk %q: %w", job, err)
+		}
+
+		cursor = it.Cursor()
+		if visited++; checkpointEvery > 0 && visited%checkpointEvery == 0 {
+			if err = store.SaveCheckpoint(job, cursor); err != nil {
+				_ = it.Close()
+				return fmt.Errorf("walk %q: %w", job, err)
+			}
+		}
+	}
+
+	if err = it.Error(); err != nil {
+		_ = it.Close()
+		if saveErr := store.SaveCheckpoint(job, cursor); saveErr != nil {
+			return fmt.Errorf("walk %q: %w", job, saveErr)
+		}
+		return fmt.Errorf("walk %q: %w", job, err)
+	}
+	if err = it.Close(); err != nil {
+		return fmt.Errorf("walk %q: %w", job, err)
+	}
+
+	return store.SaveCheckpoint(job, "")
+}
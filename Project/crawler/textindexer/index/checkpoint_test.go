@@ -0,0 +1,49 @@
+package index
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(CheckpointTestSuite))
+
+type CheckpointTestSuite struct{}
+
+func (s *CheckpointTestSuite) TestCursorRoundTrip(c *gc.C) {
+	linkID := uuid.New()
+	got, err := CursorFor(linkID).LinkID()
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.Equals, linkID)
+
+	for _, cursor := range []Cursor{"!!!", Cursor("c2hvcnQ")} {
+		_, err = cursor.LinkID()
+		c.Assert(errors.Is(err, ErrInvalidCursor), gc.Equals, true, gc.Commentf("cursor %q", cursor))
+	}
+}
+
+func (s *CheckpointTestSuite) TestFileCheckpointStore(c *gc.C) {
+	store, err := NewFileCheckpointStore(c.MkDir())
+	c.Assert(err, gc.IsNil)
+
+	cursor, err := store.LoadCheckpoint("export")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cursor, gc.Equals, Cursor(""))
+
+	exp := CursorFor(uuid.New())
+	c.Assert(store.SaveCheckpoint("export", exp), gc.IsNil)
+	cursor, err = store.LoadCheckpoint("export")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cursor, gc.Equals, exp)
+
+	// Checkpoints are kept per job.
+	cursor, err = store.LoadCheckpoint("anchor-sync")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cursor, gc.Equals, Cursor(""))
+
+	c.Assert(store.SaveCheckpoint("export", ""), gc.IsNil)
+	cursor, err = store.LoadCheckpoint("export")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cursor, gc.Equals, Cursor(""))
+}
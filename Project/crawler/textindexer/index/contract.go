@@ -1,6 +1,10 @@
 package index
 
-import "github.com/google/uuid"
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
 
 // Indexer is implemented by objects that can index and search documents
 // discovered by the Links 'R' Us crawler.
@@ -20,6 +24,63 @@ type Indexer interface {
 	// specified link ID. If no such document exists, a placeholder
 	// document with the provided score will be created.
 	UpdateScore(linkID uuid.UUID, score float64) error
+
+	// Patch applies a partial update to the indexed document with the
+	// specified link ID. Only the fields set in the patch are modified;
+	// the remaining fields, the PageRank score and the indexing timestamp
+	// are left untouched. Patch returns ErrNotFound if no such document
+	// exists.
+	Patch(linkID uuid.UUID, patch DocumentPatch) error
+
+	// Suggest returns up to limit distinct titles of indexed documents
+	// for autocompleting the partially typed search expression prefix.
+	// Each word of prefix must be a prefix of a word in the title. Titles
+	// are ordered by descending PageRank score.
+	Suggest(prefix string, limit int) ([]string, error)
+
+	// All returns an iterator over every indexed document in ascending
+	// link ID order. If cursor is not empty, iteration resumes after the
+	// document that the cursor refers to; otherwise, it starts from the
+	// beginning of the index.
+	All(cursor Cursor) (DocumentIterator, error)
+}
+
+// BulkScoreUpdater is implemented by indexers that can update the PageRank
+// scores of multiple documents with a single request.
+type BulkScoreUpdater interface {
+	// UpdateScores updates the PageRank scores of the documents whose
+	// link IDs are keys of scores. Placeholder documents are created for
+	// unknown link IDs. If some of the updates fail, the remaining ones
+	// are still applied and a *ScoreUpdateError is returned.
+	UpdateScores(scores map[uuid.UUID]float64) error
+}
+
+// Pinger is implemented by indexers that are backed by a remote server.
+type Pinger interface {
+	// Ping returns an error if the server cannot be reached or is unable
+	// to serve requests.
+	Ping(ctx context.Context) error
+}
+
+// DocumentIterator is implemented by objects that can iterate over indexed
+// documents in a stable order.
+type DocumentIterator interface {
+	// Close the iterator and release any allocated resources.
+	Close() error
+
+	// Next loads the next document. It returns false if no more documents
+	// are available.
+	Next() bool
+
+	// Error returns the last error encountered by the iterator.
+	Error() error
+
+	// Document returns the current document.
+	Document() *Document
+
+	// Cursor returns a cursor for the current document. Passing it to
+	// Indexer.All resumes iteration after the current document.
+	Cursor() Cursor
 }
 
 // Iterator is implemented by objects that can paginate search results.
@@ -39,6 +100,20 @@ type Iterator interface {
 
 	// TotalCount returns the approximate number of search results.
 	TotalCount() uint64
+
+	// MaxScore returns the highest relevance score among the search
+	// results. Scores are implementation-specific and should only be
+	// compared against scores returned by the same indexer.
+	MaxScore() float64
+
+	// Facets returns the aggregations requested by the search query
+	// computed over the full result set.
+	Facets() []Facet
+
+	// Suggestions returns corrected versions of the search expression if
+	// it matched fewer than SuggestionThreshold documents. Indexers that
+	// do not support spelling correction return nil.
+	Suggestions() []Suggestion
 }
 
 // QueryType describes the types of queries supported by the indexer
@@ -51,6 +126,10 @@ const (
 
 	// QueryTypePhrase searches for an exact phrase match.
 	QueryTypePhrase
+
+	// QueryTypeBoolean parses the search expression using the syntax
+	// described by ParseBooleanQuery.
+	QueryTypeBoolean
 )
 
 // Query encapsulates a set of parameters to use when searching indexed
@@ -62,6 +141,25 @@ type Query struct {
 	// The search expression.
 	Expression string
 
-	// The number of search results to skip.
+	// The number of search results to skip. Indexers reject queries whose
+	// offset exceeds their maximum offset with an *OffsetTooLargeError.
 	Offset uint64
+
+	// The maximum number of search results to return. If zero, the
+	// iterator returns every result past Offset.
+	Size uint64
+
+	// If set, only documents that belong to the specified search vertical
+	// are returned.
+	Vertical string
+
+	// Optional criteria that the returned documents must satisfy.
+	Filters Filters
+
+	// An optional list of aggregations to compute over the result set.
+	Facets []FacetRequest
+
+	// Optional settings for ordering the search results. If nil,
+	// DefaultRanking is used.
+	Ranking *Ranking
 }
@@ -0,0 +1,35 @@
+package index
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Cursor is an opaque token that identifies a position in the stream of
+// documents returned by Indexer.All. Cursors only depend on the link ID of
+// the document they refer to and therefore remain valid across indexer
+// restarts and can be persisted as checkpoints.
+type Cursor string
+
+// CursorFor returns a Cursor that refers to the document with the specified
+// link ID.
+func CursorFor(linkID uuid.UUID) Cursor {
+	return Cursor(base64.RawURLEncoding.EncodeToString(linkID[:]))
+}
+
+// LinkID returns the link ID of the document that c refers to. It returns
+// ErrInvalidCursor if c is malformed.
+func (c Cursor) LinkID() (uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	linkID, err := uuid.FromBytes(raw)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return linkID, nil
+}
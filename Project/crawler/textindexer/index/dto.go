@@ -1,6 +1,12 @@
 package index
 
 import (
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,9 +26,221 @@ type Document struct {
 	// The document body
 	Content string
 
+	// An optional extractive summary of the document body. It is derived
+	// from the content and is cleared whenever the content is patched.
+	Summary string
+
+	// The ISO 639-1 code of the language the document is written in (if
+	// known).
+	Language string
+
+	// The author and publication date of the document (if known). These
+	// are only populated for sites with an extraction profile.
+	Author      string
+	PublishedAt time.Time
+
+	// The search vertical that the document belongs to (see Verticals). If
+	// empty, the document belongs to VerticalWeb.
+	Vertical string
+
+	// The namespace of the crawl that indexed the document. This field is
+	// populated by the indexer.
+	Namespace string
+
 	// The last time this document was indexed.
 	IndexedAt time.Time
 
 	// The PageRank score assigned to this document.
 	PageRank float64
+
+	// Optional blob store keys for the favicon of the document's host and
+	// a thumbnail of the rendered page.
+	FaviconRef   string
+	ThumbnailRef string
+
+	// Selected HTTP response headers captured when the document was
+	// fetched, keyed by lower-case header name.
+	Headers map[string]string
+
+	// The SimHash fingerprint of the document content or zero if the
+	// content was too short to be fingerprinted.
+	Fingerprint uint64
+
+	// A hash of the crawled attributes of the document (see ContentHash)
+	// that is used for detecting whether a re-crawled page has changed or
+	// zero if unknown.
+	ContentHash uint64
+
+	// The last time the content of the document changed, i.e. the last
+	// time it was indexed with a different content hash. Unlike IndexedAt,
+	// it is not bumped by re-crawls that find the page unchanged, which
+	// makes it suitable for freshness-aware ranking.
+	ChangedAt time.Time
+
+	// If set, the document is a near-duplicate of the document indexed for
+	// the specified link. Only a reference is stored for duplicates: their
+	// title and content are left empty so that they never show up in
+	// search results.
+	DuplicateOf uuid.UUID
+}
+
+// DocumentPatch describes a partial update to an indexed document. Nil
+// fields are left unchanged. Setting a field to an empty string redacts it.
+type DocumentPatch struct {
+	Title   *string
+	Content *string
+}
+
+// IsEmpty returns true if the patch does not modify any field.
+func (p DocumentPatch) IsEmpty() bool {
+	return p.Title == nil && p.Content == nil
+}
+
+// Apply applies the patch to d. Patching the content also clears the
+// summary so that it cannot leak redacted text.
+func (p DocumentPatch) Apply(d *Document) {
+	if p.Title != nil {
+		d.Title = *p.Title
+	}
+	if p.Content != nil {
+		d.Content = *p.Content
+		d.Summary = ""
+	}
+}
+
+// ContentHash returns a hash of the attributes of doc that are extracted from
+// the crawled page: its URL, title, content, summary, language, author,
+// publication date, vertical, blob references, captured headers and duplicate
+// reference. The timestamps, the PageRank score, the namespace and the
+// fingerprint (which is derived from the content) are not covered.
+func ContentHash(doc *Document) uint64 {
+	h := fnv.New64a()
+	for _, field := range []string{
+		doc.URL, doc.Title, doc.Content, doc.Summary, doc.Language, doc.Author,
+		doc.Vertical, doc.FaviconRef, doc.ThumbnailRef, doc.DuplicateOf.String(),
+	} {
+		_, _ = io.WriteString(h, field)
+		_, _ = h.Write([]byte{0})
+	}
+	if !doc.PublishedAt.IsZero() {
+		_, _ = h.Write(binary.BigEndian.AppendUint64(nil, uint64(doc.PublishedAt.UnixNano())))
+	}
+	for _, term := range HeaderTermsOf(doc) {
+		_, _ = io.WriteString(h, term)
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// FacetField describes a document attribute that search results can be
+// aggregated by.
+type FacetField uint8
+
+const (
+	// FacetHost aggregates results by the host name of the document URL.
+	FacetHost FacetField = iota
+
+	// FacetLanguage aggregates results by document language.
+	FacetLanguage
+
+	// FacetIndexedDate aggregates results by the (UTC) day the document
+	// was indexed. Bucket values are formatted as YYYY-MM-DD.
+	FacetIndexedDate
+
+	// FacetVertical aggregates results by search vertical.
+	FacetVertical
+)
+
+// DefaultFacetSize is the number of buckets returned for a facet request that
+// does not specify a size.
+const DefaultFacetSize = 10
+
+// FacetRequest describes an aggregation to compute alongside a search.
+type FacetRequest struct {
+	// The attribute to aggregate results by.
+	Field FacetField
+
+	// The maximum number of buckets to return. If zero, DefaultFacetSize
+	// will be used instead.
+	Size int
+}
+
+// Facet contains the buckets computed for a FacetRequest.
+type Facet struct {
+	Field FacetField
+
+	// The buckets for this facet sorted by descending count.
+	Buckets []FacetBucket
+}
+
+// FacetBucket holds the number of search results that share a value for a
+// particular facet field.
+type FacetBucket struct {
+	Value string
+	Count uint64
+}
+
+// FacetSize returns the effective bucket count for the request.
+func (r FacetRequest) FacetSize() int {
+	if r.Size <= 0 {
+		return DefaultFacetSize
+	}
+	return r.Size
+}
+
+// HostOf returns the lower-cased host name of the document URL or an empty
+// string if the URL cannot be parsed.
+func HostOf(doc *Document) string {
+	u, err := url.Parse(doc.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// IndexedDateOf returns the day the document was indexed formatted as
+// YYYY-MM-DD or an empty string if the document has not been indexed yet.
+func IndexedDateOf(doc *Document) string {
+	if doc.IndexedAt.IsZero() {
+		return ""
+	}
+	return doc.IndexedAt.UTC().Format("2006-01-02")
+}
+
+// HeaderFieldPrefix is the prefix of the field names that refer to captured
+// response headers (e.g. "Headers.x-generator").
+const HeaderFieldPrefix = "Headers."
+
+// HeaderField returns the name of the field that refers to the captured
+// response header with the specified name.
+func HeaderField(name string) string {
+	return HeaderFieldPrefix + strings.ToLower(name)
+}
+
+// HeaderName returns the header name that field refers to and true if field
+// refers to a captured response header.
+func HeaderName(field string) (string, bool) {
+	if !strings.HasPrefix(field, HeaderFieldPrefix) || len(field) == len(HeaderFieldPrefix) {
+		return "", false
+	}
+	return field[len(HeaderFieldPrefix):], true
+}
+
+// HeaderTermsOf returns the captured response headers of doc as a sorted list
+// of lower-case "name=value" terms. Indexers store these terms in a keyword
+// field so that documents can be filtered by header values.
+func HeaderTermsOf(doc *Document) []string {
+	terms := make([]string, 0, len(doc.Headers))
+	for name, value := range doc.Headers {
+		terms = append(terms, strings.ToLower(name+"="+value))
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// HeaderTermPattern returns a wildcard pattern that matches the header terms
+// produced by HeaderTermsOf for the specified header whose value contains
+// text.
+func HeaderTermPattern(name, text string) string {
+	return strings.ToLower(name) + "=*" + strings.ToLower(text) + "*"
 }
@@ -1,6 +1,11 @@
 package index
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
 
 var (
 	// ErrNotFound is returned by the indexer when attempting to look up
@@ -10,4 +15,48 @@ var (
 	// ErrMissingLinkID is returned when attempting to index a document
 	// that does not specify a valid link ID.
 	ErrMissingLinkID = errors.New("document does not provide a valid linkID")
+
+	// ErrInvalidQuery is returned when a search expression cannot be parsed.
+	ErrInvalidQuery = errors.New("invalid query")
+
+	// ErrInvalidCursor is returned when attempting to resume iteration
+	// from a malformed cursor.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrOffsetTooLarge is returned when a search query skips more
+	// results than the indexer allows.
+	ErrOffsetTooLarge = errors.New("search offset too large")
 )
+
+// OffsetTooLargeError is returned by Search for queries whose offset exceeds
+// the maximum offset of the indexer. It wraps ErrOffsetTooLarge.
+type OffsetTooLargeError struct {
+	// The offset of the rejected query.
+	Offset uint64
+
+	// The largest offset accepted by the indexer.
+	MaxOffset uint64
+}
+
+// Error implements error.
+func (e *OffsetTooLargeError) Error() string {
+	return fmt.Sprintf("offset %d exceeds the maximum offset of %d", e.Offset, e.MaxOffset)
+}
+
+// Unwrap returns ErrOffsetTooLarge so that callers can check for rejected
+// offsets with errors.Is.
+func (e *OffsetTooLargeError) Unwrap() error {
+	return ErrOffsetTooLarge
+}
+
+// ScoreUpdateError is returned by UpdateScores when the scores of some of the
+// documents could not be updated.
+type ScoreUpdateError struct {
+	// The reason each failed update was rejected keyed by link ID.
+	Failed map[uuid.UUID]error
+}
+
+// Error implements error.
+func (e *ScoreUpdateError) Error() string {
+	return fmt.Sprintf("%d score update(s) failed", len(e.Failed))
+}
@@ -0,0 +1,75 @@
+package index
+
+import (
+	"strings"
+	"time"
+)
+
+// Filters restricts search results to the documents that satisfy all of the
+// specified criteria. The zero value does not filter out any documents.
+type Filters struct {
+	// If set, only documents whose host is one of the listed domains or
+	// a subdomain of one of them are returned.
+	Domains []string
+
+	// If set, only documents indexed at or after IndexedAfter and before
+	// IndexedBefore are returned.
+	IndexedAfter  time.Time
+	IndexedBefore time.Time
+
+	// If set, only documents written in one of the listed languages
+	// (ISO 639-1 codes) are returned.
+	Languages []string
+
+	// If positive, only documents with a PageRank score of at least
+	// MinPageRank are returned.
+	MinPageRank float64
+}
+
+// IsZero returns true if f does not filter out any documents.
+func (f Filters) IsZero() bool {
+	return len(f.Domains) == 0 && f.IndexedAfter.IsZero() && f.IndexedBefore.IsZero() &&
+		len(f.Languages) == 0 && f.MinPageRank <= 0
+}
+
+// FiltersOf returns the filters of the query with the domains and languages
+// lower-cased and stripped of surrounding whitespace. Domains are also
+// stripped of any leading wildcard label (e.g. "*.example.com") and empty
+// entries are dropped.
+func FiltersOf(q Query) Filters {
+	f := q.Filters
+	f.Domains = normalizeFilterValues(f.Domains, func(v string) string {
+		return strings.Trim(strings.TrimPrefix(v, "*."), ".")
+	})
+	f.Languages = normalizeFilterValues(f.Languages, func(v string) string { return v })
+	return f
+}
+
+func normalizeFilterValues(values []string, normalize func(string) string) []string {
+	var out []string
+	for _, v := range values {
+		if v = normalize(strings.ToLower(strings.TrimSpace(v))); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// DomainsOf returns the host of the document URL followed by each of its
+// parent domains (e.g. "www.example.com", "example.com" and "com") or nil
+// if the URL cannot be parsed.
+func DomainsOf(doc *Document) []string {
+	host := HostOf(doc)
+	if host == "" {
+		return nil
+	}
+	domains := []string{host}
+	for {
+		dot := strings.IndexByte(host, '.')
+		if dot == -1 {
+			return domains
+		}
+		host = host[dot+1:]
+		domains = append(domains, host)
+	}
+}
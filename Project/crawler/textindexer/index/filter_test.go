@@ -0,0 +1,32 @@
+package index
+
+import (
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(FilterTestSuite))
+
+type FilterTestSuite struct{}
+
+func (s *FilterTestSuite) TestFiltersOf(c *gc.C) {
+	c.Assert(FiltersOf(Query{}).IsZero(), gc.Equals, true)
+
+	f := FiltersOf(Query{Filters: Filters{
+		Domains:   []string{" Example.COM", "*.example.org", ".example.net.", " "},
+		Languages: []string{"EN", ""},
+	}})
+	c.Assert(f.Domains, gc.DeepEquals, []string{"example.com", "example.org", "example.net"})
+	c.Assert(f.Languages, gc.DeepEquals, []string{"en"})
+	c.Assert(f.IsZero(), gc.Equals, false)
+
+	c.Assert(Filters{IndexedBefore: time.Now()}.IsZero(), gc.Equals, false)
+	c.Assert(Filters{MinPageRank: 0.1}.IsZero(), gc.Equals, false)
+}
+
+func (s *FilterTestSuite) TestDomainsOf(c *gc.C) {
+	c.Assert(DomainsOf(&Document{URL: "https://WWW.Example.com:8080/about"}), gc.DeepEquals, []string{"www.example.com", "example.com", "com"})
+	c.Assert(DomainsOf(&Document{URL: "http://localhost/"}), gc.DeepEquals, []string{"localhost"})
+	c.Assert(DomainsOf(&Document{URL: "%zz"}), gc.IsNil)
+}
@@ -3,6 +3,7 @@ package indextest
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 	"webcrawler/crawler/textindexer/index"
 
@@ -228,6 +229,401 @@ func (s *SuiteBase) TestMatchSearchWithOffset(c *gc.C) {
 	c.Assert(iterateDocs(c, it), gc.HasLen, 0)
 }
 
+// TestSearchWithSize verifies that search iterators return at most the
+// number of results requested by the query.
+func (s *SuiteBase) TestSearchWithSize(c *gc.C) {
+	var (
+		numDocs = 30
+		expIDs  []uuid.UUID
+	)
+	for i := 0; i < numDocs; i++ {
+		id := uuid.New()
+		expIDs = append(expIDs, id)
+		doc := &index.Document{
+			LinkID:  id,
+			Title:   fmt.Sprintf("doc with ID %s", id.String()),
+			Content: "Ovidius poeta in terra pontica",
+		}
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+		c.Assert(s.idx.UpdateScore(id, float64(numDocs-i)), gc.IsNil)
+	}
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "poeta", Offset: 5, Size: 12})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.TotalCount(), gc.Equals, uint64(numDocs))
+	c.Assert(iterateDocs(c, it), gc.DeepEquals, expIDs[5:17])
+
+	// A size past the end of the result set returns the remaining results.
+	it, err = s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "poeta", Offset: 25, Size: 12})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.DeepEquals, expIDs[25:])
+}
+
+// TestSearchOffsetTooLarge verifies that searches which skip more results
+// than the default maximum offset are rejected.
+func (s *SuiteBase) TestSearchOffsetTooLarge(c *gc.C) {
+	c.Assert(s.idx.Index(&index.Document{LinkID: uuid.New(), Content: "Ovidius poeta in terra pontica"}), gc.IsNil)
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "poeta", Offset: index.DefaultMaxOffset})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.HasLen, 0)
+
+	_, err = s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "poeta", Offset: index.DefaultMaxOffset + 1})
+	c.Assert(errors.Is(err, index.ErrOffsetTooLarge), gc.Equals, true)
+}
+
+// TestSearchPaginationMetadata verifies that search iterators report the
+// total number of matching documents and the max relevance score.
+func (s *SuiteBase) TestSearchPaginationMetadata(c *gc.C) {
+	numDocs := 25
+	for i := 0; i < numDocs; i++ {
+		doc := &index.Document{
+			LinkID:  uuid.New(),
+			Title:   fmt.Sprintf("doc %d", i),
+			Content: "Ovidius poeta in terra pontica",
+		}
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+	}
+	c.Assert(s.idx.Index(&index.Document{LinkID: uuid.New(), Content: "lorem ipsum"}), gc.IsNil)
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "poeta", Offset: 10})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.TotalCount(), gc.Equals, uint64(numDocs))
+	c.Assert(it.MaxScore() > 0, gc.Equals, true)
+	c.Assert(iterateDocs(c, it), gc.HasLen, numDocs-10)
+
+	it, err = s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "nothing"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.TotalCount(), gc.Equals, uint64(0))
+	c.Assert(iterateDocs(c, it), gc.HasLen, 0)
+}
+
+// TestBooleanSearch verifies the document search logic when using the
+// boolean query syntax.
+func (s *SuiteBase) TestBooleanSearch(c *gc.C) {
+	docs := []*index.Document{
+		{LinkID: uuid.New(), URL: "http://example.com/a", Title: "Ovidius", Content: "poeta in terra pontica"},
+		{LinkID: uuid.New(), URL: "http://example.com/b", Title: "Lorem", Content: "lorem ipsum dolor poeta"},
+		{LinkID: uuid.New(), URL: "http://other.com/c", Title: "Ipsum", Content: "lorem dolor ipsum"},
+		{LinkID: uuid.New(), URL: "http://other.com/d", Title: "Misc", Content: "nothing to see here"},
+	}
+	for i, doc := range docs {
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+		c.Assert(s.idx.UpdateScore(doc.LinkID, float64(len(docs)-i)), gc.IsNil)
+	}
+
+	specs := []struct {
+		expr   string
+		expIDs []uuid.UUID
+	}{
+		{expr: "lorem AND poeta", expIDs: []uuid.UUID{docs[1].LinkID}},
+		{expr: "ovidius OR misc", expIDs: []uuid.UUID{docs[0].LinkID, docs[3].LinkID}},
+		{expr: "lorem -poeta", expIDs: []uuid.UUID{docs[2].LinkID}},
+		{expr: `"lorem ipsum"`, expIDs: []uuid.UUID{docs[1].LinkID}},
+		{expr: "title:ipsum", expIDs: []uuid.UUID{docs[2].LinkID}},
+		{expr: "poeta url:example.com", expIDs: []uuid.UUID{docs[0].LinkID, docs[1].LinkID}},
+		{expr: "(ovidius OR ipsum) NOT title:lorem", expIDs: []uuid.UUID{docs[0].LinkID, docs[2].LinkID}},
+	}
+
+	for _, spec := range specs {
+		it, err := s.idx.Search(index.Query{
+			Type:       index.QueryTypeBoolean,
+			Expression: spec.expr,
+		})
+		c.Assert(err, gc.IsNil, gc.Commentf("query %q", spec.expr))
+		c.Assert(iterateDocs(c, it), gc.DeepEquals, spec.expIDs, gc.Commentf("query %q", spec.expr))
+	}
+
+	_, err := s.idx.Search(index.Query{
+		Type:       index.QueryTypeBoolean,
+		Expression: `title:"unterminated`,
+	})
+	c.Assert(errors.Is(err, index.ErrInvalidQuery), gc.Equals, true)
+}
+
+// TestSearchFacets verifies that search results can be aggregated by host,
+// language and indexing date.
+func (s *SuiteBase) TestSearchFacets(c *gc.C) {
+	docs := []*index.Document{
+		{LinkID: uuid.New(), URL: "http://example.com/a", Language: "en", Content: "poeta in terra pontica"},
+		{LinkID: uuid.New(), URL: "http://EXAMPLE.com/b", Language: "de", Content: "poeta ipsum"},
+		{LinkID: uuid.New(), URL: "http://example.com/c", Language: "en", Content: "lorem poeta"},
+		{LinkID: uuid.New(), URL: "http://other.com/d", Language: "en", Content: "poeta dolor"},
+		{LinkID: uuid.New(), URL: "http://other.com/e", Language: "fr", Content: "nothing to see here"},
+	}
+	for _, doc := range docs {
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+	}
+
+	it, err := s.idx.Search(index.Query{
+		Type:       index.QueryTypeMatch,
+		Expression: "poeta",
+		Facets: []index.FacetRequest{
+			{Field: index.FacetHost},
+			{Field: index.FacetLanguage, Size: 1},
+			{Field: index.FacetIndexedDate},
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.HasLen, 4)
+
+	today := index.IndexedDateOf(docs[0])
+	c.Assert(it.Facets(), gc.DeepEquals, []index.Facet{
+		{
+			Field: index.FacetHost,
+			Buckets: []index.FacetBucket{
+				{Value: "example.com", Count: 3},
+				{Value: "other.com", Count: 1},
+			},
+		},
+		{
+			Field: index.FacetLanguage,
+			Buckets: []index.FacetBucket{
+				{Value: "en", Count: 3},
+			},
+		},
+		{
+			Field: index.FacetIndexedDate,
+			Buckets: []index.FacetBucket{
+				{Value: today, Count: 4},
+			},
+		},
+	})
+
+	// Searches without facet requests should not return any facets.
+	it, err = s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "poeta"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Facets(), gc.HasLen, 0)
+	c.Assert(it.Close(), gc.IsNil)
+}
+
+// TestSearchVerticals verifies that searches can be restricted to a single
+// search vertical and that documents without a vertical are treated as
+// web-pages.
+func (s *SuiteBase) TestSearchVerticals(c *gc.C) {
+	docs := []*index.Document{
+		{LinkID: uuid.New(), URL: "http://example.com/a", Content: "annual report"},
+		{LinkID: uuid.New(), URL: "http://example.com/b.pdf", Content: "annual report", Vertical: index.VerticalDocuments},
+		{LinkID: uuid.New(), URL: "http://example.com/c", Content: "annual report published", Vertical: index.VerticalNews},
+		{LinkID: uuid.New(), URL: "http://example.com/d", Content: "annual report", Vertical: index.VerticalWeb},
+	}
+	for i, doc := range docs {
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+		c.Assert(s.idx.UpdateScore(doc.LinkID, float64(len(docs)-i)), gc.IsNil)
+	}
+
+	doc, err := s.idx.FindByID(docs[0].LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Vertical, gc.Equals, index.VerticalWeb)
+
+	specs := []struct {
+		vertical string
+		expIDs   []uuid.UUID
+	}{
+		{vertical: "", expIDs: []uuid.UUID{docs[0].LinkID, docs[1].LinkID, docs[2].LinkID, docs[3].LinkID}},
+		{vertical: index.VerticalWeb, expIDs: []uuid.UUID{docs[0].LinkID, docs[3].LinkID}},
+		{vertical: index.VerticalDocuments, expIDs: []uuid.UUID{docs[1].LinkID}},
+		{vertical: index.VerticalNews, expIDs: []uuid.UUID{docs[2].LinkID}},
+	}
+	for _, spec := range specs {
+		it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "annual report", Vertical: spec.vertical})
+		c.Assert(err, gc.IsNil, gc.Commentf("vertical %q", spec.vertical))
+		c.Assert(iterateDocs(c, it), gc.DeepEquals, spec.expIDs, gc.Commentf("vertical %q", spec.vertical))
+	}
+
+	it, err := s.idx.Search(index.Query{
+		Type:       index.QueryTypeMatch,
+		Expression: "annual report",
+		Facets:     []index.FacetRequest{{Field: index.FacetVertical}},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Facets(), gc.DeepEquals, []index.Facet{
+		{
+			Field: index.FacetVertical,
+			Buckets: []index.FacetBucket{
+				{Value: index.VerticalWeb, Count: 2},
+				{Value: index.VerticalDocuments, Count: 1},
+				{Value: index.VerticalNews, Count: 1},
+			},
+		},
+	})
+	c.Assert(it.Close(), gc.IsNil)
+}
+
+// TestSearchFilters verifies that search results can be restricted by domain,
+// indexing time, language and PageRank score.
+func (s *SuiteBase) TestSearchFilters(c *gc.C) {
+	docs := []*index.Document{
+		{LinkID: uuid.New(), URL: "http://example.com/a", Content: "annual report", Language: "en"},
+		{LinkID: uuid.New(), URL: "http://blog.example.com/b", Content: "annual report", Language: "de"},
+		{LinkID: uuid.New(), URL: "http://example.org/c", Content: "annual report", Language: "en"},
+		{LinkID: uuid.New(), URL: "http://notexample.com/d", Content: "annual report", Language: "fr"},
+	}
+	var cutoff time.Time
+	for i, doc := range docs {
+		if i == 2 {
+			// Make sure that the last two documents are indexed at
+			// least a few milliseconds after the first two.
+			time.Sleep(20 * time.Millisecond)
+		}
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+		c.Assert(s.idx.UpdateScore(doc.LinkID, float64(len(docs)-i)), gc.IsNil)
+		if i == 2 {
+			got, err := s.idx.FindByID(doc.LinkID)
+			c.Assert(err, gc.IsNil)
+			cutoff = got.IndexedAt
+		}
+	}
+
+	specs := []struct {
+		descr   string
+		filters index.Filters
+		expIDs  []uuid.UUID
+	}{
+		{descr: "no filters", expIDs: []uuid.UUID{docs[0].LinkID, docs[1].LinkID, docs[2].LinkID, docs[3].LinkID}},
+		{descr: "domain", filters: index.Filters{Domains: []string{"example.com"}}, expIDs: []uuid.UUID{docs[0].LinkID, docs[1].LinkID}},
+		{descr: "domains", filters: index.Filters{Domains: []string{"EXAMPLE.org", "blog.example.com"}}, expIDs: []uuid.UUID{docs[1].LinkID, docs[2].LinkID}},
+		{descr: "language", filters: index.Filters{Languages: []string{"en"}}, expIDs: []uuid.UUID{docs[0].LinkID, docs[2].LinkID}},
+		{descr: "languages", filters: index.Filters{Languages: []string{"de", "fr"}}, expIDs: []uuid.UUID{docs[1].LinkID, docs[3].LinkID}},
+		{descr: "min PageRank", filters: index.Filters{MinPageRank: 2}, expIDs: []uuid.UUID{docs[0].LinkID, docs[1].LinkID, docs[2].LinkID}},
+		{descr: "indexed after", filters: index.Filters{IndexedAfter: cutoff}, expIDs: []uuid.UUID{docs[2].LinkID, docs[3].LinkID}},
+		{descr: "indexed before", filters: index.Filters{IndexedBefore: cutoff}, expIDs: []uuid.UUID{docs[0].LinkID, docs[1].LinkID}},
+		{
+			descr:   "combined",
+			filters: index.Filters{Domains: []string{"example.com"}, Languages: []string{"en"}, MinPageRank: 3},
+			expIDs:  []uuid.UUID{docs[0].LinkID},
+		},
+	}
+	for _, spec := range specs {
+		it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "annual report", Filters: spec.filters})
+		c.Assert(err, gc.IsNil, gc.Commentf(spec.descr))
+		c.Assert(iterateDocs(c, it), gc.DeepEquals, spec.expIDs, gc.Commentf(spec.descr))
+	}
+}
+
+// TestFieldBoosts verifies that with the default field boosts title matches
+// outrank URL matches which in turn outrank content matches.
+func (s *SuiteBase) TestFieldBoosts(c *gc.C) {
+	docs := []*index.Document{
+		{LinkID: uuid.New(), URL: "http://example.com/a", Title: "Ipsum", Content: "lorem"},
+		{LinkID: uuid.New(), URL: "http://example.com/lorem", Title: "Dolor", Content: "sit amet"},
+		{LinkID: uuid.New(), URL: "http://example.com/c", Title: "Lorem", Content: "ipsum dolor"},
+	}
+	for _, doc := range docs {
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+	}
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "lorem"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.DeepEquals, []uuid.UUID{docs[2].LinkID, docs[1].LinkID, docs[0].LinkID})
+}
+
+// TestLanguageAnalyzers verifies that the document language is detected at
+// indexing time and that the language-specific analyzers allow inflected
+// forms of the query terms to match.
+func (s *SuiteBase) TestLanguageAnalyzers(c *gc.C) {
+	docs := []*index.Document{
+		{LinkID: uuid.New(), Title: "Running", Content: "The runners were running in the park and they will be running there again"},
+		{LinkID: uuid.New(), Title: "Häuser", Content: "Die Kinder sind in den Häusern und wir werden dort wieder spielen", Language: "de-DE"},
+	}
+	for _, doc := range docs {
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+	}
+
+	doc, err := s.idx.FindByID(docs[0].LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Language, gc.Equals, "en")
+
+	doc, err = s.idx.FindByID(docs[1].LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Language, gc.Equals, "de")
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "run"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.DeepEquals, []uuid.UUID{docs[0].LinkID})
+
+	it, err = s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "haus"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.DeepEquals, []uuid.UUID{docs[1].LinkID})
+}
+
+// TestSuggestions verifies that corrections are suggested for misspelled
+// search terms of queries that match few documents.
+func (s *SuiteBase) TestSuggestions(c *gc.C) {
+	for i := 0; i < 3; i++ {
+		c.Assert(s.idx.Index(&index.Document{
+			LinkID:  uuid.New(),
+			Title:   fmt.Sprintf("Tristia %d", i),
+			Content: "Ovidius poeta in terra pontica",
+		}), gc.IsNil)
+	}
+	for i := 0; i < index.SuggestionThreshold; i++ {
+		c.Assert(s.idx.Index(&index.Document{
+			LinkID:  uuid.New(),
+			Content: "Lorem ipsum dolor sit amet",
+		}), gc.IsNil)
+	}
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "Ovidus poeta"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.TotalCount(), gc.Equals, uint64(3))
+	suggestions := it.Suggestions()
+	c.Assert(suggestions, gc.Not(gc.HasLen), 0)
+	c.Assert(suggestions[0].Expression, gc.Equals, "ovidius poeta")
+	c.Assert(it.Close(), gc.IsNil)
+
+	it, err = s.idx.Search(index.Query{Type: index.QueryTypeBoolean, Expression: "ovidus OR amet"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Suggestions(), gc.IsNil, gc.Commentf("expected no suggestions for queries with many results"))
+	c.Assert(it.Close(), gc.IsNil)
+
+	it, err = s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "ovidius"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Suggestions(), gc.IsNil, gc.Commentf("expected no suggestions for correctly spelled terms"))
+	c.Assert(it.Close(), gc.IsNil)
+}
+
+// TestSuggest checks that titles are suggested for autocomplete prefixes.
+func (s *SuiteBase) TestSuggest(c *gc.C) {
+	docs := []struct {
+		title string
+		score float64
+	}{
+		{"Tristia liber primus", 0.9},
+		{"Metamorphoses", 0.8},
+		{"Tristia liber secundus", 0.5},
+		{"Tristia liber primus", 0.3},
+		{"Ars amatoria", 0.1},
+	}
+	for _, d := range docs {
+		id := uuid.New()
+		c.Assert(s.idx.Index(&index.Document{LinkID: id, Title: d.title, Content: "Ovidius poeta"}), gc.IsNil)
+		c.Assert(s.idx.UpdateScore(id, d.score), gc.IsNil)
+	}
+
+	specs := []struct {
+		prefix string
+		limit  int
+		exp    []string
+	}{
+		{prefix: "tri", limit: 10, exp: []string{"Tristia liber primus", "Tristia liber secundus"}},
+		{prefix: "Tri", limit: 1, exp: []string{"Tristia liber primus"}},
+		{prefix: "tristia liber s", limit: 10, exp: []string{"Tristia liber secundus"}},
+		{prefix: "meta", limit: 10, exp: []string{"Metamorphoses"}},
+		{prefix: "qwx", limit: 10},
+		{prefix: " ", limit: 10},
+		{prefix: "tri", limit: 0},
+	}
+	for _, spec := range specs {
+		titles, err := s.idx.Suggest(spec.prefix, spec.limit)
+		c.Assert(err, gc.IsNil, gc.Commentf("prefix %q", spec.prefix))
+		c.Assert(titles, gc.HasLen, len(spec.exp), gc.Commentf("prefix %q", spec.prefix))
+		if len(spec.exp) != 0 {
+			c.Assert(titles, gc.DeepEquals, spec.exp, gc.Commentf("prefix %q", spec.prefix))
+		}
+	}
+}
+
 // TestUpdateScore checks that PageRank score updates work as expected.
 func (s *SuiteBase) TestUpdateScore(c *gc.C) {
 	var (
@@ -289,6 +685,403 @@ func (s *SuiteBase) TestUpdateScoreForUnknownDocument(c *gc.C) {
 	c.Assert(doc.PageRank, gc.Equals, 0.5)
 }
 
+// TestBulkUpdateScores checks that the PageRank scores of multiple documents
+// can be updated at once. It is skipped for indexers that do not implement
+// index.BulkScoreUpdater.
+func (s *SuiteBase) TestBulkUpdateScores(c *gc.C) {
+	updater, ok := s.idx.(index.BulkScoreUpdater)
+	if !ok {
+		c.Skip("indexer does not implement index.BulkScoreUpdater")
+	}
+
+	doc := &index.Document{LinkID: uuid.New(), Title: "Tristia", Content: "Ovidius poeta in terra pontica"}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+	unknownID := uuid.New()
+
+	c.Assert(updater.UpdateScores(map[uuid.UUID]float64{doc.LinkID: 0.7, unknownID: 0.3}), gc.IsNil)
+
+	got, err := s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Title, gc.Equals, doc.Title)
+	c.Assert(got.Content, gc.Equals, doc.Content)
+	c.Assert(got.PageRank, gc.Equals, 0.7)
+
+	// Placeholder documents are created for unknown link IDs.
+	got, err = s.idx.FindByID(unknownID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.PageRank, gc.Equals, 0.3)
+
+	c.Assert(updater.UpdateScores(nil), gc.IsNil)
+}
+
+// TestPatch checks that documents can be partially updated and that redacted
+// fields are no longer searchable.
+func (s *SuiteBase) TestPatch(c *gc.C) {
+	doc := &index.Document{
+		LinkID:  uuid.New(),
+		URL:     "http://example.com",
+		Title:   "Secret title",
+		Content: "Ovidius poeta in terra pontica",
+	}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+	c.Assert(s.idx.UpdateScore(doc.LinkID, 0.5), gc.IsNil)
+
+	redacted, newContent := "", "Vergilius poeta"
+	err := s.idx.Patch(doc.LinkID, index.DocumentPatch{Title: &redacted, Content: &newContent})
+	c.Assert(err, gc.IsNil)
+
+	got, err := s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.URL, gc.Equals, doc.URL)
+	c.Assert(got.Title, gc.Equals, "")
+	c.Assert(got.Content, gc.Equals, newContent)
+	c.Assert(got.PageRank, gc.Equals, 0.5)
+
+	for _, expr := range []string{"secret", "ovidius"} {
+		it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: expr})
+		c.Assert(err, gc.IsNil)
+		c.Assert(iterateDocs(c, it), gc.HasLen, 0, gc.Commentf("query %q", expr))
+	}
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "vergilius"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.DeepEquals, []uuid.UUID{doc.LinkID})
+}
+
+// TestSummary checks that document summaries are persisted and that they are
+// cleared when the document content is patched.
+func (s *SuiteBase) TestSummary(c *gc.C) {
+	doc := &index.Document{
+		LinkID:  uuid.New(),
+		URL:     "http://example.com",
+		Content: "Ovidius poeta in terra pontica. Ovidius scripsit.",
+		Summary: "Ovidius poeta in terra pontica.",
+	}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+
+	got, err := s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Summary, gc.Equals, doc.Summary)
+
+	title := "Tristia"
+	c.Assert(s.idx.Patch(doc.LinkID, index.DocumentPatch{Title: &title}), gc.IsNil)
+	got, err = s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Summary, gc.Equals, doc.Summary)
+
+	redacted := ""
+	c.Assert(s.idx.Patch(doc.LinkID, index.DocumentPatch{Content: &redacted}), gc.IsNil)
+	got, err = s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Summary, gc.Equals, "")
+}
+
+// TestPatchUnknownDocument checks that patching an unknown document returns
+// ErrNotFound.
+func (s *SuiteBase) TestPatchUnknownDocument(c *gc.C) {
+	title := "foo"
+	err := s.idx.Patch(uuid.New(), index.DocumentPatch{Title: &title})
+	c.Assert(errors.Is(err, index.ErrNotFound), gc.Equals, true)
+}
+
+// TestBlobRefs checks that blob references are persisted and that they are
+// not cleared when a document is re-indexed without them.
+func (s *SuiteBase) TestBlobRefs(c *gc.C) {
+	doc := &index.Document{
+		LinkID:       uuid.New(),
+		URL:          "http://example.com",
+		Title:        "Illustrious home page",
+		FaviconRef:   "favicons/example.com",
+		ThumbnailRef: "thumbnails/1",
+	}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+
+	doc.FaviconRef, doc.ThumbnailRef = "", ""
+	doc.Title = "Illustrious home page v2"
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+
+	got, err := s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Title, gc.Equals, doc.Title)
+	c.Assert(got.FaviconRef, gc.Equals, "favicons/example.com")
+	c.Assert(got.ThumbnailRef, gc.Equals, "thumbnails/1")
+}
+
+// TestDuplicateReference checks that content fingerprints and references to
+// canonical documents are persisted and that re-indexing a former duplicate
+// clears its reference.
+func (s *SuiteBase) TestDuplicateReference(c *gc.C) {
+	canonical := &index.Document{
+		LinkID:      uuid.New(),
+		URL:         "http://example.com/ovid",
+		Title:       "Ovidius",
+		Content:     "Ovidius poeta in terra pontica",
+		Fingerprint: 0xfedcba9876543210,
+	}
+	c.Assert(s.idx.Index(canonical), gc.IsNil)
+
+	dup := &index.Document{
+		LinkID:      uuid.New(),
+		URL:         "http://mirror.example.com/ovid",
+		Fingerprint: 0xfedcba9876543211,
+		DuplicateOf: canonical.LinkID,
+	}
+	c.Assert(s.idx.Index(dup), gc.IsNil)
+
+	got, err := s.idx.FindByID(dup.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Fingerprint, gc.Equals, dup.Fingerprint)
+	c.Assert(got.DuplicateOf, gc.Equals, canonical.LinkID)
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "pontica"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.DeepEquals, []uuid.UUID{canonical.LinkID})
+
+	dup.DuplicateOf = uuid.Nil
+	dup.Title, dup.Content = "Ovidius", "Ovidius poeta in terra pontica"
+	c.Assert(s.idx.Index(dup), gc.IsNil)
+	got, err = s.idx.FindByID(dup.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.DuplicateOf, gc.Equals, uuid.Nil)
+}
+
+// TestChangeDetection checks that the content hash and the time of the last
+// content change of a document are persisted and cleared by re-indexing the
+// document without them.
+func (s *SuiteBase) TestChangeDetection(c *gc.C) {
+	doc := &index.Document{
+		LinkID:    uuid.New(),
+		URL:       "http://example.com/fasti",
+		Title:     "Fasti",
+		Content:   "Tempora cum causis Latium digesta per annum",
+		ChangedAt: time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC),
+	}
+	doc.ContentHash = index.ContentHash(doc)
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+
+	got, err := s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.ContentHash, gc.Equals, doc.ContentHash)
+	c.Assert(got.ChangedAt.Equal(doc.ChangedAt), gc.Equals, true, gc.Commentf("got %v", got.ChangedAt))
+
+	doc.ContentHash, doc.ChangedAt = 0, time.Time{}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+	got, err = s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.ContentHash, gc.Equals, uint64(0))
+	c.Assert(got.ChangedAt.IsZero(), gc.Equals, true)
+}
+
+// TestFreshnessRanking checks that recently indexed documents can be ranked
+// above documents with a higher PageRank score.
+func (s *SuiteBase) TestFreshnessRanking(c *gc.C) {
+	older := &index.Document{LinkID: uuid.New(), Title: "Tristia", Content: "Ovidius poeta in terra pontica"}
+	c.Assert(s.idx.Index(older), gc.IsNil)
+	c.Assert(s.idx.UpdateScore(older.LinkID, 1), gc.IsNil)
+
+	time.Sleep(20 * time.Millisecond)
+	newer := &index.Document{LinkID: uuid.New(), Title: "Tristia", Content: "Ovidius poeta in terra pontica"}
+	c.Assert(s.idx.Index(newer), gc.IsNil)
+	got, err := s.idx.FindByID(newer.LinkID)
+	c.Assert(err, gc.IsNil)
+
+	it, err := s.idx.Search(index.Query{Expression: "poeta"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.DeepEquals, []uuid.UUID{older.LinkID, newer.LinkID})
+
+	// Ages are measured against the indexing time of the newer document
+	// so that the older one is at least twice the decay scale old.
+	for _, decay := range []index.DecayFunction{index.DecayGauss, index.DecayExp, index.DecayLinear} {
+		it, err = s.idx.Search(index.Query{
+			Expression: "poeta",
+			Ranking: &index.Ranking{
+				PageRankWeight:  1,
+				FreshnessWeight: 10,
+				Decay:           decay,
+				Origin:          got.IndexedAt,
+				Scale:           10 * time.Millisecond,
+			},
+		})
+		c.Assert(err, gc.IsNil)
+		c.Assert(iterateDocs(c, it), gc.DeepEquals, []uuid.UUID{newer.LinkID, older.LinkID}, gc.Commentf("decay %s", decay))
+	}
+}
+
+// TestAuthorAndPublicationDate checks that the extracted author and
+// publication date of a document are persisted.
+func (s *SuiteBase) TestAuthorAndPublicationDate(c *gc.C) {
+	doc := &index.Document{
+		LinkID:      uuid.New(),
+		URL:         "http://example.com/tristia",
+		Title:       "Tristia",
+		Content:     "Parve nec invideo sine me liber ibis in urbem",
+		Author:      "Publius Ovidius Naso",
+		PublishedAt: time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC),
+	}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+
+	got, err := s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Author, gc.Equals, doc.Author)
+	c.Assert(got.PublishedAt.Equal(doc.PublishedAt), gc.Equals, true, gc.Commentf("got %s", got.PublishedAt))
+
+	// Re-indexing a document without a known date clears it.
+	doc.Author, doc.PublishedAt = "", time.Time{}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+	got, err = s.idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Author, gc.Equals, "")
+	c.Assert(got.PublishedAt.IsZero(), gc.Equals, true)
+}
+
+// TestHeaderFilter checks that captured response headers are persisted and
+// that documents can be filtered by header values.
+func (s *SuiteBase) TestHeaderFilter(c *gc.C) {
+	docs := []*index.Document{
+		{
+			LinkID: uuid.New(), URL: "http://example.com/a", Title: "Ovidius", Content: "poeta in terra pontica",
+			Headers: map[string]string{"x-generator": "WordPress 6.4", "server": "nginx"},
+		},
+		{
+			LinkID: uuid.New(), URL: "http://example.com/b", Title: "Vergilius", Content: "poeta in terra italica",
+			Headers: map[string]string{"x-generator": "Hugo 0.120", "server": "Apache"},
+		},
+		{LinkID: uuid.New(), URL: "http://example.com/c", Title: "Horatius", Content: "poeta lyricus"},
+	}
+	for i, doc := range docs {
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+		c.Assert(s.idx.UpdateScore(doc.LinkID, float64(len(docs)-i)), gc.IsNil)
+	}
+
+	got, err := s.idx.FindByID(docs[0].LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Headers, gc.DeepEquals, docs[0].Headers)
+
+	specs := []struct {
+		expr   string
+		expIDs []uuid.UUID
+	}{
+		{expr: "header.x-generator:wordpress", expIDs: []uuid.UUID{docs[0].LinkID}},
+		{expr: `header.X-Generator:"hugo 0.120"`, expIDs: []uuid.UUID{docs[1].LinkID}},
+		{expr: "poeta -header.server:nginx", expIDs: []uuid.UUID{docs[1].LinkID, docs[2].LinkID}},
+		{expr: "header.server:iis", expIDs: nil},
+		{expr: "nginx", expIDs: nil},
+	}
+	for _, spec := range specs {
+		it, err := s.idx.Search(index.Query{
+			Type:       index.QueryTypeBoolean,
+			Expression: spec.expr,
+		})
+		c.Assert(err, gc.IsNil, gc.Commentf("query %q", spec.expr))
+		c.Assert(iterateDocs(c, it), gc.DeepEquals, spec.expIDs, gc.Commentf("query %q", spec.expr))
+	}
+
+	// Re-indexing a document replaces its previously captured headers.
+	docs[0].Headers = map[string]string{"x-generator": "Hugo 0.121"}
+	c.Assert(s.idx.Index(docs[0]), gc.IsNil)
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeBoolean, Expression: "header.server:nginx"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateDocs(c, it), gc.HasLen, 0)
+
+	got, err = s.idx.FindByID(docs[0].LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Headers, gc.DeepEquals, docs[0].Headers)
+}
+
+// TestAll checks that All returns every document in link ID order and that
+// iteration can be resumed from a cursor.
+func (s *SuiteBase) TestAll(c *gc.C) {
+	numDocs := 25
+	var expIDs []uuid.UUID
+	for i := 0; i < numDocs; i++ {
+		doc := &index.Document{
+			LinkID:  uuid.New(),
+			URL:     fmt.Sprintf("http://example.com/%d", i),
+			Content: "lorem ipsum",
+		}
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+		expIDs = append(expIDs, doc.LinkID)
+	}
+	sort.Slice(expIDs, func(i, j int) bool { return expIDs[i].String() < expIDs[j].String() })
+
+	it, err := s.idx.All("")
+	c.Assert(err, gc.IsNil)
+	var (
+		seen   []uuid.UUID
+		cursor index.Cursor
+	)
+	for it.Next() {
+		seen = append(seen, it.Document().LinkID)
+		if len(seen) == 10 {
+			cursor = it.Cursor()
+		}
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(seen, gc.DeepEquals, expIDs)
+
+	it, err = s.idx.All(cursor)
+	c.Assert(err, gc.IsNil)
+	c.Assert(iterateAll(c, it), gc.DeepEquals, expIDs[10:])
+
+	_, err = s.idx.All(index.Cursor("not-a-cursor"))
+	c.Assert(errors.Is(err, index.ErrInvalidCursor), gc.Equals, true)
+}
+
+// TestWalkAllResumesFromCheckpoint checks that a walk that is interrupted by
+// an error resumes from the last checkpoint.
+func (s *SuiteBase) TestWalkAllResumesFromCheckpoint(c *gc.C) {
+	numDocs := 15
+	for i := 0; i < numDocs; i++ {
+		doc := &index.Document{LinkID: uuid.New(), URL: fmt.Sprintf("http://example.com/%d", i)}
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+	}
+
+	var (
+		store   = index.NewInMemoryCheckpointStore()
+		visited = make(map[uuid.UUID]int)
+		errStop = errors.New("stop")
+	)
+	err := index.WalkAll(s.idx, store, "test", 5, func(doc *index.Document) error {
+		if len(visited) == 7 {
+			return errStop
+		}
+		visited[doc.LinkID]++
+		return nil
+	})
+	c.Assert(errors.Is(err, errStop), gc.Equals, true)
+
+	cursor, err := store.LoadCheckpoint("test")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cursor, gc.Not(gc.Equals), index.Cursor(""))
+
+	err = index.WalkAll(s.idx, store, "test", 5, func(doc *index.Document) error {
+		visited[doc.LinkID]++
+		return nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(visited, gc.HasLen, numDocs)
+	for linkID, count := range visited {
+		c.Assert(count, gc.Equals, 1, gc.Commentf("document %s visited %d times", linkID, count))
+	}
+
+	// Completed walks clear their checkpoint.
+	cursor, err = store.LoadCheckpoint("test")
+	c.Assert(err, gc.IsNil)
+	c.Assert(cursor, gc.Equals, index.Cursor(""))
+}
+
+func iterateAll(c *gc.C, it index.DocumentIterator) []uuid.UUID {
+	var seen []uuid.UUID
+	for it.Next() {
+		seen = append(seen, it.Document().LinkID)
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	return seen
+}
+
 func iterateDocs(c *gc.C, it index.Iterator) []uuid.UUID {
 	var seen []uuid.UUID
 	for it.Next() {
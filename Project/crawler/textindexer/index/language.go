@@ -0,0 +1,94 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SupportedLanguages lists the ISO 639-1 codes of the languages that indexers
+// apply a language-specific analyzer to.
+var SupportedLanguages = []string{"en", "de", "fr", "es", "it", "nl", "pt"}
+
+// minLanguageHits is the minimum number of stop words that must be found in a
+// text before DetectLanguage reports a language for it.
+const minLanguageHits = 2
+
+// languageStopWords contains a set of frequent, mostly language-specific,
+// words for each supported language.
+var languageStopWords = map[string]map[string]struct{}{
+	"en": wordSet("the and of to in is that it was for on are with as his they be at have this from or by not but what were we when your can said there which she do their if will each about how up out them then many some so these would other into has more her like him see time could no make than been its who now people my made over did down only way find use may long little very after words called just where most know"),
+	"de": wordSet("der die und in den von zu das mit sich des auf für ist im dem nicht ein eine als auch es an werden aus er hat dass sie nach wird bei einer um am sind noch wie einem über einen so zum war haben nur oder aber vor zur bis mehr durch man sein wurde sei"),
+	"fr": wordSet("le de la et les des en un du une que est pour qui dans par plus pas au sur ne se ce il sont les été avec son aux ont mais elle comme ou leur nous cette sa même tout ses deux aussi fait vous entre"),
+	"es": wordSet("de la que el en y los del se las por un para con no una su al lo como más pero sus le ya o este sí porque esta entre cuando muy sin sobre también me hasta hay donde quien desde todo nos durante todos uno les ni contra otros ese eso"),
+	"it": wordSet("di che il la per un non in una sono è mi si ho lo ma ti le da ha con cosa io ci se questo qui hai del della tutto bene gli nel alla anche come più sei perché dei delle degli nella sulla sul quando"),
+	"nl": wordSet("de en van ik te dat die in een hij het niet zijn is was op aan met als voor had er maar om hem dan zou of wat mijn men dit zo door over ze zich bij ook tot je mij uit der daar haar naar heb hoe heeft hebben deze"),
+	"pt": wordSet("de que não o da em um para com uma os no se na por mais as dos como mas ao ele das à seu sua ou quando muito nos já eu também só pelo pela até isso ela entre depois sem mesmo aos seus quem nas esse"),
+}
+
+func wordSet(words string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, w := range strings.Fields(words) {
+		set[w] = struct{}{}
+	}
+	return set
+}
+
+// DetectLanguage attempts to detect the language that text is written in by
+// counting the occurrences of frequent words for each supported language. It
+// returns the ISO 639-1 code of the detected language or an empty string if
+// the language cannot be determined with reasonable confidence.
+func DetectLanguage(text string) string {
+	hits := make(map[string]int, len(languageStopWords))
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, w := range words {
+		for lang, stopWords := range languageStopWords {
+			if _, found := stopWords[w]; found {
+				hits[lang]++
+			}
+		}
+	}
+
+	// Iterate SupportedLanguages rather than the map so ties are broken
+	// deterministically; a tie for first place is treated as ambiguous.
+	var best, bestHits, runnerUpHits = "", 0, 0
+	for _, lang := range SupportedLanguages {
+		switch n := hits[lang]; {
+		case n > bestHits:
+			best, bestHits, runnerUpHits = lang, n, bestHits
+		case n > runnerUpHits:
+			runnerUpHits = n
+		}
+	}
+
+	if bestHits < minLanguageHits || bestHits == runnerUpHits {
+		return ""
+	}
+	return best
+}
+
+// DocumentLanguage returns the language of doc. If the document does not
+// specify a language, it is detected from the document title and content.
+// The returned value is always a lower-case primary language subtag (e.g.
+// "en" for "en-US").
+func DocumentLanguage(doc *Document) string {
+	if doc.Language != "" {
+		lang := strings.ToLower(doc.Language)
+		if sep := strings.IndexAny(lang, "-_"); sep != -1 {
+			lang = lang[:sep]
+		}
+		return lang
+	}
+	return DetectLanguage(doc.Title + " " + doc.Content)
+}
+
+// IsSupportedLanguage returns true if lang is included in SupportedLanguages.
+func IsSupportedLanguage(lang string) bool {
+	for _, supported := range SupportedLanguages {
+		if lang == supported {
+			return true
+		}
+	}
+	return false
+}
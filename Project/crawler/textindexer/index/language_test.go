@@ -0,0 +1,33 @@
+package index
+
+import (
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(LanguageTestSuite))
+
+type LanguageTestSuite struct{}
+
+func (s *LanguageTestSuite) TestDetectLanguage(c *gc.C) {
+	specs := []struct {
+		text    string
+		expLang string
+	}{
+		{text: "The quick brown fox jumps over the lazy dog and runs into the woods", expLang: "en"},
+		{text: "Der schnelle braune Fuchs springt über den faulen Hund und läuft in den Wald", expLang: "de"},
+		{text: "Le renard brun rapide saute par-dessus le chien paresseux et court dans la forêt", expLang: "fr"},
+		{text: "El rápido zorro marrón salta sobre el perro perezoso y corre por el bosque", expLang: "es"},
+		{text: "Ovidius poeta in terra pontica", expLang: ""},
+		{text: "", expLang: ""},
+	}
+
+	for _, spec := range specs {
+		c.Assert(DetectLanguage(spec.text), gc.Equals, spec.expLang, gc.Commentf("text %q", spec.text))
+	}
+}
+
+func (s *LanguageTestSuite) TestDocumentLanguage(c *gc.C) {
+	c.Assert(DocumentLanguage(&Document{Language: "en-US"}), gc.Equals, "en")
+	c.Assert(DocumentLanguage(&Document{Language: "DE"}), gc.Equals, "de")
+	c.Assert(DocumentLanguage(&Document{Title: "Le chien", Content: "Il est dans la maison avec les enfants"}), gc.Equals, "fr")
+}
@@ -0,0 +1,44 @@
+package index
+
+import "fmt"
+
+const (
+	// DefaultBatchSize is the number of search results that indexers fetch
+	// per request to their backing store if no batch size is configured.
+	DefaultBatchSize = 10
+
+	// MaxBatchSize is the largest supported batch size.
+	MaxBatchSize = 1000
+
+	// DefaultMaxOffset is the largest query offset that indexers accept if
+	// no maximum offset is configured. It matches the default result
+	// window of elasticsearch and Meilisearch.
+	DefaultMaxOffset = 10000
+)
+
+// CheckBatchSize returns an error if size is not in the [1, MaxBatchSize]
+// range.
+func CheckBatchSize(size int) error {
+	if size < 1 || size > MaxBatchSize {
+		return fmt.Errorf("batch size must be between 1 and %d (got %d)", MaxBatchSize, size)
+	}
+	return nil
+}
+
+// CheckOffset returns an *OffsetTooLargeError if the query skips more than
+// maxOffset results.
+func CheckOffset(q Query, maxOffset uint64) error {
+	if q.Offset > maxOffset {
+		return &OffsetTooLargeError{Offset: q.Offset, MaxOffset: maxOffset}
+	}
+	return nil
+}
+
+// BatchSizeOf returns the number of results to fetch per request for the
+// query: batchSize or the query size if the query requests fewer results.
+func BatchSizeOf(q Query, batchSize int) int {
+	if q.Size != 0 && q.Size < uint64(batchSize) {
+		return int(q.Size)
+	}
+	return batchSize
+}
@@ -0,0 +1,34 @@
+package index
+
+import (
+	"errors"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(PagingTestSuite))
+
+type PagingTestSuite struct{}
+
+func (s *PagingTestSuite) TestCheckOffset(c *gc.C) {
+	c.Assert(CheckOffset(Query{Offset: 100}, 100), gc.IsNil)
+
+	err := CheckOffset(Query{Offset: 101}, 100)
+	c.Assert(errors.Is(err, ErrOffsetTooLarge), gc.Equals, true)
+	var offsetErr *OffsetTooLargeError
+	c.Assert(errors.As(err, &offsetErr), gc.Equals, true)
+	c.Assert(*offsetErr, gc.Equals, OffsetTooLargeError{Offset: 101, MaxOffset: 100})
+}
+
+func (s *PagingTestSuite) TestCheckBatchSize(c *gc.C) {
+	c.Assert(CheckBatchSize(1), gc.IsNil)
+	c.Assert(CheckBatchSize(MaxBatchSize), gc.IsNil)
+	c.Assert(CheckBatchSize(0), gc.ErrorMatches, "batch size must be between 1 and 1000 \\(got 0\\)")
+	c.Assert(CheckBatchSize(MaxBatchSize+1), gc.NotNil)
+}
+
+func (s *PagingTestSuite) TestBatchSizeOf(c *gc.C) {
+	c.Assert(BatchSizeOf(Query{}, 10), gc.Equals, 10)
+	c.Assert(BatchSizeOf(Query{Size: 3}, 10), gc.Equals, 3)
+	c.Assert(BatchSizeOf(Query{Size: 30}, 10), gc.Equals, 10)
+}
@@ -0,0 +1,303 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// QueryNodeType describes the type of a node in a parsed boolean query.
+type QueryNodeType uint8
+
+const (
+	// QueryNodeTerm matches documents containing Text.
+	QueryNodeTerm QueryNodeType = iota
+
+	// QueryNodePhrase matches documents containing Text as an exact phrase.
+	QueryNodePhrase
+
+	// QueryNodeAnd matches documents that match all child nodes.
+	QueryNodeAnd
+
+	// QueryNodeOr matches documents that match at least one child node.
+	QueryNodeOr
+
+	// QueryNodeNot matches documents that do not match its single child.
+	QueryNodeNot
+)
+
+// Document fields that can be targeted by a field prefix in a boolean query.
+const (
+	FieldTitle   = "Title"
+	FieldURL     = "URL"
+	FieldContent = "Content"
+)
+
+// queryFieldPrefixes maps the (lower-case) field prefixes supported by the
+// boolean query syntax to document field names.
+var queryFieldPrefixes = map[string]string{
+	"title":   FieldTitle,
+	"url":     FieldURL,
+	"content": FieldContent,
+}
+
+// queryField returns the document field for a field prefix.
+func queryField(prefix string) (string, bool) {
+	prefix = strings.ToLower(prefix)
+	if name := strings.TrimPrefix(prefix, "header."); name != prefix && name != "" {
+		return HeaderField(name), true
+	}
+	field, known := queryFieldPrefixes[prefix]
+	return field, known
+}
+
+// QueryNode is a node in the tree produced by ParseBooleanQuery.
+type QueryNode struct {
+	Type QueryNodeType
+
+	// The document field that a term or phrase node applies to. An empty
+	// value indicates that the node applies to all text fields.
+	Field string
+
+	// The text for term and phrase nodes.
+	Text string
+
+	// The child nodes for AND, OR and NOT nodes.
+	Children []*QueryNode
+}
+
+// ParseBooleanQuery parses expr into a QueryNode tree. The supported syntax
+// consists of:
+//   - bare terms and "quoted phrases".
+//   - field prefixes (title:, url:, content:) applied to a term or phrase.
+//   - header prefixes (e.g. header.x-generator:wordpress) that match
+//     documents whose captured response header contains the term or phrase.
+//   - the AND, OR and NOT operators (upper-case) and a leading '-' as a
+//     shorthand for NOT. Terms separated by whitespace are implicitly
+//     joined with AND.
+//   - parentheses for grouping.
+//
+// Operator precedence from highest to lowest is NOT, AND, OR.
+func ParseBooleanQuery(expr string) (*QueryNode, error) {
+	tokens, err := lexBooleanQuery(expr)
+	if err != nil {
+		return nil, fmt.Errorf("parse query: %w", err)
+	}
+
+	p := &queryParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("parse query: %w", err)
+	} else if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("parse query: %w: unexpected %q at offset %d", ErrInvalidQuery, tok.text, tok.pos)
+	}
+
+	return node, nil
+}
+
+type tokenKind uint8
+
+const (
+	tokEOF tokenKind = iota
+	tokTerm
+	tokPhrase
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type queryToken struct {
+	kind  tokenKind
+	field string
+	text  string
+	pos   int
+}
+
+func lexBooleanQuery(expr string) ([]queryToken, error) {
+	var (
+		tokens []queryToken
+		runes  = []rune(expr)
+	)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, queryToken{kind: tokNot, text: "-", pos: i})
+			i++
+		case r == '"':
+			text, next, err := lexPhrase(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: tokPhrase, text: text, pos: i})
+			i = next
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+				i++
+			}
+			word := string(runes[start:i])
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, queryToken{kind: tokAnd, text: word, pos: start})
+				continue
+			case "OR":
+				tokens = append(tokens, queryToken{kind: tokOr, text: word, pos: start})
+				continue
+			case "NOT":
+				tokens = append(tokens, queryToken{kind: tokNot, text: word, pos: start})
+				continue
+			}
+
+			tok := queryToken{kind: tokTerm, text: word, pos: start}
+			if sep := strings.IndexByte(word, ':'); sep > 0 {
+				if field, known := queryField(word[:sep]); known {
+					tok.field, tok.text = field, word[sep+1:]
+
+					// Support field-scoped phrases (e.g. title:"foo bar").
+					if tok.text == "" {
+						if i >= len(runes) || runes[i] != '"' {
+							return nil, fmt.Errorf("%w: missing value for field %q at offset %d", ErrInvalidQuery, word[:sep], start)
+						}
+						text, next, err := lexPhrase(runes, i)
+						if err != nil {
+							return nil, err
+						}
+						tok.kind, tok.text, i = tokPhrase, text, next
+					}
+				}
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+
+	return append(tokens, queryToken{kind: tokEOF, pos: len(runes)}), nil
+}
+
+// lexPhrase reads a quoted phrase starting at runes[start] and returns its
+// contents and the offset immediately after the closing quote.
+func lexPhrase(runes []rune, start int) (string, int, error) {
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] == '"' {
+			return strings.TrimSpace(string(runes[start+1 : i])), i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("%w: unterminated phrase at offset %d", ErrInvalidQuery, start)
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken { return p.tokens[p.pos] }
+func (p *queryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseOr := parseAnd ( OR parseAnd )*
+func (p *queryParser) parseOr() (*QueryNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []*QueryNode{node}
+	for p.peek().kind == tokOr {
+		p.next()
+		if node, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+
+	return joinQueryNodes(QueryNodeOr, children), nil
+}
+
+// parseAnd := parseUnary ( [AND] parseUnary )*
+func (p *queryParser) parseAnd() (*QueryNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []*QueryNode{node}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokTerm, tokPhrase, tokNot, tokLParen:
+			// implicit AND
+		default:
+			return joinQueryNodes(QueryNodeAnd, children), nil
+		}
+
+		if node, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+}
+
+// parseUnary := NOT parseUnary | parsePrimary
+func (p *queryParser) parseUnary() (*QueryNode, error) {
+	if p.peek().kind != tokNot {
+		return p.parsePrimary()
+	}
+
+	p.next()
+	child, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &QueryNode{Type: QueryNodeNot, Children: []*QueryNode{child}}, nil
+}
+
+// parsePrimary := term | phrase | '(' parseOr ')'
+func (p *queryParser) parsePrimary() (*QueryNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokTerm:
+		return &QueryNode{Type: QueryNodeTerm, Field: tok.field, Text: tok.text}, nil
+	case tokPhrase:
+		return &QueryNode{Type: QueryNodePhrase, Field: tok.field, Text: tok.text}, nil
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("%w: missing closing parenthesis for group at offset %d", ErrInvalidQuery, tok.pos)
+		}
+		return node, nil
+	case tokEOF:
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrInvalidQuery)
+	default:
+		return nil, fmt.Errorf("%w: unexpected %q at offset %d", ErrInvalidQuery, tok.text, tok.pos)
+	}
+}
+
+// joinQueryNodes wraps children in a node of the specified type unless only
+// a single child is present.
+func joinQueryNodes(nodeType QueryNodeType, children []*QueryNode) *QueryNode {
+	if len(children) == 1 {
+		return children[0]
+	}
+	return &QueryNode{Type: nodeType, Children: children}
+}
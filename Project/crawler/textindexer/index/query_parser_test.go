@@ -0,0 +1,107 @@
+package index
+
+import (
+	"errors"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(QueryParserTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type QueryParserTestSuite struct{}
+
+func (s *QueryParserTestSuite) TestImplicitAnd(c *gc.C) {
+	got, err := ParseBooleanQuery("foo bar")
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, &QueryNode{
+		Type: QueryNodeAnd,
+		Children: []*QueryNode{
+			{Type: QueryNodeTerm, Text: "foo"},
+			{Type: QueryNodeTerm, Text: "bar"},
+		},
+	})
+}
+
+func (s *QueryParserTestSuite) TestOperatorPrecedence(c *gc.C) {
+	got, err := ParseBooleanQuery(`foo OR bar AND NOT baz`)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, &QueryNode{
+		Type: QueryNodeOr,
+		Children: []*QueryNode{
+			{Type: QueryNodeTerm, Text: "foo"},
+			{
+				Type: QueryNodeAnd,
+				Children: []*QueryNode{
+					{Type: QueryNodeTerm, Text: "bar"},
+					{Type: QueryNodeNot, Children: []*QueryNode{{Type: QueryNodeTerm, Text: "baz"}}},
+				},
+			},
+		},
+	})
+}
+
+func (s *QueryParserTestSuite) TestGroupsPhrasesAndFields(c *gc.C) {
+	got, err := ParseBooleanQuery(`(title:foo OR "lorem ipsum") -url:example.com content:"dolor sit"`)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, &QueryNode{
+		Type: QueryNodeAnd,
+		Children: []*QueryNode{
+			{
+				Type: QueryNodeOr,
+				Children: []*QueryNode{
+					{Type: QueryNodeTerm, Field: FieldTitle, Text: "foo"},
+					{Type: QueryNodePhrase, Text: "lorem ipsum"},
+				},
+			},
+			{Type: QueryNodeNot, Children: []*QueryNode{{Type: QueryNodeTerm, Field: FieldURL, Text: "example.com"}}},
+			{Type: QueryNodePhrase, Field: FieldContent, Text: "dolor sit"},
+		},
+	})
+}
+
+func (s *QueryParserTestSuite) TestUnknownFieldPrefixIsTreatedAsTerm(c *gc.C) {
+	got, err := ParseBooleanQuery("author:bob")
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, &QueryNode{Type: QueryNodeTerm, Text: "author:bob"})
+}
+
+func (s *QueryParserTestSuite) TestHeaderFields(c *gc.C) {
+	got, err := ParseBooleanQuery(`header.X-Generator:wordpress -header.server:"apache 2"`)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, &QueryNode{
+		Type: QueryNodeAnd,
+		Children: []*QueryNode{
+			{Type: QueryNodeTerm, Field: "Headers.x-generator", Text: "wordpress"},
+			{Type: QueryNodeNot, Children: []*QueryNode{{Type: QueryNodePhrase, Field: "Headers.server", Text: "apache 2"}}},
+		},
+	})
+
+	name, isHeader := HeaderName(got.Children[0].Field)
+	c.Assert(isHeader, gc.Equals, true)
+	c.Assert(name, gc.Equals, "x-generator")
+
+	// A header prefix without a header name is not a field prefix.
+	got, err = ParseBooleanQuery("header.:foo")
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, &QueryNode{Type: QueryNodeTerm, Text: "header.:foo"})
+}
+
+func (s *QueryParserTestSuite) TestInvalidQueries(c *gc.C) {
+	specs := []string{
+		"",
+		`"unterminated`,
+		"(foo OR bar",
+		"foo)",
+		"foo AND",
+		"NOT",
+		"title:",
+	}
+
+	for _, spec := range specs {
+		_, err := ParseBooleanQuery(spec)
+		c.Assert(errors.Is(err, ErrInvalidQuery), gc.Equals, true, gc.Commentf("query %q", spec))
+	}
+}
@@ -0,0 +1,142 @@
+package index
+
+import (
+	"math"
+	"time"
+)
+
+// DecayFunction describes how the freshness of a document decreases with the
+// time that has passed since it was indexed.
+type DecayFunction uint8
+
+const (
+	// DecayGauss decreases freshness slowly for documents whose age is
+	// close to the offset, faster around the scale and slowly again for
+	// old documents.
+	DecayGauss DecayFunction = iota
+
+	// DecayExp decreases freshness sharply at first and then slowly.
+	DecayExp
+
+	// DecayLinear decreases freshness at a constant rate until it reaches
+	// zero at twice the scale (for a decay rate of 0.5).
+	DecayLinear
+)
+
+// String returns the name of the decay function.
+func (f DecayFunction) String() string {
+	switch f {
+	case DecayExp:
+		return "exp"
+	case DecayLinear:
+		return "linear"
+	default:
+		return "gauss"
+	}
+}
+
+const (
+	// DefaultDecayScale is the document age beyond the decay offset at
+	// which freshness drops to the decay rate if Ranking.Scale is not
+	// set.
+	DefaultDecayScale = 30 * 24 * time.Hour
+
+	// DefaultDecayRate is the freshness of documents whose age is the
+	// decay offset plus the scale if Ranking.DecayRate is not set.
+	DefaultDecayRate = 0.5
+)
+
+// Ranking controls how search results are ordered. The score of each
+// matching document is computed as:
+//
+//	relevance + PageRankWeight*PageRank + FreshnessWeight*freshness
+//
+// where freshness is a value in [0, 1] that decays with the time elapsed
+// between the moment the document was indexed and Origin.
+type Ranking struct {
+	// The weight of the document PageRank score.
+	PageRankWeight float64
+
+	// The weight of the document freshness. A zero value disables
+	// freshness-aware ranking.
+	FreshnessWeight float64
+
+	// The shape of the freshness decay.
+	Decay DecayFunction
+
+	// The point in time that document ages are measured against.
+	// Defaults to the time the search is executed.
+	Origin time.Time
+
+	// Documents indexed less than Offset before Origin are considered
+	// fully fresh.
+	Offset time.Duration
+
+	// The age beyond Offset at which freshness drops to DecayRate.
+	// Defaults to DefaultDecayScale.
+	Scale time.Duration
+
+	// The freshness of documents whose age is Offset+Scale. Must be in
+	// the (0, 1) range; defaults to DefaultDecayRate.
+	DecayRate float64
+}
+
+// DefaultRanking orders search results by the sum of their relevance and
+// PageRank scores.
+var DefaultRanking = Ranking{PageRankWeight: 1}
+
+// RankingOf returns the ranking options for the query, falling back to
+// DefaultRanking if the query does not specify any, with any unset or
+// invalid decay parameters replaced by their defaults. Origin is left
+// unset if the query does not specify it.
+func RankingOf(q Query) Ranking {
+	r := DefaultRanking
+	if q.Ranking != nil {
+		r = *q.Ranking
+	}
+	if r.Offset < 0 {
+		r.Offset = 0
+	}
+	if r.Scale <= 0 {
+		r.Scale = DefaultDecayScale
+	}
+	if r.DecayRate <= 0 || r.DecayRate >= 1 {
+		r.DecayRate = DefaultDecayRate
+	}
+	return r
+}
+
+// Freshness returns the freshness of a document indexed at the specified
+// time relative to origin. Documents with an unknown indexing time are
+// considered fully fresh.
+func (r Ranking) Freshness(indexedAt, origin time.Time) float64 {
+	if indexedAt.IsZero() {
+		return 1
+	}
+	age := origin.Sub(indexedAt)
+	if age < 0 {
+		age = -age
+	}
+	dist := float64(age-r.Offset) / float64(r.Scale)
+	if dist <= 0 {
+		return 1
+	}
+	switch r.Decay {
+	case DecayExp:
+		return math.Pow(r.DecayRate, dist)
+	case DecayLinear:
+		return math.Max(0, 1-(1-r.DecayRate)*dist)
+	default:
+		return math.Pow(r.DecayRate, dist*dist)
+	}
+}
+
+// Score combines the relevance score of a document with its PageRank score
+// and the freshness of its indexing time according to the ranking options.
+func (r Ranking) Score(relevance, pageRank float64, indexedAt, origin time.Time) float64 {
+	score := relevance + r.PageRankWeight*pageRank
+	if r.FreshnessWeight != 0 {
+		score += r.FreshnessWeight * r.Freshness(indexedAt, origin)
+	}
+	return score
+}
@@ -0,0 +1,61 @@
+package index
+
+import (
+	"math"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(RankingTestSuite))
+
+type RankingTestSuite struct{}
+
+func (s *RankingTestSuite) TestRankingOf(c *gc.C) {
+	r := RankingOf(Query{})
+	c.Assert(r.PageRankWeight, gc.Equals, 1.0)
+	c.Assert(r.FreshnessWeight, gc.Equals, 0.0)
+	c.Assert(r.Scale, gc.Equals, DefaultDecayScale)
+	c.Assert(r.DecayRate, gc.Equals, DefaultDecayRate)
+
+	r = RankingOf(Query{Ranking: &Ranking{FreshnessWeight: 2, Offset: -time.Hour, Scale: time.Hour, DecayRate: 1.5}})
+	c.Assert(r.PageRankWeight, gc.Equals, 0.0)
+	c.Assert(r.FreshnessWeight, gc.Equals, 2.0)
+	c.Assert(r.Offset, gc.Equals, time.Duration(0))
+	c.Assert(r.Scale, gc.Equals, time.Hour)
+	c.Assert(r.DecayRate, gc.Equals, DefaultDecayRate)
+}
+
+func (s *RankingTestSuite) TestFreshness(c *gc.C) {
+	origin := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	specs := []struct {
+		decay DecayFunction
+		age   time.Duration
+		exp   float64
+	}{
+		{decay: DecayGauss, age: 30 * time.Minute, exp: 1},
+		{decay: DecayGauss, age: 2 * time.Hour, exp: 0.5},
+		{decay: DecayGauss, age: 3 * time.Hour, exp: 0.0625},
+		{decay: DecayExp, age: 2 * time.Hour, exp: 0.5},
+		{decay: DecayExp, age: 3 * time.Hour, exp: 0.25},
+		{decay: DecayLinear, age: 2 * time.Hour, exp: 0.5},
+		{decay: DecayLinear, age: 3 * time.Hour, exp: 0},
+		{decay: DecayLinear, age: 4 * time.Hour, exp: 0},
+		{decay: DecayLinear, age: -90 * time.Minute, exp: 0.75},
+	}
+	for _, spec := range specs {
+		r := RankingOf(Query{Ranking: &Ranking{Decay: spec.decay, Offset: time.Hour, Scale: time.Hour}})
+		got := r.Freshness(origin.Add(-spec.age), origin)
+		c.Assert(math.Abs(got-spec.exp) < 1e-9, gc.Equals, true, gc.Commentf("%s decay for age %s: got %f", spec.decay, spec.age, got))
+	}
+
+	r := RankingOf(Query{Ranking: &Ranking{FreshnessWeight: 1}})
+	c.Assert(r.Freshness(time.Time{}, origin), gc.Equals, 1.0)
+}
+
+func (s *RankingTestSuite) TestScore(c *gc.C) {
+	origin := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := RankingOf(Query{Ranking: &Ranking{PageRankWeight: 2, FreshnessWeight: 4, Decay: DecayExp, Scale: time.Hour}})
+	c.Assert(r.Score(1.5, 0.25, origin.Add(-time.Hour), origin), gc.Equals, 1.5+0.5+2)
+	c.Assert(RankingOf(Query{}).Score(1.5, 0.25, origin.Add(-time.Hour), origin), gc.Equals, 1.75)
+}
@@ -0,0 +1,201 @@
+package index
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SuggestionThreshold is the number of matching documents below which
+// indexers look for corrections to the terms of a search expression.
+const SuggestionThreshold = 5
+
+// MaxSuggestions is the maximum number of suggestions returned for a search.
+const MaxSuggestions = 3
+
+// minSuggestTermLength is the minimum length of the terms that are
+// considered for spelling correction.
+const minSuggestTermLength = 3
+
+// Suggestion is a candidate correction for a search expression that matched
+// few or no documents.
+type Suggestion struct {
+	// The search expression with its misspelled terms replaced.
+	Expression string
+
+	// The confidence in the correction. Scores are implementation-specific
+	// and higher scores indicate better suggestions.
+	Score float64
+}
+
+// TermCorrection is a candidate replacement for a single search term.
+type TermCorrection struct {
+	// The replacement term.
+	Term string
+
+	// The confidence in the replacement. Higher scores indicate better
+	// replacements.
+	Score float64
+}
+
+// CompletionWords returns the lower-cased words of an autocomplete prefix.
+func CompletionWords(prefix string) []string {
+	return strings.FieldsFunc(strings.ToLower(prefix), func(r rune) bool { return !isWordRune(r) })
+}
+
+// exprWord describes the location of a correctable word within a search
+// expression.
+type exprWord struct {
+	start, end int
+	term       string
+}
+
+// SuggestTerms returns the distinct lower-cased terms of expr that can be
+// spell-corrected in the order they appear. Boolean operators, field prefixes
+// and terms shorter than three characters are skipped.
+func SuggestTerms(expr string) []string {
+	var (
+		terms []string
+		seen  = make(map[string]bool)
+	)
+	for _, w := range suggestWords(expr) {
+		if !seen[w.term] {
+			seen[w.term] = true
+			terms = append(terms, w.term)
+		}
+	}
+	return terms
+}
+
+// BuildSuggestions combines the corrections for the terms of expr (keyed by
+// the lower-cased terms returned by SuggestTerms) into at most MaxSuggestions
+// corrected search expressions ordered by descending score. The corrections
+// for each term must be sorted by descending score.
+//
+// The first suggestion replaces each misspelled term with its best
+// correction; the remaining ones swap a single term for one of its
+// alternative corrections. The score of a suggestion is the mean score of
+// the corrections it applies.
+func BuildSuggestions(expr string, corrections map[string][]TermCorrection) []Suggestion {
+	var misspelled []string
+	for _, term := range SuggestTerms(expr) {
+		if len(corrections[term]) != 0 {
+			misspelled = append(misspelled, term)
+		}
+	}
+	if len(misspelled) == 0 {
+		return nil
+	}
+
+	best := make(map[string]TermCorrection, len(misspelled))
+	for _, term := range misspelled {
+		best[term] = corrections[term][0]
+	}
+
+	candidates := []map[string]TermCorrection{best}
+	for _, term := range misspelled {
+		for _, alt := range corrections[term][1:] {
+			candidate := make(map[string]TermCorrection, len(best))
+			for t, c := range best {
+				candidate[t] = c
+			}
+			candidate[term] = alt
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	var (
+		suggestions []Suggestion
+		seen        = make(map[string]bool)
+	)
+	for _, candidate := range candidates {
+		var score float64
+		for _, c := range candidate {
+			score += c.Score
+		}
+		corrected := applyCorrections(expr, candidate)
+		if corrected == expr || seen[corrected] {
+			continue
+		}
+		seen[corrected] = true
+		suggestions = append(suggestions, Suggestion{Expression: corrected, Score: score / float64(len(candidate))})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Score > suggestions[j].Score })
+	if len(suggestions) > MaxSuggestions {
+		suggestions = suggestions[:MaxSuggestions]
+	}
+	return suggestions
+}
+
+// applyCorrections returns a copy of expr where every correctable word with
+// an entry in corrections is replaced by its correction.
+func applyCorrections(expr string, corrections map[string]TermCorrection) string {
+	var (
+		sb   strings.Builder
+		last int
+	)
+	for _, w := range suggestWords(expr) {
+		c, found := corrections[w.term]
+		if !found {
+			continue
+		}
+		sb.WriteString(expr[last:w.start])
+		sb.WriteString(c.Term)
+		last = w.end
+	}
+	sb.WriteString(expr[last:])
+	return sb.String()
+}
+
+// suggestWords returns the words of expr that can be spell-corrected.
+func suggestWords(expr string) []exprWord {
+	var words []exprWord
+	for start := 0; start < len(expr); {
+		r, size := utf8.DecodeRuneInString(expr[start:])
+		if !isWordRune(r) {
+			start += size
+			continue
+		}
+
+		// Consume the run of letters and digits that starts at r.
+		end := start + size
+		for end < len(expr) {
+			if r, size = utf8.DecodeRuneInString(expr[end:]); !isWordRune(r) {
+				break
+			}
+			end += size
+		}
+
+		if word := expr[start:end]; isCorrectable(expr, word, end) {
+			words = append(words, exprWord{start: start, end: end, term: strings.ToLower(word)})
+		}
+		start = end
+	}
+	return words
+}
+
+// isCorrectable returns true if word, which ends at offset end of expr, is
+// neither a boolean operator nor part of a field prefix.
+func isCorrectable(expr, word string, end int) bool {
+	switch word {
+	case "AND", "OR", "NOT":
+		return false
+	}
+	if utf8.RuneCountInString(word) < minSuggestTermLength || strings.IndexFunc(word, unicode.IsLetter) == -1 {
+		return false
+	}
+
+	// Field prefixes (e.g. "title:" or "header.X-Generator:") extend up to
+	// the next colon without any intervening whitespace.
+	rest := expr[end:]
+	if idx := strings.IndexFunc(rest, unicode.IsSpace); idx != -1 {
+		rest = rest[:idx]
+	}
+	return !strings.Contains(rest, ":")
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
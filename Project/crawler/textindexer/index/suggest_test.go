@@ -0,0 +1,42 @@
+package index
+
+import (
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(SuggestTestSuite))
+
+type SuggestTestSuite struct{}
+
+func (s *SuggestTestSuite) TestSuggestTerms(c *gc.C) {
+	specs := []struct {
+		expr string
+		exp  []string
+	}{
+		{expr: "Ovidus poeta ovidus", exp: []string{"ovidus", "poeta"}},
+		{expr: `(ovidus OR "terra pontica") NOT title:lorm`, exp: []string{"ovidus", "terra", "pontica", "lorm"}},
+		{expr: `header.X-Generator:hugo in 2024`, exp: []string{"hugo"}},
+		{expr: "-poeta url:example.com", exp: []string{"poeta", "example", "com"}},
+		{expr: "a an", exp: nil},
+	}
+	for _, spec := range specs {
+		c.Assert(SuggestTerms(spec.expr), gc.DeepEquals, spec.exp, gc.Commentf("expr %q", spec.expr))
+	}
+}
+
+func (s *SuggestTestSuite) TestBuildSuggestions(c *gc.C) {
+	expr := `Ovidus AND title:"poete in terra"`
+	suggestions := BuildSuggestions(expr, map[string][]TermCorrection{
+		"ovidus": {{Term: "ovidius", Score: 0.75}},
+		"poete":  {{Term: "poeta", Score: 0.75}, {Term: "poet", Score: 0.5}, {Term: "poetae", Score: 0.25}},
+		"title":  {{Term: "tile", Score: 1}},
+	})
+	c.Assert(suggestions, gc.DeepEquals, []Suggestion{
+		{Expression: `ovidius AND title:"poeta in terra"`, Score: 0.75},
+		{Expression: `ovidius AND title:"poet in terra"`, Score: 0.625},
+		{Expression: `ovidius AND title:"poetae in terra"`, Score: 0.5},
+	})
+
+	c.Assert(BuildSuggestions(expr, nil), gc.IsNil)
+	c.Assert(BuildSuggestions("ovidius", map[string][]TermCorrection{"ovidius": {{Term: "ovidius", Score: 1}}}), gc.IsNil)
+}
@@ -0,0 +1,41 @@
+package index
+
+import "strings"
+
+// The search verticals that indexed documents are routed to. Each vertical
+// groups documents of a particular content type so that they can be searched
+// separately.
+const (
+	// VerticalWeb contains regular web-pages. Documents that do not
+	// specify a vertical belong to it.
+	VerticalWeb = "web"
+
+	// VerticalDocuments contains the text of binary documents (e.g. PDF
+	// files).
+	VerticalDocuments = "documents"
+
+	// VerticalNews contains news articles and the contents of news feeds.
+	VerticalNews = "news"
+)
+
+// Verticals lists the supported search verticals.
+var Verticals = []string{VerticalWeb, VerticalDocuments, VerticalNews}
+
+// IsValidVertical returns true if vertical is included in Verticals.
+func IsValidVertical(vertical string) bool {
+	for _, v := range Verticals {
+		if vertical == v {
+			return true
+		}
+	}
+	return false
+}
+
+// VerticalOf returns the vertical that doc belongs to. Documents that do not
+// specify a vertical (or specify an unknown one) belong to VerticalWeb.
+func VerticalOf(doc *Document) string {
+	if vertical := strings.ToLower(doc.Vertical); IsValidVertical(vertical) {
+		return vertical
+	}
+	return VerticalWeb
+}
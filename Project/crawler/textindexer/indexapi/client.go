@@ -0,0 +1,169 @@
+package indexapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/crawler/textindexer/indexapi/proto"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/status"
+)
+
+// Compile-time check for ensuring TextIndexerClient implements
+// index.Indexer.
+var _ index.Indexer = (*TextIndexerClient)(nil)
+
+// TextIndexerClient provides an API compatible with the index.Indexer
+// interface for accessing a text indexer instance exposed by a remote gRPC
+// server.
+type TextIndexerClient struct {
+	ctx context.Context
+	cli proto.TextIndexerClient
+}
+
+// NewTextIndexerClient returns a new client instance that implements the
+// index.Indexer interface by delegating methods to an indexer instance
+// exposed by a remote gRPC server. All requests are bound to ctx;
+// cancelling it aborts any in-flight requests and open iterators.
+func NewTextIndexerClient(ctx context.Context, rpcClient proto.TextIndexerClient) *TextIndexerClient {
+	return &TextIndexerClient{ctx: ctx, cli: rpcClient}
+}
+
+// WithContext returns a copy of the client whose requests are bound to ctx.
+// It allows callers to propagate the trace that is associated with ctx to the
+// remote server.
+func (c *TextIndexerClient) WithContext(ctx context.Context) *TextIndexerClient {
+	return &TextIndexerClient{ctx: ctx, cli: c.cli}
+}
+
+// Index inserts a new document to the index or updates the index entry for
+// an existing document. The fields populated by the remote indexer (e.g.
+// the indexing timestamp) are copied back to doc.
+func (c *TextIndexerClient) Index(doc *index.Document) error {
+	res, err := c.cli.Index(c.ctx, encodeDoc(doc))
+	if err != nil {
+		return fmt.Errorf("index: %w", decodeError(err))
+	}
+
+	indexed, err := decodeDoc(res)
+	if err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	*doc = *indexed
+	return nil
+}
+
+// FindByID looks up a document by its link ID.
+func (c *TextIndexerClient) FindByID(linkID uuid.UUID) (*index.Document, error) {
+	res, err := c.cli.FindByID(c.ctx, &proto.FindByIDRequest{LinkId: linkID[:]})
+	if err != nil {
+		return nil, fmt.Errorf("find by ID: %w", decodeError(err))
+	}
+
+	doc, err := decodeDoc(res)
+	if err != nil {
+		return nil, fmt.Errorf("find by ID: %w", err)
+	}
+	return doc, nil
+}
+
+// Search the index for a particular query and return back a result
+// iterator. Results are streamed by the server as the iterator advances.
+func (c *TextIndexerClient) Search(q index.Query) (index.Iterator, error) {
+	ctx, cancelFn := context.WithCancel(c.ctx)
+	stream, err := c.cli.Search(ctx, encodeQuery(q))
+	if err != nil {
+		cancelFn()
+		return nil, fmt.Errorf("search: %w", decodeError(err))
+	}
+
+	// The first message carries the result set metadata.
+	res, err := stream.Recv()
+	if err != nil {
+		cancelFn()
+		if err == io.EOF {
+			err = fmt.Errorf("stream closed before receiving search metadata")
+		}
+		return nil, fmt.Errorf("search: %w", decodeError(err))
+	}
+	meta := res.GetMetadata()
+	if meta == nil {
+		cancelFn()
+		return nil, fmt.Errorf("search: expected search metadata as the first streamed message")
+	}
+
+	return &searchIterator{
+		stream:      stream,
+		cancelFn:    cancelFn,
+		meta:        meta,
+		facets:      decodeFacets(meta.Facets),
+		suggestions: decodeSuggestions(meta.Suggestions),
+	}, nil
+}
+
+// UpdateScore updates the PageRank score for a document with the specified
+// link ID.
+func (c *TextIndexerClient) UpdateScore(linkID uuid.UUID, score float64) error {
+	_, err := c.cli.UpdateScore(c.ctx, &proto.UpdateScoreRequest{LinkId: linkID[:], PageRank: score})
+	if err != nil {
+		return fmt.Errorf("update score: %w", decodeError(err))
+	}
+	return nil
+}
+
+// Patch applies a partial update to the indexed document with the specified
+// link ID.
+func (c *TextIndexerClient) Patch(linkID uuid.UUID, patch index.DocumentPatch) error {
+	_, err := c.cli.Patch(c.ctx, &proto.PatchRequest{LinkId: linkID[:], Title: patch.Title, Content: patch.Content})
+	if err != nil {
+		return fmt.Errorf("patch: %w", decodeError(err))
+	}
+	return nil
+}
+
+// Suggest returns up to limit distinct titles of indexed documents for
+// autocompleting the search expression prefix.
+func (c *TextIndexerClient) Suggest(prefix string, limit int) ([]string, error) {
+	res, err := c.cli.Suggest(c.ctx, &proto.SuggestRequest{Prefix: prefix, Limit: int32(limit)})
+	if err != nil {
+		return nil, fmt.Errorf("suggest: %w", decodeError(err))
+	}
+	return res.Titles, nil
+}
+
+// All returns an iterator over every indexed document in ascending link ID
+// order, resuming after the document that cursor refers to.
+func (c *TextIndexerClient) All(cursor index.Cursor) (index.DocumentIterator, error) {
+	// Validate the cursor locally so that callers get the same error as
+	// with an in-process indexer without having to wait for the stream.
+	if cursor != "" {
+		if _, err := cursor.LinkID(); err != nil {
+			return nil, fmt.Errorf("all: %w", err)
+		}
+	}
+
+	ctx, cancelFn := context.WithCancel(c.ctx)
+	stream, err := c.cli.All(ctx, &proto.AllRequest{Cursor: string(cursor)})
+	if err != nil {
+		cancelFn()
+		return nil, fmt.Errorf("all: %w", decodeError(err))
+	}
+	return &allIterator{stream: stream, cancelFn: cancelFn}, nil
+}
+
+// decodeError maps the gRPC status errors produced by encodeError back to
+// the index package errors.
+func decodeError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, mapping := range errorCodes {
+		if st.Code() == mapping.code {
+			return mapping.err
+		}
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package indexapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"webcrawler/crawler/textindexer/index/indextest"
+	"webcrawler/crawler/textindexer/indexapi/proto"
+	"webcrawler/crawler/textindexer/store/memory"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(TextIndexerAPITestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+// TextIndexerAPITestSuite runs the shared indexer test-suite against a
+// client that talks to a server backed by the in-memory bleve indexer.
+type TextIndexerAPITestSuite struct {
+	indextest.SuiteBase
+
+	idx  *memory.InMemoryBleveIndexer
+	srv  *grpc.Server
+	conn *grpc.ClientConn
+}
+
+func (s *TextIndexerAPITestSuite) SetUpTest(c *gc.C) {
+	var err error
+	s.idx, err = memory.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s.srv = grpc.NewServer()
+	proto.RegisterTextIndexerServer(s.srv, NewTextIndexerServer(s.idx))
+	go func() { _ = s.srv.Serve(lis) }()
+
+	s.conn, err = grpc.DialContext(context.TODO(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	c.Assert(err, gc.IsNil)
+	s.SetIndexer(NewTextIndexerClient(context.TODO(), proto.NewTextIndexerClient(s.conn)))
+}
+
+func (s *TextIndexerAPITestSuite) TearDownTest(c *gc.C) {
+	_ = s.conn.Close()
+	s.srv.Stop()
+	c.Assert(s.idx.Close(), gc.IsNil)
+}
@@ -0,0 +1,124 @@
+package indexapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/crawler/textindexer/indexapi/proto"
+)
+
+// searchIterator implements index.Iterator on top of a search result
+// stream.
+type searchIterator struct {
+	stream   proto.TextIndexer_SearchClient
+	cancelFn context.CancelFunc
+
+	meta        *proto.SearchMetadata
+	facets      []index.Facet
+	suggestions []index.Suggestion
+
+	latched *index.Document
+	lastErr error
+}
+
+// Close the iterator and release any allocated resources.
+func (it *searchIterator) Close() error {
+	it.cancelFn()
+	return nil
+}
+
+// Next loads the next document matching the search query.
+func (it *searchIterator) Next() bool {
+	if it.lastErr != nil {
+		return false
+	}
+	res, err := it.stream.Recv()
+	if err != nil {
+		if err != io.EOF {
+			it.lastErr = decodeError(err)
+		}
+		it.cancelFn()
+		return false
+	}
+
+	doc := res.GetDoc()
+	if doc == nil {
+		it.lastErr = fmt.Errorf("expected a document in streamed search result")
+		it.cancelFn()
+		return false
+	}
+	if it.latched, it.lastErr = decodeDoc(doc); it.lastErr != nil {
+		it.cancelFn()
+		return false
+	}
+	return true
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *searchIterator) Error() error { return it.lastErr }
+
+// Document returns the current document from the result set.
+func (it *searchIterator) Document() *index.Document { return it.latched }
+
+// TotalCount returns the approximate number of search results.
+func (it *searchIterator) TotalCount() uint64 { return it.meta.TotalCount }
+
+// MaxScore returns the highest relevance score among the search results.
+func (it *searchIterator) MaxScore() float64 { return it.meta.MaxScore }
+
+// Facets returns the aggregations requested by the search query.
+func (it *searchIterator) Facets() []index.Facet { return it.facets }
+
+// Suggestions returns corrected versions of the search expression.
+func (it *searchIterator) Suggestions() []index.Suggestion { return it.suggestions }
+
+// allIterator implements index.DocumentIterator on top of a document stream.
+type allIterator struct {
+	stream   proto.TextIndexer_AllClient
+	cancelFn context.CancelFunc
+
+	latched *index.Document
+	lastErr error
+}
+
+// Close the iterator and release any allocated resources.
+func (it *allIterator) Close() error {
+	it.cancelFn()
+	return nil
+}
+
+// Next loads the next document.
+func (it *allIterator) Next() bool {
+	if it.lastErr != nil {
+		return false
+	}
+	res, err := it.stream.Recv()
+	if err != nil {
+		if err != io.EOF {
+			it.lastErr = decodeError(err)
+		}
+		it.cancelFn()
+		return false
+	}
+
+	if it.latched, it.lastErr = decodeDoc(res); it.lastErr != nil {
+		it.cancelFn()
+		return false
+	}
+	return true
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *allIterator) Error() error { return it.lastErr }
+
+// Document returns the current document.
+func (it *allIterator) Document() *index.Document { return it.latched }
+
+// Cursor returns a cursor for the current document.
+func (it *allIterator) Cursor() index.Cursor {
+	if it.latched == nil {
+		return ""
+	}
+	return index.CursorFor(it.latched.LinkID)
+}
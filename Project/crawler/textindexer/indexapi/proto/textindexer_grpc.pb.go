@@ -0,0 +1,398 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: textindexer.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	TextIndexer_Index_FullMethodName       = "/proto.TextIndexer/Index"
+	TextIndexer_FindByID_FullMethodName    = "/proto.TextIndexer/FindByID"
+	TextIndexer_Search_FullMethodName      = "/proto.TextIndexer/Search"
+	TextIndexer_UpdateScore_FullMethodName = "/proto.TextIndexer/UpdateScore"
+	TextIndexer_Patch_FullMethodName       = "/proto.TextIndexer/Patch"
+	TextIndexer_All_FullMethodName         = "/proto.TextIndexer/All"
+	TextIndexer_Suggest_FullMethodName     = "/proto.TextIndexer/Suggest"
+)
+
+// TextIndexerClient is the client API for TextIndexer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TextIndexer provides remote access to a text indexer instance.
+type TextIndexerClient interface {
+	Index(ctx context.Context, in *Document, opts ...grpc.CallOption) (*Document, error)
+	FindByID(ctx context.Context, in *FindByIDRequest, opts ...grpc.CallOption) (*Document, error)
+	Search(ctx context.Context, in *Query, opts ...grpc.CallOption) (TextIndexer_SearchClient, error)
+	UpdateScore(ctx context.Context, in *UpdateScoreRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Patch(ctx context.Context, in *PatchRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	All(ctx context.Context, in *AllRequest, opts ...grpc.CallOption) (TextIndexer_AllClient, error)
+	Suggest(ctx context.Context, in *SuggestRequest, opts ...grpc.CallOption) (*SuggestResponse, error)
+}
+
+type textIndexerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTextIndexerClient(cc grpc.ClientConnInterface) TextIndexerClient {
+	return &textIndexerClient{cc}
+}
+
+func (c *textIndexerClient) Index(ctx context.Context, in *Document, opts ...grpc.CallOption) (*Document, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Document)
+	err := c.cc.Invoke(ctx, TextIndexer_Index_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *textIndexerClient) FindByID(ctx context.Context, in *FindByIDRequest, opts ...grpc.CallOption) (*Document, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Document)
+	err := c.cc.Invoke(ctx, TextIndexer_FindByID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *textIndexerClient) Search(ctx context.Context, in *Query, opts ...grpc.CallOption) (TextIndexer_SearchClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TextIndexer_ServiceDesc.Streams[0], TextIndexer_Search_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &textIndexerSearchClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TextIndexer_SearchClient interface {
+	Recv() (*SearchResult, error)
+	grpc.ClientStream
+}
+
+type textIndexerSearchClient struct {
+	grpc.ClientStream
+}
+
+func (x *textIndexerSearchClient) Recv() (*SearchResult, error) {
+	m := new(SearchResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *textIndexerClient) UpdateScore(ctx context.Context, in *UpdateScoreRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, TextIndexer_UpdateScore_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *textIndexerClient) Patch(ctx context.Context, in *PatchRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, TextIndexer_Patch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *textIndexerClient) All(ctx context.Context, in *AllRequest, opts ...grpc.CallOption) (TextIndexer_AllClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TextIndexer_ServiceDesc.Streams[1], TextIndexer_All_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &textIndexerAllClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TextIndexer_AllClient interface {
+	Recv() (*Document, error)
+	grpc.ClientStream
+}
+
+type textIndexerAllClient struct {
+	grpc.ClientStream
+}
+
+func (x *textIndexerAllClient) Recv() (*Document, error) {
+	m := new(Document)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *textIndexerClient) Suggest(ctx context.Context, in *SuggestRequest, opts ...grpc.CallOption) (*SuggestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SuggestResponse)
+	err := c.cc.Invoke(ctx, TextIndexer_Suggest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TextIndexerServer is the server API for TextIndexer service.
+// All implementations must embed UnimplementedTextIndexerServer
+// for forward compatibility
+//
+// TextIndexer provides remote access to a text indexer instance.
+type TextIndexerServer interface {
+	Index(context.Context, *Document) (*Document, error)
+	FindByID(context.Context, *FindByIDRequest) (*Document, error)
+	Search(*Query, TextIndexer_SearchServer) error
+	UpdateScore(context.Context, *UpdateScoreRequest) (*emptypb.Empty, error)
+	Patch(context.Context, *PatchRequest) (*emptypb.Empty, error)
+	All(*AllRequest, TextIndexer_AllServer) error
+	Suggest(context.Context, *SuggestRequest) (*SuggestResponse, error)
+	mustEmbedUnimplementedTextIndexerServer()
+}
+
+// UnimplementedTextIndexerServer must be embedded to have forward compatible implementations.
+type UnimplementedTextIndexerServer struct {
+}
+
+func (UnimplementedTextIndexerServer) Index(context.Context, *Document) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Index not implemented")
+}
+func (UnimplementedTextIndexerServer) FindByID(context.Context, *FindByIDRequest) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindByID not implemented")
+}
+func (UnimplementedTextIndexerServer) Search(*Query, TextIndexer_SearchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedTextIndexerServer) UpdateScore(context.Context, *UpdateScoreRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateScore not implemented")
+}
+func (UnimplementedTextIndexerServer) Patch(context.Context, *PatchRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Patch not implemented")
+}
+func (UnimplementedTextIndexerServer) All(*AllRequest, TextIndexer_AllServer) error {
+	return status.Errorf(codes.Unimplemented, "method All not implemented")
+}
+func (UnimplementedTextIndexerServer) Suggest(context.Context, *SuggestRequest) (*SuggestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Suggest not implemented")
+}
+func (UnimplementedTextIndexerServer) mustEmbedUnimplementedTextIndexerServer() {}
+
+// UnsafeTextIndexerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TextIndexerServer will
+// result in compilation errors.
+type UnsafeTextIndexerServer interface {
+	mustEmbedUnimplementedTextIndexerServer()
+}
+
+func RegisterTextIndexerServer(s grpc.ServiceRegistrar, srv TextIndexerServer) {
+	s.RegisterService(&TextIndexer_ServiceDesc, srv)
+}
+
+func _TextIndexer_Index_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Document)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TextIndexerServer).Index(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TextIndexer_Index_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TextIndexerServer).Index(ctx, req.(*Document))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TextIndexer_FindByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TextIndexerServer).FindByID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TextIndexer_FindByID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TextIndexerServer).FindByID(ctx, req.(*FindByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TextIndexer_Search_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Query)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TextIndexerServer).Search(m, &textIndexerSearchServer{ServerStream: stream})
+}
+
+type TextIndexer_SearchServer interface {
+	Send(*SearchResult) error
+	grpc.ServerStream
+}
+
+type textIndexerSearchServer struct {
+	grpc.ServerStream
+}
+
+func (x *textIndexerSearchServer) Send(m *SearchResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TextIndexer_UpdateScore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateScoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TextIndexerServer).UpdateScore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TextIndexer_UpdateScore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TextIndexerServer).UpdateScore(ctx, req.(*UpdateScoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TextIndexer_Patch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TextIndexerServer).Patch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TextIndexer_Patch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TextIndexerServer).Patch(ctx, req.(*PatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TextIndexer_All_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AllRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TextIndexerServer).All(m, &textIndexerAllServer{ServerStream: stream})
+}
+
+type TextIndexer_AllServer interface {
+	Send(*Document) error
+	grpc.ServerStream
+}
+
+type textIndexerAllServer struct {
+	grpc.ServerStream
+}
+
+func (x *textIndexerAllServer) Send(m *Document) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TextIndexer_Suggest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SuggestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TextIndexerServer).Suggest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TextIndexer_Suggest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TextIndexerServer).Suggest(ctx, req.(*SuggestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TextIndexer_ServiceDesc is the grpc.ServiceDesc for TextIndexer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TextIndexer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.TextIndexer",
+	HandlerType: (*TextIndexerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Index",
+			Handler:    _TextIndexer_Index_Handler,
+		},
+		{
+			MethodName: "FindByID",
+			Handler:    _TextIndexer_FindByID_Handler,
+		},
+		{
+			MethodName: "UpdateScore",
+			Handler:    _TextIndexer_UpdateScore_Handler,
+		},
+		{
+			MethodName: "Patch",
+			Handler:    _TextIndexer_Patch_Handler,
+		},
+		{
+			MethodName: "Suggest",
+			Handler:    _TextIndexer_Suggest_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Search",
+			Handler:       _TextIndexer_Search_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "All",
+			Handler:       _TextIndexer_All_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "textindexer.proto",
+}
@@ -0,0 +1,407 @@
+// Package indexapi exposes a text indexer over gRPC. The server wraps any
+// index.Indexer implementation while the client implements index.Indexer on
+// top of a remote server so that services can switch between in-process and
+// remote indexers without any code changes.
+package indexapi
+
+import (
+	"context"
+	"errors"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/crawler/textindexer/indexapi/proto"
+	"webcrawler/tracing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/textindexer.proto
+
+var _ proto.TextIndexerServer = (*TextIndexerServer)(nil)
+
+var tracer = tracing.Tracer("textindexer")
+
+// TextIndexerServer provides a gRPC layer for accessing a text indexer.
+type TextIndexerServer struct {
+	proto.UnimplementedTextIndexerServer
+	i index.Indexer
+}
+
+// NewTextIndexerServer returns a new server instance that uses the provided
+// indexer as its backing store.
+func NewTextIndexerServer(i index.Indexer) *TextIndexerServer {
+	return &TextIndexerServer{i: i}
+}
+
+// Index inserts a new document to the index or updates the index entry for
+// an existing document.
+func (s *TextIndexerServer) Index(ctx context.Context, req *proto.Document) (*proto.Document, error) {
+	doc, err := decodeDoc(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = tracing.Do(ctx, tracer, "textindexer.Index", func() error { return s.i.Index(doc) }); err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeDoc(doc), nil
+}
+
+// FindByID looks up a document by its link ID.
+func (s *TextIndexerServer) FindByID(ctx context.Context, req *proto.FindByIDRequest) (*proto.Document, error) {
+	linkID, err := decodeID(req.LinkId)
+	if err != nil {
+		return nil, err
+	}
+	var doc *index.Document
+	err = tracing.Do(ctx, tracer, "textindexer.FindByID", func() (err error) {
+		doc, err = s.i.FindByID(linkID)
+		return err
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	return encodeDoc(doc), nil
+}
+
+// Search the index for a particular query and stream the results back to
+// the client. The first streamed message carries the result set metadata.
+// Results are streamed until the result set is exhausted or the client
+// cancels the request.
+func (s *TextIndexerServer) Search(req *proto.Query, stream proto.TextIndexer_SearchServer) error {
+	it, err := s.i.Search(decodeQuery(req))
+	if err != nil {
+		return encodeError(err)
+	}
+	defer func() { _ = it.Close() }()
+
+	meta := &proto.SearchMetadata{
+		TotalCount:  it.TotalCount(),
+		MaxScore:    it.MaxScore(),
+		Facets:      encodeFacets(it.Facets()),
+		Suggestions: encodeSuggestions(it.Suggestions()),
+	}
+	if err = stream.Send(&proto.SearchResult{Result: &proto.SearchResult_Metadata{Metadata: meta}}); err != nil {
+		return err
+	}
+
+	for it.Next() {
+		if err = stream.Context().Err(); err != nil {
+			return err
+		}
+		res := &proto.SearchResult{Result: &proto.SearchResult_Doc{Doc: encodeDoc(it.Document())}}
+		if err = stream.Send(res); err != nil {
+			return err
+		}
+	}
+	if err = it.Error(); err != nil {
+		return encodeError(err)
+	}
+	return nil
+}
+
+// UpdateScore updates the PageRank score for a document with the specified
+// link ID.
+func (s *TextIndexerServer) UpdateScore(ctx context.Context, req *proto.UpdateScoreRequest) (*emptypb.Empty, error) {
+	linkID, err := decodeID(req.LinkId)
+	if err != nil {
+		return nil, err
+	}
+	err = tracing.Do(ctx, tracer, "textindexer.UpdateScore", func() error {
+		return s.i.UpdateScore(linkID, req.PageRank)
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// Suggest returns the titles that complete the requested prefix.
+func (s *TextIndexerServer) Suggest(ctx context.Context, req *proto.SuggestRequest) (*proto.SuggestResponse, error) {
+	var titles []string
+	err := tracing.Do(ctx, tracer, "textindexer.Suggest", func() (err error) {
+		titles, err = s.i.Suggest(req.Prefix, int(req.Limit))
+		return err
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	return &proto.SuggestResponse{Titles: titles}, nil
+}
+
+// Patch applies a partial update to the document with the specified link ID.
+func (s *TextIndexerServer) Patch(ctx context.Context, req *proto.PatchRequest) (*emptypb.Empty, error) {
+	linkID, err := decodeID(req.LinkId)
+	if err != nil {
+		return nil, err
+	}
+	err = tracing.Do(ctx, tracer, "textindexer.Patch", func() error {
+		return s.i.Patch(linkID, index.DocumentPatch{Title: req.Title, Content: req.Content})
+	})
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// All streams every indexed document in link ID order.
+func (s *TextIndexerServer) All(req *proto.AllRequest, stream proto.TextIndexer_AllServer) error {
+	it, err := s.i.All(index.Cursor(req.Cursor))
+	if err != nil {
+		return encodeError(err)
+	}
+	defer func() { _ = it.Close() }()
+
+	for it.Next() {
+		if err = stream.Context().Err(); err != nil {
+			return err
+		}
+		if err = stream.Send(encodeDoc(it.Document())); err != nil {
+			return err
+		}
+	}
+	if err = it.Error(); err != nil {
+		return encodeError(err)
+	}
+	return nil
+}
+
+// errorCodes maps the errors returned by indexers to gRPC status codes. The
+// client maps each code back to the corresponding index package error.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{err: index.ErrNotFound, code: codes.NotFound},
+	{err: index.ErrMissingLinkID, code: codes.FailedPrecondition},
+	{err: index.ErrInvalidQuery, code: codes.InvalidArgument},
+	{err: index.ErrInvalidCursor, code: codes.OutOfRange},
+	{err: index.ErrOffsetTooLarge, code: codes.ResourceExhausted},
+}
+
+func encodeError(err error) error {
+	for _, mapping := range errorCodes {
+		if errors.Is(err, mapping.err) {
+			return status.Error(mapping.code, err.Error())
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func decodeID(raw []byte) (uuid.UUID, error) {
+	if len(raw) == 0 {
+		return uuid.Nil, nil
+	}
+	id, err := uuid.FromBytes(raw)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid link_id: %v", err)
+	}
+	return id, nil
+}
+
+func decodeDoc(d *proto.Document) (*index.Document, error) {
+	linkID, err := decodeID(d.LinkId)
+	if err != nil {
+		return nil, err
+	}
+	duplicateOf, err := decodeID(d.DuplicateOf)
+	if err != nil {
+		return nil, err
+	}
+	doc := &index.Document{
+		LinkID:       linkID,
+		URL:          d.Url,
+		Title:        d.Title,
+		Content:      d.Content,
+		Summary:      d.Summary,
+		Language:     d.Language,
+		PageRank:     d.PageRank,
+		FaviconRef:   d.FaviconRef,
+		ThumbnailRef: d.ThumbnailRef,
+		Fingerprint:  d.Fingerprint,
+		ContentHash:  d.ContentHash,
+		DuplicateOf:  duplicateOf,
+		Author:       d.Author,
+		Vertical:     d.Vertical,
+		Namespace:    d.Namespace,
+	}
+	if d.IndexedAt != nil {
+		doc.IndexedAt = d.IndexedAt.AsTime()
+	}
+	if d.PublishedAt != nil {
+		doc.PublishedAt = d.PublishedAt.AsTime()
+	}
+	if d.ChangedAt != nil {
+		doc.ChangedAt = d.ChangedAt.AsTime()
+	}
+	if len(d.Headers) != 0 {
+		doc.Headers = d.Headers
+	}
+	return doc, nil
+}
+
+func encodeDoc(d *index.Document) *proto.Document {
+	doc := &proto.Document{
+		LinkId:       d.LinkID[:],
+		Url:          d.URL,
+		Title:        d.Title,
+		Content:      d.Content,
+		Summary:      d.Summary,
+		Language:     d.Language,
+		PageRank:     d.PageRank,
+		FaviconRef:   d.FaviconRef,
+		ThumbnailRef: d.ThumbnailRef,
+		Headers:      d.Headers,
+		Fingerprint:  d.Fingerprint,
+		ContentHash:  d.ContentHash,
+		Author:       d.Author,
+		Vertical:     d.Vertical,
+		Namespace:    d.Namespace,
+	}
+	if d.DuplicateOf != uuid.Nil {
+		doc.DuplicateOf = d.DuplicateOf[:]
+	}
+	if !d.IndexedAt.IsZero() {
+		doc.IndexedAt = timestamppb.New(d.IndexedAt)
+	}
+	if !d.PublishedAt.IsZero() {
+		doc.PublishedAt = timestamppb.New(d.PublishedAt)
+	}
+	if !d.ChangedAt.IsZero() {
+		doc.ChangedAt = timestamppb.New(d.ChangedAt)
+	}
+	return doc
+}
+
+func decodeQuery(q *proto.Query) index.Query {
+	query := index.Query{
+		Type:       index.QueryType(q.Type),
+		Expression: q.Expression,
+		Offset:     q.Offset,
+		Size:       q.Size,
+		Vertical:   q.Vertical,
+	}
+	for _, f := range q.Facets {
+		query.Facets = append(query.Facets, index.FacetRequest{Field: index.FacetField(f.Field), Size: int(f.Size)})
+	}
+	if f := q.Filters; f != nil {
+		query.Filters = index.Filters{
+			Domains:     f.Domains,
+			Languages:   f.Languages,
+			MinPageRank: f.MinPageRank,
+		}
+		if f.IndexedAfter != nil {
+			query.Filters.IndexedAfter = f.IndexedAfter.AsTime()
+		}
+		if f.IndexedBefore != nil {
+			query.Filters.IndexedBefore = f.IndexedBefore.AsTime()
+		}
+	}
+	if r := q.Ranking; r != nil {
+		query.Ranking = &index.Ranking{
+			PageRankWeight:  r.PageRankWeight,
+			FreshnessWeight: r.FreshnessWeight,
+			Decay:           index.DecayFunction(r.Decay),
+			Offset:          r.Offset.AsDuration(),
+			Scale:           r.Scale.AsDuration(),
+			DecayRate:       r.DecayRate,
+		}
+		if r.Origin != nil {
+			query.Ranking.Origin = r.Origin.AsTime()
+		}
+	}
+	return query
+}
+
+func encodeQuery(q index.Query) *proto.Query {
+	query := &proto.Query{
+		Type:       proto.Query_Type(q.Type),
+		Expression: q.Expression,
+		Offset:     q.Offset,
+		Size:       q.Size,
+		Vertical:   q.Vertical,
+	}
+	for _, f := range q.Facets {
+		query.Facets = append(query.Facets, &proto.FacetRequest{Field: proto.FacetField(f.Field), Size: int32(f.Size)})
+	}
+	if f := q.Filters; !f.IsZero() {
+		query.Filters = &proto.Filters{
+			Domains:     f.Domains,
+			Languages:   f.Languages,
+			MinPageRank: f.MinPageRank,
+		}
+		if !f.IndexedAfter.IsZero() {
+			query.Filters.IndexedAfter = timestamppb.New(f.IndexedAfter)
+		}
+		if !f.IndexedBefore.IsZero() {
+			query.Filters.IndexedBefore = timestamppb.New(f.IndexedBefore)
+		}
+	}
+	if r := q.Ranking; r != nil {
+		query.Ranking = &proto.Ranking{
+			PageRankWeight:  r.PageRankWeight,
+			FreshnessWeight: r.FreshnessWeight,
+			Decay:           proto.Ranking_Decay(r.Decay),
+			Offset:          durationpb.New(r.Offset),
+			Scale:           durationpb.New(r.Scale),
+			DecayRate:       r.DecayRate,
+		}
+		if !r.Origin.IsZero() {
+			query.Ranking.Origin = timestamppb.New(r.Origin)
+		}
+	}
+	return query
+}
+
+func encodeFacets(facets []index.Facet) []*proto.Facet {
+	if len(facets) == 0 {
+		return nil
+	}
+	out := make([]*proto.Facet, len(facets))
+	for i, f := range facets {
+		out[i] = &proto.Facet{Field: proto.FacetField(f.Field)}
+		for _, b := range f.Buckets {
+			out[i].Buckets = append(out[i].Buckets, &proto.FacetBucket{Value: b.Value, Count: b.Count})
+		}
+	}
+	return out
+}
+
+func decodeFacets(facets []*proto.Facet) []index.Facet {
+	if len(facets) == 0 {
+		return nil
+	}
+	out := make([]index.Facet, len(facets))
+	for i, f := range facets {
+		out[i] = index.Facet{Field: index.FacetField(f.Field)}
+		for _, b := range f.Buckets {
+			out[i].Buckets = append(out[i].Buckets, index.FacetBucket{Value: b.Value, Count: b.Count})
+		}
+	}
+	return out
+}
+
+func encodeSuggestions(suggestions []index.Suggestion) []*proto.Suggestion {
+	if len(suggestions) == 0 {
+		return nil
+	}
+	out := make([]*proto.Suggestion, len(suggestions))
+	for i, s := range suggestions {
+		out[i] = &proto.Suggestion{Expression: s.Expression, Score: s.Score}
+	}
+	return out
+}
+
+func decodeSuggestions(suggestions []*proto.Suggestion) []index.Suggestion {
+	if len(suggestions) == 0 {
+		return nil
+	}
+	out := make([]index.Suggestion, len(suggestions))
+	for i, s := range suggestions {
+		out[i] = index.Suggestion{Expression: s.Expression, Score: s.Score}
+	}
+	return out
+}
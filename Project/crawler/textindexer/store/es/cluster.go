@@ -0,0 +1,104 @@
+package es
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch"
+)
+
+// Distribution identifies the search engine that serves the ES API of a
+// cluster.
+type Distribution string
+
+// The supported distributions.
+const (
+	DistributionElasticsearch Distribution = "elasticsearch"
+	DistributionOpenSearch    Distribution = "opensearch"
+)
+
+// ClusterInfo describes the distribution and version of a cluster.
+type ClusterInfo struct {
+	Distribution Distribution
+
+	// The version reported by the cluster (e.g. "2.11.1") and its major
+	// and minor components.
+	Version string
+	Major   int
+	Minor   int
+}
+
+// String returns the distribution and version of the cluster.
+func (ci ClusterInfo) String() string {
+	return string(ci.Distribution) + " " + ci.Version
+}
+
+// atLeast returns true if the cluster version is at least major.minor.
+func (ci ClusterInfo) atLeast(major, minor int) bool {
+	return ci.Major > major || (ci.Major == major && ci.Minor >= minor)
+}
+
+// checkSupported returns an error if the indexer cannot be used with the
+// cluster. Elasticsearch clusters must support point-in-time searches (7.10
+// and later) while OpenSearch clusters must be on 2.x or later.
+func (ci ClusterInfo) checkSupported() error {
+	switch ci.Distribution {
+	case DistributionElasticsearch:
+		if !ci.atLeast(7, 10) {
+			return fmt.Errorf("unsupported cluster version %s; elasticsearch 7.10 or later is required", ci)
+		}
+	case DistributionOpenSearch:
+		if ci.Major < 2 {
+			return fmt.Errorf("unsupported cluster version %s; opensearch 2.0 or later is required", ci)
+		}
+	default:
+		return fmt.Errorf("unsupported cluster distribution %q", ci.Distribution)
+	}
+	return nil
+}
+
+// supportsPIT returns true if the cluster supports point-in-time searches.
+// OpenSearch added them in 2.4.
+func (ci ClusterInfo) supportsPIT() bool {
+	return ci.Distribution != DistributionOpenSearch || ci.atLeast(2, 4)
+}
+
+type esInfoRes struct {
+	Version struct {
+		Number       string `json:"number"`
+		Distribution string `json:"distribution"`
+	} `json:"version"`
+}
+
+// DetectCluster queries the root endpoint of the cluster for its distribution
+// and version. Clusters that do not report a distribution are assumed to be
+// elasticsearch clusters.
+func DetectCluster(es *elasticsearch.Client) (ClusterInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		return ClusterInfo{}, err
+	}
+
+	var infoRes esInfoRes
+	if err = performRequest(es, req, &infoRes); err != nil {
+		return ClusterInfo{}, fmt.Errorf("detect cluster: %w", err)
+	}
+
+	ci := ClusterInfo{
+		Distribution: DistributionElasticsearch,
+		Version:      infoRes.Version.Number,
+	}
+	if infoRes.Version.Distribution != "" {
+		ci.Distribution = Distribution(strings.ToLower(infoRes.Version.Distribution))
+	}
+
+	major, rest, _ := strings.Cut(ci.Version, ".")
+	minor, _, _ := strings.Cut(rest, ".")
+	if ci.Major, err = strconv.Atoi(major); err != nil {
+		return ClusterInfo{}, fmt.Errorf("detect cluster: invalid version %q", ci.Version)
+	}
+	ci.Minor, _ = strconv.Atoi(minor)
+	return ci, nil
+}
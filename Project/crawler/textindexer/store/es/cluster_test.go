@@ -0,0 +1,142 @@
+package es
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/elastic/go-elasticsearch"
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ClusterTestSuite))
+
+type ClusterTestSuite struct {
+	srv      *httptest.Server
+	info     string
+	requests []string
+	bodies   map[string]map[string]interface{}
+	client   *elasticsearch.Client
+}
+
+func (s *ClusterTestSuite) SetUpTest(c *gc.C) {
+	s.requests = nil
+	s.bodies = make(map[string]map[string]interface{})
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{s.srv.URL}})
+	c.Assert(err, gc.IsNil)
+	s.client = client
+}
+
+func (s *ClusterTestSuite) TearDownTest(c *gc.C) {
+	s.srv.Close()
+}
+
+// serve emulates the root endpoint and the OpenSearch point-in-time API.
+func (s *ClusterTestSuite) serve(w http.ResponseWriter, r *http.Request) {
+	req := r.Method + " " + r.URL.Path
+	s.requests = append(s.requests, req)
+	if data, _ := io.ReadAll(r.Body); len(data) != 0 {
+		var body map[string]interface{}
+		_ = json.Unmarshal(data, &body)
+		s.bodies[req] = body
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch req {
+	case "GET /":
+		_, _ = io.WriteString(w, s.info)
+	case "POST /textindexer/_search/point_in_time":
+		_, _ = io.WriteString(w, `{"pit_id":"pit-1","creation_time":1709294400000}`)
+	case "POST /_search":
+		_, _ = io.WriteString(w, `{"pit_id":"pit-1","hits":{"hits":[{"_source":{"LinkID":"`+uuid.Nil.String()+`","Title":"A title"}}]}}`)
+	case "DELETE /_search/point_in_time":
+		_, _ = io.WriteString(w, `{"pits":[{"pit_id":"pit-1","successful":true}]}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"type":"not_found","reason":"unexpected request"}}`)
+	}
+}
+
+func (s *ClusterTestSuite) TestDetectCluster(c *gc.C) {
+	specs := []struct {
+		info      string
+		exp       ClusterInfo
+		supported bool
+		pit       bool
+	}{
+		{
+			info:      `{"version":{"number":"8.12.2","build_flavor":"default"}}`,
+			exp:       ClusterInfo{Distribution: DistributionElasticsearch, Version: "8.12.2", Major: 8, Minor: 12},
+			supported: true,
+			pit:       true,
+		},
+		{
+			info: `{"version":{"number":"7.9.3"}}`,
+			exp:  ClusterInfo{Distribution: DistributionElasticsearch, Version: "7.9.3", Major: 7, Minor: 9},
+			pit:  true,
+		},
+		{
+			info:      `{"version":{"distribution":"opensearch","number":"2.11.1"}}`,
+			exp:       ClusterInfo{Distribution: DistributionOpenSearch, Version: "2.11.1", Major: 2, Minor: 11},
+			supported: true,
+			pit:       true,
+		},
+		{
+			info:      `{"version":{"distribution":"opensearch","number":"2.3.0"}}`,
+			exp:       ClusterInfo{Distribution: DistributionOpenSearch, Version: "2.3.0", Major: 2, Minor: 3},
+			supported: true,
+		},
+		{
+			info: `{"version":{"distribution":"opensearch","number":"1.3.14"}}`,
+			exp:  ClusterInfo{Distribution: DistributionOpenSearch, Version: "1.3.14", Major: 1, Minor: 3},
+		},
+	}
+
+	for specIndex, spec := range specs {
+		comment := gc.Commentf("spec %d", specIndex)
+		s.info = spec.info
+		ci, err := DetectCluster(s.client)
+		c.Assert(err, gc.IsNil, comment)
+		c.Assert(ci, gc.DeepEquals, spec.exp, comment)
+		c.Assert(ci.checkSupported() == nil, gc.Equals, spec.supported, comment)
+		c.Assert(ci.supportsPIT(), gc.Equals, spec.pit, comment)
+	}
+
+	s.info = `{"version":{"number":"latest"}}`
+	_, err := DetectCluster(s.client)
+	c.Assert(err, gc.ErrorMatches, `detect cluster: invalid version "latest"`)
+}
+
+func (s *ClusterTestSuite) TestOpenSearchPointInTime(c *gc.C) {
+	idx := &ElasticSearchIndexer{
+		es:        s.client,
+		cluster:   ClusterInfo{Distribution: DistributionOpenSearch, Version: "2.11.1", Major: 2, Minor: 11},
+		indexName: "textindexer",
+		batchSize: index.DefaultBatchSize,
+	}
+
+	it, err := idx.All("")
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Document().Title, gc.Equals, "A title")
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+
+	c.Assert(s.requests, gc.DeepEquals, []string{
+		"POST /textindexer/_search/point_in_time",
+		"POST /_search",
+		"DELETE /_search/point_in_time",
+	})
+	c.Assert(s.bodies["POST /_search"]["pit"], gc.DeepEquals, map[string]interface{}{"id": "pit-1", "keep_alive": pitKeepAlive})
+	c.Assert(s.bodies["DELETE /_search/point_in_time"], gc.DeepEquals, map[string]interface{}{"pit_id": []interface{}{"pit-1"}})
+
+	idx.cluster = ClusterInfo{Distribution: DistributionOpenSearch, Version: "2.3.0", Major: 2, Minor: 3}
+	_, err = idx.All("")
+	c.Assert(err, gc.ErrorMatches, "all: point-in-time searches are not supported by opensearch 2.3.0")
+}
@@ -2,6 +2,8 @@ package es
 
 import (
 	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 	"webcrawler/crawler/textindexer/index"
 
@@ -9,33 +11,128 @@ import (
 	"github.com/elastic/go-elasticsearch/esapi"
 )
 
-// The name of the elasticsearch index to use.
-const indexName = "textindexer"
+// The default name of the elasticsearch index to use.
+const defaultIndexName = "textindexer"
 
-// The size of each page of results that is cached locally by the iterator.
-const batchSize = 10
+// The amount of time that elasticsearch should keep a scroll context alive
+// between successive page requests issued by the iterator.
+const scrollKeepAlive = time.Minute
 
+// The keep-alive as expected by the scroll options of the ES client, which
+// multiplies the provided duration by time.Millisecond.
+const scrollKeepAliveOpt = scrollKeepAlive / time.Millisecond
+
+// The amount of time that elasticsearch should keep a point-in-time view
+// alive between successive page requests issued by All iterators.
+const pitKeepAlive = "1m"
+
+// The default mappings for the documents in the index.
 var esMappings = `
 {
-  "mappings" : {
-    "properties": {
-      "LinkID": {"type": "keyword"},
-      "URL": {"type": "keyword"},
-      "Content": {"type": "text"},
-      "Title": {"type": "text"},
-      "IndexedAt": {"type": "date"},
-      "PageRank": {"type": "double"}
+  "properties": {
+    "LinkID": {"type": "keyword"},
+    "URL": {
+      "type": "keyword",
+      "fields": {
+        "text": {"type": "text"}
+      }
+    },
+    "Content": {"type": "text"},
+    "Title": {
+      "type": "text",
+      "fields": {
+        "autocomplete": {"type": "text", "analyzer": "autocomplete", "search_analyzer": "standard"}
+      }
+    },
+    "Summary": {"type": "text", "index": false},
+    "Language": {"type": "keyword"},
+    "Author": {"type": "text"},
+    "PublishedAt": {"type": "date"},
+    "Host": {"type": "keyword"},
+    "IndexedDate": {"type": "keyword"},
+    "Vertical": {"type": "keyword"},
+    "Namespace": {"type": "keyword"},
+    "IndexedAt": {"type": "date"},
+    "PageRank": {"type": "double"},
+    "FaviconRef": {"type": "keyword", "index": false},
+    "ThumbnailRef": {"type": "keyword", "index": false},
+    "Headers": {"type": "object", "enabled": false},
+    "HeaderTerms": {"type": "keyword"},
+    "Fingerprint": {"type": "keyword", "index": false},
+    "ContentHash": {"type": "keyword", "index": false},
+    "ChangedAt": {"type": "date"},
+    "DuplicateOf": {"type": "keyword"},
+    "LangText": {
+      "properties": {
+        "en": {"type": "text", "analyzer": "english"},
+        "de": {"type": "text", "analyzer": "german"},
+        "fr": {"type": "text", "analyzer": "french"},
+        "es": {"type": "text", "analyzer": "spanish"},
+        "it": {"type": "text", "analyzer": "italian"},
+        "nl": {"type": "text", "analyzer": "dutch"},
+        "pt": {"type": "text", "analyzer": "portuguese"}
+      }
     }
   }
 }`
 
+// The analysis settings for the index. The autocomplete analyzer indexes the
+// prefixes (edge n-grams) of each word so that partially typed words can be
+// matched by a regular match query.
+var esAnalysis = map[string]interface{}{
+	"filter": map[string]interface{}{
+		"autocomplete_prefix": map[string]interface{}{
+			"type":     "edge_ngram",
+			"min_gram": 1,
+			"max_gram": maxAutocompleteGram,
+		},
+	},
+	"analyzer": map[string]interface{}{
+		"autocomplete": map[string]interface{}{
+			"type":      "custom",
+			"tokenizer": "standard",
+			"filter":    []string{"lowercase", "autocomplete_prefix"},
+		},
+	},
+}
+
+// The maximum length of the word prefixes indexed for autocompletion.
+const maxAutocompleteGram = 20
+
 type esSearchRes struct {
-	Hits esSearchResHits `json:"hits"`
+	ScrollID     string                      `json:"_scroll_id"`
+	PitID        string                      `json:"pit_id"`
+	Hits         esSearchResHits             `json:"hits"`
+	Aggregations map[string]esAggregation    `json:"aggregations"`
+	Suggest      map[string][]esSuggestEntry `json:"suggest"`
+}
+
+// esSuggestEntry holds the term suggester options for a single token of the
+// suggest text.
+type esSuggestEntry struct {
+	Text    string            `json:"text"`
+	Options []esSuggestOption `json:"options"`
+}
+
+type esSuggestOption struct {
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+	Freq  uint64  `json:"freq"`
+}
+
+type esAggregation struct {
+	Buckets []esAggregationBucket `json:"buckets"`
+}
+
+type esAggregationBucket struct {
+	Key      string `json:"key"`
+	DocCount uint64 `json:"doc_count"`
 }
 
 type esSearchResHits struct {
-	Total   esTotal        `json:"total"`
-	HitList []esHitWrapper `json:"hits"`
+	Total    esTotal        `json:"total"`
+	MaxScore float64        `json:"max_score"`
+	HitList  []esHitWrapper `json:"hits"`
 }
 
 type esTotal struct {
@@ -51,14 +148,88 @@ type esDoc struct {
 	URL       string    `json:"URL"`
 	Title     string    `json:"Title"`
 	Content   string    `json:"Content"`
+	Summary   string    `json:"Summary"`
+	Language  string    `json:"Language,omitempty"`
 	IndexedAt time.Time `json:"IndexedAt"`
 	PageRank  float64   `json:"PageRank,omitempty"`
+
+	// The extracted author and publication date. Both are always sent so
+	// that re-indexing a document clears stale values; unknown dates are
+	// stored as null.
+	Author      string     `json:"Author"`
+	PublishedAt *time.Time `json:"PublishedAt"`
+
+	// Blob store references. These are omitted when empty so that a
+	// re-index with a failed capture does not clear existing references.
+	FaviconRef   string `json:"FaviconRef,omitempty"`
+	ThumbnailRef string `json:"ThumbnailRef,omitempty"`
+
+	// Derived fields used for computing facets.
+	Host        string `json:"Host,omitempty"`
+	IndexedDate string `json:"IndexedDate,omitempty"`
+	Vertical    string `json:"Vertical,omitempty"`
+
+	// The namespace of the crawl that indexed the document. Documents
+	// indexed before namespaces were introduced lack this field and
+	// belong to the default namespace.
+	Namespace string `json:"Namespace,omitempty"`
+
+	// The captured response headers. Headers are stored as a list rather
+	// than an object so that partial updates replace (instead of merge)
+	// the previously captured set. HeaderTerms holds the lower-case
+	// "name=value" terms that header-scoped queries are matched against.
+	Headers     []esHeader `json:"Headers"`
+	HeaderTerms []string   `json:"HeaderTerms"`
+
+	// The content fingerprint as a hex string (ES longs are signed) and
+	// the link ID of the canonical document for duplicates. DuplicateOf is
+	// always sent so that re-indexing a former duplicate clears it.
+	Fingerprint string `json:"Fingerprint,omitempty"`
+	DuplicateOf string `json:"DuplicateOf"`
+
+	// The content hash as a hex string and the time of the last content
+	// change. Both are always sent so that re-indexing a document without
+	// them does not leave stale values behind.
+	ContentHash string     `json:"ContentHash"`
+	ChangedAt   *time.Time `json:"ChangedAt"`
+
+	// The document text keyed by language. Each entry is indexed using
+	// the ES analyzer for its language.
+	LangText map[string]string `json:"LangText,omitempty"`
+}
+
+type esHeader struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// esPitRes is the response to a request that opens a point-in-time view.
+// Elasticsearch returns the ID of the view in the "id" field while
+// OpenSearch returns it in the "pit_id" field.
+type esPitRes struct {
+	ID    string `json:"id"`
+	PitID string `json:"pit_id"`
 }
 
 type esUpdateRes struct {
 	Result string `json:"result"`
 }
 
+type esBulkRes struct {
+	Errors bool                    `json:"errors"`
+	Items  []map[string]esBulkItem `json:"items"`
+}
+
+type esBulkItem struct {
+	ID     string   `json:"_id"`
+	Status int      `json:"status"`
+	Error  *esError `json:"error"`
+}
+
+type esClusterHealthRes struct {
+	Status string `json:"status"`
+}
+
 type esErrorRes struct {
 	Error esError `json:"error"`
 }
@@ -72,12 +243,39 @@ func (e esError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Type, e.Reason)
 }
 
-// Compile-time check to ensure ElasticSearchIndexer implements Indexer.
-var _ index.Indexer = (*ElasticSearchIndexer)(nil)
+// Compile-time checks to ensure ElasticSearchIndexer implements the required
+// interfaces.
+var (
+	_ index.Indexer          = (*ElasticSearchIndexer)(nil)
+	_ index.BulkScoreUpdater = (*ElasticSearchIndexer)(nil)
+	_ index.Pinger           = (*ElasticSearchIndexer)(nil)
+)
 
 // ElasticSearchIndexer is an Indexer implementation that uses an elastic search
 // instance to catalogue and search documents.
 type ElasticSearchIndexer struct {
-	es         *elasticsearch.Client
-	refreshOpt func(*esapi.UpdateRequest)
+	es             *elasticsearch.Client
+	cluster        ClusterInfo
+	indexName      string
+	namespace      string
+	refreshOpt     func(*esapi.UpdateRequest)
+	bulkRefreshOpt func(*esapi.BulkRequest)
+	logger         *slog.Logger
+
+	// The boosted fields searched by queries that do not target a
+	// specific field.
+	textFields []string
+
+	// The number of results fetched per search request and the largest
+	// accepted query offset.
+	batchSize int
+	maxOffset uint64
+
+	// The expected mappings of the index and the outcome of the latest
+	// mapping drift check.
+	expectedMapping      map[string]interface{}
+	mappingCheckInterval time.Duration
+	rejectDriftWrites    bool
+	driftMu              sync.RWMutex
+	drift                *MappingDrift
 }
@@ -5,38 +5,121 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"webcrawler/crawler/iterutil"
 	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+	"webcrawler/namespace"
 
 	"github.com/elastic/go-elasticsearch"
 	"github.com/elastic/go-elasticsearch/esapi"
 	"github.com/google/uuid"
 )
 
-// NewElasticSearchIndexer creates a text indexer that uses an in-memory
-// bleve instance for indexing documents.
-func NewElasticSearchIndexer(esNodes []string, syncUpdates bool) (*ElasticSearchIndexer, error) {
-	cfg := elasticsearch.Config{
-		Addresses: esNodes,
+// NewElasticSearchIndexer creates a text indexer that uses an elasticsearch
+// or OpenSearch cluster for indexing documents. The distribution and version
+// of the cluster are detected when the indexer is created. The index and its
+// settings are configured via opts.
+func NewElasticSearchIndexer(esNodes []string, opts Options) (*ElasticSearchIndexer, error) {
+	if err := namespace.Validate(namespace.OrDefault(opts.Namespace)); err != nil {
+		return nil, fmt.Errorf("es indexer: %w", err)
 	}
-	es, err := elasticsearch.NewClient(cfg)
+
+	es, err := NewClient(esNodes, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = ensureIndex(es); err != nil {
+	cluster, err := DetectCluster(es)
+	if err != nil {
+		return nil, fmt.Errorf("es indexer: %w", err)
+	}
+	if err = cluster.checkSupported(); err != nil {
+		return nil, fmt.Errorf("es indexer: %w", err)
+	}
+	logger := logging.Component(opts.Logger, "textindexer.es")
+	logger.Info("connected to cluster", "distribution", cluster.Distribution, "version", cluster.Version)
+
+	opts.applyDefaults()
+	if err = opts.FieldBoosts.Validate(); err != nil {
+		return nil, fmt.Errorf("es indexer: %w", err)
+	}
+	if err = index.CheckBatchSize(opts.BatchSize); err != nil {
+		return nil, fmt.Errorf("es indexer: %w", err)
+	}
+	if err = ensureIndex(es, opts); err != nil {
 		return nil, err
 	}
+	expectedMapping, err := parseMapping(opts.Mappings)
+	if err != nil {
+		return nil, fmt.Errorf("es indexer: %w", err)
+	}
+
+	refreshOpt, bulkRefreshOpt := es.Update.WithRefresh("false"), es.Bulk.WithRefresh("false")
+	if opts.SyncUpdates {
+		refreshOpt, bulkRefreshOpt = es.Update.WithRefresh("true"), es.Bulk.WithRefresh("true")
+	}
+
+	idx := &ElasticSearchIndexer{
+		es:                   es,
+		cluster:              cluster,
+		indexName:            opts.IndexName,
+		namespace:            opts.Namespace,
+		textFields:           makeEsTextFields(opts.FieldBoosts),
+		batchSize:            opts.BatchSize,
+		maxOffset:            opts.MaxOffset,
+		refreshOpt:           refreshOpt,
+		bulkRefreshOpt:       bulkRefreshOpt,
+		logger:               logger,
+		expectedMapping:      expectedMapping,
+		mappingCheckInterval: opts.MappingCheckInterval,
+		rejectDriftWrites:    opts.RejectWritesOnDrift,
+	}
+	if _, err = idx.CheckMapping(); err != nil {
+		return nil, fmt.Errorf("es indexer: %w", err)
+	}
+	return idx, nil
+}
+
+// NewClient returns an elasticsearch client for the cluster nodes in esNodes
+// that is configured with the authentication, TLS and logging settings from
+// opts. It allows other ES-backed stores to share the connection settings of
+// the text indexer.
+func NewClient(esNodes []string, opts Options) (*elasticsearch.Client, error) {
+	transport, err := newTransport(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure ES transport: %w", err)
+	}
 
-	refreshOpt := es.Update.WithRefresh("false")
-	if syncUpdates {
-		refreshOpt = es.Update.WithRefresh("true")
+	cfg := elasticsearch.Config{
+		Addresses: esNodes,
+		Transport: newMetricsTransport(transport, opts.Logger),
 	}
+	return elasticsearch.NewClient(cfg)
+}
 
-	return &ElasticSearchIndexer{
-		es:         es,
-		refreshOpt: refreshOpt,
-	}, nil
+// Ping verifies that the cluster can be reached and that the index is
+// available. An index whose cluster health is red fails the check.
+func (i *ElasticSearchIndexer) Ping(ctx context.Context) error {
+	res, err := i.es.Cluster.Health(
+		i.es.Cluster.Health.WithContext(ctx),
+		i.es.Cluster.Health.WithIndex(i.indexName),
+	)
+	if err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+
+	var health esClusterHealthRes
+	if err = unmarshalResponse(res, &health); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	if health.Status == "red" {
+		return fmt.Errorf("ping: cluster health is %s", health.Status)
+	}
+	return nil
 }
 
 // Index inserts a new document to the index or updates the index entry
@@ -45,7 +128,13 @@ func (i *ElasticSearchIndexer) Index(doc *index.Document) error {
 	if doc.LinkID == uuid.Nil {
 		return fmt.Errorf("index: %w", index.ErrMissingLinkID)
 	}
+	if err := i.checkWritable(); err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
 
+	doc.Language = index.DocumentLanguage(doc)
+	doc.Vertical = index.VerticalOf(doc)
+	doc.Namespace = i.namespace
 	var (
 		buf   bytes.Buffer
 		esDoc = makeEsDoc(doc)
@@ -58,7 +147,7 @@ func (i *ElasticSearchIndexer) Index(doc *index.Document) error {
 		return fmt.Errorf("index: %w", err)
 	}
 
-	res, err := i.es.Update(indexName, esDoc.LinkID, &buf, i.refreshOpt)
+	res, err := i.es.Update(i.indexName, esDoc.LinkID, &buf, i.refreshOpt)
 	if err != nil {
 		return fmt.Errorf("index: %w", err)
 	}
@@ -87,7 +176,7 @@ func (i *ElasticSearchIndexer) FindByID(linkID uuid.UUID) (*index.Document, erro
 		return nil, fmt.Errorf("find by ID: %w", err)
 	}
 
-	searchRes, err := runSearch(i.es, query)
+	searchRes, err := runSearch(i.es, i.indexName, query)
 	if err != nil {
 		return nil, fmt.Errorf("find by ID: %w", err)
 	}
@@ -102,47 +191,163 @@ func (i *ElasticSearchIndexer) FindByID(linkID uuid.UUID) (*index.Document, erro
 // Search the index for a particular query and return back a result
 // iterator.
 func (i *ElasticSearchIndexer) Search(q index.Query) (index.Iterator, error) {
-	var qtype string
+	if err := index.CheckOffset(q, i.maxOffset); err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+
+	var matchQuery map[string]interface{}
 	switch q.Type {
+	case index.QueryTypeBoolean:
+		root, err := index.ParseBooleanQuery(q.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("search: %w", err)
+		}
+		matchQuery = makeEsBooleanQuery(root, i.textFields)
 	case index.QueryTypePhrase:
-		qtype = "phrase"
+		matchQuery = makeEsMultiMatchQuery("phrase", q.Expression, i.textFields)
 	default:
-		qtype = "best_fields"
+		matchQuery = makeEsMultiMatchQuery("best_fields", q.Expression, i.textFields)
+	}
+	var filters []map[string]interface{}
+	if q.Vertical != "" {
+		filters = append(filters, makeEsVerticalFilter(q.Vertical))
+	}
+	if filters = append(filters, makeEsFilters(index.FiltersOf(q))...); len(filters) != 0 {
+		matchQuery = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   matchQuery,
+				"filter": filters,
+			},
+		}
 	}
 
 	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"function_score": map[string]interface{}{
-				"query": map[string]interface{}{
-					"multi_match": map[string]interface{}{
-						"type":   qtype,
-						"query":  q.Expression,
-						"fields": []string{"Title", "Content"},
-					},
-				},
-				"script_score": map[string]interface{}{
-					"script": map[string]interface{}{
-						"source": "_score + doc['PageRank'].value",
-					},
-				},
-			},
-		},
-		"from": q.Offset,
-		"size": batchSize,
+		"query": makeEsRankingQuery(matchQuery, index.RankingOf(q)),
+		"size":  index.BatchSizeOf(q, i.batchSize),
+
+		// Report the exact number of matching documents instead of
+		// capping the total at 10k so callers can compute page counts.
+		"track_total_hits": true,
+	}
+	if len(q.Facets) != 0 {
+		query["aggs"] = makeEsAggregations(q.Facets)
 	}
 
-	searchRes, err := runSearch(i.es, query)
+	// Queries that request a bounded number of results (i.e. pages of
+	// search results) are paged via from/size so the offset is applied by
+	// the cluster and no search context is kept open. Unbounded queries
+	// are paged via the scroll API so that iterating over all results
+	// does not hit the max_result_window limit.
+	var (
+		searchRes *esSearchRes
+		err       error
+	)
+	it := &esIterator{es: i.es, indexName: i.indexName, query: query}
+	if q.Size != 0 {
+		query["from"] = q.Offset
+		searchRes, err = runSearch(i.es, i.indexName, query)
+		it.cumIdx = q.Offset
+	} else {
+		searchRes, err = runScrollSearch(i.es, i.indexName, query)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("search: %w", err)
 	}
+	it.rs, it.facets = searchRes, mapEsAggregations(q.Facets, searchRes.Aggregations)
+
+	// Suggesters cannot be used in a scroll context so corrections are
+	// requested separately for searches that matched few documents.
+	if terms := index.SuggestTerms(q.Expression); len(terms) != 0 && searchRes.Hits.Total.Count < index.SuggestionThreshold {
+		suggestRes, err := runSearch(i.es, i.indexName, makeEsSuggestQuery(terms))
+		if err != nil {
+			_ = it.Close()
+			return nil, fmt.Errorf("search: %w", err)
+		}
+		it.suggestions = mapEsSuggestions(q.Expression, suggestRes.Suggest)
+	}
 
-	return &esIterator{es: i.es, searchReq: query, rs: searchRes, cumIdx: q.Offset}, nil
+	if q.Size != 0 {
+		return iterutil.LimitDocuments(it, int(q.Size)), nil
+	}
+	if err = it.skip(q.Offset); err != nil {
+		_ = it.Close()
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return it, nil
+}
+
+// Suggest returns up to limit distinct titles of the highest ranked documents
+// whose titles contain a word starting with each word of prefix. Matching is
+// performed against the edge n-grams indexed in the Title.autocomplete field.
+func (i *ElasticSearchIndexer) Suggest(prefix string, limit int) ([]string, error) {
+	words := index.CompletionWords(prefix)
+	if len(words) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	// Over-fetch as several documents (e.g. mirrors) may share a title.
+	searchRes, err := runSearch(i.es, i.indexName, makeEsCompletionQuery(words, limit*completionOverfetch))
+	if err != nil {
+		return nil, fmt.Errorf("suggest: %w", err)
+	}
+
+	var (
+		titles []string
+		seen   = make(map[string]bool)
+	)
+	for _, hit := range searchRes.Hits.HitList {
+		title := hit.DocSource.Title
+		if title == "" || seen[title] {
+			continue
+		}
+		seen[title] = true
+		if titles = append(titles, title); len(titles) == limit {
+			break
+		}
+	}
+	return titles, nil
+}
+
+// All returns an iterator over every indexed document in ascending link ID
+// order, resuming after the document that cursor refers to. The iterator
+// pages through the documents using search_after against a point-in-time
+// view of the index so that concurrent updates do not cause documents to be
+// skipped or returned twice.
+func (i *ElasticSearchIndexer) All(cursor index.Cursor) (index.DocumentIterator, error) {
+	var searchAfter string
+	if cursor != "" {
+		linkID, err := cursor.LinkID()
+		if err != nil {
+			return nil, fmt.Errorf("all: %w", err)
+		}
+		searchAfter = linkID.String()
+	}
+
+	if !i.cluster.supportsPIT() {
+		return nil, fmt.Errorf("all: point-in-time searches are not supported by %s", i.cluster)
+	}
+	pitID, err := openPIT(i.es, i.cluster, i.indexName)
+	if err != nil {
+		return nil, fmt.Errorf("all: %w", err)
+	}
+
+	return &esAllIterator{es: i.es, cluster: i.cluster, pitID: pitID, searchAfter: searchAfter, batchSize: i.batchSize}, nil
+}
+
+// Cluster returns the distribution and version of the cluster that was
+// detected when the indexer was created.
+func (i *ElasticSearchIndexer) Cluster() ClusterInfo {
+	return i.cluster
 }
 
 // UpdateScore updates the PageRank score for a document with the
 // specified link ID. If no such document exists, a placeholder
 // document with the provided score will be created.
 func (i *ElasticSearchIndexer) UpdateScore(linkID uuid.UUID, score float64) error {
+	if err := i.checkWritable(); err != nil {
+		return fmt.Errorf("update score: %w", err)
+	}
+
 	var buf bytes.Buffer
 	update := map[string]interface{}{
 		"doc": map[string]interface{}{
@@ -155,7 +360,7 @@ func (i *ElasticSearchIndexer) UpdateScore(linkID uuid.UUID, score float64) erro
 		return fmt.Errorf("update score: %w", err)
 	}
 
-	res, err := i.es.Update(indexName, linkID.String(), &buf, i.refreshOpt)
+	res, err := i.es.Update(i.indexName, linkID.String(), &buf, i.refreshOpt)
 	if err != nil {
 		return fmt.Errorf("update score: %w", err)
 	}
@@ -168,9 +373,155 @@ func (i *ElasticSearchIndexer) UpdateScore(linkID uuid.UUID, score float64) erro
 	return nil
 }
 
-func ensureIndex(es *elasticsearch.Client) error {
-	mappingsReader := strings.NewReader(esMappings)
-	res, err := es.Indices.Create(indexName, es.Indices.Create.WithBody(mappingsReader))
+// UpdateScores updates the PageRank scores of the documents whose link IDs
+// are keys of scores using a single bulk request. Placeholder documents are
+// created for unknown link IDs.
+func (i *ElasticSearchIndexer) UpdateScores(scores map[uuid.UUID]float64) error {
+	if len(scores) == 0 {
+		return nil
+	}
+	if err := i.checkWritable(); err != nil {
+		return fmt.Errorf("update scores: %w", err)
+	}
+
+	var (
+		buf bytes.Buffer
+		enc = json.NewEncoder(&buf)
+	)
+	for linkID, score := range scores {
+		action := map[string]interface{}{
+			"update": map[string]interface{}{"_index": i.indexName, "_id": linkID.String()},
+		}
+		update := map[string]interface{}{
+			"doc": map[string]interface{}{
+				"LinkID":   linkID.String(),
+				"PageRank": score,
+			},
+			"doc_as_upsert": true,
+		}
+		if err := enc.Encode(action); err != nil {
+			return fmt.Errorf("update scores: %w", err)
+		}
+		if err := enc.Encode(update); err != nil {
+			return fmt.Errorf("update scores: %w", err)
+		}
+	}
+
+	res, err := i.es.Bulk(&buf, i.bulkRefreshOpt)
+	if err != nil {
+		return fmt.Errorf("update scores: %w", err)
+	}
+	var bulkRes esBulkRes
+	if err = unmarshalResponse(res, &bulkRes); err != nil {
+		return fmt.Errorf("update scores: %w", err)
+	}
+	if !bulkRes.Errors {
+		return nil
+	}
+
+	failed := make(map[uuid.UUID]error)
+	for _, item := range bulkRes.Items {
+		for _, result := range item {
+			if result.Error == nil {
+				continue
+			}
+			linkID, err := uuid.Parse(result.ID)
+			if err != nil {
+				return fmt.Errorf("update scores: %w", *result.Error)
+			}
+			failed[linkID] = *result.Error
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("update scores: %w", &index.ScoreUpdateError{Failed: failed})
+}
+
+// Flush refreshes the index so that any writes that were issued without
+// waiting for a refresh (see Options.SyncUpdates) become visible to searches
+// before the indexer is shut down.
+func (i *ElasticSearchIndexer) Flush() error {
+	res, err := i.es.Indices.Refresh(i.es.Indices.Refresh.WithIndex(i.indexName))
+	if err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+
+	var refreshRes map[string]interface{}
+	if err = unmarshalResponse(res, &refreshRes); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	return nil
+}
+
+// Patch applies a partial update to the indexed document with the specified
+// link ID.
+func (i *ElasticSearchIndexer) Patch(linkID uuid.UUID, patch index.DocumentPatch) error {
+	if err := i.checkWritable(); err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+
+	doc, err := i.FindByID(linkID)
+	if err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+	patch.Apply(doc)
+
+	// The language-specific copy of the document text must be kept in
+	// sync with the patched fields.
+	fields := map[string]interface{}{
+		"LangText": makeLangText(doc),
+	}
+	if patch.Title != nil {
+		fields["Title"] = doc.Title
+	}
+	if patch.Content != nil {
+		fields["Content"] = doc.Content
+		fields["Summary"] = doc.Summary
+	}
+
+	var buf bytes.Buffer
+	if err = json.NewEncoder(&buf).Encode(map[string]interface{}{"doc": fields}); err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+
+	res, err := i.es.Update(i.indexName, linkID.String(), &buf, i.refreshOpt)
+	if err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+
+	var updateRes esUpdateRes
+	if err = unmarshalResponse(res, &updateRes); err != nil {
+		if esErr, valid := err.(esError); valid && esErr.Type == "document_missing_exception" {
+			return fmt.Errorf("patch: %w", index.ErrNotFound)
+		}
+		return fmt.Errorf("patch: %w", err)
+	}
+
+	return nil
+}
+
+// ensureIndex installs the index template for the configured index and
+// creates the index if it does not already exist.
+func ensureIndex(es *elasticsearch.Client, opts Options) error {
+	tmpl, err := opts.indexTemplate()
+	if err != nil {
+		return fmt.Errorf("cannot create ES index template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err = json.NewEncoder(&buf).Encode(tmpl); err != nil {
+		return fmt.Errorf("cannot create ES index template: %w", err)
+	}
+	res, err := es.Indices.PutTemplate(&buf, opts.templateName())
+	if err != nil {
+		return fmt.Errorf("cannot create ES index template: %w", err)
+	} else if res.IsError() {
+		return fmt.Errorf("cannot create ES index template: %w", unmarshalError(res))
+	}
+	_ = res.Body.Close()
+
+	res, err = es.Indices.Create(opts.IndexName)
 	if err != nil {
 		return fmt.Errorf("cannot create ES index: %w", err)
 	} else if res.IsError() {
@@ -180,21 +531,49 @@ func ensureIndex(es *elasticsearch.Client) error {
 		}
 		return fmt.Errorf("cannot create ES index: %w", err)
 	}
+	_ = res.Body.Close()
 
 	return nil
 }
 
-func runSearch(es *elasticsearch.Client, searchQuery map[string]interface{}) (*esSearchRes, error) {
+// runScrollSearch executes searchQuery and opens a scroll context that can be
+// used to fetch the remaining pages of the result set via runScroll.
+func runScrollSearch(es *elasticsearch.Client, indexName string, searchQuery map[string]interface{}) (*esSearchRes, error) {
+	return runSearch(es, indexName, searchQuery, es.Search.WithScroll(scrollKeepAliveOpt))
+}
+
+func runSearch(es *elasticsearch.Client, indexName string, searchQuery map[string]interface{}, opts ...func(*esapi.SearchRequest)) (*esSearchRes, error) {
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
 		return nil, fmt.Errorf("find by ID: %w", err)
 	}
 
 	// Perform the search request.
-	res, err := es.Search(
+	opts = append([]func(*esapi.SearchRequest){
 		es.Search.WithContext(context.Background()),
 		es.Search.WithIndex(indexName),
 		es.Search.WithBody(&buf),
+	}, opts...)
+	res, err := es.Search(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var esRes esSearchRes
+	if err = unmarshalResponse(res, &esRes); err != nil {
+		return nil, err
+	}
+
+	return &esRes, nil
+}
+
+// runScroll fetches the next page of results for the scroll context
+// identified by scrollID.
+func runScroll(es *elasticsearch.Client, scrollID string) (*esSearchRes, error) {
+	res, err := es.Scroll(
+		es.Scroll.WithContext(context.Background()),
+		es.Scroll.WithScrollID(scrollID),
+		es.Scroll.WithScroll(scrollKeepAliveOpt),
 	)
 	if err != nil {
 		return nil, err
@@ -208,6 +587,119 @@ func runSearch(es *elasticsearch.Client, searchQuery map[string]interface{}) (*e
 	return &esRes, nil
 }
 
+// clearScroll releases the server-side resources associated with a scroll
+// context.
+func clearScroll(es *elasticsearch.Client, scrollID string) error {
+	res, err := es.ClearScroll(
+		es.ClearScroll.WithContext(context.Background()),
+		es.ClearScroll.WithScrollID(scrollID),
+	)
+	if err != nil {
+		return err
+	}
+
+	// A missing scroll context (e.g. it has already expired) is not
+	// considered to be an error.
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return unmarshalError(res)
+	}
+
+	_ = res.Body.Close()
+	return nil
+}
+
+// openPIT opens a point-in-time view of the index and returns its ID.
+func openPIT(es *elasticsearch.Client, cluster ClusterInfo, indexName string) (string, error) {
+	path := "/" + indexName + "/_pit"
+	if cluster.Distribution == DistributionOpenSearch {
+		path = "/" + indexName + "/_search/point_in_time"
+	}
+	req, err := http.NewRequest(http.MethodPost, path+"?keep_alive="+pitKeepAlive, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var pitRes esPitRes
+	if err = performRequest(es, req, &pitRes); err != nil {
+		return "", fmt.Errorf("open point in time: %w", err)
+	}
+	if pitRes.PitID != "" {
+		return pitRes.PitID, nil
+	}
+	return pitRes.ID, nil
+}
+
+// runPITSearch executes searchQuery against the point-in-time view
+// identified by pitID. The returned result carries the (possibly updated)
+// PIT ID that should be used for subsequent requests.
+func runPITSearch(es *elasticsearch.Client, pitID string, searchQuery map[string]interface{}) (*esSearchRes, error) {
+	searchQuery["pit"] = map[string]interface{}{
+		"id":         pitID,
+		"keep_alive": pitKeepAlive,
+	}
+
+	// Searches against a point-in-time view must not specify an index.
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, "/_search", &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var esRes esSearchRes
+	if err = performRequest(es, req, &esRes); err != nil {
+		return nil, err
+	}
+	return &esRes, nil
+}
+
+// closePIT releases the server-side resources associated with a
+// point-in-time view.
+func closePIT(es *elasticsearch.Client, cluster ClusterInfo, pitID string) error {
+	path, body := "/_pit", map[string]interface{}{"id": pitID}
+	if cluster.Distribution == DistributionOpenSearch {
+		path, body = "/_search/point_in_time", map[string]interface{}{"pit_id": []string{pitID}}
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, path, &buf)
+	if err != nil {
+		return err
+	}
+
+	// A missing point-in-time view (e.g. it has already expired) is not
+	// considered to be an error.
+	if err = performRequest(es, req, nil); err != nil {
+		if esErr, valid := err.(esError); valid && esErr.Type == "resource_not_found_exception" {
+			return nil
+		}
+		return fmt.Errorf("close point in time: %w", err)
+	}
+	return nil
+}
+
+// performRequest sends a raw request for endpoints that are not covered by
+// the esapi package and decodes the response into to. The response is
+// discarded if to is nil.
+func performRequest(es *elasticsearch.Client, req *http.Request, to interface{}) error {
+	req.Header.Set("Content-Type", "application/json")
+	httpRes, err := es.Perform(req)
+	if err != nil {
+		return err
+	}
+
+	if to == nil {
+		to = new(map[string]interface{})
+	}
+	res := &esapi.Response{StatusCode: httpRes.StatusCode, Body: httpRes.Body, Header: httpRes.Header}
+	return unmarshalResponse(res, to)
+}
+
 func unmarshalError(res *esapi.Response) error {
 	return unmarshalResponse(res, nil)
 }
@@ -229,23 +721,133 @@ func unmarshalResponse(res *esapi.Response, to interface{}) error {
 
 func mapEsDoc(d *esDoc) *index.Document {
 	return &index.Document{
-		LinkID:    uuid.MustParse(d.LinkID),
-		URL:       d.URL,
-		Title:     d.Title,
-		Content:   d.Content,
-		IndexedAt: d.IndexedAt.UTC(),
-		PageRank:  d.PageRank,
+		LinkID:       uuid.MustParse(d.LinkID),
+		URL:          d.URL,
+		Title:        d.Title,
+		Content:      d.Content,
+		Summary:      d.Summary,
+		Language:     d.Language,
+		Vertical:     d.Vertical,
+		Namespace:    namespace.OrDefault(d.Namespace),
+		IndexedAt:    d.IndexedAt.UTC(),
+		PageRank:     d.PageRank,
+		Author:       d.Author,
+		PublishedAt:  mapEsDate(d.PublishedAt),
+		FaviconRef:   d.FaviconRef,
+		ThumbnailRef: d.ThumbnailRef,
+		Headers:      mapEsHeaders(d.Headers),
+		Fingerprint:  mapEsHash(d.Fingerprint),
+		ContentHash:  mapEsHash(d.ContentHash),
+		ChangedAt:    mapEsDate(d.ChangedAt),
+		DuplicateOf:  mapEsDuplicateOf(d.DuplicateOf),
+	}
+}
+
+func mapEsDate(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
 	}
+	return t.UTC()
+}
+
+func mapEsHash(v string) uint64 {
+	fp, _ := strconv.ParseUint(v, 16, 64)
+	return fp
+}
+
+func mapEsDuplicateOf(v string) uuid.UUID {
+	if v == "" {
+		return uuid.Nil
+	}
+	id, _ := uuid.Parse(v)
+	return id
+}
+
+func mapEsHeaders(list []esHeader) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	headers := make(map[string]string, len(list))
+	for _, h := range list {
+		headers[h.Name] = h.Value
+	}
+	return headers
 }
 
 func makeEsDoc(d *index.Document) esDoc {
 	// Note: we intentionally skip PageRank as we don't want updates to
 	// overwrite existing PageRank values.
 	return esDoc{
-		LinkID:    d.LinkID.String(),
-		URL:       d.URL,
-		Title:     d.Title,
-		Content:   d.Content,
-		IndexedAt: d.IndexedAt.UTC(),
+		LinkID:      d.LinkID.String(),
+		URL:         d.URL,
+		Title:       d.Title,
+		Content:     d.Content,
+		Summary:     d.Summary,
+		Language:    d.Language,
+		IndexedAt:   d.IndexedAt.UTC(),
+		Author:      d.Author,
+		PublishedAt: makeEsDate(d.PublishedAt),
+		Host:        index.HostOf(d),
+		IndexedDate: index.IndexedDateOf(d),
+		Vertical:    d.Vertical,
+		Namespace:   d.Namespace,
+		LangText:    makeLangText(d),
+		Headers:     makeEsHeaders(d),
+		HeaderTerms: index.HeaderTermsOf(d),
+
+		FaviconRef:   d.FaviconRef,
+		ThumbnailRef: d.ThumbnailRef,
+
+		Fingerprint: makeEsHash(d.Fingerprint),
+		ContentHash: makeEsHash(d.ContentHash),
+		ChangedAt:   makeEsDate(d.ChangedAt),
+		DuplicateOf: makeEsDuplicateOf(d.DuplicateOf),
+	}
+}
+
+func makeEsDate(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func makeEsHash(h uint64) string {
+	if h == 0 {
+		return ""
+	}
+	return strconv.FormatUint(h, 16)
+}
+
+func makeEsDuplicateOf(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
+// makeEsHeaders returns the captured headers of d sorted by name.
+func makeEsHeaders(d *index.Document) []esHeader {
+	list := make([]esHeader, 0, len(d.Headers))
+	for name, value := range d.Headers {
+		list = append(list, esHeader{Name: name, Value: value})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// makeLangText returns the text to index with the analyzer for the language
+// of d. As documents are updated via partial updates, the entries for all
+// other supported languages are explicitly cleared in case the language of
+// the document has changed.
+func makeLangText(d *index.Document) map[string]string {
+	langText := make(map[string]string, len(index.SupportedLanguages))
+	for _, lang := range index.SupportedLanguages {
+		langText[lang] = ""
+	}
+	if index.IsSupportedLanguage(d.Language) {
+		langText[d.Language] = d.Title + "\n" + d.Content
 	}
+	return langText
 }
@@ -1,6 +1,7 @@
 package es
 
 import (
+	"context"
 	"os"
 	"strings"
 	"testing"
@@ -15,7 +16,8 @@ func Test(t *testing.T) { gc.TestingT(t) }
 
 type ElasticSearchTestSuite struct {
 	indextest.SuiteBase
-	idx *ElasticSearchIndexer
+	idx  *ElasticSearchIndexer
+	opts Options
 }
 
 func (s *ElasticSearchTestSuite) SetUpSuite(c *gc.C) {
@@ -24,7 +26,8 @@ func (s *ElasticSearchTestSuite) SetUpSuite(c *gc.C) {
 		c.Skip("Missing ES_NODES envvar; skipping elasticsearch-backed index test suite")
 	}
 
-	idx, err := NewElasticSearchIndexer(strings.Split(nodeList, ","), true)
+	s.opts = Options{IndexName: "textindexer-test", Replicas: NoReplicas, SyncUpdates: true}
+	idx, err := NewElasticSearchIndexer(strings.Split(nodeList, ","), s.opts)
 	c.Assert(err, gc.IsNil)
 	s.SetIndexer(idx)
 	s.idx = idx
@@ -32,9 +35,14 @@ func (s *ElasticSearchTestSuite) SetUpSuite(c *gc.C) {
 
 func (s *ElasticSearchTestSuite) SetUpTest(c *gc.C) {
 	if s.idx.es != nil {
-		_, err := s.idx.es.Indices.Delete([]string{indexName})
+		_, err := s.idx.es.Indices.Delete([]string{s.idx.indexName})
 		c.Assert(err, gc.IsNil)
-		err = ensureIndex(s.idx.es)
+		s.opts.applyDefaults()
+		err = ensureIndex(s.idx.es, s.opts)
 		c.Assert(err, gc.IsNil)
 	}
 }
+
+func (s *ElasticSearchTestSuite) TestPing(c *gc.C) {
+	c.Assert(s.idx.Ping(context.Background()), gc.IsNil)
+}
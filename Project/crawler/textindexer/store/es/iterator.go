@@ -6,25 +6,36 @@ import (
 	"github.com/elastic/go-elasticsearch"
 )
 
-// esIterator implements index.Iterator.
+// esIterator implements index.Iterator. Depending on the search, the results
+// are either paged via from/size requests that re-run query or via a scroll
+// context.
 type esIterator struct {
 	es        *elasticsearch.Client
-	searchReq map[string]interface{}
+	indexName string
+	query     map[string]interface{}
 
 	cumIdx uint64
 	rsIdx  int
 	rs     *esSearchRes
 
-	latchedDoc *index.Document
-	lastErr    error
+	facets      []index.Facet
+	suggestions []index.Suggestion
+	latchedDoc  *index.Document
+	lastErr     error
 }
 
 // Close the iterator and release any allocated resources.
 func (it *esIterator) Close() error {
+	var err error
+	if it.es != nil && it.rs != nil && it.rs.ScrollID != "" {
+		err = clearScroll(it.es, it.rs.ScrollID)
+	}
+
 	it.es = nil
-	it.searchReq = nil
-	it.cumIdx = it.rs.Hits.Total.Count
-	return nil
+	if it.rs != nil {
+		it.cumIdx = it.rs.Hits.Total.Count
+	}
+	return err
 }
 
 // Next loads the next document matching the search query.
@@ -35,13 +46,8 @@ func (it *esIterator) Next() bool {
 	}
 
 	// Do we need to fetch the next batch?
-	if it.rsIdx >= len(it.rs.Hits.HitList) {
-		it.searchReq["from"] = it.searchReq["from"].(uint64) + batchSize
-		if it.rs, it.lastErr = runSearch(it.es, it.searchReq); it.lastErr != nil {
-			return false
-		}
-
-		it.rsIdx = 0
+	if it.rsIdx >= len(it.rs.Hits.HitList) && !it.fetchNextBatch() {
+		return false
 	}
 
 	it.latchedDoc = mapEsDoc(&it.rs.Hits.HitList[it.rsIdx].DocSource)
@@ -50,6 +56,61 @@ func (it *esIterator) Next() bool {
 	return true
 }
 
+// skip advances the iterator past the first n results. Scroll contexts do
+// not support arbitrary offsets so any batches that fall entirely within
+// the skipped range are fetched and discarded. Searches for a page of results
+// pass the offset to the cluster instead.
+func (it *esIterator) skip(n uint64) error {
+	for n > 0 && it.cumIdx < it.rs.Hits.Total.Count {
+		if it.rsIdx >= len(it.rs.Hits.HitList) && !it.fetchNextBatch() {
+			return it.lastErr
+		}
+
+		avail := uint64(len(it.rs.Hits.HitList) - it.rsIdx)
+		if avail > n {
+			avail = n
+		}
+		it.rsIdx += int(avail)
+		it.cumIdx += avail
+		n -= avail
+	}
+
+	return nil
+}
+
+// fetchNextBatch retrieves the next page of results from the scroll context
+// or, if the results are not paged via a scroll context, by running the
+// query from the current offset.
+func (it *esIterator) fetchNextBatch() bool {
+	var (
+		rs  *esSearchRes
+		err error
+	)
+	if it.rs.ScrollID != "" {
+		rs, err = runScroll(it.es, it.rs.ScrollID)
+	} else {
+		// The aggregations have already been collected.
+		delete(it.query, "aggs")
+		it.query["from"] = it.cumIdx
+		rs, err = runSearch(it.es, it.indexName, it.query)
+	}
+	if err != nil {
+		it.lastErr = err
+		return false
+	}
+	it.rs = rs
+
+	// Guard against the cluster returning fewer results than the
+	// reported total (e.g. due to concurrent deletions).
+	if len(it.rs.Hits.HitList) == 0 {
+		it.cumIdx = it.rs.Hits.Total.Count
+		return false
+	}
+
+	it.rsIdx = 0
+	return true
+}
+
 // Error returns the last error encountered by the iterator.
 func (it *esIterator) Error() error {
 	return it.lastErr
@@ -64,3 +125,115 @@ func (it *esIterator) Document() *index.Document {
 func (it *esIterator) TotalCount() uint64 {
 	return it.rs.Hits.Total.Count
 }
+
+// MaxScore returns the highest relevance score among the search results.
+func (it *esIterator) MaxScore() float64 {
+	return it.rs.Hits.MaxScore
+}
+
+// Facets returns the aggregations requested by the search query.
+func (it *esIterator) Facets() []index.Facet {
+	return it.facets
+}
+
+// Suggestions returns corrected versions of the search expression if it
+// matched few documents.
+func (it *esIterator) Suggestions() []index.Suggestion {
+	return it.suggestions
+}
+
+// esAllIterator implements index.DocumentIterator. Documents are fetched in
+// batches sorted by link ID from a point-in-time view of the index; each
+// batch resumes after the link ID of the last document in the previous one.
+type esAllIterator struct {
+	es          *elasticsearch.Client
+	cluster     ClusterInfo
+	pitID       string
+	searchAfter string
+	batchSize   int
+
+	rsIdx int
+	rs    *esSearchRes
+
+	latchedDoc *index.Document
+	lastErr    error
+}
+
+// Close the iterator and release any allocated resources.
+func (it *esAllIterator) Close() error {
+	var err error
+	if it.es != nil && it.pitID != "" {
+		err = closePIT(it.es, it.cluster, it.pitID)
+	}
+
+	it.es = nil
+	it.rs = nil
+	return err
+}
+
+// Next loads the next document. It returns false if no more documents are
+// available.
+func (it *esAllIterator) Next() bool {
+	if it.lastErr != nil || it.es == nil {
+		return false
+	}
+
+	// Do we need to fetch the next batch?
+	if it.rs == nil || it.rsIdx >= len(it.rs.Hits.HitList) {
+		// A short batch means that there are no more documents.
+		if it.rs != nil && len(it.rs.Hits.HitList) < it.batchSize {
+			return false
+		}
+		if !it.fetchNextBatch() {
+			return false
+		}
+	}
+
+	it.latchedDoc = mapEsDoc(&it.rs.Hits.HitList[it.rsIdx].DocSource)
+	it.searchAfter = it.latchedDoc.LinkID.String()
+	it.rsIdx++
+	return true
+}
+
+// fetchNextBatch retrieves the batch of documents that follows searchAfter.
+func (it *esAllIterator) fetchNextBatch() bool {
+	query := map[string]interface{}{
+		"query":            map[string]interface{}{"match_all": map[string]interface{}{}},
+		"size":             it.batchSize,
+		"sort":             []interface{}{map[string]interface{}{"LinkID": "asc"}},
+		"track_total_hits": false,
+	}
+	if it.searchAfter != "" {
+		query["search_after"] = []interface{}{it.searchAfter}
+	}
+
+	rs, err := runPITSearch(it.es, it.pitID, query)
+	if err != nil {
+		it.lastErr = err
+		return false
+	}
+	if rs.PitID != "" {
+		it.pitID = rs.PitID
+	}
+
+	it.rs, it.rsIdx = rs, 0
+	return len(rs.Hits.HitList) != 0
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *esAllIterator) Error() error {
+	return it.lastErr
+}
+
+// Document returns the current document.
+func (it *esAllIterator) Document() *index.Document {
+	return it.latchedDoc
+}
+
+// Cursor returns a cursor for the current document.
+func (it *esAllIterator) Cursor() index.Cursor {
+	if it.latchedDoc == nil {
+		return ""
+	}
+	return index.CursorFor(it.latchedDoc.LinkID)
+}
@@ -0,0 +1,296 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrIncompatibleMapping is returned by write operations if
+// Options.RejectWritesOnDrift is set and the live index mapping has drifted
+// from the expected mappings in a way that affects indexing or scoring.
+var ErrIncompatibleMapping = errors.New("incompatible index mapping")
+
+// The field mapping parameters that are compared by mapping drift checks.
+// Changes to other parameters (e.g. "ignore_above") do not affect scoring.
+var driftParams = []string{"type", "analyzer", "search_analyzer", "normalizer", "index", "enabled"}
+
+// FieldChange describes a mapping parameter of a field whose live value
+// differs from the expected one.
+type FieldChange struct {
+	Field    string `json:"field"`
+	Param    string `json:"param"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// MappingDrift describes the differences between the live mapping of the
+// index and the expected mappings. Nested fields and multi-fields are
+// identified by their dotted paths (e.g. "Title.autocomplete").
+type MappingDrift struct {
+	// The time at which the live mapping was checked.
+	CheckedAt time.Time `json:"checkedAt"`
+
+	// Expected fields that are missing from the live mapping. Documents
+	// that populate them cause the fields to be mapped dynamically.
+	Missing []string `json:"missing,omitempty"`
+
+	// Fields whose mapping parameters have been modified.
+	Changed []FieldChange `json:"changed,omitempty"`
+
+	// Fields that are only present in the live mapping.
+	Unexpected []string `json:"unexpected,omitempty"`
+}
+
+// Drifted returns true if the live mapping differs from the expected one.
+func (d *MappingDrift) Drifted() bool {
+	return len(d.Missing) != 0 || len(d.Changed) != 0 || len(d.Unexpected) != 0
+}
+
+// Compatible returns true if the differences do not affect indexing or
+// scoring, i.e. the live mapping only adds fields to the expected one.
+func (d *MappingDrift) Compatible() bool {
+	return len(d.Missing) == 0 && len(d.Changed) == 0
+}
+
+// String returns a human-readable summary of the differences.
+func (d *MappingDrift) String() string {
+	if !d.Drifted() {
+		return "no drift"
+	}
+
+	var parts []string
+	if len(d.Missing) != 0 {
+		parts = append(parts, "missing fields: "+strings.Join(d.Missing, ", "))
+	}
+	if len(d.Changed) != 0 {
+		changes := make([]string, len(d.Changed))
+		for i, c := range d.Changed {
+			changes[i] = fmt.Sprintf("%s.%s %s -> %s", c.Field, c.Param, c.Expected, c.Actual)
+		}
+		parts = append(parts, "changed fields: "+strings.Join(changes, ", "))
+	}
+	if len(d.Unexpected) != 0 {
+		parts = append(parts, "unexpected fields: "+strings.Join(d.Unexpected, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// CheckMapping compares the live mapping of the index with the expected
+// mappings and records the outcome, which is reported by CheckHealth and
+// consulted by write operations if Options.RejectWritesOnDrift is set.
+// Operators sometimes edit mappings by hand, which silently breaks scoring;
+// the check is run when the indexer is created and periodically by the
+// MappingMonitor.
+func (i *ElasticSearchIndexer) CheckMapping() (*MappingDrift, error) {
+	req, err := http.NewRequest(http.MethodGet, "/"+i.indexName+"/_mapping", nil)
+	if err != nil {
+		return nil, fmt.Errorf("check mapping: %w", err)
+	}
+
+	// The response is keyed by the name of the concrete index, which
+	// differs from the configured name if the latter is an alias.
+	var mappingRes map[string]struct {
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	if err = performRequest(i.es, req, &mappingRes); err != nil {
+		return nil, fmt.Errorf("check mapping: %w", err)
+	}
+	live, found := mappingRes[i.indexName]
+	if !found {
+		if len(mappingRes) != 1 {
+			return nil, fmt.Errorf("check mapping: no mapping returned for index %q", i.indexName)
+		}
+		for _, m := range mappingRes {
+			live = m
+		}
+	}
+
+	drift := diffMappings(i.expectedMapping, live.Mappings)
+	drift.CheckedAt = time.Now()
+
+	i.driftMu.Lock()
+	prev := i.drift
+	i.drift = drift
+	i.driftMu.Unlock()
+
+	switch {
+	case drift.Drifted() && (prev == nil || prev.String() != drift.String()):
+		i.logger.Warn("index mapping has drifted from the expected mappings",
+			"index", i.indexName, "compatible", drift.Compatible(), "drift", drift.String())
+	case !drift.Drifted() && prev != nil && prev.Drifted():
+		i.logger.Info("index mapping matches the expected mappings again", "index", i.indexName)
+	}
+	return drift, nil
+}
+
+// MappingDrift returns the outcome of the latest mapping check.
+func (i *ElasticSearchIndexer) MappingDrift() *MappingDrift {
+	i.driftMu.RLock()
+	defer i.driftMu.RUnlock()
+	return i.drift
+}
+
+// CheckHealth reports the outcome of the latest mapping check. It returns an
+// error if the live mapping is incompatible with the expected mappings.
+func (i *ElasticSearchIndexer) CheckHealth() (interface{}, error) {
+	drift := i.MappingDrift()
+	switch {
+	case drift == nil:
+		return nil, nil
+	case !drift.Compatible():
+		return drift, fmt.Errorf("%w: %s", ErrIncompatibleMapping, drift)
+	default:
+		return drift, nil
+	}
+}
+
+// checkWritable returns an error wrapping ErrIncompatibleMapping if writes
+// are rejected due to incompatible mapping drift.
+func (i *ElasticSearchIndexer) checkWritable() error {
+	if !i.rejectDriftWrites {
+		return nil
+	}
+	if drift := i.MappingDrift(); drift != nil && !drift.Compatible() {
+		return fmt.Errorf("%w: %s", ErrIncompatibleMapping, drift)
+	}
+	return nil
+}
+
+// MappingMonitor periodically checks the mapping of an index for drift. It
+// implements service.Service.
+type MappingMonitor struct {
+	idx      *ElasticSearchIndexer
+	interval time.Duration
+}
+
+// MappingMonitor returns a monitor that checks the mapping of the index every
+// Options.MappingCheckInterval or nil if periodic checks are disabled.
+func (i *ElasticSearchIndexer) MappingMonitor() *MappingMonitor {
+	if i.mappingCheckInterval <= 0 {
+		return nil
+	}
+	return &MappingMonitor{idx: i, interval: i.mappingCheckInterval}
+}
+
+// Name implements service.Service.
+func (m *MappingMonitor) Name() string { return "mapping-monitor" }
+
+// Run checks the index mapping every check interval until ctx is cancelled.
+// Failed checks are logged and the outcome of the previous check is kept.
+func (m *MappingMonitor) Run(ctx context.Context) error {
+	m.idx.logger.Info("starting service", "check_interval", m.interval)
+	defer m.idx.logger.Info("stopped service")
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if _, err := m.idx.CheckMapping(); err != nil {
+			m.idx.logger.Warn("unable to check index mapping", "err", err)
+		}
+	}
+}
+
+// parseMapping decodes the JSON mappings of an index.
+func parseMapping(mappings string) (map[string]interface{}, error) {
+	var mapping map[string]interface{}
+	if err := json.Unmarshal([]byte(mappings), &mapping); err != nil {
+		return nil, fmt.Errorf("invalid index mappings: %w", err)
+	}
+	return mapping, nil
+}
+
+// diffMappings compares the fields of the live mapping with the expected
+// ones.
+func diffMappings(expected, live map[string]interface{}) *MappingDrift {
+	expFields, liveFields := flattenMapping(expected), flattenMapping(live)
+
+	drift := new(MappingDrift)
+	for _, field := range sortedFields(expFields) {
+		liveParams, found := liveFields[field]
+		if !found {
+			drift.Missing = append(drift.Missing, field)
+			continue
+		}
+		for _, param := range driftParams {
+			expVal, set := expFields[field][param]
+			if !set {
+				continue
+			}
+			if exp, act := formatParam(expVal), formatParam(liveParams[param]); exp != act {
+				drift.Changed = append(drift.Changed, FieldChange{Field: field, Param: param, Expected: exp, Actual: act})
+			}
+		}
+	}
+	for _, field := range sortedFields(liveFields) {
+		if _, found := expFields[field]; !found {
+			drift.Unexpected = append(drift.Unexpected, field)
+		}
+	}
+	return drift
+}
+
+// flattenMapping returns the mapping parameters of each field of mapping keyed
+// by the dotted path of the field. Fields without an explicit type are
+// objects.
+func flattenMapping(mapping map[string]interface{}) map[string]map[string]interface{} {
+	fields := make(map[string]map[string]interface{})
+	var flatten func(prefix string, props map[string]interface{})
+	flatten = func(prefix string, props map[string]interface{}) {
+		for name, raw := range props {
+			field, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			path := prefix + name
+			params := make(map[string]interface{}, len(field))
+			for param, val := range field {
+				if param != "properties" && param != "fields" {
+					params[param] = val
+				}
+			}
+			if _, typed := params["type"]; !typed {
+				params["type"] = "object"
+			}
+			fields[path] = params
+
+			if nested, ok := field["properties"].(map[string]interface{}); ok {
+				flatten(path+".", nested)
+			}
+			if multi, ok := field["fields"].(map[string]interface{}); ok {
+				flatten(path+".", multi)
+			}
+		}
+	}
+	if props, ok := mapping["properties"].(map[string]interface{}); ok {
+		flatten("", props)
+	}
+	return fields
+}
+
+func sortedFields(fields map[string]map[string]interface{}) []string {
+	list := make([]string, 0, len(fields))
+	for field := range fields {
+		list = append(list, field)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func formatParam(val interface{}) string {
+	if val == nil {
+		return "unset"
+	}
+	return fmt.Sprint(val)
+}
@@ -0,0 +1,139 @@
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+
+	"github.com/elastic/go-elasticsearch"
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(MappingTestSuite))
+
+type MappingTestSuite struct {
+	srv      *httptest.Server
+	mapping  map[string]interface{}
+	requests []string
+	idx      *ElasticSearchIndexer
+}
+
+func (s *MappingTestSuite) SetUpTest(c *gc.C) {
+	s.requests = nil
+	s.mapping = s.defaultMapping(c)
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{s.srv.URL}})
+	c.Assert(err, gc.IsNil)
+	s.idx = &ElasticSearchIndexer{
+		es:              client,
+		indexName:       "textindexer",
+		logger:          logging.Discard(),
+		expectedMapping: s.defaultMapping(c),
+	}
+}
+
+func (s *MappingTestSuite) TearDownTest(c *gc.C) {
+	s.srv.Close()
+}
+
+// serve emulates the get mapping API for an index that is accessed via an
+// alias.
+func (s *MappingTestSuite) serve(w http.ResponseWriter, r *http.Request) {
+	req := r.Method + " " + r.URL.Path
+	s.requests = append(s.requests, req)
+
+	w.Header().Set("Content-Type", "application/json")
+	switch req {
+	case "GET /textindexer/_mapping":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"textindexer-000001": map[string]interface{}{"mappings": s.mapping},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"type":"not_found","reason":"unexpected request"}}`)
+	}
+}
+
+func (s *MappingTestSuite) defaultMapping(c *gc.C) map[string]interface{} {
+	mapping, err := parseMapping(esMappings)
+	c.Assert(err, gc.IsNil)
+	return mapping
+}
+
+func (s *MappingTestSuite) TestDiffMappings(c *gc.C) {
+	drift := diffMappings(s.defaultMapping(c), s.mapping)
+	c.Assert(drift.Drifted(), gc.Equals, false)
+	c.Assert(drift.String(), gc.Equals, "no drift")
+
+	props := s.mapping["properties"].(map[string]interface{})
+	props["Title"] = map[string]interface{}{"type": "keyword"}
+	props["Extra"] = map[string]interface{}{"type": "text"}
+	delete(props, "PageRank")
+
+	drift = diffMappings(s.defaultMapping(c), s.mapping)
+	c.Assert(drift.Drifted(), gc.Equals, true)
+	c.Assert(drift.Compatible(), gc.Equals, false)
+	c.Assert(drift.Missing, gc.DeepEquals, []string{"PageRank", "Title.autocomplete"})
+	c.Assert(drift.Changed, gc.DeepEquals, []FieldChange{{Field: "Title", Param: "type", Expected: "text", Actual: "keyword"}})
+	c.Assert(drift.Unexpected, gc.DeepEquals, []string{"Extra"})
+	c.Assert(drift.String(), gc.Equals, "missing fields: PageRank, Title.autocomplete; changed fields: Title.type text -> keyword; unexpected fields: Extra")
+}
+
+func (s *MappingTestSuite) TestCompatibleDrift(c *gc.C) {
+	props := s.mapping["properties"].(map[string]interface{})
+	props["Extra"] = map[string]interface{}{"type": "text"}
+
+	drift, err := s.idx.CheckMapping()
+	c.Assert(err, gc.IsNil)
+	c.Assert(drift.Drifted(), gc.Equals, true)
+	c.Assert(drift.Compatible(), gc.Equals, true)
+
+	details, err := s.idx.CheckHealth()
+	c.Assert(err, gc.IsNil)
+	c.Assert(details, gc.Equals, drift)
+}
+
+func (s *MappingTestSuite) TestRejectWritesOnDrift(c *gc.C) {
+	s.idx.rejectDriftWrites = true
+	props := s.mapping["properties"].(map[string]interface{})
+	props["PageRank"] = map[string]interface{}{"type": "keyword"}
+
+	drift, err := s.idx.CheckMapping()
+	c.Assert(err, gc.IsNil)
+	c.Assert(drift.Compatible(), gc.Equals, false)
+	c.Assert(s.idx.MappingDrift(), gc.Equals, drift)
+
+	_, err = s.idx.CheckHealth()
+	c.Assert(errors.Is(err, ErrIncompatibleMapping), gc.Equals, true)
+	err = s.idx.Index(&index.Document{LinkID: uuid.New()})
+	c.Assert(errors.Is(err, ErrIncompatibleMapping), gc.Equals, true)
+	c.Assert(err, gc.ErrorMatches, "index: incompatible index mapping: changed fields: PageRank.type double -> keyword")
+	err = s.idx.UpdateScore(uuid.New(), 0.5)
+	c.Assert(errors.Is(err, ErrIncompatibleMapping), gc.Equals, true)
+	c.Assert(s.requests, gc.DeepEquals, []string{"GET /textindexer/_mapping"}, gc.Commentf("expected writes to be rejected without contacting the cluster"))
+
+	// Writes are accepted again once the mapping has been fixed.
+	s.mapping = s.defaultMapping(c)
+	_, err = s.idx.CheckMapping()
+	c.Assert(err, gc.IsNil)
+	_, err = s.idx.CheckHealth()
+	c.Assert(err, gc.IsNil)
+	c.Assert(s.idx.checkWritable(), gc.IsNil)
+}
+
+func (s *MappingTestSuite) TestIncompatibleDriftWithoutRejectingWrites(c *gc.C) {
+	props := s.mapping["properties"].(map[string]interface{})
+	delete(props, "LinkID")
+
+	_, err := s.idx.CheckMapping()
+	c.Assert(err, gc.IsNil)
+	_, err = s.idx.CheckHealth()
+	c.Assert(errors.Is(err, ErrIncompatibleMapping), gc.Equals, true)
+	c.Assert(s.idx.checkWritable(), gc.IsNil)
+}
@@ -0,0 +1,164 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/namespace"
+)
+
+// NoReplicas can be assigned to Options.Replicas to create an index without
+// any replica shards.
+const NoReplicas = -1
+
+// Options configures the elasticsearch index used by an ElasticSearchIndexer.
+type Options struct {
+	// The name of the index to store documents in. Defaults to
+	// "textindexer".
+	IndexName string
+
+	// The namespace of the crawl whose documents are accessed through the
+	// indexer. Each namespace other than namespace.Default is stored in
+	// its own index whose name is IndexName suffixed with "-" and the
+	// namespace. Defaults to namespace.Default.
+	Namespace string
+
+	// The number of primary shards for the index. If zero, the cluster
+	// default is used.
+	Shards int
+
+	// The number of replicas for each primary shard. If zero, the cluster
+	// default is used. Set to NoReplicas to disable replication.
+	Replicas int
+
+	// How often the index is refreshed to make recent changes visible to
+	// searches. If zero, the cluster default is used. A negative value
+	// disables periodic refreshes.
+	RefreshInterval time.Duration
+
+	// An optional JSON document that overrides the default document
+	// mappings. It is used verbatim as the "mappings" section of the
+	// index template. Custom mappings should keep the Title.autocomplete
+	// field of the default mappings; otherwise, Suggest never returns any
+	// titles. Likewise, URL boosts only take effect if the URL.text field
+	// is kept.
+	Mappings string
+
+	// How often the live index mapping is compared with the expected
+	// mappings to detect manual edits; see CheckMapping. The mapping is
+	// always checked when the indexer is created. Defaults to 5 minutes;
+	// a negative value disables periodic checks.
+	MappingCheckInterval time.Duration
+
+	// If set, write operations fail with ErrIncompatibleMapping while the
+	// latest mapping check reports drift that affects indexing or
+	// scoring. Otherwise, drift is only logged and reported by
+	// CheckHealth.
+	RejectWritesOnDrift bool
+
+	// The boosts of the fields searched by queries that do not target a
+	// specific field. Defaults to index.DefaultFieldBoosts.
+	FieldBoosts index.FieldBoosts
+
+	// The number of results fetched per search request. Defaults to
+	// index.DefaultBatchSize.
+	BatchSize int
+
+	// The largest query offset accepted by Search. Pages of search results
+	// are requested from the offset via from/size, so the offset must not
+	// exceed the max_result_window of the index. Defaults to
+	// index.DefaultMaxOffset.
+	MaxOffset uint64
+
+	// If set, write operations block until the index has been refreshed
+	// so that changes are immediately visible to searches.
+	SyncUpdates bool
+
+	// Credentials for clusters that require basic authentication.
+	Username string
+	Password string
+
+	// The base64-encoded API key (i.e. the encoding of "id:api_key") for
+	// clusters that use API key authentication. APIKey and Username are
+	// mutually exclusive.
+	APIKey string
+
+	// An optional PEM-encoded CA certificate bundle for verifying the
+	// certificates presented by the cluster nodes in addition to the
+	// system certificate pool.
+	CACert []byte
+
+	// If set, the certificates presented by the cluster nodes are not
+	// verified. This should only be used for development clusters.
+	InsecureSkipVerify bool
+
+	// An optional logger for reporting failed requests to the cluster. If
+	// not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (opts *Options) applyDefaults() {
+	if opts.IndexName == "" {
+		opts.IndexName = defaultIndexName
+	}
+	opts.Namespace = namespace.OrDefault(opts.Namespace)
+	if opts.Namespace != namespace.Default {
+		opts.IndexName += "-" + opts.Namespace
+	}
+	if opts.Mappings == "" {
+		opts.Mappings = esMappings
+	}
+	if opts.FieldBoosts == nil {
+		opts.FieldBoosts = index.DefaultFieldBoosts
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = index.DefaultBatchSize
+	}
+	if opts.MaxOffset == 0 {
+		opts.MaxOffset = index.DefaultMaxOffset
+	}
+	if opts.MappingCheckInterval == 0 {
+		opts.MappingCheckInterval = 5 * time.Minute
+	}
+}
+
+// templateName returns the name of the index template that holds the settings
+// and mappings for the index.
+func (opts *Options) templateName() string {
+	return opts.IndexName + "-template"
+}
+
+// indexTemplate returns the body of the index template request for the
+// configured index. Settings are applied via a template rather than at index
+// creation time so that they also apply when the index is implicitly
+// (re)created by a write operation.
+func (opts *Options) indexTemplate() (map[string]interface{}, error) {
+	if !json.Valid([]byte(opts.Mappings)) {
+		return nil, fmt.Errorf("invalid index mappings: malformed JSON")
+	}
+
+	settings := make(map[string]interface{})
+	if opts.Shards > 0 {
+		settings["number_of_shards"] = opts.Shards
+	}
+	switch {
+	case opts.Replicas == NoReplicas:
+		settings["number_of_replicas"] = 0
+	case opts.Replicas > 0:
+		settings["number_of_replicas"] = opts.Replicas
+	}
+	switch {
+	case opts.RefreshInterval < 0:
+		settings["refresh_interval"] = "-1"
+	case opts.RefreshInterval > 0:
+		settings["refresh_interval"] = fmt.Sprintf("%dms", opts.RefreshInterval.Milliseconds())
+	}
+
+	return map[string]interface{}{
+		"index_patterns": []string{opts.IndexName},
+		"settings":       map[string]interface{}{"index": settings, "analysis": esAnalysis},
+		"mappings":       json.RawMessage(opts.Mappings),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+	"webcrawler/namespace"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(OptionsTestSuite))
+
+type OptionsTestSuite struct{}
+
+func (s *OptionsTestSuite) TestDefaultTemplate(c *gc.C) {
+	var opts Options
+	opts.applyDefaults()
+	c.Assert(opts.IndexName, gc.Equals, defaultIndexName)
+	c.Assert(opts.templateName(), gc.Equals, "textindexer-template")
+
+	tmpl := s.renderTemplate(c, opts)
+	c.Assert(tmpl["index_patterns"], gc.DeepEquals, []interface{}{"textindexer"})
+	settings := tmpl["settings"].(map[string]interface{})
+	c.Assert(settings["index"], gc.DeepEquals, map[string]interface{}{})
+	c.Assert(settings["analysis"].(map[string]interface{})["analyzer"], gc.DeepEquals, map[string]interface{}{
+		"autocomplete": map[string]interface{}{
+			"type":      "custom",
+			"tokenizer": "standard",
+			"filter":    []interface{}{"lowercase", "autocomplete_prefix"},
+		},
+	})
+
+	props := tmpl["mappings"].(map[string]interface{})["properties"].(map[string]interface{})
+	c.Assert(props["LinkID"], gc.DeepEquals, map[string]interface{}{"type": "keyword"})
+}
+
+func (s *OptionsTestSuite) TestCustomTemplate(c *gc.C) {
+	opts := Options{
+		IndexName:       "crawl-staging",
+		Shards:          3,
+		Replicas:        NoReplicas,
+		RefreshInterval: 30 * time.Second,
+		Mappings:        `{"properties": {"LinkID": {"type": "keyword"}}}`,
+	}
+	opts.applyDefaults()
+
+	tmpl := s.renderTemplate(c, opts)
+	c.Assert(tmpl["index_patterns"], gc.DeepEquals, []interface{}{"crawl-staging"})
+	c.Assert(tmpl["settings"].(map[string]interface{})["index"], gc.DeepEquals, map[string]interface{}{
+		"number_of_shards":   float64(3),
+		"number_of_replicas": float64(0),
+		"refresh_interval":   "30000ms",
+	})
+	c.Assert(tmpl["mappings"], gc.DeepEquals, map[string]interface{}{
+		"properties": map[string]interface{}{
+			"LinkID": map[string]interface{}{"type": "keyword"},
+		},
+	})
+}
+
+func (s *OptionsTestSuite) TestNamespacedIndexName(c *gc.C) {
+	opts := Options{Namespace: namespace.Default}
+	opts.applyDefaults()
+	c.Assert(opts.IndexName, gc.Equals, defaultIndexName)
+
+	opts = Options{IndexName: "crawl", Namespace: "news-2024"}
+	opts.applyDefaults()
+	c.Assert(opts.IndexName, gc.Equals, "crawl-news-2024")
+	c.Assert(opts.templateName(), gc.Equals, "crawl-news-2024-template")
+
+	tmpl := s.renderTemplate(c, opts)
+	c.Assert(tmpl["index_patterns"], gc.DeepEquals, []interface{}{"crawl-news-2024"})
+}
+
+func (s *OptionsTestSuite) TestInvalidNamespace(c *gc.C) {
+	_, err := NewElasticSearchIndexer(nil, Options{Namespace: "News"})
+	c.Assert(errors.Is(err, namespace.ErrInvalid), gc.Equals, true)
+}
+
+func (s *OptionsTestSuite) TestInvalidMappings(c *gc.C) {
+	opts := Options{Mappings: `{"properties": `}
+	opts.applyDefaults()
+	_, err := opts.indexTemplate()
+	c.Assert(err, gc.ErrorMatches, "invalid index mappings.*")
+}
+
+// renderTemplate returns the JSON representation of the index template for
+// opts decoded into a generic map.
+func (s *OptionsTestSuite) renderTemplate(c *gc.C, opts Options) map[string]interface{} {
+	tmpl, err := opts.indexTemplate()
+	c.Assert(err, gc.IsNil)
+
+	data, err := json.Marshal(tmpl)
+	c.Assert(err, gc.IsNil)
+
+	var out map[string]interface{}
+	c.Assert(json.Unmarshal(data, &out), gc.IsNil)
+	return out
+}
@@ -0,0 +1,144 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/elastic/go-elasticsearch"
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(PagingTestSuite))
+
+type PagingTestSuite struct {
+	srv      *httptest.Server
+	docs     []esDoc
+	searches []map[string]interface{}
+	scrolls  int
+	idx      *ElasticSearchIndexer
+}
+
+func (s *PagingTestSuite) SetUpTest(c *gc.C) {
+	s.docs, s.searches, s.scrolls = nil, nil, 0
+	for i := 0; i < 50; i++ {
+		s.docs = append(s.docs, esDoc{LinkID: uuid.New().String(), Title: fmt.Sprintf("doc %d", i)})
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{s.srv.URL}})
+	c.Assert(err, gc.IsNil)
+	s.idx = &ElasticSearchIndexer{
+		es:        client,
+		indexName: "textindexer",
+		batchSize: 4,
+		maxOffset: index.DefaultMaxOffset,
+	}
+}
+
+func (s *PagingTestSuite) TearDownTest(c *gc.C) {
+	s.srv.Close()
+}
+
+// serve emulates the search and scroll APIs. Searches return the documents
+// from the requested offset; scrolls return the documents that follow the
+// ones returned by the previous request. Scroll contexts must be kept alive
+// for scrollKeepAlive.
+func (s *PagingTestSuite) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var (
+		from, size int
+		scrollID   string
+	)
+	switch path := r.URL.Path; {
+	case path == "/textindexer/_search":
+		var query map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&query)
+		s.searches = append(s.searches, query)
+		if v, ok := query["from"].(float64); ok {
+			from = int(v)
+		}
+		size = int(query["size"].(float64))
+		if keepAlive := r.URL.Query().Get("scroll"); keepAlive != "" {
+			if keepAlive != scrollKeepAlive.String() {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, `{"error":{"type":"illegal_argument_exception","reason":"unexpected keep-alive"}}`)
+				return
+			}
+			scrollID = fmt.Sprintf("%d:%d", from+size, size)
+		}
+	case strings.HasPrefix(path, "/_search/scroll") && r.Method == http.MethodDelete:
+		// Clear scroll request.
+		_, _ = io.WriteString(w, `{}`)
+		return
+	case strings.HasPrefix(path, "/_search/scroll/"):
+		s.scrolls++
+		params := r.URL.Query()
+		if params.Get("scroll") != scrollKeepAlive.String() {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"type":"illegal_argument_exception","reason":"unexpected keep-alive"}}`)
+			return
+		}
+		_, _ = fmt.Sscanf(params.Get("scroll_id"), "%d:%d", &from, &size)
+		scrollID = fmt.Sprintf("%d:%d", from+size, size)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"type":"not_found","reason":"unexpected request"}}`)
+		return
+	}
+
+	res := esSearchRes{ScrollID: scrollID}
+	res.Hits.Total.Count = uint64(len(s.docs))
+	for i := from; i < from+size && i < len(s.docs); i++ {
+		res.Hits.HitList = append(res.Hits.HitList, esHitWrapper{DocSource: s.docs[i]})
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (s *PagingTestSuite) TestPageOfResults(c *gc.C) {
+	it, err := s.idx.Search(index.Query{Expression: "doc", Offset: 25, Size: 10})
+	c.Assert(err, gc.IsNil)
+	c.Assert(s.titles(c, it), gc.DeepEquals, s.expTitles(25, 35))
+
+	// The offset is applied by the cluster and no scroll context is
+	// opened.
+	c.Assert(s.scrolls, gc.Equals, 0)
+	c.Assert(s.searches, gc.HasLen, 3)
+	for i, search := range s.searches {
+		c.Assert(search["from"], gc.Equals, float64(25+4*i))
+	}
+}
+
+func (s *PagingTestSuite) TestIterateAllResults(c *gc.C) {
+	it, err := s.idx.Search(index.Query{Expression: "doc", Offset: 45})
+	c.Assert(err, gc.IsNil)
+	c.Assert(s.titles(c, it), gc.DeepEquals, s.expTitles(45, 50))
+
+	// Unbounded searches are paged via a scroll context.
+	c.Assert(s.searches, gc.HasLen, 1)
+	c.Assert(s.searches[0]["from"], gc.IsNil)
+	c.Assert(s.scrolls > 0, gc.Equals, true)
+}
+
+func (s *PagingTestSuite) titles(c *gc.C, it index.Iterator) []string {
+	var titles []string
+	for it.Next() {
+		titles = append(titles, it.Document().Title)
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	return titles
+}
+
+func (s *PagingTestSuite) expTitles(from, to int) []string {
+	var titles []string
+	for i := from; i < to; i++ {
+		titles = append(titles, s.docs[i].Title)
+	}
+	return titles
+}
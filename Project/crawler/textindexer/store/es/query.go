@@ -0,0 +1,409 @@
+package es
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+)
+
+// makeEsTextFields returns the boosted document fields that are searched by
+// queries which do not target a specific field. The URL is matched against
+// its analyzed URL.text sub-field and the language-specific text fields
+// (which hold both the title and the content) are searched if the content
+// is, using the content boost.
+func makeEsTextFields(boosts index.FieldBoosts) []string {
+	var fields []string
+	for _, field := range boosts.Fields() {
+		name := field
+		if field == index.FieldURL {
+			name = "URL.text"
+		}
+		fields = append(fields, boostedEsField(name, boosts[field]))
+	}
+	if boost, ok := boosts[index.FieldContent]; ok {
+		for _, lang := range index.SupportedLanguages {
+			fields = append(fields, boostedEsField("LangText."+lang, boost))
+		}
+	}
+	return fields
+}
+
+func boostedEsField(name string, boost float64) string {
+	if boost == 1 {
+		return name
+	}
+	return name + "^" + strconv.FormatFloat(boost, 'g', -1, 64)
+}
+
+// makeEsMultiMatchQuery returns a multi_match query of the specified type
+// that searches the specified text fields for expr.
+func makeEsMultiMatchQuery(qtype, expr string, textFields []string) map[string]interface{} {
+	return map[string]interface{}{
+		"multi_match": map[string]interface{}{
+			"type":   qtype,
+			"query":  expr,
+			"fields": textFields,
+		},
+	}
+}
+
+// makeEsBooleanQuery translates a parsed boolean query into its equivalent
+// elasticsearch query DSL representation. Terms and phrases that do not
+// target a specific field are matched against textFields.
+func makeEsBooleanQuery(node *index.QueryNode, textFields []string) map[string]interface{} {
+	switch node.Type {
+	case index.QueryNodeAnd:
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": makeEsBooleanQueryList(node.Children, textFields),
+			},
+		}
+	case index.QueryNodeOr:
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should":               makeEsBooleanQueryList(node.Children, textFields),
+				"minimum_should_match": 1,
+			},
+		}
+	case index.QueryNodeNot:
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must_not": makeEsBooleanQueryList(node.Children, textFields),
+			},
+		}
+	case index.QueryNodePhrase:
+		if node.Field == "" {
+			return makeEsMultiMatchQuery("phrase", node.Text, textFields)
+		}
+		return makeEsFieldQuery("match_phrase", node.Field, node.Text)
+	default:
+		if node.Field == "" {
+			return makeEsMultiMatchQuery("best_fields", node.Text, textFields)
+		}
+		return makeEsFieldQuery("match", node.Field, node.Text)
+	}
+}
+
+func makeEsBooleanQueryList(nodes []*index.QueryNode, textFields []string) []map[string]interface{} {
+	list := make([]map[string]interface{}, len(nodes))
+	for i, node := range nodes {
+		list[i] = makeEsBooleanQuery(node, textFields)
+	}
+	return list
+}
+
+// makeEsFieldQuery returns a query of the specified type that searches a
+// single document field. As the URL field is mapped as a keyword, queries
+// against it are converted into a wildcard query so that partial URLs (e.g.
+// url:example.com) can be matched. The same applies to header-scoped queries
+// which are matched against the HeaderTerms keyword field. Wildcards in text
+// are escaped so that they are matched literally.
+func makeEsFieldQuery(qtype, field, text string) map[string]interface{} {
+	if name, isHeader := index.HeaderName(field); isHeader {
+		return map[string]interface{}{
+			"wildcard": map[string]interface{}{
+				"HeaderTerms": map[string]interface{}{
+					"value": index.HeaderTermPattern(name, escapeEsWildcard(text)),
+				},
+			},
+		}
+	}
+	if field == index.FieldURL {
+		return map[string]interface{}{
+			"wildcard": map[string]interface{}{
+				field: map[string]interface{}{
+					"value": "*" + escapeEsWildcard(text) + "*",
+				},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		qtype: map[string]interface{}{
+			field: text,
+		},
+	}
+}
+
+// esWildcardEscaper escapes the characters that have a special meaning in the
+// pattern of a wildcard query.
+var esWildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+// escapeEsWildcard returns text with its wildcard characters escaped so that
+// it can be embedded in the pattern of a wildcard query.
+func escapeEsWildcard(text string) string {
+	return esWildcardEscaper.Replace(text)
+}
+
+// esFacetFields maps each facet field to the document field that holds its
+// value.
+var esFacetFields = map[index.FacetField]string{
+	index.FacetHost:        "Host",
+	index.FacetLanguage:    "Language",
+	index.FacetIndexedDate: "IndexedDate",
+	index.FacetVertical:    "Vertical",
+}
+
+// makeEsVerticalFilter returns a filter that matches the documents belonging
+// to the specified search vertical. Documents indexed before verticals were
+// introduced lack the Vertical field and are treated as web-pages.
+func makeEsVerticalFilter(vertical string) map[string]interface{} {
+	term := map[string]interface{}{
+		"term": map[string]interface{}{"Vertical": vertical},
+	}
+	if vertical != index.VerticalWeb {
+		return term
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []map[string]interface{}{
+				term,
+				{
+					"bool": map[string]interface{}{
+						"must_not": map[string]interface{}{
+							"exists": map[string]interface{}{"field": "Vertical"},
+						},
+					},
+				},
+			},
+			"minimum_should_match": 1,
+		},
+	}
+}
+
+// makeEsFilters returns a filter clause for each criterion set in f.
+// Documents match a domain if their host is the domain itself or one of its
+// subdomains.
+func makeEsFilters(f index.Filters) []map[string]interface{} {
+	var filters []map[string]interface{}
+	if len(f.Domains) != 0 {
+		var domainQueries []map[string]interface{}
+		for _, domain := range f.Domains {
+			domainQueries = append(domainQueries,
+				map[string]interface{}{"term": map[string]interface{}{"Host": domain}},
+				map[string]interface{}{"wildcard": map[string]interface{}{"Host": map[string]interface{}{"value": "*." + escapeEsWildcard(domain)}}},
+			)
+		}
+		filters = append(filters, map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should":               domainQueries,
+				"minimum_should_match": 1,
+			},
+		})
+	}
+	if !f.IndexedAfter.IsZero() || !f.IndexedBefore.IsZero() {
+		dateRange := make(map[string]interface{})
+		if !f.IndexedAfter.IsZero() {
+			dateRange["gte"] = f.IndexedAfter.UTC().Format(esDateFormat)
+		}
+		if !f.IndexedBefore.IsZero() {
+			dateRange["lt"] = f.IndexedBefore.UTC().Format(esDateFormat)
+		}
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{"IndexedAt": dateRange},
+		})
+	}
+	if len(f.Languages) != 0 {
+		filters = append(filters, map[string]interface{}{
+			"terms": map[string]interface{}{"Language": f.Languages},
+		})
+	}
+	if f.MinPageRank > 0 {
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{"PageRank": map[string]interface{}{"gte": f.MinPageRank}},
+		})
+	}
+	return filters
+}
+
+// esDateFormat is the layout of the timestamps passed to Elasticsearch
+// queries. Date fields are stored with millisecond precision.
+const esDateFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// makeEsRankingQuery wraps matchQuery in a function_score query that adds the
+// weighted PageRank score and freshness of each matching document to its
+// relevance score. Documents without a PageRank score are treated as having
+// a zero score while documents that lack an indexing timestamp are treated
+// as fully fresh.
+func makeEsRankingQuery(matchQuery map[string]interface{}, r index.Ranking) map[string]interface{} {
+	var functions []map[string]interface{}
+	if r.PageRankWeight != 0 {
+		functions = append(functions, map[string]interface{}{
+			"field_value_factor": map[string]interface{}{
+				"field":   "PageRank",
+				"missing": 0,
+			},
+			"weight": r.PageRankWeight,
+		})
+	}
+	if r.FreshnessWeight != 0 {
+		decay := map[string]interface{}{
+			"scale":  esDuration(max(r.Scale, time.Millisecond)),
+			"offset": esDuration(r.Offset),
+			"decay":  r.DecayRate,
+		}
+		if !r.Origin.IsZero() {
+			decay["origin"] = r.Origin.UTC().Format(esDateFormat)
+		}
+		functions = append(functions, map[string]interface{}{
+			r.Decay.String(): map[string]interface{}{"IndexedAt": decay},
+			"weight":         r.FreshnessWeight,
+		})
+	}
+	if len(functions) == 0 {
+		return matchQuery
+	}
+
+	return map[string]interface{}{
+		"function_score": map[string]interface{}{
+			"query":      matchQuery,
+			"functions":  functions,
+			"score_mode": "sum",
+			"boost_mode": "sum",
+		},
+	}
+}
+
+// esDuration formats d as an Elasticsearch time unit with millisecond
+// precision.
+func esDuration(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+// makeEsAggregations returns a terms aggregation for each facet request.
+func makeEsAggregations(facets []index.FacetRequest) map[string]interface{} {
+	aggs := make(map[string]interface{}, len(facets))
+	for i, facet := range facets {
+		aggs[esAggregationName(i)] = map[string]interface{}{
+			"terms": map[string]interface{}{
+				"field": esFacetFields[facet.Field],
+				"size":  facet.FacetSize(),
+			},
+		}
+	}
+	return aggs
+}
+
+// mapEsAggregations converts the aggregations in a search response into the
+// facets requested by the query.
+func mapEsAggregations(facets []index.FacetRequest, aggs map[string]esAggregation) []index.Facet {
+	if len(facets) == 0 {
+		return nil
+	}
+
+	out := make([]index.Facet, len(facets))
+	for i, facet := range facets {
+		out[i].Field = facet.Field
+		for _, bucket := range aggs[esAggregationName(i)].Buckets {
+			out[i].Buckets = append(out[i].Buckets, index.FacetBucket{Value: bucket.Key, Count: bucket.DocCount})
+		}
+	}
+	return out
+}
+
+func esAggregationName(facetIndex int) string {
+	return fmt.Sprintf("facet_%d", facetIndex)
+}
+
+// esSuggestFields lists the fields that term suggesters are run against. Both
+// fields use the standard analyzer so their terms are not stemmed.
+var esSuggestFields = []string{index.FieldTitle, index.FieldContent}
+
+// completionOverfetch is the factor by which Suggest over-fetches documents to
+// make up for documents with duplicate titles.
+const completionOverfetch = 4
+
+// makeEsCompletionQuery returns a search request for the size highest ranked
+// documents whose titles contain a word prefixed by each of words.
+func makeEsCompletionQuery(words []string, size int) map[string]interface{} {
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"match": map[string]interface{}{
+				"Title.autocomplete": map[string]interface{}{
+					"query":    strings.Join(words, " "),
+					"operator": "and",
+				},
+			},
+		},
+		"sort": []interface{}{
+			map[string]interface{}{"PageRank": "desc"},
+			"_score",
+		},
+		"_source":          []string{"Title"},
+		"size":             size,
+		"track_total_hits": false,
+	}
+}
+
+// makeEsSuggestQuery returns a search request that runs a term suggester over
+// each of the esSuggestFields for the specified terms. Suggesters only
+// propose terms that occur in more documents than the original term.
+func makeEsSuggestQuery(terms []string) map[string]interface{} {
+	suggest := map[string]interface{}{"text": strings.Join(terms, " ")}
+	for _, field := range esSuggestFields {
+		suggest[field] = map[string]interface{}{
+			"term": map[string]interface{}{
+				"field":        field,
+				"suggest_mode": "popular",
+				"sort":         "score",
+				"size":         index.MaxSuggestions,
+			},
+		}
+	}
+	return map[string]interface{}{
+		"size":             0,
+		"track_total_hits": false,
+		"suggest":          suggest,
+	}
+}
+
+// mapEsSuggestions merges the term suggester options for each term and
+// combines them into suggestions for expr.
+func mapEsSuggestions(expr string, suggest map[string][]esSuggestEntry) []index.Suggestion {
+	type candidate struct {
+		index.TermCorrection
+		freq uint64
+	}
+	candidates := make(map[string]map[string]candidate)
+	for _, field := range esSuggestFields {
+		for _, entry := range suggest[field] {
+			if candidates[entry.Text] == nil {
+				candidates[entry.Text] = make(map[string]candidate)
+			}
+			for _, opt := range entry.Options {
+				c := candidates[entry.Text][opt.Text]
+				c.Term = opt.Text
+				c.freq += opt.Freq
+				if opt.Score > c.Score {
+					c.Score = opt.Score
+				}
+				candidates[entry.Text][opt.Text] = c
+			}
+		}
+	}
+
+	corrections := make(map[string][]index.TermCorrection, len(candidates))
+	for term, opts := range candidates {
+		list := make([]candidate, 0, len(opts))
+		for _, c := range opts {
+			list = append(list, c)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			if list[i].freq != list[j].freq {
+				return list[i].freq > list[j].freq
+			}
+			return list[i].Term < list[j].Term
+		})
+		for i := 0; i < len(list) && i < index.MaxSuggestions; i++ {
+			corrections[term] = append(corrections[term], list[i].TermCorrection)
+		}
+	}
+	return index.BuildSuggestions(expr, corrections)
+}
@@ -0,0 +1,156 @@
+package es
+
+import (
+	"encoding/json"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(QueryTestSuite))
+
+type QueryTestSuite struct{}
+
+func (s *QueryTestSuite) TestSuggestions(c *gc.C) {
+	query := makeEsSuggestQuery([]string{"ovidus", "poeta"})
+	c.Assert(query["size"], gc.Equals, 0)
+	suggest := query["suggest"].(map[string]interface{})
+	c.Assert(suggest["text"], gc.Equals, "ovidus poeta")
+	c.Assert(suggest, gc.HasLen, 1+len(esSuggestFields))
+
+	var res esSearchRes
+	c.Assert(json.Unmarshal([]byte(`{
+		"suggest": {
+			"Title": [
+				{"text": "ovidus", "options": [{"text": "ovidio", "score": 0.8, "freq": 1}]},
+				{"text": "poeta", "options": []}
+			],
+			"Content": [
+				{"text": "ovidus", "options": [
+					{"text": "ovidius", "score": 0.8, "freq": 3},
+					{"text": "ovidio", "score": 0.75, "freq": 1}
+				]},
+				{"text": "poeta", "options": []}
+			]
+		}
+	}`), &res), gc.IsNil)
+
+	c.Assert(mapEsSuggestions(`Ovidus AND "poeta"`, res.Suggest), gc.DeepEquals, []index.Suggestion{
+		{Expression: `ovidius AND "poeta"`, Score: 0.8},
+		{Expression: `ovidio AND "poeta"`, Score: 0.8},
+	})
+	c.Assert(mapEsSuggestions("poeta", res.Suggest), gc.IsNil)
+}
+
+func (s *QueryTestSuite) TestCompletionQuery(c *gc.C) {
+	query := makeEsCompletionQuery([]string{"ovid", "po"}, 12)
+	c.Assert(query["size"], gc.Equals, 12)
+	c.Assert(query["query"], gc.DeepEquals, map[string]interface{}{
+		"match": map[string]interface{}{
+			"Title.autocomplete": map[string]interface{}{
+				"query":    "ovid po",
+				"operator": "and",
+			},
+		},
+	})
+	c.Assert(query["sort"].([]interface{})[0], gc.DeepEquals, map[string]interface{}{"PageRank": "desc"})
+}
+
+func (s *QueryTestSuite) TestRankingQuery(c *gc.C) {
+	match := map[string]interface{}{"match_all": map[string]interface{}{}}
+	c.Assert(makeEsRankingQuery(match, index.RankingOf(index.Query{})), gc.DeepEquals, map[string]interface{}{
+		"function_score": map[string]interface{}{
+			"query": match,
+			"functions": []map[string]interface{}{
+				{
+					"field_value_factor": map[string]interface{}{"field": "PageRank", "missing": 0},
+					"weight":             1.0,
+				},
+			},
+			"score_mode": "sum",
+			"boost_mode": "sum",
+		},
+	})
+
+	origin := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	query := makeEsRankingQuery(match, index.RankingOf(index.Query{Ranking: &index.Ranking{
+		FreshnessWeight: 2,
+		Decay:           index.DecayExp,
+		Origin:          origin,
+		Offset:          time.Hour,
+		Scale:           24 * time.Hour,
+	}}))
+	c.Assert(query["function_score"].(map[string]interface{})["functions"], gc.DeepEquals, []map[string]interface{}{
+		{
+			"exp": map[string]interface{}{
+				"IndexedAt": map[string]interface{}{
+					"origin": "2024-03-01T12:00:00.000Z",
+					"offset": "3600000ms",
+					"scale":  "86400000ms",
+					"decay":  0.5,
+				},
+			},
+			"weight": 2.0,
+		},
+	})
+
+	// Queries that ignore both PageRank and freshness rank by relevance.
+	c.Assert(makeEsRankingQuery(match, index.Ranking{}), gc.DeepEquals, match)
+}
+
+func (s *QueryTestSuite) TestFilters(c *gc.C) {
+	c.Assert(makeEsFilters(index.Filters{}), gc.IsNil)
+
+	filters := makeEsFilters(index.FiltersOf(index.Query{Filters: index.Filters{
+		Domains:       []string{"Example.com"},
+		IndexedAfter:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		IndexedBefore: time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC),
+		Languages:     []string{"en", "de"},
+		MinPageRank:   0.25,
+	}}))
+	c.Assert(filters, gc.DeepEquals, []map[string]interface{}{
+		{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"term": map[string]interface{}{"Host": "example.com"}},
+					{"wildcard": map[string]interface{}{"Host": map[string]interface{}{"value": "*.example.com"}}},
+				},
+				"minimum_should_match": 1,
+			},
+		},
+		{
+			"range": map[string]interface{}{"IndexedAt": map[string]interface{}{
+				"gte": "2024-03-01T12:00:00.000Z",
+				"lt":  "2024-03-08T12:00:00.000Z",
+			}},
+		},
+		{"terms": map[string]interface{}{"Language": []string{"en", "de"}}},
+		{"range": map[string]interface{}{"PageRank": map[string]interface{}{"gte": 0.25}}},
+	})
+}
+
+func (s *QueryTestSuite) TestFieldQueryEscapesWildcards(c *gc.C) {
+	c.Assert(makeEsFieldQuery("match", index.FieldURL, `*.example.com/a?b\c`), gc.DeepEquals, map[string]interface{}{
+		"wildcard": map[string]interface{}{
+			"URL": map[string]interface{}{"value": `*\*.example.com/a\?b\\c*`},
+		},
+	})
+	c.Assert(makeEsFieldQuery("match", index.HeaderFieldPrefix+"Server", "ngin*"), gc.DeepEquals, map[string]interface{}{
+		"wildcard": map[string]interface{}{
+			"HeaderTerms": map[string]interface{}{"value": `server=*ngin\**`},
+		},
+	})
+}
+
+func (s *QueryTestSuite) TestTextFields(c *gc.C) {
+	fields := makeEsTextFields(index.DefaultFieldBoosts)
+	c.Assert(fields[:3], gc.DeepEquals, []string{"Title^3", "URL.text^2", "Content"})
+	c.Assert(fields[3:], gc.HasLen, len(index.SupportedLanguages))
+	c.Assert(fields[3], gc.Equals, "LangText."+index.SupportedLanguages[0])
+
+	// The language-specific fields are only searched along with the
+	// content.
+	c.Assert(makeEsTextFields(index.FieldBoosts{index.FieldTitle: 1.5}), gc.DeepEquals, []string{"Title^1.5"})
+	c.Assert(makeEsTextFields(index.FieldBoosts{index.FieldContent: 2})[1], gc.Equals, "LangText."+index.SupportedLanguages[0]+"^2")
+}
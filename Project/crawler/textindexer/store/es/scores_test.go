@@ -0,0 +1,105 @@
+package es
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/elastic/go-elasticsearch"
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ScoresTestSuite))
+
+type ScoresTestSuite struct {
+	srv     *httptest.Server
+	lines   []map[string]interface{}
+	failIDs map[string]bool
+	idx     *ElasticSearchIndexer
+}
+
+func (s *ScoresTestSuite) SetUpTest(c *gc.C) {
+	s.lines = nil
+	s.failIDs = make(map[string]bool)
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{s.srv.URL}})
+	c.Assert(err, gc.IsNil)
+	s.idx = &ElasticSearchIndexer{
+		es:             client,
+		indexName:      "textindexer",
+		bulkRefreshOpt: client.Bulk.WithRefresh("false"),
+	}
+}
+
+func (s *ScoresTestSuite) TearDownTest(c *gc.C) {
+	s.srv.Close()
+}
+
+// serve emulates the bulk API. Updates of the documents in failIDs are
+// rejected.
+func (s *ScoresTestSuite) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method+" "+r.URL.Path != "POST /_bulk" {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"type":"not_found","reason":"unexpected request"}}`)
+		return
+	}
+
+	var (
+		items   []interface{}
+		hasErrs bool
+	)
+	for scanner := bufio.NewScanner(r.Body); scanner.Scan(); {
+		var line map[string]interface{}
+		_ = json.Unmarshal(scanner.Bytes(), &line)
+		s.lines = append(s.lines, line)
+
+		action, ok := line["update"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id := action["_id"].(string)
+		result := map[string]interface{}{"_id": id, "status": http.StatusOK}
+		if s.failIDs[id] {
+			hasErrs = true
+			result["status"] = http.StatusTooManyRequests
+			result["error"] = map[string]interface{}{"type": "es_rejected_execution_exception", "reason": "rejected"}
+		}
+		items = append(items, map[string]interface{}{"update": result})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": hasErrs, "items": items})
+}
+
+func (s *ScoresTestSuite) TestUpdateScores(c *gc.C) {
+	id := uuid.New()
+	c.Assert(s.idx.UpdateScores(map[uuid.UUID]float64{id: 0.5}), gc.IsNil)
+	c.Assert(s.lines, gc.DeepEquals, []map[string]interface{}{
+		{"update": map[string]interface{}{"_index": "textindexer", "_id": id.String()}},
+		{"doc": map[string]interface{}{"LinkID": id.String(), "PageRank": 0.5}, "doc_as_upsert": true},
+	})
+
+	// Empty updates do not issue any requests.
+	s.lines = nil
+	c.Assert(s.idx.UpdateScores(nil), gc.IsNil)
+	c.Assert(s.lines, gc.HasLen, 0)
+}
+
+func (s *ScoresTestSuite) TestUpdateScoresPartialFailure(c *gc.C) {
+	okID, failedID := uuid.New(), uuid.New()
+	s.failIDs[failedID.String()] = true
+
+	err := s.idx.UpdateScores(map[uuid.UUID]float64{okID: 0.1, failedID: 0.2})
+	c.Assert(err, gc.ErrorMatches, "update scores: 1 score update\\(s\\) failed")
+	c.Assert(s.lines, gc.HasLen, 4)
+
+	var updateErr *index.ScoreUpdateError
+	c.Assert(errors.As(err, &updateErr), gc.Equals, true)
+	c.Assert(updateErr.Failed, gc.HasLen, 1)
+	c.Assert(updateErr.Failed[failedID], gc.ErrorMatches, "es_rejected_execution_exception: rejected")
+}
@@ -0,0 +1,207 @@
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+	"webcrawler/crawler/textindexer/backup"
+
+	"github.com/elastic/go-elasticsearch"
+)
+
+// The suffix appended to the index name to obtain the name of the scratch
+// index that snapshots are restored to.
+const restoreIndexSuffix = "-restore"
+
+// Compile-time check to ensure SnapshotBackend implements backup.Backend.
+var _ backup.Backend = (*SnapshotBackend)(nil)
+
+// SnapshotBackend is a backup.Backend that stores backups of an index as
+// snapshots in an elasticsearch snapshot repository. The repository must
+// have been registered with the cluster beforehand.
+type SnapshotBackend struct {
+	es         *elasticsearch.Client
+	indexName  string
+	repository string
+}
+
+// SnapshotBackend returns a backup backend that snapshots the index of i to
+// the specified snapshot repository.
+func (i *ElasticSearchIndexer) SnapshotBackend(repository string) *SnapshotBackend {
+	return &SnapshotBackend{es: i.es, indexName: i.indexName, repository: repository}
+}
+
+type esCountRes struct {
+	Count int64 `json:"count"`
+}
+
+type esSnapshot struct {
+	Snapshot          string   `json:"snapshot"`
+	State             string   `json:"state"`
+	Indices           []string `json:"indices"`
+	StartTimeInMillis int64    `json:"start_time_in_millis"`
+	Metadata          struct {
+		Documents int64 `json:"documents"`
+	} `json:"metadata"`
+}
+
+type esSnapshotRes struct {
+	Snapshot esSnapshot `json:"snapshot"`
+}
+
+type esSnapshotListRes struct {
+	Snapshots []esSnapshot `json:"snapshots"`
+}
+
+func (s esSnapshot) info() *backup.Info {
+	return &backup.Info{
+		Name:      s.Snapshot,
+		CreatedAt: time.UnixMilli(s.StartTimeInMillis).UTC(),
+		Documents: s.Metadata.Documents,
+	}
+}
+
+// Create snapshots the current contents of the index under name. The number
+// of documents in the index is recorded in the snapshot metadata.
+func (b *SnapshotBackend) Create(name string) (*backup.Info, error) {
+	req, err := http.NewRequest(http.MethodGet, "/"+b.indexName+"/_count", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot: %w", err)
+	}
+	var countRes esCountRes
+	if err = performRequest(b.es, req, &countRes); err != nil {
+		return nil, fmt.Errorf("create snapshot: %w", err)
+	}
+
+	body := map[string]interface{}{
+		"indices":              b.indexName,
+		"include_global_state": false,
+		"metadata":             map[string]interface{}{"documents": countRes.Count},
+	}
+	var snapshotRes esSnapshotRes
+	if err = b.perform(http.MethodPut, b.snapshotPath(name)+"?wait_for_completion=true", body, &snapshotRes); err != nil {
+		return nil, fmt.Errorf("create snapshot: %w", err)
+	}
+	if snapshotRes.Snapshot.State != "SUCCESS" {
+		return nil, fmt.Errorf("create snapshot: snapshot %q completed with state %s", name, snapshotRes.Snapshot.State)
+	}
+	return snapshotRes.Snapshot.info(), nil
+}
+
+// List returns the successful snapshots of the index ordered by creation
+// time, oldest first.
+func (b *SnapshotBackend) List() ([]*backup.Info, error) {
+	var listRes esSnapshotListRes
+	if err := b.perform(http.MethodGet, b.snapshotPath("_all"), nil, &listRes); err != nil {
+		return nil, fmt.Errorf("list snapshots: %w", err)
+	}
+
+	sort.SliceStable(listRes.Snapshots, func(i, j int) bool {
+		return listRes.Snapshots[i].StartTimeInMillis < listRes.Snapshots[j].StartTimeInMillis
+	})
+	var infos []*backup.Info
+	for _, snapshot := range listRes.Snapshots {
+		if snapshot.State == "SUCCESS" && containsString(snapshot.Indices, b.indexName) {
+			infos = append(infos, snapshot.info())
+		}
+	}
+	return infos, nil
+}
+
+// Delete removes the snapshot with the specified name.
+func (b *SnapshotBackend) Delete(name string) error {
+	if err := b.perform(http.MethodDelete, b.snapshotPath(name), nil, nil); err != nil {
+		if esErr, valid := err.(esError); valid && esErr.Type == "snapshot_missing_exception" {
+			return fmt.Errorf("delete snapshot %q: %w", name, backup.ErrNotFound)
+		}
+		return fmt.Errorf("delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore restores the snapshot with the specified name to a scratch index
+// next to the live index. Closing the returned index deletes the scratch
+// index.
+func (b *SnapshotBackend) Restore(name string) (backup.RestoredIndex, error) {
+	scratch := b.indexName + restoreIndexSuffix
+
+	// Drop any scratch index left behind by an interrupted verification.
+	if err := deleteIndex(b.es, scratch); err != nil {
+		return nil, fmt.Errorf("restore snapshot: %w", err)
+	}
+
+	body := map[string]interface{}{
+		"indices":              b.indexName,
+		"include_global_state": false,
+		"include_aliases":      false,
+		"rename_pattern":       ".+",
+		"rename_replacement":   scratch,
+	}
+	if err := b.perform(http.MethodPost, b.snapshotPath(name)+"/_restore?wait_for_completion=true", body, nil); err != nil {
+		if esErr, valid := err.(esError); valid && esErr.Type == "snapshot_missing_exception" {
+			return nil, fmt.Errorf("restore snapshot %q: %w", name, backup.ErrNotFound)
+		}
+		return nil, fmt.Errorf("restore snapshot: %w", err)
+	}
+
+	return &restoredIndex{ElasticSearchIndexer: &ElasticSearchIndexer{es: b.es, indexName: scratch}}, nil
+}
+
+// restoredIndex is a scratch index that holds a restored snapshot.
+type restoredIndex struct {
+	*ElasticSearchIndexer
+}
+
+// Close deletes the scratch index.
+func (r *restoredIndex) Close() error {
+	return deleteIndex(r.es, r.indexName)
+}
+
+func (b *SnapshotBackend) snapshotPath(name string) string {
+	return "/_snapshot/" + url.PathEscape(b.repository) + "/" + url.PathEscape(name)
+}
+
+// perform sends a raw request with an optional JSON body to the cluster and
+// decodes the response into to.
+func (b *SnapshotBackend) perform(method, path string, body, to interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, path, &buf)
+	if err != nil {
+		return err
+	}
+	return performRequest(b.es, req, to)
+}
+
+// deleteIndex deletes the specified index. A missing index is not considered
+// to be an error.
+func deleteIndex(es *elasticsearch.Client, indexName string) error {
+	res, err := es.Indices.Delete([]string{indexName})
+	if err != nil {
+		return fmt.Errorf("delete index: %w", err)
+	}
+	if err = unmarshalResponse(res, new(map[string]interface{})); err != nil {
+		if esErr, valid := err.(esError); valid && esErr.Type == "index_not_found_exception" {
+			return nil
+		}
+		return fmt.Errorf("delete index: %w", err)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
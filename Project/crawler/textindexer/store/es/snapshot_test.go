@@ -0,0 +1,120 @@
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+	"webcrawler/crawler/textindexer/backup"
+
+	"github.com/elastic/go-elasticsearch"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(SnapshotTestSuite))
+
+type SnapshotTestSuite struct {
+	srv      *httptest.Server
+	requests []string
+	bodies   map[string]map[string]interface{}
+	backend  *SnapshotBackend
+}
+
+func (s *SnapshotTestSuite) SetUpTest(c *gc.C) {
+	s.requests = nil
+	s.bodies = make(map[string]map[string]interface{})
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{s.srv.URL}})
+	c.Assert(err, gc.IsNil)
+	idx := &ElasticSearchIndexer{es: client, indexName: "textindexer"}
+	s.backend = idx.SnapshotBackend("backups")
+}
+
+func (s *SnapshotTestSuite) TearDownTest(c *gc.C) {
+	s.srv.Close()
+}
+
+// serve emulates the subset of the elasticsearch API used by the snapshot
+// backend.
+func (s *SnapshotTestSuite) serve(w http.ResponseWriter, r *http.Request) {
+	req := r.Method + " " + r.URL.Path
+	s.requests = append(s.requests, req)
+	if data, _ := io.ReadAll(r.Body); len(data) != 0 {
+		var body map[string]interface{}
+		_ = json.Unmarshal(data, &body)
+		s.bodies[req] = body
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch req {
+	case "GET /textindexer/_count":
+		_, _ = io.WriteString(w, `{"count":42}`)
+	case "PUT /_snapshot/backups/backup-1":
+		_, _ = io.WriteString(w, `{"snapshot":{"snapshot":"backup-1","state":"SUCCESS","indices":["textindexer"],"start_time_in_millis":1709294400000,"metadata":{"documents":42}}}`)
+	case "GET /_snapshot/backups/_all":
+		_, _ = io.WriteString(w, `{"snapshots":[
+			{"snapshot":"backup-2","state":"SUCCESS","indices":["textindexer"],"start_time_in_millis":1709380800000,"metadata":{"documents":43}},
+			{"snapshot":"backup-1","state":"SUCCESS","indices":["textindexer"],"start_time_in_millis":1709294400000,"metadata":{"documents":42}},
+			{"snapshot":"failed","state":"FAILED","indices":["textindexer"],"start_time_in_millis":1709300000000},
+			{"snapshot":"other","state":"SUCCESS","indices":["other-index"],"start_time_in_millis":1709300000000}
+		]}`)
+	case "DELETE /textindexer-restore":
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"type":"index_not_found_exception","reason":"no such index"}}`)
+	case "POST /_snapshot/backups/backup-1/_restore":
+		_, _ = io.WriteString(w, `{"snapshot":{"snapshot":"backup-1"}}`)
+	case "DELETE /_snapshot/backups/missing":
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"type":"snapshot_missing_exception","reason":"missing"}}`)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"type":"unexpected_request","reason":"unexpected request"}}`)
+	}
+}
+
+func (s *SnapshotTestSuite) TestCreate(c *gc.C) {
+	info, err := s.backend.Create("backup-1")
+	c.Assert(err, gc.IsNil)
+	c.Assert(info, gc.DeepEquals, &backup.Info{
+		Name:      "backup-1",
+		CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Documents: 42,
+	})
+
+	body := s.bodies["PUT /_snapshot/backups/backup-1"]
+	c.Assert(body["indices"], gc.Equals, "textindexer")
+	c.Assert(body["include_global_state"], gc.Equals, false)
+	c.Assert(body["metadata"], gc.DeepEquals, map[string]interface{}{"documents": float64(42)})
+}
+
+func (s *SnapshotTestSuite) TestList(c *gc.C) {
+	infos, err := s.backend.List()
+	c.Assert(err, gc.IsNil)
+	c.Assert(infos, gc.HasLen, 2)
+	c.Assert(infos[0].Name, gc.Equals, "backup-1")
+	c.Assert(infos[1].Name, gc.Equals, "backup-2")
+	c.Assert(infos[1].Documents, gc.Equals, int64(43))
+}
+
+func (s *SnapshotTestSuite) TestRestoreToScratchIndex(c *gc.C) {
+	restored, err := s.backend.Restore("backup-1")
+	c.Assert(err, gc.IsNil)
+	c.Assert(restored.Close(), gc.IsNil)
+
+	c.Assert(s.requests, gc.DeepEquals, []string{
+		"DELETE /textindexer-restore",
+		"POST /_snapshot/backups/backup-1/_restore",
+		"DELETE /textindexer-restore",
+	})
+	body := s.bodies["POST /_snapshot/backups/backup-1/_restore"]
+	c.Assert(body["rename_replacement"], gc.Equals, "textindexer-restore")
+	c.Assert(body["indices"], gc.Equals, "textindexer")
+}
+
+func (s *SnapshotTestSuite) TestDeleteMissingSnapshot(c *gc.C) {
+	err := s.backend.Delete("missing")
+	c.Assert(errors.Is(err, backup.ErrNotFound), gc.Equals, true)
+}
@@ -0,0 +1,145 @@
+package es
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+	"webcrawler/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("textindexer.es")
+
+// newTransport returns the HTTP transport for talking to the ES cluster
+// configured with the authentication and TLS settings from opts. It returns
+// nil if opts do not require a custom transport.
+func newTransport(opts Options) (http.RoundTripper, error) {
+	if opts.Username == "" && opts.APIKey == "" && len(opts.CACert) == 0 && !opts.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	if opts.APIKey != "" && opts.Username != "" {
+		return nil, fmt.Errorf("API key and username/password authentication are mutually exclusive")
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if len(opts.CACert) != 0 || opts.InsecureSkipVerify {
+		tlsCfg := &tls.Config{
+			// Skipping verification is only meant to be used for
+			// development clusters with self-signed certificates.
+			InsecureSkipVerify: opts.InsecureSkipVerify,
+		}
+		if len(opts.CACert) != 0 {
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(opts.CACert) {
+				return nil, fmt.Errorf("CA certificate does not contain any valid PEM-encoded certificates")
+			}
+			tlsCfg.RootCAs = pool
+		}
+		base.TLSClientConfig = tlsCfg
+	}
+
+	var authHeader string
+	switch {
+	case opts.APIKey != "":
+		authHeader = "ApiKey " + opts.APIKey
+	case opts.Username != "":
+		authHeader = "Basic " + base64.StdEncoding.EncodeToString([]byte(opts.Username+":"+opts.Password))
+	default:
+		return base, nil
+	}
+
+	return &authTransport{base: base, authHeader: authHeader}, nil
+}
+
+// authTransport is an http.RoundTripper that adds an Authorization header to
+// each outgoing request.
+type authTransport struct {
+	base       http.RoundTripper
+	authHeader string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the original request.
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", t.authHeader)
+	return t.base.RoundTrip(req)
+}
+
+// metricsTransport is an http.RoundTripper that records the latency of each
+// request to the ES cluster, traces it as a child of the span in the request
+// context and logs the requests that fail due to network or server errors.
+type metricsTransport struct {
+	base   http.RoundTripper
+	logger *slog.Logger
+}
+
+// newMetricsTransport wraps base with a metricsTransport. If base is nil,
+// http.DefaultTransport is used instead.
+func newMetricsTransport(base http.RoundTripper, logger *slog.Logger) *metricsTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &metricsTransport{base: base, logger: logging.Component(logger, "textindexer.es")}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	op, startedAt := esOperation(req.URL.Path), time.Now()
+	defer metrics.ObserveSince(metrics.ESRequestDuration.WithLabelValues(op), startedAt)
+
+	ctx, span := tracer.Start(req.Context(), "es."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.path", req.URL.Path),
+		),
+	)
+	// RoundTrippers must not modify the original request.
+	req = req.Clone(ctx)
+	tracing.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := t.base.RoundTrip(req)
+	if res != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	}
+	if err == nil && res.StatusCode >= http.StatusInternalServerError {
+		tracing.End(span, fmt.Errorf("unexpected status code: %d", res.StatusCode))
+	} else {
+		tracing.End(span, err)
+	}
+
+	switch {
+	case err != nil:
+		t.logger.Warn("ES request failed", "operation", op, "method", req.Method, "path", req.URL.Path, "err", err)
+	case res.StatusCode >= http.StatusInternalServerError:
+		t.logger.Warn("ES request failed", "operation", op, "method", req.Method, "path", req.URL.Path, "status", res.StatusCode)
+	}
+	return res, err
+}
+
+// esOperation returns the name of the ES API invoked by a request to path;
+// e.g. "bulk" for "/_bulk" or "search" for "/index/_search". Requests to
+// index-level APIs (e.g. creating an index) are reported as "index".
+func esOperation(path string) string {
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "_") && len(segment) > 1 {
+			return segment[1:]
+		}
+	}
+	return "index"
+}
@@ -0,0 +1,158 @@
+package es
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(TransportTestSuite))
+
+type TransportTestSuite struct{}
+
+func (s *TransportTestSuite) TestDefaultTransport(c *gc.C) {
+	transport, err := newTransport(Options{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(transport, gc.IsNil)
+}
+
+func (s *TransportTestSuite) TestAuthHeaders(c *gc.C) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	specs := []struct {
+		opts    Options
+		expAuth string
+	}{
+		{
+			opts:    Options{Username: "elastic", Password: "secret"},
+			expAuth: "Basic " + base64.StdEncoding.EncodeToString([]byte("elastic:secret")),
+		},
+		{
+			opts:    Options{APIKey: "aWQ6a2V5"},
+			expAuth: "ApiKey aWQ6a2V5",
+		},
+	}
+
+	for _, spec := range specs {
+		transport, err := newTransport(spec.opts)
+		c.Assert(err, gc.IsNil)
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		c.Assert(err, gc.IsNil)
+		res, err := transport.RoundTrip(req)
+		c.Assert(err, gc.IsNil)
+		_ = res.Body.Close()
+
+		c.Assert(gotAuth, gc.Equals, spec.expAuth)
+		c.Assert(req.Header.Get("Authorization"), gc.Equals, "", gc.Commentf("original request should not be modified"))
+	}
+}
+
+func (s *TransportTestSuite) TestConflictingAuthOptions(c *gc.C) {
+	_, err := newTransport(Options{Username: "elastic", APIKey: "aWQ6a2V5"})
+	c.Assert(err, gc.ErrorMatches, ".*mutually exclusive.*")
+}
+
+func (s *TransportTestSuite) TestTLSVerification(c *gc.C) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	specs := []struct {
+		opts   Options
+		expErr bool
+	}{
+		{opts: Options{Username: "elastic"}, expErr: true},
+		{opts: Options{CACert: caCert}},
+		{opts: Options{InsecureSkipVerify: true}},
+	}
+
+	for i, spec := range specs {
+		transport, err := newTransport(spec.opts)
+		c.Assert(err, gc.IsNil)
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		c.Assert(err, gc.IsNil)
+		res, err := transport.RoundTrip(req)
+		if spec.expErr {
+			c.Assert(err, gc.Not(gc.IsNil), gc.Commentf("spec %d", i))
+			continue
+		}
+		c.Assert(err, gc.IsNil, gc.Commentf("spec %d", i))
+		_ = res.Body.Close()
+	}
+}
+
+func (s *TransportTestSuite) TestInvalidCACert(c *gc.C) {
+	_, err := newTransport(Options{CACert: []byte("not a certificate")})
+	c.Assert(err, gc.ErrorMatches, ".*valid PEM-encoded certificates.*")
+}
+
+func (s *TransportTestSuite) TestESOperation(c *gc.C) {
+	specs := map[string]string{
+		"/_bulk":                 "bulk",
+		"/textindexer/_search":   "search",
+		"/textindexer/_doc/123":  "doc",
+		"/textindexer/_update/1": "update",
+		"/_pit":                  "pit",
+		"/textindexer":           "index",
+		"/":                      "index",
+	}
+	for path, exp := range specs {
+		c.Assert(esOperation(path), gc.Equals, exp, gc.Commentf("path %q", path))
+	}
+}
+
+func (s *TransportTestSuite) TestMetricsTransport(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	obs := metrics.ESRequestDuration.WithLabelValues("bulk").(prometheus.Histogram)
+	before := histogramCount(c, obs)
+
+	res, err := (&http.Client{Transport: newMetricsTransport(nil, nil)}).Post(srv.URL+"/_bulk", "application/json", nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Body.Close(), gc.IsNil)
+	c.Assert(histogramCount(c, obs), gc.Equals, before+1)
+}
+
+func (s *TransportTestSuite) TestMetricsTransportLogsServerErrors(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger, err := logging.New(logging.Config{Output: &logs})
+	c.Assert(err, gc.IsNil)
+
+	res, err := (&http.Client{Transport: newMetricsTransport(nil, logger)}).Get(srv.URL + "/textindexer/_search")
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Body.Close(), gc.IsNil)
+
+	var rec map[string]interface{}
+	c.Assert(json.Unmarshal(logs.Bytes(), &rec), gc.IsNil)
+	c.Assert(rec["level"], gc.Equals, "WARN")
+	c.Assert(rec["component"], gc.Equals, "textindexer.es")
+	c.Assert(rec["operation"], gc.Equals, "search")
+	c.Assert(rec["status"], gc.Equals, float64(http.StatusServiceUnavailable))
+}
+
+func histogramCount(c *gc.C, h prometheus.Histogram) uint64 {
+	var m dto.Metric
+	c.Assert(h.Write(&m), gc.IsNil)
+	return m.GetHistogram().GetSampleCount()
+}
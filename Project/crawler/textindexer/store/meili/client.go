@@ -0,0 +1,154 @@
+package meili
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client is a minimal client for the subset of the Meilisearch HTTP API that
+// is used by the indexer.
+type client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+
+	// Settings for waiting on asynchronous tasks.
+	taskPollInterval time.Duration
+	taskTimeout      time.Duration
+}
+
+// meiliError is returned by the Meilisearch API for failed requests and
+// tasks.
+type meiliError struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+	Type    string `json:"type"`
+}
+
+func (e meiliError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// errorCode returns the Meilisearch error code for err or an empty string if
+// err was not returned by the Meilisearch API.
+func errorCode(err error) string {
+	if mErr, valid := err.(meiliError); valid {
+		return mErr.Code
+	}
+	return ""
+}
+
+// do sends a request with an optional JSON-encoded body to the API endpoint
+// at path and decodes the response into to. The response is discarded if to
+// is nil.
+func (c *client) do(method, path string, body, to interface{}) error {
+	return c.doContext(context.Background(), method, path, body, to)
+}
+
+// doContext behaves like do but aborts the request when ctx is cancelled.
+func (c *client) doContext(ctx context.Context, method, path string, body, to interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+		reqBody = &buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var mErr meiliError
+		if err = json.NewDecoder(res.Body).Decode(&mErr); err != nil || mErr.Code == "" {
+			return fmt.Errorf("unexpected response status %d", res.StatusCode)
+		}
+		return mErr
+	}
+	if to == nil {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(to)
+}
+
+// taskRes is returned by the endpoints that enqueue an asynchronous task.
+type taskRes struct {
+	TaskUID int64 `json:"taskUid"`
+}
+
+// task describes the status of an asynchronous task.
+type task struct {
+	Status string      `json:"status"`
+	Error  *meiliError `json:"error"`
+}
+
+// enqueue sends a request that enqueues an asynchronous task and returns the
+// task UID. If wait is set, enqueue blocks until the task has been processed
+// and returns its error if it failed.
+func (c *client) enqueue(method, path string, body interface{}, wait bool) (int64, error) {
+	var res taskRes
+	if err := c.do(method, path, body, &res); err != nil {
+		return 0, err
+	}
+	if !wait {
+		return res.TaskUID, nil
+	}
+	return res.TaskUID, c.waitForTask(res.TaskUID)
+}
+
+// waitForTask polls the status of the task with the specified UID until it
+// has been processed or the task timeout expires.
+func (c *client) waitForTask(uid int64) error {
+	deadline := time.Now().Add(c.taskTimeout)
+	for {
+		var t task
+		if err := c.do(http.MethodGet, fmt.Sprintf("/tasks/%d", uid), nil, &t); err != nil {
+			return fmt.Errorf("wait for task %d: %w", uid, err)
+		}
+
+		switch t.Status {
+		case "succeeded":
+			return nil
+		case "failed":
+			if t.Error != nil {
+				return *t.Error
+			}
+			return fmt.Errorf("task %d failed", uid)
+		case "canceled":
+			return fmt.Errorf("task %d was canceled", uid)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("wait for task %d: timed out after %s", uid, c.taskTimeout)
+		}
+		time.Sleep(c.taskPollInterval)
+	}
+}
+
+// indexPath returns the path of an endpoint of the index with the specified
+// UID.
+func indexPath(uid string, parts ...string) string {
+	return "/indexes/" + uid + strings.Join(append([]string{""}, parts...), "/")
+}
@@ -0,0 +1,139 @@
+package meili
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ClientTestSuite))
+
+type ClientTestSuite struct {
+	srv      *httptest.Server
+	requests []string
+	bodies   map[string]interface{}
+	auth     []string
+}
+
+func (s *ClientTestSuite) SetUpTest(c *gc.C) {
+	s.requests, s.auth = nil, nil
+	s.bodies = make(map[string]interface{})
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+}
+
+func (s *ClientTestSuite) TearDownTest(c *gc.C) {
+	s.srv.Close()
+}
+
+// serve emulates the endpoints used for setting up an index. Creating the
+// index fails as it already exists.
+func (s *ClientTestSuite) serve(w http.ResponseWriter, r *http.Request) {
+	req := r.Method + " " + r.URL.Path
+	s.requests = append(s.requests, req)
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+	if data, _ := io.ReadAll(r.Body); len(data) != 0 {
+		var body interface{}
+		_ = json.Unmarshal(data, &body)
+		s.bodies[req] = body
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch req {
+	case "GET /version":
+		_, _ = io.WriteString(w, `{"pkgVersion":"1.6.2"}`)
+	case "GET /indexes/textindexer-crawl-1":
+		_, _ = io.WriteString(w, `{"uid":"textindexer-crawl-1","primaryKey":"LinkID"}`)
+	case "POST /indexes":
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, `{"taskUid":1}`)
+	case "PATCH /indexes/textindexer-crawl-1/settings":
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, `{"taskUid":2}`)
+	case "GET /tasks/1":
+		_, _ = io.WriteString(w, `{"status":"failed","error":{"message":"Index already exists.","code":"index_already_exists","type":"invalid_request"}}`)
+	case "GET /tasks/2":
+		_, _ = io.WriteString(w, `{"status":"succeeded"}`)
+	case "POST /indexes/textindexer-crawl-1/search":
+		_, _ = io.WriteString(w, `{"hits":[{"Title":"Ovidius poeta"},{"Title":""},{"Title":"Ovidius poeta"},{"Title":"Ovidius Naso"}]}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"message":"Document not found.","code":"document_not_found","type":"invalid_request"}`)
+	}
+}
+
+func (s *ClientTestSuite) TestSetUpIndex(c *gc.C) {
+	idx, err := NewMeilisearchIndexer(s.srv.URL+"/", Options{Namespace: "crawl-1", APIKey: "secret"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(idx.indexUID, gc.Equals, "textindexer-crawl-1")
+
+	c.Assert(s.requests, gc.DeepEquals, []string{
+		"GET /version",
+		"POST /indexes",
+		"GET /tasks/1",
+		"PATCH /indexes/textindexer-crawl-1/settings",
+		"GET /tasks/2",
+	})
+	for _, auth := range s.auth {
+		c.Assert(auth, gc.Equals, "Bearer secret")
+	}
+	c.Assert(s.bodies["POST /indexes"], gc.DeepEquals, map[string]interface{}{"uid": "textindexer-crawl-1", "primaryKey": "LinkID"})
+
+	settings := s.bodies["PATCH /indexes/textindexer-crawl-1/settings"].(map[string]interface{})
+	c.Assert(settings["sortableAttributes"], gc.DeepEquals, []interface{}{"PageRank", "LinkKey", "LinkID"})
+	c.Assert(settings["rankingRules"].([]interface{})[len(rankingRules)-1], gc.Equals, "PageRank:desc")
+
+	_, err = idx.FindByID(uuid.New())
+	c.Assert(errors.Is(err, index.ErrNotFound), gc.Equals, true)
+
+	// No writes have been issued so there is nothing to flush.
+	c.Assert(idx.Flush(), gc.IsNil)
+}
+
+func (s *ClientTestSuite) TestSetUpIndexFailure(c *gc.C) {
+	_, err := NewMeilisearchIndexer(s.srv.URL, Options{Namespace: "crawl 1"})
+	c.Assert(err, gc.ErrorMatches, "meili indexer: .*unsupported character.*")
+
+	_, err = NewMeilisearchIndexer(s.srv.URL, Options{IndexName: "other"})
+	c.Assert(err, gc.ErrorMatches, "meili indexer: cannot apply index settings: document_not_found: Document not found.")
+}
+
+func (s *ClientTestSuite) TestPing(c *gc.C) {
+	idx, err := NewMeilisearchIndexer(s.srv.URL, Options{Namespace: "crawl-1"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(idx.Ping(context.Background()), gc.IsNil)
+
+	idx.indexUID = "missing"
+	c.Assert(idx.Ping(context.Background()), gc.ErrorMatches, "ping: document_not_found: .*")
+
+	s.srv.Close()
+	c.Assert(idx.Ping(context.Background()), gc.ErrorMatches, "ping: .*connection refused.*")
+}
+
+func (s *ClientTestSuite) TestSuggest(c *gc.C) {
+	idx, err := NewMeilisearchIndexer(s.srv.URL, Options{Namespace: "crawl-1"})
+	c.Assert(err, gc.IsNil)
+
+	titles, err := idx.Suggest("Ovidius P", 5)
+	c.Assert(err, gc.IsNil)
+	c.Assert(titles, gc.DeepEquals, []string{"Ovidius poeta", "Ovidius Naso"})
+
+	req := s.bodies["POST /indexes/textindexer-crawl-1/search"].(map[string]interface{})
+	c.Assert(req["q"], gc.Equals, "ovidius p")
+	c.Assert(req["limit"], gc.Equals, float64(5*completionOverfetch))
+	c.Assert(req["attributesToSearchOn"], gc.DeepEquals, []interface{}{"Title"})
+
+	titles, err = idx.Suggest("Ovidius", 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(titles, gc.DeepEquals, []string{"Ovidius poeta"})
+
+	titles, err = idx.Suggest(" -- ", 5)
+	c.Assert(err, gc.IsNil)
+	c.Assert(titles, gc.IsNil)
+}
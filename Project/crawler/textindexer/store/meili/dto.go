@@ -0,0 +1,200 @@
+package meili
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+)
+
+// The default UID of the Meilisearch index to use.
+const defaultIndexName = "textindexer"
+
+// completionOverfetch is the factor by which Suggest over-fetches documents to
+// make up for documents with duplicate titles.
+const completionOverfetch = 4
+
+// rankingRules defines how Meilisearch orders search results. The built-in
+// relevancy rules come first so that text relevance dominates; documents of
+// equal relevance (and all documents returned by filter-only searches) are
+// then ordered by their PageRank score.
+var rankingRules = []string{
+	"words",
+	"typo",
+	"proximity",
+	"attribute",
+	"sort",
+	"exactness",
+	"PageRank:desc",
+}
+
+// indexSettings returns the settings that are applied to the index when the
+// indexer is created.
+func indexSettings(opts Options) map[string]interface{} {
+	return map[string]interface{}{
+		"searchableAttributes": []string{"Title", "Content"},
+		"displayedAttributes":  []string{"*"},
+		"filterableAttributes": []string{"Terms", "Host", "Language", "IndexedDate", "Vertical", "LinkKey", "Domains", "IndexedTime", "PageRank"},
+		"sortableAttributes":   []string{"PageRank", "LinkKey", "LinkID"},
+		"rankingRules":         rankingRules,
+		"typoTolerance": map[string]interface{}{
+			"enabled": true,
+			"minWordSizeForTypos": map[string]interface{}{
+				"oneTypo":  opts.MinWordSizeForOneTypo,
+				"twoTypos": opts.MinWordSizeForTwoTypos,
+			},
+		},
+		"pagination": map[string]interface{}{
+			"maxTotalHits": opts.MaxTotalHits,
+		},
+		"faceting": map[string]interface{}{
+			"maxValuesPerFacet": maxFacetValues,
+			"sortFacetValuesBy": map[string]string{"*": "count"},
+		},
+	}
+}
+
+// The maximum number of values per facet returned by Meilisearch. Facet
+// requests for more buckets are capped to this value.
+const maxFacetValues = 100
+
+type meiliDoc struct {
+	LinkID    string    `json:"LinkID"`
+	URL       string    `json:"URL"`
+	Title     string    `json:"Title"`
+	Content   string    `json:"Content"`
+	Summary   string    `json:"Summary"`
+	Language  string    `json:"Language,omitempty"`
+	IndexedAt time.Time `json:"IndexedAt"`
+	PageRank  float64   `json:"PageRank,omitempty"`
+
+	// The first 48 bits of the link ID as an integer. Meilisearch only
+	// supports range filters on numbers so this key is used for resuming
+	// iteration in link ID order.
+	LinkKey int64 `json:"LinkKey"`
+
+	// The extracted author and publication date. Both are always sent so
+	// that re-indexing a document clears stale values; unknown dates are
+	// stored as null.
+	Author      string     `json:"Author"`
+	PublishedAt *time.Time `json:"PublishedAt"`
+
+	// Blob store references. These are omitted when empty so that a
+	// re-index with a failed capture does not clear existing references.
+	FaviconRef   string `json:"FaviconRef,omitempty"`
+	ThumbnailRef string `json:"ThumbnailRef,omitempty"`
+
+	// Derived fields used for computing facets.
+	Host        string `json:"Host,omitempty"`
+	IndexedDate string `json:"IndexedDate,omitempty"`
+	Vertical    string `json:"Vertical,omitempty"`
+
+	// Derived fields used for filtering search results. Meilisearch only
+	// supports range filters on numbers so the indexing time is also
+	// stored in milliseconds since the Unix epoch. Domains holds the host
+	// and each of its parent domains.
+	IndexedTime int64    `json:"IndexedTime,omitempty"`
+	Domains     []string `json:"Domains,omitempty"`
+
+	// The namespace of the crawl that indexed the document.
+	Namespace string `json:"Namespace,omitempty"`
+
+	// The captured response headers. Partial document updates replace
+	// top-level fields as a whole so re-indexing a document replaces the
+	// previously captured set.
+	Headers map[string]string `json:"Headers"`
+
+	// The content fingerprint as a hex string and the link ID of the
+	// canonical document for duplicates. DuplicateOf is always sent so
+	// that re-indexing a former duplicate clears it.
+	Fingerprint string `json:"Fingerprint,omitempty"`
+	DuplicateOf string `json:"DuplicateOf"`
+
+	// The content hash as a hex string and the time of the last content
+	// change. Both are always sent so that re-indexing a document without
+	// them does not leave stale values behind.
+	ContentHash string     `json:"ContentHash"`
+	ChangedAt   *time.Time `json:"ChangedAt"`
+
+	// The lower-case terms that the boolean query operators which cannot
+	// be expressed as a Meilisearch query are matched against (see
+	// makeTerms).
+	Terms []string `json:"Terms"`
+}
+
+// scoreDoc is the partial document sent by UpdateScore.
+type scoreDoc struct {
+	LinkID   string  `json:"LinkID"`
+	LinkKey  int64   `json:"LinkKey"`
+	PageRank float64 `json:"PageRank"`
+}
+
+// searchReq is the body of a search request. Results are paged either via
+// Offset and Limit or via Page and HitsPerPage; only the latter reports the
+// exact number of matching documents.
+type searchReq struct {
+	Query                string   `json:"q"`
+	Filter               string   `json:"filter,omitempty"`
+	Sort                 []string `json:"sort,omitempty"`
+	Offset               uint64   `json:"offset,omitempty"`
+	Limit                int      `json:"limit,omitempty"`
+	Page                 int      `json:"page,omitempty"`
+	HitsPerPage          int      `json:"hitsPerPage,omitempty"`
+	Facets               []string `json:"facets,omitempty"`
+	MatchingStrategy     string   `json:"matchingStrategy,omitempty"`
+	ShowRankingScore     bool     `json:"showRankingScore,omitempty"`
+	AttributesToRetrieve []string `json:"attributesToRetrieve,omitempty"`
+	AttributesToSearchOn []string `json:"attributesToSearchOn,omitempty"`
+}
+
+type searchRes struct {
+	Hits              []searchHit                  `json:"hits"`
+	TotalHits         uint64                       `json:"totalHits"`
+	TotalPages        int                          `json:"totalPages"`
+	FacetDistribution map[string]map[string]uint64 `json:"facetDistribution"`
+}
+
+// searchHit wraps a returned document together with the ranking score that
+// Meilisearch adds to it.
+type searchHit struct {
+	Doc   meiliDoc
+	Score float64
+}
+
+func (h *searchHit) UnmarshalJSON(data []byte) error {
+	var score struct {
+		RankingScore float64 `json:"_rankingScore"`
+	}
+	if err := json.Unmarshal(data, &score); err != nil {
+		return err
+	}
+	h.Score = score.RankingScore
+	return json.Unmarshal(data, &h.Doc)
+}
+
+// Compile-time checks to ensure MeilisearchIndexer implements Indexer and
+// Pinger.
+var (
+	_ index.Indexer = (*MeilisearchIndexer)(nil)
+	_ index.Pinger  = (*MeilisearchIndexer)(nil)
+)
+
+// MeilisearchIndexer is an Indexer implementation that uses a Meilisearch
+// instance to catalogue and search documents.
+type MeilisearchIndexer struct {
+	c         *client
+	indexUID  string
+	namespace string
+	sync      bool
+
+	// The number of results fetched per search request and the largest
+	// accepted query offset.
+	batchSize int
+	maxOffset uint64
+
+	// The UID of the most recently enqueued write task. Flush waits for it
+	// to be processed.
+	mu          sync.Mutex
+	lastTaskUID int64
+	hasPending  bool
+}
@@ -0,0 +1,236 @@
+package meili
+
+import (
+	"net/http"
+	"strconv"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+)
+
+// searchIterator implements index.Iterator. Results are fetched in pages of
+// a fixed number of documents so that Meilisearch reports the exact number of
+// matching documents.
+type searchIterator struct {
+	c        *client
+	indexUID string
+	req      searchReq
+
+	rsIdx int
+	rs    *searchRes
+
+	maxScore   float64
+	facets     []index.Facet
+	latchedDoc *index.Document
+	lastErr    error
+}
+
+// newSearchIterator runs req and skips the first offset results. Results are
+// fetched in pages of batchSize documents.
+func newSearchIterator(c *client, indexUID string, req searchReq, offset uint64, batchSize int, facets []index.FacetRequest) (*searchIterator, error) {
+	req.HitsPerPage = batchSize
+	req.Page = int(offset/uint64(batchSize)) + 1
+	req.ShowRankingScore = true
+
+	it := &searchIterator{c: c, indexUID: indexUID, req: req}
+	rs, err := it.search(req)
+	if err != nil {
+		return nil, err
+	}
+	it.rs, it.rsIdx = rs, int(offset%uint64(batchSize))
+	it.facets = mapFacets(facets, rs.FacetDistribution)
+
+	// Results are ranked by relevance so the highest score is the score of
+	// the first result.
+	switch {
+	case req.Page == 1 && len(rs.Hits) != 0:
+		it.maxScore = rs.Hits[0].Score
+	case req.Page > 1 && rs.TotalHits != 0:
+		first := req
+		first.Page, first.HitsPerPage, first.Facets = 1, 1, nil
+		first.AttributesToRetrieve = []string{"LinkID"}
+		if rs, err = it.search(first); err != nil {
+			return nil, err
+		} else if len(rs.Hits) != 0 {
+			it.maxScore = rs.Hits[0].Score
+		}
+	}
+	return it, nil
+}
+
+func (it *searchIterator) search(req searchReq) (*searchRes, error) {
+	var rs searchRes
+	if err := it.c.do(http.MethodPost, indexPath(it.indexUID, "search"), req, &rs); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// Close the iterator and release any allocated resources.
+func (it *searchIterator) Close() error {
+	it.c = nil
+	return nil
+}
+
+// Next loads the next document matching the search query.
+// It returns false if no more documents are available.
+func (it *searchIterator) Next() bool {
+	if it.lastErr != nil || it.c == nil {
+		return false
+	}
+
+	// Do we need to fetch the next batch? A short batch means that there
+	// are no more documents.
+	if it.rsIdx >= len(it.rs.Hits) {
+		if len(it.rs.Hits) < it.req.HitsPerPage || it.req.Page >= it.rs.TotalPages {
+			return false
+		}
+		it.req.Page++
+		it.req.Facets = nil
+		rs, err := it.search(it.req)
+		if err != nil {
+			it.lastErr = err
+			return false
+		}
+		it.rs, it.rsIdx = rs, 0
+		if len(rs.Hits) == 0 {
+			return false
+		}
+	}
+
+	it.latchedDoc = mapMeiliDoc(&it.rs.Hits[it.rsIdx].Doc)
+	it.rsIdx++
+	return true
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *searchIterator) Error() error {
+	return it.lastErr
+}
+
+// Document returns the current document from the result set.
+func (it *searchIterator) Document() *index.Document {
+	return it.latchedDoc
+}
+
+// TotalCount returns the number of search results.
+func (it *searchIterator) TotalCount() uint64 {
+	return it.rs.TotalHits
+}
+
+// MaxScore returns the highest ranking score among the search results. Scores
+// are in the [0, 1] range.
+func (it *searchIterator) MaxScore() float64 {
+	return it.maxScore
+}
+
+// Facets returns the aggregations requested by the search query.
+func (it *searchIterator) Facets() []index.Facet {
+	return it.facets
+}
+
+// Suggestions always returns nil as Meilisearch applies typo tolerance to
+// the search terms instead.
+func (it *searchIterator) Suggestions() []index.Suggestion {
+	return nil
+}
+
+// allIterator implements index.DocumentIterator. Documents are fetched in
+// batches sorted by link ID. Each batch is restricted to the documents whose
+// LinkKey is not less than the key of the last returned document; documents
+// sharing that key are skipped up to and including the last returned one.
+// As documents are never re-keyed, concurrent updates do not cause documents
+// to be skipped or returned twice.
+type allIterator struct {
+	c        *client
+	indexUID string
+
+	batchSize   int
+	searchAfter uuid.UUID
+	hasAfter    bool
+
+	rsIdx  int
+	rs     []searchHit
+	isLast bool
+
+	latchedDoc *index.Document
+	lastErr    error
+}
+
+// Close the iterator and release any allocated resources.
+func (it *allIterator) Close() error {
+	it.c = nil
+	it.rs = nil
+	return nil
+}
+
+// Next loads the next document. It returns false if no more documents are
+// available.
+func (it *allIterator) Next() bool {
+	if it.lastErr != nil || it.c == nil {
+		return false
+	}
+
+	if it.rsIdx >= len(it.rs) {
+		if it.isLast || !it.fetchNextBatch() {
+			return false
+		}
+	}
+
+	it.latchedDoc = mapMeiliDoc(&it.rs[it.rsIdx].Doc)
+	it.searchAfter, it.hasAfter = it.latchedDoc.LinkID, true
+	it.rsIdx++
+	return true
+}
+
+// fetchNextBatch retrieves the batch of documents that follows searchAfter.
+func (it *allIterator) fetchNextBatch() bool {
+	req := searchReq{
+		Sort:  []string{"LinkKey:asc", "LinkID:asc"},
+		Limit: it.batchSize,
+	}
+	if it.hasAfter {
+		req.Filter = "LinkKey >= " + strconv.FormatInt(linkKey(it.searchAfter), 10)
+	}
+
+	for {
+		var rs searchRes
+		if err := it.c.do(http.MethodPost, indexPath(it.indexUID, "search"), req, &rs); err != nil {
+			it.lastErr = err
+			return false
+		}
+
+		it.rs, it.rsIdx = it.rs[:0], 0
+		for _, hit := range rs.Hits {
+			if !it.hasAfter || hit.Doc.LinkID > it.searchAfter.String() {
+				it.rs = append(it.rs, hit)
+			}
+		}
+		it.isLast = len(rs.Hits) < it.batchSize
+
+		// A full batch of documents that share the key of the last
+		// returned document needs to be paged through.
+		if len(it.rs) != 0 || it.isLast {
+			return len(it.rs) != 0
+		}
+		req.Offset += uint64(len(rs.Hits))
+	}
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *allIterator) Error() error {
+	return it.lastErr
+}
+
+// Document returns the current document.
+func (it *allIterator) Document() *index.Document {
+	return it.latchedDoc
+}
+
+// Cursor returns a cursor for the current document.
+func (it *allIterator) Cursor() index.Cursor {
+	if it.latchedDoc == nil {
+		return ""
+	}
+	return index.CursorFor(it.latchedDoc.LinkID)
+}
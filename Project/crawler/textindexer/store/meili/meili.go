@@ -0,0 +1,397 @@
+// Package meili provides a text indexer backed by Meilisearch.
+//
+// Match and phrase queries as well as the plain terms and phrases of boolean
+// queries are passed to Meilisearch so that they are matched with typo
+// tolerance. Results are ranked by the Meilisearch relevancy rules followed by
+// the PageRank score of each document which is stored as a sortable
+// attribute. Boolean operators that Meilisearch queries cannot express are
+// evaluated as filters over a list of terms stored with each document; such
+// terms are matched exactly.
+//
+// Unlike the bleve and elasticsearch backends, Meilisearch does not stem
+// words so inflected forms of a term only match within the typo tolerance.
+package meili
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"webcrawler/crawler/iterutil"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+	"webcrawler/namespace"
+
+	"github.com/google/uuid"
+)
+
+// The interval for polling the status of asynchronous tasks.
+const taskPollInterval = 50 * time.Millisecond
+
+// NewMeilisearchIndexer creates a text indexer that uses the Meilisearch
+// instance at baseURL for indexing documents. The index is created if it does
+// not exist and its settings are configured via opts.
+func NewMeilisearchIndexer(baseURL string, opts Options) (*MeilisearchIndexer, error) {
+	if err := namespace.Validate(namespace.OrDefault(opts.Namespace)); err != nil {
+		return nil, fmt.Errorf("meili indexer: %w", err)
+	}
+
+	opts.applyDefaults()
+	if err := index.CheckBatchSize(opts.BatchSize); err != nil {
+		return nil, fmt.Errorf("meili indexer: %w", err)
+	}
+	c := &client{
+		baseURL:          strings.TrimRight(baseURL, "/"),
+		apiKey:           opts.APIKey,
+		http:             opts.HTTPClient,
+		taskPollInterval: taskPollInterval,
+		taskTimeout:      opts.TaskTimeout,
+	}
+
+	var version struct {
+		PkgVersion string `json:"pkgVersion"`
+	}
+	if err := c.do(http.MethodGet, "/version", nil, &version); err != nil {
+		return nil, fmt.Errorf("meili indexer: %w", err)
+	}
+	logging.Component(opts.Logger, "textindexer.meili").Info("connected to instance", "version", version.PkgVersion)
+
+	if err := ensureIndex(c, opts); err != nil {
+		return nil, fmt.Errorf("meili indexer: %w", err)
+	}
+
+	return &MeilisearchIndexer{
+		c:         c,
+		indexUID:  opts.IndexName,
+		namespace: opts.Namespace,
+		sync:      opts.SyncUpdates,
+		batchSize: opts.BatchSize,
+		maxOffset: opts.MaxOffset,
+	}, nil
+}
+
+// ensureIndex creates the configured index if it does not already exist and
+// applies the index settings.
+func ensureIndex(c *client, opts Options) error {
+	createReq := map[string]string{"uid": opts.IndexName, "primaryKey": "LinkID"}
+	if _, err := c.enqueue(http.MethodPost, "/indexes", createReq, true); err != nil && errorCode(err) != "index_already_exists" {
+		return fmt.Errorf("cannot create index: %w", err)
+	}
+
+	if _, err := c.enqueue(http.MethodPatch, indexPath(opts.IndexName, "settings"), indexSettings(opts), true); err != nil {
+		return fmt.Errorf("cannot apply index settings: %w", err)
+	}
+	return nil
+}
+
+// Ping verifies that the Meilisearch instance can be reached and that the
+// index exists and is accessible with the configured API key.
+func (i *MeilisearchIndexer) Ping(ctx context.Context) error {
+	if err := i.c.doContext(ctx, http.MethodGet, indexPath(i.indexUID), nil, nil); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	return nil
+}
+
+// Index inserts a new document to the index or updates the index entry
+// for and existing document.
+func (i *MeilisearchIndexer) Index(doc *index.Document) error {
+	if doc.LinkID == uuid.Nil {
+		return fmt.Errorf("index: %w", index.ErrMissingLinkID)
+	}
+
+	doc.Language = index.DocumentLanguage(doc)
+	doc.Vertical = index.VerticalOf(doc)
+	doc.Namespace = i.namespace
+	if err := i.updateDocument(makeMeiliDoc(doc)); err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	return nil
+}
+
+// FindByID looks up a document by its link ID.
+func (i *MeilisearchIndexer) FindByID(linkID uuid.UUID) (*index.Document, error) {
+	var doc meiliDoc
+	if err := i.c.do(http.MethodGet, indexPath(i.indexUID, "documents", linkID.String()), nil, &doc); err != nil {
+		if errorCode(err) == "document_not_found" {
+			return nil, fmt.Errorf("find by ID: %w", index.ErrNotFound)
+		}
+		return nil, fmt.Errorf("find by ID: %w", err)
+	}
+	return mapMeiliDoc(&doc), nil
+}
+
+// Search the index for a particular query and return back a result
+// iterator.
+func (i *MeilisearchIndexer) Search(q index.Query) (index.Iterator, error) {
+	if err := index.CheckOffset(q, i.maxOffset); err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+
+	mq, err := makeMeiliQuery(q)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+
+	req := searchReq{
+		Query:            mq.query,
+		Filter:           mq.filter,
+		MatchingStrategy: mq.matchingStrategy,
+		Facets:           makeFacets(q.Facets),
+	}
+	it, err := newSearchIterator(i.c, i.indexUID, req, q.Offset, index.BatchSizeOf(q, i.batchSize), q.Facets)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	if q.Size != 0 {
+		return iterutil.LimitDocuments(it, int(q.Size)), nil
+	}
+	return it, nil
+}
+
+// Suggest returns up to limit distinct titles of the documents whose titles
+// match prefix. Meilisearch only treats the last word of a query as a prefix
+// so the preceding words of prefix must match title words in full (subject
+// to typo tolerance). Titles are ordered by relevance and then by PageRank.
+func (i *MeilisearchIndexer) Suggest(prefix string, limit int) ([]string, error) {
+	words := index.CompletionWords(prefix)
+	if len(words) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	// Over-fetch as several documents (e.g. mirrors) may share a title.
+	req := searchReq{
+		Query:                strings.Join(words, " "),
+		Sort:                 []string{"PageRank:desc"},
+		Limit:                limit * completionOverfetch,
+		MatchingStrategy:     "all",
+		AttributesToRetrieve: []string{"Title"},
+		AttributesToSearchOn: []string{"Title"},
+	}
+	var rs searchRes
+	if err := i.c.do(http.MethodPost, indexPath(i.indexUID, "search"), req, &rs); err != nil {
+		return nil, fmt.Errorf("suggest: %w", err)
+	}
+
+	var (
+		titles []string
+		seen   = make(map[string]bool)
+	)
+	for _, hit := range rs.Hits {
+		title := hit.Doc.Title
+		if title == "" || seen[title] {
+			continue
+		}
+		seen[title] = true
+		if titles = append(titles, title); len(titles) == limit {
+			break
+		}
+	}
+	return titles, nil
+}
+
+// UpdateScore updates the PageRank score for a document with the
+// specified link ID. If no such document exists, a placeholder
+// document with the provided score will be created.
+func (i *MeilisearchIndexer) UpdateScore(linkID uuid.UUID, score float64) error {
+	err := i.updateDocument(scoreDoc{
+		LinkID:   linkID.String(),
+		LinkKey:  linkKey(linkID),
+		PageRank: score,
+	})
+	if err != nil {
+		return fmt.Errorf("update score: %w", err)
+	}
+	return nil
+}
+
+// Patch applies a partial update to the indexed document with the specified
+// link ID.
+func (i *MeilisearchIndexer) Patch(linkID uuid.UUID, patch index.DocumentPatch) error {
+	doc, err := i.FindByID(linkID)
+	if err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+	patch.Apply(doc)
+
+	// The terms used for filtering must be kept in sync with the patched
+	// fields.
+	fields := map[string]interface{}{
+		"LinkID": doc.LinkID.String(),
+		"Terms":  makeTerms(doc),
+	}
+	if patch.Title != nil {
+		fields["Title"] = doc.Title
+	}
+	if patch.Content != nil {
+		fields["Content"] = doc.Content
+		fields["Summary"] = doc.Summary
+	}
+	if err = i.updateDocument(fields); err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+	return nil
+}
+
+// All returns an iterator over every indexed document in ascending link ID
+// order, resuming after the document that cursor refers to.
+func (i *MeilisearchIndexer) All(cursor index.Cursor) (index.DocumentIterator, error) {
+	it := &allIterator{c: i.c, indexUID: i.indexUID, batchSize: i.batchSize}
+	if cursor != "" {
+		linkID, err := cursor.LinkID()
+		if err != nil {
+			return nil, fmt.Errorf("all: %w", err)
+		}
+		it.searchAfter, it.hasAfter = linkID, true
+	}
+	return it, nil
+}
+
+// Flush waits until Meilisearch has processed all writes that were issued
+// without waiting for them (see Options.SyncUpdates) so that they become
+// visible to searches before the indexer is shut down.
+func (i *MeilisearchIndexer) Flush() error {
+	i.mu.Lock()
+	uid, pending := i.lastTaskUID, i.hasPending
+	i.mu.Unlock()
+	if !pending {
+		return nil
+	}
+
+	if err := i.c.waitForTask(uid); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	return nil
+}
+
+// updateDocument adds doc to the index or merges its fields into the indexed
+// document with the same link ID.
+func (i *MeilisearchIndexer) updateDocument(doc interface{}) error {
+	uid, err := i.c.enqueue(http.MethodPut, indexPath(i.indexUID, "documents"), []interface{}{doc}, i.sync)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	if !i.hasPending || uid > i.lastTaskUID {
+		i.lastTaskUID, i.hasPending = uid, true
+	}
+	i.mu.Unlock()
+	return nil
+}
+
+// linkKey returns the first 48 bits of linkID as an integer. Keys sort in the
+// same order as the link IDs they are derived from and are exactly
+// representable by the floating point numbers that Meilisearch filters on.
+func linkKey(linkID uuid.UUID) int64 {
+	var buf [8]byte
+	copy(buf[2:], linkID[:6])
+	return int64(binary.BigEndian.Uint64(buf[:]))
+}
+
+func mapMeiliDoc(d *meiliDoc) *index.Document {
+	return &index.Document{
+		LinkID:       uuid.MustParse(d.LinkID),
+		URL:          d.URL,
+		Title:        d.Title,
+		Content:      d.Content,
+		Summary:      d.Summary,
+		Language:     d.Language,
+		Vertical:     d.Vertical,
+		Namespace:    namespace.OrDefault(d.Namespace),
+		IndexedAt:    d.IndexedAt.UTC(),
+		PageRank:     d.PageRank,
+		Author:       d.Author,
+		PublishedAt:  mapMeiliDate(d.PublishedAt),
+		FaviconRef:   d.FaviconRef,
+		ThumbnailRef: d.ThumbnailRef,
+		Headers:      mapMeiliHeaders(d.Headers),
+		Fingerprint:  mapMeiliHash(d.Fingerprint),
+		ContentHash:  mapMeiliHash(d.ContentHash),
+		ChangedAt:    mapMeiliDate(d.ChangedAt),
+		DuplicateOf:  mapMeiliDuplicateOf(d.DuplicateOf),
+	}
+}
+
+func mapMeiliDate(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// meiliTime returns t in milliseconds since the Unix epoch or zero if t is
+// not set.
+func meiliTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func mapMeiliHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+func mapMeiliHash(v string) uint64 {
+	fp, _ := strconv.ParseUint(v, 16, 64)
+	return fp
+}
+
+func mapMeiliDuplicateOf(v string) uuid.UUID {
+	if v == "" {
+		return uuid.Nil
+	}
+	id, _ := uuid.Parse(v)
+	return id
+}
+
+func makeMeiliDoc(d *index.Document) meiliDoc {
+	// Note: we intentionally skip PageRank as we don't want updates to
+	// overwrite existing PageRank values.
+	doc := meiliDoc{
+		LinkID:       d.LinkID.String(),
+		LinkKey:      linkKey(d.LinkID),
+		URL:          d.URL,
+		Title:        d.Title,
+		Content:      d.Content,
+		Summary:      d.Summary,
+		Language:     d.Language,
+		IndexedAt:    d.IndexedAt.UTC(),
+		Author:       d.Author,
+		FaviconRef:   d.FaviconRef,
+		ThumbnailRef: d.ThumbnailRef,
+		Host:         index.HostOf(d),
+		IndexedDate:  index.IndexedDateOf(d),
+		IndexedTime:  meiliTime(d.IndexedAt),
+		Domains:      index.DomainsOf(d),
+		Vertical:     d.Vertical,
+		Namespace:    d.Namespace,
+		Headers:      d.Headers,
+		Terms:        makeTerms(d),
+	}
+	if !d.PublishedAt.IsZero() {
+		publishedAt := d.PublishedAt.UTC()
+		doc.PublishedAt = &publishedAt
+	}
+	if !d.ChangedAt.IsZero() {
+		changedAt := d.ChangedAt.UTC()
+		doc.ChangedAt = &changedAt
+	}
+	if d.Fingerprint != 0 {
+		doc.Fingerprint = strconv.FormatUint(d.Fingerprint, 16)
+	}
+	if d.ContentHash != 0 {
+		doc.ContentHash = strconv.FormatUint(d.ContentHash, 16)
+	}
+	if d.DuplicateOf != uuid.Nil {
+		doc.DuplicateOf = d.DuplicateOf.String()
+	}
+	return doc
+}
@@ -0,0 +1,66 @@
+package meili
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"webcrawler/crawler/textindexer/index/indextest"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(MeilisearchTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type MeilisearchTestSuite struct {
+	indextest.SuiteBase
+	idx  *MeilisearchIndexer
+	opts Options
+}
+
+func (s *MeilisearchTestSuite) SetUpSuite(c *gc.C) {
+	baseURL := os.Getenv("MEILI_URL")
+	if baseURL == "" {
+		c.Skip("Missing MEILI_URL envvar; skipping meilisearch-backed index test suite")
+	}
+
+	s.opts = Options{IndexName: "textindexer-test", APIKey: os.Getenv("MEILI_API_KEY"), SyncUpdates: true}
+	idx, err := NewMeilisearchIndexer(baseURL, s.opts)
+	c.Assert(err, gc.IsNil)
+	s.SetIndexer(idx)
+	s.idx = idx
+}
+
+func (s *MeilisearchTestSuite) SetUpTest(c *gc.C) {
+	if s.idx != nil {
+		_, err := s.idx.c.enqueue(http.MethodDelete, indexPath(s.idx.indexUID), nil, true)
+		c.Assert(err, gc.IsNil)
+		s.opts.applyDefaults()
+		c.Assert(ensureIndex(s.idx.c, s.opts), gc.IsNil)
+	}
+}
+
+// TestLanguageAnalyzers overrides the shared test as Meilisearch does not stem
+// words.
+func (s *MeilisearchTestSuite) TestLanguageAnalyzers(c *gc.C) {
+	c.Skip("meilisearch does not support language-specific stemming")
+}
+
+// TestSuggestions overrides the shared test as Meilisearch applies typo
+// tolerance instead of suggesting corrections.
+func (s *MeilisearchTestSuite) TestSuggestions(c *gc.C) {
+	c.Skip("meilisearch does not suggest corrections")
+}
+
+// TestFreshnessRanking overrides the shared test as Meilisearch orders results
+// using a fixed set of ranking rules.
+func (s *MeilisearchTestSuite) TestFreshnessRanking(c *gc.C) {
+	c.Skip("meilisearch does not support custom ranking functions")
+}
+
+// TestFieldBoosts overrides the shared test as Meilisearch ranks matches by
+// the order of the searchable attributes and does not search URLs.
+func (s *MeilisearchTestSuite) TestFieldBoosts(c *gc.C) {
+	c.Skip("meilisearch does not support field boosts")
+}
@@ -0,0 +1,91 @@
+package meili
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/namespace"
+)
+
+// Options configures the Meilisearch index used by a MeilisearchIndexer.
+type Options struct {
+	// The UID of the index to store documents in. Defaults to
+	// "textindexer".
+	IndexName string
+
+	// The namespace of the crawl whose documents are accessed through the
+	// indexer. Each namespace other than namespace.Default is stored in
+	// its own index whose UID is IndexName suffixed with "-" and the
+	// namespace. Defaults to namespace.Default.
+	Namespace string
+
+	// The API key for instances that are protected by a master key. The
+	// key must be allowed to manage indexes, documents and tasks.
+	APIKey string
+
+	// If set, write operations block until Meilisearch has processed them
+	// so that changes are immediately visible to searches. Otherwise,
+	// writes are applied asynchronously.
+	SyncUpdates bool
+
+	// The minimum word length for accepting one and two typos in query
+	// terms. Defaults to 5 and 9 characters which match the Meilisearch
+	// defaults.
+	MinWordSizeForOneTypo  int
+	MinWordSizeForTwoTypos int
+
+	// The maximum number of results that can be paged through for a
+	// single search. Defaults to 10000.
+	MaxTotalHits int
+
+	// The number of results fetched per search request. Defaults to
+	// index.DefaultBatchSize.
+	BatchSize int
+
+	// The largest query offset accepted by Search. Defaults to
+	// index.DefaultMaxOffset.
+	MaxOffset uint64
+
+	// How long to wait for asynchronous tasks (such as applying the index
+	// settings) to complete. Defaults to 30 seconds.
+	TaskTimeout time.Duration
+
+	// An optional HTTP client for sending requests to Meilisearch. If not
+	// specified, a client with a 30 second timeout is used.
+	HTTPClient *http.Client
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (opts *Options) applyDefaults() {
+	if opts.IndexName == "" {
+		opts.IndexName = defaultIndexName
+	}
+	opts.Namespace = namespace.OrDefault(opts.Namespace)
+	if opts.Namespace != namespace.Default {
+		opts.IndexName += "-" + opts.Namespace
+	}
+	if opts.MinWordSizeForOneTypo <= 0 {
+		opts.MinWordSizeForOneTypo = 5
+	}
+	if opts.MinWordSizeForTwoTypos <= 0 {
+		opts.MinWordSizeForTwoTypos = 9
+	}
+	if opts.MaxTotalHits <= 0 {
+		opts.MaxTotalHits = 10000
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = index.DefaultBatchSize
+	}
+	if opts.MaxOffset == 0 {
+		opts.MaxOffset = index.DefaultMaxOffset
+	}
+	if opts.TaskTimeout <= 0 {
+		opts.TaskTimeout = 30 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+}
@@ -0,0 +1,269 @@
+package meili
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"webcrawler/crawler/textindexer/index"
+)
+
+// meiliQuery is the Meilisearch equivalent of an index.Query.
+type meiliQuery struct {
+	// The query string which is matched (with typo tolerance) against the
+	// searchable attributes.
+	query string
+
+	// The matching strategy for the query string. "last" allows documents
+	// that match a subset of the query terms while "all" requires all of
+	// them to match.
+	matchingStrategy string
+
+	// An optional filter expression that restricts the result set.
+	filter string
+}
+
+// makeMeiliQuery translates q into a Meilisearch query.
+func makeMeiliQuery(q index.Query) (meiliQuery, error) {
+	var mq meiliQuery
+	switch q.Type {
+	case index.QueryTypeBoolean:
+		root, err := index.ParseBooleanQuery(q.Expression)
+		if err != nil {
+			return meiliQuery{}, err
+		}
+		mq = makeBooleanQuery(root)
+	case index.QueryTypePhrase:
+		mq = meiliQuery{query: quotePhrase(q.Expression), matchingStrategy: "all"}
+	default:
+		mq = meiliQuery{query: q.Expression, matchingStrategy: "last"}
+	}
+
+	if q.Vertical != "" {
+		mq.filter = joinFilters(" AND ", mq.filter, makeVerticalFilter(q.Vertical))
+	}
+	mq.filter = joinFilters(" AND ", mq.filter, makeSearchFilter(index.FiltersOf(q)))
+	return mq, nil
+}
+
+// makeBooleanQuery translates a parsed boolean query. Meilisearch queries can
+// only express a conjunction of terms and phrases that are searched for in all
+// searchable attributes. Such nodes at the top level of the query are passed
+// to Meilisearch as the query string so that they are matched with typo
+// tolerance and ranked by relevance. The remaining nodes (disjunctions,
+// negations and field-scoped terms) are converted into a filter over the
+// document terms; they are matched exactly and phrases are matched as a
+// conjunction of their terms.
+func makeBooleanQuery(root *index.QueryNode) meiliQuery {
+	nodes := []*index.QueryNode{root}
+	if root.Type == index.QueryNodeAnd {
+		nodes = root.Children
+	}
+
+	var (
+		queryParts []string
+		filters    []string
+	)
+	for _, node := range nodes {
+		switch {
+		case node.Type == index.QueryNodeTerm && node.Field == "":
+			queryParts = append(queryParts, node.Text)
+		case node.Type == index.QueryNodePhrase && node.Field == "":
+			queryParts = append(queryParts, quotePhrase(node.Text))
+		default:
+			filters = append(filters, makeFilter(node))
+		}
+	}
+
+	return meiliQuery{
+		query:            strings.Join(queryParts, " "),
+		matchingStrategy: "all",
+		filter:           joinFilters(" AND ", filters...),
+	}
+}
+
+// makeFilter returns a filter expression over the Terms attribute that
+// matches the documents matching node.
+func makeFilter(node *index.QueryNode) string {
+	switch node.Type {
+	case index.QueryNodeAnd, index.QueryNodeOr:
+		op := " AND "
+		if node.Type == index.QueryNodeOr {
+			op = " OR "
+		}
+		filters := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			filters[i] = makeFilter(child)
+		}
+		return "(" + joinFilters(op, filters...) + ")"
+	case index.QueryNodeNot:
+		return "NOT " + makeFilter(node.Children[0])
+	default:
+		tokens := tokenize(node.Text)
+		if len(tokens) == 0 {
+			// Text without any terms cannot match any document.
+			return `Terms = ""`
+		}
+		filters := make([]string, len(tokens))
+		for i, token := range tokens {
+			filters[i] = "Terms = " + quoteFilterValue(termFor(node.Field, token))
+		}
+		return "(" + joinFilters(" AND ", filters...) + ")"
+	}
+}
+
+// makeVerticalFilter returns a filter that matches the documents belonging to
+// the specified search vertical. Placeholder documents created by UpdateScore
+// lack the Vertical attribute and are treated as web-pages.
+func makeVerticalFilter(vertical string) string {
+	filter := "Vertical = " + quoteFilterValue(vertical)
+	if vertical != index.VerticalWeb {
+		return filter
+	}
+	return "(" + filter + " OR Vertical NOT EXISTS)"
+}
+
+// makeSearchFilter returns a filter expression that matches the documents
+// satisfying all of the criteria set in f.
+func makeSearchFilter(f index.Filters) string {
+	var filters []string
+	if len(f.Domains) != 0 {
+		filters = append(filters, "Domains IN "+quoteFilterValues(f.Domains))
+	}
+	if !f.IndexedAfter.IsZero() {
+		filters = append(filters, "IndexedTime >= "+strconv.FormatInt(f.IndexedAfter.UnixMilli(), 10))
+	}
+	if !f.IndexedBefore.IsZero() {
+		filters = append(filters, "IndexedTime < "+strconv.FormatInt(f.IndexedBefore.UnixMilli(), 10))
+	}
+	if len(f.Languages) != 0 {
+		filters = append(filters, "Language IN "+quoteFilterValues(f.Languages))
+	}
+	if f.MinPageRank > 0 {
+		filters = append(filters, "PageRank >= "+strconv.FormatFloat(f.MinPageRank, 'g', -1, 64))
+	}
+	return joinFilters(" AND ", filters...)
+}
+
+// makeTerms returns the sorted set of terms that filters are matched against.
+// It contains the terms of the title and content as well as the terms of each
+// field that can be targeted by a field prefix, prefixed with the lower-case
+// field name (e.g. "url:example").
+func makeTerms(d *index.Document) []string {
+	set := make(map[string]struct{})
+	addTerms := func(field, text string) {
+		for _, token := range tokenize(text) {
+			set[termFor(field, token)] = struct{}{}
+		}
+	}
+
+	addTerms("", d.Title)
+	addTerms("", d.Content)
+	addTerms(index.FieldTitle, d.Title)
+	addTerms(index.FieldContent, d.Content)
+	addTerms(index.FieldURL, d.URL)
+	for name, value := range d.Headers {
+		addTerms(index.HeaderField(name), value)
+	}
+
+	terms := make([]string, 0, len(set))
+	for term := range set {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// termFor returns the entry of the Terms attribute for a token of the
+// specified field. Tokens of the title and content are also stored without a
+// field prefix.
+func termFor(field, token string) string {
+	if field == "" {
+		return token
+	}
+	return strings.ToLower(field) + ":" + token
+}
+
+// tokenize splits text into lower-case terms consisting of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// quotePhrase returns a query string that matches text as an exact phrase.
+func quotePhrase(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "") + `"`
+}
+
+func quoteFilterValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
+
+// quoteFilterValues returns a filter array containing the quoted values.
+func quoteFilterValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quoteFilterValue(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// joinFilters joins the non-empty filters with op.
+func joinFilters(op string, filters ...string) string {
+	nonEmpty := filters[:0:0]
+	for _, filter := range filters {
+		if filter != "" {
+			nonEmpty = append(nonEmpty, filter)
+		}
+	}
+	return strings.Join(nonEmpty, op)
+}
+
+// facetAttributes maps each facet field to the document attribute that holds
+// its value.
+var facetAttributes = map[index.FacetField]string{
+	index.FacetHost:        "Host",
+	index.FacetLanguage:    "Language",
+	index.FacetIndexedDate: "IndexedDate",
+	index.FacetVertical:    "Vertical",
+}
+
+// makeFacets returns the attributes to compute the facet distribution for.
+func makeFacets(facets []index.FacetRequest) []string {
+	var attrs []string
+	for _, facet := range facets {
+		attrs = append(attrs, facetAttributes[facet.Field])
+	}
+	return attrs
+}
+
+// mapFacets converts the facet distribution in a search response into the
+// facets requested by the query. Buckets are sorted by descending count and
+// ties are broken by value.
+func mapFacets(facets []index.FacetRequest, dist map[string]map[string]uint64) []index.Facet {
+	if len(facets) == 0 {
+		return nil
+	}
+
+	out := make([]index.Facet, len(facets))
+	for i, facet := range facets {
+		out[i].Field = facet.Field
+
+		var buckets []index.FacetBucket
+		for value, count := range dist[facetAttributes[facet.Field]] {
+			buckets = append(buckets, index.FacetBucket{Value: value, Count: count})
+		}
+		sort.Slice(buckets, func(i, j int) bool {
+			if buckets[i].Count != buckets[j].Count {
+				return buckets[i].Count > buckets[j].Count
+			}
+			return buckets[i].Value < buckets[j].Value
+		})
+		if size := facet.FacetSize(); len(buckets) > size {
+			buckets = buckets[:size]
+		}
+		out[i].Buckets = buckets
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package meili
+
+import (
+	"errors"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(QueryTestSuite))
+
+type QueryTestSuite struct{}
+
+func (s *QueryTestSuite) TestMatchAndPhraseQueries(c *gc.C) {
+	mq, err := makeMeiliQuery(index.Query{Type: index.QueryTypeMatch, Expression: "lorem ipsum"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(mq, gc.DeepEquals, meiliQuery{query: "lorem ipsum", matchingStrategy: "last"})
+
+	mq, err = makeMeiliQuery(index.Query{Type: index.QueryTypePhrase, Expression: `lorem "ipsum"`, Vertical: index.VerticalNews})
+	c.Assert(err, gc.IsNil)
+	c.Assert(mq, gc.DeepEquals, meiliQuery{query: `"lorem ipsum"`, matchingStrategy: "all", filter: `Vertical = "news"`})
+}
+
+func (s *QueryTestSuite) TestFilters(c *gc.C) {
+	mq, err := makeMeiliQuery(index.Query{
+		Expression: "lorem",
+		Vertical:   index.VerticalNews,
+		Filters: index.Filters{
+			Domains:       []string{" Example.com", "*.example.org", ""},
+			IndexedAfter:  time.UnixMilli(1709294400000),
+			IndexedBefore: time.UnixMilli(1709899200000),
+			Languages:     []string{"en", "DE"},
+			MinPageRank:   0.25,
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(mq.filter, gc.Equals, `Vertical = "news" AND Domains IN ["example.com", "example.org"] AND `+
+		`IndexedTime >= 1709294400000 AND IndexedTime < 1709899200000 AND Language IN ["en", "de"] AND PageRank >= 0.25`)
+
+	doc := makeMeiliDoc(&index.Document{LinkID: uuid.New(), URL: "https://www.Example.com/about", IndexedAt: time.UnixMilli(1709294400000)})
+	c.Assert(doc.Domains, gc.DeepEquals, []string{"www.example.com", "example.com", "com"})
+	c.Assert(doc.IndexedTime, gc.Equals, int64(1709294400000))
+}
+
+func (s *QueryTestSuite) TestBooleanQueries(c *gc.C) {
+	specs := []struct {
+		expr     string
+		vertical string
+		exp      meiliQuery
+	}{
+		{
+			expr: `lorem "dolor sit"`,
+			exp:  meiliQuery{query: `lorem "dolor sit"`, matchingStrategy: "all"},
+		},
+		{
+			expr: "lorem -poeta",
+			exp:  meiliQuery{query: "lorem", matchingStrategy: "all", filter: `NOT (Terms = "poeta")`},
+		},
+		{
+			expr: "poeta url:example.com",
+			exp:  meiliQuery{query: "poeta", matchingStrategy: "all", filter: `(Terms = "url:example" AND Terms = "url:com")`},
+		},
+		{
+			expr:     "(ovidius OR ipsum) NOT title:lorem",
+			vertical: index.VerticalWeb,
+			exp: meiliQuery{
+				matchingStrategy: "all",
+				filter:           `((Terms = "ovidius") OR (Terms = "ipsum")) AND NOT (Terms = "title:lorem") AND (Vertical = "web" OR Vertical NOT EXISTS)`,
+			},
+		},
+		{
+			expr: `header.X-Generator:"hugo 0.120"`,
+			exp:  meiliQuery{matchingStrategy: "all", filter: `(Terms = "headers.x-generator:hugo" AND Terms = "headers.x-generator:0" AND Terms = "headers.x-generator:120")`},
+		},
+	}
+
+	for _, spec := range specs {
+		mq, err := makeMeiliQuery(index.Query{Type: index.QueryTypeBoolean, Expression: spec.expr, Vertical: spec.vertical})
+		c.Assert(err, gc.IsNil, gc.Commentf("query %q", spec.expr))
+		c.Assert(mq, gc.DeepEquals, spec.exp, gc.Commentf("query %q", spec.expr))
+	}
+
+	_, err := makeMeiliQuery(index.Query{Type: index.QueryTypeBoolean, Expression: `title:"unterminated`})
+	c.Assert(errors.Is(err, index.ErrInvalidQuery), gc.Equals, true)
+}
+
+func (s *QueryTestSuite) TestMakeTerms(c *gc.C) {
+	doc := &index.Document{
+		LinkID:  uuid.New(),
+		URL:     "http://Example.com/a",
+		Title:   "Ovidius",
+		Content: "poeta, poeta",
+		Headers: map[string]string{"Server": "nginx"},
+	}
+	c.Assert(makeTerms(doc), gc.DeepEquals, []string{
+		"content:poeta",
+		"headers.server:nginx",
+		"ovidius",
+		"poeta",
+		"title:ovidius",
+		"url:a",
+		"url:com",
+		"url:example",
+		"url:http",
+	})
+}
+
+func (s *QueryTestSuite) TestMapFacets(c *gc.C) {
+	facets := mapFacets(
+		[]index.FacetRequest{{Field: index.FacetHost, Size: 2}, {Field: index.FacetLanguage}},
+		map[string]map[string]uint64{
+			"Host": {"b.com": 2, "a.com": 2, "c.com": 5},
+		},
+	)
+	c.Assert(facets, gc.DeepEquals, []index.Facet{
+		{
+			Field: index.FacetHost,
+			Buckets: []index.FacetBucket{
+				{Value: "c.com", Count: 5},
+				{Value: "a.com", Count: 2},
+			},
+		},
+		{Field: index.FacetLanguage},
+	})
+
+	c.Assert(mapFacets(nil, nil), gc.IsNil)
+}
+
+func (s *QueryTestSuite) TestLinkKeyOrder(c *gc.C) {
+	a := uuid.MustParse("00000000-0001-4000-8000-000000000000")
+	b := uuid.MustParse("00000000-0002-4000-8000-000000000000")
+	c.Assert(linkKey(a) < linkKey(b), gc.Equals, true)
+	c.Assert(linkKey(uuid.MustParse("ffffffff-ffff-4000-8000-000000000000")), gc.Equals, int64(1<<48-1))
+}
@@ -1,33 +1,113 @@
 package memory
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
+	"webcrawler/crawler/iterutil"
 	"webcrawler/crawler/textindexer/index"
+	"webcrawler/namespace"
 
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
 	"github.com/blevesearch/bleve/v2/search/query"
 	"github.com/google/uuid"
 )
 
-// The size of each page of results that is cached locally by the iterator.
-const batchSize = 10
+// Compile-time checks to ensure InMemoryBleveIndexer implements the required
+// interfaces.
+var (
+	_ index.Indexer          = (*InMemoryBleveIndexer)(nil)
+	_ index.BulkScoreUpdater = (*InMemoryBleveIndexer)(nil)
+)
+
+// Config encapsulates the optional settings for an InMemoryBleveIndexer.
+type Config struct {
+	// The namespace of the crawl whose documents are stored in the
+	// indexer. Defaults to namespace.Default.
+	Namespace string
+
+	// The boosts of the fields searched by queries that do not target a
+	// specific field. Defaults to index.DefaultFieldBoosts.
+	FieldBoosts index.FieldBoosts
+
+	// The size of each page of results that is cached locally by the
+	// iterators. Defaults to index.DefaultBatchSize.
+	BatchSize int
+
+	// The largest query offset accepted by Search. Defaults to
+	// index.DefaultMaxOffset.
+	MaxOffset uint64
+}
+
+// fieldBoosts returns the configured field boosts or the default ones if
+// none are configured.
+func (cfg Config) fieldBoosts() (index.FieldBoosts, error) {
+	if cfg.FieldBoosts == nil {
+		return index.DefaultFieldBoosts, nil
+	}
+	if err := cfg.FieldBoosts.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg.FieldBoosts, nil
+}
+
+// batchSize returns the configured batch size or the default one if none is
+// configured.
+func (cfg Config) batchSize() (int, error) {
+	if cfg.BatchSize == 0 {
+		return index.DefaultBatchSize, nil
+	}
+	if err := index.CheckBatchSize(cfg.BatchSize); err != nil {
+		return 0, err
+	}
+	return cfg.BatchSize, nil
+}
 
-// Compile-time check to ensure InMemoryBleveIndexer implements Indexer.
-var _ index.Indexer = (*InMemoryBleveIndexer)(nil)
+// maxOffset returns the configured maximum offset or the default one if none
+// is configured.
+func (cfg Config) maxOffset() uint64 {
+	if cfg.MaxOffset == 0 {
+		return index.DefaultMaxOffset
+	}
+	return cfg.MaxOffset
+}
 
 // NewInMemoryBleveIndexer creates a text indexer that uses an in-memory
 // bleve instance for indexing documents.
 func NewInMemoryBleveIndexer() (*InMemoryBleveIndexer, error) {
-	mapping := bleve.NewIndexMapping()
-	idx, err := bleve.NewMemOnly(mapping)
+	return NewInMemoryBleveIndexerWithConfig(Config{})
+}
+
+// NewInMemoryBleveIndexerWithConfig creates a text indexer that uses an
+// in-memory bleve instance for indexing documents and the settings in cfg.
+func NewInMemoryBleveIndexerWithConfig(cfg Config) (*InMemoryBleveIndexer, error) {
+	ns := namespace.OrDefault(cfg.Namespace)
+	if err := namespace.Validate(ns); err != nil {
+		return nil, fmt.Errorf("in-memory indexer: %w", err)
+	}
+	boosts, err := cfg.fieldBoosts()
+	if err != nil {
+		return nil, fmt.Errorf("in-memory indexer: %w", err)
+	}
+	batchSize, err := cfg.batchSize()
+	if err != nil {
+		return nil, fmt.Errorf("in-memory indexer: %w", err)
+	}
+
+	idx, err := bleve.NewMemOnly(newIndexMapping())
 	if err != nil {
 		return nil, err
 	}
 
 	return &InMemoryBleveIndexer{
-		idx:  idx,
-		docs: make(map[string]*index.Document),
+		idx:       idx,
+		ns:        ns,
+		boosts:    boosts,
+		batchSize: batchSize,
+		maxOffset: cfg.maxOffset(),
+		docs:      make(map[string]*index.Document),
 	}, nil
 }
 
@@ -43,23 +123,43 @@ func (i *InMemoryBleveIndexer) Index(doc *index.Document) error {
 		return fmt.Errorf("index: %w", index.ErrMissingLinkID)
 	}
 
-	doc.IndexedAt = time.Now()
+	// Documents that have not been timestamped by the caller are indexed
+	// at the current time. Strip the monotonic clock reading so that the
+	// timestamp survives a round-trip through the document store of
+	// persistent indexers.
+	if doc.IndexedAt.IsZero() {
+		doc.IndexedAt = time.Now()
+	}
+	doc.IndexedAt = doc.IndexedAt.UTC().Round(0)
+	doc.Language = index.DocumentLanguage(doc)
+	doc.Vertical = index.VerticalOf(doc)
+	doc.Namespace = i.ns
 	dcopy := copyDoc(doc)
 	key := dcopy.LinkID.String()
 
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	// If updating, preserve existing PageRank score
-	if orig, exists := i.docs[key]; exists {
+	orig, err := i.getDoc(key)
+	if err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+
+	// If updating, preserve existing PageRank score and any blob
+	// references that were not captured this time.
+	if orig != nil {
 		dcopy.PageRank = orig.PageRank
+		if dcopy.FaviconRef == "" {
+			dcopy.FaviconRef = orig.FaviconRef
+		}
+		if dcopy.ThumbnailRef == "" {
+			dcopy.ThumbnailRef = orig.ThumbnailRef
+		}
 	}
 
-	if err := i.idx.Index(key, makeBleveDoc(dcopy)); err != nil {
+	if err := i.putDoc(key, dcopy); err != nil {
 		return fmt.Errorf("index: %w", err)
 	}
-
-	i.docs[key] = dcopy
 	return nil
 }
 
@@ -73,7 +173,10 @@ func (i *InMemoryBleveIndexer) findByID(linkID string) (*index.Document, error)
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 
-	if d, found := i.docs[linkID]; found {
+	d, err := i.getDoc(linkID)
+	if err != nil {
+		return nil, fmt.Errorf("find by ID: %w", err)
+	} else if d != nil {
 		return copyDoc(d), nil
 	}
 
@@ -83,24 +186,127 @@ func (i *InMemoryBleveIndexer) findByID(linkID string) (*index.Document, error)
 // Search the index for a particular query and return back a result
 // iterator.
 func (i *InMemoryBleveIndexer) Search(q index.Query) (index.Iterator, error) {
+	if err := index.CheckOffset(q, i.maxOffset); err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+
 	var bq query.Query
 	switch q.Type {
+	case index.QueryTypeBoolean:
+		root, err := index.ParseBooleanQuery(q.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("search: %w", err)
+		}
+		bq = makeBleveBooleanQuery(root, i.boosts)
 	case index.QueryTypePhrase:
-		bq = bleve.NewMatchPhraseQuery(q.Expression)
+		bq = makeBleveTextQuery(index.QueryNodePhrase, q.Expression, i.boosts)
 	default:
-		bq = bleve.NewMatchQuery(q.Expression)
+		bq = makeBleveTextQuery(index.QueryNodeTerm, q.Expression, i.boosts)
+	}
+	if q.Vertical != "" {
+		verticalQuery := bleve.NewTermQuery(q.Vertical)
+		verticalQuery.SetField(bleveFacetFields[index.FacetVertical])
+		bq = bleve.NewConjunctionQuery(bq, verticalQuery)
+	}
+	if filterQueries := makeBleveFilterQueries(index.FiltersOf(q)); len(filterQueries) != 0 {
+		bq = bleve.NewConjunctionQuery(append([]query.Query{bq}, filterQueries...)...)
 	}
 
 	searchReq := bleve.NewSearchRequest(bq)
-	searchReq.SortBy([]string{"-PageRank", "-_score"})
-	searchReq.Size = batchSize
+	searchReq.SortByCustom(search.SortOrder{newRankingSort(index.RankingOf(q))})
+	searchReq.Size = index.BatchSizeOf(q, i.batchSize)
 	searchReq.From = int(q.Offset)
+	for fIdx, facet := range q.Facets {
+		searchReq.AddFacet(bleveFacetName(fIdx), bleve.NewFacetRequest(bleveFacetFields[facet.Field], facet.FacetSize()))
+	}
 	rs, err := i.idx.Search(searchReq)
 	if err != nil {
 		return nil, fmt.Errorf("search: %w", err)
 	}
 
-	return &bleveIterator{idx: i, searchReq: searchReq, rs: rs, cumIdx: q.Offset}, nil
+	// Facets only need to be computed once; skip them when fetching
+	// subsequent result pages.
+	facets := mapBleveFacets(q.Facets, rs.Facets)
+	searchReq.Facets = nil
+
+	var suggestions []index.Suggestion
+	if rs.Total < index.SuggestionThreshold {
+		if suggestions, err = suggest(i.idx, q.Expression); err != nil {
+			return nil, fmt.Errorf("search: %w", err)
+		}
+	}
+
+	it := &bleveIterator{idx: i, searchReq: searchReq, rs: rs, cumIdx: q.Offset, facets: facets, suggestions: suggestions}
+	if q.Size != 0 {
+		return iterutil.LimitDocuments(it, int(q.Size)), nil
+	}
+	return it, nil
+}
+
+// Suggest returns up to limit distinct titles of indexed documents for
+// autocompleting the search expression prefix.
+func (i *InMemoryBleveIndexer) Suggest(prefix string, limit int) ([]string, error) {
+	words := index.CompletionWords(prefix)
+	if len(words) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	prefixQueries := make([]query.Query, len(words))
+	for wIdx, word := range words {
+		q := bleve.NewPrefixQuery(word)
+		q.SetField(index.FieldTitle)
+		prefixQueries[wIdx] = q
+	}
+	searchReq := bleve.NewSearchRequest(bleve.NewConjunctionQuery(prefixQueries...))
+	searchReq.SortBy([]string{"-PageRank", "_id"})
+	searchReq.Size = i.batchSize
+
+	var (
+		titles []string
+		seen   = make(map[string]bool)
+	)
+	for {
+		rs, err := i.idx.Search(searchReq)
+		if err != nil {
+			return nil, fmt.Errorf("suggest: %w", err)
+		}
+		for _, hit := range rs.Hits {
+			doc, err := i.findByID(hit.ID)
+			if err != nil {
+				return nil, fmt.Errorf("suggest: %w", err)
+			}
+			if seen[doc.Title] {
+				continue
+			}
+			seen[doc.Title] = true
+			if titles = append(titles, doc.Title); len(titles) == limit {
+				return titles, nil
+			}
+		}
+		if len(rs.Hits) < searchReq.Size {
+			return titles, nil
+		}
+		searchReq.From += searchReq.Size
+	}
+}
+
+// All returns an iterator over every indexed document in ascending link ID
+// order, resuming after the document that cursor refers to.
+func (i *InMemoryBleveIndexer) All(cursor index.Cursor) (index.DocumentIterator, error) {
+	// Bleve document IDs are the canonical string representation of the
+	// link IDs which sort in the same order as the link IDs themselves.
+	searchReq := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	searchReq.SortBy([]string{"_id"})
+	searchReq.Size = i.batchSize
+	if cursor != "" {
+		linkID, err := cursor.LinkID()
+		if err != nil {
+			return nil, fmt.Errorf("all: %w", err)
+		}
+		searchReq.SetSearchAfter([]string{linkID.String()})
+	}
+
+	return &bleveAllIterator{idx: i, searchReq: searchReq}, nil
 }
 
 // UpdateScore updates the PageRank score for a document with the specified
@@ -111,30 +317,234 @@ func (i *InMemoryBleveIndexer) UpdateScore(linkID uuid.UUID, score float64) erro
 	defer i.mu.Unlock()
 
 	key := linkID.String()
-	doc, found := i.docs[key]
-	if !found {
+	doc, err := i.getDoc(key)
+	if err != nil {
+		return fmt.Errorf("update score: %w", err)
+	} else if doc == nil {
 		doc = &index.Document{LinkID: linkID}
-		i.docs[key] = doc
+	} else {
+		doc = copyDoc(doc)
 	}
 
 	doc.PageRank = score
-	if err := i.idx.Index(key, makeBleveDoc(doc)); err != nil {
+	if err := i.putDoc(key, doc); err != nil {
 		return fmt.Errorf("update score: %w", err)
 	}
 
 	return nil
 }
 
+// UpdateScores updates the PageRank scores of the documents whose link IDs
+// are keys of scores using a single batch. Placeholder documents are created
+// for unknown link IDs.
+func (i *InMemoryBleveIndexer) UpdateScores(scores map[uuid.UUID]float64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var (
+		docs   = make(map[string]*index.Document, len(scores))
+		failed map[uuid.UUID]error
+	)
+	for linkID, score := range scores {
+		key := linkID.String()
+		doc, err := i.getDoc(key)
+		if err != nil {
+			if failed == nil {
+				failed = make(map[uuid.UUID]error)
+			}
+			failed[linkID] = err
+			continue
+		} else if doc == nil {
+			doc = &index.Document{LinkID: linkID}
+		} else {
+			doc = copyDoc(doc)
+		}
+		doc.PageRank = score
+		docs[key] = doc
+	}
+
+	if err := i.putDocs(docs); err != nil {
+		return fmt.Errorf("update scores: %w", err)
+	}
+	if failed != nil {
+		return fmt.Errorf("update scores: %w", &index.ScoreUpdateError{Failed: failed})
+	}
+	return nil
+}
+
+// Patch applies a partial update to the indexed document with the specified
+// link ID.
+func (i *InMemoryBleveIndexer) Patch(linkID uuid.UUID, patch index.DocumentPatch) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key := linkID.String()
+	orig, err := i.getDoc(key)
+	if err != nil {
+		return fmt.Errorf("patch: %w", err)
+	} else if orig == nil {
+		return fmt.Errorf("patch: %w", index.ErrNotFound)
+	}
+
+	dcopy := copyDoc(orig)
+	patch.Apply(dcopy)
+	if err := i.putDoc(key, dcopy); err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+	return nil
+}
+
+// getDoc returns the stored document with the specified key or nil if no
+// such document exists. Callers must hold the indexer lock and must not
+// modify the returned document.
+func (i *InMemoryBleveIndexer) getDoc(key string) (*index.Document, error) {
+	if i.docs != nil {
+		return i.docs[key], nil
+	}
+
+	data, err := i.idx.GetInternal(docInternalKey(key))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	doc := new(index.Document)
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("malformed document %q: %w", key, err)
+	}
+	return doc, nil
+}
+
+// putDoc indexes doc under key and stores its contents. Callers must hold the
+// indexer lock.
+func (i *InMemoryBleveIndexer) putDoc(key string, doc *index.Document) error {
+	return i.putDocs(map[string]*index.Document{key: doc})
+}
+
+// putDocs indexes each document in docs under its key and stores its contents
+// using a single batch. Persistent indexers store the contents in the
+// internal key-value store of the bleve index as part of the same batch as
+// the indexed fields. Callers must hold the indexer lock.
+func (i *InMemoryBleveIndexer) putDocs(docs map[string]*index.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	batch := i.idx.NewBatch()
+	for key, doc := range docs {
+		if err := batch.Index(key, makeBleveDoc(doc)); err != nil {
+			return err
+		}
+		if i.docs != nil {
+			continue
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		batch.SetInternal(docInternalKey(key), data)
+	}
+	if err := i.idx.Batch(batch); err != nil {
+		return err
+	}
+
+	if i.docs != nil {
+		for key, doc := range docs {
+			i.docs[key] = doc
+		}
+	}
+	return nil
+}
+
+// docInternalKey returns the key of the document with the specified key in
+// the internal key-value store of a persistent bleve index.
+func docInternalKey(key string) []byte {
+	return []byte("doc:" + key)
+}
+
+// newIndexMapping returns the bleve mapping for indexed documents. Unscoped
+// match and phrase queries search the boosted fields individually, so the
+// URL field and the keyword fields that back search facets are excluded
+// from the composite field.
+//
+// The text of documents written in one of the supported languages is also
+// indexed under a LangText.<lang> field that uses the analyzer for that
+// language.
+//
+// Captured response headers are indexed as "name=value" keywords under the
+// HeaderTerms field and can only be matched by header-scoped queries.
+//
+// The indexing time is only used for ranking search results by freshness.
+func newIndexMapping() mapping.IndexMapping {
+	urlMapping := bleve.NewTextFieldMapping()
+	urlMapping.IncludeInAll = false
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping.AddFieldMappingsAt("URL", urlMapping)
+	for _, field := range bleveFacetFields {
+		keywordMapping := bleve.NewKeywordFieldMapping()
+		keywordMapping.IncludeInAll = false
+		m.DefaultMapping.AddFieldMappingsAt(field, keywordMapping)
+	}
+	indexedAtMapping := bleve.NewDateTimeFieldMapping()
+	indexedAtMapping.IncludeInAll = false
+	m.DefaultMapping.AddFieldMappingsAt("IndexedAt", indexedAtMapping)
+	headerMapping := bleve.NewKeywordFieldMapping()
+	headerMapping.IncludeInAll = false
+	m.DefaultMapping.AddFieldMappingsAt(headerTermsField, headerMapping)
+
+	langTextMapping := bleve.NewDocumentMapping()
+	for _, lang := range index.SupportedLanguages {
+		fieldMapping := bleve.NewTextFieldMapping()
+		fieldMapping.Analyzer = lang
+		fieldMapping.IncludeInAll = false
+		langTextMapping.AddFieldMappingsAt(lang, fieldMapping)
+	}
+	m.DefaultMapping.AddSubDocumentMapping(langTextField, langTextMapping)
+	return m
+}
+
 func copyDoc(d *index.Document) *index.Document {
 	dcopy := new(index.Document)
 	*dcopy = *d
+	if d.Headers != nil {
+		dcopy.Headers = make(map[string]string, len(d.Headers))
+		for name, value := range d.Headers {
+			dcopy.Headers[name] = value
+		}
+	}
 	return dcopy
 }
 
 func makeBleveDoc(d *index.Document) bleveDoc {
 	return bleveDoc{
-		Title:    d.Title,
-		Content:  d.Content,
-		PageRank: d.PageRank,
+		URL:         d.URL,
+		Title:       d.Title,
+		Content:     d.Content,
+		PageRank:    d.PageRank,
+		IndexedAt:   bleveIndexedAt(d),
+		Host:        index.HostOf(d),
+		Language:    d.Language,
+		IndexedDate: index.IndexedDateOf(d),
+		Vertical:    d.Vertical,
+		HeaderTerms: index.HeaderTermsOf(d),
+		LangText:    makeLangText(d),
+	}
+}
+
+// bleveIndexedAt returns the indexing time of d or nil if d has not been
+// indexed yet.
+func bleveIndexedAt(d *index.Document) *time.Time {
+	if d.IndexedAt.IsZero() {
+		return nil
+	}
+	indexedAt := d.IndexedAt
+	return &indexedAt
+}
+
+// makeLangText returns the text to index with the analyzer for the language
+// of d or nil if the language is not supported.
+func makeLangText(d *index.Document) map[string]string {
+	if !index.IsSupportedLanguage(d.Language) {
+		return nil
 	}
+	return map[string]string{d.Language: d.Title + "\n" + d.Content}
 }
@@ -1,9 +1,15 @@
 package memory
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"testing"
+	"webcrawler/crawler/textindexer/index"
 	"webcrawler/crawler/textindexer/index/indextest"
+	"webcrawler/namespace"
 
+	"github.com/google/uuid"
 	gc "gopkg.in/check.v1"
 )
 
@@ -26,3 +32,110 @@ func (s *InMemoryBleveTestSuite) SetUpTest(c *gc.C) {
 func (s *InMemoryBleveTestSuite) TearDownTest(c *gc.C) {
 	c.Assert(s.idx.Close(), gc.IsNil)
 }
+
+func (s *InMemoryBleveTestSuite) TestNamespace(c *gc.C) {
+	doc := &index.Document{LinkID: uuid.New(), URL: "http://example.com"}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+	c.Assert(doc.Namespace, gc.Equals, namespace.Default)
+
+	idx, err := NewInMemoryBleveIndexerWithConfig(Config{Namespace: "crawl-1"})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(idx.Close(), gc.IsNil) }()
+
+	c.Assert(idx.Index(doc), gc.IsNil)
+	got, err := idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Namespace, gc.Equals, "crawl-1")
+
+	_, err = NewInMemoryBleveIndexerWithConfig(Config{Namespace: "crawl 1"})
+	c.Assert(errors.Is(err, namespace.ErrInvalid), gc.Equals, true)
+}
+
+func (s *InMemoryBleveTestSuite) TestCustomFieldBoosts(c *gc.C) {
+	idx, err := NewInMemoryBleveIndexerWithConfig(Config{FieldBoosts: index.FieldBoosts{index.FieldTitle: 1}})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(idx.Close(), gc.IsNil) }()
+
+	titleMatch := &index.Document{LinkID: uuid.New(), Title: "Lorem"}
+	contentMatch := &index.Document{LinkID: uuid.New(), Title: "Ipsum", Content: "lorem"}
+	c.Assert(idx.Index(titleMatch), gc.IsNil)
+	c.Assert(idx.Index(contentMatch), gc.IsNil)
+
+	// Only the boosted fields are searched.
+	it, err := idx.Search(index.Query{Expression: "lorem"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Document().LinkID, gc.Equals, titleMatch.LinkID)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Close(), gc.IsNil)
+
+	_, err = NewInMemoryBleveIndexerWithConfig(Config{FieldBoosts: index.FieldBoosts{}})
+	c.Assert(err, gc.ErrorMatches, "in-memory indexer: field boosts: at least one field must be searched")
+}
+
+func (s *InMemoryBleveTestSuite) TestCustomPaging(c *gc.C) {
+	idx, err := NewInMemoryBleveIndexerWithConfig(Config{BatchSize: 2, MaxOffset: 3})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(idx.Close(), gc.IsNil) }()
+
+	for i := 0; i < 5; i++ {
+		c.Assert(idx.Index(&index.Document{LinkID: uuid.New(), Title: "Lorem"}), gc.IsNil)
+	}
+
+	// Results are fetched in batches of 2 documents.
+	it, err := idx.Search(index.Query{Expression: "lorem", Offset: 3})
+	c.Assert(err, gc.IsNil)
+	var count int
+	for it.Next() {
+		count++
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(count, gc.Equals, 2)
+	c.Assert(it.Close(), gc.IsNil)
+
+	_, err = idx.Search(index.Query{Expression: "lorem", Offset: 4})
+	c.Assert(err, gc.ErrorMatches, "search: offset 4 exceeds the maximum offset of 3")
+
+	_, err = NewInMemoryBleveIndexerWithConfig(Config{BatchSize: index.MaxBatchSize + 1})
+	c.Assert(err, gc.ErrorMatches, "in-memory indexer: batch size must be between 1 and 1000 \\(got 1001\\)")
+}
+
+func (s *InMemoryBleveTestSuite) TestDumpAndRestore(c *gc.C) {
+	// Index enough documents for the restored documents to span multiple
+	// batches.
+	var linkIDs []uuid.UUID
+	for i := 0; i <= restoreBatchSize; i++ {
+		doc := &index.Document{LinkID: uuid.New(), URL: fmt.Sprintf("http://example.com/%d", i), Title: "Lorem", Content: "ipsum"}
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+		linkIDs = append(linkIDs, doc.LinkID)
+	}
+	c.Assert(s.idx.UpdateScore(linkIDs[0], 0.5), gc.IsNil)
+
+	var buf bytes.Buffer
+	c.Assert(s.idx.Dump(&buf), gc.IsNil)
+	restored, err := NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(restored.Close(), gc.IsNil) }()
+	c.Assert(restored.Restore(&buf), gc.IsNil)
+
+	for _, linkID := range linkIDs {
+		exp, err := s.idx.FindByID(linkID)
+		c.Assert(err, gc.IsNil)
+		got, err := restored.FindByID(linkID)
+		c.Assert(err, gc.IsNil)
+		c.Assert(got, gc.DeepEquals, exp)
+	}
+
+	// The restored documents are searchable.
+	it, err := restored.Search(index.Query{Expression: "lorem"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.TotalCount(), gc.Equals, uint64(len(linkIDs)))
+	c.Assert(it.Close(), gc.IsNil)
+
+	// Dumps can only be restored to indexers of the same namespace.
+	c.Assert(s.idx.Dump(&buf), gc.IsNil)
+	other, err := NewInMemoryBleveIndexerWithConfig(Config{Namespace: "crawl-1"})
+	c.Assert(err, gc.IsNil)
+	defer func() { c.Assert(other.Close(), gc.IsNil) }()
+	c.Assert(other.Restore(&buf), gc.ErrorMatches, `restore: dumped index belongs to namespace "default"`)
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/namespace"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+)
+
+// Compile-time check to ensure DiskBleveIndexer implements Indexer.
+var _ index.Indexer = (*DiskBleveIndexer)(nil)
+
+// The maximum time to wait for the lock on the index files of another
+// process to be released.
+const diskOpenTimeout = "5s"
+
+// DiskBleveIndexer is an Indexer implementation that keeps its bleve index
+// and the contents of the indexed documents on disk so that they survive
+// restarts. The index files can only be opened by a single process at a
+// time.
+type DiskBleveIndexer struct {
+	*InMemoryBleveIndexer
+
+	closeMu sync.Mutex
+	closed  bool
+}
+
+// NewDiskBleveIndexer opens the bleve index at path or creates it if path
+// does not exist.
+func NewDiskBleveIndexer(path string) (*DiskBleveIndexer, error) {
+	return NewDiskBleveIndexerWithConfig(path, Config{})
+}
+
+// NewDiskBleveIndexerWithConfig opens the bleve index at path or creates it
+// if path does not exist using the settings in cfg. New indexes use the same
+// mapping as the in-memory indexer; existing indexes keep the mapping they
+// were created with.
+func NewDiskBleveIndexerWithConfig(path string, cfg Config) (*DiskBleveIndexer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("disk indexer: index path has not been specified")
+	}
+	ns := namespace.OrDefault(cfg.Namespace)
+	if err := namespace.Validate(ns); err != nil {
+		return nil, fmt.Errorf("disk indexer: %w", err)
+	}
+	boosts, err := cfg.fieldBoosts()
+	if err != nil {
+		return nil, fmt.Errorf("disk indexer: %w", err)
+	}
+	batchSize, err := cfg.batchSize()
+	if err != nil {
+		return nil, fmt.Errorf("disk indexer: %w", err)
+	}
+
+	runtimeCfg := map[string]interface{}{"bolt_timeout": diskOpenTimeout}
+	idx, err := bleve.OpenUsing(path, runtimeCfg)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		idx, err = bleve.NewUsing(path, newIndexMapping(), scorch.Name, bleve.Config.DefaultKVStore, runtimeCfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("disk indexer: %w", err)
+	}
+
+	return &DiskBleveIndexer{
+		InMemoryBleveIndexer: &InMemoryBleveIndexer{
+			idx:       idx,
+			ns:        ns,
+			boosts:    boosts,
+			batchSize: batchSize,
+			maxOffset: cfg.maxOffset(),
+		},
+	}, nil
+}
+
+// Compact merges the segments of the index into a single segment and
+// reclaims the space used by deleted and updated documents. Scorch merges
+// segments in the background as documents are indexed, so compacting is
+// only worthwhile after large batches of updates. It blocks until the merge
+// completes or ctx expires.
+func (i *DiskBleveIndexer) Compact(ctx context.Context) error {
+	i.closeMu.Lock()
+	defer i.closeMu.Unlock()
+	if i.closed {
+		return fmt.Errorf("compact: %w", bleve.ErrorIndexClosed)
+	}
+
+	internal, err := i.idx.Advanced()
+	if err != nil {
+		return fmt.Errorf("compact: %w", err)
+	}
+	s, ok := internal.(*scorch.Scorch)
+	if !ok {
+		return fmt.Errorf("compact: unsupported index type %T", internal)
+	}
+	if err := s.ForceMerge(ctx, nil); err != nil {
+		return fmt.Errorf("compact: %w", err)
+	}
+	return ctx.Err()
+}
+
+// Close flushes any pending writes to disk and releases the index files.
+// Closing an already closed indexer is a no-op.
+func (i *DiskBleveIndexer) Close() error {
+	i.closeMu.Lock()
+	defer i.closeMu.Unlock()
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.idx.Close()
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/crawler/textindexer/index/indextest"
+	"webcrawler/namespace"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(DiskBleveTestSuite))
+
+type DiskBleveTestSuite struct {
+	indextest.SuiteBase
+	path string
+	idx  *DiskBleveIndexer
+}
+
+func (s *DiskBleveTestSuite) SetUpTest(c *gc.C) {
+	s.path = filepath.Join(c.MkDir(), "index.bleve")
+	idx, err := NewDiskBleveIndexer(s.path)
+	c.Assert(err, gc.IsNil)
+	s.SetIndexer(idx)
+	s.idx = idx
+}
+
+func (s *DiskBleveTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+}
+
+func (s *DiskBleveTestSuite) TestPersistence(c *gc.C) {
+	doc := &index.Document{
+		LinkID:  uuid.New(),
+		URL:     "http://example.com",
+		Title:   "Persistent index",
+		Content: "The quick brown fox survives a restart",
+		Headers: map[string]string{"x-generator": "hugo"},
+	}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+	c.Assert(s.idx.UpdateScore(doc.LinkID, 0.5), gc.IsNil)
+	c.Assert(s.idx.Close(), gc.IsNil)
+
+	idx, err := NewDiskBleveIndexer(s.path)
+	c.Assert(err, gc.IsNil)
+	s.idx = idx
+
+	got, err := idx.FindByID(doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Title, gc.Equals, doc.Title)
+	c.Assert(got.Headers, gc.DeepEquals, doc.Headers)
+	c.Assert(got.PageRank, gc.Equals, 0.5)
+	c.Assert(got.IndexedAt.Equal(doc.IndexedAt), gc.Equals, true)
+
+	it, err := idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "fox"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.TotalCount(), gc.Equals, uint64(1))
+	c.Assert(it.Next(), gc.Equals, true)
+	c.Assert(it.Document().LinkID, gc.Equals, doc.LinkID)
+	c.Assert(it.Close(), gc.IsNil)
+}
+
+func (s *DiskBleveTestSuite) TestCompact(c *gc.C) {
+	linkID := uuid.New()
+	for i := 0; i < 20; i++ {
+		c.Assert(s.idx.Index(&index.Document{LinkID: linkID, URL: "http://example.com", Content: "revision"}), gc.IsNil)
+	}
+	c.Assert(s.idx.Compact(context.TODO()), gc.IsNil)
+
+	got, err := s.idx.FindByID(linkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.Content, gc.Equals, "revision")
+}
+
+func (s *DiskBleveTestSuite) TestUpdateScoresPartialFailure(c *gc.C) {
+	okID, corruptID := uuid.New(), uuid.New()
+	c.Assert(s.idx.idx.SetInternal(docInternalKey(corruptID.String()), []byte("{")), gc.IsNil)
+
+	err := s.idx.UpdateScores(map[uuid.UUID]float64{okID: 0.1, corruptID: 0.2})
+	var updateErr *index.ScoreUpdateError
+	c.Assert(errors.As(err, &updateErr), gc.Equals, true)
+	c.Assert(updateErr.Failed, gc.HasLen, 1)
+	c.Assert(updateErr.Failed[corruptID], gc.ErrorMatches, "malformed document .*")
+
+	// The remaining scores are still updated.
+	got, err := s.idx.FindByID(okID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got.PageRank, gc.Equals, 0.1)
+}
+
+func (s *DiskBleveTestSuite) TestClose(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+	c.Assert(s.idx.Close(), gc.IsNil)
+	c.Assert(s.idx.Compact(context.TODO()), gc.ErrorMatches, "compact: .*closed.*")
+	c.Assert(s.idx.Index(&index.Document{LinkID: uuid.New()}), gc.ErrorMatches, "index: .*closed.*")
+}
+
+func (s *DiskBleveTestSuite) TestInvalidConfig(c *gc.C) {
+	_, err := NewDiskBleveIndexer("")
+	c.Assert(err, gc.ErrorMatches, "disk indexer: index path has not been specified")
+
+	_, err = NewDiskBleveIndexerWithConfig(filepath.Join(c.MkDir(), "index.bleve"), Config{Namespace: "crawl 1"})
+	c.Assert(errors.Is(err, namespace.ErrInvalid), gc.Equals, true)
+}
@@ -2,21 +2,56 @@ package memory
 
 import (
 	"sync"
+	"time"
 	"webcrawler/crawler/textindexer/index"
 
 	"github.com/blevesearch/bleve/v2"
 )
 
 type bleveDoc struct {
+	URL      string
 	Title    string
 	Content  string
 	PageRank float64
+
+	// The indexing time used for freshness-aware ranking. It is nil for
+	// placeholder documents that have not been indexed yet.
+	IndexedAt *time.Time
+
+	// Keyword fields used for computing facets.
+	Host        string
+	Language    string
+	IndexedDate string
+	Vertical    string
+
+	// The captured response headers as lower-case "name=value" keyword
+	// terms.
+	HeaderTerms []string
+
+	// The document text keyed by language. Each entry is indexed using
+	// the analyzer for its language.
+	LangText map[string]string
 }
 
 // InMemoryBleveIndexer is an Indexer implementation that uses an in-memory
 // bleve instance to catalogue and search documents.
 type InMemoryBleveIndexer struct {
-	mu   sync.RWMutex
+	mu sync.RWMutex
+	ns string
+
+	// The boosts of the fields searched by queries that do not target a
+	// specific field.
+	boosts index.FieldBoosts
+
+	// The number of results fetched per search request and the largest
+	// accepted query offset.
+	batchSize int
+	maxOffset uint64
+
+	// The contents of the indexed documents keyed by link ID. Bleve only
+	// stores the indexed fields. The map is nil for indexers whose
+	// documents are persisted alongside the bleve index (see
+	// DiskBleveIndexer).
 	docs map[string]*index.Document
 
 	idx bleve.Index
@@ -15,8 +15,10 @@ type bleveIterator struct {
 	rsIdx  int
 	rs     *bleve.SearchResult
 
-	latchedDoc *index.Document
-	lastErr    error
+	facets      []index.Facet
+	suggestions []index.Suggestion
+	latchedDoc  *index.Document
+	lastErr     error
 }
 
 // Close the iterator and release any allocated resources.
@@ -73,3 +75,97 @@ func (it *bleveIterator) TotalCount() uint64 {
 	}
 	return it.rs.Total
 }
+
+// MaxScore returns the highest relevance score among the search results.
+func (it *bleveIterator) MaxScore() float64 {
+	if it.rs == nil {
+		return 0
+	}
+	return it.rs.MaxScore
+}
+
+// Facets returns the aggregations requested by the search query.
+func (it *bleveIterator) Facets() []index.Facet {
+	return it.facets
+}
+
+// Suggestions returns corrected versions of the search expression if it
+// matched few documents.
+func (it *bleveIterator) Suggestions() []index.Suggestion {
+	return it.suggestions
+}
+
+// bleveAllIterator implements index.DocumentIterator. Documents are fetched
+// in batches sorted by document ID and each batch resumes after the ID of
+// the last document in the previous batch.
+type bleveAllIterator struct {
+	idx       *InMemoryBleveIndexer
+	searchReq *bleve.SearchRequest
+
+	rsIdx int
+	rs    *bleve.SearchResult
+
+	latchedDoc *index.Document
+	lastErr    error
+}
+
+// Close the iterator and release any allocated resources.
+func (it *bleveAllIterator) Close() error {
+	it.idx = nil
+	it.searchReq = nil
+	it.rs = nil
+	return nil
+}
+
+// Next loads the next document. It returns false if no more documents are
+// available.
+func (it *bleveAllIterator) Next() bool {
+	if it.lastErr != nil || it.idx == nil {
+		return false
+	}
+
+	// Do we need to fetch the next batch?
+	if it.rs == nil || it.rsIdx >= it.rs.Hits.Len() {
+		if it.rs != nil {
+			// A short batch means that there are no more documents.
+			if it.rs.Hits.Len() < it.searchReq.Size {
+				return false
+			}
+			it.searchReq.SetSearchAfter([]string{it.rs.Hits[it.rs.Hits.Len()-1].ID})
+		}
+		if it.rs, it.lastErr = it.idx.idx.Search(it.searchReq); it.lastErr != nil {
+			return false
+		}
+
+		it.rsIdx = 0
+		if it.rs.Hits.Len() == 0 {
+			return false
+		}
+	}
+
+	nextID := it.rs.Hits[it.rsIdx].ID
+	if it.latchedDoc, it.lastErr = it.idx.findByID(nextID); it.lastErr != nil {
+		return false
+	}
+
+	it.rsIdx++
+	return true
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *bleveAllIterator) Error() error {
+	return it.lastErr
+}
+
+// Document returns the current document.
+func (it *bleveAllIterator) Document() *index.Document {
+	return it.latchedDoc
+}
+
+// Cursor returns a cursor for the current document.
+func (it *bleveAllIterator) Cursor() index.Cursor {
+	if it.latchedDoc == nil {
+		return ""
+	}
+	return index.CursorFor(it.latchedDoc.LinkID)
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"webcrawler/crawler/textindexer/index"
+)
+
+const (
+	// dumpFormatVersion is the version of the format written by Dump.
+	dumpFormatVersion = 1
+
+	// restoreBatchSize is the number of documents that Restore writes to
+	// the index per batch.
+	restoreBatchSize = 500
+)
+
+// dumpHeader is the first value of a dumped index.
+type dumpHeader struct {
+	Version   int
+	Namespace string
+}
+
+// Dump writes the documents of the indexer to w as a gob stream that can be
+// restored with Restore. Documents are read in batches, so documents that are
+// indexed while the dump is in progress may or may not be included.
+func (i *InMemoryBleveIndexer) Dump(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(dumpHeader{Version: dumpFormatVersion, Namespace: i.ns}); err != nil {
+		return fmt.Errorf("dump: %w", err)
+	}
+
+	it, err := i.All("")
+	if err != nil {
+		return fmt.Errorf("dump: %w", err)
+	}
+	defer func() { _ = it.Close() }()
+	for it.Next() {
+		if err = enc.Encode(it.Document()); err != nil {
+			return fmt.Errorf("dump: %w", err)
+		}
+	}
+	if err = it.Error(); err != nil {
+		return fmt.Errorf("dump: %w", err)
+	}
+	return nil
+}
+
+// Restore indexes the documents read from r, which must have been written by
+// Dump. Indexed documents with the same link IDs are replaced. Unlike Index,
+// Restore preserves the indexing time and PageRank score of each document.
+func (i *InMemoryBleveIndexer) Restore(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	var hdr dumpHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if hdr.Version != dumpFormatVersion {
+		return fmt.Errorf("restore: unsupported format version %d", hdr.Version)
+	}
+	if hdr.Namespace != i.ns {
+		return fmt.Errorf("restore: dumped index belongs to namespace %q", hdr.Namespace)
+	}
+
+	docs := make(map[string]*index.Document, restoreBatchSize)
+	for {
+		doc := new(index.Document)
+		if err := dec.Decode(doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("restore: %w", err)
+		}
+		docs[doc.LinkID.String()] = doc
+
+		if len(docs) == restoreBatchSize {
+			if err := i.restoreBatch(docs); err != nil {
+				return fmt.Errorf("restore: %w", err)
+			}
+			docs = make(map[string]*index.Document, restoreBatchSize)
+		}
+	}
+	if err := i.restoreBatch(docs); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	return nil
+}
+
+// restoreBatch writes a batch of restored documents to the index.
+func (i *InMemoryBleveIndexer) restoreBatch(docs map[string]*index.Document) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.putDocs(docs)
+}
@@ -0,0 +1,275 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/blevesearch/bleve/v2"
+
+	// Register the analyzers for index.SupportedLanguages.
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/de"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/en"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/es"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/it"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// makeBleveBooleanQuery translates a parsed boolean query into its equivalent
+// bleve query representation. Terms and phrases that do not target a
+// specific field are matched against the fields in boosts.
+func makeBleveBooleanQuery(node *index.QueryNode, boosts index.FieldBoosts) query.Query {
+	switch node.Type {
+	case index.QueryNodeAnd:
+		return bleve.NewConjunctionQuery(makeBleveBooleanQueryList(node.Children, boosts)...)
+	case index.QueryNodeOr:
+		return bleve.NewDisjunctionQuery(makeBleveBooleanQueryList(node.Children, boosts)...)
+	case index.QueryNodeNot:
+		return query.NewBooleanQuery(nil, nil, makeBleveBooleanQueryList(node.Children, boosts))
+	case index.QueryNodePhrase:
+		if name, isHeader := index.HeaderName(node.Field); isHeader {
+			return makeBleveHeaderQuery(name, node.Text)
+		}
+		if node.Field == "" {
+			return makeBleveTextQuery(node.Type, node.Text, boosts)
+		}
+		q := bleve.NewMatchPhraseQuery(node.Text)
+		q.SetField(node.Field)
+		return q
+	default:
+		if name, isHeader := index.HeaderName(node.Field); isHeader {
+			return makeBleveHeaderQuery(name, node.Text)
+		}
+		if node.Field == "" {
+			return makeBleveTextQuery(node.Type, node.Text, boosts)
+		}
+		q := bleve.NewMatchQuery(node.Text)
+		q.SetField(node.Field)
+		return q
+	}
+}
+
+// The name of the bleveDoc field that holds the language-specific text.
+const langTextField = "LangText"
+
+// The name of the bleveDoc field that holds the captured header terms.
+const headerTermsField = "HeaderTerms"
+
+// makeBleveFilterQueries returns a query for each criterion set in f.
+// Documents match a domain if their host is the domain itself or one of its
+// subdomains.
+func makeBleveFilterQueries(f index.Filters) []query.Query {
+	var queries []query.Query
+	if len(f.Domains) != 0 {
+		var domainQueries []query.Query
+		for _, domain := range f.Domains {
+			hostQuery := bleve.NewTermQuery(domain)
+			hostQuery.SetField(bleveFacetFields[index.FacetHost])
+			subdomainQuery := bleve.NewWildcardQuery("*." + domain)
+			subdomainQuery.SetField(bleveFacetFields[index.FacetHost])
+			domainQueries = append(domainQueries, hostQuery, subdomainQuery)
+		}
+		queries = append(queries, bleve.NewDisjunctionQuery(domainQueries...))
+	}
+	if !f.IndexedAfter.IsZero() || !f.IndexedBefore.IsZero() {
+		inclusiveStart, inclusiveEnd := true, false
+		q := bleve.NewDateRangeInclusiveQuery(f.IndexedAfter, f.IndexedBefore, &inclusiveStart, &inclusiveEnd)
+		q.SetField("IndexedAt")
+		queries = append(queries, q)
+	}
+	if len(f.Languages) != 0 {
+		var langQueries []query.Query
+		for _, lang := range f.Languages {
+			q := bleve.NewTermQuery(lang)
+			q.SetField(bleveFacetFields[index.FacetLanguage])
+			langQueries = append(langQueries, q)
+		}
+		queries = append(queries, bleve.NewDisjunctionQuery(langQueries...))
+	}
+	if f.MinPageRank > 0 {
+		inclusiveMin := true
+		q := bleve.NewNumericRangeInclusiveQuery(&f.MinPageRank, nil, &inclusiveMin, nil)
+		q.SetField("PageRank")
+		queries = append(queries, q)
+	}
+	return queries
+}
+
+// makeBleveHeaderQuery returns a query that matches documents whose captured
+// header with the specified name contains text.
+func makeBleveHeaderQuery(name, text string) query.Query {
+	q := bleve.NewWildcardQuery(index.HeaderTermPattern(name, text))
+	q.SetField(headerTermsField)
+	return q
+}
+
+// makeBleveTextQuery returns a match (or phrase match) query for text that
+// targets each of the fields in boosts and, if the content is searched, each
+// of the language-specific text fields. The latter hold both the title and
+// the content and are analyzed with a language analyzer; they therefore also
+// match inflected forms of the query terms and use the content boost.
+func makeBleveTextQuery(nodeType index.QueryNodeType, text string, boosts index.FieldBoosts) query.Query {
+	newQuery := func(field string, boost float64) query.Query {
+		if nodeType == index.QueryNodePhrase {
+			q := bleve.NewMatchPhraseQuery(text)
+			q.SetField(field)
+			q.SetBoost(boost)
+			return q
+		}
+		q := bleve.NewMatchQuery(text)
+		q.SetField(field)
+		q.SetBoost(boost)
+		return q
+	}
+
+	var list []query.Query
+	for _, field := range boosts.Fields() {
+		list = append(list, newQuery(field, boosts[field]))
+	}
+	if boost, ok := boosts[index.FieldContent]; ok {
+		for _, lang := range index.SupportedLanguages {
+			list = append(list, newQuery(langTextField+"."+lang, boost))
+		}
+	}
+	return bleve.NewDisjunctionQuery(list...)
+}
+
+func makeBleveBooleanQueryList(nodes []*index.QueryNode, boosts index.FieldBoosts) []query.Query {
+	list := make([]query.Query, len(nodes))
+	for i, node := range nodes {
+		list[i] = makeBleveBooleanQuery(node, boosts)
+	}
+	return list
+}
+
+// bleveFacetFields maps each facet field to the bleveDoc field that holds its
+// value.
+var bleveFacetFields = map[index.FacetField]string{
+	index.FacetHost:        "Host",
+	index.FacetLanguage:    "Language",
+	index.FacetIndexedDate: "IndexedDate",
+	index.FacetVertical:    "Vertical",
+}
+
+// mapBleveFacets converts the facet results returned by bleve into the facets
+// requested by the query.
+func mapBleveFacets(facets []index.FacetRequest, results search.FacetResults) []index.Facet {
+	if len(facets) == 0 {
+		return nil
+	}
+
+	out := make([]index.Facet, len(facets))
+	for fIdx, facet := range facets {
+		out[fIdx].Field = facet.Field
+		res := results[bleveFacetName(fIdx)]
+		if res == nil || res.Terms == nil {
+			continue
+		}
+		for _, term := range res.Terms.Terms() {
+			// Placeholder documents created by UpdateScore have no
+			// facet values.
+			if term.Term == "" {
+				continue
+			}
+			out[fIdx].Buckets = append(out[fIdx].Buckets, index.FacetBucket{Value: term.Term, Count: uint64(term.Count)})
+		}
+	}
+	return out
+}
+
+func bleveFacetName(facetIndex int) string {
+	return fmt.Sprintf("facet_%d", facetIndex)
+}
+
+// bleveSuggestFields lists the fields whose term dictionaries are searched for
+// spelling corrections. Both fields use the standard analyzer so their terms
+// are not stemmed.
+var bleveSuggestFields = []string{index.FieldTitle, index.FieldContent}
+
+// suggest returns corrected versions of expr. Bleve does not provide a term
+// suggester; instead, the dictionary entries that share the first letter of
+// each search term are scanned for fuzzy matches that occur in more
+// documents than the term itself. Matches are ranked by their similarity to
+// the term and then by document frequency.
+func suggest(idx bleve.Index, expr string) ([]index.Suggestion, error) {
+	corrections := make(map[string][]index.TermCorrection)
+	for _, term := range index.SuggestTerms(expr) {
+		candidates, err := fuzzyTerms(idx, term)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) != 0 {
+			corrections[term] = candidates
+		}
+	}
+	return index.BuildSuggestions(expr, corrections), nil
+}
+
+// fuzzyTerms returns up to index.MaxSuggestions corrections for term sorted by
+// descending score.
+func fuzzyTerms(idx bleve.Index, term string) ([]index.TermCorrection, error) {
+	maxEdits := 2
+	if utf8.RuneCountInString(term) <= 4 {
+		maxEdits = 1
+	}
+
+	freqs := make(map[string]uint64)
+	_, firstRuneLen := utf8.DecodeRuneInString(term)
+	for _, field := range bleveSuggestFields {
+		dict, err := idx.FieldDictPrefix(field, []byte(term[:firstRuneLen]))
+		if err != nil {
+			return nil, err
+		}
+		for {
+			entry, err := dict.Next()
+			if err != nil {
+				_ = dict.Close()
+				return nil, err
+			} else if entry == nil {
+				break
+			}
+			if _, exceeded := search.LevenshteinDistanceMax(term, entry.Term, maxEdits); !exceeded {
+				freqs[entry.Term] += entry.Count
+			}
+		}
+		if err = dict.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	type candidate struct {
+		index.TermCorrection
+		freq uint64
+	}
+	var candidates []candidate
+	for t, freq := range freqs {
+		if t == term || freq <= freqs[term] {
+			continue
+		}
+		dist := search.LevenshteinDistance(term, t)
+		candidates = append(candidates, candidate{
+			TermCorrection: index.TermCorrection{Term: t, Score: 1 - float64(dist)/float64(len(term))},
+			freq:           freq,
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].freq != candidates[j].freq {
+			return candidates[i].freq > candidates[j].freq
+		}
+		return candidates[i].Term < candidates[j].Term
+	})
+
+	var corrections []index.TermCorrection
+	for i := 0; i < len(candidates) && i < index.MaxSuggestions; i++ {
+		corrections = append(corrections, candidates[i].TermCorrection)
+	}
+	return corrections, nil
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"time"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/blevesearch/bleve/v2/numeric"
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+// Compile-time check to ensure rankingSort implements search.SearchSort.
+var _ search.SearchSort = (*rankingSort)(nil)
+
+// rankingSort is a bleve sort order that ranks the search results by the
+// score that index.Ranking computes from their relevance score, PageRank
+// score and indexing time.
+type rankingSort struct {
+	ranking index.Ranking
+	origin  time.Time
+	desc    bool
+
+	// The field values of the document being sorted.
+	pageRank  float64
+	indexedAt time.Time
+}
+
+// newRankingSort returns a descending rankingSort. The freshness of the
+// documents is measured against the origin of r or, if not set, the current
+// time so that it remains the same while paging through the results.
+func newRankingSort(r index.Ranking) *rankingSort {
+	origin := r.Origin
+	if origin.IsZero() {
+		origin = time.Now()
+	}
+	return &rankingSort{ranking: r, origin: origin, desc: true}
+}
+
+// UpdateVisitor records the PageRank score and indexing time of the document
+// being sorted.
+func (s *rankingSort) UpdateVisitor(field string, term []byte) {
+	// Numeric and date-time fields are also indexed at lower precisions
+	// to speed up range queries; only the full precision term is needed.
+	if valid, shift := numeric.ValidPrefixCodedTermBytes(term); !valid || shift != 0 {
+		return
+	}
+	i64, err := numeric.PrefixCoded(term).Int64()
+	if err != nil {
+		return
+	}
+	switch field {
+	case "PageRank":
+		s.pageRank = numeric.Int64ToFloat64(i64)
+	case "IndexedAt":
+		s.indexedAt = time.Unix(0, i64)
+	}
+}
+
+// Value returns the ranking score of the document encoded so that scores
+// sort in lexicographic order. It also resets the recorded field values for
+// processing the next document.
+func (s *rankingSort) Value(d *search.DocumentMatch) string {
+	score := s.ranking.Score(d.Score, s.pageRank, s.indexedAt, s.origin)
+	s.pageRank, s.indexedAt = 0, time.Time{}
+	return string(numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(score), 0))
+}
+
+// Descending returns true if higher scores are ranked first.
+func (s *rankingSort) Descending() bool { return s.desc }
+
+// RequiresDocID returns false as the score does not depend on the document ID.
+func (s *rankingSort) RequiresDocID() bool { return false }
+
+// RequiresScoring returns false. Bleve interprets a true value as sorting
+// by the relevance score alone; the relevance score is computed regardless.
+func (s *rankingSort) RequiresScoring() bool { return false }
+
+// RequiresFields returns the fields whose values are needed for the score.
+func (s *rankingSort) RequiresFields() []string { return []string{"PageRank", "IndexedAt"} }
+
+// Reverse flips the sort direction.
+func (s *rankingSort) Reverse() { s.desc = !s.desc }
+
+// Copy returns a copy of the sort order.
+func (s *rankingSort) Copy() search.SearchSort {
+	return &rankingSort{ranking: s.ranking, origin: s.origin, desc: s.desc}
+}
@@ -0,0 +1,95 @@
+package tiered
+
+import (
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+)
+
+// tieredIterator serves search results from the hot index. If the consumer
+// iterates past the end of the hot results, the iterator falls back to the
+// full index and skips the documents that were already served.
+type tieredIterator struct {
+	full  index.Indexer
+	query index.Query
+
+	cur        index.Iterator
+	fellBack   bool
+	seen       map[uuid.UUID]struct{}
+	totalCount uint64
+	err        error
+}
+
+// Close the iterator and release any allocated resources.
+func (it *tieredIterator) Close() error {
+	return it.cur.Close()
+}
+
+// Next loads the next document matching the search query.
+func (it *tieredIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for {
+		if it.cur.Next() {
+			linkID := it.cur.Document().LinkID
+			if _, seen := it.seen[linkID]; seen {
+				continue
+			}
+			if !it.fellBack {
+				it.seen[linkID] = struct{}{}
+			}
+			return true
+		}
+
+		if it.err = it.cur.Error(); it.err != nil || it.fellBack {
+			return false
+		}
+
+		fullIt, err := it.full.Search(it.query)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		_ = it.cur.Close()
+		it.cur, it.fellBack = fullIt, true
+	}
+}
+
+// Error returns the last error encountered by the iterator.
+func (it *tieredIterator) Error() error {
+	return it.err
+}
+
+// Document returns the current document from the result set.
+func (it *tieredIterator) Document() *index.Document {
+	return it.cur.Document()
+}
+
+// TotalCount returns the approximate number of search results. While the
+// results are served by the hot index, the count is estimated from the
+// number of hot index matches.
+func (it *tieredIterator) TotalCount() uint64 {
+	if it.fellBack {
+		return it.cur.TotalCount()
+	}
+	return it.totalCount
+}
+
+// MaxScore returns the highest relevance score among the search results.
+func (it *tieredIterator) MaxScore() float64 {
+	return it.cur.MaxScore()
+}
+
+// Facets returns the aggregations requested by the search query. Queries
+// with facets are always served by the full index.
+func (it *tieredIterator) Facets() []index.Facet {
+	return it.cur.Facets()
+}
+
+// Suggestions returns corrected versions of the search expression computed
+// by the index that currently serves the results.
+func (it *tieredIterator) Suggestions() []index.Suggestion {
+	return it.cur.Suggestions()
+}
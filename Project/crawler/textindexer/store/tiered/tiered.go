@@ -0,0 +1,379 @@
+// Package tiered provides a two-tier text indexer. All documents are stored
+// in a full index while the documents with the highest PageRank scores are
+// additionally stored in a small hot index. Searches are served by the hot
+// index whenever it contains enough matches and only fall back to the full
+// index when needed.
+package tiered
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Compile-time check to ensure Indexer implements index.Indexer.
+var _ index.Indexer = (*Indexer)(nil)
+
+// Config encapsulates the settings for a tiered indexer.
+type Config struct {
+	// The index that stores every document.
+	Full index.Indexer
+
+	// A function for creating an empty hot index. A new hot index is
+	// created each time the tiers are rebalanced.
+	NewHotIndex func() (index.Indexer, error)
+
+	// The fraction of documents (by PageRank) to keep in the hot index;
+	// e.g. 0.1 keeps the top 10% of the documents.
+	HotFraction float64
+
+	// The minimum number of matches that the hot index must return past
+	// the query offset for a search to be served by the hot index.
+	// Defaults to 10.
+	MinHotResults uint64
+
+	// An optional logger for reporting rebalances. If not specified,
+	// nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.Full == nil {
+		err = multierror.Append(err, fmt.Errorf("full index has not been provided"))
+	}
+	if cfg.NewHotIndex == nil {
+		err = multierror.Append(err, fmt.Errorf("hot index factory has not been provided"))
+	}
+	if cfg.HotFraction <= 0 || cfg.HotFraction > 1 {
+		err = multierror.Append(err, fmt.Errorf("hot fraction must be in the (0, 1] range"))
+	}
+	if cfg.MinHotResults == 0 {
+		cfg.MinHotResults = 10
+	}
+	return err
+}
+
+// Indexer is an index.Indexer implementation that keeps the documents with
+// the highest PageRank scores in a separate hot index.
+//
+// The hot index remains empty until Rebalance is invoked for the first
+// time. Afterwards, documents whose score is raised above the PageRank
+// threshold computed by the last rebalance are promoted to the hot index
+// immediately while demotions only take effect at the next rebalance.
+type Indexer struct {
+	cfg    Config
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	hot       index.Indexer
+	hotIDs    map[uuid.UUID]struct{}
+	threshold float64
+}
+
+// NewIndexer creates a new tiered indexer using the provided config.
+func NewIndexer(cfg Config) (*Indexer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("tiered indexer: config validation failed: %w", err)
+	}
+
+	return &Indexer{
+		cfg:       cfg,
+		logger:    logging.Component(cfg.Logger, "textindexer.tiered"),
+		hotIDs:    make(map[uuid.UUID]struct{}),
+		threshold: math.Inf(1),
+	}, nil
+}
+
+// Close releases the hot index if it implements io.Closer. The full index
+// is owned by the caller and is not closed.
+func (i *Indexer) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return closeIndex(i.hot)
+}
+
+// Flush flushes the pending writes of the full and the hot index if they
+// implement a Flush method.
+func (i *Indexer) Flush() error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var err error
+	for _, idx := range []index.Indexer{i.cfg.Full, i.hot} {
+		if flusher, ok := idx.(interface{ Flush() error }); ok {
+			if flushErr := flusher.Flush(); flushErr != nil {
+				err = multierror.Append(err, flushErr)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("tiered index: flush: %w", err)
+	}
+	return nil
+}
+
+// Index inserts a new document to the index or updates the index entry for
+// an existing document.
+func (i *Indexer) Index(doc *index.Document) error {
+	if err := i.cfg.Full.Index(doc); err != nil {
+		return err
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if _, isHot := i.hotIDs[doc.LinkID]; !isHot {
+		return nil
+	}
+
+	// Index into the hot tier a copy of the document so that the fields
+	// populated by the full index are not overwritten.
+	hotDoc := new(index.Document)
+	*hotDoc = *doc
+	if err := i.hot.Index(hotDoc); err != nil {
+		return fmt.Errorf("tiered index: hot tier: %w", err)
+	}
+	return nil
+}
+
+// FindByID looks up a document by its link ID.
+func (i *Indexer) FindByID(linkID uuid.UUID) (*index.Document, error) {
+	return i.cfg.Full.FindByID(linkID)
+}
+
+// Search the index for a particular query and return back a result
+// iterator. Queries that request facets are always served by the full index
+// as facets must be computed over the full result set.
+func (i *Indexer) Search(q index.Query) (index.Iterator, error) {
+	i.mu.RLock()
+	hot := i.hot
+	i.mu.RUnlock()
+	if hot == nil || len(q.Facets) != 0 {
+		return i.cfg.Full.Search(q)
+	}
+
+	hotIt, err := hot.Search(q)
+	if err != nil {
+		return nil, err
+	}
+	if hotIt.TotalCount() < q.Offset+i.cfg.MinHotResults {
+		_ = hotIt.Close()
+		return i.cfg.Full.Search(q)
+	}
+
+	return &tieredIterator{
+		full:       i.cfg.Full,
+		query:      q,
+		cur:        hotIt,
+		seen:       make(map[uuid.UUID]struct{}),
+		totalCount: i.estimateTotalCount(hotIt.TotalCount()),
+	}, nil
+}
+
+// Suggest returns up to limit distinct titles that complete prefix. As
+// completions are ordered by PageRank, they are served by the hot index when
+// it yields limit titles and by the full index otherwise.
+func (i *Indexer) Suggest(prefix string, limit int) ([]string, error) {
+	i.mu.RLock()
+	hot := i.hot
+	i.mu.RUnlock()
+	if hot != nil {
+		titles, err := hot.Suggest(prefix, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(titles) >= limit {
+			return titles, nil
+		}
+	}
+	return i.cfg.Full.Suggest(prefix, limit)
+}
+
+// estimateTotalCount estimates the number of full index matches given the
+// number of hot index matches.
+func (i *Indexer) estimateTotalCount(hotCount uint64) uint64 {
+	return uint64(math.Ceil(float64(hotCount) / i.cfg.HotFraction))
+}
+
+// UpdateScore updates the PageRank score for a document with the specified
+// link ID. Documents whose new score reaches the hot tier threshold are
+// promoted to the hot index.
+func (i *Indexer) UpdateScore(linkID uuid.UUID, score float64) error {
+	if err := i.cfg.Full.UpdateScore(linkID, score); err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, isHot := i.hotIDs[linkID]; isHot {
+		if err := i.hot.UpdateScore(linkID, score); err != nil {
+			return fmt.Errorf("tiered update score: hot tier: %w", err)
+		}
+		return nil
+	} else if i.hot == nil || score <= 0 || score < i.threshold {
+		return nil
+	}
+
+	doc, err := i.cfg.Full.FindByID(linkID)
+	if err != nil {
+		return fmt.Errorf("tiered update score: %w", err)
+	}
+	if err = promote(i.hot, doc); err != nil {
+		return fmt.Errorf("tiered update score: hot tier: %w", err)
+	}
+	i.hotIDs[linkID] = struct{}{}
+	return nil
+}
+
+// Patch applies a partial update to the indexed document with the specified
+// link ID.
+func (i *Indexer) Patch(linkID uuid.UUID, patch index.DocumentPatch) error {
+	if err := i.cfg.Full.Patch(linkID, patch); err != nil {
+		return err
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if _, isHot := i.hotIDs[linkID]; !isHot {
+		return nil
+	}
+	if err := i.hot.Patch(linkID, patch); err != nil {
+		return fmt.Errorf("tiered patch: hot tier: %w", err)
+	}
+	return nil
+}
+
+// All returns an iterator over every indexed document in ascending link ID
+// order.
+func (i *Indexer) All(cursor index.Cursor) (index.DocumentIterator, error) {
+	return i.cfg.Full.All(cursor)
+}
+
+// Rebalance rebuilds the hot index so that it contains the top HotFraction
+// of the documents in the full index by PageRank. Documents that are
+// indexed while a rebalance is in progress are picked up by the next
+// rebalance. As the previous hot index is closed, iterators returned by
+// searches that are still in progress may report an error.
+func (i *Indexer) Rebalance() error {
+	startedAt := time.Now()
+	hotIDs, threshold, err := i.selectHotDocs()
+	if err != nil {
+		return fmt.Errorf("tiered rebalance: %w", err)
+	}
+
+	hot, err := i.cfg.NewHotIndex()
+	if err != nil {
+		return fmt.Errorf("tiered rebalance: creating hot index: %w", err)
+	}
+	if err = i.populateHotIndex(hot, hotIDs); err != nil {
+		if closeErr := closeIndex(hot); closeErr != nil {
+			i.logger.Warn("unable to close discarded hot index", "err", closeErr)
+		}
+		return fmt.Errorf("tiered rebalance: %w", err)
+	}
+
+	i.mu.Lock()
+	prevHot := i.hot
+	i.hot, i.hotIDs, i.threshold = hot, hotIDs, threshold
+	i.mu.Unlock()
+	i.logger.Info("rebalanced index tiers", "hot_docs", len(hotIDs), "threshold", threshold, "duration", time.Since(startedAt))
+
+	if err = closeIndex(prevHot); err != nil {
+		return fmt.Errorf("tiered rebalance: closing previous hot index: %w", err)
+	}
+	return nil
+}
+
+// selectHotDocs returns the IDs of the top HotFraction of the documents in
+// the full index by PageRank and the lowest score among them. Documents
+// with a zero score are never selected.
+func (i *Indexer) selectHotDocs() (map[uuid.UUID]struct{}, float64, error) {
+	type scoredDoc struct {
+		linkID uuid.UUID
+		score  float64
+	}
+
+	it, err := i.cfg.Full.All("")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = it.Close() }()
+
+	var docs []scoredDoc
+	for it.Next() {
+		doc := it.Document()
+		docs = append(docs, scoredDoc{linkID: doc.LinkID, score: doc.PageRank})
+	}
+	if err = it.Error(); err != nil {
+		return nil, 0, err
+	}
+
+	// Sort by score and break ties by link ID so that the selection is
+	// deterministic.
+	sort.Slice(docs, func(a, b int) bool {
+		if docs[a].score != docs[b].score {
+			return docs[a].score > docs[b].score
+		}
+		return docs[a].linkID.String() < docs[b].linkID.String()
+	})
+
+	var (
+		hotIDs    = make(map[uuid.UUID]struct{})
+		threshold = math.Inf(1)
+		hotCount  = int(math.Ceil(float64(len(docs)) * i.cfg.HotFraction))
+	)
+	for _, doc := range docs[:hotCount] {
+		if doc.score <= 0 {
+			break
+		}
+		hotIDs[doc.linkID] = struct{}{}
+		threshold = doc.score
+	}
+	return hotIDs, threshold, nil
+}
+
+// populateHotIndex copies the documents with the specified IDs from the full
+// index into hot.
+func (i *Indexer) populateHotIndex(hot index.Indexer, hotIDs map[uuid.UUID]struct{}) error {
+	it, err := i.cfg.Full.All("")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = it.Close() }()
+
+	for it.Next() {
+		doc := it.Document()
+		if _, isHot := hotIDs[doc.LinkID]; !isHot {
+			continue
+		}
+		if err = promote(hot, doc); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// promote copies doc, including its PageRank score, into the hot index.
+func promote(hot index.Indexer, doc *index.Document) error {
+	score := doc.PageRank
+	if err := hot.Index(doc); err != nil {
+		return err
+	}
+	return hot.UpdateScore(doc.LinkID, score)
+}
+
+func closeIndex(idx index.Indexer) error {
+	if closer, ok := idx.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package tiered
+
+import (
+	"fmt"
+	"testing"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/crawler/textindexer/index/indextest"
+	"webcrawler/crawler/textindexer/store/memory"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var (
+	_ = gc.Suite(new(TieredIndexerTestSuite))
+	_ = gc.Suite(new(HotTierTestSuite))
+)
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+// TieredIndexerTestSuite runs the shared indexer tests against a tiered
+// indexer whose hot index has been built from an empty full index.
+type TieredIndexerTestSuite struct {
+	indextest.SuiteBase
+	full *memory.InMemoryBleveIndexer
+	idx  *Indexer
+}
+
+func (s *TieredIndexerTestSuite) SetUpTest(c *gc.C) {
+	s.full, s.idx = newTestIndexer(c, 0.5)
+	c.Assert(s.idx.Rebalance(), gc.IsNil)
+	s.SetIndexer(s.idx)
+}
+
+func (s *TieredIndexerTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+	c.Assert(s.full.Close(), gc.IsNil)
+}
+
+type HotTierTestSuite struct {
+	full *memory.InMemoryBleveIndexer
+	idx  *Indexer
+	ids  []uuid.UUID
+}
+
+func (s *HotTierTestSuite) SetUpTest(c *gc.C) {
+	s.full, s.idx = newTestIndexer(c, 0.2)
+
+	// Index 20 documents; the documents with a higher index have a higher
+	// PageRank score and the last 5 documents have no score.
+	s.ids = make([]uuid.UUID, 20)
+	for i := range s.ids {
+		s.ids[i] = uuid.New()
+		c.Assert(s.idx.Index(&index.Document{
+			LinkID:  s.ids[i],
+			URL:     fmt.Sprintf("http://example.com/%d", i),
+			Content: fmt.Sprintf("common term doc%d", i),
+		}), gc.IsNil)
+		if i < 15 {
+			c.Assert(s.idx.UpdateScore(s.ids[i], float64(i+1)), gc.IsNil)
+		}
+	}
+}
+
+func (s *HotTierTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+	c.Assert(s.full.Close(), gc.IsNil)
+}
+
+func (s *HotTierTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewIndexer(Config{HotFraction: 1.5})
+	c.Assert(err, gc.ErrorMatches, "(?s).*full index has not been provided.*hot index factory has not been provided.*hot fraction must be in the.*")
+}
+
+func (s *HotTierTestSuite) TestRebalanceSelectsTopDocuments(c *gc.C) {
+	c.Assert(s.idx.Rebalance(), gc.IsNil)
+	c.Assert(s.idx.threshold, gc.Equals, 12.0)
+	c.Assert(s.idx.hotIDs, gc.HasLen, 4)
+	for _, id := range s.ids[11:15] {
+		doc, err := s.idx.hot.FindByID(id)
+		c.Assert(err, gc.IsNil)
+		c.Assert(doc.PageRank, gc.Not(gc.Equals), 0.0)
+	}
+
+	// Raising the score of a document above the threshold promotes it.
+	c.Assert(s.idx.UpdateScore(s.ids[0], 100), gc.IsNil)
+	doc, err := s.idx.hot.FindByID(s.ids[0])
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.PageRank, gc.Equals, 100.0)
+
+	// Patches to hot documents are applied to both tiers.
+	title := "patched"
+	c.Assert(s.idx.Patch(s.ids[0], index.DocumentPatch{Title: &title}), gc.IsNil)
+	doc, err = s.idx.hot.FindByID(s.ids[0])
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Title, gc.Equals, title)
+}
+
+func (s *HotTierTestSuite) TestSearchServedByHotTier(c *gc.C) {
+	s.idx.cfg.MinHotResults = 2
+	c.Assert(s.idx.Rebalance(), gc.IsNil)
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "common"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it, gc.FitsTypeOf, new(tieredIterator))
+	c.Assert(it.TotalCount(), gc.Equals, uint64(20))
+
+	// Iterating past the hot results falls back to the full index without
+	// returning any duplicates.
+	seen := make(map[uuid.UUID]bool)
+	for it.Next() {
+		linkID := it.Document().LinkID
+		c.Assert(seen[linkID], gc.Equals, false)
+		seen[linkID] = true
+	}
+	c.Assert(it.Error(), gc.IsNil)
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(seen, gc.HasLen, 20)
+}
+
+func (s *HotTierTestSuite) TestSearchFallsBackToFullIndex(c *gc.C) {
+	s.idx.cfg.MinHotResults = 2
+	c.Assert(s.idx.Rebalance(), gc.IsNil)
+
+	specs := []index.Query{
+		// Not enough hot matches.
+		{Type: index.QueryTypeMatch, Expression: "doc0"},
+		// Not enough hot matches past the offset.
+		{Type: index.QueryTypeMatch, Expression: "common", Offset: 3},
+		// Facets must be computed over the full index.
+		{Type: index.QueryTypeMatch, Expression: "common", Facets: []index.FacetRequest{{Field: index.FacetLanguage}}},
+	}
+	for _, q := range specs {
+		it, err := s.idx.Search(q)
+		c.Assert(err, gc.IsNil)
+		_, isTiered := it.(*tieredIterator)
+		c.Assert(isTiered, gc.Equals, false, gc.Commentf("query %+v", q))
+		c.Assert(it.Close(), gc.IsNil)
+	}
+}
+
+func newTestIndexer(c *gc.C, hotFraction float64) (*memory.InMemoryBleveIndexer, *Indexer) {
+	full, err := memory.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+
+	idx, err := NewIndexer(Config{
+		Full:        full,
+		HotFraction: hotFraction,
+		NewHotIndex: func() (index.Indexer, error) {
+			return memory.NewInMemoryBleveIndexer()
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	return full, idx
+}
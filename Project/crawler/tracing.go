@@ -0,0 +1,152 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+	"webcrawler/crawler/errstore"
+	"webcrawler/pipeline"
+	"webcrawler/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("crawler")
+
+// linkTrace holds the root span that covers the journey of a link through
+// the crawler pipeline along with the provenance of the link, i.e. the
+// stages that processed it. The trace is shared by all copies of a payload
+// (see Clone) and ends when the last copy is marked as processed.
+type linkTrace struct {
+	span trace.Span
+	refs int32
+
+	linkID uuid.UUID
+	url    string
+
+	// An optional recorder for the provenance of links that go through
+	// the pipeline without errors and the ID of the crawl pass.
+	recorder ProvenanceRecorder
+	passID   uint64
+
+	mu     sync.Mutex
+	stages []errstore.StageRecord
+	failed bool
+}
+
+// startLinkTrace starts a new trace for the link with the specified ID and
+// URL. Each link is traced separately; if ctx carries the span of a crawl
+// pass, the link trace is linked to it.
+func startLinkTrace(ctx context.Context, linkID uuid.UUID, linkURL string) *linkTrace {
+	attrs := []attribute.KeyValue{
+		attribute.String("crawler.link_id", linkID.String()),
+		attribute.String("url.full", linkURL),
+	}
+	if u, err := url.Parse(linkURL); err == nil && u.Host != "" {
+		attrs = append(attrs, attribute.String("server.address", u.Hostname()))
+	}
+
+	opts := []trace.SpanStartOption{trace.WithNewRoot(), trace.WithAttributes(attrs...)}
+	if passSpan := trace.SpanContextFromContext(ctx); passSpan.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: passSpan}))
+	}
+	_, span := tracer.Start(ctx, "crawler.link", opts...)
+	return &linkTrace{span: span, refs: 1, linkID: linkID, url: linkURL}
+}
+
+// retain registers an additional payload copy that shares the trace.
+func (t *linkTrace) retain() {
+	if t != nil {
+		atomic.AddInt32(&t.refs, 1)
+	}
+}
+
+// release unregisters a payload copy and ends the span once all copies have
+// been released. If no stage failed, the provenance of the link is then
+// passed to the recorder.
+func (t *linkTrace) release() {
+	if t == nil || atomic.AddInt32(&t.refs, -1) != 0 {
+		return
+	}
+	t.span.End()
+	if t.recorder != nil && !t.failed {
+		t.recorder.LinkProcessed(LinkProvenance{
+			PassID: t.passID,
+			LinkID: t.linkID,
+			URL:    t.url,
+			Stages: t.stages,
+		})
+	}
+}
+
+// recordStage appends a stage to the provenance of the link.
+func (t *linkTrace) recordStage(rec errstore.StageRecord) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.stages = append(t.stages, rec)
+	t.failed = t.failed || rec.Outcome == errstore.StageFailed
+	t.mu.Unlock()
+}
+
+// fail flags the link as failed by stage, which has been running since
+// startedAt, and returns the stages that processed the link so far followed
+// by the failing stage.
+func (t *linkTrace) fail(stage string, startedAt time.Time) []errstore.StageRecord {
+	if t == nil {
+		return nil
+	}
+	rec := errstore.StageRecord{Stage: stage, Outcome: errstore.StageFailed}
+	if !startedAt.IsZero() {
+		rec.Duration = time.Since(startedAt)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failed = true
+	return append(append([]errstore.StageRecord(nil), t.stages...), rec)
+}
+
+// tracedProcessor wraps a pipeline processor and records a span for each
+// payload that it processes as a child of the payload's link span. The
+// duration and outcome of each invocation are also added to the provenance
+// of the link.
+type tracedProcessor struct {
+	stage string
+	proc  pipeline.Processor
+}
+
+// traced returns a processor that traces the invocations of proc. The span
+// names are prefixed with "crawler.".
+func traced(name string, proc pipeline.Processor) pipeline.Processor {
+	return &tracedProcessor{stage: name, proc: proc}
+}
+
+// Process implements pipeline.Processor.
+func (tp *tracedProcessor) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	lt := payload.trace
+	if lt != nil {
+		ctx = trace.ContextWithSpan(ctx, lt.span)
+	}
+
+	ctx, span := tracer.Start(ctx, "crawler."+tp.stage)
+	payload.stageStartedAt = time.Now()
+	out, err := tp.proc.Process(ctx, p)
+
+	rec := errstore.StageRecord{Stage: tp.stage, Outcome: errstore.StageOK, Duration: time.Since(payload.stageStartedAt)}
+	if err != nil {
+		rec.Outcome = errstore.StageFailed
+	} else if out == nil {
+		rec.Outcome = errstore.StageDropped
+		span.SetAttributes(attribute.Bool("crawler.dropped", true))
+	}
+	lt.recordStage(rec)
+	tracing.End(span, err)
+	return out, err
+}
@@ -0,0 +1,165 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"webcrawler/crawler/errstore"
+	"webcrawler/pipeline"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(TracingTestSuite))
+
+type TracingTestSuite struct {
+	rec *tracetest.SpanRecorder
+	tp  *sdktrace.TracerProvider
+}
+
+// The global tracer provider can only be replaced once as the package-level
+// tracers keep delegating to the first registered provider. The recorder is
+// therefore shared by all runs of the suite.
+var (
+	spanRecorder    = tracetest.NewSpanRecorder()
+	tracerProvider  = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	setProviderOnce sync.Once
+)
+
+func (s *TracingTestSuite) SetUpSuite(c *gc.C) {
+	setProviderOnce.Do(func() { otel.SetTracerProvider(tracerProvider) })
+	s.rec, s.tp = spanRecorder, tracerProvider
+}
+
+func (s *TracingTestSuite) TestLinkTraceCoversAllPayloadCopies(c *gc.C) {
+	ctx, pass := s.tp.Tracer("test").Start(context.TODO(), "crawler.pass")
+	defer pass.End()
+
+	linkID := uuid.New()
+	p := payloadPool.Get().(*crawlerPayload)
+	p.LinkID, p.URL = linkID, "http://example.com/foo"
+	p.trace = startLinkTrace(ctx, linkID, p.URL)
+	linkSpan := p.trace.span.SpanContext()
+
+	ok := traced("ok", pipeline.ProcessorFunc(func(_ context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+		return p, nil
+	}))
+	fail := traced("fail", pipeline.ProcessorFunc(func(context.Context, pipeline.Payload) (pipeline.Payload, error) {
+		return nil, errors.New("boom")
+	}))
+
+	clone := p.Clone()
+	_, err := ok.Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	_, err = fail.Process(context.TODO(), clone)
+	c.Assert(err, gc.ErrorMatches, "boom")
+
+	p.MarkAsProcessed()
+	c.Assert(s.endedSpan(linkSpan.TraceID().String(), "crawler.link"), gc.IsNil, gc.Commentf("link span ended before all payload copies were processed"))
+	clone.MarkAsProcessed()
+
+	link := s.endedSpan(linkSpan.TraceID().String(), "crawler.link")
+	c.Assert(link, gc.NotNil)
+	c.Assert(link.Parent().IsValid(), gc.Equals, false, gc.Commentf("expected the link span to be a root span"))
+	c.Assert(link.Links(), gc.HasLen, 1)
+	c.Assert(link.Links()[0].SpanContext.SpanID(), gc.Equals, pass.SpanContext().SpanID())
+
+	for _, name := range []string{"crawler.ok", "crawler.fail"} {
+		stage := s.endedSpan(linkSpan.TraceID().String(), name)
+		c.Assert(stage, gc.NotNil, gc.Commentf("no span recorded for %q", name))
+		c.Assert(stage.Parent().SpanID(), gc.Equals, linkSpan.SpanID())
+	}
+	c.Assert(s.endedSpan(linkSpan.TraceID().String(), "crawler.fail").Status().Code, gc.Equals, codes.Error)
+}
+
+func (s *TracingTestSuite) TestLinkProvenance(c *gc.C) {
+	var (
+		recorder provenanceRecorder
+		store, _ = errstore.NewStore(errstore.Config{})
+		errs     = &errorReporter{recorder: store, passID: 3}
+	)
+	newPayload := func(linkURL string) *crawlerPayload {
+		p := payloadPool.Get().(*crawlerPayload)
+		p.LinkID, p.URL = uuid.New(), linkURL
+		p.trace = startLinkTrace(context.TODO(), p.LinkID, p.URL)
+		p.trace.recorder, p.trace.passID = &recorder, 3
+		return p
+	}
+
+	pass := traced("pass", pipeline.ProcessorFunc(func(_ context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+		return p, nil
+	}))
+	drop := traced("drop", pipeline.ProcessorFunc(func(context.Context, pipeline.Payload) (pipeline.Payload, error) {
+		return nil, nil
+	}))
+	reportAndDrop := traced("report", pipeline.ProcessorFunc(func(_ context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+		errs.report("report", p.(*crawlerPayload), errstore.ClassTimeout, errors.New("timed out"))
+		return nil, nil
+	}))
+
+	// Links that are processed by all stages or dropped without an error
+	// are passed to the recorder once all payload copies are processed.
+	p := newPayload("http://example.com/ok")
+	_, err := pass.Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	clone := p.Clone()
+	_, err = pass.Process(context.TODO(), clone)
+	c.Assert(err, gc.IsNil)
+	_, err = drop.Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	p.MarkAsProcessed()
+	c.Assert(recorder.links, gc.HasLen, 0)
+	clone.MarkAsProcessed()
+
+	c.Assert(recorder.links, gc.HasLen, 1)
+	c.Assert(recorder.links[0].PassID, gc.Equals, uint64(3))
+	c.Assert(recorder.links[0].URL, gc.Equals, "http://example.com/ok")
+	c.Assert(stageOutcomes(recorder.links[0].Stages), gc.DeepEquals, []string{"pass:ok", "pass:ok", "drop:dropped"})
+
+	// The provenance of links that fail is attached to the error events.
+	p = newPayload("http://example.com/fail")
+	_, err = pass.Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	_, err = reportAndDrop.Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+	p.MarkAsProcessed()
+	c.Assert(recorder.links, gc.HasLen, 1)
+
+	records := store.Records(errstore.Filter{}, 0)
+	c.Assert(records, gc.HasLen, 1)
+	c.Assert(records[0].URL, gc.Equals, "http://example.com/fail")
+	c.Assert(stageOutcomes(records[0].Stages), gc.DeepEquals, []string{"pass:ok", "report:failed"})
+	c.Assert(records[0].Stages[1].Duration > 0, gc.Equals, true)
+}
+
+func (s *TracingTestSuite) endedSpan(traceID, name string) sdktrace.ReadOnlySpan {
+	for _, span := range s.rec.Ended() {
+		if span.SpanContext().TraceID().String() == traceID && span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+type provenanceRecorder struct {
+	links []LinkProvenance
+}
+
+func (r *provenanceRecorder) LinkProcessed(p LinkProvenance) {
+	r.links = append(r.links, p)
+}
+
+// stageOutcomes returns the stages and outcomes of stages as "stage:outcome"
+// strings.
+func stageOutcomes(stages []errstore.StageRecord) []string {
+	out := make([]string, len(stages))
+	for i, rec := range stages {
+		out[i] = rec.Stage + ":" + string(rec.Outcome)
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// URLRewriteRule maps the canonical URLs under a prefix to the URLs under the
+// prefix of a mirror that serves the same content. The crawler fetches pages
+// from the mirror while the link graph and the index only ever see canonical
+// URLs. As the mapping is applied in both directions, canonical URLs that are
+// stored in the link graph are mapped back to the mirror when they are
+// re-crawled.
+type URLRewriteRule struct {
+	// The prefix of the canonical (public) URLs; e.g.
+	// "https://docs.example.com/".
+	Canonical string
+
+	// The prefix of the URLs that are fetched instead; e.g.
+	// "http://mirror.internal/docs/".
+	Mirror string
+}
+
+// urlRewriter translates URLs between their canonical and mirror forms. Rules
+// are evaluated in order and the first matching rule is applied. A nil
+// urlRewriter leaves all URLs untouched.
+type urlRewriter struct {
+	rules []URLRewriteRule
+}
+
+// newURLRewriter returns a urlRewriter for rules or nil if no rules are
+// specified.
+func newURLRewriter(rules []URLRewriteRule) *urlRewriter {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &urlRewriter{rules: rules}
+}
+
+// toMirror returns the mirror URL for the canonical URL rawURL. The second
+// return value is false if none of the rules applies to rawURL.
+func (rw *urlRewriter) toMirror(rawURL string) (string, bool) {
+	if rw == nil {
+		return rawURL, false
+	}
+	for _, rule := range rw.rules {
+		if hasURLPrefix(rawURL, rule.Canonical) {
+			return rule.Mirror + rawURL[len(rule.Canonical):], true
+		}
+	}
+	return rawURL, false
+}
+
+// toCanonical returns the canonical URL for the mirror URL rawURL or rawURL
+// itself if none of the rules applies to it.
+func (rw *urlRewriter) toCanonical(rawURL string) string {
+	if rw == nil {
+		return rawURL
+	}
+	for _, rule := range rw.rules {
+		if hasURLPrefix(rawURL, rule.Mirror) {
+			return rule.Canonical + rawURL[len(rule.Mirror):]
+		}
+	}
+	return rawURL
+}
+
+// canonicalURL behaves like toCanonical but operates on parsed URLs. It
+// returns nil if u is nil or the rewritten URL cannot be parsed.
+func (rw *urlRewriter) canonicalURL(u *url.URL) *url.URL {
+	if rw == nil || u == nil {
+		return u
+	}
+
+	rawURL := u.String()
+	if canonical := rw.toCanonical(rawURL); canonical != rawURL {
+		cu, err := url.Parse(canonical)
+		if err != nil {
+			return nil
+		}
+		return cu
+	}
+	return u
+}
+
+// hasURLPrefix returns true if rawURL starts with prefix and the match ends
+// at a URL component boundary so that e.g. the "http://example.com" prefix
+// does not match "http://example.com.evil.org".
+func hasURLPrefix(rawURL, prefix string) bool {
+	if prefix == "" || !strings.HasPrefix(rawURL, prefix) {
+		return false
+	}
+	if len(rawURL) == len(prefix) || strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	return strings.ContainsRune("/?#", rune(rawURL[len(prefix)]))
+}
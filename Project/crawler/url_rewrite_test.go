@@ -0,0 +1,86 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"sort"
+
+	"webcrawler/crawler/mocks"
+
+	"github.com/golang/mock/gomock"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(URLRewriteTestSuite))
+
+type URLRewriteTestSuite struct{}
+
+var testRewriteRules = []URLRewriteRule{
+	{Canonical: "https://docs.example.com/", Mirror: "http://mirror.internal/docs/"},
+	{Canonical: "https://example.com", Mirror: "http://10.0.0.1:8080/www"},
+}
+
+func (s *URLRewriteTestSuite) TestRewrite(c *gc.C) {
+	rw := newURLRewriter(testRewriteRules)
+	specs := []struct {
+		canonical string
+		mirror    string
+		mirrored  bool
+	}{
+		{"https://docs.example.com/guide?page=2", "http://mirror.internal/docs/guide?page=2", true},
+		{"https://docs.example.com/", "http://mirror.internal/docs/", true},
+		{"https://example.com", "http://10.0.0.1:8080/www", true},
+		{"https://example.com/about", "http://10.0.0.1:8080/www/about", true},
+		// Prefixes only match at URL component boundaries.
+		{"https://example.com.evil.org/", "https://example.com.evil.org/", false},
+		{"https://other.com/", "https://other.com/", false},
+	}
+
+	for _, spec := range specs {
+		mirror, mirrored := rw.toMirror(spec.canonical)
+		c.Assert(mirror, gc.Equals, spec.mirror, gc.Commentf("url %q", spec.canonical))
+		c.Assert(mirrored, gc.Equals, spec.mirrored, gc.Commentf("url %q", spec.canonical))
+		c.Assert(rw.toCanonical(mirror), gc.Equals, spec.canonical, gc.Commentf("url %q", mirror))
+	}
+}
+
+func (s *URLRewriteTestSuite) TestNilRewriter(c *gc.C) {
+	rw := newURLRewriter(nil)
+	c.Assert(rw, gc.IsNil)
+
+	mirror, mirrored := rw.toMirror("https://example.com")
+	c.Assert(mirror, gc.Equals, "https://example.com")
+	c.Assert(mirrored, gc.Equals, false)
+	c.Assert(rw.toCanonical("https://example.com"), gc.Equals, "https://example.com")
+
+	u, err := url.Parse("https://example.com")
+	c.Assert(err, gc.IsNil)
+	c.Assert(rw.canonicalURL(u), gc.Equals, u)
+}
+
+func (s *URLRewriteTestSuite) TestLinkExtractorCanonicalizesMirrorLinks(c *gc.C) {
+	ctrl := gomock.NewController(c)
+	defer ctrl.Finish()
+	privNetDetector := mocks.NewMockPrivateNetworkDetector(ctrl)
+	privNetDetector.EXPECT().IsPrivate("example.com").Return(false, nil)
+
+	// The page was fetched from the mirror but its payload carries the
+	// canonical URL. Links to the mirror must be mapped back.
+	p := &crawlerPayload{URL: "https://docs.example.com/guide/"}
+	_, err := p.RawContent.WriteString(`
+<a href="intro">relative</a>
+<a href="http://mirror.internal/docs/api">absolute mirror link</a>
+<a href="http://10.0.0.1:8080/www/blog">other mirror</a>
+`)
+	c.Assert(err, gc.IsNil)
+
+	_, err = newLinkExtractor(privNetDetector, newURLRewriter(testRewriteRules), nil, nil, false).Process(context.TODO(), p)
+	c.Assert(err, gc.IsNil)
+
+	sort.Strings(p.Links)
+	c.Assert(p.Links, gc.DeepEquals, []string{
+		"https://docs.example.com/api",
+		"https://docs.example.com/guide/intro",
+		"https://example.com/blog",
+	})
+}
@@ -0,0 +1,142 @@
+package warc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// The suffix of the WARC files that are still being written to.
+const openSuffix = ".open"
+
+// FileConfig encapsulates the settings for a FileWriter.
+type FileConfig struct {
+	// The directory to create the WARC files in. It is created if it does
+	// not exist.
+	Dir string
+
+	// The prefix of the file names. Defaults to "webcrawler".
+	Prefix string
+
+	// The size after which the current file is closed and a new one is
+	// started. Defaults to 1 GiB.
+	MaxFileSize int64
+}
+
+func (cfg *FileConfig) validate() error {
+	var err error
+	if cfg.Dir == "" {
+		err = multierror.Append(err, fmt.Errorf("directory has not been provided"))
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "webcrawler"
+	}
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = 1 << 30
+	}
+	return err
+}
+
+// FileWriter writes WARC records to gzip-compressed files named
+// <prefix>-<timestamp>-<sequence>.warc.gz. Files carry an ".open" suffix
+// while they are being written to and are renamed once they reach the
+// configured size or the writer is closed. Each file starts with a warcinfo
+// record. FileWriter is safe for concurrent use.
+type FileWriter struct {
+	cfg FileConfig
+
+	mu   sync.Mutex
+	seq  int
+	file *os.File
+	size *countingWriter
+	w    *Writer
+}
+
+// NewFileWriter returns a FileWriter using the provided config.
+func NewFileWriter(cfg FileConfig) (*FileWriter, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("warc file writer: config validation failed: %w", err)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("warc file writer: %w", err)
+	}
+	return &FileWriter{cfg: cfg}, nil
+}
+
+// WriteRecord appends a response record for rec to the current file,
+// starting a new file if necessary.
+func (fw *FileWriter) WriteRecord(rec *Record) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if fw.file == nil {
+		if err := fw.open(); err != nil {
+			return err
+		}
+	}
+	if err := fw.w.WriteRecord(rec); err != nil {
+		return err
+	}
+	if fw.size.n >= fw.cfg.MaxFileSize {
+		return fw.closeFile()
+	}
+	return nil
+}
+
+// Close closes the current file.
+func (fw *FileWriter) Close() error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.closeFile()
+}
+
+// open creates the next file and writes its warcinfo record.
+func (fw *FileWriter) open() error {
+	fw.seq++
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", fw.cfg.Prefix, time.Now().UTC().Format("20060102150405"), fw.seq)
+	f, err := os.OpenFile(filepath.Join(fw.cfg.Dir, name+openSuffix), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	fw.file, fw.size = f, &countingWriter{w: f}
+	fw.w = NewWriter(fw.size, true)
+	if err = fw.w.WriteInfo(name); err != nil {
+		_ = fw.closeFile()
+		return err
+	}
+	return nil
+}
+
+// closeFile closes the current file, if any, and removes its ".open" suffix.
+func (fw *FileWriter) closeFile() error {
+	if fw.file == nil {
+		return nil
+	}
+	f := fw.file
+	fw.file, fw.size, fw.w = nil, nil, nil
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	openPath := f.Name()
+	if err := os.Rename(openPath, openPath[:len(openPath)-len(openSuffix)]); err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to the wrapped writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,124 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrMalformedRecord is returned by Reader when the archive contains a record
+// that cannot be parsed.
+var ErrMalformedRecord = errors.New("malformed WARC record")
+
+// Reader reads the response records of a WARC file. Plain and gzip-compressed
+// (including per-record compressed) files are supported.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader for the WARC file read from r. Compressed files
+// are detected automatically.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("warc: %w", err)
+		}
+		br = bufio.NewReader(zr)
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next returns the next HTTP response record of the archive, skipping all
+// other record types, or io.EOF once the archive has been read.
+//
+// The body of the returned record has its transfer encoding removed but
+// retains any content encoding indicated by the Content-Encoding header. The
+// LinkID of the record is only populated for records written by this
+// package.
+func (r *Reader) Next() (*Record, error) {
+	for {
+		fields, block, err := r.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if fields.Get("WARC-Type") != "response" || !strings.HasPrefix(fields.Get("Content-Type"), "application/http") {
+			continue
+		}
+		return parseResponse(fields, block)
+	}
+}
+
+// readRecord reads the named fields and the content block of the next
+// record.
+func (r *Reader) readRecord() (textproto.MIMEHeader, []byte, error) {
+	// Skip the blank lines that separate records.
+	var version string
+	for version == "" {
+		line, err := r.r.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			return nil, nil, io.EOF
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("warc: %w", err)
+		}
+		version = strings.TrimSpace(line)
+	}
+	if !strings.HasPrefix(version, "WARC/") {
+		return nil, nil, fmt.Errorf("warc: %w: unexpected version line %q", ErrMalformedRecord, version)
+	}
+
+	fields, err := textproto.NewReader(r.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, nil, fmt.Errorf("warc: %w: %v", ErrMalformedRecord, err)
+	}
+	length, err := strconv.ParseInt(fields.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, nil, fmt.Errorf("warc: %w: invalid content length %q", ErrMalformedRecord, fields.Get("Content-Length"))
+	}
+	block := make([]byte, length)
+	if _, err = io.ReadFull(r.r, block); err != nil {
+		return nil, nil, fmt.Errorf("warc: %w: truncated content block: %v", ErrMalformedRecord, err)
+	}
+	return fields, block, nil
+}
+
+// parseResponse returns the Record for a response record with the specified
+// fields and content block.
+func parseResponse(fields textproto.MIMEHeader, block []byte) (*Record, error) {
+	targetURI := strings.Trim(fields.Get("WARC-Target-URI"), "<>")
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("warc: %w: %s: %v", ErrMalformedRecord, targetURI, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("warc: %w: %s: %v", ErrMalformedRecord, targetURI, err)
+	}
+
+	rec := &Record{
+		URL:        targetURI,
+		StatusCode: res.StatusCode,
+		Proto:      res.Proto,
+		Header:     res.Header,
+		Body:       body,
+	}
+	if linkID, err := uuid.Parse(fields.Get(LinkIDField)); err == nil {
+		rec.LinkID = linkID
+	}
+	if fetchedAt, err := time.Parse(time.RFC3339, fields.Get("WARC-Date")); err == nil {
+		rec.FetchedAt = fetchedAt
+	}
+	return rec, nil
+}
@@ -0,0 +1,175 @@
+// Package warc archives fetched HTTP responses as WARC/1.1 records (ISO
+// 28500:2017), the standard format for web archives.
+//
+// A Writer appends records to an arbitrary io.Writer while a FileWriter
+// appends them to gzip-compressed files that are rotated once they reach a
+// configurable size. Each response record carries the ID of the crawled link
+// in the WebCrawler-Link-ID field so that archived responses can be mapped
+// back to the link graph. A Reader reads the response records of existing
+// archives, including those created by other tools.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The name of the WARC field that holds the ID of the crawled link.
+const LinkIDField = "WebCrawler-Link-ID"
+
+// The default HTTP version of archived responses.
+const defaultProto = "HTTP/1.1"
+
+// The headers that are not archived as they describe the transfer of the
+// response body rather than the body itself. Bodies are archived after their
+// content encoding has been decoded.
+var transferHeaders = map[string]bool{
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+}
+
+// Record describes a fetched HTTP response that is archived as a WARC
+// response record.
+type Record struct {
+	// The ID and URL of the crawled link.
+	LinkID uuid.UUID
+	URL    string
+
+	// The time when the response was received.
+	FetchedAt time.Time
+
+	// The status code, HTTP version (defaults to HTTP/1.1) and headers of
+	// the response.
+	StatusCode int
+	Proto      string
+	Header     http.Header
+
+	// The response body. Bodies are written after decoding their content
+	// encoding; bodies read from archives created by other tools may
+	// still be encoded as indicated by the Content-Encoding header.
+	Body []byte
+}
+
+// Writer writes WARC records to an io.Writer. It is safe for concurrent use.
+type Writer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	compress bool
+}
+
+// NewWriter returns a Writer that appends records to w. If compress is true,
+// each record is written as a separate gzip member so that readers can
+// decompress individual records.
+func NewWriter(w io.Writer, compress bool) *Writer {
+	return &Writer{w: w, compress: compress}
+}
+
+// WriteRecord appends a response record for rec.
+func (w *Writer) WriteRecord(rec *Record) error {
+	block := responseBlock(rec)
+	fields := [][2]string{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", recordID()},
+		{"WARC-Date", formatDate(rec.FetchedAt)},
+		{"WARC-Target-URI", rec.URL},
+		{"WARC-Payload-Digest", digest(rec.Body)},
+		{"WARC-Block-Digest", digest(block)},
+		{"Content-Type", "application/http; msgtype=response"},
+		{LinkIDField, rec.LinkID.String()},
+	}
+	return w.write(fields, block)
+}
+
+// WriteInfo appends a warcinfo record which describes the software that
+// created the records that follow it. The filename is omitted if empty.
+func (w *Writer) WriteInfo(filename string) error {
+	fields := [][2]string{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", recordID()},
+		{"WARC-Date", formatDate(time.Now())},
+	}
+	if filename != "" {
+		fields = append(fields, [2]string{"WARC-Filename", filename})
+	}
+	fields = append(fields, [2]string{"Content-Type", "application/warc-fields"})
+	return w.write(fields, []byte("software: webcrawler\r\nformat: WARC File Format 1.1\r\n"))
+}
+
+// write appends a record with the specified named fields and content block.
+func (w *Writer) write(fields [][2]string, block []byte) error {
+	var buf bytes.Buffer
+	buf.WriteString("WARC/1.1\r\n")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "%s: %s\r\n", f[0], f[1])
+	}
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(block))
+	buf.Write(block)
+	buf.WriteString("\r\n\r\n")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.compress {
+		if _, err := w.w.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("warc: %w", err)
+		}
+		return nil
+	}
+	zw := gzip.NewWriter(w.w)
+	if _, err := zw.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	return nil
+}
+
+// responseBlock returns the HTTP response message that forms the content
+// block of the response record for rec.
+func responseBlock(rec *Record) []byte {
+	proto := rec.Proto
+	if proto == "" {
+		proto = defaultProto
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %d %s\r\n", proto, rec.StatusCode, http.StatusText(rec.StatusCode))
+	names := make([]string, 0, len(rec.Header))
+	for name := range rec.Header {
+		if !transferHeaders[http.CanonicalHeaderKey(name)] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range rec.Header[name] {
+			fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+		}
+	}
+	buf.WriteString("Content-Length: " + strconv.Itoa(len(rec.Body)) + "\r\n\r\n")
+	buf.Write(rec.Body)
+	return buf.Bytes()
+}
+
+func recordID() string { return "<urn:uuid:" + uuid.New().String() + ">" }
+
+func formatDate(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+
+// digest returns the labelled SHA-1 digest of data in the base32 encoding
+// used by web archiving tools.
+func digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,139 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(WARCTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type WARCTestSuite struct{}
+
+func (s *WARCTestSuite) TestResponseRecord(c *gc.C) {
+	var buf bytes.Buffer
+	linkID := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	err := NewWriter(&buf, false).WriteRecord(&Record{
+		LinkID:     linkID,
+		URL:        "http://example.com/",
+		FetchedAt:  time.Date(2024, 3, 5, 10, 0, 0, 0, time.FixedZone("CET", 3600)),
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":     {"text/html"},
+			"Content-Encoding": {"gzip"},
+			"Content-Length":   {"12"},
+			"Set-Cookie":       {"a=1", "b=2"},
+		},
+		Body: []byte("hello"),
+	})
+	c.Assert(err, gc.IsNil)
+
+	header, block, ok := strings.Cut(buf.String(), "\r\n\r\n")
+	c.Assert(ok, gc.Equals, true)
+	c.Assert(header, gc.Matches, `(?s)WARC/1\.1\r\nWARC-Type: response\r\nWARC-Record-ID: <urn:uuid:[0-9a-f-]{36}>\r\n.*`)
+	c.Assert(header, gc.Matches, `(?s).*\r\nWARC-Date: 2024-03-05T09:00:00Z\r\n.*`)
+	c.Assert(header, gc.Matches, `(?s).*\r\nWARC-Target-URI: http://example.com/\r\n.*`)
+	c.Assert(header, gc.Matches, `(?s).*\r\nWARC-Payload-Digest: sha1:VL2MMHO4YXUKFWV63YHTWSBM3GXKSQ2N\r\n.*`)
+	c.Assert(header, gc.Matches, `(?s).*\r\nWebCrawler-Link-ID: `+linkID.String()+`\r\n.*`)
+
+	// Transfer headers are replaced by the length of the decoded body.
+	expBlock := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nSet-Cookie: a=1\r\nSet-Cookie: b=2\r\nContent-Length: 5\r\n\r\nhello"
+	c.Assert(strings.HasSuffix(header, "\r\nContent-Length: "+strconv.Itoa(len(expBlock))), gc.Equals, true)
+	c.Assert(block, gc.Equals, expBlock+"\r\n\r\n")
+}
+
+func (s *WARCTestSuite) TestReadCompressedRecords(c *gc.C) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, true)
+	c.Assert(w.WriteInfo("test.warc.gz"), gc.IsNil)
+	linkID := uuid.New()
+	fetchedAt := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	c.Assert(w.WriteRecord(&Record{
+		LinkID:     linkID,
+		URL:        "http://example.com/",
+		FetchedAt:  fetchedAt,
+		StatusCode: http.StatusNotFound,
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       []byte("not found"),
+	}), gc.IsNil)
+	c.Assert(w.WriteRecord(&Record{URL: "http://example.com/empty", StatusCode: http.StatusNoContent}), gc.IsNil)
+
+	r, err := NewReader(&buf)
+	c.Assert(err, gc.IsNil)
+	rec, err := r.Next()
+	c.Assert(err, gc.IsNil)
+	c.Assert(rec.LinkID, gc.Equals, linkID)
+	c.Assert(rec.URL, gc.Equals, "http://example.com/")
+	c.Assert(rec.FetchedAt.Equal(fetchedAt), gc.Equals, true)
+	c.Assert(rec.StatusCode, gc.Equals, http.StatusNotFound)
+	c.Assert(rec.Proto, gc.Equals, "HTTP/1.1")
+	c.Assert(rec.Header.Get("Content-Type"), gc.Equals, "text/html")
+	c.Assert(string(rec.Body), gc.Equals, "not found")
+
+	rec, err = r.Next()
+	c.Assert(err, gc.IsNil)
+	c.Assert(rec.URL, gc.Equals, "http://example.com/empty")
+	c.Assert(rec.Body, gc.HasLen, 0)
+
+	_, err = r.Next()
+	c.Assert(err, gc.Equals, io.EOF)
+
+	r, err = NewReader(strings.NewReader("WARC/1.1\r\nWARC-Type: response\r\nContent-Length: 100\r\n\r\nshort"))
+	c.Assert(err, gc.IsNil)
+	_, err = r.Next()
+	c.Assert(errors.Is(err, ErrMalformedRecord), gc.Equals, true)
+}
+
+func (s *WARCTestSuite) TestFileRotation(c *gc.C) {
+	dir := c.MkDir()
+	fw, err := NewFileWriter(FileConfig{Dir: dir, Prefix: "test", MaxFileSize: 1})
+	c.Assert(err, gc.IsNil)
+
+	for _, u := range []string{"http://example.com/a", "http://example.com/b"} {
+		c.Assert(fw.WriteRecord(&Record{URL: u, StatusCode: http.StatusOK, Body: []byte(u)}), gc.IsNil)
+	}
+	// Each record exceeds the maximum file size so every file holds a
+	// single record and has already been closed.
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(files, gc.HasLen, 2)
+	c.Assert(files[0], gc.Matches, `.*/test-\d{14}-00001\.warc\.gz`)
+	c.Assert(files[1], gc.Matches, `.*/test-\d{14}-00002\.warc\.gz`)
+
+	f, err := os.Open(files[1])
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	c.Assert(err, gc.IsNil)
+	content, err := io.ReadAll(zr)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(content), gc.Matches, `(?s)WARC/1\.1\r\nWARC-Type: warcinfo\r\n.*WARC-Filename: test-\d{14}-00002\.warc\.gz\r\n.*WARC-Type: response\r\n.*http://example.com/b\r\n\r\n$`)
+
+	// Files that are still being written to carry a suffix until the
+	// writer is closed.
+	fw, err = NewFileWriter(FileConfig{Dir: dir, Prefix: "open"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(fw.WriteRecord(&Record{URL: "http://example.com/c", StatusCode: http.StatusOK}), gc.IsNil)
+	open, err := filepath.Glob(filepath.Join(dir, "open-*.warc.gz.open"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(open, gc.HasLen, 1)
+	c.Assert(fw.Close(), gc.IsNil)
+	_, err = os.Stat(strings.TrimSuffix(open[0], ".open"))
+	c.Assert(err, gc.IsNil)
+
+	_, err = NewFileWriter(FileConfig{})
+	c.Assert(err, gc.ErrorMatches, "(?s)warc file writer: config validation failed: .*directory has not been provided.*")
+}
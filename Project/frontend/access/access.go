@@ -0,0 +1,205 @@
+// Package access implements per-API-key field visibility policies for the
+// search results and documents returned by the frontend.
+//
+// Clients identify themselves by passing an API key via the X-API-Key
+// header. Requests without a key may only see the public fields while each
+// key may be granted access to additional fields such as the raw document
+// content or internal metadata. Handlers resolve the fields that are visible
+// to a request via Policy.Resolve and redact all other fields when mapping
+// documents to responses.
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// HeaderAPIKey is the request header that clients pass their API key in.
+const HeaderAPIKey = "X-API-Key"
+
+// ErrUnknownAPIKey is returned by Policy.Resolve for requests that specify an
+// API key which has not been configured.
+var ErrUnknownAPIKey = errors.New("unknown API key")
+
+// The document fields whose visibility can be controlled. The names match the
+// JSON and GraphQL field names used by the frontend.
+const (
+	FieldLinkID       = "linkID"
+	FieldURL          = "url"
+	FieldTitle        = "title"
+	FieldSnippet      = "snippet"
+	FieldVertical     = "vertical"
+	FieldContent      = "content"
+	FieldSummary      = "summary"
+	FieldLanguage     = "language"
+	FieldAuthor       = "author"
+	FieldPublishedAt  = "publishedAt"
+	FieldIndexedAt    = "indexedAt"
+	FieldPageRank     = "pageRank"
+	FieldFaviconRef   = "faviconRef"
+	FieldThumbnailRef = "thumbnailRef"
+	FieldHeaders      = "headers"
+)
+
+// Fields lists all fields whose visibility can be controlled.
+var Fields = []string{
+	FieldLinkID, FieldURL, FieldTitle, FieldSnippet, FieldVertical,
+	FieldContent, FieldSummary, FieldLanguage, FieldAuthor, FieldPublishedAt,
+	FieldIndexedAt, FieldPageRank, FieldFaviconRef, FieldThumbnailRef, FieldHeaders,
+}
+
+// DefaultPublicFields lists the fields that are visible to requests without
+// an API key unless configured otherwise.
+var DefaultPublicFields = []string{FieldLinkID, FieldURL, FieldTitle, FieldSnippet, FieldVertical}
+
+// IsValidField returns true if field is included in Fields.
+func IsValidField(field string) bool {
+	for _, f := range Fields {
+		if field == f {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldSet is a set of visible fields. A nil FieldSet does not restrict the
+// visible fields.
+type FieldSet map[string]struct{}
+
+// NewFieldSet returns a FieldSet containing the specified fields.
+func NewFieldSet(fields ...string) FieldSet {
+	set := make(FieldSet, len(fields))
+	for _, field := range fields {
+		set[field] = struct{}{}
+	}
+	return set
+}
+
+// Has returns true if field is visible.
+func (s FieldSet) Has(field string) bool {
+	if s == nil {
+		return true
+	}
+	_, visible := s[field]
+	return visible
+}
+
+// List returns the visible fields in alphabetical order.
+func (s FieldSet) List() []string {
+	list := make([]string, 0, len(s))
+	for field := range s {
+		list = append(list, field)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Config encapsulates the settings for a Policy.
+type Config struct {
+	// The fields that are visible to requests without an API key.
+	// Defaults to DefaultPublicFields.
+	PublicFields []string
+
+	// The fields that are visible to requests with a particular API key.
+	// Keys always have access to the public fields.
+	Keys map[string][]string
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.PublicFields == nil {
+		cfg.PublicFields = DefaultPublicFields
+	}
+	for _, field := range cfg.PublicFields {
+		if !IsValidField(field) {
+			err = multierror.Append(err, fmt.Errorf("unknown public field %q", field))
+		}
+	}
+	for key, fields := range cfg.Keys {
+		if strings.TrimSpace(key) == "" {
+			err = multierror.Append(err, fmt.Errorf("API keys must not be empty"))
+		}
+		for _, field := range fields {
+			if !IsValidField(field) {
+				err = multierror.Append(err, fmt.Errorf("unknown field %q for API key %s", field, redactKey(key)))
+			}
+		}
+	}
+	return err
+}
+
+// Policy maps API keys to the set of fields that are visible to them.
+type Policy struct {
+	public FieldSet
+	keys   map[string]FieldSet
+}
+
+// NewPolicy returns a new field visibility policy using the provided config.
+func NewPolicy(cfg Config) (*Policy, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("access policy: config validation failed: %w", err)
+	}
+
+	p := &Policy{
+		public: NewFieldSet(cfg.PublicFields...),
+		keys:   make(map[string]FieldSet, len(cfg.Keys)),
+	}
+	for key, fields := range cfg.Keys {
+		p.keys[key] = NewFieldSet(append(append([]string{}, cfg.PublicFields...), fields...)...)
+	}
+	return p, nil
+}
+
+// FieldsFor returns the fields that are visible to the specified API key or
+// the public fields if apiKey is empty.
+func (p *Policy) FieldsFor(apiKey string) (FieldSet, error) {
+	if apiKey == "" {
+		return p.public, nil
+	}
+	fields, known := p.keys[apiKey]
+	if !known {
+		return nil, ErrUnknownAPIKey
+	}
+	return fields, nil
+}
+
+// Resolve looks up the fields that are visible to the API key of r and
+// returns a shallow copy of r whose context carries them (see
+// FieldsFromContext). It returns ErrUnknownAPIKey if r specifies an API key
+// that has not been configured.
+func (p *Policy) Resolve(r *http.Request) (*http.Request, error) {
+	fields, err := p.FieldsFor(r.Header.Get(HeaderAPIKey))
+	if err != nil {
+		return nil, err
+	}
+	return r.WithContext(WithFields(r.Context(), fields)), nil
+}
+
+type fieldsCtxKey struct{}
+
+// WithFields returns a copy of ctx that carries the set of visible fields.
+func WithFields(ctx context.Context, fields FieldSet) context.Context {
+	return context.WithValue(ctx, fieldsCtxKey{}, fields)
+}
+
+// FieldsFromContext returns the set of visible fields carried by ctx. The
+// second return value is false if no policy has been applied to ctx.
+func FieldsFromContext(ctx context.Context) (FieldSet, bool) {
+	fields, ok := ctx.Value(fieldsCtxKey{}).(FieldSet)
+	return fields, ok
+}
+
+// redactKey returns a prefix of an API key that is safe to include in error
+// messages.
+func redactKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + "****"
+}
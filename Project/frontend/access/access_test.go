@@ -0,0 +1,88 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(AccessTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type AccessTestSuite struct{}
+
+func (s *AccessTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewPolicy(Config{
+		PublicFields: []string{FieldURL, "secret"},
+		Keys: map[string][]string{
+			"":           nil,
+			"0123456789": {FieldContent, "bogus"},
+		},
+	})
+	c.Assert(err, gc.NotNil)
+	c.Assert(err.Error(), gc.Matches, `(?s).*unknown public field "secret".*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*API keys must not be empty.*`)
+	c.Assert(err.Error(), gc.Matches, `(?s).*unknown field "bogus" for API key 0123\*\*\*\*.*`)
+	c.Assert(err.Error(), gc.Not(gc.Matches), `(?s).*0123456789.*`)
+}
+
+func (s *AccessTestSuite) TestFieldsFor(c *gc.C) {
+	p, err := NewPolicy(Config{
+		Keys: map[string][]string{
+			"internal": {FieldContent, FieldPageRank},
+			"basic":    nil,
+		},
+	})
+	c.Assert(err, gc.IsNil)
+
+	fields, err := p.FieldsFor("")
+	c.Assert(err, gc.IsNil)
+	c.Assert(fields.List(), gc.DeepEquals, []string{FieldLinkID, FieldSnippet, FieldTitle, FieldURL, FieldVertical})
+
+	fields, err = p.FieldsFor("basic")
+	c.Assert(err, gc.IsNil)
+	c.Assert(fields.List(), gc.DeepEquals, []string{FieldLinkID, FieldSnippet, FieldTitle, FieldURL, FieldVertical})
+
+	fields, err = p.FieldsFor("internal")
+	c.Assert(err, gc.IsNil)
+	c.Assert(fields.Has(FieldContent), gc.Equals, true)
+	c.Assert(fields.Has(FieldPageRank), gc.Equals, true)
+	c.Assert(fields.Has(FieldURL), gc.Equals, true)
+	c.Assert(fields.Has(FieldHeaders), gc.Equals, false)
+
+	_, err = p.FieldsFor("unknown")
+	c.Assert(errors.Is(err, ErrUnknownAPIKey), gc.Equals, true)
+}
+
+func (s *AccessTestSuite) TestResolve(c *gc.C) {
+	p, err := NewPolicy(Config{
+		PublicFields: []string{FieldURL},
+		Keys:         map[string][]string{"internal": {FieldContent}},
+	})
+	c.Assert(err, gc.IsNil)
+
+	_, applied := FieldsFromContext(context.Background())
+	c.Assert(applied, gc.Equals, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	resolved, err := p.Resolve(req)
+	c.Assert(err, gc.IsNil)
+	fields, applied := FieldsFromContext(resolved.Context())
+	c.Assert(applied, gc.Equals, true)
+	c.Assert(fields.List(), gc.DeepEquals, []string{FieldURL})
+
+	req.Header.Set(HeaderAPIKey, "internal")
+	resolved, err = p.Resolve(req)
+	c.Assert(err, gc.IsNil)
+	fields, _ = FieldsFromContext(resolved.Context())
+	c.Assert(fields.List(), gc.DeepEquals, []string{FieldContent, FieldURL})
+
+	req.Header.Set(HeaderAPIKey, "other")
+	_, err = p.Resolve(req)
+	c.Assert(errors.Is(err, ErrUnknownAPIKey), gc.Equals, true)
+}
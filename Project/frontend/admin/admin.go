@@ -0,0 +1,524 @@
+// Package admin exposes an HTTP API that allows moderators to patch or redact
+// the fields of indexed documents without having to recrawl them. Every
+// change is recorded in an audit log together with the identity of the
+// moderator and the reason for the change.
+//
+// The API exposes the following endpoints:
+//
+//	PATCH /documents/{linkID}        replace the title and/or content
+//	POST  /documents/{linkID}/redact clear the listed fields
+//	GET   /documents/{linkID}/audit  list the audit trail for a document
+//	GET   /backups                   report the index backups and job outcomes
+//	POST  /backups                   create an index backup
+//	POST  /backups/verify            restore a backup (?name=, default newest)
+//	                                 to a scratch index and run smoke queries
+//	GET   /pacing                    report the request pacing state of each
+//	                                 recently crawled host
+//	GET   /errors                    list the most recent crawl errors
+//	GET   /errors/classes            report the most frequent crawl error
+//	                                 classes
+//	GET   /links                     list the links of the link graph
+//	POST  /jobs                      create and start a crawl job
+//	GET   /jobs                      list the crawl jobs and their progress
+//	GET   /jobs/{name}               report the state and progress of a job
+//	POST  /jobs/{name}/pause         pause a running job
+//	POST  /jobs/{name}/resume        resume a paused or failed job
+//	POST  /jobs/{name}/cancel        cancel a job
+//	POST  /ingest                    ingest externally discovered links
+//
+// The crawl error endpoints accept the optional host, pass, stage and class
+// query parameters for narrowing down the errors and a limit parameter for the
+// maximum number of entries to return (default 100).
+//
+// The links endpoint accepts the optional domain (which also matches
+// subdomains), status (pending, scheduled or due), retrievedAfter and
+// retrievedBefore (RFC 3339 timestamps) and dead (true or false) query
+// parameters for narrowing down the links. Links are ordered by ID and
+// returned in pages of up to limit links (default 100); the nextCursor of a
+// response is passed as the cursor parameter to request the following page.
+// A link is considered dead if its most recent crawl error indicates that its
+// host does not resolve or its server responded with a 4xx status code; the
+// dead flag is therefore only reported if a crawl error store is available.
+//
+// A crawl job is created from a JSON body with its name, seeds, scope and
+// maxPages (see jobs.Spec). The progress of each job reports the number of
+// pages and bytes fetched, the size of its frontier and the number of errors
+// it encountered.
+//
+// The ingest endpoint accepts the NDJSON records and seed lists described by
+// ingest.HTTPHandler.
+//
+// The backup endpoints are only available if a backup scheduler has been
+// configured, the links endpoint is only available if a link graph has been
+// configured, the ingest endpoint is only available if an ingest handler has
+// been configured and the pacing, crawl error and crawl job endpoints are
+// only available if the crawler runs in the same process.
+//
+// All requests must carry an "Authorization: Bearer <token>" header that
+// matches the configured token.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/pacing"
+	"webcrawler/crawler/textindexer/backup"
+	"webcrawler/crawler/textindexer/index"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+// IndexAPI defines the set of text indexer operations required by the admin
+// API.
+type IndexAPI interface {
+	// FindByID looks up a document by its link ID.
+	FindByID(linkID uuid.UUID) (*index.Document, error)
+
+	// Patch applies a partial update to the indexed document with the
+	// specified link ID.
+	Patch(linkID uuid.UUID, patch index.DocumentPatch) error
+}
+
+// BackupAPI defines the set of backup scheduler operations exposed by the
+// admin API.
+type BackupAPI interface {
+	// Status returns the available backups and the outcome of the most
+	// recent backup jobs.
+	Status() (*backup.Status, error)
+
+	// Backup creates a new backup.
+	Backup() (*backup.Info, error)
+
+	// Verify restores the backup with the specified name (or the newest
+	// backup if name is empty) to a scratch index and queries it.
+	Verify(name string) (*backup.Verification, error)
+}
+
+// PacingAPI defines the set of pacing controller operations exposed by the
+// admin API.
+type PacingAPI interface {
+	// Status returns the pacing state of the recently requested hosts.
+	Status() []pacing.HostState
+}
+
+// ErrorsAPI defines the set of crawl error store operations exposed by the
+// admin API.
+type ErrorsAPI interface {
+	// Records returns up to limit error records that match f, most
+	// recently seen first.
+	Records(f errstore.Filter, limit int) []errstore.Record
+
+	// TopClasses returns up to limit error classes of the records that
+	// match f by descending event count.
+	TopClasses(f errstore.Filter, limit int) []errstore.ClassSummary
+}
+
+// Config encapsulates the settings for the admin API handler.
+type Config struct {
+	// The text indexer whose documents are to be modified.
+	IndexAPI IndexAPI
+
+	// The audit log for recording changes.
+	AuditLog AuditLog
+
+	// The bearer token that clients must present.
+	Token string
+
+	// A clock for timestamping audit entries. Defaults to time.Now.
+	Clock func() time.Time
+
+	// An optional backup scheduler for the text index.
+	Backups BackupAPI
+
+	// An optional controller that paces the crawler requests to each
+	// host.
+	Pacing PacingAPI
+
+	// An optional store for the errors encountered by the crawler.
+	Errors ErrorsAPI
+
+	// An optional link graph whose links can be listed.
+	Graph GraphAPI
+
+	// An optional manager for crawl jobs.
+	Jobs JobsAPI
+
+	// An optional handler for ingesting externally discovered links
+	// (e.g. an ingest.HTTPHandler).
+	Ingest http.Handler
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.IndexAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("index API has not been provided"))
+	}
+	if cfg.AuditLog == nil {
+		err = multierror.Append(err, fmt.Errorf("audit log has not been provided"))
+	}
+	if cfg.Token == "" {
+		err = multierror.Append(err, fmt.Errorf("access token has not been provided"))
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+// The names of the document fields that can be modified via the API.
+const (
+	fieldTitle   = "title"
+	fieldContent = "content"
+)
+
+// Handler is an http.Handler that serves the admin API.
+type Handler struct {
+	cfg Config
+	mux *http.ServeMux
+}
+
+// NewHandler returns a new admin API handler using the provided config.
+func NewHandler(cfg Config) (*Handler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("admin handler: config validation failed: %w", err)
+	}
+
+	h := &Handler{cfg: cfg, mux: http.NewServeMux()}
+	h.mux.HandleFunc("PATCH /documents/{linkID}", h.patchDocument)
+	h.mux.HandleFunc("POST /documents/{linkID}/redact", h.redactDocument)
+	h.mux.HandleFunc("GET /documents/{linkID}/audit", h.auditTrail)
+	if cfg.Backups != nil {
+		h.mux.HandleFunc("GET /backups", h.backupStatus)
+		h.mux.HandleFunc("POST /backups", h.createBackup)
+		h.mux.HandleFunc("POST /backups/verify", h.verifyBackup)
+	}
+	if cfg.Pacing != nil {
+		h.mux.HandleFunc("GET /pacing", h.pacingStatus)
+	}
+	if cfg.Errors != nil {
+		h.mux.HandleFunc("GET /errors", h.crawlErrors)
+		h.mux.HandleFunc("GET /errors/classes", h.crawlErrorClasses)
+	}
+	if cfg.Graph != nil {
+		h.mux.HandleFunc("GET /links", h.listLinks)
+	}
+	if cfg.Jobs != nil {
+		h.mux.HandleFunc("POST /jobs", h.createJob)
+		h.mux.HandleFunc("GET /jobs", h.listJobs)
+		h.mux.HandleFunc("GET /jobs/{name}", h.getJob)
+		h.mux.HandleFunc("POST /jobs/{name}/pause", h.jobAction(cfg.Jobs.Pause))
+		h.mux.HandleFunc("POST /jobs/{name}/resume", h.jobAction(cfg.Jobs.Resume))
+		h.mux.HandleFunc("POST /jobs/{name}/cancel", h.jobAction(cfg.Jobs.Cancel))
+	}
+	if cfg.Ingest != nil {
+		h.mux.Handle("POST /ingest", cfg.Ingest)
+	}
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "missing or invalid access token")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.Token)) == 1
+}
+
+// patchRequest describes the body of a PATCH request.
+type patchRequest struct {
+	Actor   string  `json:"actor"`
+	Reason  string  `json:"reason"`
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+}
+
+func (h *Handler) patchDocument(w http.ResponseWriter, r *http.Request) {
+	var req patchRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	patch := index.DocumentPatch{Title: req.Title, Content: req.Content}
+	if patch.IsEmpty() {
+		writeError(w, http.StatusBadRequest, "at least one of title or content must be specified")
+		return
+	}
+
+	h.applyPatch(w, r, ActionPatch, req.Actor, req.Reason, patch)
+}
+
+// redactRequest describes the body of a redaction request.
+type redactRequest struct {
+	Actor  string   `json:"actor"`
+	Reason string   `json:"reason"`
+	Fields []string `json:"fields"`
+}
+
+func (h *Handler) redactDocument(w http.ResponseWriter, r *http.Request) {
+	var req redactRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	var (
+		patch index.DocumentPatch
+		empty string
+	)
+	for _, field := range req.Fields {
+		switch field {
+		case fieldTitle:
+			patch.Title = &empty
+		case fieldContent:
+			patch.Content = &empty
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown field %q; expected %q or %q", field, fieldTitle, fieldContent))
+			return
+		}
+	}
+	if patch.IsEmpty() {
+		writeError(w, http.StatusBadRequest, "at least one field to redact must be specified")
+		return
+	}
+
+	h.applyPatch(w, r, ActionRedact, req.Actor, req.Reason, patch)
+}
+
+// applyPatch validates the request metadata, applies patch to the document
+// referenced by the request path and records the change in the audit log.
+func (h *Handler) applyPatch(w http.ResponseWriter, r *http.Request, action Action, actor, reason string, patch index.DocumentPatch) {
+	linkID, ok := parseLinkID(w, r)
+	if !ok {
+		return
+	}
+	if actor == "" || reason == "" {
+		writeError(w, http.StatusBadRequest, "both actor and reason must be specified")
+		return
+	}
+
+	if err := h.cfg.IndexAPI.Patch(linkID, patch); err != nil {
+		if errors.Is(err, index.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "document not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	entry := AuditEntry{
+		Time:   h.cfg.Clock().UTC(),
+		Actor:  actor,
+		LinkID: linkID,
+		Action: action,
+		Fields: patchedFields(patch),
+		Reason: reason,
+	}
+	if err := h.cfg.AuditLog.Record(entry); err != nil {
+		// The change has already been applied; report the failure so
+		// that it can be recorded manually.
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("document updated but audit entry could not be recorded: %v", err))
+		return
+	}
+
+	doc, err := h.cfg.IndexAPI.FindByID(linkID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, documentResponse{
+		LinkID:  doc.LinkID,
+		URL:     doc.URL,
+		Title:   doc.Title,
+		Content: doc.Content,
+	})
+}
+
+func (h *Handler) auditTrail(w http.ResponseWriter, r *http.Request) {
+	linkID, ok := parseLinkID(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := h.cfg.AuditLog.Entries(linkID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (h *Handler) backupStatus(w http.ResponseWriter, _ *http.Request) {
+	status, err := h.cfg.Backups.Status()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *Handler) createBackup(w http.ResponseWriter, _ *http.Request) {
+	info, err := h.cfg.Backups.Backup()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, info)
+}
+
+// verifyBackup responds with the verification outcome; a backup that fails
+// verification is reported via the passed field of a 200 response.
+func (h *Handler) verifyBackup(w http.ResponseWriter, r *http.Request) {
+	v, err := h.cfg.Backups.Verify(r.URL.Query().Get("name"))
+	if err != nil {
+		if errors.Is(err, backup.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "backup not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// pacingResponse describes the body of a pacing status response.
+type pacingResponse struct {
+	Hosts []pacing.HostState `json:"hosts"`
+}
+
+func (h *Handler) pacingStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, pacingResponse{Hosts: h.cfg.Pacing.Status()})
+}
+
+// The default and maximum number of entries returned by the crawl error
+// endpoints.
+const (
+	defaultErrorsLimit = 100
+	maxErrorsLimit     = 1000
+)
+
+// errorsResponse describes the body of a crawl errors response.
+type errorsResponse struct {
+	Records []errstore.Record `json:"records"`
+}
+
+// errorClassesResponse describes the body of a crawl error classes response.
+type errorClassesResponse struct {
+	Classes []errstore.ClassSummary `json:"classes"`
+}
+
+func (h *Handler) crawlErrors(w http.ResponseWriter, r *http.Request) {
+	filter, limit, ok := parseErrorsQuery(w, r)
+	if !ok {
+		return
+	}
+	records := h.cfg.Errors.Records(filter, limit)
+	if records == nil {
+		records = []errstore.Record{}
+	}
+	writeJSON(w, http.StatusOK, errorsResponse{Records: records})
+}
+
+func (h *Handler) crawlErrorClasses(w http.ResponseWriter, r *http.Request) {
+	filter, limit, ok := parseErrorsQuery(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, errorClassesResponse{Classes: h.cfg.Errors.TopClasses(filter, limit)})
+}
+
+// parseErrorsQuery parses the filter and limit query parameters of the crawl
+// error endpoints.
+func parseErrorsQuery(w http.ResponseWriter, r *http.Request) (errstore.Filter, int, bool) {
+	q := r.URL.Query()
+	filter := errstore.Filter{
+		Host:  q.Get("host"),
+		Stage: q.Get("stage"),
+		Class: errstore.Class(q.Get("class")),
+	}
+	if v := q.Get("pass"); v != "" {
+		passID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid pass ID")
+			return filter, 0, false
+		}
+		filter.PassID = passID
+	}
+
+	limit := defaultErrorsLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxErrorsLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxErrorsLimit))
+			return filter, 0, false
+		}
+		limit = n
+	}
+	return filter, limit, true
+}
+
+// documentResponse describes the patched document returned to clients.
+type documentResponse struct {
+	LinkID  uuid.UUID `json:"linkID"`
+	URL     string    `json:"url"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+}
+
+func patchedFields(patch index.DocumentPatch) []string {
+	var fields []string
+	if patch.Title != nil {
+		fields = append(fields, fieldTitle)
+	}
+	if patch.Content != nil {
+		fields = append(fields, fieldContent)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func parseLinkID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	linkID, err := uuid.Parse(r.PathValue("linkID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid link ID")
+		return uuid.Nil, false
+	}
+	return linkID, true
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, to interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(to); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("malformed request: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,437 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webcrawler/crawler"
+	"webcrawler/crawler/blobstore"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/ingest"
+	"webcrawler/crawler/jobs"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/pacing"
+	"webcrawler/crawler/textindexer/backup"
+	"webcrawler/crawler/textindexer/index"
+
+	memgraph "webcrawler/crawler/linkgraph/store/memory"
+	memindex "webcrawler/crawler/textindexer/store/memory"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(AdminTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type AdminTestSuite struct {
+	idx      *memindex.InMemoryBleveIndexer
+	auditLog *InMemoryAuditLog
+	auditBuf bytes.Buffer
+	h        *Handler
+	doc      *index.Document
+	now      time.Time
+}
+
+func (s *AdminTestSuite) SetUpTest(c *gc.C) {
+	var err error
+	s.idx, err = memindex.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+
+	s.auditBuf.Reset()
+	s.auditLog = NewInMemoryAuditLog(&s.auditBuf)
+	s.now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.h, err = NewHandler(Config{
+		IndexAPI: s.idx,
+		AuditLog: s.auditLog,
+		Token:    "s3cr3t",
+		Clock:    func() time.Time { return s.now },
+	})
+	c.Assert(err, gc.IsNil)
+
+	s.doc = &index.Document{
+		LinkID:  uuid.New(),
+		URL:     "http://example.com",
+		Title:   "Leaked phone number 555-1234",
+		Content: "Ovidius poeta in terra pontica",
+	}
+	c.Assert(s.idx.Index(s.doc), gc.IsNil)
+}
+
+func (s *AdminTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+}
+
+func (s *AdminTestSuite) TestPatchDocument(c *gc.C) {
+	rec := s.do("PATCH", "/documents/"+s.doc.LinkID.String(), "s3cr3t",
+		`{"actor": "alice", "reason": "PII", "title": "Example"}`)
+	c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf(rec.Body.String()))
+
+	doc, err := s.idx.FindByID(s.doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Title, gc.Equals, "Example")
+	c.Assert(doc.Content, gc.Equals, s.doc.Content)
+
+	entries, err := s.auditLog.Entries(s.doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(entries, gc.DeepEquals, []AuditEntry{{
+		Time:   s.now,
+		Actor:  "alice",
+		LinkID: s.doc.LinkID,
+		Action: ActionPatch,
+		Fields: []string{"title"},
+		Reason: "PII",
+	}})
+	c.Assert(strings.Count(s.auditBuf.String(), "\n"), gc.Equals, 1)
+}
+
+func (s *AdminTestSuite) TestRedactDocument(c *gc.C) {
+	rec := s.do("POST", "/documents/"+s.doc.LinkID.String()+"/redact", "s3cr3t",
+		`{"actor": "bob", "reason": "court order", "fields": ["content", "title"]}`)
+	c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf(rec.Body.String()))
+
+	doc, err := s.idx.FindByID(s.doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Title, gc.Equals, "")
+	c.Assert(doc.Content, gc.Equals, "")
+
+	it, err := s.idx.Search(index.Query{Type: index.QueryTypeMatch, Expression: "poeta"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(it.Next(), gc.Equals, false)
+	c.Assert(it.Close(), gc.IsNil)
+
+	rec = s.do("GET", "/documents/"+s.doc.LinkID.String()+"/audit", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	var entries []AuditEntry
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &entries), gc.IsNil)
+	c.Assert(entries, gc.HasLen, 1)
+	c.Assert(entries[0].Action, gc.Equals, ActionRedact)
+	c.Assert(entries[0].Fields, gc.DeepEquals, []string{"content", "title"})
+}
+
+func (s *AdminTestSuite) TestRequestValidation(c *gc.C) {
+	specs := []struct {
+		descr  string
+		method string
+		path   string
+		token  string
+		body   string
+		exp    int
+	}{
+		{"bad token", "PATCH", "/documents/" + s.doc.LinkID.String(), "nope", `{"actor": "a", "reason": "r", "title": ""}`, http.StatusUnauthorized},
+		{"bad link ID", "PATCH", "/documents/foo", "s3cr3t", `{"actor": "a", "reason": "r", "title": ""}`, http.StatusBadRequest},
+		{"unknown link", "PATCH", "/documents/" + uuid.New().String(), "s3cr3t", `{"actor": "a", "reason": "r", "title": ""}`, http.StatusNotFound},
+		{"missing reason", "PATCH", "/documents/" + s.doc.LinkID.String(), "s3cr3t", `{"actor": "a", "title": ""}`, http.StatusBadRequest},
+		{"empty patch", "PATCH", "/documents/" + s.doc.LinkID.String(), "s3cr3t", `{"actor": "a", "reason": "r"}`, http.StatusBadRequest},
+		{"unknown redact field", "POST", "/documents/" + s.doc.LinkID.String() + "/redact", "s3cr3t", `{"actor": "a", "reason": "r", "fields": ["url"]}`, http.StatusBadRequest},
+	}
+
+	for _, spec := range specs {
+		rec := s.do(spec.method, spec.path, spec.token, spec.body)
+		c.Assert(rec.Code, gc.Equals, spec.exp, gc.Commentf("%s: %s", spec.descr, rec.Body.String()))
+	}
+
+	// Rejected requests must not be audited nor modify the document.
+	entries, err := s.auditLog.Entries(s.doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(entries, gc.HasLen, 0)
+
+	doc, err := s.idx.FindByID(s.doc.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Title, gc.Equals, s.doc.Title)
+}
+
+func (s *AdminTestSuite) TestBackupEndpoints(c *gc.C) {
+	rec := s.do("GET", "/backups", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound, gc.Commentf("backup endpoints should not be served without a scheduler"))
+
+	backend, err := backup.NewBlobBackend(backup.BlobConfig{Index: s.idx, Store: blobstore.NewInMemoryStore()})
+	c.Assert(err, gc.IsNil)
+	sched, err := backup.NewScheduler(backup.Config{Backend: backend, SmokeQueries: []string{"pontica"}})
+	c.Assert(err, gc.IsNil)
+	s.h, err = NewHandler(Config{IndexAPI: s.idx, AuditLog: s.auditLog, Token: "s3cr3t", Backups: sched})
+	c.Assert(err, gc.IsNil)
+
+	rec = s.do("POST", "/backups/verify", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound, gc.Commentf(rec.Body.String()))
+
+	rec = s.do("POST", "/backups", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusCreated, gc.Commentf(rec.Body.String()))
+	var info backup.Info
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &info), gc.IsNil)
+	c.Assert(info.Documents, gc.Equals, int64(1))
+
+	rec = s.do("POST", "/backups/verify?name="+info.Name, "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf(rec.Body.String()))
+	var v backup.Verification
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &v), gc.IsNil)
+	c.Assert(v.Passed, gc.Equals, true, gc.Commentf(rec.Body.String()))
+
+	rec = s.do("GET", "/backups", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	var status backup.Status
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &status), gc.IsNil)
+	c.Assert(status.Backups, gc.HasLen, 1)
+	c.Assert(status.LastBackup.Backup, gc.Equals, info.Name)
+	c.Assert(status.LastVerification.Backup, gc.Equals, info.Name)
+}
+
+func (s *AdminTestSuite) TestPacingEndpoint(c *gc.C) {
+	rec := s.do("GET", "/pacing", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound, gc.Commentf("pacing endpoint should not be served without a controller"))
+
+	ctrl, err := pacing.NewController(pacing.Config{MaxDelay: time.Minute, Clock: func() time.Time { return s.now }})
+	c.Assert(err, gc.IsNil)
+	ctrl.SetCrawlDelay("example.com", 5*time.Second)
+	ctrl.Observe("example.com", http.StatusServiceUnavailable, http.Header{"Retry-After": {"30"}})
+	s.h, err = NewHandler(Config{IndexAPI: s.idx, AuditLog: s.auditLog, Token: "s3cr3t", Pacing: ctrl})
+	c.Assert(err, gc.IsNil)
+
+	rec = s.do("GET", "/pacing", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	var res struct {
+		Hosts []pacing.HostState `json:"hosts"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &res), gc.IsNil)
+	c.Assert(res.Hosts, gc.HasLen, 1)
+	c.Assert(res.Hosts[0].Host, gc.Equals, "example.com")
+	c.Assert(res.Hosts[0].Delay, gc.Equals, 5*time.Second)
+	c.Assert(res.Hosts[0].Overloads, gc.Equals, 1)
+	c.Assert(res.Hosts[0].RetryAfter.Equal(s.now.Add(30*time.Second)), gc.Equals, true)
+}
+
+func (s *AdminTestSuite) TestCrawlErrorEndpoints(c *gc.C) {
+	rec := s.do("GET", "/errors", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound, gc.Commentf("error endpoints should not be served without an error store"))
+
+	store, err := errstore.NewStore(errstore.Config{Clock: func() time.Time { return s.now }})
+	c.Assert(err, gc.IsNil)
+	store.Record(errstore.Event{PassID: 1, URL: "http://a.com/x", Stage: "link_fetcher", Class: errstore.ClassHTTPClient, Message: "unexpected status code 404"})
+	store.Record(errstore.Event{PassID: 1, URL: "http://a.com/y", Stage: "link_fetcher", Class: errstore.ClassHTTPClient})
+	store.Record(errstore.Event{PassID: 2, URL: "http://b.com/", Stage: "link_fetcher", Class: errstore.ClassDNS})
+	s.h, err = NewHandler(Config{IndexAPI: s.idx, AuditLog: s.auditLog, Token: "s3cr3t", Errors: store})
+	c.Assert(err, gc.IsNil)
+
+	rec = s.do("GET", "/errors?host=a.com&limit=1", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	var errorsRes struct {
+		Records []errstore.Record `json:"records"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &errorsRes), gc.IsNil)
+	c.Assert(errorsRes.Records, gc.HasLen, 1)
+	c.Assert(errorsRes.Records[0].URL, gc.Equals, "http://a.com/y")
+
+	rec = s.do("GET", "/errors/classes?pass=2", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	var classesRes struct {
+		Classes []errstore.ClassSummary `json:"classes"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &classesRes), gc.IsNil)
+	c.Assert(classesRes.Classes, gc.HasLen, 1)
+	c.Assert(classesRes.Classes[0].Class, gc.Equals, errstore.ClassDNS)
+	c.Assert(classesRes.Classes[0].Hosts, gc.Equals, 1)
+
+	rec = s.do("GET", "/errors?class=tls", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), gc.Equals, "{\"records\":[]}\n")
+
+	c.Assert(s.do("GET", "/errors?pass=latest", "s3cr3t", "").Code, gc.Equals, http.StatusBadRequest)
+	c.Assert(s.do("GET", "/errors/classes?limit=0", "s3cr3t", "").Code, gc.Equals, http.StatusBadRequest)
+}
+
+func (s *AdminTestSuite) TestListLinks(c *gc.C) {
+	rec := s.do("GET", "/links", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound, gc.Commentf("links endpoint should not be served without a link graph"))
+
+	g := memgraph.NewInMemoryGraph()
+	retrievedAt := s.now.Add(-time.Hour).Unix()
+	links := map[string]*graph.Link{
+		"http://a.com/":      {URL: "http://a.com/"},
+		"http://www.a.com/x": {URL: "http://www.a.com/x", RetrievedAt: retrievedAt, NextFetchAt: s.now.Add(time.Hour).Unix()},
+		"http://a.com/gone":  {URL: "http://a.com/gone", RetrievedAt: retrievedAt, NextFetchAt: s.now.Add(-time.Minute).Unix()},
+		"http://b.com/":      {URL: "http://b.com/", RetrievedAt: s.now.Add(-48 * time.Hour).Unix()},
+	}
+	for _, link := range links {
+		c.Assert(g.UpsertLink(link), gc.IsNil)
+	}
+	store, err := errstore.NewStore(errstore.Config{})
+	c.Assert(err, gc.IsNil)
+	store.Record(errstore.Event{URL: "http://b.com/", Class: errstore.ClassHTTPClient})
+	store.Record(errstore.Event{URL: "http://b.com/", Class: errstore.ClassTimeout})
+	store.Record(errstore.Event{URL: "http://a.com/gone", Class: errstore.ClassHTTPClient})
+
+	s.h, err = NewHandler(Config{
+		IndexAPI: s.idx,
+		AuditLog: s.auditLog,
+		Token:    "s3cr3t",
+		Clock:    func() time.Time { return s.now },
+		Graph:    g,
+	})
+	c.Assert(err, gc.IsNil)
+
+	res := s.listLinks(c, "/links?domain=A.com&limit=2")
+	c.Assert(res.Links, gc.HasLen, 2)
+	c.Assert(res.NextCursor, gc.Equals, res.Links[1].ID.String())
+	c.Assert(res.Links[0].Dead, gc.IsNil, gc.Commentf("dead flag should not be reported without an error store"))
+	page := s.listLinks(c, "/links?domain=A.com&limit=2&cursor="+res.NextCursor)
+	c.Assert(page.Links, gc.HasLen, 1)
+	c.Assert(page.NextCursor, gc.Equals, "")
+
+	var urls []string
+	for _, link := range append(res.Links, page.Links...) {
+		urls = append(urls, link.URL)
+		c.Assert(link.ID, gc.Equals, links[link.URL].ID)
+	}
+	c.Assert(urls, gc.HasLen, 3)
+	c.Assert(strings.Join(urls, ","), gc.Not(gc.Matches), ".*b.com.*")
+	c.Assert(res.Links[0].ID.String() < res.Links[1].ID.String(), gc.Equals, true)
+	c.Assert(res.Links[1].ID.String() < page.Links[0].ID.String(), gc.Equals, true)
+
+	res = s.listLinks(c, "/links?status=scheduled")
+	c.Assert(res.Links, gc.HasLen, 1)
+	c.Assert(res.Links[0].URL, gc.Equals, "http://www.a.com/x")
+	c.Assert(res.Links[0].Status, gc.Equals, LinkScheduled)
+	c.Assert(res.Links[0].RetrievedAt.Equal(s.now.Add(-time.Hour)), gc.Equals, true)
+
+	res = s.listLinks(c, "/links?status=pending")
+	c.Assert(res.Links, gc.HasLen, 1)
+	c.Assert(res.Links[0].URL, gc.Equals, "http://a.com/")
+	c.Assert(res.Links[0].RetrievedAt, gc.IsNil)
+
+	res = s.listLinks(c, "/links?retrievedAfter=2024-02-29T12:00:00Z&retrievedBefore=2024-03-01T12:00:00Z&status=due")
+	c.Assert(res.Links, gc.HasLen, 1)
+	c.Assert(res.Links[0].URL, gc.Equals, "http://a.com/gone")
+
+	c.Assert(s.do("GET", "/links?dead=true", "s3cr3t", "").Code, gc.Equals, http.StatusBadRequest)
+	s.h.cfg.Errors = store
+
+	// Only the most recent error of a link determines whether it is dead.
+	res = s.listLinks(c, "/links?dead=true")
+	c.Assert(res.Links, gc.HasLen, 1)
+	c.Assert(res.Links[0].URL, gc.Equals, "http://a.com/gone")
+	c.Assert(*res.Links[0].Dead, gc.Equals, true)
+	res = s.listLinks(c, "/links?dead=false&domain=b.com")
+	c.Assert(res.Links, gc.HasLen, 1)
+	c.Assert(*res.Links[0].Dead, gc.Equals, false)
+
+	for _, query := range []string{"status=gone", "retrievedAfter=yesterday", "dead=maybe", "cursor=x", "limit=1001"} {
+		c.Assert(s.do("GET", "/links?"+query, "s3cr3t", "").Code, gc.Equals, http.StatusBadRequest, gc.Commentf(query))
+	}
+}
+
+func (s *AdminTestSuite) TestIngestEndpoint(c *gc.C) {
+	rec := s.do("POST", "/ingest", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound, gc.Commentf("ingest endpoint should not be served without an ingest handler"))
+
+	g := memgraph.NewInMemoryGraph()
+	ingester, err := ingest.NewIngester(ingest.Config{Graph: g, PrivateNetworkDetector: publicNetwork{}})
+	c.Assert(err, gc.IsNil)
+	s.h, err = NewHandler(Config{
+		IndexAPI: s.idx,
+		AuditLog: s.auditLog,
+		Token:    "s3cr3t",
+		Ingest:   ingest.NewHTTPHandler(ingester),
+	})
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(s.do("POST", "/ingest", "", `{"url":"http://example.com/a"}`).Code, gc.Equals, http.StatusUnauthorized)
+	rec = s.do("POST", "/ingest", "s3cr3t", `{"url":"http://example.com/a","srcURL":"http://example.com/"}`+"\n"+`{"url":"ftp://example.com"}`)
+	c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf(rec.Body.String()))
+	var summary ingest.Summary
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &summary), gc.IsNil)
+	c.Assert(summary.Accepted, gc.Equals, uint64(1))
+	c.Assert(summary.Rejected, gc.Equals, uint64(1))
+
+	it, err := g.Links(uuid.Nil, uuid.Max, time.Now().Unix())
+	c.Assert(err, gc.IsNil)
+	var count int
+	for it.Next() {
+		count++
+	}
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(count, gc.Equals, 2)
+}
+
+func (s *AdminTestSuite) TestJobEndpoints(c *gc.C) {
+	rec := s.do("GET", "/jobs", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound, gc.Commentf("job endpoints should not be served without a job manager"))
+
+	// The manager does not run so the jobs are not crawled.
+	mgr, err := jobs.NewManager(jobs.Config{
+		Store: jobs.NewInMemoryStore(),
+		Crawler: crawler.Config{
+			PrivateNetworkDetector: publicNetwork{},
+			URLGetter:              http.DefaultClient,
+			Graph:                  unusedGraph{},
+			Indexer:                s.idx,
+			FetchWorkers:           1,
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	s.h, err = NewHandler(Config{IndexAPI: s.idx, AuditLog: s.auditLog, Token: "s3cr3t", Jobs: mgr})
+	c.Assert(err, gc.IsNil)
+
+	rec = s.do("POST", "/jobs", "s3cr3t", `{"name":"docs","seeds":["https://docs.example.com/"],"maxPages":10}`)
+	c.Assert(rec.Code, gc.Equals, http.StatusCreated, gc.Commentf(rec.Body.String()))
+	var job jobs.Job
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &job), gc.IsNil)
+	c.Assert(job.Name, gc.Equals, "docs")
+	c.Assert(job.State, gc.Equals, jobs.Running)
+	c.Assert(job.Progress.FrontierSize, gc.Equals, 1)
+
+	c.Assert(s.do("POST", "/jobs", "s3cr3t", `{"name":"docs","seeds":["https://docs.example.com/"]}`).Code, gc.Equals, http.StatusConflict)
+	c.Assert(s.do("POST", "/jobs", "s3cr3t", `{"name":"docs 2","seeds":[]}`).Code, gc.Equals, http.StatusBadRequest)
+
+	rec = s.do("POST", "/jobs/docs/pause", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &job), gc.IsNil)
+	c.Assert(job.State, gc.Equals, jobs.Paused)
+	c.Assert(s.do("POST", "/jobs/docs/pause", "s3cr3t", "").Code, gc.Equals, http.StatusConflict)
+
+	rec = s.do("POST", "/jobs/docs/cancel", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	rec = s.do("GET", "/jobs/docs", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &job), gc.IsNil)
+	c.Assert(job.State, gc.Equals, jobs.Cancelled)
+	c.Assert(s.do("POST", "/jobs/docs/resume", "s3cr3t", "").Code, gc.Equals, http.StatusConflict)
+
+	rec = s.do("GET", "/jobs", "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	var list struct {
+		Jobs []jobs.Job `json:"jobs"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &list), gc.IsNil)
+	c.Assert(list.Jobs, gc.HasLen, 1)
+	c.Assert(s.do("GET", "/jobs/missing", "s3cr3t", "").Code, gc.Equals, http.StatusNotFound)
+}
+
+// publicNetwork is a crawler.PrivateNetworkDetector that treats all hosts as
+// public.
+type publicNetwork struct{}
+
+func (publicNetwork) IsPrivate(string) (bool, error) { return false, nil }
+
+// unusedGraph is a crawler.Graph for job managers that never crawl.
+type unusedGraph struct{ crawler.Graph }
+
+func (s *AdminTestSuite) listLinks(c *gc.C, path string) linksResponse {
+	rec := s.do("GET", path, "s3cr3t", "")
+	c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf(rec.Body.String()))
+	var res linksResponse
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &res), gc.IsNil)
+	return res
+}
+
+func (s *AdminTestSuite) do(method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.h.ServeHTTP(rec, req)
+	return rec
+}
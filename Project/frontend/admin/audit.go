@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Action describes the type of change applied to a document.
+type Action string
+
+const (
+	// ActionPatch indicates that one or more document fields were
+	// replaced with new values.
+	ActionPatch Action = "patch"
+
+	// ActionRedact indicates that one or more document fields were
+	// cleared.
+	ActionRedact Action = "redact"
+)
+
+// AuditEntry records a change applied to an indexed document via the admin
+// API.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	LinkID uuid.UUID `json:"linkID"`
+	Action Action    `json:"action"`
+
+	// The names of the modified fields.
+	Fields []string `json:"fields"`
+
+	// The justification provided by the actor.
+	Reason string `json:"reason"`
+}
+
+// AuditLog is implemented by objects that can persist an audit trail for
+// the changes applied via the admin API.
+type AuditLog interface {
+	// Record appends an entry to the audit log.
+	Record(entry AuditEntry) error
+
+	// Entries returns the audit entries for the specified link ID in
+	// the order they were recorded.
+	Entries(linkID uuid.UUID) ([]AuditEntry, error)
+}
+
+// Compile-time check for ensuring InMemoryAuditLog implements AuditLog.
+var _ AuditLog = (*InMemoryAuditLog)(nil)
+
+// InMemoryAuditLog is an AuditLog that keeps entries in memory and optionally
+// mirrors them as JSON lines to an io.Writer (e.g. an append-only file) so
+// they outlive the process.
+type InMemoryAuditLog struct {
+	mu      sync.RWMutex
+	w       io.Writer
+	entries []AuditEntry
+}
+
+// NewInMemoryAuditLog creates a new in-memory audit log. If w is not nil,
+// each recorded entry is also written to it as a JSON document followed by a
+// newline.
+func NewInMemoryAuditLog(w io.Writer) *InMemoryAuditLog {
+	return &InMemoryAuditLog{w: w}
+}
+
+// Record appends an entry to the audit log.
+func (l *InMemoryAuditLog) Record(entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.w != nil {
+		if err := json.NewEncoder(l.w).Encode(entry); err != nil {
+			return fmt.Errorf("record audit entry: %w", err)
+		}
+	}
+
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// Entries returns the audit entries for the specified link ID in the order
+// they were recorded.
+func (l *InMemoryAuditLog) Entries(linkID uuid.UUID) ([]AuditEntry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var list []AuditEntry
+	for _, entry := range l.entries {
+		if entry.LinkID == linkID {
+			list = append(list, entry)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"webcrawler/crawler/jobs"
+)
+
+// JobsAPI defines the set of crawl job manager operations exposed by the
+// admin API.
+type JobsAPI interface {
+	// Create creates a new job described by spec and starts running it.
+	Create(spec jobs.Spec) (*jobs.Job, error)
+
+	// Jobs returns all jobs ordered by name.
+	Jobs() []*jobs.Job
+
+	// Job returns the job with the specified name.
+	Job(name string) (*jobs.Job, error)
+
+	// Pause, Resume and Cancel control the job with the specified name.
+	Pause(name string) (*jobs.Job, error)
+	Resume(name string) (*jobs.Job, error)
+	Cancel(name string) (*jobs.Job, error)
+}
+
+// jobsResponse describes the body of a job list response.
+type jobsResponse struct {
+	Jobs []*jobs.Job `json:"jobs"`
+}
+
+func (h *Handler) createJob(w http.ResponseWriter, r *http.Request) {
+	var spec jobs.Spec
+	if !decodeRequest(w, r, &spec) {
+		return
+	}
+	job, err := h.cfg.Jobs.Create(spec)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (h *Handler) listJobs(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, jobsResponse{Jobs: h.cfg.Jobs.Jobs()})
+}
+
+func (h *Handler) getJob(w http.ResponseWriter, r *http.Request) {
+	h.jobAction(h.cfg.Jobs.Job)(w, r)
+}
+
+// jobAction returns a handler that applies op to the job referenced by the
+// request path and responds with the job.
+func (h *Handler) jobAction(op func(name string) (*jobs.Job, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := op(r.PathValue("name"))
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+// writeJobError maps the errors of the job manager to HTTP responses.
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, "job not found")
+	case errors.Is(err, jobs.ErrExists), errors.Is(err, jobs.ErrInvalidState):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, jobs.ErrInvalidSpec):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
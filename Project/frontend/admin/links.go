@@ -0,0 +1,306 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"webcrawler/crawler/errstore"
+	"webcrawler/crawler/linkgraph/graph"
+
+	"github.com/google/uuid"
+)
+
+// GraphAPI defines the set of link graph operations required by the admin
+// API.
+type GraphAPI interface {
+	// Links returns an iterator for the set of links whose IDs belong to
+	// the [fromID, toID) range and were due to be fetched before the
+	// specified time.
+	Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error)
+}
+
+// LinkStatus describes the crawl state of a link.
+type LinkStatus string
+
+// The supported link states.
+const (
+	// LinkPending indicates that the link has not been retrieved yet.
+	LinkPending LinkStatus = "pending"
+
+	// LinkScheduled indicates that the link has been retrieved and is not
+	// due to be fetched again yet.
+	LinkScheduled LinkStatus = "scheduled"
+
+	// LinkDue indicates that the link has been retrieved and is due to be
+	// fetched again.
+	LinkDue LinkStatus = "due"
+)
+
+// linkStatus returns the status of link at the specified unix time.
+func linkStatus(link *graph.Link, now int64) LinkStatus {
+	switch {
+	case link.RetrievedAt == 0:
+		return LinkPending
+	case link.NextFetchAt > now:
+		return LinkScheduled
+	default:
+		return LinkDue
+	}
+}
+
+// The default and maximum number of links returned by the links endpoint.
+const (
+	defaultLinksLimit = 100
+	maxLinksLimit     = 1000
+)
+
+// maxUUID is the upper bound of the link ID space.
+var maxUUID = uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+
+// linkFilter selects the links returned by the links endpoint. Zero-valued
+// fields match all links.
+type linkFilter struct {
+	domain          string
+	status          LinkStatus
+	retrievedAfter  int64
+	retrievedBefore int64
+
+	// If non-nil, only links whose dead flag matches are returned.
+	dead *bool
+}
+
+func (f *linkFilter) matches(link *graph.Link, status LinkStatus, dead bool) bool {
+	if f.status != "" && f.status != status {
+		return false
+	}
+	if f.retrievedAfter != 0 && link.RetrievedAt < f.retrievedAfter {
+		return false
+	}
+	if f.retrievedBefore != 0 && link.RetrievedAt >= f.retrievedBefore {
+		return false
+	}
+	if f.dead != nil && *f.dead != dead {
+		return false
+	}
+	if f.domain != "" {
+		u, err := url.Parse(link.URL)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(u.Hostname())
+		return host == f.domain || strings.HasSuffix(host, "."+f.domain)
+	}
+	return true
+}
+
+// linkResponse describes a link returned by the links endpoint.
+type linkResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	URL         string     `json:"url"`
+	Namespace   string     `json:"namespace,omitempty"`
+	Status      LinkStatus `json:"status"`
+	RetrievedAt *time.Time `json:"retrievedAt,omitempty"`
+	NextFetchAt *time.Time `json:"nextFetchAt,omitempty"`
+	FirstPassID uint64     `json:"firstPassID"`
+	PassID      uint64     `json:"passID"`
+
+	// Only reported if a crawl error store is available.
+	Dead *bool `json:"dead,omitempty"`
+}
+
+// linksResponse describes the body of a links response.
+type linksResponse struct {
+	Links []linkResponse `json:"links"`
+
+	// The cursor for requesting the next page or empty if this is the
+	// last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// listLinks responds with a page of the links that match the query
+// parameters ordered by ID. As the graph store does not iterate links in ID
+// order, each request scans the links that follow the cursor and keeps the
+// ones with the lowest IDs.
+func (h *Handler) listLinks(w http.ResponseWriter, r *http.Request) {
+	filter, cursor, limit, ok := h.parseLinksQuery(w, r)
+	if !ok {
+		return
+	}
+
+	deadURLs := h.deadURLs()
+	now := h.cfg.Clock().Unix()
+	fromID := uuid.Nil
+	if cursor != uuid.Nil {
+		if cursor == maxUUID {
+			writeJSON(w, http.StatusOK, linksResponse{Links: []linkResponse{}})
+			return
+		}
+		fromID = nextUUID(cursor)
+	}
+
+	it, err := h.cfg.Graph.Links(fromID, maxUUID, math.MaxInt64)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Keep one more link than requested to find out whether another page
+	// follows.
+	var page []*graph.Link
+	for it.Next() {
+		link := it.Link()
+		if !filter.matches(link, linkStatus(link, now), deadURLs[link.URL]) {
+			continue
+		}
+		pos := sort.Search(len(page), func(i int) bool { return bytes.Compare(page[i].ID[:], link.ID[:]) > 0 })
+		if pos > limit {
+			continue
+		}
+		page = append(page, nil)
+		copy(page[pos+1:], page[pos:])
+		page[pos] = link
+		if len(page) > limit+1 {
+			page = page[:limit+1]
+		}
+	}
+	if err = it.Error(); err != nil {
+		_ = it.Close()
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = it.Close(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := linksResponse{Links: make([]linkResponse, 0, len(page))}
+	if len(page) > limit {
+		page = page[:limit]
+		res.NextCursor = page[limit-1].ID.String()
+	}
+	for _, link := range page {
+		lr := linkResponse{
+			ID:          link.ID,
+			URL:         link.URL,
+			Namespace:   link.Namespace,
+			Status:      linkStatus(link, now),
+			RetrievedAt: unixTime(link.RetrievedAt),
+			NextFetchAt: unixTime(link.NextFetchAt),
+			FirstPassID: link.FirstPassID,
+			PassID:      link.PassID,
+		}
+		if deadURLs != nil {
+			dead := deadURLs[link.URL]
+			lr.Dead = &dead
+		}
+		res.Links = append(res.Links, lr)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// deadURLs returns the set of URLs whose most recent crawl error indicates a
+// dead link or nil if no crawl error store is available.
+func (h *Handler) deadURLs() map[string]bool {
+	if h.cfg.Errors == nil {
+		return nil
+	}
+	dead := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, rec := range h.cfg.Errors.Records(errstore.Filter{}, 0) {
+		// Records are ordered from the most to the least recently seen.
+		if seen[rec.URL] {
+			continue
+		}
+		seen[rec.URL] = true
+		if rec.Class.Dead() {
+			dead[rec.URL] = true
+		}
+	}
+	return dead
+}
+
+// parseLinksQuery parses the filter, cursor and limit query parameters of the
+// links endpoint.
+func (h *Handler) parseLinksQuery(w http.ResponseWriter, r *http.Request) (linkFilter, uuid.UUID, int, bool) {
+	q := r.URL.Query()
+	filter := linkFilter{
+		domain: strings.TrimSuffix(strings.ToLower(q.Get("domain")), "."),
+		status: LinkStatus(q.Get("status")),
+	}
+	switch filter.status {
+	case "", LinkPending, LinkScheduled, LinkDue:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown status %q; expected %q, %q or %q", filter.status, LinkPending, LinkScheduled, LinkDue))
+		return filter, uuid.Nil, 0, false
+	}
+
+	for param, to := range map[string]*int64{"retrievedAfter": &filter.retrievedAfter, "retrievedBefore": &filter.retrievedBefore} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be an RFC 3339 timestamp", param))
+				return filter, uuid.Nil, 0, false
+			}
+			*to = t.Unix()
+		}
+	}
+
+	if v := q.Get("dead"); v != "" {
+		dead, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "dead must be a boolean")
+			return filter, uuid.Nil, 0, false
+		}
+		if h.cfg.Errors == nil {
+			writeError(w, http.StatusBadRequest, "the dead filter requires a crawl error store")
+			return filter, uuid.Nil, 0, false
+		}
+		filter.dead = &dead
+	}
+
+	cursor := uuid.Nil
+	if v := q.Get("cursor"); v != "" {
+		var err error
+		if cursor, err = uuid.Parse(v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return filter, uuid.Nil, 0, false
+		}
+	}
+
+	limit := defaultLinksLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxLinksLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLinksLimit))
+			return filter, uuid.Nil, 0, false
+		}
+		limit = n
+	}
+	return filter, cursor, limit, true
+}
+
+// nextUUID returns the UUID that immediately follows id.
+func nextUUID(id uuid.UUID) uuid.UUID {
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i]++
+		if id[i] != 0 {
+			break
+		}
+	}
+	return id
+}
+
+// unixTime converts a unix timestamp to a UTC time or nil if ts is zero.
+func unixTime(ts int64) *time.Time {
+	if ts == 0 {
+		return nil
+	}
+	t := time.Unix(ts, 0).UTC()
+	return &t
+}
@@ -0,0 +1,273 @@
+package frontend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/frontend/access"
+)
+
+const (
+	// apiPrefix is the path prefix of the versioned JSON API.
+	apiPrefix = "/api/v1"
+
+	// maxAPIPageSize is the maximum number of results that API clients can
+	// request per page.
+	maxAPIPageSize = 100
+
+	// defaultSuggestLimit and maxSuggestLimit are the default and maximum
+	// number of autocomplete suggestions returned by the API.
+	defaultSuggestLimit = 8
+	maxSuggestLimit     = 20
+)
+
+// API error codes included in structured error responses.
+const (
+	apiErrInvalidArgument  = "invalid_argument"
+	apiErrNotFound         = "not_found"
+	apiErrNotAcceptable    = "not_acceptable"
+	apiErrUnsupportedMedia = "unsupported_media_type"
+	apiErrUnavailable      = "unavailable"
+	apiErrUnauthenticated  = "unauthenticated"
+	apiErrInternal         = "internal"
+)
+
+// apiError describes an error returned by the JSON API.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiErrorResponse is the body of all API error responses.
+type apiErrorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// apiSearchResponse describes a page of search results returned by the API.
+type apiSearchResponse struct {
+	Query         string         `json:"query"`
+	Vertical      string         `json:"vertical,omitempty"`
+	TotalResults  uint64         `json:"totalResults"`
+	Results       []searchResult `json:"results"`
+	Suggestions   []string       `json:"suggestions,omitempty"`
+	NextPageToken string         `json:"nextPageToken,omitempty"`
+}
+
+// apiSuggestResponse describes the autocomplete suggestions for a prefix.
+type apiSuggestResponse struct {
+	Query       string   `json:"query"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// apiLinkRequest describes a link submission request.
+type apiLinkRequest struct {
+	URL string `json:"url"`
+}
+
+// pageToken encodes the position of a page within the results of a query.
+// Tokens are bound to the query and vertical they were issued for.
+type pageToken struct {
+	Query    string `json:"q"`
+	Vertical string `json:"v,omitempty"`
+	Offset   uint64 `json:"o"`
+}
+
+func (t pageToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(token string) (pageToken, bool) {
+	var t pageToken
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(data, &t) != nil {
+		return pageToken{}, false
+	}
+	return t, true
+}
+
+func (h *Handler) registerAPIRoutes() {
+	h.mux.HandleFunc("GET "+apiPrefix+"/search", h.apiSearch)
+	h.mux.HandleFunc("GET "+apiPrefix+"/suggest", h.apiSuggest)
+	h.mux.HandleFunc("POST "+apiPrefix+"/links", h.apiSubmitLink)
+	h.mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, _ *http.Request) {
+		writeAPIError(w, http.StatusNotFound, apiErrNotFound, "unknown API endpoint")
+	})
+}
+
+// apiSearch handles search requests. The query is specified via the "q"
+// parameter, the search vertical via the optional "vertical" parameter and
+// the page size via the optional "limit" parameter. Clients obtain
+// subsequent pages by passing the nextPageToken value of a response as the
+// "pageToken" parameter of the next request.
+func (h *Handler) apiSearch(w http.ResponseWriter, r *http.Request) {
+	if !acceptsJSON(r) {
+		writeAPIError(w, http.StatusNotAcceptable, apiErrNotAcceptable, "responses are only available as application/json")
+		return
+	}
+
+	params := r.URL.Query()
+	queryText := strings.TrimSpace(params.Get("q"))
+	if queryText == "" {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "missing search query")
+		return
+	}
+
+	vertical, ok := parseVertical(params.Get("vertical"))
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "vertical must be one of: "+strings.Join(index.Verticals, ", "))
+		return
+	}
+
+	limit := h.cfg.ResultsPerPage
+	if l := params.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > maxAPIPageSize {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "limit must be between 1 and "+strconv.Itoa(maxAPIPageSize))
+			return
+		}
+	}
+
+	var offset uint64
+	if token := params.Get("pageToken"); token != "" {
+		t, ok := decodePageToken(token)
+		if !ok || t.Query != queryText || t.Vertical != vertical {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "invalid page token")
+			return
+		}
+		offset = t.Offset
+	}
+
+	query := newSearchQuery(queryText, vertical, offset)
+	query.Size = uint64(limit)
+	it, err := h.cfg.IndexAPI.Search(query)
+	if errors.Is(err, index.ErrOffsetTooLarge) {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "page token points past the deepest searchable page")
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "search failed")
+		return
+	}
+	defer func() { _ = it.Close() }()
+
+	res := apiSearchResponse{
+		Query:        queryText,
+		Vertical:     vertical,
+		TotalResults: it.TotalCount(),
+		Results:      []searchResult{},
+		Suggestions:  suggestedQueries(query, it.Suggestions()),
+	}
+	terms, fields := queryTerms(queryText), visibleFields(r)
+	for len(res.Results) < limit && it.Next() {
+		res.Results = append(res.Results, newSearchResult(it.Document(), terms, h.cfg.MaxSnippetLength, fields))
+	}
+	if err = it.Error(); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "search failed")
+		return
+	}
+
+	if next := offset + uint64(len(res.Results)); len(res.Results) == limit && next < res.TotalResults {
+		res.NextPageToken = pageToken{Query: queryText, Vertical: vertical, Offset: next}.encode()
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// apiSuggest returns the titles of indexed documents that complete the
+// partially typed query passed via the "q" parameter. The number of
+// suggestions can be specified via the optional "limit" parameter. Clients
+// that may not see document titles receive no suggestions.
+func (h *Handler) apiSuggest(w http.ResponseWriter, r *http.Request) {
+	if !acceptsJSON(r) {
+		writeAPIError(w, http.StatusNotAcceptable, apiErrNotAcceptable, "responses are only available as application/json")
+		return
+	}
+
+	params := r.URL.Query()
+	prefix := strings.TrimSpace(params.Get("q"))
+	if prefix == "" {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "missing search query")
+		return
+	}
+
+	limit := defaultSuggestLimit
+	if l := params.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > maxSuggestLimit {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "limit must be between 1 and "+strconv.Itoa(maxSuggestLimit))
+			return
+		}
+	}
+
+	res := apiSuggestResponse{Query: prefix, Suggestions: []string{}}
+	if visibleFields(r).Has(access.FieldTitle) {
+		titles, err := h.cfg.IndexAPI.Suggest(prefix, limit)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "suggest failed")
+			return
+		}
+		res.Suggestions = append(res.Suggestions, titles...)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// apiSubmitLink handles link submission requests. The request body must be
+// a JSON object with a "url" field.
+func (h *Handler) apiSubmitLink(w http.ResponseWriter, r *http.Request) {
+	if !acceptsJSON(r) {
+		writeAPIError(w, http.StatusNotAcceptable, apiErrNotAcceptable, "responses are only available as application/json")
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, apiErrUnsupportedMedia, "request body must be application/json")
+		return
+	}
+
+	var req apiLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "invalid request body")
+		return
+	}
+
+	linkURL, err := h.normalizeSubmittedURL(req.URL)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, err.Error())
+		return
+	}
+
+	link := &graph.Link{URL: linkURL}
+	if err = h.cfg.GraphAPI.UpsertLink(link); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "unable to submit link")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, submitResponse{LinkID: link.ID, URL: link.URL})
+}
+
+// acceptsJSON returns true if the client accepts JSON responses. Requests
+// without an Accept header are assumed to accept any media type.
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			return true
+		}
+	}
+	return false
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, apiErrorResponse{Error: apiError{Code: code, Message: msg}})
+}
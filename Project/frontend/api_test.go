@@ -0,0 +1,473 @@
+package frontend
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/frontend/access"
+	"webcrawler/frontend/feeds"
+	"webcrawler/pagerank/history"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *FrontendTestSuite) TestAPISearchPagination(c *gc.C) {
+	for i := 0; i < 5; i++ {
+		c.Assert(s.idx.Index(&index.Document{
+			LinkID:  uuid.New(),
+			URL:     fmt.Sprintf("http://example.com/%d", i),
+			Content: "Ovidius poeta in terra pontica",
+		}), gc.IsNil)
+	}
+
+	seen := make(map[string]bool)
+	var token string
+	for pages := 1; ; pages++ {
+		params := url.Values{"q": {"poeta"}, "limit": {"2"}}
+		if token != "" {
+			params.Set("pageToken", token)
+		}
+		rec := s.do(httptest.NewRequest(http.MethodGet, "/api/v1/search?"+params.Encode(), nil))
+		c.Assert(rec.Code, gc.Equals, http.StatusOK)
+		c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "application/json")
+
+		var res apiSearchResponse
+		c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+		c.Assert(res.TotalResults, gc.Equals, uint64(5))
+		for _, r := range res.Results {
+			c.Assert(seen[r.URL], gc.Equals, false, gc.Commentf("duplicate result %q", r.URL))
+			seen[r.URL] = true
+		}
+
+		if token = res.NextPageToken; token == "" {
+			c.Assert(pages, gc.Equals, 3)
+			break
+		}
+	}
+	c.Assert(seen, gc.HasLen, 5)
+}
+
+func (s *FrontendTestSuite) TestAPISearchVertical(c *gc.C) {
+	docs := []*index.Document{
+		{LinkID: uuid.New(), URL: "http://example.com/a", Content: "annual report"},
+		{LinkID: uuid.New(), URL: "http://example.com/b.pdf", Content: "annual report", Vertical: index.VerticalDocuments},
+		{LinkID: uuid.New(), URL: "http://example.com/c.pdf", Content: "annual report", Vertical: index.VerticalDocuments},
+	}
+	for _, doc := range docs {
+		c.Assert(s.idx.Index(doc), gc.IsNil)
+	}
+
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/api/v1/search?q=report&vertical=Documents&limit=1", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	var res apiSearchResponse
+	c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+	c.Assert(res.Vertical, gc.Equals, index.VerticalDocuments)
+	c.Assert(res.TotalResults, gc.Equals, uint64(2))
+	c.Assert(res.Results, gc.HasLen, 1)
+	c.Assert(res.Results[0].Vertical, gc.Equals, index.VerticalDocuments)
+	c.Assert(res.NextPageToken, gc.Not(gc.Equals), "")
+
+	// Page tokens are bound to the vertical they were issued for.
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/api/v1/search?q=report&limit=1&pageToken="+res.NextPageToken, nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusBadRequest)
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/api/v1/search?q=report&vertical=documents&limit=1&pageToken="+res.NextPageToken, nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+}
+
+func (s *FrontendTestSuite) TestAPISearchSuggestions(c *gc.C) {
+	c.Assert(s.idx.Index(&index.Document{
+		LinkID:  uuid.New(),
+		URL:     "http://example.com/a",
+		Content: "Ovidius poeta in terra pontica",
+	}), gc.IsNil)
+
+	specs := []struct {
+		query string
+		exp   []string
+	}{
+		{query: "ovidus", exp: []string{"ovidius"}},
+		{query: `"ovidius poetta"`, exp: []string{`"ovidius poeta"`}},
+		{query: "ovidius", exp: nil},
+	}
+	for _, spec := range specs {
+		rec := s.do(httptest.NewRequest(http.MethodGet, "/api/v1/search?"+url.Values{"q": {spec.query}}.Encode(), nil))
+		c.Assert(rec.Code, gc.Equals, http.StatusOK)
+		var res apiSearchResponse
+		c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+		c.Assert(res.Suggestions, gc.DeepEquals, spec.exp, gc.Commentf("query %q", spec.query))
+	}
+
+	// The HTML results page links to the suggested queries.
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/search?q=ovidus", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), gc.Matches, `(?s).*Did you mean <a href="/search\?q=ovidius">ovidius</a>\?.*`)
+}
+
+func (s *FrontendTestSuite) TestAPISuggest(c *gc.C) {
+	for i, title := range []string{"Tristia liber primus", "Tristia liber secundus", "Metamorphoses"} {
+		id := uuid.New()
+		c.Assert(s.idx.Index(&index.Document{
+			LinkID:  id,
+			URL:     fmt.Sprintf("http://example.com/%d", i),
+			Title:   title,
+			Content: "Ovidius poeta",
+		}), gc.IsNil)
+		c.Assert(s.idx.UpdateScore(id, float64(3-i)), gc.IsNil)
+	}
+
+	specs := []struct {
+		target string
+		exp    []string
+	}{
+		{target: "/api/v1/suggest?q=tri", exp: []string{"Tristia liber primus", "Tristia liber secundus"}},
+		{target: "/api/v1/suggest?q=tristia+s&limit=5", exp: []string{"Tristia liber secundus"}},
+		{target: "/api/v1/suggest?q=tri&limit=1", exp: []string{"Tristia liber primus"}},
+		{target: "/api/v1/suggest?q=qwx", exp: []string{}},
+	}
+	for _, spec := range specs {
+		rec := s.do(httptest.NewRequest(http.MethodGet, spec.target, nil))
+		c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf("target %q", spec.target))
+		var res apiSuggestResponse
+		c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+		c.Assert(res.Suggestions, gc.DeepEquals, spec.exp, gc.Commentf("target %q", spec.target))
+	}
+
+	for _, target := range []string{"/api/v1/suggest", "/api/v1/suggest?q=tri&limit=0", "/api/v1/suggest?q=tri&limit=21"} {
+		rec := s.do(httptest.NewRequest(http.MethodGet, target, nil))
+		c.Assert(rec.Code, gc.Equals, http.StatusBadRequest, gc.Commentf("target %q", target))
+	}
+}
+
+func (s *FrontendTestSuite) TestAPISearchFieldRedaction(c *gc.C) {
+	linkID := uuid.New()
+	c.Assert(s.idx.Index(&index.Document{
+		LinkID:  linkID,
+		URL:     "http://example.com/a",
+		Title:   "Tristia",
+		Content: "Ovidius poeta in terra pontica",
+		Headers: map[string]string{"Server": "nginx"},
+	}), gc.IsNil)
+	c.Assert(s.idx.UpdateScore(linkID, 0.5), gc.IsNil)
+
+	search := func(apiKey string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=poeta", nil)
+		if apiKey != "" {
+			req.Header.Set(access.HeaderAPIKey, apiKey)
+		}
+		rec := s.do(req)
+		c.Assert(rec.Code, gc.Equals, http.StatusOK)
+
+		var res struct {
+			Results []map[string]interface{} `json:"results"`
+		}
+		c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+		c.Assert(res.Results, gc.HasLen, 1)
+		return res.Results[0]
+	}
+	keysOf := func(m map[string]interface{}) []string {
+		var keys []string
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	// Without a policy only the default public fields are included.
+	res := search("")
+	c.Assert(keysOf(res), gc.DeepEquals, []string{"linkID", "snippet", "title", "url", "vertical"})
+
+	policy, err := access.NewPolicy(access.Config{
+		PublicFields: []string{access.FieldURL, access.FieldTitle},
+		Keys:         map[string][]string{"k3y": {access.FieldContent, access.FieldPageRank}},
+	})
+	c.Assert(err, gc.IsNil)
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, ResultsPerPage: 2, Access: policy})
+	c.Assert(err, gc.IsNil)
+
+	res = search("")
+	c.Assert(res, gc.DeepEquals, map[string]interface{}{"url": "http://example.com/a", "title": "Tristia"})
+
+	res = search("k3y")
+	c.Assert(keysOf(res), gc.DeepEquals, []string{"content", "pageRank", "title", "url"})
+	c.Assert(res["content"], gc.Equals, "Ovidius poeta in terra pontica")
+	c.Assert(res["pageRank"], gc.Equals, 0.5)
+
+	// Requests with unknown API keys are rejected.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=poeta", nil)
+	req.Header.Set(access.HeaderAPIKey, "unknown")
+	rec := s.do(req)
+	c.Assert(rec.Code, gc.Equals, http.StatusUnauthorized)
+	var apiErr apiErrorResponse
+	c.Assert(json.NewDecoder(rec.Body).Decode(&apiErr), gc.IsNil)
+	c.Assert(apiErr.Error.Code, gc.Equals, apiErrUnauthenticated)
+}
+
+func (s *FrontendTestSuite) TestAPISearchErrors(c *gc.C) {
+	token := pageToken{Query: "other", Offset: 2}.encode()
+	deepToken := pageToken{Query: "foo", Offset: index.DefaultMaxOffset + 1}.encode()
+	specs := []struct {
+		target string
+		accept string
+		status int
+		code   string
+	}{
+		{target: "/api/v1/search", status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/search?q=foo&limit=0", status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/search?q=foo&limit=1000", status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/search?q=foo&vertical=images", status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/search?q=foo&pageToken=%21%21", status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/search?q=foo&pageToken=" + token, status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/search?q=foo&pageToken=" + deepToken, status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/search?q=foo", accept: "text/html", status: http.StatusNotAcceptable, code: apiErrNotAcceptable},
+		{target: "/api/v1/unknown", status: http.StatusNotFound, code: apiErrNotFound},
+	}
+
+	for _, spec := range specs {
+		req := httptest.NewRequest(http.MethodGet, spec.target, nil)
+		if spec.accept != "" {
+			req.Header.Set("Accept", spec.accept)
+		}
+		rec := s.do(req)
+		c.Assert(rec.Code, gc.Equals, spec.status, gc.Commentf("target %q", spec.target))
+
+		var res apiErrorResponse
+		c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+		c.Assert(res.Error.Code, gc.Equals, spec.code, gc.Commentf("target %q", spec.target))
+		c.Assert(res.Error.Message, gc.Not(gc.Equals), "")
+	}
+}
+
+func (s *FrontendTestSuite) TestAPISubmitLink(c *gc.C) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"url": "HTTPS://Example.com/a#b"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Accept", "text/html;q=0.9, application/json")
+	rec := s.do(req)
+	c.Assert(rec.Code, gc.Equals, http.StatusAccepted)
+
+	var res submitResponse
+	c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+	c.Assert(res.URL, gc.Equals, "https://example.com/a")
+	link, err := s.g.FindLink(res.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(link.URL, gc.Equals, res.URL)
+}
+
+func (s *FrontendTestSuite) TestAPISubmitLinkErrors(c *gc.C) {
+	specs := []struct {
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{contentType: "application/x-www-form-urlencoded", body: "url=http://example.com", status: http.StatusUnsupportedMediaType, code: apiErrUnsupportedMedia},
+		{contentType: "application/json", body: "{", status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{contentType: "application/json", body: `{"url": "ftp://example.com"}`, status: http.StatusBadRequest, code: apiErrInvalidArgument},
+	}
+
+	for _, spec := range specs {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(spec.body))
+		req.Header.Set("Content-Type", spec.contentType)
+		rec := s.do(req)
+		c.Assert(rec.Code, gc.Equals, spec.status, gc.Commentf("body %q", spec.body))
+
+		var res apiErrorResponse
+		c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+		c.Assert(res.Error.Code, gc.Equals, spec.code)
+	}
+}
+
+func (s *FrontendTestSuite) TestAPIScoreTrend(c *gc.C) {
+	hist := history.NewMemoryStore()
+	linkID := uuid.New()
+	start := time.Now().Add(-3 * time.Hour).UTC().Truncate(time.Second)
+	for i, score := range []float64{0.25, 0.5, 0.75} {
+		_, err := hist.RecordPass(start.Add(time.Duration(i)*time.Hour), []history.LinkScore{
+			{LinkID: linkID, Host: "example.com", Score: score},
+			{LinkID: uuid.New(), Host: "example.com", Score: 0.25 - score/4},
+		})
+		c.Assert(err, gc.IsNil)
+	}
+
+	var err error
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, ScoreHistory: hist})
+	c.Assert(err, gc.IsNil)
+
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/api/v1/links/"+linkID.String()+"/pagerank?since=150m", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf(rec.Body.String()))
+	var res apiScoreTrendResponse
+	c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+	c.Assert(*res.LinkID, gc.Equals, linkID)
+	c.Assert(res.Points, gc.HasLen, 2)
+	c.Assert(res.Change, gc.Equals, 0.25)
+	c.Assert(res.Direction, gc.Equals, trendRising)
+
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/api/v1/domains/Example.com/pagerank?since="+url.QueryEscape(start.Format(time.RFC3339)), nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf(rec.Body.String()))
+	res = apiScoreTrendResponse{}
+	c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+	c.Assert(res.Domain, gc.Equals, "example.com")
+	c.Assert(res.Points, gc.HasLen, 3)
+	c.Assert(res.Points[0].Links, gc.Equals, 2)
+	c.Assert(res.Direction, gc.Equals, trendRising)
+
+	specs := []struct {
+		target string
+		status int
+		code   string
+	}{
+		{target: "/api/v1/links/foo/pagerank", status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/links/" + linkID.String() + "/pagerank?since=yesterday", status: http.StatusBadRequest, code: apiErrInvalidArgument},
+		{target: "/api/v1/links/" + uuid.New().String() + "/pagerank", status: http.StatusNotFound, code: apiErrNotFound},
+		{target: "/api/v1/domains/unknown.com/pagerank", status: http.StatusNotFound, code: apiErrNotFound},
+	}
+	for _, spec := range specs {
+		rec := s.do(httptest.NewRequest(http.MethodGet, spec.target, nil))
+		c.Assert(rec.Code, gc.Equals, spec.status, gc.Commentf("target %q", spec.target))
+		var errRes apiErrorResponse
+		c.Assert(json.NewDecoder(rec.Body).Decode(&errRes), gc.IsNil)
+		c.Assert(errRes.Error.Code, gc.Equals, spec.code, gc.Commentf("target %q", spec.target))
+	}
+}
+
+func (s *FrontendTestSuite) TestAPIFeeds(c *gc.C) {
+	// The endpoints are only served if feeds are configured.
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/api/v1/feeds/top", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound)
+
+	for i, vertical := range []string{"", "", index.VerticalNews} {
+		linkID := uuid.New()
+		c.Assert(s.idx.Index(&index.Document{LinkID: linkID, URL: fmt.Sprintf("http://example.com/%d", i), Vertical: vertical}), gc.IsNil)
+		c.Assert(s.idx.UpdateScore(linkID, float64(i)), gc.IsNil)
+	}
+	builder, err := feeds.NewBuilder(feeds.Config{IndexAPI: s.idx})
+	c.Assert(err, gc.IsNil)
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, ResultsPerPage: 2, Feeds: builder})
+	c.Assert(err, gc.IsNil)
+
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/api/v1/feeds/recent", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusServiceUnavailable)
+	var errRes apiErrorResponse
+	c.Assert(json.NewDecoder(rec.Body).Decode(&errRes), gc.IsNil)
+	c.Assert(errRes.Error.Code, gc.Equals, apiErrUnavailable)
+
+	c.Assert(builder.Refresh(), gc.IsNil)
+	specs := []struct {
+		target string
+		exp    []string
+	}{
+		{target: "/api/v1/feeds/top", exp: []string{"http://example.com/2", "http://example.com/1"}},
+		{target: "/api/v1/feeds/top?limit=3&domain=Example.com", exp: []string{"http://example.com/2", "http://example.com/1", "http://example.com/0"}},
+		{target: "/api/v1/feeds/top?vertical=web", exp: []string{"http://example.com/1", "http://example.com/0"}},
+		{target: "/api/v1/feeds/recent?vertical=news", exp: []string{"http://example.com/2"}},
+	}
+	for _, spec := range specs {
+		rec := s.do(httptest.NewRequest(http.MethodGet, spec.target, nil))
+		c.Assert(rec.Code, gc.Equals, http.StatusOK, gc.Commentf("target %q", spec.target))
+		var feed feeds.Feed
+		c.Assert(json.NewDecoder(rec.Body).Decode(&feed), gc.IsNil)
+		var urls []string
+		for _, page := range feed.Pages {
+			urls = append(urls, page.URL)
+		}
+		c.Assert(urls, gc.DeepEquals, spec.exp, gc.Commentf("target %q", spec.target))
+	}
+
+	for _, target := range []string{
+		"/api/v1/feeds/top?domain=example.com&vertical=web",
+		"/api/v1/feeds/top?limit=0",
+		"/api/v1/feeds/recent?vertical=video",
+	} {
+		rec := s.do(httptest.NewRequest(http.MethodGet, target, nil))
+		c.Assert(rec.Code, gc.Equals, http.StatusBadRequest, gc.Commentf("target %q", target))
+	}
+}
+
+func (s *FrontendTestSuite) TestAPIChangeFeeds(c *gc.C) {
+	for i := 0; i < 3; i++ {
+		c.Assert(s.idx.Index(&index.Document{LinkID: uuid.New(), URL: fmt.Sprintf("http://example.com/%d", i), Title: fmt.Sprintf("page %d", i)}), gc.IsNil)
+		time.Sleep(time.Millisecond)
+	}
+	builder, err := feeds.NewBuilder(feeds.Config{IndexAPI: s.idx})
+	c.Assert(err, gc.IsNil)
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, ResultsPerPage: 2, Feeds: builder})
+	c.Assert(err, gc.IsNil)
+	c.Assert(builder.Refresh(), gc.IsNil)
+
+	// Follow the next links of the Atom feed.
+	var titles []string
+	target := "http://frontend.local/api/v1/feeds/changes.atom?domain=example.com"
+	for pages := 0; target != ""; pages++ {
+		c.Assert(pages < 2, gc.Equals, true, gc.Commentf("expected the feed to have two pages"))
+		rec := s.do(httptest.NewRequest(http.MethodGet, target, nil))
+		c.Assert(rec.Code, gc.Equals, http.StatusOK)
+		c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "application/atom+xml; charset=utf-8")
+
+		var feed atomFeed
+		c.Assert(xml.NewDecoder(rec.Body).Decode(&feed), gc.IsNil)
+		c.Assert(feed.ID, gc.Equals, "http://frontend.local/api/v1/feeds/changes.atom?domain=example.com")
+		c.Assert(feed.Title, gc.Equals, "Recently indexed pages on example.com")
+		target = ""
+		for _, link := range feed.Links {
+			if link.Rel == "next" {
+				target = link.Href
+			}
+		}
+		for _, entry := range feed.Entries {
+			c.Assert(strings.HasPrefix(entry.ID, "urn:uuid:"), gc.Equals, true)
+			titles = append(titles, entry.Title)
+		}
+	}
+	c.Assert(titles, gc.DeepEquals, []string{"page 2", "page 1", "page 0"})
+
+	rec := s.do(httptest.NewRequest(http.MethodGet, "http://frontend.local/api/v1/feeds/changes.json?limit=1", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "application/feed+json")
+	var feed jsonFeed
+	c.Assert(json.NewDecoder(rec.Body).Decode(&feed), gc.IsNil)
+	c.Assert(feed.Version, gc.Equals, jsonFeedVersion)
+	c.Assert(feed.FeedURL, gc.Equals, "http://frontend.local/api/v1/feeds/changes.json?limit=1")
+	c.Assert(feed.Items, gc.HasLen, 1)
+	c.Assert(feed.Items[0].URL, gc.Equals, "http://example.com/2")
+	c.Assert(feed.Items[0].ContentText, gc.Equals, "page 2")
+	next, err := url.Parse(feed.NextURL)
+	c.Assert(err, gc.IsNil)
+	c.Assert(next.Query().Get("cursor"), gc.Not(gc.Equals), "")
+	c.Assert(next.Query().Get("limit"), gc.Equals, "1")
+
+	for _, target := range []string{
+		"/api/v1/feeds/changes.json?namespace=default&domain=example.com",
+		"/api/v1/feeds/changes.json?cursor=not-a-cursor",
+		"/api/v1/feeds/changes.atom?limit=0",
+	} {
+		rec := s.do(httptest.NewRequest(http.MethodGet, target, nil))
+		c.Assert(rec.Code, gc.Equals, http.StatusBadRequest, gc.Commentf("target %q", target))
+	}
+}
+
+func (s *FrontendTestSuite) TestScoreTrendDirection(c *gc.C) {
+	specs := []struct {
+		scores []float64
+		exp    string
+	}{
+		{scores: []float64{0.5}, exp: trendStable},
+		{scores: []float64{0.5, 0.502}, exp: trendStable},
+		{scores: []float64{0.5, 0.4}, exp: trendFalling},
+		{scores: []float64{0, 0.1}, exp: trendRising},
+	}
+	for _, spec := range specs {
+		var points []history.Point
+		for _, score := range spec.scores {
+			points = append(points, history.Point{Score: score})
+		}
+		c.Assert(newScoreTrendResponse(nil, "example.com", points).Direction, gc.Equals, spec.exp, gc.Commentf("scores %v", spec.scores))
+	}
+}
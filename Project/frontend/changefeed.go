@@ -0,0 +1,203 @@
+package frontend
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"webcrawler/frontend/feeds"
+)
+
+// The identifier of the JSON Feed version of the change feeds.
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// atomFeed describes an Atom (RFC 4287) feed document.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+// jsonFeed describes a JSON Feed document.
+type jsonFeed struct {
+	Version string         `json:"version"`
+	Title   string         `json:"title"`
+	FeedURL string         `json:"feed_url"`
+	NextURL string         `json:"next_url,omitempty"`
+	Items   []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	Title        string    `json:"title,omitempty"`
+	Summary      string    `json:"summary,omitempty"`
+	ContentText  string    `json:"content_text"`
+	DateModified time.Time `json:"date_modified"`
+}
+
+// apiChangesAtom returns a page of the change feed as an Atom feed.
+func (h *Handler) apiChangesAtom(w http.ResponseWriter, r *http.Request) {
+	filter, feed, ok := h.changeFeed(w, r)
+	if !ok {
+		return
+	}
+
+	// The feed is identified by the URL of its first page.
+	doc := atomFeed{
+		ID:      requestURL(r, "").String(),
+		Title:   changeFeedTitle(filter),
+		Updated: feed.GeneratedAt.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "webcrawler"},
+		Links:   []atomLink{{Rel: "self", Href: requestURL(r, r.URL.Query().Get("cursor")).String()}},
+		Entries: make([]atomEntry, 0, len(feed.Pages)),
+	}
+	if feed.NextCursor != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "next", Href: requestURL(r, feed.NextCursor).String()})
+	}
+	for _, page := range feed.Pages {
+		title := page.Title
+		if title == "" {
+			title = page.URL
+		}
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:      "urn:uuid:" + page.LinkID.String(),
+			Title:   title,
+			Link:    atomLink{Href: page.URL},
+			Updated: page.IndexedAt.UTC().Format(time.RFC3339Nano),
+			Summary: page.Summary,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(doc)
+}
+
+// apiChangesJSON returns a page of the change feed as a JSON Feed.
+func (h *Handler) apiChangesJSON(w http.ResponseWriter, r *http.Request) {
+	filter, feed, ok := h.changeFeed(w, r)
+	if !ok {
+		return
+	}
+
+	doc := jsonFeed{
+		Version: jsonFeedVersion,
+		Title:   changeFeedTitle(filter),
+		FeedURL: requestURL(r, "").String(),
+		Items:   make([]jsonFeedItem, 0, len(feed.Pages)),
+	}
+	if feed.NextCursor != "" {
+		doc.NextURL = requestURL(r, feed.NextCursor).String()
+	}
+	for _, page := range feed.Pages {
+		// Items must have either an HTML or a text content.
+		content := page.Summary
+		if content == "" {
+			content = page.Title
+		}
+		if content == "" {
+			content = page.URL
+		}
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:           page.LinkID.String(),
+			URL:          page.URL,
+			Title:        page.Title,
+			Summary:      page.Summary,
+			ContentText:  content,
+			DateModified: page.IndexedAt.UTC(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/feed+json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+// changeFeed parses the "namespace", "domain", "cursor" and "limit"
+// parameters of a change feed request and retrieves the requested page of
+// the feed. If the request cannot be served, an error response is written
+// and false is returned.
+func (h *Handler) changeFeed(w http.ResponseWriter, r *http.Request) (feeds.ChangeFilter, *feeds.ChangeFeed, bool) {
+	params := r.URL.Query()
+	filter := feeds.ChangeFilter{
+		Namespace: strings.TrimSpace(params.Get("namespace")),
+		Domain:    strings.ToLower(strings.TrimSpace(params.Get("domain"))),
+	}
+	if filter.Namespace != "" && filter.Domain != "" {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "namespace and domain cannot be combined")
+		return filter, nil, false
+	}
+	limit, ok := h.parseFeedLimit(w, r)
+	if !ok {
+		return filter, nil, false
+	}
+
+	feed, err := h.cfg.Feeds.Changes(filter, params.Get("cursor"), limit)
+	switch {
+	case errors.Is(err, feeds.ErrInvalidCursor):
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "invalid cursor")
+	case errors.Is(err, feeds.ErrNotReady):
+		writeAPIError(w, http.StatusServiceUnavailable, apiErrUnavailable, "feeds have not been generated yet")
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "unable to retrieve feed")
+	default:
+		return filter, feed, true
+	}
+	return filter, nil, false
+}
+
+func changeFeedTitle(filter feeds.ChangeFilter) string {
+	switch {
+	case filter.Namespace != "":
+		return "Recently indexed pages in " + filter.Namespace
+	case filter.Domain != "":
+		return "Recently indexed pages on " + filter.Domain
+	default:
+		return "Recently indexed pages"
+	}
+}
+
+// requestURL returns the absolute URL of r with the "cursor" parameter set to
+// cursor or removed if cursor is empty.
+func requestURL(r *http.Request, cursor string) *url.URL {
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	query := u.Query()
+	query.Del("cursor")
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	u.RawQuery = query.Encode()
+	return &u
+}
@@ -0,0 +1,99 @@
+package frontend
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/frontend/feeds"
+)
+
+// FeedsAPI defines the set of operations required by the page feed API.
+type FeedsAPI interface {
+	// TopPages returns up to limit pages with the highest PageRank
+	// scores that match f.
+	TopPages(f feeds.Filter, limit int) (*feeds.Feed, error)
+
+	// RecentPages returns up to limit of the most recently indexed pages
+	// in the specified search vertical or in all verticals if vertical
+	// is empty.
+	RecentPages(vertical string, limit int) (*feeds.Feed, error)
+
+	// Changes returns up to limit of the most recently indexed pages
+	// that match f, starting after the page that cursor refers to.
+	Changes(f feeds.ChangeFilter, cursor string, limit int) (*feeds.ChangeFeed, error)
+}
+
+func (h *Handler) registerFeedRoutes() {
+	h.mux.HandleFunc("GET "+apiPrefix+"/feeds/top", h.apiTopPages)
+	h.mux.HandleFunc("GET "+apiPrefix+"/feeds/recent", h.apiRecentPages)
+	h.mux.HandleFunc("GET "+apiPrefix+"/feeds/changes.atom", h.apiChangesAtom)
+	h.mux.HandleFunc("GET "+apiPrefix+"/feeds/changes.json", h.apiChangesJSON)
+}
+
+// apiTopPages returns the pages with the highest PageRank scores. The feed
+// can be restricted to a domain via the "domain" parameter or to a search
+// vertical via the "vertical" parameter.
+func (h *Handler) apiTopPages(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	domain := strings.ToLower(strings.TrimSpace(params.Get("domain")))
+	vertical, ok := parseVertical(params.Get("vertical"))
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "vertical must be one of: "+strings.Join(index.Verticals, ", "))
+		return
+	}
+	if domain != "" && vertical != "" {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "domain and vertical cannot be combined")
+		return
+	}
+	limit, ok := h.parseFeedLimit(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := h.cfg.Feeds.TopPages(feeds.Filter{Domain: domain, Vertical: vertical}, limit)
+	writeFeed(w, feed, err)
+}
+
+// apiRecentPages returns the most recently indexed pages. The feed can be
+// restricted to a search vertical via the "vertical" parameter.
+func (h *Handler) apiRecentPages(w http.ResponseWriter, r *http.Request) {
+	vertical, ok := parseVertical(r.URL.Query().Get("vertical"))
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "vertical must be one of: "+strings.Join(index.Verticals, ", "))
+		return
+	}
+	limit, ok := h.parseFeedLimit(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := h.cfg.Feeds.RecentPages(vertical, limit)
+	writeFeed(w, feed, err)
+}
+
+// parseFeedLimit parses the optional "limit" parameter which defaults to the
+// number of results per page.
+func (h *Handler) parseFeedLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	limit := h.cfg.ResultsPerPage
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > maxAPIPageSize {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "limit must be between 1 and "+strconv.Itoa(maxAPIPageSize))
+			return 0, false
+		}
+	}
+	return limit, true
+}
+
+func writeFeed(w http.ResponseWriter, feed *feeds.Feed, err error) {
+	switch {
+	case errors.Is(err, feeds.ErrNotReady):
+		writeAPIError(w, http.StatusServiceUnavailable, apiErrUnavailable, "feeds have not been generated yet")
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "unable to retrieve feed")
+	default:
+		writeJSON(w, http.StatusOK, feed)
+	}
+}
@@ -0,0 +1,456 @@
+// Package feeds materializes the query-less page feeds served on the home
+// page of the frontend:
+//
+//   - the top pages by PageRank score, overall and for each domain and
+//     search vertical;
+//   - the most recently indexed pages, overall and for each search vertical;
+//   - the change feeds of recently indexed or re-indexed pages, overall and
+//     for each namespace and domain, which can be paged through with a
+//     cursor so that downstream aggregators can consume the crawl output.
+//
+// Computing these feeds requires a pass over the entire index, so a Builder
+// periodically scans the index and atomically swaps in the new results.
+// Requests are served from the most recent results.
+package feeds
+
+import (
+	"container/heap"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrNotReady is returned by the feed queries until the feeds have been
+// generated for the first time.
+var ErrNotReady = errors.New("feeds have not been generated yet")
+
+// ErrInvalidCursor is returned by Changes for malformed cursors.
+var ErrInvalidCursor = errors.New("invalid feed cursor")
+
+// IndexAPI defines the set of text indexer operations required by the
+// Builder.
+type IndexAPI interface {
+	// All returns an iterator over every indexed document.
+	All(cursor index.Cursor) (index.DocumentIterator, error)
+}
+
+// Config encapsulates the settings for a Builder.
+type Config struct {
+	// The index to generate the feeds from.
+	IndexAPI IndexAPI
+
+	// How often the feeds are regenerated. Defaults to 10 minutes.
+	RefreshInterval time.Duration
+
+	// The number of pages kept for the overall, per-vertical and
+	// per-namespace feeds. Defaults to 100.
+	Size int
+
+	// The number of pages kept for each per-domain feed. Defaults to 10.
+	DomainSize int
+
+	// A clock for timestamping the generated feeds. Defaults to time.Now.
+	Clock func() time.Time
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.IndexAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("index API has not been provided"))
+	}
+	if cfg.RefreshInterval < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for refresh interval"))
+	} else if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = 10 * time.Minute
+	}
+	if cfg.Size < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for feed size"))
+	} else if cfg.Size == 0 {
+		cfg.Size = 100
+	}
+	if cfg.DomainSize < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for domain feed size"))
+	} else if cfg.DomainSize == 0 {
+		cfg.DomainSize = 10
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+// Page describes a page included in a feed.
+type Page struct {
+	LinkID    uuid.UUID `json:"linkID"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Domain    string    `json:"domain"`
+	Vertical  string    `json:"vertical"`
+	Namespace string    `json:"namespace,omitempty"`
+	Summary   string    `json:"summary,omitempty"`
+	PageRank  float64   `json:"pageRank"`
+	IndexedAt time.Time `json:"indexedAt"`
+}
+
+// Feed is a list of pages generated at a particular point in time.
+type Feed struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Pages       []Page    `json:"pages"`
+}
+
+// Filter selects the feed returned by TopPages. Zero-valued fields match all
+// pages; Domain and Vertical cannot be combined.
+type Filter struct {
+	Domain   string
+	Vertical string
+}
+
+// ChangeFilter selects the change feed returned by Changes. Zero-valued
+// fields match all pages; Namespace and Domain cannot be combined.
+type ChangeFilter struct {
+	Namespace string
+	Domain    string
+}
+
+// ChangeFeed is a page of a change feed.
+type ChangeFeed struct {
+	Feed
+
+	// The cursor for requesting the next (older) page or empty if this
+	// is the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// snapshot holds the feeds generated by a single pass over the index. Each
+// feed is sorted from the best to the worst ranked page.
+type snapshot struct {
+	generatedAt time.Time
+
+	top           []Page
+	topByDomain   map[string][]Page
+	topByVertical map[string][]Page
+
+	recent            []Page
+	recentByVertical  map[string][]Page
+	recentByNamespace map[string][]Page
+	recentByDomain    map[string][]Page
+}
+
+// Builder periodically generates the page feeds from the index.
+type Builder struct {
+	cfg    Config
+	logger *slog.Logger
+
+	mu   sync.RWMutex
+	snap *snapshot
+}
+
+// NewBuilder returns a new feed builder using the provided config.
+func NewBuilder(cfg Config) (*Builder, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("feed builder: config validation failed: %w", err)
+	}
+	return &Builder{cfg: cfg, logger: logging.Component(cfg.Logger, "frontend.feeds")}, nil
+}
+
+// Name returns the name of the builder when running as a service.
+func (b *Builder) Name() string { return "feeds" }
+
+// Run regenerates the feeds every RefreshInterval until ctx is cancelled.
+// Failures are logged and the previously generated feeds are kept.
+func (b *Builder) Run(ctx context.Context) error {
+	b.logger.Info("starting service", "refresh_interval", b.cfg.RefreshInterval)
+	defer b.logger.Info("stopped service")
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		if err := b.Refresh(); err != nil {
+			b.logger.Warn("unable to refresh feeds", "err", err)
+		}
+		timer.Reset(b.cfg.RefreshInterval)
+	}
+}
+
+// Refresh scans the index and replaces the served feeds with the results.
+// Placeholder documents that have not been crawled yet and near-duplicates
+// of other pages are excluded.
+func (b *Builder) Refresh() error {
+	startedAt := time.Now()
+	var (
+		top               = newTopK(b.cfg.Size, byPageRank)
+		topByDomain       = make(map[string]*topK)
+		topByVertical     = make(map[string]*topK)
+		recent            = newTopK(b.cfg.Size, byIndexedAt)
+		recentByVertical  = make(map[string]*topK)
+		recentByNamespace = make(map[string]*topK)
+		recentByDomain    = make(map[string]*topK)
+		scanned           int
+	)
+
+	it, err := b.cfg.IndexAPI.All("")
+	if err != nil {
+		return fmt.Errorf("refresh feeds: %w", err)
+	}
+	for it.Next() {
+		doc := it.Document()
+		if doc.URL == "" || doc.DuplicateOf != uuid.Nil {
+			continue
+		}
+		scanned++
+
+		page := newPage(doc)
+		top.add(page)
+		addTo(topByDomain, page.Domain, b.cfg.DomainSize, byPageRank, page)
+		addTo(topByVertical, page.Vertical, b.cfg.Size, byPageRank, page)
+		if !page.IndexedAt.IsZero() {
+			recent.add(page)
+			addTo(recentByVertical, page.Vertical, b.cfg.Size, byIndexedAt, page)
+			addTo(recentByNamespace, page.Namespace, b.cfg.Size, byIndexedAt, page)
+			addTo(recentByDomain, page.Domain, b.cfg.DomainSize, byIndexedAt, page)
+		}
+	}
+	if err = it.Error(); err != nil {
+		_ = it.Close()
+		return fmt.Errorf("refresh feeds: %w", err)
+	}
+	if err = it.Close(); err != nil {
+		return fmt.Errorf("refresh feeds: %w", err)
+	}
+
+	snap := &snapshot{
+		generatedAt:       b.cfg.Clock(),
+		top:               top.sorted(),
+		topByDomain:       sortedFeeds(topByDomain),
+		topByVertical:     sortedFeeds(topByVertical),
+		recent:            recent.sorted(),
+		recentByVertical:  sortedFeeds(recentByVertical),
+		recentByNamespace: sortedFeeds(recentByNamespace),
+		recentByDomain:    sortedFeeds(recentByDomain),
+	}
+	b.mu.Lock()
+	b.snap = snap
+	b.mu.Unlock()
+
+	b.logger.Info("refreshed feeds", "pages", scanned, "domains", len(topByDomain), "elapsed", time.Since(startedAt))
+	return nil
+}
+
+// TopPages returns up to limit pages with the highest PageRank scores that
+// match f. A non-positive limit returns the entire feed.
+func (b *Builder) TopPages(f Filter, limit int) (*Feed, error) {
+	if f.Domain != "" && f.Vertical != "" {
+		return nil, fmt.Errorf("top pages: domain and vertical filters cannot be combined")
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.snap == nil {
+		return nil, fmt.Errorf("top pages: %w", ErrNotReady)
+	}
+
+	pages := b.snap.top
+	switch {
+	case f.Domain != "":
+		pages = b.snap.topByDomain[strings.ToLower(f.Domain)]
+	case f.Vertical != "":
+		pages = b.snap.topByVertical[strings.ToLower(f.Vertical)]
+	}
+	return newFeed(b.snap.generatedAt, pages, limit), nil
+}
+
+// RecentPages returns up to limit of the most recently indexed pages in the
+// specified search vertical or in all verticals if vertical is empty. A
+// non-positive limit returns the entire feed.
+func (b *Builder) RecentPages(vertical string, limit int) (*Feed, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.snap == nil {
+		return nil, fmt.Errorf("recent pages: %w", ErrNotReady)
+	}
+
+	pages := b.snap.recent
+	if vertical != "" {
+		pages = b.snap.recentByVertical[strings.ToLower(vertical)]
+	}
+	return newFeed(b.snap.generatedAt, pages, limit), nil
+}
+
+// Changes returns up to limit of the most recently indexed (or re-indexed)
+// pages that match f, starting after the page that cursor refers to. An
+// empty cursor returns the first page of the feed. Cursors refer to a
+// position in the feed rather than to a page so that they remain valid
+// across refreshes. A non-positive limit returns the rest of the feed.
+func (b *Builder) Changes(f ChangeFilter, cursor string, limit int) (*ChangeFeed, error) {
+	if f.Namespace != "" && f.Domain != "" {
+		return nil, fmt.Errorf("changes: namespace and domain filters cannot be combined")
+	}
+	var after *Page
+	if cursor != "" {
+		page, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("changes: %w", err)
+		}
+		after = &page
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.snap == nil {
+		return nil, fmt.Errorf("changes: %w", ErrNotReady)
+	}
+
+	pages := b.snap.recent
+	switch {
+	case f.Namespace != "":
+		pages = b.snap.recentByNamespace[f.Namespace]
+	case f.Domain != "":
+		pages = b.snap.recentByDomain[strings.ToLower(f.Domain)]
+	}
+	if after != nil {
+		// Pages are sorted from the most to the least recently indexed
+		// one.
+		pages = pages[sort.Search(len(pages), func(i int) bool { return byIndexedAt(pages[i], *after) }):]
+	}
+
+	feed := &ChangeFeed{Feed: *newFeed(b.snap.generatedAt, pages, limit)}
+	if n := len(feed.Pages); n != 0 && n < len(pages) {
+		feed.NextCursor = encodeCursor(feed.Pages[n-1])
+	}
+	return feed, nil
+}
+
+// encodeCursor returns a cursor for the feed position that follows page.
+func encodeCursor(page Page) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(page.IndexedAt.UnixNano(), 10) + " " + page.URL))
+}
+
+// decodeCursor returns a page that holds the indexing time and URL that
+// cursor refers to.
+func decodeCursor(cursor string) (Page, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Page{}, ErrInvalidCursor
+	}
+	ts, pageURL, found := strings.Cut(string(data), " ")
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if !found || err != nil {
+		return Page{}, ErrInvalidCursor
+	}
+	return Page{URL: pageURL, IndexedAt: time.Unix(0, nanos)}, nil
+}
+
+func newPage(doc *index.Document) Page {
+	return Page{
+		LinkID:    doc.LinkID,
+		URL:       doc.URL,
+		Title:     doc.Title,
+		Domain:    index.HostOf(doc),
+		Vertical:  index.VerticalOf(doc),
+		Namespace: doc.Namespace,
+		Summary:   doc.Summary,
+		PageRank:  doc.PageRank,
+		IndexedAt: doc.IndexedAt,
+	}
+}
+
+// newFeed returns a feed with a copy of up to limit of the provided pages.
+func newFeed(generatedAt time.Time, pages []Page, limit int) *Feed {
+	if limit > 0 && len(pages) > limit {
+		pages = pages[:limit]
+	}
+	return &Feed{GeneratedAt: generatedAt, Pages: append([]Page{}, pages...)}
+}
+
+func addTo(feeds map[string]*topK, key string, size int, less func(a, b Page) bool, page Page) {
+	feed := feeds[key]
+	if feed == nil {
+		feed = newTopK(size, less)
+		feeds[key] = feed
+	}
+	feed.add(page)
+}
+
+func sortedFeeds(feeds map[string]*topK) map[string][]Page {
+	sorted := make(map[string][]Page, len(feeds))
+	for key, feed := range feeds {
+		sorted[key] = feed.sorted()
+	}
+	return sorted
+}
+
+// byPageRank and byIndexedAt return true if page a is ranked below page b.
+// Ties are broken by URL so that the feeds are stable across refreshes.
+func byPageRank(a, b Page) bool {
+	if a.PageRank != b.PageRank {
+		return a.PageRank < b.PageRank
+	}
+	return a.URL > b.URL
+}
+
+func byIndexedAt(a, b Page) bool {
+	if !a.IndexedAt.Equal(b.IndexedAt) {
+		return a.IndexedAt.Before(b.IndexedAt)
+	}
+	return a.URL > b.URL
+}
+
+// topK keeps the size best ranked pages that are added to it. It implements
+// heap.Interface with the worst ranked page at the root.
+type topK struct {
+	size  int
+	less  func(a, b Page) bool
+	pages []Page
+}
+
+func newTopK(size int, less func(a, b Page) bool) *topK {
+	return &topK{size: size, less: less}
+}
+
+func (t *topK) Len() int           { return len(t.pages) }
+func (t *topK) Less(i, j int) bool { return t.less(t.pages[i], t.pages[j]) }
+func (t *topK) Swap(i, j int)      { t.pages[i], t.pages[j] = t.pages[j], t.pages[i] }
+func (t *topK) Push(x interface{}) { t.pages = append(t.pages, x.(Page)) }
+func (t *topK) Pop() (x interface{}) {
+	x, t.pages = t.pages[len(t.pages)-1], t.pages[:len(t.pages)-1]
+	return x
+}
+
+// add adds page if it ranks above the worst page that is currently kept.
+func (t *topK) add(page Page) {
+	switch {
+	case len(t.pages) < t.size:
+		heap.Push(t, page)
+	case t.less(t.pages[0], page):
+		t.pages[0] = page
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted returns the kept pages from the best to the worst ranked one.
+func (t *topK) sorted() []Page {
+	pages := append([]Page(nil), t.pages...)
+	sort.Slice(pages, func(i, j int) bool { return t.less(pages[j], pages[i]) })
+	return pages
+}
@@ -0,0 +1,188 @@
+package feeds
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"webcrawler/crawler/textindexer/index"
+	memindex "webcrawler/crawler/textindexer/store/memory"
+	"webcrawler/namespace"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(FeedsTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type FeedsTestSuite struct {
+	idx     *memindex.InMemoryBleveIndexer
+	builder *Builder
+	now     time.Time
+}
+
+func (s *FeedsTestSuite) SetUpTest(c *gc.C) {
+	var err error
+	s.idx, err = memindex.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	s.now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.builder, err = NewBuilder(Config{
+		IndexAPI:   s.idx,
+		Size:       3,
+		DomainSize: 2,
+		Clock:      func() time.Time { return s.now },
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *FeedsTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+}
+
+// index adds a document and assigns its PageRank score. The IndexedAt field
+// of the document is set by the indexer.
+func (s *FeedsTestSuite) index(c *gc.C, rawURL, vertical string, score float64) uuid.UUID {
+	doc := &index.Document{LinkID: uuid.New(), URL: rawURL, Title: rawURL, Content: "content", Vertical: vertical}
+	c.Assert(s.idx.Index(doc), gc.IsNil)
+	c.Assert(s.idx.UpdateScore(doc.LinkID, score), gc.IsNil)
+	return doc.LinkID
+}
+
+func (s *FeedsTestSuite) TestNotReady(c *gc.C) {
+	_, err := s.builder.TopPages(Filter{}, 0)
+	c.Assert(errors.Is(err, ErrNotReady), gc.Equals, true)
+	_, err = s.builder.RecentPages("", 0)
+	c.Assert(errors.Is(err, ErrNotReady), gc.Equals, true)
+}
+
+func (s *FeedsTestSuite) TestTopPages(c *gc.C) {
+	for i := 0; i < 4; i++ {
+		s.index(c, fmt.Sprintf("http://a.com/%d", i), "", float64(i))
+	}
+	s.index(c, "http://B.com/news", index.VerticalNews, 10)
+	s.index(c, "http://b.com/doc", index.VerticalDocuments, 0.5)
+
+	// Placeholders and near-duplicates are excluded.
+	c.Assert(s.idx.UpdateScore(uuid.New(), 100), gc.IsNil)
+	c.Assert(s.idx.Index(&index.Document{LinkID: uuid.New(), URL: "http://c.com/", DuplicateOf: uuid.New()}), gc.IsNil)
+
+	c.Assert(s.builder.Refresh(), gc.IsNil)
+
+	feed, err := s.builder.TopPages(Filter{}, 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(feed.GeneratedAt, gc.Equals, s.now)
+	c.Assert(urlsOf(feed), gc.DeepEquals, []string{"http://B.com/news", "http://a.com/3", "http://a.com/2"})
+	c.Assert(feed.Pages[0].Domain, gc.Equals, "b.com")
+	c.Assert(feed.Pages[0].Vertical, gc.Equals, index.VerticalNews)
+	c.Assert(feed.Pages[0].PageRank, gc.Equals, 10.0)
+
+	feed, err = s.builder.TopPages(Filter{}, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(feed), gc.DeepEquals, []string{"http://B.com/news"})
+
+	feed, err = s.builder.TopPages(Filter{Domain: "A.com"}, 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(feed), gc.DeepEquals, []string{"http://a.com/3", "http://a.com/2"})
+
+	feed, err = s.builder.TopPages(Filter{Vertical: index.VerticalWeb}, 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(feed), gc.DeepEquals, []string{"http://a.com/3", "http://a.com/2", "http://a.com/1"})
+
+	feed, err = s.builder.TopPages(Filter{Domain: "unknown.com"}, 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(feed.Pages, gc.HasLen, 0)
+
+	_, err = s.builder.TopPages(Filter{Domain: "a.com", Vertical: index.VerticalWeb}, 0)
+	c.Assert(err, gc.ErrorMatches, "top pages: domain and vertical filters cannot be combined")
+}
+
+func (s *FeedsTestSuite) TestRecentPages(c *gc.C) {
+	for i := 0; i < 4; i++ {
+		s.index(c, fmt.Sprintf("http://a.com/%d", i), "", 0)
+		time.Sleep(time.Millisecond)
+	}
+	s.index(c, "http://b.com/news", index.VerticalNews, 0)
+	c.Assert(s.builder.Refresh(), gc.IsNil)
+
+	feed, err := s.builder.RecentPages("", 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(feed), gc.DeepEquals, []string{"http://b.com/news", "http://a.com/3", "http://a.com/2"})
+
+	feed, err = s.builder.RecentPages(index.VerticalWeb, 2)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(feed), gc.DeepEquals, []string{"http://a.com/3", "http://a.com/2"})
+
+	// Served feeds are not affected by later refreshes.
+	s.index(c, "http://c.com/", "", 0)
+	c.Assert(s.builder.Refresh(), gc.IsNil)
+	c.Assert(urlsOf(feed), gc.DeepEquals, []string{"http://a.com/3", "http://a.com/2"})
+	feed, err = s.builder.RecentPages(index.VerticalWeb, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(feed), gc.DeepEquals, []string{"http://c.com/"})
+}
+
+func (s *FeedsTestSuite) TestChanges(c *gc.C) {
+	var lastID uuid.UUID
+	for i := 0; i < 3; i++ {
+		lastID = s.index(c, fmt.Sprintf("http://a.com/%d", i), "", 0)
+		time.Sleep(time.Millisecond)
+	}
+	s.index(c, "http://b.com/", "", 0)
+	c.Assert(s.builder.Refresh(), gc.IsNil)
+
+	// Page through the overall feed.
+	feed, err := s.builder.Changes(ChangeFilter{}, "", 2)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(&feed.Feed), gc.DeepEquals, []string{"http://b.com/", "http://a.com/2"})
+	c.Assert(feed.NextCursor, gc.Not(gc.Equals), "")
+	cursor := feed.NextCursor
+
+	feed, err = s.builder.Changes(ChangeFilter{}, cursor, 2)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(&feed.Feed), gc.DeepEquals, []string{"http://a.com/1"})
+	c.Assert(feed.NextCursor, gc.Equals, "")
+
+	// Cursors remain valid after pages are re-indexed.
+	c.Assert(s.idx.Index(&index.Document{LinkID: lastID, URL: "http://a.com/2", Title: "updated"}), gc.IsNil)
+	c.Assert(s.builder.Refresh(), gc.IsNil)
+	feed, err = s.builder.Changes(ChangeFilter{}, cursor, 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(&feed.Feed), gc.DeepEquals, []string{"http://a.com/1"})
+
+	feed, err = s.builder.Changes(ChangeFilter{Domain: "A.com"}, "", 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(&feed.Feed), gc.DeepEquals, []string{"http://a.com/2", "http://a.com/1"})
+
+	feed, err = s.builder.Changes(ChangeFilter{Namespace: namespace.Default}, "", 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(urlsOf(&feed.Feed), gc.DeepEquals, []string{"http://a.com/2"})
+	c.Assert(feed.Pages[0].Namespace, gc.Equals, namespace.Default)
+
+	feed, err = s.builder.Changes(ChangeFilter{Namespace: "other"}, "", 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(feed.Pages, gc.HasLen, 0)
+
+	_, err = s.builder.Changes(ChangeFilter{}, "not a cursor", 0)
+	c.Assert(errors.Is(err, ErrInvalidCursor), gc.Equals, true)
+	_, err = s.builder.Changes(ChangeFilter{Namespace: "a", Domain: "a.com"}, "", 0)
+	c.Assert(err, gc.ErrorMatches, "changes: namespace and domain filters cannot be combined")
+}
+
+func (s *FeedsTestSuite) TestInvalidConfig(c *gc.C) {
+	_, err := NewBuilder(Config{RefreshInterval: -1, Size: -1, DomainSize: -1})
+	c.Assert(err, gc.ErrorMatches, `(?s)feed builder: config validation failed:.*`+
+		`index API has not been provided.*`+
+		`invalid value for refresh interval.*`+
+		`invalid value for feed size.*`+
+		`invalid value for domain feed size.*`)
+}
+
+func urlsOf(feed *Feed) []string {
+	urls := []string{}
+	for _, page := range feed.Pages {
+		urls = append(urls, page.URL)
+	}
+	return urls
+}
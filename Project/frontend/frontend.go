@@ -0,0 +1,591 @@
+// Package frontend implements the user-facing HTTP service of the search
+// engine. It exposes the following endpoints:
+//
+//	GET  /              render the search form
+//	GET  /search        search the index (query in the "q" parameter)
+//	GET  /submit/site   render the link submission form
+//	POST /submit/site   seed a new URL (in the "url" field) into the link graph
+//
+// Searches can be restricted to a single search vertical (web, documents or
+// news) via the optional "vertical" parameter.
+//
+// Search results and submission outcomes are rendered as HTML pages unless
+// the client requests JSON, either via an "Accept: application/json" header
+// or a "format=json" query parameter.
+//
+// In addition, the search and link submission functionality is exposed to
+// third-party clients via a versioned JSON API:
+//
+//	GET  /api/v1/search  search the index (query in the "q" parameter)
+//	POST /api/v1/links   seed a new URL into the link graph
+//
+// If a PageRank score history is configured, the API also reports whether a
+// link or domain is gaining or losing authority over time:
+//
+//	GET  /api/v1/links/{linkID}/pagerank  the score trend of a link
+//	GET  /api/v1/domains/{host}/pagerank  the summed score trend of a domain
+//
+// If page feeds are configured, the API also serves content for the home page
+// that does not depend on a query. The feeds are periodically precomputed;
+// see package feeds.
+//
+//	GET  /api/v1/feeds/top     the top pages by PageRank ("domain" or "vertical")
+//	GET  /api/v1/feeds/recent  the most recently indexed pages ("vertical")
+//
+// The recently indexed or re-indexed pages are also syndicated as Atom and
+// JSON Feed documents so that downstream aggregators can consume the crawl
+// output. These feeds can be restricted to a namespace or domain and are
+// paged from the newest to the oldest page via the "cursor" parameter:
+//
+//	GET  /api/v1/feeds/changes.atom  ("namespace" or "domain", "cursor")
+//	GET  /api/v1/feeds/changes.json  ("namespace" or "domain", "cursor")
+//
+// If an access policy is configured, the fields included in search results
+// depend on the API key passed via the X-API-Key header. Requests without a
+// key only see the public fields while requests with an unknown key are
+// rejected; see package access.
+//
+// The health of the components the frontend depends on (e.g. whether the
+// mapping of the search index has drifted) is reported as JSON at /healthz
+// and whether the stores it depends on can be reached at /readyz. Both
+// respond with a 503 status if any check fails; see package health.
+//
+// The service metrics are exposed in the Prometheus format at /metrics. If an
+// SLO tracker is configured, the SLO indicators derived from them (pages/sec,
+// index lag, frontier age p95 and error budget consumption) are exposed as
+// JSON at /metrics/slo; see package slo for details.
+package frontend
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/frontend/access"
+	"webcrawler/metrics"
+	"webcrawler/metrics/slo"
+	"webcrawler/urlutil/normalizer"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// GraphAPI defines the set of link graph operations required by the
+// frontend.
+type GraphAPI interface {
+	// UpsertLink creates a new link or updates an existing link.
+	UpsertLink(link *graph.Link) error
+}
+
+// IndexAPI defines the set of text indexer operations required by the
+// frontend.
+type IndexAPI interface {
+	// Search the index for a particular query and return back a result
+	// iterator.
+	Search(query index.Query) (index.Iterator, error)
+
+	// Suggest returns up to limit distinct titles of indexed documents for
+	// autocompleting the search expression prefix.
+	Suggest(prefix string, limit int) ([]string, error)
+}
+
+// SLOAPI defines the set of SLO tracker operations required by the frontend.
+type SLOAPI interface {
+	// Report returns the SLO indicators for each tracked window.
+	Report() (*slo.Report, error)
+}
+
+// Config encapsulates the settings for the frontend service.
+type Config struct {
+	// The link graph that submitted links are added to.
+	GraphAPI GraphAPI
+
+	// The text indexer to search.
+	IndexAPI IndexAPI
+
+	// The number of results to display per page. Defaults to 10.
+	ResultsPerPage int
+
+	// The maximum length (in characters) of the snippet that is rendered
+	// for each search result. Defaults to 256.
+	MaxSnippetLength int
+
+	// An optional PageRank score history for serving the score trend API.
+	ScoreHistory ScoreHistoryAPI
+
+	// An optional normalizer for submitted links. If not specified, only
+	// the normalizations that are not configurable are applied.
+	URLNormalizer *normalizer.Normalizer
+
+	// An optional SLO tracker for serving the SLO indicators.
+	SLO SLOAPI
+
+	// Optional precomputed page feeds for serving the feed API.
+	Feeds FeedsAPI
+
+	// An optional policy that controls which document fields are included
+	// in search results for each API key. If not specified, search results
+	// include the access.DefaultPublicFields.
+	Access *access.Policy
+
+	// Optional health checks that are reported at /healthz, keyed by the
+	// name of the checked component.
+	HealthChecks map[string]HealthChecker
+
+	// Optional readiness checks (e.g. whether the stores can be reached)
+	// that are reported at /readyz, keyed by the name of the checked
+	// component.
+	ReadinessChecks map[string]HealthChecker
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.GraphAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("graph API has not been provided"))
+	}
+	if cfg.IndexAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("index API has not been provided"))
+	}
+	if cfg.ResultsPerPage <= 0 {
+		cfg.ResultsPerPage = 10
+	}
+	if cfg.MaxSnippetLength <= 0 {
+		cfg.MaxSnippetLength = 256
+	}
+	return err
+}
+
+// Handler is an http.Handler that serves the frontend.
+type Handler struct {
+	cfg  Config
+	mux  *http.ServeMux
+	tmpl *template.Template
+}
+
+// NewHandler returns a new frontend handler using the provided config.
+func NewHandler(cfg Config) (*Handler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("frontend handler: config validation failed: %w", err)
+	}
+
+	tmpl, err := template.ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("frontend handler: parsing templates: %w", err)
+	}
+
+	h := &Handler{cfg: cfg, mux: http.NewServeMux(), tmpl: tmpl}
+	h.mux.HandleFunc("GET /{$}", h.renderIndex)
+	h.mux.HandleFunc("GET /search", h.search)
+	h.mux.HandleFunc("GET /submit/site", h.renderSubmitForm)
+	h.mux.HandleFunc("POST /submit/site", h.submitSite)
+	if cfg.ScoreHistory != nil {
+		h.registerTrendRoutes()
+	}
+	if cfg.Feeds != nil {
+		h.registerFeedRoutes()
+	}
+	h.registerAPIRoutes()
+	h.registerHealthRoutes()
+	h.mux.Handle("GET /metrics", metrics.Handler())
+	if cfg.SLO != nil {
+		h.mux.HandleFunc("GET /metrics/slo", h.sloReport)
+	}
+	return h, nil
+}
+
+// sloReport returns the SLO indicators reported by the SLO tracker.
+func (h *Handler) sloReport(w http.ResponseWriter, _ *http.Request) {
+	rep, err := h.cfg.SLO.Report()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "unable to compute SLO indicators")
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.Access != nil {
+		resolved, err := h.cfg.Access.Resolve(r)
+		if err != nil {
+			h.writeAccessError(w, r)
+			return
+		}
+		r = resolved
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) renderIndex(w http.ResponseWriter, _ *http.Request) {
+	h.render(w, http.StatusOK, "index.html", nil)
+}
+
+// searchResult describes a single search result. Only the fields that are
+// visible to the client are populated and encoded.
+type searchResult struct {
+	LinkID   uuid.UUID     `json:"linkID"`
+	URL      string        `json:"url"`
+	Title    string        `json:"title"`
+	Snippet  template.HTML `json:"snippet"`
+	Vertical string        `json:"vertical"`
+
+	// Fields that are only visible to clients with an API key that has
+	// been granted access to them.
+	Content      string            `json:"content,omitempty"`
+	Summary      string            `json:"summary,omitempty"`
+	Language     string            `json:"language,omitempty"`
+	Author       string            `json:"author,omitempty"`
+	PublishedAt  *time.Time        `json:"publishedAt,omitempty"`
+	IndexedAt    *time.Time        `json:"indexedAt,omitempty"`
+	PageRank     *float64          `json:"pageRank,omitempty"`
+	FaviconRef   string            `json:"faviconRef,omitempty"`
+	ThumbnailRef string            `json:"thumbnailRef,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+
+	fields access.FieldSet
+}
+
+// MarshalJSON implements json.Marshaler. The public fields are always encoded
+// if they are visible while the remaining fields are omitted if empty.
+func (r searchResult) MarshalJSON() ([]byte, error) {
+	type visibleResult struct {
+		LinkID   *uuid.UUID     `json:"linkID,omitempty"`
+		URL      *string        `json:"url,omitempty"`
+		Title    *string        `json:"title,omitempty"`
+		Snippet  *template.HTML `json:"snippet,omitempty"`
+		Vertical *string        `json:"vertical,omitempty"`
+
+		Content      string            `json:"content,omitempty"`
+		Summary      string            `json:"summary,omitempty"`
+		Language     string            `json:"language,omitempty"`
+		Author       string            `json:"author,omitempty"`
+		PublishedAt  *time.Time        `json:"publishedAt,omitempty"`
+		IndexedAt    *time.Time        `json:"indexedAt,omitempty"`
+		PageRank     *float64          `json:"pageRank,omitempty"`
+		FaviconRef   string            `json:"faviconRef,omitempty"`
+		ThumbnailRef string            `json:"thumbnailRef,omitempty"`
+		Headers      map[string]string `json:"headers,omitempty"`
+	}
+
+	v := visibleResult{
+		Content:      r.Content,
+		Summary:      r.Summary,
+		Language:     r.Language,
+		Author:       r.Author,
+		PublishedAt:  r.PublishedAt,
+		IndexedAt:    r.IndexedAt,
+		PageRank:     r.PageRank,
+		FaviconRef:   r.FaviconRef,
+		ThumbnailRef: r.ThumbnailRef,
+		Headers:      r.Headers,
+	}
+	if r.fields.Has(access.FieldLinkID) {
+		v.LinkID = &r.LinkID
+	}
+	if r.fields.Has(access.FieldURL) {
+		v.URL = &r.URL
+	}
+	if r.fields.Has(access.FieldTitle) {
+		v.Title = &r.Title
+	}
+	if r.fields.Has(access.FieldSnippet) {
+		v.Snippet = &r.Snippet
+	}
+	if r.fields.Has(access.FieldVertical) {
+		v.Vertical = &r.Vertical
+	}
+	return json.Marshal(v)
+}
+
+// searchResponse describes a page of search results.
+type searchResponse struct {
+	Query        string         `json:"query"`
+	Vertical     string         `json:"vertical,omitempty"`
+	Page         int            `json:"page"`
+	TotalPages   int            `json:"totalPages"`
+	TotalResults uint64         `json:"totalResults"`
+	Results      []searchResult `json:"results"`
+	Suggestions  []string       `json:"suggestions,omitempty"`
+
+	// Populated for HTML responses only.
+	PrevPage  int      `json:"-"`
+	NextPage  int      `json:"-"`
+	Verticals []string `json:"-"`
+}
+
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	queryText := strings.TrimSpace(r.URL.Query().Get("q"))
+	if queryText == "" {
+		h.writeError(w, r, http.StatusBadRequest, "missing search query")
+		return
+	}
+
+	vertical, ok := parseVertical(r.URL.Query().Get("vertical"))
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, "invalid search vertical")
+		return
+	}
+
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		var err error
+		if page, err = strconv.Atoi(p); err != nil || page < 1 {
+			h.writeError(w, r, http.StatusBadRequest, "invalid page number")
+			return
+		}
+	}
+
+	query := newSearchQuery(queryText, vertical, uint64(page-1)*uint64(h.cfg.ResultsPerPage))
+	query.Size = uint64(h.cfg.ResultsPerPage)
+	it, err := h.cfg.IndexAPI.Search(query)
+	if errors.Is(err, index.ErrOffsetTooLarge) {
+		h.writeError(w, r, http.StatusBadRequest, "page number too large")
+		return
+	} else if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, "search failed")
+		return
+	}
+	defer func() { _ = it.Close() }()
+
+	res := searchResponse{
+		Query:        queryText,
+		Vertical:     vertical,
+		Page:         page,
+		TotalResults: it.TotalCount(),
+		TotalPages:   int(math.Ceil(float64(it.TotalCount()) / float64(h.cfg.ResultsPerPage))),
+		Results:      []searchResult{},
+		Suggestions:  suggestedQueries(query, it.Suggestions()),
+	}
+	terms, fields := queryTerms(queryText), visibleFields(r)
+	for len(res.Results) < h.cfg.ResultsPerPage && it.Next() {
+		res.Results = append(res.Results, newSearchResult(it.Document(), terms, h.cfg.MaxSnippetLength, fields))
+	}
+	if err = it.Error(); err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, "search failed")
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	if page > 1 {
+		res.PrevPage = page - 1
+	}
+	if page < res.TotalPages {
+		res.NextPage = page + 1
+	}
+	res.Verticals = index.Verticals
+	h.render(w, http.StatusOK, "results.html", res)
+}
+
+// suggestedQueries returns the query texts for the corrections that the
+// indexer suggested for q.
+func suggestedQueries(q index.Query, suggestions []index.Suggestion) []string {
+	var out []string
+	for _, s := range suggestions {
+		text := s.Expression
+		if q.Type == index.QueryTypePhrase {
+			text = `"` + text + `"`
+		}
+		out = append(out, text)
+	}
+	return out
+}
+
+// newSearchQuery returns an index query for the provided query text that is
+// restricted to the specified vertical (if any). Queries wrapped in double
+// quotes are executed as phrase queries.
+func newSearchQuery(queryText, vertical string, offset uint64) index.Query {
+	q := index.Query{Type: index.QueryTypeMatch, Expression: queryText, Offset: offset, Vertical: vertical}
+	if len(queryText) > 1 && strings.HasPrefix(queryText, `"`) && strings.HasSuffix(queryText, `"`) {
+		q.Type = index.QueryTypePhrase
+		q.Expression = strings.Trim(queryText, `"`)
+	}
+	return q
+}
+
+// parseVertical validates the value of a vertical parameter. An empty value
+// selects all verticals.
+func parseVertical(vertical string) (string, bool) {
+	vertical = strings.ToLower(strings.TrimSpace(vertical))
+	return vertical, vertical == "" || index.IsValidVertical(vertical)
+}
+
+// newSearchResult maps doc to a search result that only includes the
+// specified fields.
+func newSearchResult(doc *index.Document, terms []string, maxSnippetLength int, fields access.FieldSet) searchResult {
+	res := searchResult{fields: fields}
+	if fields.Has(access.FieldLinkID) {
+		res.LinkID = doc.LinkID
+	}
+	if fields.Has(access.FieldURL) {
+		res.URL = doc.URL
+	}
+	if fields.Has(access.FieldTitle) {
+		res.Title = doc.Title
+	}
+	if fields.Has(access.FieldSnippet) {
+		res.Snippet = resultSnippet(doc, terms, maxSnippetLength)
+	}
+	if fields.Has(access.FieldVertical) {
+		res.Vertical = index.VerticalOf(doc)
+	}
+	if fields.Has(access.FieldContent) {
+		res.Content = doc.Content
+	}
+	if fields.Has(access.FieldSummary) {
+		res.Summary = doc.Summary
+	}
+	if fields.Has(access.FieldLanguage) {
+		res.Language = doc.Language
+	}
+	if fields.Has(access.FieldAuthor) {
+		res.Author = doc.Author
+	}
+	if fields.Has(access.FieldPublishedAt) && !doc.PublishedAt.IsZero() {
+		res.PublishedAt = &doc.PublishedAt
+	}
+	if fields.Has(access.FieldIndexedAt) && !doc.IndexedAt.IsZero() {
+		res.IndexedAt = &doc.IndexedAt
+	}
+	if fields.Has(access.FieldPageRank) {
+		res.PageRank = &doc.PageRank
+	}
+	if fields.Has(access.FieldFaviconRef) {
+		res.FaviconRef = doc.FaviconRef
+	}
+	if fields.Has(access.FieldThumbnailRef) {
+		res.ThumbnailRef = doc.ThumbnailRef
+	}
+	if fields.Has(access.FieldHeaders) {
+		res.Headers = doc.Headers
+	}
+	return res
+}
+
+// publicFields is the set of fields that are visible when no access policy
+// has been configured.
+var publicFields = access.NewFieldSet(access.DefaultPublicFields...)
+
+// visibleFields returns the fields that the client that issued r can see.
+func visibleFields(r *http.Request) access.FieldSet {
+	if fields, ok := access.FieldsFromContext(r.Context()); ok {
+		return fields
+	}
+	return publicFields
+}
+
+// writeAccessError rejects a request that specifies an unknown API key.
+func (h *Handler) writeAccessError(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		writeAPIError(w, http.StatusUnauthorized, apiErrUnauthenticated, "unknown API key")
+		return
+	}
+	h.writeError(w, r, http.StatusUnauthorized, "unknown API key")
+}
+
+func (h *Handler) renderSubmitForm(w http.ResponseWriter, _ *http.Request) {
+	h.render(w, http.StatusOK, "submit.html", nil)
+}
+
+// submitResponse describes the outcome of a link submission.
+type submitResponse struct {
+	LinkID uuid.UUID `json:"linkID"`
+	URL    string    `json:"url"`
+}
+
+func (h *Handler) submitSite(w http.ResponseWriter, r *http.Request) {
+	var rawURL string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		rawURL = req.URL
+	} else {
+		rawURL = r.FormValue("url")
+	}
+
+	link, err := h.normalizeSubmittedURL(rawURL)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Links with a zero RetrievedAt value are picked up by the next crawl
+	// pass.
+	newLink := &graph.Link{URL: link}
+	if err = h.cfg.GraphAPI.UpsertLink(newLink); err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, "unable to submit link")
+		return
+	}
+
+	res := submitResponse{LinkID: newLink.ID, URL: newLink.URL}
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusAccepted, res)
+		return
+	}
+	h.render(w, http.StatusAccepted, "submitted.html", res)
+}
+
+// normalizeSubmittedURL validates a submitted URL and returns it in a
+// canonical form.
+func (h *Handler) normalizeSubmittedURL(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", errors.New("missing URL")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.New("invalid URL")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("only http and https URLs can be submitted")
+	}
+	if u.Hostname() == "" {
+		return "", errors.New("URL does not specify a host")
+	}
+	return h.cfg.URLNormalizer.NormalizeURL(u).String(), nil
+}
+
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func (h *Handler) render(w http.ResponseWriter, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = h.tmpl.ExecuteTemplate(w, name, data)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if wantsJSON(r) {
+		writeJSON(w, status, map[string]string{"error": msg})
+		return
+	}
+	h.render(w, status, "error.html", msg)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,312 @@
+package frontend
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	linkgraph "webcrawler/crawler/linkgraph/store/memory"
+	"webcrawler/crawler/textindexer/index"
+	textindexer "webcrawler/crawler/textindexer/store/memory"
+	"webcrawler/metrics/slo"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(FrontendTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type FrontendTestSuite struct {
+	g   *linkgraph.InMemoryGraph
+	idx *textindexer.InMemoryBleveIndexer
+	h   *Handler
+}
+
+func (s *FrontendTestSuite) SetUpTest(c *gc.C) {
+	var err error
+	s.g = linkgraph.NewInMemoryGraph()
+	s.idx, err = textindexer.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, ResultsPerPage: 2})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *FrontendTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+}
+
+func (s *FrontendTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewHandler(Config{})
+	c.Assert(err, gc.ErrorMatches, "(?s).*graph API has not been provided.*index API has not been provided.*")
+}
+
+func (s *FrontendTestSuite) TestSearchJSON(c *gc.C) {
+	for i := 0; i < 3; i++ {
+		c.Assert(s.idx.Index(&index.Document{
+			LinkID:  uuid.New(),
+			URL:     fmt.Sprintf("http://example.com/%d", i),
+			Title:   fmt.Sprintf("Page %d", i),
+			Content: "Ovidius poeta in terra pontica",
+		}), gc.IsNil)
+	}
+
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/search?q=poeta&page=2&format=json", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "application/json")
+
+	var res searchResponse
+	c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+	c.Assert(res.Page, gc.Equals, 2)
+	c.Assert(res.TotalPages, gc.Equals, 2)
+	c.Assert(res.TotalResults, gc.Equals, uint64(3))
+	c.Assert(res.Results, gc.HasLen, 1)
+	c.Assert(res.Results[0].Snippet, gc.Equals, template.HTML("Ovidius <em>poeta</em> in terra pontica"))
+}
+
+func (s *FrontendTestSuite) TestSearchHTML(c *gc.C) {
+	c.Assert(s.idx.Index(&index.Document{
+		LinkID:  uuid.New(),
+		URL:     "http://example.com",
+		Title:   "<script>Ovidius</script>",
+		Content: "Ovidius poeta in terra pontica",
+	}), gc.IsNil)
+
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/search?q=ovidius", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	body := rec.Body.String()
+	c.Assert(strings.Contains(body, "<em>Ovidius</em> poeta"), gc.Equals, true, gc.Commentf(body))
+	c.Assert(strings.Contains(body, "&lt;script&gt;Ovidius&lt;/script&gt;"), gc.Equals, true, gc.Commentf(body))
+	c.Assert(strings.Contains(body, "Page 1 of 1"), gc.Equals, true, gc.Commentf(body))
+}
+
+func (s *FrontendTestSuite) TestSearchHTMLVertical(c *gc.C) {
+	c.Assert(s.idx.Index(&index.Document{
+		LinkID:   uuid.New(),
+		URL:      "http://example.com/news/1",
+		Title:    "Storm warning",
+		Content:  "A storm is expected tonight",
+		Vertical: index.VerticalNews,
+	}), gc.IsNil)
+
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/search?q=storm&vertical=web", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	body := rec.Body.String()
+	c.Assert(strings.Contains(body, "0 result(s)"), gc.Equals, true, gc.Commentf(body))
+	c.Assert(strings.Contains(body, "<strong>web</strong>"), gc.Equals, true, gc.Commentf(body))
+	c.Assert(strings.Contains(body, `href="/search?q=storm&amp;vertical=news"`), gc.Equals, true, gc.Commentf(body))
+
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/search?q=storm&vertical=news", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	body = rec.Body.String()
+	c.Assert(strings.Contains(body, "1 result(s)"), gc.Equals, true, gc.Commentf(body))
+	c.Assert(strings.Contains(body, "[news]"), gc.Equals, true, gc.Commentf(body))
+}
+
+func (s *FrontendTestSuite) TestSearchErrors(c *gc.C) {
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/search?format=json", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusBadRequest)
+
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/search?q=foo&page=0", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusBadRequest)
+	c.Assert(strings.Contains(rec.Body.String(), "invalid page number"), gc.Equals, true)
+
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/search?q=foo&vertical=images", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusBadRequest)
+	c.Assert(strings.Contains(rec.Body.String(), "invalid search vertical"), gc.Equals, true)
+}
+
+func (s *FrontendTestSuite) TestSubmitSite(c *gc.C) {
+	form := url.Values{"url": {"HTTP://Example.COM/about#team"}}
+	req := httptest.NewRequest(http.MethodPost, "/submit/site", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := s.do(req)
+	c.Assert(rec.Code, gc.Equals, http.StatusAccepted)
+	c.Assert(strings.Contains(rec.Body.String(), "http://example.com/about"), gc.Equals, true)
+
+	req = httptest.NewRequest(http.MethodPost, "/submit/site", strings.NewReader(`{"url": "https://other.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	rec = s.do(req)
+	c.Assert(rec.Code, gc.Equals, http.StatusAccepted)
+
+	var res submitResponse
+	c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+	link, err := s.g.FindLink(res.LinkID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(link.URL, gc.Equals, "https://other.com/")
+
+	var urls []string
+	it, err := s.g.Links(uuid.Nil, uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"), 1)
+	c.Assert(err, gc.IsNil)
+	for it.Next() {
+		urls = append(urls, it.Link().URL)
+	}
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(urls, gc.HasLen, 2)
+}
+
+func (s *FrontendTestSuite) TestSubmitInvalidSite(c *gc.C) {
+	for _, rawURL := range []string{"", "ftp://example.com", "http://", "::not-a-url"} {
+		req := httptest.NewRequest(http.MethodPost, "/submit/site?format=json", strings.NewReader(url.Values{"url": {rawURL}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := s.do(req)
+		c.Assert(rec.Code, gc.Equals, http.StatusBadRequest, gc.Commentf("url %q", rawURL))
+	}
+
+	var seen int
+	it, err := s.g.Links(uuid.Nil, uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"), 1)
+	c.Assert(err, gc.IsNil)
+	for it.Next() {
+		seen++
+	}
+	c.Assert(it.Close(), gc.IsNil)
+	c.Assert(seen, gc.Equals, 0)
+}
+
+func (s *FrontendTestSuite) TestMakeSnippet(c *gc.C) {
+	specs := []struct {
+		content string
+		query   string
+		maxLen  int
+		exp     template.HTML
+	}{
+		{
+			content: "Fish & chips <b>are</b> tasty",
+			query:   "chips",
+			maxLen:  100,
+			exp:     "Fish &amp; <em>chips</em> &lt;b&gt;are&lt;/b&gt; tasty",
+		},
+		{
+			content: "one two three four five six seven eight nine ten",
+			query:   "seven",
+			maxLen:  20,
+			exp:     "… six <em>seven</em> eight nine …",
+		},
+		{
+			content: "poets and poetry",
+			query:   `"POET"`,
+			maxLen:  100,
+			exp:     "<em>poets</em> and <em>poetry</em>",
+		},
+		{
+			content: "no match here at all",
+			query:   "missing",
+			maxLen:  8,
+			exp:     "no match …",
+		},
+	}
+
+	for _, spec := range specs {
+		got := makeSnippet(spec.content, queryTerms(spec.query), spec.maxLen)
+		c.Assert(got, gc.Equals, spec.exp, gc.Commentf("query %q", spec.query))
+	}
+}
+
+func (s *FrontendTestSuite) do(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.h.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *FrontendTestSuite) TestMetrics(c *gc.C) {
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(strings.Contains(rec.Body.String(), "webcrawler_crawler_pages_fetched_total"), gc.Equals, true)
+}
+
+func (s *FrontendTestSuite) TestSLOReport(c *gc.C) {
+	// The endpoint is only served if an SLO tracker is configured.
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/metrics/slo", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound)
+
+	tracker, err := slo.NewTracker(slo.Config{Windows: []time.Duration{time.Minute}})
+	c.Assert(err, gc.IsNil)
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, SLO: tracker})
+	c.Assert(err, gc.IsNil)
+
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/metrics/slo", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "application/json")
+
+	var rep slo.Report
+	c.Assert(json.NewDecoder(rec.Body).Decode(&rep), gc.IsNil)
+	c.Assert(rep.FetchSuccessTarget, gc.Equals, 0.99)
+	c.Assert(rep.Windows, gc.HasLen, 1)
+	c.Assert(rep.Windows[0].WindowSeconds, gc.Equals, 60.0)
+}
+
+func (s *FrontendTestSuite) TestHealth(c *gc.C) {
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(strings.TrimSpace(rec.Body.String()), gc.Equals, `{"status":"ok"}`)
+
+	mapping := &healthStub{details: map[string]string{"drift": "none"}}
+	var err error
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, HealthChecks: map[string]HealthChecker{
+		"textindexer.mapping": mapping,
+		"other":               &healthStub{},
+	}})
+	c.Assert(err, gc.IsNil)
+
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(strings.TrimSpace(rec.Body.String()), gc.Equals, `{"status":"ok","checks":{"other":{"status":"ok"},"textindexer.mapping":{"status":"ok","details":{"drift":"none"}}}}`)
+
+	// A single failing check marks the frontend as unhealthy.
+	mapping.err = fmt.Errorf("incompatible index mapping")
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusServiceUnavailable)
+	c.Assert(strings.TrimSpace(rec.Body.String()), gc.Equals, `{"status":"unhealthy","checks":{"other":{"status":"ok"},"textindexer.mapping":{"status":"unhealthy","error":"incompatible index mapping","details":{"drift":"none"}}}}`)
+}
+
+func (s *FrontendTestSuite) TestReadiness(c *gc.C) {
+	rec := s.do(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(strings.TrimSpace(rec.Body.String()), gc.Equals, `{"status":"ok"}`)
+
+	var err error
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, ReadinessChecks: map[string]HealthChecker{
+		"linkgraph": &healthStub{err: fmt.Errorf("connection refused")},
+	}})
+	c.Assert(err, gc.IsNil)
+
+	// Failing readiness checks do not affect the health report.
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	rec = s.do(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusServiceUnavailable)
+	c.Assert(strings.TrimSpace(rec.Body.String()), gc.Equals, `{"status":"unhealthy","checks":{"linkgraph":{"status":"unhealthy","error":"connection refused"}}}`)
+}
+
+type healthStub struct {
+	details interface{}
+	err     error
+}
+
+func (h *healthStub) CheckHealth() (interface{}, error) { return h.details, h.err }
+
+func (s *FrontendTestSuite) TestResultSnippetPrefersSummary(c *gc.C) {
+	doc := &index.Document{
+		Title:   "Ovidius",
+		Content: "Menu Home About Contact. Lorem ipsum dolor sit amet.",
+		Summary: "A biography of the poet.",
+	}
+
+	// The query terms only match the title.
+	c.Assert(resultSnippet(doc, queryTerms("ovidius"), 100), gc.Equals, template.HTML("A biography of the poet."))
+	c.Assert(resultSnippet(doc, queryTerms("poet"), 100), gc.Equals, template.HTML("A biography of the <em>poet</em>."))
+
+	// Content matches are highlighted as usual.
+	c.Assert(resultSnippet(doc, queryTerms("lorem"), 100), gc.Equals, template.HTML("Menu Home About Contact. <em>Lorem</em> ipsum dolor sit amet."))
+
+	doc.Summary = ""
+	c.Assert(resultSnippet(doc, queryTerms("ovidius"), 100), gc.Equals, template.HTML("Menu Home About Contact. Lorem ipsum dolor sit amet."))
+}
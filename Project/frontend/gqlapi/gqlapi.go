@@ -0,0 +1,168 @@
+// Package gqlapi exposes a GraphQL endpoint that combines data from the link
+// graph and the text indexer. It allows clients to fetch a document together
+// with its link graph neighbourhood (outbound edges and backlinks) with a
+// single request.
+//
+// To protect the backing stores from expensive queries, each request is
+// checked against a maximum selection depth and a maximum complexity score
+// before it gets executed.
+package gqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/frontend/access"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/hashicorp/go-multierror"
+)
+
+// GraphAPI defines the set of link graph operations required by the GraphQL
+// resolvers.
+type GraphAPI interface {
+	// FindLink looks up a link by its ID.
+	FindLink(id uuid.UUID) (*graph.Link, error)
+
+	// Edges returns an iterator for the set of edges whose source vertex
+	// IDs belong to the [fromID, toID) range and were updated before the
+	// provided unix timestamp.
+	Edges(fromID, toID uuid.UUID, updatedBefore int64) (graph.EdgeIterator, error)
+}
+
+// IndexAPI defines the set of text indexer operations required by the
+// GraphQL resolvers.
+type IndexAPI interface {
+	// FindByID looks up a document by its link ID.
+	FindByID(linkID uuid.UUID) (*index.Document, error)
+
+	// Search the index for a particular query and return back a result
+	// iterator.
+	Search(query index.Query) (index.Iterator, error)
+}
+
+// Config encapsulates the settings for the GraphQL handler.
+type Config struct {
+	// The link graph to resolve links and edges from.
+	GraphAPI GraphAPI
+
+	// The text indexer to resolve documents from.
+	IndexAPI IndexAPI
+
+	// The maximum nesting depth of a query's selection set. Defaults to 6.
+	MaxDepth int
+
+	// The maximum complexity score for a query. Each selected field adds
+	// one point to the score; the cost of the fields selected under a
+	// list field is multiplied by the number of items the list may
+	// return. Defaults to 1000.
+	MaxComplexity int
+
+	// The maximum number of items that list fields may return. Defaults
+	// to 50.
+	MaxListSize int
+
+	// An optional policy that controls which document fields are visible
+	// to each API key. If not specified, all fields are visible.
+	Access *access.Policy
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.GraphAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("graph API has not been provided"))
+	}
+	if cfg.IndexAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("index API has not been provided"))
+	}
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = 6
+	}
+	if cfg.MaxComplexity <= 0 {
+		cfg.MaxComplexity = 1000
+	}
+	if cfg.MaxListSize <= 0 {
+		cfg.MaxListSize = 50
+	}
+	return err
+}
+
+// Handler is an http.Handler that serves GraphQL requests.
+type Handler struct {
+	cfg    Config
+	schema graphql.Schema
+}
+
+// NewHandler returns a new GraphQL handler using the provided config.
+func NewHandler(cfg Config) (*Handler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("graphql handler: config validation failed: %w", err)
+	}
+
+	schema, err := newSchema(&resolver{cfg: cfg})
+	if err != nil {
+		return nil, fmt.Errorf("graphql handler: %w", err)
+	}
+
+	return &Handler{cfg: cfg, schema: schema}, nil
+}
+
+// request describes the body of a GraphQL request.
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// ServeHTTP implements http.Handler. Queries can either be submitted as a JSON
+// document via POST or via the "query" parameter of a GET request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.Access != nil {
+		resolved, err := h.cfg.Access.Resolve(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r = resolved
+	}
+
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "malformed GraphQL request", http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res := h.Execute(r.Context(), req.Query, req.OperationName, req.Variables)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// Execute checks query against the configured depth and complexity limits and
+// executes it against the schema.
+func (h *Handler) Execute(ctx context.Context, query, operationName string, variables map[string]interface{}) *graphql.Result {
+	if err := checkLimits(query, variables, h.cfg); err != nil {
+		return errorResult(err)
+	}
+
+	return graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  query,
+		OperationName:  operationName,
+		VariableValues: variables,
+		Context:        ctx,
+	})
+}
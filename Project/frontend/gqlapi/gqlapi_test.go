@@ -0,0 +1,265 @@
+package gqlapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/frontend/access"
+
+	memgraph "webcrawler/crawler/linkgraph/store/memory"
+	memindex "webcrawler/crawler/textindexer/store/memory"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(GraphQLTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type GraphQLTestSuite struct {
+	g   *memgraph.InMemoryGraph
+	idx *memindex.InMemoryBleveIndexer
+	h   *Handler
+
+	links map[string]*graph.Link
+}
+
+func (s *GraphQLTestSuite) SetUpTest(c *gc.C) {
+	var err error
+	s.g = memgraph.NewInMemoryGraph()
+	s.idx, err = memindex.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, MaxDepth: 5, MaxComplexity: 200})
+	c.Assert(err, gc.IsNil)
+
+	// Build a small graph: A -> B, C -> B, B -> C
+	s.links = make(map[string]*graph.Link)
+	for _, url := range []string{"http://a.com", "http://b.com", "http://c.com"} {
+		link := &graph.Link{URL: url, RetrievedAt: 1000}
+		c.Assert(s.g.UpsertLink(link), gc.IsNil)
+		s.links[url] = link
+	}
+	for _, e := range [][2]string{{"http://a.com", "http://b.com"}, {"http://c.com", "http://b.com"}, {"http://b.com", "http://c.com"}} {
+		c.Assert(s.g.UpsertEdge(&graph.Edge{Src: s.links[e[0]].ID, Dst: s.links[e[1]].ID}), gc.IsNil)
+	}
+
+	c.Assert(s.idx.Index(&index.Document{
+		LinkID:  s.links["http://b.com"].ID,
+		URL:     "http://b.com",
+		Title:   "Page B",
+		Content: "Ovidius poeta in terra pontica",
+	}), gc.IsNil)
+}
+
+func (s *GraphQLTestSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.idx.Close(), gc.IsNil)
+}
+
+func (s *GraphQLTestSuite) TestDocumentWithNeighbourhood(c *gc.C) {
+	res := s.exec(c, `query($id: ID!) {
+		document(id: $id) {
+			title
+			link {
+				url
+				outEdges { destination { url } }
+				backlinks { url }
+			}
+		}
+	}`, map[string]interface{}{"id": s.links["http://b.com"].ID.String()})
+
+	c.Assert(res["errors"], gc.IsNil)
+	doc := res["data"].(map[string]interface{})["document"].(map[string]interface{})
+	c.Assert(doc["title"], gc.Equals, "Page B")
+
+	link := doc["link"].(map[string]interface{})
+	c.Assert(link["url"], gc.Equals, "http://b.com")
+	c.Assert(link["outEdges"], gc.DeepEquals, []interface{}{
+		map[string]interface{}{"destination": map[string]interface{}{"url": "http://c.com"}},
+	})
+
+	var backlinks []string
+	for _, bl := range link["backlinks"].([]interface{}) {
+		backlinks = append(backlinks, bl.(map[string]interface{})["url"].(string))
+	}
+	c.Assert(backlinks, gc.HasLen, 2)
+	c.Assert(strings.Join(backlinks, ","), gc.Matches, "(http://a.com,http://c.com|http://c.com,http://a.com)")
+}
+
+func (s *GraphQLTestSuite) TestSearch(c *gc.C) {
+	res := s.exec(c, `{ search(query: "poeta", mode: MATCH) { totalCount documents { url link { id } } } }`, nil)
+	c.Assert(res["errors"], gc.IsNil)
+	c.Assert(res["data"], gc.DeepEquals, map[string]interface{}{
+		"search": map[string]interface{}{
+			"totalCount": float64(1),
+			"documents": []interface{}{
+				map[string]interface{}{
+					"url":  "http://b.com",
+					"link": map[string]interface{}{"id": s.links["http://b.com"].ID.String()},
+				},
+			},
+		},
+	})
+}
+
+func (s *GraphQLTestSuite) TestSearchVertical(c *gc.C) {
+	res := s.exec(c, `{ search(query: "poeta", vertical: WEB) { totalCount documents { url vertical } } }`, nil)
+	c.Assert(res["errors"], gc.IsNil)
+	c.Assert(res["data"], gc.DeepEquals, map[string]interface{}{
+		"search": map[string]interface{}{
+			"totalCount": float64(1),
+			"documents": []interface{}{
+				map[string]interface{}{"url": "http://b.com", "vertical": "WEB"},
+			},
+		},
+	})
+
+	res = s.exec(c, `{ search(query: "poeta", vertical: NEWS) { totalCount } }`, nil)
+	c.Assert(res["errors"], gc.IsNil)
+	c.Assert(res["data"], gc.DeepEquals, map[string]interface{}{
+		"search": map[string]interface{}{"totalCount": float64(0)},
+	})
+}
+
+func (s *GraphQLTestSuite) TestSearchSuggestions(c *gc.C) {
+	res := s.exec(c, `{ search(query: "ovidus") { totalCount suggestions } }`, nil)
+	c.Assert(res["errors"], gc.IsNil)
+	c.Assert(res["data"], gc.DeepEquals, map[string]interface{}{
+		"search": map[string]interface{}{
+			"totalCount":  float64(0),
+			"suggestions": []interface{}{"ovidius"},
+		},
+	})
+}
+
+func (s *GraphQLTestSuite) TestMissingEntities(c *gc.C) {
+	res := s.exec(c, `query($id: ID!) { link(id: $id) { url document { title } } }`, map[string]interface{}{
+		"id": s.links["http://a.com"].ID.String(),
+	})
+	c.Assert(res["errors"], gc.IsNil)
+	c.Assert(res["data"], gc.DeepEquals, map[string]interface{}{
+		"link": map[string]interface{}{"url": "http://a.com", "document": nil},
+	})
+
+	res = s.exec(c, `{ link(id: "00000000-0000-0000-0000-000000000000") { url } }`, nil)
+	c.Assert(res["errors"], gc.IsNil)
+	c.Assert(res["data"], gc.DeepEquals, map[string]interface{}{"link": nil})
+}
+
+func (s *GraphQLTestSuite) TestOutEdgesByRelType(c *gc.C) {
+	c.Assert(s.g.UpsertEdge(&graph.Edge{
+		Src:        s.links["http://b.com"].ID,
+		Dst:        s.links["http://a.com"].ID,
+		RelType:    graph.RelCanonical,
+		AnchorText: "Page A",
+		NoFollow:   true,
+	}), gc.IsNil)
+
+	res := s.exec(c, `query($id: ID!) {
+		link(id: $id) { outEdges(relTypes: [CANONICAL, REFRESH]) { relType anchorText noFollow destination { url } } }
+	}`, map[string]interface{}{"id": s.links["http://b.com"].ID.String()})
+	c.Assert(res["errors"], gc.IsNil)
+	c.Assert(res["data"], gc.DeepEquals, map[string]interface{}{
+		"link": map[string]interface{}{"outEdges": []interface{}{
+			map[string]interface{}{
+				"relType":     "CANONICAL",
+				"anchorText":  "Page A",
+				"noFollow":    true,
+				"destination": map[string]interface{}{"url": "http://a.com"},
+			},
+		}},
+	})
+}
+
+func (s *GraphQLTestSuite) TestRestrictedFields(c *gc.C) {
+	policy, err := access.NewPolicy(access.Config{Keys: map[string][]string{"k3y": {access.FieldContent}}})
+	c.Assert(err, gc.IsNil)
+	s.h, err = NewHandler(Config{GraphAPI: s.g, IndexAPI: s.idx, Access: policy})
+	c.Assert(err, gc.IsNil)
+
+	query := `{ search(query: "poeta") { documents { url content } } }`
+	res := s.exec(c, query, nil)
+	c.Assert(res["errors"], gc.HasLen, 1)
+	c.Assert(res["errors"].([]interface{})[0].(map[string]interface{})["message"], gc.Equals, errFieldRestricted.Error())
+	c.Assert(res["data"], gc.DeepEquals, map[string]interface{}{
+		"search": map[string]interface{}{
+			"documents": []interface{}{
+				map[string]interface{}{"url": "http://b.com", "content": nil},
+			},
+		},
+	})
+
+	ctx := access.WithFields(context.TODO(), mustFieldsFor(c, policy, "k3y"))
+	gqlRes := s.h.Execute(ctx, query, "", nil)
+	c.Assert(gqlRes.Errors, gc.HasLen, 0)
+	doc := gqlRes.Data.(map[string]interface{})["search"].(map[string]interface{})["documents"].([]interface{})[0]
+	c.Assert(doc, gc.DeepEquals, map[string]interface{}{"url": "http://b.com", "content": "Ovidius poeta in terra pontica"})
+
+	// Requests with unknown API keys are rejected.
+	req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(query), nil)
+	req.Header.Set(access.HeaderAPIKey, "unknown")
+	rec := httptest.NewRecorder()
+	s.h.ServeHTTP(rec, req)
+	c.Assert(rec.Code, gc.Equals, http.StatusUnauthorized)
+}
+
+func (s *GraphQLTestSuite) TestDepthLimit(c *gc.C) {
+	res := s.h.Execute(context.TODO(), `{ link(id: "x") { backlinks { backlinks { backlinks { backlinks { url } } } } } }`, "", nil)
+	c.Assert(res.Errors, gc.HasLen, 1)
+	c.Assert(res.Errors[0].Message, gc.Matches, ErrQueryTooDeep.Error()+".*")
+}
+
+func (s *GraphQLTestSuite) TestComplexityLimit(c *gc.C) {
+	// The nested backlinks are resolved once per item of the outer list
+	// which quickly exceeds the complexity budget.
+	res := s.h.Execute(context.TODO(), `query($n: Int) { link(id: "x") { backlinks(limit: $n) { backlinks { url } } } }`, "", map[string]interface{}{"n": float64(20)})
+	c.Assert(res.Errors, gc.HasLen, 1)
+	c.Assert(res.Errors[0].Message, gc.Matches, ErrQueryTooComplex.Error()+".*")
+
+	// Fragments are expanded when computing the complexity.
+	res = s.h.Execute(context.TODO(), `
+		fragment bl on Link { backlinks(limit: 20) { backlinks { url } } }
+		{ link(id: "x") { ...bl } }`, "", nil)
+	c.Assert(res.Errors, gc.HasLen, 1)
+	c.Assert(res.Errors[0].Message, gc.Matches, ErrQueryTooComplex.Error()+".*")
+}
+
+func (s *GraphQLTestSuite) TestMethodNotAllowed(c *gc.C) {
+	rec := httptest.NewRecorder()
+	s.h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/graphql", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusMethodNotAllowed)
+}
+
+func (s *GraphQLTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewHandler(Config{})
+	c.Assert(err, gc.ErrorMatches, "(?s).*graph API has not been provided.*index API has not been provided.*")
+	c.Assert(errors.Is(err, ErrQueryTooDeep), gc.Equals, false)
+}
+
+func mustFieldsFor(c *gc.C, policy *access.Policy, apiKey string) access.FieldSet {
+	fields, err := policy.FieldsFor(apiKey)
+	c.Assert(err, gc.IsNil)
+	return fields
+}
+
+// exec submits query via a POST request and returns the decoded response.
+func (s *GraphQLTestSuite) exec(c *gc.C, query string, variables map[string]interface{}) map[string]interface{} {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	c.Assert(err, gc.IsNil)
+
+	rec := httptest.NewRecorder()
+	s.h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+
+	var res map[string]interface{}
+	c.Assert(json.NewDecoder(rec.Body).Decode(&res), gc.IsNil)
+	return res
+}
@@ -0,0 +1,160 @@
+package gqlapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+var (
+	// ErrQueryTooDeep is returned for queries whose selection sets are
+	// nested deeper than the configured limit.
+	ErrQueryTooDeep = errors.New("query exceeds maximum depth")
+
+	// ErrQueryTooComplex is returned for queries whose complexity score
+	// exceeds the configured limit.
+	ErrQueryTooComplex = errors.New("query exceeds maximum complexity")
+)
+
+// backlinksScanCost is the extra complexity charged for resolving backlinks
+// as it requires a full scan of the graph edges.
+const backlinksScanCost = 100
+
+// listFields contains the names of the fields whose sub-selections are
+// resolved once per returned item.
+var listFields = map[string]bool{
+	"outEdges":  true,
+	"backlinks": true,
+	"search":    true,
+}
+
+// checkLimits parses query and ensures that it does not exceed the depth and
+// complexity limits in cfg.
+func checkLimits(query string, variables map[string]interface{}, cfg Config) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return err
+	}
+
+	a := &limitAnalyzer{
+		cfg:       cfg,
+		variables: variables,
+		fragments: make(map[string]*ast.FragmentDefinition),
+	}
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			a.fragments[frag.Name.Value] = frag
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		depth, complexity := a.analyze(op.SelectionSet, 0, nil)
+		if depth > cfg.MaxDepth {
+			return fmt.Errorf("%w: depth %d > %d", ErrQueryTooDeep, depth, cfg.MaxDepth)
+		}
+		if complexity > cfg.MaxComplexity {
+			return fmt.Errorf("%w: complexity %d > %d", ErrQueryTooComplex, complexity, cfg.MaxComplexity)
+		}
+	}
+	return nil
+}
+
+// limitAnalyzer computes the depth and complexity of a query document.
+type limitAnalyzer struct {
+	cfg       Config
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// analyze returns the maximum depth and the complexity of the provided
+// selection set. The visiting set keeps track of the fragments being expanded
+// so that fragment cycles (which are rejected by the query validator) do not
+// cause infinite recursion.
+func (a *limitAnalyzer) analyze(set *ast.SelectionSet, depth int, visiting map[string]bool) (int, int) {
+	if set == nil {
+		return depth, 0
+	}
+
+	maxDepth, complexity := depth, 0
+	for _, sel := range set.Selections {
+		var selDepth, selComplexity int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			selDepth, selComplexity = a.analyze(sel.SelectionSet, depth+1, visiting)
+			if listFields[sel.Name.Value] {
+				selComplexity *= a.listSize(sel)
+			}
+			if sel.Name.Value == "backlinks" {
+				selComplexity += backlinksScanCost
+			}
+			selComplexity++
+		case *ast.InlineFragment:
+			selDepth, selComplexity = a.analyze(sel.SelectionSet, depth, visiting)
+		case *ast.FragmentSpread:
+			frag := a.fragments[sel.Name.Value]
+			if frag == nil || visiting[sel.Name.Value] {
+				continue
+			}
+			nested := map[string]bool{sel.Name.Value: true}
+			for name := range visiting {
+				nested[name] = true
+			}
+			selDepth, selComplexity = a.analyze(frag.SelectionSet, depth, nested)
+		}
+
+		if selDepth > maxDepth {
+			maxDepth = selDepth
+		}
+		complexity += selComplexity
+	}
+	return maxDepth, complexity
+}
+
+// listSize returns the number of items that a list field may return based on
+// its limit argument.
+func (a *limitAnalyzer) listSize(field *ast.Field) int {
+	size := defaultListSize
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				size = n
+			}
+		case *ast.Variable:
+			switch n := a.variables[v.Name.Value].(type) {
+			case int:
+				size = n
+			case float64:
+				size = int(n)
+			}
+		}
+	}
+
+	if size <= 0 {
+		size = defaultListSize
+	} else if size > a.cfg.MaxListSize {
+		size = a.cfg.MaxListSize
+	}
+	return size
+}
+
+// errorResult wraps err into a GraphQL result.
+func errorResult(err error) *graphql.Result {
+	return &graphql.Result{
+		Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)},
+	}
+}
@@ -0,0 +1,493 @@
+package gqlapi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"webcrawler/crawler/iterutil"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/frontend/access"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+// The number of items returned by list fields when no limit is specified.
+const defaultListSize = 10
+
+var searchModeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "SearchMode",
+	Description: "The way that a search expression is interpreted.",
+	Values: graphql.EnumValueConfigMap{
+		"MATCH":   &graphql.EnumValueConfig{Value: index.QueryTypeMatch},
+		"PHRASE":  &graphql.EnumValueConfig{Value: index.QueryTypePhrase},
+		"BOOLEAN": &graphql.EnumValueConfig{Value: index.QueryTypeBoolean},
+	},
+})
+
+var verticalEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "Vertical",
+	Description: "A search vertical that groups documents of a particular content type.",
+	Values: graphql.EnumValueConfigMap{
+		"WEB":       &graphql.EnumValueConfig{Value: index.VerticalWeb},
+		"DOCUMENTS": &graphql.EnumValueConfig{Value: index.VerticalDocuments},
+		"NEWS":      &graphql.EnumValueConfig{Value: index.VerticalNews},
+	},
+})
+
+var relTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "RelType",
+	Description: "The kind of reference from the source of an edge to its destination.",
+	Values: graphql.EnumValueConfigMap{
+		"HYPERLINK": &graphql.EnumValueConfig{Value: graph.RelHyperlink},
+		"CANONICAL": &graphql.EnumValueConfig{Value: graph.RelCanonical},
+		"ALTERNATE": &graphql.EnumValueConfig{Value: graph.RelAlternate},
+		"REFRESH":   &graphql.EnumValueConfig{Value: graph.RelRefresh},
+		"IMAGE":     &graphql.EnumValueConfig{Value: graph.RelImage},
+		"SCRIPT":    &graphql.EnumValueConfig{Value: graph.RelScript},
+		"FRAME":     &graphql.EnumValueConfig{Value: graph.RelFrame},
+	},
+})
+
+// searchResult is the source object for the SearchResult type.
+type searchResult struct {
+	totalCount  uint64
+	docs        []*index.Document
+	suggestions []string
+}
+
+// newSchema builds the GraphQL schema and binds its fields to r.
+func newSchema(r *resolver) (graphql.Schema, error) {
+	limitArg := graphql.FieldConfigArgument{
+		"limit": &graphql.ArgumentConfig{
+			Type:        graphql.Int,
+			Description: "The maximum number of items to return.",
+		},
+	}
+
+	linkType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Link",
+		Description: "A link in the link graph.",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(*graph.Link).ID.String(), nil },
+			},
+			"url": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(*graph.Link).URL, nil },
+			},
+			"retrievedAt": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "The last time the link was crawled or null if it has not been crawled yet.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return unixToTime(p.Source.(*graph.Link).RetrievedAt), nil
+				},
+			},
+		},
+	})
+
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Edge",
+		Description: "A directed edge between two links.",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(*graph.Edge).ID.String(), nil },
+			},
+			"updatedAt": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return unixToTime(p.Source.(*graph.Edge).UpdatedAt), nil
+				},
+			},
+			"relType": &graphql.Field{
+				Type:    graphql.NewNonNull(relTypeEnum),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(*graph.Edge).RelType, nil },
+			},
+			"anchorText": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(*graph.Edge).AnchorText, nil },
+			},
+			"noFollow": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(*graph.Edge).NoFollow, nil },
+			},
+			"source": &graphql.Field{
+				Type: linkType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.findLink(p.Source.(*graph.Edge).Src)
+				},
+			},
+			"destination": &graphql.Field{
+				Type: linkType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.findLink(p.Source.(*graph.Edge).Dst)
+				},
+			},
+		},
+	})
+
+	documentType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Document",
+		Description: "An indexed web-page.",
+		Fields: graphql.Fields{
+			"linkID": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: restricted(access.FieldLinkID, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).LinkID.String(), nil
+				}),
+			},
+			"url": &graphql.Field{
+				Type: graphql.String,
+				Resolve: restricted(access.FieldURL, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).URL, nil
+				}),
+			},
+			"title": &graphql.Field{
+				Type: graphql.String,
+				Resolve: restricted(access.FieldTitle, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).Title, nil
+				}),
+			},
+			"content": &graphql.Field{
+				Type: graphql.String,
+				Resolve: restricted(access.FieldContent, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).Content, nil
+				}),
+			},
+			"language": &graphql.Field{
+				Type: graphql.String,
+				Resolve: restricted(access.FieldLanguage, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).Language, nil
+				}),
+			},
+			"vertical": &graphql.Field{
+				Type: graphql.NewNonNull(verticalEnum),
+				Resolve: restricted(access.FieldVertical, func(p graphql.ResolveParams) (interface{}, error) {
+					return index.VerticalOf(p.Source.(*index.Document)), nil
+				}),
+			},
+			"indexedAt": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: restricted(access.FieldIndexedAt, func(p graphql.ResolveParams) (interface{}, error) {
+					if ts := p.Source.(*index.Document).IndexedAt; !ts.IsZero() {
+						return ts, nil
+					}
+					return nil, nil
+				}),
+			},
+			"summary": &graphql.Field{
+				Type:        graphql.String,
+				Description: "An extractive summary of the document content (if generated).",
+				Resolve: restricted(access.FieldSummary, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).Summary, nil
+				}),
+			},
+			"pageRank": &graphql.Field{
+				Type: graphql.Float,
+				Resolve: restricted(access.FieldPageRank, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).PageRank, nil
+				}),
+			},
+			"faviconRef": &graphql.Field{
+				Type:        graphql.String,
+				Description: "The blob store key for the favicon of the document host (if captured).",
+				Resolve: restricted(access.FieldFaviconRef, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).FaviconRef, nil
+				}),
+			},
+			"thumbnailRef": &graphql.Field{
+				Type:        graphql.String,
+				Description: "The blob store key for a thumbnail of the rendered page (if captured).",
+				Resolve: restricted(access.FieldThumbnailRef, func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*index.Document).ThumbnailRef, nil
+				}),
+			},
+			"link": &graphql.Field{
+				Type:        linkType,
+				Description: "The link graph entry for the document.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.findLink(p.Source.(*index.Document).LinkID)
+				},
+			},
+		},
+	})
+
+	// The following fields introduce cycles between the types and are
+	// therefore added after all types have been defined.
+	linkType.AddFieldConfig("document", &graphql.Field{
+		Type:        documentType,
+		Description: "The indexed contents of the link or null if the link has not been indexed.",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return r.findDocument(p.Source.(*graph.Link).ID)
+		},
+	})
+	linkType.AddFieldConfig("outEdges", &graphql.Field{
+		Type:        graphql.NewList(edgeType),
+		Description: "The edges that originate from this link.",
+		Args: graphql.FieldConfigArgument{
+			"limit": limitArg["limit"],
+			"relTypes": &graphql.ArgumentConfig{
+				Type:        graphql.NewList(graphql.NewNonNull(relTypeEnum)),
+				Description: "If specified, only edges of these kinds are returned.",
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			relTypes, _ := p.Args["relTypes"].([]interface{})
+			return r.outEdges(p.Source.(*graph.Link).ID, relTypes, r.limit(p.Args))
+		},
+	})
+	linkType.AddFieldConfig("backlinks", &graphql.Field{
+		Type:        graphql.NewList(linkType),
+		Description: "The links that point to this link.",
+		Args:        limitArg,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return r.backlinks(p.Source.(*graph.Link).ID, r.limit(p.Args))
+		},
+	})
+
+	searchResultType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SearchResult",
+		Fields: graphql.Fields{
+			"totalCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return int(p.Source.(*searchResult).totalCount), nil
+				},
+			},
+			"documents": &graphql.Field{
+				Type:    graphql.NewList(documentType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(*searchResult).docs, nil },
+			},
+			"suggestions": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Description: "Corrected versions of the search query if it matched few documents.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*searchResult).suggestions, nil
+				},
+			},
+		},
+	})
+
+	idArg := graphql.FieldConfigArgument{
+		"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+	}
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"link": &graphql.Field{
+				Type: linkType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := parseID(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return r.findLink(id)
+				},
+			},
+			"document": &graphql.Field{
+				Type: documentType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := parseID(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return r.findDocument(id)
+				},
+			},
+			"search": &graphql.Field{
+				Type: searchResultType,
+				Args: graphql.FieldConfigArgument{
+					"query":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"mode":   &graphql.ArgumentConfig{Type: searchModeEnum, DefaultValue: index.QueryTypeMatch},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					"vertical": &graphql.ArgumentConfig{
+						Type:        verticalEnum,
+						Description: "Restricts the search to a single vertical. If omitted, all verticals are searched.",
+					},
+					"limit": limitArg["limit"],
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					offset, _ := p.Args["offset"].(int)
+					if offset < 0 {
+						return nil, fmt.Errorf("offset must not be negative")
+					}
+					mode, _ := p.Args["mode"].(index.QueryType)
+					vertical, _ := p.Args["vertical"].(string)
+					return r.search(index.Query{
+						Type:       mode,
+						Expression: p.Args["query"].(string),
+						Offset:     uint64(offset),
+						Vertical:   vertical,
+					}, r.limit(p.Args))
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// resolver implements the data access logic for the schema fields.
+type resolver struct {
+	cfg Config
+}
+
+// limit returns the effective list size for a field given its arguments.
+func (r *resolver) limit(args map[string]interface{}) int {
+	limit, ok := args["limit"].(int)
+	if !ok || limit <= 0 {
+		limit = defaultListSize
+	}
+	if limit > r.cfg.MaxListSize {
+		limit = r.cfg.MaxListSize
+	}
+	return limit
+}
+
+// findLink looks up a link returning nil if it does not exist.
+func (r *resolver) findLink(id uuid.UUID) (*graph.Link, error) {
+	link, err := r.cfg.GraphAPI.FindLink(id)
+	if errors.Is(err, graph.ErrNotFound) {
+		return nil, nil
+	}
+	return link, err
+}
+
+// findDocument looks up a document returning nil if it does not exist.
+func (r *resolver) findDocument(id uuid.UUID) (*index.Document, error) {
+	doc, err := r.cfg.IndexAPI.FindByID(id)
+	if errors.Is(err, index.ErrNotFound) {
+		return nil, nil
+	}
+	return doc, err
+}
+
+// outEdges returns up to limit edges that originate from the specified link.
+// If relTypes is not empty, only edges of those kinds are returned.
+func (r *resolver) outEdges(src uuid.UUID, relTypes []interface{}, limit int) ([]*graph.Edge, error) {
+	it, err := r.cfg.GraphAPI.Edges(src, nextUUID(src), edgesUpdatedBefore())
+	if err != nil {
+		return nil, err
+	}
+	return collectEdges(it, limit, func(e *graph.Edge) bool {
+		if len(relTypes) == 0 {
+			return true
+		}
+		for _, relType := range relTypes {
+			if relType == e.RelType {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// backlinks returns up to limit links with an edge pointing to dst. As the
+// graph only supports iterating edges by their source, this requires a full
+// scan of the edge set and is therefore accounted for in the complexity
+// score of a query.
+func (r *resolver) backlinks(dst uuid.UUID, limit int) ([]*graph.Link, error) {
+	it, err := r.cfg.GraphAPI.Edges(uuid.Nil, maxUUID, edgesUpdatedBefore())
+	if err != nil {
+		return nil, err
+	}
+	edges, err := collectEdges(it, limit, func(e *graph.Edge) bool { return e.Dst == dst })
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*graph.Link, 0, len(edges))
+	for _, edge := range edges {
+		link, err := r.findLink(edge.Src)
+		if err != nil {
+			return nil, err
+		} else if link != nil {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// search runs q against the indexer and returns up to limit documents.
+func (r *resolver) search(q index.Query, limit int) (*searchResult, error) {
+	q.Size = uint64(limit)
+	it, err := r.cfg.IndexAPI.Search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &searchResult{totalCount: it.TotalCount(), suggestions: []string{}}
+	for _, s := range it.Suggestions() {
+		res.suggestions = append(res.suggestions, s.Expression)
+	}
+	for len(res.docs) < limit && it.Next() {
+		res.docs = append(res.docs, it.Document())
+	}
+	if err = it.Error(); err != nil {
+		_ = it.Close()
+		return nil, err
+	}
+	return res, it.Close()
+}
+
+func collectEdges(it graph.EdgeIterator, limit int, filter func(*graph.Edge) bool) ([]*graph.Edge, error) {
+	return iterutil.CollectEdges(iterutil.LimitEdges(iterutil.FilterEdges(it, filter), limit))
+}
+
+func parseID(args map[string]interface{}) (uuid.UUID, error) {
+	id, err := uuid.Parse(args["id"].(string))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid ID: %w", err)
+	}
+	return id, nil
+}
+
+// edgesUpdatedBefore returns a timestamp that includes all existing edges.
+func edgesUpdatedBefore() int64 {
+	return time.Now().Add(time.Minute).Unix()
+}
+
+var maxUUID = uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+
+// nextUUID returns the UUID that immediately follows id so that [id,
+// nextUUID(id)) ranges only contain id.
+func nextUUID(id uuid.UUID) uuid.UUID {
+	if id == maxUUID {
+		return maxUUID
+	}
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i]++
+		if id[i] != 0 {
+			break
+		}
+	}
+	return id
+}
+
+func unixToTime(ts int64) interface{} {
+	if ts == 0 {
+		return nil
+	}
+	return time.Unix(ts, 0).UTC()
+}
+
+// errFieldRestricted is returned when resolving a document field that is not
+// visible to the API key of the request.
+var errFieldRestricted = errors.New("field is not accessible with the provided API key")
+
+// restricted wraps the resolver for a document field so that it fails with
+// errFieldRestricted if the field is not visible to the API key of the
+// request. Fields are unrestricted if no access policy has been configured.
+func restricted(field string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if fields, ok := access.FieldsFromContext(p.Context); ok && !fields.Has(field) {
+			return nil, errFieldRestricted
+		}
+		return resolve(p)
+	}
+}
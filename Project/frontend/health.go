@@ -0,0 +1,15 @@
+package frontend
+
+import "webcrawler/health"
+
+// HealthChecker defines the operation required by the frontend for reporting
+// the health of a component at /healthz or its readiness at /readyz.
+type HealthChecker = health.Checker
+
+// registerHealthRoutes registers the liveness and readiness endpoints. Both
+// respond with a 503 status if any of their checks fail so that load
+// balancers and orchestrators can act on it.
+func (h *Handler) registerHealthRoutes() {
+	h.mux.Handle("GET /healthz", health.Handler(h.cfg.HealthChecks))
+	h.mux.Handle("GET /readyz", health.Handler(h.cfg.ReadinessChecks))
+}
@@ -0,0 +1,139 @@
+package frontend
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"webcrawler/crawler/textindexer/index"
+)
+
+// queryTerms returns the lower-cased terms of a search query.
+func queryTerms(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var (
+		terms []string
+		seen  = make(map[string]bool)
+	)
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// makeSnippet returns an HTML snippet of at most maxLen characters from
+// content. The snippet is centred around the first occurrence of any of the
+// query terms and each word that starts with one of the terms is wrapped in
+// an <em> tag. The rest of the snippet is HTML-escaped.
+func makeSnippet(content string, terms []string, maxLen int) template.HTML {
+	content = strings.Join(strings.Fields(content), " ")
+	if content == "" {
+		return ""
+	}
+
+	termRegex := termRegexp(terms)
+
+	// Select a window of maxLen characters that starts a few words before
+	// the first match.
+	start := 0
+	if termRegex != nil {
+		if loc := termRegex.FindStringIndex(content); loc != nil {
+			start = windowStart(content, loc[0], maxLen/4)
+		}
+	}
+	end, truncated := windowEnd(content, start, maxLen)
+	window := content[start:end]
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+	last := 0
+	if termRegex != nil {
+		for _, loc := range termRegex.FindAllStringIndex(window, -1) {
+			b.WriteString(html.EscapeString(window[last:loc[0]]))
+			b.WriteString("<em>")
+			b.WriteString(html.EscapeString(window[loc[0]:loc[1]]))
+			b.WriteString("</em>")
+			last = loc[1]
+		}
+	}
+	b.WriteString(html.EscapeString(window[last:]))
+	if truncated {
+		b.WriteString(" …")
+	}
+	return template.HTML(b.String())
+}
+
+// resultSnippet returns the snippet for a search result. The snippet is
+// generated from the document summary when the content does not contain any
+// of the query terms (e.g. when the document matched via its title) as
+// the highlighted fragment would otherwise be an arbitrary prefix of the
+// content.
+func resultSnippet(doc *index.Document, terms []string, maxLen int) template.HTML {
+	if doc.Summary != "" {
+		if termRegex := termRegexp(terms); termRegex == nil || !termRegex.MatchString(doc.Content) {
+			return makeSnippet(doc.Summary, terms, maxLen)
+		}
+	}
+	return makeSnippet(doc.Content, terms, maxLen)
+}
+
+// termRegexp returns a case-insensitive regular expression that matches the
+// words that start with one of terms or nil if no terms are specified.
+func termRegexp(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)[\pL\pN]*`)
+}
+
+// windowStart returns the offset of the word boundary that precedes matchAt
+// by at most lead characters.
+func windowStart(content string, matchAt, lead int) int {
+	start := matchAt
+	for n := 0; start > 0 && n < lead; n++ {
+		_, size := utf8.DecodeLastRuneInString(content[:start])
+		start -= size
+	}
+	if start == 0 {
+		return 0
+	}
+	if space := strings.IndexByte(content[start:matchAt], ' '); space != -1 {
+		return start + space + 1
+	}
+	return matchAt
+}
+
+// windowEnd returns the end offset of a window of at most maxLen characters
+// that starts at start and ends at a word boundary, and whether the window
+// ends before the end of content.
+func windowEnd(content string, start, maxLen int) (int, bool) {
+	end := start
+	for n := 0; end < len(content) && n < maxLen; n++ {
+		_, size := utf8.DecodeRuneInString(content[end:])
+		end += size
+	}
+	if end == len(content) {
+		return end, false
+	}
+	if content[end] == ' ' {
+		return end, true
+	}
+	if space := strings.LastIndexByte(content[start:end], ' '); space > 0 {
+		end = start + space
+	}
+	return end, true
+}
@@ -0,0 +1,135 @@
+package frontend
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"webcrawler/pagerank/history"
+
+	"github.com/google/uuid"
+)
+
+// Score changes smaller than this fraction of the initial score are reported
+// as stable.
+const stableTrendThreshold = 0.01
+
+// Trend directions reported by the score trend API.
+const (
+	trendRising  = "rising"
+	trendFalling = "falling"
+	trendStable  = "stable"
+)
+
+// ScoreHistoryAPI defines the set of PageRank score history operations
+// required by the score trend API.
+type ScoreHistoryAPI interface {
+	// LinkTrend returns the scores of a link for the passes that
+	// completed at or after since, oldest first.
+	LinkTrend(linkID uuid.UUID, since time.Time) ([]history.Point, error)
+
+	// DomainTrend returns the aggregated scores of the links of host for
+	// the passes that completed at or after since, oldest first.
+	DomainTrend(host string, since time.Time) ([]history.Point, error)
+}
+
+// apiScoreTrendResponse describes the PageRank score trend of a link or
+// domain.
+type apiScoreTrendResponse struct {
+	LinkID *uuid.UUID `json:"linkID,omitempty"`
+	Domain string     `json:"domain,omitempty"`
+
+	Points []history.Point `json:"points"`
+
+	// The difference between the newest and the oldest score and whether
+	// it amounts to the link or domain gaining or losing authority.
+	Change    float64 `json:"change"`
+	Direction string  `json:"direction"`
+}
+
+func (h *Handler) registerTrendRoutes() {
+	h.mux.HandleFunc("GET "+apiPrefix+"/links/{linkID}/pagerank", h.apiLinkTrend)
+	h.mux.HandleFunc("GET "+apiPrefix+"/domains/{host}/pagerank", h.apiDomainTrend)
+}
+
+// apiLinkTrend returns the score trend for a link. The optional "since"
+// parameter limits the trend to recent passes; see parseSince.
+func (h *Handler) apiLinkTrend(w http.ResponseWriter, r *http.Request) {
+	linkID, err := uuid.Parse(r.PathValue("linkID"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "invalid link ID")
+		return
+	}
+	since, ok := parseSince(w, r)
+	if !ok {
+		return
+	}
+
+	points, err := h.cfg.ScoreHistory.LinkTrend(linkID, since)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "unable to retrieve score history")
+		return
+	} else if len(points) == 0 {
+		writeAPIError(w, http.StatusNotFound, apiErrNotFound, "no score history for link")
+		return
+	}
+	writeJSON(w, http.StatusOK, newScoreTrendResponse(&linkID, "", points))
+}
+
+// apiDomainTrend returns the aggregated score trend for the links of a host.
+// The optional "since" parameter limits the trend to recent passes; see
+// parseSince.
+func (h *Handler) apiDomainTrend(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(r.PathValue("host"))
+	since, ok := parseSince(w, r)
+	if !ok {
+		return
+	}
+
+	points, err := h.cfg.ScoreHistory.DomainTrend(host, since)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, apiErrInternal, "unable to retrieve score history")
+		return
+	} else if len(points) == 0 {
+		writeAPIError(w, http.StatusNotFound, apiErrNotFound, "no score history for domain")
+		return
+	}
+	writeJSON(w, http.StatusOK, newScoreTrendResponse(nil, host, points))
+}
+
+// parseSince parses the "since" parameter which is either an RFC 3339
+// timestamp or a duration relative to the current time (e.g. "720h"). If the
+// parameter is missing, the zero time is returned.
+func parseSince(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		return time.Time{}, true
+	}
+	if since, err := time.Parse(time.RFC3339, v); err == nil {
+		return since, true
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return time.Now().Add(-d), true
+	}
+	writeAPIError(w, http.StatusBadRequest, apiErrInvalidArgument, "since must be an RFC 3339 timestamp or a positive duration")
+	return time.Time{}, false
+}
+
+func newScoreTrendResponse(linkID *uuid.UUID, domain string, points []history.Point) apiScoreTrendResponse {
+	first, last := points[0].Score, points[len(points)-1].Score
+	res := apiScoreTrendResponse{
+		LinkID:    linkID,
+		Domain:    domain,
+		Points:    points,
+		Change:    last - first,
+		Direction: trendStable,
+	}
+	if math.Abs(res.Change) > stableTrendThreshold*first {
+		if res.Change > 0 {
+			res.Direction = trendRising
+		} else {
+			res.Direction = trendFalling
+		}
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+// Package health implements the liveness and readiness reports that the
+// webcrawler services expose over HTTP so that load balancers and
+// orchestrators such as Kubernetes can act on them.
+//
+// A report runs a set of named checks and responds with a JSON document that
+// lists the outcome of each check. The response status is 200 if all checks
+// pass and 503 otherwise.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// The statuses included in health reports.
+const (
+	StatusOK        = "ok"
+	StatusUnhealthy = "unhealthy"
+)
+
+// DefaultPingTimeout is the default time limit for the checks returned by
+// PingChecker.
+const DefaultPingTimeout = 2 * time.Second
+
+// Checker is implemented by components whose health can be reported.
+type Checker interface {
+	// CheckHealth returns a non-nil error if the component is unhealthy.
+	// The returned details, if any, are included in the health report
+	// either way.
+	CheckHealth() (details interface{}, err error)
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func() (interface{}, error)
+
+// CheckHealth implements Checker.
+func (f CheckerFunc) CheckHealth() (interface{}, error) { return f() }
+
+// Pinger is implemented by stores that are backed by a remote server.
+type Pinger interface {
+	// Ping returns an error if the server cannot be reached or is unable
+	// to serve requests.
+	Ping(ctx context.Context) error
+}
+
+// PingChecker returns a Checker that pings p. Pings that do not complete
+// within timeout fail. If timeout is not positive, DefaultPingTimeout is
+// used.
+func PingChecker(p Pinger, timeout time.Duration) Checker {
+	if timeout <= 0 {
+		timeout = DefaultPingTimeout
+	}
+	return CheckerFunc(func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return nil, p.Ping(ctx)
+	})
+}
+
+// Report is the response body of the handlers returned by Handler.
+type Report struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks,omitempty"`
+}
+
+// Check describes the outcome of a single health check.
+type Check struct {
+	Status  string      `json:"status"`
+	Error   string      `json:"error,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Run executes checks and returns the resulting report.
+func Run(checks map[string]Checker) Report {
+	rep := Report{Status: StatusOK, Checks: make(map[string]Check, len(checks))}
+	for name, checker := range checks {
+		details, err := checker.CheckHealth()
+		check := Check{Status: StatusOK, Details: details}
+		if err != nil {
+			check.Status, check.Error = StatusUnhealthy, err.Error()
+			rep.Status = StatusUnhealthy
+		}
+		rep.Checks[name] = check
+	}
+	return rep
+}
+
+// Handler returns an HTTP handler that runs checks on every request and
+// responds with the resulting report.
+func Handler(checks map[string]Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		rep := Run(checks)
+		status := http.StatusOK
+		if rep.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(rep)
+	})
+}
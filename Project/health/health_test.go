@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(HealthTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type HealthTestSuite struct{}
+
+func (s *HealthTestSuite) TestHandler(c *gc.C) {
+	var dbErr error
+	h := Handler(map[string]Checker{
+		"db":    CheckerFunc(func() (interface{}, error) { return nil, dbErr }),
+		"index": CheckerFunc(func() (interface{}, error) { return map[string]int{"docs": 3}, nil }),
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "application/json")
+	c.Assert(strings.TrimSpace(rec.Body.String()), gc.Equals, `{"status":"ok","checks":{"db":{"status":"ok"},"index":{"status":"ok","details":{"docs":3}}}}`)
+
+	dbErr = errors.New("connection refused")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusServiceUnavailable)
+	c.Assert(strings.TrimSpace(rec.Body.String()), gc.Equals, `{"status":"unhealthy","checks":{"db":{"status":"unhealthy","error":"connection refused"},"index":{"status":"ok","details":{"docs":3}}}}`)
+}
+
+func (s *HealthTestSuite) TestHandlerWithoutChecks(c *gc.C) {
+	rec := httptest.NewRecorder()
+	Handler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(strings.TrimSpace(rec.Body.String()), gc.Equals, `{"status":"ok"}`)
+}
+
+func (s *HealthTestSuite) TestPingChecker(c *gc.C) {
+	_, err := PingChecker(pingFunc(func(context.Context) error { return nil }), 0).CheckHealth()
+	c.Assert(err, gc.IsNil)
+
+	// Pings that outlive the timeout fail.
+	slow := pingFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	_, err = PingChecker(slow, 10*time.Millisecond).CheckHealth()
+	c.Assert(errors.Is(err, context.DeadlineExceeded), gc.Equals, true)
+}
+
+type pingFunc func(context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error { return f(ctx) }
@@ -0,0 +1,110 @@
+// Package logging provides the structured logger that is shared by the
+// webcrawler components. Loggers are created once per service via New and
+// handed to each component (graph stores, indexers, crawler stages etc.)
+// through its configuration. Components that are not provided with a logger
+// fall back to Discard so logging remains optional.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Supported output formats.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config encapsulates the settings for creating a new logger.
+type Config struct {
+	// The minimum level of the emitted records; one of "debug", "info",
+	// "warn" or "error". Defaults to "info".
+	Level string
+
+	// The output format; one of "json" or "text". Defaults to "json".
+	Format string
+
+	// The writer where records are written to. Defaults to os.Stderr.
+	Output io.Writer
+}
+
+// New returns a logger that writes records using the settings in cfg.
+func New(cfg Config) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatJSON:
+		return slog.New(slog.NewJSONHandler(out, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(out, opts)), nil
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q; expected one of %q or %q", cfg.Format, FormatJSON, FormatText)
+	}
+}
+
+// ParseLevel parses a level name. An empty name maps to the info level.
+func ParseLevel(name string) (slog.Level, error) {
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("logging: unknown level %q; expected one of \"debug\", \"info\", \"warn\" or \"error\"", name)
+	}
+	return level, nil
+}
+
+// Discard returns a logger that drops all records.
+func Discard() *slog.Logger {
+	return slog.New(discardHandler{})
+}
+
+// Component returns a child of logger whose records are tagged with the
+// specified component name. If logger is nil, a discarding logger is
+// returned instead.
+func Component(logger *slog.Logger, name string) *slog.Logger {
+	if logger == nil {
+		return Discard()
+	}
+	return logger.With(slog.String("component", name))
+}
+
+// Link returns an attribute group that identifies the link a record refers
+// to. The group contains the link ID and, if rawURL is not empty, the link
+// URL and its host.
+func Link(id uuid.UUID, rawURL string) slog.Attr {
+	attrs := []any{slog.String("id", id.String())}
+	if rawURL != "" {
+		attrs = append(attrs, slog.String("url", rawURL))
+		if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+			attrs = append(attrs, slog.String("host", u.Hostname()))
+		}
+	}
+	return slog.Group("link", attrs...)
+}
+
+// discardHandler is a slog.Handler that drops all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(LoggingTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type LoggingTestSuite struct{}
+
+func (s *LoggingTestSuite) TestJSONOutput(c *gc.C) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "debug", Output: &buf})
+	c.Assert(err, gc.IsNil)
+
+	id := uuid.New()
+	Component(logger, "crawler.fetcher").Debug("skipping link", Link(id, "http://example.com:8080/foo"), "status", 404)
+
+	var rec map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &rec), gc.IsNil)
+	c.Assert(rec["level"], gc.Equals, "DEBUG")
+	c.Assert(rec["msg"], gc.Equals, "skipping link")
+	c.Assert(rec["component"], gc.Equals, "crawler.fetcher")
+	c.Assert(rec["status"], gc.Equals, float64(404))
+	c.Assert(rec["link"], gc.DeepEquals, map[string]interface{}{
+		"id":   id.String(),
+		"url":  "http://example.com:8080/foo",
+		"host": "example.com",
+	})
+}
+
+func (s *LoggingTestSuite) TestLevelFiltering(c *gc.C) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "WARN", Format: FormatText, Output: &buf})
+	c.Assert(err, gc.IsNil)
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+	c.Assert(strings.Contains(buf.String(), "dropped"), gc.Equals, false)
+	c.Assert(strings.Contains(buf.String(), "level=WARN msg=kept"), gc.Equals, true, gc.Commentf(buf.String()))
+}
+
+func (s *LoggingTestSuite) TestInvalidConfig(c *gc.C) {
+	_, err := New(Config{Level: "verbose"})
+	c.Assert(err, gc.ErrorMatches, `logging: unknown level "verbose".*`)
+
+	_, err = New(Config{Format: "xml"})
+	c.Assert(err, gc.ErrorMatches, `logging: unknown format "xml".*`)
+}
+
+func (s *LoggingTestSuite) TestDiscard(c *gc.C) {
+	logger := Component(nil, "foo")
+	c.Assert(logger.Enabled(context.Background(), slog.LevelError), gc.Equals, false)
+}
@@ -0,0 +1,263 @@
+// Package metrics defines the Prometheus metrics that are exported by the
+// webcrawler services. All metrics are registered with Registry which also
+// includes the standard Go runtime and process collectors. Services expose
+// the metrics by mounting Handler on their HTTP server (e.g. at /metrics).
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The namespace shared by all webcrawler metrics.
+const namespace = "webcrawler"
+
+// Registry holds all the metrics exported by the webcrawler services.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// PagesFetched counts the pages that were successfully fetched by the
+	// crawler. The fetch rate can be derived via the rate() function.
+	PagesFetched = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "pages_fetched_total",
+		Help:      "The number of pages fetched by the crawler.",
+	})
+
+	// FetchDuration tracks the time it takes for the crawler to fetch a
+	// page, including reading the response body.
+	FetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "fetch_duration_seconds",
+		Help:      "The time spent fetching pages.",
+		Buckets:   prometheus.ExponentialBuckets(0.025, 2, 10),
+	})
+
+	// FetchResponses counts the responses received by the crawler by HTTP
+	// status code. Requests that failed without a response are counted
+	// with the "error" code.
+	FetchResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "fetch_responses_total",
+		Help:      "The number of fetch responses by HTTP status code.",
+	}, []string{"code"})
+
+	// FetchContentEncodings counts the fetch responses by the content
+	// encoding of their body ("identity" for bodies that are not encoded).
+	FetchContentEncodings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "fetch_content_encodings_total",
+		Help:      "The number of fetch responses by content encoding.",
+	}, []string{"encoding"})
+
+	// SkippedBodies counts the fetch responses whose body was discarded by
+	// reason: "unsupported_encoding", "decode_error" or "too_large".
+	SkippedBodies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "skipped_bodies_total",
+		Help:      "The number of fetch responses whose body was discarded.",
+	}, []string{"reason"})
+
+	// DuplicatePages counts the crawled pages whose content was detected
+	// as a near-duplicate of a previously crawled page.
+	DuplicatePages = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "duplicate_pages_total",
+		Help:      "The number of crawled pages with near-duplicate content.",
+	})
+
+	// UnchangedDocuments counts the re-crawled pages that were not
+	// re-indexed because their content hash matched the one stored in the
+	// link graph.
+	UnchangedDocuments = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "unchanged_documents_total",
+		Help:      "The number of re-crawled pages whose indexing was skipped as their content did not change.",
+	})
+
+	// OutOfScopeLinks counts the discovered links that were dropped because
+	// they fall outside the crawl scope by reason (e.g. "host", "url",
+	// "depth", "off_site" or "domain_quota").
+	OutOfScopeLinks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "out_of_scope_links_total",
+		Help:      "The number of discovered links dropped for being outside the crawl scope.",
+	}, []string{"reason"})
+
+	// RegionSkippedLinks counts the links that were skipped by a crawl
+	// pass because their host is crawled by another region.
+	RegionSkippedLinks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "region_skipped_links_total",
+		Help:      "The number of links skipped because their host is crawled by another region.",
+	})
+
+	// PacingWait tracks the time that fetch workers spend waiting for the
+	// next request slot of a host.
+	PacingWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "pacing_wait_seconds",
+		Help:      "The time spent waiting for per-host request slots.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+	})
+
+	// ThrottledLinks counts the links that were skipped because their host
+	// asked the crawler to back off for longer than it is willing to wait.
+	ThrottledLinks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "throttled_links_total",
+		Help:      "The number of links skipped because their host was throttled.",
+	})
+
+	// RobotsLookups counts the robots.txt policy lookups by the source that
+	// served them: "local" (the worker's own cache), "shared" (the store
+	// shared by all workers) or "fetched" (the robots.txt file was fetched).
+	RobotsLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "robots_lookups_total",
+		Help:      "The number of robots.txt policy lookups by source.",
+	}, []string{"source"})
+
+	// FrontierAge tracks the time since the links that the crawler picks
+	// up for a new crawl were last retrieved. Links that have never been
+	// retrieved are not tracked.
+	FrontierAge = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "frontier_age_seconds",
+		Help:      "The time since the crawled links were last retrieved.",
+		Buckets:   prometheus.ExponentialBuckets(60, 4, 10),
+	})
+
+	// IndexLag tracks the time between fetching a page and writing it to
+	// the text index.
+	IndexLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "index_lag_seconds",
+		Help:      "The time between fetching and indexing pages.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	})
+
+	// GraphUpsertDuration tracks the latency of link graph upserts by
+	// store and entity ("link" or "edge").
+	GraphUpsertDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "linkgraph",
+		Name:      "upsert_duration_seconds",
+		Help:      "The time spent upserting links and edges into the link graph.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"store", "entity"})
+
+	// ESRequestDuration tracks the latency of the requests issued to the
+	// elasticsearch cluster by operation (e.g. "bulk", "search").
+	ESRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "textindexer",
+		Name:      "es_request_duration_seconds",
+		Help:      "The time spent on elasticsearch requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"operation"})
+
+	// SuperstepDuration tracks the time it takes to execute a single
+	// superstep of a BSP graph algorithm such as a PageRank iteration.
+	SuperstepDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "bspgraph",
+		Name:      "superstep_duration_seconds",
+		Help:      "The time spent executing graph supersteps.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+
+	// QueueMessages counts the link messages of the queue-based crawl
+	// pipeline by outcome ("published", "acked", "retried" or
+	// "dead_lettered").
+	QueueMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "queue_messages_total",
+		Help:      "The number of link queue messages by outcome.",
+	}, []string{"outcome"})
+
+	// LinkFailures counts the failed link fetches that were recorded in
+	// the link graph by outcome ("retry_scheduled" or "quarantined").
+	LinkFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "link_failures_total",
+		Help:      "The number of recorded link fetch failures by outcome.",
+	}, []string{"outcome"})
+
+	// BackupJobs counts the text index backup jobs by type ("backup",
+	// "retention" or "verify") and result ("success" or "failure").
+	BackupJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "textindexer",
+		Name:      "backup_jobs_total",
+		Help:      "The number of text index backup jobs executed.",
+	}, []string{"job", "result"})
+
+	// BackupLastSuccess records the unix timestamp of the last successful
+	// text index backup job by type.
+	BackupLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "textindexer",
+		Name:      "backup_last_success_timestamp_seconds",
+		Help:      "The time when each type of text index backup job last succeeded.",
+	}, []string{"job"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PagesFetched,
+		FetchDuration,
+		FetchResponses,
+		FetchContentEncodings,
+		SkippedBodies,
+		DuplicatePages,
+		UnchangedDocuments,
+		OutOfScopeLinks,
+		RegionSkippedLinks,
+		PacingWait,
+		ThrottledLinks,
+		RobotsLookups,
+		FrontierAge,
+		IndexLag,
+		GraphUpsertDuration,
+		ESRequestDuration,
+		SuperstepDuration,
+		QueueMessages,
+		LinkFailures,
+		BackupJobs,
+		BackupLastSuccess,
+	)
+}
+
+// Handler returns an http.Handler that serves the metrics in Registry using
+// the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveSince records the time elapsed since start in obs.
+func ObserveSince(obs prometheus.Observer, start time.Time) {
+	obs.Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(MetricsTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type MetricsTestSuite struct{}
+
+func (s *MetricsTestSuite) TestHandler(c *gc.C) {
+	PagesFetched.Inc()
+	FetchResponses.WithLabelValues("404").Inc()
+	ObserveSince(GraphUpsertDuration.WithLabelValues("memory", "link"), time.Now().Add(-time.Second))
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+
+	body := rec.Body.String()
+	for _, exp := range []string{
+		"webcrawler_crawler_pages_fetched_total ",
+		`webcrawler_crawler_fetch_responses_total{code="404"} `,
+		`webcrawler_linkgraph_upsert_duration_seconds_count{entity="link",store="memory"} `,
+		"go_goroutines ",
+	} {
+		c.Assert(strings.Contains(body, exp), gc.Equals, true, gc.Commentf("expected output to contain %q", exp))
+	}
+}
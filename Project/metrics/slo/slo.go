@@ -0,0 +1,392 @@
+// Package slo derives service level indicators for the crawl pipeline from
+// the metrics in the metrics package. A Tracker periodically samples the
+// relevant counters and histograms and reports the following indicators over
+// a set of rolling windows:
+//
+//   - the rate at which pages are fetched (pages/sec);
+//   - the mean time between fetching a page and writing it to the index;
+//   - the 95th percentile of the time since the crawled links were last
+//     retrieved (frontier age);
+//   - the fraction of the fetch error budget that was consumed.
+//
+// The indicators are computed the same way as the equivalent PromQL
+// expressions (rate, histogram_quantile) so that dashboards can consume them
+// directly instead of reimplementing the derivations from the raw series.
+// As the samples are taken from the metrics registry of the current process,
+// the indicators only reflect the services running in the same process.
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The names of the metric families that the indicators are derived from.
+const (
+	pagesFetchedMetric   = "webcrawler_crawler_pages_fetched_total"
+	fetchResponsesMetric = "webcrawler_crawler_fetch_responses_total"
+	indexLagMetric       = "webcrawler_crawler_index_lag_seconds"
+	frontierAgeMetric    = "webcrawler_crawler_frontier_age_seconds"
+)
+
+// The quantile of the frontier age distribution that is reported.
+const frontierAgeQuantile = 0.95
+
+// Config encapsulates the settings for the SLO tracker.
+type Config struct {
+	// The source of the sampled metrics. Defaults to metrics.Registry.
+	Gatherer prometheus.Gatherer
+
+	// How often the metrics are sampled. Defaults to 15 seconds.
+	SampleInterval time.Duration
+
+	// The rolling windows over which the indicators are computed.
+	// Defaults to 5 minutes, 1 hour and 24 hours.
+	Windows []time.Duration
+
+	// The target fraction of fetches that must succeed. Fetches that
+	// fail without a response or with a 5xx status code count against
+	// the error budget. Defaults to 0.99.
+	FetchSuccessTarget float64
+
+	// A clock for timestamping samples. Defaults to time.Now.
+	Clock func() time.Time
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.Gatherer == nil {
+		cfg.Gatherer = metrics.Registry
+	}
+	if cfg.SampleInterval < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid value for sample interval"))
+	} else if cfg.SampleInterval == 0 {
+		cfg.SampleInterval = 15 * time.Second
+	}
+	if len(cfg.Windows) == 0 {
+		cfg.Windows = []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour}
+	}
+	for _, w := range cfg.Windows {
+		if w <= 0 {
+			err = multierror.Append(err, fmt.Errorf("invalid value for window %s", w))
+		}
+	}
+	if cfg.FetchSuccessTarget < 0 || cfg.FetchSuccessTarget >= 1 {
+		err = multierror.Append(err, fmt.Errorf("fetch success target must be in the [0, 1) range"))
+	} else if cfg.FetchSuccessTarget == 0 {
+		cfg.FetchSuccessTarget = 0.99
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return err
+}
+
+// Report describes the indicators for each of the configured windows.
+type Report struct {
+	GeneratedAt        time.Time      `json:"generatedAt"`
+	FetchSuccessTarget float64        `json:"fetchSuccessTarget"`
+	Windows            []WindowReport `json:"windows"`
+}
+
+// WindowReport describes the indicators for a single rolling window.
+// Indicators that cannot be computed because no observations were recorded
+// in the window are omitted.
+type WindowReport struct {
+	// The length of the window in seconds.
+	WindowSeconds float64 `json:"windowSeconds"`
+
+	// The part of the window that is covered by samples. It is shorter
+	// than the window until the tracker has been running for the whole
+	// window.
+	CoveredSeconds float64 `json:"coveredSeconds"`
+
+	PagesPerSecond        float64  `json:"pagesPerSecond"`
+	IndexLagSeconds       *float64 `json:"indexLagSeconds,omitempty"`
+	FrontierAgeP95Seconds *float64 `json:"frontierAgeP95Seconds,omitempty"`
+
+	// The fraction of fetches that failed and the fraction of the error
+	// budget that they consumed; values above 1 indicate that the budget
+	// was exceeded.
+	FetchErrorRatio     float64 `json:"fetchErrorRatio"`
+	ErrorBudgetConsumed float64 `json:"errorBudgetConsumed"`
+}
+
+// Tracker samples the pipeline metrics and reports the SLO indicators
+// derived from them.
+type Tracker struct {
+	cfg    Config
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// The collected samples, oldest first.
+	samples []*sample
+}
+
+// NewTracker returns a new SLO tracker using the provided config.
+func NewTracker(cfg Config) (*Tracker, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("slo tracker: config validation failed: %w", err)
+	}
+	return &Tracker{cfg: cfg, logger: logging.Component(cfg.Logger, "metrics.slo")}, nil
+}
+
+// Name returns the name of the tracker when running as a service.
+func (t *Tracker) Name() string { return "slo" }
+
+// Run samples the metrics every SampleInterval until ctx is cancelled.
+// Sampling failures are logged.
+func (t *Tracker) Run(ctx context.Context) error {
+	t.logger.Info("starting service", "sample_interval", t.cfg.SampleInterval)
+	defer t.logger.Info("stopped service")
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		if err := t.Sample(); err != nil {
+			t.logger.Warn("unable to sample metrics", "err", err)
+		}
+		timer.Reset(t.cfg.SampleInterval)
+	}
+}
+
+// Sample records the current values of the metrics. Samples that are no
+// longer needed for computing the indicators of the longest window are
+// discarded.
+func (t *Tracker) Sample() error {
+	s, err := t.gather()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples = append(t.samples, s)
+
+	// Keep the newest sample that is older than the longest window as
+	// the baseline for that window.
+	cutoff := s.at.Add(-t.maxWindow())
+	drop := 0
+	for drop+1 < len(t.samples) && !t.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	t.samples = append(t.samples[:0:0], t.samples[drop:]...)
+	return nil
+}
+
+// Report computes the indicators for each window from the current values of
+// the metrics and the collected samples.
+func (t *Tracker) Report() (*Report, error) {
+	now, err := t.gather()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rep := &Report{
+		GeneratedAt:        now.at.UTC(),
+		FetchSuccessTarget: t.cfg.FetchSuccessTarget,
+		Windows:            make([]WindowReport, 0, len(t.cfg.Windows)),
+	}
+	for _, w := range t.cfg.Windows {
+		rep.Windows = append(rep.Windows, t.windowReport(w, now))
+	}
+	return rep, nil
+}
+
+// windowReport computes the indicators for window w based on the difference
+// between now and the newest sample that is at least w old (or the oldest
+// sample if the tracker has not been running for long enough).
+func (t *Tracker) windowReport(w time.Duration, now *sample) WindowReport {
+	wr := WindowReport{WindowSeconds: w.Seconds()}
+	base := t.baseline(now.at.Add(-w))
+	if base == nil {
+		return wr
+	}
+
+	elapsed := now.at.Sub(base.at).Seconds()
+	if elapsed <= 0 {
+		return wr
+	}
+	wr.CoveredSeconds = elapsed
+	wr.PagesPerSecond = (now.pagesFetched - base.pagesFetched) / elapsed
+
+	if responses := now.fetchResponses - base.fetchResponses; responses > 0 {
+		wr.FetchErrorRatio = (now.fetchErrors - base.fetchErrors) / responses
+		wr.ErrorBudgetConsumed = wr.FetchErrorRatio / (1 - t.cfg.FetchSuccessTarget)
+	}
+
+	if lag := now.indexLag.sub(base.indexLag); lag.count > 0 {
+		mean := lag.sum / float64(lag.count)
+		wr.IndexLagSeconds = &mean
+	}
+	if age := now.frontierAge.sub(base.frontierAge); age.count > 0 {
+		p95 := age.quantile(frontierAgeQuantile)
+		wr.FrontierAgeP95Seconds = &p95
+	}
+	return wr
+}
+
+// baseline returns the newest sample taken at or before since or the oldest
+// sample if none qualifies.
+func (t *Tracker) baseline(since time.Time) *sample {
+	if len(t.samples) == 0 {
+		return nil
+	}
+	idx := sort.Search(len(t.samples), func(i int) bool { return t.samples[i].at.After(since) })
+	if idx == 0 {
+		return t.samples[0]
+	}
+	return t.samples[idx-1]
+}
+
+func (t *Tracker) maxWindow() time.Duration {
+	var max time.Duration
+	for _, w := range t.cfg.Windows {
+		if w > max {
+			max = w
+		}
+	}
+	return max
+}
+
+// gather returns a sample with the current values of the tracked metrics.
+// Metrics that have not been registered are treated as zero.
+func (t *Tracker) gather() (*sample, error) {
+	families, err := t.cfg.Gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("slo tracker: gathering metrics: %w", err)
+	}
+
+	s := &sample{at: t.cfg.Clock()}
+	for _, mf := range families {
+		switch mf.GetName() {
+		case pagesFetchedMetric:
+			for _, m := range mf.GetMetric() {
+				s.pagesFetched += m.GetCounter().GetValue()
+			}
+		case fetchResponsesMetric:
+			for _, m := range mf.GetMetric() {
+				v := m.GetCounter().GetValue()
+				s.fetchResponses += v
+				if isFetchError(m) {
+					s.fetchErrors += v
+				}
+			}
+		case indexLagMetric:
+			s.indexLag = makeHistogram(mf)
+		case frontierAgeMetric:
+			s.frontierAge = makeHistogram(mf)
+		}
+	}
+	return s, nil
+}
+
+// isFetchError returns true if m counts fetches that failed without a
+// response or with a server error.
+func isFetchError(m *dto.Metric) bool {
+	for _, lp := range m.GetLabel() {
+		if lp.GetName() != "code" {
+			continue
+		}
+		if lp.GetValue() == "error" {
+			return true
+		}
+		code, err := strconv.Atoi(lp.GetValue())
+		return err == nil && code >= 500
+	}
+	return false
+}
+
+// sample holds the values of the tracked metrics at a point in time.
+type sample struct {
+	at time.Time
+
+	pagesFetched   float64
+	fetchResponses float64
+	fetchErrors    float64
+
+	indexLag    histogram
+	frontierAge histogram
+}
+
+// histogram holds the cumulative bucket counts of a histogram metric.
+type histogram struct {
+	sum     float64
+	count   uint64
+	buckets []bucket
+}
+
+type bucket struct {
+	upperBound float64
+	count      uint64
+}
+
+func makeHistogram(mf *dto.MetricFamily) histogram {
+	var h histogram
+	for _, m := range mf.GetMetric() {
+		mh := m.GetHistogram()
+		h.sum += mh.GetSampleSum()
+		h.count += mh.GetSampleCount()
+		for i, b := range mh.GetBucket() {
+			if i == len(h.buckets) {
+				h.buckets = append(h.buckets, bucket{upperBound: b.GetUpperBound()})
+			}
+			h.buckets[i].count += b.GetCumulativeCount()
+		}
+	}
+	return h
+}
+
+// sub returns the observations that were recorded in h but not in other.
+func (h histogram) sub(other histogram) histogram {
+	res := histogram{sum: h.sum - other.sum, count: h.count - other.count}
+	for i, b := range h.buckets {
+		if i < len(other.buckets) {
+			b.count -= other.buckets[i].count
+		}
+		res.buckets = append(res.buckets, b)
+	}
+	return res
+}
+
+// quantile estimates the q-quantile of the observations by linear
+// interpolation within the bucket that contains it, like the PromQL
+// histogram_quantile function. Observations above the highest bucket bound
+// are reported as that bound.
+func (h histogram) quantile(q float64) float64 {
+	rank := q * float64(h.count)
+	var lowerBound float64
+	var lowerCount uint64
+	for _, b := range h.buckets {
+		if float64(b.count) >= rank {
+			if b.count == lowerCount {
+				return b.upperBound
+			}
+			return lowerBound + (b.upperBound-lowerBound)*(rank-float64(lowerCount))/float64(b.count-lowerCount)
+		}
+		lowerBound, lowerCount = b.upperBound, b.count
+	}
+	return lowerBound
+}
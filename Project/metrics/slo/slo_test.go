@@ -0,0 +1,171 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(TrackerTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type TrackerTestSuite struct {
+	now time.Time
+
+	pagesFetched   prometheus.Counter
+	fetchResponses *prometheus.CounterVec
+	indexLag       prometheus.Histogram
+	frontierAge    prometheus.Histogram
+
+	tracker *Tracker
+}
+
+func (s *TrackerTestSuite) SetUpTest(c *gc.C) {
+	s.now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.pagesFetched = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "webcrawler", Subsystem: "crawler", Name: "pages_fetched_total",
+	})
+	s.fetchResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webcrawler", Subsystem: "crawler", Name: "fetch_responses_total",
+	}, []string{"code"})
+	s.indexLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "webcrawler", Subsystem: "crawler", Name: "index_lag_seconds",
+		Buckets: []float64{1, 10, 100},
+	})
+	s.frontierAge = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "webcrawler", Subsystem: "crawler", Name: "frontier_age_seconds",
+		Buckets: []float64{100, 200, 400},
+	})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(s.pagesFetched, s.fetchResponses, s.indexLag, s.frontierAge)
+
+	var err error
+	s.tracker, err = NewTracker(Config{
+		Gatherer: reg,
+		Windows:  []time.Duration{time.Minute, time.Hour},
+		Clock:    func() time.Time { return s.now },
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *TrackerTestSuite) TestReportWithoutSamples(c *gc.C) {
+	rep, err := s.tracker.Report()
+	c.Assert(err, gc.IsNil)
+	c.Assert(rep.FetchSuccessTarget, gc.Equals, 0.99)
+	c.Assert(rep.Windows, gc.DeepEquals, []WindowReport{
+		{WindowSeconds: 60},
+		{WindowSeconds: 3600},
+	})
+}
+
+func (s *TrackerTestSuite) TestReport(c *gc.C) {
+	// Activity before the first window is only visible to the second
+	// one.
+	s.pagesFetched.Add(100)
+	c.Assert(s.tracker.Sample(), gc.IsNil)
+	s.advance(2 * time.Minute)
+	s.pagesFetched.Add(60)
+	c.Assert(s.tracker.Sample(), gc.IsNil)
+
+	s.advance(time.Minute)
+	s.pagesFetched.Add(120)
+	s.fetchResponses.WithLabelValues("200").Add(95)
+	s.fetchResponses.WithLabelValues("404").Add(2)
+	s.fetchResponses.WithLabelValues("503").Add(2)
+	s.fetchResponses.WithLabelValues("error").Add(1)
+	for _, lag := range []float64{0.5, 1.5, 4} {
+		s.indexLag.Observe(lag)
+	}
+	for i := 0; i < 20; i++ {
+		s.frontierAge.Observe(150)
+	}
+
+	rep, err := s.tracker.Report()
+	c.Assert(err, gc.IsNil)
+	c.Assert(rep.GeneratedAt, gc.Equals, s.now)
+	c.Assert(rep.Windows, gc.HasLen, 2)
+
+	w := rep.Windows[0]
+	c.Assert(w.CoveredSeconds, gc.Equals, 60.0)
+	c.Assert(w.PagesPerSecond, gc.Equals, 2.0)
+	c.Assert(w.FetchErrorRatio, gc.Equals, 0.03)
+	c.Assert(w.ErrorBudgetConsumed, floatEquals, 3.0)
+	c.Assert(*w.IndexLagSeconds, floatEquals, 2.0)
+	// The 19th of 20 observations in the (100, 200] bucket.
+	c.Assert(*w.FrontierAgeP95Seconds, floatEquals, 195.0)
+
+	// The tracker has only been running for 3 minutes.
+	w = rep.Windows[1]
+	c.Assert(w.CoveredSeconds, gc.Equals, 180.0)
+	c.Assert(w.PagesPerSecond, floatEquals, 1.0)
+}
+
+func (s *TrackerTestSuite) TestReportOmitsIndicatorsWithoutObservations(c *gc.C) {
+	s.indexLag.Observe(3)
+	c.Assert(s.tracker.Sample(), gc.IsNil)
+	s.advance(time.Minute)
+
+	rep, err := s.tracker.Report()
+	c.Assert(err, gc.IsNil)
+	c.Assert(rep.Windows[0].CoveredSeconds, gc.Equals, 60.0)
+	c.Assert(rep.Windows[0].IndexLagSeconds, gc.IsNil)
+	c.Assert(rep.Windows[0].FrontierAgeP95Seconds, gc.IsNil)
+	c.Assert(rep.Windows[0].ErrorBudgetConsumed, gc.Equals, 0.0)
+}
+
+func (s *TrackerTestSuite) TestSampleDiscardsExpiredSamples(c *gc.C) {
+	for i := 0; i < 10; i++ {
+		c.Assert(s.tracker.Sample(), gc.IsNil)
+		s.advance(20 * time.Minute)
+	}
+	c.Assert(s.tracker.Sample(), gc.IsNil)
+
+	// The newest sample that is at least one hour old is retained as the
+	// baseline for the longest window.
+	c.Assert(s.tracker.samples, gc.HasLen, 4)
+	c.Assert(s.now.Sub(s.tracker.samples[0].at), gc.Equals, time.Hour)
+}
+
+func (s *TrackerTestSuite) TestHistogramQuantile(c *gc.C) {
+	h := histogram{count: 10, buckets: []bucket{{1, 5}, {2, 5}, {4, 8}}}
+	c.Assert(h.quantile(0.25), floatEquals, 0.5)
+	c.Assert(h.quantile(0.5), floatEquals, 1.0)
+	c.Assert(h.quantile(0.65), floatEquals, 3.0)
+	// Observations above the highest bound.
+	c.Assert(h.quantile(0.95), floatEquals, 4.0)
+}
+
+func (s *TrackerTestSuite) TestInvalidConfig(c *gc.C) {
+	_, err := NewTracker(Config{
+		SampleInterval:     -time.Second,
+		Windows:            []time.Duration{0},
+		FetchSuccessTarget: 1,
+	})
+	c.Assert(err, gc.ErrorMatches, "(?s)slo tracker: config validation failed: .*sample interval.*window.*fetch success target.*")
+}
+
+func (s *TrackerTestSuite) advance(d time.Duration) {
+	s.now = s.now.Add(d)
+}
+
+// floatEquals compares two float64 values allowing for rounding errors.
+var floatEquals gc.Checker = &floatEqualsChecker{
+	CheckerInfo: &gc.CheckerInfo{Name: "floatEquals", Params: []string{"obtained", "expected"}},
+}
+
+type floatEqualsChecker struct {
+	*gc.CheckerInfo
+}
+
+func (*floatEqualsChecker) Check(params []interface{}, _ []string) (bool, string) {
+	obtained, ok1 := params[0].(float64)
+	expected, ok2 := params[1].(float64)
+	if !ok1 || !ok2 {
+		return false, "both values must be float64"
+	}
+	diff := obtained - expected
+	return diff < 1e-9 && diff > -1e-9, ""
+}
@@ -0,0 +1,55 @@
+// Package namespace defines the namespaces that isolate independent crawls
+// which share the same link graph and text indexer infrastructure.
+//
+// Each crawl (e.g. a crawl of a different seed set) is assigned its own
+// namespace. Links, edges, checkpoints and indexed documents are scoped to
+// the namespace of the store instance that created them, so crawls in
+// different namespaces never observe each other's data.
+package namespace
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Default is the namespace used by stores that are not configured with an
+// explicit namespace. Data created before namespaces were introduced belongs
+// to it.
+const Default = "default"
+
+// The maximum length of a namespace name.
+const maxLength = 64
+
+// ErrInvalid is returned by Validate for malformed namespace names.
+var ErrInvalid = errors.New("invalid namespace")
+
+// Validate returns an error if ns is not a valid namespace name. Valid names
+// consist of 1 to 64 lower-case letters, digits, '-' and '_' characters and
+// start with a letter or a digit. Namespace names are used verbatim as part
+// of elasticsearch index names which constrains the set of allowed
+// characters.
+func Validate(ns string) error {
+	if ns == "" {
+		return fmt.Errorf("%w: name must not be empty", ErrInvalid)
+	}
+	if len(ns) > maxLength {
+		return fmt.Errorf("%w: name %q exceeds %d characters", ErrInvalid, ns, maxLength)
+	}
+	for i, r := range ns {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case (r == '-' || r == '_') && i > 0:
+		default:
+			return fmt.Errorf("%w: name %q contains unsupported character %q", ErrInvalid, ns, r)
+		}
+	}
+	return nil
+}
+
+// OrDefault returns ns or Default if ns is empty.
+func OrDefault(ns string) string {
+	if ns == "" {
+		return Default
+	}
+	return ns
+}
@@ -0,0 +1,31 @@
+package namespace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(NamespaceTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type NamespaceTestSuite struct{}
+
+func (s *NamespaceTestSuite) TestValidate(c *gc.C) {
+	for _, ns := range []string{Default, "a", "crawl-2024_q1", strings.Repeat("x", maxLength)} {
+		c.Assert(Validate(ns), gc.IsNil, gc.Commentf("namespace %q", ns))
+	}
+
+	for _, ns := range []string{"", "Crawl", "-crawl", "_crawl", "crawl.1", "crawl 1", "crawl/1", strings.Repeat("x", maxLength+1)} {
+		err := Validate(ns)
+		c.Assert(errors.Is(err, ErrInvalid), gc.Equals, true, gc.Commentf("namespace %q", ns))
+	}
+}
+
+func (s *NamespaceTestSuite) TestOrDefault(c *gc.C) {
+	c.Assert(OrDefault(""), gc.Equals, Default)
+	c.Assert(OrDefault("crawl-1"), gc.Equals, "crawl-1")
+}
@@ -0,0 +1,217 @@
+// Package history keeps the PageRank scores computed by each PageRank pass so
+// that the trend of the score of a link or a domain can be inspected over
+// time.
+//
+// Each pass stores one sample per scored link and one aggregated sample per
+// domain (the sum of the scores of the domain's links). Old passes are
+// dropped via Prune to keep the history compact.
+package history
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LinkScore is the score computed for a link by a PageRank pass.
+type LinkScore struct {
+	LinkID uuid.UUID
+
+	// The host name of the link URL; see Host.
+	Host string
+
+	Score float64
+}
+
+// Point is a sample of a score trend.
+type Point struct {
+	// The pass that computed the score and the time when it completed.
+	PassID     uint64    `json:"passID"`
+	ComputedAt time.Time `json:"computedAt"`
+
+	Score float64 `json:"score"`
+
+	// The number of links that contributed to a domain score. Not set for
+	// link scores.
+	Links int `json:"links,omitempty"`
+}
+
+// Store is implemented by objects that can persist the PageRank score
+// history.
+type Store interface {
+	// RecordPass stores the scores computed by a PageRank pass that
+	// completed at computedAt and returns the ID assigned to the pass.
+	// Pass IDs increase monotonically.
+	RecordPass(computedAt time.Time, scores []LinkScore) (uint64, error)
+
+	// LinkTrend returns the scores of a link for the passes that
+	// completed at or after since, oldest first.
+	LinkTrend(linkID uuid.UUID, since time.Time) ([]Point, error)
+
+	// DomainTrend returns the aggregated scores of the links of host for
+	// the passes that completed at or after since, oldest first.
+	DomainTrend(host string, since time.Time) ([]Point, error)
+
+	// Prune deletes all but the keepPasses most recent passes.
+	Prune(keepPasses int) error
+}
+
+// Host returns the normalized host name that the scores of a link URL are
+// aggregated by. It returns an empty string if linkURL cannot be parsed.
+func Host(linkURL string) string {
+	u, err := url.Parse(linkURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// domainScores aggregates scores by host.
+func domainScores(scores []LinkScore) map[string]*Point {
+	domains := make(map[string]*Point)
+	for _, s := range scores {
+		if s.Host == "" {
+			continue
+		}
+		p := domains[s.Host]
+		if p == nil {
+			p = new(Point)
+			domains[s.Host] = p
+		}
+		p.Score += s.Score
+		p.Links++
+	}
+	return domains
+}
+
+// Compile-time check for ensuring MemoryStore implements Store.
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is a Store that keeps the score history in memory.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	passes  []passInfo
+	links   map[uuid.UUID][]sample
+	domains map[string][]sample
+}
+
+type passInfo struct {
+	id         uint64
+	computedAt time.Time
+}
+
+// sample is a score recorded by a pass. Scores are stored as 32-bit floats
+// which are precise enough for tracking trends.
+type sample struct {
+	passID uint64
+	score  float32
+	links  int32
+}
+
+// NewMemoryStore returns a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		links:   make(map[uuid.UUID][]sample),
+		domains: make(map[string][]sample),
+	}
+}
+
+// RecordPass implements Store.
+func (s *MemoryStore) RecordPass(computedAt time.Time, scores []LinkScore) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passID := uint64(1)
+	if n := len(s.passes); n != 0 {
+		passID = s.passes[n-1].id + 1
+	}
+	s.passes = append(s.passes, passInfo{id: passID, computedAt: computedAt.UTC()})
+	for _, ls := range scores {
+		s.links[ls.LinkID] = append(s.links[ls.LinkID], sample{passID: passID, score: float32(ls.Score)})
+	}
+	for host, p := range domainScores(scores) {
+		s.domains[host] = append(s.domains[host], sample{passID: passID, score: float32(p.Score), links: int32(p.Links)})
+	}
+	return passID, nil
+}
+
+// LinkTrend implements Store.
+func (s *MemoryStore) LinkTrend(linkID uuid.UUID, since time.Time) ([]Point, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.trend(s.links[linkID], since), nil
+}
+
+// DomainTrend implements Store.
+func (s *MemoryStore) DomainTrend(host string, since time.Time) ([]Point, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.trend(s.domains[strings.ToLower(host)], since), nil
+}
+
+func (s *MemoryStore) trend(samples []sample, since time.Time) []Point {
+	var points []Point
+	for _, smp := range samples {
+		computedAt := s.passTime(smp.passID)
+		if computedAt.Before(since) {
+			continue
+		}
+		points = append(points, Point{
+			PassID:     smp.passID,
+			ComputedAt: computedAt,
+			Score:      float64(smp.score),
+			Links:      int(smp.links),
+		})
+	}
+	return points
+}
+
+func (s *MemoryStore) passTime(passID uint64) time.Time {
+	i := sort.Search(len(s.passes), func(i int) bool { return s.passes[i].id >= passID })
+	return s.passes[i].computedAt
+}
+
+// Prune implements Store.
+func (s *MemoryStore) Prune(keepPasses int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.passes) <= keepPasses {
+		return nil
+	}
+	s.passes = append([]passInfo(nil), s.passes[len(s.passes)-keepPasses:]...)
+	var minPassID uint64
+	if len(s.passes) != 0 {
+		minPassID = s.passes[0].id
+	}
+
+	for linkID, samples := range s.links {
+		if samples = pruneSamples(samples, minPassID); len(samples) == 0 {
+			delete(s.links, linkID)
+		} else {
+			s.links[linkID] = samples
+		}
+	}
+	for host, samples := range s.domains {
+		if samples = pruneSamples(samples, minPassID); len(samples) == 0 {
+			delete(s.domains, host)
+		} else {
+			s.domains[host] = samples
+		}
+	}
+	return nil
+}
+
+// pruneSamples removes the samples of passes before minPassID. A zero
+// minPassID removes all samples.
+func pruneSamples(samples []sample, minPassID uint64) []sample {
+	if minPassID == 0 {
+		return nil
+	}
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].passID >= minPassID })
+	return samples[i:]
+}
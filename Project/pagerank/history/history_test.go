@@ -0,0 +1,118 @@
+package history
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var (
+	_ = gc.Suite(new(MemoryStoreTestSuite))
+	_ = gc.Suite(new(PostgresStoreTestSuite))
+)
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+// storeTests holds the tests that are shared by all store implementations.
+type storeTests struct {
+	store Store
+}
+
+func (s *storeTests) TestTrends(c *gc.C) {
+	linkA, linkB := uuid.New(), uuid.New()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, scores := range [][2]float64{{0.5, 0.25}, {0.25, 0.5}, {0.125, 0.75}} {
+		passID, err := s.store.RecordPass(start.Add(time.Duration(i)*time.Hour), []LinkScore{
+			{LinkID: linkA, Host: "example.com", Score: scores[0]},
+			{LinkID: linkB, Host: "example.com", Score: scores[1]},
+			{LinkID: uuid.New(), Host: "other.com", Score: 0.125},
+		})
+		c.Assert(err, gc.IsNil)
+		c.Assert(passID, gc.Equals, uint64(i+1))
+	}
+
+	points, err := s.store.LinkTrend(linkB, start.Add(time.Hour))
+	c.Assert(err, gc.IsNil)
+	c.Assert(points, gc.DeepEquals, []Point{
+		{PassID: 2, ComputedAt: start.Add(time.Hour), Score: 0.5},
+		{PassID: 3, ComputedAt: start.Add(2 * time.Hour), Score: 0.75},
+	})
+
+	points, err = s.store.DomainTrend("Example.COM", time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(points, gc.HasLen, 3)
+	c.Assert(points[2], gc.DeepEquals, Point{PassID: 3, ComputedAt: start.Add(2 * time.Hour), Score: 0.875, Links: 2})
+
+	points, err = s.store.LinkTrend(uuid.New(), time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(points, gc.HasLen, 0)
+}
+
+func (s *storeTests) TestPrune(c *gc.C) {
+	linkID := uuid.New()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		_, err := s.store.RecordPass(start.Add(time.Duration(i)*time.Hour), []LinkScore{{LinkID: linkID, Host: "example.com", Score: 0.5}})
+		c.Assert(err, gc.IsNil)
+	}
+
+	c.Assert(s.store.Prune(2), gc.IsNil)
+	points, err := s.store.LinkTrend(linkID, time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(points, gc.HasLen, 2)
+	c.Assert(points[0].PassID, gc.Equals, uint64(4))
+	points, err = s.store.DomainTrend("example.com", time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(points, gc.HasLen, 2)
+
+	// Pass IDs keep increasing after pruning.
+	passID, err := s.store.RecordPass(start.Add(5*time.Hour), nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(passID, gc.Equals, uint64(6))
+}
+
+type MemoryStoreTestSuite struct {
+	storeTests
+}
+
+func (s *MemoryStoreTestSuite) SetUpTest(c *gc.C) {
+	s.store = NewMemoryStore()
+}
+
+func (s *MemoryStoreTestSuite) TestHost(c *gc.C) {
+	c.Assert(Host("https://WWW.Example.com:8080/foo"), gc.Equals, "www.example.com")
+	c.Assert(Host("://bogus"), gc.Equals, "")
+}
+
+type PostgresStoreTestSuite struct {
+	storeTests
+	db *PostgresStore
+}
+
+func (s *PostgresStoreTestSuite) SetUpSuite(c *gc.C) {
+	dsn := os.Getenv("CDB_DSN")
+	if dsn == "" {
+		c.Skip("Missing CDB_DSN envvar; skipping postgres score history test suite")
+	}
+
+	var err error
+	s.db, err = NewPostgresStore(dsn)
+	c.Assert(err, gc.IsNil)
+	s.store = s.db
+}
+
+func (s *PostgresStoreTestSuite) SetUpTest(c *gc.C) {
+	for _, table := range []string{"pagerank_history", "pagerank_domain_history", "pagerank_passes"} {
+		_, err := s.db.db.Exec("TRUNCATE " + table)
+		c.Assert(err, gc.IsNil)
+	}
+}
+
+func (s *PostgresStoreTestSuite) TearDownSuite(c *gc.C) {
+	if s.db != nil {
+		c.Assert(s.db.Close(), gc.IsNil)
+	}
+}
@@ -0,0 +1,202 @@
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	// Register the postgres driver.
+	_ "github.com/lib/pq"
+)
+
+// The maximum number of rows inserted by a single statement.
+const insertBatchSize = 500
+
+var (
+	insertPassQuery = `
+INSERT INTO pagerank_passes (pass_id, computed_at)
+SELECT COALESCE(MAX(pass_id), 0) + 1, $1 FROM pagerank_passes
+RETURNING pass_id
+`
+	linkTrendQuery = `
+SELECT p.pass_id, p.computed_at, h.score FROM pagerank_history h
+JOIN pagerank_passes p ON p.pass_id = h.pass_id
+WHERE h.link_id = $1 AND p.computed_at >= $2
+ORDER BY p.pass_id
+`
+	domainTrendQuery = `
+SELECT p.pass_id, p.computed_at, d.score, d.links FROM pagerank_domain_history d
+JOIN pagerank_passes p ON p.pass_id = d.pass_id
+WHERE d.host = $1 AND p.computed_at >= $2
+ORDER BY p.pass_id
+`
+	latestPassQuery = "SELECT COALESCE(MAX(pass_id), 0) FROM pagerank_passes"
+	pruneQueries    = []string{
+		"DELETE FROM pagerank_history WHERE pass_id <= $1",
+		"DELETE FROM pagerank_domain_history WHERE pass_id <= $1",
+		"DELETE FROM pagerank_passes WHERE pass_id <= $1",
+	}
+)
+
+// Compile-time check for ensuring PostgresStore implements Store.
+var _ Store = (*PostgresStore)(nil)
+
+// PostgresStore is a Store that persists the score history to the
+// pagerank_passes, pagerank_history and pagerank_domain_history tables of a
+// postgres database.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore returns a PostgresStore that connects to the database
+// specified by dsn.
+func NewPostgresStore(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Close terminates the connection to the database.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// RecordPass implements Store.
+func (s *PostgresStore) RecordPass(computedAt time.Time, scores []LinkScore) (uint64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("record pass: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var passID uint64
+	if err = tx.QueryRow(insertPassQuery, computedAt.UTC()).Scan(&passID); err != nil {
+		return 0, fmt.Errorf("record pass: %w", err)
+	}
+
+	linkRows := make([][]interface{}, 0, len(scores))
+	for _, ls := range scores {
+		linkRows = append(linkRows, []interface{}{ls.LinkID, passID, float32(ls.Score)})
+	}
+	if err = insertRows(tx, "pagerank_history (link_id, pass_id, score)", linkRows); err != nil {
+		return 0, fmt.Errorf("record pass: %w", err)
+	}
+
+	domains := domainScores(scores)
+	domainRows := make([][]interface{}, 0, len(domains))
+	for host, p := range domains {
+		domainRows = append(domainRows, []interface{}{host, passID, float32(p.Score), p.Links})
+	}
+	if err = insertRows(tx, "pagerank_domain_history (host, pass_id, score, links)", domainRows); err != nil {
+		return 0, fmt.Errorf("record pass: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("record pass: %w", err)
+	}
+	return passID, nil
+}
+
+// insertRows inserts rows into table using multi-row INSERT statements.
+func insertRows(tx *sql.Tx, table string, rows [][]interface{}) error {
+	for len(rows) != 0 {
+		batch := rows
+		if len(batch) > insertBatchSize {
+			batch = batch[:insertBatchSize]
+		}
+		rows = rows[len(batch):]
+
+		var (
+			query strings.Builder
+			args  []interface{}
+		)
+		query.WriteString("INSERT INTO " + table + " VALUES ")
+		for i, row := range batch {
+			if i != 0 {
+				query.WriteByte(',')
+			}
+			query.WriteByte('(')
+			for j, v := range row {
+				if j != 0 {
+					query.WriteByte(',')
+				}
+				args = append(args, v)
+				fmt.Fprintf(&query, "$%d", len(args))
+			}
+			query.WriteByte(')')
+		}
+		if _, err := tx.Exec(query.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LinkTrend implements Store.
+func (s *PostgresStore) LinkTrend(linkID uuid.UUID, since time.Time) ([]Point, error) {
+	rows, err := s.db.Query(linkTrendQuery, linkID, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("link trend: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var points []Point
+	for rows.Next() {
+		var p Point
+		if err = rows.Scan(&p.PassID, &p.ComputedAt, &p.Score); err != nil {
+			return nil, fmt.Errorf("link trend: %w", err)
+		}
+		p.ComputedAt = p.ComputedAt.UTC()
+		points = append(points, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("link trend: %w", err)
+	}
+	return points, nil
+}
+
+// DomainTrend implements Store.
+func (s *PostgresStore) DomainTrend(host string, since time.Time) ([]Point, error) {
+	rows, err := s.db.Query(domainTrendQuery, strings.ToLower(host), since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("domain trend: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var points []Point
+	for rows.Next() {
+		var p Point
+		if err = rows.Scan(&p.PassID, &p.ComputedAt, &p.Score, &p.Links); err != nil {
+			return nil, fmt.Errorf("domain trend: %w", err)
+		}
+		p.ComputedAt = p.ComputedAt.UTC()
+		points = append(points, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("domain trend: %w", err)
+	}
+	return points, nil
+}
+
+// Prune implements Store.
+func (s *PostgresStore) Prune(keepPasses int) error {
+	var latest int64
+	if err := s.db.QueryRow(latestPassQuery).Scan(&latest); err != nil {
+		return fmt.Errorf("prune history: %w", err)
+	}
+	cutoff := latest - int64(keepPasses)
+	if cutoff <= 0 {
+		return nil
+	}
+	for _, query := range pruneQueries {
+		if _, err := s.db.Exec(query, cutoff); err != nil {
+			return fmt.Errorf("prune history: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Package pagerank implements the iterative version of the PageRank algorithm
+// on top of the bspgraph processing engine.
+package pagerank
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"webcrawler/bspgraph"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Config encapsulates the settings for a Calculator.
+type Config struct {
+	// The damping factor, i.e. the probability that a random surfer
+	// follows one of the outgoing links of a page instead of jumping to a
+	// random page. Defaults to 0.85.
+	DampingFactor float64
+
+	// The calculator stops iterating once the sum of absolute differences
+	// (SAD) between the scores of two consecutive supersteps drops below
+	// this value. Defaults to 0.001.
+	MinSADForConvergence float64
+
+	// The number of workers for executing each superstep. Defaults to 1.
+	ComputeWorkers int
+
+	// An optional logger for reporting the progress of calculations. If
+	// not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if cfg.DampingFactor < 0 || cfg.DampingFactor > 1 {
+		err = multierror.Append(err, fmt.Errorf("damping factor must be in the [0, 1] range"))
+	} else if cfg.DampingFactor == 0 {
+		cfg.DampingFactor = 0.85
+	}
+	if cfg.MinSADForConvergence < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid min SAD for convergence"))
+	} else if cfg.MinSADForConvergence == 0 {
+		cfg.MinSADForConvergence = 0.001
+	}
+	if cfg.ComputeWorkers < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid number of compute workers"))
+	} else if cfg.ComputeWorkers == 0 {
+		cfg.ComputeWorkers = 1
+	}
+	return err
+}
+
+// IncomingScoreMessage is exchanged between vertices to distribute the score
+// of a page to the pages that it links to.
+type IncomingScoreMessage struct {
+	Score float64
+}
+
+// Type implements bspgraph.Message.
+func (IncomingScoreMessage) Type() string { return "score" }
+
+// Calculator computes the PageRank score of each vertex in a graph. Vertices
+// are populated via Graph (e.g. using bspgraph.LoadLinkGraph) before invoking
+// Run; their initial values are ignored.
+type Calculator struct {
+	g   *bspgraph.Graph
+	cfg Config
+}
+
+// NewCalculator returns a new Calculator instance using the provided config.
+// It is important for callers to invoke Close on the returned calculator
+// when they are done using it.
+func NewCalculator(cfg Config) (*Calculator, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("pagerank calculator: config validation failed: %w", err)
+	}
+
+	c := &Calculator{cfg: cfg}
+	g, err := bspgraph.NewGraph(bspgraph.GraphConfig{
+		ComputeFn:      c.computeScore,
+		ComputeWorkers: cfg.ComputeWorkers,
+		Logger:         cfg.Logger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pagerank calculator: %w", err)
+	}
+	c.g = g
+	return c, nil
+}
+
+// Close releases any resources associated with the calculator.
+func (c *Calculator) Close() error {
+	return c.g.Close()
+}
+
+// Graph returns the graph that the calculator operates on.
+func (c *Calculator) Graph() *bspgraph.Graph {
+	return c.g
+}
+
+// Run executes supersteps until the scores converge or ctx expires.
+func (c *Calculator) Run(ctx context.Context) error {
+	c.g.RegisterAggregator("page_count", new(bspgraph.IntAccumulator))
+	c.g.RegisterAggregator("sad", new(bspgraph.Float64Accumulator))
+	c.g.RegisterAggregator(residualAggregator(0), new(bspgraph.Float64Accumulator))
+	c.g.RegisterAggregator(residualAggregator(1), new(bspgraph.Float64Accumulator))
+
+	ex := bspgraph.NewExecutor(c.g, bspgraph.ExecutorCallbacks{
+		PreStep: func(_ context.Context, g *bspgraph.Graph) error {
+			// Reset the SAD and the residual that is populated by
+			// the upcoming superstep.
+			g.Aggregator("sad").Set(0.0)
+			g.Aggregator(residualAggregator(g.Superstep())).Set(0.0)
+			return nil
+		},
+		PostStepKeepRunning: func(_ context.Context, g *bspgraph.Graph, _ int) (bool, error) {
+			// The first superstep only counts the vertices and the
+			// second one assigns the initial scores.
+			if g.Superstep() < 2 {
+				return true, nil
+			}
+			return g.Aggregator("sad").Get().(float64) >= c.cfg.MinSADForConvergence, nil
+		},
+	})
+	if err := ex.RunToCompletion(ctx); err != nil {
+		return fmt.Errorf("pagerank: %w", err)
+	}
+	return nil
+}
+
+// Scores invokes visitFn with the score of each vertex in the graph.
+func (c *Calculator) Scores(visitFn func(id string, score float64) error) error {
+	for id, v := range c.g.Vertices() {
+		score, _ := v.Value().(float64)
+		if err := visitFn(id, score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// computeScore implements bspgraph.ComputeFunc. The score of a page is
+// distributed evenly between the pages it links to. Pages without outgoing
+// links distribute their score evenly between all pages via a residual
+// aggregator that is read in the following superstep.
+func (c *Calculator) computeScore(g *bspgraph.Graph, v *bspgraph.Vertex, msgIt bspgraph.MessageIterator) error {
+	superstep := g.Superstep()
+	pageCountAgg := g.Aggregator("page_count")
+	if superstep == 0 {
+		pageCountAgg.Aggregate(1)
+		return nil
+	}
+
+	var (
+		pageCount = float64(pageCountAgg.Get().(int))
+		newScore  float64
+	)
+	if superstep == 1 {
+		newScore = 1.0 / pageCount
+	} else {
+		newScore = (1.0 - c.cfg.DampingFactor) / pageCount
+		for msgIt.Next() {
+			newScore += c.cfg.DampingFactor * msgIt.Message().(IncomingScoreMessage).Score
+		}
+		residual := g.Aggregator(residualAggregator(superstep + 1)).Get().(float64)
+		newScore += c.cfg.DampingFactor * residual / pageCount
+	}
+
+	oldScore, _ := v.Value().(float64)
+	g.Aggregator("sad").Aggregate(math.Abs(newScore - oldScore))
+	v.SetValue(newScore)
+
+	// Edges that point outside the graph (e.g. to links that were added
+	// after the loaded snapshot) are ignored.
+	var dstIDs []string
+	for _, e := range v.Edges() {
+		if _, known := g.Vertices()[e.DstID()]; known {
+			dstIDs = append(dstIDs, e.DstID())
+		}
+	}
+	if len(dstIDs) == 0 {
+		g.Aggregator(residualAggregator(superstep)).Aggregate(newScore)
+		return nil
+	}
+
+	msg := IncomingScoreMessage{Score: newScore / float64(len(dstIDs))}
+	for _, dstID := range dstIDs {
+		if err := g.SendMessage(dstID, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// residualAggregator returns the name of the aggregator that collects the
+// scores of pages without outgoing links in the specified superstep. Two
+// aggregators are used in alternating supersteps so that the residual of the
+// previous superstep can be read while the residual of the current one is
+// being populated.
+func residualAggregator(superstep int) string {
+	return fmt.Sprintf("residual_%d", superstep%2)
+}
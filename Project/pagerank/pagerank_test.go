@@ -0,0 +1,79 @@
+package pagerank
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(CalculatorTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type CalculatorTestSuite struct{}
+
+func (s *CalculatorTestSuite) TestScores(c *gc.C) {
+	calc := s.calculator(c, Config{MinSADForConvergence: 1e-9, ComputeWorkers: 2})
+	defer func() { c.Assert(calc.Close(), gc.IsNil) }()
+	g := calc.Graph()
+	for _, id := range []string{"a", "b", "c", "d"} {
+		g.AddVertex(id, nil)
+	}
+	for _, e := range [][2]string{{"a", "b"}, {"a", "c"}, {"b", "c"}, {"c", "a"}, {"d", "c"}} {
+		c.Assert(g.AddEdge(e[0], e[1], nil), gc.IsNil)
+	}
+
+	c.Assert(calc.Run(context.Background()), gc.IsNil)
+
+	// The expected scores for the above graph with a damping factor of
+	// 0.85.
+	exp := map[string]float64{
+		"a": 0.372526,
+		"b": 0.195824,
+		"c": 0.394149,
+		"d": 0.037500,
+	}
+	var sum float64
+	err := calc.Scores(func(id string, score float64) error {
+		c.Assert(math.Abs(score-exp[id]) < 1e-5, gc.Equals, true, gc.Commentf("vertex %q: expected score %f; got %f", id, exp[id], score))
+		sum += score
+		return nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(math.Abs(sum-1.0) < 1e-6, gc.Equals, true, gc.Commentf("expected scores to sum to 1; got %f", sum))
+}
+
+func (s *CalculatorTestSuite) TestDanglingAndExternalLinks(c *gc.C) {
+	calc := s.calculator(c, Config{MinSADForConvergence: 1e-9})
+	defer func() { c.Assert(calc.Close(), gc.IsNil) }()
+	g := calc.Graph()
+	g.AddVertex("a", nil)
+	g.AddVertex("b", nil)
+	c.Assert(g.AddEdge("a", "b", nil), gc.IsNil)
+	// Links to vertices outside the graph are ignored so "b" is treated
+	// as a page without outgoing links.
+	c.Assert(g.AddEdge("b", "external", nil), gc.IsNil)
+
+	c.Assert(calc.Run(context.Background()), gc.IsNil)
+
+	scores := make(map[string]float64)
+	c.Assert(calc.Scores(func(id string, score float64) error {
+		scores[id] = score
+		return nil
+	}), gc.IsNil)
+	c.Assert(math.Abs(scores["a"]+scores["b"]-1.0) < 1e-6, gc.Equals, true)
+	c.Assert(scores["b"] > scores["a"], gc.Equals, true)
+}
+
+func (s *CalculatorTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewCalculator(Config{DampingFactor: 1.5, MinSADForConvergence: -1, ComputeWorkers: -1})
+	c.Assert(err, gc.ErrorMatches, "(?s).*damping factor.*min SAD.*compute workers.*")
+}
+
+func (s *CalculatorTestSuite) calculator(c *gc.C, cfg Config) *Calculator {
+	calc, err := NewCalculator(cfg)
+	c.Assert(err, gc.IsNil)
+	return calc
+}
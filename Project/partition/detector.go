@@ -0,0 +1,136 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoPartitionDataAvailableYet is returned by detectors when the partition
+// assignment for the current instance cannot be determined yet (e.g. because
+// its DNS records have not been published). Callers should retry later.
+var ErrNoPartitionDataAvailableYet = errors.New("no partition data available yet")
+
+// Detector is implemented by types that can assign a partition to the
+// current instance.
+type Detector interface {
+	// PartitionInfo returns the partition assigned to the current
+	// instance and the total number of partitions.
+	PartitionInfo(ctx context.Context) (int, int, error)
+}
+
+// AssignedRange uses det to look up the partition assigned to the current
+// instance and returns its [fromID, toID) extents within the full UUID
+// keyspace.
+func AssignedRange(ctx context.Context, det Detector) (uuid.UUID, uuid.UUID, error) {
+	curPartition, numPartitions, err := det.PartitionInfo(ctx)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	r, err := NewFullRange(numPartitions)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return r.PartitionExtents(curPartition)
+}
+
+// Compile-time checks for ensuring the detectors implement Detector.
+var (
+	_ Detector = (*StaticDetector)(nil)
+	_ Detector = (*DNSDetector)(nil)
+)
+
+// StaticDetector assigns partitions based on a fixed list of instance names
+// (e.g. from a configuration file). The current instance is assigned the
+// partition that corresponds to the position of its name in the list.
+type StaticDetector struct {
+	self    string
+	members []string
+}
+
+// NewStaticDetector returns a detector that assigns self the partition that
+// corresponds to its position in members.
+func NewStaticDetector(self string, members []string) *StaticDetector {
+	return &StaticDetector{self: self, members: append([]string(nil), members...)}
+}
+
+// PartitionInfo implements Detector. An empty member list denotes a single
+// instance deployment that owns the entire keyspace.
+func (d *StaticDetector) PartitionInfo(_ context.Context) (int, int, error) {
+	if len(d.members) == 0 {
+		return 0, 1, nil
+	}
+	for i, member := range d.members {
+		if member == d.self {
+			return i, len(d.members), nil
+		}
+	}
+	return -1, -1, fmt.Errorf("static partition detector: %q is not in the member list", d.self)
+}
+
+// SRVLookupFunc resolves the SRV records for a service. It has the same
+// signature as net.Resolver.LookupSRV.
+type SRVLookupFunc func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+// DNSDetector assigns partitions using the SRV records published for a
+// service (e.g. a Kubernetes headless service). The SRV targets are sorted
+// and the current instance is assigned the partition that corresponds to the
+// position of the target whose first label matches its host name.
+type DNSDetector struct {
+	srvName  string
+	hostname string
+	lookup   SRVLookupFunc
+}
+
+// NewDNSDetector returns a detector that looks up the SRV records for srvName
+// and locates hostname among their targets.
+func NewDNSDetector(srvName, hostname string) *DNSDetector {
+	return &DNSDetector{
+		srvName:  srvName,
+		hostname: hostname,
+		lookup:   net.DefaultResolver.LookupSRV,
+	}
+}
+
+// WithLookup overrides the function used for resolving SRV records.
+func (d *DNSDetector) WithLookup(lookup SRVLookupFunc) *DNSDetector {
+	d.lookup = lookup
+	return d
+}
+
+// PartitionInfo implements Detector.
+func (d *DNSDetector) PartitionInfo(ctx context.Context) (int, int, error) {
+	_, addrs, err := d.lookup(ctx, "", "", d.srvName)
+	if err != nil {
+		return -1, -1, fmt.Errorf("dns partition detector: %w", err)
+	}
+
+	targets := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		targets = append(targets, strings.ToLower(strings.TrimSuffix(addr.Target, ".")))
+	}
+	sort.Strings(targets)
+
+	self := firstLabel(strings.ToLower(d.hostname))
+	for i, target := range targets {
+		if firstLabel(target) == self {
+			return i, len(targets), nil
+		}
+	}
+
+	// Our own record may not have been published yet.
+	return -1, -1, ErrNoPartitionDataAvailableYet
+}
+
+func firstLabel(host string) string {
+	if idx := strings.IndexByte(host, '.'); idx != -1 {
+		return host[:idx]
+	}
+	return host
+}
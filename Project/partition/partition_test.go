@@ -0,0 +1,113 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(PartitionTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type PartitionTestSuite struct{}
+
+func (s *PartitionTestSuite) TestFullRangeSplits(c *gc.C) {
+	r, err := NewFullRange(4)
+	c.Assert(err, gc.IsNil)
+	c.Assert(r.NumPartitions(), gc.Equals, 4)
+
+	expSplits := []string{
+		"00000000-0000-0000-0000-000000000000",
+		"3fffffff-ffff-ffff-ffff-ffffffffffff",
+		"7fffffff-ffff-ffff-ffff-fffffffffffe",
+		"bfffffff-ffff-ffff-ffff-fffffffffffd",
+		"ffffffff-ffff-ffff-ffff-ffffffffffff",
+	}
+	for p := 0; p < 4; p++ {
+		from, to, err := r.PartitionExtents(p)
+		c.Assert(err, gc.IsNil)
+		c.Assert(from.String(), gc.Equals, expSplits[p], gc.Commentf("partition %d", p))
+		c.Assert(to.String(), gc.Equals, expSplits[p+1], gc.Commentf("partition %d", p))
+	}
+
+	_, _, err = r.PartitionExtents(4)
+	c.Assert(errors.Is(err, ErrInvalidPartition), gc.Equals, true)
+}
+
+func (s *PartitionTestSuite) TestPartitionForID(c *gc.C) {
+	r, err := NewFullRange(10)
+	c.Assert(err, gc.IsNil)
+
+	for i := 0; i < 1000; i++ {
+		id := uuid.New()
+		p, err := r.PartitionForID(id)
+		c.Assert(err, gc.IsNil)
+
+		from, to, err := r.PartitionExtents(p)
+		c.Assert(err, gc.IsNil)
+		c.Assert(between(id, from, to), gc.Equals, true, gc.Commentf("%s not in partition %d", id, p))
+	}
+
+	_, err = r.PartitionForID(MaxUUID)
+	c.Assert(errors.Is(err, ErrOutOfRange), gc.Equals, true)
+}
+
+func (s *PartitionTestSuite) TestInvalidRanges(c *gc.C) {
+	_, err := NewFullRange(0)
+	c.Assert(err, gc.Equals, ErrInvalidPartitionCount)
+
+	_, err = NewRange(MaxUUID, uuid.Nil, 1)
+	c.Assert(err, gc.Equals, ErrInvalidRangeBounds)
+}
+
+func (s *PartitionTestSuite) TestStaticDetector(c *gc.C) {
+	det := NewStaticDetector("crawler-b", []string{"crawler-a", "crawler-b", "crawler-c"})
+	cur, num, err := det.PartitionInfo(context.TODO())
+	c.Assert(err, gc.IsNil)
+	c.Assert(cur, gc.Equals, 1)
+	c.Assert(num, gc.Equals, 3)
+
+	_, _, err = NewStaticDetector("crawler-z", []string{"crawler-a"}).PartitionInfo(context.TODO())
+	c.Assert(err, gc.ErrorMatches, `.*"crawler-z" is not in the member list`)
+}
+
+func (s *PartitionTestSuite) TestDNSDetector(c *gc.C) {
+	records := []*net.SRV{
+		{Target: "crawler-2.crawler.default.svc.cluster.local."},
+		{Target: "crawler-0.crawler.default.svc.cluster.local."},
+		{Target: "crawler-1.crawler.default.svc.cluster.local."},
+	}
+	lookup := func(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+		c.Assert(name, gc.Equals, "crawler.default.svc.cluster.local")
+		return "", records, nil
+	}
+
+	det := NewDNSDetector("crawler.default.svc.cluster.local", "crawler-2").WithLookup(lookup)
+	cur, num, err := det.PartitionInfo(context.TODO())
+	c.Assert(err, gc.IsNil)
+	c.Assert(cur, gc.Equals, 2)
+	c.Assert(num, gc.Equals, 3)
+
+	from, to, err := AssignedRange(context.TODO(), det)
+	c.Assert(err, gc.IsNil)
+	r, _ := NewFullRange(3)
+	expFrom, expTo, _ := r.PartitionExtents(2)
+	c.Assert(from, gc.Equals, expFrom)
+	c.Assert(to, gc.Equals, expTo)
+
+	// An instance whose record has not been published yet.
+	det = NewDNSDetector("crawler.default.svc.cluster.local", "crawler-3").WithLookup(lookup)
+	_, _, err = det.PartitionInfo(context.TODO())
+	c.Assert(err, gc.Equals, ErrNoPartitionDataAvailableYet)
+}
+
+func between(id, from, to uuid.UUID) bool {
+	idNum := new(big.Int).SetBytes(id[:])
+	return idNum.Cmp(new(big.Int).SetBytes(from[:])) >= 0 && idNum.Cmp(new(big.Int).SetBytes(to[:])) < 0
+}
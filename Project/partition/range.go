@@ -0,0 +1,128 @@
+// Package partition splits the UUID keyspace used by the link graph into
+// contiguous ranges so that multiple crawler instances can process the same
+// graph without duplicating work. Each instance determines its partition
+// number and the total number of partitions via a Detector and then only
+// iterates the links and edges whose IDs fall within its assigned
+// [fromID, toID) range.
+package partition
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidPartitionCount is returned when attempting to split a range
+	// into a non-positive number of partitions.
+	ErrInvalidPartitionCount = errors.New("number of partitions must be at least 1")
+
+	// ErrInvalidRangeBounds is returned when the end of a range is not
+	// greater than its start.
+	ErrInvalidRangeBounds = errors.New("range end must be greater than range start")
+
+	// ErrInvalidPartition is returned when requesting the extents of a
+	// partition that does not exist.
+	ErrInvalidPartition = errors.New("invalid partition index")
+
+	// ErrOutOfRange is returned when looking up the partition for an ID
+	// that does not belong to the range.
+	ErrOutOfRange = errors.New("ID is not part of the range")
+)
+
+// MaxUUID is the largest possible UUID value.
+var MaxUUID = uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+
+// Range represents a contiguous [start, end) UUID region that is split into
+// a number of partitions.
+type Range struct {
+	start       uuid.UUID
+	rangeSplits []uuid.UUID
+}
+
+// NewFullRange creates a new range that spans the full UUID keyspace and
+// splits it into the provided number of partitions.
+func NewFullRange(numPartitions int) (Range, error) {
+	return NewRange(uuid.Nil, MaxUUID, numPartitions)
+}
+
+// NewRange creates a new range [start, end) and splits it into the provided
+// number of partitions of (roughly) equal size.
+func NewRange(start, end uuid.UUID, numPartitions int) (Range, error) {
+	if numPartitions <= 0 {
+		return Range{}, ErrInvalidPartitionCount
+	}
+	if bytes.Compare(start[:], end[:]) >= 0 {
+		return Range{}, ErrInvalidRangeBounds
+	}
+
+	var (
+		startNum  = new(big.Int).SetBytes(start[:])
+		endNum    = new(big.Int).SetBytes(end[:])
+		size      = new(big.Int).Sub(endNum, startNum)
+		partSize  = new(big.Int).Div(size, big.NewInt(int64(numPartitions)))
+		splits    = make([]uuid.UUID, numPartitions)
+		cursor    = new(big.Int).Set(startNum)
+		splitUUID uuid.UUID
+	)
+	for i := 0; i < numPartitions; i++ {
+		if i == numPartitions-1 {
+			// The last partition absorbs any rounding error.
+			splits[i] = end
+			break
+		}
+
+		cursor.Add(cursor, partSize)
+		splitUUID = uuid.UUID{}
+		cursor.FillBytes(splitUUID[:])
+		splits[i] = splitUUID
+	}
+
+	return Range{start: start, rangeSplits: splits}, nil
+}
+
+// Extents returns the [start, end) extents of the entire range.
+func (r Range) Extents() (uuid.UUID, uuid.UUID) {
+	return r.start, r.rangeSplits[len(r.rangeSplits)-1]
+}
+
+// NumPartitions returns the number of partitions the range is split into.
+func (r Range) NumPartitions() int {
+	return len(r.rangeSplits)
+}
+
+// PartitionExtents returns the [start, end) extents for the specified
+// partition.
+func (r Range) PartitionExtents(partition int) (uuid.UUID, uuid.UUID, error) {
+	if partition < 0 || partition >= len(r.rangeSplits) {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("partition %d: %w", partition, ErrInvalidPartition)
+	}
+
+	if partition == 0 {
+		return r.start, r.rangeSplits[0], nil
+	}
+	return r.rangeSplits[partition-1], r.rangeSplits[partition], nil
+}
+
+// PartitionForID returns the index of the partition that id belongs to.
+func (r Range) PartitionForID(id uuid.UUID) (int, error) {
+	start, end := r.Extents()
+	if bytes.Compare(id[:], start[:]) < 0 || bytes.Compare(id[:], end[:]) >= 0 {
+		return -1, fmt.Errorf("partition for %s: %w", id, ErrOutOfRange)
+	}
+
+	// Find the first split point that is greater than id.
+	lo, hi := 0, len(r.rangeSplits)-1
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if bytes.Compare(id[:], r.rangeSplits[mid][:]) < 0 {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo, nil
+}
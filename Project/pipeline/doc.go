@@ -0,0 +1,17 @@
+// Package pipeline provides a generic, multi-stage data processing pipeline.
+//
+// A Pipeline reads Payload values from a Source, passes each one through a
+// sequence of stages and delivers the results to a Sink. Each stage wraps a
+// Processor and is executed by a StageRunner that controls its concurrency:
+//
+//   - FIFO processes payloads one at a time, preserving their order.
+//   - FixedWorkerPool processes payloads using a fixed number of workers.
+//   - DynamicWorkerPool spins up workers on demand, up to a maximum.
+//   - Broadcast sends a copy of each payload to multiple processors.
+//
+// Processors may drop a payload by returning nil, in which case the pipeline
+// calls MarkAsProcessed on it so that payload implementations can return
+// themselves to an object pool. Any error returned by a stage, the source or
+// the sink terminates the pipeline; Process returns all errors that were
+// encountered.
+package pipeline
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"webcrawler/crawler"
+	"webcrawler/crawler/extract"
+	"webcrawler/crawler/iterutil"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/recrawl"
+	"webcrawler/crawler/region"
+	"webcrawler/crawler/scope"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+	"webcrawler/partition"
+	"webcrawler/urlutil/normalizer"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+// CrawlerGraphAPI defines the set of link graph operations required by the
+// crawler service.
+type CrawlerGraphAPI interface {
+	graph.CheckpointStore
+
+	// UpsertLink creates a new link or updates an existing link.
+	UpsertLink(link *graph.Link) error
+
+	// UpsertEdge creates a new edge or updates an existing edge.
+	UpsertEdge(edge *graph.Edge) error
+
+	// RemoveStaleEdges removes any edge that originates from the specified
+	// link ID and was updated before the specified unix timestamp.
+	RemoveStaleEdges(fromID uuid.UUID, updatedBefore int64) error
+
+	// Links returns an iterator for the set of links whose IDs belong to
+	// the [fromID, toID) range and are due to be fetched before the
+	// provided unix timestamp.
+	Links(fromID, toID uuid.UUID, dueBefore int64) (graph.LinkIterator, error)
+}
+
+// CrawlerConfig encapsulates the settings for the crawler service.
+type CrawlerConfig struct {
+	// The link graph to crawl. It also stores the crawl checkpoints.
+	GraphAPI CrawlerGraphAPI
+
+	// The text indexer for the crawled pages.
+	IndexAPI crawler.Indexer
+
+	// The detector for the partition of the link graph that is crawled
+	// by this instance.
+	PartitionDetector partition.Detector
+
+	// An optional Router for region-aware crawling. If specified, links
+	// whose host is assigned to a different region are skipped and the
+	// PartitionDetector must only split the link graph between the
+	// instances of this instance's region.
+	Region *region.Router
+
+	// A PrivateNetworkDetector instance.
+	PrivateNetworkDetector crawler.PrivateNetworkDetector
+
+	// A URLGetter instance for fetching links.
+	URLGetter crawler.URLGetter
+
+	// An optional Fetcher that is used instead of the URLGetter for
+	// fetching links; see crawler.Config.
+	Fetcher crawler.Fetcher
+
+	// An optional RobotsPolicy for skipping links that are disallowed by
+	// the robots.txt file of their host.
+	Robots crawler.RobotsPolicy
+
+	// An optional Pacer for spacing out the requests to each host; see
+	// crawler.Config.
+	Pacer crawler.Pacer
+
+	// The number of concurrent workers used for retrieving links.
+	FetchWorkers int
+
+	// How often a new crawl pass is started.
+	UpdateInterval time.Duration
+
+	// The amount of time before a link is re-crawled if no RecrawlPolicy
+	// is specified.
+	ReIndexThreshold time.Duration
+
+	// An optional policy for scheduling the next fetch of each crawled
+	// link; see crawler.Config. If not specified, links are re-crawled
+	// once ReIndexThreshold has elapsed.
+	RecrawlPolicy crawler.RecrawlPolicy
+
+	// Optional limits for each crawl pass. A pass that exceeds its budget
+	// is resumed when the next pass is due.
+	Budget crawler.Budget
+
+	// The maximum size of a decoded response body; see crawler.Config.
+	MaxBodySize int64
+
+	// The maximum time to wait for in-flight links to drain once the
+	// service is stopped. Zero waits indefinitely.
+	ShutdownDrainTimeout time.Duration
+
+	// Optional lists of response headers to capture, URL rewrite rules
+	// and per-domain extraction profiles; see crawler.Config.
+	HeaderRules        []crawler.HeaderRule
+	URLRewriteRules    []crawler.URLRewriteRule
+	ExtractionProfiles []extract.Profile
+
+	// An optional normalizer and scope for the discovered links and an
+	// optional detector for near-duplicate pages; see crawler.Config.
+	URLNormalizer *normalizer.Normalizer
+	Scope         *scope.Scope
+	Deduplicator  crawler.Deduplicator
+
+	// An optional archiver for the responses of the fetched pages; see
+	// crawler.Config.
+	Archiver crawler.Archiver
+
+	// If true, the resources referenced by each page are added to the
+	// link graph; see crawler.Config.
+	ExtractResourceLinks bool
+
+	// If true, the page-level robots directives are ignored; see
+	// crawler.Config.
+	IgnoreRobotsDirectives bool
+
+	// An optional recorder for the errors encountered while crawling; see
+	// crawler.Config.
+	Errors crawler.ErrorRecorder
+
+	// An optional tracker for scheduling retries of the links that could
+	// not be fetched; see crawler.Config.
+	Failures crawler.FailureTracker
+
+	// An optional list of components whose pending writes are flushed at
+	// the end of each pass.
+	Flushers []crawler.Flusher
+
+	// An optional queue for distributing the links of each pass among a
+	// pool of QueueWorker instances. If specified, each pass publishes
+	// its due links to the queue instead of crawling them.
+	Queue LinkPublisher
+
+	// The amount of time for which the links that were published to
+	// Queue are not published again while they wait to be crawled.
+	// Defaults to UpdateInterval.
+	QueueLease time.Duration
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *CrawlerConfig) validate() error {
+	var err error
+	if cfg.GraphAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("graph API has not been provided"))
+	}
+	if cfg.IndexAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("index API has not been provided"))
+	}
+	if cfg.PartitionDetector == nil {
+		err = multierror.Append(err, fmt.Errorf("partition detector has not been provided"))
+	}
+	if cfg.PrivateNetworkDetector == nil {
+		err = multierror.Append(err, fmt.Errorf("private network detector has not been provided"))
+	}
+	if cfg.URLGetter == nil {
+		err = multierror.Append(err, fmt.Errorf("URL getter has not been provided"))
+	}
+	if cfg.FetchWorkers <= 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid number of fetch workers"))
+	}
+	if cfg.UpdateInterval <= 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid update interval"))
+	}
+	if cfg.ReIndexThreshold < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid re-index threshold"))
+	}
+	if cfg.MaxBodySize < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid max body size"))
+	}
+	if cfg.ShutdownDrainTimeout < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid shutdown drain timeout"))
+	}
+	if cfg.QueueLease < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid queue lease"))
+	} else if cfg.QueueLease == 0 {
+		cfg.QueueLease = cfg.UpdateInterval
+	}
+	return err
+}
+
+// Crawler periodically crawls the links in the partition of the link graph
+// that is assigned to this instance. Each pass is checkpointed so that a pass
+// that is interrupted (e.g. because the service was stopped) is resumed after
+// a restart.
+type Crawler struct {
+	cfg    CrawlerConfig
+	logger *slog.Logger
+}
+
+// NewCrawler returns a new crawler service instance using the provided
+// config.
+func NewCrawler(cfg CrawlerConfig) (*Crawler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("crawler service: config validation failed: %w", err)
+	}
+
+	return &Crawler{cfg: cfg, logger: logging.Component(cfg.Logger, "service.crawler")}, nil
+}
+
+// Name implements Service.
+func (svc *Crawler) Name() string { return "crawler" }
+
+// Run implements Service. When ctx is cancelled, the pass in progress stops
+// feeding new links into the crawler pipeline and waits for the links that are
+// in flight to drain before it is checkpointed.
+func (svc *Crawler) Run(ctx context.Context) error {
+	svc.logger.Info("starting service", "update_interval", svc.cfg.UpdateInterval, "fetch_workers", svc.cfg.FetchWorkers)
+	defer svc.logger.Info("stopped service")
+
+	return runPeriodically(ctx, svc.cfg.UpdateInterval, svc.crawlPass)
+}
+
+// RunPass executes a single crawl pass for the partition assigned to this
+// instance and returns once it has completed. As with the passes executed by
+// Run, failures are logged rather than returned.
+func (svc *Crawler) RunPass(ctx context.Context) error {
+	return svc.crawlPass(ctx)
+}
+
+// crawlPass executes the next crawl pass for the partition assigned to this
+// instance. Pass failures are logged and the pass is retried when the next
+// one is due.
+func (svc *Crawler) crawlPass(ctx context.Context) error {
+	curPartition, fromID, toID, err := svc.assignedPartition(ctx)
+	if errors.Is(err, partition.ErrNoPartitionDataAvailableYet) {
+		svc.logger.Warn("deferring crawl pass until partition data is available")
+		return nil
+	} else if err != nil {
+		svc.logger.Error("unable to detect partition", "err", err)
+		return nil
+	}
+
+	sc, err := crawler.NewShutdownCoordinator(crawler.ShutdownConfig{
+		Checkpoints:  svc.cfg.GraphAPI,
+		Partition:    curPartition,
+		Flushers:     svc.cfg.Flushers,
+		DrainTimeout: svc.cfg.ShutdownDrainTimeout,
+		Logger:       svc.cfg.Logger,
+	})
+	if err != nil {
+		return err
+	}
+	pass, err := sc.NextPass()
+	if err != nil {
+		svc.logger.Error("unable to determine next crawl pass", "partition", curPartition, "err", err)
+		return nil
+	}
+
+	// The crawl context is detached from ctx so that in-flight links can
+	// drain once ctx is cancelled; it is only cancelled if they do not
+	// drain within the configured timeout.
+	stop := context.AfterFunc(ctx, sc.Shutdown)
+	defer stop()
+	crawlCtx, cancel := sc.Context(context.WithoutCancel(ctx))
+	defer cancel()
+
+	report, err := svc.crawl(crawlCtx, sc, pass, fromID, toID)
+	if err != nil {
+		// Failed passes are checkpointed as interrupted so that they
+		// are resumed.
+		svc.logger.Error("crawl pass failed", "partition", curPartition, "pass_id", pass.ID, "err", err)
+		report = nil
+	} else {
+		svc.logger.Info("crawl pass finished", "partition", curPartition, "pass_id", pass.ID, "resumed", pass.Resumed,
+			"status", report.Status, "processed", report.Processed, "bytes_fetched", report.BytesFetched, "elapsed", report.Elapsed)
+	}
+	if err = sc.Complete(pass, report); err != nil {
+		svc.logger.Error("unable to checkpoint crawl pass", "partition", curPartition, "pass_id", pass.ID, "err", err)
+	}
+	return nil
+}
+
+// assignedPartition returns the partition assigned to this instance and its
+// [fromID, toID) extents.
+func (svc *Crawler) assignedPartition(ctx context.Context) (int, uuid.UUID, uuid.UUID, error) {
+	curPartition, numPartitions, err := svc.cfg.PartitionDetector.PartitionInfo(ctx)
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, err
+	}
+	r, err := partition.NewFullRange(numPartitions)
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, err
+	}
+	fromID, toID, err := r.PartitionExtents(curPartition)
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, err
+	}
+	return curPartition, fromID, toID, nil
+}
+
+// crawl sends the links in [fromID, toID) that are due for a re-crawl
+// through a crawler pipeline for pass.
+func (svc *Crawler) crawl(ctx context.Context, sc *crawler.ShutdownCoordinator, pass crawler.Pass, fromID, toID uuid.UUID) (*crawler.Report, error) {
+	// Links that were crawled by a resumed pass before it was interrupted
+	// have been scheduled for a fetch after the pass started and are
+	// skipped.
+	linkIt, err := svc.cfg.GraphAPI.Links(fromID, toID, pass.StartedAt.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = linkIt.Close() }()
+	if svc.cfg.Region != nil {
+		linkIt = iterutil.FilterLinks(linkIt, svc.acceptedByRegion)
+	}
+	if svc.cfg.Queue != nil {
+		return svc.dispatch(ctx, sc, pass, linkIt)
+	}
+
+	cfg := svc.PipelineConfig()
+	cfg.PassID = pass.ID
+	cfg.Shutdown = sc
+	return crawler.NewCrawler(cfg).CrawlWithBudget(ctx, linkIt, svc.cfg.Budget)
+}
+
+// PipelineConfig returns the settings of the crawler pipeline that crawls
+// each pass without the settings of a particular pass (the pass ID and
+// shutdown coordinator). It allows other components such as crawl jobs to
+// crawl links the same way as the service.
+func (svc *Crawler) PipelineConfig() crawler.Config {
+	policy := svc.cfg.RecrawlPolicy
+	if policy == nil {
+		policy = recrawl.FixedInterval(svc.cfg.ReIndexThreshold)
+	}
+	return crawler.Config{
+		PrivateNetworkDetector: svc.cfg.PrivateNetworkDetector,
+		URLGetter:              svc.cfg.URLGetter,
+		Fetcher:                svc.cfg.Fetcher,
+		Robots:                 svc.cfg.Robots,
+		Pacer:                  svc.cfg.Pacer,
+		Graph:                  crawlerGraph{svc.cfg.GraphAPI},
+		Indexer:                svc.cfg.IndexAPI,
+		FetchWorkers:           svc.cfg.FetchWorkers,
+		MaxBodySize:            svc.cfg.MaxBodySize,
+		HeaderRules:            svc.cfg.HeaderRules,
+		Archiver:               svc.cfg.Archiver,
+		URLRewriteRules:        svc.cfg.URLRewriteRules,
+		ExtractionProfiles:     svc.cfg.ExtractionProfiles,
+		URLNormalizer:          svc.cfg.URLNormalizer,
+		Scope:                  svc.cfg.Scope,
+		ExtractResourceLinks:   svc.cfg.ExtractResourceLinks,
+		IgnoreRobotsDirectives: svc.cfg.IgnoreRobotsDirectives,
+		Deduplicator:           svc.cfg.Deduplicator,
+		RecrawlPolicy:          policy,
+		Errors:                 svc.cfg.Errors,
+		Failures:               svc.cfg.Failures,
+		Logger:                 svc.cfg.Logger,
+	}
+}
+
+// acceptedByRegion returns true if link is crawled by the instances of this
+// instance's region.
+func (svc *Crawler) acceptedByRegion(link *graph.Link) bool {
+	if svc.cfg.Region.Accepts(link.URL) {
+		return true
+	}
+	metrics.RegionSkippedLinks.Inc()
+	return false
+}
+
+// crawlerGraph adapts a CrawlerGraphAPI to the crawler.Graph interface. The
+// link graph stores express timestamps as unix seconds.
+type crawlerGraph struct {
+	CrawlerGraphAPI
+}
+
+// RemoveStaleEdges implements crawler.Graph.
+func (g crawlerGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore time.Time) error {
+	return g.CrawlerGraphAPI.RemoveStaleEdges(fromID, updatedBefore.Unix())
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+	"webcrawler/frontend"
+	"webcrawler/frontend/access"
+	"webcrawler/frontend/gqlapi"
+	"webcrawler/logging"
+	"webcrawler/urlutil/normalizer"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// The maximum time to wait for in-flight requests to complete when the
+// frontend service is stopped.
+const frontendShutdownTimeout = 10 * time.Second
+
+// FrontendGraphAPI defines the set of link graph operations required by the
+// frontend service.
+type FrontendGraphAPI interface {
+	frontend.GraphAPI
+	gqlapi.GraphAPI
+}
+
+// FrontendIndexAPI defines the set of text indexer operations required by the
+// frontend service.
+type FrontendIndexAPI interface {
+	frontend.IndexAPI
+	gqlapi.IndexAPI
+}
+
+// FrontendConfig encapsulates the settings for the frontend service.
+type FrontendConfig struct {
+	// The link graph that submitted links are added to.
+	GraphAPI FrontendGraphAPI
+
+	// The text indexer to search.
+	IndexAPI FrontendIndexAPI
+
+	// The address to listen for HTTP requests on.
+	ListenAddress string
+
+	// The number of search results per page.
+	ResultsPerPage int
+
+	// Limits for the GraphQL endpoint; see gqlapi.Config.
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+
+	// An optional PageRank score history for serving the score trend API.
+	ScoreHistory frontend.ScoreHistoryAPI
+
+	// An optional normalizer for submitted links.
+	URLNormalizer *normalizer.Normalizer
+
+	// An optional SLO tracker for serving the SLO indicators at
+	// /metrics/slo.
+	SLO frontend.SLOAPI
+
+	// Optional precomputed page feeds for serving the feed API.
+	Feeds frontend.FeedsAPI
+
+	// An optional policy that controls which document fields are visible
+	// to each API key.
+	Access *access.Policy
+
+	// Optional health and readiness checks that are reported at /healthz
+	// and /readyz; see frontend.Config.
+	HealthChecks    map[string]frontend.HealthChecker
+	ReadinessChecks map[string]frontend.HealthChecker
+
+	// An optional handler for the admin API which is served below
+	// /admin/.
+	Admin http.Handler
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *FrontendConfig) validate() error {
+	var err error
+	if cfg.GraphAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("graph API has not been provided"))
+	}
+	if cfg.IndexAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("index API has not been provided"))
+	}
+	if cfg.ListenAddress == "" {
+		err = multierror.Append(err, fmt.Errorf("listen address has not been specified"))
+	}
+	return err
+}
+
+// Frontend serves the search engine frontend, the GraphQL API (at /graphql)
+// and optionally the admin API (below /admin/) over HTTP.
+type Frontend struct {
+	cfg     FrontendConfig
+	handler http.Handler
+	logger  *slog.Logger
+}
+
+// NewFrontend returns a new frontend service instance using the provided
+// config.
+func NewFrontend(cfg FrontendConfig) (*Frontend, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("frontend service: config validation failed: %w", err)
+	}
+
+	feHandler, err := frontend.NewHandler(frontend.Config{
+		GraphAPI:        cfg.GraphAPI,
+		IndexAPI:        cfg.IndexAPI,
+		ResultsPerPage:  cfg.ResultsPerPage,
+		ScoreHistory:    cfg.ScoreHistory,
+		URLNormalizer:   cfg.URLNormalizer,
+		SLO:             cfg.SLO,
+		Feeds:           cfg.Feeds,
+		Access:          cfg.Access,
+		HealthChecks:    cfg.HealthChecks,
+		ReadinessChecks: cfg.ReadinessChecks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("frontend service: %w", err)
+	}
+	gqlHandler, err := gqlapi.NewHandler(gqlapi.Config{
+		GraphAPI:      cfg.GraphAPI,
+		IndexAPI:      cfg.IndexAPI,
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+		Access:        cfg.Access,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("frontend service: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", gqlHandler)
+	if cfg.Admin != nil {
+		mux.Handle("/admin/", http.StripPrefix("/admin", cfg.Admin))
+	}
+	mux.Handle("/", feHandler)
+	return &Frontend{cfg: cfg, handler: mux, logger: logging.Component(cfg.Logger, "service.frontend")}, nil
+}
+
+// Name implements Service.
+func (svc *Frontend) Name() string { return "frontend" }
+
+// Run implements Service.
+func (svc *Frontend) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", svc.cfg.ListenAddress)
+	if err != nil {
+		return err
+	}
+	return svc.Serve(ctx, l)
+}
+
+// Serve accepts HTTP connections on l until ctx is cancelled. Once ctx is
+// cancelled, in-flight requests are given a grace period to complete.
+func (svc *Frontend) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{Handler: svc.handler}
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), frontendShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	})
+	defer stop()
+
+	svc.logger.Info("starting service", "listen_address", l.Addr().String())
+	defer svc.logger.Info("stopped service")
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+	"webcrawler/logging"
+	"webcrawler/tracing"
+
+	"github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+)
+
+// The maximum time to wait for in-flight RPCs to complete when the gRPC
+// server is stopped.
+const grpcShutdownTimeout = 10 * time.Second
+
+// GRPCServerConfig encapsulates the settings for the gRPC server.
+type GRPCServerConfig struct {
+	// The address to listen for gRPC connections on.
+	ListenAddress string
+
+	// The functions that register the served APIs with the server (e.g.
+	// a closure over proto.RegisterLinkIngestionServer). At least one
+	// must be provided.
+	Services []func(*grpc.Server)
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *GRPCServerConfig) validate() error {
+	var err error
+	if cfg.ListenAddress == "" {
+		err = multierror.Append(err, fmt.Errorf("listen address has not been specified"))
+	}
+	if len(cfg.Services) == 0 {
+		err = multierror.Append(err, fmt.Errorf("no gRPC services have been provided"))
+	}
+	return err
+}
+
+// GRPCServer serves the gRPC APIs of the stores and the link ingester to
+// the services running in other processes.
+type GRPCServer struct {
+	cfg    GRPCServerConfig
+	srv    *grpc.Server
+	logger *slog.Logger
+}
+
+// NewGRPCServer returns a new gRPC server instance using the provided config.
+func NewGRPCServer(cfg GRPCServerConfig) (*GRPCServer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("grpc server: config validation failed: %w", err)
+	}
+
+	srv := grpc.NewServer(tracing.ServerOptions()...)
+	for _, register := range cfg.Services {
+		register(srv)
+	}
+	return &GRPCServer{cfg: cfg, srv: srv, logger: logging.Component(cfg.Logger, "service.grpc")}, nil
+}
+
+// Name implements Service.
+func (svc *GRPCServer) Name() string { return "grpc" }
+
+// Run implements Service.
+func (svc *GRPCServer) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", svc.cfg.ListenAddress)
+	if err != nil {
+		return err
+	}
+	return svc.Serve(ctx, l)
+}
+
+// Serve accepts gRPC connections on l until ctx is cancelled. Once ctx is
+// cancelled, in-flight RPCs are given a grace period to complete.
+func (svc *GRPCServer) Serve(ctx context.Context, l net.Listener) error {
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.AfterFunc(grpcShutdownTimeout, svc.srv.Stop)
+		defer timer.Stop()
+		svc.srv.GracefulStop()
+	})
+	defer stop()
+
+	svc.logger.Info("starting service", "listen_address", l.Addr().String())
+	defer svc.logger.Info("stopped service")
+	if err := svc.srv.Serve(l); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"webcrawler/health"
+	"webcrawler/logging"
+	"webcrawler/metrics"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// HealthServerConfig encapsulates the settings for the health server.
+type HealthServerConfig struct {
+	// The address to listen for HTTP requests on.
+	ListenAddress string
+
+	// Optional health checks that are reported at /healthz, keyed by the
+	// name of the checked component.
+	HealthChecks map[string]health.Checker
+
+	// Optional readiness checks (e.g. whether the stores can be reached)
+	// that are reported at /readyz, keyed by the name of the checked
+	// component.
+	ReadinessChecks map[string]health.Checker
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *HealthServerConfig) validate() error {
+	var err error
+	if cfg.ListenAddress == "" {
+		err = multierror.Append(err, fmt.Errorf("listen address has not been specified"))
+	}
+	return err
+}
+
+// HealthServer serves the health (/healthz) and readiness (/readyz) reports
+// and the metrics (/metrics) of the services that do not serve HTTP requests
+// themselves, such as the crawler and the PageRank calculator.
+type HealthServer struct {
+	cfg     HealthServerConfig
+	handler http.Handler
+	logger  *slog.Logger
+}
+
+// NewHealthServer returns a new health server instance using the provided
+// config.
+func NewHealthServer(cfg HealthServerConfig) (*HealthServer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("health server: config validation failed: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", health.Handler(cfg.HealthChecks))
+	mux.Handle("GET /readyz", health.Handler(cfg.ReadinessChecks))
+	mux.Handle("GET /metrics", metrics.Handler())
+	return &HealthServer{cfg: cfg, handler: mux, logger: logging.Component(cfg.Logger, "service.health")}, nil
+}
+
+// Name implements Service.
+func (svc *HealthServer) Name() string { return "health" }
+
+// Run implements Service.
+func (svc *HealthServer) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", svc.cfg.ListenAddress)
+	if err != nil {
+		return err
+	}
+	return svc.Serve(ctx, l)
+}
+
+// Serve accepts HTTP connections on l until ctx is cancelled.
+func (svc *HealthServer) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{Handler: svc.handler}
+	stop := context.AfterFunc(ctx, func() { _ = srv.Close() })
+	defer stop()
+
+	svc.logger.Info("starting service", "listen_address", l.Addr().String())
+	defer svc.logger.Info("stopped service")
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
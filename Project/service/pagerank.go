@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+	"webcrawler/bspgraph"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/textindexer/index"
+	"webcrawler/logging"
+	"webcrawler/pagerank"
+	"webcrawler/pagerank/history"
+	"webcrawler/partition"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+)
+
+// latestPass is used for loading a snapshot of the link graph that includes
+// the links and edges added by all crawl passes so far. It is only used for
+// graphs that do not keep track of crawl checkpoints and is capped to the
+// range of the signed 64-bit pass-ID columns of the SQL stores.
+const latestPass = math.MaxInt64
+
+// scoreBatchSize is the number of scores written per request to text indexers
+// that support bulk score updates.
+const scoreBatchSize = 500
+
+// PageRankIndexAPI defines the set of text indexer operations required by the
+// PageRank service. If the text indexer also implements
+// index.BulkScoreUpdater, scores are written in batches.
+type PageRankIndexAPI interface {
+	// UpdateScore updates the PageRank score for a document with the
+	// specified link ID.
+	UpdateScore(linkID uuid.UUID, score float64) error
+}
+
+// PageRankConfig encapsulates the settings for the PageRank service.
+type PageRankConfig struct {
+	// The link graph to calculate the scores for. If it implements
+	// graph.Snapshotter, each pass loads a snapshot of the graph so that
+	// the crawler can keep updating the graph in the meantime. If it
+	// implements graph.CheckpointStore, the scores are calculated for the
+	// links and edges as of the most recent crawl pass that all partitions
+	// have completed and, if it also implements graph.RemovalPruner, the
+	// edge removals of that and earlier passes are pruned afterwards.
+	GraphAPI bspgraph.LinkGraphSource
+
+	// The text indexer that stores the calculated scores.
+	IndexAPI PageRankIndexAPI
+
+	// The number of workers used for executing each superstep.
+	ComputeWorkers int
+
+	// How often the scores are recalculated.
+	UpdateInterval time.Duration
+
+	// An optional store for recording the scores computed by each pass.
+	History history.Store
+
+	// The number of passes to keep in the history. If zero, all passes
+	// are kept.
+	HistoryPasses int
+
+	// If set, edges that were created by nofollow links do not pass on
+	// any score.
+	SkipNoFollowEdges bool
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *PageRankConfig) validate() error {
+	var err error
+	if cfg.GraphAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("graph API has not been provided"))
+	}
+	if cfg.IndexAPI == nil {
+		err = multierror.Append(err, fmt.Errorf("index API has not been provided"))
+	}
+	if cfg.ComputeWorkers <= 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid number of compute workers"))
+	}
+	if cfg.UpdateInterval <= 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid update interval"))
+	}
+	if cfg.HistoryPasses < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid number of history passes"))
+	}
+	return err
+}
+
+// PageRank periodically calculates the PageRank scores for all links in the
+// link graph and updates the scores of the corresponding indexed documents.
+type PageRank struct {
+	cfg    PageRankConfig
+	calc   *pagerank.Calculator
+	logger *slog.Logger
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewPageRank returns a new PageRank service instance using the provided
+// config.
+func NewPageRank(cfg PageRankConfig) (*PageRank, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("pagerank service: config validation failed: %w", err)
+	}
+
+	calc, err := pagerank.NewCalculator(pagerank.Config{
+		ComputeWorkers: cfg.ComputeWorkers,
+		Logger:         cfg.Logger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pagerank service: %w", err)
+	}
+
+	return &PageRank{cfg: cfg, calc: calc, logger: logging.Component(cfg.Logger, "service.pagerank")}, nil
+}
+
+// Name implements Service.
+func (svc *PageRank) Name() string { return "pagerank" }
+
+// Run implements Service.
+func (svc *PageRank) Run(ctx context.Context) error {
+	svc.logger.Info("starting service", "update_interval", svc.cfg.UpdateInterval, "compute_workers", svc.cfg.ComputeWorkers)
+	defer svc.logger.Info("stopped service")
+	defer func() { _ = svc.Close() }()
+
+	return runPeriodically(ctx, svc.cfg.UpdateInterval, func(ctx context.Context) error {
+		if err := svc.updateScores(ctx); err != nil && ctx.Err() == nil {
+			// The scores are recalculated when the next update is
+			// due.
+			svc.logger.Error("unable to update PageRank scores", "err", err)
+		}
+		return nil
+	})
+}
+
+// RunPass calculates the scores once and returns once they have been written
+// to the text indexer. It must not be invoked concurrently with Run.
+func (svc *PageRank) RunPass(ctx context.Context) error {
+	return svc.updateScores(ctx)
+}
+
+// Close releases the resources held by the score calculator. Callers that
+// only invoke RunPass must close the service once they are done with it; Run
+// closes the service when it returns.
+func (svc *PageRank) Close() error {
+	svc.closeOnce.Do(func() { svc.closeErr = svc.calc.Close() })
+	return svc.closeErr
+}
+
+// updateScores calculates the scores for a snapshot of the link graph and
+// writes them to the text indexer and the score history.
+func (svc *PageRank) updateScores(ctx context.Context) error {
+	startedAt := time.Now()
+	if err := svc.calc.Graph().Reset(); err != nil {
+		return err
+	}
+
+	// The link hosts are only needed for aggregating the score history.
+	var (
+		hosts  map[string]string
+		initFn bspgraph.VertexInitFunc
+		scores []history.LinkScore
+	)
+	if svc.cfg.History != nil {
+		hosts = make(map[string]string)
+		initFn = func(link *graph.Link) interface{} {
+			hosts[link.ID.String()] = history.Host(link.URL)
+			return nil
+		}
+	}
+	var filterFn bspgraph.EdgeFilterFunc
+	if svc.cfg.SkipNoFollowEdges {
+		filterFn = func(edge *graph.Edge) bool { return !edge.NoFollow }
+	}
+	passID, err := svc.completedPass()
+	if err != nil {
+		return err
+	}
+	var src bspgraph.LinkGraphSource = svc.cfg.GraphAPI
+	if snapshotter, ok := src.(graph.Snapshotter); ok {
+		src = snapshotter.Snapshot()
+	}
+	if err = bspgraph.LoadLinkGraph(svc.calc.Graph(), src, uuid.Nil, partition.MaxUUID, passID, initFn, filterFn); err != nil {
+		return err
+	}
+	if err := svc.calc.Run(ctx); err != nil {
+		return err
+	}
+
+	var (
+		updated int
+		writer  = newScoreWriter(svc.cfg.IndexAPI)
+	)
+	err = svc.calc.Scores(func(id string, score float64) error {
+		linkID, err := uuid.Parse(id)
+		if err != nil {
+			return err
+		}
+		if err = writer.write(linkID, score); err != nil {
+			return err
+		}
+		if hosts != nil {
+			scores = append(scores, history.LinkScore{LinkID: linkID, Host: hosts[id], Score: score})
+		}
+		updated++
+		return nil
+	})
+	if err == nil {
+		err = writer.flush()
+	}
+	if err != nil {
+		return fmt.Errorf("update scores: %w", err)
+	}
+
+	if svc.cfg.History != nil {
+		if _, err = svc.cfg.History.RecordPass(time.Now(), scores); err != nil {
+			return fmt.Errorf("record score history: %w", err)
+		}
+		if svc.cfg.HistoryPasses > 0 {
+			if err = svc.cfg.History.Prune(svc.cfg.HistoryPasses); err != nil {
+				return fmt.Errorf("prune score history: %w", err)
+			}
+		}
+	}
+
+	if err = svc.pruneRemovals(passID); err != nil {
+		return err
+	}
+
+	svc.logger.Info("updated PageRank scores", "links", updated, "pass_id", passID, "elapsed", time.Since(startedAt))
+	return nil
+}
+
+// pruneRemovals discards the edge removals of the specified and earlier
+// passes. Subsequent score updates load the graph as of the same or a later
+// pass and therefore never need them again. Graphs without checkpoints are
+// loaded as of the latest pass and their removals are kept.
+func (svc *PageRank) pruneRemovals(passID uint64) error {
+	pruner, canPrune := svc.cfg.GraphAPI.(graph.RemovalPruner)
+	if _, checkpointed := svc.cfg.GraphAPI.(graph.CheckpointStore); !canPrune || !checkpointed || passID == 0 {
+		return nil
+	}
+	if err := pruner.PruneRemovals(passID); err != nil {
+		return fmt.Errorf("prune edge removals: %w", err)
+	}
+	return nil
+}
+
+// completedPass returns the crawl pass that the link graph is loaded as of.
+// Links and edges added by passes that are still in progress are excluded so
+// that the scores are calculated for a consistent view of the graph.
+func (svc *PageRank) completedPass() (uint64, error) {
+	store, ok := svc.cfg.GraphAPI.(graph.CheckpointStore)
+	if !ok {
+		return latestPass, nil
+	}
+	cps, err := store.Checkpoints()
+	if err != nil {
+		return 0, fmt.Errorf("load crawl checkpoints: %w", err)
+	}
+	return graph.CompletedPass(cps), nil
+}
+
+// scoreWriter writes PageRank scores to the text indexer. Scores are batched
+// if the text indexer supports bulk score updates.
+type scoreWriter struct {
+	api   PageRankIndexAPI
+	bulk  index.BulkScoreUpdater
+	batch map[uuid.UUID]float64
+}
+
+func newScoreWriter(api PageRankIndexAPI) *scoreWriter {
+	w := &scoreWriter{api: api}
+	if bulk, ok := api.(index.BulkScoreUpdater); ok {
+		w.bulk = bulk
+		w.batch = make(map[uuid.UUID]float64, scoreBatchSize)
+	}
+	return w
+}
+
+// write updates the score of the specified link or adds it to the current
+// batch, flushing the batch once it is full.
+func (w *scoreWriter) write(linkID uuid.UUID, score float64) error {
+	if w.bulk == nil {
+		return w.api.UpdateScore(linkID, score)
+	}
+	w.batch[linkID] = score
+	if len(w.batch) < scoreBatchSize {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush writes the scores in the current batch.
+func (w *scoreWriter) flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	err := w.bulk.UpdateScores(w.batch)
+	w.batch = make(map[uuid.UUID]float64, scoreBatchSize)
+	return err
+}
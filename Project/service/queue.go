@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"webcrawler/crawler"
+	"webcrawler/crawler/linkgraph/graph"
+	"webcrawler/crawler/queue"
+	"webcrawler/logging"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// The number of links that are leased and published together by a crawl pass
+// that dispatches its links to a queue.
+const dispatchBatchSize = 100
+
+// LinkPublisher is implemented by queues that distribute the links of each
+// crawl pass among a pool of workers, such as queue.LinkQueue.
+type LinkPublisher interface {
+	// Publish appends links to the queue.
+	Publish(ctx context.Context, links ...*graph.Link) error
+}
+
+// LinkConsumer is implemented by queues from which a QueueWorker receives the
+// links to crawl, such as queue.LinkQueue.
+type LinkConsumer interface {
+	// Consume invokes crawl for each batch of links that is received
+	// from the queue until ctx is cancelled.
+	Consume(ctx context.Context, crawl queue.CrawlFunc) error
+}
+
+// dispatch publishes the links of linkIt to the configured queue instead of
+// crawling them.
+//
+// Before a link is published, it is leased by pushing its next fetch time
+// QueueLease into the future so that subsequent passes do not publish it again
+// while it waits to be crawled. The lease is superseded once a worker crawls
+// the link; links whose messages are lost are published again once their
+// lease expires.
+func (svc *Crawler) dispatch(ctx context.Context, sc *crawler.ShutdownCoordinator, pass crawler.Pass, linkIt graph.LinkIterator) (*crawler.Report, error) {
+	start := time.Now()
+	report := new(crawler.Report)
+	batch := make([]*graph.Link, 0, dispatchBatchSize)
+	publish := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		leasedUntil := time.Now().Add(svc.cfg.QueueLease).Unix()
+		for _, link := range batch {
+			leased := *link
+			leased.NextFetchAt = leasedUntil
+			leased.PassID = pass.ID
+			if err := svc.cfg.GraphAPI.UpsertLink(&leased); err != nil {
+				return fmt.Errorf("lease link %s: %w", link.ID, err)
+			}
+		}
+		if err := svc.cfg.Queue.Publish(ctx, batch...); err != nil {
+			return err
+		}
+		report.Processed += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+dispatchLoop:
+	for linkIt.Next() {
+		select {
+		case <-sc.Draining():
+			report.Status = crawler.CrawlInterrupted
+			break dispatchLoop
+		default:
+		}
+		link := *linkIt.Link()
+		if batch = append(batch, &link); len(batch) == dispatchBatchSize {
+			if err := publish(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := linkIt.Error(); err != nil {
+		return nil, err
+	}
+	if err := publish(); err != nil {
+		return nil, err
+	}
+	report.Elapsed = time.Since(start)
+	return report, nil
+}
+
+// QueueWorkerConfig encapsulates the settings for the queue worker service.
+type QueueWorkerConfig struct {
+	// The queue from which the links to crawl are received.
+	Queue LinkConsumer
+
+	// The settings of the crawler pipeline that crawls each batch of
+	// links; see Crawler.PipelineConfig.
+	Pipeline crawler.Config
+
+	// An optional gate that the worker enters before it crawls each batch
+	// of links and leaves once the batch has been crawled.
+	Gate crawler.Gate
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *QueueWorkerConfig) validate() error {
+	var err error
+	if cfg.Queue == nil {
+		err = multierror.Append(err, fmt.Errorf("link queue has not been provided"))
+	}
+	return err
+}
+
+// QueueWorker crawls the links that are published to a link queue by the
+// crawl passes of the crawler services. Multiple workers share the links of
+// the queue between them; see queue.LinkQueue for the delivery guarantees.
+type QueueWorker struct {
+	cfg    QueueWorkerConfig
+	logger *slog.Logger
+}
+
+// NewQueueWorker returns a new queue worker service instance using the
+// provided config.
+func NewQueueWorker(cfg QueueWorkerConfig) (*QueueWorker, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("queue worker service: config validation failed: %w", err)
+	}
+
+	return &QueueWorker{cfg: cfg, logger: logging.Component(cfg.Logger, "service.queue-worker")}, nil
+}
+
+// Name implements Service.
+func (svc *QueueWorker) Name() string { return "queue-worker" }
+
+// Run implements Service.
+func (svc *QueueWorker) Run(ctx context.Context) error {
+	svc.logger.Info("starting service", "fetch_workers", svc.cfg.Pipeline.FetchWorkers)
+	defer svc.logger.Info("stopped service")
+
+	return svc.cfg.Queue.Consume(ctx, svc.crawl)
+}
+
+// crawl sends a batch of links received from the queue through a crawler
+// pipeline.
+func (svc *QueueWorker) crawl(ctx context.Context, linkIt graph.LinkIterator) error {
+	if svc.cfg.Gate != nil {
+		leave, err := svc.cfg.Gate.Enter(ctx)
+		if err != nil {
+			return err
+		}
+		defer leave()
+	}
+
+	processed, err := crawler.NewCrawler(svc.cfg.Pipeline).Crawl(ctx, linkIt)
+	if err != nil {
+		svc.logger.Error("unable to crawl links", "err", err)
+		return err
+	}
+	svc.logger.Debug("crawled links", "processed", processed)
+	return nil
+}
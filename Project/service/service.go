@@ -0,0 +1,72 @@
+// Package service implements the long-running webcrawler services (crawler,
+// PageRank calculator and frontend) on top of the building blocks provided by
+// the other packages. Each service runs until its context is cancelled; a
+// Group runs multiple services in the same process.
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Service is implemented by long-running services.
+type Service interface {
+	// Name returns the name of the service.
+	Name() string
+
+	// Run executes the service and blocks until ctx is cancelled or an
+	// unrecoverable error occurs.
+	Run(ctx context.Context) error
+}
+
+// Group is a list of services that are executed concurrently.
+type Group []Service
+
+// Run executes all services in the group and blocks until all of them have
+// returned. If any service returns, the context passed to the remaining
+// services is cancelled so that the whole group shuts down. Run returns the
+// first error that was reported by a service.
+func (g Group) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		errOnce sync.Once
+		err     error
+	)
+	for _, s := range g {
+		wg.Add(1)
+		go func(s Service) {
+			defer wg.Done()
+			if sErr := s.Run(ctx); sErr != nil {
+				errOnce.Do(func() { err = fmt.Errorf("%s: %w", s.Name(), sErr) })
+			}
+			cancel()
+		}(s)
+	}
+	wg.Wait()
+	return err
+}
+
+// runPeriodically invokes fn immediately and then every interval until ctx
+// is cancelled or fn returns an error.
+func runPeriodically(ctx context.Context, interval time.Duration, fn func(context.Context) error) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,574 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/crawler/ingest"
+	ingestproto "webcrawler/crawler/ingest/proto"
+	"webcrawler/crawler/linkgraph/graph"
+	memgraph "webcrawler/crawler/linkgraph/store/memory"
+	"webcrawler/crawler/queue"
+	"webcrawler/crawler/region"
+	memidx "webcrawler/crawler/textindexer/store/memory"
+	"webcrawler/health"
+	"webcrawler/pagerank/history"
+	"webcrawler/partition"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(ServiceTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type ServiceTestSuite struct{}
+
+func (s *ServiceTestSuite) TestCrawler(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, `<html><head><title>A title</title></head><body>Hello world</body></html>`)
+	}))
+	defer srv.Close()
+
+	g := memgraph.NewInMemoryGraph()
+	link := &graph.Link{URL: srv.URL}
+	c.Assert(g.UpsertLink(link), gc.IsNil)
+	indexer, err := memidx.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = indexer.Close() }()
+
+	svc, err := NewCrawler(CrawlerConfig{
+		GraphAPI:               g,
+		IndexAPI:               indexer,
+		PartitionDetector:      partition.NewStaticDetector("", nil),
+		PrivateNetworkDetector: publicNetworkDetector{},
+		URLGetter:              srv.Client(),
+		FetchWorkers:           2,
+		UpdateInterval:         time.Hour,
+	})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- svc.Run(ctx) }()
+
+	waitFor(c, func() bool {
+		cp, err := g.Checkpoint(0)
+		return err == nil && cp.Completed()
+	})
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+
+	doc, err := indexer.FindByID(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Title, gc.Equals, "A title")
+	cp, err := g.Checkpoint(0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cp.PassID, gc.Equals, uint64(1))
+}
+
+func (s *ServiceTestSuite) TestCrawlerSkipsLinksOfOtherRegions(c *gc.C) {
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.Host)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, `<html><head><title>A title</title></head><body>Hello world</body></html>`)
+	}))
+	defer srv.Close()
+
+	g := memgraph.NewInMemoryGraph()
+	usLink := &graph.Link{URL: srv.URL}
+	euLink := &graph.Link{URL: strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)}
+	c.Assert(g.UpsertLink(usLink), gc.IsNil)
+	c.Assert(g.UpsertLink(euLink), gc.IsNil)
+	indexer, err := memidx.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = indexer.Close() }()
+	router, err := region.New(region.Config{
+		Self:    "us",
+		Default: "us",
+		Domains: []region.DomainTag{{Domain: "localhost", Region: "eu"}},
+	})
+	c.Assert(err, gc.IsNil)
+
+	svc, err := NewCrawler(CrawlerConfig{
+		GraphAPI:               g,
+		IndexAPI:               indexer,
+		PartitionDetector:      partition.NewStaticDetector("", nil),
+		Region:                 router,
+		PrivateNetworkDetector: publicNetworkDetector{},
+		URLGetter:              srv.Client(),
+		FetchWorkers:           2,
+		UpdateInterval:         time.Hour,
+	})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- svc.Run(ctx) }()
+
+	waitFor(c, func() bool {
+		cp, err := g.Checkpoint(0)
+		return err == nil && cp.Completed()
+	})
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+
+	_, err = indexer.FindByID(usLink.ID)
+	c.Assert(err, gc.IsNil)
+	_, err = indexer.FindByID(euLink.ID)
+	c.Assert(err, gc.NotNil)
+	mu.Lock()
+	defer mu.Unlock()
+	c.Assert(requested, gc.DeepEquals, []string{strings.TrimPrefix(srv.URL, "http://")})
+}
+
+func (s *ServiceTestSuite) TestCrawlerDispatchesLinksToQueue(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, `<html><head><title>A title</title></head><body>Hello world</body></html>`)
+	}))
+	defer srv.Close()
+
+	g := memgraph.NewInMemoryGraph()
+	link := &graph.Link{URL: srv.URL}
+	c.Assert(g.UpsertLink(link), gc.IsNil)
+	indexer, err := memidx.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = indexer.Close() }()
+	broker := queue.NewMemoryBroker()
+	defer func() { _ = broker.Close() }()
+	linkQueue, err := queue.NewLinkQueue(queue.LinkQueueConfig{
+		Broker:      broker,
+		Topic:       "links",
+		Group:       "crawlers",
+		MaxAttempts: 3,
+		BatchSize:   10,
+		BatchWait:   10 * time.Millisecond,
+	})
+	c.Assert(err, gc.IsNil)
+
+	crawlerSvc, err := NewCrawler(CrawlerConfig{
+		GraphAPI:               g,
+		IndexAPI:               indexer,
+		PartitionDetector:      partition.NewStaticDetector("", nil),
+		PrivateNetworkDetector: publicNetworkDetector{},
+		URLGetter:              srv.Client(),
+		FetchWorkers:           2,
+		UpdateInterval:         time.Hour,
+		Queue:                  linkQueue,
+	})
+	c.Assert(err, gc.IsNil)
+
+	// The pass publishes the link and leases it so that the next pass
+	// does not publish it again.
+	c.Assert(crawlerSvc.RunPass(context.Background()), gc.IsNil)
+	c.Assert(broker.Messages("links"), gc.HasLen, 1)
+	leased, err := g.FindLink(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(leased.NextFetchAt > time.Now().Add(59*time.Minute).Unix(), gc.Equals, true)
+	c.Assert(crawlerSvc.RunPass(context.Background()), gc.IsNil)
+	c.Assert(broker.Messages("links"), gc.HasLen, 1)
+	_, err = indexer.FindByID(link.ID)
+	c.Assert(err, gc.NotNil)
+
+	gate := new(countingGate)
+	worker, err := NewQueueWorker(QueueWorkerConfig{Queue: linkQueue, Pipeline: crawlerSvc.PipelineConfig(), Gate: gate})
+	c.Assert(err, gc.IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- worker.Run(ctx) }()
+
+	waitFor(c, func() bool {
+		_, err := indexer.FindByID(link.ID)
+		return err == nil
+	})
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+
+	doc, err := indexer.FindByID(link.ID)
+	c.Assert(err, gc.IsNil)
+	c.Assert(doc.Title, gc.Equals, "A title")
+
+	// Each batch is crawled while the worker holds the gate.
+	c.Assert(atomic.LoadInt32(&gate.entered) > 0, gc.Equals, true)
+	c.Assert(atomic.LoadInt32(&gate.left), gc.Equals, atomic.LoadInt32(&gate.entered))
+}
+
+func (s *ServiceTestSuite) TestPageRank(c *gc.C) {
+	g := memgraph.NewInMemoryGraph()
+	links := make([]*graph.Link, 3)
+	for i := range links {
+		links[i] = &graph.Link{URL: fmt.Sprintf("http://example.com/%d", i)}
+		c.Assert(g.UpsertLink(links[i]), gc.IsNil)
+	}
+	for i := range links {
+		dst := links[(i+1)%len(links)]
+		c.Assert(g.UpsertEdge(&graph.Edge{Src: links[i].ID, Dst: dst.ID}), gc.IsNil)
+	}
+
+	indexer := newScoreRecorder()
+	hist := history.NewMemoryStore()
+	svc, err := NewPageRank(PageRankConfig{
+		GraphAPI:       g,
+		IndexAPI:       indexer,
+		ComputeWorkers: 2,
+		UpdateInterval: time.Hour,
+		History:        hist,
+		HistoryPasses:  10,
+	})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- svc.Run(ctx) }()
+
+	waitFor(c, func() bool {
+		points, err := hist.DomainTrend("example.com", time.Time{})
+		return err == nil && len(points) == 1
+	})
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+
+	points, err := hist.DomainTrend("example.com", time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(points[0].Links, gc.Equals, len(links))
+	c.Assert(points[0].Score > 0.999 && points[0].Score < 1.001, gc.Equals, true, gc.Commentf("unexpected domain score %f", points[0].Score))
+
+	for id, score := range indexer.snapshot() {
+		c.Assert(score > 0.333 && score < 0.334, gc.Equals, true, gc.Commentf("unexpected score %f for link %s", score, id))
+	}
+}
+
+func (s *ServiceTestSuite) TestPageRankBulkScoreUpdates(c *gc.C) {
+	g := memgraph.NewInMemoryGraph()
+	numLinks := scoreBatchSize + 10
+	for i := 0; i < numLinks; i++ {
+		c.Assert(g.UpsertLink(&graph.Link{URL: fmt.Sprintf("http://example.com/%d", i)}), gc.IsNil)
+	}
+
+	indexer := &bulkScoreRecorder{scoreRecorder: newScoreRecorder()}
+	svc, err := NewPageRank(PageRankConfig{
+		GraphAPI:       g,
+		IndexAPI:       indexer,
+		ComputeWorkers: 2,
+		UpdateInterval: time.Hour,
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc.RunPass(context.Background()), gc.IsNil)
+
+	c.Assert(indexer.snapshot(), gc.HasLen, numLinks)
+	c.Assert(indexer.batchSizes, gc.DeepEquals, []int{scoreBatchSize, 10})
+	c.Assert(indexer.singleUpdates, gc.Equals, 0)
+}
+
+func (s *ServiceTestSuite) TestPageRankLoadsGraphSnapshot(c *gc.C) {
+	g := &snapshotCounter{InMemoryGraph: memgraph.NewInMemoryGraph()}
+	for i := 0; i < 3; i++ {
+		c.Assert(g.UpsertLink(&graph.Link{URL: fmt.Sprintf("http://example.com/%d", i)}), gc.IsNil)
+	}
+
+	indexer := newScoreRecorder()
+	svc, err := NewPageRank(PageRankConfig{
+		GraphAPI:       g,
+		IndexAPI:       indexer,
+		ComputeWorkers: 2,
+		UpdateInterval: time.Hour,
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc.RunPass(context.Background()), gc.IsNil)
+
+	c.Assert(g.snapshots, gc.Equals, 1)
+	c.Assert(indexer.snapshot(), gc.HasLen, 3)
+}
+
+func (s *ServiceTestSuite) TestPageRankSkipsPassesInProgress(c *gc.C) {
+	g := &pruneRecorder{InMemoryGraph: memgraph.NewInMemoryGraph()}
+	crawled := &graph.Link{URL: "http://example.com/crawled", PassID: 1}
+	inProgress := &graph.Link{URL: "http://example.com/in-progress", PassID: 2}
+	c.Assert(g.UpsertLink(crawled), gc.IsNil)
+	c.Assert(g.UpsertLink(inProgress), gc.IsNil)
+
+	// Partition 0 completed pass 1 while partition 1 is still crawling
+	// pass 2.
+	now := time.Now()
+	c.Assert(g.SaveCheckpoint(&graph.Checkpoint{Partition: 0, PassID: 2, PassStartedAt: now, CompletedAt: now}), gc.IsNil)
+	c.Assert(g.SaveCheckpoint(&graph.Checkpoint{Partition: 1, PassID: 2, PassStartedAt: now}), gc.IsNil)
+
+	indexer := newScoreRecorder()
+	svc, err := NewPageRank(PageRankConfig{
+		GraphAPI:       g,
+		IndexAPI:       indexer,
+		ComputeWorkers: 2,
+		UpdateInterval: time.Hour,
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(svc.RunPass(context.Background()), gc.IsNil)
+
+	scores := indexer.snapshot()
+	c.Assert(scores, gc.HasLen, 1)
+	_, found := scores[crawled.ID]
+	c.Assert(found, gc.Equals, true)
+
+	// The edge removals of the completed pass are no longer needed.
+	c.Assert(g.pruned, gc.DeepEquals, []uint64{1})
+}
+
+func (s *ServiceTestSuite) TestFrontend(c *gc.C) {
+	indexer, err := memidx.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = indexer.Close() }()
+
+	admin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/backups" {
+			http.NotFound(w, r)
+		}
+	})
+	svc, err := NewFrontend(FrontendConfig{
+		GraphAPI:      memgraph.NewInMemoryGraph(),
+		IndexAPI:      indexer,
+		ListenAddress: "127.0.0.1:0",
+		Admin:         admin,
+	})
+	c.Assert(err, gc.IsNil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, gc.IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- svc.Serve(ctx, l) }()
+
+	for _, path := range []string{"/", "/graphql?query={__typename}", "/admin/backups"} {
+		res, err := http.Get("http://" + l.Addr().String() + path)
+		c.Assert(err, gc.IsNil)
+		_ = res.Body.Close()
+		c.Assert(res.StatusCode, gc.Equals, http.StatusOK, gc.Commentf("path %q", path))
+	}
+
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+}
+
+func (s *ServiceTestSuite) TestHealthServer(c *gc.C) {
+	svc, err := NewHealthServer(HealthServerConfig{
+		ListenAddress: "127.0.0.1:0",
+		ReadinessChecks: map[string]health.Checker{
+			"linkgraph": health.CheckerFunc(func() (interface{}, error) { return nil, errors.New("connection refused") }),
+		},
+	})
+	c.Assert(err, gc.IsNil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, gc.IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- svc.Serve(ctx, l) }()
+
+	for path, status := range map[string]int{
+		"/healthz": http.StatusOK,
+		"/readyz":  http.StatusServiceUnavailable,
+		"/metrics": http.StatusOK,
+		"/":        http.StatusNotFound,
+	} {
+		res, err := http.Get("http://" + l.Addr().String() + path)
+		c.Assert(err, gc.IsNil)
+		_ = res.Body.Close()
+		c.Assert(res.StatusCode, gc.Equals, status, gc.Commentf("path %q", path))
+	}
+
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+}
+
+func (s *ServiceTestSuite) TestGRPCServer(c *gc.C) {
+	g := memgraph.NewInMemoryGraph()
+	ingester, err := ingest.NewIngester(ingest.Config{Graph: g, PrivateNetworkDetector: publicNetworkDetector{}})
+	c.Assert(err, gc.IsNil)
+	svc, err := NewGRPCServer(GRPCServerConfig{
+		ListenAddress: "127.0.0.1:0",
+		Services: []func(*grpc.Server){
+			func(srv *grpc.Server) { ingestproto.RegisterLinkIngestionServer(srv, ingest.NewGRPCServer(ingester)) },
+		},
+	})
+	c.Assert(err, gc.IsNil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, gc.IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- svc.Serve(ctx, l) }()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	c.Assert(err, gc.IsNil)
+	defer func() { _ = conn.Close() }()
+	stream, err := ingestproto.NewLinkIngestionClient(conn).IngestLinks(context.TODO())
+	c.Assert(err, gc.IsNil)
+	c.Assert(stream.Send(&ingestproto.LinkRecord{Url: "http://example.com/"}), gc.IsNil)
+	summary, err := stream.CloseAndRecv()
+	c.Assert(err, gc.IsNil)
+	c.Assert(summary.Accepted, gc.Equals, uint64(1))
+
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+}
+
+func (s *ServiceTestSuite) TestGroupStopsWhenAServiceFails(c *gc.C) {
+	var blocked blockingService
+	err := Group{&blocked, failingService{}}.Run(context.Background())
+	c.Assert(err, gc.ErrorMatches, "failing: boom")
+	c.Assert(blocked.stopped, gc.Equals, true)
+}
+
+func (s *ServiceTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := NewCrawler(CrawlerConfig{FetchWorkers: -1})
+	c.Assert(err, gc.ErrorMatches, "(?s)crawler service: config validation failed:.*graph API.*index API.*partition detector.*private network detector.*URL getter.*fetch workers.*update interval.*")
+
+	_, err = NewPageRank(PageRankConfig{})
+	c.Assert(err, gc.ErrorMatches, "(?s)pagerank service: config validation failed:.*graph API.*index API.*compute workers.*update interval.*")
+
+	_, err = NewFrontend(FrontendConfig{})
+	c.Assert(err, gc.ErrorMatches, "(?s)frontend service: config validation failed:.*graph API.*index API.*listen address.*")
+
+	_, err = NewHealthServer(HealthServerConfig{})
+	c.Assert(err, gc.ErrorMatches, "(?s)health server: config validation failed:.*listen address.*")
+
+	_, err = NewGRPCServer(GRPCServerConfig{})
+	c.Assert(err, gc.ErrorMatches, "(?s)grpc server: config validation failed:.*listen address.*gRPC services.*")
+}
+
+// waitFor polls cond until it returns true or a timeout expires.
+func waitFor(c *gc.C, cond func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			c.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// countingGate is a crawler.Gate that counts how often it was entered and
+// left.
+type countingGate struct {
+	entered, left int32
+}
+
+func (g *countingGate) Enter(context.Context) (func(), error) {
+	atomic.AddInt32(&g.entered, 1)
+	return func() { atomic.AddInt32(&g.left, 1) }, nil
+}
+
+type publicNetworkDetector struct{}
+
+func (publicNetworkDetector) IsPrivate(string) (bool, error) { return false, nil }
+
+type scoreRecorder struct {
+	mu     sync.Mutex
+	scores map[uuid.UUID]float64
+}
+
+func newScoreRecorder() *scoreRecorder {
+	return &scoreRecorder{scores: make(map[uuid.UUID]float64)}
+}
+
+func (r *scoreRecorder) UpdateScore(linkID uuid.UUID, score float64) error {
+	r.mu.Lock()
+	r.scores[linkID] = score
+	r.mu.Unlock()
+	return nil
+}
+
+// bulkScoreRecorder is a scoreRecorder that implements index.BulkScoreUpdater
+// and records the size of each batch of updates.
+type bulkScoreRecorder struct {
+	*scoreRecorder
+	batchSizes    []int
+	singleUpdates int
+}
+
+func (r *bulkScoreRecorder) UpdateScore(linkID uuid.UUID, score float64) error {
+	r.singleUpdates++
+	return r.scoreRecorder.UpdateScore(linkID, score)
+}
+
+func (r *bulkScoreRecorder) UpdateScores(scores map[uuid.UUID]float64) error {
+	r.batchSizes = append(r.batchSizes, len(scores))
+	for linkID, score := range scores {
+		_ = r.scoreRecorder.UpdateScore(linkID, score)
+	}
+	return nil
+}
+
+func (r *scoreRecorder) snapshot() map[uuid.UUID]float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scores := make(map[uuid.UUID]float64, len(r.scores))
+	for id, score := range r.scores {
+		scores[id] = score
+	}
+	return scores
+}
+
+type blockingService struct{ stopped bool }
+
+func (*blockingService) Name() string { return "blocking" }
+
+func (s *blockingService) Run(ctx context.Context) error {
+	<-ctx.Done()
+	s.stopped = true
+	return nil
+}
+
+type failingService struct{}
+
+func (failingService) Name() string { return "failing" }
+
+func (failingService) Run(context.Context) error { return errors.New("boom") }
+
+// snapshotCounter is an in-memory graph that counts the snapshots taken.
+type snapshotCounter struct {
+	*memgraph.InMemoryGraph
+	snapshots int
+}
+
+func (g *snapshotCounter) Snapshot() graph.Snapshot {
+	g.snapshots++
+	return g.InMemoryGraph.Snapshot()
+}
+
+// pruneRecorder is an in-memory graph that records the passes whose edge
+// removals are pruned.
+type pruneRecorder struct {
+	*memgraph.InMemoryGraph
+	pruned []uint64
+}
+
+func (g *pruneRecorder) PruneRemovals(passID uint64) error {
+	g.pruned = append(g.pruned, passID)
+	return g.InMemoryGraph.PruneRemovals(passID)
+}
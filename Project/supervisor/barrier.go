@@ -0,0 +1,147 @@
+package supervisor
+
+import (
+	"context"
+	"sync"
+	"webcrawler/crawler"
+)
+
+// Compile-time check to ensure Barrier implements crawler.Gate.
+var _ crawler.Gate = (*Barrier)(nil)
+
+// Barrier synchronizes shared and exclusive passes. Any number of shared
+// passes may run at the same time while an exclusive pass only runs when no
+// other pass is running.
+//
+// Besides the passes scheduled by a Supervisor, services that update the
+// link graph outside of the crawl passes (e.g. crawl jobs) can enter the
+// barrier as a shared pass via Enter so that exclusive passes do not overlap
+// with them either.
+//
+// The barrier is fair to both kinds of passes: exclusive passes that are
+// waiting for the barrier take precedence over shared passes that have not
+// started yet, and the shared passes that were waiting while an exclusive
+// pass ran are admitted before the next exclusive pass.
+type Barrier struct {
+	mu        sync.Mutex
+	shared    int
+	exclusive bool
+
+	waitingShared    int
+	waitingExclusive int
+
+	// The number of waiting shared passes that are admitted before the
+	// next exclusive pass.
+	admitShared int
+
+	// Closed and replaced whenever the state of the barrier changes.
+	changed chan struct{}
+}
+
+// NewBarrier returns a new Barrier instance.
+func NewBarrier() *Barrier {
+	return &Barrier{changed: make(chan struct{})}
+}
+
+// Enter implements crawler.Gate. It blocks until a shared pass can run or ctx
+// is cancelled.
+func (b *Barrier) Enter(ctx context.Context) (leave func(), err error) {
+	if err = b.acquire(ctx, false); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() { once.Do(func() { b.release(false) }) }, nil
+}
+
+// acquire blocks until a shared or exclusive pass can run or ctx is
+// cancelled. Callers must invoke release once the pass has returned.
+func (b *Barrier) acquire(ctx context.Context, exclusive bool) error {
+	waiting := false
+	for {
+		b.mu.Lock()
+		if b.tryAcquire(exclusive, waiting) {
+			if waiting {
+				b.stopWaiting(exclusive)
+			}
+			b.notify()
+			b.mu.Unlock()
+			return nil
+		}
+		if !waiting {
+			if exclusive {
+				b.waitingExclusive++
+			} else {
+				b.waitingShared++
+			}
+			waiting = true
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			b.mu.Lock()
+			b.stopWaiting(exclusive)
+			b.notify()
+			b.mu.Unlock()
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// tryAcquire acquires the barrier if possible. It must be called while
+// holding b.mu.
+func (b *Barrier) tryAcquire(exclusive, waiting bool) bool {
+	switch {
+	case b.exclusive:
+		return false
+	case exclusive:
+		if b.shared != 0 || b.admitShared != 0 {
+			return false
+		}
+		b.exclusive = true
+		return true
+	case waiting && b.admitShared != 0:
+		b.admitShared--
+		b.shared++
+		return true
+	case b.waitingExclusive == 0:
+		b.shared++
+		return true
+	default:
+		return false
+	}
+}
+
+// stopWaiting updates the waiting pass counters once a pass no longer waits
+// for the barrier. It must be called while holding b.mu.
+func (b *Barrier) stopWaiting(exclusive bool) {
+	if exclusive {
+		b.waitingExclusive--
+		return
+	}
+	b.waitingShared--
+	b.admitShared = min(b.admitShared, b.waitingShared)
+}
+
+// release releases the barrier acquired by a shared or exclusive pass.
+func (b *Barrier) release(exclusive bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if exclusive {
+		b.exclusive = false
+		b.admitShared = b.waitingShared
+	} else {
+		b.shared--
+	}
+	b.notify()
+}
+
+// notify wakes up the passes that are waiting for the barrier. It must be
+// called while holding b.mu.
+func (b *Barrier) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,251 @@
+// Package supervisor orchestrates the services that run in a single process
+// (monolith mode).
+//
+// The supervisor schedules the periodic passes of the batch services (e.g.
+// crawl and PageRank passes) itself instead of letting each service run its
+// own timer. This allows it to synchronize them: a pass that is marked as
+// exclusive never overlaps with any other pass, so that, for instance,
+// PageRank scores are never calculated while a crawl pass is still
+// updating the link graph. Passes that must only be executed by a single
+// instance at a time can be restricted to the instance that holds the
+// leadership according to a LeaderElector.
+//
+// Long-running services (e.g. the frontend) are run alongside the passes.
+// When the supervisor is stopped, no new passes are started and the services
+// keep running until the passes in progress have returned so that, for
+// instance, the frontend keeps serving requests while a crawl pass is
+// draining. If any service fails, everything is stopped.
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+	"webcrawler/logging"
+	"webcrawler/service"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Compile-time check to ensure Supervisor implements service.Service.
+var _ service.Service = (*Supervisor)(nil)
+
+// PassRunner is implemented by services that can execute a single pass of
+// their work on demand, such as service.Crawler and service.PageRank.
+type PassRunner interface {
+	RunPass(ctx context.Context) error
+}
+
+// LeaderElector is implemented by objects that decide which of the instances
+// running the same passes holds the leadership.
+type LeaderElector interface {
+	// IsLeader returns true if this instance currently holds the
+	// leadership. It is invoked before each leader-only pass.
+	IsLeader(ctx context.Context) (bool, error)
+}
+
+// LeaderFunc adapts a function to the LeaderElector interface.
+type LeaderFunc func(ctx context.Context) (bool, error)
+
+// IsLeader implements LeaderElector.
+func (f LeaderFunc) IsLeader(ctx context.Context) (bool, error) { return f(ctx) }
+
+// Pass describes a periodic pass that is scheduled by the supervisor.
+type Pass struct {
+	// The name of the pass, used for logging.
+	Name string
+
+	// The runner that executes the pass. If it implements io.Closer, it
+	// is closed once the supervisor stops.
+	Runner PassRunner
+
+	// The time between the start of consecutive passes. The first pass
+	// starts immediately. If a pass takes longer than the interval, the
+	// next one starts as soon as it returns.
+	Interval time.Duration
+
+	// If set, the pass waits for all other passes to return before it
+	// starts and no other pass starts until it returns.
+	Exclusive bool
+
+	// If set, the pass is skipped unless this instance holds the
+	// leadership.
+	LeaderOnly bool
+}
+
+// Config encapsulates the settings for a Supervisor.
+type Config struct {
+	// The periodic passes to schedule.
+	Passes []Pass
+
+	// The long-running services to run alongside the passes.
+	Services []service.Service
+
+	// An optional leader elector that is consulted before each
+	// leader-only pass. If not specified, this instance is assumed to be
+	// the only one and therefore always holds the leadership.
+	Elector LeaderElector
+
+	// An optional barrier that synchronizes the passes. It allows services
+	// that update the link graph outside of the passes to hold back the
+	// exclusive passes; see Barrier.Enter. If not specified, a new barrier
+	// is created.
+	Barrier *Barrier
+
+	// An optional logger. If not specified, nothing is logged.
+	Logger *slog.Logger
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	if len(cfg.Passes) == 0 && len(cfg.Services) == 0 {
+		err = multierror.Append(err, fmt.Errorf("no passes or services have been provided"))
+	}
+	for i, p := range cfg.Passes {
+		if p.Name == "" {
+			err = multierror.Append(err, fmt.Errorf("pass %d: name has not been specified", i))
+		}
+		if p.Runner == nil {
+			err = multierror.Append(err, fmt.Errorf("pass %d: runner has not been provided", i))
+		}
+		if p.Interval <= 0 {
+			err = multierror.Append(err, fmt.Errorf("pass %d: invalid interval", i))
+		}
+	}
+	return err
+}
+
+// Supervisor runs a set of periodic passes and long-running services in the
+// same process.
+type Supervisor struct {
+	cfg     Config
+	barrier *Barrier
+	logger  *slog.Logger
+}
+
+// New returns a new supervisor using the provided config.
+func New(cfg Config) (*Supervisor, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("supervisor: config validation failed: %w", err)
+	}
+	if cfg.Barrier == nil {
+		cfg.Barrier = NewBarrier()
+	}
+	if cfg.Elector == nil {
+		cfg.Elector = LeaderFunc(func(context.Context) (bool, error) { return true, nil })
+	}
+
+	return &Supervisor{
+		cfg:     cfg,
+		barrier: cfg.Barrier,
+		logger:  logging.Component(cfg.Logger, "supervisor"),
+	}, nil
+}
+
+// Name implements service.Service.
+func (s *Supervisor) Name() string { return "supervisor" }
+
+// Run implements service.Service. It schedules the passes and runs the
+// services until ctx is cancelled or a service fails. Once ctx is cancelled,
+// the passes in progress are allowed to return before the services are
+// stopped. Run returns the first error that was reported by a service.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.logger.Info("starting supervisor", "passes", len(s.cfg.Passes), "services", len(s.cfg.Services))
+	defer s.logger.Info("stopped supervisor")
+	defer s.closeRunners()
+
+	// The services outlive ctx until the passes have returned but are
+	// stopped right away if one of them fails.
+	svcCtx, stopServices := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopServices()
+	passCtx, stopPasses := context.WithCancel(ctx)
+	defer stopPasses()
+
+	svcErrCh := make(chan error, 1)
+	go func() {
+		err := service.Group(s.cfg.Services).Run(svcCtx)
+		if err != nil || len(s.cfg.Services) != 0 {
+			// The services only return on their own if one of them
+			// failed.
+			stopPasses()
+		}
+		svcErrCh <- err
+	}()
+
+	var wg sync.WaitGroup
+	for _, p := range s.cfg.Passes {
+		wg.Add(1)
+		go func(p Pass) {
+			defer wg.Done()
+			s.schedule(passCtx, p)
+		}(p)
+	}
+	wg.Wait()
+
+	stopServices()
+	return <-svcErrCh
+}
+
+// schedule executes p every pass interval until ctx is cancelled.
+func (s *Supervisor) schedule(ctx context.Context, p Pass) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		startedAt := time.Now()
+		s.runPass(ctx, p)
+		timer.Reset(max(p.Interval-time.Since(startedAt), 0))
+	}
+}
+
+// runPass executes a single pass once the barrier allows it to run. Pass
+// failures are logged and the pass is retried when the next one is due.
+func (s *Supervisor) runPass(ctx context.Context, p Pass) {
+	logger := s.logger.With("pass", p.Name)
+	if p.LeaderOnly {
+		leader, err := s.cfg.Elector.IsLeader(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("unable to determine leadership; skipping pass", "err", err)
+			}
+			return
+		} else if !leader {
+			logger.Debug("skipping pass as this instance is not the leader")
+			return
+		}
+	}
+
+	waitStartedAt := time.Now()
+	if err := s.barrier.acquire(ctx, p.Exclusive); err != nil {
+		return
+	}
+	defer s.barrier.release(p.Exclusive)
+
+	startedAt := time.Now()
+	logger.Debug("starting pass", "waited", startedAt.Sub(waitStartedAt))
+	if err := p.Runner.RunPass(ctx); err != nil && ctx.Err() == nil {
+		logger.Error("pass failed", "err", err)
+		return
+	}
+	logger.Debug("pass completed", "elapsed", time.Since(startedAt))
+}
+
+// closeRunners closes the pass runners that hold resources.
+func (s *Supervisor) closeRunners() {
+	for _, p := range s.cfg.Passes {
+		if closer, ok := p.Runner.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				s.logger.Error("unable to close pass runner", "pass", p.Name, "err", err)
+			}
+		}
+	}
+}
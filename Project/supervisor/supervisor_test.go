@@ -0,0 +1,363 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/crawler"
+	"webcrawler/crawler/jobs"
+	memgraph "webcrawler/crawler/linkgraph/store/memory"
+	memidx "webcrawler/crawler/textindexer/store/memory"
+	"webcrawler/service"
+
+	"github.com/google/uuid"
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(SupervisorTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type SupervisorTestSuite struct{}
+
+func (s *SupervisorTestSuite) TestExclusivePassesNeverOverlap(c *gc.C) {
+	var (
+		running    int32
+		overlapped int32
+	)
+	crawl := func(n *int32) PassRunner {
+		return passFunc(func(context.Context) error {
+			atomic.AddInt32(n, 1)
+			atomic.AddInt32(&running, 1)
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+	}
+	var crawlsA, crawlsB, pageRanks int32
+	pageRank := passFunc(func(context.Context) error {
+		atomic.AddInt32(&pageRanks, 1)
+		if atomic.LoadInt32(&running) != 0 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(2 * time.Millisecond)
+		if atomic.LoadInt32(&running) != 0 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		return nil
+	})
+
+	sup, err := New(Config{Passes: []Pass{
+		{Name: "crawl-a", Runner: crawl(&crawlsA), Interval: time.Millisecond},
+		{Name: "crawl-b", Runner: crawl(&crawlsB), Interval: time.Millisecond},
+		{Name: "pagerank", Runner: pageRank, Interval: time.Millisecond, Exclusive: true},
+	}})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- sup.Run(ctx) }()
+	waitFor(c, func() bool {
+		return atomic.LoadInt32(&pageRanks) >= 5 && atomic.LoadInt32(&crawlsA) >= 5 && atomic.LoadInt32(&crawlsB) >= 5
+	})
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+	c.Assert(atomic.LoadInt32(&overlapped), gc.Equals, int32(0), gc.Commentf("PageRank pass ran while a crawl pass was in progress"))
+}
+
+func (s *SupervisorTestSuite) TestJobCrawlsHoldBackExclusivePasses(c *gc.C) {
+	var (
+		fetching   int32
+		overlapped int32
+		pageRanks  int32
+	)
+	fetched, release := make(chan struct{}, 1), make(chan struct{})
+	getter := getterFunc(func(string) (*http.Response, error) {
+		atomic.StoreInt32(&fetching, 1)
+		defer atomic.StoreInt32(&fetching, 0)
+		select {
+		case fetched <- struct{}{}:
+		default:
+		}
+		<-release
+		return &http.Response{StatusCode: http.StatusNotFound, Header: make(http.Header), Body: http.NoBody}, nil
+	})
+
+	barrier := NewBarrier()
+	indexer, err := memidx.NewInMemoryBleveIndexer()
+	c.Assert(err, gc.IsNil)
+	m, err := jobs.NewManager(jobs.Config{
+		Store: jobs.NewInMemoryStore(),
+		Crawler: crawler.Config{
+			PrivateNetworkDetector: publicNetwork{},
+			URLGetter:              getter,
+			Graph:                  crawlerGraph{memgraph.NewInMemoryGraph()},
+			Indexer:                indexer,
+			FetchWorkers:           1,
+		},
+		Gate: barrier,
+	})
+	c.Assert(err, gc.IsNil)
+
+	sup, err := New(Config{
+		Passes: []Pass{{
+			Name: "pagerank",
+			Runner: passFunc(func(context.Context) error {
+				atomic.AddInt32(&pageRanks, 1)
+				if atomic.LoadInt32(&fetching) != 0 {
+					atomic.StoreInt32(&overlapped, 1)
+				}
+				return nil
+			}),
+			Interval:  time.Millisecond,
+			Exclusive: true,
+		}},
+		Services: []service.Service{m},
+		Barrier:  barrier,
+	})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- sup.Run(ctx) }()
+	waitFor(c, func() bool { return atomic.LoadInt32(&pageRanks) > 0 })
+
+	_, err = m.Create(jobs.Spec{Name: "site", Seeds: []string{"http://example.com/"}})
+	c.Assert(err, gc.IsNil)
+	<-fetched
+
+	// The PageRank pass waits for the batch of the job that is being
+	// crawled.
+	passes := atomic.LoadInt32(&pageRanks)
+	time.Sleep(20 * time.Millisecond)
+	c.Assert(atomic.LoadInt32(&pageRanks), gc.Equals, passes)
+
+	close(release)
+	waitFor(c, func() bool { return atomic.LoadInt32(&pageRanks) > passes })
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+	c.Assert(atomic.LoadInt32(&overlapped), gc.Equals, int32(0), gc.Commentf("PageRank pass ran while a job crawl was in progress"))
+}
+
+func (s *SupervisorTestSuite) TestLeaderOnlyPasses(c *gc.C) {
+	var (
+		leader int32
+		passes int32
+	)
+	elector := LeaderFunc(func(context.Context) (bool, error) { return atomic.LoadInt32(&leader) == 1, nil })
+	sup, err := New(Config{
+		Passes: []Pass{{
+			Name:       "pagerank",
+			Runner:     passFunc(func(context.Context) error { atomic.AddInt32(&passes, 1); return nil }),
+			Interval:   time.Millisecond,
+			LeaderOnly: true,
+		}},
+		Elector: elector,
+	})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- sup.Run(ctx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	c.Assert(atomic.LoadInt32(&passes), gc.Equals, int32(0))
+
+	atomic.StoreInt32(&leader, 1)
+	waitFor(c, func() bool { return atomic.LoadInt32(&passes) > 0 })
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+}
+
+func (s *SupervisorTestSuite) TestServicesOutliveDrainingPasses(c *gc.C) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+	started := make(chan struct{})
+	runner := &closablePass{run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // drain in-flight work
+		record("pass returned")
+		return nil
+	}}
+	svc := serviceFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		record("service stopped")
+		return nil
+	})
+
+	sup, err := New(Config{
+		Passes:   []Pass{{Name: "crawl", Runner: runner, Interval: time.Hour}},
+		Services: []service.Service{svc},
+	})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- sup.Run(ctx) }()
+	<-started
+	cancel()
+	c.Assert(<-errCh, gc.IsNil)
+	c.Assert(events, gc.DeepEquals, []string{"pass returned", "service stopped"})
+	c.Assert(runner.closed, gc.Equals, true)
+}
+
+func (s *SupervisorTestSuite) TestServiceFailureStopsPasses(c *gc.C) {
+	passStopped := make(chan struct{})
+	sup, err := New(Config{
+		Passes: []Pass{{
+			Name: "crawl",
+			Runner: passFunc(func(ctx context.Context) error {
+				<-ctx.Done()
+				close(passStopped)
+				return nil
+			}),
+			Interval: time.Hour,
+		}},
+		Services: []service.Service{serviceFunc(func(context.Context) error { return errors.New("boom") })},
+	})
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(sup.Run(context.Background()), gc.ErrorMatches, "test: boom")
+	<-passStopped
+}
+
+func (s *SupervisorTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := New(Config{})
+	c.Assert(err, gc.ErrorMatches, "(?s)supervisor: config validation failed:.*no passes or services.*")
+
+	_, err = New(Config{Passes: []Pass{{}}})
+	c.Assert(err, gc.ErrorMatches, "(?s)supervisor: config validation failed:.*pass 0: name.*pass 0: runner.*pass 0: invalid interval.*")
+}
+
+func (s *SupervisorTestSuite) TestBarrierPrefersWaitingExclusivePasses(c *gc.C) {
+	b := NewBarrier()
+	ctx := context.Background()
+	c.Assert(b.acquire(ctx, false), gc.IsNil)
+
+	exclusiveAcquired := make(chan struct{})
+	go func() {
+		_ = b.acquire(ctx, true)
+		close(exclusiveAcquired)
+	}()
+	waitFor(c, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.waitingExclusive == 1
+	})
+
+	// New shared passes queue up behind the waiting exclusive pass.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	c.Assert(b.acquire(timeoutCtx, false), gc.Equals, context.DeadlineExceeded)
+
+	b.release(false)
+	<-exclusiveAcquired
+	b.release(true)
+	c.Assert(b.acquire(ctx, false), gc.IsNil)
+}
+
+func (s *SupervisorTestSuite) TestBarrierAdmitsWaitingSharedPasses(c *gc.C) {
+	b := NewBarrier()
+	ctx := context.Background()
+	c.Assert(b.acquire(ctx, true), gc.IsNil)
+
+	sharedAcquired := make(chan struct{})
+	go func() {
+		_ = b.acquire(ctx, false)
+		close(sharedAcquired)
+	}()
+	waitFor(c, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.waitingShared == 1
+	})
+
+	// The next exclusive pass waits for the shared pass that was waiting
+	// while the previous one ran.
+	b.release(true)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	c.Assert(b.acquire(timeoutCtx, true), gc.Equals, context.DeadlineExceeded)
+	<-sharedAcquired
+	b.release(false)
+	c.Assert(b.acquire(ctx, true), gc.IsNil)
+}
+
+func (s *SupervisorTestSuite) TestBarrierAbandonedExclusiveWait(c *gc.C) {
+	b := NewBarrier()
+	ctx := context.Background()
+	c.Assert(b.acquire(ctx, false), gc.IsNil)
+
+	// An exclusive pass that gives up waiting no longer blocks shared
+	// passes.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	c.Assert(b.acquire(timeoutCtx, true), gc.Equals, context.DeadlineExceeded)
+	c.Assert(b.acquire(ctx, false), gc.IsNil)
+}
+
+type getterFunc func(string) (*http.Response, error)
+
+func (f getterFunc) Get(url string) (*http.Response, error) { return f(url) }
+
+// publicNetwork is a crawler.PrivateNetworkDetector that treats every host as
+// public.
+type publicNetwork struct{}
+
+func (publicNetwork) IsPrivate(string) (bool, error) { return false, nil }
+
+// crawlerGraph adapts the in-memory link graph to the crawler.Graph
+// interface.
+type crawlerGraph struct {
+	*memgraph.InMemoryGraph
+}
+
+func (g crawlerGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore time.Time) error {
+	return g.InMemoryGraph.RemoveStaleEdges(fromID, updatedBefore.Unix())
+}
+
+type passFunc func(context.Context) error
+
+func (f passFunc) RunPass(ctx context.Context) error { return f(ctx) }
+
+type closablePass struct {
+	run    func(context.Context) error
+	closed bool
+}
+
+func (p *closablePass) RunPass(ctx context.Context) error { return p.run(ctx) }
+
+func (p *closablePass) Close() error {
+	p.closed = true
+	return nil
+}
+
+type serviceFunc func(context.Context) error
+
+func (serviceFunc) Name() string { return "test" }
+
+func (f serviceFunc) Run(ctx context.Context) error { return f(ctx) }
+
+// waitFor polls cond until it returns true or a timeout expires.
+func waitFor(c *gc.C, cond func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			c.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
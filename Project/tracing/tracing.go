@@ -0,0 +1,66 @@
+// Package tracing provides the helpers for instrumenting the webcrawler
+// components with OpenTelemetry. Spans are created via the global tracer
+// provider which discards all spans until a service registers an SDK
+// provider with otel.SetTracerProvider. Traces are propagated across the
+// gRPC APIs using the W3C trace context format when the server and client
+// options returned by ServerOptions and DialOptions are used.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// The prefix shared by the instrumentation scope of all webcrawler tracers.
+const instrumentationPrefix = "webcrawler/"
+
+// Propagator is used for propagating traces across process boundaries.
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Tracer returns the tracer for the specified component (e.g. "crawler").
+func Tracer(component string) trace.Tracer {
+	return otel.Tracer(instrumentationPrefix + component)
+}
+
+// End ends span. If err is not nil, it is recorded in the span and the span
+// status is set to error.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Do invokes fn inside a span with the specified name that is started by
+// tracer as a child of the span in ctx.
+func Do(ctx context.Context, tracer trace.Tracer, name string, fn func() error, attrs ...attribute.KeyValue) error {
+	_, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	err := fn()
+	End(span, err)
+	return err
+}
+
+// ServerOptions returns the options for instrumenting a gRPC server so that
+// each RPC is traced as a child of the trace propagated by the client.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithPropagators(Propagator))),
+	}
+}
+
+// DialOptions returns the options for instrumenting a gRPC client so that
+// the trace associated with the context of each RPC is propagated to the
+// server.
+func DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithPropagators(Propagator))),
+	}
+}
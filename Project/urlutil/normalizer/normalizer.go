@@ -0,0 +1,224 @@
+// Package normalizer maps the different spellings of a URL to a single
+// canonical form so that duplicate links do not end up as separate entries
+// in the link graph.
+//
+// All URLs are normalized by lower-casing the scheme and host, dropping
+// default ports, fragments and empty query strings and resolving dot
+// segments in the path. Depending on the Config, tracking query parameters
+// are stripped, the remaining query parameters are sorted and trailing
+// slashes are trimmed from the path.
+package normalizer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrInvalidURL is returned when a URL cannot be normalized because it is
+// not an absolute http(s) URL.
+var ErrInvalidURL = errors.New("invalid URL")
+
+// DefaultStripParams lists the query parameters that are commonly appended
+// by analytics and advertising tools and do not affect the page content.
+var DefaultStripParams = []string{
+	"utm_*", "gclid", "dclid", "fbclid", "msclkid", "yclid", "mc_cid", "mc_eid", "_ga", "_gl",
+}
+
+// Config encapsulates the settings for a Normalizer.
+type Config struct {
+	// The names of the query parameters to remove. Names are matched
+	// case-insensitively; a trailing "*" matches any parameter with the
+	// preceding prefix (e.g. "utm_*").
+	StripParams []string
+
+	// If set, the query parameters are sorted by name. Parameters with
+	// the same name retain their relative order.
+	SortQuery bool
+
+	// If set, trailing slashes are removed from all paths except the root
+	// path.
+	TrimTrailingSlash bool
+}
+
+func (cfg *Config) validate() error {
+	var err error
+	for i, param := range cfg.StripParams {
+		if strings.TrimSuffix(param, "*") == "" {
+			err = multierror.Append(err, fmt.Errorf("strip param %d: must specify a name or prefix", i))
+		}
+	}
+	return err
+}
+
+// Normalizer converts URLs to their canonical form. It is safe for
+// concurrent use. A nil Normalizer only applies the normalizations that are
+// not configurable.
+type Normalizer struct {
+	cfg Config
+
+	stripNames    map[string]struct{}
+	stripPrefixes []string
+}
+
+// New returns a new Normalizer using the provided config.
+func New(cfg Config) (*Normalizer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("url normalizer: config validation failed: %w", err)
+	}
+
+	n := &Normalizer{cfg: cfg, stripNames: make(map[string]struct{})}
+	for _, param := range cfg.StripParams {
+		param = strings.ToLower(param)
+		if prefix, isPrefix := strings.CutSuffix(param, "*"); isPrefix {
+			n.stripPrefixes = append(n.stripPrefixes, prefix)
+		} else {
+			n.stripNames[param] = struct{}{}
+		}
+	}
+	return n, nil
+}
+
+// Normalize parses rawURL and returns its canonical form. It returns an error
+// wrapping ErrInvalidURL if rawURL is not an absolute http(s) URL.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("%w: missing URL", ErrInvalidURL)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+	return n.NormalizeURL(u).String(), nil
+}
+
+// Resolve resolves ref against base and returns the canonical form of the
+// resulting URL.
+func (n *Normalizer) Resolve(base *url.URL, ref string) (*url.URL, error) {
+	refURL, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	return n.NormalizeURL(base.ResolveReference(refURL)), nil
+}
+
+// NormalizeURL returns a normalized copy of u. The caller is responsible for
+// ensuring that u is an absolute URL.
+func (n *Normalizer) NormalizeURL(u *url.URL) *url.URL {
+	res := *u
+	res.Scheme = strings.ToLower(res.Scheme)
+	res.Host = normalizeHost(res.Scheme, res.Host)
+	res.Fragment, res.RawFragment = "", ""
+	res.ForceQuery = false
+
+	if strings.Contains(res.Path, "/.") {
+		// Resolving the path against the URL itself removes the dot
+		// segments while keeping the query intact.
+		res = *res.ResolveReference(&url.URL{Path: res.Path, RawPath: res.RawPath, RawQuery: res.RawQuery})
+	}
+	if n != nil && n.cfg.TrimTrailingSlash {
+		res.Path = trimTrailingSlash(res.Path)
+		res.RawPath = trimTrailingSlash(res.RawPath)
+	}
+	if res.Path == "" && res.Opaque == "" {
+		res.Path, res.RawPath = "/", ""
+	}
+
+	res.RawQuery = n.normalizeQuery(res.RawQuery)
+	return &res
+}
+
+// normalizeHost lower-cases host and removes the port if it is the default
+// port for scheme.
+func normalizeHost(scheme, host string) string {
+	host = strings.ToLower(host)
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		// host does not specify a port.
+		return strings.TrimSuffix(host, ".")
+	}
+
+	name = strings.TrimSuffix(name, ".")
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") || port == "" {
+		if strings.Contains(name, ":") {
+			return "[" + name + "]"
+		}
+		return name
+	}
+	return net.JoinHostPort(name, port)
+}
+
+func trimTrailingSlash(path string) string {
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+	return path
+}
+
+// normalizeQuery removes the stripped and empty parameters from rawQuery and,
+// if enabled, sorts the remaining ones. The encoding of the retained
+// parameters is preserved.
+func (n *Normalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	type param struct{ name, raw string }
+	var params []param
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if n.strip(name) {
+			continue
+		}
+		params = append(params, param{name: name, raw: raw})
+	}
+
+	if n != nil && n.cfg.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool { return params[i].name < params[j].name })
+	}
+
+	var sb strings.Builder
+	for i, p := range params {
+		if i != 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(p.raw)
+	}
+	return sb.String()
+}
+
+// strip returns true if the query parameter called name should be removed.
+func (n *Normalizer) strip(name string) bool {
+	if n == nil {
+		return false
+	}
+	name = strings.ToLower(name)
+	if _, found := n.stripNames[name]; found {
+		return true
+	}
+	for _, prefix := range n.stripPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
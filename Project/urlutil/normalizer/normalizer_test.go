@@ -0,0 +1,101 @@
+package normalizer
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+var _ = gc.Suite(new(NormalizerTestSuite))
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type NormalizerTestSuite struct{}
+
+func (s *NormalizerTestSuite) TestNormalize(c *gc.C) {
+	n, err := New(Config{
+		StripParams:       DefaultStripParams,
+		SortQuery:         true,
+		TrimTrailingSlash: true,
+	})
+	c.Assert(err, gc.IsNil)
+
+	specs := []struct {
+		in  string
+		exp string
+	}{
+		{in: "HTTP://Example.COM", exp: "http://example.com/"},
+		{in: " https://example.com:443/foo/ ", exp: "https://example.com/foo"},
+		{in: "http://example.com:80/foo#section", exp: "http://example.com/foo"},
+		{in: "http://example.com:8080/", exp: "http://example.com:8080/"},
+		{in: "https://example.com:80/", exp: "https://example.com:80/"},
+		{in: "http://example.com./a/./b/../c//", exp: "http://example.com/a/c"},
+		{in: "http://[::1]:80/", exp: "http://[::1]/"},
+		{in: "http://example.com/?", exp: "http://example.com/"},
+		{in: "http://example.com/?b=2&a=1&b=1", exp: "http://example.com/?a=1&b=2&b=1"},
+		{in: "http://example.com/?utm_source=x&id=7&UTM_Medium=y&fbclid=z", exp: "http://example.com/?id=7"},
+		{in: "http://example.com/?q=a%20b&&gclid=1", exp: "http://example.com/?q=a%20b"},
+		{in: "http://user@Example.com/Path/", exp: "http://user@example.com/Path"},
+	}
+	for specIndex, spec := range specs {
+		got, err := n.Normalize(spec.in)
+		c.Assert(err, gc.IsNil, gc.Commentf("spec %d", specIndex))
+		c.Assert(got, gc.Equals, spec.exp, gc.Commentf("spec %d", specIndex))
+
+		// Normalizing a normalized URL is a no-op.
+		again, err := n.Normalize(got)
+		c.Assert(err, gc.IsNil)
+		c.Assert(again, gc.Equals, got, gc.Commentf("spec %d", specIndex))
+	}
+}
+
+func (s *NormalizerTestSuite) TestNormalizeWithMinimalConfig(c *gc.C) {
+	n, err := New(Config{})
+	c.Assert(err, gc.IsNil)
+
+	for _, n := range []*Normalizer{n, nil} {
+		got, err := n.Normalize("https://Example.com:443/foo/?utm_source=x&b=1&a=2#top")
+		c.Assert(err, gc.IsNil)
+		c.Assert(got, gc.Equals, "https://example.com/foo/?utm_source=x&b=1&a=2")
+	}
+}
+
+func (s *NormalizerTestSuite) TestNormalizeInvalidURLs(c *gc.C) {
+	n, err := New(Config{})
+	c.Assert(err, gc.IsNil)
+
+	for _, in := range []string{"", "ftp://example.com", "/relative/path", "http://", "http://%zz"} {
+		_, err = n.Normalize(in)
+		c.Assert(errors.Is(err, ErrInvalidURL), gc.Equals, true, gc.Commentf("input %q: %v", in, err))
+	}
+}
+
+func (s *NormalizerTestSuite) TestResolve(c *gc.C) {
+	n, err := New(Config{StripParams: []string{"ref"}, TrimTrailingSlash: true})
+	c.Assert(err, gc.IsNil)
+
+	base, err := url.Parse("https://example.com/docs/intro/")
+	c.Assert(err, gc.IsNil)
+
+	specs := []struct {
+		ref string
+		exp string
+	}{
+		{ref: "setup/?ref=nav", exp: "https://example.com/docs/intro/setup"},
+		{ref: "../faq#answers", exp: "https://example.com/docs/faq"},
+		{ref: "//Other.com:443", exp: "https://other.com/"},
+		{ref: "/", exp: "https://example.com/"},
+	}
+	for specIndex, spec := range specs {
+		got, err := n.Resolve(base, spec.ref)
+		c.Assert(err, gc.IsNil)
+		c.Assert(got.String(), gc.Equals, spec.exp, gc.Commentf("spec %d", specIndex))
+	}
+}
+
+func (s *NormalizerTestSuite) TestConfigValidation(c *gc.C) {
+	_, err := New(Config{StripParams: []string{"utm_*", "*", ""}})
+	c.Assert(err, gc.ErrorMatches, "(?s)url normalizer: config validation failed:.*strip param 1.*strip param 2.*")
+}